@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"compliancetoolkit/pkg/anonymize"
+	"compliancetoolkit/pkg/evidencequery"
+	"compliancetoolkit/pkg/fileio"
+)
+
+// runAnonymizeCLI writes copies of evidence logs with host names, user
+// names, addresses and domain names replaced by pseudonyms to the
+// "anonymized" folder of the output directory, for sharing with consultants
+// or vendors. Arguments are evidence logs, directories of them or glob
+// patterns; without arguments the evidence directory is used. With a key
+// file the same host or user gets the same pseudonym in every run.
+func (app *App) runAnonymizeCLI(args []string, keyFile string) bool {
+	var key []byte
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to read pseudonym key: %v\n", err)
+			return false
+		}
+		if key = bytes.TrimSpace(data); len(key) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Pseudonym key file %s is empty\n", keyFile)
+			return false
+		}
+	}
+
+	if len(args) == 0 {
+		args = []string{app.evidenceDir}
+	}
+	files, err := evidencequery.Files(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No evidence logs found\n")
+		return false
+	}
+
+	// Every file is read before any is written, so a host name learned
+	// from one log is also replaced in the text of the others
+	scrubber := anonymize.New(key)
+	documents := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+		if err := scrubber.Learn(data); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", file, err)
+			return false
+		}
+		documents[file] = data
+	}
+
+	outDir := filepath.Join(app.outputDir, "anonymized")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create %s: %v\n", outDir, err)
+		return false
+	}
+	for _, file := range files {
+		scrubbed, err := scrubber.JSON(documents[file])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", file, err)
+			return false
+		}
+		// File names can hold host names too
+		target := filepath.Join(outDir, scrubber.Text(filepath.Base(file)))
+		if err := fileio.WriteFile(target, append(scrubbed, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+		fmt.Printf("  ✅ %s\n", target)
+	}
+
+	fmt.Printf("\nAnonymized %d file(s). Review them before sharing: free text is scrubbed of known names and addresses only.\n", len(files))
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"compliancetoolkit/pkg"
+)
+
+// retentionDirs returns the output directories retention applies to
+func (app *App) retentionDirs() []string {
+	return []string{app.outputDir, app.evidenceDir}
+}
+
+// runCleanupCLI deletes old reports and evidence logs according to
+// reports.retention. Files under a legal hold are kept. With dryRun the
+// files are only listed.
+func (app *App) runCleanupCLI(dryRun bool) bool {
+	retention := app.config.Reports.Retention
+	if !retention.Enabled() {
+		fmt.Println("No retention limits configured (reports.retention); nothing to do")
+		return true
+	}
+
+	results, err := pkg.CleanupOutputDirs(app.retentionDirs(), retention, dryRun, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	failed := 0
+	for _, result := range results {
+		fmt.Printf("%s\n", result.Dir)
+		for _, path := range result.Deleted {
+			if dryRun {
+				fmt.Printf("  ➜ would delete %s\n", path)
+			} else {
+				fmt.Printf("  🗑 deleted %s\n", path)
+			}
+		}
+		for _, path := range result.Held {
+			fmt.Printf("  🔒 kept %s (legal hold)\n", path)
+		}
+		for _, err := range result.Errors {
+			fmt.Printf("  ❌ %v\n", err)
+		}
+		failed += len(result.Errors)
+
+		verb := "freed"
+		if dryRun {
+			verb = "would free"
+		}
+		fmt.Printf("  %d file(s), %s %.1f MB\n", len(result.Deleted), verb, float64(result.FreedBytes)/(1<<20))
+	}
+
+	return failed == 0
+}
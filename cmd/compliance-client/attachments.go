@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// uploadAttachments uploads the files configured in attachments for report
+// to the accepted submission submissionID. Failures are only logged: the
+// submission itself was accepted. Submissions sent later from the cache get
+// no attachments, as the files may have changed since the report ran.
+func (c *ComplianceClient) uploadAttachments(report, submissionID string) {
+	for _, path := range c.attachmentFiles(report, time.Now()) {
+		description := c.attachmentDescription(path)
+		if err := c.uploadAttachment(submissionID, path, description); err != nil {
+			c.logger.Warn("Failed to upload attachment", "submission_id", submissionID, "path", path, "error", err)
+			continue
+		}
+		c.logger.Info("Attachment uploaded", "submission_id", submissionID, "path", path)
+	}
+}
+
+// uploadAttachment uploads one file
+func (c *ComplianceClient) uploadAttachment(submissionID, path, description string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = c.api.UploadAttachment(submissionID, filepath.Base(path), f, description)
+	return err
+}
+
+// attachmentFiles returns the regular files matched by the attachments of
+// report that are recent enough, sorted and without duplicates
+func (c *ComplianceClient) attachmentFiles(report string, now time.Time) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, attachment := range c.config.Attachments {
+		if !attachment.appliesTo(report) {
+			continue
+		}
+		matches, err := filepath.Glob(attachment.Path)
+		if err != nil {
+			c.logger.Warn("Invalid attachment path", "path", attachment.Path, "error", err)
+			continue
+		}
+		if len(matches) == 0 {
+			c.logger.Debug("No files match attachment path", "path", attachment.Path)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.Mode().IsRegular() || seen[match] {
+				continue
+			}
+			if attachment.MaxAge > 0 && now.Sub(info.ModTime()) > attachment.MaxAge {
+				c.logger.Debug("Skipping stale attachment", "path", match, "modified", info.ModTime())
+				continue
+			}
+			seen[match] = true
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// attachmentDescription returns the description of the first attachment
+// whose path matches file
+func (c *ComplianceClient) attachmentDescription(file string) string {
+	for _, attachment := range c.config.Attachments {
+		if matched, _ := filepath.Match(attachment.Path, file); matched {
+			return attachment.Description
+		}
+	}
+	return ""
+}
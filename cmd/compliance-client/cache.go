@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/fileio"
 )
 
 // SubmissionCache provides local storage for submissions when server is unavailable
@@ -47,7 +48,7 @@ func (c *SubmissionCache) Store(submission *api.ComplianceSubmission) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := fileio.WriteFile(filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
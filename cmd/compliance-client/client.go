@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
@@ -8,11 +11,14 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
+	"compliancetoolkit/pkg"
 	"compliancetoolkit/pkg/api"
 )
 
@@ -23,13 +29,46 @@ type ComplianceClient struct {
 	runner *ReportRunner
 	cache  *SubmissionCache
 	api    *api.Client
+
+	// metrics are served on metrics.listen in scheduled mode
+	metrics *clientMetrics
+
+	// schedule is reported with every heartbeat; refresh_policies replaces it
+	schedule atomic.Pointer[api.ScheduleInfo]
+
+	// assigned holds the report files of the policies the server assigned
+	// to the client, from the last heartbeat; see setAssignedPolicies
+	assigned atomic.Pointer[[]string]
+
+	// commandKey verifies commands delivered by the server
+	commandKey ed25519.PublicKey
+
+	// commandMu serializes command execution; lastCommandID guards against
+	// a command being replayed
+	commandMu     sync.Mutex
+	lastCommandID int
+
+	// deltaBases holds, per report type, the last submission the server
+	// accepted, which the next is sent as a delta of
+	deltaMu    sync.Mutex
+	deltaBases map[string]deltaBase
+
+	// runFailures are runs the watchdog stopped, queued for the next
+	// heartbeat; see recordRunFailure
+	runFailuresMu sync.Mutex
+	runFailures   []api.RunFailure
+
+	// compatibility is the server's last verdict on the client's version;
+	// see noteCompatibility
+	compatibility atomic.Pointer[api.Compatibility]
 }
 
 // NewComplianceClient creates a new compliance client
 func NewComplianceClient(config *ClientConfig, logger *slog.Logger) *ComplianceClient {
 	client := &ComplianceClient{
-		config: config,
-		logger: logger,
+		config:     config,
+		logger:     logger,
+		deltaBases: make(map[string]deltaBase),
 	}
 
 	// Create report runner
@@ -50,15 +89,50 @@ func NewComplianceClient(config *ClientConfig, logger *slog.Logger) *ComplianceC
 		}
 	}
 
+	client.metrics = newClientMetrics(client.cache)
+
 	// Create API client if in server mode
 	if config.IsServerMode() {
 		opts := []api.ClientOption{
 			api.WithTimeout(config.Server.Timeout),
+			api.WithClientID(config.Client.ID),
 		}
 		if !config.Server.TLSVerify {
 			opts = append(opts, api.WithInsecureSkipVerify())
 		}
 		client.api = api.NewClient(config.Server.URL, config.Server.APIKey, opts...)
+		// Validate has checked the key that downloads are verified with
+		if config.Reports.SyncFromServer {
+			key, err := config.policyKey()
+			if err != nil {
+				logger.Warn("Invalid policy signing key; report configs will not be synced", "error", err)
+			} else {
+				client.runner.policies = client.api
+				client.runner.policyKey = key
+			}
+		}
+	}
+
+	// Load the key powershell query scripts must be signed with. Validate
+	// has already checked it decodes when powershell queries are enabled.
+	if config.Security.PowerShell.Enabled {
+		key, err := config.scriptKey()
+		if err != nil {
+			logger.Warn("Invalid script signing key; powershell queries will be refused", "error", err)
+		} else {
+			client.runner.scriptKey = key
+		}
+	}
+
+	// Load the key remote commands must be signed with. Validate has already
+	// checked it decodes when commands are enabled.
+	if config.Commands.Enabled {
+		key, err := config.Commands.publicKey()
+		if err != nil {
+			logger.Warn("Invalid command signing key; remote commands will be refused", "error", err)
+		} else {
+			client.commandKey = key
+		}
 	}
 
 	return client
@@ -66,6 +140,8 @@ func NewComplianceClient(config *ClientConfig, logger *slog.Logger) *ComplianceC
 
 // Run executes the client based on configuration
 func (c *ComplianceClient) Run() error {
+	c.logPrivileges()
+
 	// Check if scheduling is enabled
 	if c.config.Schedule.Enabled {
 		return c.runScheduled()
@@ -88,7 +164,7 @@ func (c *ComplianceClient) runOnce() error {
 
 	// Execute all configured reports
 	for _, reportName := range c.config.Reports.Reports {
-		if err := c.executeReport(reportName); err != nil {
+		if err := c.executeReport(context.Background(), reportName); err != nil {
 			c.logger.Error("Report execution failed",
 				"report", reportName,
 				"error", err,
@@ -103,6 +179,7 @@ func (c *ComplianceClient) runOnce() error {
 // runScheduled runs reports on a schedule
 func (c *ComplianceClient) runScheduled() error {
 	c.logger.Info("Running in scheduled mode", "cron", c.config.Schedule.Cron)
+	c.startMetrics()
 
 	// Create cron scheduler with default logger
 	scheduler := cron.New()
@@ -111,9 +188,9 @@ func (c *ComplianceClient) runScheduled() error {
 	_, err := scheduler.AddFunc(c.config.Schedule.Cron, func() {
 		c.logger.Info("Scheduled execution triggered")
 
-		// Execute all configured reports
-		for _, reportName := range c.config.Reports.Reports {
-			if err := c.executeReport(reportName); err != nil {
+		// Execute all configured and assigned reports
+		for _, reportName := range c.reportNames() {
+			if err := c.executeWatched(reportName); err != nil {
 				c.logger.Error("Scheduled report execution failed",
 					"report", reportName,
 					"error", err,
@@ -127,6 +204,17 @@ func (c *ComplianceClient) runScheduled() error {
 		return fmt.Errorf("failed to add scheduled job: %w", err)
 	}
 
+	// Report the schedule to the server so it can detect missed runs
+	if c.api != nil && c.config.Schedule.HeartbeatInterval > 0 {
+		c.schedule.Store(c.scheduleInfo())
+		c.sendHeartbeat()
+
+		spec := fmt.Sprintf("@every %s", c.config.Schedule.HeartbeatInterval)
+		if _, err := scheduler.AddFunc(spec, c.sendHeartbeat); err != nil {
+			return fmt.Errorf("failed to add heartbeat job: %w", err)
+		}
+	}
+
 	// Start scheduler
 	scheduler.Start()
 	c.logger.Info("Scheduler started successfully", "cron", c.config.Schedule.Cron)
@@ -153,19 +241,90 @@ func (c *ComplianceClient) runScheduled() error {
 	return nil
 }
 
-// executeReport executes a single report
-func (c *ComplianceClient) executeReport(reportName string) error {
+// scheduleInfo describes the configured schedule for heartbeats. Reports are
+// identified by report title, which is the report type submissions carry.
+func (c *ComplianceClient) scheduleInfo() *api.ScheduleInfo {
+	_, offset := time.Now().Zone()
+
+	names := c.reportNames()
+	reports := make([]string, 0, len(names))
+	for _, reportName := range names {
+		reportConfig, err := c.runner.loadReportConfig(reportName)
+		if err != nil {
+			c.logger.Warn("Failed to load report for heartbeat", "report", reportName, "error", err)
+			continue
+		}
+		reports = append(reports, reportConfig.Metadata.ReportTitle)
+	}
+
+	return &api.ScheduleInfo{
+		Enabled:          c.config.Schedule.Enabled,
+		Cron:             c.config.Schedule.Cron,
+		UTCOffsetSeconds: offset,
+		Reports:          reports,
+	}
+}
+
+// sendHeartbeat tells the server the client is alive and executes any
+// commands returned with the response. Failures are only logged; the next
+// heartbeat or submission will try again.
+func (c *ComplianceClient) sendHeartbeat() {
+	heartbeat := &api.Heartbeat{
+		ClientID:     c.config.Client.ID,
+		Hostname:     c.config.Client.Hostname,
+		Timestamp:    time.Now(),
+		AgentVersion: version,
+		Schedule:     c.schedule.Load(),
+
+		SchemaVersions: []int{api.SchemaVersion},
+	}
+	if c.config.Commands.Enabled {
+		heartbeat.Capabilities = c.config.Commands.Capabilities
+	}
+	heartbeat.RunFailures = c.pendingRunFailures()
+
+	resp, err := c.api.Heartbeat(heartbeat)
+	if err != nil {
+		c.logger.Warn("Failed to send heartbeat", "error", err)
+		return
+	}
+
+	c.logger.Debug("Heartbeat sent", "client_id", heartbeat.ClientID)
+	c.noteCompatibility(resp.Compatibility)
+	if len(heartbeat.RunFailures) > 0 {
+		c.deliveredRunFailures(heartbeat.RunFailures)
+	}
+
+	// Null when the server could not tell; keep the last list then
+	if resp.Policies != nil {
+		c.setAssignedPolicies(resp.Policies)
+	}
+
+	for _, cmd := range resp.Commands {
+		c.executeCommand(cmd)
+	}
+}
+
+// executeReport executes a single report. Cancelling ctx stops the scan
+// between queries.
+func (c *ComplianceClient) executeReport(ctx context.Context, reportName string) error {
 	startTime := time.Now()
 
 	c.logger.Info("Executing report", "report", reportName)
 
 	// Run the report
-	submission, err := c.runner.Run(reportName)
+	submission, err := c.runner.Run(ctx, reportName)
+	if errors.Is(err, pkg.ErrUnknownProfile) {
+		c.logger.Info("Report skipped", "report", reportName, "reason", err)
+		return nil
+	}
 	if err != nil {
+		c.metrics.reportRuns.Inc(reportName, "failed")
 		return fmt.Errorf("report execution failed: %w", err)
 	}
 
 	duration := time.Since(startTime)
+	c.metrics.observeReport(reportName, duration.Seconds(), submission)
 	c.logger.Info("Report completed",
 		"report", reportName,
 		"duration", duration,
@@ -176,11 +335,20 @@ func (c *ComplianceClient) executeReport(reportName string) error {
 
 	// Submit to server if configured
 	if c.api != nil {
+		if c.cache != nil && submission.Telemetry != nil {
+			if backlog, err := c.cache.Count(); err == nil {
+				submission.Telemetry.CacheBacklog = backlog
+			}
+		}
+
 		if err := c.submitToServer(submission); err != nil {
 			c.logger.Error("Failed to submit to server", "error", err)
 
-			// Cache the submission for later retry
-			if c.cache != nil {
+			// Cache the submission for later retry, unless the server
+			// refuses this client version, which resending cannot fix
+			if errors.Is(err, api.ErrVersionUnsupported) {
+				c.logger.Error("Server no longer accepts submissions from this client version; upgrade the client", "version", version)
+			} else if c.cache != nil {
 				if err := c.cache.Store(submission); err != nil {
 					c.logger.Error("Failed to cache submission", "error", err)
 				} else {
@@ -190,6 +358,8 @@ func (c *ComplianceClient) executeReport(reportName string) error {
 
 			// Don't return error - local report was generated successfully
 			c.logger.Warn("Server submission failed but local report saved")
+		} else {
+			c.uploadAttachments(reportName, submission.SubmissionID)
 		}
 	}
 
@@ -220,7 +390,7 @@ func (c *ComplianceClient) submitToServer(submission *api.ComplianceSubmission)
 			time.Sleep(backoff)
 		}
 
-		resp, err := c.api.Submit(submission)
+		resp, err := c.send(submission)
 		attemptDuration := time.Since(attemptStart)
 
 		if err == nil {
@@ -232,10 +402,18 @@ func (c *ComplianceClient) submitToServer(submission *api.ComplianceSubmission)
 				"total_duration", totalDuration,
 				"total_backoff", totalBackoff,
 			)
+			c.metrics.submissions.Inc("accepted")
 			return nil
 		}
 
 		lastErr = err
+
+		// Count failed deliveries in the telemetry block; cached submissions
+		// keep counting across runs
+		if submission.Telemetry != nil {
+			submission.Telemetry.RetryCount++
+		}
+
 		c.logger.Warn("Submission attempt failed",
 			"attempt", attempt+1,
 			"max_attempts", c.config.Retry.MaxAttempts+1,
@@ -251,6 +429,7 @@ func (c *ComplianceClient) submitToServer(submission *api.ComplianceSubmission)
 				"total_duration", totalDuration,
 				"error", err,
 			)
+			c.metrics.submissions.Inc("failed")
 			return fmt.Errorf("submission failed (non-retryable): %w", err)
 		}
 	}
@@ -262,6 +441,7 @@ func (c *ComplianceClient) submitToServer(submission *api.ComplianceSubmission)
 		"total_backoff", totalBackoff,
 		"error", lastErr,
 	)
+	c.metrics.submissions.Inc("failed")
 	return fmt.Errorf("submission failed after %d attempts: %w", c.config.Retry.MaxAttempts+1, lastErr)
 }
 
@@ -286,15 +466,22 @@ func (c *ComplianceClient) retryCachedSubmissions() error {
 	for _, sub := range submissions {
 		c.logger.Info("Retrying cached submission", "submission_id", sub.SubmissionID)
 
-		if err := c.submitToServer(sub); err != nil {
+		err := c.submitToServer(sub)
+		if err != nil && !errors.Is(err, api.ErrVersionUnsupported) {
 			c.logger.Warn("Failed to submit cached submission",
 				"submission_id", sub.SubmissionID,
 				"error", err,
 			)
 			continue
 		}
+		if err != nil {
+			c.logger.Error("Dropping cached submission the server refuses from this client version",
+				"submission_id", sub.SubmissionID,
+				"version", version,
+			)
+		}
 
-		// Remove from cache on success
+		// Remove from cache on success, or when it can never be delivered
 		if err := c.cache.Remove(sub.SubmissionID); err != nil {
 			c.logger.Warn("Failed to remove from cache",
 				"submission_id", sub.SubmissionID,
@@ -341,6 +528,12 @@ func (c *ComplianceClient) shouldRetry(err error) bool {
 	// Parse HTTP status code from error message
 	statusCode := extractStatusCode(errStr)
 
+	// Rate limited by the server: back off and try again
+	if statusCode == 429 {
+		c.logger.Debug("Rate limited by server, retrying", "error", errStr)
+		return true
+	}
+
 	// Client errors (4xx) are NOT retryable (bad request, auth failure, etc.)
 	if statusCode >= 400 && statusCode < 500 {
 		c.logger.Warn("Client error detected, NOT retrying",
@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/objectstore"
 )
 
 // TestErrorClassification tests the error classification logic
@@ -64,6 +81,12 @@ func TestErrorClassification(t *testing.T) {
 			shouldRetry: false,
 			description: "404 errors should NOT retry",
 		},
+		{
+			name:        "429 too many requests",
+			err:         fmt.Errorf("server error (429): too many requests"),
+			shouldRetry: true,
+			description: "rate limited submissions should retry",
+		},
 		{
 			name:        "500 internal server error",
 			err:         fmt.Errorf("server error (500): internal server error"),
@@ -205,3 +228,875 @@ func TestNetworkErrorDetection(t *testing.T) {
 		})
 	}
 }
+
+// TestClassifyReadError tests mapping registry read failures to error classes
+func TestClassifyReadError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"missing value", &pkg.RegistryError{Op: "OpenKey", Err: registry.ErrNotExist}, api.ErrorClassNotFound},
+		{"access denied", &pkg.RegistryError{Op: "OpenKey", Err: syscall.ERROR_ACCESS_DENIED}, api.ErrorClassAccessDenied},
+		{"timeout", fmt.Errorf("registry read cancelled: %w", context.DeadlineExceeded), api.ErrorClassTimeout},
+		{"other failure", errors.New("unable to read value"), api.ErrorClassReadFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyReadError(tt.err); got != tt.want {
+				t.Errorf("classifyReadError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoadReportConfig tests that report configs are validated before they
+// run, and that a config which passed is not validated again
+func TestLoadReportConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultClientConfig()
+	config.Reports.ConfigPath = dir
+	config.Reports.ValidationCache = filepath.Join(dir, "validated_configs.json")
+	runner := NewReportRunner(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	valid := `{"version": "1.0", "queries": [{"name": "uac", "root_key": "HKLM", "path": "SOFTWARE\\Policies", "operation": "read"}]}`
+	invalid := `{"version": "1.0", "queries": [{"name": "uac", "root_key": "HKLM", "path": "SOFTWARE\\..\\SAM", "operation": "read"}]}`
+	for name, content := range map[string]string{"valid.json": valid, "invalid.json": invalid} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := runner.loadReportConfig("invalid.json"); err == nil {
+		t.Error("loadReportConfig() accepted an invalid report config")
+	}
+	if _, err := runner.loadReportConfig("valid.json"); err != nil {
+		t.Fatalf("loadReportConfig() error = %v", err)
+	}
+	if _, cached, err := runner.validated.LoadConfig(filepath.Join(dir, "valid.json")); err != nil || !cached {
+		t.Errorf("LoadConfig() after loadReportConfig() = cached %v, error %v; want cached", cached, err)
+	}
+}
+
+// TestBlockedQuery tests that queries outside the security settings, as
+// tightened by the report's, are not run and are reported with a
+// policy_violation evidence record and audit event
+func TestBlockedQuery(t *testing.T) {
+	config := DefaultClientConfig()
+	config.Security.AllowedRegistryRoots = []string{"HKLM", "HKCU"}
+	var audit strings.Builder
+	runner := &ReportRunner{
+		config: config,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		audit:  pkg.NewAuditLogger(slog.New(slog.NewTextHandler(&audit, nil)), true),
+	}
+
+	report := &pkg.RegistryConfig{Security: &pkg.ReportSecurity{
+		AllowedRegistryRoots: []string{"HKEY_LOCAL_MACHINE", "HKEY_USERS"},
+		DenyRegistryPaths:    []string{`SOFTWARE\Contoso\Secrets`},
+	}}
+	security, ok := config.Security.forReport(report)
+	if !ok {
+		t.Fatal("forReport() ok = false, want HKLM allowed by both")
+	}
+	rules := querySecurity{ReportSecurity: security}
+
+	tests := []struct {
+		name  string
+		query pkg.RegistryQuery
+		rules querySecurity
+		rule  string
+	}{
+		{"denied by client", pkg.RegistryQuery{Name: "secrets", RootKey: "HKLM", Path: `SECURITY\Policy\Secrets\DefaultPassword`}, rules, "deny_registry_paths"},
+		{"denied by report", pkg.RegistryQuery{Name: "contoso", RootKey: "HKLM", Path: `SOFTWARE\Contoso\Secrets`}, rules, "deny_registry_paths"},
+		{"root allowed by client only", pkg.RegistryQuery{Name: "user", RootKey: "HKCU", Path: `Software\Policies`}, rules, "allowed_registry_roots"},
+		{"no root in common", pkg.RegistryQuery{Name: "machine", RootKey: "HKLM", Path: `SOFTWARE\Policies`}, querySecurity{noRoots: true}, "allowed_registry_roots"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, evidence := runner.executeQuery(context.Background(), tt.query, tt.rules)
+			if result.Status != "error" || result.ErrorClass != api.ErrorClassBlocked {
+				t.Errorf("result = %+v, want an error of class %q", result, api.ErrorClassBlocked)
+			}
+			if evidence == nil || evidence.Action != "policy_violation" || evidence.Details["rule"] != tt.rule {
+				t.Errorf("evidence = %+v, want a policy_violation by %s", evidence, tt.rule)
+			}
+			if !strings.Contains(audit.String(), "event_type="+string(pkg.AuditEventPolicyViolation)) ||
+				!strings.Contains(audit.String(), "security."+tt.rule) {
+				t.Errorf("audit log = %q, want a policy violation by %s", audit.String(), tt.rule)
+			}
+			audit.Reset()
+		})
+	}
+}
+
+// TestEventLogSettings tests the validation of the audit events written to
+// the Windows event log
+func TestEventLogSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*EventLogSettings)
+		wantErr bool
+	}{
+		{"disabled", func(e *EventLogSettings) {}, false},
+		{"security events", func(e *EventLogSettings) { e.Enabled = true }, false},
+		{"all categories", func(e *EventLogSettings) { e.Enabled = true; e.Events = pkg.AuditEventCategories }, false},
+		{"unknown category", func(e *EventLogSettings) { e.Enabled = true; e.Events = []string{"security", "network"} }, true},
+		{"no source", func(e *EventLogSettings) { e.Enabled = true; e.Source = "" }, true},
+		{"no source while disabled", func(e *EventLogSettings) { e.Source = "" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultClientConfig()
+			tt.modify(&config.Logging.EventLog)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestClientMetrics tests that report runs, unreadable checks and the
+// cache are exported
+func TestClientMetrics(t *testing.T) {
+	cache, err := NewSubmissionCache(t.TempDir(), 10, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSubmissionCache() error = %v", err)
+	}
+	if err := cache.Store(&api.ComplianceSubmission{SubmissionID: "cached-1"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	m := newClientMetrics(cache)
+	submission := &api.ComplianceSubmission{}
+	submission.Compliance.Queries = []api.QueryResult{
+		{Name: "ok", Status: "pass"},
+		{Name: "missing", Status: "error", ErrorClass: api.ErrorClassNotFound},
+		{Name: "secrets", Status: "error", ErrorClass: api.ErrorClassBlocked},
+	}
+	m.observeReport("baseline.json", 1.5, submission)
+	m.reportRuns.Inc("other.json", "failed")
+
+	var b strings.Builder
+	if _, err := m.registry.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	for _, want := range []string{
+		`compliance_client_report_duration_seconds{report="baseline.json"} 1.5`,
+		`compliance_client_report_runs_total{report="baseline.json",result="success"} 1`,
+		`compliance_client_report_runs_total{report="other.json",result="failed"} 1`,
+		`compliance_client_query_errors_total{report="baseline.json",error_class="blocked"} 1`,
+		`compliance_client_query_errors_total{report="baseline.json",error_class="not_found"} 1`,
+		"compliance_client_cache_submissions 1",
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
+
+// TestAuthorizeCommand tests that only enabled, correctly signed, unexpired
+// and not previously executed commands are accepted
+func TestAuthorizeCommand(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sign := func(key ed25519.PrivateKey, cmd api.Command) api.Command {
+		payload, err := cmd.SigningPayload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+		return cmd
+	}
+	command := func(id int, clientID, commandType string, expiresAt time.Time) api.Command {
+		return api.Command{ID: id, ClientID: clientID, Type: commandType, ExpiresAt: &expiresAt}
+	}
+	later := now.Add(time.Hour)
+
+	tampered := sign(privateKey, command(10, "client-1", api.CommandTypeScan, later))
+	tampered.Reports = []string{"Other Report"}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		cmd     api.Command
+		wantErr bool
+	}{
+		{"valid", true, sign(privateKey, command(10, "client-1", api.CommandTypeScan, later)), false},
+		{"commands disabled", false, sign(privateKey, command(10, "client-1", api.CommandTypeScan, later)), true},
+		{"capability not enabled", true, sign(privateKey, command(10, "client-1", api.CommandTypeUpdateAgent, later)), true},
+		{"unsigned", true, command(10, "client-1", api.CommandTypeScan, later), true},
+		{"wrong key", true, sign(otherKey, command(10, "client-1", api.CommandTypeScan, later)), true},
+		{"tampered", true, tampered, true},
+		{"other client", true, sign(privateKey, command(10, "client-2", api.CommandTypeScan, later)), true},
+		{"expired", true, sign(privateKey, command(10, "client-1", api.CommandTypeScan, now.Add(-time.Minute))), true},
+		{"replayed", true, sign(privateKey, command(5, "client-1", api.CommandTypeScan, later)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultClientConfig()
+			config.Client.ID = "client-1"
+			config.Commands.Enabled = tt.enabled
+			config.Commands.Capabilities = []string{api.CommandTypeScan}
+
+			client := &ComplianceClient{config: config, commandKey: publicKey, lastCommandID: 5}
+
+			err := client.authorizeCommand(tt.cmd, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authorizeCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakePolicies serves one published report config to syncReportConfig,
+// signed with key as the policy signedAs, or unsigned when key is nil
+type fakePolicies struct {
+	data     string
+	version  string
+	key      ed25519.PrivateKey
+	signedAs string
+}
+
+func (f *fakePolicies) DownloadPolicy(policyID, localSHA256 string) (*api.PolicyDownload, error) {
+	if policyID != "cis-l1" {
+		return nil, api.ErrPolicyNotFound
+	}
+	sum := sha256.Sum256([]byte(f.data))
+	hash := hex.EncodeToString(sum[:])
+	if localSHA256 == hash {
+		return &api.PolicyDownload{Version: f.version, SHA256: hash, NotModified: true}, nil
+	}
+	download := &api.PolicyDownload{Data: []byte(f.data), Version: f.version, SHA256: hash}
+	if f.key != nil {
+		signature := ed25519.Sign(f.key, api.PolicySigningPayload(f.signedAs, f.version, hash))
+		download.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+	return download, nil
+}
+
+// TestSyncReportConfig tests pulling missing and updated report configs
+func TestSyncReportConfig(t *testing.T) {
+	config := DefaultClientConfig()
+	config.Reports.ConfigPath = t.TempDir()
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := &fakePolicies{
+		data:     testPolicy("1.1", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`),
+		version:  "1.1",
+		key:      privateKey,
+		signedAs: "cis-l1",
+	}
+	runner := &ReportRunner{
+		config:    config,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		policies:  policies,
+		policyKey: publicKey,
+		audit:     pkg.NewAuditLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), true),
+	}
+	path := filepath.Join(config.Reports.ConfigPath, "cis-l1.json")
+
+	// Missing locally: downloaded
+	if err := runner.syncReportConfig("cis-l1.json"); err != nil {
+		t.Fatalf("syncReportConfig() error = %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != policies.data {
+		t.Fatalf("local config = %q, %v; want the server's copy", data, err)
+	}
+
+	// Current: left alone
+	if err := runner.syncReportConfig("cis-l1.json"); err != nil {
+		t.Errorf("syncReportConfig() current copy error = %v", err)
+	}
+
+	// Version the configuration does not declare: rejected, local copy kept
+	previous := policies.data
+	policies.data = testPolicy("1.2", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`)
+	policies.version = "2.0"
+	if err := runner.syncReportConfig("cis-l1.json"); err == nil {
+		t.Error("syncReportConfig() version mismatch error = nil, want error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != previous {
+		t.Errorf("local config replaced by a rejected download: %q", data)
+	}
+
+	// Signed with another key, or for another policy: rejected
+	policies.version = "1.2"
+	for _, tt := range []struct {
+		name     string
+		key      ed25519.PrivateKey
+		signedAs string
+	}{
+		{"unpinned key", otherKey, "cis-l1"},
+		{"other policy", privateKey, "nist"},
+	} {
+		policies.key, policies.signedAs = tt.key, tt.signedAs
+		if err := runner.syncReportConfig("cis-l1.json"); err == nil {
+			t.Errorf("syncReportConfig() %s error = nil, want error", tt.name)
+		}
+		if data, _ := os.ReadFile(path); string(data) != previous {
+			t.Errorf("local config replaced by a download signed with the %s: %q", tt.name, data)
+		}
+	}
+
+	// Signed with the pinned key: replaced
+	policies.key, policies.signedAs = privateKey, "cis-l1"
+	if err := runner.syncReportConfig("cis-l1.json"); err != nil {
+		t.Errorf("syncReportConfig() update error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != policies.data {
+		t.Errorf("local config = %q, want the updated copy", data)
+	}
+
+	// Not published: local copy used
+	if err := runner.syncReportConfig("nist.json"); err != nil {
+		t.Errorf("syncReportConfig() unpublished report error = %v", err)
+	}
+}
+
+// TestAssignedPolicies tests that policies assigned on the server are
+// downloaded and run alongside the configured reports
+func TestAssignedPolicies(t *testing.T) {
+	got := assignedReports([]string{"cis-l1.json", "NIST_800_171.json"}, []string{"NIST_800_171", "pos-baseline", "../escape", "pos-baseline"})
+	if want := []string{"pos-baseline.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("assignedReports() = %v, want %v", got, want)
+	}
+
+	config := DefaultClientConfig()
+	config.Reports.ConfigPath = t.TempDir()
+	config.Reports.Reports = []string{"nist.json"}
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := &ComplianceClient{
+		config: config,
+		logger: logger,
+		runner: &ReportRunner{
+			config: config,
+			logger: logger,
+			policies: &fakePolicies{
+				data:     testPolicy("1.0", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`),
+				version:  "1.0",
+				key:      privateKey,
+				signedAs: "cis-l1",
+			},
+			policyKey: publicKey,
+			audit:     pkg.NewAuditLogger(logger, true),
+		},
+	}
+
+	c.setAssignedPolicies([]string{"cis-l1"})
+	if got, want := c.reportNames(), []string{"nist.json", "cis-l1.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reportNames() = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(config.Reports.ConfigPath, "cis-l1.json")); err != nil {
+		t.Errorf("assigned policy not downloaded: %v", err)
+	}
+
+	c.setAssignedPolicies([]string{})
+	if got, want := c.reportNames(), []string{"nist.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reportNames() after unassigning = %v, want %v", got, want)
+	}
+
+	// Without reports.sync_from_server assignments are ignored
+	c.runner.policies = nil
+	c.setAssignedPolicies([]string{"cis-l1"})
+	if got := c.reportNames(); len(got) != 1 {
+		t.Errorf("reportNames() without sync = %v, want the configured reports", got)
+	}
+}
+
+// testPolicy returns a report config of the given version with one query
+// of the given fields
+func testPolicy(version, query string) string {
+	return `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"` + version +
+		`"},"queries":[{"name":"q",` + query + `}]}`
+}
+
+// TestSyncReportConfigRefused tests that downloads that are unsigned, do
+// not match the schema or fail the security validators are refused with an
+// audit event, keeping the local copy
+func TestSyncReportConfigRefused(t *testing.T) {
+	config := DefaultClientConfig()
+	config.Reports.ConfigPath = t.TempDir()
+	config.Security.DenyRegistryPaths = []string{`SOFTWARE\Secrets`}
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(config.Reports.ConfigPath, "cis-l1.json")
+	local := testPolicy("1.0", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`)
+	if err := os.WriteFile(path, []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		key   ed25519.PrivateKey
+	}{
+		{"unsigned", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`, nil},
+		{"missing path", `"root_key":"HKLM","value_name":"V","operation":"read"`, privateKey},
+		{"unknown field", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"read","expected_valu":"1"`, privateKey},
+		{"write", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"write","write_type":"DWORD","write_value":1`, privateKey},
+		{"remediate", `"root_key":"HKLM","path":"SOFTWARE\\Policies","value_name":"V","operation":"remediate","expected_value":"1"`, privateKey},
+		{"invalid root key", `"root_key":"HKXX","path":"SOFTWARE\\Policies","value_name":"V","operation":"read"`, privateKey},
+		{"path traversal", `"root_key":"HKLM","path":"SOFTWARE\\..\\SAM","value_name":"V","operation":"read"`, privateKey},
+		{"denied path", `"root_key":"HKLM","path":"SOFTWARE\\Secrets\\Keys","value_name":"V","operation":"read"`, privateKey},
+		{"powershell not enabled", `"operation":"powershell","script":"Get-Date","script_signature":"c2ln"`, privateKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var audit strings.Builder
+			runner := &ReportRunner{
+				config:    config,
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+				policies:  &fakePolicies{data: testPolicy("2.0", tt.query), version: "2.0", key: tt.key, signedAs: "cis-l1"},
+				policyKey: publicKey,
+				audit:     pkg.NewAuditLogger(slog.New(slog.NewTextHandler(&audit, nil)), true),
+			}
+			if err := runner.syncReportConfig("cis-l1.json"); err == nil {
+				t.Error("syncReportConfig() error = nil, want error")
+			}
+			if data, _ := os.ReadFile(path); string(data) != local {
+				t.Errorf("local config replaced by a refused download: %q", data)
+			}
+			if !strings.Contains(audit.String(), "event_type="+string(pkg.AuditEventPolicyViolation)) ||
+				!strings.Contains(audit.String(), "resource=cis-l1") {
+				t.Errorf("audit log = %q, want a policy violation for cis-l1", audit.String())
+			}
+		})
+	}
+}
+
+// TestPolicyKey tests choosing the key report config downloads are verified
+// with: the publisher key, else the server's command signing key
+func TestPolicyKey(t *testing.T) {
+	publisher := base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))
+	server := base64.StdEncoding.EncodeToString(append(make([]byte, ed25519.PublicKeySize-1), 1))
+
+	tests := []struct {
+		name      string
+		publisher string
+		server    string
+		want      string
+		wantErr   bool
+	}{
+		{"publisher key", publisher, server, publisher, false},
+		{"server key", "", server, server, false},
+		{"no key", "", "", "", true},
+		{"invalid publisher key", "c2hvcnQ=", server, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultClientConfig()
+			config.Reports.PolicyPublicKey = tt.publisher
+			config.Commands.ServerPublicKey = tt.server
+
+			key, err := config.policyKey()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("policyKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := base64.StdEncoding.EncodeToString(key); err == nil && got != tt.want {
+				t.Errorf("policyKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAttachmentFiles tests which files are attached to a report's
+// submissions
+func TestAttachmentFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"gpresult.html":  time.Hour,
+		"old-gpo.html":   72 * time.Hour,
+		"screenshot.png": time.Minute,
+		"notes.txt":      time.Minute,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "folder.html"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &ComplianceClient{
+		config: DefaultClientConfig(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	client.config.Attachments = []AttachmentSettings{
+		{Path: filepath.Join(dir, "*.html"), Description: "GPO report", MaxAge: 24 * time.Hour},
+		{Path: filepath.Join(dir, "screenshot.png"), Reports: []string{"NIST_800_171_compliance.json"}},
+		{Path: filepath.Join(dir, "*.html")},
+	}
+
+	got := client.attachmentFiles("NIST_800_171_compliance.json", now)
+	want := []string{filepath.Join(dir, "gpresult.html"), filepath.Join(dir, "old-gpo.html"), filepath.Join(dir, "screenshot.png")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("attachmentFiles() = %v, want %v", got, want)
+	}
+
+	got = client.attachmentFiles("system_info.json", now)
+	want = []string{filepath.Join(dir, "gpresult.html"), filepath.Join(dir, "old-gpo.html")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("attachmentFiles() for another report = %v, want %v", got, want)
+	}
+
+	if description := client.attachmentDescription(filepath.Join(dir, "gpresult.html")); description != "GPO report" {
+		t.Errorf("attachmentDescription() = %q, want GPO report", description)
+	}
+}
+
+// TestSubmissionMetadata tests that metadata values expand environment
+// variables and that empty values are left out
+func TestSubmissionMetadata(t *testing.T) {
+	t.Setenv("IMAGE_BUILD_ID", "2026.10.3")
+	t.Setenv("EMPTY_VALUE", "")
+	config := &ClientConfig{Metadata: map[string]string{
+		"image_build": "${IMAGE_BUILD_ID}",
+		"ticket_id":   "CHG001234",
+		"ring":        "$EMPTY_VALUE",
+	}}
+
+	want := map[string]string{"image_build": "2026.10.3", "ticket_id": "CHG001234"}
+	if got := config.submissionMetadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("submissionMetadata() = %v, want %v", got, want)
+	}
+	if got := (&ClientConfig{}).submissionMetadata(); got != nil {
+		t.Errorf("submissionMetadata() without metadata = %v, want nil", got)
+	}
+}
+
+// TestStoreReport tests that reports and evidence are stored under the
+// host, report and month, and that the host's expired objects are deleted
+func TestStoreReport(t *testing.T) {
+	store, err := objectstore.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "pc-42/NIST_800_171_compliance/2025/01/old.html", strings.NewReader("old"), 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "pc-7/NIST_800_171_compliance/2025/01/old.html", strings.NewReader("old"), 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"pc-42/NIST_800_171_compliance/2025/01/old.html", "pc-7/NIST_800_171_compliance/2025/01/old.html"} {
+		os.Chtimes(store.Location(key), old, old)
+	}
+
+	report := filepath.Join(t.TempDir(), "NIST_800_171_report.html")
+	if err := os.WriteFile(report, []byte("<html>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := &ClientConfig{Client: ClientSettings{Hostname: "PC-42"}}
+	config.Storage.Retention = 24 * time.Hour
+	runner := &ReportRunner{config: config, logger: slog.New(slog.NewTextHandler(io.Discard, nil)), store: store}
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		Timestamp:    time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC),
+		ReportType:   "NIST 800-171",
+	}
+
+	runner.storeReport("NIST_800_171_compliance.json", submission, []string{report})
+
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	want := []string{
+		"pc-42/NIST_800_171_compliance/2026/10/NIST_800_171_report.html",
+		"pc-42/NIST_800_171_compliance/2026/10/sub-1.json",
+		"pc-7/NIST_800_171_compliance/2025/01/old.html",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("stored objects = %v, want %v", keys, want)
+	}
+}
+
+// TestCPUBudget tests that a scan is aborted once it used more CPU time
+// than its budget
+func TestCPUBudget(t *testing.T) {
+	now := 10 * time.Second
+	used := func() (time.Duration, error) { return now, nil }
+
+	budget := newCPUBudget(time.Second, used)
+	now += 900 * time.Millisecond
+	if err := budget.check(); err != nil {
+		t.Fatalf("check() within budget = %v", err)
+	}
+	now += 200 * time.Millisecond
+	if err := budget.check(); !errors.Is(err, errCPUBudgetExceeded) {
+		t.Fatalf("check() over budget = %v, want errCPUBudgetExceeded", err)
+	}
+
+	if err := newCPUBudget(0, used).check(); err != nil {
+		t.Errorf("check() without a budget = %v", err)
+	}
+	broken := func() (time.Duration, error) { return 0, errors.New("access denied") }
+	if err := newCPUBudget(time.Second, broken).check(); err != nil {
+		t.Errorf("check() when CPU time cannot be read = %v", err)
+	}
+}
+
+// TestResourceSettingsValidation tests that negative limits are refused
+func TestResourceSettingsValidation(t *testing.T) {
+	for _, resources := range []ResourceSettings{{MaxWorkers: -1}, {CPUBudget: -time.Second}} {
+		config := DefaultClientConfig()
+		config.Resources = resources
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate() with %+v = nil, want an error", resources)
+		}
+	}
+	config := DefaultClientConfig()
+	config.Resources = ResourceSettings{LowPriority: true, MaxWorkers: 2, CPUBudget: 2 * time.Minute}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+// TestPowerShellQuery tests that scripts are refused unless powershell
+// queries are enabled and the script is signed for the query
+func TestPowerShellQuery(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus"
+	sign := func(key ed25519.PrivateKey, name string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, api.ScriptSigningPayload(name, script)))
+	}
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		signature string
+		rule      string
+	}{
+		{"disabled", false, sign(privateKey, "bitlocker"), "powershell.enabled"},
+		{"signed with another key", true, sign(otherKey, "bitlocker"), "powershell.public_key"},
+		{"signed for another query", true, sign(privateKey, "defender"), "powershell.public_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultClientConfig()
+			config.Security.PowerShell.Enabled = tt.enabled
+			runner := &ReportRunner{config: config, logger: slog.New(slog.NewTextHandler(io.Discard, nil)), scriptKey: publicKey}
+			query := pkg.RegistryQuery{Name: "bitlocker", Operation: "powershell", Script: script, ScriptSignature: tt.signature, ExpectedValue: "On"}
+
+			result, evidence := runner.executeQuery(context.Background(), query, querySecurity{})
+			if result.Status != "error" || result.ErrorClass != api.ErrorClassBlocked {
+				t.Errorf("result = %+v, want an error of class %q", result, api.ErrorClassBlocked)
+			}
+			if evidence == nil || evidence.Action != "policy_violation" || evidence.Details["rule"] != tt.rule {
+				t.Errorf("evidence = %+v, want a policy_violation by %s", evidence, tt.rule)
+			}
+		})
+	}
+
+	if err := verifyScript(publicKey, pkg.RegistryQuery{Name: "bitlocker", Script: script, ScriptSignature: sign(privateKey, "bitlocker")}); err != nil {
+		t.Errorf("verifyScript() = %v, want a valid signature", err)
+	}
+}
+
+// TestPowerShellSettings tests validating powershell settings and the
+// options a query's script runs with
+func TestPowerShellSettings(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))
+	for _, settings := range []PowerShellSettings{
+		{Enabled: true, ExecutionPolicy: "AllSigned", LanguageMode: "constrained"},
+		{Enabled: true, ExecutionPolicy: "Unrestricted", LanguageMode: "constrained", PublicKey: key},
+		{Enabled: true, ExecutionPolicy: "AllSigned", LanguageMode: "restricted", PublicKey: key},
+		{Enabled: true, ExecutionPolicy: "AllSigned", LanguageMode: "constrained", Timeout: -time.Second, PublicKey: key},
+	} {
+		config := DefaultClientConfig()
+		config.Security.PowerShell = settings
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate() with %+v = nil, want an error", settings)
+		}
+	}
+
+	config := DefaultClientConfig()
+	config.Security.PowerShell.Enabled = true
+	config.Reports.PolicyPublicKey = key
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	opts := config.Security.PowerShell.options(pkg.RegistryQuery{TimeoutSeconds: 90})
+	if opts.LanguageMode != pkg.LanguageModeConstrained || opts.ExecutionPolicy != "AllSigned" || opts.Timeout != 90*time.Second {
+		t.Errorf("options() = %+v, want constrained, AllSigned, 90s", opts)
+	}
+	config.Security.PowerShell.LanguageMode = "full"
+	if opts := config.Security.PowerShell.options(pkg.RegistryQuery{}); opts.LanguageMode != pkg.LanguageModeFull || opts.Timeout != pkg.DefaultPowerShellTimeout {
+		t.Errorf("options() = %+v, want full language mode and the default timeout", opts)
+	}
+}
+
+// TestCharacterPolicySetting tests validation of security.character_policy
+func TestCharacterPolicySetting(t *testing.T) {
+	for policy, valid := range map[string]bool{"ascii": true, "unicode": true, "": false, "utf-8": false} {
+		config := DefaultClientConfig()
+		config.Security.CharacterPolicy = policy
+		if err := config.Validate(); (err == nil) != valid {
+			t.Errorf("Validate() with character_policy %q = %v, want valid %v", policy, err, valid)
+		}
+	}
+}
+
+// TestWatchdog tests that runs exceeding their maximum duration are
+// stopped, abandoned when they ignore cancellation, and queued for the
+// next heartbeat
+func TestWatchdog(t *testing.T) {
+	config := DefaultClientConfig()
+	config.Reports.ConfigPath = t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := &ComplianceClient{
+		config:  config,
+		logger:  logger,
+		runner:  NewReportRunner(config, logger),
+		metrics: newClientMetrics(nil),
+	}
+
+	quick := func(ctx context.Context, reportName string) error { return nil }
+	if err := client.watch("quick.json", time.Second, time.Second, quick); err != nil {
+		t.Fatalf("watch() of a quick run = %v", err)
+	}
+
+	cancellable := func(ctx context.Context, reportName string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := client.watch("slow.json", 10*time.Millisecond, time.Second, cancellable); !errors.Is(err, errRunHung) {
+		t.Fatalf("watch() of a slow run = %v, want errRunHung", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	stuck := func(ctx context.Context, reportName string) error {
+		<-release
+		return nil
+	}
+	if err := client.watch("stuck.json", 10*time.Millisecond, 10*time.Millisecond, stuck); !errors.Is(err, errRunHung) {
+		t.Fatalf("watch() of a stuck run = %v, want errRunHung", err)
+	}
+
+	failures := client.pendingRunFailures()
+	if len(failures) != 2 {
+		t.Fatalf("pending failures = %+v, want 2", failures)
+	}
+	if failures[0].ReportType != "slow.json" || failures[0].Abandoned || failures[0].LimitSeconds != 0.01 {
+		t.Errorf("first failure = %+v, want slow.json stopped after 10ms", failures[0])
+	}
+	if failures[1].ReportType != "stuck.json" || !failures[1].Abandoned {
+		t.Errorf("second failure = %+v, want stuck.json abandoned", failures[1])
+	}
+
+	client.deliveredRunFailures(failures[:1])
+	if pending := client.pendingRunFailures(); len(pending) != 1 || pending[0].ReportType != "stuck.json" {
+		t.Errorf("pending failures after delivery = %+v, want stuck.json only", pending)
+	}
+}
+
+// TestWithoutElevation tests the privilege check of configured reports and
+// what an unelevated client does with checks that need elevation
+func TestWithoutElevation(t *testing.T) {
+	for mode, valid := range map[string]bool{"run_all": true, "run_standard": true, "refuse": true, "": false, "skip": false} {
+		config := DefaultClientConfig()
+		config.Security.WithoutElevation = mode
+		if err := config.Validate(); (err == nil) != valid {
+			t.Errorf("Validate() with without_elevation %q = %v, want valid %v", mode, err, valid)
+		}
+	}
+
+	dir := t.TempDir()
+	report := `{"version": "1.0", "metadata": {"report_title": "Audit", "report_version": "1.0.0"}, "queries": [
+		{"name": "lsa-audit", "root_key": "HKLM", "path": "SECURITY\\Policy\\PolAdtEv", "operation": "read"},
+		{"name": "uac", "root_key": "HKLM", "path": "SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\Policies\\System", "value_name": "EnableLUA", "operation": "read", "expected_value": "1"}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "audit.json"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newRunner := func(mode string) *ReportRunner {
+		config := DefaultClientConfig()
+		config.Reports.ConfigPath = dir
+		config.Reports.ValidationCache = ""
+		config.Reports.SaveLocal = false
+		config.Security.WithoutElevation = mode
+		runner := NewReportRunner(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		runner.elevated = false
+		return runner
+	}
+
+	reports := newRunner(elevationRunAll).checkPrivileges([]string{"audit.json", "missing.json"})
+	if len(reports) != 2 || reports[1].Err == nil {
+		t.Fatalf("checkPrivileges() = %+v, want the missing report to fail", reports)
+	}
+	if got := reports[0]; !reflect.DeepEqual(got.Standard, []string{"uac"}) || len(got.Elevated) != 1 || got.Elevated[0].Name != "lsa-audit" {
+		t.Errorf("checkPrivileges() = %+v, want lsa-audit elevated and uac standard", got)
+	}
+	var out strings.Builder
+	printPrivileges(&out, false, elevationRunStandard, reports)
+	for _, want := range []string{"Running as a standard account", "1 checks run as a standard account, 1 need elevation",
+		`elevated  lsa-audit (HKLM\SECURITY is readable by LocalSystem only)`, "standard  uac", "cannot check"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printPrivileges() output is missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := newRunner(elevationRefuse).Run(context.Background(), "audit.json"); err == nil || !strings.Contains(err.Error(), "need elevation") {
+		t.Errorf("Run() with refuse error = %v, want the report refused", err)
+	}
+
+	submission, err := newRunner(elevationRunStandard).Run(context.Background(), "audit.json")
+	if err != nil {
+		t.Fatalf("Run() with run_standard error = %v", err)
+	}
+	skipped := submission.Compliance.Queries[0]
+	if skipped.Status != "error" || skipped.ErrorClass != api.ErrorClassNeedsElevation {
+		t.Errorf("lsa-audit result = %+v, want skipped as %s", skipped, api.ErrorClassNeedsElevation)
+	}
+	if ran := submission.Compliance.Queries[1]; ran.ErrorClass == api.ErrorClassNeedsElevation {
+		t.Errorf("uac result = %+v, want it run", ran)
+	}
+	if len(submission.Evidence) == 0 || submission.Evidence[0].Action != "privilege_check" {
+		t.Errorf("evidence = %+v, want a privilege_check record first", submission.Evidence)
+	}
+}
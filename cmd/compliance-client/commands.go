@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/fileio"
+)
+
+// maxAgentDownloadBytes caps the size of an agent binary fetched by update_agent
+const maxAgentDownloadBytes = 200 << 20
+
+// executeCommand runs a command delivered by the server and reports the
+// outcome. Commands that are not enabled, not signed by the server or
+// already executed are refused and reported as failed.
+func (c *ComplianceClient) executeCommand(cmd api.Command) {
+	c.commandMu.Lock()
+	defer c.commandMu.Unlock()
+
+	result := &api.CommandResult{
+		ClientID: c.config.Client.ID,
+		Status:   api.CommandStatusCompleted,
+	}
+
+	if err := c.authorizeCommand(cmd, time.Now()); err != nil {
+		c.logger.Warn("Refused server command", "id", cmd.ID, "type", cmd.Type, "error", err)
+		result.Status = api.CommandStatusFailed
+		result.Message = fmt.Sprintf("refused: %v", err)
+	} else {
+		c.lastCommandID = cmd.ID
+		c.logger.Info("Executing server command", "id", cmd.ID, "type", cmd.Type)
+
+		output, message, err := c.runCommand(cmd)
+		if err != nil {
+			result.Status = api.CommandStatusFailed
+			result.Message = err.Error()
+		} else {
+			result.Message = message
+		}
+		result.Output = output
+	}
+
+	if err := c.api.ReportCommandResult(cmd.ID, result); err != nil {
+		c.logger.Warn("Failed to report command result", "id", cmd.ID, "error", err)
+	}
+}
+
+// authorizeCommand checks that the client opted in to the command type, that
+// the server signed the command for this client and that it is neither
+// expired nor a replay of a command already executed
+func (c *ComplianceClient) authorizeCommand(cmd api.Command, now time.Time) error {
+	if !c.config.Commands.Enabled {
+		return fmt.Errorf("remote commands are disabled on this client")
+	}
+
+	allowed := false
+	for _, capability := range c.config.Commands.Capabilities {
+		if capability == cmd.Type {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("capability %q is not enabled on this client", cmd.Type)
+	}
+
+	if c.commandKey == nil {
+		return fmt.Errorf("no command signing key configured")
+	}
+	if err := verifyCommand(c.commandKey, cmd, c.config.Client.ID, now); err != nil {
+		return err
+	}
+
+	if cmd.ID <= c.lastCommandID {
+		return fmt.Errorf("command %d was already executed", cmd.ID)
+	}
+	return nil
+}
+
+// verifyCommand checks a command's signature, target client and expiry
+func verifyCommand(key ed25519.PublicKey, cmd api.Command, clientID string, now time.Time) error {
+	if cmd.Signature == "" {
+		return fmt.Errorf("command is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(cmd.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid command signature encoding: %w", err)
+	}
+
+	payload, err := cmd.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+	if !ed25519.Verify(key, payload, signature) {
+		return fmt.Errorf("command signature does not verify")
+	}
+
+	if cmd.ClientID != clientID {
+		return fmt.Errorf("command was issued for client %s", cmd.ClientID)
+	}
+	if cmd.ExpiresAt == nil || now.After(*cmd.ExpiresAt) {
+		return fmt.Errorf("command has expired")
+	}
+	return nil
+}
+
+// runCommand executes an authorized command and returns any output for the
+// server along with a short result message
+func (c *ComplianceClient) runCommand(cmd api.Command) (output, message string, err error) {
+	switch cmd.Type {
+	case api.CommandTypeScan:
+		return "", "", c.runOnDemandScan(cmd.Reports)
+	case api.CommandTypeRefreshPolicies:
+		message, err := c.refreshPolicies()
+		return "", message, err
+	case api.CommandTypeUploadDiagnostics:
+		output, err := c.collectDiagnostics()
+		return output, "diagnostics collected", err
+	case api.CommandTypeUpdateAgent:
+		message, err := c.updateAgent(cmd.Arguments)
+		return "", message, err
+	default:
+		return "", "", fmt.Errorf("unsupported command type %q", cmd.Type)
+	}
+}
+
+// runOnDemandScan executes the configured and assigned reports whose report
+// type is listed, or all of them when reportTypes is empty
+func (c *ComplianceClient) runOnDemandScan(reportTypes []string) error {
+	wanted := make(map[string]bool, len(reportTypes))
+	for _, reportType := range reportTypes {
+		wanted[reportType] = true
+	}
+
+	var ran int
+	var failed []string
+	for _, reportName := range c.reportNames() {
+		if len(wanted) > 0 {
+			reportConfig, err := c.runner.loadReportConfig(reportName)
+			if err != nil || !wanted[reportConfig.Metadata.ReportTitle] {
+				continue
+			}
+		}
+
+		ran++
+		if err := c.executeWatched(reportName); err != nil {
+			c.logger.Error("On-demand report execution failed", "report", reportName, "error", err)
+			failed = append(failed, reportName)
+		}
+	}
+
+	if ran == 0 {
+		return fmt.Errorf("none of the requested reports are configured on this client")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d reports failed: %s", len(failed), ran, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// refreshPolicies reloads the configured report definitions from disk, first
+// pulling updates from the server when reports.sync_from_server is set, and
+// updates the schedule reported to the server, so edited or replaced report
+// files are picked up without restarting the agent
+func (c *ComplianceClient) refreshPolicies() (string, error) {
+	var failed []string
+	names := c.reportNames()
+	for _, reportName := range names {
+		if c.runner.policies != nil {
+			if err := c.runner.syncReportConfig(reportName); err != nil {
+				c.logger.Error("Failed to sync report from server", "report", reportName, "error", err)
+				failed = append(failed, reportName)
+				continue
+			}
+		}
+		if _, err := c.runner.loadReportConfig(reportName); err != nil {
+			c.logger.Error("Failed to reload report", "report", reportName, "error", err)
+			failed = append(failed, reportName)
+		}
+	}
+
+	if c.schedule.Load() != nil {
+		c.schedule.Store(c.scheduleInfo())
+	}
+
+	loaded := len(names) - len(failed)
+	if len(failed) > 0 {
+		return "", fmt.Errorf("reloaded %d report(s); failed: %s", loaded, strings.Join(failed, ", "))
+	}
+	return fmt.Sprintf("reloaded %d report(s)", loaded), nil
+}
+
+// diagnostics is the agent state returned by upload_diagnostics. It never
+// includes the API key or other secrets.
+type diagnostics struct {
+	AgentVersion   string    `json:"agent_version"`
+	ClientID       string    `json:"client_id"`
+	Hostname       string    `json:"hostname"`
+	CollectedAt    time.Time `json:"collected_at"`
+	GoVersion      string    `json:"go_version"`
+	OS             string    `json:"os"`
+	Arch           string    `json:"arch"`
+	Goroutines     int       `json:"goroutines"`
+	HeapAllocBytes uint64    `json:"heap_alloc_bytes"`
+	ServerURL      string    `json:"server_url"`
+	ScheduleCron   string    `json:"schedule_cron,omitempty"`
+	Reports        []string  `json:"reports"`
+	ReportErrors   []string  `json:"report_errors,omitempty"`
+	Capabilities   []string  `json:"capabilities"`
+	CacheEnabled   bool      `json:"cache_enabled"`
+	CacheBacklog   int       `json:"cache_backlog"`
+	CacheBytes     int64     `json:"cache_bytes"`
+}
+
+// collectDiagnostics gathers agent state for troubleshooting as JSON
+func (c *ComplianceClient) collectDiagnostics() (string, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	diag := diagnostics{
+		AgentVersion:   version,
+		ClientID:       c.config.Client.ID,
+		Hostname:       c.config.Client.Hostname,
+		CollectedAt:    time.Now().UTC(),
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		ServerURL:      c.config.Server.URL,
+		Reports:        c.reportNames(),
+		Capabilities:   c.config.Commands.Capabilities,
+		CacheEnabled:   c.cache != nil,
+	}
+	if c.config.Schedule.Enabled {
+		diag.ScheduleCron = c.config.Schedule.Cron
+	}
+
+	for _, reportName := range diag.Reports {
+		if _, err := c.runner.loadReportConfig(reportName); err != nil {
+			diag.ReportErrors = append(diag.ReportErrors, fmt.Sprintf("%s: %v", reportName, err))
+		}
+	}
+
+	if c.cache != nil {
+		if count, err := c.cache.Count(); err == nil {
+			diag.CacheBacklog = count
+		}
+		if size, err := c.cache.Size(); err == nil {
+			diag.CacheBytes = size
+		}
+	}
+
+	data, err := json.Marshal(diag)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+	return string(data), nil
+}
+
+// updateAgent downloads a new agent binary, verifies its SHA-256 digest and
+// swaps it in for the running executable. The running process keeps using
+// the old binary; the new version takes effect when the agent restarts.
+func (c *ComplianceClient) updateAgent(args map[string]string) (string, error) {
+	url := args[api.CommandArgURL]
+	wantSum := strings.ToLower(args[api.CommandArgSHA256])
+	newVersion := args[api.CommandArgVersion]
+	if !strings.HasPrefix(url, "https://") || wantSum == "" || newVersion == "" {
+		return "", fmt.Errorf("update_agent requires an https url, sha256 and version")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate agent executable: %w", err)
+	}
+
+	staged := exe + ".new"
+	if err := downloadVerified(url, wantSum, staged); err != nil {
+		os.Remove(staged)
+		return "", err
+	}
+
+	// Windows allows renaming a running executable, but not replacing it
+	previous := exe + ".old"
+	os.Remove(previous)
+	if err := os.Rename(exe, previous); err != nil {
+		os.Remove(staged)
+		return "", fmt.Errorf("failed to move current agent aside: %w", err)
+	}
+	if err := os.Rename(staged, exe); err != nil {
+		os.Rename(previous, exe)
+		return "", fmt.Errorf("failed to install new agent: %w", err)
+	}
+
+	c.logger.Info("Agent update installed", "from", version, "to", newVersion, "path", exe)
+	return fmt.Sprintf("installed version %s; takes effect when the agent restarts", newVersion), nil
+}
+
+// downloadVerified fetches url into path and checks the file's SHA-256. path
+// is left untouched unless the download completes and verifies.
+func downloadVerified(url, wantSum, path string) error {
+	httpClient := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	if err := fileio.EnsureFreeSpace(filepath.Dir(path), maxAgentDownloadBytes); err != nil {
+		return err
+	}
+
+	// The staged binary only appears once it is complete and verified
+	file, err := fileio.Create(path, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create staged binary: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(resp.Body, maxAgentDownloadBytes+1))
+	if err != nil {
+		return fmt.Errorf("failed to write staged binary: %w", err)
+	}
+	if written > maxAgentDownloadBytes {
+		return fmt.Errorf("agent binary exceeds %d bytes", maxAgentDownloadBytes)
+	}
+
+	if gotSum := hex.EncodeToString(hash.Sum(nil)); gotSum != wantSum {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", gotSum, wantSum)
+	}
+	return file.Commit()
+}
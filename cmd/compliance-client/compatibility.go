@@ -0,0 +1,37 @@
+package main
+
+import (
+	"slices"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// noteCompatibility logs the server's verdict on the client's version from
+// a heartbeat response when it changes. Servers predating the handshake
+// send none.
+func (c *ComplianceClient) noteCompatibility(compat *api.Compatibility) {
+	if compat == nil {
+		return
+	}
+	previous := c.compatibility.Swap(compat)
+	if previous != nil && previous.Status == compat.Status && slices.Equal(previous.Warnings, compat.Warnings) {
+		return
+	}
+
+	attrs := []any{
+		"version", version,
+		"server_version", compat.ServerVersion,
+		"min_version", compat.MinVersion,
+		"warnings", compat.Warnings,
+	}
+	switch {
+	case compat.Status == api.CompatibilityUnsupported:
+		c.logger.Error("Server does not support this client version; submissions are refused until the client is upgraded", attrs...)
+	case compat.Status == api.CompatibilityDeprecated:
+		c.logger.Warn("Client version is deprecated by the server; upgrade the client", attrs...)
+	case len(compat.Warnings) > 0:
+		c.logger.Warn("Server reported compatibility warnings", attrs...)
+	case previous != nil:
+		c.logger.Info("Server accepts this client version", attrs...)
+	}
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/objectstore"
 )
 
 // ClientConfig represents the complete client configuration
@@ -13,10 +23,28 @@ type ClientConfig struct {
 	Client   ClientSettings   `mapstructure:"client"`
 	Server   ServerSettings   `mapstructure:"server"`
 	Reports  ReportSettings   `mapstructure:"reports"`
+	Security SecuritySettings `mapstructure:"security"`
 	Schedule ScheduleSettings `mapstructure:"schedule"`
 	Retry    RetrySettings    `mapstructure:"retry"`
 	Cache    CacheSettings    `mapstructure:"cache"`
+	Commands CommandSettings  `mapstructure:"commands"`
+	Metrics  MetricsSettings  `mapstructure:"metrics"`
 	Logging  LoggingSettings  `mapstructure:"logging"`
+
+	// Resources limits what scans take from the machine, for workstations
+	// and build machines that are in use while scans run
+	Resources ResourceSettings `mapstructure:"resources"`
+
+	// Attachments are files uploaded with each accepted submission
+	Attachments []AttachmentSettings `mapstructure:"attachments"`
+
+	// Metadata is sent with every submission, e.g. a ticket or image build
+	// ID. The server must define the fields (metadata.fields); values may
+	// reference environment variables as ${NAME}.
+	Metadata map[string]string `mapstructure:"metadata"`
+
+	// Storage copies reports and evidence to a bucket or container
+	Storage StorageSettings `mapstructure:"storage"`
 }
 
 // ClientSettings contains client identification and behavior
@@ -33,20 +61,125 @@ type ServerSettings struct {
 	TLSVerify      bool          `mapstructure:"tls_verify"`       // Verify TLS certificates
 	Timeout        time.Duration `mapstructure:"timeout"`          // Request timeout
 	RetryOnStartup bool          `mapstructure:"retry_on_startup"` // Retry cached submissions on startup
+	// DeltaSubmissions sends only the checks that changed since the
+	// previous accepted submission of a report
+	DeltaSubmissions bool `mapstructure:"delta_submissions"`
 }
 
 // ReportSettings contains report execution configuration
 type ReportSettings struct {
-	ConfigPath string   `mapstructure:"config_path"` // Path to report configs
-	OutputPath string   `mapstructure:"output_path"` // Local output directory
-	Reports    []string `mapstructure:"reports"`     // List of reports to run
-	SaveLocal  bool     `mapstructure:"save_local"`  // Save HTML reports locally
+	ConfigPath     string   `mapstructure:"config_path"`      // Path to report configs
+	OutputPath     string   `mapstructure:"output_path"`      // Local output directory
+	Reports        []string `mapstructure:"reports"`          // List of reports to run
+	SaveLocal      bool     `mapstructure:"save_local"`       // Save HTML reports locally
+	ExportFormats  []string `mapstructure:"export_formats"`   // Spreadsheet formats (csv, xlsx) saved with local reports
+	SyncFromServer bool     `mapstructure:"sync_from_server"` // Download missing or updated report configs from the server before running
+	Profile        string   `mapstructure:"profile"`          // Run only the queries of this report profile; reports without it are skipped (empty: every query)
+
+	// MaxConcurrentReads is how many queries run at once; results keep
+	// the report's order. QueryTimeout stops a query that takes longer
+	// (0: the reader's own timeouts).
+	MaxConcurrentReads int           `mapstructure:"max_concurrent_reads"`
+	QueryTimeout       time.Duration `mapstructure:"query_timeout"`
+
+	// PolicyPublicKey is the base64 Ed25519 key downloaded report configs
+	// must be signed with. Empty trusts commands.server_public_key, the
+	// server's own key.
+	PolicyPublicKey string `mapstructure:"policy_public_key"`
+
+	// ValidationCache is the file that remembers report configs which
+	// passed validation, by content hash, so unchanged configs are not
+	// validated again on every run (empty: validate every run)
+	ValidationCache string `mapstructure:"validation_cache"`
+}
+
+// SecuritySettings restricts the registry locations report queries may
+// read. Queries outside them are not run; they are reported as errors with
+// error class "blocked" and a policy_violation evidence record. A report
+// config's own security section can only make them stricter.
+type SecuritySettings struct {
+	AllowedRegistryRoots []string `mapstructure:"allowed_registry_roots"` // Root keys queries may read (empty allows all)
+	DenyRegistryPaths    []string `mapstructure:"deny_registry_paths"`    // Paths, and their subkeys, queries may never read
+	AuditMode            bool     `mapstructure:"audit_mode"`             // Log every registry location queries read or are refused
+
+	// CharacterPolicy is the characters registry paths and value names may
+	// contain: "ascii", or "unicode" to also allow letters and digits of any
+	// script, for localized Windows
+	CharacterPolicy string `mapstructure:"character_policy"`
+
+	// PowerShell controls powershell queries, which run a signed script
+	// block instead of reading the registry
+	PowerShell PowerShellSettings `mapstructure:"powershell"`
+
+	// WithoutElevation is what a client that is neither an elevated
+	// administrator nor LocalSystem does with queries that need elevation
+	// (pkg.RegistryQuery.NeedsElevation): run_all runs them anyway,
+	// run_standard skips them and runs the rest, refuse runs no report that
+	// has one
+	WithoutElevation string `mapstructure:"without_elevation"`
+}
+
+// security.without_elevation values
+const (
+	elevationRunAll      = "run_all"
+	elevationRunStandard = "run_standard"
+	elevationRefuse      = "refuse"
+)
+
+// PowerShellSettings controls how powershell queries run. Without Enabled
+// they are blocked like a denied registry path.
+type PowerShellSettings struct {
+	Enabled         bool          `mapstructure:"enabled"`          // Run powershell queries
+	ExecutionPolicy string        `mapstructure:"execution_policy"` // Restricted, AllSigned, RemoteSigned or Bypass
+	LanguageMode    string        `mapstructure:"language_mode"`    // constrained or full
+	Timeout         time.Duration `mapstructure:"timeout"`          // Stop a script that runs longer, unless its query sets timeout_seconds
+
+	// PublicKey is the base64 Ed25519 key scripts must be signed with
+	// (compliance-server sign-script). Empty uses the key downloaded report
+	// configs are verified with.
+	PublicKey string `mapstructure:"public_key"`
+}
+
+// options returns the options a query's script runs with
+func (s PowerShellSettings) options(query pkg.RegistryQuery) pkg.PowerShellOptions {
+	opts := pkg.PowerShellOptions{
+		ExecutionPolicy: s.ExecutionPolicy,
+		LanguageMode:    pkg.LanguageModeConstrained,
+		Timeout:         s.Timeout,
+	}
+	if strings.EqualFold(s.LanguageMode, "full") {
+		opts.LanguageMode = pkg.LanguageModeFull
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = pkg.DefaultPowerShellTimeout
+	}
+	if query.TimeoutSeconds > 0 {
+		opts.Timeout = time.Duration(query.TimeoutSeconds) * time.Second
+	}
+	return opts
+}
+
+// forReport returns the settings a report runs under: these combined with
+// the report config's security section, keeping the stricter of each.
+// ok is false when no root key is allowed by both.
+func (s SecuritySettings) forReport(report *pkg.RegistryConfig) (pkg.ReportSecurity, bool) {
+	local := pkg.ReportSecurity{
+		AllowedRegistryRoots: s.AllowedRegistryRoots,
+		DenyRegistryPaths:    s.DenyRegistryPaths,
+		AuditMode:            s.AuditMode,
+	}
+	if report.Security == nil {
+		return local, true
+	}
+	return local.Stricter(*report.Security)
 }
 
 // ScheduleSettings contains scheduling configuration
 type ScheduleSettings struct {
-	Enabled bool   `mapstructure:"enabled"` // Enable scheduled execution
-	Cron    string `mapstructure:"cron"`    // Cron expression (e.g., "0 2 * * *")
+	Enabled           bool          `mapstructure:"enabled"`            // Enable scheduled execution
+	Cron              string        `mapstructure:"cron"`               // Cron expression (e.g., "0 2 * * *")
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // How often the schedule is reported to the server (0 disables)
+	MaxRunDuration    time.Duration `mapstructure:"max_run_duration"`   // Stop a scheduled or commanded report run that takes longer (0 disables)
 }
 
 // RetrySettings contains retry logic configuration
@@ -67,11 +200,118 @@ type CacheSettings struct {
 	AutoClean  bool          `mapstructure:"auto_clean"`   // Automatically clean old cache
 }
 
+// CommandSettings controls which remote commands the server may ask the
+// client to run. Commands are refused unless enabled, listed in
+// Capabilities and signed with the server's key.
+type CommandSettings struct {
+	Enabled         bool     `mapstructure:"enabled"`           // Accept commands delivered with heartbeat responses
+	Capabilities    []string `mapstructure:"capabilities"`      // Command types the client executes
+	ServerPublicKey string   `mapstructure:"server_public_key"` // Base64 Ed25519 key from GET /api/v1/commands/signing-key
+}
+
+// MetricsSettings controls the Prometheus metrics listener, which runs in
+// scheduled mode and as a service
+type MetricsSettings struct {
+	Listen string `mapstructure:"listen"` // host:port /metrics is served on, e.g. "127.0.0.1:9183"; empty disables
+}
+
+// ResourceSettings limits the CPU and I/O scans use
+type ResourceSettings struct {
+	LowPriority bool          `mapstructure:"low_priority"` // Scan at below-normal CPU, I/O and memory priority
+	MaxWorkers  int           `mapstructure:"max_workers"`  // CPUs the client runs on at once (0: all)
+	CPUBudget   time.Duration `mapstructure:"cpu_budget"`   // Abort a scan that used more CPU time than this (0: no limit)
+}
+
+// AttachmentSettings names supplementary evidence files, such as exported
+// GPO reports or screenshots, attached to the submissions of reports
+type AttachmentSettings struct {
+	Path        string        `mapstructure:"path"`        // File, or glob pattern matching files
+	Description string        `mapstructure:"description"` // Shown with the attachment on the server
+	Reports     []string      `mapstructure:"reports"`     // Reports the files are attached to (empty: all)
+	MaxAge      time.Duration `mapstructure:"max_age"`     // Skip files last modified longer ago (0: any age)
+}
+
+// StorageSettings copies the HTML reports, exports and evidence JSON of
+// every run to an S3-compatible bucket or Azure Blob container (or a
+// directory, such as a file share), under <host>/<report>/<year>/<month>/
+type StorageSettings struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Retention time.Duration `mapstructure:"retention"` // Delete this host's objects older than this (0 keeps them)
+
+	objectstore.Config `mapstructure:",squash"`
+}
+
+// appliesTo reports whether the files are attached to report's submissions
+func (a AttachmentSettings) appliesTo(report string) bool {
+	return len(a.Reports) == 0 || slices.Contains(a.Reports, report)
+}
+
+// knownCapabilities are the command types the client can execute
+var knownCapabilities = map[string]bool{
+	api.CommandTypeScan:              true,
+	api.CommandTypeRefreshPolicies:   true,
+	api.CommandTypeUploadDiagnostics: true,
+	api.CommandTypeUpdateAgent:       true,
+}
+
+// publicKey decodes the configured server command signing key
+func (c CommandSettings) publicKey() (ed25519.PublicKey, error) {
+	return decodePublicKey("commands.server_public_key", c.ServerPublicKey)
+}
+
+// policyKey decodes the key downloaded report configs are verified with:
+// reports.policy_public_key, or the server's command signing key
+func (c *ClientConfig) policyKey() (ed25519.PublicKey, error) {
+	if c.Reports.PolicyPublicKey != "" {
+		return decodePublicKey("reports.policy_public_key", c.Reports.PolicyPublicKey)
+	}
+	if c.Commands.ServerPublicKey == "" {
+		return nil, fmt.Errorf("reports.sync_from_server requires reports.policy_public_key or commands.server_public_key")
+	}
+	return c.Commands.publicKey()
+}
+
+// scriptKey decodes the key powershell query scripts are verified with:
+// security.powershell.public_key, or the key of downloaded report configs
+func (c *ClientConfig) scriptKey() (ed25519.PublicKey, error) {
+	if c.Security.PowerShell.PublicKey != "" {
+		return decodePublicKey("security.powershell.public_key", c.Security.PowerShell.PublicKey)
+	}
+	if c.Reports.PolicyPublicKey == "" && c.Commands.ServerPublicKey == "" {
+		return nil, fmt.Errorf("security.powershell.enabled requires security.powershell.public_key, reports.policy_public_key or commands.server_public_key")
+	}
+	return c.policyKey()
+}
+
+// decodePublicKey decodes a base64 Ed25519 public key setting
+func decodePublicKey(setting, value string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", setting, err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s must be a %d byte Ed25519 key", setting, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
 // LoggingSettings contains logging configuration
 type LoggingSettings struct {
 	Level      string `mapstructure:"level"`       // Log level: debug, info, warn, error
 	Format     string `mapstructure:"format"`      // Log format: json, text
 	OutputPath string `mapstructure:"output_path"` // Log file path (or stdout/stderr)
+
+	// EventLog writes audit events, such as queries blocked by security
+	// settings and refused report config downloads, to the Windows
+	// Application event log for EDR and SIEM agents
+	EventLog EventLogSettings `mapstructure:"event_log"`
+}
+
+// EventLogSettings selects the audit events written to the Windows event log
+type EventLogSettings struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Source  string   `mapstructure:"source"` // Event source; the service registers its name
+	Events  []string `mapstructure:"events"` // Audit event categories (pkg.AuditEventCategories) written
 }
 
 // DefaultClientConfig returns a ClientConfig with sensible defaults
@@ -97,11 +337,36 @@ func DefaultClientConfig() *ClientConfig {
 			Reports: []string{
 				"NIST_800_171_compliance.json",
 			},
-			SaveLocal: true,
+			SaveLocal:      true,
+			ExportFormats:  []string{},
+			SyncFromServer: false,
+
+			MaxConcurrentReads: 10,
+			QueryTimeout:       30 * time.Second,
+			ValidationCache:    "cache/validated_configs.json",
+		},
+		Security: SecuritySettings{
+			AllowedRegistryRoots: []string{}, // All roots
+			DenyRegistryPaths: []string{
+				// Block security-sensitive keys, as the toolkit does
+				`SOFTWARE\Microsoft\Windows NT\CurrentVersion\Winlogon\SpecialAccounts`,
+				`SECURITY\Policy\Secrets`,
+				`SAM\SAM\Domains\Account\Users`,
+			},
+			CharacterPolicy: string(pkg.CharacterPolicyASCII),
+			PowerShell: PowerShellSettings{
+				Enabled:         false,
+				ExecutionPolicy: "AllSigned",
+				LanguageMode:    "constrained",
+				Timeout:         pkg.DefaultPowerShellTimeout,
+			},
+			WithoutElevation: elevationRunAll,
 		},
 		Schedule: ScheduleSettings{
-			Enabled: false,
-			Cron:    "0 2 * * *", // Daily at 2 AM
+			Enabled:           false,
+			Cron:              "0 2 * * *", // Daily at 2 AM
+			HeartbeatInterval: 15 * time.Minute,
+			MaxRunDuration:    2 * time.Hour,
 		},
 		Retry: RetrySettings{
 			MaxAttempts:        3,
@@ -117,10 +382,23 @@ func DefaultClientConfig() *ClientConfig {
 			MaxAge:    7 * 24 * time.Hour, // 7 days
 			AutoClean: true,
 		},
+		Commands: CommandSettings{
+			Enabled:      false,
+			Capabilities: []string{api.CommandTypeScan},
+		},
+		Storage: StorageSettings{
+			Enabled: false,
+			Config:  objectstore.Config{Type: objectstore.TypeS3},
+		},
 		Logging: LoggingSettings{
 			Level:      "info",
 			Format:     "text",
 			OutputPath: "stdout",
+			EventLog: EventLogSettings{
+				Enabled: false,
+				Source:  serviceName,
+				Events:  []string{"security"},
+			},
 		},
 	}
 }
@@ -186,16 +464,38 @@ func setClientDefaults(v *viper.Viper, cfg *ClientConfig) {
 	v.SetDefault("server.tls_verify", cfg.Server.TLSVerify)
 	v.SetDefault("server.timeout", cfg.Server.Timeout)
 	v.SetDefault("server.retry_on_startup", cfg.Server.RetryOnStartup)
+	v.SetDefault("server.delta_submissions", cfg.Server.DeltaSubmissions)
 
 	// Reports
 	v.SetDefault("reports.config_path", cfg.Reports.ConfigPath)
 	v.SetDefault("reports.output_path", cfg.Reports.OutputPath)
 	v.SetDefault("reports.reports", cfg.Reports.Reports)
 	v.SetDefault("reports.save_local", cfg.Reports.SaveLocal)
+	v.SetDefault("reports.export_formats", cfg.Reports.ExportFormats)
+	v.SetDefault("reports.sync_from_server", cfg.Reports.SyncFromServer)
+	v.SetDefault("reports.policy_public_key", cfg.Reports.PolicyPublicKey)
+	v.SetDefault("reports.profile", cfg.Reports.Profile)
+	v.SetDefault("reports.max_concurrent_reads", cfg.Reports.MaxConcurrentReads)
+	v.SetDefault("reports.query_timeout", cfg.Reports.QueryTimeout)
+	v.SetDefault("reports.validation_cache", cfg.Reports.ValidationCache)
+
+	// Security
+	v.SetDefault("security.allowed_registry_roots", cfg.Security.AllowedRegistryRoots)
+	v.SetDefault("security.deny_registry_paths", cfg.Security.DenyRegistryPaths)
+	v.SetDefault("security.audit_mode", cfg.Security.AuditMode)
+	v.SetDefault("security.character_policy", cfg.Security.CharacterPolicy)
+	v.SetDefault("security.powershell.enabled", cfg.Security.PowerShell.Enabled)
+	v.SetDefault("security.powershell.execution_policy", cfg.Security.PowerShell.ExecutionPolicy)
+	v.SetDefault("security.powershell.language_mode", cfg.Security.PowerShell.LanguageMode)
+	v.SetDefault("security.powershell.timeout", cfg.Security.PowerShell.Timeout)
+	v.SetDefault("security.powershell.public_key", cfg.Security.PowerShell.PublicKey)
+	v.SetDefault("security.without_elevation", cfg.Security.WithoutElevation)
 
 	// Schedule
 	v.SetDefault("schedule.enabled", cfg.Schedule.Enabled)
 	v.SetDefault("schedule.cron", cfg.Schedule.Cron)
+	v.SetDefault("schedule.heartbeat_interval", cfg.Schedule.HeartbeatInterval)
+	v.SetDefault("schedule.max_run_duration", cfg.Schedule.MaxRunDuration)
 
 	// Retry
 	v.SetDefault("retry.max_attempts", cfg.Retry.MaxAttempts)
@@ -211,10 +511,44 @@ func setClientDefaults(v *viper.Viper, cfg *ClientConfig) {
 	v.SetDefault("cache.max_age", cfg.Cache.MaxAge)
 	v.SetDefault("cache.auto_clean", cfg.Cache.AutoClean)
 
+	// Commands
+	v.SetDefault("commands.enabled", cfg.Commands.Enabled)
+	v.SetDefault("commands.capabilities", cfg.Commands.Capabilities)
+	v.SetDefault("commands.server_public_key", cfg.Commands.ServerPublicKey)
+
+	// Metrics defaults
+	v.SetDefault("metrics.listen", cfg.Metrics.Listen)
+
+	// Resources
+	v.SetDefault("resources.low_priority", cfg.Resources.LowPriority)
+	v.SetDefault("resources.max_workers", cfg.Resources.MaxWorkers)
+	v.SetDefault("resources.cpu_budget", cfg.Resources.CPUBudget)
+
+	// Storage
+	v.SetDefault("storage.enabled", cfg.Storage.Enabled)
+	v.SetDefault("storage.retention", cfg.Storage.Retention)
+	v.SetDefault("storage.type", cfg.Storage.Type)
+	v.SetDefault("storage.dir", cfg.Storage.Dir)
+	v.SetDefault("storage.s3.endpoint", cfg.Storage.S3.Endpoint)
+	v.SetDefault("storage.s3.region", cfg.Storage.S3.Region)
+	v.SetDefault("storage.s3.bucket", cfg.Storage.S3.Bucket)
+	v.SetDefault("storage.s3.prefix", cfg.Storage.S3.Prefix)
+	v.SetDefault("storage.s3.access_key", cfg.Storage.S3.AccessKey)
+	v.SetDefault("storage.s3.secret_key", cfg.Storage.S3.SecretKey)
+	v.SetDefault("storage.s3.path_style", cfg.Storage.S3.PathStyle)
+	v.SetDefault("storage.azure.endpoint", cfg.Storage.Azure.Endpoint)
+	v.SetDefault("storage.azure.account", cfg.Storage.Azure.Account)
+	v.SetDefault("storage.azure.container", cfg.Storage.Azure.Container)
+	v.SetDefault("storage.azure.prefix", cfg.Storage.Azure.Prefix)
+	v.SetDefault("storage.azure.account_key", cfg.Storage.Azure.AccountKey)
+
 	// Logging
 	v.SetDefault("logging.level", cfg.Logging.Level)
 	v.SetDefault("logging.format", cfg.Logging.Format)
 	v.SetDefault("logging.output_path", cfg.Logging.OutputPath)
+	v.SetDefault("logging.event_log.enabled", cfg.Logging.EventLog.Enabled)
+	v.SetDefault("logging.event_log.source", cfg.Logging.EventLog.Source)
+	v.SetDefault("logging.event_log.events", cfg.Logging.EventLog.Events)
 }
 
 // processConfig performs post-processing on the loaded config
@@ -269,6 +603,24 @@ func (c *ClientConfig) IsServerMode() bool {
 	return c.Server.URL != ""
 }
 
+// submissionMetadata returns the metadata sent with submissions, with
+// environment variables expanded. Fields that expand to nothing are left
+// out.
+func (c *ClientConfig) submissionMetadata() map[string]string {
+	var metadata map[string]string
+	for name, value := range c.Metadata {
+		value = strings.TrimSpace(os.ExpandEnv(value))
+		if value == "" {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[name] = value
+	}
+	return metadata
+}
+
 // Validate validates the configuration
 func (c *ClientConfig) Validate() error {
 	if !c.Client.Enabled {
@@ -283,6 +635,79 @@ func (c *ClientConfig) Validate() error {
 		return fmt.Errorf("at least one report must be configured")
 	}
 
+	if err := pkg.ValidateExportFormats(c.Reports.ExportFormats); err != nil {
+		return fmt.Errorf("reports.export_formats: %w", err)
+	}
+	if c.Reports.MaxConcurrentReads < 0 {
+		return fmt.Errorf("reports.max_concurrent_reads must not be negative")
+	}
+	if c.Reports.QueryTimeout < 0 {
+		return fmt.Errorf("reports.query_timeout must not be negative")
+	}
+
+	for _, root := range c.Security.AllowedRegistryRoots {
+		if _, err := pkg.ParseRootKey(strings.ToUpper(strings.TrimSpace(root))); err != nil {
+			return fmt.Errorf("security.allowed_registry_roots: %w", err)
+		}
+	}
+
+	if err := pkg.ValidateDenyList(c.Security.DenyRegistryPaths); err != nil {
+		return fmt.Errorf("security.deny_registry_paths: %w", err)
+	}
+	if err := pkg.ValidateCharacterPolicy(pkg.CharacterPolicy(c.Security.CharacterPolicy)); err != nil {
+		return fmt.Errorf("security.character_policy: %w", err)
+	}
+
+	switch c.Security.WithoutElevation {
+	case elevationRunAll, elevationRunStandard, elevationRefuse:
+	default:
+		return fmt.Errorf("security.without_elevation must be %s, %s or %s", elevationRunAll, elevationRunStandard, elevationRefuse)
+	}
+
+	if powershell := c.Security.PowerShell; powershell.Enabled {
+		if !slices.ContainsFunc(pkg.PowerShellExecutionPolicies, func(policy string) bool {
+			return strings.EqualFold(policy, powershell.ExecutionPolicy)
+		}) {
+			return fmt.Errorf("security.powershell.execution_policy must be one of %s", strings.Join(pkg.PowerShellExecutionPolicies, ", "))
+		}
+		if mode := strings.ToLower(powershell.LanguageMode); mode != "constrained" && mode != "full" {
+			return fmt.Errorf("security.powershell.language_mode must be constrained or full")
+		}
+		if powershell.Timeout < 0 {
+			return fmt.Errorf("security.powershell.timeout must not be negative")
+		}
+		if _, err := c.scriptKey(); err != nil {
+			return err
+		}
+	}
+
+	if c.Metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Listen); err != nil {
+			return fmt.Errorf("metrics.listen: %w", err)
+		}
+	}
+
+	if c.Logging.EventLog.Enabled {
+		if c.Logging.EventLog.Source == "" {
+			return fmt.Errorf("logging.event_log.source is required when the event log is enabled")
+		}
+		for _, category := range c.Logging.EventLog.Events {
+			if !slices.Contains(pkg.AuditEventCategories, category) {
+				return fmt.Errorf("logging.event_log.events: unknown category %q, must be one of %s",
+					category, strings.Join(pkg.AuditEventCategories, ", "))
+			}
+		}
+	}
+
+	if c.Reports.SyncFromServer {
+		if !c.IsServerMode() {
+			return fmt.Errorf("reports.sync_from_server requires server.url")
+		}
+		if _, err := c.policyKey(); err != nil {
+			return err
+		}
+	}
+
 	// If server mode, validate server config
 	if c.IsServerMode() {
 		if c.Server.APIKey == "" {
@@ -293,6 +718,13 @@ func (c *ClientConfig) Validate() error {
 		}
 	}
 
+	if c.Schedule.HeartbeatInterval < 0 {
+		return fmt.Errorf("schedule.heartbeat_interval must be >= 0")
+	}
+	if c.Schedule.MaxRunDuration < 0 {
+		return fmt.Errorf("schedule.max_run_duration must be >= 0")
+	}
+
 	// Validate retry settings
 	if c.Retry.MaxAttempts < 0 {
 		return fmt.Errorf("retry.max_attempts must be >= 0")
@@ -311,5 +743,76 @@ func (c *ClientConfig) Validate() error {
 		}
 	}
 
+	// Validate attachment settings
+	for i, attachment := range c.Attachments {
+		if attachment.Path == "" {
+			return fmt.Errorf("attachments[%d].path is required", i)
+		}
+		if _, err := filepath.Match(attachment.Path, ""); err != nil {
+			return fmt.Errorf("attachments[%d].path: %w", i, err)
+		}
+		if attachment.MaxAge < 0 {
+			return fmt.Errorf("attachments[%d].max_age must be >= 0", i)
+		}
+	}
+	if len(c.Attachments) > 0 && !c.IsServerMode() {
+		return fmt.Errorf("attachments require server.url")
+	}
+
+	// Validate metadata field names, which the server checks against its
+	// definitions
+	for name := range c.Metadata {
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return fmt.Errorf("metadata field %q must be lowercase letters, digits and underscores", name)
+		}
+	}
+
+	// Validate storage settings
+	if c.Storage.Enabled {
+		if c.Storage.Retention < 0 {
+			return fmt.Errorf("storage.retention must be >= 0")
+		}
+		switch c.Storage.Type {
+		case objectstore.TypeDir:
+			if c.Storage.Dir == "" {
+				return fmt.Errorf("storage.dir is required with storage.type %s", objectstore.TypeDir)
+			}
+		case objectstore.TypeS3:
+			s3 := c.Storage.S3
+			if s3.Bucket == "" || s3.Region == "" || s3.AccessKey == "" || s3.SecretKey == "" {
+				return fmt.Errorf("storage.s3 requires bucket, region, access_key and secret_key")
+			}
+		case objectstore.TypeAzure:
+			azure := c.Storage.Azure
+			if azure.Account == "" || azure.Container == "" || azure.AccountKey == "" {
+				return fmt.Errorf("storage.azure requires account, container and account_key")
+			}
+		default:
+			return fmt.Errorf("storage.type must be %s, %s or %s", objectstore.TypeS3, objectstore.TypeAzure, objectstore.TypeDir)
+		}
+	}
+
+	if c.Resources.MaxWorkers < 0 {
+		return fmt.Errorf("resources.max_workers must not be negative")
+	}
+	if c.Resources.CPUBudget < 0 {
+		return fmt.Errorf("resources.cpu_budget must not be negative")
+	}
+
+	// Validate command settings
+	if c.Commands.Enabled {
+		if !c.IsServerMode() {
+			return fmt.Errorf("commands.enabled requires server.url")
+		}
+		if _, err := c.Commands.publicKey(); err != nil {
+			return err
+		}
+		for _, capability := range c.Commands.Capabilities {
+			if !knownCapabilities[capability] {
+				return fmt.Errorf("unknown command capability: %q", capability)
+			}
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// deltaBase is the last submission of a report the server accepted
+type deltaBase struct {
+	submissionID string
+	timestamp    time.Time
+	queries      []api.QueryResult
+}
+
+// send submits a report. With server.delta_submissions it is sent as a delta
+// of the previous accepted submission of its report type, falling back to
+// the full submission when the server refuses the delta.
+func (c *ComplianceClient) send(submission *api.ComplianceSubmission) (*api.SubmissionResponse, error) {
+	if delta := c.deltaSubmission(submission); delta != nil {
+		resp, err := c.api.SubmitDelta(delta)
+		if err == nil {
+			c.rememberSubmission(submission)
+			return resp, nil
+		}
+		if !errors.Is(err, api.ErrDeltaRejected) {
+			return nil, err
+		}
+		c.logger.Info("Server refused delta submission; sending it in full",
+			"submission_id", submission.SubmissionID,
+			"report_type", submission.ReportType,
+			"error", err,
+		)
+	}
+
+	resp, err := c.api.Submit(submission)
+	if err == nil {
+		c.rememberSubmission(submission)
+	}
+	return resp, err
+}
+
+// deltaSubmission returns submission with its check results replaced by a
+// delta, or nil when it has to be sent in full: deltas are disabled, no
+// earlier submission of the report was accepted since the client started,
+// check names are not unique, or every check changed. Evidence is sent for
+// changed checks only.
+func (c *ComplianceClient) deltaSubmission(submission *api.ComplianceSubmission) *api.ComplianceSubmission {
+	if !c.config.Server.DeltaSubmissions {
+		return nil
+	}
+
+	c.deltaMu.Lock()
+	base, ok := c.deltaBases[submission.ReportType]
+	c.deltaMu.Unlock()
+	if !ok || !base.timestamp.Before(submission.Timestamp) {
+		return nil
+	}
+
+	queries := submission.Compliance.Queries
+	delta, ok := api.NewSubmissionDelta(base.submissionID, base.queries, queries)
+	if !ok || len(delta.Changed) == len(queries) {
+		return nil
+	}
+
+	changed := make(map[string]bool, len(delta.Changed))
+	for _, q := range delta.Changed {
+		changed[q.Name] = true
+	}
+
+	partial := *submission
+	partial.Compliance.Queries = nil
+	partial.Evidence = nil
+	for _, e := range submission.Evidence {
+		if changed[e.QueryName] {
+			partial.Evidence = append(partial.Evidence, e)
+		}
+	}
+	partial.Delta = delta
+	return &partial
+}
+
+// rememberSubmission records an accepted submission as the base of its
+// report's next delta, unless a later one is already the base (a cached
+// submission delivered late)
+func (c *ComplianceClient) rememberSubmission(submission *api.ComplianceSubmission) {
+	if !c.config.Server.DeltaSubmissions {
+		return
+	}
+
+	c.deltaMu.Lock()
+	defer c.deltaMu.Unlock()
+	if base, ok := c.deltaBases[submission.ReportType]; ok && base.timestamp.After(submission.Timestamp) {
+		return
+	}
+	if c.deltaBases == nil {
+		c.deltaBases = make(map[string]deltaBase)
+	}
+	c.deltaBases[submission.ReportType] = deltaBase{
+		submissionID: submission.SubmissionID,
+		timestamp:    submission.Timestamp,
+		queries:      submission.Compliance.Queries,
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+)
+
+// processElevated reports whether the client runs with an elevated token:
+// as an administrator past UAC, or as LocalSystem
+func processElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// privilegeReport sorts the queries of a report by whether a standard
+// service account can run them
+type privilegeReport struct {
+	Report   string
+	Standard []string        // Queries a standard account can run
+	Elevated []elevatedQuery // Queries that need elevation
+	Err      error           // The report could not be loaded
+}
+
+// elevatedQuery is a query that needs elevation, and why
+type elevatedQuery struct {
+	Name   string
+	Reason string
+}
+
+// checkPrivileges sorts the queries of a loaded report
+func checkPrivileges(reportName string, report *pkg.RegistryConfig) privilegeReport {
+	result := privilegeReport{Report: reportName}
+	for _, query := range report.Queries {
+		if needs, reason := query.NeedsElevation(); needs {
+			result.Elevated = append(result.Elevated, elevatedQuery{Name: query.Name, Reason: reason})
+		} else {
+			result.Standard = append(result.Standard, query.Name)
+		}
+	}
+	return result
+}
+
+// checkPrivileges loads each report and sorts its queries, as the runner
+// would run them under the configured profile
+func (r *ReportRunner) checkPrivileges(reportNames []string) []privilegeReport {
+	reports := make([]privilegeReport, 0, len(reportNames))
+	for _, reportName := range reportNames {
+		report, err := r.loadReportConfig(reportName)
+		if err == nil {
+			err = report.ApplyProfile(r.config.Reports.Profile)
+		}
+		if err != nil {
+			reports = append(reports, privilegeReport{Report: reportName, Err: err})
+			continue
+		}
+		reports = append(reports, checkPrivileges(reportName, report))
+	}
+	return reports
+}
+
+// logPrivileges logs which configured checks need elevation at startup.
+// An elevated client runs them all; otherwise each is logged with what
+// security.without_elevation does with it.
+func (c *ComplianceClient) logPrivileges() {
+	elevated := c.runner.elevated
+	mode := c.config.Security.WithoutElevation
+	c.logger.Info("Checked privileges", "elevated", elevated, "without_elevation", mode)
+
+	for _, report := range c.runner.checkPrivileges(c.config.Reports.Reports) {
+		if report.Err != nil {
+			c.logger.Warn("Cannot check the privileges of report", "report", report.Report, "error", report.Err)
+			continue
+		}
+		c.logger.Info("Report privileges",
+			"report", report.Report,
+			"standard", len(report.Standard),
+			"needs_elevation", len(report.Elevated),
+		)
+		if elevated {
+			continue
+		}
+		for _, query := range report.Elevated {
+			c.logger.Warn("Check needs elevation, which the client does not have",
+				"report", report.Report,
+				"query", query.Name,
+				"reason", query.Reason,
+				"action", unelevatedAction(mode),
+			)
+		}
+	}
+}
+
+// unelevatedAction describes what an unelevated client does with a check
+// that needs elevation
+func unelevatedAction(mode string) string {
+	switch mode {
+	case elevationRunStandard:
+		return "skipped"
+	case elevationRefuse:
+		return "report refused"
+	default:
+		return "run, likely failing with access_denied"
+	}
+}
+
+// printPrivileges writes the privilege check of every configured report,
+// listing each check (--check-privileges)
+func printPrivileges(w io.Writer, elevated bool, mode string, reports []privilegeReport) {
+	running := "a standard account"
+	if elevated {
+		running = "elevated"
+	}
+	fmt.Fprintf(w, "Running as %s; security.without_elevation: %s\n", running, mode)
+
+	for _, report := range reports {
+		fmt.Fprintf(w, "\n%s\n", report.Report)
+		if report.Err != nil {
+			fmt.Fprintf(w, "  cannot check: %v\n", report.Err)
+			continue
+		}
+		fmt.Fprintf(w, "  %d checks run as a standard account, %d need elevation\n", len(report.Standard), len(report.Elevated))
+		for _, query := range report.Elevated {
+			fmt.Fprintf(w, "  elevated  %s (%s)\n", query.Name, query.Reason)
+		}
+		for _, name := range report.Standard {
+			fmt.Fprintf(w, "  standard  %s\n", name)
+		}
+	}
+}
+
+// unelevatedQuery completes the result of a query skipped because it needs
+// elevation the client does not have
+func unelevatedQuery(query pkg.RegistryQuery, result api.QueryResult, reason string) (api.QueryResult, *api.EvidenceRecord) {
+	result.Status = "error"
+	result.Actual = "not elevated"
+	result.Message = fmt.Sprintf("Skipped: %s and the client is not elevated (security.without_elevation: %s)", reason, elevationRunStandard)
+	result.ErrorClass = api.ErrorClassNeedsElevation
+
+	return result, &api.EvidenceRecord{
+		QueryName: query.Name,
+		Timestamp: time.Now(),
+		Action:    "privilege_check",
+		Result:    "skipped",
+		Details: map[string]interface{}{
+			"root_key":   query.RootKey,
+			"path":       query.Path,
+			"value_name": query.ValueName,
+			"reason":     reason,
+		},
+	}
+}
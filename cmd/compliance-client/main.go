@@ -8,6 +8,10 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/pflag"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/buildinfo"
+	"compliancetoolkit/pkg/fileio"
 )
 
 const version = "1.0.0"
@@ -18,12 +22,14 @@ func main() {
 
 	configFile := flags.StringP("config", "c", "", "Path to config file")
 	reportName := flags.StringP("report", "r", "", "Single report to run (overrides config)")
+	profile := flags.String("profile", "", "Report profile to run, e.g. 'quick' (overrides config)")
 	serverURL := flags.String("server", "", "Server URL (overrides config)")
 	apiKey := flags.String("api-key", "", "API key (overrides config)")
 	standaloneMode := flags.Bool("standalone", false, "Force standalone mode (no server)")
 	onceMode := flags.Bool("once", false, "Run once and exit (ignore schedule)")
 	showVersion := flags.BoolP("version", "v", false, "Show version and exit")
 	generateConfig := flags.Bool("generate-config", false, "Generate default config file and exit")
+	privilegeCheck := flags.Bool("check-privileges", false, "List which checks of the configured reports need elevation and exit")
 
 	// Service management flags
 	installSvc := flags.Bool("install-service", false, "Install as Windows service")
@@ -36,7 +42,7 @@ func main() {
 
 	// Handle version
 	if *showVersion {
-		fmt.Printf("Compliance Toolkit Client v%s\n", version)
+		fmt.Print(buildinfo.String("Compliance Toolkit Client", buildinfo.Get(version)))
 		return
 	}
 
@@ -118,6 +124,9 @@ func main() {
 	if *reportName != "" {
 		config.Reports.Reports = []string{*reportName}
 	}
+	if *profile != "" {
+		config.Reports.Profile = *profile
+	}
 	if *onceMode {
 		config.Schedule.Enabled = false
 	}
@@ -127,10 +136,21 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error: Invalid configuration: %v\n", err)
 		os.Exit(1)
 	}
+	// Validate has checked the policy; registry paths and value names are
+	// validated under it from here on
+	pkg.SetCharacterPolicy(pkg.CharacterPolicy(config.Security.CharacterPolicy))
 
 	// Set up logging
 	logger := setupLogging(config.Logging)
 	slog.SetDefault(logger)
+	applyWorkerLimit(config.Resources, logger)
+
+	// Handle the privilege check
+	if *privilegeCheck {
+		runner := NewReportRunner(config, logger)
+		printPrivileges(os.Stdout, runner.elevated, config.Security.WithoutElevation, runner.checkPrivileges(config.Reports.Reports))
+		return
+	}
 
 	// If running as service, use service runner
 	if isService {
@@ -143,8 +163,11 @@ func main() {
 	}
 
 	// Log startup (interactive/console mode)
+	build := buildinfo.Get(version)
 	slog.Info("Compliance Client starting",
 		"version", version,
+		"commit", build.Commit,
+		"build_sha256", build.BinarySHA256,
 		"client_id", config.Client.ID,
 		"hostname", config.Client.Hostname,
 		"mode", getMode(config),
@@ -257,20 +280,40 @@ server:
   tls_verify: true          # Verify TLS certificates
   timeout: 30s              # Request timeout
   retry_on_startup: true    # Retry cached submissions on startup
+  delta_submissions: false  # Send only changed checks after the first submission of each report
 
 # Report configuration
 reports:
   config_path: "configs/reports"
   output_path: "output/reports"
   save_local: true          # Save HTML reports locally
+  export_formats: []        # Also save results as csv and/or xlsx
+  sync_from_server: false   # Download missing or updated report configs from the server before each run
+  policy_public_key: ""     # Key downloads must be signed with (default: commands.server_public_key)
+  profile: ""               # Run only this profile's queries, e.g. "quick"; reports without it are skipped
+  max_concurrent_reads: 10  # Queries run at once; results keep the report's order
+  query_timeout: 30s        # Stop a query that takes longer (0: reader timeouts only)
   reports:
     - "NIST_800_171_compliance.json"
     # - "FIPS_140_2_compliance.json"
 
+# Registry locations report queries may read. Blocked queries are reported
+# to the server as errors with error class "blocked". Report configs from
+# the server can add a "security" section, which only makes these stricter.
+security:
+  allowed_registry_roots: []  # Root keys queries may read (empty allows all)
+  deny_registry_paths:        # Paths, and their subkeys, queries may never read
+    - 'SOFTWARE\Microsoft\Windows NT\CurrentVersion\Winlogon\SpecialAccounts'
+    - 'SECURITY\Policy\Secrets'
+    - 'SAM\SAM\Domains\Account\Users'
+  audit_mode: false           # Log every registry location queries read or are refused
+  without_elevation: run_all  # Checks needing elevation when not elevated: run_all, run_standard (skip them) or refuse
+
 # Scheduling (requires Windows Service or Task Scheduler)
 schedule:
   enabled: false
   cron: "0 2 * * *"         # Daily at 2 AM (cron syntax)
+  max_run_duration: 2h      # Stop runs that take longer, alert the server and carry on (0 disables)
 
 # Retry configuration
 retry:
@@ -288,15 +331,41 @@ cache:
   max_age: 168h             # 7 days
   auto_clean: true
 
+# Resource limits for machines in use while scans run
+resources:
+  low_priority: false       # Scan at below-normal CPU, I/O and memory priority
+  max_workers: 0            # CPUs the client runs on at once (0: all)
+  cpu_budget: 0s            # Abort a scan that used more CPU time than this, e.g. 2m (0: no limit)
+
+# Prometheus metrics (scheduled mode and service only)
+metrics:
+  listen: ""                # e.g. "127.0.0.1:9183" serves /metrics; empty disables
+
+# Files uploaded with each accepted submission (see client.yaml)
+attachments: []
+
+# Site-specific values sent with every submission (see client.yaml)
+metadata: {}
+
+# Copy reports and evidence to a bucket or container (see client.yaml)
+storage:
+  enabled: false
+  type: "s3"                # s3, azure or dir
+  retention: 0s
+
 # Logging configuration
 logging:
   level: "info"             # debug, info, warn, error
   format: "text"            # text, json
   output_path: "stdout"     # stdout, stderr, or file path
+  event_log:
+    enabled: false          # Write audit events to the Windows Application event log
+    source: "ComplianceToolkitClient"
+    events: ["security"]    # system, registry, config, report, security
 `
 
 	// Write file
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := fileio.WriteFile(path, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
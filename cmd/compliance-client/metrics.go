@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/metrics"
+)
+
+// clientMetrics are the metrics served on metrics.listen
+type clientMetrics struct {
+	registry *metrics.Registry
+
+	reportDuration   *metrics.Gauge
+	reportRuns       *metrics.Counter
+	queryErrors      *metrics.Counter
+	submissions      *metrics.Counter
+	watchdogStops    *metrics.Counter
+	cacheSubmissions *metrics.Gauge
+	cacheBytes       *metrics.Gauge
+}
+
+// newClientMetrics registers the client's metrics. Cache gauges are read
+// from cache, which may be nil, when scraped.
+func newClientMetrics(cache *SubmissionCache) *clientMetrics {
+	r := metrics.NewRegistry()
+	m := &clientMetrics{
+		registry: r,
+		reportDuration: r.Gauge("compliance_client_report_duration_seconds",
+			"How long the last run of each report took.", "report"),
+		reportRuns: r.Counter("compliance_client_report_runs_total",
+			"Report runs by result (success or failed).", "report", "result"),
+		queryErrors: r.Counter("compliance_client_query_errors_total",
+			"Checks that could not be read, by report and error class.", "report", "error_class"),
+		submissions: r.Counter("compliance_client_submissions_total",
+			"Submissions to the server by result (accepted or failed), retries included.", "result"),
+		watchdogStops: r.Counter("compliance_client_watchdog_stops_total",
+			"Report runs stopped for exceeding schedule.max_run_duration.", "report"),
+		cacheSubmissions: r.Gauge("compliance_client_cache_submissions",
+			"Submissions waiting in the local cache for delivery."),
+		cacheBytes: r.Gauge("compliance_client_cache_bytes",
+			"Size of the local submission cache."),
+	}
+
+	if cache != nil {
+		r.OnScrape(func() {
+			if count, err := cache.Count(); err == nil {
+				m.cacheSubmissions.Set(float64(count))
+			}
+			if size, err := cache.Size(); err == nil {
+				m.cacheBytes.Set(float64(size))
+			}
+		})
+	}
+	return m
+}
+
+// observeReport records a completed report run and its unreadable checks
+func (m *clientMetrics) observeReport(report string, seconds float64, submission *api.ComplianceSubmission) {
+	m.reportDuration.Set(seconds, report)
+	m.reportRuns.Inc(report, "success")
+	for _, query := range submission.Compliance.Queries {
+		if query.ErrorClass != "" {
+			m.queryErrors.Inc(report, query.ErrorClass)
+		}
+	}
+}
+
+// startMetrics serves /metrics on metrics.listen until the process exits.
+// A listener that cannot be opened is logged; reports still run.
+func (c *ComplianceClient) startMetrics() {
+	addr := c.config.Metrics.Listen
+	if addr == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		c.logger.Error("Failed to start metrics listener", "listen", addr, "error", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", c.metrics.registry.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, net.ErrClosed) {
+			c.logger.Error("Metrics listener stopped", "error", err)
+		}
+	}()
+	c.logger.Info("Serving metrics", "listen", listener.Addr().String())
+}
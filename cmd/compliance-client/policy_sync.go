@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/fileio"
+	"compliancetoolkit/pkg/schema"
+)
+
+// policyDownloader fetches report configurations published on the server
+type policyDownloader interface {
+	DownloadPolicy(policyID, localSHA256 string) (*api.PolicyDownload, error)
+}
+
+// reportPolicyID returns the server policy a report file is published as:
+// its name without extension, as the server assigns when it imports
+// configs/reports
+func reportPolicyID(reportName string) string {
+	base := filepath.Base(reportName)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// syncReportConfig brings the local copy of a report configuration up to
+// date with the policy published on the server. The server answers Not
+// Modified while the local file's SHA-256 matches; otherwise the download
+// must be signed with the pinned policy key, match the version the server
+// declares and pass checkPolicy before it replaces the local file. A
+// refused download is recorded as an audit event and the local copy kept.
+// Reports the server does not publish are left alone.
+func (r *ReportRunner) syncReportConfig(reportName string) error {
+	path := filepath.Join(r.config.Reports.ConfigPath, reportName)
+	policyID := reportPolicyID(reportName)
+
+	localSum := ""
+	localVersion := ""
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		sum := sha256.Sum256(data)
+		localSum = hex.EncodeToString(sum[:])
+		var local pkg.RegistryConfig
+		if json.Unmarshal(data, &local) == nil {
+			localVersion = local.Metadata.ReportVersion
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to read local report config: %w", err)
+	}
+
+	download, err := r.policies.DownloadPolicy(policyID, localSum)
+	if errors.Is(err, api.ErrPolicyNotFound) {
+		r.logger.Debug("Report is not published on the server; using local copy", "report", reportName, "policy_id", policyID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if download.NotModified {
+		r.logger.Debug("Report config is current", "report", reportName, "version", download.Version)
+		return nil
+	}
+
+	config, err := r.checkDownload(policyID, download)
+	if err != nil {
+		r.audit.LogSecurityEvent(pkg.AuditEventPolicyViolation, policyID, err.Error(), map[string]interface{}{
+			"report":  reportName,
+			"version": download.Version,
+			"sha256":  download.SHA256,
+		})
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report config directory: %w", err)
+	}
+	if err := fileio.WriteFile(path, download.Data, 0644); err != nil {
+		return fmt.Errorf("failed to save report config: %w", err)
+	}
+
+	r.logger.Info("Updated report config from server",
+		"report", reportName,
+		"policy_id", policyID,
+		"previous_version", localVersion,
+		"version", config.Metadata.ReportVersion,
+		"sha256", download.SHA256,
+	)
+	return nil
+}
+
+// checkDownload verifies a downloaded report config and decodes it: it must
+// be signed, declare the version the server published and pass checkPolicy
+func (r *ReportRunner) checkDownload(policyID string, download *api.PolicyDownload) (*pkg.RegistryConfig, error) {
+	if err := verifyPolicy(r.policyKey, policyID, download); err != nil {
+		return nil, err
+	}
+	if err := schema.ValidateReport(download.Data); err != nil {
+		return nil, fmt.Errorf("downloaded report config does not match the report schema: %w", err)
+	}
+	var config pkg.RegistryConfig
+	if err := json.Unmarshal(download.Data, &config); err != nil {
+		return nil, fmt.Errorf("downloaded report config is not valid JSON: %w", err)
+	}
+	if download.Version != "" && config.Metadata.ReportVersion != download.Version {
+		return nil, fmt.Errorf("downloaded report config is version %q, but the server published version %q",
+			config.Metadata.ReportVersion, download.Version)
+	}
+	if err := checkPolicy(&config, r.config.Security); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// checkPolicy applies the security validators to a report config from the
+// server. Every query must be read-only, as the client never writes the
+// registry, and well formed; powershell queries are only accepted when
+// enabled, and their scripts are still verified before they run. A config
+// reaching for a path the client's own deny list blocks is refused whole,
+// rather than run with those queries blocked as a local config would be.
+func checkPolicy(config *pkg.RegistryConfig, security SecuritySettings) error {
+	for i, query := range config.Queries {
+		if query.IsPowerShell() && !security.PowerShell.Enabled {
+			return fmt.Errorf("downloaded report config query[%d] (%s) runs a powershell script, and security.powershell.enabled is not set",
+				i, query.Name)
+		}
+		if !strings.EqualFold(query.Operation, "read") && !query.IsTree() && !query.IsPowerShell() {
+			return fmt.Errorf("downloaded report config query[%d] (%s) has operation %q; only read, read_tree, read_users and powershell queries are accepted",
+				i, query.Name, query.Operation)
+		}
+	}
+	if err := pkg.ValidateConfig(config); err != nil {
+		return fmt.Errorf("downloaded report config is invalid: %w", err)
+	}
+	for i, query := range config.Queries {
+		if err := pkg.ValidateAgainstDenyList(query.Path, security.DenyRegistryPaths); err != nil {
+			return fmt.Errorf("downloaded report config query[%d] (%s) is refused: %w", i, query.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifyPolicy checks a downloaded report config's signature, which covers
+// the policy ID the client asked for, the version and the data's hash
+func verifyPolicy(key ed25519.PublicKey, policyID string, download *api.PolicyDownload) error {
+	if download.Signature == "" {
+		return fmt.Errorf("downloaded report config is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(download.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid report config signature encoding: %w", err)
+	}
+	if key == nil || !ed25519.Verify(key, api.PolicySigningPayload(policyID, download.Version, download.SHA256), signature) {
+		return fmt.Errorf("downloaded report config signature does not verify")
+	}
+	return nil
+}
+
+// assignedReports returns the report files of the policies the server
+// assigned to the client that reports.reports does not already run
+func assignedReports(configured, policyIDs []string) []string {
+	running := make(map[string]bool, len(configured))
+	for _, reportName := range configured {
+		running[reportPolicyID(reportName)] = true
+	}
+	var reports []string
+	for _, policyID := range policyIDs {
+		if policyID == "" || running[policyID] || policyID != filepath.Base(policyID) {
+			continue
+		}
+		running[policyID] = true
+		reports = append(reports, policyID+".json")
+	}
+	return reports
+}
+
+// reportNames returns the reports the client runs: reports.reports and the
+// policies assigned to it on the server
+func (c *ComplianceClient) reportNames() []string {
+	names := append([]string{}, c.config.Reports.Reports...)
+	if assigned := c.assigned.Load(); assigned != nil {
+		names = append(names, *assigned...)
+	}
+	return names
+}
+
+// setAssignedPolicies adopts the policies a heartbeat says are assigned to
+// the client, directly or through its groups. Newly assigned ones are
+// downloaded at once, and the schedule reported to the server is updated
+// so missed runs of them are detected. Assigned policies need
+// reports.sync_from_server; without it they are ignored.
+func (c *ComplianceClient) setAssignedPolicies(policyIDs []string) {
+	if c.runner.policies == nil {
+		return
+	}
+	reports := assignedReports(c.config.Reports.Reports, policyIDs)
+	previous := c.assigned.Load()
+	if previous != nil && slices.Equal(*previous, reports) {
+		return
+	}
+	c.assigned.Store(&reports)
+
+	for _, reportName := range reports {
+		if previous != nil && slices.Contains(*previous, reportName) {
+			continue
+		}
+		if err := c.runner.syncReportConfig(reportName); err != nil {
+			c.logger.Error("Failed to download assigned policy", "report", reportName, "error", err)
+		}
+	}
+	c.logger.Info("Assigned policies changed", "reports", reports)
+
+	if c.schedule.Load() != nil {
+		c.schedule.Store(c.scheduleInfo())
+	}
+}
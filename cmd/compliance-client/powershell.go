@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+)
+
+// errScriptSignature is returned by verifyScript for a script whose
+// signature was not made with the script signing key
+var errScriptSignature = errors.New("script signature does not verify")
+
+// verifyScript checks that a powershell query's script is signed with key,
+// for the query's own name
+func verifyScript(key ed25519.PublicKey, query pkg.RegistryQuery) error {
+	if key == nil {
+		return fmt.Errorf("no script signing key is configured")
+	}
+	signature, err := base64.StdEncoding.DecodeString(query.ScriptSignature)
+	if err != nil || !ed25519.Verify(key, api.ScriptSigningPayload(query.Name, query.Script), signature) {
+		return errScriptSignature
+	}
+	return nil
+}
+
+// executePowerShellQuery runs the script of a powershell query and compares
+// its output with the expected value. Scripts only run when enabled and
+// signed; the command line, script, output and exit code are kept in the
+// evidence record.
+func (r *ReportRunner) executePowerShellQuery(ctx context.Context, query pkg.RegistryQuery, security querySecurity,
+	result api.QueryResult) (api.QueryResult, *api.EvidenceRecord) {
+
+	settings := r.config.Security.PowerShell
+	if !settings.Enabled {
+		return r.blockedQuery(query, result, "powershell.enabled", fmt.Errorf("powershell queries are disabled"))
+	}
+	if err := query.Validate(); err != nil {
+		result.Status = "error"
+		result.Actual = "error"
+		result.Message = fmt.Sprintf("Invalid powershell query: %v", err)
+		result.ErrorClass = api.ErrorClassInvalidQuery
+		return result, nil
+	}
+	if err := verifyScript(r.scriptKey, query); err != nil {
+		return r.blockedQuery(query, result, "powershell.public_key", err)
+	}
+
+	opts := settings.options(query)
+	run, err := pkg.RunPowerShell(ctx, query.Script, opts)
+	if security.AuditMode {
+		r.logger.Info("PowerShell script run",
+			"query", query.Name,
+			"execution_policy", opts.ExecutionPolicy,
+			"language_mode", opts.LanguageMode,
+			"exit_code", run.ExitCode,
+			"error", err,
+		)
+	}
+
+	sum := sha256.Sum256([]byte(query.Script))
+	evidence := &api.EvidenceRecord{
+		QueryName: query.Name,
+		Timestamp: time.Now(),
+		Action:    "powershell_run",
+		Details: map[string]interface{}{
+			"command":          strings.Join(run.Command, " "),
+			"script":           run.Script,
+			"script_sha256":    hex.EncodeToString(sum[:]),
+			"execution_policy": opts.ExecutionPolicy,
+			"language_mode":    opts.LanguageMode,
+			"timeout":          opts.Timeout.String(),
+			"stdout":           run.Stdout,
+			"stderr":           run.Stderr,
+			"exit_code":        run.ExitCode,
+			"output_truncated": run.Truncated,
+			"duration":         run.Duration.Milliseconds(),
+		},
+	}
+
+	if err != nil {
+		result.Status = "error"
+		result.Actual = "error"
+		result.Message = err.Error()
+		result.ErrorClass = classifyReadError(err)
+		evidence.Result = "error"
+		evidence.Details["error"] = err.Error()
+		evidence.Details["error_class"] = result.ErrorClass
+		return result, evidence
+	}
+
+	lines := run.Lines()
+	result.Actual = strings.Join(lines, ", ")
+	if len(lines) > 1 {
+		result.ActualList = lines
+	}
+
+	switch {
+	case run.ExitCode != 0:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Script exited with code %d", run.ExitCode)
+		if stderr := strings.TrimSpace(run.Stderr); stderr != "" {
+			result.Message += ": " + strings.SplitN(stderr, "\n", 2)[0]
+		}
+		result.ErrorClass = api.ErrorClassReadFailed
+		evidence.Result = "error"
+		return result, evidence
+	case run.Truncated:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Script wrote more than %d bytes of output; only the start was kept", pkg.MaxPowerShellOutputLength)
+		result.ErrorClass = api.ErrorClassReadFailed
+		evidence.Result = "error"
+		return result, evidence
+	}
+
+	evidence.Result = "success"
+	if query.ExpectedValue == "" {
+		result.Status = "pass"
+		return result, evidence
+	}
+
+	matches, err := query.MatchesOutput(lines)
+	switch {
+	case err != nil:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Cannot compare value: %v", err)
+		result.ErrorClass = api.ErrorClassInvalidQuery
+	case matches:
+		result.Status = "pass"
+	default:
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("Expected '%s', got '%s'", query.ExpectedDescription(), result.Actual)
+	}
+	return result, evidence
+}
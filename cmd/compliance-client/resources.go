@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// errCPUBudgetExceeded is returned when a scan uses more CPU time than
+// resources.cpu_budget allows
+var errCPUBudgetExceeded = errors.New("CPU budget exceeded")
+
+// applyWorkerLimit caps the CPUs the client runs on at once
+func applyWorkerLimit(settings ResourceSettings, logger *slog.Logger) {
+	if settings.MaxWorkers <= 0 {
+		return
+	}
+	previous := runtime.GOMAXPROCS(settings.MaxWorkers)
+	logger.Info("Limited worker concurrency", "max_workers", settings.MaxWorkers, "cpus", previous)
+}
+
+// lowPriority lowers the process's priority while at least one scan runs.
+// Priority is process-wide and a command can start a scan while a
+// scheduled one runs, so scans are counted and the last to finish
+// restores the priority.
+var lowPriority scanPriority
+
+type scanPriority struct {
+	mu       sync.Mutex
+	scans    int
+	previous uint32
+}
+
+// enter lowers the priority class to below normal and starts background
+// mode, which also lowers I/O and memory priority. The returned function
+// ends the scan. Failures are logged; the scan runs either way.
+func (p *scanPriority) enter(logger *slog.Logger) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scans++
+	if p.scans == 1 {
+		process := windows.CurrentProcess()
+		previous, err := windows.GetPriorityClass(process)
+		if err != nil {
+			previous = windows.NORMAL_PRIORITY_CLASS
+		}
+		p.previous = previous
+		if err := windows.SetPriorityClass(process, windows.PROCESS_MODE_BACKGROUND_BEGIN); err != nil {
+			logger.Warn("Failed to start background processing mode", "error", err)
+		}
+		if err := windows.SetPriorityClass(process, windows.BELOW_NORMAL_PRIORITY_CLASS); err != nil {
+			logger.Warn("Failed to lower process priority", "error", err)
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { p.leave(logger) }) }
+}
+
+// leave restores the priority when the last scan finishes
+func (p *scanPriority) leave(logger *slog.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scans--
+	if p.scans > 0 {
+		return
+	}
+	process := windows.CurrentProcess()
+	if err := windows.SetPriorityClass(process, windows.PROCESS_MODE_BACKGROUND_END); err != nil {
+		logger.Warn("Failed to end background processing mode", "error", err)
+	}
+	if err := windows.SetPriorityClass(process, p.previous); err != nil {
+		logger.Warn("Failed to restore process priority", "error", err)
+	}
+}
+
+// processCPUTime returns the user and kernel CPU time the process has used
+func processCPUTime() (time.Duration, error) {
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(windows.CurrentProcess(), &creation, &exit, &kernel, &user); err != nil {
+		return 0, fmt.Errorf("failed to read process times: %w", err)
+	}
+	return filetimeDuration(kernel) + filetimeDuration(user), nil
+}
+
+// filetimeDuration converts a FILETIME interval, in 100ns units
+func filetimeDuration(ft windows.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
+
+// cpuBudget measures the CPU time the process used since a scan started.
+// The process's other work, such as heartbeats, counts too, which is small
+// next to a scan.
+type cpuBudget struct {
+	limit time.Duration
+	start time.Duration
+	used  func() (time.Duration, error)
+}
+
+// newCPUBudget starts measuring; a limit of 0, or CPU time that cannot be
+// read, means no limit
+func newCPUBudget(limit time.Duration, used func() (time.Duration, error)) *cpuBudget {
+	if limit <= 0 {
+		return &cpuBudget{}
+	}
+	start, err := used()
+	if err != nil {
+		slog.Warn("CPU budget disabled", "error", err)
+		return &cpuBudget{}
+	}
+	return &cpuBudget{limit: limit, start: start, used: used}
+}
+
+// check returns errCPUBudgetExceeded once the scan used more than the limit
+func (b *cpuBudget) check() error {
+	if b.used == nil {
+		return nil
+	}
+	now, err := b.used()
+	if err != nil {
+		return nil
+	}
+	if spent := now - b.start; spent > b.limit {
+		return fmt.Errorf("%w: used %s of %s", errCPUBudgetExceeded, spent.Round(time.Millisecond), b.limit)
+	}
+	return nil
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +21,9 @@ import (
 
 	"compliancetoolkit/pkg"
 	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/buildinfo"
+	"compliancetoolkit/pkg/fingerprint"
+	"compliancetoolkit/pkg/objectstore"
 )
 
 // ReportRunner executes compliance reports and generates submissions
@@ -24,6 +31,31 @@ type ReportRunner struct {
 	config *ClientConfig
 	logger *slog.Logger
 	reader *pkg.RegistryReader
+
+	// policies pulls report configs from the server before they are loaded;
+	// nil unless reports.sync_from_server is set. Downloads must be signed
+	// with policyKey.
+	policies  policyDownloader
+	policyKey ed25519.PublicKey
+
+	// scriptKey verifies the scripts of powershell queries; nil unless
+	// security.powershell.enabled is set
+	scriptKey ed25519.PublicKey
+
+	// validated skips validating report configs that have not changed
+	// since they last passed
+	validated *pkg.ValidationCache
+
+	// audit records downloaded report configs the runner refuses
+	audit *pkg.AuditLogger
+
+	// store receives copies of reports and evidence; nil unless storage is
+	// enabled
+	store objectstore.Store
+
+	// elevated is set when the client runs as an elevated administrator or
+	// LocalSystem; see security.without_elevation
+	elevated bool
 }
 
 // NewReportRunner creates a new report runner
@@ -34,43 +66,157 @@ func NewReportRunner(config *ClientConfig, logger *slog.Logger) *ReportRunner {
 		pkg.WithTimeout(5*time.Second),
 	)
 
-	return &ReportRunner{
-		config: config,
-		logger: logger,
-		reader: reader,
+	runner := &ReportRunner{
+		config:   config,
+		logger:   logger,
+		reader:   reader,
+		audit:    pkg.NewAuditLogger(logger, true),
+		elevated: processElevated(),
+	}
+
+	if settings := config.Logging.EventLog; settings.Enabled {
+		sink, err := pkg.NewEventLogSink(settings.Source, settings.Events)
+		if err != nil {
+			logger.Warn("Failed to open event log, audit events go to the log only", "error", err)
+		} else {
+			runner.audit.AddSink(sink)
+		}
 	}
+
+	// Validation results hold for the build and character policy that
+	// produced them, so an upgraded client validates every config again
+	if config.Reports.ValidationCache != "" {
+		build := buildinfo.Get(version)
+		rules := strings.Join([]string{build.Version, build.BinarySHA256, config.Security.CharacterPolicy}, "+")
+		runner.validated = pkg.NewValidationCache(config.Reports.ValidationCache, rules)
+	}
+
+	if config.Storage.Enabled {
+		store, err := objectstore.New(config.Storage.Config)
+		if err != nil {
+			logger.Warn("Failed to initialize report storage; reports are kept locally only", "error", err)
+		} else {
+			runner.store = store
+		}
+	}
+	return runner
 }
 
 // Run executes a report and returns a ComplianceSubmission
-func (r *ReportRunner) Run(reportName string) (*api.ComplianceSubmission, error) {
+func (r *ReportRunner) Run(ctx context.Context, reportName string) (*api.ComplianceSubmission, error) {
 	startTime := time.Now()
 
+	// Pull a missing or updated configuration from the server. If that
+	// fails the local copy, if any, still runs.
+	if r.policies != nil {
+		if err := r.syncReportConfig(reportName); err != nil {
+			r.logger.Warn("Failed to sync report config from server", "report", reportName, "error", err)
+		}
+	}
+
 	// Load report configuration
 	reportConfig, err := r.loadReportConfig(reportName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load report config: %w", err)
 	}
+	if err := reportConfig.ApplyProfile(r.config.Reports.Profile); err != nil {
+		return nil, err
+	}
 
 	r.logger.Info("Loaded report configuration",
 		"report", reportConfig.Metadata.ReportTitle,
 		"version", reportConfig.Metadata.ReportVersion,
+		"profile", r.config.Reports.Profile,
 		"queries", len(reportConfig.Queries),
 	)
 
-	// Execute all queries
-	results := make([]api.QueryResult, 0, len(reportConfig.Queries))
-	evidence := make([]api.EvidenceRecord, 0)
+	// A report's security section can only tighten the client's settings
+	security, ok := r.config.Security.forReport(reportConfig)
+	if !ok {
+		r.logger.Warn("Report and client allow no registry root key in common; every query is blocked",
+			"report", reportConfig.Metadata.ReportTitle,
+			"report_roots", reportConfig.Security.AllowedRegistryRoots,
+			"client_roots", r.config.Security.AllowedRegistryRoots,
+		)
+	}
+	rules := querySecurity{ReportSecurity: security, noRoots: !ok}
+
+	// Without elevation, checks that need it are run anyway, skipped or
+	// keep the whole report from running
+	if !r.elevated && r.config.Security.WithoutElevation != elevationRunAll {
+		privileges := checkPrivileges(reportName, reportConfig)
+		if len(privileges.Elevated) > 0 && r.config.Security.WithoutElevation == elevationRefuse {
+			return nil, fmt.Errorf("%d of %d checks need elevation, which the client does not have (security.without_elevation: %s)",
+				len(privileges.Elevated), len(reportConfig.Queries), elevationRefuse)
+		}
+		if len(privileges.Elevated) > 0 {
+			r.logger.Warn("Skipping checks that need elevation",
+				"report", reportConfig.Metadata.ReportTitle,
+				"skipped", len(privileges.Elevated),
+				"queries", len(reportConfig.Queries),
+			)
+			rules.skipElevated = true
+		}
+	}
+
+	if r.config.Resources.LowPriority {
+		defer lowPriority.enter(r.logger)()
+	}
+	budget := newCPUBudget(r.config.Resources.CPUBudget, processCPUTime)
+
+	// Execute all queries, up to reports.max_concurrent_reads at a time.
+	// Each query fills its own slot, so results keep the report's order.
+	queries := reportConfig.Queries
+	results := make([]api.QueryResult, len(queries))
+	evidenceRecs := make([]*api.EvidenceRecord, len(queries))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var budgetErr error
+	var budgetOnce sync.Once
+	var started atomic.Int32
+
+	scanStart := time.Now()
+	err = pkg.RunConcurrently(ctx, len(queries), r.config.Reports.MaxConcurrentReads, r.config.Reports.QueryTimeout, func(ctx context.Context, i int) {
+		if err := budget.check(); err != nil {
+			budgetOnce.Do(func() {
+				budgetErr = err
+				cancel()
+			})
+			return
+		}
+		started.Add(1)
+
+		queryStart := time.Now()
+		result, evidenceRec := r.executeQuery(ctx, queries[i], rules)
+		result.DurationMs = float64(time.Since(queryStart).Microseconds()) / 1000.0
+		results[i], evidenceRecs[i] = result, evidenceRec
+	})
+	if budgetErr != nil {
+		run := int(started.Load())
+		r.logger.Warn("Aborting scan",
+			"report", reportName,
+			"queries_run", run,
+			"queries", len(queries),
+			"error", budgetErr,
+		)
+		return nil, fmt.Errorf("scan aborted after %d of %d queries: %w", run, len(queries), budgetErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan cancelled after %d of %d queries: %w", started.Load(), len(queries), err)
+	}
 
-	for _, query := range reportConfig.Queries {
-		result, evidenceRec := r.executeQuery(query)
-		results = append(results, result)
-		if evidenceRec != nil {
-			evidence = append(evidence, *evidenceRec)
+	checkDurations := make([]float64, 0, len(queries))
+	evidence := make([]api.EvidenceRecord, 0)
+	for i, result := range results {
+		checkDurations = append(checkDurations, result.DurationMs)
+		if evidenceRecs[i] != nil {
+			evidence = append(evidence, *evidenceRecs[i])
 		}
 	}
+	scanDuration := time.Since(scanStart)
 
 	// Calculate compliance statistics
-	complianceData := r.calculateCompliance(results)
+	complianceData := api.NewComplianceData(results)
 
 	// Collect system information
 	sysInfo := r.collectSystemInfo()
@@ -89,14 +235,24 @@ func (r *ReportRunner) Run(reportName string) (*api.ComplianceSubmission, error)
 		Compliance:    complianceData,
 		Evidence:      evidence,
 		SystemInfo:    sysInfo,
+		Telemetry:     collectTelemetry(scanDuration, checkDurations),
+		Metadata:      r.config.submissionMetadata(),
 	}
 
 	// Save local HTML report if configured
+	var files []string
 	if r.config.Reports.SaveLocal {
-		if err := r.saveHTMLReport(reportConfig, results); err != nil {
+		saved, err := r.saveHTMLReport(reportConfig, results)
+		if err != nil {
 			r.logger.Warn("Failed to save HTML report", "error", err)
 			// Don't fail - report execution succeeded
 		}
+		files = saved
+	}
+
+	// Copy the reports and evidence to object storage if configured
+	if r.store != nil {
+		r.storeReport(reportName, submission, files)
 	}
 
 	duration := time.Since(startTime)
@@ -108,32 +264,60 @@ func (r *ReportRunner) Run(reportName string) (*api.ComplianceSubmission, error)
 	return submission, nil
 }
 
-// loadReportConfig loads a report configuration file
+// loadReportConfig loads and validates a report configuration file.
+// Validation is skipped for a file unchanged since it last passed.
 func (r *ReportRunner) loadReportConfig(reportName string) (*pkg.RegistryConfig, error) {
 	// Build path to config file
 	configPath := filepath.Join(r.config.Reports.ConfigPath, reportName)
 
-	// Load using existing pkg function
-	config, err := pkg.LoadRegistryConfig(configPath)
-	if err != nil {
+	config, cached, err := r.validated.LoadConfig(configPath)
+	if config == nil {
 		return nil, fmt.Errorf("failed to load report: %w", err)
 	}
+	if err != nil {
+		r.logger.Warn("Failed to save report config validation", "report", reportName, "error", err)
+	}
+	r.logger.Debug("Report config validated", "report", reportName, "cached", cached)
 
 	return config, nil
 }
 
+// querySecurity is the security settings a report's queries run under
+type querySecurity struct {
+	pkg.ReportSecurity
+	noRoots      bool // The client and report allow no root key in common
+	skipElevated bool // Skip queries that need elevation; the client is not elevated
+}
+
 // executeQuery executes a single registry query
-func (r *ReportRunner) executeQuery(query pkg.RegistryQuery) (api.QueryResult, *api.EvidenceRecord) {
-	ctx := context.Background()
+func (r *ReportRunner) executeQuery(ctx context.Context, query pkg.RegistryQuery, security querySecurity) (api.QueryResult, *api.EvidenceRecord) {
 	queryStart := time.Now()
 
 	result := api.QueryResult{
 		Name:        query.Name,
 		Description: query.Description,
 		Expected:    query.ExpectedValue,
+		Operator:    query.ExpectedOperator,
 		RootKey:     query.RootKey,
 		Path:        query.Path,
 		ValueName:   query.ValueName,
+		Controls:    query.Controls,
+	}
+
+	if security.skipElevated {
+		if needs, reason := query.NeedsElevation(); needs {
+			return unelevatedQuery(query, result, reason)
+		}
+	}
+
+	if query.IsPowerShell() {
+		return r.executePowerShellQuery(ctx, query, security, result)
+	}
+
+	// Queries outside the registry locations in security settings are not
+	// run, and are reported so the server can show what policies attempt
+	if rule, err := securityViolation(query, security); err != nil {
+		return r.blockedQuery(query, result, rule, err)
 	}
 
 	// Parse root key
@@ -142,11 +326,25 @@ func (r *ReportRunner) executeQuery(query pkg.RegistryQuery) (api.QueryResult, *
 		result.Status = "error"
 		result.Message = fmt.Sprintf("Invalid root key: %v", err)
 		result.Actual = "error"
+		result.ErrorClass = api.ErrorClassInvalidQuery
 		return result, nil
 	}
 
+	if query.IsTree() {
+		return r.executeTreeQuery(ctx, query, rootKey, security, result, queryStart)
+	}
+
 	// Execute registry read
-	value, err := r.reader.ReadValue(ctx, rootKey, query.Path, query.ValueName)
+	typed, err := r.reader.ReadQueryValue(ctx, rootKey, query)
+	if security.AuditMode {
+		r.logger.Info("Registry access",
+			"query", query.Name,
+			"root_key", query.RootKey,
+			"path", query.Path,
+			"value_name", query.ValueName,
+			"error", err,
+		)
+	}
 
 	// Create evidence record
 	evidence := &api.EvidenceRecord{
@@ -162,6 +360,9 @@ func (r *ReportRunner) executeQuery(query pkg.RegistryQuery) (api.QueryResult, *
 	}
 
 	if err != nil {
+		result.ErrorClass = classifyReadError(err)
+		evidence.Details["error_class"] = result.ErrorClass
+
 		// Check if it's a "not found" error
 		if pkg.IsNotExist(err) {
 			result.Status = "fail"
@@ -179,59 +380,239 @@ func (r *ReportRunner) executeQuery(query pkg.RegistryQuery) (api.QueryResult, *
 	}
 
 	// Success - compare with expected value
+	value := typed.Display()
 	result.Actual = value
+	if typed.IsList() {
+		result.ActualList = typed.Strings
+	}
 	evidence.Result = "success"
-	evidence.Details["actual_value"] = value
+	evidence.Details["actual_value"] = typed.ReportValue()
+	evidence.Details["value_type"] = typed.Type
 
 	// Debug logging
 	r.logger.Debug("Comparing values",
 		"query", query.Name,
 		"actual", value,
 		"expected", query.ExpectedValue,
+		"operator", query.ExpectedOperator,
 		"actual_len", len(value),
 		"expected_len", len(query.ExpectedValue),
 	)
 
-	// Smart comparison (handles both exact matches and "value (description)" format)
-	matches := compareValues(value, query.ExpectedValue)
+	// Smart comparison (exact matches, "value (description)" format, or the
+	// query's expected_operator); multi-string items are compared as a list
+	matches, err := query.MatchesValue(typed)
 	r.logger.Debug("Comparison result",
 		"query", query.Name,
 		"matches", matches,
+		"error", err,
 	)
 
-	if matches {
+	switch {
+	case err != nil:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Cannot compare value: %v", err)
+		result.ErrorClass = api.ErrorClassInvalidQuery
+	case matches:
 		result.Status = "pass"
-	} else {
+	default:
 		result.Status = "fail"
-		result.Message = fmt.Sprintf("Expected '%s', got '%s'", query.ExpectedValue, value)
+		result.Message = fmt.Sprintf("Expected '%s', got '%s'", query.ExpectedDescription(), value)
 	}
 
 	return result, evidence
 }
 
-// calculateCompliance calculates overall compliance statistics
-func (r *ReportRunner) calculateCompliance(results []api.QueryResult) api.ComplianceData {
-	data := api.ComplianceData{
-		TotalChecks: len(results),
-		Queries:     results,
+// maxTreeMismatches is how many failing values a read_tree result's message
+// names
+const maxTreeMismatches = 5
+
+// executeTreeQuery executes a read_tree or read_users query. Without an expected value it
+// only collects the tree; with one, every value read must meet it.
+func (r *ReportRunner) executeTreeQuery(ctx context.Context, query pkg.RegistryQuery, rootKey registry.Key,
+	security querySecurity, result api.QueryResult, queryStart time.Time) (api.QueryResult, *api.EvidenceRecord) {
+
+	tree, err := r.reader.ReadTree(ctx, rootKey, query.Path, query.TreeOptions(security.DenyRegistryPaths))
+	if security.AuditMode {
+		r.logger.Info("Registry tree access",
+			"query", query.Name,
+			"root_key", query.RootKey,
+			"path", query.Path,
+			"value_name", query.ValueName,
+			"error", err,
+		)
 	}
 
-	for _, result := range results {
-		switch result.Status {
-		case "pass":
-			data.PassedChecks++
-		case "fail":
-			data.FailedChecks++
-		case "warning":
-			data.WarningChecks++
-		case "error":
-			data.ErrorChecks++
+	evidence := &api.EvidenceRecord{
+		QueryName: query.Name,
+		Timestamp: time.Now(),
+		Action:    "registry_read",
+		Details: map[string]interface{}{
+			"root_key":   query.RootKey,
+			"path":       query.Path,
+			"value_name": query.ValueName,
+			"duration":   time.Since(queryStart).Milliseconds(),
+		},
+	}
+
+	if err != nil {
+		result.ErrorClass = classifyReadError(err)
+		evidence.Details["error_class"] = result.ErrorClass
+		if pkg.IsNotExist(err) {
+			result.Status = "fail"
+			result.Actual = "not found"
+			result.Message = "Registry key not found"
+			evidence.Result = "not_found"
+		} else {
+			result.Status = "error"
+			result.Actual = "error"
+			result.Message = err.Error()
+			evidence.Result = "error"
+		}
+		evidence.Details["error"] = err.Error()
+		return result, evidence
+	}
+
+	values := tree.Values()
+	result.Actual = tree.String()
+	evidence.Result = "success"
+	evidence.Details["key_count"] = len(tree.Keys)
+	evidence.Details["value_count"] = len(values)
+	evidence.Details["truncated"] = tree.Truncated
+	evidence.Details["skipped"] = tree.Skipped
+	if query.IsPerUser() {
+		evidence.Details["users"] = len(tree.Keys) + len(tree.Missing)
+		evidence.Details["missing"] = tree.Missing
+	}
+
+	if query.ExpectedValue == "" {
+		result.Status = "pass"
+		return result, evidence
+	}
+
+	mismatches, err := query.MatchesTree(tree)
+	switch {
+	case errors.Is(err, pkg.ErrEmptyTree):
+		result.Status = "fail"
+		result.Message = "No values found under the key"
+		if query.IsPerUser() {
+			result.Message = "No user hives are loaded"
 		}
+	case err != nil:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Cannot compare value: %v", err)
+		result.ErrorClass = api.ErrorClassInvalidQuery
+	case len(mismatches) > 0:
+		result.Status = "fail"
+		names := make([]string, 0, maxTreeMismatches)
+		for i, value := range mismatches {
+			if i == maxTreeMismatches {
+				names = append(names, fmt.Sprintf("and %d more", len(mismatches)-i))
+				break
+			}
+			names = append(names, value.String())
+		}
+		result.Message = fmt.Sprintf("%d of %d values do not match '%s': %s",
+			len(mismatches), tree.Compared(), query.ExpectedDescription(), strings.Join(names, "; "))
+	case tree.Truncated:
+		result.Status = "error"
+		result.Message = fmt.Sprintf("Only the first %d keys were checked; raise max_keys", len(tree.Keys))
+	default:
+		result.Status = "pass"
+	}
+	return result, evidence
+}
+
+// securityViolation returns the security setting that blocks query and the
+// reason, or nil when the query may run
+func securityViolation(query pkg.RegistryQuery, security querySecurity) (string, error) {
+	if err := pkg.ValidateAgainstDenyList(query.Path, security.DenyRegistryPaths); err != nil {
+		return "deny_registry_paths", err
+	}
+	if security.noRoots {
+		return "allowed_registry_roots", fmt.Errorf("the report and the client allow no root key in common")
+	}
+	if err := pkg.ValidateAgainstAllowList(query.RootKey, security.AllowedRegistryRoots); err != nil {
+		return "allowed_registry_roots", err
+	}
+	return "", nil
+}
+
+// blockedQuery completes the result of a query the security settings
+// blocked, and records the attempt as a policy_violation evidence record
+func (r *ReportRunner) blockedQuery(query pkg.RegistryQuery, result api.QueryResult, rule string, err error) (api.QueryResult, *api.EvidenceRecord) {
+	reason := err.Error()
+	var validationErr *pkg.ValidationError
+	if errors.As(err, &validationErr) {
+		reason = validationErr.Message
+	}
+
+	r.logger.Warn("Query blocked by security policy",
+		"query", query.Name,
+		"root_key", query.RootKey,
+		"path", query.Path,
+		"rule", rule,
+	)
+
+	result.Status = "error"
+	result.Actual = "blocked"
+	result.Message = fmt.Sprintf("Blocked by security.%s: %s", rule, reason)
+	result.ErrorClass = api.ErrorClassBlocked
+	r.audit.LogPolicyViolation(query.RootKey+`\`+query.Path, "security."+rule, reason)
+
+	return result, &api.EvidenceRecord{
+		QueryName: query.Name,
+		Timestamp: time.Now(),
+		Action:    "policy_violation",
+		Result:    "blocked",
+		Details: map[string]interface{}{
+			"root_key":   query.RootKey,
+			"path":       query.Path,
+			"value_name": query.ValueName,
+			"rule":       rule,
+			"error":      reason,
+		},
 	}
+}
 
-	data.OverallStatus = data.CalculateOverallStatus()
+// classifyReadError maps a registry read failure to one of the api.ErrorClass
+// values aggregated by the server
+func classifyReadError(err error) string {
+	switch {
+	case pkg.IsNotExist(err):
+		return api.ErrorClassNotFound
+	case errors.Is(err, syscall.ERROR_ACCESS_DENIED):
+		return api.ErrorClassAccessDenied
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return api.ErrorClassTimeout
+	default:
+		return api.ErrorClassReadFailed
+	}
+}
 
-	return data
+// collectTelemetry builds the agent telemetry block for a completed scan.
+// Cache backlog and retry count are filled in later by the submitter.
+func collectTelemetry(scanDuration time.Duration, checkDurations []float64) *api.AgentTelemetry {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	build := buildinfo.Get(version)
+
+	telemetry := &api.AgentTelemetry{
+		AgentVersion:     version,
+		ScanDurationMs:   scanDuration.Milliseconds(),
+		CheckCount:       len(checkDurations),
+		CheckP95Ms:       api.Percentile(checkDurations, 95),
+		MemoryAllocBytes: mem.HeapAlloc,
+		MemorySysBytes:   mem.Sys,
+		Build:            &build,
+	}
+	for _, d := range checkDurations {
+		if d > telemetry.CheckMaxMs {
+			telemetry.CheckMaxMs = d
+		}
+	}
+
+	return telemetry
 }
 
 // collectSystemInfo collects system information
@@ -271,9 +652,30 @@ func (r *ReportRunner) collectSystemInfo() api.SystemInfo {
 		info.LastBootTime = lastBootTime
 	}
 
+	info.Fingerprint = r.getFingerprint(info.MacAddress)
+
 	return info
 }
 
+// getFingerprint derives the machine fingerprint the server uses to
+// recognize this machine after a hostname change or reinstall
+func (r *ReportRunner) getFingerprint(macAddress string) string {
+	ctx := context.Background()
+	components := fingerprint.Components{MacAddress: macAddress}
+
+	if guid, err := r.reader.ReadValue(ctx, registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Cryptography`, "MachineGuid"); err == nil {
+		components.MachineGUID = guid
+	}
+
+	if data, err := r.reader.ReadBinary(ctx, registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Services\mssmbios\Data`, "SMBiosData"); err == nil {
+		components.Manufacturer, components.BIOSSerial = fingerprint.ParseSMBIOS(data)
+	}
+
+	return fingerprint.Compute(components)
+}
+
 // getWindowsVersion attempts to get Windows version from registry
 func (r *ReportRunner) getWindowsVersion() string {
 	ctx := context.Background()
@@ -371,11 +773,12 @@ func (r *ReportRunner) getLastBootTime() string {
 	return ""
 }
 
-// saveHTMLReport generates and saves an HTML report locally
-func (r *ReportRunner) saveHTMLReport(reportConfig *pkg.RegistryConfig, results []api.QueryResult) error {
+// saveHTMLReport generates and saves an HTML report locally, and returns
+// the files written
+func (r *ReportRunner) saveHTMLReport(reportConfig *pkg.RegistryConfig, results []api.QueryResult) ([]string, error) {
 	// Ensure output directory exists
 	if err := os.MkdirAll(r.config.Reports.OutputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Create HTML report using existing pattern
@@ -411,59 +814,35 @@ func (r *ReportRunner) saveHTMLReport(reportConfig *pkg.RegistryConfig, results
 
 	// Generate HTML report
 	if err := htmlReport.Generate(); err != nil {
-		return fmt.Errorf("failed to generate HTML report: %w", err)
+		return nil, fmt.Errorf("failed to generate HTML report: %w", err)
 	}
 
 	r.logger.Info("HTML report saved", "path", htmlReport.OutputPath)
-	return nil
-}
-
-// compareValues performs smart comparison of registry values with expected values
-// Handles cases where expected value contains descriptions like "1 (Enabled)"
-func compareValues(actual, expected string) bool {
-	// Trim whitespace and normalize
-	actual = strings.TrimSpace(actual)
-	expected = strings.TrimSpace(expected)
-
-	// Case 1: Exact match (case-insensitive)
-	if strings.EqualFold(actual, expected) {
-		return true
-	}
-
-	// Case 2: Expected format is "value (description)"
-	// Extract value before opening parenthesis and compare
-	if idx := strings.Index(expected, "("); idx > 0 {
-		expectedValue := strings.TrimSpace(expected[:idx])
-		if strings.EqualFold(actual, expectedValue) {
-			return true
+	files := []string{htmlReport.OutputPath}
+
+	if len(r.config.Reports.ExportFormats) > 0 {
+		rows := make([]pkg.ExportRow, 0, len(results))
+		for _, result := range results {
+			rows = append(rows, pkg.ExportRow{
+				Name:        result.Name,
+				Description: result.Description,
+				RootKey:     result.RootKey,
+				Path:        result.Path,
+				ValueName:   result.ValueName,
+				Expected:    api.DescribeExpected(result.Expected, result.Operator),
+				Actual:      result.Actual,
+				Status:      strings.ToUpper(result.Status),
+				Message:     result.Message,
+			})
 		}
-	}
 
-	// Case 3: Actual format is "value (description)", expected is just value
-	// Extract value before opening parenthesis and compare
-	if idx := strings.Index(actual, "("); idx > 0 {
-		actualValue := strings.TrimSpace(actual[:idx])
-		if strings.EqualFold(actualValue, expected) {
-			return true
+		basePath := strings.TrimSuffix(htmlReport.OutputPath, filepath.Ext(htmlReport.OutputPath))
+		paths, err := pkg.ExportResults(basePath, reportConfig.Metadata.ReportTitle, r.config.Reports.ExportFormats, rows)
+		if err != nil {
+			return files, fmt.Errorf("failed to export results: %w", err)
 		}
+		r.logger.Info("Results exported", "paths", paths)
+		files = append(files, paths...)
 	}
-
-	// Case 4: Handle numeric comparisons (convert both to integers if possible)
-	// Registry often returns DWORD as integer, config might have "1" or "0"
-	actualInt, actualErr := strconv.ParseInt(actual, 10, 64)
-	expectedInt, expectedErr := strconv.ParseInt(expected, 10, 64)
-	if actualErr == nil && expectedErr == nil {
-		return actualInt == expectedInt
-	}
-
-	// Case 5: Expected has value before parenthesis, try numeric comparison
-	if idx := strings.Index(expected, "("); idx > 0 {
-		expectedValue := strings.TrimSpace(expected[:idx])
-		expectedInt, expectedErr := strconv.ParseInt(expectedValue, 10, 64)
-		if actualErr == nil && expectedErr == nil {
-			return actualInt == expectedInt
-		}
-	}
-
-	return false
+	return files, nil
 }
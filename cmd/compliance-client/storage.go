@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/objectstore"
+)
+
+// storageTimeout bounds the uploads and clean-up of one report
+const storageTimeout = 10 * time.Minute
+
+// storeReport copies the files saved for a report, and its submission as
+// evidence JSON, to the object store, then deletes this client's objects
+// past storage.retention. Failures are only logged: the report ran.
+func (r *ReportRunner) storeReport(reportName string, submission *api.ComplianceSubmission, files []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	dir := storagePrefix(r.config.Client.Hostname, reportName, submission.Timestamp)
+	for _, file := range files {
+		key := dir + filepath.Base(file)
+		if err := putFile(ctx, r.store, key, file); err != nil {
+			r.logger.Warn("Failed to store report", "path", file, "error", err)
+			continue
+		}
+		r.logger.Info("Report stored", "location", r.store.Location(key))
+	}
+
+	data, err := json.MarshalIndent(submission, "", "  ")
+	if err != nil {
+		r.logger.Warn("Failed to encode evidence for storage", "error", err)
+	} else {
+		key := dir + submission.SubmissionID + ".json"
+		if err := r.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+			r.logger.Warn("Failed to store evidence", "submission_id", submission.SubmissionID, "error", err)
+		} else {
+			r.logger.Info("Evidence stored", "location", r.store.Location(key))
+		}
+	}
+
+	if r.config.Storage.Retention > 0 {
+		prefix := storageHostPrefix(r.config.Client.Hostname)
+		deleted, err := objectstore.Expire(ctx, r.store, prefix, time.Now().Add(-r.config.Storage.Retention))
+		if err != nil {
+			r.logger.Warn("Failed to delete expired reports from storage", "error", err)
+		}
+		if deleted > 0 {
+			r.logger.Info("Deleted expired reports from storage", "count", deleted)
+		}
+	}
+}
+
+// putFile uploads a local file under key
+func putFile(ctx context.Context, store objectstore.Store, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return store.Put(ctx, key, f, info.Size(), contentType)
+}
+
+// storageHostPrefix returns the prefix of every object a host stores
+func storageHostPrefix(hostname string) string {
+	return storageKeyPart(strings.ToLower(hostname)) + "/"
+}
+
+// storagePrefix returns the prefix of the objects stored for one run of a
+// report: <host>/<report>/<year>/<month>/
+func storagePrefix(hostname, reportName string, timestamp time.Time) string {
+	report := strings.TrimSuffix(filepath.Base(reportName), filepath.Ext(reportName))
+	return storageHostPrefix(hostname) + path.Join(storageKeyPart(report), timestamp.UTC().Format("2006/01")) + "/"
+}
+
+// storageKeyPart makes s safe as one segment of an object key
+func storageKeyPart(s string) string {
+	s = strings.Map(func(c rune) rune {
+		if c == '/' || c == '\\' {
+			return '_'
+		}
+		return c
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
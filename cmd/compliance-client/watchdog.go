@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// errRunHung is returned for a report run the watchdog stopped
+var errRunHung = errors.New("report run exceeded schedule.max_run_duration")
+
+// watchdogGrace is how long a cancelled run gets to return before the
+// watchdog abandons it, e.g. when it is stuck in a registry call that does
+// not honor cancellation
+const watchdogGrace = 30 * time.Second
+
+// executeWatched runs a report under the watchdog: a run taking longer than
+// schedule.max_run_duration is cancelled, and abandoned if it does not
+// return within watchdogGrace. The failure is reported to the server with
+// a heartbeat and the caller carries on with its schedule, so a hung run
+// never needs a service restart.
+func (c *ComplianceClient) executeWatched(reportName string) error {
+	limit := c.config.Schedule.MaxRunDuration
+	if limit <= 0 {
+		return c.executeReport(context.Background(), reportName)
+	}
+	return c.watch(reportName, limit, watchdogGrace, c.executeReport)
+}
+
+// watch runs execute with a deadline of limit; see executeWatched
+func (c *ComplianceClient) watch(reportName string, limit, grace time.Duration, execute func(context.Context, string) error) error {
+	startedAt := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+
+	// Buffered, so an abandoned run can still finish and exit
+	done := make(chan error, 1)
+	go func() { done <- execute(ctx, reportName) }()
+
+	// A run that completes as the deadline passes still counts
+	select {
+	case err := <-done:
+		if err == nil || ctx.Err() == nil {
+			return err
+		}
+	case <-ctx.Done():
+		select {
+		case err := <-done:
+			if err == nil {
+				return nil
+			}
+		case <-time.After(grace):
+			c.recordRunFailure(reportName, startedAt, limit, true)
+			return fmt.Errorf("%w (%s); the run did not stop and was abandoned", errRunHung, limit)
+		}
+	}
+
+	c.recordRunFailure(reportName, startedAt, limit, false)
+	return fmt.Errorf("%w (%s)", errRunHung, limit)
+}
+
+// recordRunFailure logs a run the watchdog stopped and reports it to the
+// server with an immediate heartbeat. Failures stay queued until a
+// heartbeat delivers them.
+func (c *ComplianceClient) recordRunFailure(reportName string, startedAt time.Time, limit time.Duration, abandoned bool) {
+	c.logger.Error("Watchdog stopped report run",
+		"report", reportName,
+		"started_at", startedAt,
+		"max_run_duration", limit,
+		"abandoned", abandoned,
+	)
+	c.metrics.watchdogStops.Inc(reportName)
+
+	// The server knows reports by title, as scheduleInfo reports them
+	reportType := reportName
+	if reportConfig, err := c.runner.loadReportConfig(reportName); err == nil && reportConfig.Metadata.ReportTitle != "" {
+		reportType = reportConfig.Metadata.ReportTitle
+	}
+
+	c.runFailuresMu.Lock()
+	c.runFailures = append(c.runFailures, api.RunFailure{
+		ReportType:   reportType,
+		StartedAt:    startedAt,
+		LimitSeconds: limit.Seconds(),
+		Abandoned:    abandoned,
+	})
+	c.runFailuresMu.Unlock()
+
+	// In its own goroutine: the run may have been started by a command,
+	// which holds commandMu while a heartbeat's commands are executed
+	if c.api != nil {
+		go c.sendHeartbeat()
+	}
+}
+
+// pendingRunFailures returns the run failures not yet delivered
+func (c *ComplianceClient) pendingRunFailures() []api.RunFailure {
+	c.runFailuresMu.Lock()
+	defer c.runFailuresMu.Unlock()
+	if len(c.runFailures) == 0 {
+		return nil
+	}
+	return append([]api.RunFailure(nil), c.runFailures...)
+}
+
+// deliveredRunFailures drops failures a heartbeat delivered. Failures
+// recorded meanwhile stay queued; a failure delivered twice by concurrent
+// heartbeats raises one alert, as the server deduplicates them.
+func (c *ComplianceClient) deliveredRunFailures(sent []api.RunFailure) {
+	c.runFailuresMu.Lock()
+	defer c.runFailuresMu.Unlock()
+	c.runFailures = slices.DeleteFunc(c.runFailures, func(failure api.RunFailure) bool {
+		return slices.Contains(sent, failure)
+	})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Admin audit actions; the part before the dot is the target type
+const (
+	auditUserCreate         = "user.create"
+	auditUserDelete         = "user.delete"
+	auditUserImport         = "user.import"
+	auditUserPassword       = "user.password_change"
+	auditUserProfile        = "user.profile_change"
+	auditUserScope          = "user.scope_change"
+	auditUserTeam           = "user.team_change"
+	auditAPIKeyGenerate     = "api_key.generate"
+	auditAPIKeyRevoke       = "api_key.revoke"
+	auditAPIKeyToggle       = "api_key.toggle"
+	auditAPIKeyExpire       = "api_key.expire"
+	auditPolicyCreate       = "policy.create"
+	auditPolicyUpdate       = "policy.update"
+	auditPolicyDelete       = "policy.delete"
+	auditPolicyOwner        = "policy.owner_change"
+	auditPolicyImport       = "policy.import"
+	auditPolicyPackImport   = "policy.pack_import"
+	auditPolicyRollout      = "policy.rollout"
+	auditPolicyRolloutHalt  = "policy.rollout_halt"
+	auditPolicyPromote      = "policy.rollout_promote"
+	auditPolicyRolloutEnd   = "policy.rollout_cancel"
+	auditClientClearHistory = "client.clear_history"
+	auditClientClearAll     = "client.clear_all_history"
+	auditClientMerge        = "client.merge"
+	auditClientTags         = "client.tags_change"
+	auditClientPolicies     = "client.policies_change"
+	auditGroupPolicies      = "group.policies_change"
+	auditAlertRuleSave      = "alert_rule.save"
+	auditAlertRuleDelete    = "alert_rule.delete"
+	auditTemplateSave       = "notification_template.save"
+	auditTemplateDelete     = "notification_template.delete"
+	auditExportAnonymized   = "export.anonymized"
+	auditAttachmentDelete   = "attachment.delete"
+	auditDataSubjectPurge   = "data_subject.purge"
+	auditSettingsUpdate     = "settings.update"
+	auditLoginMessage       = "settings.login_message"
+	auditMaintenanceMode    = "settings.maintenance_mode"
+	auditWindowCreate       = "maintenance_window.create"
+	auditWindowUpdate       = "maintenance_window.update"
+	auditWindowDelete       = "maintenance_window.delete"
+)
+
+// adminChange is what an audited request changed. Handlers describe it with
+// noteAdminChange; auditAdmin records it once the handler has succeeded.
+type adminChange struct {
+	target        string
+	before, after interface{}
+}
+
+// adminChangeContextKey keys the adminChange of an audited request
+type adminChangeContextKey struct{}
+
+// auditAdmin middleware records the request as action in the admin audit
+// trail when the handler answers with a success status. It runs after
+// authentication so the actor is known.
+func (s *ComplianceServer) auditAdmin(action string) middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			change := &adminChange{}
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next(wrapped, r.WithContext(context.WithValue(r.Context(), adminChangeContextKey{}, change)))
+
+			if wrapped.statusCode >= http.StatusBadRequest {
+				return
+			}
+			s.recordAdminChange(r, action, change)
+		}
+	}
+}
+
+// noteAdminChange describes the change an audited request made: its target
+// and the object before and after. Requests without auditAdmin ignore it.
+func noteAdminChange(r *http.Request, target string, before, after interface{}) {
+	if change, ok := r.Context().Value(adminChangeContextKey{}).(*adminChange); ok {
+		change.target, change.before, change.after = target, before, after
+	}
+}
+
+// auditActor names who made r: the logged-in user, else the API key
+func (s *ComplianceServer) auditActor(r *http.Request) string {
+	if user := requestUser(r); user != nil {
+		return user.Username
+	}
+	if config := s.config(); !config.Auth.Enabled || !config.Auth.RequireKey {
+		return "anonymous"
+	}
+	return "api-key"
+}
+
+// recordAdminChange writes an admin audit entry. Failures are logged rather
+// than returned: the change has already been made.
+func (s *ComplianceServer) recordAdminChange(r *http.Request, action string, change *adminChange) {
+	targetType, _, _ := strings.Cut(action, ".")
+	entry := api.AdminAuditEntry{
+		Actor:      s.auditActor(r),
+		RemoteAddr: s.remoteIP(r),
+		Action:     action,
+		TargetType: targetType,
+		Target:     change.target,
+	}
+
+	var err error
+	if entry.Before, err = auditValue(change.before); err == nil {
+		entry.After, err = auditValue(change.after)
+	}
+	if err == nil {
+		err = s.addAdminAudit(entry)
+	}
+	if err != nil {
+		s.logger.Error("Failed to record admin audit", "error", err, "action", action, "target", change.target)
+	}
+}
+
+// addAdminAudit writes an admin audit entry and forwards it to the SIEM
+func (s *ComplianceServer) addAdminAudit(entry api.AdminAuditEntry) error {
+	if err := s.db.AddAdminAudit(entry); err != nil {
+		return err
+	}
+	s.forwarder.forward(auditEvent(entry))
+	return nil
+}
+
+// auditValue marshals a before or after value; nil stays empty
+func auditValue(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit value: %w", err)
+	}
+	return data, nil
+}
+
+// adminAuditFilter selects admin audit entries; empty fields match all
+type adminAuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	Target     string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// parseAdminAuditFilter reads the filter of GET /api/v1/audit
+func parseAdminAuditFilter(r *http.Request) (adminAuditFilter, error) {
+	query := r.URL.Query()
+	filter := adminAuditFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		Target:     query.Get("target"),
+		Limit:      100,
+	}
+
+	bounds := []struct {
+		name string
+		t    *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}}
+	for _, bound := range bounds {
+		if v := query.Get(bound.name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time such as 2026-10-01T00:00:00Z", bound.name)
+			}
+			*bound.t = parsed.UTC()
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			return filter, fmt.Errorf("limit must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// handleListAdminAudit returns the admin audit trail, newest first
+// (GET /api/v1/audit). actor, action, target_type and target match exactly;
+// since and until bound the time.
+func (s *ComplianceServer) handleListAdminAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAdminAuditFilter(r)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := s.requestDB(r).ListAdminAudit(filter)
+	if err != nil {
+		s.logger.Error("Failed to list admin audit", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list audit trail")
+		return
+	}
+
+	s.respond(w, r, api.AdminAuditListResponse{Entries: entries, Count: len(entries)}, adminAuditTable(entries))
+}
+
+// AddAdminAudit appends an entry to the admin audit trail
+func (d *Database) AddAdminAudit(entry api.AdminAuditEntry) error {
+	const query = `
+		INSERT INTO admin_audit_log (actor, remote_addr, action, target_type, target, before_value, after_value, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+	`
+
+	_, err := d.db.Exec(safesql.New(query, entry.Actor, entry.RemoteAddr, entry.Action, entry.TargetType, entry.Target, nullableJSON(entry.Before), nullableJSON(entry.After)))
+	if err != nil {
+		return fmt.Errorf("failed to record admin audit: %w", err)
+	}
+	return nil
+}
+
+// ListAdminAudit returns the admin audit entries matching filter, newest first
+func (d *Database) ListAdminAudit(filter adminAuditFilter) ([]api.AdminAuditEntry, error) {
+	conditions := []safesql.Query{safesql.New("TRUE")}
+	if filter.Actor != "" {
+		conditions = append(conditions, safesql.New("actor = $1", filter.Actor))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, safesql.New("action = $1", filter.Action))
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, safesql.New("target_type = $1", filter.TargetType))
+	}
+	if filter.Target != "" {
+		conditions = append(conditions, safesql.New("target = $1", filter.Target))
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, safesql.New("timestamp >= $1", filter.Since.Format(time.RFC3339)))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, safesql.New("timestamp < $1", filter.Until.Format(time.RFC3339)))
+	}
+
+	query := safesql.New(`
+		SELECT id, timestamp, actor, remote_addr, action, target_type, target, before_value, after_value
+		FROM admin_audit_log
+		WHERE `).
+		AppendQuery(safesql.Join(conditions, " AND ")).
+		Append(`
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $1
+	`, filter.Limit)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query admin audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []api.AdminAuditEntry{}
+	for rows.Next() {
+		var entry api.AdminAuditEntry
+		var remoteAddr, target, before, after sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Actor, &remoteAddr, &entry.Action,
+			&entry.TargetType, &target, &before, &after); err != nil {
+			return nil, fmt.Errorf("failed to scan admin audit: %w", err)
+		}
+		entry.RemoteAddr = remoteAddr.String
+		entry.Target = target.String
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullableJSON converts an optional JSON value to a value for a TEXT column
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"compliancetoolkit/pkg/api"
+)
+
+// raiseAlert stores an alert unless the client is in a maintenance window at
+// the time the alert refers to, or an alert with the same dedupe key exists.
+// It reports whether a new alert was stored; new alerts are forwarded to the
+// SIEM.
+func (s *ComplianceServer) raiseAlert(alert *api.Alert, dedupeKey string) (bool, error) {
+	if alert.ClientID != "" {
+		at := alert.Timestamp
+		if alert.ExpectedAt != nil {
+			at = *alert.ExpectedAt
+		}
+		if window := s.activeMaintenanceWindow(alert.ClientID, alert.Hostname, at); window != nil {
+			s.logger.Debug("Alert suppressed by maintenance window",
+				"type", alert.Type,
+				"client_id", alert.ClientID,
+				"window", window.Name,
+			)
+			return false, nil
+		}
+	}
+
+	created, err := s.db.CreateAlert(alert, dedupeKey)
+	if created {
+		s.forwarder.forward(alertEvent(alert))
+	}
+	return created, err
+}
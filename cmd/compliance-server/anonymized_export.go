@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"compliancetoolkit/pkg/anonymize"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// maxAnonymizedExport is the most submissions one anonymized export holds
+const maxAnonymizedExport = 5000
+
+// handleAnonymizedExport returns the submissions of a period with host
+// names, user names, IP and MAC addresses and domain names replaced by
+// pseudonyms (GET /api/v1/export/anonymized), so they can be shared with
+// consultants or vendors. A host or user gets the same pseudonym throughout
+// the export, and across exports with export.anonymization_key set.
+// Optional parameters: report_type and days (default 30).
+func (s *ComplianceServer) handleAnonymizedExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			s.sendError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Second)
+	submissions, err := s.scopedDB(r).ExportSubmissions(since, query.Get("report_type"), maxAnonymizedExport+1)
+	if err != nil {
+		s.logger.Error("Failed to load submissions for export", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submissions")
+		return
+	}
+
+	export := api.AnonymizedExport{
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Since:       since,
+		Submissions: []api.ComplianceSubmission{},
+	}
+	if len(submissions) > maxAnonymizedExport {
+		submissions = submissions[:maxAnonymizedExport]
+		export.Truncated = true
+	}
+
+	export.Submissions, err = anonymizeSubmissions(submissions, []byte(s.config().Export.AnonymizationKey))
+	if err != nil {
+		s.logger.Error("Failed to anonymize submissions", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to anonymize submissions")
+		return
+	}
+
+	noteAdminChange(r, fmt.Sprintf("%d submissions since %s", len(export.Submissions), since.Format(time.RFC3339)), nil, nil)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="anonymized-export-%s.json"`, export.GeneratedAt.Format("20060102")))
+	s.respond(w, r, export, nil)
+}
+
+// anonymizeSubmissions replaces the identifying values of submissions with
+// pseudonyms derived from key (a random key when empty). Every submission is
+// read before any is scrubbed, so a host name learned from one is also
+// replaced in the free text of the others.
+func anonymizeSubmissions(submissions []*api.ComplianceSubmission, key []byte) ([]api.ComplianceSubmission, error) {
+	scrubber := anonymize.New(key)
+	documents := make([][]byte, len(submissions))
+	for i, submission := range submissions {
+		data, err := json.Marshal(submission)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal submission %s: %w", submission.SubmissionID, err)
+		}
+		if err := scrubber.Learn(data); err != nil {
+			return nil, err
+		}
+		documents[i] = data
+	}
+
+	anonymized := make([]api.ComplianceSubmission, len(documents))
+	for i, data := range documents {
+		scrubbed, err := scrubber.JSON(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(scrubbed, &anonymized[i]); err != nil {
+			return nil, fmt.Errorf("failed to read anonymized submission: %w", err)
+		}
+	}
+	return anonymized, nil
+}
+
+// ExportSubmissions returns up to limit submissions made at or after since,
+// oldest first, with their evidence and system information, optionally
+// limited to one report type
+func (d *Database) ExportSubmissions(since time.Time, reportType string, limit int) ([]*api.ComplianceSubmission, error) {
+	query := safesql.New(`
+		SELECT submission_id, client_id, hostname, timestamp, report_type, report_version,
+		       compliance_data, evidence, system_info, during_maintenance, summary_only, metadata
+		FROM submissions
+		WHERE `).
+		AppendQuery(d.scopeCondition(safesql.New("client_id"))).
+		Append(` AND timestamp >= $1`, since.UTC().Format(time.RFC3339))
+	if reportType != "" {
+		query = query.Append(" AND report_type = $1", reportType)
+	}
+	query = query.Append(" ORDER BY timestamp, submission_id LIMIT $1", limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submissions: %w", err)
+	}
+	defer rows.Close()
+
+	var submissions []*api.ComplianceSubmission
+	for rows.Next() {
+		var submission api.ComplianceSubmission
+		var timestampStr, complianceData, evidence, systemInfo string
+		var reportVersion, metadata sql.NullString
+		if err := rows.Scan(&submission.SubmissionID, &submission.ClientID, &submission.Hostname, &timestampStr,
+			&submission.ReportType, &reportVersion, &complianceData, &evidence, &systemInfo,
+			&submission.DuringMaintenance, &submission.SummaryOnly, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		if submission.Timestamp, err = parseStoredTime(timestampStr); err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		submission.ReportVersion = reportVersion.String
+
+		if err := json.Unmarshal([]byte(complianceData), &submission.Compliance); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal compliance data of %s: %w", submission.SubmissionID, err)
+		}
+		if err := json.Unmarshal([]byte(evidence), &submission.Evidence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evidence of %s: %w", submission.SubmissionID, err)
+		}
+		if err := json.Unmarshal([]byte(systemInfo), &submission.SystemInfo); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal system info of %s: %w", submission.SubmissionID, err)
+		}
+		if submission.Metadata, err = decodeMetadata(metadata); err != nil {
+			return nil, fmt.Errorf("submission %s: %w", submission.SubmissionID, err)
+		}
+		submissions = append(submissions, &submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read submissions: %w", err)
+	}
+
+	return submissions, nil
+}
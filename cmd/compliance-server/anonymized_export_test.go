@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestAnonymizeSubmissions tests that identifying values are replaced
+// consistently across the submissions of an export, and that results are
+// kept
+func TestAnonymizeSubmissions(t *testing.T) {
+	at := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	submissions := []*api.ComplianceSubmission{
+		{
+			SubmissionID: "s1", ClientID: "client-ws-0042", Hostname: "WS-0042", Timestamp: at, ReportType: "Baseline",
+			Compliance: api.ComplianceData{OverallStatus: "non-compliant", Queries: []api.QueryResult{
+				{Name: "Admin Shares", Status: "fail", Message: `\\FIN-LAPTOP-7\c$ reachable from ws-0042`},
+			}},
+			SystemInfo: api.SystemInfo{OSVersion: "10.0.19045", Domain: "corp.contoso.com", IPAddress: "10.20.30.40", MacAddress: "00:1A:2B:3C:4D:5E"},
+		},
+		{
+			SubmissionID: "s2", ClientID: "client-fin-laptop-7", Hostname: "FIN-LAPTOP-7", Timestamp: at.Add(time.Hour), ReportType: "Baseline",
+			Compliance: api.ComplianceData{OverallStatus: "compliant"},
+		},
+	}
+
+	anonymized, err := anonymizeSubmissions(submissions, []byte("export key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(anonymized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"WS-0042", "ws-0042", "FIN-LAPTOP-7", "contoso", "10.20.30.40", "1A:2B"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("%q left in %s", leaked, data)
+		}
+	}
+
+	first := anonymized[0]
+	if first.SubmissionID != "s1" || !first.Timestamp.Equal(at) || first.Compliance.Queries[0].Status != "fail" ||
+		first.SystemInfo.OSVersion != "10.0.19045" || first.Compliance.OverallStatus != "non-compliant" {
+		t.Errorf("results changed: %+v", first)
+	}
+	if !strings.Contains(first.Compliance.Queries[0].Message, anonymized[1].Hostname) {
+		t.Errorf("host learned from the second submission not replaced consistently: %q, %q",
+			first.Compliance.Queries[0].Message, anonymized[1].Hostname)
+	}
+
+	again, err := anonymizeSubmissions(submissions[:1], []byte("export key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0].Hostname != first.Hostname || again[0].ClientID != first.ClientID {
+		t.Errorf("pseudonyms differ between exports with the same key: %q, %q", again[0].Hostname, first.Hostname)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Keys with an expiry date are checked hourly. Their creator is emailed once
+// auth.api_key_expiry_warning before the key expires (if they have an email
+// address and want the warning), and the key is deactivated once it has
+// expired, which the admin audit trail records as api_key.expire.
+
+// apiKeyExpiryInterval is how often keys are checked
+const apiKeyExpiryInterval = time.Hour
+
+// expiringAPIKey is a key to warn its creator about
+type expiringAPIKey struct {
+	ID        int
+	Name      string
+	KeyPrefix string
+	CreatedBy string
+	ExpiresAt time.Time
+}
+
+// apiKeyExpiresSoon reports whether an active key expiring at expiresAt (a
+// stored timestamp, empty for never) expires within warning of now
+func apiKeyExpiresSoon(expiresAt string, warning time.Duration, now time.Time) bool {
+	if expiresAt == "" || warning <= 0 {
+		return false
+	}
+	t := parseDBTime(expiresAt)
+	return !t.IsZero() && t.After(now) && !t.After(now.Add(warning))
+}
+
+// startAPIKeyExpiry checks API key expiry now and then hourly
+func (s *ComplianceServer) startAPIKeyExpiry() {
+	go func() {
+		ticker := time.NewTicker(apiKeyExpiryInterval)
+		defer ticker.Stop()
+
+		for {
+			s.checkAPIKeyExpiry()
+			<-ticker.C
+		}
+	}()
+}
+
+// checkAPIKeyExpiry deactivates expired keys and warns the creators of keys
+// about to expire
+func (s *ComplianceServer) checkAPIKeyExpiry() {
+	expired, err := s.db.DeactivateExpiredAPIKeys()
+	if err != nil {
+		s.logger.Error("Failed to deactivate expired API keys", "error", err)
+	}
+	for _, key := range expired {
+		s.logger.Info("Expired API key deactivated", "id", key.ID, "name", key.Name, "created_by", key.CreatedBy)
+		before := key.Info()
+		before.IsActive = true
+		entry := api.AdminAuditEntry{
+			Actor:      "system",
+			Action:     auditAPIKeyExpire,
+			TargetType: "api_key",
+			Target:     key.Name,
+		}
+		if entry.Before, err = auditValue(before); err == nil {
+			entry.After, err = auditValue(key.Info())
+		}
+		if err == nil {
+			err = s.addAdminAudit(entry)
+		}
+		if err != nil {
+			s.logger.Error("Failed to record admin audit", "error", err, "action", auditAPIKeyExpire, "target", key.Name)
+		}
+	}
+
+	warning := s.config().Auth.APIKeyExpiryWarning
+	if s.mailer == nil || warning <= 0 {
+		return
+	}
+	keys, err := s.db.ListExpiringAPIKeys(warning)
+	if err != nil {
+		s.logger.Error("Failed to list expiring API keys", "error", err)
+		return
+	}
+	for _, key := range keys {
+		s.warnAPIKeyExpiry(key)
+	}
+}
+
+// warnAPIKeyExpiry emails the creator of a key about to expire. The key is
+// claimed first so that with several replicas only one sends the warning;
+// the claim is released when sending fails so the next check retries.
+func (s *ComplianceServer) warnAPIKeyExpiry(key expiringAPIKey) {
+	user, err := s.db.GetUser(key.CreatedBy)
+	if err != nil {
+		if err.Error() != "user not found" {
+			s.logger.Error("Failed to get user", "username", key.CreatedBy, "error", err)
+		}
+		return
+	}
+	if user.Email == "" || !user.Notifications.APIKeyExpiry {
+		return
+	}
+
+	claimed, err := s.db.SetAPIKeyExpiryNotified(key.ID, true)
+	if err != nil || !claimed {
+		if err != nil {
+			s.logger.Error("Failed to claim API key expiry warning", "id", key.ID, "error", err)
+		}
+		return
+	}
+
+	subject, body, err := s.notificationEmail(notificationAPIKeyExpiry, notificationOrg(user), apiKeyExpiryEmailData(key, s.dashboardURL("/settings")))
+	if err == nil {
+		err = s.mailer.send(user.Email, subject, body)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to email API key expiry warning", "id", key.ID, "username", user.Username, "error", err)
+		if _, err := s.db.SetAPIKeyExpiryNotified(key.ID, false); err != nil {
+			s.logger.Error("Failed to release API key expiry warning", "id", key.ID, "error", err)
+		}
+		return
+	}
+	s.logger.Info("API key expiry warning sent", "id", key.ID, "name", key.Name, "username", user.Username)
+}
+
+// apiKeyExpiryEmailData returns the fields of the warning that key is about
+// to expire
+func apiKeyExpiryEmailData(key expiringAPIKey, settingsURL string) map[string]string {
+	return map[string]string{
+		"KeyName":     key.Name,
+		"KeyPrefix":   key.KeyPrefix,
+		"ExpiresOn":   key.ExpiresAt.UTC().Format("2 Jan 2006"),
+		"ExpiresAt":   key.ExpiresAt.UTC().Format("2 Jan 2006 15:04 MST"),
+		"SettingsURL": settingsURL,
+	}
+}
+
+// DeactivateExpiredAPIKeys deactivates active keys whose expiry has passed
+// and returns them
+func (d *Database) DeactivateExpiredAPIKeys() ([]APIKey, error) {
+	rows, err := d.db.Query(safesql.New(`
+		UPDATE api_keys SET is_active = false
+		WHERE is_active = true AND expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP
+		RETURNING id, name, key_prefix, created_by, created_at, last_used, expires_at
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate expired API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var key APIKey
+		var lastUsed sql.NullString
+		if err := rows.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.CreatedBy, &key.CreatedAt, &lastUsed, &key.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		key.LastUsed = lastUsed.String
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ListExpiringAPIKeys returns the active keys expiring within warning whose
+// creator has not been warned yet
+func (d *Database) ListExpiringAPIKeys(warning time.Duration) ([]expiringAPIKey, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT id, name, key_prefix, created_by, expires_at
+		FROM api_keys
+		WHERE is_active = true AND expiry_notified_at IS NULL
+		  AND expires_at > CURRENT_TIMESTAMP
+		  AND expires_at <= CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+		ORDER BY expires_at
+	`, int64(warning/time.Second)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []expiringAPIKey
+	for rows.Next() {
+		var key expiringAPIKey
+		var expiresAt string
+		if err := rows.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.CreatedBy, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		key.ExpiresAt = parseDBTime(expiresAt)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SetAPIKeyExpiryNotified marks a key's creator as warned of its expiry, or
+// clears the mark. It reports whether the mark changed, so that of several
+// replicas checking the same key only one claims it.
+func (d *Database) SetAPIKeyExpiryNotified(id int, notified bool) (bool, error) {
+	query := safesql.New(`UPDATE api_keys SET expiry_notified_at = CURRENT_TIMESTAMP WHERE id = $1 AND expiry_notified_at IS NULL`, id)
+	if !notified {
+		query = safesql.New(`UPDATE api_keys SET expiry_notified_at = NULL WHERE id = $1 AND expiry_notified_at IS NOT NULL`, id)
+	}
+
+	result, err := d.db.Exec(query)
+	if err != nil {
+		return false, fmt.Errorf("failed to update API key expiry warning: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
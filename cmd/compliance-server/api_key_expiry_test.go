@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestAPIKeyExpiresSoon tests which expiry dates the settings page flags
+func TestAPIKeyExpiresSoon(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		name      string
+		expiresAt string
+		warning   time.Duration
+		want      bool
+	}{
+		{"never expires", "", week, false},
+		{"within warning", "2026-10-20T12:00:00Z", week, true},
+		{"at warning boundary", "2026-10-22T12:00:00Z", week, true},
+		{"beyond warning", "2026-10-23T12:00:00Z", week, false},
+		{"already expired", "2026-10-14T12:00:00Z", week, false},
+		{"warnings disabled", "2026-10-16T12:00:00Z", 0, false},
+		{"unparseable", "soon", week, false},
+	}
+	for _, tt := range tests {
+		if got := apiKeyExpiresSoon(tt.expiresAt, tt.warning, now); got != tt.want {
+			t.Errorf("%s: apiKeyExpiresSoon(%q) = %v, want %v", tt.name, tt.expiresAt, got, tt.want)
+		}
+	}
+}
+
+// TestAPIKeyExpiryEmail tests that the warning names the key, its expiry
+// and where to replace it, and never more of the key than its prefix
+func TestAPIKeyExpiryEmail(t *testing.T) {
+	key := expiringAPIKey{
+		ID:        4,
+		Name:      "build-agents",
+		KeyPrefix: "ctk_ab12",
+		CreatedBy: "alice",
+		ExpiresAt: time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+	}
+	builtin := builtinNotifications[notificationAPIKeyExpiry]
+	rendered, err := renderNotification(api.NotificationTemplate{Subject: builtin.subject, Body: builtin.body},
+		apiKeyExpiryEmailData(key, "https://compliance.example.com/settings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, body := rendered.Subject, rendered.Body
+
+	if subject != `API key "build-agents" expires on 20 Oct 2026` {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{`"build-agents"`, "ctk_ab12", "20 Oct 2026 09:30 UTC", "https://compliance.example.com/settings"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+}
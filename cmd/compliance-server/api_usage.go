@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Requests authenticated with a database API key or as a user are counted
+// in memory per endpoint and UTC day, and the counts added to api_usage
+// (migration 0016) every minute. Keys from the config file have no ID to
+// count against and are not tracked.
+
+const (
+	// apiUsageFlushInterval is how often counted requests are written
+	apiUsageFlushInterval = time.Minute
+
+	// apiUsageRetentionDays is how many days of usage are kept, and the
+	// longest period the usage endpoints report
+	apiUsageRetentionDays = 90
+
+	// defaultAPIUsageDays is the period the usage endpoints report by default
+	defaultAPIUsageDays = 30
+)
+
+// usageKey identifies a counter: the API key (by hash) or user making the
+// requests, the day and the endpoint's route pattern
+type usageKey struct {
+	keyHash  string
+	userID   int
+	day      string // YYYY-MM-DD, UTC
+	endpoint string
+}
+
+// usageCount is what was counted for a usageKey since the last flush
+type usageCount struct {
+	requests int64
+	errors   int64
+	lastUsed time.Time
+}
+
+// usageRecorder counts requests until they are flushed. A nil recorder
+// counts nothing.
+type usageRecorder struct {
+	mu     sync.Mutex
+	counts map[usageKey]*usageCount
+}
+
+// newUsageRecorder returns an empty recorder
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{counts: make(map[usageKey]*usageCount)}
+}
+
+// record counts a request made at now that was answered with status
+func (u *usageRecorder) record(keyHash string, userID int, endpoint string, status int, now time.Time) {
+	if u == nil {
+		return
+	}
+	now = now.UTC()
+	key := usageKey{keyHash: keyHash, userID: userID, day: now.Format(time.DateOnly), endpoint: endpoint}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	c, ok := u.counts[key]
+	if !ok {
+		c = &usageCount{}
+		u.counts[key] = c
+	}
+	c.requests++
+	if status >= http.StatusBadRequest {
+		c.errors++
+	}
+	if now.After(c.lastUsed) {
+		c.lastUsed = now
+	}
+}
+
+// take returns the counts so far and starts counting afresh
+func (u *usageRecorder) take() map[usageKey]*usageCount {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	counts := u.counts
+	u.counts = make(map[usageKey]*usageCount)
+	return counts
+}
+
+// restore adds counts that could not be written back, to be written with
+// the next flush
+func (u *usageRecorder) restore(counts map[usageKey]*usageCount) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for key, c := range counts {
+		existing, ok := u.counts[key]
+		if !ok {
+			u.counts[key] = c
+			continue
+		}
+		existing.requests += c.requests
+		existing.errors += c.errors
+		if c.lastUsed.After(existing.lastUsed) {
+			existing.lastUsed = c.lastUsed
+		}
+	}
+}
+
+// trackUsage runs next and counts the request toward the API key stored as
+// keyHash or the user with userID
+func (s *ComplianceServer) trackUsage(next http.HandlerFunc, w http.ResponseWriter, r *http.Request, keyHash string, userID int) {
+	if s.usage == nil {
+		next(w, r)
+		return
+	}
+	wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	next(wrapped, r)
+	s.usage.record(keyHash, userID, r.Pattern, wrapped.statusCode, time.Now())
+}
+
+// startAPIUsage writes counted requests every minute and removes usage
+// older than apiUsageRetentionDays daily
+func (s *ComplianceServer) startAPIUsage() {
+	go func() {
+		flush := time.NewTicker(apiUsageFlushInterval)
+		defer flush.Stop()
+		prune := time.NewTicker(24 * time.Hour)
+		defer prune.Stop()
+
+		for {
+			select {
+			case <-flush.C:
+				s.flushAPIUsage()
+			case <-prune.C:
+				cutoff := time.Now().UTC().AddDate(0, 0, -apiUsageRetentionDays)
+				if err := s.db.PruneAPIUsage(cutoff); err != nil {
+					s.logger.Error("Failed to prune API usage", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// flushAPIUsage writes the requests counted since the last flush
+func (s *ComplianceServer) flushAPIUsage() {
+	if s.usage == nil {
+		return
+	}
+	counts := s.usage.take()
+	if len(counts) == 0 {
+		return
+	}
+	if err := s.db.AddAPIUsage(counts); err != nil {
+		s.logger.Error("Failed to record API usage", "error", err)
+		s.usage.restore(counts)
+	}
+}
+
+// handleAPIKeyUsage reports the requests made with an API key
+// (GET /api/v1/apikeys/{id}/usage?days=)
+func (s *ComplianceServer) handleAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+	days, ok := s.usageDays(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := s.requestDB(r).GetAPIKey(id); err != nil {
+		if err.Error() == "API key not found" {
+			s.sendError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		s.logger.Error("Failed to get API key", "id", id, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get API key usage")
+		return
+	}
+
+	rows, err := s.requestDB(r).GetAPIUsage("api_key_id", id, days, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to get API key usage", "id", id, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get API key usage")
+		return
+	}
+
+	response := summarizeAPIUsage(rows, days)
+	response.APIKeyID = id
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUserUsage reports the requests a user made
+// (GET /api/v1/users/{username}/usage?days=)
+func (s *ComplianceServer) handleUserUsage(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	days, ok := s.usageDays(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.requestDB(r).GetUser(username)
+	if err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to get user", "username", username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get user usage")
+		return
+	}
+
+	rows, err := s.requestDB(r).GetAPIUsage("user_id", user.ID, days, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to get user usage", "username", username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get user usage")
+		return
+	}
+
+	response := summarizeAPIUsage(rows, days)
+	response.Username = user.Username
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// usageDays reads the days query parameter, answering 400 when invalid
+func (s *ComplianceServer) usageDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return defaultAPIUsageDays, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > apiUsageRetentionDays {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", apiUsageRetentionDays))
+		return 0, false
+	}
+	return days, true
+}
+
+// apiUsageRow is one stored day's usage of an endpoint
+type apiUsageRow struct {
+	Day      string
+	Endpoint string
+	Requests int64
+	Errors   int64
+	LastUsed time.Time
+}
+
+// summarizeAPIUsage totals usage rows overall, per endpoint (most recently
+// used first) and per day (oldest first)
+func summarizeAPIUsage(rows []apiUsageRow, days int) api.APIUsageResponse {
+	response := api.APIUsageResponse{
+		Days:      days,
+		Endpoints: []api.APIUsageEndpoint{},
+		Daily:     []api.APIUsageDay{},
+	}
+	endpoints := make(map[string]*api.APIUsageEndpoint)
+	daily := make(map[string]*api.APIUsageDay)
+
+	for _, row := range rows {
+		response.Requests += row.Requests
+		response.Errors += row.Errors
+		if response.LastUsed == nil || row.LastUsed.After(*response.LastUsed) {
+			lastUsed := row.LastUsed
+			response.LastUsed = &lastUsed
+		}
+
+		e, ok := endpoints[row.Endpoint]
+		if !ok {
+			e = &api.APIUsageEndpoint{Endpoint: row.Endpoint}
+			endpoints[row.Endpoint] = e
+		}
+		e.Requests += row.Requests
+		e.Errors += row.Errors
+		if row.LastUsed.After(e.LastUsed) {
+			e.LastUsed = row.LastUsed
+		}
+
+		d, ok := daily[row.Day]
+		if !ok {
+			d = &api.APIUsageDay{Date: row.Day}
+			daily[row.Day] = d
+		}
+		d.Requests += row.Requests
+		d.Errors += row.Errors
+	}
+	response.ErrorRate = errorRate(response.Errors, response.Requests)
+
+	for _, e := range endpoints {
+		e.ErrorRate = errorRate(e.Errors, e.Requests)
+		response.Endpoints = append(response.Endpoints, *e)
+	}
+	sort.Slice(response.Endpoints, func(i, j int) bool {
+		a, b := response.Endpoints[i], response.Endpoints[j]
+		if !a.LastUsed.Equal(b.LastUsed) {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		return a.Endpoint < b.Endpoint
+	})
+
+	for _, d := range daily {
+		response.Daily = append(response.Daily, *d)
+	}
+	sort.Slice(response.Daily, func(i, j int) bool {
+		return response.Daily[i].Date < response.Daily[j].Date
+	})
+	return response
+}
+
+// errorRate returns errors per request, 0 without requests
+func errorRate(errors, requests int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// AddAPIUsage adds counted requests to the stored usage. Counts for API keys
+// and users deleted since are dropped.
+func (d *Database) AddAPIUsage(counts map[usageKey]*usageCount) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const keyQuery = `
+		INSERT INTO api_usage (api_key_id, day, endpoint, requests, errors, last_used)
+		SELECT id, $1, $2, $3, $4, $5 FROM api_keys WHERE key_hash = $6
+		ON CONFLICT (api_key_id, day, endpoint) WHERE api_key_id IS NOT NULL DO UPDATE
+		SET requests = api_usage.requests + EXCLUDED.requests,
+		    errors = api_usage.errors + EXCLUDED.errors,
+		    last_used = GREATEST(api_usage.last_used, EXCLUDED.last_used)
+	`
+	const userQuery = `
+		INSERT INTO api_usage (user_id, day, endpoint, requests, errors, last_used)
+		SELECT id, $1, $2, $3, $4, $5 FROM users WHERE id = $6
+		ON CONFLICT (user_id, day, endpoint) WHERE user_id IS NOT NULL DO UPDATE
+		SET requests = api_usage.requests + EXCLUDED.requests,
+		    errors = api_usage.errors + EXCLUDED.errors,
+		    last_used = GREATEST(api_usage.last_used, EXCLUDED.last_used)
+	`
+
+	for key, c := range counts {
+		lastUsed := c.lastUsed.UTC().Format(time.RFC3339)
+		query := safesql.New(keyQuery, key.day, key.endpoint, c.requests, c.errors, lastUsed, key.keyHash)
+		if key.keyHash == "" {
+			query = safesql.New(userQuery, key.day, key.endpoint, c.requests, c.errors, lastUsed, key.userID)
+		}
+		_, err := tx.Exec(query)
+		if err != nil {
+			return fmt.Errorf("failed to add API usage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit API usage: %w", err)
+	}
+	return nil
+}
+
+// GetAPIUsage returns the usage stored for the API key or user whose ID is
+// in column (api_key_id or user_id) over the days days up to now
+func (d *Database) GetAPIUsage(column string, id, days int, now time.Time) ([]apiUsageRow, error) {
+	var owner safesql.Query
+	switch column {
+	case "api_key_id":
+		owner = safesql.New("api_key_id = $1", id)
+	case "user_id":
+		owner = safesql.New("user_id = $1", id)
+	default:
+		return nil, fmt.Errorf("invalid usage column %q", column)
+	}
+	since := now.UTC().AddDate(0, 0, 1-days).Format(time.DateOnly)
+
+	query := safesql.New(`
+		SELECT TO_CHAR(day, 'YYYY-MM-DD'), endpoint, requests, errors, last_used
+		FROM api_usage
+		WHERE `).
+		AppendQuery(owner).
+		Append(` AND day >= $1`, since)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []apiUsageRow
+	for rows.Next() {
+		var row apiUsageRow
+		if err := rows.Scan(&row.Day, &row.Endpoint, &row.Requests, &row.Errors, &row.LastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan API usage: %w", err)
+		}
+		row.LastUsed = row.LastUsed.UTC()
+		usage = append(usage, row)
+	}
+	return usage, rows.Err()
+}
+
+// PruneAPIUsage removes usage of days before cutoff
+func (d *Database) PruneAPIUsage(cutoff time.Time) error {
+	_, err := d.db.Exec(safesql.New(`DELETE FROM api_usage WHERE day < $1`, cutoff.UTC().Format(time.DateOnly)))
+	if err != nil {
+		return fmt.Errorf("failed to prune API usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestUsageRecorder tests counting requests per key, user, day and endpoint
+func TestUsageRecorder(t *testing.T) {
+	u := newUsageRecorder()
+	now := time.Date(2026, 10, 15, 23, 59, 0, 0, time.UTC)
+
+	u.record("hash", 0, "GET /api/v1/clients", http.StatusOK, now)
+	u.record("hash", 0, "GET /api/v1/clients", http.StatusNotFound, now.Add(-time.Minute))
+	u.record("hash", 0, "GET /api/v1/clients", http.StatusOK, now.Add(2*time.Minute))
+	u.record("", 7, "GET /api/v1/clients", http.StatusInternalServerError, now)
+
+	counts := u.take()
+	if len(counts) != 3 {
+		t.Fatalf("counters = %d, want 3 (two days for the key, one for the user)", len(counts))
+	}
+	key := counts[usageKey{keyHash: "hash", day: "2026-10-15", endpoint: "GET /api/v1/clients"}]
+	if key == nil || key.requests != 2 || key.errors != 1 || !key.lastUsed.Equal(now) {
+		t.Errorf("key on 2026-10-15 = %+v", key)
+	}
+	user := counts[usageKey{userID: 7, day: "2026-10-15", endpoint: "GET /api/v1/clients"}]
+	if user == nil || user.requests != 1 || user.errors != 1 {
+		t.Errorf("user = %+v", user)
+	}
+	if len(u.take()) != 0 {
+		t.Error("take() did not start counting afresh")
+	}
+
+	u.record("hash", 0, "GET /api/v1/clients", http.StatusOK, now)
+	u.restore(counts)
+	again := u.take()
+	if got := again[usageKey{keyHash: "hash", day: "2026-10-15", endpoint: "GET /api/v1/clients"}]; got.requests != 3 {
+		t.Errorf("restored requests = %d, want 3", got.requests)
+	}
+	if len(again) != 3 {
+		t.Errorf("restored counters = %d, want 3", len(again))
+	}
+
+	var disabled *usageRecorder
+	disabled.record("hash", 0, "GET /", http.StatusOK, now)
+}
+
+// TestSummarizeAPIUsage tests totals, error rates and ordering
+func TestSummarizeAPIUsage(t *testing.T) {
+	day1 := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	rows := []apiUsageRow{
+		{Day: "2026-10-15", Endpoint: "GET /api/v1/clients", Requests: 10, Errors: 1, LastUsed: day2},
+		{Day: "2026-10-14", Endpoint: "POST /api/v1/compliance/submit", Requests: 30, Errors: 3, LastUsed: day1.Add(time.Hour)},
+		{Day: "2026-10-14", Endpoint: "GET /api/v1/clients", Requests: 10, Errors: 0, LastUsed: day1},
+	}
+
+	got := summarizeAPIUsage(rows, 7)
+	if got.Days != 7 || got.Requests != 50 || got.Errors != 4 || got.ErrorRate != 0.08 {
+		t.Errorf("totals = %d requests, %d errors, rate %v", got.Requests, got.Errors, got.ErrorRate)
+	}
+	if got.LastUsed == nil || !got.LastUsed.Equal(day2) {
+		t.Errorf("last used = %v, want %v", got.LastUsed, day2)
+	}
+	if len(got.Endpoints) != 2 || got.Endpoints[0].Endpoint != "GET /api/v1/clients" ||
+		got.Endpoints[0].Requests != 20 || got.Endpoints[0].ErrorRate != 0.05 || got.Endpoints[1].ErrorRate != 0.1 {
+		t.Errorf("endpoints = %+v, want clients (most recent) then submit", got.Endpoints)
+	}
+	if len(got.Daily) != 2 || got.Daily[0].Date != "2026-10-14" || got.Daily[0].Requests != 40 || got.Daily[1].Errors != 1 {
+		t.Errorf("daily = %+v", got.Daily)
+	}
+
+	empty := summarizeAPIUsage(nil, 30)
+	if empty.LastUsed != nil || empty.Endpoints == nil || empty.Daily == nil || empty.ErrorRate != 0 {
+		t.Errorf("empty = %+v, want empty lists and no last use", empty)
+	}
+}
+
+// TestAPIUsageValidation tests requests refused before usage is looked up
+func TestAPIUsageValidation(t *testing.T) {
+	s := newTestServer()
+	for _, target := range []string{
+		"/api/v1/apikeys/abc/usage",
+		"/api/v1/apikeys/3/usage?days=0",
+		"/api/v1/apikeys/3/usage?days=91",
+		"/api/v1/users/alice/usage?days=week",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
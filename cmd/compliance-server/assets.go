@@ -0,0 +1,25 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// assets holds the dashboard pages and the scripts they load. They are
+// compiled into the binary, so the server needs no files beside it and every
+// replica serves the same pages.
+//
+//go:embed templates static
+var assets embed.FS
+
+// staticAssets is served under /static/
+var staticAssets = mustSub(assets, "static")
+
+// mustSub returns the subtree of an embedded filesystem at dir
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/objectstore"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Limits on the form fields of an attachment upload
+const (
+	maxAttachmentNameLength        = 255
+	maxAttachmentDescriptionLength = 1000
+)
+
+// attachmentSubmission returns the submission in the path when the request
+// may see it, answering 404 otherwise, and 404 for every request when
+// attachments are disabled
+func (s *ComplianceServer) attachmentSubmission(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.attachments == nil {
+		s.sendError(w, http.StatusNotFound, "Attachments are disabled")
+		return "", false
+	}
+	submissionID := r.PathValue("submission_id")
+	if _, err := s.scopedDB(r).GetSubmission(submissionID); err != nil {
+		s.sendError(w, http.StatusNotFound, "Submission not found")
+		return "", false
+	}
+	return submissionID, true
+}
+
+// handleUploadAttachment attaches the file in the multipart field "file",
+// with an optional "description" field, to a submission
+// (POST /api/v1/submissions/{submission_id}/attachments). The media type is
+// sniffed from the content and must be in attachments.allowed_types.
+func (s *ComplianceServer) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	submissionID, ok := s.attachmentSubmission(w, r)
+	if !ok {
+		return
+	}
+	settings := s.config().Attachments
+	maxSize := int64(settings.MaxSizeMB) << 20
+
+	// The form is read in memory rather than spooled to temporary files,
+	// which stateless servers do not have
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Request must be multipart/form-data")
+		return
+	}
+
+	var fileName, description string
+	var data []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.sendAttachmentReadError(w, err, settings.MaxSizeMB)
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			if data != nil {
+				s.sendError(w, http.StatusBadRequest, "Only one file can be uploaded at a time")
+				return
+			}
+			fileName = part.FileName()
+			if data, err = io.ReadAll(io.LimitReader(part, maxSize+1)); err != nil {
+				s.sendAttachmentReadError(w, err, settings.MaxSizeMB)
+				return
+			}
+			if int64(len(data)) > maxSize {
+				s.sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachment is larger than %d MB", settings.MaxSizeMB))
+				return
+			}
+		case "description":
+			value, err := io.ReadAll(io.LimitReader(part, maxAttachmentDescriptionLength+1))
+			if err != nil {
+				s.sendAttachmentReadError(w, err, settings.MaxSizeMB)
+				return
+			}
+			if len(value) > maxAttachmentDescriptionLength || !utf8.Valid(value) {
+				s.sendError(w, http.StatusBadRequest, fmt.Sprintf("description must be UTF-8 text of at most %d bytes", maxAttachmentDescriptionLength))
+				return
+			}
+			description = strings.TrimSpace(string(value))
+		}
+		part.Close()
+	}
+
+	if data == nil {
+		s.sendError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	if len(data) == 0 {
+		s.sendError(w, http.StatusBadRequest, "file is empty")
+		return
+	}
+	contentType, err := attachmentContentType(data, settings.AllowedTypes)
+	if err != nil {
+		s.sendError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := api.Attachment{
+		SubmissionID: submissionID,
+		FileName:     attachmentFileName(fileName),
+		ContentType:  contentType,
+		SizeBytes:    int64(len(data)),
+		SHA256:       hex.EncodeToString(sum[:]),
+		Description:  description,
+		UploadedBy:   s.auditActor(r),
+	}
+	key, err := newAttachmentKey(time.Now())
+	if err != nil {
+		s.logger.Error("Failed to generate attachment key", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+
+	if err := s.attachments.Put(r.Context(), key, bytes.NewReader(data), attachment.SizeBytes, contentType); err != nil {
+		s.logger.Error("Failed to store attachment", "error", err, "submission_id", submissionID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+	if err := s.requestDB(r).CreateAttachment(&attachment, key); err != nil {
+		s.logger.Error("Failed to save attachment", "error", err, "submission_id", submissionID)
+		if err := s.attachments.Delete(context.Background(), key); err != nil {
+			s.logger.Warn("Failed to delete unsaved attachment", "error", err, "key", key)
+		}
+		s.sendError(w, http.StatusInternalServerError, "Failed to save attachment")
+		return
+	}
+
+	s.logger.Info("Attachment uploaded", "submission_id", submissionID, "id", attachment.ID,
+		"file_name", attachment.FileName, "size_bytes", attachment.SizeBytes, "uploaded_by", attachment.UploadedBy)
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/submissions/%s/attachments/%d", submissionID, attachment.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// sendAttachmentReadError answers a failure to read an upload, which is 413
+// when the body was larger than allowed
+func (s *ComplianceServer) sendAttachmentReadError(w http.ResponseWriter, err error, maxSizeMB int) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachment is larger than %d MB", maxSizeMB))
+		return
+	}
+	s.sendError(w, http.StatusBadRequest, "Invalid multipart form")
+}
+
+// attachmentContentType returns the media type sniffed from data, without
+// parameters, or an error when it is not one of allowed
+func attachmentContentType(data []byte, allowed []string) (string, error) {
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "", fmt.Errorf("Unrecognized file type")
+	}
+	if !slices.Contains(allowed, contentType) {
+		return "", fmt.Errorf("File type %s is not allowed (allowed: %s)", contentType, strings.Join(allowed, ", "))
+	}
+	return contentType, nil
+}
+
+// attachmentFileName cleans the file name an upload gave: no directories,
+// control characters or invalid UTF-8, and at most
+// maxAttachmentNameLength bytes
+func attachmentFileName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > maxAttachmentNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// newAttachmentKey returns a new random storage key, grouped by month
+func newAttachmentKey(now time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return now.UTC().Format("2006/01/") + hex.EncodeToString(b), nil
+}
+
+// handleListAttachments lists the attachments of a submission
+// (GET /api/v1/submissions/{submission_id}/attachments)
+func (s *ComplianceServer) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	submissionID, ok := s.attachmentSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	attachments, err := s.requestDB(r).ListAttachments(submissionID)
+	if err != nil {
+		s.logger.Error("Failed to list attachments", "error", err, "submission_id", submissionID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list attachments")
+		return
+	}
+
+	s.respond(w, r, api.AttachmentListResponse{SubmissionID: submissionID, Attachments: attachments}, attachmentsTable(attachments))
+}
+
+// requestAttachment returns the attachment in the path with its storage
+// key, answering 404 when it does not exist
+func (s *ComplianceServer) requestAttachment(w http.ResponseWriter, r *http.Request, submissionID string) (*api.Attachment, string, bool) {
+	id, err := strconv.ParseInt(r.PathValue("attachment_id"), 10, 64)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "Attachment not found")
+		return nil, "", false
+	}
+	attachment, key, err := s.requestDB(r).GetAttachment(submissionID, id)
+	if err != nil {
+		if err.Error() != "attachment not found" {
+			s.logger.Error("Failed to get attachment", "error", err, "submission_id", submissionID, "id", id)
+		}
+		s.sendError(w, http.StatusNotFound, "Attachment not found")
+		return nil, "", false
+	}
+	return attachment, key, true
+}
+
+// handleDownloadAttachment sends an attachment's file
+// (GET /api/v1/submissions/{submission_id}/attachments/{attachment_id}).
+// Files are always downloaded rather than shown, and sandboxed if a browser
+// shows one anyway, as uploaded HTML could otherwise run in the dashboard's
+// origin.
+func (s *ComplianceServer) handleDownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	submissionID, ok := s.attachmentSubmission(w, r)
+	if !ok {
+		return
+	}
+	attachment, key, ok := s.requestAttachment(w, r, submissionID)
+	if !ok {
+		return
+	}
+
+	body, err := s.attachments.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrNotFound) {
+			s.logger.Error("Attachment file missing from storage", "submission_id", submissionID, "id", attachment.ID, "key", key)
+			s.sendError(w, http.StatusNotFound, "Attachment file not found")
+			return
+		}
+		s.logger.Error("Failed to read attachment", "error", err, "submission_id", submissionID, "id", attachment.ID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to read attachment")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	if _, err := io.Copy(w, body); err != nil {
+		s.logger.Warn("Failed to send attachment", "error", err, "submission_id", submissionID, "id", attachment.ID)
+	}
+}
+
+// handleDeleteAttachment deletes an attachment and its file
+// (DELETE /api/v1/submissions/{submission_id}/attachments/{attachment_id})
+func (s *ComplianceServer) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	submissionID, ok := s.attachmentSubmission(w, r)
+	if !ok {
+		return
+	}
+	attachment, _, ok := s.requestAttachment(w, r, submissionID)
+	if !ok {
+		return
+	}
+
+	key, err := s.requestDB(r).DeleteAttachment(submissionID, attachment.ID)
+	if err != nil {
+		s.logger.Error("Failed to delete attachment", "error", err, "submission_id", submissionID, "id", attachment.ID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to delete attachment")
+		return
+	}
+	// The row is gone, so a file left behind is only wasted space
+	if err := s.attachments.Delete(r.Context(), key); err != nil {
+		s.logger.Warn("Failed to delete attachment file", "error", err, "key", key)
+	}
+
+	s.logger.Info("Attachment deleted", "submission_id", submissionID, "id", attachment.ID, "file_name", attachment.FileName)
+	noteAdminChange(r, fmt.Sprintf("%s/%d", submissionID, attachment.ID), attachment, nil)
+	s.respond(w, r, api.StatusResponse{Status: "success", Message: "Attachment deleted"}, nil)
+}
+
+// removeOrphanAttachments deletes the attachments of deleted submissions
+// and their files. It runs after submissions are expired or cleared.
+func (s *ComplianceServer) removeOrphanAttachments() {
+	if s.attachments == nil {
+		return
+	}
+	keys, err := s.db.DeleteOrphanAttachments()
+	if err != nil {
+		s.logger.Error("Failed to delete attachments of deleted submissions", "error", err)
+		return
+	}
+	for _, key := range keys {
+		if err := s.attachments.Delete(context.Background(), key); err != nil {
+			s.logger.Warn("Failed to delete attachment file", "error", err, "key", key)
+		}
+	}
+	if len(keys) > 0 {
+		s.logger.Info("Deleted attachments of deleted submissions", "count", len(keys))
+	}
+}
+
+// attachmentColumns are the columns scanAttachment reads
+const attachmentColumns = `id, submission_id, file_name, content_type, size_bytes, sha256, description,
+	uploaded_by, uploaded_at, storage_key`
+
+// scanAttachment reads a row of attachmentColumns, returning the storage
+// key apart as it is never sent to clients
+func scanAttachment(scan func(...any) error) (*api.Attachment, string, error) {
+	var a api.Attachment
+	var uploadedBy sql.NullString
+	var uploadedAt, key string
+	if err := scan(&a.ID, &a.SubmissionID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.SHA256,
+		&a.Description, &uploadedBy, &uploadedAt, &key); err != nil {
+		return nil, "", err
+	}
+	a.UploadedBy = uploadedBy.String
+	uploaded, err := parseStoredTime(uploadedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse upload time: %w", err)
+	}
+	a.UploadedAt = uploaded
+	return &a, key, nil
+}
+
+// CreateAttachment records an attachment whose file is stored under key,
+// setting its ID and upload time
+func (d *Database) CreateAttachment(a *api.Attachment, key string) error {
+	const query = `
+		INSERT INTO submission_attachments
+			(submission_id, file_name, content_type, size_bytes, sha256, storage_key, description, uploaded_by, uploaded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+		RETURNING id, uploaded_at
+	`
+
+	var uploadedAt string
+	err := d.db.QueryRow(safesql.New(query, a.SubmissionID, a.FileName, a.ContentType, a.SizeBytes, a.SHA256,
+		key, a.Description, nullIfEmpty(a.UploadedBy))).Scan(&a.ID, &uploadedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save attachment: %w", err)
+	}
+	if a.UploadedAt, err = parseStoredTime(uploadedAt); err != nil {
+		return fmt.Errorf("failed to parse upload time: %w", err)
+	}
+	return nil
+}
+
+// ListAttachments returns the attachments of a submission, oldest first
+func (d *Database) ListAttachments(submissionID string) ([]api.Attachment, error) {
+	rows, err := d.db.Query(safesql.New(`SELECT `+attachmentColumns+` FROM submission_attachments
+		WHERE submission_id = $1 ORDER BY id`, submissionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []api.Attachment{}
+	for rows.Next() {
+		a, _, err := scanAttachment(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment returns an attachment of a submission and its storage key
+func (d *Database) GetAttachment(submissionID string, id int64) (*api.Attachment, string, error) {
+	a, key, err := scanAttachment(d.db.QueryRow(safesql.New(`SELECT `+attachmentColumns+` FROM submission_attachments
+		WHERE submission_id = $1 AND id = $2`, submissionID, id)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("attachment not found")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return a, key, nil
+}
+
+// DeleteAttachment deletes an attachment of a submission and returns the
+// storage key of its file
+func (d *Database) DeleteAttachment(submissionID string, id int64) (string, error) {
+	var key string
+	err := d.db.QueryRow(safesql.New(`DELETE FROM submission_attachments
+		WHERE submission_id = $1 AND id = $2 RETURNING storage_key`, submissionID, id)).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("attachment not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return key, nil
+}
+
+// DeleteOrphanAttachments deletes the attachments whose submission no
+// longer exists and returns the storage keys of their files
+func (d *Database) DeleteOrphanAttachments() ([]string, error) {
+	rows, err := d.db.Query(safesql.New(`
+		DELETE FROM submission_attachments a
+		WHERE NOT EXISTS (SELECT 1 FROM submissions s WHERE s.submission_id = a.submission_id)
+		RETURNING storage_key
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan storage key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
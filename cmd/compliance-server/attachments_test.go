@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/objectstore"
+)
+
+// TestAttachmentContentType tests that media types are sniffed from the
+// content, whatever the file claims to be
+func TestAttachmentContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png", false},
+		{"pdf", "%PDF-1.7\n", "application/pdf", false},
+		{"gpo report", "<html><body>Group Policy</body></html>", "text/html", false},
+		{"csv", "check,status\nFirewall,pass\n", "text/plain", false},
+		{"executable", "MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attachmentContentType([]byte(tt.data), defaultAttachmentTypes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("attachmentContentType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("attachmentContentType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := attachmentContentType([]byte("%PDF-1.7\n"), []string{"image/png"}); err == nil {
+		t.Error("attachmentContentType() accepted a type missing from the allowed list")
+	}
+}
+
+// TestAttachmentFileName tests that uploaded file names lose directories
+// and control characters
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"gpo-report.html", "gpo-report.html"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\admin\Desktop\screenshot.png`, "screenshot.png"},
+		{"report\r\n.pdf", "report.pdf"},
+		{"", "attachment"},
+		{"..", "attachment"},
+		{strings.Repeat("é", 200), strings.Repeat("é", 127)},
+	}
+
+	for _, tt := range tests {
+		if got := attachmentFileName(tt.name); got != tt.want {
+			t.Errorf("attachmentFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestNewAttachmentKey tests that storage keys are unique and valid object
+// keys
+func TestNewAttachmentKey(t *testing.T) {
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	first, err := newAttachmentKey(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := newAttachmentKey(now)
+	if first == second || !strings.HasPrefix(first, "2026/10/") {
+		t.Errorf("newAttachmentKey() = %q, %q", first, second)
+	}
+
+	store, err := objectstore.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(t.Context(), first, strings.NewReader("x"), 1, "text/plain"); err != nil {
+		t.Errorf("key %q refused by the store: %v", first, err)
+	}
+}
@@ -0,0 +1,739 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// bootstrapState is the server configuration kept in a version-controlled
+// file and reconciled into the database by `compliance-server apply`. A
+// section missing from the file is left alone; a listed section (even an
+// empty one) is managed, and with --prune anything it does not list is
+// removed.
+type bootstrapState struct {
+	Users      []bootstrapUser       `mapstructure:"users"`
+	APIKeys    []bootstrapAPIKey     `mapstructure:"api_keys"`
+	Policies   []bootstrapPolicy     `mapstructure:"policies"`
+	ClientTags []bootstrapClientTags `mapstructure:"client_tags"`
+}
+
+// bootstrapUser is a dashboard user. Passwords are never kept in the file:
+// password_env names the environment variable holding the initial password,
+// which is only read when the user is created.
+type bootstrapUser struct {
+	Username    string           `mapstructure:"username"`
+	Role        string           `mapstructure:"role"`
+	Team        string           `mapstructure:"team"`
+	Scope       *api.ClientScope `mapstructure:"scope"` // Required for operators
+	PasswordEnv string           `mapstructure:"password_env"`
+
+	password string
+}
+
+// bootstrapAPIKey is a database-backed API key, matched by name. key_env
+// names the environment variable holding the key; a different key rotates it.
+type bootstrapAPIKey struct {
+	Name      string `mapstructure:"name"`
+	KeyEnv    string `mapstructure:"key_env"`
+	ExpiresAt string `mapstructure:"expires_at"` // RFC 3339 or YYYY-MM-DD; empty never expires
+	Disabled  bool   `mapstructure:"disabled"`
+
+	key     string
+	expires *time.Time
+}
+
+// bootstrapPolicy is a policy whose report configuration is read from file,
+// relative to the state file
+type bootstrapPolicy struct {
+	PolicyID    string `mapstructure:"policy_id"`
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Framework   string `mapstructure:"framework"`
+	Version     string `mapstructure:"version"`
+	Category    string `mapstructure:"category"`
+	Author      string `mapstructure:"author"`
+	Status      string `mapstructure:"status"` // Defaults to active
+	File        string `mapstructure:"file"`
+	Owner       string `mapstructure:"owner"`
+	OwnerTeam   string `mapstructure:"owner_team"`
+	Signature   string `mapstructure:"signature"` // Publisher signature from compliance-server sign-policy
+
+	RetentionDays int `mapstructure:"retention_days"` // Overrides the retention limits for the policy's submissions
+
+	policyData string
+}
+
+// bootstrapClientTags are the tags of one registered client
+type bootstrapClientTags struct {
+	ClientID string   `mapstructure:"client_id"`
+	Tags     []string `mapstructure:"tags"`
+}
+
+// bootstrapChange is one step reconciling the database with a state file
+type bootstrapChange struct {
+	Kind   string // "user", "api key", "policy" or "client tags"
+	Name   string
+	Action string // "create", "update", "delete" or "skip"
+	Detail string
+
+	apply func(*Database) error // nil for skipped changes
+}
+
+// bootstrapSnapshot is the current database state a plan is made against
+type bootstrapSnapshot struct {
+	Users      []User
+	APIKeys    []APIKey
+	Policies   []Policy
+	ClientTags map[string][]string // Tags of every registered client
+}
+
+// loadBootstrapState reads and validates a state file. Secrets are read
+// with getenv and policy files relative to the state file.
+func loadBootstrapState(path string, getenv func(string) string) (*bootstrapState, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state bootstrapState
+	if err := v.UnmarshalExact(&state, viper.DecodeHook(timeToStringHook)); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if err := state.prepare(filepath.Dir(path), getenv); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// prepare validates and normalizes the state, reads secrets and policy files
+func (st *bootstrapState) prepare(dir string, getenv func(string) string) error {
+	validRoles := map[string]bool{roleAdmin: true, roleOperator: true, "viewer": true, "auditor": true}
+	seen := make(map[string]bool)
+	for i := range st.Users {
+		u := &st.Users[i]
+		if u.Username == "" {
+			return fmt.Errorf("users[%d]: username is required", i)
+		}
+		if seen[u.Username] {
+			return fmt.Errorf("user %s is listed twice", u.Username)
+		}
+		seen[u.Username] = true
+		if !validRoles[u.Role] {
+			return fmt.Errorf("user %s: invalid role %q (use admin, operator, viewer or auditor)", u.Username, u.Role)
+		}
+
+		var err error
+		if u.Scope, err = validateUserScope(u.Role, u.Scope); err != nil {
+			return fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		if u.Team, err = normalizeTeam(u.Team); err != nil {
+			return fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		if u.PasswordEnv != "" {
+			u.password = getenv(u.PasswordEnv)
+		}
+	}
+
+	seen = make(map[string]bool)
+	for i := range st.APIKeys {
+		k := &st.APIKeys[i]
+		if k.Name == "" {
+			return fmt.Errorf("api_keys[%d]: name is required", i)
+		}
+		if seen[k.Name] {
+			return fmt.Errorf("API key %s is listed twice", k.Name)
+		}
+		seen[k.Name] = true
+		if k.KeyEnv == "" {
+			return fmt.Errorf("API key %s: key_env is required", k.Name)
+		}
+		if k.key = getenv(k.KeyEnv); len(k.key) < 16 {
+			return fmt.Errorf("API key %s: %s must hold a key of at least 16 characters", k.Name, k.KeyEnv)
+		}
+		if k.ExpiresAt != "" {
+			expires, err := parseBootstrapTime(k.ExpiresAt)
+			if err != nil {
+				return fmt.Errorf("API key %s: %w", k.Name, err)
+			}
+			k.expires = &expires
+		}
+	}
+
+	seen = make(map[string]bool)
+	for i := range st.Policies {
+		p := &st.Policies[i]
+		if p.PolicyID == "" || p.Name == "" || p.File == "" {
+			return fmt.Errorf("policies[%d]: policy_id, name and file are required", i)
+		}
+		if seen[p.PolicyID] {
+			return fmt.Errorf("policy %s is listed twice", p.PolicyID)
+		}
+		seen[p.PolicyID] = true
+		if p.Status == "" {
+			p.Status = "active"
+		}
+
+		var err error
+		if p.OwnerTeam, err = normalizeTeam(p.OwnerTeam); err != nil {
+			return fmt.Errorf("policy %s: %w", p.PolicyID, err)
+		}
+
+		file := p.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", p.PolicyID, err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("policy %s: %s is not valid JSON", p.PolicyID, p.File)
+		}
+		if err := checkPolicySignature(p.Signature); err != nil {
+			return fmt.Errorf("policy %s: %w", p.PolicyID, err)
+		}
+		if err := checkPolicyRetention(p.RetentionDays); err != nil {
+			return fmt.Errorf("policy %s: %w", p.PolicyID, err)
+		}
+		p.policyData = string(data)
+	}
+
+	seen = make(map[string]bool)
+	for i := range st.ClientTags {
+		c := &st.ClientTags[i]
+		if c.ClientID == "" {
+			return fmt.Errorf("client_tags[%d]: client_id is required", i)
+		}
+		if seen[c.ClientID] {
+			return fmt.Errorf("client %s is listed twice", c.ClientID)
+		}
+		seen[c.ClientID] = true
+
+		var err error
+		if c.Tags, err = normalizeTags(c.Tags); err != nil {
+			return fmt.Errorf("client %s: %w", c.ClientID, err)
+		}
+	}
+
+	return nil
+}
+
+// timeToStringHook keeps unquoted YAML dates (expires_at: 2027-01-01),
+// which the YAML parser reads as times, usable as strings
+func timeToStringHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if t, ok := data.(time.Time); ok && to.Kind() == reflect.String {
+		return t.Format(time.RFC3339), nil
+	}
+	return data, nil
+}
+
+// parseBootstrapTime parses an RFC 3339 time or a date (midnight UTC)
+func parseBootstrapTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// planBootstrap lists the changes that make the database match the state,
+// in the order they must be applied (users before the policies they own).
+// With prune, managed sections also lose what the state does not list.
+func planBootstrap(state *bootstrapState, current bootstrapSnapshot, prune bool) ([]bootstrapChange, error) {
+	var changes []bootstrapChange
+
+	users, err := planBootstrapUsers(state.Users, current.Users, prune)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, users...)
+
+	keys, err := planBootstrapAPIKeys(state.APIKeys, current.APIKeys, prune)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, keys...)
+
+	// Owners must exist once the users have been reconciled
+	owners := make(map[string]bool)
+	if state.Users == nil || !prune {
+		for _, u := range current.Users {
+			owners[u.Username] = true
+		}
+	}
+	for _, u := range state.Users {
+		owners[u.Username] = true
+	}
+	policies, err := planBootstrapPolicies(state.Policies, current.Policies, owners, prune)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, policies...)
+
+	changes = append(changes, planBootstrapClientTags(state.ClientTags, current.ClientTags, prune)...)
+	return changes, nil
+}
+
+// planBootstrapUsers reconciles users. Existing users keep their password.
+func planBootstrapUsers(desired []bootstrapUser, current []User, prune bool) ([]bootstrapChange, error) {
+	if desired == nil {
+		return nil, nil
+	}
+
+	existing := make(map[string]User, len(current))
+	for _, u := range current {
+		existing[u.Username] = u
+	}
+
+	var changes []bootstrapChange
+	listed := make(map[string]bool, len(desired))
+	hasAdmin := false
+	for _, u := range desired {
+		u := u
+		listed[u.Username] = true
+		hasAdmin = hasAdmin || u.Role == roleAdmin
+
+		old, ok := existing[u.Username]
+		if !ok {
+			if u.password == "" {
+				return nil, fmt.Errorf("user %s does not exist yet; set password_env to a variable holding its initial password", u.Username)
+			}
+			changes = append(changes, bootstrapChange{
+				Kind: "user", Name: u.Username, Action: "create", Detail: "role " + u.Role,
+				apply: func(db *Database) error {
+					hash, err := bcrypt.GenerateFromPassword([]byte(u.password), bcrypt.DefaultCost)
+					if err != nil {
+						return fmt.Errorf("failed to hash password: %w", err)
+					}
+					return db.CreateUser(u.Username, string(hash), u.Role, u.Team, u.Scope)
+				},
+			})
+			continue
+		}
+
+		var diffs []string
+		if old.Role != u.Role {
+			diffs = append(diffs, fmt.Sprintf("role %s -> %s", old.Role, u.Role))
+		}
+		if old.Team != u.Team {
+			diffs = append(diffs, fmt.Sprintf("team %q -> %q", old.Team, u.Team))
+		}
+		if !sameScope(userScope(&old), u.Scope) {
+			diffs = append(diffs, "scope")
+		}
+		if len(diffs) > 0 {
+			changes = append(changes, bootstrapChange{
+				Kind: "user", Name: u.Username, Action: "update", Detail: strings.Join(diffs, ", "),
+				apply: func(db *Database) error {
+					return db.UpdateUserAccess(u.Username, u.Role, u.Team, u.Scope)
+				},
+			})
+		}
+	}
+
+	if prune {
+		if !hasAdmin {
+			return nil, fmt.Errorf("pruning users would leave no admin; list at least one admin user")
+		}
+		for _, u := range current {
+			if listed[u.Username] {
+				continue
+			}
+			username := u.Username
+			changes = append(changes, bootstrapChange{
+				Kind: "user", Name: username, Action: "delete",
+				apply: func(db *Database) error { return db.DeleteUser(username) },
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// planBootstrapAPIKeys reconciles API keys by name
+func planBootstrapAPIKeys(desired []bootstrapAPIKey, current []APIKey, prune bool) ([]bootstrapChange, error) {
+	if desired == nil {
+		return nil, nil
+	}
+
+	existing := make(map[string][]APIKey, len(current))
+	for _, k := range current {
+		existing[k.Name] = append(existing[k.Name], k)
+	}
+
+	var changes []bootstrapChange
+	listed := make(map[string]bool, len(desired))
+	for _, k := range desired {
+		k := k
+		listed[k.Name] = true
+
+		var expiresAt *string
+		if k.expires != nil {
+			value := k.expires.Format(time.RFC3339)
+			expiresAt = &value
+		}
+
+		matches := existing[k.Name]
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("%d API keys are named %s; delete the extra ones first", len(matches), k.Name)
+		}
+		if len(matches) == 0 {
+			changes = append(changes, bootstrapChange{
+				Kind: "api key", Name: k.Name, Action: "create",
+				apply: func(db *Database) error {
+					hash, err := bcrypt.GenerateFromPassword([]byte(k.key), bcrypt.DefaultCost)
+					if err != nil {
+						return fmt.Errorf("failed to hash API key: %w", err)
+					}
+					if err := db.CreateAPIKey(k.Name, string(hash), k.key[:8]+"...", "bootstrap", expiresAt); err != nil {
+						return err
+					}
+					if !k.Disabled {
+						return nil
+					}
+					created, err := db.GetAPIKeyByHash(string(hash))
+					if err != nil || created == nil {
+						return fmt.Errorf("failed to find created API key: %v", err)
+					}
+					return db.DeactivateAPIKey(created.ID)
+				},
+			})
+			continue
+		}
+
+		old := matches[0]
+		var diffs []string
+		rotate := bcrypt.CompareHashAndPassword([]byte(old.KeyHash), []byte(k.key)) != nil
+		if rotate {
+			diffs = append(diffs, "key rotated")
+		}
+		oldExpires := parseDBTime(old.ExpiresAt)
+		expiryChanged := (k.expires == nil) != (old.ExpiresAt == "") ||
+			(k.expires != nil && !k.expires.Equal(oldExpires))
+		if expiryChanged {
+			diffs = append(diffs, fmt.Sprintf("expires %q -> %q", old.ExpiresAt, k.ExpiresAt))
+		}
+		if old.IsActive == k.Disabled {
+			diffs = append(diffs, fmt.Sprintf("active %t -> %t", old.IsActive, !k.Disabled))
+		}
+		if len(diffs) == 0 {
+			continue
+		}
+
+		changes = append(changes, bootstrapChange{
+			Kind: "api key", Name: k.Name, Action: "update", Detail: strings.Join(diffs, ", "),
+			apply: func(db *Database) error {
+				if rotate || expiryChanged {
+					hash, prefix := old.KeyHash, old.KeyPrefix
+					if rotate {
+						newHash, err := bcrypt.GenerateFromPassword([]byte(k.key), bcrypt.DefaultCost)
+						if err != nil {
+							return fmt.Errorf("failed to hash API key: %w", err)
+						}
+						hash, prefix = string(newHash), k.key[:8]+"..."
+					}
+					if err := db.UpdateAPIKey(old.ID, hash, prefix, expiresAt); err != nil {
+						return err
+					}
+				}
+				if old.IsActive == k.Disabled {
+					if k.Disabled {
+						return db.DeactivateAPIKey(old.ID)
+					}
+					return db.ActivateAPIKey(old.ID)
+				}
+				return nil
+			},
+		})
+	}
+
+	if prune {
+		for _, k := range current {
+			if listed[k.Name] {
+				continue
+			}
+			id := k.ID
+			changes = append(changes, bootstrapChange{
+				Kind: "api key", Name: k.Name, Action: "delete",
+				apply: func(db *Database) error { return db.DeleteAPIKey(id) },
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// planBootstrapPolicies reconciles policies and their owners. owners are the
+// users that will exist once users are reconciled.
+func planBootstrapPolicies(desired []bootstrapPolicy, current []Policy, owners map[string]bool, prune bool) ([]bootstrapChange, error) {
+	if desired == nil {
+		return nil, nil
+	}
+
+	existing := make(map[string]Policy, len(current))
+	for _, p := range current {
+		existing[p.PolicyID] = p
+	}
+
+	var changes []bootstrapChange
+	listed := make(map[string]bool, len(desired))
+	for _, bp := range desired {
+		listed[bp.PolicyID] = true
+		if bp.Owner != "" && !owners[bp.Owner] {
+			return nil, fmt.Errorf("policy %s: owner %s is not a user", bp.PolicyID, bp.Owner)
+		}
+
+		p := Policy{
+			PolicyID:    bp.PolicyID,
+			Name:        bp.Name,
+			Description: bp.Description,
+			Framework:   bp.Framework,
+			Version:     bp.Version,
+			Category:    bp.Category,
+			Author:      bp.Author,
+			Status:      bp.Status,
+			PolicyData:  bp.policyData,
+			Owner:       bp.Owner,
+			OwnerTeam:   bp.OwnerTeam,
+			Signature:   bp.Signature,
+
+			RetentionDays: bp.RetentionDays,
+		}
+
+		old, ok := existing[p.PolicyID]
+		if !ok {
+			changes = append(changes, bootstrapChange{
+				Kind: "policy", Name: p.PolicyID, Action: "create", Detail: p.Name,
+				apply: func(db *Database) error { return db.CreatePolicy(&p) },
+			})
+			continue
+		}
+
+		var diffs []string
+		for _, field := range []struct{ name, old, new string }{
+			{"name", old.Name, p.Name},
+			{"description", old.Description, p.Description},
+			{"framework", old.Framework, p.Framework},
+			{"version", old.Version, p.Version},
+			{"category", old.Category, p.Category},
+			{"author", old.Author, p.Author},
+			{"status", old.Status, p.Status},
+			{"policy data", old.PolicyData, p.PolicyData},
+			{"signature", old.Signature, p.Signature},
+			{"retention", strconv.Itoa(old.RetentionDays), strconv.Itoa(p.RetentionDays)},
+		} {
+			if field.old != field.new {
+				diffs = append(diffs, field.name)
+			}
+		}
+		contentChanged := len(diffs) > 0
+		ownersChanged := old.Owner != p.Owner || old.OwnerTeam != p.OwnerTeam
+		if ownersChanged {
+			diffs = append(diffs, "owners")
+		}
+		if len(diffs) == 0 {
+			continue
+		}
+
+		changes = append(changes, bootstrapChange{
+			Kind: "policy", Name: p.PolicyID, Action: "update", Detail: strings.Join(diffs, ", "),
+			apply: func(db *Database) error {
+				if contentChanged {
+					if err := db.UpdatePolicy(p.PolicyID, &p); err != nil {
+						return err
+					}
+				}
+				if ownersChanged {
+					return db.SetPolicyOwner(p.PolicyID, p.Owner, p.OwnerTeam)
+				}
+				return nil
+			},
+		})
+	}
+
+	if prune {
+		for _, p := range current {
+			if listed[p.PolicyID] {
+				continue
+			}
+			policyID := p.PolicyID
+			changes = append(changes, bootstrapChange{
+				Kind: "policy", Name: policyID, Action: "delete",
+				apply: func(db *Database) error { return db.DeletePolicy(policyID) },
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// planBootstrapClientTags reconciles client tags. Clients register
+// themselves, so tags for clients that have not registered yet are skipped
+// until a later apply.
+func planBootstrapClientTags(desired []bootstrapClientTags, current map[string][]string, prune bool) []bootstrapChange {
+	if desired == nil {
+		return nil
+	}
+
+	var changes []bootstrapChange
+	listed := make(map[string]bool, len(desired))
+	for _, c := range desired {
+		c := c
+		listed[c.ClientID] = true
+
+		old, ok := current[c.ClientID]
+		if !ok {
+			changes = append(changes, bootstrapChange{
+				Kind: "client tags", Name: c.ClientID, Action: "skip", Detail: "client not registered yet",
+			})
+			continue
+		}
+		if sameStrings(old, c.Tags) {
+			continue
+		}
+		changes = append(changes, bootstrapChange{
+			Kind: "client tags", Name: c.ClientID, Action: "update",
+			Detail: fmt.Sprintf("[%s] -> [%s]", strings.Join(old, " "), strings.Join(c.Tags, " ")),
+			apply:  func(db *Database) error { return db.SetClientTags(c.ClientID, c.Tags) },
+		})
+	}
+
+	if prune {
+		for clientID, tags := range current {
+			if listed[clientID] || len(tags) == 0 {
+				continue
+			}
+			clientID := clientID
+			changes = append(changes, bootstrapChange{
+				Kind: "client tags", Name: clientID, Action: "update",
+				Detail: fmt.Sprintf("[%s] -> []", strings.Join(tags, " ")),
+				apply:  func(db *Database) error { return db.SetClientTags(clientID, nil) },
+			})
+		}
+	}
+
+	return changes
+}
+
+// sameScope reports whether two client scopes (nil for unscoped users) are
+// equal
+func sameScope(a, b *api.ClientScope) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return sameStrings(a.Orgs, b.Orgs) && sameStrings(a.Tags, b.Tags)
+}
+
+// sameStrings reports whether two lists hold the same strings in the same
+// order, treating nil and empty lists as equal
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// bootstrapSnapshot reads the current state of everything a state file
+// manages
+func (d *Database) bootstrapSnapshot() (bootstrapSnapshot, error) {
+	var snapshot bootstrapSnapshot
+	var err error
+
+	if snapshot.Users, err = d.ListUsers(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.APIKeys, err = d.ListAPIKeys(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Policies, err = d.ListPolicies(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.ClientTags, err = d.ListClientTags(); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// runApplyCommand reconciles the database with a state file for
+// `compliance-server apply`. It reports whether it succeeded.
+func runApplyCommand(config *ServerConfig, path string, dryRun, prune bool) bool {
+	state, err := loadBootstrapState(path, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	db, err := NewDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open database: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	snapshot, err := db.bootstrapSnapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	changes, err := planBootstrap(state, snapshot, prune)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	applied, pending := 0, 0
+	for _, change := range changes {
+		if change.apply != nil {
+			pending++
+		}
+	}
+	for _, change := range changes {
+		fmt.Printf("  %-7s %-12s %s", change.Action, change.Kind, change.Name)
+		if change.Detail != "" {
+			fmt.Printf(" (%s)", change.Detail)
+		}
+		fmt.Println()
+
+		if change.apply == nil || dryRun {
+			continue
+		}
+		if err := change.apply(db); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s %s %s: %v\n", change.Action, change.Kind, change.Name, err)
+			fmt.Printf("Applied %d of %d changes before the error\n", applied, pending)
+			return false
+		}
+		applied++
+	}
+
+	switch {
+	case pending == 0:
+		fmt.Printf("Nothing to do; the server matches %s\n", path)
+	case dryRun:
+		fmt.Println("Dry run; no changes were made")
+	default:
+		fmt.Printf("Applied %d changes from %s\n", applied, path)
+	}
+	return true
+}
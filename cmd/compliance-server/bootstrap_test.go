@@ -0,0 +1,214 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// writeStateFile writes a state file and the policy file it references
+func writeStateFile(t *testing.T, state string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "policies"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "policies", "cis-l1.json"), []byte(`{"version":"1.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "state.yaml")
+	if err := os.WriteFile(path, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testEnv returns a getenv function serving fixed values
+func testEnv(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+// TestLoadBootstrapState tests reading and validating state files
+func TestLoadBootstrapState(t *testing.T) {
+	env := testEnv(map[string]string{"ALICE_PASSWORD": "change-me-now", "CI_KEY": "ci-key-0123456789abcdef"})
+
+	path := writeStateFile(t, `
+users:
+  - username: alice
+    role: operator
+    team: CIS-Benchmarks
+    password_env: ALICE_PASSWORD
+    scope:
+      tags: [POS]
+api_keys:
+  - name: ci
+    key_env: CI_KEY
+    expires_at: 2027-01-01
+policies:
+  - policy_id: cis-l1
+    name: CIS Level 1
+    file: policies/cis-l1.json
+    owner_team: cis-benchmarks
+client_tags: []
+`)
+	state, err := loadBootstrapState(path, env)
+	if err != nil {
+		t.Fatalf("loadBootstrapState() error = %v", err)
+	}
+
+	alice := state.Users[0]
+	if alice.password != "change-me-now" || alice.Team != "cis-benchmarks" || alice.Scope == nil || alice.Scope.Tags[0] != "pos" {
+		t.Errorf("user = %+v, want password, normalized team and scope", alice)
+	}
+	if key := state.APIKeys[0]; key.key != "ci-key-0123456789abcdef" || key.expires == nil || key.expires.Year() != 2027 {
+		t.Errorf("API key = %+v, want key and expiry", key)
+	}
+	if policy := state.Policies[0]; policy.policyData != `{"version":"1.0"}` || policy.Status != "active" {
+		t.Errorf("policy = %+v, want data read from file and active status", policy)
+	}
+	if state.ClientTags == nil {
+		t.Error("an empty client_tags section should be managed (non-nil)")
+	}
+
+	invalid := []struct {
+		name  string
+		state string
+	}{
+		{"unknown section", "notification_rules: []\n"},
+		{"unknown field", "users:\n  - username: alice\n    role: admin\n    password: secret\n"},
+		{"invalid role", "users:\n  - username: alice\n    role: root\n"},
+		{"operator without scope", "users:\n  - username: alice\n    role: operator\n"},
+		{"user listed twice", "users:\n  - {username: alice, role: admin}\n  - {username: alice, role: viewer}\n"},
+		{"missing key", "api_keys:\n  - name: ci\n    key_env: MISSING\n"},
+		{"invalid expiry", "api_keys:\n  - name: ci\n    key_env: CI_KEY\n    expires_at: next year\n"},
+		{"missing policy file", "policies:\n  - {policy_id: nist, name: NIST, file: policies/nist.json}\n"},
+		{"negative policy retention", "policies:\n  - {policy_id: cis-l1, name: CIS, file: policies/cis-l1.json, retention_days: -1}\n"},
+		{"invalid tag", "client_tags:\n  - client_id: client-1\n    tags: [a b]\n"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadBootstrapState(writeStateFile(t, tt.state), env); err == nil {
+				t.Error("loadBootstrapState() error = nil, want error")
+			}
+		})
+	}
+}
+
+// TestPlanBootstrap tests the changes planned against an existing server
+func TestPlanBootstrap(t *testing.T) {
+	keyHash, err := bcrypt.GenerateFromPassword([]byte("ci-key-0123456789abcdef"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := bootstrapSnapshot{
+		Users: []User{
+			{Username: "admin", Role: "admin"},
+			{Username: "bob", Role: "viewer"},
+			{Username: "carol", Role: "viewer"},
+		},
+		APIKeys: []APIKey{
+			{ID: 1, Name: "ci", KeyHash: string(keyHash), IsActive: true},
+			{ID: 2, Name: "old", KeyHash: string(keyHash), IsActive: true},
+		},
+		Policies: []Policy{
+			{PolicyID: "cis-l1", Name: "CIS Level 1", Status: "active", PolicyData: `{}`, Owner: "bob"},
+			{PolicyID: "legacy", Name: "Legacy", Status: "active", PolicyData: `{}`},
+		},
+		ClientTags: map[string][]string{"client-1": {"emea"}, "client-2": {"apac"}},
+	}
+
+	state := &bootstrapState{
+		Users: []bootstrapUser{
+			{Username: "admin", Role: "admin"},
+			{Username: "bob", Role: roleOperator, Scope: &api.ClientScope{Orgs: []string{}, Tags: []string{"pos"}}},
+			{Username: "dave", Role: "viewer", password: "change-me-now"},
+		},
+		APIKeys: []bootstrapAPIKey{
+			{Name: "ci", key: "ci-key-0123456789abcdef"},
+			{Name: "deploy", key: "deploy-key-0123456789"},
+		},
+		Policies: []bootstrapPolicy{
+			{PolicyID: "cis-l1", Name: "CIS Level 1", Status: "active", policyData: `{}`, OwnerTeam: "cis"},
+		},
+		ClientTags: []bootstrapClientTags{
+			{ClientID: "client-1", Tags: []string{"emea"}},
+			{ClientID: "client-3", Tags: []string{"pos"}},
+		},
+	}
+
+	plan := func(t *testing.T, prune bool) []string {
+		t.Helper()
+		changes, err := planBootstrap(state, current, prune)
+		if err != nil {
+			t.Fatalf("planBootstrap() error = %v", err)
+		}
+		var got []string
+		for _, c := range changes {
+			got = append(got, c.Action+" "+c.Kind+" "+c.Name)
+			if (c.apply == nil) != (c.Action == "skip") {
+				t.Errorf("%s %s %s: apply set = %v", c.Action, c.Kind, c.Name, c.apply != nil)
+			}
+		}
+		return got
+	}
+
+	t.Run("reconcile", func(t *testing.T) {
+		got := strings.Join(plan(t, false), "\n")
+		want := strings.Join([]string{
+			"update user bob",
+			"create user dave",
+			"create api key deploy",
+			"update policy cis-l1",
+			"skip client tags client-3",
+		}, "\n")
+		if got != want {
+			t.Errorf("plan =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		got := strings.Join(plan(t, true), "\n")
+		for _, want := range []string{"delete user carol", "delete api key old", "delete policy legacy", "update client tags client-2"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("plan is missing %q:\n%s", want, got)
+			}
+		}
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		changes, err := planBootstrap(&bootstrapState{
+			Users:   []bootstrapUser{{Username: "admin", Role: "admin"}},
+			APIKeys: []bootstrapAPIKey{{Name: "ci", key: "ci-key-0123456789abcdef"}},
+		}, current, false)
+		if err != nil || len(changes) != 0 {
+			t.Errorf("planBootstrap() = %d changes, %v; want none", len(changes), err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			state *bootstrapState
+			prune bool
+		}{
+			{"new user without password", &bootstrapState{Users: []bootstrapUser{{Username: "erin", Role: "viewer"}}}, false},
+			{"prune without admin", &bootstrapState{Users: []bootstrapUser{{Username: "bob", Role: "viewer"}}}, true},
+			{"unknown owner", &bootstrapState{Policies: []bootstrapPolicy{{PolicyID: "p", Name: "P", Owner: "erin"}}}, false},
+			{"owner pruned", &bootstrapState{
+				Users:    []bootstrapUser{{Username: "admin", Role: "admin"}},
+				Policies: []bootstrapPolicy{{PolicyID: "p", Name: "P", Owner: "bob"}},
+			}, true},
+		}
+		for _, tt := range tests {
+			if _, err := planBootstrap(tt.state, current, tt.prune); err == nil {
+				t.Errorf("%s: planBootstrap() error = nil, want error", tt.name)
+			}
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// cacheKeyPrefix namespaces the server's keys in a shared Redis
+const cacheKeyPrefix = "compliance:"
+
+// redisRetryInterval is how long the cache stays on its in-memory fallback
+// after Redis fails before Redis is tried again
+const redisRetryInterval = 30 * time.Second
+
+// sharedCache holds short-lived values. Get reports a miss as false.
+// DeletePrefix removes every key starting with prefix.
+type sharedCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	DeletePrefix(ctx context.Context, prefix string) error
+	Close() error
+}
+
+// newSharedCache creates the cache selected by cache.backend. An
+// unreachable Redis does not stop the server: the cache falls back to
+// memory until Redis answers.
+func newSharedCache(settings CacheSettings, logger *slog.Logger) (sharedCache, error) {
+	if settings.Backend != cacheBackendRedis {
+		return newMemoryCache(), nil
+	}
+
+	opts, err := redis.ParseURL(settings.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache.redis_url: %w", err)
+	}
+	// Fail fast so requests are not held up while Redis is down
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = time.Second
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 500 * time.Millisecond
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 500 * time.Millisecond
+	}
+
+	primary := &redisCache{client: redis.NewClient(opts)}
+	cache := &fallbackCache{
+		primary:  primary,
+		fallback: newMemoryCache(),
+		logger:   logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := primary.client.Ping(ctx).Err(); err != nil {
+		cache.markDown(err)
+	} else {
+		logger.Info("Cache initialized", "backend", cacheBackendRedis, "addr", opts.Addr)
+	}
+	return cache, nil
+}
+
+// memoryCache is a cache private to this server process
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries so keys that are never read again do not pile up
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = memoryCacheEntry{value: value, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (c *memoryCache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+// redisCache is a cache shared by every server connected to one Redis
+type redisCache struct {
+	client *redis.Client
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, cacheKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, cacheKeyPrefix+key, value, ttl).Err()
+}
+
+func (c *redisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := c.client.Scan(ctx, 0, cacheKeyPrefix+redisGlobEscape(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// redisGlobEscape escapes the glob characters of SCAN MATCH patterns
+func redisGlobEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
+
+// fallbackCache uses primary while it works and fallback for
+// redisRetryInterval after each primary failure
+type fallbackCache struct {
+	primary  sharedCache
+	fallback sharedCache
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// usePrimary reports whether primary should be tried
+func (c *fallbackCache) usePrimary() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(c.downUntil)
+}
+
+// markDown switches to the fallback after a primary failure
+func (c *fallbackCache) markDown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.downUntil.IsZero() {
+		c.logger.Warn("Redis cache unavailable; using in-memory cache", "error", err, "retry_in", redisRetryInterval)
+	}
+	c.downUntil = time.Now().Add(redisRetryInterval)
+}
+
+// markUp records that primary answered again
+func (c *fallbackCache) markUp() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.downUntil.IsZero() {
+		c.logger.Info("Redis cache available again")
+		c.downUntil = time.Time{}
+	}
+}
+
+func (c *fallbackCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if c.usePrimary() {
+		value, ok, err := c.primary.Get(ctx, key)
+		if err == nil {
+			c.markUp()
+			return value, ok, nil
+		}
+		c.markDown(err)
+	}
+	return c.fallback.Get(ctx, key)
+}
+
+func (c *fallbackCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if c.usePrimary() {
+		err := c.primary.Set(ctx, key, value, ttl)
+		if err == nil {
+			c.markUp()
+			return nil
+		}
+		c.markDown(err)
+	}
+	return c.fallback.Set(ctx, key, value, ttl)
+}
+
+// DeletePrefix deletes from both caches, since either may hold the keys
+func (c *fallbackCache) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := c.fallback.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+	err := c.primary.DeletePrefix(ctx, prefix)
+	if err != nil {
+		c.markDown(err)
+	}
+	return err
+}
+
+func (c *fallbackCache) Close() error {
+	return c.primary.Close()
+}
+
+// dashboardSummaryKeyPrefix starts the cache keys of dashboard summaries,
+// which are followed by the client scope
+const dashboardSummaryKeyPrefix = "dashboard_summary:"
+
+// dashboardSummary returns the dashboard summary for the request's client
+// scope, reusing one computed within cache.summary_ttl
+func (s *ComplianceServer) dashboardSummary(r *http.Request) (*api.DashboardSummary, error) {
+	if s.cache == nil || s.config().Cache.SummaryTTL <= 0 {
+		return s.scopedDB(r).GetDashboardSummary()
+	}
+
+	scope, err := json.Marshal(requestScope(r))
+	if err != nil {
+		return nil, err
+	}
+	key := dashboardSummaryKeyPrefix + string(scope)
+
+	if data, ok, err := s.cache.Get(r.Context(), key); err != nil {
+		s.logger.Warn("Failed to read cached dashboard summary", "error", err)
+	} else if ok {
+		var summary api.DashboardSummary
+		if err := json.Unmarshal(data, &summary); err == nil {
+			return &summary, nil
+		}
+	}
+
+	summary, err := s.scopedDB(r).GetDashboardSummary()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(summary); err == nil {
+		if err := s.cache.Set(r.Context(), key, data, s.config().Cache.SummaryTTL); err != nil {
+			s.logger.Warn("Failed to cache dashboard summary", "error", err)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// failingCache is a primary cache that is down
+type failingCache struct {
+	calls int
+}
+
+func (c *failingCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.calls++
+	return nil, false, errors.New("connection refused")
+}
+
+func (c *failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.calls++
+	return errors.New("connection refused")
+}
+
+func (c *failingCache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.calls++
+	return errors.New("connection refused")
+}
+
+func (c *failingCache) Close() error {
+	return nil
+}
+
+// TestMemoryCache tests that entries are returned until they expire
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache()
+
+	cache.Set(ctx, "fresh", []byte("a"), time.Minute)
+	cache.Set(ctx, "stale", []byte("b"), -time.Second)
+
+	if value, ok, err := cache.Get(ctx, "fresh"); err != nil || !ok || string(value) != "a" {
+		t.Errorf("Get(fresh) = %q, %v, %v; want a", value, ok, err)
+	}
+	if _, ok, _ := cache.Get(ctx, "stale"); ok {
+		t.Error("Get(stale) returned an expired entry")
+	}
+	if _, ok, _ := cache.Get(ctx, "missing"); ok {
+		t.Error("Get(missing) reported a hit")
+	}
+}
+
+// TestMemoryCacheDeletePrefix tests that only keys with the prefix are
+// deleted
+func TestMemoryCacheDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryCache()
+
+	cache.Set(ctx, "dashboard_summary:null", []byte("a"), time.Minute)
+	cache.Set(ctx, `dashboard_summary:{"orgs":["emea"]}`, []byte("b"), time.Minute)
+	cache.Set(ctx, "other", []byte("c"), time.Minute)
+
+	if err := cache.DeletePrefix(ctx, "dashboard_summary:"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("%d entries left, want 1", len(cache.entries))
+	}
+	if _, ok, _ := cache.Get(ctx, "other"); !ok {
+		t.Error("DeletePrefix() deleted a key without the prefix")
+	}
+}
+
+// TestRedisGlobEscape tests that glob characters in a prefix match
+// themselves
+func TestRedisGlobEscape(t *testing.T) {
+	if got, want := redisGlobEscape(`a*b?[c]\d`), `a\*b\?\[c\]\\d`; got != want {
+		t.Errorf("redisGlobEscape() = %s, want %s", got, want)
+	}
+}
+
+// TestFallbackCache tests that an unavailable primary is bypassed in favour
+// of memory, and not retried on every request
+func TestFallbackCache(t *testing.T) {
+	ctx := context.Background()
+	primary := &failingCache{}
+	cache := &fallbackCache{
+		primary:  primary,
+		fallback: newMemoryCache(),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if err := cache.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if value, ok, err := cache.Get(ctx, "key"); err != nil || !ok || string(value) != "value" {
+		t.Errorf("Get() = %q, %v, %v; want the value from the fallback", value, ok, err)
+	}
+	if primary.calls != 1 {
+		t.Errorf("primary called %d times, want 1 before the retry interval", primary.calls)
+	}
+}
+
+// TestNewSharedCacheUnreachableRedis tests that the server starts with an
+// in-memory cache when Redis cannot be reached
+func TestNewSharedCacheUnreachableRedis(t *testing.T) {
+	ctx := context.Background()
+	cache, err := newSharedCache(CacheSettings{Backend: cacheBackendRedis, RedisURL: "redis://127.0.0.1:1/0"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newSharedCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	if err := cache.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if value, ok, err := cache.Get(ctx, "key"); err != nil || !ok || string(value) != "value" {
+		t.Errorf("Get() = %q, %v, %v; want the cached value", value, ok, err)
+	}
+}
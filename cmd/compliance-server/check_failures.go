@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// findCheckFailures lists the clients whose latest submission fails the
+// named check, given the latest submission of every client (see
+// LatestSubmissions), by hostname. Submissions without the check are not
+// counted as evaluated.
+func findCheckFailures(latest []*api.ComplianceSubmission, queryName string) api.CheckFailuresResponse {
+	response := api.CheckFailuresResponse{
+		QueryName: queryName,
+		Failures:  []api.CheckFailure{},
+	}
+	for _, submission := range latest {
+		for _, result := range submission.Compliance.Queries {
+			if result.Name != queryName {
+				continue
+			}
+			response.ClientsEvaluated++
+			if result.Status != checkStatusFail {
+				break
+			}
+			response.Failures = append(response.Failures, api.CheckFailure{
+				ClientID:     submission.ClientID,
+				Hostname:     submission.Hostname,
+				SubmissionID: submission.SubmissionID,
+				Timestamp:    submission.Timestamp,
+				Expected:     result.Expected,
+				Operator:     result.Operator,
+				Actual:       result.Actual,
+				ActualList:   result.ActualList,
+				Message:      result.Message,
+				SummaryOnly:  submission.SummaryOnly,
+			})
+			break
+		}
+	}
+
+	sort.Slice(response.Failures, func(i, j int) bool {
+		a, b := response.Failures[i], response.Failures[j]
+		if !strings.EqualFold(a.Hostname, b.Hostname) {
+			return strings.ToLower(a.Hostname) < strings.ToLower(b.Hostname)
+		}
+		return a.ClientID < b.ClientID
+	})
+	response.ClientsFailing = len(response.Failures)
+	return response
+}
+
+// hasCheck reports whether a policy defines the named check
+func (p *simulationPolicy) hasCheck(queryName string) bool {
+	for _, query := range p.Queries {
+		if query.Name == queryName {
+			return true
+		}
+	}
+	return false
+}
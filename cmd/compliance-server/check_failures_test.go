@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestFindCheckFailures tests listing the clients whose latest submission
+// fails a check
+func TestFindCheckFailures(t *testing.T) {
+	day := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	submission := func(clientID, hostname string, summaryOnly bool, results ...api.QueryResult) *api.ComplianceSubmission {
+		return &api.ComplianceSubmission{
+			SubmissionID: "sub-" + clientID, ClientID: clientID, Hostname: hostname, Timestamp: day,
+			ReportType: "CIS", SummaryOnly: summaryOnly,
+			Compliance: api.ComplianceData{Queries: results},
+		}
+	}
+	smbFail := api.QueryResult{Name: "smb_v1_enabled", Status: "fail", Expected: "0", Actual: "1", Message: "SMBv1 is enabled"}
+
+	latest := []*api.ComplianceSubmission{
+		submission("c1", "WS-B", false, smbFail),
+		submission("c2", "WS-A", false, api.QueryResult{Name: "uac", Status: "fail"}, smbFail),
+		submission("c3", "WS-C", false, api.QueryResult{Name: "smb_v1_enabled", Status: "pass", Actual: "0"}),
+		submission("c4", "ws-c", true, api.QueryResult{Name: "smb_v1_enabled", Status: "fail"}),
+		submission("c5", "WS-D", false, api.QueryResult{Name: "uac", Status: "pass"}),
+		submission("c6", "WS-E", false, api.QueryResult{Name: "smb_v1_enabled", Status: "error"}),
+	}
+
+	got := findCheckFailures(latest, "smb_v1_enabled")
+	if got.ClientsEvaluated != 5 || got.ClientsFailing != 3 {
+		t.Fatalf("evaluated %d, failing %d; want 5 and 3", got.ClientsEvaluated, got.ClientsFailing)
+	}
+
+	wantOrder := []string{"c2", "c1", "c4"}
+	for i, failure := range got.Failures {
+		if failure.ClientID != wantOrder[i] {
+			t.Errorf("failure %d = %s, want %s (sorted by hostname)", i, failure.ClientID, wantOrder[i])
+		}
+	}
+	if first := got.Failures[0]; first.Actual != "1" || first.Expected != "0" || first.SubmissionID != "sub-c2" || first.Message != smbFail.Message {
+		t.Errorf("first failure = %+v, want the reported value", first)
+	}
+	if !got.Failures[2].SummaryOnly {
+		t.Error("failure of a summary-only submission is not marked")
+	}
+
+	if none := findCheckFailures(latest, "missing"); none.ClientsEvaluated != 0 || none.Failures == nil {
+		t.Errorf("unknown check = %+v, want nothing evaluated and an empty list", none)
+	}
+}
+
+// TestCheckFailuresValidation tests that the check failures endpoint needs a policy
+func TestCheckFailuresValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	for _, target := range []string{"/api/v1/checks/smb_v1_enabled/failures", "/api/v1/checks/smb_v1_enabled/failures?policy=+"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d (body %s)", target, rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// Orderings supported by the check performance report
+const (
+	checkSortDuration = "duration"
+	checkSortErrors   = "errors"
+)
+
+// checkPerformanceStats accumulates the executions of one check
+type checkPerformanceStats struct {
+	executions   int
+	durations    []float64
+	errors       int
+	errorClasses map[string]int
+}
+
+// summarizeCheckPerformance aggregates the duration and error class every
+// client reported for each check, ranked by sortBy: slowest p95 first for
+// "duration", most errors first for "errors". At most limit checks are
+// returned.
+func summarizeCheckPerformance(history []*api.ComplianceSubmission, sortBy string, limit int) []api.CheckPerformance {
+	stats := make(map[checkKey]*checkPerformanceStats)
+	for _, submission := range history {
+		for _, result := range submission.Compliance.Queries {
+			key := checkKey{reportType: submission.ReportType, name: result.Name}
+			st, ok := stats[key]
+			if !ok {
+				st = &checkPerformanceStats{errorClasses: make(map[string]int)}
+				stats[key] = st
+			}
+
+			st.executions++
+			// Submissions from older agents carry no durations
+			if result.DurationMs > 0 {
+				st.durations = append(st.durations, result.DurationMs)
+			}
+			if result.Status == "error" {
+				st.errors++
+			}
+			if result.ErrorClass != "" {
+				st.errorClasses[result.ErrorClass]++
+			}
+		}
+	}
+
+	checks := make([]api.CheckPerformance, 0, len(stats))
+	for key, st := range stats {
+		check := api.CheckPerformance{
+			ReportType: key.reportType,
+			Name:       key.name,
+			Executions: st.executions,
+			P95Ms:      api.Percentile(st.durations, 95),
+			Errors:     st.errors,
+			ErrorRate:  float64(st.errors) / float64(st.executions),
+		}
+		if len(st.errorClasses) > 0 {
+			check.ErrorClasses = st.errorClasses
+		}
+
+		var total float64
+		for _, d := range st.durations {
+			total += d
+			check.MaxMs = max(check.MaxMs, d)
+		}
+		if len(st.durations) > 0 {
+			check.AvgMs = total / float64(len(st.durations))
+		}
+
+		checks = append(checks, check)
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		a, b := checks[i], checks[j]
+		if sortBy == checkSortErrors {
+			if a.Errors != b.Errors {
+				return a.Errors > b.Errors
+			}
+			if a.ErrorRate != b.ErrorRate {
+				return a.ErrorRate > b.ErrorRate
+			}
+		} else if a.P95Ms != b.P95Ms {
+			return a.P95Ms > b.P95Ms
+		}
+		if a.ReportType != b.ReportType {
+			return a.ReportType < b.ReportType
+		}
+		return a.Name < b.Name
+	})
+
+	if len(checks) > limit {
+		checks = checks[:limit]
+	}
+	return checks
+}
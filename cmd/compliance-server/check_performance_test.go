@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSummarizeCheckPerformance tests aggregating and ranking check statistics
+func TestSummarizeCheckPerformance(t *testing.T) {
+	submission := func(results ...api.QueryResult) *api.ComplianceSubmission {
+		return &api.ComplianceSubmission{ReportType: "NIST", Compliance: api.ComplianceData{Queries: results}}
+	}
+
+	history := []*api.ComplianceSubmission{
+		submission(
+			api.QueryResult{Name: "Slow", Status: "pass", DurationMs: 100},
+			api.QueryResult{Name: "Denied", Status: "error", DurationMs: 2, ErrorClass: api.ErrorClassAccessDenied},
+			api.QueryResult{Name: "Missing", Status: "fail", DurationMs: 1, ErrorClass: api.ErrorClassNotFound},
+		),
+		submission(
+			api.QueryResult{Name: "Slow", Status: "pass", DurationMs: 300},
+			api.QueryResult{Name: "Denied", Status: "error", DurationMs: 4, ErrorClass: api.ErrorClassAccessDenied},
+			api.QueryResult{Name: "Missing", Status: "fail", ErrorClass: api.ErrorClassNotFound}, // Older agent without durations
+		),
+	}
+
+	byDuration := summarizeCheckPerformance(history, checkSortDuration, 10)
+	if got := performanceNames(byDuration); !reflect.DeepEqual(got, []string{"Slow", "Denied", "Missing"}) {
+		t.Fatalf("duration order = %v, want [Slow Denied Missing]", got)
+	}
+
+	slow := byDuration[0]
+	if slow.Executions != 2 || slow.AvgMs != 200 || slow.P95Ms != 300 || slow.MaxMs != 300 {
+		t.Errorf("Slow = %+v, want 2 executions, avg 200, p95 300, max 300", slow)
+	}
+
+	missing := byDuration[2]
+	if missing.Executions != 2 || missing.AvgMs != 1 || missing.Errors != 0 {
+		t.Errorf("Missing = %+v, want 2 executions, avg over the timed one, no errors", missing)
+	}
+	if missing.ErrorClasses[api.ErrorClassNotFound] != 2 {
+		t.Errorf("Missing error classes = %v, want not_found=2", missing.ErrorClasses)
+	}
+
+	byErrors := summarizeCheckPerformance(history, checkSortErrors, 1)
+	if len(byErrors) != 1 || byErrors[0].Name != "Denied" || byErrors[0].ErrorRate != 1 {
+		t.Errorf("errors order = %+v, want only Denied with error rate 1", byErrors)
+	}
+}
+
+// performanceNames returns the check names of a performance report in order
+func performanceNames(checks []api.CheckPerformance) []string {
+	names := []string{}
+	for _, check := range checks {
+		names = append(names, check.Name)
+	}
+	return names
+}
+
+// TestCheckPerformanceValidation tests parameter validation of the check performance report
+func TestCheckPerformanceValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"zero days", "days=0"},
+		{"unknown sort", "sort=name"},
+		{"zero limit", "limit=0"},
+		{"limit too large", "limit=1001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/analytics/check-performance?"+tt.query, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// summarizeCheckValues counts the values clients reported for the named
+// check, given the latest submission of every client (see
+// LatestSubmissions). Numeric values are listed in ascending order, with
+// their minimum, median and maximum; other values are listed by the clients
+// reporting them, most first.
+func summarizeCheckValues(latest []*api.ComplianceSubmission, queryName string) api.CheckValuesResponse {
+	response := api.CheckValuesResponse{
+		QueryName: queryName,
+		Values:    []api.CheckValueCount{},
+	}
+
+	counts := make(map[string]*api.CheckValueCount)
+	var numbers []float64
+	numeric := true
+	for _, submission := range latest {
+		for _, result := range submission.Compliance.Queries {
+			if result.Name != queryName {
+				continue
+			}
+			response.ClientsEvaluated++
+
+			value := strings.TrimSpace(result.Actual)
+			switch {
+			case result.ErrorClass == api.ErrorClassNotFound || value == "not found":
+				response.NotFound++
+			case submission.SummaryOnly || value == "" || result.Status == "error":
+				response.Unreported++
+			default:
+				response.ClientsReporting++
+				count, ok := counts[value]
+				if !ok {
+					count = &api.CheckValueCount{Value: value}
+					counts[value] = count
+				}
+				count.Clients++
+				switch result.Status {
+				case checkStatusPass:
+					count.Passing++
+				case checkStatusFail:
+					count.Failing++
+				}
+				if n, err := strconv.ParseFloat(value, 64); err == nil && numeric {
+					numbers = append(numbers, n)
+				} else {
+					numeric = false
+				}
+			}
+			break
+		}
+	}
+
+	for _, count := range counts {
+		count.Percent = math.Round(float64(count.Clients)/float64(response.ClientsReporting)*1000) / 10
+		response.Values = append(response.Values, *count)
+	}
+
+	response.Numeric = numeric && len(numbers) > 0
+	if response.Numeric {
+		sort.Slice(response.Values, func(i, j int) bool {
+			a, _ := strconv.ParseFloat(response.Values[i].Value, 64)
+			b, _ := strconv.ParseFloat(response.Values[j].Value, 64)
+			return a < b
+		})
+		sort.Float64s(numbers)
+		median := numbers[len(numbers)/2]
+		if len(numbers)%2 == 0 {
+			median = (numbers[len(numbers)/2-1] + median) / 2
+		}
+		response.Min, response.Median, response.Max = &numbers[0], &median, &numbers[len(numbers)-1]
+	} else {
+		sort.Slice(response.Values, func(i, j int) bool {
+			a, b := response.Values[i], response.Values[j]
+			if a.Clients != b.Clients {
+				return a.Clients > b.Clients
+			}
+			return a.Value < b.Value
+		})
+	}
+	return response
+}
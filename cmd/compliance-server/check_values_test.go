@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSummarizeCheckValues tests counting the values clients last reported
+// for a check
+func TestSummarizeCheckValues(t *testing.T) {
+	submission := func(summaryOnly bool, results ...api.QueryResult) *api.ComplianceSubmission {
+		return &api.ComplianceSubmission{SummaryOnly: summaryOnly, Compliance: api.ComplianceData{Queries: results}}
+	}
+	length := func(actual, status string) api.QueryResult {
+		return api.QueryResult{Name: "min_password_length", Status: status, Actual: actual}
+	}
+
+	latest := []*api.ComplianceSubmission{
+		submission(false, length("14", "pass")),
+		submission(false, length("8", "fail")),
+		submission(false, api.QueryResult{Name: "uac", Status: "pass", Actual: "1"}, length("8", "fail")),
+		submission(false, length(" 12 ", "fail")),
+		submission(false, api.QueryResult{Name: "min_password_length", Status: "error", ErrorClass: api.ErrorClassNotFound, Actual: "not found"}),
+		submission(false, api.QueryResult{Name: "min_password_length", Status: "error", ErrorClass: api.ErrorClassAccessDenied}),
+		submission(true, length("", "pass")),
+		submission(false, api.QueryResult{Name: "uac", Status: "pass", Actual: "1"}),
+	}
+
+	got := summarizeCheckValues(latest, "min_password_length")
+	if got.ClientsEvaluated != 7 || got.ClientsReporting != 4 || got.NotFound != 1 || got.Unreported != 2 {
+		t.Fatalf("counts = %+v, want 7 evaluated, 4 reporting, 1 not found, 2 unreported", got)
+	}
+	if !got.Numeric || *got.Min != 8 || *got.Median != 10 || *got.Max != 14 {
+		t.Errorf("numeric = %v, min/median/max = %v/%v/%v; want 8/10/14", got.Numeric, *got.Min, *got.Median, *got.Max)
+	}
+
+	want := []api.CheckValueCount{
+		{Value: "8", Clients: 2, Failing: 2, Percent: 50},
+		{Value: "12", Clients: 1, Failing: 1, Percent: 25},
+		{Value: "14", Clients: 1, Passing: 1, Percent: 25},
+	}
+	if len(got.Values) != len(want) {
+		t.Fatalf("values = %+v, want %+v", got.Values, want)
+	}
+	for i := range want {
+		if got.Values[i] != want[i] {
+			t.Errorf("values[%d] = %+v, want %+v", i, got.Values[i], want[i])
+		}
+	}
+
+	// Text values are listed by the clients reporting them
+	text := summarizeCheckValues([]*api.ComplianceSubmission{
+		submission(false, api.QueryResult{Name: "ntlm", Status: "pass", Actual: "NTLMv2"}),
+		submission(false, api.QueryResult{Name: "ntlm", Status: "fail", Actual: "LM"}),
+		submission(false, api.QueryResult{Name: "ntlm", Status: "pass", Actual: "NTLMv2"}),
+	}, "ntlm")
+	if text.Numeric || text.Min != nil || len(text.Values) != 2 || text.Values[0].Value != "NTLMv2" || text.Values[0].Percent != 66.7 {
+		t.Errorf("text values = %+v, want NTLMv2 first without numeric statistics", text)
+	}
+}
+
+// TestCheckValuesValidation tests that the value distribution endpoint needs a policy
+func TestCheckValuesValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/checks/min_password_length/values", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+}
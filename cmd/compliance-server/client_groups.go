@@ -0,0 +1,613 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Client groups are client tags (see normalizeTags). Policies and alert
+// rules can be assigned to a group, reaching every client that carries the
+// tag now or later, as well as to single clients.
+
+// alertRuleTypes are the alert types alert rules may change: those raised
+// for a client
+var alertRuleTypes = map[string]bool{
+	alertTypeMissedRun: true,
+}
+
+// alertSeverities ranks alert severities, most severe last
+var alertSeverities = map[string]int{"info": 1, "warning": 2, "critical": 3}
+
+// maxAlertRuleGrace bounds the grace period of an alert rule
+const maxAlertRuleGrace = 7 * 24 * 60 // Minutes
+
+// normalizeGroup normalizes a group name like a client tag
+func normalizeGroup(group string) (string, error) {
+	tags, err := normalizeTags([]string{group})
+	if err != nil {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+// validateAlertRule normalizes rule's group and checks its fields
+func validateAlertRule(rule *api.AlertRule) error {
+	if !alertRuleTypes[rule.AlertType] {
+		return fmt.Errorf("alert_type must be %q", alertTypeMissedRun)
+	}
+	group, err := normalizeGroup(rule.Group)
+	if err != nil {
+		return fmt.Errorf("group: %w", err)
+	}
+	rule.Group = group
+	if rule.Severity != "" && alertSeverities[rule.Severity] == 0 {
+		return fmt.Errorf("severity must be info, warning or critical")
+	}
+	if rule.GraceMinutes < 0 || rule.GraceMinutes > maxAlertRuleGrace {
+		return fmt.Errorf("grace_minutes must be between 0 and %d", maxAlertRuleGrace)
+	}
+	return nil
+}
+
+// alertBehavior is how an alert type is raised for one client
+type alertBehavior struct {
+	muted    bool
+	severity string        // Empty for the alert type's own
+	grace    time.Duration // Zero for the configured grace
+}
+
+// resolveAlertRules combines the rules for alertType that apply to a client
+// in groups tags. Any disabled rule mutes the alert; otherwise the shortest
+// grace and the most severe severity of the enabled rules win, so a client
+// in several groups is alerted as strictly as any of them asks.
+func resolveAlertRules(rules []api.AlertRule, alertType string, tags []string) alertBehavior {
+	var behavior alertBehavior
+	for _, rule := range rules {
+		if rule.AlertType != alertType || !slices.Contains(tags, rule.Group) {
+			continue
+		}
+		if !rule.Enabled {
+			behavior.muted = true
+			continue
+		}
+		if alertSeverities[rule.Severity] > alertSeverities[behavior.severity] {
+			behavior.severity = rule.Severity
+		}
+		grace := time.Duration(rule.GraceMinutes) * time.Minute
+		if grace > 0 && (behavior.grace == 0 || grace < behavior.grace) {
+			behavior.grace = grace
+		}
+	}
+	return behavior
+}
+
+// handleListClientGroups returns every client group with its clients'
+// count, policies and alert rules (GET /api/v1/groups)
+func (s *ComplianceServer) handleListClientGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.requestDB(r).ListClientGroups()
+	if err != nil {
+		s.logger.Error("Failed to list client groups", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list client groups")
+		return
+	}
+	s.respond(w, r, api.ClientGroupListResponse{Groups: groups, Count: len(groups)}, nil)
+}
+
+// handleSetGroupPolicies replaces the policies assigned to a client group
+// (PUT /api/v1/groups/{group}/policies)
+func (s *ComplianceServer) handleSetGroupPolicies(w http.ResponseWriter, r *http.Request) {
+	group, err := normalizeGroup(r.PathValue("group"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	policyIDs, ok := s.decodePolicyAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := s.requestDB(r).GroupPolicies(group)
+	if err != nil {
+		s.logger.Error("Failed to get group policies", "error", err, "group", group)
+		s.sendError(w, http.StatusInternalServerError, "Failed to assign policies")
+		return
+	}
+	if err := s.requestDB(r).SetGroupPolicies(group, policyIDs, s.auditActor(r)); err != nil {
+		s.logger.Error("Failed to set group policies", "error", err, "group", group)
+		s.sendError(w, http.StatusInternalServerError, "Failed to assign policies")
+		return
+	}
+
+	s.logger.Info("Group policies updated", "group", group, "policies", policyIDs)
+	noteAdminChange(r, group, map[string][]string{"policies": before}, map[string][]string{"policies": policyIDs})
+	s.respond(w, r, api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Group %s has %d policy(ies)", group, len(policyIDs)),
+	}, nil)
+}
+
+// handleGetClientPolicies lists the policies assigned to a client, directly
+// and through its groups (GET /api/v1/clients/{client_id}/policies)
+func (s *ComplianceServer) handleGetClientPolicies(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+	if _, err := s.scopedDB(r).GetClient(clientID); err != nil {
+		s.sendError(w, http.StatusNotFound, "Client not found")
+		return
+	}
+
+	assignments, err := s.requestDB(r).ClientPolicyAssignments(clientID)
+	if err != nil {
+		s.logger.Error("Failed to get client policies", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get client policies")
+		return
+	}
+	s.respond(w, r, api.ClientPoliciesResponse{ClientID: clientID, Policies: assignments}, nil)
+}
+
+// handleSetClientPolicies replaces the policies assigned to a single client;
+// those it gets through its groups are unchanged
+// (PUT /api/v1/clients/{client_id}/policies)
+func (s *ComplianceServer) handleSetClientPolicies(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+	policyIDs, ok := s.decodePolicyAssignment(w, r)
+	if !ok {
+		return
+	}
+
+	var before []string
+	if assignments, err := s.requestDB(r).ClientPolicyAssignments(clientID); err == nil {
+		for _, assignment := range assignments {
+			if assignment.Group == "" {
+				before = append(before, assignment.PolicyID)
+			}
+		}
+	}
+
+	if err := s.requestDB(r).SetClientPolicies(clientID, policyIDs, s.auditActor(r)); err != nil {
+		if err.Error() == "client not found" {
+			s.sendError(w, http.StatusNotFound, "Client not found")
+			return
+		}
+		s.logger.Error("Failed to set client policies", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to assign policies")
+		return
+	}
+
+	s.logger.Info("Client policies updated", "client_id", clientID, "policies", policyIDs)
+	noteAdminChange(r, clientID, map[string][]string{"policies": before}, map[string][]string{"policies": policyIDs})
+	s.respond(w, r, api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Client %s has %d policy(ies) assigned directly", clientID, len(policyIDs)),
+	}, nil)
+}
+
+// decodePolicyAssignment reads a PolicyAssignmentRequest and checks that
+// its policies exist, answering the request when it is invalid. The IDs are
+// returned de-duplicated and sorted.
+func (s *ComplianceServer) decodePolicyAssignment(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var request api.PolicyAssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	seen := make(map[string]bool, len(request.PolicyIDs))
+	policyIDs := []string{}
+	for _, policyID := range request.PolicyIDs {
+		policyID = strings.TrimSpace(policyID)
+		if policyID == "" || seen[policyID] {
+			continue
+		}
+		seen[policyID] = true
+		policyIDs = append(policyIDs, policyID)
+	}
+	sort.Strings(policyIDs)
+
+	missing, err := s.requestDB(r).MissingPolicies(policyIDs)
+	if err != nil {
+		s.logger.Error("Failed to check policies", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to assign policies")
+		return nil, false
+	}
+	if len(missing) > 0 {
+		s.sendError(w, http.StatusBadRequest, "Unknown policy: "+strings.Join(missing, ", "))
+		return nil, false
+	}
+	return policyIDs, true
+}
+
+// handleListAlertRules returns every alert rule (GET /api/v1/alert-rules)
+func (s *ComplianceServer) handleListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.requestDB(r).ListAlertRules()
+	if err != nil {
+		s.logger.Error("Failed to list alert rules", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list alert rules")
+		return
+	}
+	if rules == nil {
+		rules = []api.AlertRule{}
+	}
+	s.respond(w, r, rules, nil)
+}
+
+// handleSaveAlertRule creates the alert rule of a group and alert type, or
+// replaces it (POST /api/v1/alert-rules)
+func (s *ComplianceServer) handleSaveAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule api.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateAlertRule(&rule); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rule.CreatedBy = s.auditActor(r)
+
+	if err := s.requestDB(r).SaveAlertRule(&rule); err != nil {
+		s.logger.Error("Failed to save alert rule", "error", err, "group", rule.Group)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save alert rule")
+		return
+	}
+
+	s.logger.Info("Alert rule saved", "id", rule.ID, "alert_type", rule.AlertType, "group", rule.Group, "enabled", rule.Enabled)
+	noteAdminChange(r, rule.Group, nil, rule)
+	s.respond(w, r, rule, nil)
+}
+
+// handleDeleteAlertRule deletes an alert rule; its group's clients are
+// alerted as configured again (DELETE /api/v1/alert-rules/{rule_id})
+func (s *ComplianceServer) handleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("rule_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid alert rule ID")
+		return
+	}
+
+	rule, err := s.requestDB(r).DeleteAlertRule(id)
+	if err != nil {
+		if err.Error() == "alert rule not found" {
+			s.sendError(w, http.StatusNotFound, "Alert rule not found")
+			return
+		}
+		s.logger.Error("Failed to delete alert rule", "error", err, "id", id)
+		s.sendError(w, http.StatusInternalServerError, "Failed to delete alert rule")
+		return
+	}
+
+	s.logger.Info("Alert rule deleted", "id", id, "alert_type", rule.AlertType, "group", rule.Group)
+	noteAdminChange(r, rule.Group, rule, nil)
+	s.respond(w, r, api.StatusResponse{Status: "success", Message: "Alert rule deleted"}, nil)
+}
+
+// ListClientGroups returns every tag that clients carry or that policies or
+// alert rules are assigned to, in order
+func (d *Database) ListClientGroups() ([]api.ClientGroup, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT tag, COUNT(client_id)
+		FROM (
+			SELECT tag, client_id FROM client_tags
+			UNION ALL SELECT tag, NULL FROM group_policies
+			UNION ALL SELECT tag, NULL FROM alert_rules
+		) g
+		GROUP BY tag
+		ORDER BY tag
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []api.ClientGroup{}
+	index := make(map[string]int)
+	for rows.Next() {
+		group := api.ClientGroup{Policies: []string{}, AlertRules: []api.AlertRule{}}
+		if err := rows.Scan(&group.Tag, &group.Clients); err != nil {
+			return nil, fmt.Errorf("failed to scan client group: %w", err)
+		}
+		index[group.Tag] = len(groups)
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	policies, err := d.db.Query(safesql.New(`SELECT tag, policy_id FROM group_policies ORDER BY tag, policy_id`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group policies: %w", err)
+	}
+	defer policies.Close()
+	for policies.Next() {
+		var tag, policyID string
+		if err := policies.Scan(&tag, &policyID); err != nil {
+			return nil, fmt.Errorf("failed to scan group policy: %w", err)
+		}
+		if i, ok := index[tag]; ok {
+			groups[i].Policies = append(groups[i].Policies, policyID)
+		}
+	}
+	if err := policies.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := d.ListAlertRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if i, ok := index[rule.Group]; ok {
+			groups[i].AlertRules = append(groups[i].AlertRules, rule)
+		}
+	}
+	return groups, nil
+}
+
+// GroupPolicies returns the IDs of the policies assigned to a group
+func (d *Database) GroupPolicies(tag string) ([]string, error) {
+	rows, err := d.db.Query(safesql.New(`SELECT policy_id FROM group_policies WHERE tag = $1 ORDER BY policy_id`, tag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group policies: %w", err)
+	}
+	defer rows.Close()
+
+	policyIDs := []string{}
+	for rows.Next() {
+		var policyID string
+		if err := rows.Scan(&policyID); err != nil {
+			return nil, fmt.Errorf("failed to scan group policy: %w", err)
+		}
+		policyIDs = append(policyIDs, policyID)
+	}
+	return policyIDs, rows.Err()
+}
+
+// MissingPolicies returns the policy IDs that name no policy
+func (d *Database) MissingPolicies(policyIDs []string) ([]string, error) {
+	if len(policyIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := d.db.Query(safesql.New(`SELECT policy_id FROM policies WHERE policy_id = ANY($1)`, pq.Array(policyIDs)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policies: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool, len(policyIDs))
+	for rows.Next() {
+		var policyID string
+		if err := rows.Scan(&policyID); err != nil {
+			return nil, fmt.Errorf("failed to scan policy: %w", err)
+		}
+		found[policyID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, policyID := range policyIDs {
+		if !found[policyID] {
+			missing = append(missing, policyID)
+		}
+	}
+	return missing, nil
+}
+
+// SetGroupPolicies replaces the policies assigned to a group. Policies that
+// stay assigned keep when and by whom they were first assigned.
+func (d *Database) SetGroupPolicies(tag string, policyIDs []string, assignedBy string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin policy assignment: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(safesql.New(`DELETE FROM group_policies WHERE tag = $1 AND NOT (policy_id = ANY($2))`, tag, pq.Array(policyIDs)))
+	if err != nil {
+		return fmt.Errorf("failed to remove group policies: %w", err)
+	}
+	for _, policyID := range policyIDs {
+		_, err := tx.Exec(safesql.New(`
+			INSERT INTO group_policies (tag, policy_id, assigned_by, assigned_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+			ON CONFLICT (tag, policy_id) DO NOTHING
+		`, tag, policyID, assignedBy))
+		if err != nil {
+			return fmt.Errorf("failed to assign group policy: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit policy assignment: %w", err)
+	}
+	return nil
+}
+
+// SetClientPolicies replaces the policies assigned to a single client.
+// Policies that stay assigned keep when and by whom they were first
+// assigned.
+func (d *Database) SetClientPolicies(clientID string, policyIDs []string, assignedBy string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin policy assignment: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(safesql.New(`SELECT EXISTS (SELECT 1 FROM clients WHERE client_id = $1)`, clientID)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query client: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("client not found")
+	}
+
+	_, err = tx.Exec(safesql.New(`DELETE FROM client_policies WHERE client_id = $1 AND NOT (policy_id = ANY($2))`, clientID, pq.Array(policyIDs)))
+	if err != nil {
+		return fmt.Errorf("failed to remove client policies: %w", err)
+	}
+	for _, policyID := range policyIDs {
+		_, err := tx.Exec(safesql.New(`
+			INSERT INTO client_policies (client_id, policy_id, assigned_by, assigned_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+			ON CONFLICT (client_id, policy_id) DO NOTHING
+		`, clientID, policyID, assignedBy))
+		if err != nil {
+			return fmt.Errorf("failed to assign client policy: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit policy assignment: %w", err)
+	}
+	return nil
+}
+
+// ClientPolicyAssignments returns the policies assigned to a client and its
+// groups, ordered by policy
+func (d *Database) ClientPolicyAssignments(clientID string) ([]api.PolicyAssignment, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT policy_id, '' AS tag, assigned_by, assigned_at
+		FROM client_policies
+		WHERE client_id = $1
+		UNION ALL
+		SELECT g.policy_id, g.tag, g.assigned_by, g.assigned_at
+		FROM group_policies g
+		JOIN client_tags t ON t.tag = g.tag
+		WHERE t.client_id = $1
+		ORDER BY policy_id, tag
+	`, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client policies: %w", err)
+	}
+	defer rows.Close()
+
+	assignments := []api.PolicyAssignment{}
+	for rows.Next() {
+		var assignment api.PolicyAssignment
+		var assignedBy, assignedAt sql.NullString
+		if err := rows.Scan(&assignment.PolicyID, &assignment.Group, &assignedBy, &assignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan client policy: %w", err)
+		}
+		assignment.AssignedBy = assignedBy.String
+		assignment.AssignedAt = assignedAt.String
+		assignments = append(assignments, assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// AssignedPolicies returns the IDs of the active policies a client is to
+// run: those assigned to it and to its groups
+func (d *Database) AssignedPolicies(clientID string) ([]string, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT p.policy_id
+		FROM policies p
+		WHERE p.status = 'active' AND p.policy_id IN (
+			SELECT policy_id FROM client_policies WHERE client_id = $1
+			UNION
+			SELECT g.policy_id FROM group_policies g
+			JOIN client_tags t ON t.tag = g.tag
+			WHERE t.client_id = $1
+		)
+		ORDER BY p.policy_id
+	`, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assigned policies: %w", err)
+	}
+	defer rows.Close()
+
+	policyIDs := []string{}
+	for rows.Next() {
+		var policyID string
+		if err := rows.Scan(&policyID); err != nil {
+			return nil, fmt.Errorf("failed to scan assigned policy: %w", err)
+		}
+		policyIDs = append(policyIDs, policyID)
+	}
+	return policyIDs, rows.Err()
+}
+
+// alertRuleColumns are the alert_rules columns scanAlertRule reads
+const alertRuleColumns = `id, alert_type, tag, enabled, severity, grace_minutes, created_by, created_at`
+
+// scanAlertRule scans a row of alertRuleColumns
+func scanAlertRule(scan func(...any) error) (*api.AlertRule, error) {
+	var rule api.AlertRule
+	var severity, createdBy, createdAt sql.NullString
+	var grace sql.NullInt64
+	if err := scan(&rule.ID, &rule.AlertType, &rule.Group, &rule.Enabled, &severity, &grace, &createdBy, &createdAt); err != nil {
+		return nil, err
+	}
+	rule.Severity = severity.String
+	rule.GraceMinutes = int(grace.Int64)
+	rule.CreatedBy = createdBy.String
+	rule.CreatedAt = createdAt.String
+	return &rule, nil
+}
+
+// ListAlertRules returns every alert rule, by group and alert type
+func (d *Database) ListAlertRules() ([]api.AlertRule, error) {
+	rows, err := d.db.Query(safesql.New(`SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY tag, alert_type`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []api.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveAlertRule stores rule, replacing the rule of the same group and alert
+// type, and sets its ID and creation time
+func (d *Database) SaveAlertRule(rule *api.AlertRule) error {
+	const query = `
+		INSERT INTO alert_rules (alert_type, tag, enabled, severity, grace_minutes, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		ON CONFLICT (alert_type, tag) DO UPDATE SET
+			enabled = EXCLUDED.enabled, severity = EXCLUDED.severity, grace_minutes = EXCLUDED.grace_minutes,
+			created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at
+		RETURNING id, created_at
+	`
+
+	var grace sql.NullInt64
+	if rule.GraceMinutes > 0 {
+		grace = sql.NullInt64{Int64: int64(rule.GraceMinutes), Valid: true}
+	}
+	err := d.db.QueryRow(safesql.New(query, rule.AlertType, rule.Group, rule.Enabled, nullIfEmpty(rule.Severity), grace, nullIfEmpty(rule.CreatedBy))).
+		Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteAlertRule deletes an alert rule and returns it
+func (d *Database) DeleteAlertRule(id int) (*api.AlertRule, error) {
+	rule, err := scanAlertRule(d.db.QueryRow(safesql.New(`DELETE FROM alert_rules WHERE id = $1 RETURNING `+alertRuleColumns, id)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	return rule, nil
+}
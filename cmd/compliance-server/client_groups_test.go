@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestValidateAlertRule tests alert rule validation and group normalization
+func TestValidateAlertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    api.AlertRule
+		wantErr string
+	}{
+		{"valid", api.AlertRule{AlertType: "missed_run", Group: " POS-Terminals ", Severity: "critical", GraceMinutes: 30}, ""},
+		{"mute", api.AlertRule{AlertType: "missed_run", Group: "lab"}, ""},
+		{"unknown type", api.AlertRule{AlertType: "rollout_halted", Group: "pos"}, "alert_type"},
+		{"no group", api.AlertRule{AlertType: "missed_run"}, "group"},
+		{"bad severity", api.AlertRule{AlertType: "missed_run", Group: "pos", Severity: "urgent"}, "severity"},
+		{"negative grace", api.AlertRule{AlertType: "missed_run", Group: "pos", GraceMinutes: -1}, "grace_minutes"},
+		{"grace over a week", api.AlertRule{AlertType: "missed_run", Group: "pos", GraceMinutes: maxAlertRuleGrace + 1}, "grace_minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertRule(&tt.rule)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateAlertRule() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateAlertRule() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	rule := api.AlertRule{AlertType: "missed_run", Group: " POS-Terminals "}
+	if err := validateAlertRule(&rule); err != nil || rule.Group != "pos-terminals" {
+		t.Errorf("group = %q, %v; want pos-terminals", rule.Group, err)
+	}
+}
+
+// TestResolveAlertRules tests how the rules of a client's groups combine
+func TestResolveAlertRules(t *testing.T) {
+	rules := []api.AlertRule{
+		{AlertType: "missed_run", Group: "domain-controllers", Enabled: true, Severity: "critical", GraceMinutes: 60},
+		{AlertType: "missed_run", Group: "pos", Enabled: true, Severity: "info", GraceMinutes: 240},
+		{AlertType: "missed_run", Group: "datacenter", Enabled: true, GraceMinutes: 30},
+		{AlertType: "missed_run", Group: "lab", Enabled: false},
+		{AlertType: "other", Group: "pos", Enabled: false},
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		want alertBehavior
+	}{
+		{"no groups", nil, alertBehavior{}},
+		{"group without rules", []string{"kiosk"}, alertBehavior{}},
+		{"one rule", []string{"pos"}, alertBehavior{severity: "info", grace: 4 * time.Hour}},
+		{"strictest wins", []string{"datacenter", "domain-controllers", "pos"}, alertBehavior{severity: "critical", grace: 30 * time.Minute}},
+		{"rule without severity", []string{"datacenter"}, alertBehavior{grace: 30 * time.Minute}},
+		{"muted", []string{"lab", "domain-controllers"}, alertBehavior{muted: true, severity: "critical", grace: time.Hour}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveAlertRules(rules, "missed_run", tt.tags); got != tt.want {
+				t.Errorf("resolveAlertRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClientGroupRequestValidation tests the 400 responses of the group
+// endpoints, which are returned before the database is used
+func TestClientGroupRequestValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"invalid group", "PUT", "/api/v1/groups/a%20b/policies", `{"policy_ids":["cis-l1"]}`},
+		{"assignment not JSON", "PUT", "/api/v1/groups/pos/policies", `cis-l1`},
+		{"client assignment not JSON", "PUT", "/api/v1/clients/client-1/policies", `cis-l1`},
+		{"alert rule type", "POST", "/api/v1/alert-rules", `{"alert_type":"offline","group":"pos"}`},
+		{"alert rule not JSON", "POST", "/api/v1/alert-rules", `rule`},
+		{"alert rule ID", "DELETE", "/api/v1/alert-rules/first", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// Client identity matching modes (clients.identity_match). Every mode but
+// off matches clients by machine fingerprint first.
+const (
+	identityMatchOff         = "off"          // Never merge automatically
+	identityMatchFingerprint = "fingerprint"  // Same machine fingerprint
+	identityMatchHostnameMAC = "hostname_mac" // Same fingerprint, or same hostname and MAC address
+	identityMatchHostname    = "hostname"     // Same fingerprint, or same hostname
+)
+
+// normalizeMAC returns a MAC address in lower case without separators, so
+// "00-1A-2B-3C-4D-5E" and "00:1a:2b:3c:4d:5e" compare equal
+func normalizeMAC(mac string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '-', '.', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(mac))
+}
+
+// findIdentityMatch returns the client among candidates (most recently seen
+// first) that client replaces, or "" if there is none. A candidate with the
+// same fingerprint matches even under another hostname. Otherwise, under
+// hostname_mac a candidate with the same hostname must have reported the
+// same MAC address; clients that never reported one never match.
+func findIdentityMatch(mode string, client clientIdentity, candidates []clientIdentity) string {
+	if mode == "" || mode == identityMatchOff {
+		return ""
+	}
+
+	if client.Fingerprint != "" {
+		for _, candidate := range candidates {
+			if candidate.ClientID != client.ClientID && candidate.Fingerprint == client.Fingerprint {
+				return candidate.ClientID
+			}
+		}
+	}
+
+	mac := normalizeMAC(client.MacAddress)
+	for _, candidate := range candidates {
+		if candidate.ClientID == client.ClientID || !strings.EqualFold(candidate.Hostname, client.Hostname) {
+			continue
+		}
+		// A different fingerprint is a different machine reusing the hostname
+		if client.Fingerprint != "" && candidate.Fingerprint != "" {
+			continue
+		}
+		switch mode {
+		case identityMatchHostname:
+			return candidate.ClientID
+		case identityMatchHostnameMAC:
+			if mac != "" && normalizeMAC(candidate.MacAddress) == mac {
+				return candidate.ClientID
+			}
+		}
+	}
+	return ""
+}
+
+// reconcileClientIdentity merges an earlier client record of the same
+// machine into clientID when clients.identity_match recognizes one, so a
+// reimaged or renamed machine that reports under a new client ID keeps its
+// history. Failures are logged and never fail the request that triggered
+// the check.
+func (s *ComplianceServer) reconcileClientIdentity(clientID, hostname string, systemInfo *api.SystemInfo) {
+	mode := s.config().Clients.IdentityMatch
+	if mode == "" || mode == identityMatchOff {
+		return
+	}
+
+	client := clientIdentity{ClientID: clientID, Hostname: hostname}
+	if systemInfo != nil {
+		client.MacAddress = systemInfo.MacAddress
+		client.Fingerprint = systemInfo.Fingerprint
+	}
+	if mode == identityMatchFingerprint && client.Fingerprint == "" {
+		return
+	}
+
+	candidates, err := s.db.ListClientIdentities(hostname, client.Fingerprint)
+	if err != nil {
+		s.logger.Warn("Failed to look up clients for identity matching", "error", err, "client_id", clientID)
+		return
+	}
+
+	previousID := findIdentityMatch(mode, client, candidates)
+	if previousID == "" {
+		return
+	}
+
+	merged, err := s.db.MergeClients(clientID, previousID)
+	if err != nil {
+		s.logger.Error("Failed to merge duplicate client", "error", err, "client_id", clientID, "previous_client_id", previousID)
+		return
+	}
+	s.logger.Info("Merged previous client record into re-registered client",
+		"client_id", clientID,
+		"previous_client_id", previousID,
+		"hostname", hostname,
+		"match", mode,
+		"submissions", merged.Submissions,
+	)
+}
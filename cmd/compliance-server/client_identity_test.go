@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFindIdentityMatch tests recognizing the previous record of a re-registered machine
+func TestFindIdentityMatch(t *testing.T) {
+	candidates := []clientIdentity{
+		{ClientID: "client-new", Hostname: "WS-01", MacAddress: "00:1a:2b:3c:4d:5e"},
+		{ClientID: "client-nic-swap", Hostname: "ws-01", MacAddress: "00:1a:2b:3c:4d:ff"},
+		{ClientID: "client-old", Hostname: "WS-01", MacAddress: "00-1A-2B-3C-4D-5E"},
+		{ClientID: "client-no-mac", Hostname: "WS-01"},
+	}
+	fingerprinted := []clientIdentity{
+		{ClientID: "client-other-machine", Hostname: "WS-01", MacAddress: "00:1a:2b:3c:4d:5e", Fingerprint: "v1:bbb"},
+		{ClientID: "client-renamed", Hostname: "LAPTOP-7", Fingerprint: "v1:aaa"},
+	}
+
+	newClient := clientIdentity{ClientID: "client-new", Hostname: "WS-01", MacAddress: "00:1a:2b:3c:4d:5e"}
+	otherMAC := newClient
+	otherMAC.MacAddress = "001A.2B3C.4D5E"
+	noMAC := newClient
+	noMAC.MacAddress = ""
+	changedMAC := newClient
+	changedMAC.MacAddress = "aa:bb:cc:dd:ee:ff"
+	withFingerprint := newClient
+	withFingerprint.Fingerprint = "v1:aaa"
+	unknownFingerprint := newClient
+	unknownFingerprint.Fingerprint = "v1:ccc"
+
+	tests := []struct {
+		name       string
+		mode       string
+		client     clientIdentity
+		candidates []clientIdentity
+		want       string
+	}{
+		{"off", identityMatchOff, withFingerprint, fingerprinted, ""},
+		{"hostname and mac", identityMatchHostnameMAC, newClient, candidates, "client-old"},
+		{"mac separators and case", identityMatchHostnameMAC, otherMAC, candidates, "client-old"},
+		{"no mac reported", identityMatchHostnameMAC, noMAC, candidates, ""},
+		{"mac differs", identityMatchHostnameMAC, changedMAC, candidates, ""},
+		{"hostname takes most recent", identityMatchHostname, noMAC, candidates, "client-nic-swap"},
+		{"only itself", identityMatchHostname, newClient, candidates[:1], ""},
+		{"no candidates", identityMatchHostname, newClient, nil, ""},
+		{"fingerprint across hostnames", identityMatchFingerprint, withFingerprint, fingerprinted, "client-renamed"},
+		{"fingerprint before hostname", identityMatchHostname, withFingerprint, fingerprinted, "client-renamed"},
+		{"fingerprint mode ignores hostname", identityMatchFingerprint, newClient, candidates, ""},
+		{"different fingerprint same hostname and mac", identityMatchHostnameMAC, unknownFingerprint, fingerprinted, ""},
+		{"fingerprint falls back to hostname", identityMatchHostnameMAC, unknownFingerprint, candidates, "client-old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findIdentityMatch(tt.mode, tt.client, tt.candidates); got != tt.want {
+				t.Errorf("findIdentityMatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeClientValidation tests that malformed merge requests are rejected
+// before any client lookup
+func TestMergeClientValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"no source", `{}`},
+		{"merge into itself", `{"source_client_id":"client-1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/clients/merge/client-1", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
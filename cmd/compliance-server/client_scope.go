@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// roleOperator is the role of users limited to the clients in their scope.
+// Every other role sees all clients.
+const roleOperator = "operator"
+
+// validTag matches a client tag after normalization
+var validTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// normalizeTags lower-cases, de-duplicates and sorts tags, rejecting any
+// that are not made of letters, digits, dots, dashes and underscores
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !validTag.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q: use up to 64 letters, digits, '.', '-' or '_'", tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// normalizeScope returns a scope with lower-cased, de-duplicated and sorted
+// organizations and tags
+func normalizeScope(scope api.ClientScope) (api.ClientScope, error) {
+	tags, err := normalizeTags(scope.Tags)
+	if err != nil {
+		return api.ClientScope{}, err
+	}
+
+	seen := make(map[string]bool, len(scope.Orgs))
+	orgs := []string{}
+	for _, org := range scope.Orgs {
+		org = strings.ToLower(strings.TrimSpace(org))
+		if org == "" {
+			return api.ClientScope{}, fmt.Errorf("organization names cannot be empty")
+		}
+		if !seen[org] {
+			seen[org] = true
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Strings(orgs)
+
+	return api.ClientScope{Orgs: orgs, Tags: tags}, nil
+}
+
+// validateUserScope checks that a scope is given for operators, and only
+// for them, and returns it normalized (nil for other roles)
+func validateUserScope(role string, scope *api.ClientScope) (*api.ClientScope, error) {
+	if role != roleOperator {
+		if scope != nil && (len(scope.Orgs) > 0 || len(scope.Tags) > 0) {
+			return nil, fmt.Errorf("only operators have a client scope")
+		}
+		return nil, nil
+	}
+
+	if scope == nil || (len(scope.Orgs) == 0 && len(scope.Tags) == 0) {
+		return nil, fmt.Errorf("operators need at least one organization or tag in their scope")
+	}
+	normalized, err := normalizeScope(*scope)
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// userScope returns the clients a user may see, or nil if the user's role
+// sees every client. An operator always gets a scope, so an operator whose
+// scope was emptied sees nothing rather than everything.
+func userScope(user *User) *api.ClientScope {
+	if user.Role != roleOperator {
+		return nil
+	}
+	scope := user.Scope
+	return &scope
+}
+
+// scopeContextKey keys the client scope of the authenticated user in a
+// request context
+type scopeContextKey struct{}
+
+// withClientScope returns r carrying the client scope of the user making it
+func withClientScope(r *http.Request, scope *api.ClientScope) *http.Request {
+	if scope == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), scopeContextKey{}, scope))
+}
+
+// requestScope returns the client scope of the user making r, or nil when
+// the request may see every client (admins, other roles, API keys)
+func requestScope(r *http.Request) *api.ClientScope {
+	scope, _ := r.Context().Value(scopeContextKey{}).(*api.ClientScope)
+	return scope
+}
+
+// scopedDB returns the database as seen by the user making r. Handlers that
+// read or change client data use it instead of s.db so that operators only
+// reach the clients in their scope.
+func (s *ComplianceServer) scopedDB(r *http.Request) *Database {
+	return s.requestDB(r).WithScope(requestScope(r))
+}
+
+// requestDB returns the database with statements canceled when r is
+// canceled or times out (see timeoutMiddleware)
+func (s *ComplianceServer) requestDB(r *http.Request) *Database {
+	return s.db.WithContext(r.Context())
+}
+
+// requireUnscoped rejects operators. It guards endpoints that act on the
+// whole server (users, API keys, settings, policies) or that only agents
+// call.
+func (s *ComplianceServer) requireUnscoped(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requestScope(r) != nil {
+			s.sendError(w, http.StatusForbidden, "Operators cannot use this endpoint")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// WithScope returns a view of the database limited to the clients in scope.
+// A nil scope returns d unchanged.
+func (d *Database) WithScope(scope *api.ClientScope) *Database {
+	if scope == nil {
+		return d
+	}
+	scoped := *d
+	scoped.scope = scope
+	return &scoped
+}
+
+// scopeCondition returns a SQL condition limiting column (a client ID) to the
+// clients in the database view's scope. Unscoped views get a condition that
+// is always true.
+func (d *Database) scopeCondition(column safesql.Query) safesql.Query {
+	if d.scope == nil {
+		return safesql.New("TRUE")
+	}
+
+	orgs := make([]string, len(d.scope.Orgs))
+	for i, org := range d.scope.Orgs {
+		orgs[i] = strings.ToLower(org)
+	}
+	tags := append([]string{}, d.scope.Tags...)
+	return column.Append(` IN (
+			SELECT client_id FROM clients WHERE LOWER(domain) = ANY($1)
+			UNION
+			SELECT client_id FROM client_tags WHERE tag = ANY($2)
+		)`, pq.Array(orgs), pq.Array(tags))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// TestNormalizeTags tests tag clean-up and validation
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{"sorted and lower-cased", []string{"POS-Terminals", " emea ", "dc_01"}, []string{"dc_01", "emea", "pos-terminals"}, false},
+		{"duplicates removed", []string{"emea", "EMEA"}, []string{"emea"}, false},
+		{"none", nil, []string{}, false},
+		{"empty tag", []string{"emea", " "}, nil, true},
+		{"comma", []string{"emea,apac"}, nil, true},
+		{"too long", []string{strings.Repeat("a", 65)}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateUserScope tests that scopes are required for operators and
+// refused for other roles
+func TestValidateUserScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		scope   *api.ClientScope
+		want    *api.ClientScope
+		wantErr bool
+	}{
+		{"operator", roleOperator, &api.ClientScope{Orgs: []string{"EMEA.example.com"}, Tags: []string{"POS"}},
+			&api.ClientScope{Orgs: []string{"emea.example.com"}, Tags: []string{"pos"}}, false},
+		{"operator tags only", roleOperator, &api.ClientScope{Tags: []string{"pos"}},
+			&api.ClientScope{Orgs: []string{}, Tags: []string{"pos"}}, false},
+		{"operator without scope", roleOperator, nil, nil, true},
+		{"operator with empty scope", roleOperator, &api.ClientScope{}, nil, true},
+		{"operator with blank org", roleOperator, &api.ClientScope{Orgs: []string{" "}}, nil, true},
+		{"admin", "admin", nil, nil, false},
+		{"viewer with empty scope", "viewer", &api.ClientScope{}, nil, false},
+		{"viewer with scope", "viewer", &api.ClientScope{Tags: []string{"pos"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateUserScope(tt.role, tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateUserScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateUserScope() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUserScope tests that only operators are scoped, and that an operator
+// with an empty scope sees nothing rather than everything
+func TestUserScope(t *testing.T) {
+	if scope := userScope(&User{Role: "admin", Scope: api.ClientScope{Tags: []string{"pos"}}}); scope != nil {
+		t.Errorf("userScope(admin) = %+v, want nil", scope)
+	}
+	if scope := userScope(&User{Role: roleOperator}); scope == nil {
+		t.Error("userScope(operator without scope) = nil, want an empty scope")
+	}
+}
+
+// TestScopeCondition tests the SQL condition limiting queries to a scope
+func TestScopeCondition(t *testing.T) {
+	d := &Database{}
+
+	condition := d.scopeCondition(safesql.New("client_id"))
+	if condition.String() != "TRUE" || condition.Args() != nil {
+		t.Errorf("unscoped scopeCondition() = %q, %v, want TRUE without arguments", condition, condition.Args())
+	}
+
+	if d.WithScope(nil) != d {
+		t.Error("WithScope(nil) should return the database unchanged")
+	}
+
+	scoped := d.WithScope(&api.ClientScope{Orgs: []string{"EMEA.example.com"}, Tags: []string{"pos"}})
+	if d.scope != nil {
+		t.Fatal("WithScope() modified the original database")
+	}
+	condition = safesql.New("SELECT 1 WHERE a = $1 AND ", "a").AppendQuery(scoped.scopeCondition(safesql.New("s.client_id")))
+	for _, want := range []string{"s.client_id IN (", "LOWER(domain) = ANY($2)", "tag = ANY($3)"} {
+		if !strings.Contains(condition.String(), want) {
+			t.Errorf("scopeCondition() = %q, want it to contain %q", condition, want)
+		}
+	}
+	args := condition.Args()[1:]
+	if len(args) != 2 {
+		t.Fatalf("scopeCondition() returned %d arguments, want 2", len(args))
+	}
+	if orgs, err := args[0].(driver.Valuer).Value(); err != nil || orgs != `{"emea.example.com"}` {
+		t.Errorf("organization argument = %v, %v, want lower-cased array", orgs, err)
+	}
+}
+
+// TestOperatorRoutes tests that operators are refused server-wide and agent
+// endpoints before they run, and reach client endpoints
+func TestOperatorRoutes(t *testing.T) {
+	handler := newTestServer().routeHandler()
+	operator := &api.ClientScope{Tags: []string{"pos"}}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"POST", "/api/v1/users/create", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/users/scope", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/users/team", `{}`, http.StatusForbidden},
+		{"GET", "/api/v1/apikeys", "", http.StatusForbidden},
+		{"POST", "/api/v1/settings/config/update", `{}`, http.StatusForbidden},
+		{"PUT", "/api/v1/policies/policy-1", `{}`, http.StatusForbidden},
+		{"PUT", "/api/v1/policies/policy-1/owner", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/clients/tags/client-1", `{"tags":["pos"]}`, http.StatusForbidden},
+		{"PUT", "/api/v1/clients/client-1/policies", `{"policy_ids":[]}`, http.StatusForbidden},
+		{"GET", "/api/v1/groups", "", http.StatusForbidden},
+		{"PUT", "/api/v1/groups/pos/policies", `{"policy_ids":[]}`, http.StatusForbidden},
+		{"POST", "/api/v1/alert-rules", `{}`, http.StatusForbidden},
+		{"PUT", "/api/v1/notification-templates/invitation", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/compliance/submit", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/clients/heartbeat", `{}`, http.StatusForbidden},
+		{"POST", "/api/v1/clients/merge/client-1", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, withClientScope(r, operator))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestScopeRequestValidation tests the 400 responses of the tag and scope
+// endpoints, which are returned before the database is used
+func TestScopeRequestValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"invalid tag", "/api/v1/clients/tags/client-1", `{"tags":["a b"]}`},
+		{"tags not JSON", "/api/v1/clients/tags/client-1", `tags`},
+		{"scope without username", "/api/v1/users/scope", `{"scope":{"tags":["pos"]}}`},
+		{"empty scope", "/api/v1/users/scope", `{"username":"emea-ops","scope":{}}`},
+		{"operator without scope", "/api/v1/users/create", `{"username":"emea-ops","password":"secret123","role":"operator"}`},
+		{"viewer with scope", "/api/v1/users/create", `{"username":"auditor","password":"secret123","role":"viewer","scope":{"tags":["pos"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/fileio"
+	"compliancetoolkit/pkg/policypack"
+)
+
+// initializeCommandSigning loads the key commands are signed with, creating
+// it on first start. Clients pin the public half, so the key must persist
+// across restarts; stateless servers keep it in the database.
+func (s *ComplianceServer) initializeCommandSigning() error {
+	var key ed25519.PrivateKey
+	var created bool
+	var err error
+	config := s.config()
+	location := config.Commands.SigningKeyFile
+	if config.Server.Stateless {
+		location = "database"
+		key, created, err = s.loadSharedSigningKey()
+	} else {
+		key, created, err = loadOrCreateSigningKey(config.Commands.SigningKeyFile)
+	}
+	if err != nil {
+		return err
+	}
+	s.commandKey = key
+
+	publicKey := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if created {
+		s.logger.Warn("Generated command signing key",
+			"stored_in", location,
+			"public_key", publicKey,
+			"warning", "Configure this public key on clients that accept remote commands",
+		)
+	} else {
+		s.logger.Info("Command signing key loaded", "public_key", publicKey)
+	}
+	return nil
+}
+
+// loadOrCreateSigningKey reads a PEM encoded Ed25519 private key, generating
+// and writing a new one when the file does not exist
+func loadOrCreateSigningKey(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := parseSigningKey(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid command signing key %s: %w", path, err)
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read command signing key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate command signing key: %w", err)
+	}
+
+	data, err = encodeSigningKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := fileio.WriteFile(path, data, 0600); err != nil {
+		return nil, false, fmt.Errorf("failed to write command signing key: %w", err)
+	}
+
+	return key, true, nil
+}
+
+// loadSharedSigningKey returns the signing key kept in the database for all
+// replicas. The first server to start stores the key from
+// commands.signing_key_file when that file exists, so clients keep the
+// public key they pinned, and a new key otherwise.
+func (s *ComplianceServer) loadSharedSigningKey() (ed25519.PrivateKey, bool, error) {
+	generated := false
+	value, stored, err := s.db.SharedSecret(secretCommandSigning, func() (string, error) {
+		path := s.config().Commands.SigningKeyFile
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if _, err := parseSigningKey(data); err != nil {
+				return "", fmt.Errorf("invalid command signing key %s: %w", path, err)
+			}
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read command signing key: %w", err)
+		}
+
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate command signing key: %w", err)
+		}
+		data, err = encodeSigningKey(key)
+		if err != nil {
+			return "", err
+		}
+		generated = true
+		return string(data), nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, err := parseSigningKey([]byte(value))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid command signing key in database: %w", err)
+	}
+	return key, stored && generated, nil
+}
+
+// encodeSigningKey PEM encodes an Ed25519 private key as PKCS#8
+func encodeSigningKey(key ed25519.PrivateKey) ([]byte, error) {
+	return policypack.EncodePrivateKey(key)
+}
+
+// parseSigningKey decodes a PEM encoded PKCS#8 Ed25519 private key
+func parseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	return policypack.ParsePrivateKey(data)
+}
+
+// signCommand stamps a command with its expiry and signs it for delivery
+func signCommand(key ed25519.PrivateKey, cmd *api.Command, expiresAt time.Time) error {
+	expiresAt = expiresAt.UTC().Truncate(time.Second)
+	cmd.ExpiresAt = &expiresAt
+
+	payload, err := cmd.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("failed to encode command for signing: %w", err)
+	}
+
+	cmd.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestLoadOrCreateSigningKey tests that the signing key is generated once and
+// reloaded unchanged afterwards
+func TestLoadOrCreateSigningKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs", "command_signing.key")
+
+	first, created, err := loadOrCreateSigningKey(path)
+	if err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+	if !created {
+		t.Error("first load did not report the key as created")
+	}
+
+	second, created, err := loadOrCreateSigningKey(path)
+	if err != nil {
+		t.Fatalf("second load: %v", err)
+	}
+	if created {
+		t.Error("second load generated a new key")
+	}
+	if !first.Equal(second) {
+		t.Error("reloaded key differs from the generated key")
+	}
+}
+
+// TestEncodeSigningKey tests that an encoded key, as stored in the database
+// for stateless servers, parses back to the same key
+func TestEncodeSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := encodeSigningKey(key)
+	if err != nil {
+		t.Fatalf("encodeSigningKey() error = %v", err)
+	}
+	parsed, err := parseSigningKey(data)
+	if err != nil {
+		t.Fatalf("parseSigningKey() error = %v", err)
+	}
+	if !key.Equal(parsed) {
+		t.Error("parsed key differs from the encoded key")
+	}
+}
+
+// TestSignCommand tests that signatures verify against the command as
+// delivered and fail once any signed field changes
+func TestSignCommand(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cmd *api.Command)
+		valid  bool
+	}{
+		{"unchanged", func(cmd *api.Command) {}, true},
+		{"status and timestamps are not signed", func(cmd *api.Command) {
+			cmd.Status = api.CommandStatusDelivered
+			cmd.CreatedAt = time.Now()
+		}, true},
+		{"client changed", func(cmd *api.Command) { cmd.ClientID = "client-2" }, false},
+		{"type changed", func(cmd *api.Command) { cmd.Type = api.CommandTypeScan }, false},
+		{"argument changed", func(cmd *api.Command) { cmd.Arguments[api.CommandArgURL] = "https://evil.example/agent.exe" }, false},
+		{"expiry extended", func(cmd *api.Command) {
+			later := cmd.ExpiresAt.Add(time.Hour)
+			cmd.ExpiresAt = &later
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := api.Command{
+				ID:       7,
+				ClientID: "client-1",
+				Type:     api.CommandTypeUpdateAgent,
+				Arguments: map[string]string{
+					api.CommandArgURL:     "https://updates.example/agent.exe",
+					api.CommandArgSHA256:  "00",
+					api.CommandArgVersion: "1.1.0",
+				},
+			}
+			if err := signCommand(privateKey, &cmd, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("signCommand() error = %v", err)
+			}
+
+			tt.modify(&cmd)
+
+			signature, err := base64.StdEncoding.DecodeString(cmd.Signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, err := cmd.SigningPayload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ed25519.Verify(publicKey, payload, signature); got != tt.valid {
+				t.Errorf("signature valid = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+}
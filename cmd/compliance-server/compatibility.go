@@ -0,0 +1,158 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// serverSchemaVersions are the submission schema versions the server reads
+var serverSchemaVersions = []int{api.SchemaVersion}
+
+// parseVersion parses an agent version such as "1.4.2", "v2.0" or
+// "2.1.0-rc1+build5". Build metadata is ignored; a pre-release sorts before
+// its release.
+func parseVersion(version string) (parts []int, prerelease bool, err error) {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, _, prerelease = strings.Cut(v, "-")
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false, fmt.Errorf("invalid version %q: want numbers separated by dots, e.g. 1.4.2", version)
+		}
+		parts = append(parts, n)
+	}
+	return parts, prerelease, nil
+}
+
+// compareVersions returns -1, 0 or 1 as version a is older than, the same
+// as or newer than b. Missing fields count as 0, so 1.2 equals 1.2.0.
+func compareVersions(a, b string) (int, error) {
+	partsA, preA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	partsB, preB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range max(len(partsA), len(partsB)) {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if c := cmp.Compare(x, y); c != 0 {
+			return c, nil
+		}
+	}
+	switch {
+	case preA && !preB:
+		return -1, nil
+	case !preA && preB:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// checkCompatibility answers a client's version handshake. Clients that
+// list no schema versions predate the handshake and send version 1. An
+// agent version that is missing or cannot be parsed, as with development
+// builds, is never refused.
+func checkCompatibility(settings ClientSettings, agentVersion string, schemaVersions []int) *api.Compatibility {
+	compat := &api.Compatibility{
+		Status:         api.CompatibilityOK,
+		ServerVersion:  version,
+		SchemaVersions: serverSchemaVersions,
+		MinVersion:     settings.MinVersion,
+	}
+
+	if len(schemaVersions) > 0 && !slices.ContainsFunc(schemaVersions, func(v int) bool { return slices.Contains(serverSchemaVersions, v) }) {
+		compat.Status = api.CompatibilityUnsupported
+		compat.Warnings = append(compat.Warnings, fmt.Sprintf(
+			"The client sends submission schema versions %v; this server reads %v", schemaVersions, serverSchemaVersions))
+	}
+
+	if settings.MinVersion == "" && settings.DeprecatedBelow == "" {
+		return compat
+	}
+	if _, _, err := parseVersion(agentVersion); err != nil {
+		compat.Warnings = append(compat.Warnings, fmt.Sprintf("Agent version %q is not recognized and cannot be checked", agentVersion))
+		return compat
+	}
+	if settings.MinVersion != "" {
+		if c, err := compareVersions(agentVersion, settings.MinVersion); err == nil && c < 0 {
+			compat.Status = api.CompatibilityUnsupported
+			compat.Warnings = append(compat.Warnings, fmt.Sprintf(
+				"Agent version %s is older than the minimum %s; submissions are refused until the client is upgraded", agentVersion, settings.MinVersion))
+			return compat
+		}
+	}
+	if settings.DeprecatedBelow != "" {
+		if c, err := compareVersions(agentVersion, settings.DeprecatedBelow); err == nil && c < 0 {
+			if compat.Status == api.CompatibilityOK {
+				compat.Status = api.CompatibilityDeprecated
+			}
+			compat.Warnings = append(compat.Warnings, fmt.Sprintf(
+				"Agent version %s is deprecated; upgrade to %s or later", agentVersion, settings.DeprecatedBelow))
+		}
+	}
+	return compat
+}
+
+// markCompatibility flags a client whose last reported agent version is
+// deprecated or unsupported, for the dashboard
+func markCompatibility(settings ClientSettings, client *api.ClientInfo) {
+	if client.AgentVersion == "" {
+		return
+	}
+	if compat := checkCompatibility(settings, client.AgentVersion, nil); compat.Status != api.CompatibilityOK {
+		client.Compatibility = compat.Status
+	}
+}
+
+// noteCompatibility logs a client whose version is deprecated or
+// unsupported, once per client and verdict rather than with every heartbeat
+func (s *ComplianceServer) noteCompatibility(clientID, agentVersion string, compat *api.Compatibility) {
+	if compat.Status == api.CompatibilityOK {
+		s.compatibilityLogged.Delete(clientID)
+		return
+	}
+	key := compat.Status + " " + agentVersion
+	if previous, loaded := s.compatibilityLogged.Swap(clientID, key); loaded && previous == key {
+		return
+	}
+	s.logger.Warn("Client version is "+compat.Status,
+		"client_id", clientID,
+		"agent_version", agentVersion,
+		"min_version", compat.MinVersion,
+		"warnings", compat.Warnings,
+	)
+}
+
+// refuseUnsupportedVersion answers a submission from an agent older than
+// clients.min_version with 426 Upgrade Required and reports whether it did.
+// The version comes from the submission's telemetry.
+func (s *ComplianceServer) refuseUnsupportedVersion(w http.ResponseWriter, submission *api.ComplianceSubmission) bool {
+	settings := s.config().Clients
+	if settings.MinVersion == "" || submission.Telemetry == nil {
+		return false
+	}
+	agentVersion := submission.Telemetry.AgentVersion
+	compat := checkCompatibility(settings, agentVersion, nil)
+	if compat.Status != api.CompatibilityUnsupported {
+		return false
+	}
+	s.noteCompatibility(submission.ClientID, agentVersion, compat)
+	s.sendError(w, http.StatusUpgradeRequired, fmt.Sprintf(
+		"Agent version %s is no longer supported; upgrade the client to %s or later", agentVersion, settings.MinVersion))
+	return true
+}
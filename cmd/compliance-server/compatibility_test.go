@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestCompareVersions tests agent version ordering
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.4.2", "1.4.2", 0},
+		{"1.4.2+build7", "1.4.2", 0},
+		{"1.9.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"2.0.0-rc1", "2.0.0", -1},
+		{"2.0.0", "2.0.0-rc1", 1},
+	}
+	for _, tt := range tests {
+		got, err := compareVersions(tt.a, tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, %v; want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "dev", "1.x", "1..2", "1.-2"} {
+		if _, err := compareVersions(invalid, "1.0.0"); err == nil {
+			t.Errorf("compareVersions(%q) error = nil, want invalid version", invalid)
+		}
+	}
+}
+
+// TestCheckCompatibility tests the server's answer to a client's handshake
+func TestCheckCompatibility(t *testing.T) {
+	settings := ClientSettings{DeprecatedBelow: "1.2.0", MinVersion: "1.0.0"}
+	tests := []struct {
+		name           string
+		settings       ClientSettings
+		agentVersion   string
+		schemaVersions []int
+		want           string
+		warnings       int
+	}{
+		{"no limits", ClientSettings{}, "0.1.0", nil, api.CompatibilityOK, 0},
+		{"current", settings, "1.2.0", []int{api.SchemaVersion}, api.CompatibilityOK, 0},
+		{"deprecated", settings, "1.1.5", nil, api.CompatibilityDeprecated, 1},
+		{"below minimum", settings, "0.9.0", nil, api.CompatibilityUnsupported, 1},
+		{"development build", settings, "dev", nil, api.CompatibilityOK, 1},
+		{"unknown schema", ClientSettings{}, "1.2.0", []int{api.SchemaVersion + 1}, api.CompatibilityUnsupported, 1},
+		{"newer and current schema", ClientSettings{}, "1.2.0", []int{api.SchemaVersion, api.SchemaVersion + 1}, api.CompatibilityOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkCompatibility(tt.settings, tt.agentVersion, tt.schemaVersions)
+			if got.Status != tt.want || len(got.Warnings) != tt.warnings {
+				t.Errorf("checkCompatibility() = %s with warnings %q; want %s with %d warnings", got.Status, got.Warnings, tt.want, tt.warnings)
+			}
+			if got.ServerVersion != version || got.MinVersion != tt.settings.MinVersion {
+				t.Errorf("checkCompatibility() = %+v, want the server version and minimum", got)
+			}
+		})
+	}
+}
+
+// TestRefuseUnsupportedVersion tests that submissions from agents below
+// clients.min_version are refused with 426 Upgrade Required
+func TestRefuseUnsupportedVersion(t *testing.T) {
+	s := newTestServer()
+	s.config().Clients.MinVersion = "1.2.0"
+
+	tests := []struct {
+		name      string
+		telemetry *api.AgentTelemetry
+		refused   bool
+	}{
+		{"below minimum", &api.AgentTelemetry{AgentVersion: "1.1.0"}, true},
+		{"at minimum", &api.AgentTelemetry{AgentVersion: "1.2.0"}, false},
+		{"no telemetry", nil, false},
+		{"unparsable version", &api.AgentTelemetry{AgentVersion: "dev"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			refused := s.refuseUnsupportedVersion(rec, &api.ComplianceSubmission{ClientID: "client-1", Telemetry: tt.telemetry})
+			if refused != tt.refused {
+				t.Fatalf("refuseUnsupportedVersion() = %v, want %v", refused, tt.refused)
+			}
+			if refused && rec.Code != http.StatusUpgradeRequired {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusUpgradeRequired)
+			}
+		})
+	}
+}
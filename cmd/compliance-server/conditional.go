@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// respondCached writes data like respond, adding validators so that polling
+// clients such as the auto-refreshing dashboard can revalidate cheaply.
+//
+// The ETag is a hash of the encoded body, so it changes whenever the content
+// (or negotiated format) changes. lastModified is optional; when non-zero it is
+// sent as Last-Modified and honoured for If-Modified-Since. If-None-Match takes
+// precedence over If-Modified-Since as required by RFC 9110.
+func (s *ComplianceServer) respondCached(w http.ResponseWriter, r *http.Request, data interface{}, table csvTable, lastModified time.Time) {
+	contentType, body, ok := s.render(w, r, data, table)
+	if !ok {
+		return
+	}
+
+	etag := contentETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// contentETag returns a strong entity tag derived from the response body
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since for a GET or HEAD request
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
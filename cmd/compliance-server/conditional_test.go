@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestNotModified tests If-None-Match and If-Modified-Since evaluation
+func TestNotModified(t *testing.T) {
+	etag := contentETag([]byte(`{"total_clients":3}`))
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no validators", "GET", nil, modified, false},
+		{"matching etag", "GET", map[string]string{"If-None-Match": etag}, time.Time{}, true},
+		{"weak matching etag", "GET", map[string]string{"If-None-Match": "W/" + etag}, time.Time{}, true},
+		{"etag in list", "GET", map[string]string{"If-None-Match": `"other", ` + etag}, time.Time{}, true},
+		{"wildcard etag", "HEAD", map[string]string{"If-None-Match": "*"}, time.Time{}, true},
+		{"stale etag", "GET", map[string]string{"If-None-Match": `"stale"`}, time.Time{}, false},
+		{"etag wins over date", "GET", map[string]string{
+			"If-None-Match":     `"stale"`,
+			"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat),
+		}, modified, false},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, modified, true},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, modified, false},
+		{"date without last modified", "GET", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, time.Time{}, false},
+		{"invalid date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, modified, false},
+		{"post ignored", "POST", map[string]string{"If-None-Match": etag}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/v1/clients", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := notModified(r, etag, tt.lastModified); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+
+	"compliancetoolkit/pkg/fileio"
+	"compliancetoolkit/pkg/objectstore"
 )
 
 // ServerConfig represents the server configuration
@@ -15,6 +27,22 @@ type ServerConfig struct {
 	Auth     AuthSettings     `mapstructure:"auth"`
 	Dashboard DashboardSettings `mapstructure:"dashboard"`
 	Logging  LoggingSettings  `mapstructure:"logging"`
+	Alerts   AlertSettings    `mapstructure:"alerts"`
+	Commands CommandSettings  `mapstructure:"commands"`
+	Clients  ClientSettings   `mapstructure:"clients"`
+	Cache    CacheSettings    `mapstructure:"cache"`
+	Policies PolicySettings   `mapstructure:"policies"`
+	Sampling SamplingSettings `mapstructure:"sampling"`
+	RateLimit RateLimitSettings `mapstructure:"rate_limit"`
+	SubmissionQuota SubmissionQuotaSettings `mapstructure:"submission_quota"`
+	Notifications NotificationSettings `mapstructure:"notifications"`
+	Retention RetentionSettings `mapstructure:"retention"`
+	Metrics  MetricsSettings  `mapstructure:"metrics"`
+	Forwarding ForwardingSettings `mapstructure:"forwarding"`
+	Events   EventSettings    `mapstructure:"events"`
+	Export   ExportSettings   `mapstructure:"export"`
+	Attachments AttachmentSettings `mapstructure:"attachments"`
+	Metadata MetadataSettings `mapstructure:"metadata"`
 }
 
 // ServerSettings contains HTTP server configuration
@@ -22,8 +50,33 @@ type ServerSettings struct {
 	Host string     `mapstructure:"host"`
 	Port int        `mapstructure:"port"`
 	TLS  TLSSettings `mapstructure:"tls"`
+
+	// Listen is a host:port address that replaces host and port when set,
+	// e.g. ":8080" or "127.0.0.1:8443"
+	Listen string `mapstructure:"listen"`
+
+	// ShutdownTimeout is how long requests in flight are given to finish on
+	// SIGTERM or a service stop. Keep it below the supervisor's kill timeout
+	// (10 seconds for docker stop).
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// RequestTimeout is how long a handler may run before the request's
+	// database work is canceled and the client gets 504 Gateway Timeout.
+	// LongRequestTimeout replaces it for imports and exports.
+	RequestTimeout     time.Duration `mapstructure:"request_timeout"`
+	LongRequestTimeout time.Duration `mapstructure:"long_request_timeout"`
+
+	// Stateless keeps no state on local disk, so any number of replicas can
+	// run behind a load balancer: the command signing key is kept in the
+	// database and logs must go to stdout or stderr
+	Stateless bool `mapstructure:"stateless"`
 }
 
+// envPrefix prefixes the environment variables that override configuration
+// keys: COMPLIANCE_SERVER_PORT sets server.port, COMPLIANCE_DATABASE_HOST sets
+// database.host
+const envPrefix = "COMPLIANCE"
+
 // TLSSettings contains TLS/HTTPS configuration
 type TLSSettings struct {
 	Enabled  bool   `mapstructure:"enabled"`
@@ -40,6 +93,19 @@ type DatabaseSettings struct {
 	User     string `mapstructure:"user"`     // Database user
 	Password string `mapstructure:"password"` // Database password
 	SSLMode  string `mapstructure:"sslmode"`  // SSL mode (disable, require, verify-ca, verify-full)
+
+	// AutoMigrate applies pending schema migrations on startup. Disable it
+	// where schema changes are reviewed first; the server then refuses to
+	// start until they are applied with --migrate up.
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+
+	// Connection pool. Each submission is a few short writes, so a few dozen
+	// connections serve thousands of clients; raise max_open_conns (within the
+	// server's max_connections) if requests wait on the pool.
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // Connections open at once, busy or idle
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // Idle connections kept for reuse
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // Connections are replaced after this long
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // Idle connections are closed after this long
 }
 
 // AuthSettings contains authentication configuration
@@ -55,12 +121,76 @@ type AuthSettings struct {
 
 	RequireKey    bool     `mapstructure:"require_key"`     // Set to true to enforce authentication
 	JWT           JWTAuthSettings `mapstructure:"jwt"`       // JWT authentication settings
+	Lockout       LockoutSettings `mapstructure:"lockout"`   // Account lockout after failed logins
+	InviteTTL     time.Duration   `mapstructure:"invite_ttl"` // How long invitations of imported users stay valid
+	PasswordResetTTL time.Duration `mapstructure:"password_reset_ttl"` // How long emailed password reset links stay valid
+	// APIKeyExpiryWarning is how long before an API key expires its creator
+	// is emailed and the settings page flags it; 0 disables both
+	APIKeyExpiryWarning time.Duration `mapstructure:"api_key_expiry_warning"`
+}
+
+// LockoutSettings locks a user account after repeated failed logins
+type LockoutSettings struct {
+	MaxAttempts int           `mapstructure:"max_attempts"` // Consecutive failed logins that lock the account; 0 disables lockout
+	Duration    time.Duration `mapstructure:"duration"`     // How long a locked account refuses logins
+}
+
+// RateLimitSettings limits requests per client IP address on the endpoints
+// open to brute force and floods. With cache.backend redis the counts are
+// kept in Redis and the limits apply across replicas; otherwise each
+// replica enforces its own.
+type RateLimitSettings struct {
+	Enabled         bool `mapstructure:"enabled"`
+	LoginPerMinute  int  `mapstructure:"login_per_minute"`  // Login attempts per IP address and minute
+	SubmitPerMinute int  `mapstructure:"submit_per_minute"` // Compliance submissions per IP address and minute
+
+	// TrustForwardedFor limits by the address a reverse proxy puts last in
+	// X-Forwarded-For instead of the connection's address. Enable it only
+	// behind a proxy that sets the header, or clients can choose their own.
+	TrustForwardedFor bool `mapstructure:"trust_forwarded_for"`
+}
+
+// SubmissionQuotaSettings limits how often each client may submit and flags
+// submissions far larger than the client's last, so a misconfigured or
+// compromised agent cannot flood the database. Submissions are counted in the
+// database by client ID, so the quotas hold across replicas and restarts.
+type SubmissionQuotaSettings struct {
+	Enabled bool `mapstructure:"enabled"`
+	PerHour int  `mapstructure:"per_hour"` // Submissions per client in the last hour; 0 is unlimited
+	PerDay  int  `mapstructure:"per_day"`  // Submissions per client in the last 24 hours; 0 is unlimited
+
+	// AnomalyFactor flags a submission with at least this many times the
+	// checks of the client's previous submission of the report type; 0
+	// disables the check
+	AnomalyFactor float64 `mapstructure:"anomaly_factor"`
+}
+
+// NotificationSettings configures the email the server sends, such as the
+// invitations of imported users
+type NotificationSettings struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// BaseURL is the address users reach the dashboard at, e.g.
+	// "https://compliance.example.com"; links in email start with it
+	BaseURL string `mapstructure:"base_url"`
+
+	SMTP SMTPSettings `mapstructure:"smtp"`
+}
+
+// SMTPSettings is the mail server notifications are sent through. The
+// connection is upgraded with STARTTLS when the server offers it.
+type SMTPSettings struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"` // Empty sends without authentication
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"` // Sender address, e.g. "Compliance <compliance@example.com>"
 }
 
 // JWTAuthSettings contains JWT-specific authentication configuration
 type JWTAuthSettings struct {
 	Enabled              bool   `mapstructure:"enabled"`                // Enable JWT authentication
-	SecretKey            string `mapstructure:"secret_key"`             // Secret key for signing tokens (auto-generated if empty)
+	SecretKey            string `mapstructure:"secret_key"`             // Secret key for signing tokens (generated and kept in the database if empty)
 	AccessTokenLifetime  int    `mapstructure:"access_token_lifetime"`  // Access token lifetime in minutes (default: 15)
 	RefreshTokenLifetime int    `mapstructure:"refresh_token_lifetime"` // Refresh token lifetime in days (default: 7)
 	Issuer               string `mapstructure:"issuer"`                 // Token issuer (default: compliance-toolkit)
@@ -72,6 +202,237 @@ type DashboardSettings struct {
 	Enabled      bool   `mapstructure:"enabled"`
 	Path         string `mapstructure:"path"`          // URL path for dashboard
 	LoginMessage string `mapstructure:"login_message"` // Message displayed on login page
+
+	// Timezone is the IANA time zone timestamps are shown in for users who
+	// have not chosen one; empty shows each viewer's browser time zone
+	Timezone string `mapstructure:"timezone"`
+}
+
+// AlertSettings contains alerting configuration
+type AlertSettings struct {
+	MissedRunCheck    bool          `mapstructure:"missed_run_check"`    // Raise alerts for missed scheduled runs
+	MissedRunGrace    time.Duration `mapstructure:"missed_run_grace"`    // How late a scheduled run may be before it counts as missed
+	MissedRunInterval time.Duration `mapstructure:"missed_run_interval"` // How often schedules are evaluated
+}
+
+// CommandSettings contains configuration for commands queued for clients
+type CommandSettings struct {
+	SigningKeyFile string        `mapstructure:"signing_key_file"` // Ed25519 key commands are signed with (created if missing)
+	TTL            time.Duration `mapstructure:"ttl"`              // How long a delivered command stays valid
+}
+
+// CacheSettings selects where short-lived data, such as dashboard summaries,
+// is cached. With the redis backend replicas share one cache, and fall back
+// to their own memory while Redis is unreachable.
+type CacheSettings struct {
+	Backend    string        `mapstructure:"backend"`     // memory or redis
+	RedisURL   string        `mapstructure:"redis_url"`   // redis://[:password@]host:6379/0, for the redis backend
+	SummaryTTL time.Duration `mapstructure:"summary_ttl"` // How long a dashboard summary is reused; 0 disables caching
+}
+
+// PolicySettings controls importing signed policy packs from URLs
+type PolicySettings struct {
+	PackSources   []string `mapstructure:"pack_sources"`     // URL prefixes packs may be fetched from; empty disables import-url
+	TrustedKeys   []string `mapstructure:"trusted_keys"`     // Base64 Ed25519 keys pack signatures must verify against
+	MaxPackSizeMB int      `mapstructure:"max_pack_size_mb"` // Largest pack archive that is downloaded
+}
+
+// SamplingSettings limits how many submissions keep their full evidence, for
+// fleets too large to store every check detail. Submissions over the daily
+// sample keep their summary and each check's status; their evidence records
+// and check details are dropped.
+type SamplingSettings struct {
+	Enabled    bool `mapstructure:"enabled"`
+	FullPerDay int  `mapstructure:"full_per_day"` // Submissions per report type and day stored in full
+}
+
+// MetricsSettings controls the Prometheus endpoint at /metrics
+type MetricsSettings struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Token   string `mapstructure:"token"` // Bearer token scrapers must send; empty leaves /metrics open
+}
+
+// ForwardingSettings sends audit and compliance events to a SIEM (Splunk,
+// QRadar, Sentinel) as syslog messages, so it need not poll the REST API
+type ForwardingSettings struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Address  string `mapstructure:"address"`  // Receiver host:port, e.g. "siem.example.com:6514"
+	Protocol string `mapstructure:"protocol"` // tcp, tls or udp
+	Format   string `mapstructure:"format"`   // rfc5424 (structured data) or cef (CEF in the syslog message)
+	CAFile   string `mapstructure:"ca_file"`  // tls: CA certificates the receiver is verified with (empty uses the system roots)
+
+	// Events are the kinds forwarded: audit, submission and alert. Empty
+	// forwards all.
+	Events []string `mapstructure:"events"`
+
+	// QueueSize is how many events wait while the receiver is slow or
+	// unreachable; events beyond it are dropped and counted
+	QueueSize int `mapstructure:"queue_size"`
+}
+
+// EventSettings configures the event bus that submissions, client
+// registrations, policy changes and logins are published on
+type EventSettings struct {
+	// Outbox keeps each event in the database until every consumer has
+	// handled it, so events survive restarts and failed deliveries are
+	// retried. Without it events are delivered from memory, once.
+	Outbox bool `mapstructure:"outbox"`
+
+	// MaxAttempts is how often a failing delivery is tried before it is
+	// given up
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// Retain is how long delivered and abandoned events stay in the outbox
+	Retain time.Duration `mapstructure:"retain"`
+
+	// Webhooks are posted the events of their topics
+	Webhooks []WebhookSettings `mapstructure:"webhooks"`
+}
+
+// WebhookSettings posts events to an HTTP endpoint as JSON
+type WebhookSettings struct {
+	Name   string   `mapstructure:"name"`   // Lowercase letters, digits, ".", "_" and "-"; identifies its deliveries
+	URL    string   `mapstructure:"url"`    // http(s) endpoint
+	Topics []string `mapstructure:"topics"` // Empty sends every topic
+	Secret string   `mapstructure:"secret"` // Signs each body in X-Compliance-Signature; empty sends unsigned
+}
+
+// ExportSettings configures data exports
+type ExportSettings struct {
+	// AnonymizationKey derives the pseudonyms of anonymized exports. With
+	// a key the same host or user gets the same pseudonym in every export;
+	// without one each export uses a new random key.
+	AnonymizationKey string `mapstructure:"anonymization_key"`
+}
+
+// AttachmentSettings configures the evidence files attached to submissions
+type AttachmentSettings struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Storage keeps the files: a local directory, or an S3-compatible
+	// bucket or Azure Blob container, which stateless servers require
+	Storage objectstore.Config `mapstructure:"storage"`
+
+	// MaxSizeMB is the largest file accepted
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+
+	// AllowedTypes are the media types accepted, as sniffed from the
+	// content rather than taken from the upload
+	AllowedTypes []string `mapstructure:"allowed_types"`
+}
+
+// defaultAttachmentTypes are the media types of screenshots, documents and
+// exported reports. CSV and JSON files are sniffed as text/plain.
+var defaultAttachmentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "application/pdf",
+	"text/plain", "text/html", "text/xml", "application/zip",
+}
+
+// MetadataSettings defines the site-specific metadata clients may attach to
+// submissions, such as ticket or image build IDs
+type MetadataSettings struct {
+	Fields []MetadataField `mapstructure:"fields"`
+}
+
+// MetadataField is one metadata field. Submissions carrying a field that
+// is not defined are refused.
+type MetadataField struct {
+	Name        string `mapstructure:"name"` // Lowercase letters, digits and underscores
+	Description string `mapstructure:"description"`
+	Type        string `mapstructure:"type"` // string (default), integer, boolean or enum
+	Required    bool   `mapstructure:"required"`
+
+	// Pattern is a regular expression string values must match in full
+	Pattern   string   `mapstructure:"pattern"`
+	Values    []string `mapstructure:"values"`     // enum: the allowed values
+	MaxLength int      `mapstructure:"max_length"` // string: default 256
+}
+
+// Forwarding protocols, formats and event kinds
+const (
+	forwardProtocolTCP = "tcp"
+	forwardProtocolTLS = "tls"
+	forwardProtocolUDP = "udp"
+
+	forwardFormatRFC5424 = "rfc5424"
+	forwardFormatCEF     = "cef"
+
+	forwardAudit      = "audit"
+	forwardSubmission = "submission"
+	forwardAlert      = "alert"
+)
+
+// Retention actions
+const (
+	retentionActionArchive = "archive"
+	retentionActionPrune   = "prune"
+)
+
+// RetentionSettings limits how many submissions the database keeps.
+// Submissions older than max_age, and those beyond the newest max_per_client
+// of a client, expire: they are archived to compressed JSON files in
+// archive_dir, or in the bucket or container of storage, and deleted, or
+// only deleted with action prune. Submissions of a report type whose policy
+// sets retention_days expire after that many days instead.
+type RetentionSettings struct {
+	MaxAge        time.Duration `mapstructure:"max_age"`         // 0 keeps submissions regardless of age
+	MaxPerClient  int           `mapstructure:"max_per_client"`  // 0 keeps any number per client
+	PerPolicy     bool          `mapstructure:"per_policy"`      // Expire by policy retention_days even with no other limit set
+	Action        string        `mapstructure:"action"`          // archive or prune
+	ArchiveDir    string        `mapstructure:"archive_dir"`     // Where archive files are written with storage type dir
+	ArchiveMaxAge time.Duration `mapstructure:"archive_max_age"` // Archives older than this are deleted (0 keeps them)
+	Interval      time.Duration `mapstructure:"interval"`        // How often expired submissions are looked for
+
+	// Storage keeps the archives: archive_dir with type dir, or an
+	// S3-compatible bucket or Azure Blob container, which stateless
+	// servers require. Its dir is not used.
+	Storage objectstore.Config `mapstructure:"storage"`
+}
+
+// archiveStorage returns the configuration of the store archives are
+// written to
+func (r RetentionSettings) archiveStorage() objectstore.Config {
+	storage := r.Storage
+	if storage.Type == "" || storage.Type == objectstore.TypeDir {
+		storage.Type = objectstore.TypeDir
+		storage.Dir = r.ArchiveDir
+	}
+	return storage
+}
+
+// enabled reports whether any retention limit is set
+func (r RetentionSettings) enabled() bool {
+	return r.MaxAge > 0 || r.MaxPerClient > 0 || r.PerPolicy
+}
+
+// trustedKeys decodes policies.trusted_keys
+func (p PolicySettings) trustedKeys() ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(p.TrustedKeys))
+	for i, value := range p.TrustedKeys {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("policies.trusted_keys[%d] is not a base64 Ed25519 public key", i)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, nil
+}
+
+// Cache backends
+const (
+	cacheBackendMemory = "memory"
+	cacheBackendRedis  = "redis"
+)
+
+// ClientSettings contains configuration for client records
+type ClientSettings struct {
+	IdentityMatch string `mapstructure:"identity_match"` // off, fingerprint, hostname_mac or hostname: how a re-registered machine is recognized and merged
+
+	// Agents older than DeprecatedBelow are warned to upgrade; submissions
+	// from agents older than MinVersion are refused with 426 Upgrade
+	// Required. Empty disables the check.
+	DeprecatedBelow string `mapstructure:"deprecated_below"`
+	MinVersion      string `mapstructure:"min_version"`
 }
 
 // LoggingSettings contains logging configuration
@@ -81,13 +442,21 @@ type LoggingSettings struct {
 	OutputPath string `mapstructure:"output_path"` // stdout, stderr, or file path
 }
 
-// LoadServerConfig loads configuration from file
+// LoadServerConfig loads configuration from file. Environment variables
+// override the file, and without a file the server is configured from the
+// environment alone (as in containers).
 func LoadServerConfig(configPath string) (*ServerConfig, error) {
 	v := viper.New()
 
 	// Set defaults
 	setConfigDefaults(v)
 
+	// Environment overrides; every key has a default, so each one can be
+	// set this way
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
 	// Determine config file path
 	if configPath != "" {
 		// Use specified config file
@@ -120,6 +489,11 @@ func setConfigDefaults(v *viper.Viper) {
 	v.SetDefault("server.tls.enabled", true)
 	v.SetDefault("server.tls.cert_file", "certs/server.crt")
 	v.SetDefault("server.tls.key_file", "certs/server.key")
+	v.SetDefault("server.listen", "")
+	v.SetDefault("server.shutdown_timeout", "30s")
+	v.SetDefault("server.request_timeout", "10s")
+	v.SetDefault("server.long_request_timeout", "2m")
+	v.SetDefault("server.stateless", false)
 
 	// Database defaults (PostgreSQL only)
 	v.SetDefault("database.type", "postgres")
@@ -129,6 +503,11 @@ func setConfigDefaults(v *viper.Viper) {
 	v.SetDefault("database.user", "compliance")
 	v.SetDefault("database.password", "compliance")
 	v.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.auto_migrate", true)
+	v.SetDefault("database.max_open_conns", 25)
+	v.SetDefault("database.max_idle_conns", 5)
+	v.SetDefault("database.conn_max_lifetime", "5m")
+	v.SetDefault("database.conn_max_idle_time", "1m")
 
 	// Auth defaults
 	v.SetDefault("auth.enabled", true)
@@ -139,16 +518,116 @@ func setConfigDefaults(v *viper.Viper) {
 
 	// JWT defaults
 	v.SetDefault("auth.jwt.enabled", true) // Enabled by default (migration complete)
-	v.SetDefault("auth.jwt.secret_key", "") // Auto-generated on first run if empty
+	v.SetDefault("auth.jwt.secret_key", "") // Generated and kept in the database if empty
 	v.SetDefault("auth.jwt.access_token_lifetime", 15) // 15 minutes
 	v.SetDefault("auth.jwt.refresh_token_lifetime", 7) // 7 days
 	v.SetDefault("auth.jwt.issuer", "ComplianceToolkit")
 	v.SetDefault("auth.jwt.audience", "ComplianceToolkit")
 
+	// Lockout defaults
+	v.SetDefault("auth.lockout.max_attempts", 5)
+	v.SetDefault("auth.lockout.duration", "30m")
+	v.SetDefault("auth.invite_ttl", "72h")
+	v.SetDefault("auth.password_reset_ttl", "1h")
+	v.SetDefault("auth.api_key_expiry_warning", "168h")
+
 	// Dashboard defaults
 	v.SetDefault("dashboard.enabled", true)
 	v.SetDefault("dashboard.path", "/dashboard")
 	v.SetDefault("dashboard.login_message", "Welcome to Compliance Toolkit")
+	v.SetDefault("dashboard.timezone", "")
+
+	// Alert defaults
+	v.SetDefault("alerts.missed_run_check", true)
+	v.SetDefault("alerts.missed_run_grace", "2h")
+	v.SetDefault("alerts.missed_run_interval", "15m")
+
+	// Command defaults
+	v.SetDefault("commands.signing_key_file", "certs/command_signing.key")
+	v.SetDefault("commands.ttl", "1h")
+
+	// Client defaults
+	v.SetDefault("clients.identity_match", identityMatchFingerprint)
+	v.SetDefault("clients.deprecated_below", "")
+	v.SetDefault("clients.min_version", "")
+
+	// Cache defaults
+	v.SetDefault("cache.backend", cacheBackendMemory)
+	v.SetDefault("cache.redis_url", "")
+	v.SetDefault("cache.summary_ttl", "15s")
+
+	// Policy pack defaults
+	v.SetDefault("policies.pack_sources", []string{})
+	v.SetDefault("policies.trusted_keys", []string{})
+	v.SetDefault("policies.max_pack_size_mb", 50)
+
+	// Sampling defaults
+	v.SetDefault("sampling.enabled", false)
+	v.SetDefault("sampling.full_per_day", 100)
+
+	// Rate limit defaults
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.login_per_minute", 10)
+	v.SetDefault("rate_limit.submit_per_minute", 120)
+	v.SetDefault("rate_limit.trust_forwarded_for", false)
+
+	// Submission quota defaults
+	v.SetDefault("submission_quota.enabled", true)
+	v.SetDefault("submission_quota.per_hour", 60)
+	v.SetDefault("submission_quota.per_day", 500)
+	v.SetDefault("submission_quota.anomaly_factor", 10)
+
+	// Notification defaults
+	v.SetDefault("notifications.enabled", false)
+	v.SetDefault("notifications.base_url", "")
+	v.SetDefault("notifications.smtp.host", "")
+	v.SetDefault("notifications.smtp.port", 587)
+	v.SetDefault("notifications.smtp.username", "")
+	v.SetDefault("notifications.smtp.password", "")
+	v.SetDefault("notifications.smtp.from", "")
+
+	// Retention defaults
+	v.SetDefault("retention.max_age", "0s")
+	v.SetDefault("retention.max_per_client", 0)
+	v.SetDefault("retention.per_policy", false)
+	v.SetDefault("retention.action", retentionActionArchive)
+	v.SetDefault("retention.archive_dir", "archive")
+	v.SetDefault("retention.archive_max_age", "0s")
+	setStorageDefaults(v, "retention.storage", "")
+	v.SetDefault("retention.interval", "24h")
+
+	// Metrics defaults
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.token", "")
+
+	// Forwarding defaults
+	v.SetDefault("forwarding.enabled", false)
+	v.SetDefault("forwarding.address", "")
+	v.SetDefault("forwarding.protocol", forwardProtocolTLS)
+	v.SetDefault("forwarding.format", forwardFormatRFC5424)
+	v.SetDefault("forwarding.ca_file", "")
+	v.SetDefault("forwarding.events", []string{})
+	v.SetDefault("forwarding.queue_size", 10000)
+
+	// Event bus defaults
+	v.SetDefault("events.outbox", true)
+	v.SetDefault("events.max_attempts", 10)
+	v.SetDefault("events.retain", "168h")
+	v.SetDefault("events.webhooks", []WebhookSettings{})
+
+	// Export defaults
+	v.SetDefault("export.anonymization_key", "")
+
+	// Attachment defaults
+	// Off by default: local disk storage would stop stateless servers
+	// from starting
+	v.SetDefault("attachments.enabled", false)
+	setStorageDefaults(v, "attachments.storage", "attachments")
+	v.SetDefault("attachments.max_size_mb", 20)
+	v.SetDefault("attachments.allowed_types", defaultAttachmentTypes)
+
+	// Metadata defaults: no fields, so submissions carry none
+	v.SetDefault("metadata.fields", []MetadataField{})
 
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
@@ -156,15 +635,83 @@ func setConfigDefaults(v *viper.Viper) {
 	v.SetDefault("logging.output_path", "stdout")
 }
 
+// setStorageDefaults sets the defaults of the object storage settings under
+// key, so each can be set with an environment variable
+func setStorageDefaults(v *viper.Viper, key, dir string) {
+	v.SetDefault(key+".type", objectstore.TypeDir)
+	v.SetDefault(key+".dir", dir)
+	v.SetDefault(key+".s3.endpoint", "")
+	v.SetDefault(key+".s3.region", "")
+	v.SetDefault(key+".s3.bucket", "")
+	v.SetDefault(key+".s3.prefix", "")
+	v.SetDefault(key+".s3.access_key", "")
+	v.SetDefault(key+".s3.secret_key", "")
+	v.SetDefault(key+".s3.path_style", false)
+	v.SetDefault(key+".azure.endpoint", "")
+	v.SetDefault(key+".azure.account", "")
+	v.SetDefault(key+".azure.container", "")
+	v.SetDefault(key+".azure.prefix", "")
+	v.SetDefault(key+".azure.account_key", "")
+}
+
+// validateStorage checks the object storage settings under key
+func validateStorage(key string, storage objectstore.Config) error {
+	switch storage.Type {
+	case objectstore.TypeDir:
+	case objectstore.TypeS3:
+		s3 := storage.S3
+		if s3.Bucket == "" || s3.Region == "" || s3.AccessKey == "" || s3.SecretKey == "" {
+			return fmt.Errorf("%s.s3 requires bucket, region, access_key and secret_key", key)
+		}
+	case objectstore.TypeAzure:
+		azure := storage.Azure
+		if azure.Account == "" || azure.Container == "" || azure.AccountKey == "" {
+			return fmt.Errorf("%s.azure requires account, container and account_key", key)
+		}
+	default:
+		return fmt.Errorf("%s.type must be %s, %s or %s", key, objectstore.TypeDir, objectstore.TypeS3, objectstore.TypeAzure)
+	}
+	return nil
+}
+
 // unmarshalConfig unmarshals viper config into ServerConfig
 func unmarshalConfig(v *viper.Viper) (*ServerConfig, error) {
 	var config ServerConfig
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if config.Server.Listen != "" {
+		host, port, err := parseListenAddress(config.Server.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server.listen: %w", err)
+		}
+		config.Server.Host, config.Server.Port = host, port
+	}
 	return &config, nil
 }
 
+// parseListenAddress splits a host:port listen address. An empty host
+// listens on all interfaces.
+func parseListenAddress(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return host, port, nil
+}
+
+// ListenAddress returns the host:port address the server listens on
+func (s ServerSettings) ListenAddress() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
 // Validate validates the server configuration
 func (c *ServerConfig) Validate() error {
 	// Validate port
@@ -172,6 +719,24 @@ func (c *ServerConfig) Validate() error {
 		return fmt.Errorf("invalid port: %d (must be 1-65535)", c.Server.Port)
 	}
 
+	// Validate shutdown timeout
+	if c.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("server.shutdown_timeout must be positive")
+	}
+
+	// Validate handler timeouts
+	if c.Server.RequestTimeout <= 0 || c.Server.LongRequestTimeout <= 0 {
+		return fmt.Errorf("server.request_timeout and server.long_request_timeout must be positive")
+	}
+	if c.Server.LongRequestTimeout < c.Server.RequestTimeout {
+		return fmt.Errorf("server.long_request_timeout must not be shorter than server.request_timeout")
+	}
+
+	// Validate stateless mode
+	if c.Server.Stateless && c.Logging.OutputPath != "stdout" && c.Logging.OutputPath != "stderr" {
+		return fmt.Errorf("server.stateless requires logging.output_path stdout or stderr")
+	}
+
 	// Validate TLS settings
 	if c.Server.TLS.Enabled {
 		if c.Server.TLS.CertFile == "" {
@@ -195,6 +760,15 @@ func (c *ServerConfig) Validate() error {
 	if c.Database.User == "" {
 		return fmt.Errorf("database user is required")
 	}
+	if c.Database.MaxOpenConns < 1 {
+		return fmt.Errorf("database.max_open_conns must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		return fmt.Errorf("database.max_idle_conns must be between 0 and max_open_conns (%d)", c.Database.MaxOpenConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("database.conn_max_lifetime and conn_max_idle_time must not be negative")
+	}
 
 	// Validate auth settings
 	// NOTE: Static API keys (c.Auth.APIKeys) are DEPRECATED
@@ -209,6 +783,203 @@ func (c *ServerConfig) Validate() error {
 		}
 	}
 
+	if c.Auth.Lockout.MaxAttempts < 0 {
+		return fmt.Errorf("auth.lockout.max_attempts must not be negative")
+	}
+	if c.Auth.Lockout.MaxAttempts > 0 && c.Auth.Lockout.Duration <= 0 {
+		return fmt.Errorf("auth.lockout.duration must be positive")
+	}
+	if c.Auth.InviteTTL <= 0 {
+		return fmt.Errorf("auth.invite_ttl must be positive")
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		return fmt.Errorf("auth.password_reset_ttl must be positive")
+	}
+	if c.Auth.APIKeyExpiryWarning < 0 {
+		return fmt.Errorf("auth.api_key_expiry_warning must not be negative")
+	}
+
+	if _, err := loadDisplayLocation(c.Dashboard.Timezone); err != nil {
+		return fmt.Errorf("dashboard.timezone: %w", err)
+	}
+
+	// Validate alert settings
+	if c.Alerts.MissedRunCheck && c.Alerts.MissedRunInterval <= 0 {
+		return fmt.Errorf("alerts.missed_run_interval must be positive")
+	}
+
+	// Validate command settings
+	if c.Commands.SigningKeyFile == "" {
+		return fmt.Errorf("commands.signing_key_file is required")
+	}
+	if c.Commands.TTL <= 0 {
+		return fmt.Errorf("commands.ttl must be positive")
+	}
+
+	// Validate client settings
+	switch c.Clients.IdentityMatch {
+	case identityMatchOff, identityMatchFingerprint, identityMatchHostnameMAC, identityMatchHostname:
+	default:
+		return fmt.Errorf("clients.identity_match must be %s, %s, %s or %s",
+			identityMatchOff, identityMatchFingerprint, identityMatchHostnameMAC, identityMatchHostname)
+	}
+	for key, value := range map[string]string{"clients.deprecated_below": c.Clients.DeprecatedBelow, "clients.min_version": c.Clients.MinVersion} {
+		if value == "" {
+			continue
+		}
+		if _, _, err := parseVersion(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	// Validate cache settings
+	switch c.Cache.Backend {
+	case cacheBackendMemory:
+	case cacheBackendRedis:
+		if _, err := redis.ParseURL(c.Cache.RedisURL); err != nil {
+			return fmt.Errorf("cache.redis_url: %w", err)
+		}
+	default:
+		return fmt.Errorf("cache.backend must be %s or %s", cacheBackendMemory, cacheBackendRedis)
+	}
+	if c.Cache.SummaryTTL < 0 {
+		return fmt.Errorf("cache.summary_ttl must not be negative")
+	}
+
+	// Validate policy pack settings
+	if _, err := c.Policies.trustedKeys(); err != nil {
+		return err
+	}
+	if len(c.Policies.PackSources) > 0 && len(c.Policies.TrustedKeys) == 0 {
+		return fmt.Errorf("policies.pack_sources requires policies.trusted_keys")
+	}
+	for _, source := range c.Policies.PackSources {
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("policies.pack_sources: %q is not an http(s) URL", source)
+		}
+	}
+	if c.Policies.MaxPackSizeMB <= 0 {
+		return fmt.Errorf("policies.max_pack_size_mb must be positive")
+	}
+
+	if c.Sampling.Enabled && c.Sampling.FullPerDay < 1 {
+		return fmt.Errorf("sampling.full_per_day must be at least 1 so audit samples are kept")
+	}
+
+	if c.RateLimit.Enabled && (c.RateLimit.LoginPerMinute < 1 || c.RateLimit.SubmitPerMinute < 1) {
+		return fmt.Errorf("rate_limit.login_per_minute and submit_per_minute must be at least 1")
+	}
+	if c.SubmissionQuota.Enabled {
+		if c.SubmissionQuota.PerHour < 0 || c.SubmissionQuota.PerDay < 0 {
+			return fmt.Errorf("submission_quota.per_hour and per_day must not be negative")
+		}
+		if c.SubmissionQuota.AnomalyFactor != 0 && c.SubmissionQuota.AnomalyFactor <= 1 {
+			return fmt.Errorf("submission_quota.anomaly_factor must be greater than 1, or 0 to disable it")
+		}
+	}
+	if c.Notifications.BaseURL != "" {
+		u, err := url.Parse(c.Notifications.BaseURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("notifications.base_url: %q is not an http(s) URL", c.Notifications.BaseURL)
+		}
+	}
+	if c.Notifications.Enabled {
+		if c.Notifications.BaseURL == "" {
+			return fmt.Errorf("notifications.base_url is required when notifications are enabled, for links in email")
+		}
+		if c.Notifications.SMTP.Host == "" || c.Notifications.SMTP.Port <= 0 {
+			return fmt.Errorf("notifications.smtp.host and port are required when notifications are enabled")
+		}
+		if _, err := mail.ParseAddress(c.Notifications.SMTP.From); err != nil {
+			return fmt.Errorf("notifications.smtp.from: %w", err)
+		}
+	}
+
+	if c.Forwarding.Enabled {
+		if _, _, err := net.SplitHostPort(c.Forwarding.Address); err != nil {
+			return fmt.Errorf("forwarding.address: %q is not host:port", c.Forwarding.Address)
+		}
+		switch c.Forwarding.Protocol {
+		case forwardProtocolTCP, forwardProtocolTLS, forwardProtocolUDP:
+		default:
+			return fmt.Errorf("forwarding.protocol must be %q, %q or %q", forwardProtocolTCP, forwardProtocolTLS, forwardProtocolUDP)
+		}
+		switch c.Forwarding.Format {
+		case forwardFormatRFC5424, forwardFormatCEF:
+		default:
+			return fmt.Errorf("forwarding.format must be %q or %q", forwardFormatRFC5424, forwardFormatCEF)
+		}
+		for _, kind := range c.Forwarding.Events {
+			switch kind {
+			case forwardAudit, forwardSubmission, forwardAlert:
+			default:
+				return fmt.Errorf("forwarding.events: unknown event kind %q (audit, submission or alert)", kind)
+			}
+		}
+		if c.Forwarding.QueueSize < 1 {
+			return fmt.Errorf("forwarding.queue_size must be at least 1")
+		}
+	}
+
+	if err := validateEventSettings(c.Events); err != nil {
+		return err
+	}
+
+	// Validate retention settings
+	if c.Retention.MaxAge < 0 || c.Retention.MaxPerClient < 0 {
+		return fmt.Errorf("retention.max_age and max_per_client must not be negative")
+	}
+	if c.Retention.enabled() {
+		if c.Retention.Interval <= 0 {
+			return fmt.Errorf("retention.interval must be positive")
+		}
+		switch c.Retention.Action {
+		case retentionActionPrune:
+		case retentionActionArchive:
+			storage := c.Retention.archiveStorage()
+			if storage.Type == objectstore.TypeDir {
+				if c.Retention.ArchiveDir == "" {
+					return fmt.Errorf("retention.archive_dir is required to archive submissions")
+				}
+				if c.Server.Stateless {
+					return fmt.Errorf("server.stateless requires retention.action %s or retention.storage.type %s or %s; archive_dir is local disk",
+						retentionActionPrune, objectstore.TypeS3, objectstore.TypeAzure)
+				}
+			}
+			if err := validateStorage("retention.storage", storage); err != nil {
+				return err
+			}
+			if c.Retention.ArchiveMaxAge < 0 {
+				return fmt.Errorf("retention.archive_max_age must not be negative")
+			}
+		default:
+			return fmt.Errorf("retention.action must be %s or %s", retentionActionArchive, retentionActionPrune)
+		}
+	}
+
+	// Validate attachment settings
+	if c.Attachments.Enabled {
+		if c.Attachments.MaxSizeMB <= 0 || c.Attachments.MaxSizeMB > 1024 {
+			return fmt.Errorf("attachments.max_size_mb must be between 1 and 1024")
+		}
+		if len(c.Attachments.AllowedTypes) == 0 {
+			return fmt.Errorf("attachments.allowed_types must list at least one media type")
+		}
+		if c.Attachments.Storage.Type == objectstore.TypeDir && c.Server.Stateless {
+			return fmt.Errorf("server.stateless requires attachments.storage.type %s or %s, or attachments.enabled false; %s storage is local disk",
+				objectstore.TypeS3, objectstore.TypeAzure, objectstore.TypeDir)
+		}
+		if err := validateStorage("attachments.storage", c.Attachments.Storage); err != nil {
+			return err
+		}
+	}
+
+	// Validate metadata fields
+	if err := c.Metadata.validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -229,11 +1000,18 @@ func generateDefaultConfig(configPath string) error {
 
 	// Generate YAML content
 	content := `# Compliance Toolkit Server Configuration
+# Any key can be overridden with an environment variable: prefix it with
+# COMPLIANCE_ and replace dots with underscores (COMPLIANCE_DATABASE_HOST).
 
 # HTTP/HTTPS server settings
 server:
   host: "0.0.0.0"       # Bind to all interfaces
   port: 8080            # HTTP port (use 8443 for HTTPS)
+  # listen: ":8080"     # host:port, replaces host and port when set
+  shutdown_timeout: 30s # Time requests in flight get to finish on stop
+  request_timeout: 10s  # Handlers running longer get 504 Gateway Timeout
+  long_request_timeout: 2m # request_timeout for imports and exports
+  stateless: false      # Keep no state on local disk (multiple replicas)
   tls:
     enabled: false      # Set to true for HTTPS
     cert_file: "certs/server.crt"
@@ -248,6 +1026,11 @@ database:
   user: "compliance"
   password: "compliance"
   sslmode: "disable"    # disable, require, verify-ca, verify-full
+  auto_migrate: true    # Apply schema migrations on startup (see --migrate)
+  max_open_conns: 25    # Connection pool size; keep below PostgreSQL max_connections
+  max_idle_conns: 5
+  conn_max_lifetime: 5m
+  conn_max_idle_time: 1m
 
 # Authentication settings
 auth:
@@ -257,16 +1040,171 @@ auth:
   # JWT authentication (recommended)
   jwt:
     enabled: true        # JWT enabled by default
-    secret_key: ""       # Auto-generated on first run if empty
+    secret_key: ""       # Generated and kept in the database if empty
     access_token_lifetime: 15   # Access token lifetime in minutes
     refresh_token_lifetime: 7   # Refresh token lifetime in days
     issuer: "ComplianceToolkit"
     audience: "ComplianceToolkit"
 
+  # Account lockout after failed logins
+  lockout:
+    max_attempts: 5      # Consecutive failed logins that lock the account (0 disables)
+    duration: 30m        # How long a locked account refuses logins
+  invite_ttl: 72h        # How long invitations of imported users stay valid
+  password_reset_ttl: 1h # How long emailed password reset links stay valid
+  api_key_expiry_warning: 168h # Email key creators this long before keys expire (0 disables)
+
 # Web dashboard
 dashboard:
   enabled: true
   path: "/dashboard"    # URL path for dashboard
+  timezone: ""          # IANA zone for timestamps, e.g. "Europe/Berlin"; empty uses each browser's
+
+# Alerting
+alerts:
+  missed_run_check: true    # Alert when a scheduled client run does not arrive
+  missed_run_grace: 2h      # How late a run may be before it counts as missed
+  missed_run_interval: 15m  # How often client schedules are evaluated
+
+# Remote commands delivered to clients with heartbeat responses
+commands:
+  signing_key_file: "certs/command_signing.key"  # Ed25519 key (created on first start)
+  ttl: 1h                   # Clients refuse commands older than this
+
+# Client records
+clients:
+  identity_match: "fingerprint"  # off, fingerprint, hostname_mac or hostname: merge the old record of a reimaged machine
+  deprecated_below: ""      # Agents older than this version are told to upgrade, e.g. "1.2.0"
+  min_version: ""           # Submissions from agents older than this are refused (426 Upgrade Required)
+
+# Cache for short-lived data such as dashboard summaries
+cache:
+  backend: "memory"     # memory, or redis to share it between replicas
+  # redis_url: "redis://localhost:6379/0"
+  summary_ttl: 15s      # How long a dashboard summary is reused (0 disables)
+
+# Signed policy packs imported with POST /api/v1/policies/import-url
+policies:
+  pack_sources: []      # URL prefixes packs may be fetched from, e.g. "https://artifacts.example.com/packs/"
+  trusted_keys: []      # Base64 Ed25519 keys pack signatures must verify against
+  max_pack_size_mb: 50
+
+# Evidence sampling for very large fleets: submissions over the daily sample
+# keep only their summary and check statuses
+sampling:
+  enabled: false
+  full_per_day: 100     # Submissions per report type and day stored with full evidence
+
+# Per IP address request limits on login and submission endpoints, across
+# replicas with cache.backend redis
+rate_limit:
+  enabled: true
+  login_per_minute: 10      # Login attempts per IP address and minute
+  submit_per_minute: 120    # Compliance submissions per IP address and minute
+  trust_forwarded_for: false  # Use X-Forwarded-For (only behind a reverse proxy)
+
+# Per client submission quotas, counted in the database (all replicas)
+submission_quota:
+  enabled: true
+  per_hour: 60          # Submissions per client in the last hour (0 is unlimited)
+  per_day: 500          # Submissions per client in the last 24 hours (0 is unlimited)
+  anomaly_factor: 10    # Flag a submission with this many times the checks of the last (0 disables)
+
+# Email notifications (invitations of imported users)
+notifications:
+  enabled: false
+  base_url: ""          # Dashboard address for links in email, e.g. "https://compliance.example.com"
+  smtp:
+    host: ""
+    port: 587           # STARTTLS is used when the server offers it
+    username: ""        # Empty sends without authentication
+    password: ""
+    from: ""            # e.g. "Compliance <compliance@example.com>"
+
+# Submission retention: expired submissions are archived to gzip-compressed
+# JSON files and deleted from the database. Both limits 0 keeps everything.
+retention:
+  max_age: 0s           # e.g. 8760h expires submissions after a year
+  max_per_client: 0     # Newest submissions kept per client
+  action: "archive"     # archive, or prune to delete without archiving
+  archive_dir: "archive"
+  archive_max_age: 0s   # e.g. 61320h deletes archives after seven years (0 keeps them)
+  interval: 24h         # How often expired submissions are looked for
+  storage:
+    type: "dir"         # dir (archive_dir), s3 or azure (required to archive when stateless)
+    s3:
+      endpoint: ""
+      region: ""
+      bucket: ""
+      prefix: ""        # e.g. "archives/"
+      access_key: ""
+      secret_key: ""
+      path_style: false
+    azure:
+      endpoint: ""      # Empty: https://<account>.blob.core.windows.net
+      account: ""
+      container: ""
+      prefix: ""
+      account_key: ""   # Base64 storage account key
+
+# Prometheus metrics at /metrics
+metrics:
+  enabled: true
+  token: ""             # Bearer token scrapers must send; empty leaves /metrics open
+
+# Forward audit, submission and alert events to a SIEM as syslog messages
+forwarding:
+  enabled: false
+  address: ""           # Receiver host:port, e.g. "siem.example.com:6514"
+  protocol: "tls"       # tls, tcp or udp
+  format: "rfc5424"     # rfc5424 (structured data) or cef
+  ca_file: ""           # tls: CA certificates for the receiver (empty: system roots)
+  events: []            # audit, submission, alert (empty forwards all)
+  queue_size: 10000     # Events held while the receiver is unreachable
+
+# Event bus: submission.accepted, client.registered, policy.updated and
+# user.login events for the SIEM forwarder, missed run alerts and webhooks
+events:
+  outbox: true          # Keep events in the database until delivered (false: memory only)
+  max_attempts: 10      # Tries per failing delivery, with backoff up to an hour
+  retain: "168h"        # How long delivered and abandoned events are kept
+  webhooks: []          # - name: soc, url: https://..., topics: [], secret: ""
+
+# Evidence files attached to submissions (screenshots, exported GPO reports)
+attachments:
+  enabled: false
+  storage:
+    type: "dir"         # dir, s3 for an S3-compatible bucket or azure for Azure Blob Storage (s3 or azure when stateless)
+    dir: "attachments"
+    s3:
+      endpoint: ""      # e.g. "https://minio.example.com:9000" (empty: AWS S3 in region)
+      region: ""
+      bucket: ""
+      prefix: ""
+      access_key: ""
+      secret_key: ""
+      path_style: false # Bucket in the path rather than the host name (MinIO)
+    azure:
+      endpoint: ""      # Empty: https://<account>.blob.core.windows.net
+      account: ""
+      container: ""
+      prefix: ""
+      account_key: ""   # Base64 storage account key
+  max_size_mb: 20
+  allowed_types: ["image/png", "image/jpeg", "image/gif", "application/pdf", "text/plain", "text/html", "text/xml", "application/zip"]
+
+# Site-specific metadata clients may attach to submissions. Submissions
+# with undefined fields, or without a required one, are refused.
+metadata:
+  fields: []
+  # - name: "ticket_id"       # Lowercase letters, digits and underscores
+  #   description: "Change ticket the scan belongs to"
+  #   type: "string"          # string, integer, boolean or enum
+  #   pattern: "(INC|CHG)[0-9]{6}"
+  #   max_length: 64
+  # - name: "image_build"
+  #   type: "string"
+  #   required: true
 
 # Logging configuration
 logging:
@@ -276,7 +1214,7 @@ logging:
 `
 
 	// Write file
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := fileio.WriteFile(path, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
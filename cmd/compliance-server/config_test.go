@@ -0,0 +1,267 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"compliancetoolkit/pkg/objectstore"
+)
+
+// defaultTestConfig returns the configuration a server starts with when no
+// config file exists
+func defaultTestConfig(t *testing.T) *ServerConfig {
+	t.Helper()
+	v := viper.New()
+	setConfigDefaults(v)
+	config, err := unmarshalConfig(v)
+	if err != nil {
+		t.Fatalf("unmarshalConfig() error = %v", err)
+	}
+	return config
+}
+
+// TestValidateDatabaseSettings tests connection pool and client setting validation
+func TestValidateDatabaseSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *ServerConfig)
+		wantErr bool
+	}{
+		{"defaults", func(c *ServerConfig) {}, false},
+		{"larger pool", func(c *ServerConfig) { c.Database.MaxOpenConns = 200; c.Database.MaxIdleConns = 50 }, false},
+		{"no idle connections", func(c *ServerConfig) { c.Database.MaxIdleConns = 0 }, false},
+		{"unlimited lifetime", func(c *ServerConfig) { c.Database.ConnMaxLifetime = 0 }, false},
+		{"no open connections", func(c *ServerConfig) { c.Database.MaxOpenConns = 0 }, true},
+		{"more idle than open", func(c *ServerConfig) { c.Database.MaxIdleConns = 30 }, true},
+		{"negative idle time", func(c *ServerConfig) { c.Database.ConnMaxIdleTime = -time.Second }, true},
+		{"identity match hostname_mac", func(c *ServerConfig) { c.Clients.IdentityMatch = identityMatchHostnameMAC }, false},
+		{"unknown identity match", func(c *ServerConfig) { c.Clients.IdentityMatch = "mac" }, true},
+		{"longer request timeout", func(c *ServerConfig) { c.Server.RequestTimeout = time.Minute }, false},
+		{"no request timeout", func(c *ServerConfig) { c.Server.RequestTimeout = 0 }, true},
+		{"long timeout below request timeout", func(c *ServerConfig) { c.Server.LongRequestTimeout = time.Second }, true},
+		{"stateless", func(c *ServerConfig) { c.Server.Stateless = true }, false},
+		{"stateless with log file", func(c *ServerConfig) { c.Server.Stateless = true; c.Logging.OutputPath = "server.log" }, true},
+		{"redis cache", func(c *ServerConfig) { c.Cache.Backend = cacheBackendRedis; c.Cache.RedisURL = "redis://redis:6379/0" }, false},
+		{"redis cache without url", func(c *ServerConfig) { c.Cache.Backend = cacheBackendRedis }, true},
+		{"unknown cache backend", func(c *ServerConfig) { c.Cache.Backend = "memcached" }, true},
+		{"pack sources", func(c *ServerConfig) {
+			c.Policies.PackSources = []string{"https://artifacts.example.com/packs/"}
+			c.Policies.TrustedKeys = []string{"11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}
+		}, false},
+		{"pack sources without trusted keys", func(c *ServerConfig) { c.Policies.PackSources = []string{"https://artifacts.example.com/packs/"} }, true},
+		{"invalid trusted key", func(c *ServerConfig) { c.Policies.TrustedKeys = []string{"not-a-key"} }, true},
+		{"pack source without host", func(c *ServerConfig) {
+			c.Policies.PackSources = []string{"file:///srv/packs"}
+			c.Policies.TrustedKeys = []string{"11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}
+		}, true},
+		{"sampling", func(c *ServerConfig) { c.Sampling.Enabled = true }, false},
+		{"sampling without full submissions", func(c *ServerConfig) { c.Sampling.Enabled = true; c.Sampling.FullPerDay = 0 }, true},
+		{"lockout disabled", func(c *ServerConfig) { c.Auth.Lockout.MaxAttempts = 0; c.Auth.Lockout.Duration = 0 }, false},
+		{"lockout without duration", func(c *ServerConfig) { c.Auth.Lockout.Duration = 0 }, true},
+		{"negative lockout attempts", func(c *ServerConfig) { c.Auth.Lockout.MaxAttempts = -1 }, true},
+		{"rate limit disabled", func(c *ServerConfig) { c.RateLimit.Enabled = false; c.RateLimit.LoginPerMinute = 0 }, false},
+		{"no logins per minute", func(c *ServerConfig) { c.RateLimit.LoginPerMinute = 0 }, true},
+		{"unlimited submission quota", func(c *ServerConfig) { c.SubmissionQuota.PerHour = 0; c.SubmissionQuota.PerDay = 0 }, false},
+		{"negative submission quota", func(c *ServerConfig) { c.SubmissionQuota.PerDay = -1 }, true},
+		{"anomaly check disabled", func(c *ServerConfig) { c.SubmissionQuota.AnomalyFactor = 0 }, false},
+		{"anomaly factor of one", func(c *ServerConfig) { c.SubmissionQuota.AnomalyFactor = 1 }, true},
+		{"dashboard timezone", func(c *ServerConfig) { c.Dashboard.Timezone = "America/Chicago" }, false},
+		{"unknown dashboard timezone", func(c *ServerConfig) { c.Dashboard.Timezone = "PST" }, true},
+		{"no invite ttl", func(c *ServerConfig) { c.Auth.InviteTTL = 0 }, true},
+		{"no password reset ttl", func(c *ServerConfig) { c.Auth.PasswordResetTTL = 0 }, true},
+		{"no api key expiry warning", func(c *ServerConfig) { c.Auth.APIKeyExpiryWarning = 0 }, false},
+		{"negative api key expiry warning", func(c *ServerConfig) { c.Auth.APIKeyExpiryWarning = -time.Hour }, true},
+		{"notifications", func(c *ServerConfig) {
+			c.Notifications.Enabled = true
+			c.Notifications.BaseURL = "https://compliance.example.com"
+			c.Notifications.SMTP.Host = "smtp.example.com"
+			c.Notifications.SMTP.From = "Compliance <compliance@example.com>"
+		}, false},
+		{"notifications without base url", func(c *ServerConfig) {
+			c.Notifications.Enabled = true
+			c.Notifications.SMTP.Host = "smtp.example.com"
+			c.Notifications.SMTP.From = "compliance@example.com"
+		}, true},
+		{"notifications without smtp host", func(c *ServerConfig) {
+			c.Notifications.Enabled = true
+			c.Notifications.BaseURL = "https://compliance.example.com"
+			c.Notifications.SMTP.From = "compliance@example.com"
+		}, true},
+		{"notifications without sender", func(c *ServerConfig) {
+			c.Notifications.Enabled = true
+			c.Notifications.BaseURL = "https://compliance.example.com"
+			c.Notifications.SMTP.Host = "smtp.example.com"
+		}, true},
+		{"base url without scheme", func(c *ServerConfig) { c.Notifications.BaseURL = "compliance.example.com" }, true},
+		{"forwarding", func(c *ServerConfig) { c.Forwarding.Enabled = true; c.Forwarding.Address = "siem.example.com:6514" }, false},
+		{"forwarding without address", func(c *ServerConfig) { c.Forwarding.Enabled = true }, true},
+		{"unknown forwarding protocol", func(c *ServerConfig) {
+			c.Forwarding.Enabled = true
+			c.Forwarding.Address = "siem.example.com:514"
+			c.Forwarding.Protocol = "relp"
+		}, true},
+		{"unknown forwarding format", func(c *ServerConfig) {
+			c.Forwarding.Enabled = true
+			c.Forwarding.Address = "siem.example.com:6514"
+			c.Forwarding.Format = "leef"
+		}, true},
+		{"unknown forwarded event", func(c *ServerConfig) {
+			c.Forwarding.Enabled = true
+			c.Forwarding.Address = "siem.example.com:6514"
+			c.Forwarding.Events = []string{"audit", "login"}
+		}, true},
+		{"webhook", func(c *ServerConfig) {
+			c.Events.Webhooks = []WebhookSettings{{Name: "soc", URL: "https://hooks.example.com/compliance", Topics: []string{topicSubmissionAccepted}}}
+		}, false},
+		{"webhook without url", func(c *ServerConfig) { c.Events.Webhooks = []WebhookSettings{{Name: "soc"}} }, true},
+		{"webhook with unknown topic", func(c *ServerConfig) {
+			c.Events.Webhooks = []WebhookSettings{{Name: "soc", URL: "https://hooks.example.com/compliance", Topics: []string{"submission.rejected"}}}
+		}, true},
+		{"duplicate webhook names", func(c *ServerConfig) {
+			c.Events.Webhooks = []WebhookSettings{{Name: "soc", URL: "https://a.example.com/"}, {Name: "soc", URL: "https://b.example.com/"}}
+		}, true},
+		{"no event delivery attempts", func(c *ServerConfig) { c.Events.MaxAttempts = 0 }, true},
+		{"retention", func(c *ServerConfig) { c.Retention.MaxAge = 365 * 24 * time.Hour; c.Retention.MaxPerClient = 1000 }, false},
+		{"negative retention age", func(c *ServerConfig) { c.Retention.MaxAge = -time.Hour }, true},
+		{"unknown retention action", func(c *ServerConfig) { c.Retention.MaxPerClient = 100; c.Retention.Action = "delete" }, true},
+		{"archive without directory", func(c *ServerConfig) { c.Retention.MaxPerClient = 100; c.Retention.ArchiveDir = "" }, true},
+		{"stateless archive", func(c *ServerConfig) { c.Retention.MaxPerClient = 100; c.Server.Stateless = true }, true},
+		{"per-policy retention only", func(c *ServerConfig) { c.Retention.PerPolicy = true }, false},
+		{"per-policy retention without interval", func(c *ServerConfig) { c.Retention.PerPolicy = true; c.Retention.Interval = 0 }, true},
+		{"stateless prune", func(c *ServerConfig) {
+			c.Retention.MaxPerClient = 100
+			c.Retention.Action = retentionActionPrune
+			c.Server.Stateless = true
+			c.Attachments.Enabled = false
+		}, false},
+		{"stateless archive in azure", func(c *ServerConfig) {
+			c.Retention.MaxPerClient = 100
+			c.Retention.ArchiveMaxAge = 7 * 365 * 24 * time.Hour
+			c.Retention.Storage.Type = objectstore.TypeAzure
+			c.Retention.Storage.Azure = objectstore.AzureConfig{Account: "evidence", Container: "archives", AccountKey: "c2VjcmV0"}
+			c.Server.Stateless = true
+			c.Attachments.Enabled = false
+		}, false},
+		{"archive in azure without key", func(c *ServerConfig) {
+			c.Retention.MaxPerClient = 100
+			c.Retention.Storage.Type = objectstore.TypeAzure
+			c.Retention.Storage.Azure = objectstore.AzureConfig{Account: "evidence", Container: "archives"}
+		}, true},
+		{"negative archive age", func(c *ServerConfig) { c.Retention.MaxPerClient = 100; c.Retention.ArchiveMaxAge = -time.Hour }, true},
+		{"attachments in s3", func(c *ServerConfig) {
+			c.Attachments.Enabled = true
+			c.Attachments.Storage.Type = objectstore.TypeS3
+			c.Attachments.Storage.S3 = objectstore.S3Config{Region: "us-east-1", Bucket: "evidence", AccessKey: "key", SecretKey: "secret"}
+			c.Server.Stateless = true
+		}, false},
+		{"attachments in s3 without bucket", func(c *ServerConfig) {
+			c.Attachments.Enabled = true
+			c.Attachments.Storage.Type = objectstore.TypeS3
+			c.Attachments.Storage.S3 = objectstore.S3Config{Region: "us-east-1", AccessKey: "key", SecretKey: "secret"}
+		}, true},
+		{"stateless attachments on disk", func(c *ServerConfig) { c.Server.Stateless = true; c.Attachments.Enabled = true }, true},
+		{"attachments in azure", func(c *ServerConfig) {
+			c.Attachments.Enabled = true
+			c.Attachments.Storage.Type = objectstore.TypeAzure
+			c.Attachments.Storage.Azure = objectstore.AzureConfig{Account: "evidence", Container: "attachments", AccountKey: "c2VjcmV0"}
+		}, false},
+		{"unknown attachment storage", func(c *ServerConfig) { c.Attachments.Enabled = true; c.Attachments.Storage.Type = "gcs" }, true},
+		{"no attachment size", func(c *ServerConfig) { c.Attachments.Enabled = true; c.Attachments.MaxSizeMB = 0 }, true},
+		{"no attachment types", func(c *ServerConfig) { c.Attachments.Enabled = true; c.Attachments.AllowedTypes = nil }, true},
+		{"metadata fields", func(c *ServerConfig) {
+			c.Metadata.Fields = []MetadataField{{Name: "ticket_id", Pattern: "INC[0-9]+"}, {Name: "ring", Type: "enum", Values: []string{"pilot", "broad"}}}
+		}, false},
+		{"metadata field name", func(c *ServerConfig) { c.Metadata.Fields = []MetadataField{{Name: "Ticket-ID"}} }, true},
+		{"metadata field twice", func(c *ServerConfig) { c.Metadata.Fields = []MetadataField{{Name: "ticket"}, {Name: "ticket"}} }, true},
+		{"metadata enum without values", func(c *ServerConfig) { c.Metadata.Fields = []MetadataField{{Name: "ring", Type: "enum"}} }, true},
+		{"metadata bad pattern", func(c *ServerConfig) { c.Metadata.Fields = []MetadataField{{Name: "ticket", Pattern: "INC[0-9"}} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultTestConfig(t)
+			tt.modify(config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestAutoMigrateDefault tests that a server without a config file migrates
+// its schema on startup
+func TestAutoMigrateDefault(t *testing.T) {
+	if !defaultTestConfig(t).Database.AutoMigrate {
+		t.Error("database.auto_migrate should default to true")
+	}
+}
+
+// TestEnvironmentConfig tests that environment variables override the config
+// file and configure a server that has none
+func TestEnvironmentConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  port: 9000\ndatabase:\n  host: db.internal\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("COMPLIANCE_DATABASE_HOST", "postgres")
+	t.Setenv("COMPLIANCE_SERVER_SHUTDOWN_TIMEOUT", "8s")
+	t.Setenv("COMPLIANCE_AUTH_JWT_ENABLED", "false")
+
+	config, err := LoadServerConfig(path)
+	if err != nil {
+		t.Fatalf("LoadServerConfig() error = %v", err)
+	}
+	if config.Server.Port != 9000 || config.Database.Host != "postgres" {
+		t.Errorf("port = %d, database host = %q; want 9000 from the file and postgres from the environment", config.Server.Port, config.Database.Host)
+	}
+	if config.Server.ShutdownTimeout != 8*time.Second || config.Auth.JWT.Enabled {
+		t.Errorf("shutdown timeout = %v, JWT enabled = %v; want 8s and false", config.Server.ShutdownTimeout, config.Auth.JWT.Enabled)
+	}
+
+	// No server.yaml in the working directory: environment only
+	t.Chdir(dir)
+	os.Remove(path)
+	t.Setenv("COMPLIANCE_SERVER_LISTEN", "127.0.0.1:8081")
+	config, err = LoadServerConfig("")
+	if err != nil {
+		t.Fatalf("LoadServerConfig() without a file error = %v", err)
+	}
+	if got := config.Server.ListenAddress(); got != "127.0.0.1:8081" {
+		t.Errorf("ListenAddress() = %q, want 127.0.0.1:8081", got)
+	}
+
+	t.Setenv("COMPLIANCE_SERVER_LISTEN", "8081")
+	if _, err := LoadServerConfig(""); err == nil {
+		t.Error("LoadServerConfig() with an invalid listen address error = nil, want error")
+	}
+}
+
+// TestParseListenAddress tests listen address parsing
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{":8080", "0.0.0.0", 8080, false},
+		{"127.0.0.1:8443", "127.0.0.1", 8443, false},
+		{"[::]:8080", "::", 8080, false},
+		{"localhost", "", 0, true},
+		{"0.0.0.0:http", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		host, port, err := parseListenAddress(tt.addr)
+		if (err != nil) != tt.wantErr || host != tt.wantHost || port != tt.wantPort {
+			t.Errorf("parseListenAddress(%q) = %q, %d, %v; want %q, %d, error %v",
+				tt.addr, host, port, err, tt.wantHost, tt.wantPort, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// controlIndex maps the checks of the active policies to the controls they
+// cover by framework
+type controlIndex map[checkKey]map[string][]string
+
+// buildControlIndex reads the control mappings of the active policies' queries.
+// Policies whose data cannot be read are skipped.
+func buildControlIndex(policies []Policy) controlIndex {
+	index := make(controlIndex)
+	for _, policy := range policies {
+		if policy.Status != "active" {
+			continue
+		}
+		var config struct {
+			Metadata struct {
+				ReportTitle string `json:"report_title"`
+			} `json:"metadata"`
+			Queries []struct {
+				Name     string              `json:"name"`
+				Controls map[string][]string `json:"controls"`
+			} `json:"queries"`
+		}
+		if err := json.Unmarshal([]byte(policy.PolicyData), &config); err != nil {
+			continue
+		}
+		for _, query := range config.Queries {
+			if len(query.Controls) > 0 {
+				index[checkKey{reportType: config.Metadata.ReportTitle, name: query.Name}] = query.Controls
+			}
+		}
+	}
+	return index
+}
+
+// controlFamily returns the family of a framework's control ID: the letters
+// before the hyphen of NIST 800-53 style IDs (AC-2(1) is AC), the first two
+// parts of NIST 800-171 IDs (3.1.1 is 3.1) and of ISO 27001:2013 Annex A IDs
+// (A.9.2.1 is A.9), and the first part otherwise (CIS 18.9.1 is 18, PCI DSS
+// 8.2.3 is 8)
+func controlFamily(framework, id string) string {
+	id = strings.TrimSpace(id)
+	if prefix, _, found := strings.Cut(id, "-"); found && isLetters(prefix) {
+		return strings.ToUpper(prefix)
+	}
+
+	parts := strings.Split(id, ".")
+	n := 1
+	if strings.Contains(framework, "800-171") || (len(parts[0]) == 1 && isLetters(parts[0])) {
+		n = 2
+	}
+	if n > len(parts) {
+		n = len(parts)
+	}
+	return strings.Join(parts[:n], ".")
+}
+
+// isLetters reports whether s is a non-empty run of letters
+func isLetters(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareControlIDs orders control IDs and families part by part, numbers by
+// value, so that 3.2 comes before 3.10
+func compareControlIDs(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil && aNum != bNum:
+			if aNum < bNum {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && aParts[i] != bParts[i]:
+			return strings.Compare(aParts[i], bParts[i])
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
+// controlFamilyKey identifies a family of a framework
+type controlFamilyKey struct {
+	framework, family string
+}
+
+// controlFamilyStats accumulates the results mapped to one family
+type controlFamilyStats struct {
+	family   api.ControlFamily
+	controls map[string]bool
+	failing  map[string]bool
+}
+
+// summarizeControlFamilies pivots the latest passed and failed result of
+// every check, per client and report type, by the control families the
+// check is mapped to. A result's own control mapping is used, or the
+// active policy's when the client sent none. framework, when set, limits
+// the families to one framework (case-insensitive).
+func summarizeControlFamilies(history []*api.ComplianceSubmission, index controlIndex, framework string) []api.ControlFamily {
+	// The latest submission of each client and report type
+	type clientReport struct{ clientID, reportType string }
+	latest := make(map[clientReport]*api.ComplianceSubmission)
+	for _, submission := range history {
+		key := clientReport{submission.ClientID, submission.ReportType}
+		if current, ok := latest[key]; !ok || submission.Timestamp.After(current.Timestamp) {
+			latest[key] = submission
+		}
+	}
+
+	stats := make(map[controlFamilyKey]*controlFamilyStats)
+	for _, submission := range latest {
+		for _, result := range submission.Compliance.Queries {
+			if !isPassOrFail(result.Status) {
+				continue
+			}
+			controls := result.Controls
+			if len(controls) == 0 {
+				controls = index[checkKey{reportType: submission.ReportType, name: result.Name}]
+			}
+
+			for name, ids := range controls {
+				name = strings.TrimSpace(name)
+				if name == "" || (framework != "" && !strings.EqualFold(name, framework)) {
+					continue
+				}
+				// A check counts once per family however many of its
+				// controls the family holds
+				counted := make(map[string]bool)
+				for _, id := range ids {
+					id = strings.TrimSpace(id)
+					if id == "" {
+						continue
+					}
+					key := controlFamilyKey{framework: name, family: controlFamily(name, id)}
+					st, ok := stats[key]
+					if !ok {
+						st = &controlFamilyStats{
+							family:   api.ControlFamily{Framework: key.framework, Family: key.family},
+							controls: make(map[string]bool),
+							failing:  make(map[string]bool),
+						}
+						stats[key] = st
+					}
+					st.controls[id] = true
+					if counted[key.family] {
+						continue
+					}
+					counted[key.family] = true
+
+					st.family.Checks++
+					if result.Status == checkStatusPass {
+						st.family.Passed++
+					} else {
+						st.family.Failed++
+						st.failing[submission.ClientID] = true
+					}
+				}
+			}
+		}
+	}
+
+	families := make([]api.ControlFamily, 0, len(stats))
+	for _, st := range stats {
+		f := st.family
+		f.Controls = make([]string, 0, len(st.controls))
+		for id := range st.controls {
+			f.Controls = append(f.Controls, id)
+		}
+		sort.Slice(f.Controls, func(i, j int) bool { return compareControlIDs(f.Controls[i], f.Controls[j]) < 0 })
+		f.PassRate = math.Round(float64(f.Passed)/float64(f.Checks)*1000) / 10
+		f.ClientsFailing = len(st.failing)
+		families = append(families, f)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		a, b := families[i], families[j]
+		if a.Framework != b.Framework {
+			return a.Framework < b.Framework
+		}
+		return compareControlIDs(a.Family, b.Family) < 0
+	})
+	return families
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestControlFamily tests the family derived from control IDs of the
+// supported frameworks
+func TestControlFamily(t *testing.T) {
+	tests := []struct {
+		framework, id, want string
+	}{
+		{"NIST 800-171", "3.1.1", "3.1"},
+		{"NIST 800-171", "3.13.11", "3.13"},
+		{"NIST 800-53", "AC-2(1)", "AC"},
+		{"NIST 800-53", "ac-17", "AC"},
+		{"CIS", "18.9.1", "18"},
+		{"CIS", "2.3.1.1", "2"},
+		{"ISO 27001", "A.9.2.1", "A.9"},
+		{"ISO 27001:2022", "5.15", "5"},
+		{"PCI-DSS", "8.2.3", "8"},
+		{"PCI-DSS", " 10 ", "10"},
+	}
+	for _, tt := range tests {
+		if got := controlFamily(tt.framework, tt.id); got != tt.want {
+			t.Errorf("controlFamily(%q, %q) = %q, want %q", tt.framework, tt.id, got, tt.want)
+		}
+	}
+}
+
+// TestCompareControlIDs tests that numbered parts sort by value
+func TestCompareControlIDs(t *testing.T) {
+	ordered := []string{"3.1", "3.1.2", "3.2", "3.10", "A.9", "A.12", "AC"}
+	for i := 1; i < len(ordered); i++ {
+		if compareControlIDs(ordered[i-1], ordered[i]) >= 0 {
+			t.Errorf("%s does not sort before %s", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+// TestSummarizeControlFamilies tests pivoting the latest results by control
+// family, with mappings sent by clients or taken from the active policy
+func TestSummarizeControlFamilies(t *testing.T) {
+	day := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	submission := func(clientID string, at time.Time, results ...api.QueryResult) *api.ComplianceSubmission {
+		return &api.ComplianceSubmission{
+			ClientID: clientID, ReportType: "Baseline", Timestamp: at,
+			Compliance: api.ComplianceData{Queries: results},
+		}
+	}
+	passwordControls := map[string][]string{"NIST 800-171": {"3.5.7", "3.5.8"}, "CIS": {"1.1.4"}}
+
+	policies := []Policy{
+		{Status: "active", PolicyData: `{"metadata":{"report_title":"Baseline"},"queries":[
+			{"name":"Firewall","controls":{"NIST 800-171":["3.13.1"]}}]}`},
+		{Status: "draft", PolicyData: `{"metadata":{"report_title":"Baseline"},"queries":[
+			{"name":"Audit","controls":{"NIST 800-171":["3.3.1"]}}]}`},
+		{Status: "active", PolicyData: `not json`},
+	}
+	history := []*api.ComplianceSubmission{
+		// Superseded by c1's next submission
+		submission("c1", day, api.QueryResult{Name: "Password", Status: "pass", Controls: passwordControls}),
+		submission("c1", day.Add(24*time.Hour),
+			api.QueryResult{Name: "Password", Status: "fail", Controls: passwordControls},
+			api.QueryResult{Name: "Firewall", Status: "pass"},
+			api.QueryResult{Name: "Audit", Status: "fail"},
+		),
+		submission("c2", day,
+			api.QueryResult{Name: "Password", Status: "pass", Controls: passwordControls},
+			api.QueryResult{Name: "Firewall", Status: "error"},
+		),
+	}
+
+	families := summarizeControlFamilies(history, buildControlIndex(policies), "")
+	want := []api.ControlFamily{
+		{Framework: "CIS", Family: "1", Controls: []string{"1.1.4"}, Checks: 2, Passed: 1, Failed: 1, PassRate: 50, ClientsFailing: 1},
+		{Framework: "NIST 800-171", Family: "3.5", Controls: []string{"3.5.7", "3.5.8"}, Checks: 2, Passed: 1, Failed: 1, PassRate: 50, ClientsFailing: 1},
+		{Framework: "NIST 800-171", Family: "3.13", Controls: []string{"3.13.1"}, Checks: 1, Passed: 1, PassRate: 100},
+	}
+	if !reflect.DeepEqual(families, want) {
+		t.Errorf("summarizeControlFamilies() =\n%+v\nwant\n%+v", families, want)
+	}
+
+	families = summarizeControlFamilies(history, buildControlIndex(policies), "cis")
+	if len(families) != 1 || families[0].Framework != "CIS" {
+		t.Errorf("framework filter returned %+v", families)
+	}
+}
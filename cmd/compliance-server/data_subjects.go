@@ -0,0 +1,633 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// dataSubjectErased replaces a purged data subject's name in the records
+// that are kept, such as the admin audit trail
+const dataSubjectErased = "[erased]"
+
+// dataSubjectRedactBatch is how many submissions a user purge loads at once
+const dataSubjectRedactBatch = 100
+
+var (
+	dataSubjectHostnamePattern = regexp.MustCompile(`^[a-z0-9_][a-z0-9_.-]{0,252}$`)
+	// At least two characters, so a purge cannot erase every "a" in the evidence
+	dataSubjectUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.@$\\-]{1,255}$`)
+)
+
+// normalizeDataSubject trims and checks a data subject request. Hostnames
+// are lowercased and lose a trailing dot.
+func normalizeDataSubject(request api.DataSubjectRequest) (api.DataSubjectRequest, error) {
+	request.Hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(request.Hostname)), ".")
+	request.User = strings.TrimSpace(request.User)
+	if request.Hostname == "" && request.User == "" {
+		return request, errors.New("hostname or user is required")
+	}
+	if request.Hostname != "" && !dataSubjectHostnamePattern.MatchString(request.Hostname) {
+		return request, errors.New("invalid hostname")
+	}
+	if request.User != "" && !dataSubjectUserPattern.MatchString(request.User) {
+		return request, errors.New("invalid user: use 2 to 256 letters, digits and . _ @ $ - \\ characters")
+	}
+	return request, nil
+}
+
+// dataSubjectPattern returns a case-insensitive PostgreSQL regular
+// expression (for ~*) matching word where it is not part of a longer name.
+// With qualified, the word may be followed by a domain, so a short hostname
+// also matches its fully qualified names.
+func dataSubjectPattern(word string, qualified bool) string {
+	end := `($|[^A-Za-z0-9_.-])`
+	if qualified {
+		end = `($|[^A-Za-z0-9_-])`
+	}
+	return `(^|[^A-Za-z0-9_.-])` + regexp.QuoteMeta(word) + end
+}
+
+// isDataSubjectNameChar reports whether c can be part of a name, so a
+// word next to it is part of a longer name (dataSubjectPattern unqualified)
+func isDataSubjectNameChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '-'
+}
+
+// redactWord replaces word, a case-insensitive match of a name, with
+// dataSubjectErased in text wherever it is not part of a longer name, and
+// reports whether it did
+func redactWord(text string, word *regexp.Regexp) (string, bool) {
+	var b strings.Builder
+	last := 0
+	for _, loc := range word.FindAllStringIndex(text, -1) {
+		if loc[0] > 0 && isDataSubjectNameChar(text[loc[0]-1]) ||
+			loc[1] < len(text) && isDataSubjectNameChar(text[loc[1]]) {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(dataSubjectErased)
+		last = loc[1]
+	}
+	if last == 0 {
+		return text, false
+	}
+	b.WriteString(text[last:])
+	return b.String(), true
+}
+
+// redactDocument replaces the mentions of word in a stored document. In
+// JSON only string values are rewritten, so keys and escapes stay intact
+// and the document keeps its structure; other text is rewritten as a
+// whole. It reports whether anything was replaced.
+func redactDocument(doc string, word *regexp.Regexp) (string, bool) {
+	if !json.Valid([]byte(doc)) {
+		return redactWord(doc, word)
+	}
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return redactWord(doc, word)
+	}
+
+	changed := false
+	var redact func(value interface{}) interface{}
+	redact = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			text, ok := redactWord(v, word)
+			changed = changed || ok
+			return text
+		case []interface{}:
+			for i := range v {
+				v[i] = redact(v[i])
+			}
+		case map[string]interface{}:
+			for key := range v {
+				v[key] = redact(v[key])
+			}
+		}
+		return value
+	}
+	value = redact(value)
+	if !changed {
+		return doc, false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return redactWord(doc, word)
+	}
+	return string(data), true
+}
+
+// hostnameCondition matches column against a normalized hostname. A short
+// name matches the first label of qualified names, so "laptop-42" finds
+// "LAPTOP-42.corp.example.com" too.
+func hostnameCondition(column safesql.Query, hostname string) safesql.Query {
+	if strings.Contains(hostname, ".") {
+		return safesql.New("LOWER(").AppendQuery(column).Append(") = $1", hostname)
+	}
+	return safesql.New("split_part(LOWER(").AppendQuery(column).Append("), '.', 1) = $1", hostname)
+}
+
+// newDataSubjectReportID returns a random identifier for a deletion report
+func newDataSubjectReportID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "dsr-" + hex.EncodeToString(b), nil
+}
+
+// signDataSubjectReport signs the report as encoded, so the signature can
+// be checked against the bytes a recipient stores
+func signDataSubjectReport(key ed25519.PrivateKey, report *api.DataSubjectReport) (*api.SignedDataSubjectReport, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return &api.SignedDataSubjectReport{
+		Report:    data,
+		Algorithm: "ed25519",
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}, nil
+}
+
+// decodeDataSubject reads and checks the request body of the data subject
+// endpoints, answering the request when it is invalid
+func (s *ComplianceServer) decodeDataSubject(w http.ResponseWriter, r *http.Request) (api.DataSubjectRequest, bool) {
+	var request api.DataSubjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return request, false
+	}
+	request, err := normalizeDataSubject(request)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return request, false
+	}
+	return request, true
+}
+
+// handleLocateDataSubject reports the records referencing a hostname or
+// user without changing them (POST /api/v1/data-subjects/locate). The
+// subject is sent in the body so it stays out of access logs.
+func (s *ComplianceServer) handleLocateDataSubject(w http.ResponseWriter, r *http.Request) {
+	subject, ok := s.decodeDataSubject(w, r)
+	if !ok {
+		return
+	}
+
+	report, _, err := s.db.PurgeDataSubject(subject, true)
+	if err != nil {
+		s.logger.Error("Failed to locate data subject", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to locate data subject")
+		return
+	}
+	report.PerformedBy = s.auditActor(r)
+	if s.attachments != nil {
+		report.Files = report.Deleted["submission_attachments"]
+	}
+
+	s.respond(w, r, report, nil)
+}
+
+// handlePurgeDataSubject deletes the records of a hostname or user and
+// redacts the references kept records make to it, answering with a report
+// signed with the server's signing key (POST /api/v1/data-subjects/purge)
+func (s *ComplianceServer) handlePurgeDataSubject(w http.ResponseWriter, r *http.Request) {
+	if s.commandKey == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "Command signing is not initialized")
+		return
+	}
+	subject, ok := s.decodeDataSubject(w, r)
+	if !ok {
+		return
+	}
+
+	report, keys, err := s.db.PurgeDataSubject(subject, false)
+	if err != nil {
+		s.logger.Error("Failed to purge data subject", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to purge data subject")
+		return
+	}
+	report.PerformedBy = s.auditActor(r)
+
+	// The rows are gone; files that cannot be deleted are listed for the
+	// administrator, as the orphan sweep can no longer find them
+	if s.attachments != nil {
+		for _, key := range keys {
+			if err := s.attachments.Delete(context.Background(), key); err != nil {
+				s.logger.Warn("Failed to delete attachment file", "error", err, "key", key)
+				report.Notes = append(report.Notes, fmt.Sprintf("Attachment file %s could not be deleted from the attachment store", key))
+				continue
+			}
+			report.Files++
+		}
+	}
+
+	// Cached dashboard summaries count the deleted clients until they expire
+	if s.cache != nil {
+		if err := s.cache.DeletePrefix(context.Background(), dashboardSummaryKeyPrefix); err != nil {
+			s.logger.Warn("Failed to clear cached dashboard summaries", "error", err)
+			report.Notes = append(report.Notes, fmt.Sprintf("Cached dashboard summaries could not be cleared; they expire within %s", s.config().Cache.SummaryTTL))
+		}
+	}
+	report.Notes = append(report.Notes, "Server and client log files are not searched")
+
+	signed, err := signDataSubjectReport(s.commandKey, report)
+	if err != nil {
+		s.logger.Error("Failed to sign data subject report", "error", err, "report_id", report.ReportID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to sign data subject report")
+		return
+	}
+
+	// Neither the log nor the audit trail names the subject
+	s.logger.Info("Purged data subject", "report_id", report.ReportID, "clients", len(report.ClientIDs),
+		"submissions", report.Deleted["submissions"])
+	noteAdminChange(r, report.ReportID, nil, map[string]interface{}{
+		"client_ids": report.ClientIDs,
+		"deleted":    report.Deleted,
+		"redacted":   report.Redacted,
+	})
+	s.respond(w, r, signed, nil)
+}
+
+// dataSubjectUserColumns are the columns naming the user who made a record.
+// A purged user's name in them is replaced with dataSubjectErased.
+var dataSubjectUserColumns = []struct {
+	table, column safesql.Query
+	name          string
+}{
+	{safesql.New("client_commands"), safesql.New("created_by"), "client_commands.created_by"},
+	{safesql.New("client_commands"), safesql.New("approved_by"), "client_commands.approved_by"},
+	{safesql.New("command_audit"), safesql.New("actor"), "command_audit.actor"},
+	{safesql.New("alerts"), safesql.New("acknowledged_by"), "alerts.acknowledged_by"},
+	{safesql.New("alert_rules"), safesql.New("created_by"), "alert_rules.created_by"},
+	{safesql.New("maintenance_windows"), safesql.New("created_by"), "maintenance_windows.created_by"},
+	{safesql.New("client_policies"), safesql.New("assigned_by"), "client_policies.assigned_by"},
+	{safesql.New("group_policies"), safesql.New("assigned_by"), "group_policies.assigned_by"},
+	{safesql.New("policy_rollouts"), safesql.New("started_by"), "policy_rollouts.started_by"},
+	{safesql.New("notification_templates"), safesql.New("updated_by"), "notification_templates.updated_by"},
+	{safesql.New("submission_attachments"), safesql.New("uploaded_by"), "submission_attachments.uploaded_by"},
+	{safesql.New("api_keys"), safesql.New("created_by"), "api_keys.created_by"},
+}
+
+// PurgeDataSubject deletes the records of a data subject and redacts the
+// references kept records make to it, in one transaction:
+//
+//   - a hostname deletes its clients with their submissions, telemetry,
+//     alerts, commands, maintenance windows and policy assignments, and the
+//     admin audit entries about them lose their target and values
+//   - a user deletes its auth audit entries, and its name is replaced
+//     wherever it is recorded as the author of a change and in the
+//     results, evidence, system information and metadata of submissions.
+//     Submissions are not deleted for a user: names such as Administrator
+//     appear in the evidence of most of the fleet.
+//
+// With dryRun the transaction is rolled back, so the report counts what a
+// purge would do. The storage keys of the deleted attachments are returned
+// for the caller to delete once the purge is committed. Retention archives
+// are not rewritten; the report lists those holding the subject's
+// submissions.
+func (d *Database) PurgeDataSubject(subject api.DataSubjectRequest, dryRun bool) (*api.DataSubjectReport, []string, error) {
+	reportID, err := newDataSubjectReportID()
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &api.DataSubjectReport{
+		ReportID:    reportID,
+		Hostname:    subject.Hostname,
+		User:        subject.User,
+		DryRun:      dryRun,
+		ClientIDs:   []string{},
+		Deleted:     map[string]int64{},
+		Redacted:    map[string]int64{},
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin purge: %w", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(counts map[string]int64, name string, query safesql.Query) error {
+		result, err := tx.Exec(query)
+		if err != nil {
+			return fmt.Errorf("failed to purge %s: %w", name, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		counts[name] += n
+		return nil
+	}
+
+	if subject.Hostname != "" {
+		rows, err := tx.Query(safesql.New(`SELECT client_id FROM clients WHERE `).
+			AppendQuery(hostnameCondition(safesql.New("hostname"), subject.Hostname)).
+			Append(` ORDER BY client_id`))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find clients: %w", err)
+		}
+		for rows.Next() {
+			var clientID string
+			if err := rows.Scan(&clientID); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("failed to scan client: %w", err)
+			}
+			report.ClientIDs = append(report.ClientIDs, clientID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to find clients: %w", err)
+		}
+	}
+	clientIDs := pq.Array(report.ClientIDs)
+
+	// Submissions go first so their attachments and rollups can follow
+	var submissionIDs []string
+	affected := map[string]bool{}
+	if subject.Hostname != "" {
+		conditions := []safesql.Query{
+			safesql.New("client_id = ANY($1)", clientIDs),
+			hostnameCondition(safesql.New("hostname"), subject.Hostname),
+		}
+		rows, err := tx.Query(safesql.New(`DELETE FROM submissions WHERE `).
+			AppendQuery(safesql.Join(conditions, " OR ")).
+			Append(` RETURNING submission_id, client_id`))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to purge submissions: %w", err)
+		}
+		for rows.Next() {
+			var submissionID, clientID string
+			if err := rows.Scan(&submissionID, &clientID); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("failed to scan submission: %w", err)
+			}
+			submissionIDs = append(submissionIDs, submissionID)
+			affected[clientID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to purge submissions: %w", err)
+		}
+	}
+	report.Deleted["submissions"] = int64(len(submissionIDs))
+
+	var keys []string
+	rows, err := tx.Query(safesql.New(`DELETE FROM submission_attachments WHERE submission_id = ANY($1) RETURNING storage_key`,
+		pq.Array(submissionIDs)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to purge attachments: %w", err)
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to purge attachments: %w", err)
+	}
+	report.Deleted["submission_attachments"] = int64(len(keys))
+
+	// Archives are listed before their index rows go
+	rows, err = tx.Query(safesql.New(`
+		SELECT DISTINCT a.path FROM submission_archives a
+		JOIN archived_submissions s ON s.archive_id = a.id
+		WHERE s.client_id = ANY($1)
+		ORDER BY a.path
+	`, clientIDs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find archives: %w", err)
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan archive: %w", err)
+		}
+		report.ArchiveFiles = append(report.ArchiveFiles, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to find archives: %w", err)
+	}
+
+	if subject.Hostname != "" {
+		deletes := []struct {
+			name  string
+			query safesql.Query
+		}{
+			{"agent_telemetry", safesql.New(`DELETE FROM agent_telemetry WHERE client_id = ANY($1)`, clientIDs)},
+			{"alerts", safesql.New(`DELETE FROM alerts WHERE client_id = ANY($1) OR `, clientIDs).
+				AppendQuery(hostnameCondition(safesql.New("hostname"), subject.Hostname))},
+			// command_audit has no foreign key to client_commands
+			{"command_audit", safesql.New(`DELETE FROM command_audit WHERE command_id IN
+				(SELECT id FROM client_commands WHERE client_id = ANY($1))`, clientIDs)},
+			{"client_commands", safesql.New(`DELETE FROM client_commands WHERE client_id = ANY($1)`, clientIDs)},
+			{"maintenance_windows", safesql.New(`DELETE FROM maintenance_windows WHERE client_id = ANY($1)`, clientIDs)},
+			{"client_policies", safesql.New(`DELETE FROM client_policies WHERE client_id = ANY($1)`, clientIDs)},
+			{"archived_submissions", safesql.New(`DELETE FROM archived_submissions WHERE client_id = ANY($1)`, clientIDs)},
+		}
+		for _, del := range deletes {
+			if err := exec(report.Deleted, del.name, del.query); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// Clients that keep other submissions get their rollups rebuilt; the
+	// rollups of deleted clients go with them
+	var rebuild []string
+	for clientID := range affected {
+		rebuild = append(rebuild, clientID)
+	}
+	if len(rebuild) > 0 {
+		if err := d.rebuildRollups(tx, rebuild); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if subject.Hostname != "" {
+		// Tags, rollups and submission states cascade
+		if err := exec(report.Deleted, "clients", safesql.New(`DELETE FROM clients WHERE client_id = ANY($1)`, clientIDs)); err != nil {
+			return nil, nil, err
+		}
+
+		pattern := dataSubjectPattern(subject.Hostname, !strings.Contains(subject.Hostname, "."))
+		err := exec(report.Redacted, "admin_audit_log", safesql.New(`
+			UPDATE admin_audit_log SET target = $3, before_value = NULL, after_value = NULL
+			WHERE target = ANY($1) OR target ~* $2 OR before_value ~* $2 OR after_value ~* $2
+		`, clientIDs, pattern, dataSubjectErased))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if subject.User != "" {
+		user := strings.ToLower(subject.User)
+		pattern := dataSubjectPattern(subject.User, false)
+
+		if err := exec(report.Deleted, "auth_audit_log", safesql.New(`DELETE FROM auth_audit_log WHERE LOWER(username) = $1`, user)); err != nil {
+			return nil, nil, err
+		}
+		err := exec(report.Redacted, "admin_audit_log", safesql.New(`
+			UPDATE admin_audit_log SET
+				actor = CASE WHEN LOWER(actor) = $1 THEN $3 ELSE actor END,
+				target = CASE WHEN target ~* $2 THEN $3 ELSE target END,
+				before_value = CASE WHEN before_value ~* $2 THEN NULL ELSE before_value END,
+				after_value = CASE WHEN after_value ~* $2 THEN NULL ELSE after_value END
+			WHERE LOWER(actor) = $1 OR target ~* $2 OR before_value ~* $2 OR after_value ~* $2
+		`, user, pattern, dataSubjectErased))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		redacted, err := redactSubmissions(tx, subject.User, pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Redacted["submissions"] = redacted
+
+		for _, col := range dataSubjectUserColumns {
+			query := safesql.New("UPDATE ").AppendQuery(col.table).
+				Append(" SET ").AppendQuery(col.column).Append(" = $1 WHERE LOWER(", dataSubjectErased).
+				AppendQuery(col.column).Append(") = $1", user)
+			if err := exec(report.Redacted, col.name, query); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		var account bool
+		err = tx.QueryRow(safesql.New(`SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(username) = $1)`, user)).Scan(&account)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query users: %w", err)
+		}
+		if account {
+			report.Notes = append(report.Notes, "The user's login account still exists; delete it under Users to remove it and its policy ownership")
+		}
+	}
+
+	if len(report.ArchiveFiles) > 0 {
+		report.Notes = append(report.Notes, "Retention archives are not rewritten; delete the archive files listed or remove the subject's submissions from them")
+	}
+	if subject.User != "" {
+		report.Notes = append(report.Notes, "Retention archives are not searched for the user")
+	}
+
+	if dryRun {
+		return report, nil, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return report, keys, nil
+}
+
+// redactSubmissions replaces a user's name with dataSubjectErased in the
+// submissions whose results, evidence, system information or metadata
+// match pattern (dataSubjectPattern), and returns how many it changed.
+// Submissions are loaded in batches, as a common name can be in most of
+// them.
+func redactSubmissions(tx *safesql.Tx, user, pattern string) (int64, error) {
+	rows, err := tx.Query(safesql.New(`
+		SELECT submission_id FROM submissions
+		WHERE compliance_data ~* $1 OR evidence ~* $1 OR system_info ~* $1 OR metadata::text ~* $1
+		ORDER BY submission_id
+	`, pattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find submissions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find submissions: %w", err)
+	}
+
+	type submissionDocuments struct {
+		id   string
+		docs [4]sql.NullString // compliance_data, evidence, system_info, metadata
+	}
+	word := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(user))
+	var redacted int64
+	for start := 0; start < len(ids); start += dataSubjectRedactBatch {
+		batch := ids[start:min(start+dataSubjectRedactBatch, len(ids))]
+		rows, err := tx.Query(safesql.New(`
+			SELECT submission_id, compliance_data, evidence, system_info, metadata::text
+			FROM submissions WHERE submission_id = ANY($1)
+		`, pq.Array(batch)))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read submissions: %w", err)
+		}
+		var submissions []submissionDocuments
+		for rows.Next() {
+			var sub submissionDocuments
+			if err := rows.Scan(&sub.id, &sub.docs[0], &sub.docs[1], &sub.docs[2], &sub.docs[3]); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan submission: %w", err)
+			}
+			submissions = append(submissions, sub)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to read submissions: %w", err)
+		}
+
+		for _, sub := range submissions {
+			changed := false
+			for i, doc := range sub.docs {
+				if !doc.Valid {
+					continue
+				}
+				text, ok := redactDocument(doc.String, word)
+				sub.docs[i].String = text
+				changed = changed || ok
+			}
+			if !changed {
+				continue
+			}
+			_, err := tx.Exec(safesql.New(`
+				UPDATE submissions
+				SET compliance_data = $2, evidence = $3, system_info = $4, metadata = $5::jsonb
+				WHERE submission_id = $1
+			`, sub.id, sub.docs[0], sub.docs[1], sub.docs[2], sub.docs[3]))
+			if err != nil {
+				return 0, fmt.Errorf("failed to redact submission: %w", err)
+			}
+			redacted++
+		}
+	}
+	return redacted, nil
+}
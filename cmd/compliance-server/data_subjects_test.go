@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// TestNormalizeDataSubject tests the hostnames and users a purge accepts
+func TestNormalizeDataSubject(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      api.DataSubjectRequest
+		wantHostname string
+		wantErr      bool
+	}{
+		{"hostname", api.DataSubjectRequest{Hostname: " LAPTOP-42.corp.example.com. "}, "laptop-42.corp.example.com", false},
+		{"user", api.DataSubjectRequest{User: `CORP\jdoe`}, "", false},
+		{"both", api.DataSubjectRequest{Hostname: "laptop-42", User: "jdoe@example.com"}, "laptop-42", false},
+		{"neither", api.DataSubjectRequest{}, "", true},
+		{"hostname with wildcard", api.DataSubjectRequest{Hostname: "laptop-%"}, "", true},
+		{"one-letter user", api.DataSubjectRequest{User: "a"}, "", true},
+		{"user with pattern", api.DataSubjectRequest{User: "j.*"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeDataSubject(tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeDataSubject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", got.Hostname, tt.wantHostname)
+			}
+		})
+	}
+}
+
+// TestDataSubjectPattern tests that a name matches only as a whole word,
+// checked with Go's regexp, which agrees with PostgreSQL on these patterns
+func TestDataSubjectPattern(t *testing.T) {
+	tests := []struct {
+		word      string
+		qualified bool
+		text      string
+		want      bool
+	}{
+		{"jdoe", false, `{"path":"C:\\Users\\jdoe\\Desktop"}`, true},
+		{"jdoe", false, "Owner: JDOE", true},
+		{"jdoe", false, "jdoe@example.com", true},
+		{"jdoe", false, "jdoe2", false},
+		{"jdoe", false, "ajdoe", false},
+		{"jdoe", false, "jdoe.smith", false},
+		{"j.doe", false, "jxdoe", false},
+		{"laptop-42", true, "laptop-42.corp.example.com", true},
+		{"laptop-42", true, "laptop-421", false},
+		{"laptop-42", true, "laptop-42-old", false},
+		{"laptop-42.corp", false, "laptop-42.corp.example.com", false},
+	}
+
+	for _, tt := range tests {
+		re := regexp.MustCompile("(?i)" + dataSubjectPattern(tt.word, tt.qualified))
+		if got := re.MatchString(tt.text); got != tt.want {
+			t.Errorf("dataSubjectPattern(%q, %v) matches %q = %v, want %v", tt.word, tt.qualified, tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestRedactDocument tests that a user's name is replaced where
+// dataSubjectPattern finds it, in JSON string values only
+func TestRedactDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    string
+		changed bool
+	}{
+		{"profile path", `{"path":"C:\\Users\\JDoe\\Desktop","size":10}`, `{"path":"C:\\Users\\[erased]\\Desktop","size":10}`, true},
+		{"every mention", `["jdoe jdoe","Owner: jdoe"]`, `["[erased] [erased]","Owner: [erased]"]`, true},
+		{"longer names", `["jdoe2","jdoe.smith","ajdoe"]`, `["jdoe2","jdoe.smith","ajdoe"]`, false},
+		{"keys kept", `{"jdoe":true}`, `{"jdoe":true}`, false},
+		{"large numbers kept", `{"owner":"jdoe","id":12345678901234567890}`, `{"id":12345678901234567890,"owner":"[erased]"}`, true},
+		{"plain text", `logged on: jdoe`, `logged on: [erased]`, true},
+	}
+
+	word := regexp.MustCompile(`(?i)` + regexp.QuoteMeta("jdoe"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := redactDocument(tt.doc, word)
+			if got != tt.want || changed != tt.changed {
+				t.Errorf("redactDocument() = %s, %v; want %s, %v", got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+// TestHostnameCondition tests that short names match the first label
+func TestHostnameCondition(t *testing.T) {
+	short := hostnameCondition(safesql.New("hostname"), "laptop-42")
+	if got := short.String(); got != "split_part(LOWER(hostname), '.', 1) = $1" {
+		t.Errorf("short name condition = %s", got)
+	}
+	qualified := hostnameCondition(safesql.New("hostname"), "laptop-42.corp")
+	if got := qualified.String(); got != "LOWER(hostname) = $1" {
+		t.Errorf("qualified name condition = %s", got)
+	}
+}
+
+// TestSignDataSubjectReport tests that the signature verifies against the
+// report bytes sent and the public signing key
+func TestSignDataSubjectReport(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &api.DataSubjectReport{ReportID: "dsr-1", Hostname: "laptop-42", ClientIDs: []string{"c1"},
+		Deleted: map[string]int64{"clients": 1}}
+
+	signed, err := signDataSubjectReport(private, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The envelope round-trips without changing the signed bytes
+	data, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received api.SignedDataSubjectReport
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(received.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Algorithm != "ed25519" || !ed25519.Verify(public, received.Report, signature) {
+		t.Error("signature does not verify")
+	}
+
+	tampered := []byte(string(received.Report[:len(received.Report)-1]) + `,"x":1}`)
+	if ed25519.Verify(public, tampered, signature) {
+		t.Error("signature verifies a changed report")
+	}
+}
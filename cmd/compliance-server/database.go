@@ -1,29 +1,77 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/auth"
+	"compliancetoolkit/pkg/safesql"
 )
 
 // Database handles all database operations (PostgreSQL only)
 type Database struct {
-	db     *sql.DB
+	db     *timedDB // Statements are timed for /metrics
 	logger *slog.Logger
+	scope  *api.ClientScope // Clients this view is limited to; nil for all (see WithScope)
 }
 
-// NewDatabase creates and initializes a new PostgreSQL database connection
+// NewDatabase connects to PostgreSQL and brings the schema up to date. With
+// database.auto_migrate disabled it refuses a schema with pending migrations
+// instead of applying them.
 func NewDatabase(config DatabaseSettings, logger *slog.Logger) (*Database, error) {
+	database, err := connectDatabase(config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AutoMigrate {
+		applied, err := database.MigrateUp(false)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to migrate schema: %w", err)
+		}
+		if len(applied) > 0 {
+			logger.Info("Database schema migrated", "applied", len(applied))
+		}
+	} else {
+		pending, err := database.PendingMigrationCount()
+		if err != nil {
+			database.Close()
+			return nil, err
+		}
+		if pending > 0 {
+			database.Close()
+			return nil, fmt.Errorf("database schema is %d migration(s) behind; run compliance-server --migrate up", pending)
+		}
+	}
+
+	logger.Info("Database initialized",
+		"type", "postgres",
+		"host", config.Host,
+		"database", config.Name,
+		"max_open_conns", config.MaxOpenConns,
+	)
+	return database, nil
+}
+
+// connectDatabase opens the PostgreSQL connection pool without touching the
+// schema
+func connectDatabase(config DatabaseSettings, logger *slog.Logger) (*Database, error) {
 	// Build PostgreSQL connection string
+	// Sessions run in UTC, so CURRENT_TIMESTAMP defaults and NOW() store UTC
+	// like the times the server writes
 	connString := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s timezone=UTC",
 		config.Host,
 		config.Port,
 		config.User,
@@ -40,258 +88,33 @@ func NewDatabase(config DatabaseSettings, logger *slog.Logger) (*Database, error
 
 	// Test connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Configure connection pool for PostgreSQL
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
-	database := &Database{
-		db:     db,
+	return &Database{
+		db:     &timedDB{DB: db},
 		logger: logger,
-	}
-
-	// Initialize schema
-	if err := database.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	logger.Info("Database initialized", "type", "postgres", "host", config.Host, "database", config.Name)
-	return database, nil
-}
-
-// getAutoIncrementType returns PostgreSQL auto-increment syntax
-func (d *Database) getAutoIncrementType() string {
-	return "SERIAL PRIMARY KEY"
-}
-
-// getPartialIndexSQL returns PostgreSQL partial index SQL for refresh_tokens.revoked
-func (d *Database) getPartialIndexSQL() string {
-	return "CREATE INDEX IF NOT EXISTS idx_refresh_tokens_revoked ON refresh_tokens(revoked) WHERE revoked = false;"
-}
-
-// placeholder returns PostgreSQL positional placeholder ($1, $2, $3...)
-func (d *Database) placeholder(n int) string {
-	return fmt.Sprintf("$%d", n)
-}
-
-// getBooleanDefault returns PostgreSQL boolean literal (true/false)
-func (d *Database) getBooleanDefault(value bool) string {
-	if value {
-		return "true"
-	}
-	return "false"
-}
-
-// getDateTimeSubtract returns PostgreSQL datetime subtraction SQL
-func (d *Database) getDateTimeSubtract(hours int) string {
-	return fmt.Sprintf("CURRENT_TIMESTAMP - INTERVAL '%d hours'", hours)
-}
-
-// initSchema creates database tables if they don't exist
-func (d *Database) initSchema() error {
-	autoIncrement := d.getAutoIncrementType()
-	boolTrue := d.getBooleanDefault(true)
-	boolFalse := d.getBooleanDefault(false)
-
-	schema := fmt.Sprintf(`
-	-- Clients table
-	CREATE TABLE IF NOT EXISTS clients (
-		id %s,
-		client_id TEXT UNIQUE NOT NULL,
-		hostname TEXT NOT NULL,
-		first_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		os_version TEXT,
-		build_number TEXT,
-		architecture TEXT,
-		domain TEXT,
-		ip_address TEXT,
-		mac_address TEXT,
-		status TEXT DEFAULT 'active',
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	-- Submissions table
-	CREATE TABLE IF NOT EXISTS submissions (
-		id %s,
-		submission_id TEXT UNIQUE NOT NULL,
-		client_id TEXT NOT NULL,
-		hostname TEXT NOT NULL,
-		timestamp TIMESTAMP NOT NULL,
-		report_type TEXT NOT NULL,
-		report_version TEXT,
-		overall_status TEXT,
-		total_checks INTEGER DEFAULT 0,
-		passed_checks INTEGER DEFAULT 0,
-		failed_checks INTEGER DEFAULT 0,
-		warning_checks INTEGER DEFAULT 0,
-		error_checks INTEGER DEFAULT 0,
-		compliance_data TEXT,  -- JSON
-		evidence TEXT,         -- JSON array
-		system_info TEXT,      -- JSON
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (client_id) REFERENCES clients(client_id)
-	);
-
-	-- Policies table
-	CREATE TABLE IF NOT EXISTS policies (
-		id %s,
-		policy_id TEXT UNIQUE NOT NULL,
-		name TEXT NOT NULL,
-		description TEXT,
-		framework TEXT,  -- NIST, FIPS, CIS, etc.
-		version TEXT,
-		category TEXT,
-		author TEXT,
-		status TEXT DEFAULT 'active',  -- active, inactive, draft
-		policy_data TEXT NOT NULL,  -- JSON policy configuration
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	-- Client policy assignments (for future use)
-	CREATE TABLE IF NOT EXISTS client_policies (
-		id %s,
-		client_id TEXT NOT NULL,
-		policy_id TEXT NOT NULL,
-		assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		assigned_by TEXT,
-		FOREIGN KEY (client_id) REFERENCES clients(client_id),
-		FOREIGN KEY (policy_id) REFERENCES policies(policy_id),
-		UNIQUE(client_id, policy_id)
-	);
-
-	-- Users table for authentication
-	CREATE TABLE IF NOT EXISTS users (
-		id %s,
-		username TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		role TEXT NOT NULL CHECK(role IN ('admin', 'viewer', 'auditor')),
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_login TIMESTAMP
-	);
-
-	-- API Keys table for secure key management
-	CREATE TABLE IF NOT EXISTS api_keys (
-		id %s,
-		name TEXT NOT NULL,
-		key_hash TEXT NOT NULL,
-		key_prefix TEXT NOT NULL,  -- First 8 chars for display
-		created_by TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_used TIMESTAMP,
-		expires_at TIMESTAMP,
-		is_active BOOLEAN DEFAULT %s
-	);
-
-	-- Indexes for performance
-	CREATE INDEX IF NOT EXISTS idx_submissions_client_id ON submissions(client_id);
-	CREATE INDEX IF NOT EXISTS idx_submissions_timestamp ON submissions(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_submissions_report_type ON submissions(report_type);
-	CREATE INDEX IF NOT EXISTS idx_clients_status ON clients(status);
-	CREATE INDEX IF NOT EXISTS idx_policies_framework ON policies(framework);
-	CREATE INDEX IF NOT EXISTS idx_policies_status ON policies(status);
-	CREATE INDEX IF NOT EXISTS idx_client_policies_client_id ON client_policies(client_id);
-	CREATE INDEX IF NOT EXISTS idx_client_policies_policy_id ON client_policies(policy_id);
-	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-
-	-- JWT Authentication Tables (Phase 1 Migration)
-
-	-- Refresh tokens table for JWT authentication
-	CREATE TABLE IF NOT EXISTS refresh_tokens (
-		id TEXT PRIMARY KEY,
-		user_id INTEGER NOT NULL,
-		token_hash TEXT NOT NULL,
-		token_family TEXT,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_used TIMESTAMP,
-		revoked BOOLEAN DEFAULT %s,
-		revoked_at TIMESTAMP,
-		revoked_reason TEXT,
-		user_agent TEXT,
-		ip_address TEXT,
-		device_fingerprint TEXT,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);
-
-	-- JWT blacklist for immediate token revocation
-	CREATE TABLE IF NOT EXISTS jwt_blacklist (
-		jti TEXT PRIMARY KEY,
-		user_id INTEGER NOT NULL,
-		expires_at TIMESTAMP NOT NULL,
-		blacklisted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		reason TEXT,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);
-
-	-- Auth audit log for security monitoring
-	CREATE TABLE IF NOT EXISTS auth_audit_log (
-		id %s,
-		user_id INTEGER,
-		username TEXT,
-		event_type TEXT NOT NULL,
-		auth_method TEXT,
-		ip_address TEXT,
-		user_agent TEXT,
-		success BOOLEAN,
-		failure_reason TEXT,
-		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		metadata TEXT
-	);
-
-	-- JWT-specific indexes
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
-	%s
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token_family ON refresh_tokens(token_family);
-	CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_expires_at ON jwt_blacklist(expires_at);
-	CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_user_id ON jwt_blacklist(user_id);
-	CREATE INDEX IF NOT EXISTS idx_auth_audit_log_user_id ON auth_audit_log(user_id);
-	CREATE INDEX IF NOT EXISTS idx_auth_audit_log_timestamp ON auth_audit_log(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_auth_audit_log_event_type ON auth_audit_log(event_type);
-	`, autoIncrement, autoIncrement, autoIncrement, autoIncrement, autoIncrement, autoIncrement, boolTrue, boolFalse, autoIncrement,
-		d.getPartialIndexSQL())
-
-	if _, err := d.db.Exec(schema); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
-	}
-
-	// Add JWT columns to users table (ALTER TABLE)
-	jwtColumns := []string{
-		"ALTER TABLE users ADD COLUMN jwt_version INTEGER DEFAULT 1",
-		"ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP",
-		"ALTER TABLE users ADD COLUMN failed_login_attempts INTEGER DEFAULT 0",
-		"ALTER TABLE users ADD COLUMN account_locked_until TIMESTAMP",
-		fmt.Sprintf("ALTER TABLE users ADD COLUMN mfa_enabled BOOLEAN DEFAULT %s", boolFalse),
-		"ALTER TABLE users ADD COLUMN mfa_secret TEXT",
-	}
-
-	for _, alterSQL := range jwtColumns {
-		_, err := d.db.Exec(alterSQL)
-		if err != nil {
-			// Ignore "duplicate column name" errors (column already exists)
-			if !isColumnExistsError(err) {
-				return fmt.Errorf("failed to add JWT column: %w", err)
-			}
-		}
-	}
-
-	d.logger.Debug("Database schema initialized with JWT support")
-	return nil
+	}, nil
 }
 
-// isColumnExistsError checks if the error is due to a column already existing in PostgreSQL
-func isColumnExistsError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := err.Error()
-	// PostgreSQL returns: "pq: column \"xyz\" of relation \"table\" already exists"
-	return strings.Contains(errStr, "already exists")
+// WithContext returns a view of the database whose statements are canceled
+// when ctx is done, so a request's queries stop with the request. Work that
+// must finish regardless (submissions, audit entries) uses the database
+// itself.
+func (d *Database) WithContext(ctx context.Context) *Database {
+	if d == nil || d.db == nil {
+		return d
+	}
+	bound := *d
+	bound.db = &timedDB{DB: d.db.DB, ctx: ctx}
+	return &bound
 }
 
 // Ping checks if the database connection is alive
@@ -322,56 +145,175 @@ func (d *Database) SaveSubmission(submission *api.ComplianceSubmission) error {
 		return fmt.Errorf("failed to marshal system info: %w", err)
 	}
 
-	query := fmt.Sprintf(`
+	metadata, err := encodeMetadata(submission.Metadata)
+	if err != nil {
+		return err
+	}
+
+	const query = `
 		INSERT INTO submissions (
 			submission_id, client_id, hostname, timestamp, report_type, report_version,
 			overall_status, total_checks, passed_checks, failed_checks, warning_checks, error_checks,
-			compliance_data, evidence, system_info
-		) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4), d.placeholder(5),
-		d.placeholder(6), d.placeholder(7), d.placeholder(8), d.placeholder(9), d.placeholder(10),
-		d.placeholder(11), d.placeholder(12), d.placeholder(13), d.placeholder(14), d.placeholder(15))
-
-	_, err = d.db.Exec(query,
-		submission.SubmissionID,
-		submission.ClientID,
-		submission.Hostname,
-		submission.Timestamp.Format(time.RFC3339),
-		submission.ReportType,
-		submission.ReportVersion,
-		submission.Compliance.OverallStatus,
-		submission.Compliance.TotalChecks,
-		submission.Compliance.PassedChecks,
-		submission.Compliance.FailedChecks,
-		submission.Compliance.WarningChecks,
-		submission.Compliance.ErrorChecks,
-		complianceData,
-		evidence,
-		systemInfo,
-	)
+			compliance_data, evidence, system_info, during_maintenance, summary_only, metadata
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18::jsonb)
+	`
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(safesql.New(query, submission.SubmissionID, submission.ClientID, submission.Hostname, submission.Timestamp.UTC().Format(time.RFC3339), submission.ReportType, submission.ReportVersion, submission.Compliance.OverallStatus, submission.Compliance.TotalChecks, submission.Compliance.PassedChecks, submission.Compliance.FailedChecks, submission.Compliance.WarningChecks, submission.Compliance.ErrorChecks, complianceData, evidence, systemInfo, submission.DuringMaintenance, submission.SummaryOnly, metadata))
 
 	if err != nil {
 		return fmt.Errorf("failed to insert submission: %w", err)
 	}
+	if err := d.addSubmissionRollup(tx, submission); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit submission: %w", err)
+	}
+
+	if submission.Telemetry != nil {
+		if err := d.saveTelemetry(submission); err != nil {
+			// Telemetry is diagnostic only - never reject a stored submission over it
+			d.logger.Warn("Failed to save agent telemetry", "submission_id", submission.SubmissionID, "error", err)
+		}
+	}
 
 	d.logger.Debug("Saved submission", "submission_id", submission.SubmissionID)
 	return nil
 }
 
+// CountFullSubmissionsToday counts the submissions of a report type stored
+// today with their full evidence, for evidence sampling
+func (d *Database) CountFullSubmissionsToday(reportType string) (int, error) {
+	const query = `
+		SELECT COUNT(*) FROM submissions
+		WHERE report_type = $1 AND created_at >= CURRENT_DATE AND NOT summary_only
+	`
+
+	var count int
+	if err := d.db.QueryRow(safesql.New(query, reportType)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count full submissions: %w", err)
+	}
+	return count, nil
+}
+
+// saveTelemetry stores the agent telemetry block of a submission
+func (d *Database) saveTelemetry(submission *api.ComplianceSubmission) error {
+	t := submission.Telemetry
+	var build api.BuildInfo
+	if t.Build != nil {
+		build = *t.Build
+	}
+	const query = `
+		INSERT INTO agent_telemetry (
+			submission_id, client_id, timestamp, agent_version, scan_duration_ms, check_count,
+			check_p95_ms, check_max_ms, cache_backlog, retry_count, memory_alloc_bytes, memory_sys_bytes,
+			agent_commit, agent_build_time, agent_builder, agent_build_hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''))
+	`
+
+	_, err := d.db.Exec(safesql.New(query, submission.SubmissionID, submission.ClientID, submission.Timestamp.UTC().Format(time.RFC3339), t.AgentVersion, t.ScanDurationMs, t.CheckCount, t.CheckP95Ms, t.CheckMaxMs, t.CacheBacklog, t.RetryCount, int64(t.MemoryAllocBytes), int64(t.MemorySysBytes), build.Commit, build.BuildTime, build.Builder, build.BinarySHA256))
+	if err != nil {
+		return fmt.Errorf("failed to insert agent telemetry: %w", err)
+	}
+
+	return nil
+}
+
+// GetClientTelemetry retrieves the most recent telemetry samples for a client, oldest first
+func (d *Database) GetClientTelemetry(clientID string, limit int) ([]api.TelemetryPoint, error) {
+	scope := d.scopeCondition(safesql.New("t.client_id"))
+	query := safesql.New(`
+		SELECT * FROM (
+			SELECT t.submission_id, t.timestamp, s.report_type, t.agent_version, t.scan_duration_ms,
+			       t.check_count, t.check_p95_ms, t.check_max_ms, t.cache_backlog, t.retry_count,
+			       t.memory_alloc_bytes, t.memory_sys_bytes,
+			       t.agent_commit, t.agent_build_time, t.agent_builder, t.agent_build_hash
+			FROM agent_telemetry t
+			JOIN submissions s ON s.submission_id = t.submission_id
+			WHERE t.client_id = $1 AND `, clientID).
+		AppendQuery(scope).
+		Append(`
+			ORDER BY t.timestamp DESC
+			LIMIT $1
+		) recent
+		ORDER BY timestamp ASC
+	`, limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent telemetry: %w", err)
+	}
+	defer rows.Close()
+
+	points := []api.TelemetryPoint{}
+	for rows.Next() {
+		var p api.TelemetryPoint
+		var timestamp time.Time
+		var agentVersion, commit, buildTime, builder, buildHash sql.NullString
+		var memAlloc, memSys int64
+		err := rows.Scan(
+			&p.SubmissionID,
+			&timestamp,
+			&p.ReportType,
+			&agentVersion,
+			&p.ScanDurationMs,
+			&p.CheckCount,
+			&p.CheckP95Ms,
+			&p.CheckMaxMs,
+			&p.CacheBacklog,
+			&p.RetryCount,
+			&memAlloc,
+			&memSys,
+			&commit,
+			&buildTime,
+			&builder,
+			&buildHash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent telemetry: %w", err)
+		}
+
+		p.Timestamp = timestamp
+		p.AgentVersion = agentVersion.String
+		p.MemoryAllocBytes = uint64(memAlloc)
+		p.MemorySysBytes = uint64(memSys)
+		if commit.Valid || buildHash.Valid {
+			p.Build = &api.BuildInfo{
+				Version:      p.AgentVersion,
+				Commit:       commit.String,
+				BuildTime:    buildTime.String,
+				Builder:      builder.String,
+				BinarySHA256: buildHash.String,
+			}
+		}
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
 // GetSubmission retrieves a submission by ID
 func (d *Database) GetSubmission(submissionID string) (*api.ComplianceSubmission, error) {
-	query := fmt.Sprintf(`
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
 		SELECT submission_id, client_id, hostname, timestamp, report_type, report_version,
-		       compliance_data, evidence, system_info
+		       compliance_data, evidence, system_info, during_maintenance, summary_only, metadata
 		FROM submissions
-		WHERE submission_id = %s
-	`, d.placeholder(1))
+		WHERE submission_id = $1 AND `, submissionID).
+		AppendQuery(scope)
 
 	var submission api.ComplianceSubmission
 	var complianceData, evidence, systemInfo string
 	var timestampStr string
+	var metadata sql.NullString
 
-	err := d.db.QueryRow(query, submissionID).Scan(
+	err := d.db.QueryRow(query).Scan(
 		&submission.SubmissionID,
 		&submission.ClientID,
 		&submission.Hostname,
@@ -381,6 +323,9 @@ func (d *Database) GetSubmission(submissionID string) (*api.ComplianceSubmission
 		&complianceData,
 		&evidence,
 		&systemInfo,
+		&submission.DuringMaintenance,
+		&submission.SummaryOnly,
+		&metadata,
 	)
 
 	if err == sql.ErrNoRows {
@@ -391,7 +336,7 @@ func (d *Database) GetSubmission(submissionID string) (*api.ComplianceSubmission
 	}
 
 	// Parse timestamp from string
-	submission.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
+	submission.Timestamp, err = parseStoredTime(timestampStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 	}
@@ -406,17 +351,143 @@ func (d *Database) GetSubmission(submissionID string) (*api.ComplianceSubmission
 	if err := json.Unmarshal([]byte(systemInfo), &submission.SystemInfo); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal system info: %w", err)
 	}
+	if submission.Metadata, err = decodeMetadata(metadata); err != nil {
+		return nil, err
+	}
 
 	return &submission, nil
 }
 
-// RegisterClient registers or updates a client
-func (d *Database) RegisterClient(registration *api.ClientRegistration) error {
-	query := fmt.Sprintf(`
+// SubmissionExists reports whether a submission with the given ID is stored
+func (d *Database) SubmissionExists(submissionID string) (bool, error) {
+	const query = `SELECT COUNT(*) FROM submissions WHERE submission_id = $1`
+
+	var count int
+	if err := d.db.QueryRow(safesql.New(query, submissionID)).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to query submission: %w", err)
+	}
+	return count > 0, nil
+}
+
+// LatestSubmissions returns the most recent submission of a report type from
+// every client that has submitted it
+func (d *Database) LatestSubmissions(reportType string) ([]*api.ComplianceSubmission, error) {
+	scope := d.scopeCondition(safesql.New("s.client_id"))
+	query := safesql.New(`
+		SELECT s.submission_id
+		FROM submissions s
+		WHERE s.report_type = $1
+		  AND `, reportType).
+		AppendQuery(scope).
+		Append(`
+		  AND s.timestamp = (
+			SELECT MAX(timestamp) FROM submissions
+			WHERE client_id = s.client_id AND report_type = s.report_type
+		  )
+		ORDER BY s.client_id
+	`)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest submissions: %w", err)
+	}
+
+	var submissionIDs []string
+	for rows.Next() {
+		var submissionID string
+		if err := rows.Scan(&submissionID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		submissionIDs = append(submissionIDs, submissionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read submissions: %w", err)
+	}
+
+	submissions := make([]*api.ComplianceSubmission, 0, len(submissionIDs))
+	for _, submissionID := range submissionIDs {
+		submission, err := d.GetSubmission(submissionID)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, nil
+}
+
+// SubmissionHistory returns the check results of every submission made at or
+// after since, optionally limited to one report type, ordered by client,
+// report type and time. Evidence and system information are not loaded.
+func (d *Database) SubmissionHistory(since time.Time, reportType string) ([]*api.ComplianceSubmission, error) {
+	// Bounding the timestamp in SQL limits the scan to the partitions of the
+	// period; a day of slack covers the UTC offsets compared below
+	query := safesql.New(`
+		SELECT submission_id, client_id, hostname, timestamp, report_type, report_version, compliance_data
+		FROM submissions
+		WHERE `).
+		AppendQuery(d.scopeCondition(safesql.New("client_id"))).
+		Append(` AND timestamp >= $1`, since.UTC().Add(-24*time.Hour).Format(time.RFC3339))
+	if reportType != "" {
+		query = query.Append(" AND report_type = $1", reportType)
+	}
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submission history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*api.ComplianceSubmission
+	for rows.Next() {
+		var submission api.ComplianceSubmission
+		var timestampStr, complianceData string
+		var reportVersion sql.NullString
+		if err := rows.Scan(&submission.SubmissionID, &submission.ClientID, &submission.Hostname,
+			&timestampStr, &submission.ReportType, &reportVersion, &complianceData); err != nil {
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+
+		// Rows older versions stored in other formats are filtered after
+		// parsing rather than by comparing the stored strings
+		submission.Timestamp, err = parseStoredTime(timestampStr)
+		if err != nil || submission.Timestamp.Before(since) {
+			continue
+		}
+		submission.ReportVersion = reportVersion.String
+
+		if err := json.Unmarshal([]byte(complianceData), &submission.Compliance); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal compliance data: %w", err)
+		}
+		history = append(history, &submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read submission history: %w", err)
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if a.ClientID != b.ClientID {
+			return a.ClientID < b.ClientID
+		}
+		if a.ReportType != b.ReportType {
+			return a.ReportType < b.ReportType
+		}
+		return a.Timestamp.Before(b.Timestamp)
+	})
+
+	return history, nil
+}
+
+// RegisterClient registers or updates a client. It reports whether the
+// client was created.
+func (d *Database) RegisterClient(registration *api.ClientRegistration) (bool, error) {
+	const query = `
 		INSERT INTO clients (
 			client_id, hostname, os_version, build_number, architecture,
-			domain, ip_address, mac_address, first_seen, last_seen
-		) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+			domain, ip_address, mac_address, fingerprint, first_seen, last_seen
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		ON CONFLICT(client_id) DO UPDATE SET
 			hostname = excluded.hostname,
 			os_version = excluded.os_version,
@@ -425,37 +496,31 @@ func (d *Database) RegisterClient(registration *api.ClientRegistration) error {
 			domain = excluded.domain,
 			ip_address = excluded.ip_address,
 			mac_address = excluded.mac_address,
+			fingerprint = COALESCE(NULLIF(excluded.fingerprint, ''), clients.fingerprint),
 			last_seen = CURRENT_TIMESTAMP
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4),
-		d.placeholder(5), d.placeholder(6), d.placeholder(7), d.placeholder(8))
-
-	_, err := d.db.Exec(query,
-		registration.ClientID,
-		registration.Hostname,
-		registration.SystemInfo.OSVersion,
-		registration.SystemInfo.BuildNumber,
-		registration.SystemInfo.Architecture,
-		registration.SystemInfo.Domain,
-		registration.SystemInfo.IPAddress,
-		registration.SystemInfo.MacAddress,
-	)
+		RETURNING (xmax = 0)
+	`
+
+	var created bool
+	err := d.db.QueryRow(safesql.New(query, registration.ClientID, registration.Hostname, registration.SystemInfo.OSVersion, registration.SystemInfo.BuildNumber, registration.SystemInfo.Architecture, registration.SystemInfo.Domain, registration.SystemInfo.IPAddress, registration.SystemInfo.MacAddress, registration.SystemInfo.Fingerprint)).Scan(&created)
 
 	if err != nil {
-		return fmt.Errorf("failed to register client: %w", err)
+		return false, fmt.Errorf("failed to register client: %w", err)
 	}
 
-	d.logger.Debug("Registered client", "client_id", registration.ClientID)
-	return nil
+	d.logger.Debug("Registered client", "client_id", registration.ClientID, "created", created)
+	return created, nil
 }
 
-// UpdateClientLastSeen updates the last_seen timestamp and system info for a client
-func (d *Database) UpdateClientLastSeen(clientID, hostname string, systemInfo *api.SystemInfo) error {
-	query := fmt.Sprintf(`
+// UpdateClientLastSeen updates the last_seen timestamp and system info for a
+// client, creating it if needed. It reports whether the client was created.
+func (d *Database) UpdateClientLastSeen(clientID, hostname string, systemInfo *api.SystemInfo) (bool, error) {
+	const query = `
 		INSERT INTO clients (
 			client_id, hostname, os_version, build_number, architecture,
-			domain, ip_address, mac_address, first_seen, last_seen
+			domain, ip_address, mac_address, fingerprint, first_seen, last_seen
 		)
-		VALUES (%s, %s, %s, %s, %s, %s, %s, %s, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		ON CONFLICT(client_id) DO UPDATE SET
 			hostname = excluded.hostname,
 			os_version = excluded.os_version,
@@ -464,11 +529,12 @@ func (d *Database) UpdateClientLastSeen(clientID, hostname string, systemInfo *a
 			domain = excluded.domain,
 			ip_address = excluded.ip_address,
 			mac_address = excluded.mac_address,
+			fingerprint = COALESCE(NULLIF(excluded.fingerprint, ''), clients.fingerprint),
 			last_seen = CURRENT_TIMESTAMP
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4),
-		d.placeholder(5), d.placeholder(6), d.placeholder(7), d.placeholder(8))
+		RETURNING (xmax = 0)
+	`
 
-	var osVersion, buildNumber, architecture, domain, ipAddress, macAddress string
+	var osVersion, buildNumber, architecture, domain, ipAddress, macAddress, fingerprint string
 	if systemInfo != nil {
 		osVersion = systemInfo.OSVersion
 		buildNumber = systemInfo.BuildNumber
@@ -476,32 +542,273 @@ func (d *Database) UpdateClientLastSeen(clientID, hostname string, systemInfo *a
 		domain = systemInfo.Domain
 		ipAddress = systemInfo.IPAddress
 		macAddress = systemInfo.MacAddress
+		fingerprint = systemInfo.Fingerprint
 	}
 
-	_, err := d.db.Exec(query, clientID, hostname, osVersion, buildNumber, architecture, domain, ipAddress, macAddress)
+	// xmax is 0 for a row the statement inserted rather than updated
+	var created bool
+	err := d.db.QueryRow(safesql.New(query, clientID, hostname, osVersion, buildNumber, architecture, domain, ipAddress, macAddress, fingerprint)).Scan(&created)
 	if err != nil {
-		return fmt.Errorf("failed to update client last_seen: %w", err)
+		return false, fmt.Errorf("failed to update client last_seen: %w", err)
 	}
 
+	return created, nil
+}
+
+// FindClientByHostname returns the ID of the most recently seen client with
+// the given hostname, compared case-insensitively, or "" if there is none
+func (d *Database) FindClientByHostname(hostname string) (string, error) {
+	const query = `
+		SELECT client_id FROM clients
+		WHERE LOWER(hostname) = LOWER($1)
+		ORDER BY last_seen DESC
+		LIMIT 1
+	`
+
+	var clientID string
+	err := d.db.QueryRow(safesql.New(query, hostname)).Scan(&clientID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find client: %w", err)
+	}
+	return clientID, nil
+}
+
+// EnsureClient creates a client first and last seen at seen, leaving an
+// existing client unchanged. Imports of historical scans use it so that old
+// results do not make a client look recently active.
+func (d *Database) EnsureClient(clientID, hostname string, systemInfo *api.SystemInfo, seen time.Time) error {
+	const query = `
+		INSERT INTO clients (
+			client_id, hostname, os_version, build_number, architecture, first_seen, last_seen
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT(client_id) DO NOTHING
+	`
+
+	var osVersion, buildNumber, architecture string
+	if systemInfo != nil {
+		osVersion = systemInfo.OSVersion
+		buildNumber = systemInfo.BuildNumber
+		architecture = systemInfo.Architecture
+	}
+
+	if _, err := d.db.Exec(safesql.New(query, clientID, hostname, osVersion, buildNumber, architecture, seen, seen)); err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
 	return nil
 }
 
+// clientIdentity is the part of a client record used to recognize a
+// reinstalled machine that registered under a new client ID
+type clientIdentity struct {
+	ClientID    string
+	Hostname    string
+	MacAddress  string
+	Fingerprint string
+	LastSeen    time.Time
+}
+
+// ListClientIdentities returns the clients registered with the given hostname,
+// compared case-insensitively, or with the given fingerprint, most recently
+// seen first. An empty fingerprint matches no client.
+func (d *Database) ListClientIdentities(hostname, fingerprint string) ([]clientIdentity, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
+		SELECT client_id, hostname, mac_address, fingerprint, last_seen FROM clients
+		WHERE (LOWER(hostname) = LOWER($1) OR ($2 <> '' AND fingerprint = $2))
+		AND `, hostname, fingerprint).
+		AppendQuery(scope).
+		Append(`
+		ORDER BY last_seen DESC
+	`)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []clientIdentity
+	for rows.Next() {
+		var id clientIdentity
+		var macAddress, fingerprint sql.NullString
+		if err := rows.Scan(&id.ClientID, &id.Hostname, &macAddress, &fingerprint, &id.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan client: %w", err)
+		}
+		id.MacAddress = macAddress.String
+		id.Fingerprint = fingerprint.String
+		identities = append(identities, id)
+	}
+	return identities, rows.Err()
+}
+
+// ListDuplicateClients returns the clients that share a hostname with at
+// least one other client, grouped by hostname
+func (d *Database) ListDuplicateClients() ([]api.DuplicateClientGroup, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
+		SELECT LOWER(hostname) FROM clients
+		WHERE `).
+		AppendQuery(scope).
+		Append(`
+		GROUP BY LOWER(hostname)
+		HAVING COUNT(*) > 1
+		ORDER BY LOWER(hostname)
+	`)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate clients: %w", err)
+	}
+	var hostnames []string
+	for rows.Next() {
+		var hostname string
+		if err := rows.Scan(&hostname); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan hostname: %w", err)
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groups := make([]api.DuplicateClientGroup, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		identities, err := d.ListClientIdentities(hostname, "")
+		if err != nil {
+			return nil, err
+		}
+
+		group := api.DuplicateClientGroup{Hostname: hostname}
+		for _, id := range identities {
+			client, err := d.GetClient(id.ClientID)
+			if err != nil {
+				return nil, err
+			}
+			group.Clients = append(group.Clients, *client)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// MergeClients moves everything recorded for the source client (submissions,
+// telemetry, policy assignments, alerts, commands, maintenance windows and
+// tags) to the target client and deletes the source client. The target keeps the
+// earlier first_seen and later last_seen of the two. Everything happens in
+// one transaction.
+func (d *Database) MergeClients(targetID, sourceID string) (*api.ClientMergeResponse, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	merged := &api.ClientMergeResponse{TargetClientID: targetID, SourceClientID: sourceID}
+
+	// Policies already assigned to the target keep the target's assignment
+	_, err = tx.Exec(safesql.New(`
+		DELETE FROM client_policies
+		WHERE client_id = $1
+		AND policy_id IN (SELECT policy_id FROM client_policies WHERE client_id = $2)
+	`, sourceID, targetID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge policy assignments: %w", err)
+	}
+
+	// The target gains the source's tags; the source's rows go with it
+	_, err = tx.Exec(safesql.New(`
+		INSERT INTO client_tags (client_id, tag)
+		SELECT $1, tag FROM client_tags WHERE client_id = $2
+		ON CONFLICT DO NOTHING
+	`, targetID, sourceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	moves := []struct {
+		table safesql.Query
+		count *int64
+	}{
+		{safesql.New("submissions"), &merged.Submissions},
+		{safesql.New("agent_telemetry"), &merged.Telemetry},
+		{safesql.New("client_policies"), &merged.PolicyAssignments},
+		{safesql.New("alerts"), &merged.Alerts},
+		{safesql.New("client_commands"), &merged.Commands},
+		{safesql.New("maintenance_windows"), &merged.MaintenanceWindows},
+	}
+	for _, move := range moves {
+		query := safesql.New("UPDATE ").AppendQuery(move.table).
+			Append(" SET client_id = $1 WHERE client_id = $2", targetID, sourceID)
+		result, err := tx.Exec(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move %s: %w", move.table, err)
+		}
+		if *move.count, err = result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(safesql.New(`
+		UPDATE clients SET
+			first_seen = LEAST(first_seen, (SELECT first_seen FROM clients WHERE client_id = $1)),
+			last_seen = GREATEST(last_seen, (SELECT last_seen FROM clients WHERE client_id = $1))
+		WHERE client_id = $2
+	`, sourceID, targetID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update merged client: %w", err)
+	}
+
+	if err := d.rebuildRollups(tx, []string{targetID, sourceID}); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(safesql.New(`DELETE FROM clients WHERE client_id = $1`, sourceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete merged client: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("client %s not found", sourceID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	d.logger.Info("Merged clients",
+		"target_client_id", targetID,
+		"source_client_id", sourceID,
+		"submissions", merged.Submissions,
+	)
+	return merged, nil
+}
+
 // ListClients returns all registered clients
 func (d *Database) ListClients() ([]api.ClientInfo, error) {
-	query := `
+	scope := d.scopeCondition(safesql.New("c.client_id"))
+	query := safesql.New(`
 		SELECT
 			c.id, c.client_id, c.hostname, c.first_seen, c.last_seen, c.status,
-			c.os_version, c.build_number, c.architecture, c.domain, c.ip_address, c.mac_address,
+			c.os_version, c.build_number, c.architecture, c.domain, c.ip_address, c.mac_address, c.fingerprint, c.agent_version,
 			(SELECT submission_id FROM submissions WHERE client_id = c.client_id ORDER BY timestamp DESC LIMIT 1) as last_submission,
 			(SELECT AVG(passed_checks * 100.0 / NULLIF(total_checks, 0))
 			 FROM (SELECT passed_checks, total_checks
 			       FROM submissions
 			       WHERE client_id = c.client_id
 			       ORDER BY timestamp DESC
-			       LIMIT 10)) as compliance_score
+			       LIMIT 10)) as compliance_score,
+			(SELECT STRING_AGG(tag, ',' ORDER BY tag) FROM client_tags WHERE client_id = c.client_id) as tags
 		FROM clients c
+		WHERE `).
+		AppendQuery(scope).
+		Append(`
 		ORDER BY c.last_seen DESC
-	`
+	`)
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -512,11 +819,11 @@ func (d *Database) ListClients() ([]api.ClientInfo, error) {
 	var clients []api.ClientInfo
 	for rows.Next() {
 		var client api.ClientInfo
-		var lastSubmission sql.NullString
+		var lastSubmission, tags sql.NullString
 		var complianceScore sql.NullFloat64
 
 		// Use NullString for all nullable fields
-		var osVersion, buildNumber, architecture, domain, ipAddress, macAddress sql.NullString
+		var osVersion, buildNumber, architecture, domain, ipAddress, macAddress, fingerprint, agentVersion sql.NullString
 
 		err := rows.Scan(
 			&client.ID,
@@ -531,8 +838,11 @@ func (d *Database) ListClients() ([]api.ClientInfo, error) {
 			&domain,
 			&ipAddress,
 			&macAddress,
+			&fingerprint,
+			&agentVersion,
 			&lastSubmission,
 			&complianceScore,
+			&tags,
 		)
 
 		if err != nil {
@@ -558,12 +868,19 @@ func (d *Database) ListClients() ([]api.ClientInfo, error) {
 		if macAddress.Valid {
 			client.SystemInfo.MacAddress = macAddress.String
 		}
+		if fingerprint.Valid {
+			client.SystemInfo.Fingerprint = fingerprint.String
+		}
+		client.AgentVersion = agentVersion.String
 		if lastSubmission.Valid {
 			client.LastSubmission = lastSubmission.String
 		}
 		if complianceScore.Valid {
 			client.ComplianceScore = complianceScore.Float64
 		}
+		if tags.String != "" {
+			client.Tags = strings.Split(tags.String, ",")
+		}
 
 		clients = append(clients, client)
 	}
@@ -574,16 +891,19 @@ func (d *Database) ListClients() ([]api.ClientInfo, error) {
 // GetDashboardSummary returns summary data for the dashboard
 func (d *Database) GetDashboardSummary() (*api.DashboardSummary, error) {
 	summary := &api.DashboardSummary{
-		ComplianceByType: make(map[string]api.ComplianceStats),
+		RecentSubmissions: []api.SubmissionSummary{},
+		ComplianceByType:  make(map[string]api.ComplianceStats),
 	}
 
+	scope := d.scopeCondition(safesql.New("client_id"))
+
 	// Get total and active clients
-	query := fmt.Sprintf(`
+	query := safesql.New(`
 		SELECT
 			COUNT(*) as total,
-			COUNT(CASE WHEN last_seen > %s THEN 1 END) as active
+			COUNT(CASE WHEN last_seen > CURRENT_TIMESTAMP - INTERVAL '24 hours' THEN 1 END) as active
 		FROM clients
-	`, d.getDateTimeSubtract(24))
+		WHERE `).AppendQuery(scope)
 
 	err := d.db.QueryRow(query).Scan(&summary.TotalClients, &summary.ActiveClients)
 
@@ -592,29 +912,25 @@ func (d *Database) GetDashboardSummary() (*api.DashboardSummary, error) {
 	}
 
 	// Get compliant clients (last submission was compliant)
-	err = d.db.QueryRow(`
-		SELECT COUNT(DISTINCT client_id)
-		FROM submissions s1
+	err = d.db.QueryRow(safesql.New(`
+		SELECT COUNT(*)
+		FROM client_rollups
 		WHERE overall_status = 'compliant'
-		AND timestamp = (
-			SELECT MAX(timestamp)
-			FROM submissions s2
-			WHERE s2.client_id = s1.client_id
-		)
-	`).Scan(&summary.CompliantClients)
+		AND `).AppendQuery(scope)).Scan(&summary.CompliantClients)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get compliant client count: %w", err)
 	}
 
 	// Get recent submissions
-	rows, err := d.db.Query(`
+	rows, err := d.db.Query(safesql.New(`
 		SELECT submission_id, client_id, hostname, timestamp, report_type,
-		       overall_status, passed_checks, failed_checks
+		       overall_status, passed_checks, failed_checks, during_maintenance
 		FROM submissions
+		WHERE `).AppendQuery(scope).Append(`
 		ORDER BY timestamp DESC
 		LIMIT 10
-	`)
+	`))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to query recent submissions: %w", err)
@@ -633,31 +949,34 @@ func (d *Database) GetDashboardSummary() (*api.DashboardSummary, error) {
 			&sub.OverallStatus,
 			&sub.PassedChecks,
 			&sub.FailedChecks,
+			&sub.DuringMaintenance,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan submission: %w", err)
 		}
 
-		// Parse timestamp from string
-		sub.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		// A row with an unreadable timestamp is listed without one rather
+		// than failing the summary
+		if sub.Timestamp, err = parseStoredTime(timestampStr); err != nil {
+			d.logger.Warn("Submission has an unreadable timestamp", "submission_id", sub.SubmissionID, "error", err)
 		}
 
 		summary.RecentSubmissions = append(summary.RecentSubmissions, sub)
 	}
 
 	// Get compliance stats by report type
-	statsRows, err := d.db.Query(`
+	statsRows, err := d.db.Query(safesql.New(`
 		SELECT
 			report_type,
-			COUNT(*) as total_submissions,
-			AVG(passed_checks * 100.0 / NULLIF(total_checks, 0)) as avg_score,
-			SUM(CASE WHEN overall_status = 'compliant' THEN 1 ELSE 0 END) * 100.0 / COUNT(*) as pass_rate,
-			SUM(CASE WHEN overall_status != 'compliant' THEN 1 ELSE 0 END) * 100.0 / COUNT(*) as fail_rate
-		FROM submissions
+			SUM(submissions) as total_submissions,
+			COALESCE(SUM(score_sum) / NULLIF(SUM(scored), 0), 0) as avg_score,
+			SUM(compliant) * 100.0 / SUM(submissions) as pass_rate,
+			SUM(noncompliant) * 100.0 / SUM(submissions) as fail_rate
+		FROM report_type_rollups
+		WHERE submissions > 0
+		AND `).AppendQuery(scope).Append(`
 		GROUP BY report_type
-	`)
+	`))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to query compliance stats: %w", err)
@@ -680,32 +999,40 @@ func (d *Database) GetDashboardSummary() (*api.DashboardSummary, error) {
 		summary.ComplianceByType[reportType] = stats
 	}
 
+	// Get open alerts
+	summary.Alerts, err = d.ListAlerts(false, 20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alerts: %w", err)
+	}
+
 	return summary, nil
 }
 
 // GetClient retrieves detailed information for a specific client
 func (d *Database) GetClient(clientID string) (*api.ClientInfo, error) {
-	query := fmt.Sprintf(`
+	scope := d.scopeCondition(safesql.New("c.client_id"))
+	query := safesql.New(`
 		SELECT
 			c.id, c.client_id, c.hostname, c.first_seen, c.last_seen, c.status,
-			c.os_version, c.build_number, c.architecture, c.domain, c.ip_address, c.mac_address,
+			c.os_version, c.build_number, c.architecture, c.domain, c.ip_address, c.mac_address, c.fingerprint, c.agent_version,
 			(SELECT submission_id FROM submissions WHERE client_id = c.client_id ORDER BY timestamp DESC LIMIT 1) as last_submission,
 			(SELECT AVG(passed_checks * 100.0 / NULLIF(total_checks, 0))
 			 FROM (SELECT passed_checks, total_checks
 			       FROM submissions
 			       WHERE client_id = c.client_id
 			       ORDER BY timestamp DESC
-			       LIMIT 10)) as compliance_score
+			       LIMIT 10)) as compliance_score,
+			(SELECT STRING_AGG(tag, ',' ORDER BY tag) FROM client_tags WHERE client_id = c.client_id) as tags
 		FROM clients c
-		WHERE c.client_id = %s
-	`, d.placeholder(1))
+		WHERE c.client_id = $1 AND `, clientID).
+		AppendQuery(scope)
 
 	var client api.ClientInfo
-	var lastSubmission sql.NullString
+	var lastSubmission, tags sql.NullString
 	var complianceScore sql.NullFloat64
-	var osVersion, buildNumber, architecture, domain, ipAddress, macAddress sql.NullString
+	var osVersion, buildNumber, architecture, domain, ipAddress, macAddress, fingerprint, agentVersion sql.NullString
 
-	err := d.db.QueryRow(query, clientID).Scan(
+	err := d.db.QueryRow(query).Scan(
 		&client.ID,
 		&client.ClientID,
 		&client.Hostname,
@@ -718,8 +1045,11 @@ func (d *Database) GetClient(clientID string) (*api.ClientInfo, error) {
 		&domain,
 		&ipAddress,
 		&macAddress,
+		&fingerprint,
+		&agentVersion,
 		&lastSubmission,
 		&complianceScore,
+		&tags,
 	)
 
 	if err == sql.ErrNoRows {
@@ -748,12 +1078,19 @@ func (d *Database) GetClient(clientID string) (*api.ClientInfo, error) {
 	if macAddress.Valid {
 		client.SystemInfo.MacAddress = macAddress.String
 	}
+	if fingerprint.Valid {
+		client.SystemInfo.Fingerprint = fingerprint.String
+	}
+	client.AgentVersion = agentVersion.String
 	if lastSubmission.Valid {
 		client.LastSubmission = lastSubmission.String
 	}
 	if complianceScore.Valid {
 		client.ComplianceScore = complianceScore.Float64
 	}
+	if tags.String != "" {
+		client.Tags = strings.Split(tags.String, ",")
+	}
 
 	return &client, nil
 }
@@ -761,7 +1098,8 @@ func (d *Database) GetClient(clientID string) (*api.ClientInfo, error) {
 // GetClientComplianceScoresByType retrieves average compliance scores per report type for a client
 // Calculates average of last 10 submissions for each report type
 func (d *Database) GetClientComplianceScoresByType(clientID string) (map[string]float64, error) {
-	query := fmt.Sprintf(`
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
 		SELECT
 			report_type,
 			AVG(passed_checks * 100.0 / NULLIF(total_checks, 0)) as avg_score
@@ -772,13 +1110,15 @@ func (d *Database) GetClientComplianceScoresByType(clientID string) (map[string]
 				total_checks,
 				ROW_NUMBER() OVER (PARTITION BY report_type ORDER BY timestamp DESC) as rn
 			FROM submissions
-			WHERE client_id = %s
+			WHERE client_id = $1 AND `, clientID).
+		AppendQuery(scope).
+		Append(`
 		)
 		WHERE rn <= 10
 		GROUP BY report_type
-	`, d.placeholder(1))
+	`)
 
-	rows, err := d.db.Query(query, clientID)
+	rows, err := d.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query compliance scores by type: %w", err)
 	}
@@ -803,15 +1143,18 @@ func (d *Database) GetClientComplianceScoresByType(clientID string) (map[string]
 
 // GetClientSubmissions retrieves all submissions for a specific client
 func (d *Database) GetClientSubmissions(clientID string) ([]api.SubmissionSummary, error) {
-	query := fmt.Sprintf(`
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
 		SELECT submission_id, client_id, hostname, timestamp, report_type,
-		       overall_status, total_checks, passed_checks, failed_checks
+		       overall_status, total_checks, passed_checks, failed_checks, during_maintenance, summary_only, metadata
 		FROM submissions
-		WHERE client_id = %s
+		WHERE client_id = $1 AND `, clientID).
+		AppendQuery(scope).
+		Append(`
 		ORDER BY timestamp DESC
-	`, d.placeholder(1))
+	`)
 
-	rows, err := d.db.Query(query, clientID)
+	rows, err := d.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query client submissions: %w", err)
 	}
@@ -821,6 +1164,7 @@ func (d *Database) GetClientSubmissions(clientID string) ([]api.SubmissionSummar
 	for rows.Next() {
 		var sub api.SubmissionSummary
 		var timestampStr string
+		var metadata sql.NullString
 		err := rows.Scan(
 			&sub.SubmissionID,
 			&sub.ClientID,
@@ -831,85 +1175,917 @@ func (d *Database) GetClientSubmissions(clientID string) ([]api.SubmissionSummar
 			&sub.TotalChecks,
 			&sub.PassedChecks,
 			&sub.FailedChecks,
+			&sub.DuringMaintenance,
+			&sub.SummaryOnly,
+			&metadata,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+
+		if sub.Timestamp, err = parseStoredTime(timestampStr); err != nil {
+			d.logger.Warn("Submission has an unreadable timestamp", "submission_id", sub.SubmissionID, "error", err)
+		}
+		if sub.Metadata, err = decodeMetadata(metadata); err != nil {
+			d.logger.Warn("Submission has unreadable metadata", "submission_id", sub.SubmissionID, "error", err)
+		}
+
+		submissions = append(submissions, sub)
+	}
+
+	return submissions, nil
+}
+
+// ClearClientHistory deletes all submissions for a specific client
+func (d *Database) ClearClientHistory(clientID string) (int64, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Telemetry has no foreign key to the partitioned submissions table
+	telemetryQuery := safesql.New(`DELETE FROM agent_telemetry WHERE client_id = $1 AND `, clientID).AppendQuery(scope)
+	if _, err := tx.Exec(telemetryQuery); err != nil {
+		return 0, fmt.Errorf("failed to clear client telemetry: %w", err)
+	}
+
+	query := safesql.New(`DELETE FROM submissions WHERE client_id = $1 AND `, clientID).AppendQuery(scope)
+
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear client history: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if err := d.rebuildRollups(tx, []string{clientID}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit client history: %w", err)
+	}
+
+	d.logger.Info("Cleared client history", "client_id", clientID, "submissions_deleted", rowsAffected)
+	return rowsAffected, nil
+}
+
+// SetClientTags replaces the tags of a client
+func (d *Database) SetClientTags(clientID string, tags []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tag update: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(safesql.New(`SELECT EXISTS (SELECT 1 FROM clients WHERE client_id = $1)`, clientID)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query client: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("client not found")
+	}
+
+	if _, err := tx.Exec(safesql.New(`DELETE FROM client_tags WHERE client_id = $1`, clientID)); err != nil {
+		return fmt.Errorf("failed to clear client tags: %w", err)
+	}
+	for _, tag := range tags {
+		_, err := tx.Exec(safesql.New(`INSERT INTO client_tags (client_id, tag) VALUES ($1, $2)`, clientID, tag))
+		if err != nil {
+			return fmt.Errorf("failed to add client tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tag update: %w", err)
+	}
+
+	d.logger.Info("Client tags updated", "client_id", clientID, "tags", tags)
+	return nil
+}
+
+// ListClientTags returns the tags of every registered client, keyed by
+// client ID. Untagged clients have an empty list.
+func (d *Database) ListClientTags() (map[string][]string, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT c.client_id, t.tag
+		FROM clients c
+		LEFT JOIN client_tags t ON t.client_id = c.client_id
+		ORDER BY c.client_id, t.tag
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var clientID string
+		var tag sql.NullString
+		if err := rows.Scan(&clientID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan client tag: %w", err)
+		}
+		if _, ok := tags[clientID]; !ok {
+			tags[clientID] = []string{}
+		}
+		if tag.Valid {
+			tags[clientID] = append(tags[clientID], tag.String)
+		}
+	}
+
+	return tags, rows.Err()
+}
+
+// ClearAllSubmissions deletes all submissions from all clients in the view's
+// scope (keeps clients registered)
+func (d *Database) ClearAllSubmissions() (int64, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(safesql.New(`DELETE FROM agent_telemetry WHERE `).AppendQuery(scope)); err != nil {
+		return 0, fmt.Errorf("failed to clear telemetry: %w", err)
+	}
+
+	query := safesql.New(`DELETE FROM submissions WHERE `).AppendQuery(scope)
+
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear all submissions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if err := d.clearRollups(tx); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit cleared submissions: %w", err)
+	}
+
+	d.logger.Info("Cleared all submissions", "submissions_deleted", rowsAffected)
+	return rowsAffected, nil
+}
+
+// ClientSchedule is the run schedule a client last reported in a heartbeat
+type ClientSchedule struct {
+	ClientID  string
+	Hostname  string
+	Cron      string
+	UTCOffset int       // Seconds east of UTC; the cron expression is in client local time
+	Reports   []string  // Report types expected from each run
+	Since     time.Time // When the schedule was first reported or last changed
+}
+
+// RecordHeartbeat marks a client as alive and stores the schedule it reported.
+// schedule_updated_at only moves when the schedule itself changes so that runs
+// expected under an older schedule are not reported as missed.
+func (d *Database) RecordHeartbeat(heartbeat *api.Heartbeat) error {
+	var enabled bool
+	var cronExpr, reports string
+	var utcOffset int
+	if heartbeat.Schedule != nil {
+		enabled = heartbeat.Schedule.Enabled
+		cronExpr = heartbeat.Schedule.Cron
+		utcOffset = heartbeat.Schedule.UTCOffsetSeconds
+
+		reportsJSON, err := json.Marshal(heartbeat.Schedule.Reports)
+		if err != nil {
+			return fmt.Errorf("failed to marshal schedule reports: %w", err)
+		}
+		reports = string(reportsJSON)
+	}
+
+	capabilities, err := json.Marshal(heartbeat.Capabilities)
+	if err != nil {
+		return fmt.Errorf("failed to marshal capabilities: %w", err)
+	}
+
+	const query = `
+		INSERT INTO clients (
+			client_id, hostname, first_seen, last_seen, last_heartbeat, agent_version,
+			schedule_enabled, schedule_cron, schedule_utc_offset, schedule_reports, schedule_updated_at,
+			capabilities
+		) VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, $8)
+		ON CONFLICT(client_id) DO UPDATE SET
+			hostname = excluded.hostname,
+			last_seen = CURRENT_TIMESTAMP,
+			last_heartbeat = CURRENT_TIMESTAMP,
+			agent_version = excluded.agent_version,
+			schedule_updated_at = CASE
+				WHEN clients.schedule_updated_at IS NULL
+				  OR clients.schedule_enabled IS DISTINCT FROM excluded.schedule_enabled
+				  OR clients.schedule_cron IS DISTINCT FROM excluded.schedule_cron
+				  OR clients.schedule_utc_offset IS DISTINCT FROM excluded.schedule_utc_offset
+				THEN CURRENT_TIMESTAMP
+				ELSE clients.schedule_updated_at
+			END,
+			schedule_enabled = excluded.schedule_enabled,
+			schedule_cron = excluded.schedule_cron,
+			schedule_utc_offset = excluded.schedule_utc_offset,
+			schedule_reports = excluded.schedule_reports,
+			capabilities = excluded.capabilities
+	`
+
+	_, err = d.db.Exec(safesql.New(query, heartbeat.ClientID, heartbeat.Hostname, heartbeat.AgentVersion, enabled, cronExpr, utcOffset, reports, string(capabilities)))
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// ClientCapabilities returns the command types a client reported it accepts
+// in its latest heartbeat
+func (d *Database) ClientCapabilities(clientID string) ([]string, error) {
+	var capabilities sql.NullString
+	const query = `SELECT capabilities FROM clients WHERE client_id = $1`
+	err := d.db.QueryRow(safesql.New(query, clientID)).Scan(&capabilities)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("client not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capabilities: %w", err)
+	}
+
+	var result []string
+	if capabilities.Valid && capabilities.String != "" {
+		if err := json.Unmarshal([]byte(capabilities.String), &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal capabilities: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// ListClientSchedules returns the schedules of all clients running on a schedule
+func (d *Database) ListClientSchedules() ([]ClientSchedule, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT client_id, hostname, schedule_cron, schedule_utc_offset, schedule_reports, schedule_updated_at
+		FROM clients
+		WHERE schedule_enabled = true AND schedule_cron IS NOT NULL AND schedule_cron != ''
+		ORDER BY client_id
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []ClientSchedule
+	for rows.Next() {
+		var schedule ClientSchedule
+		var utcOffset sql.NullInt64
+		var reports sql.NullString
+		var since sql.NullTime
+
+		if err := rows.Scan(&schedule.ClientID, &schedule.Hostname, &schedule.Cron, &utcOffset, &reports, &since); err != nil {
+			return nil, fmt.Errorf("failed to scan client schedule: %w", err)
+		}
+
+		schedule.UTCOffset = int(utcOffset.Int64)
+		schedule.Since = since.Time
+		if reports.Valid && reports.String != "" {
+			if err := json.Unmarshal([]byte(reports.String), &schedule.Reports); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal schedule reports: %w", err)
+			}
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// GetLastSubmissionTimes returns the most recent submission time per report type for a client
+func (d *Database) GetLastSubmissionTimes(clientID string) (map[string]time.Time, error) {
+	const query = `
+		SELECT report_type, MAX(timestamp)
+		FROM submissions
+		WHERE client_id = $1
+		GROUP BY report_type
+	`
+
+	rows, err := d.db.Query(safesql.New(query, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last submission times: %w", err)
+	}
+	defer rows.Close()
+
+	lastRuns := make(map[string]time.Time)
+	for rows.Next() {
+		var reportType string
+		var lastRun time.Time
+		if err := rows.Scan(&reportType, &lastRun); err != nil {
+			return nil, fmt.Errorf("failed to scan last submission time: %w", err)
+		}
+		lastRuns[reportType] = lastRun
+	}
+
+	return lastRuns, rows.Err()
+}
+
+// CreateAlert stores an alert unless one with the same dedupe key already
+// exists. It reports whether a new alert was created.
+func (d *Database) CreateAlert(alert *api.Alert, dedupeKey string) (bool, error) {
+	const query = `
+		INSERT INTO alerts (
+			alert_type, severity, client_id, hostname, report_type, message,
+			dedupe_key, expected_at, last_success_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT(dedupe_key) DO NOTHING
+	`
+
+	result, err := d.db.Exec(safesql.New(query, alert.Type, alert.Severity, alert.ClientID, alert.Hostname, alert.ReportType, alert.Message, dedupeKey, nullableTime(alert.ExpectedAt), nullableTime(alert.LastSuccess), alert.Timestamp.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return false, fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ResolveAlerts marks the open alerts of a type for a client and report type as resolved
+func (d *Database) ResolveAlerts(clientID, alertType, reportType string) (int64, error) {
+	const query = `
+		UPDATE alerts SET resolved_at = CURRENT_TIMESTAMP
+		WHERE client_id = $1 AND alert_type = $2 AND report_type = $3 AND resolved_at IS NULL
+	`
+
+	result, err := d.db.Exec(safesql.New(query, clientID, alertType, reportType))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve alerts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// ListAlerts returns alerts newest first. Resolved alerts are only included when requested.
+func (d *Database) ListAlerts(includeResolved bool, limit int) ([]api.Alert, error) {
+	query := safesql.New(`
+		SELECT id, alert_type, severity, client_id, hostname, report_type, message,
+		       expected_at, last_success_at, created_at, acknowledged, resolved_at
+		FROM alerts
+		WHERE `)
+	if !includeResolved {
+		query = query.Append("resolved_at IS NULL AND ")
+	}
+	query = query.AppendQuery(d.scopeCondition(safesql.New("client_id"))).Append(`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`, limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []api.Alert{}
+	for rows.Next() {
+		var alert api.Alert
+		var id int64
+		var clientID, hostname, reportType sql.NullString
+		var expectedAt, lastSuccess, resolvedAt sql.NullTime
+
+		err := rows.Scan(
+			&id,
+			&alert.Type,
+			&alert.Severity,
+			&clientID,
+			&hostname,
+			&reportType,
+			&alert.Message,
+			&expectedAt,
+			&lastSuccess,
+			&alert.Timestamp,
+			&alert.Acknowledged,
+			&resolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+
+		alert.ID = strconv.FormatInt(id, 10)
+		alert.ClientID = clientID.String
+		alert.Hostname = hostname.String
+		alert.ReportType = reportType.String
+		alert.ExpectedAt = timePtr(expectedAt)
+		alert.LastSuccess = timePtr(lastSuccess)
+		alert.ResolvedAt = timePtr(resolvedAt)
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// AcknowledgeAlert marks an alert as seen by a user
+func (d *Database) AcknowledgeAlert(id int, username string) error {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
+		UPDATE alerts SET acknowledged = true, acknowledged_by = $1
+		WHERE id = $2 AND `, username, id).
+		AppendQuery(scope)
+
+	result, err := d.db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge alert: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("alert not found")
+	}
+
+	return nil
+}
+
+// ListMaintenanceWindows returns all maintenance windows
+func (d *Database) ListMaintenanceWindows() ([]api.MaintenanceWindow, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT id, name, description, client_id, hostname_pattern, tag, cron, duration_minutes,
+		       timezone, starts_at, ends_at, enabled, created_by, created_at
+		FROM maintenance_windows
+		ORDER BY name
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []api.MaintenanceWindow{}
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, *window)
+	}
+
+	return windows, rows.Err()
+}
+
+// GetMaintenanceWindow retrieves a maintenance window by ID
+func (d *Database) GetMaintenanceWindow(id int) (*api.MaintenanceWindow, error) {
+	const query = `
+		SELECT id, name, description, client_id, hostname_pattern, tag, cron, duration_minutes,
+		       timezone, starts_at, ends_at, enabled, created_by, created_at
+		FROM maintenance_windows
+		WHERE id = $1
+	`
+
+	window, err := scanMaintenanceWindow(d.db.QueryRow(safesql.New(query, id)))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("maintenance window not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return window, nil
+}
+
+// scanMaintenanceWindow scans one maintenance_windows row
+func scanMaintenanceWindow(row interface{ Scan(...interface{}) error }) (*api.MaintenanceWindow, error) {
+	var w api.MaintenanceWindow
+	var description, clientID, hostnamePattern, group, cronExpr, timezone, createdBy, createdAt sql.NullString
+	var durationMinutes sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&w.ID,
+		&w.Name,
+		&description,
+		&clientID,
+		&hostnamePattern,
+		&group,
+		&cronExpr,
+		&durationMinutes,
+		&timezone,
+		&startsAt,
+		&endsAt,
+		&w.Enabled,
+		&createdBy,
+		&createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+	}
+
+	w.Description = description.String
+	w.ClientID = clientID.String
+	w.HostnamePattern = hostnamePattern.String
+	w.Group = group.String
+	w.Cron = cronExpr.String
+	w.DurationMinutes = int(durationMinutes.Int64)
+	w.Timezone = timezone.String
+	w.StartsAt = timePtr(startsAt)
+	w.EndsAt = timePtr(endsAt)
+	w.CreatedBy = createdBy.String
+	w.CreatedAt = createdAt.String
+
+	return &w, nil
+}
+
+// CreateMaintenanceWindow creates a maintenance window and sets its ID
+func (d *Database) CreateMaintenanceWindow(w *api.MaintenanceWindow) error {
+	const query = `
+		INSERT INTO maintenance_windows (
+			name, description, client_id, hostname_pattern, tag, cron, duration_minutes,
+			timezone, starts_at, ends_at, enabled, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+
+	err := d.db.QueryRow(safesql.New(query, w.Name, w.Description, w.ClientID, w.HostnamePattern, w.Group, w.Cron, w.DurationMinutes, w.Timezone, nullableTime(w.StartsAt), nullableTime(w.EndsAt), w.Enabled, w.CreatedBy)).Scan(&w.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+
+	d.logger.Info("Maintenance window created", "id", w.ID, "name", w.Name)
+	return nil
+}
+
+// UpdateMaintenanceWindow replaces a maintenance window's definition
+func (d *Database) UpdateMaintenanceWindow(id int, w *api.MaintenanceWindow) error {
+	const query = `
+		UPDATE maintenance_windows
+		SET name = $1, description = $2, client_id = $3, hostname_pattern = $4, tag = $5, cron = $6,
+		    duration_minutes = $7, timezone = $8, starts_at = $9, ends_at = $10, enabled = $11
+		WHERE id = $12
+	`
+
+	result, err := d.db.Exec(safesql.New(query, w.Name, w.Description, w.ClientID, w.HostnamePattern, w.Group, w.Cron, w.DurationMinutes, w.Timezone, nullableTime(w.StartsAt), nullableTime(w.EndsAt), w.Enabled, id))
+	if err != nil {
+		return fmt.Errorf("failed to update maintenance window: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("maintenance window not found")
+	}
+
+	d.logger.Info("Maintenance window updated", "id", id)
+	return nil
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window
+func (d *Database) DeleteMaintenanceWindow(id int) error {
+	const query = `DELETE FROM maintenance_windows WHERE id = $1`
+
+	result, err := d.db.Exec(safesql.New(query, id))
+	if err != nil {
+		return fmt.Errorf("failed to delete maintenance window: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("maintenance window not found")
+	}
+
+	d.logger.Info("Maintenance window deleted", "id", id)
+	return nil
+}
+
+// commandPayload holds the type-specific arguments of a queued command
+type commandPayload struct {
+	Reports   []string          `json:"reports,omitempty"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// CreateCommand queues a command for a client and sets its ID. Commands are
+// created pending unless cmd.Status already names another initial state
+// (awaiting_approval for staged commands).
+func (d *Database) CreateCommand(cmd *api.Command) error {
+	payload, err := json.Marshal(commandPayload{Reports: cmd.Reports, Arguments: cmd.Arguments})
+	if err != nil {
+		return fmt.Errorf("failed to marshal command payload: %w", err)
+	}
+
+	if cmd.Status == "" {
+		cmd.Status = api.CommandStatusPending
+	}
+	cmd.CreatedAt = time.Now().UTC()
+
+	const query = `
+		INSERT INTO client_commands (client_id, command_type, payload, status, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	err = d.db.QueryRow(safesql.New(query, cmd.ClientID, cmd.Type, string(payload), cmd.Status, cmd.CreatedBy, cmd.CreatedAt.Format(time.RFC3339))).Scan(&cmd.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+
+	d.logger.Info("Command queued", "id", cmd.ID, "client_id", cmd.ClientID, "type", cmd.Type)
+	return nil
+}
+
+// DeliverPendingCommands marks a client's pending commands as delivered and
+// returns them. Marking and reading happen in one statement so a command is
+// handed out at most once even when heartbeats overlap.
+func (d *Database) DeliverPendingCommands(clientID string) ([]api.Command, error) {
+	const query = `
+		UPDATE client_commands
+		SET status = $1, delivered_at = CURRENT_TIMESTAMP
+		WHERE client_id = $2 AND status = $3
+		RETURNING ` + commandColumns + `
+	`
+
+	rows, err := d.db.Query(safesql.New(query, api.CommandStatusDelivered, clientID, api.CommandStatusPending))
+	if err != nil {
+		return nil, fmt.Errorf("failed to deliver commands: %w", err)
+	}
+	defer rows.Close()
+
+	commands, err := scanCommands(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].ID < commands[j].ID })
+	return commands, nil
+}
+
+// GetCommand retrieves a single command by ID
+func (d *Database) GetCommand(id int) (*api.Command, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`SELECT ` + commandColumns + ` FROM client_commands WHERE id = $1 AND `, id).
+		AppendQuery(scope)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query command: %w", err)
+	}
+	defer rows.Close()
+
+	commands, err := scanCommands(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("command not found")
+	}
+	return &commands[0], nil
+}
+
+// DecideCommand moves a staged command to pending (approved) or rejected and
+// records who decided. The staging admin can never approve their own
+// command; the check is part of the update so concurrent decisions cannot
+// bypass it.
+func (d *Database) DecideCommand(id int, username string, approve bool) error {
+	status := api.CommandStatusRejected
+	if approve {
+		status = api.CommandStatusPending
+	}
+
+	query := safesql.New(`
+		UPDATE client_commands
+		SET status = $1, approved_by = $2, approved_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = $4`, status, username, id, api.CommandStatusAwaitingApproval)
+	if approve {
+		query = query.Append(" AND created_by != $1", username)
+	}
+
+	res, err := d.db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to update command: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("command not found")
+	}
+
+	return nil
+}
+
+// CompleteCommand records the result a client reported for a delivered command
+func (d *Database) CompleteCommand(id int, clientID string, result *api.CommandResult) error {
+	const query = `
+		UPDATE client_commands
+		SET status = $1, message = $2, output = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND client_id = $5 AND status = $6
+	`
+
+	res, err := d.db.Exec(safesql.New(query, result.Status, result.Message, result.Output, id, clientID, api.CommandStatusDelivered))
+	if err != nil {
+		return fmt.Errorf("failed to complete command: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("command not found")
+	}
+
+	return nil
+}
+
+// ListCommands returns the most recent commands, optionally for one client
+func (d *Database) ListCommands(clientID string, limit int) ([]api.Command, error) {
+	var rows *sql.Rows
+	var err error
+	if clientID != "" {
+		scope := d.scopeCondition(safesql.New("client_id"))
+		query := safesql.New(`
+			SELECT ` + commandColumns + ` FROM client_commands
+			WHERE client_id = $1 AND `, clientID).
+			AppendQuery(scope).
+			Append(`
+			ORDER BY created_at DESC, id DESC
+			LIMIT $1
+		`, limit)
+		rows, err = d.db.Query(query)
+	} else {
+		scope := d.scopeCondition(safesql.New("client_id"))
+		query := safesql.New(`
+			SELECT ` + commandColumns + ` FROM client_commands
+			WHERE `).
+			AppendQuery(scope).
+			Append(`
+			ORDER BY created_at DESC, id DESC
+			LIMIT $1
+		`, limit)
+		rows, err = d.db.Query(query)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commands: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCommands(rows)
+}
+
+// commandColumns is the column list read by scanCommands
+const commandColumns = `id, client_id, command_type, payload, status, message, output, created_by,
+		       created_at, approved_by, approved_at, delivered_at, completed_at`
+
+// scanCommands reads client_commands rows selected with commandColumns
+func scanCommands(rows *sql.Rows) ([]api.Command, error) {
+	commands := []api.Command{}
+	for rows.Next() {
+		var cmd api.Command
+		var payload, message, output, createdBy, approvedBy sql.NullString
+		var approvedAt, deliveredAt, completedAt sql.NullTime
+
+		err := rows.Scan(
+			&cmd.ID,
+			&cmd.ClientID,
+			&cmd.Type,
+			&payload,
+			&cmd.Status,
+			&message,
+			&output,
+			&createdBy,
+			&cmd.CreatedAt,
+			&approvedBy,
+			&approvedAt,
+			&deliveredAt,
+			&completedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan submission: %w", err)
+			return nil, fmt.Errorf("failed to scan command: %w", err)
 		}
 
-		// Parse timestamp from string
-		sub.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		if payload.Valid && payload.String != "" {
+			var args commandPayload
+			if err := json.Unmarshal([]byte(payload.String), &args); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal command payload: %w", err)
+			}
+			cmd.Reports = args.Reports
+			cmd.Arguments = args.Arguments
 		}
+		cmd.Message = message.String
+		cmd.Output = output.String
+		cmd.CreatedBy = createdBy.String
+		cmd.ApprovedBy = approvedBy.String
+		cmd.ApprovedAt = timePtr(approvedAt)
+		cmd.DeliveredAt = timePtr(deliveredAt)
+		cmd.CompletedAt = timePtr(completedAt)
 
-		submissions = append(submissions, sub)
+		commands = append(commands, cmd)
 	}
 
-	return submissions, nil
+	return commands, rows.Err()
 }
 
-// ClearClientHistory deletes all submissions for a specific client
-func (d *Database) ClearClientHistory(clientID string) (int64, error) {
-	query := fmt.Sprintf(`DELETE FROM submissions WHERE client_id = %s`, d.placeholder(1))
+// AddCommandAudit appends an event to a command's audit trail
+func (d *Database) AddCommandAudit(commandID int, event, actor, details string) error {
+	const query = `
+		INSERT INTO command_audit (command_id, event, actor, details, timestamp)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+	`
 
-	result, err := d.db.Exec(query, clientID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to clear client history: %w", err)
+	if _, err := d.db.Exec(safesql.New(query, commandID, event, actor, details)); err != nil {
+		return fmt.Errorf("failed to record command audit: %w", err)
 	}
+	return nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// ListCommandAudit returns the audit trail of a command, oldest first
+func (d *Database) ListCommandAudit(commandID int) ([]api.CommandAuditEntry, error) {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	query := safesql.New(`
+		SELECT id, command_id, event, actor, details, timestamp
+		FROM command_audit
+		WHERE command_id = $1
+		AND command_id IN (SELECT id FROM client_commands WHERE `, commandID).
+		AppendQuery(scope).
+		Append(`)
+		ORDER BY timestamp, id
+	`)
+	rows, err := d.db.Query(query)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to query command audit: %w", err)
 	}
+	defer rows.Close()
 
-	d.logger.Info("Cleared client history", "client_id", clientID, "submissions_deleted", rowsAffected)
-	return rowsAffected, nil
-}
+	entries := []api.CommandAuditEntry{}
+	for rows.Next() {
+		var entry api.CommandAuditEntry
+		var actor, details sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.CommandID, &entry.Event, &actor, &details, &entry.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan command audit: %w", err)
+		}
+		entry.Actor = actor.String
+		entry.Details = details.String
+		entries = append(entries, entry)
+	}
 
-// ClearAllSubmissions deletes all submissions from all clients (keeps clients registered)
-func (d *Database) ClearAllSubmissions() (int64, error) {
-	query := `DELETE FROM submissions`
+	return entries, rows.Err()
+}
 
-	result, err := d.db.Exec(query)
-	if err != nil {
-		return 0, fmt.Errorf("failed to clear all submissions: %w", err)
+// nullableTime converts an optional time to a value suitable for a TIMESTAMP column
+func nullableTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
 	}
+	return t.UTC().Format(time.RFC3339)
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+// timePtr converts a nullable TIMESTAMP column to an optional time
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
 	}
-
-	d.logger.Info("Cleared all submissions", "submissions_deleted", rowsAffected)
-	return rowsAffected, nil
+	return &t.Time
 }
 
-// Policy represents a compliance policy
-type Policy struct {
-	ID          int    `json:"id"`
-	PolicyID    string `json:"policy_id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Framework   string `json:"framework"`
-	Version     string `json:"version"`
-	Category    string `json:"category"`
-	Author      string `json:"author"`
-	Status      string `json:"status"`
-	PolicyData  string `json:"policy_data"` // JSON
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
-}
+// Policy represents a compliance policy (shared with API clients via pkg/api)
+type Policy = api.Policy
 
 // ListPolicies retrieves all policies
 func (d *Database) ListPolicies() ([]Policy, error) {
-	query := `
+	const query = `
 		SELECT id, policy_id, name, description, framework, version, category, author, status,
-		       policy_data, created_at, updated_at
+		       policy_data, owner, owner_team, signature, provenance, retention_days, created_at, updated_at
 		FROM policies
 		ORDER BY created_at DESC
 	`
 
-	rows, err := d.db.Query(query)
+	rows, err := d.db.Query(safesql.New(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query policies: %w", err)
 	}
@@ -918,7 +2094,8 @@ func (d *Database) ListPolicies() ([]Policy, error) {
 	var policies []Policy
 	for rows.Next() {
 		var p Policy
-		var description, framework, version, category, author sql.NullString
+		var description, framework, version, category, author, owner, ownerTeam, signature, provenance sql.NullString
+		var retentionDays sql.NullInt64
 
 		err := rows.Scan(
 			&p.ID,
@@ -931,6 +2108,11 @@ func (d *Database) ListPolicies() ([]Policy, error) {
 			&author,
 			&p.Status,
 			&p.PolicyData,
+			&owner,
+			&ownerTeam,
+			&signature,
+			&provenance,
+			&retentionDays,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 		)
@@ -954,6 +2136,13 @@ func (d *Database) ListPolicies() ([]Policy, error) {
 		if author.Valid {
 			p.Author = author.String
 		}
+		p.Owner = owner.String
+		p.OwnerTeam = ownerTeam.String
+		p.Signature = signature.String
+		p.RetentionDays = int(retentionDays.Int64)
+		if p.Provenance, err = parsePolicyProvenance(provenance); err != nil {
+			return nil, err
+		}
 
 		policies = append(policies, p)
 	}
@@ -963,17 +2152,18 @@ func (d *Database) ListPolicies() ([]Policy, error) {
 
 // GetPolicy retrieves a specific policy by policy_id
 func (d *Database) GetPolicy(policyID string) (*Policy, error) {
-	query := fmt.Sprintf(`
+	const query = `
 		SELECT id, policy_id, name, description, framework, version, category, author, status,
-		       policy_data, created_at, updated_at
+		       policy_data, owner, owner_team, signature, provenance, retention_days, created_at, updated_at
 		FROM policies
-		WHERE policy_id = %s
-	`, d.placeholder(1))
+		WHERE policy_id = $1
+	`
 
 	var p Policy
-	var description, framework, version, category, author sql.NullString
+	var description, framework, version, category, author, owner, ownerTeam, signature, provenance sql.NullString
+	var retentionDays sql.NullInt64
 
-	err := d.db.QueryRow(query, policyID).Scan(
+	err := d.db.QueryRow(safesql.New(query, policyID)).Scan(
 		&p.ID,
 		&p.PolicyID,
 		&p.Name,
@@ -984,6 +2174,11 @@ func (d *Database) GetPolicy(policyID string) (*Policy, error) {
 		&author,
 		&p.Status,
 		&p.PolicyData,
+		&owner,
+		&ownerTeam,
+		&signature,
+		&provenance,
+		&retentionDays,
 		&p.CreatedAt,
 		&p.UpdatedAt,
 	)
@@ -1011,31 +2206,32 @@ func (d *Database) GetPolicy(policyID string) (*Policy, error) {
 	if author.Valid {
 		p.Author = author.String
 	}
+	p.Owner = owner.String
+	p.OwnerTeam = ownerTeam.String
+	p.Signature = signature.String
+	p.RetentionDays = int(retentionDays.Int64)
+	if p.Provenance, err = parsePolicyProvenance(provenance); err != nil {
+		return nil, err
+	}
 
 	return &p, nil
 }
 
-// CreatePolicy creates a new policy
+// CreatePolicy creates a new policy, owned by p.Owner and p.OwnerTeam
 func (d *Database) CreatePolicy(p *Policy) error {
-	query := fmt.Sprintf(`
+	const query = `
 		INSERT INTO policies (
-			policy_id, name, description, framework, version, category, author, status, policy_data
-		) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4),
-		d.placeholder(5), d.placeholder(6), d.placeholder(7), d.placeholder(8), d.placeholder(9))
-
-	_, err := d.db.Exec(
-		query,
-		p.PolicyID,
-		p.Name,
-		p.Description,
-		p.Framework,
-		p.Version,
-		p.Category,
-		p.Author,
-		p.Status,
-		p.PolicyData,
-	)
+			policy_id, name, description, framework, version, category, author, status, policy_data,
+			owner, owner_team, signature, provenance, retention_days
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+
+	provenance, err := encodePolicyProvenance(p.Provenance)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(safesql.New(query, p.PolicyID, p.Name, p.Description, p.Framework, p.Version, p.Category, p.Author, p.Status, p.PolicyData, nullIfEmpty(p.Owner), nullIfEmpty(p.OwnerTeam), nullIfEmpty(p.Signature), provenance, nullIfZero(p.RetentionDays)))
 
 	if err != nil {
 		return fmt.Errorf("failed to create policy: %w", err)
@@ -1045,32 +2241,79 @@ func (d *Database) CreatePolicy(p *Policy) error {
 	return nil
 }
 
-// UpdatePolicy updates an existing policy
+// UpdatePolicy updates an existing policy, including its signature,
+// provenance and retention, which are cleared if p has none. Its owners are left
+// unchanged; use SetPolicyOwner to change them. The replaced version is kept
+// as a policy revision.
 func (d *Database) UpdatePolicy(policyID string, p *Policy) error {
-	query := fmt.Sprintf(`
+	const query = `
 		UPDATE policies
-		SET name = %s, description = %s, framework = %s, version = %s, category = %s,
-		    author = %s, status = %s, policy_data = %s, updated_at = CURRENT_TIMESTAMP
-		WHERE policy_id = %s
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4),
-		d.placeholder(5), d.placeholder(6), d.placeholder(7), d.placeholder(8), d.placeholder(9))
-
-	result, err := d.db.Exec(
-		query,
-		p.Name,
-		p.Description,
-		p.Framework,
-		p.Version,
-		p.Category,
-		p.Author,
-		p.Status,
-		p.PolicyData,
-		policyID,
-	)
+		SET name = $1, description = $2, framework = $3, version = $4, category = $5,
+		    author = $6, status = $7, policy_data = $8, signature = $9, provenance = $10,
+		    retention_days = $11, updated_at = CURRENT_TIMESTAMP
+		WHERE policy_id = $12
+	`
+
+	provenance, err := encodePolicyProvenance(p.Provenance)
+	if err != nil {
+		return err
+	}
 
+	tx, err := d.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := savePolicyRevision(tx, policyID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(safesql.New(query, p.Name, p.Description, p.Framework, p.Version, p.Category, p.Author, p.Status, p.PolicyData, nullIfEmpty(p.Signature), provenance, nullIfZero(p.RetentionDays), policyID)); err != nil {
 		return fmt.Errorf("failed to update policy: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit policy update: %w", err)
+	}
+
+	d.logger.Info("Policy updated", "policy_id", policyID)
+	return nil
+}
+
+// encodePolicyProvenance encodes provenance for the policies table; nil is
+// stored as NULL
+func encodePolicyProvenance(provenance *api.PolicyProvenance) (interface{}, error) {
+	if provenance == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(provenance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode policy provenance: %w", err)
+	}
+	return string(data), nil
+}
+
+// parsePolicyProvenance decodes a provenance column
+func parsePolicyProvenance(value sql.NullString) (*api.PolicyProvenance, error) {
+	if !value.Valid || value.String == "" {
+		return nil, nil
+	}
+	var provenance api.PolicyProvenance
+	if err := json.Unmarshal([]byte(value.String), &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse policy provenance: %w", err)
+	}
+	return &provenance, nil
+}
+
+// SetPolicyOwner replaces the user and team owning a policy. Empty values
+// clear them.
+func (d *Database) SetPolicyOwner(policyID, owner, ownerTeam string) error {
+	const query = `UPDATE policies SET owner = $1, owner_team = $2 WHERE policy_id = $3`
+
+	result, err := d.db.Exec(safesql.New(query, nullIfEmpty(owner), nullIfEmpty(ownerTeam), policyID))
+	if err != nil {
+		return fmt.Errorf("failed to update policy owner: %w", err)
+	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -1081,15 +2324,31 @@ func (d *Database) UpdatePolicy(policyID string, p *Policy) error {
 		return fmt.Errorf("policy not found")
 	}
 
-	d.logger.Info("Policy updated", "policy_id", policyID)
+	d.logger.Info("Policy owner updated", "policy_id", policyID, "owner", owner, "owner_team", ownerTeam)
 	return nil
 }
 
+// nullIfEmpty stores empty strings as NULL
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullIfZero stores zero as NULL
+func nullIfZero(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
 // DeletePolicy deletes a policy
 func (d *Database) DeletePolicy(policyID string) error {
-	query := fmt.Sprintf(`DELETE FROM policies WHERE policy_id = %s`, d.placeholder(1))
+	const query = `DELETE FROM policies WHERE policy_id = $1`
 
-	result, err := d.db.Exec(query, policyID)
+	result, err := d.db.Exec(safesql.New(query, policyID))
 	if err != nil {
 		return fmt.Errorf("failed to delete policy: %w", err)
 	}
@@ -1109,20 +2368,54 @@ func (d *Database) DeletePolicy(policyID string) error {
 
 // User represents a user account
 type User struct {
-	ID           int    `json:"id"`
-	Username     string `json:"username"`
-	PasswordHash string `json:"-"` // Never expose in JSON
-	Role         string `json:"role"`
-	CreatedAt    string `json:"created_at"`
-	LastLogin    string `json:"last_login,omitempty"`
+	ID           int             `json:"id"`
+	Username     string          `json:"username"`
+	PasswordHash string          `json:"-"` // Never expose in JSON
+	Role         string          `json:"role"`
+	Team         string          `json:"team,omitempty"` // Team whose policies the user may edit
+	Scope        api.ClientScope `json:"-"`              // Clients visible to an operator
+	CreatedAt    string          `json:"created_at"`
+	LastLogin    string          `json:"last_login,omitempty"`
+	LockedUntil  *time.Time      `json:"-"` // Set while failed logins keep the account locked
+	Timezone     string          `json:"-"` // Display time zone preference; empty for the default
+	Locale       string          `json:"-"` // Display locale preference; empty for the browser's
+	Email        string          `json:"email,omitempty"`
+	// MustChangePassword is set for invited users until they accept the
+	// invitation and choose a password
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+
+	DisplayName   string                      `json:"display_name,omitempty"`
+	Notifications api.NotificationPreferences `json:"-"`
 }
 
-// CreateUser creates a new user with hashed password
-func (d *Database) CreateUser(username, passwordHash, role string) error {
-	query := fmt.Sprintf(`INSERT INTO users (username, password_hash, role) VALUES (%s, %s, %s)`,
-		d.placeholder(1), d.placeholder(2), d.placeholder(3))
+// Info returns the public representation of the user
+func (u User) Info() api.UserInfo {
+	return api.UserInfo{
+		ID:        u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		Team:      u.Team,
+		Scope:     userScope(&u),
+		CreatedAt: u.CreatedAt,
+		LastLogin: u.LastLogin,
+		Email:     u.Email,
+
+		DisplayName:        u.DisplayName,
+		MustChangePassword: u.MustChangePassword,
+	}
+}
+
+// CreateUser creates a new user with hashed password. team may be empty;
+// scope is the client scope of an operator and nil for other roles.
+func (d *Database) CreateUser(username, passwordHash, role, team string, scope *api.ClientScope) error {
+	orgs, tags, err := marshalScope(scope)
+	if err != nil {
+		return err
+	}
+
+	const query = `INSERT INTO users (username, password_hash, role, team, scope_orgs, scope_tags) VALUES ($1, $2, $3, $4, $5, $6)`
 
-	_, err := d.db.Exec(query, username, passwordHash, role)
+	_, err = d.db.Exec(safesql.New(query, username, passwordHash, role, nullIfEmpty(team), orgs, tags))
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -1133,19 +2426,34 @@ func (d *Database) CreateUser(username, passwordHash, role string) error {
 
 // GetUser retrieves a user by username
 func (d *Database) GetUser(username string) (*User, error) {
-	query := fmt.Sprintf(`SELECT id, username, password_hash, role, created_at, last_login FROM users WHERE username = %s`,
-		d.placeholder(1))
+	const query = `SELECT id, username, password_hash, role, team, scope_orgs, scope_tags, created_at, last_login,
+		CASE WHEN account_locked_until > NOW() THEN account_locked_until END, timezone, locale,
+		email, must_change_password, display_name, notify_alerts, notify_key_expiry, notify_digest
+		FROM users WHERE username = $1`
 
 	var user User
-	var lastLogin sql.NullString
+	var team, lastLogin, scopeOrgs, scopeTags sql.NullString
+	var lockedUntil sql.NullTime
 
-	err := d.db.QueryRow(query, username).Scan(
+	err := d.db.QueryRow(safesql.New(query, username)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
 		&user.Role,
+		&team,
+		&scopeOrgs,
+		&scopeTags,
 		&user.CreatedAt,
 		&lastLogin,
+		&lockedUntil,
+		&user.Timezone,
+		&user.Locale,
+		&user.Email,
+		&user.MustChangePassword,
+		&user.DisplayName,
+		&user.Notifications.Alerts,
+		&user.Notifications.APIKeyExpiry,
+		&user.Notifications.Digest,
 	)
 
 	if err == sql.ErrNoRows {
@@ -1155,18 +2463,27 @@ func (d *Database) GetUser(username string) (*User, error) {
 		return nil, fmt.Errorf("failed to query user: %w", err)
 	}
 
+	user.Team = team.String
 	if lastLogin.Valid {
 		user.LastLogin = lastLogin.String
 	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if user.Scope, err = unmarshalScope(scopeOrgs, scopeTags); err != nil {
+		return nil, err
+	}
 
 	return &user, nil
 }
 
 // ListUsers retrieves all users
 func (d *Database) ListUsers() ([]User, error) {
-	query := `SELECT id, username, role, created_at, last_login FROM users ORDER BY created_at DESC`
+	const query = `SELECT id, username, role, team, scope_orgs, scope_tags, created_at, last_login, email, must_change_password,
+		display_name, notify_alerts, notify_key_expiry, notify_digest
+		FROM users ORDER BY created_at DESC`
 
-	rows, err := d.db.Query(query)
+	rows, err := d.db.Query(safesql.New(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %w", err)
 	}
@@ -1175,22 +2492,35 @@ func (d *Database) ListUsers() ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var user User
-		var lastLogin sql.NullString
+		var team, lastLogin, scopeOrgs, scopeTags sql.NullString
 
 		err := rows.Scan(
 			&user.ID,
 			&user.Username,
 			&user.Role,
+			&team,
+			&scopeOrgs,
+			&scopeTags,
 			&user.CreatedAt,
 			&lastLogin,
+			&user.Email,
+			&user.MustChangePassword,
+			&user.DisplayName,
+			&user.Notifications.Alerts,
+			&user.Notifications.APIKeyExpiry,
+			&user.Notifications.Digest,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 
+		user.Team = team.String
 		if lastLogin.Valid {
 			user.LastLogin = lastLogin.String
 		}
+		if user.Scope, err = unmarshalScope(scopeOrgs, scopeTags); err != nil {
+			return nil, err
+		}
 
 		users = append(users, user)
 	}
@@ -1200,10 +2530,9 @@ func (d *Database) ListUsers() ([]User, error) {
 
 // UpdateUserLastLogin updates the last_login timestamp
 func (d *Database) UpdateUserLastLogin(username string) error {
-	query := fmt.Sprintf(`UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE username = %s`,
-		d.placeholder(1))
+	const query = `UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE username = $1`
 
-	_, err := d.db.Exec(query, username)
+	_, err := d.db.Exec(safesql.New(query, username))
 	if err != nil {
 		return fmt.Errorf("failed to update last login: %w", err)
 	}
@@ -1211,14 +2540,108 @@ func (d *Database) UpdateUserLastLogin(username string) error {
 	return nil
 }
 
-// UpdateUserPassword updates a user's password hash
-func (d *Database) UpdateUserPassword(username, passwordHash string) error {
-	query := fmt.Sprintf(`UPDATE users SET password_hash = %s WHERE username = %s`,
-		d.placeholder(1), d.placeholder(2))
+// RecordFailedLogin counts a failed login for a user, returning the time
+// the account is locked until if the policy locked it
+func (d *Database) RecordFailedLogin(userID int, policy auth.LockoutPolicy) (*time.Time, error) {
+	lockedUntil, err := auth.RecordFailedLogin(context.Background(), d.db.DB, userID, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failed login: %w", err)
+	}
+	return lockedUntil, nil
+}
+
+// ResetFailedLogins clears a user's failed logins after a successful login
+func (d *Database) ResetFailedLogins(userID int) error {
+	if err := auth.ResetFailedLogins(context.Background(), d.db.DB, userID); err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
 
-	result, err := d.db.Exec(query, passwordHash, username)
+// SetUserPreferences stores the time zone and locale a user's dashboard
+// shows timestamps in
+func (d *Database) SetUserPreferences(username, timezone, locale string) error {
+	const query = `UPDATE users SET timezone = $1, locale = $2 WHERE username = $3`
+
+	result, err := d.db.Exec(safesql.New(query, timezone, locale, username))
 	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+		return fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetUserScope replaces the client scope of an operator
+func (d *Database) SetUserScope(username string, scope api.ClientScope) error {
+	orgs, tags, err := marshalScope(&scope)
+	if err != nil {
+		return err
+	}
+
+	const query = `UPDATE users SET scope_orgs = $1, scope_tags = $2 WHERE username = $3 AND role = $4`
+
+	result, err := d.db.Exec(safesql.New(query, orgs, tags, username, roleOperator))
+	if err != nil {
+		return fmt.Errorf("failed to update user scope: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("operator not found")
+	}
+
+	d.logger.Info("User scope updated", "username", username, "orgs", scope.Orgs, "tags", scope.Tags)
+	return nil
+}
+
+// SetUserTeam sets the team of a user. An empty team removes the user from
+// their team.
+func (d *Database) SetUserTeam(username, team string) error {
+	const query = `UPDATE users SET team = $1 WHERE username = $2`
+
+	result, err := d.db.Exec(safesql.New(query, nullIfEmpty(team), username))
+	if err != nil {
+		return fmt.Errorf("failed to update user team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	d.logger.Info("User team updated", "username", username, "team", team)
+	return nil
+}
+
+// UpdateUserAccess replaces the role, team and client scope of a user. scope
+// is nil for roles other than operator.
+func (d *Database) UpdateUserAccess(username, role, team string, scope *api.ClientScope) error {
+	orgs, tags, err := marshalScope(scope)
+	if err != nil {
+		return err
+	}
+
+	const query = `UPDATE users SET role = $1, team = $2, scope_orgs = $3, scope_tags = $4 WHERE username = $5`
+
+	result, err := d.db.Exec(safesql.New(query, role, nullIfEmpty(team), orgs, tags, username))
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -1230,15 +2653,78 @@ func (d *Database) UpdateUserPassword(username, passwordHash string) error {
 		return fmt.Errorf("user not found")
 	}
 
+	d.logger.Info("User access updated", "username", username, "role", role, "team", team)
+	return nil
+}
+
+// marshalScope converts a client scope to the JSON stored in scope_orgs and
+// scope_tags. A nil scope is stored as NULL.
+func marshalScope(scope *api.ClientScope) (orgs, tags interface{}, err error) {
+	if scope == nil {
+		return nil, nil, nil
+	}
+	orgsJSON, err := json.Marshal(scope.Orgs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal scope: %w", err)
+	}
+	tagsJSON, err := json.Marshal(scope.Tags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal scope: %w", err)
+	}
+	return string(orgsJSON), string(tagsJSON), nil
+}
+
+// unmarshalScope reads the scope_orgs and scope_tags columns
+func unmarshalScope(orgs, tags sql.NullString) (api.ClientScope, error) {
+	var scope api.ClientScope
+	if orgs.String != "" {
+		if err := json.Unmarshal([]byte(orgs.String), &scope.Orgs); err != nil {
+			return scope, fmt.Errorf("failed to unmarshal scope: %w", err)
+		}
+	}
+	if tags.String != "" {
+		if err := json.Unmarshal([]byte(tags.String), &scope.Tags); err != nil {
+			return scope, fmt.Errorf("failed to unmarshal scope: %w", err)
+		}
+	}
+	return scope, nil
+}
+
+// UpdateUserPassword updates a user's password hash and ends the user's
+// dashboard sessions
+func (d *Database) UpdateUserPassword(username, passwordHash string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin password update: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(safesql.New(`UPDATE users SET password_hash = $1 WHERE username = $2 RETURNING id`, passwordHash, username)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := tx.Exec(safesql.New(`DELETE FROM sessions WHERE user_id = $1`, userID)); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password update: %w", err)
+	}
+
 	d.logger.Info("User password updated", "username", username)
 	return nil
 }
 
 // DeleteUser deletes a user
 func (d *Database) DeleteUser(username string) error {
-	query := fmt.Sprintf(`DELETE FROM users WHERE username = %s`, d.placeholder(1))
+	const query = `DELETE FROM users WHERE username = $1`
 
-	result, err := d.db.Exec(query, username)
+	result, err := d.db.Exec(safesql.New(query, username))
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -1258,10 +2744,10 @@ func (d *Database) DeleteUser(username string) error {
 
 // UserExists checks if a user exists
 func (d *Database) UserExists(username string) (bool, error) {
-	query := fmt.Sprintf(`SELECT COUNT(*) FROM users WHERE username = %s`, d.placeholder(1))
+	const query = `SELECT COUNT(*) FROM users WHERE username = $1`
 
 	var count int
-	err := d.db.QueryRow(query, username).Scan(&count)
+	err := d.db.QueryRow(safesql.New(query, username)).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -1271,10 +2757,10 @@ func (d *Database) UserExists(username string) (bool, error) {
 
 // HasAnyUsers checks if any users exist in the database
 func (d *Database) HasAnyUsers() (bool, error) {
-	query := `SELECT COUNT(*) FROM users`
+	const query = `SELECT COUNT(*) FROM users`
 
 	var count int
-	err := d.db.QueryRow(query).Scan(&count)
+	err := d.db.QueryRow(safesql.New(query)).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check users: %w", err)
 	}
@@ -1295,14 +2781,28 @@ type APIKey struct {
 	IsActive  bool   `json:"is_active"`
 }
 
+// Info returns the public representation of the API key
+func (k APIKey) Info() api.APIKeyInfo {
+	return api.APIKeyInfo{
+		ID:        k.ID,
+		Name:      k.Name,
+		KeyPrefix: k.KeyPrefix,
+		CreatedBy: k.CreatedBy,
+		CreatedAt: k.CreatedAt,
+		LastUsed:  k.LastUsed,
+		ExpiresAt: k.ExpiresAt,
+		IsActive:  k.IsActive,
+	}
+}
+
 // CreateAPIKey creates a new API key in the database
 func (d *Database) CreateAPIKey(name, keyHash, keyPrefix, createdBy string, expiresAt *string) error {
-	query := fmt.Sprintf(`
+	const query = `
 		INSERT INTO api_keys (name, key_hash, key_prefix, created_by, expires_at, is_active)
-		VALUES (%s, %s, %s, %s, %s, %s)
-	`, d.placeholder(1), d.placeholder(2), d.placeholder(3), d.placeholder(4), d.placeholder(5), d.getBooleanDefault(true))
+		VALUES ($1, $2, $3, $4, $5, true)
+	`
 
-	_, err := d.db.Exec(query, name, keyHash, keyPrefix, createdBy, expiresAt)
+	_, err := d.db.Exec(safesql.New(query, name, keyHash, keyPrefix, createdBy, expiresAt))
 	if err != nil {
 		return fmt.Errorf("failed to create API key: %w", err)
 	}
@@ -1313,13 +2813,13 @@ func (d *Database) CreateAPIKey(name, keyHash, keyPrefix, createdBy string, expi
 
 // ListAPIKeys retrieves all API keys
 func (d *Database) ListAPIKeys() ([]APIKey, error) {
-	query := `
+	const query = `
 		SELECT id, name, key_hash, key_prefix, created_by, created_at, last_used, expires_at, is_active
 		FROM api_keys
 		ORDER BY created_at DESC
 	`
 
-	rows, err := d.db.Query(query)
+	rows, err := d.db.Query(safesql.New(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query API keys: %w", err)
 	}
@@ -1361,16 +2861,16 @@ func (d *Database) ListAPIKeys() ([]APIKey, error) {
 
 // GetAPIKeyByHash retrieves an API key by its hash
 func (d *Database) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
-	query := fmt.Sprintf(`
+	const query = `
 		SELECT id, name, key_hash, key_prefix, created_by, created_at, last_used, expires_at, is_active
 		FROM api_keys
-		WHERE key_hash = %s AND is_active = %s
-	`, d.placeholder(1), d.getBooleanDefault(true))
+		WHERE key_hash = $1 AND is_active = true
+	`
 
 	var key APIKey
 	var lastUsed, expiresAt sql.NullString
 
-	err := d.db.QueryRow(query, keyHash).Scan(
+	err := d.db.QueryRow(safesql.New(query, keyHash)).Scan(
 		&key.ID,
 		&key.Name,
 		&key.KeyHash,
@@ -1398,15 +2898,49 @@ func (d *Database) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
 	return &key, nil
 }
 
+// GetAPIKey retrieves an API key by ID, active or not
+func (d *Database) GetAPIKey(id int) (*APIKey, error) {
+	const query = `
+		SELECT id, name, key_hash, key_prefix, created_by, created_at, last_used, expires_at, is_active
+		FROM api_keys
+		WHERE id = $1
+	`
+
+	var key APIKey
+	var lastUsed, expiresAt sql.NullString
+
+	err := d.db.QueryRow(safesql.New(query, id)).Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.KeyPrefix,
+		&key.CreatedBy,
+		&key.CreatedAt,
+		&lastUsed,
+		&expiresAt,
+		&key.IsActive,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+
+	key.LastUsed = lastUsed.String
+	key.ExpiresAt = expiresAt.String
+	return &key, nil
+}
+
 // ListActiveAPIKeyHashes retrieves all active API key hashes for authentication
 func (d *Database) ListActiveAPIKeyHashes() ([]string, error) {
-	query := fmt.Sprintf(`
+	const query = `
 		SELECT key_hash
 		FROM api_keys
-		WHERE is_active = %s AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
-	`, d.getBooleanDefault(true))
+		WHERE is_active = true AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+	`
 
-	rows, err := d.db.Query(query)
+	rows, err := d.db.Query(safesql.New(query))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active API key hashes: %w", err)
 	}
@@ -1427,10 +2961,9 @@ func (d *Database) ListActiveAPIKeyHashes() ([]string, error) {
 
 // UpdateAPIKeyLastUsed updates the last_used timestamp for an API key
 func (d *Database) UpdateAPIKeyLastUsed(keyHash string) error {
-	query := fmt.Sprintf(`UPDATE api_keys SET last_used = CURRENT_TIMESTAMP WHERE key_hash = %s`,
-		d.placeholder(1))
+	const query = `UPDATE api_keys SET last_used = CURRENT_TIMESTAMP WHERE key_hash = $1`
 
-	_, err := d.db.Exec(query, keyHash)
+	_, err := d.db.Exec(safesql.New(query, keyHash))
 	if err != nil {
 		return fmt.Errorf("failed to update API key last used: %w", err)
 	}
@@ -1440,9 +2973,9 @@ func (d *Database) UpdateAPIKeyLastUsed(keyHash string) error {
 
 // DeleteAPIKey deletes an API key by ID
 func (d *Database) DeleteAPIKey(id int) error {
-	query := fmt.Sprintf(`DELETE FROM api_keys WHERE id = %s`, d.placeholder(1))
+	const query = `DELETE FROM api_keys WHERE id = $1`
 
-	result, err := d.db.Exec(query, id)
+	result, err := d.db.Exec(safesql.New(query, id))
 	if err != nil {
 		return fmt.Errorf("failed to delete API key: %w", err)
 	}
@@ -1460,12 +2993,34 @@ func (d *Database) DeleteAPIKey(id int) error {
 	return nil
 }
 
+// UpdateAPIKey replaces the key material and expiry of an API key by ID. The
+// creator is warned again before the new expiry.
+func (d *Database) UpdateAPIKey(id int, keyHash, keyPrefix string, expiresAt *string) error {
+	const query = `UPDATE api_keys SET key_hash = $1, key_prefix = $2, expires_at = $3, expiry_notified_at = NULL WHERE id = $4`
+
+	result, err := d.db.Exec(safesql.New(query, keyHash, keyPrefix, expiresAt, id))
+	if err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key not found")
+	}
+
+	d.logger.Info("API key updated", "id", id)
+	return nil
+}
+
 // DeactivateAPIKey deactivates an API key by ID
 func (d *Database) DeactivateAPIKey(id int) error {
-	query := fmt.Sprintf(`UPDATE api_keys SET is_active = %s WHERE id = %s`,
-		d.getBooleanDefault(false), d.placeholder(1))
+	const query = `UPDATE api_keys SET is_active = false WHERE id = $1`
 
-	result, err := d.db.Exec(query, id)
+	result, err := d.db.Exec(safesql.New(query, id))
 	if err != nil {
 		return fmt.Errorf("failed to deactivate API key: %w", err)
 	}
@@ -1485,10 +3040,9 @@ func (d *Database) DeactivateAPIKey(id int) error {
 
 // ActivateAPIKey activates an API key by ID
 func (d *Database) ActivateAPIKey(id int) error {
-	query := fmt.Sprintf(`UPDATE api_keys SET is_active = %s WHERE id = %s`,
-		d.getBooleanDefault(true), d.placeholder(1))
+	const query = `UPDATE api_keys SET is_active = true WHERE id = $1`
 
-	result, err := d.db.Exec(query, id)
+	result, err := d.db.Exec(safesql.New(query, id))
 	if err != nil {
 		return fmt.Errorf("failed to activate API key: %w", err)
 	}
@@ -1505,3 +3059,36 @@ func (d *Database) ActivateAPIKey(id int) error {
 	d.logger.Info("API key activated", "id", id)
 	return nil
 }
+
+// Names of the secrets shared by all server replicas in server_secrets
+const (
+	secretJWT            = "jwt_secret_key"
+	secretCommandSigning = "command_signing_key"
+)
+
+// SharedSecret returns the named secret from server_secrets, storing the
+// result of generate first if no server has created it yet. The second
+// result reports whether this call created it.
+func (d *Database) SharedSecret(name string, generate func() (string, error)) (string, bool, error) {
+	value, err := generate()
+	if err != nil {
+		return "", false, err
+	}
+
+	result, err := d.db.Exec(safesql.New(`INSERT INTO server_secrets (name, value) VALUES ($1, $2) ON CONFLICT(name) DO NOTHING`, name, value))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to store %s: %w", name, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 1 {
+		return value, true, nil
+	}
+
+	if err := d.db.QueryRow(safesql.New(`SELECT value FROM server_secrets WHERE name = $1`, name)).Scan(&value); err != nil {
+		return "", false, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	return value, false, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dbTimeLayouts are the formats timestamps read back as strings come in:
+// RFC 3339 from TIMESTAMP columns, and the text older versions stored
+// (SQLite DATETIME, PostgreSQL text output, Go's time.Time.String).
+// Layouts without an offset are UTC, as the server stores it.
+var dbTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseStoredTime parses a timestamp column scanned into a string, in any
+// format the server has written, returning it in UTC. Unix seconds are
+// accepted too.
+func parseStoredTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	// time.Time.String appends the monotonic clock reading
+	if i := strings.Index(value, " m="); i >= 0 {
+		value = value[:i]
+	}
+
+	for _, layout := range dbTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+// parseDBTime parses a timestamp column that was scanned into a string.
+// It returns the zero time when the value is empty or not recognised.
+func parseDBTime(value string) time.Time {
+	t, _ := parseStoredTime(value)
+	return t
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseDBTime tests parsing of string-scanned timestamp columns,
+// including the formats rows written by older versions hold
+func TestParseDBTime(t *testing.T) {
+	noon := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2025-03-01T12:00:00Z", noon},
+		{"2025-03-01T12:00:00.123456Z", time.Date(2025, 3, 1, 12, 0, 0, 123456000, time.UTC)},
+		{"2025-03-01T13:00:00+01:00", noon},
+		{"2025-03-01 12:00:00", noon},
+		{"2025-03-01T12:00:00", noon},
+		{"2025-03-01 07:00:00-05", noon},
+		{"2025-03-01 14:00:00+02:00", noon},
+		{"2025-03-01 13:00:00.5 +0100 CET m=+0.000123", noon.Add(500 * time.Millisecond)},
+		{"  2025-03-01 12:00:00  ", noon},
+		{"2025-03-01", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"1740830400", noon},
+		{"", time.Time{}},
+		{"not a time", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got := parseDBTime(tt.value)
+			if !got.Equal(tt.want) {
+				t.Errorf("parseDBTime(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			if !got.IsZero() && got.Location() != time.UTC {
+				t.Errorf("parseDBTime(%q) location = %v, want UTC", tt.value, got.Location())
+			}
+		})
+	}
+
+	if _, err := parseStoredTime("03/01/2025"); err == nil {
+		t.Error("parseStoredTime() of an unknown format error = nil, want error")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// submissionState is the full set of check results of a client's latest
+// submission of a report type, which its next delta is applied to
+type submissionState struct {
+	SubmissionID string
+	Timestamp    time.Time
+	Hash         string
+	Queries      []api.QueryResult
+}
+
+// newSubmissionState returns the state a submission leaves, without check
+// durations, which deltas do not carry
+func newSubmissionState(submission *api.ComplianceSubmission) submissionState {
+	queries := make([]api.QueryResult, len(submission.Compliance.Queries))
+	for i, q := range submission.Compliance.Queries {
+		q.DurationMs = 0
+		queries[i] = q
+	}
+	return submissionState{
+		SubmissionID: submission.SubmissionID,
+		Timestamp:    submission.Timestamp,
+		Hash:         api.QueryStateHash(queries),
+		Queries:      queries,
+	}
+}
+
+// handleSubmitDelta handles submissions carrying only the checks that
+// changed since the client's previous submission of the report
+// (POST /api/v1/compliance/submit-delta). The full check results are
+// rebuilt from the stored state; when that is not possible the client is
+// answered 409 Conflict and sends the full submission.
+func (s *ComplianceServer) handleSubmitDelta(w http.ResponseWriter, r *http.Request) {
+	var submission api.ComplianceSubmission
+	if !s.decodeSubmission(w, r, &submission) {
+		return
+	}
+
+	delta := submission.Delta
+	switch {
+	case delta == nil || delta.BaseSubmissionID == "" || delta.StateHash == "":
+		s.sendError(w, http.StatusBadRequest, "delta with base_submission_id and state_hash is required")
+		return
+	case len(submission.Compliance.Queries) > 0:
+		s.sendError(w, http.StatusBadRequest, "A delta submission carries its checks in delta, not compliance.queries")
+		return
+	case submission.ClientID == "" || submission.ReportType == "":
+		s.sendError(w, http.StatusBadRequest, "client_id and report_type are required")
+		return
+	}
+
+	state, err := s.requestDB(r).GetSubmissionState(submission.ClientID, submission.ReportType)
+	if err != nil && err.Error() != "submission state not found" {
+		s.logger.Error("Failed to get submission state", "error", err, "client_id", submission.ClientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to read submission state")
+		return
+	}
+	if state == nil || state.SubmissionID != delta.BaseSubmissionID {
+		s.logger.Info("Delta submission base is not the latest; full submission required",
+			"client_id", submission.ClientID, "report_type", submission.ReportType, "base", delta.BaseSubmissionID)
+		s.sendError(w, http.StatusConflict, fmt.Sprintf("Submission %s is not the latest stored for this report; send the full submission", delta.BaseSubmissionID))
+		return
+	}
+
+	queries, err := delta.Apply(state.Queries)
+	if err != nil {
+		s.logger.Warn("Delta submission does not rebuild the client's state; full submission required",
+			"client_id", submission.ClientID, "report_type", submission.ReportType, "error", err)
+		s.sendError(w, http.StatusConflict, "The delta does not match the stored state: "+err.Error()+"; send the full submission")
+		return
+	}
+	submission.Compliance.Queries = queries
+	submission.Delta = nil
+
+	if err := submission.Validate(); err != nil {
+		s.logger.Warn("Submission validation failed", "error", err)
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.logger.Debug("Delta submission rebuilt",
+		"submission_id", submission.SubmissionID,
+		"changed", len(delta.Changed),
+		"removed", len(delta.Removed),
+		"checks", len(queries),
+	)
+	s.storeSubmission(w, &submission, "delta")
+}
+
+// GetSubmissionState returns the stored state of a client's report type,
+// or the error "submission state not found"
+func (d *Database) GetSubmissionState(clientID, reportType string) (*submissionState, error) {
+	var state submissionState
+	var timestamp string
+	var queries []byte
+	err := d.db.QueryRow(safesql.New(`
+		SELECT submission_id, timestamp, state_hash, queries
+		FROM submission_states
+		WHERE client_id = $1 AND report_type = $2
+	`, clientID, reportType)).Scan(&state.SubmissionID, &timestamp, &state.Hash, &queries)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("submission state not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submission state: %w", err)
+	}
+
+	state.Timestamp = parseDBTime(timestamp)
+	if err := json.Unmarshal(queries, &state.Queries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal submission state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveSubmissionState stores the state a submission left for its client and
+// report type, unless a later submission's is already stored (a cached
+// submission delivered late)
+func (d *Database) SaveSubmissionState(clientID, reportType string, state submissionState) error {
+	queries, err := json.Marshal(state.Queries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal submission state: %w", err)
+	}
+
+	_, err = d.db.Exec(safesql.New(`
+		INSERT INTO submission_states (client_id, report_type, submission_id, timestamp, state_hash, queries)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (client_id, report_type) DO UPDATE
+		SET submission_id = EXCLUDED.submission_id, timestamp = EXCLUDED.timestamp,
+		    state_hash = EXCLUDED.state_hash, queries = EXCLUDED.queries
+		WHERE submission_states.timestamp <= EXCLUDED.timestamp
+	`, clientID, reportType, state.SubmissionID, state.Timestamp.UTC().Format(time.RFC3339), state.Hash, string(queries)))
+	if err != nil {
+		return fmt.Errorf("failed to save submission state: %w", err)
+	}
+	return nil
+}
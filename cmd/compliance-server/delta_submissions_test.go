@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestNewSubmissionState tests that stored states drop durations and hash
+// like the client does
+func TestNewSubmissionState(t *testing.T) {
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		Timestamp:    time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+		Compliance: api.ComplianceData{Queries: []api.QueryResult{
+			{Name: "firewall", Status: "pass", DurationMs: 12},
+		}},
+	}
+
+	state := newSubmissionState(submission)
+	if state.SubmissionID != "sub-1" || len(state.Queries) != 1 || state.Queries[0].DurationMs != 0 {
+		t.Errorf("state = %+v", state)
+	}
+	if state.Hash != api.QueryStateHash(submission.Compliance.Queries) {
+		t.Error("state hash differs from the client's hash of the same results")
+	}
+	if submission.Compliance.Queries[0].DurationMs != 12 {
+		t.Error("newSubmissionState() changed the submission")
+	}
+}
+
+// TestSubmitDeltaValidation tests delta submissions refused before the
+// stored state is read, and deltas sent to the full submission endpoint
+func TestSubmitDeltaValidation(t *testing.T) {
+	s := newTestServer()
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/api/v1/compliance/submit-delta", `not json`},
+		{"/api/v1/compliance/submit-delta", `{"client_id":"c1","report_type":"NIST"}`},
+		{"/api/v1/compliance/submit-delta", `{"client_id":"c1","report_type":"NIST","delta":{"base_submission_id":"s1"}}`},
+		{"/api/v1/compliance/submit-delta", `{"report_type":"NIST","delta":{"base_submission_id":"s1","state_hash":"abc"}}`},
+		{"/api/v1/compliance/submit-delta", `{"client_id":"c1","report_type":"NIST","compliance":{"queries":[{"name":"a"}]},"delta":{"base_submission_id":"s1","state_hash":"abc"}}`},
+		{"/api/v1/compliance/submit", `{"client_id":"c1","report_type":"NIST","delta":{"base_submission_id":"s1","state_hash":"abc"}}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want 400", tt.path, tt.body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+	"github.com/google/uuid"
+)
+
+const (
+	// requestIDHeader carries the ID a request is logged under. A valid ID
+	// sent by the client, or a proxy in front of the server, is kept.
+	requestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength bounds the IDs accepted from clients
+	maxRequestIDLength = 64
+
+	// problemContentType is the media type of RFC 7807 error responses
+	problemContentType = "application/problem+json"
+)
+
+// errorPage renders errors on dashboard routes for browsers
+var errorPage = template.Must(template.ParseFS(assets, path.Join(templatesDir, "error.html")))
+
+// requestIDKey keys the request ID in a request context
+type requestIDKey struct{}
+
+// requestIDMiddleware gives every request an ID, sent back in the
+// X-Request-ID header, included in error responses and logged, so a failure
+// a user reports can be found in the server log
+func (s *ComplianceServer) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID reports whether id, sent by a client, is safe to log and
+// echo: 1 to 64 letters, digits, dots, dashes and underscores
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID of r, or "" outside requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// newProblem returns the problem details of an error response. The request
+// ID is read from the response header set by requestIDMiddleware, so
+// handlers need not pass the request along.
+func newProblem(w http.ResponseWriter, code int, message string) api.Problem {
+	return api.Problem{
+		Type:      api.ProblemTypeBlank,
+		Title:     http.StatusText(code),
+		Status:    code,
+		Detail:    message,
+		RequestID: w.Header().Get(requestIDHeader),
+		ErrorResponse: api.ErrorResponse{
+			Error:   http.StatusText(code),
+			Message: message,
+			Code:    code,
+		},
+	}
+}
+
+// sendErrorPage sends an HTML error page, for routes browsers navigate to.
+// The message is shown to the user, so it must not contain internal details.
+func (s *ComplianceServer) sendErrorPage(w http.ResponseWriter, r *http.Request, code int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := errorPage.Execute(w, map[string]interface{}{
+		"Status":    code,
+		"Title":     http.StatusText(code),
+		"Message":   message,
+		"Path":      r.URL.Path,
+		"RequestID": w.Header().Get(requestIDHeader),
+	}); err != nil {
+		s.logger.Warn("Failed to render error page", "error", err, "request_id", requestID(r))
+	}
+}
+
+// sendNotFound answers a path no route serves: API paths get problem+json,
+// others the HTML error page
+func (s *ComplianceServer) sendNotFound(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		s.sendError(w, http.StatusNotFound, "No API endpoint at "+r.URL.Path)
+		return
+	}
+	s.sendErrorPage(w, r, http.StatusNotFound, "The page you requested does not exist.")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestRequestIDMiddleware tests that requests get an ID, and that only
+// well-formed IDs sent by clients are kept
+func TestRequestIDMiddleware(t *testing.T) {
+	s := newTestServer()
+	var seen string
+	handler := s.requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	}))
+
+	tests := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{"none sent", "", false},
+		{"valid", "proxy-1f3a.42_b", true},
+		{"with spaces", "id with spaces", false},
+		{"with newline", "id\nforged: header", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+			if tt.sent != "" {
+				req.Header.Set(requestIDHeader, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("header ID = %q, context ID = %q; want the same non-empty ID", got, seen)
+			}
+			if (got == tt.sent) != tt.keep {
+				t.Errorf("ID = %q for %q sent, want kept = %v", got, tt.sent, tt.keep)
+			}
+		})
+	}
+}
+
+// TestErrorResponses tests that API errors are problem+json and page errors
+// HTML, both carrying the request ID
+func TestErrorResponses(t *testing.T) {
+	s := newTestServer()
+	handler := s.requestIDMiddleware(s.routeHandler())
+
+	t.Run("api", func(t *testing.T) {
+		for _, tt := range []struct {
+			method, path string
+			code         int
+		}{
+			{http.MethodGet, "/api/v1/no-such-endpoint", http.StatusNotFound},
+			{http.MethodDelete, "/api/v1/health", http.StatusMethodNotAllowed},
+		} {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(requestIDHeader, "trace-123")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code || rec.Header().Get("Content-Type") != problemContentType {
+				t.Fatalf("%s %s = %d %q, want %d %s", tt.method, tt.path, rec.Code, rec.Header().Get("Content-Type"), tt.code, problemContentType)
+			}
+			var problem api.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("%s %s: decode problem: %v", tt.method, tt.path, err)
+			}
+			if problem.Type != api.ProblemTypeBlank || problem.Status != tt.code || problem.Title != http.StatusText(tt.code) || problem.RequestID != "trace-123" {
+				t.Errorf("%s %s problem = %+v, want status %d with request ID trace-123", tt.method, tt.path, problem, tt.code)
+			}
+			// Clients reading the earlier error format still find it
+			if problem.Code != tt.code || problem.Message == "" || problem.Message != problem.Detail {
+				t.Errorf("%s %s legacy fields = %+v, want code %d and the detail as message", tt.method, tt.path, problem.ErrorResponse, tt.code)
+			}
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/no-such-page", nil))
+
+		if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			t.Fatalf("GET /no-such-page = %d %q, want a 404 HTML page", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		body := rec.Body.String()
+		id := rec.Header().Get(requestIDHeader)
+		if !strings.Contains(body, "404 Not Found") || id == "" || !strings.Contains(body, id) {
+			t.Errorf("error page does not show the status and request ID %q:\n%s", id, body)
+		}
+	})
+}
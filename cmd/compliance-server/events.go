@@ -0,0 +1,669 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Changes are published on the event bus once stored, and consumers (the
+// SIEM forwarder, missed run alerts, webhooks) subscribe to their topics
+// rather than being called by the handlers. With events.outbox (migration
+// 0026) each event is written to event_outbox once per consumer that
+// subscribed, and delivered from there: an event outlives a restart, a
+// failed delivery is retried with backoff up to events.max_attempts, and
+// any replica may deliver it. Without the outbox events are delivered from
+// memory, once. Either way a consumer may see an event twice or out of
+// order, and must tolerate it.
+
+// Event topics
+const (
+	topicSubmissionAccepted = "submission.accepted"
+	topicClientRegistered   = "client.registered"
+	topicPolicyUpdated      = "policy.updated"
+	topicUserLogin          = "user.login"
+)
+
+// eventTopics are the topics published, in the order they are documented
+var eventTopics = []string{topicSubmissionAccepted, topicClientRegistered, topicPolicyUpdated, topicUserLogin}
+
+const (
+	// eventPollInterval is how often the outbox is checked for retries and
+	// for events published by other replicas
+	eventPollInterval = 5 * time.Second
+
+	// eventBatchSize is how many outbox rows are claimed at a time
+	eventBatchSize = 100
+
+	// eventLease is how long a claimed delivery is left to its replica
+	// before another may try it, should the first stop mid-delivery
+	eventLease = 5 * time.Minute
+
+	// eventPruneInterval is how often delivered and abandoned events past
+	// events.retain are deleted
+	eventPruneInterval = time.Hour
+)
+
+// busEvent is an event as consumers receive it. Webhooks are sent it as JSON.
+type busEvent struct {
+	ID      string          `json:"id"` // Shared by every consumer's delivery, to recognize repeats
+	Topic   string          `json:"topic"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// decode unmarshals the payload into v
+func (e busEvent) decode(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", e.Topic, err)
+	}
+	return nil
+}
+
+// eventConsumer handles the events of its topics. An error fails the
+// delivery, which the outbox retries.
+type eventConsumer struct {
+	name   string
+	topics map[string]bool // nil subscribes to every topic
+	handle func(event busEvent) error
+}
+
+// subscribes reports whether the consumer receives events of topic
+func (c *eventConsumer) subscribes(topic string) bool {
+	return c.topics == nil || c.topics[topic]
+}
+
+// topicSet returns the topics as a set, or nil (every topic) when empty
+func topicSet(topics ...string) map[string]bool {
+	if len(topics) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		set[topic] = true
+	}
+	return set
+}
+
+// eventDelivery is an event for one consumer
+type eventDelivery struct {
+	OutboxID int64 // 0 when delivered from memory
+	Consumer string
+	Attempts int // Including this one
+	Event    busEvent
+}
+
+// eventBus delivers published events to the consumers subscribed to them
+type eventBus struct {
+	settings  EventSettings
+	logger    *slog.Logger
+	db        *Database // The outbox; nil delivers from memory
+	consumers map[string]*eventConsumer
+
+	queue chan eventDelivery // Deliveries from memory
+	wake  chan struct{}      // Signals an event written to the outbox
+	stop  chan struct{}      // Closed by close
+	done  chan struct{}      // Closed when run returns
+}
+
+// newEventBus returns an event bus keeping events in db's outbox, or in
+// memory when db is nil. Subscribe the consumers, then start it.
+func newEventBus(settings EventSettings, logger *slog.Logger, db *Database) *eventBus {
+	return &eventBus{
+		settings:  settings,
+		logger:    logger,
+		db:        db,
+		consumers: map[string]*eventConsumer{},
+		queue:     make(chan eventDelivery, 10000),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// subscribe adds a consumer. Its name identifies its deliveries in the
+// outbox, so it must stay the same across restarts.
+func (b *eventBus) subscribe(consumer *eventConsumer) {
+	b.consumers[consumer.name] = consumer
+}
+
+// start delivers events until close is called
+func (b *eventBus) start() {
+	go b.run()
+}
+
+// publish sends an event to the consumers of topic. It never fails the
+// caller: the change has been stored, so an event that cannot be written to
+// the outbox is delivered from memory instead. On a nil bus it does nothing.
+func (b *eventBus) publish(topic string, payload interface{}) {
+	if b == nil {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		b.logger.Error("Failed to encode event", "topic", topic, "error", err)
+		return
+	}
+	event := busEvent{ID: uuid.NewString(), Topic: topic, Time: time.Now().UTC(), Payload: data}
+
+	var consumers []string
+	for name, consumer := range b.consumers {
+		if consumer.subscribes(topic) {
+			consumers = append(consumers, name)
+		}
+	}
+	if len(consumers) == 0 {
+		return
+	}
+
+	if b.db != nil {
+		err := b.db.EnqueueEvent(event, consumers)
+		if err == nil {
+			select {
+			case b.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+		b.logger.Error("Failed to write event to the outbox; delivering it from memory", "topic", topic, "event_id", event.ID, "error", err)
+	}
+
+	for _, name := range consumers {
+		select {
+		case b.queue <- eventDelivery{Consumer: name, Attempts: 1, Event: event}:
+		default:
+			serverMetrics.eventDeliveries.Inc(name, "dropped")
+		}
+	}
+}
+
+// run delivers events from memory as they are published and from the
+// outbox as they fall due, until close is called
+func (b *eventBus) run() {
+	defer close(b.done)
+
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(eventPruneInterval)
+	defer prune.Stop()
+
+	b.dispatchOutbox()
+	for {
+		select {
+		case delivery := <-b.queue:
+			b.deliver(delivery)
+		case <-b.wake:
+			b.dispatchOutbox()
+		case <-poll.C:
+			b.dispatchOutbox()
+		case <-prune.C:
+			b.pruneOutbox()
+		case <-b.stop:
+			for {
+				select {
+				case delivery := <-b.queue:
+					b.deliver(delivery)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// dispatchOutbox delivers the outbox events that are due. Consumers are
+// delivered to side by side, each in publishing order; after a failure the
+// rest of a consumer's batch waits with the failed event, so an unreachable
+// webhook does not hold up the others.
+func (b *eventBus) dispatchOutbox() {
+	if b.db == nil {
+		return
+	}
+	for {
+		deliveries, err := b.db.ClaimEvents(eventBatchSize, eventLease)
+		if err != nil {
+			b.logger.Error("Failed to read the event outbox", "error", err)
+			return
+		}
+
+		byConsumer := map[string][]eventDelivery{}
+		for _, delivery := range deliveries {
+			byConsumer[delivery.Consumer] = append(byConsumer[delivery.Consumer], delivery)
+		}
+		var wg sync.WaitGroup
+		for _, batch := range byConsumer {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i, delivery := range batch {
+					retry, ok := b.deliver(delivery)
+					if ok || retry == 0 {
+						continue
+					}
+					ids := make([]int64, 0, len(batch)-i-1)
+					for _, waiting := range batch[i+1:] {
+						ids = append(ids, waiting.OutboxID)
+					}
+					if err := b.db.ReleaseEvents(ids, retry); err != nil {
+						b.logger.Warn("Failed to postpone events", "consumer", delivery.Consumer, "error", err)
+					}
+					return
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < eventBatchSize {
+			return
+		}
+	}
+}
+
+// deliver hands an event to its consumer and records the outcome. It
+// reports whether the delivery succeeded, and if not when it is retried:
+// zero when it is not.
+func (b *eventBus) deliver(delivery eventDelivery) (retry time.Duration, ok bool) {
+	err := b.handle(delivery)
+	if err == nil {
+		serverMetrics.eventDeliveries.Inc(delivery.Consumer, "delivered")
+		if delivery.OutboxID != 0 {
+			if err := b.db.MarkEventDelivered(delivery.OutboxID); err != nil {
+				b.logger.Warn("Failed to mark event delivered; it will be delivered again", "event_id", delivery.Event.ID, "consumer", delivery.Consumer, "error", err)
+			}
+		}
+		return 0, true
+	}
+
+	if delivery.OutboxID != 0 && delivery.Attempts < b.settings.MaxAttempts {
+		retry = eventRetryDelay(delivery.Attempts)
+	}
+	if retry > 0 {
+		serverMetrics.eventDeliveries.Inc(delivery.Consumer, "retried")
+		b.logger.Warn("Event delivery failed; retrying",
+			"topic", delivery.Event.Topic,
+			"event_id", delivery.Event.ID,
+			"consumer", delivery.Consumer,
+			"attempts", delivery.Attempts,
+			"retry_in", retry,
+			"error", err,
+		)
+	} else {
+		serverMetrics.eventDeliveries.Inc(delivery.Consumer, "failed")
+		b.logger.Error("Event delivery failed; giving up",
+			"topic", delivery.Event.Topic,
+			"event_id", delivery.Event.ID,
+			"consumer", delivery.Consumer,
+			"attempts", delivery.Attempts,
+			"error", err,
+		)
+	}
+	if delivery.OutboxID != 0 {
+		if err := b.db.MarkEventFailed(delivery.OutboxID, err.Error(), retry); err != nil {
+			b.logger.Warn("Failed to record event delivery failure", "event_id", delivery.Event.ID, "consumer", delivery.Consumer, "error", err)
+		}
+	}
+	return retry, false
+}
+
+// handle runs the consumer of a delivery, turning a panic into an error so
+// one bad event cannot stop the bus
+func (b *eventBus) handle(delivery eventDelivery) (err error) {
+	consumer, ok := b.consumers[delivery.Consumer]
+	if !ok {
+		return fmt.Errorf("no consumer %q is subscribed", delivery.Consumer)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("consumer panicked: %v", p)
+		}
+	}()
+	return consumer.handle(delivery.Event)
+}
+
+// eventRetryDelay is how long a delivery that failed attempts times waits
+// before the next: 30 seconds, doubling up to an hour
+func eventRetryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, time.Hour)
+}
+
+// pruneOutbox deletes the delivered and abandoned events past events.retain
+func (b *eventBus) pruneOutbox() {
+	if b.db == nil {
+		return
+	}
+	deleted, err := b.db.PruneEvents(b.settings.Retain)
+	if err != nil {
+		b.logger.Warn("Failed to prune the event outbox", "error", err)
+		return
+	}
+	if deleted > 0 {
+		b.logger.Debug("Pruned the event outbox", "deleted", deleted)
+	}
+}
+
+// close waits until the events queued in memory are delivered or ctx is
+// done. Events in the outbox are delivered after the restart.
+func (b *eventBus) close(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.logger.Warn("Event queue not emptied before shutdown", "events", len(b.queue))
+	}
+}
+
+// submissionAccepted is the payload of submission.accepted. It carries the
+// counts of the submission, not its check results.
+type submissionAccepted struct {
+	SubmissionID      string             `json:"submission_id"`
+	ClientID          string             `json:"client_id"`
+	Hostname          string             `json:"hostname"`
+	ReportType        string             `json:"report_type"`
+	ReportVersion     string             `json:"report_version,omitempty"`
+	Timestamp         time.Time          `json:"timestamp"`
+	Kind              string             `json:"kind"` // full, or delta when rebuilt from one
+	DuringMaintenance bool               `json:"during_maintenance,omitempty"`
+	Compliance        api.ComplianceData `json:"compliance"`
+}
+
+// newSubmissionAccepted returns the submission.accepted payload of a stored
+// submission
+func newSubmissionAccepted(submission *api.ComplianceSubmission, kind string) submissionAccepted {
+	compliance := submission.Compliance
+	compliance.Queries = nil
+	return submissionAccepted{
+		SubmissionID:      submission.SubmissionID,
+		ClientID:          submission.ClientID,
+		Hostname:          submission.Hostname,
+		ReportType:        submission.ReportType,
+		ReportVersion:     submission.ReportVersion,
+		Timestamp:         submission.Timestamp,
+		Kind:              kind,
+		DuringMaintenance: submission.DuringMaintenance,
+		Compliance:        compliance,
+	}
+}
+
+// submission returns the summary of the submission the payload describes
+func (p submissionAccepted) submission() *api.ComplianceSubmission {
+	return &api.ComplianceSubmission{
+		SubmissionID:      p.SubmissionID,
+		ClientID:          p.ClientID,
+		Hostname:          p.Hostname,
+		ReportType:        p.ReportType,
+		ReportVersion:     p.ReportVersion,
+		Timestamp:         p.Timestamp,
+		DuringMaintenance: p.DuringMaintenance,
+		Compliance:        p.Compliance,
+	}
+}
+
+// clientRegistered is the payload of client.registered, published when a
+// client ID is first seen, by registration or a first submission
+type clientRegistered struct {
+	ClientID  string `json:"client_id"`
+	Hostname  string `json:"hostname"`
+	OSVersion string `json:"os_version,omitempty"`
+	Source    string `json:"source"` // registration or submission
+}
+
+// policyUpdated is the payload of policy.updated, published when a policy
+// is created, changed, handed to other owners or deleted through the API
+type policyUpdated struct {
+	PolicyID string `json:"policy_id"`
+	Action   string `json:"action"` // created, updated, owner or deleted
+	Actor    string `json:"actor"`
+}
+
+// userLogin is the payload of user.login, published for every successful
+// login
+type userLogin struct {
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+// publishPolicyUpdated publishes policy.updated for a change made by r
+func (s *ComplianceServer) publishPolicyUpdated(r *http.Request, policyID, action string) {
+	s.events.publish(topicPolicyUpdated, policyUpdated{PolicyID: policyID, Action: action, Actor: s.auditActor(r)})
+}
+
+// subscribeEventConsumers subscribes the server's consumers to the bus
+func (s *ComplianceServer) subscribeEventConsumers() {
+	if s.forwarder != nil {
+		s.events.subscribe(&eventConsumer{
+			name:   "siem",
+			topics: topicSet(topicSubmissionAccepted, topicClientRegistered, topicUserLogin),
+			handle: s.forwardBusEvent,
+		})
+	}
+
+	s.events.subscribe(&eventConsumer{
+		name:   "missed_run_alerts",
+		topics: topicSet(topicSubmissionAccepted),
+		handle: func(event busEvent) error {
+			var accepted submissionAccepted
+			if err := event.decode(&accepted); err != nil {
+				return err
+			}
+			// A delivered report clears any missed run alerts raised for it
+			_, err := s.db.ResolveAlerts(accepted.ClientID, alertTypeMissedRun, accepted.ReportType)
+			return err
+		},
+	})
+
+	for _, webhook := range s.config().Events.Webhooks {
+		s.events.subscribe(newWebhookConsumer(webhook))
+	}
+}
+
+// forwardBusEvent queues an event for the SIEM. Logins and new clients are
+// forwarded as audit events; policy changes already are, by the admin audit.
+func (s *ComplianceServer) forwardBusEvent(event busEvent) error {
+	switch event.Topic {
+	case topicSubmissionAccepted:
+		var accepted submissionAccepted
+		if err := event.decode(&accepted); err != nil {
+			return err
+		}
+		s.forwarder.forward(submissionEvent(accepted.submission()))
+	case topicClientRegistered:
+		var registered clientRegistered
+		if err := event.decode(&registered); err != nil {
+			return err
+		}
+		s.forwarder.forward(forwardEvent{
+			Time:     event.Time,
+			Kind:     forwardAudit,
+			Name:     "client.register",
+			Message:  fmt.Sprintf("client %s registered by %s", registered.Hostname, registered.Source),
+			Severity: 3,
+			Fields: []forwardField{
+				{"action", "client.register"},
+				{"client_id", registered.ClientID},
+				{"hostname", registered.Hostname},
+			},
+		})
+	case topicUserLogin:
+		var login userLogin
+		if err := event.decode(&login); err != nil {
+			return err
+		}
+		fields := []forwardField{{"actor", login.Username}, {"action", "user.login"}, {"role", login.Role}}
+		if login.RemoteAddr != "" {
+			fields = append(fields, forwardField{"remote_addr", login.RemoteAddr})
+		}
+		s.forwarder.forward(forwardEvent{
+			Time:     event.Time,
+			Kind:     forwardAudit,
+			Name:     "user.login",
+			Message:  "user.login by " + login.Username,
+			Severity: 3,
+			Fields:   fields,
+		})
+	}
+	return nil
+}
+
+// EnqueueEvent writes an event to the outbox once for each consumer
+func (d *Database) EnqueueEvent(event busEvent, consumers []string) error {
+	_, err := d.db.Exec(safesql.New(`
+		INSERT INTO event_outbox (event_id, topic, consumer, payload, occurred_at)
+		SELECT $1, $2, consumer, $3, $4
+		FROM unnest($5::text[]) AS consumer
+	`, event.ID, event.Topic, string(event.Payload), event.Time, pq.Array(consumers)))
+	if err != nil {
+		return fmt.Errorf("failed to write event to the outbox: %w", err)
+	}
+	return nil
+}
+
+// ClaimEvents returns up to limit outbox deliveries that are due, counting
+// the attempt and leaving them to the caller for lease
+func (d *Database) ClaimEvents(limit int, lease time.Duration) ([]eventDelivery, error) {
+	rows, err := d.db.Query(safesql.New(`
+		UPDATE event_outbox
+		SET attempts = attempts + 1,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, topic, consumer, payload, occurred_at, attempts
+	`, limit, lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim events: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []eventDelivery
+	for rows.Next() {
+		var delivery eventDelivery
+		var payload string
+		if err := rows.Scan(&delivery.OutboxID, &delivery.Event.ID, &delivery.Event.Topic, &delivery.Consumer,
+			&payload, &delivery.Event.Time, &delivery.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		delivery.Event.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	// UPDATE ... RETURNING does not keep the subquery's order
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].OutboxID < deliveries[j].OutboxID })
+	return deliveries, nil
+}
+
+// MarkEventDelivered records a successful delivery
+func (d *Database) MarkEventDelivered(id int64) error {
+	_, err := d.db.Exec(safesql.New(`
+		UPDATE event_outbox SET delivered_at = CURRENT_TIMESTAMP, last_error = '' WHERE id = $1
+	`, id))
+	if err != nil {
+		return fmt.Errorf("failed to mark event delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed delivery, to be tried again after retry,
+// or abandoned when retry is zero
+func (d *Database) MarkEventFailed(id int64, message string, retry time.Duration) error {
+	_, err := d.db.Exec(safesql.New(`
+		UPDATE event_outbox
+		SET last_error = $2,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3),
+			failed_at = CASE WHEN $3 = 0 THEN CURRENT_TIMESTAMP END
+		WHERE id = $1
+	`, id, message, retry.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to record event failure: %w", err)
+	}
+	return nil
+}
+
+// ReleaseEvents returns claimed deliveries that were not attempted to the
+// outbox, due after retry
+func (d *Database) ReleaseEvents(ids []int64, retry time.Duration) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := d.db.Exec(safesql.New(`
+		UPDATE event_outbox
+		SET attempts = attempts - 1,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id = ANY($1)
+	`, pq.Array(ids), retry.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to release events: %w", err)
+	}
+	return nil
+}
+
+// PruneEvents deletes the delivered and abandoned events that occurred
+// more than retain ago, returning how many were deleted
+func (d *Database) PruneEvents(retain time.Duration) (int64, error) {
+	result, err := d.db.Exec(safesql.New(`
+		DELETE FROM event_outbox
+		WHERE (delivered_at IS NOT NULL OR failed_at IS NOT NULL)
+		  AND occurred_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+	`, retain.Seconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// validateEventSettings checks the events section of the configuration
+func validateEventSettings(settings EventSettings) error {
+	if settings.MaxAttempts < 1 {
+		return fmt.Errorf("events.max_attempts must be at least 1")
+	}
+	if settings.Retain <= 0 {
+		return fmt.Errorf("events.retain must be positive")
+	}
+	names := map[string]bool{}
+	for i, webhook := range settings.Webhooks {
+		if !validTag.MatchString(webhook.Name) {
+			return fmt.Errorf("events.webhooks[%d].name %q must be lowercase letters, digits, '.', '_' and '-'", i, webhook.Name)
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("events.webhooks: duplicate name %q", webhook.Name)
+		}
+		names[webhook.Name] = true
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("events.webhooks[%d].url: %q is not an http(s) URL", i, webhook.URL)
+		}
+		for _, topic := range webhook.Topics {
+			if !slices.Contains(eventTopics, topic) {
+				return fmt.Errorf("events.webhooks[%d].topics: unknown topic %q (%s)", i, topic, strings.Join(eventTopics, ", "))
+			}
+		}
+	}
+	return nil
+}
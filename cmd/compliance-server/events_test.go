@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestEventBusMemory tests that events published without an outbox reach
+// the consumers of their topic, and that a failing or panicking consumer
+// does not stop the others
+func TestEventBusMemory(t *testing.T) {
+	bus := newEventBus(EventSettings{MaxAttempts: 3, Retain: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	var mu sync.Mutex
+	received := map[string][]busEvent{}
+	record := func(name string) func(busEvent) error {
+		return func(event busEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received[name] = append(received[name], event)
+			return nil
+		}
+	}
+	bus.subscribe(&eventConsumer{name: "failing", handle: func(busEvent) error { return errors.New("unreachable") }})
+	bus.subscribe(&eventConsumer{name: "panicking", handle: func(busEvent) error { panic("bad consumer") }})
+	bus.subscribe(&eventConsumer{name: "submissions", topics: topicSet(topicSubmissionAccepted), handle: record("submissions")})
+	bus.subscribe(&eventConsumer{name: "all", handle: record("all")})
+	bus.start()
+
+	bus.publish(topicSubmissionAccepted, submissionAccepted{SubmissionID: "sub-1", ClientID: "client-1", Kind: "full"})
+	bus.publish(topicUserLogin, userLogin{Username: "alice", Role: "admin"})
+	bus.close(context.Background())
+
+	if len(received["all"]) != 2 || len(received["submissions"]) != 1 {
+		t.Fatalf("received %d events for all topics and %d submissions, want 2 and 1", len(received["all"]), len(received["submissions"]))
+	}
+	event := received["submissions"][0]
+	if event.ID == "" || event.ID != received["all"][0].ID || event.Topic != topicSubmissionAccepted {
+		t.Errorf("submission event = %+v, want the ID every consumer got", event)
+	}
+	var accepted submissionAccepted
+	if err := event.decode(&accepted); err != nil || accepted.SubmissionID != "sub-1" || accepted.Kind != "full" {
+		t.Errorf("decode() = %+v, %v; want submission sub-1", accepted, err)
+	}
+
+	// Publishing on a server without a bus does nothing
+	var none *eventBus
+	none.publish(topicUserLogin, userLogin{Username: "alice"})
+}
+
+// TestEventRetryDelay tests the backoff between delivery attempts
+func TestEventRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := eventRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("eventRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// TestSubmissionAccepted tests that the submission.accepted payload carries
+// what the SIEM forwarder needs without the check results
+func TestSubmissionAccepted(t *testing.T) {
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		ClientID:     "client-1",
+		Hostname:     "ws-01",
+		ReportType:   "NIST",
+		Compliance: api.ComplianceData{
+			OverallStatus: "non-compliant",
+			TotalChecks:   2,
+			PassedChecks:  1,
+			FailedChecks:  1,
+			Queries:       []api.QueryResult{{Name: "q1", Status: "pass"}, {Name: "q2", Status: "fail"}},
+		},
+	}
+	accepted := newSubmissionAccepted(submission, "delta")
+	if accepted.Compliance.Queries != nil || accepted.Kind != "delta" {
+		t.Errorf("payload = %+v, want no check results and kind delta", accepted)
+	}
+
+	want := submissionEvent(submission)
+	got := submissionEvent(accepted.submission())
+	if got.Message != want.Message || got.Severity != want.Severity || len(got.Fields) != len(want.Fields) {
+		t.Errorf("forwarded event = %+v, want %+v", got, want)
+	}
+}
+
+// TestWebhookConsumer tests that webhooks are posted signed events, and that
+// an answer other than 2xx fails the delivery
+func TestWebhookConsumer(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	status := http.StatusNoContent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	consumer := newWebhookConsumer(WebhookSettings{Name: "soc", URL: ts.URL, Topics: []string{topicClientRegistered}, Secret: "s3cret"})
+	if consumer.name != "webhook:soc" || !consumer.subscribes(topicClientRegistered) || consumer.subscribes(topicUserLogin) {
+		t.Fatalf("consumer %q subscribes to the wrong topics", consumer.name)
+	}
+
+	event := busEvent{ID: "event-1", Topic: topicClientRegistered, Time: time.Now(), Payload: []byte(`{"client_id":"client-1"}`)}
+	if err := consumer.handle(event); err != nil {
+		t.Fatalf("handle() error = %v", err)
+	}
+	if received.Method != http.MethodPost || received.Header.Get("X-Compliance-Event") != topicClientRegistered || received.Header.Get("X-Compliance-Event-ID") != "event-1" {
+		t.Errorf("webhook request = %s with headers %v", received.Method, received.Header)
+	}
+	if got := received.Header.Get(webhookSignatureHeader); got != signWebhook("s3cret", body) {
+		t.Errorf("signature = %q, want the HMAC of the body", got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := consumer.handle(event); err == nil {
+		t.Error("handle() succeeded on a 503")
+	}
+}
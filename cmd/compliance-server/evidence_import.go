@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// maxEvidenceImportBytes limits the body of an evidence import request
+const maxEvidenceImportBytes = 64 << 20
+
+// handleImportEvidence stores evidence logs written by the standalone
+// toolkit as submissions (POST /api/v1/import/evidence). The body is one
+// evidence log or a JSON array of them.
+func (s *ComplianceServer) handleImportEvidence(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEvidenceImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.sendError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Import is larger than %d MB; split it into several requests", maxEvidenceImportBytes>>20))
+			return
+		}
+		s.sendError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	logs, err := splitEvidenceLogs(body)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := make([]api.EvidenceImportResult, 0, len(logs))
+	for i, data := range logs {
+		results = append(results, s.importEvidenceLog(fmt.Sprintf("#%d", i+1), data))
+	}
+
+	s.respond(w, r, newEvidenceImportResponse(results), nil)
+}
+
+// splitEvidenceLogs returns the evidence logs in an import body: either a
+// single log object or an array of them
+func splitEvidenceLogs(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("request body must be an evidence log or an array of evidence logs")
+	}
+	if body[0] != '[' {
+		return []json.RawMessage{body}, nil
+	}
+
+	var logs []json.RawMessage
+	if err := json.Unmarshal(body, &logs); err != nil {
+		return nil, fmt.Errorf("invalid JSON array of evidence logs")
+	}
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no evidence logs to import")
+	}
+	return logs, nil
+}
+
+// importEvidenceLog stores one toolkit evidence log as a submission. The
+// scanned machine is matched to an existing client by hostname; an unknown
+// host gets the client ID the compliance client would register for it. The
+// report type is replaced by the name and version of the policy imported from
+// the same report file, so imported scans line up with agent submissions.
+// Importing a log twice is a no-op.
+func (s *ComplianceServer) importEvidenceLog(source string, data []byte) api.EvidenceImportResult {
+	result := api.EvidenceImportResult{Source: source, Status: api.EvidenceFailed}
+
+	log, err := api.ParseEvidenceLog(data)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Hostname = log.MachineInfo.Hostname
+
+	clientID, err := s.db.FindClientByHostname(log.MachineInfo.Hostname)
+	if err != nil {
+		s.logger.Error("Failed to match evidence log to a client", "source", source, "error", err)
+		result.Error = "Failed to look up client"
+		return result
+	}
+	if clientID == "" {
+		clientID = fmt.Sprintf("client-%s", log.MachineInfo.Hostname)
+	}
+
+	submission := log.Submission(clientID)
+	if policy, err := s.db.GetPolicy(log.ScanMetadata.ReportType); err == nil && policy != nil && policy.Name != "" {
+		submission.ReportType = policy.Name
+		submission.ReportVersion = policy.Version
+	}
+	result.SubmissionID = submission.SubmissionID
+	result.ClientID = clientID
+	result.ReportType = submission.ReportType
+
+	exists, err := s.db.SubmissionExists(submission.SubmissionID)
+	if err != nil {
+		s.logger.Error("Failed to check for imported submission", "source", source, "error", err)
+		result.Error = "Failed to check for an earlier import"
+		return result
+	}
+	if exists {
+		result.Status = api.EvidenceSkipped
+		return result
+	}
+
+	if err := s.db.EnsureClient(clientID, submission.Hostname, &submission.SystemInfo, submission.Timestamp); err != nil {
+		s.logger.Error("Failed to create client for evidence log", "source", source, "error", err)
+		result.Error = "Failed to register client"
+		return result
+	}
+	if err := s.db.SaveSubmission(submission); err != nil {
+		s.logger.Error("Failed to save imported submission", "source", source, "error", err)
+		result.Error = "Failed to save submission"
+		return result
+	}
+
+	s.logger.Info("Imported evidence log",
+		"source", source,
+		"submission_id", submission.SubmissionID,
+		"client_id", clientID,
+		"report_type", submission.ReportType,
+		"timestamp", submission.Timestamp,
+	)
+	result.Status = api.EvidenceImported
+	return result
+}
+
+// newEvidenceImportResponse summarizes the results of an import
+func newEvidenceImportResponse(results []api.EvidenceImportResult) api.EvidenceImportResponse {
+	resp := api.EvidenceImportResponse{Status: "success", Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case api.EvidenceImported:
+			resp.Imported++
+		case api.EvidenceSkipped:
+			resp.Skipped++
+		default:
+			resp.Failed++
+		}
+	}
+	if resp.Failed > 0 {
+		resp.Status = "partial"
+	}
+	resp.Message = fmt.Sprintf("Imported %d evidence logs, skipped %d already imported, %d failed",
+		resp.Imported, resp.Skipped, resp.Failed)
+	return resp
+}
+
+// evidenceLogFiles expands paths into evidence log files. Directories
+// contribute the toolkit's "<report>_evidence_<timestamp>.json" files; files
+// named explicitly are used as given.
+func evidenceLogFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*_evidence_*.json"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// runEvidenceImport imports evidence log files from the command line and
+// prints the outcome of each. It returns false if any file failed.
+func runEvidenceImport(config *ServerConfig, paths []string) bool {
+	files, err := evidenceLogFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if len(files) == 0 {
+		fmt.Println("No evidence logs found")
+		return true
+	}
+
+	db, err := NewDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open database: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	s := &ComplianceServer{logger: slog.Default(), db: db}
+	s.cfg.Store(config)
+
+	results := make([]api.EvidenceImportResult, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			results = append(results, api.EvidenceImportResult{Source: file, Status: api.EvidenceFailed, Error: err.Error()})
+		} else {
+			results = append(results, s.importEvidenceLog(file, data))
+		}
+
+		result := results[len(results)-1]
+		switch result.Status {
+		case api.EvidenceImported:
+			fmt.Printf("  imported  %s -> %s (%s)\n", file, result.ClientID, result.ReportType)
+		case api.EvidenceSkipped:
+			fmt.Printf("  skipped   %s (already imported)\n", file)
+		default:
+			fmt.Printf("  failed    %s: %s\n", file, result.Error)
+		}
+	}
+
+	resp := newEvidenceImportResponse(results)
+	fmt.Println(resp.Message)
+	return resp.Failed == 0
+}
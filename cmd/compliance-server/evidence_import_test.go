@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSplitEvidenceLogs tests splitting an import body into evidence logs
+func TestSplitEvidenceLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{"single log", `{"scan_metadata":{}}`, 1, false},
+		{"single log with whitespace", "\n  {\"scan_metadata\":{}}\n", 1, false},
+		{"array", `[{"scan_metadata":{}},{"scan_metadata":{}}]`, 2, false},
+		{"empty body", "", 0, true},
+		{"whitespace only", "  \n", 0, true},
+		{"empty array", `[]`, 0, true},
+		{"broken array", `[{"scan_metadata":{}}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := splitEvidenceLogs([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitEvidenceLogs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(logs) != tt.want {
+				t.Errorf("splitEvidenceLogs() returned %d logs, want %d", len(logs), tt.want)
+			}
+		})
+	}
+}
+
+// TestNewEvidenceImportResponse tests the import summary counts and status
+func TestNewEvidenceImportResponse(t *testing.T) {
+	result := func(status string) api.EvidenceImportResult {
+		return api.EvidenceImportResult{Status: status}
+	}
+
+	tests := []struct {
+		name       string
+		results    []api.EvidenceImportResult
+		wantStatus string
+		wantCounts [3]int // imported, skipped, failed
+	}{
+		{"all imported", []api.EvidenceImportResult{result(api.EvidenceImported), result(api.EvidenceImported)}, "success", [3]int{2, 0, 0}},
+		{"re-import", []api.EvidenceImportResult{result(api.EvidenceSkipped), result(api.EvidenceImported)}, "success", [3]int{1, 1, 0}},
+		{"some failed", []api.EvidenceImportResult{result(api.EvidenceImported), result(api.EvidenceFailed)}, "partial", [3]int{1, 0, 1}},
+		{"nothing", nil, "success", [3]int{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newEvidenceImportResponse(tt.results)
+			if resp.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			got := [3]int{resp.Imported, resp.Skipped, resp.Failed}
+			if got != tt.wantCounts {
+				t.Errorf("counts = %v, want %v", got, tt.wantCounts)
+			}
+		})
+	}
+}
+
+// TestEvidenceLogFiles tests expanding files and directories into evidence logs
+func TestEvidenceLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"NIST_800_171_compliance_evidence_20250101_120000.json",
+		"fips_140_2_compliance_evidence_20250102_090000.json",
+		"NIST_800_171_compliance_20250101_120000.html",
+		"notes.json",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	explicit := filepath.Join(dir, "notes.json")
+
+	files, err := evidenceLogFiles([]string{dir, explicit})
+	if err != nil {
+		t.Fatalf("evidenceLogFiles() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("evidenceLogFiles() = %v, want 2 evidence logs and the explicit file", files)
+	}
+	if files[2] != explicit {
+		t.Errorf("explicit file = %q, want %q", files[2], explicit)
+	}
+
+	if _, err := evidenceLogFiles([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("evidenceLogFiles() with a missing path should fail")
+	}
+}
+
+// TestHandleImportEvidenceRejects tests bodies rejected before any log is stored
+func TestHandleImportEvidenceRejects(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"empty body", "", http.StatusBadRequest},
+		{"empty array", "[]", http.StatusBadRequest},
+		{"too large", strings.Repeat(" ", maxEvidenceImportBytes+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/import/evidence", strings.NewReader(tt.body))
+			s.handleImportEvidence(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// checkKey identifies a check within a policy
+type checkKey struct {
+	reportType string
+	name       string
+}
+
+// flakyCheckStats accumulates the transitions seen for one check
+type flakyCheckStats struct {
+	flips        int
+	observations int
+	lastFlip     time.Time
+	clients      map[string]bool
+}
+
+// findFlakyChecks lists the checks whose result flips between pass and fail
+// at least minFlips times across consecutive submissions from the same
+// client. history must be ordered by client, report type and time, as
+// returned by SubmissionHistory.
+//
+// Only transitions the policy does not explain are counted: a pair of
+// submissions is skipped when the report version changed between them, and
+// a check is skipped when its expected value or operator changed. Warning
+// and error results are neither flips nor observations.
+func findFlakyChecks(history []*api.ComplianceSubmission, minFlips int) []api.FlakyPolicy {
+	stats := make(map[checkKey]*flakyCheckStats)
+
+	for i := 1; i < len(history); i++ {
+		prev, cur := history[i-1], history[i]
+		if prev.ClientID != cur.ClientID || prev.ReportType != cur.ReportType {
+			continue
+		}
+		if prev.ReportVersion != cur.ReportVersion {
+			continue
+		}
+
+		previous := make(map[string]api.QueryResult, len(prev.Compliance.Queries))
+		for _, result := range prev.Compliance.Queries {
+			previous[result.Name] = result
+		}
+
+		for _, result := range cur.Compliance.Queries {
+			before, ok := previous[result.Name]
+			if !ok || before.Expected != result.Expected || before.Operator != result.Operator {
+				continue
+			}
+			if !isPassOrFail(before.Status) || !isPassOrFail(result.Status) {
+				continue
+			}
+
+			key := checkKey{reportType: cur.ReportType, name: result.Name}
+			st, ok := stats[key]
+			if !ok {
+				st = &flakyCheckStats{clients: make(map[string]bool)}
+				stats[key] = st
+			}
+
+			st.observations++
+			if before.Status != result.Status {
+				st.flips++
+				st.clients[cur.ClientID] = true
+				if cur.Timestamp.After(st.lastFlip) {
+					st.lastFlip = cur.Timestamp
+				}
+			}
+		}
+	}
+
+	byPolicy := make(map[string][]api.FlakyCheck)
+	for key, st := range stats {
+		if st.flips == 0 || st.flips < minFlips {
+			continue
+		}
+
+		clientIDs := make([]string, 0, len(st.clients))
+		for clientID := range st.clients {
+			clientIDs = append(clientIDs, clientID)
+		}
+		sort.Strings(clientIDs)
+
+		byPolicy[key.reportType] = append(byPolicy[key.reportType], api.FlakyCheck{
+			Name:            key.name,
+			Flips:           st.flips,
+			Observations:    st.observations,
+			FlipRate:        float64(st.flips) / float64(st.observations),
+			ClientsAffected: len(clientIDs),
+			LastFlip:        st.lastFlip,
+			ClientIDs:       clientIDs,
+		})
+	}
+
+	policies := make([]api.FlakyPolicy, 0, len(byPolicy))
+	for reportType, checks := range byPolicy {
+		sort.Slice(checks, func(i, j int) bool {
+			if checks[i].Flips != checks[j].Flips {
+				return checks[i].Flips > checks[j].Flips
+			}
+			return checks[i].Name < checks[j].Name
+		})
+		policies = append(policies, api.FlakyPolicy{ReportType: reportType, Checks: checks})
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].ReportType < policies[j].ReportType
+	})
+
+	return policies
+}
+
+// isPassOrFail reports whether a check status is a definite pass or fail
+func isPassOrFail(status string) bool {
+	return status == checkStatusPass || status == checkStatusFail
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// flakySubmission builds a submission whose checks have the given statuses
+func flakySubmission(clientID, version string, at time.Time, statuses map[string]string) *api.ComplianceSubmission {
+	submission := &api.ComplianceSubmission{
+		ClientID:      clientID,
+		ReportType:    "NIST",
+		ReportVersion: version,
+		Timestamp:     at,
+	}
+	for name, status := range statuses {
+		submission.Compliance.Queries = append(submission.Compliance.Queries, api.QueryResult{
+			Name:     name,
+			Status:   status,
+			Expected: "1",
+		})
+	}
+	return submission
+}
+
+// TestFindFlakyChecks tests detecting checks that flap under an unchanged policy
+func TestFindFlakyChecks(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return base.AddDate(0, 0, n) }
+
+	history := []*api.ComplianceSubmission{
+		// client-1: Firewall flaps three times, UAC is stable, Audit only
+		// changes alongside a report version bump
+		flakySubmission("client-1", "1.0", day(0), map[string]string{"Firewall": "pass", "UAC": "pass", "Audit": "fail"}),
+		flakySubmission("client-1", "1.0", day(1), map[string]string{"Firewall": "fail", "UAC": "pass", "Audit": "fail"}),
+		flakySubmission("client-1", "1.0", day(2), map[string]string{"Firewall": "pass", "UAC": "pass", "Audit": "fail"}),
+		flakySubmission("client-1", "1.1", day(3), map[string]string{"Firewall": "pass", "UAC": "pass", "Audit": "pass"}),
+		flakySubmission("client-1", "1.1", day(4), map[string]string{"Firewall": "fail", "UAC": "pass", "Audit": "pass"}),
+		// client-2: Firewall flips once; errors are not flips
+		flakySubmission("client-2", "1.0", day(0), map[string]string{"Firewall": "fail", "UAC": "error"}),
+		flakySubmission("client-2", "1.0", day(5), map[string]string{"Firewall": "pass", "UAC": "pass"}),
+		flakySubmission("client-2", "1.0", day(6), map[string]string{"Firewall": "pass", "UAC": "error"}),
+	}
+
+	policies := findFlakyChecks(history, 2)
+	if len(policies) != 1 || policies[0].ReportType != "NIST" {
+		t.Fatalf("policies = %+v, want only NIST", policies)
+	}
+
+	want := []api.FlakyCheck{{
+		Name:            "Firewall",
+		Flips:           4,
+		Observations:    5,
+		FlipRate:        0.8,
+		ClientsAffected: 2,
+		LastFlip:        day(5),
+		ClientIDs:       []string{"client-1", "client-2"},
+	}}
+	if !reflect.DeepEqual(policies[0].Checks, want) {
+		t.Errorf("checks = %+v, want %+v", policies[0].Checks, want)
+	}
+}
+
+// TestFindFlakyChecksExpectedChange tests that a changed expected value is not a flip
+func TestFindFlakyChecksExpectedChange(t *testing.T) {
+	first := flakySubmission("client-1", "1.0", time.Now().Add(-time.Hour), map[string]string{"Firewall": "pass"})
+	second := flakySubmission("client-1", "1.0", time.Now(), map[string]string{"Firewall": "fail"})
+	second.Compliance.Queries[0].Expected = "2"
+
+	if policies := findFlakyChecks([]*api.ComplianceSubmission{first, second}, 1); len(policies) != 0 {
+		t.Errorf("policies = %+v, want none", policies)
+	}
+}
+
+// TestFlakyChecksValidation tests parameter validation of the flaky check report
+func TestFlakyChecksValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"non-numeric days", "days=abc"},
+		{"zero days", "days=0"},
+		{"too many days", "days=366"},
+		{"zero min flips", "min_flips=0"},
+		{"negative min flips", "min_flips=-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/analytics/flaky-checks?"+tt.query, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// forwardSDID is the structured data ID of RFC 5424 messages. 32473 is the
+// enterprise number RFC 5612 reserves for documentation and examples.
+const forwardSDID = "compliance@32473"
+
+// syslogFacility is local0, the facility every forwarded message uses
+const syslogFacility = 16
+
+// forwardField is a named value of a forwarded event
+type forwardField struct {
+	Key   string
+	Value string
+}
+
+// forwardEvent is an audit, submission or alert event sent to the SIEM
+type forwardEvent struct {
+	Time     time.Time
+	Kind     string // forwardAudit, forwardSubmission or forwardAlert
+	Name     string // What happened, e.g. "policy.update" or "non-compliant"
+	Message  string // One line for people reading the event
+	Severity int    // CEF severity, 0 (lowest) to 10
+	Fields   []forwardField
+}
+
+// eventForwarder sends events to a syslog receiver from a queue, so a slow
+// or unreachable SIEM never holds up a request. Events that do not fit in
+// the queue are dropped and counted.
+type eventForwarder struct {
+	settings ForwardingSettings
+	logger   *slog.Logger
+	hostname string
+	kinds    map[string]bool // Kinds forwarded; nil forwards all
+	dial     func() (net.Conn, error)
+
+	queue chan forwardEvent
+	stop  chan struct{} // Closed by close
+	done  chan struct{} // Closed when run returns
+	conn  net.Conn      // Owned by run
+}
+
+// newEventForwarder returns the forwarder for the settings, or nil when
+// forwarding is disabled. It starts sending at once; close stops it.
+func newEventForwarder(settings ForwardingSettings, logger *slog.Logger) (*eventForwarder, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var dial func() (net.Conn, error)
+	switch settings.Protocol {
+	case forwardProtocolTLS:
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if settings.CAFile != "" {
+			pem, err := os.ReadFile(settings.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read forwarding.ca_file: %w", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("forwarding.ca_file %s holds no PEM certificates", settings.CAFile)
+			}
+		}
+		dial = func() (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", settings.Address, config)
+		}
+	default:
+		dial = func() (net.Conn, error) {
+			return dialer.Dial(settings.Protocol, settings.Address)
+		}
+	}
+
+	f := newForwarder(settings, logger, dial)
+	go f.run()
+	return f, nil
+}
+
+// newForwarder returns a forwarder sending through dial, not yet running
+func newForwarder(settings ForwardingSettings, logger *slog.Logger, dial func() (net.Conn, error)) *eventForwarder {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	f := &eventForwarder{
+		settings: settings,
+		logger:   logger,
+		hostname: hostname,
+		dial:     dial,
+		queue:    make(chan forwardEvent, settings.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if len(settings.Events) > 0 {
+		f.kinds = make(map[string]bool, len(settings.Events))
+		for _, kind := range settings.Events {
+			f.kinds[kind] = true
+		}
+	}
+	return f
+}
+
+// forward queues an event of a forwarded kind. It never blocks; on a nil
+// forwarder it does nothing.
+func (f *eventForwarder) forward(event forwardEvent) {
+	if f == nil || (f.kinds != nil && !f.kinds[event.Kind]) {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	select {
+	case f.queue <- event:
+	default:
+		serverMetrics.forwarded.Inc("dropped")
+	}
+}
+
+// run sends queued events until close is called, then sends those still
+// queued and returns
+func (f *eventForwarder) run() {
+	defer close(f.done)
+	defer func() {
+		if f.conn != nil {
+			f.conn.Close()
+		}
+	}()
+
+	for {
+		select {
+		case event := <-f.queue:
+			f.deliver(event)
+		case <-f.stop:
+			for {
+				select {
+				case event := <-f.queue:
+					f.deliver(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends an event. A failed send is retried on a new connection
+// after a growing delay, up to a minute, so events wait in the queue while
+// the receiver is down.
+func (f *eventForwarder) deliver(event forwardEvent) {
+	message := f.format(event)
+	for delay := time.Second; ; delay = min(2*delay, time.Minute) {
+		err := f.send(message)
+		if err == nil {
+			serverMetrics.forwarded.Inc("sent")
+			return
+		}
+		f.logger.Warn("Failed to forward event; retrying", "address", f.settings.Address, "error", err, "retry_in", delay)
+		time.Sleep(delay)
+	}
+}
+
+// send writes one message, connecting first if needed. Stream protocols
+// frame it with its length (RFC 6587 octet counting, as RFC 5425 requires
+// for TLS); over UDP it is one datagram.
+func (f *eventForwarder) send(message string) error {
+	if f.conn == nil {
+		conn, err := f.dial()
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+
+	frame := message
+	if f.settings.Protocol != forwardProtocolUDP {
+		frame = strconv.Itoa(len(message)) + " " + message
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := f.conn.Write([]byte(frame)); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return err
+	}
+	return nil
+}
+
+// close waits until the queued events are sent or ctx is done; events
+// still queued then are lost
+func (f *eventForwarder) close(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	close(f.stop)
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		f.logger.Warn("Forwarding queue not emptied before shutdown", "events", len(f.queue))
+	}
+}
+
+// format renders an event as an RFC 5424 syslog message, carrying the
+// fields as structured data or, in CEF format, as the CEF message
+func (f *eventForwarder) format(event forwardEvent) string {
+	header := fmt.Sprintf("<%d>1 %s %s compliance-server %d %s",
+		syslogFacility*8+syslogSeverity(event.Severity),
+		event.Time.UTC().Format(time.RFC3339Nano),
+		f.hostname,
+		os.Getpid(),
+		event.Kind,
+	)
+	if f.settings.Format == forwardFormatCEF {
+		return header + " - " + formatCEF(event)
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + forwardSDID + ` event="` + escapeSDValue(event.Name) + `"`)
+	for _, field := range event.Fields {
+		sd.WriteString(" " + field.Key + `="` + escapeSDValue(field.Value) + `"`)
+	}
+	sd.WriteString("]")
+	return header + " " + sd.String() + " " + oneLine(event.Message)
+}
+
+// oneLine replaces line breaks, which receivers may take for the end of a
+// message, with spaces
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// syslogSeverity maps a CEF severity to a syslog one
+func syslogSeverity(cef int) int {
+	switch {
+	case cef >= 9:
+		return 2 // Critical
+	case cef >= 7:
+		return 3 // Error
+	case cef >= 4:
+		return 4 // Warning
+	default:
+		return 6 // Informational
+	}
+}
+
+// escapeSDValue escapes a structured data parameter value (RFC 5424
+// section 6.3.3)
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// cefKeys maps field names to the CEF extension keys SIEMs parse. Other
+// fields are sent under their own names, which SIEMs keep as additional
+// extensions.
+var cefKeys = map[string]string{
+	"actor":       "suser",
+	"remote_addr": "src",
+	"hostname":    "dhost",
+	"client_id":   "deviceExternalId",
+	"action":      "act",
+}
+
+// formatCEF renders an event in the ArcSight Common Event Format
+func formatCEF(event forwardEvent) string {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	extension := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|Compliance Toolkit|compliance-server|%s|%s|%s|%d|",
+		header.Replace(version),
+		header.Replace(event.Kind+":"+event.Name),
+		header.Replace(oneLine(event.Message)),
+		event.Severity,
+	)
+	b.WriteString("rt=" + strconv.FormatInt(event.Time.UnixMilli(), 10))
+	b.WriteString(" cat=" + extension.Replace(event.Kind))
+	for _, field := range event.Fields {
+		key := field.Key
+		if mapped, ok := cefKeys[key]; ok {
+			key = mapped
+		}
+		b.WriteString(" " + key + "=" + extension.Replace(field.Value))
+	}
+	return b.String()
+}
+
+// auditEvent is the forwarded form of an admin audit entry
+func auditEvent(entry api.AdminAuditEntry) forwardEvent {
+	event := forwardEvent{
+		Kind:     forwardAudit,
+		Name:     entry.Action,
+		Message:  fmt.Sprintf("%s by %s", entry.Action, entry.Actor),
+		Severity: 3,
+		Fields: []forwardField{
+			{"actor", entry.Actor},
+			{"action", entry.Action},
+			{"target_type", entry.TargetType},
+		},
+	}
+	if entry.Target != "" {
+		event.Message += " on " + entry.Target
+		event.Fields = append(event.Fields, forwardField{"target", entry.Target})
+	}
+	if entry.RemoteAddr != "" {
+		event.Fields = append(event.Fields, forwardField{"remote_addr", entry.RemoteAddr})
+	}
+	return event
+}
+
+// submissionEvent is the forwarded form of a stored compliance submission
+func submissionEvent(submission *api.ComplianceSubmission) forwardEvent {
+	c := submission.Compliance
+	severity := 1
+	if c.FailedChecks > 0 || c.ErrorChecks > 0 {
+		severity = 5
+	}
+	return forwardEvent{
+		Time:     submission.Timestamp,
+		Kind:     forwardSubmission,
+		Name:     c.OverallStatus,
+		Message:  fmt.Sprintf("%s on %s: %s, %d of %d checks passed", submission.ReportType, submission.Hostname, c.OverallStatus, c.PassedChecks, c.TotalChecks),
+		Severity: severity,
+		Fields: []forwardField{
+			{"submission_id", submission.SubmissionID},
+			{"client_id", submission.ClientID},
+			{"hostname", submission.Hostname},
+			{"report_type", submission.ReportType},
+			{"report_version", submission.ReportVersion},
+			{"status", c.OverallStatus},
+			{"total_checks", strconv.Itoa(c.TotalChecks)},
+			{"passed_checks", strconv.Itoa(c.PassedChecks)},
+			{"failed_checks", strconv.Itoa(c.FailedChecks)},
+			{"warning_checks", strconv.Itoa(c.WarningChecks)},
+			{"error_checks", strconv.Itoa(c.ErrorChecks)},
+		},
+	}
+}
+
+// alertEvent is the forwarded form of a raised alert
+func alertEvent(alert *api.Alert) forwardEvent {
+	severity := 3
+	switch alert.Severity {
+	case "critical":
+		severity = 9
+	case "warning":
+		severity = 6
+	}
+	event := forwardEvent{
+		Time:     alert.Timestamp,
+		Kind:     forwardAlert,
+		Name:     alert.Type,
+		Message:  alert.Message,
+		Severity: severity,
+		Fields:   []forwardField{{"severity", alert.Severity}},
+	}
+	for _, field := range []forwardField{
+		{"alert_id", alert.ID},
+		{"client_id", alert.ClientID},
+		{"hostname", alert.Hostname},
+		{"report_type", alert.ReportType},
+	} {
+		if field.Value != "" {
+			event.Fields = append(event.Fields, field)
+		}
+	}
+	return event
+}
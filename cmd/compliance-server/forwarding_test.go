@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestForwardFormats tests the RFC 5424 and CEF renderings of an event
+func TestForwardFormats(t *testing.T) {
+	event := auditEvent(api.AdminAuditEntry{
+		Actor:      "alice",
+		RemoteAddr: "10.0.0.5",
+		Action:     "policy.update",
+		TargetType: "policy",
+		Target:     `cis "l1"]=|x`,
+	})
+	event.Time = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	f := newForwarder(ForwardingSettings{Format: forwardFormatRFC5424}, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	f.hostname = "compliance-01"
+	header := fmt.Sprintf("<134>1 2026-10-01T12:00:00Z compliance-01 compliance-server %d audit", os.Getpid())
+
+	want := header + ` [compliance@32473 event="policy.update" actor="alice" action="policy.update" target_type="policy"` +
+		` target="cis \"l1\"\]=|x" remote_addr="10.0.0.5"] policy.update by alice on cis "l1"]=|x`
+	if got := f.format(event); got != want {
+		t.Errorf("rfc5424 =\n%s\nwant\n%s", got, want)
+	}
+
+	f.settings.Format = forwardFormatCEF
+	want = header + ` - CEF:0|Compliance Toolkit|compliance-server|` + version + `|audit:policy.update|policy.update by alice on cis "l1"]=\|x|3|` +
+		`rt=1790856000000 cat=audit suser=alice act=policy.update target_type=policy target=cis "l1"]\=|x src=10.0.0.5`
+	if got := f.format(event); got != want {
+		t.Errorf("cef =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestForwardEvents tests the events built from submissions and alerts
+func TestForwardEvents(t *testing.T) {
+	submission := submissionEvent(&api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		ClientID:     "client-1",
+		Hostname:     "ws-01",
+		ReportType:   "NIST 800-171",
+		Compliance:   api.ComplianceData{OverallStatus: "non-compliant", TotalChecks: 10, PassedChecks: 8, FailedChecks: 2},
+	})
+	if submission.Name != "non-compliant" || submission.Severity != 5 || submission.Message != "NIST 800-171 on ws-01: non-compliant, 8 of 10 checks passed" {
+		t.Errorf("submission event = %+v", submission)
+	}
+
+	alert := alertEvent(&api.Alert{Severity: "critical", Type: alertTypeMissedRun, Hostname: "ws-01", Message: "Missed run"})
+	if alert.Severity != 9 || alert.Name != alertTypeMissedRun {
+		t.Errorf("alert event = %+v, want severity 9", alert)
+	}
+	for _, field := range alert.Fields {
+		if field.Value == "" {
+			t.Errorf("alert event has empty field %s", field.Key)
+		}
+	}
+}
+
+// TestForwarderDelivery tests that queued events of the configured kinds
+// reach a TCP receiver, framed by octet counting, and are sent on close
+func TestForwarderDelivery(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		var messages []string
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			message := make([]byte, n)
+			if _, err := io.ReadFull(r, message); err != nil {
+				break
+			}
+			messages = append(messages, string(message))
+		}
+		received <- messages
+	}()
+
+	settings := ForwardingSettings{
+		Address:   listener.Addr().String(),
+		Protocol:  forwardProtocolTCP,
+		Format:    forwardFormatRFC5424,
+		Events:    []string{forwardAudit, forwardAlert},
+		QueueSize: 10,
+	}
+	f := newForwarder(settings, slog.New(slog.NewTextHandler(io.Discard, nil)), func() (net.Conn, error) {
+		return net.Dial("tcp", settings.Address)
+	})
+	go f.run()
+
+	f.forward(auditEvent(api.AdminAuditEntry{Actor: "alice", Action: "user.create", TargetType: "user", Target: "bob"}))
+	f.forward(submissionEvent(&api.ComplianceSubmission{ReportType: "NIST"})) // Not a forwarded kind
+	f.forward(alertEvent(&api.Alert{Severity: "warning", Type: "missed_run", Message: "Missed run\nof NIST"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f.close(ctx)
+
+	messages := <-received
+	if len(messages) != 2 {
+		t.Fatalf("received %d messages, want 2: %q", len(messages), messages)
+	}
+	if !strings.Contains(messages[0], ` audit [compliance@32473 event="user.create"`) {
+		t.Errorf("first message = %q, want the audit event", messages[0])
+	}
+	if !strings.HasPrefix(messages[1], "<132>1 ") || !strings.HasSuffix(messages[1], "] Missed run of NIST") {
+		t.Errorf("second message = %q, want a warning alert on one line", messages[1])
+	}
+
+	var nilForwarder *eventForwarder
+	nilForwarder.forward(forwardEvent{Kind: forwardAudit}) // Forwarding disabled: no-op
+	nilForwarder.close(ctx)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleListAlerts returns open alerts (GET /api/v1/alerts).
+// Pass ?include_resolved=true to include alerts that have since cleared.
+func (s *ComplianceServer) handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	includeResolved, _ := strconv.ParseBool(r.URL.Query().Get("include_resolved"))
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			s.sendError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	alerts, err := s.scopedDB(r).ListAlerts(includeResolved, limit)
+	if err != nil {
+		s.logger.Error("Failed to list alerts", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list alerts")
+		return
+	}
+
+	s.respond(w, r, api.AlertListResponse{Alerts: alerts, Count: len(alerts)}, alertsTable(alerts))
+}
+
+// handleAcknowledgeAlert marks an alert as seen (POST /api/v1/alerts/{alert_id}/acknowledge)
+func (s *ComplianceServer) handleAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("alert_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	acknowledgedBy := "system"
+	if user := requestUser(r); user != nil {
+		acknowledgedBy = user.Username
+	}
+
+	if err := s.scopedDB(r).AcknowledgeAlert(id, acknowledgedBy); err != nil {
+		s.logger.Error("Failed to acknowledge alert", "error", err, "alert_id", id)
+		s.sendError(w, http.StatusNotFound, "Alert not found")
+		return
+	}
+
+	s.respond(w, r, api.StatusResponse{Status: "success", Message: "Alert acknowledged"}, nil)
+}
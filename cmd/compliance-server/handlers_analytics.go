@@ -0,0 +1,257 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleFlakyChecks lists checks that flap between pass and fail across
+// consecutive submissions of an unchanged policy (GET /api/v1/analytics/flaky-checks).
+// Optional parameters: report_type, days (default 30) and min_flips (default 2).
+func (s *ComplianceServer) handleFlakyChecks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			s.sendError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	minFlips := 2
+	if v := query.Get("min_flips"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			s.sendError(w, http.StatusBadRequest, "min_flips must be a positive integer")
+			return
+		}
+		minFlips = parsed
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Second)
+	history, err := s.scopedDB(r).SubmissionHistory(since, query.Get("report_type"))
+	if err != nil {
+		s.logger.Error("Failed to load submission history", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submission history")
+		return
+	}
+
+	policies := findFlakyChecks(history, minFlips)
+	s.respond(w, r, api.FlakyChecksResponse{
+		Since:    since,
+		MinFlips: minFlips,
+		Policies: policies,
+	}, flakyChecksTable(policies))
+}
+
+// handleCheckPerformance ranks checks by execution time or error rate to
+// guide policy optimization (GET /api/v1/analytics/check-performance).
+// Optional parameters: report_type, days (default 30), sort ("duration" or
+// "errors", default "duration") and limit (default 50).
+func (s *ComplianceServer) handleCheckPerformance(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			s.sendError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	sortBy := checkSortDuration
+	if v := query.Get("sort"); v != "" {
+		if v != checkSortDuration && v != checkSortErrors {
+			s.sendError(w, http.StatusBadRequest, "sort must be duration or errors")
+			return
+		}
+		sortBy = v
+	}
+
+	limit := 50
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			s.sendError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Second)
+	history, err := s.scopedDB(r).SubmissionHistory(since, query.Get("report_type"))
+	if err != nil {
+		s.logger.Error("Failed to load submission history", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submission history")
+		return
+	}
+
+	checks := summarizeCheckPerformance(history, sortBy, limit)
+	s.respond(w, r, api.CheckPerformanceResponse{
+		Since:  since,
+		SortBy: sortBy,
+		Checks: checks,
+	}, checkPerformanceTable(checks))
+}
+
+// handlePolicyViolations lists the checks clients refused to run because
+// their security settings block the registry location they read, so that
+// security can see which policies attempt blocked reads across the fleet
+// (GET /api/v1/analytics/policy-violations). Optional parameters:
+// report_type and days (default 30).
+func (s *ComplianceServer) handlePolicyViolations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			s.sendError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Second)
+	history, err := s.scopedDB(r).SubmissionHistory(since, query.Get("report_type"))
+	if err != nil {
+		s.logger.Error("Failed to load submission history", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submission history")
+		return
+	}
+
+	violations := summarizePolicyViolations(history)
+	s.respond(w, r, api.PolicyViolationsResponse{
+		Since:      since,
+		Violations: violations,
+	}, policyViolationsTable(violations))
+}
+
+// handleControlFamilies pivots the latest check results of clients by the
+// control families of the frameworks checks are mapped to, so compliance can
+// be read per framework area (GET /api/v1/analytics/control-families).
+// Optional parameters: framework, report_type and days (default 30).
+func (s *ComplianceServer) handleControlFamilies(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := 30
+	if v := query.Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 365 {
+			s.sendError(w, http.StatusBadRequest, "days must be between 1 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	db := s.scopedDB(r)
+	policies, err := db.ListPolicies()
+	if err != nil {
+		s.logger.Error("Failed to list policies", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load policies")
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Second)
+	history, err := db.SubmissionHistory(since, query.Get("report_type"))
+	if err != nil {
+		s.logger.Error("Failed to load submission history", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submission history")
+		return
+	}
+
+	families := summarizeControlFamilies(history, buildControlIndex(policies), strings.TrimSpace(query.Get("framework")))
+	s.respond(w, r, api.ControlFamiliesResponse{
+		Since:    since,
+		Families: families,
+	}, controlFamiliesTable(families))
+}
+
+// handleCheckFailures lists every client whose latest submission fails a
+// check, with the value it reported, to answer questions such as "who still
+// has SMBv1 enabled" (GET /api/v1/checks/{query_name}/failures). The
+// required policy parameter is described at checkReportType.
+func (s *ComplianceServer) handleCheckFailures(w http.ResponseWriter, r *http.Request) {
+	queryName := r.PathValue("query_name")
+	reportType, ok := s.checkReportType(w, r, queryName)
+	if !ok {
+		return
+	}
+
+	latest, err := s.scopedDB(r).LatestSubmissions(reportType)
+	if err != nil {
+		s.logger.Error("Failed to load latest submissions", "error", err, "report_type", reportType)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submissions")
+		return
+	}
+
+	response := findCheckFailures(latest, queryName)
+	response.ReportType = reportType
+	s.respond(w, r, response, checkFailuresTable(response.Failures))
+}
+
+// handleCheckValues returns the distribution of the values clients last
+// reported for a check, to help policy owners choose realistic expected
+// values before tightening a baseline (GET /api/v1/checks/{query_name}/values).
+// The required policy parameter is described at checkReportType.
+func (s *ComplianceServer) handleCheckValues(w http.ResponseWriter, r *http.Request) {
+	queryName := r.PathValue("query_name")
+	reportType, ok := s.checkReportType(w, r, queryName)
+	if !ok {
+		return
+	}
+
+	latest, err := s.scopedDB(r).LatestSubmissions(reportType)
+	if err != nil {
+		s.logger.Error("Failed to load latest submissions", "error", err, "report_type", reportType)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load submissions")
+		return
+	}
+
+	response := summarizeCheckValues(latest, queryName)
+	response.ReportType = reportType
+	s.respond(w, r, response, checkValuesTable(response.Values))
+}
+
+// checkReportType resolves the policy parameter of the per-check reports to
+// the report type of the submissions to read. It is a policy ID or that
+// report type itself; a policy ID must name a policy defining the check.
+// It sends the error response and returns false when the parameter is
+// missing or invalid.
+func (s *ComplianceServer) checkReportType(w http.ResponseWriter, r *http.Request, queryName string) (string, bool) {
+	policyParam := strings.TrimSpace(r.URL.Query().Get("policy"))
+	if policyParam == "" {
+		s.sendError(w, http.StatusBadRequest, "policy is required")
+		return "", false
+	}
+
+	stored, err := s.requestDB(r).GetPolicy(policyParam)
+	if err != nil {
+		if err.Error() == "policy not found" {
+			return policyParam, true
+		}
+		s.logger.Error("Failed to get policy", "error", err, "policy_id", policyParam)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+		return "", false
+	}
+
+	policy, err := parseSimulationPolicy(stored.PolicyData)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	if !policy.hasCheck(queryName) {
+		s.sendError(w, http.StatusNotFound, "Policy has no check named "+queryName)
+		return "", false
+	}
+	return policy.Metadata.ReportTitle, true
+}
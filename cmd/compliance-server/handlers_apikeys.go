@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// handleListAPIKeys lists all API keys from database
+func (s *ComplianceServer) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.requestDB(r).ListAPIKeys()
+	if err != nil {
+		s.logger.Error("Failed to list API keys", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	now := time.Now()
+	keyInfos := make([]api.APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		info := key.Info()
+		info.ExpiresSoon = key.IsActive && apiKeyExpiresSoon(key.ExpiresAt, s.config().Auth.APIKeyExpiryWarning, now)
+		keyInfos = append(keyInfos, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keyInfos)
+}
+
+// handleGenerateAPIKey generates a new API key
+func (s *ComplianceServer) handleGenerateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string  `json:"name"`
+		ExpiresAt *string `json:"expires_at,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		s.sendError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	// Get current user from session (if logged in)
+	createdBy := "system"
+	if user := requestUser(r); user != nil {
+		createdBy = user.Username
+	}
+
+	// Generate secure random API key
+	apiKey, err := generateSecureAPIKey()
+	if err != nil {
+		s.logger.Error("Failed to generate API key", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to generate API key")
+		return
+	}
+
+	// Hash the key
+	keyHash, err := bcrypt.GenerateFromPassword([]byte(apiKey), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash API key", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to hash API key")
+		return
+	}
+
+	// Store first 8 characters as prefix for display
+	keyPrefix := apiKey[:8] + "..."
+
+	// Save to database
+	if err := s.requestDB(r).CreateAPIKey(req.Name, string(keyHash), keyPrefix, createdBy, req.ExpiresAt); err != nil {
+		s.logger.Error("Failed to save API key", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save API key")
+		return
+	}
+
+	s.logger.Info("API key generated", "name", req.Name, "created_by", createdBy)
+	created := APIKey{Name: req.Name, KeyPrefix: keyPrefix, CreatedBy: createdBy, IsActive: true}
+	if req.ExpiresAt != nil {
+		created.ExpiresAt = *req.ExpiresAt
+	}
+	noteAdminChange(r, req.Name, nil, created.Info())
+
+	// Return the full key ONLY once (never stored)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.APIKeyCreatedResponse{
+		Status:  "success",
+		Message: "API key created successfully",
+		APIKey:  apiKey, // Only time this is shown!
+		Prefix:  keyPrefix,
+		Name:    req.Name,
+	})
+}
+
+// handleDeleteAPIKeyDB deletes an API key from database
+func (s *ComplianceServer) handleDeleteAPIKeyDB(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID int `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	before, _ := s.requestDB(r).GetAPIKey(req.ID)
+
+	if err := s.requestDB(r).DeleteAPIKey(req.ID); err != nil {
+		s.logger.Error("Failed to delete API key", "id", req.ID, "error", err)
+		s.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before != nil {
+		noteAdminChange(r, before.Name, before.Info(), nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "API key deleted successfully",
+	})
+}
+
+// handleToggleAPIKey toggles an API key's active status
+func (s *ComplianceServer) handleToggleAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int  `json:"id"`
+		Active bool `json:"active"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	before, _ := s.requestDB(r).GetAPIKey(req.ID)
+
+	var err error
+	if req.Active {
+		err = s.requestDB(r).ActivateAPIKey(req.ID)
+	} else {
+		err = s.requestDB(r).DeactivateAPIKey(req.ID)
+	}
+
+	if err != nil {
+		s.logger.Error("Failed to toggle API key", "id", req.ID, "active", req.Active, "error", err)
+		s.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before != nil {
+		after := *before
+		after.IsActive = req.Active
+		noteAdminChange(r, before.Name, before.Info(), after.Info())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("API key %s successfully", map[bool]string{true: "activated", false: "deactivated"}[req.Active]),
+	})
+}
+
+// generateSecureAPIKey generates a cryptographically secure random API key
+func generateSecureAPIKey() (string, error) {
+	// Generate 32 random bytes (256 bits)
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	// Encode to base64 URL-safe format (43 characters)
+	key := base64.URLEncoding.EncodeToString(bytes)
+	return key, nil
+}
+
+// handleAPIKeys returns list of API keys (masked)
+func (s *ComplianceServer) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Mask API keys for security
+	keys := s.config().Auth.APIKeys
+	maskedKeys := make([]map[string]string, 0, len(keys))
+	for i, key := range keys {
+		masked := maskAPIKey(key)
+		maskedKeys = append(maskedKeys, map[string]string{
+			"id":     fmt.Sprintf("key-%d", i+1),
+			"key":    masked,
+			"masked": "true",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(maskedKeys)
+}
+
+// handleAddAPIKey adds a new API key
+func (s *ComplianceServer) handleAddAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Key string `json:"key"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if request.Key == "" {
+		s.sendError(w, http.StatusBadRequest, "API key cannot be empty")
+		return
+	}
+
+	// Add to runtime config unless it is a duplicate
+	err := s.updateConfig(func(c *ServerConfig) error {
+		for _, existingKey := range c.Auth.APIKeys {
+			if existingKey == request.Key {
+				return errAPIKeyExists
+			}
+		}
+		c.Auth.APIKeys = append(slices.Clip(c.Auth.APIKeys), request.Key)
+		return nil
+	})
+	if err != nil {
+		s.sendError(w, http.StatusConflict, "API key already exists")
+		return
+	}
+
+	s.logger.Info("API key added", "key_preview", maskAPIKey(request.Key))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "API key added successfully",
+	})
+}
+
+// handleDeleteAPIKey deletes an API key
+func (s *ComplianceServer) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Key string `json:"key"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// Find and remove key
+	err := s.updateConfig(func(c *ServerConfig) error {
+		found := false
+		newKeys := make([]string, 0, len(c.Auth.APIKeys))
+		for _, key := range c.Auth.APIKeys {
+			if key != request.Key {
+				newKeys = append(newKeys, key)
+			} else {
+				found = true
+			}
+		}
+		if !found {
+			return errAPIKeyNotFound
+		}
+		c.Auth.APIKeys = newKeys
+		return nil
+	})
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	s.logger.Info("API key deleted", "key_preview", maskAPIKey(request.Key))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "API key deleted successfully",
+	})
+}
+
+// Errors updateConfig returns to the API key handlers
+var (
+	errAPIKeyExists   = errors.New("API key already exists")
+	errAPIKeyNotFound = errors.New("API key not found")
+)
+
+// maskAPIKey masks an API key for display
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
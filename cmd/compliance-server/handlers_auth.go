@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// handleLogin processes login requests
+func (s *ComplianceServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var loginReq struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate inputs
+	if loginReq.Username == "" || loginReq.Password == "" {
+		s.sendError(w, http.StatusBadRequest, "Username and password required")
+		return
+	}
+
+	// Get user from database
+	user, err := s.requestDB(r).GetUser(loginReq.Username)
+	if err != nil {
+		s.logger.Warn("Login attempt for non-existent user", "username", loginReq.Username)
+		s.sendError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	// Refuse locked accounts before checking the password, answering as
+	// for unknown users so the lock does not reveal that the account exists
+	if user.LockedUntil != nil {
+		s.logger.Warn("Login attempt for locked account", "username", loginReq.Username, "remote_addr", r.RemoteAddr,
+			"locked_until", user.LockedUntil.Format(time.RFC3339))
+		s.sendError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	// Verify password
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(loginReq.Password))
+	if err != nil {
+		s.logger.Warn("Failed login attempt", "username", loginReq.Username, "remote_addr", r.RemoteAddr)
+		lockedUntil, err := s.requestDB(r).RecordFailedLogin(user.ID, s.lockoutPolicy())
+		if err != nil {
+			s.logger.Error("Failed to record failed login", "username", loginReq.Username, "error", err)
+		} else if lockedUntil != nil {
+			s.logger.Warn("Account locked after failed logins", "username", loginReq.Username, "locked_until", lockedUntil)
+		}
+		s.sendError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	// Invited users have a password nobody knows until they accept the
+	// invitation, so this only stops an admin-set password being used first
+	if user.MustChangePassword {
+		s.sendError(w, http.StatusForbidden, "Set your password with the link in your invitation before logging in")
+		return
+	}
+
+	if err := s.requestDB(r).ResetFailedLogins(user.ID); err != nil {
+		s.logger.Error("Failed to reset failed logins", "username", loginReq.Username, "error", err)
+	}
+
+	// Update last login timestamp
+	if err := s.requestDB(r).UpdateUserLastLogin(loginReq.Username); err != nil {
+		s.logger.Error("Failed to update last login", "username", loginReq.Username, "error", err)
+	}
+
+	// Create the session the cookie refers to
+	token, tokenHash, err := newUserToken()
+	if err == nil {
+		err = s.requestDB(r).CreateSession(user.ID, tokenHash, time.Now().Add(sessionTTL))
+	}
+	if err != nil {
+		s.logger.Error("Failed to create session", "username", loginReq.Username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+
+	sessionCookie := &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false, // Set to true if using HTTPS
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(sessionTTL.Seconds()),
+	}
+	http.SetCookie(w, sessionCookie)
+
+	// Also set role cookie for frontend
+	roleCookie := &http.Cookie{
+		Name:     "session_role",
+		Value:    user.Role,
+		Path:     "/",
+		HttpOnly: false, // Allow JS to read role for UI
+		Secure:   false,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(sessionTTL.Seconds()),
+	}
+	http.SetCookie(w, roleCookie)
+
+	s.logger.Info("User logged in", "username", loginReq.Username, "role", user.Role)
+	s.events.publish(topicUserLogin, userLogin{Username: user.Username, Role: user.Role, RemoteAddr: s.remoteIP(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.LoginResponse{
+		Success:  true,
+		Username: user.Username,
+		Role:     user.Role,
+	})
+}
+
+// lockoutPolicy returns when failed logins lock an account
+// (auth.lockout), shared by session and JWT logins
+func (s *ComplianceServer) lockoutPolicy() auth.LockoutPolicy {
+	return auth.LockoutPolicy{
+		MaxAttempts: s.config().Auth.Lockout.MaxAttempts,
+		Duration:    s.config().Auth.Lockout.Duration,
+	}
+}
+
+// handleLogout processes logout requests
+func (s *ComplianceServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// End the session and clear its cookies
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+		if err := s.requestDB(r).DeleteSession(hashUserToken(cookie.Value)); err != nil {
+			s.logger.Error("Failed to delete session", "error", err)
+		}
+	}
+
+	sessionCookie := &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	}
+	http.SetCookie(w, sessionCookie)
+
+	roleCookie := &http.Cookie{
+		Name:     "session_role",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: false,
+		MaxAge:   -1,
+	}
+	http.SetCookie(w, roleCookie)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.LoginResponse{Success: true})
+}
+
+// handleGetSession returns session info (used by dashboard to check if authenticated)
+func (s *ComplianceServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	// Check for cookie
+	keys := s.config().Auth.APIKeys
+	cookie, err := r.Cookie("api_token")
+	if err != nil || cookie.Value == "" {
+		// No session cookie, return first API key for convenience (dashboard use only)
+		if len(keys) > 0 {
+			// Set cookie with first API key
+			http.SetCookie(w, &http.Cookie{
+				Name:     "api_token",
+				Value:    keys[0],
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+				MaxAge:   86400, // 24 hours
+			})
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(api.SessionResponse{
+				Status:         "authenticated",
+				Authentication: "cookie",
+			})
+			return
+		}
+
+		s.sendError(w, http.StatusUnauthorized, "No authentication found")
+		return
+	}
+
+	// Validate existing cookie
+	valid := false
+	for _, key := range keys {
+		if cookie.Value == key {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		s.sendError(w, http.StatusUnauthorized, "Invalid session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.SessionResponse{
+		Status:         "authenticated",
+		Authentication: "cookie",
+	})
+}
+
+// handleGetLoginMessage returns the configured login message (public endpoint)
+func (s *ComplianceServer) handleGetLoginMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.LoginMessageResponse{
+		Message: s.config().Dashboard.LoginMessage,
+	})
+}
+
+// handleUpdateLoginMessage updates the login message
+func (s *ComplianceServer) handleUpdateLoginMessage(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Message string `json:"message"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// Update the login message in config (runtime only)
+	s.updateConfig(func(c *ServerConfig) error {
+		noteAdminChange(r, "dashboard.login_message", c.Dashboard.LoginMessage, request.Message)
+		c.Dashboard.LoginMessage = request.Message
+		return nil
+	})
+
+	s.logger.Info("Login message updated", "message", request.Message)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Login message updated successfully",
+	})
+}
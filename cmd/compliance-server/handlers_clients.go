@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleRegister handles client registration requests
+func (s *ComplianceServer) handleRegister(w http.ResponseWriter, r *http.Request) {
+	// Parse registration
+	var registration api.ClientRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	s.logger.Info("Client registration",
+		"client_id", registration.ClientID,
+		"hostname", registration.Hostname,
+	)
+
+	// Register client in database
+	created, err := s.requestDB(r).RegisterClient(&registration)
+	if err != nil {
+		s.logger.Error("Failed to register client", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to register client")
+		return
+	}
+	if created {
+		s.events.publish(topicClientRegistered, clientRegistered{
+			ClientID:  registration.ClientID,
+			Hostname:  registration.Hostname,
+			OSVersion: registration.SystemInfo.OSVersion,
+			Source:    "registration",
+		})
+	}
+	s.reconcileClientIdentity(registration.ClientID, registration.Hostname, &registration.SystemInfo)
+
+	compat := checkCompatibility(s.config().Clients, registration.AgentVersion, registration.SchemaVersions)
+	s.noteCompatibility(registration.ClientID, registration.AgentVersion, compat)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.RegistrationResponse{
+		Status:        "registered",
+		Message:       "Client registered successfully",
+		Compatibility: compat,
+	})
+}
+
+// handleHeartbeat records that a client is alive and the schedule it runs on
+func (s *ComplianceServer) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var heartbeat api.Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if err := heartbeat.Validate(); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if heartbeat.Schedule != nil && heartbeat.Schedule.Enabled {
+		if _, err := cron.ParseStandard(heartbeat.Schedule.Cron); err != nil {
+			s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid schedule.cron: %v", err))
+			return
+		}
+	}
+
+	if err := s.requestDB(r).RecordHeartbeat(&heartbeat); err != nil {
+		s.logger.Error("Failed to record heartbeat", "error", err, "client_id", heartbeat.ClientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to record heartbeat")
+		return
+	}
+
+	s.logger.Debug("Client heartbeat", "client_id", heartbeat.ClientID, "hostname", heartbeat.Hostname)
+	s.raiseRunFailureAlerts(&heartbeat)
+
+	// Heartbeats are accepted whatever the verdict, so an outdated client
+	// still learns it has to upgrade and still receives update commands
+	compat := checkCompatibility(s.config().Clients, heartbeat.AgentVersion, heartbeat.SchemaVersions)
+	s.noteCompatibility(heartbeat.ClientID, heartbeat.AgentVersion, compat)
+
+	// Hand over any queued commands
+	commands, err := s.requestDB(r).DeliverPendingCommands(heartbeat.ClientID)
+	if err != nil {
+		s.logger.Error("Failed to deliver commands", "error", err, "client_id", heartbeat.ClientID)
+	}
+	// A command that cannot be signed is not sent, as the client would
+	// refuse it, but marked failed so it does not stay delivered
+	expiresAt := time.Now().Add(s.config().Commands.TTL)
+	signed := commands[:0]
+	for _, cmd := range commands {
+		if err := signCommand(s.commandKey, &cmd, expiresAt); err != nil {
+			s.logger.Error("Failed to sign command", "error", err, "id", cmd.ID)
+			result := &api.CommandResult{Status: api.CommandStatusFailed, Message: "The server failed to sign the command"}
+			if err := s.requestDB(r).CompleteCommand(cmd.ID, cmd.ClientID, result); err != nil {
+				s.logger.Error("Failed to mark command failed", "error", err, "id", cmd.ID)
+			}
+			s.auditCommand(cmd.ID, result.Status, "server", result.Message)
+			continue
+		}
+		s.logger.Info("Command delivered", "id", cmd.ID, "client_id", cmd.ClientID, "type", cmd.Type)
+		s.auditCommand(cmd.ID, commandEventDelivered, cmd.ClientID, "")
+		signed = append(signed, cmd)
+	}
+	commands = signed
+
+	// Policies assigned to the client and its groups; clients syncing
+	// reports from the server run them as well. On failure nil is sent,
+	// which clients take as no change.
+	policies, err := s.requestDB(r).AssignedPolicies(heartbeat.ClientID)
+	if err != nil {
+		s.logger.Error("Failed to get assigned policies", "error", err, "client_id", heartbeat.ClientID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.HeartbeatResponse{
+		Status:        "ok",
+		ServerTime:    time.Now().UTC(),
+		Commands:      commands,
+		Policies:      policies,
+		Compatibility: compat,
+	})
+}
+
+// handleListClients handles client list requests. Pass ?tag= to list the
+// clients of one group.
+func (s *ComplianceServer) handleListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := s.scopedDB(r).ListClients()
+	if err != nil {
+		s.logger.Error("Failed to list clients", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list clients")
+		return
+	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		clients = slices.DeleteFunc(clients, func(client api.ClientInfo) bool {
+			return !slices.Contains(client.Tags, strings.ToLower(tag))
+		})
+	}
+	if clients == nil {
+		clients = []api.ClientInfo{}
+	}
+
+	settings := s.config().Clients
+	for i := range clients {
+		markCompatibility(settings, &clients[i])
+	}
+
+	if windows, err := s.requestDB(r).ListMaintenanceWindows(); err != nil {
+		s.logger.Warn("Failed to load maintenance windows", "error", err)
+	} else {
+		now := time.Now()
+		for i := range clients {
+			if window := findMaintenanceWindow(windows, clients[i].ClientID, clients[i].Hostname, clients[i].Tags, now); window != nil {
+				clients[i].MaintenanceWindow = window.Name
+			}
+		}
+	}
+
+	// No Last-Modified: deletes, merges, maintenance windows and
+	// compatibility settings change the list without a newer last_seen
+	s.respondCached(w, r, clients, clientsTable(clients), time.Time{})
+}
+
+// handleGetClient returns details for a single client (GET /api/v1/clients/{client_id})
+func (s *ComplianceServer) handleGetClient(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+	db := s.scopedDB(r)
+
+	// Get client from database
+	client, err := db.GetClient(clientID)
+	if err != nil {
+		s.logger.Error("Failed to get client", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusNotFound, "Client not found")
+		return
+	}
+
+	// Get compliance scores by report type
+	scoresByType, err := db.GetClientComplianceScoresByType(clientID)
+	if err != nil {
+		s.logger.Warn("Failed to get compliance scores by type", "error", err, "client_id", clientID)
+		// Non-fatal - continue with empty scores
+		scoresByType = make(map[string]float64)
+	}
+	client.ComplianceScoresByType = scoresByType
+
+	if window := s.activeMaintenanceWindow(client.ClientID, client.Hostname, time.Now()); window != nil {
+		client.MaintenanceWindow = window.Name
+	}
+	markCompatibility(s.config().Clients, client)
+
+	// Scores, maintenance windows and compatibility change without
+	// last_seen, so only the ETag validates the detail
+	s.respondCached(w, r, client, nil, time.Time{})
+}
+
+// handleClientSubmissions handles client submission history requests
+func (s *ComplianceServer) handleClientSubmissions(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+
+	// Get submissions from database
+	submissions, err := s.scopedDB(r).GetClientSubmissions(clientID)
+	if err != nil {
+		s.logger.Error("Failed to get client submissions", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve submissions")
+		return
+	}
+	if submissions == nil {
+		submissions = []api.SubmissionSummary{}
+	}
+
+	s.respond(w, r, submissions, submissionsTable(submissions))
+}
+
+// handleClientTelemetry returns the agent telemetry history for a client, oldest first.
+// The optional limit query parameter caps the number of samples (default 100, max 1000).
+func (s *ComplianceServer) handleClientTelemetry(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			s.sendError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, 1000)
+	}
+
+	points, err := s.scopedDB(r).GetClientTelemetry(clientID, limit)
+	if err != nil {
+		s.logger.Error("Failed to get client telemetry", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve telemetry")
+		return
+	}
+
+	s.respond(w, r, points, telemetryTable(points))
+}
+
+// handleClearClientHistory clears all submission history for a client
+func (s *ComplianceServer) handleClearClientHistory(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+	db := s.scopedDB(r)
+
+	// Verify client exists
+	client, err := db.GetClient(clientID)
+	if err != nil {
+		s.logger.Error("Client not found", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusNotFound, "Client not found")
+		return
+	}
+
+	// Clear client history
+	deletedCount, err := db.ClearClientHistory(clientID)
+	if err != nil {
+		s.logger.Error("Failed to clear client history", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to clear history")
+		return
+	}
+
+	s.logger.Info("Client history cleared", "client_id", clientID, "deleted_count", deletedCount)
+	if deletedCount > 0 {
+		go s.removeOrphanAttachments()
+	}
+	noteAdminChange(r, clientID, map[string]interface{}{"hostname": client.Hostname, "submissions": deletedCount}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.DeleteCountResponse{
+		Status:       "success",
+		Message:      fmt.Sprintf("Cleared %d submissions for client %s", deletedCount, clientID),
+		DeletedCount: deletedCount,
+	})
+}
+
+// handleDuplicateClients lists hostnames registered by more than one client
+// (GET /api/v1/clients/duplicates)
+func (s *ComplianceServer) handleDuplicateClients(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.scopedDB(r).ListDuplicateClients()
+	if err != nil {
+		s.logger.Error("Failed to list duplicate clients", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list duplicate clients")
+		return
+	}
+
+	s.respond(w, r, groups, nil)
+}
+
+// handleMergeClient merges another client record into the client in the path
+// and deletes it (POST /api/v1/clients/merge/{client_id})
+func (s *ComplianceServer) handleMergeClient(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("client_id")
+
+	var request api.ClientMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if request.SourceClientID == "" {
+		s.sendError(w, http.StatusBadRequest, "source_client_id is required")
+		return
+	}
+	if request.SourceClientID == targetID {
+		s.sendError(w, http.StatusBadRequest, "A client cannot be merged into itself")
+		return
+	}
+
+	// Operators can only merge clients that are both in their scope
+	for _, clientID := range []string{targetID, request.SourceClientID} {
+		if _, err := s.scopedDB(r).GetClient(clientID); err != nil {
+			s.logger.Error("Client not found", "error", err, "client_id", clientID)
+			s.sendError(w, http.StatusNotFound, fmt.Sprintf("Client not found: %s", clientID))
+			return
+		}
+	}
+
+	merged, err := s.requestDB(r).MergeClients(targetID, request.SourceClientID)
+	if err != nil {
+		s.logger.Error("Failed to merge clients", "error", err, "client_id", targetID, "source_client_id", request.SourceClientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to merge clients")
+		return
+	}
+
+	noteAdminChange(r, targetID, map[string]string{"source_client_id": request.SourceClientID}, merged)
+	merged.Status = "success"
+	merged.Message = fmt.Sprintf("Merged %s into %s (%d submissions)", request.SourceClientID, targetID, merged.Submissions)
+	s.respond(w, r, merged, nil)
+}
+
+// handleSetClientTags replaces the tags of a client
+// (POST /api/v1/clients/tags/{client_id})
+func (s *ComplianceServer) handleSetClientTags(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+
+	var request api.ClientTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tags, err := normalizeTags(request.Tags)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var before []string
+	if client, err := s.requestDB(r).GetClient(clientID); err == nil {
+		before = client.Tags
+	}
+
+	if err := s.requestDB(r).SetClientTags(clientID, tags); err != nil {
+		if err.Error() == "client not found" {
+			s.sendError(w, http.StatusNotFound, "Client not found")
+			return
+		}
+		s.logger.Error("Failed to set client tags", "error", err, "client_id", clientID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set tags")
+		return
+	}
+
+	noteAdminChange(r, clientID, map[string][]string{"tags": before}, map[string][]string{"tags": tags})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Client %s has %d tag(s)", clientID, len(tags)),
+	})
+}
+
+// handleDashboardSummary provides dashboard data
+func (s *ComplianceServer) handleDashboardSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.dashboardSummary(r)
+	if err != nil {
+		s.logger.Error("Failed to get dashboard summary", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get dashboard summary")
+		return
+	}
+
+	s.respondCached(w, r, summary, complianceByTypeTable(summary.ComplianceByType), time.Time{})
+}
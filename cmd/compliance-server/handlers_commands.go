@@ -0,0 +1,412 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// supportedCommandTypes are the command types clients know how to execute
+var supportedCommandTypes = map[string]bool{
+	api.CommandTypeScan:              true,
+	api.CommandTypeRefreshPolicies:   true,
+	api.CommandTypeUploadDiagnostics: true,
+	api.CommandTypeUpdateAgent:       true,
+}
+
+// approvalRequiredCommandTypes change what runs on clients. One admin stages
+// them and a different admin must approve them before they are delivered.
+var approvalRequiredCommandTypes = map[string]bool{
+	api.CommandTypeUpdateAgent: true,
+}
+
+// Command audit events
+const (
+	commandEventStaged    = "staged"
+	commandEventApproved  = "approved"
+	commandEventRejected  = "rejected"
+	commandEventQueued    = "queued"
+	commandEventDelivered = "delivered"
+)
+
+// validateCommandRequest checks a command request's type, target and
+// type-specific arguments
+func validateCommandRequest(request api.CommandRequest) error {
+	if !supportedCommandTypes[request.Type] {
+		return fmt.Errorf("unsupported command type: %q", request.Type)
+	}
+	targets := 0
+	for _, target := range []string{request.ClientID, request.HostnamePattern, request.Group} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("exactly one of client_id, hostname_pattern or group is required")
+	}
+	if request.Group != "" {
+		if _, err := normalizeGroup(request.Group); err != nil {
+			return fmt.Errorf("invalid group: %w", err)
+		}
+	}
+	if len(request.Reports) > 0 && request.Type != api.CommandTypeScan {
+		return fmt.Errorf("reports only apply to %s commands", api.CommandTypeScan)
+	}
+
+	if request.Type == api.CommandTypeUpdateAgent {
+		u, err := url.Parse(request.Arguments[api.CommandArgURL])
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("arguments.%s must be an https URL", api.CommandArgURL)
+		}
+		if sum, err := hex.DecodeString(request.Arguments[api.CommandArgSHA256]); err != nil || len(sum) != 32 {
+			return fmt.Errorf("arguments.%s must be a hex SHA-256 digest", api.CommandArgSHA256)
+		}
+		if request.Arguments[api.CommandArgVersion] == "" {
+			return fmt.Errorf("arguments.%s is required", api.CommandArgVersion)
+		}
+	}
+	return nil
+}
+
+// handleCreateCommand queues a command for a client, for every client
+// whose hostname matches a pattern or for every client in a group
+// (POST /api/v1/commands)
+func (s *ComplianceServer) handleCreateCommand(w http.ResponseWriter, r *http.Request) {
+	var request api.CommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateCommandRequest(request); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	createdBy := "system"
+	if user := requestUser(r); user != nil {
+		createdBy = user.Username
+	}
+
+	status, event := api.CommandStatusPending, commandEventQueued
+	if approvalRequiredCommandTypes[request.Type] {
+		admin, err := requestAdmin(r)
+		if err != nil {
+			s.sendError(w, http.StatusForbidden, fmt.Sprintf("Staging %s commands requires a signed-in admin: %v", request.Type, err))
+			return
+		}
+		createdBy = admin
+		status, event = api.CommandStatusAwaitingApproval, commandEventStaged
+	}
+
+	clientIDs, err := s.commandTargets(s.scopedDB(r), request)
+	if err != nil {
+		s.logger.Error("Failed to resolve command targets", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to resolve clients")
+		return
+	}
+	if len(clientIDs) == 0 {
+		s.sendError(w, http.StatusNotFound, "No matching clients")
+		return
+	}
+
+	// Only queue for clients that opted in to this command type
+	clientIDs, skipped, err := s.capableClients(clientIDs, request.Type)
+	if err != nil {
+		s.logger.Error("Failed to load client capabilities", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to resolve clients")
+		return
+	}
+	if len(clientIDs) == 0 {
+		s.sendError(w, http.StatusConflict, fmt.Sprintf("No matching client has enabled the %s capability", request.Type))
+		return
+	}
+
+	commands := make([]api.Command, 0, len(clientIDs))
+	for _, clientID := range clientIDs {
+		cmd := api.Command{
+			ClientID:  clientID,
+			Type:      request.Type,
+			Reports:   request.Reports,
+			Arguments: request.Arguments,
+			Status:    status,
+			CreatedBy: createdBy,
+		}
+		if err := s.requestDB(r).CreateCommand(&cmd); err != nil {
+			s.logger.Error("Failed to queue command", "error", err, "client_id", clientID)
+			s.sendError(w, http.StatusInternalServerError, "Failed to queue command")
+			return
+		}
+		s.auditCommand(cmd.ID, event, createdBy, "")
+		commands = append(commands, cmd)
+	}
+
+	message := fmt.Sprintf("Queued %d command(s); clients collect them with their next heartbeat", len(commands))
+	if status == api.CommandStatusAwaitingApproval {
+		message = fmt.Sprintf("Staged %d command(s); a second admin must approve them before delivery", len(commands))
+	}
+	if len(skipped) > 0 {
+		message += fmt.Sprintf(". Skipped %d client(s) without the %s capability: %s",
+			len(skipped), request.Type, strings.Join(skipped, ", "))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.CommandsCreatedResponse{
+		Status:   "success",
+		Message:  message,
+		Commands: commands,
+	})
+}
+
+// capableClients splits clients into those whose last heartbeat listed the
+// command type among their capabilities and those that did not
+func (s *ComplianceServer) capableClients(clientIDs []string, commandType string) (capable, skipped []string, err error) {
+	for _, clientID := range clientIDs {
+		capabilities, err := s.db.ClientCapabilities(clientID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if hasCapability(capabilities, commandType) {
+			capable = append(capable, clientID)
+		} else {
+			skipped = append(skipped, clientID)
+		}
+	}
+	return capable, skipped, nil
+}
+
+// hasCapability reports whether a capability list includes a command type
+func hasCapability(capabilities []string, commandType string) bool {
+	for _, capability := range capabilities {
+		if capability == commandType {
+			return true
+		}
+	}
+	return false
+}
+
+// auditCommand records a command event. Failures are logged rather than
+// returned so an audit outage does not block command delivery.
+func (s *ComplianceServer) auditCommand(commandID int, event, actor, details string) {
+	if err := s.db.AddCommandAudit(commandID, event, actor, details); err != nil {
+		s.logger.Error("Failed to record command audit", "error", err, "command_id", commandID, "event", event)
+	}
+}
+
+// commandTargets resolves the clients a command request addresses among
+// those db can see. An unknown or out-of-scope client_id resolves to no
+// clients.
+func (s *ComplianceServer) commandTargets(db *Database, request api.CommandRequest) ([]string, error) {
+	if request.ClientID != "" {
+		if _, err := db.GetClient(request.ClientID); err != nil {
+			return nil, nil
+		}
+		return []string{request.ClientID}, nil
+	}
+
+	clients, err := db.ListClients()
+	if err != nil {
+		return nil, err
+	}
+
+	group := strings.ToLower(strings.TrimSpace(request.Group))
+	var clientIDs []string
+	for _, client := range clients {
+		if group != "" && slices.Contains(client.Tags, group) ||
+			request.HostnamePattern != "" && hostnameMatches(request.HostnamePattern, client.Hostname) {
+			clientIDs = append(clientIDs, client.ClientID)
+		}
+	}
+	return clientIDs, nil
+}
+
+// requestAdmin returns the username of the admin authMiddleware
+// authenticated r as, by session or JWT. Requests made with an API key name
+// no user and are refused.
+func requestAdmin(r *http.Request) (string, error) {
+	user := requestUser(r)
+	if user == nil {
+		return "", fmt.Errorf("not signed in")
+	}
+	if user.Role != "admin" {
+		return "", fmt.Errorf("admin role required")
+	}
+	return user.Username, nil
+}
+
+// handleApproveCommand releases a staged command for delivery
+// (POST /api/v1/commands/{command_id}/approve)
+func (s *ComplianceServer) handleApproveCommand(w http.ResponseWriter, r *http.Request) {
+	s.decideCommand(w, r, true)
+}
+
+// handleRejectCommand declines a staged command so it is never delivered
+// (POST /api/v1/commands/{command_id}/reject)
+func (s *ComplianceServer) handleRejectCommand(w http.ResponseWriter, r *http.Request) {
+	s.decideCommand(w, r, false)
+}
+
+// decideCommand approves or rejects a command awaiting approval. Approval
+// must come from a different admin than the one who staged the command;
+// either admin may reject it.
+func (s *ComplianceServer) decideCommand(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.Atoi(r.PathValue("command_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid command ID")
+		return
+	}
+
+	admin, err := requestAdmin(r)
+	if err != nil {
+		s.sendError(w, http.StatusForbidden, fmt.Sprintf("Deciding on staged commands requires a signed-in admin: %v", err))
+		return
+	}
+
+	cmd, err := s.scopedDB(r).GetCommand(id)
+	if err != nil {
+		if err.Error() == "command not found" {
+			s.sendError(w, http.StatusNotFound, "Command not found")
+			return
+		}
+		s.logger.Error("Failed to get command", "error", err, "command_id", id)
+		s.sendError(w, http.StatusInternalServerError, "Failed to get command")
+		return
+	}
+
+	if cmd.Status != api.CommandStatusAwaitingApproval {
+		s.sendError(w, http.StatusConflict, fmt.Sprintf("Command is not awaiting approval (status %s)", cmd.Status))
+		return
+	}
+	if approve && cmd.CreatedBy == admin {
+		s.sendError(w, http.StatusForbidden, "A command must be approved by a different admin than the one who staged it")
+		return
+	}
+
+	if err := s.requestDB(r).DecideCommand(id, admin, approve); err != nil {
+		if err.Error() == "command not found" {
+			s.sendError(w, http.StatusConflict, "Command is no longer awaiting approval")
+			return
+		}
+		s.logger.Error("Failed to decide command", "error", err, "command_id", id)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update command")
+		return
+	}
+
+	event, message := commandEventRejected, "Command rejected"
+	if approve {
+		event, message = commandEventApproved, "Command approved; the client collects it with its next heartbeat"
+	}
+	s.auditCommand(id, event, admin, "")
+	s.logger.Info("Staged command decided", "id", id, "event", event, "admin", admin, "staged_by", cmd.CreatedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{Status: "success", Message: message})
+}
+
+// handleListCommands returns recent commands (GET /api/v1/commands).
+// Pass ?client_id= to restrict the list to one client.
+func (s *ComplianceServer) handleListCommands(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			s.sendError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	commands, err := s.scopedDB(r).ListCommands(r.URL.Query().Get("client_id"), limit)
+	if err != nil {
+		s.logger.Error("Failed to list commands", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list commands")
+		return
+	}
+
+	s.respond(w, r, commands, commandsTable(commands))
+}
+
+// handleCommandResult records how a client finished a delivered command
+// (POST /api/v1/commands/{command_id}/result)
+func (s *ComplianceServer) handleCommandResult(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("command_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid command ID")
+		return
+	}
+
+	var result api.CommandResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if result.ClientID == "" {
+		s.sendError(w, http.StatusBadRequest, "client_id is required")
+		return
+	}
+	if result.Status != api.CommandStatusCompleted && result.Status != api.CommandStatusFailed {
+		s.sendError(w, http.StatusBadRequest, "status must be completed or failed")
+		return
+	}
+
+	if err := s.requestDB(r).CompleteCommand(id, result.ClientID, &result); err != nil {
+		if err.Error() == "command not found" {
+			s.sendError(w, http.StatusNotFound, "No delivered command with that ID for this client")
+			return
+		}
+		s.logger.Error("Failed to record command result", "error", err, "command_id", id)
+		s.sendError(w, http.StatusInternalServerError, "Failed to record command result")
+		return
+	}
+
+	s.logger.Info("Command finished", "id", id, "client_id", result.ClientID, "status", result.Status)
+	s.auditCommand(id, result.Status, result.ClientID, result.Message)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{Status: "success"})
+}
+
+// handleCommandAudit returns the audit trail of a command
+// (GET /api/v1/commands/{command_id}/audit)
+func (s *ComplianceServer) handleCommandAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("command_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid command ID")
+		return
+	}
+
+	entries, err := s.scopedDB(r).ListCommandAudit(id)
+	if err != nil {
+		s.logger.Error("Failed to list command audit", "error", err, "command_id", id)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list command audit")
+		return
+	}
+
+	s.respond(w, r, entries, commandAuditTable(entries))
+}
+
+// handleCommandSigningKey publishes the public key clients verify command
+// signatures with (GET /api/v1/commands/signing-key)
+func (s *ComplianceServer) handleCommandSigningKey(w http.ResponseWriter, r *http.Request) {
+	if s.commandKey == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "Command signing is not initialized")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.CommandSigningKeyResponse{
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(s.commandKey.Public().(ed25519.PublicKey)),
+	})
+}
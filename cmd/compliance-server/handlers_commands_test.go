@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCommandRequestValidation tests that malformed command requests are
+// rejected before any client lookup
+func TestCommandRequestValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"invalid json", "POST", "/api/v1/commands", `{`},
+		{"unsupported type", "POST", "/api/v1/commands", `{"type":"reboot","client_id":"client-1"}`},
+		{"no target", "POST", "/api/v1/commands", `{"type":"scan"}`},
+		{"two targets", "POST", "/api/v1/commands", `{"type":"scan","client_id":"client-1","hostname_pattern":"WS-*"}`},
+		{"group and client", "POST", "/api/v1/commands", `{"type":"scan","client_id":"client-1","group":"pos"}`},
+		{"bad group", "POST", "/api/v1/commands", `{"type":"scan","group":"point of sale"}`},
+		{"reports on non-scan", "POST", "/api/v1/commands", `{"type":"upload_diagnostics","client_id":"client-1","reports":["NIST"]}`},
+		{"update without url", "POST", "/api/v1/commands", `{"type":"update_agent","client_id":"client-1","arguments":{"sha256":"` + strings.Repeat("ab", 32) + `","version":"1.1.0"}}`},
+		{"update over http", "POST", "/api/v1/commands", `{"type":"update_agent","client_id":"client-1","arguments":{"url":"http://updates.example/agent.exe","sha256":"` + strings.Repeat("ab", 32) + `","version":"1.1.0"}}`},
+		{"update bad digest", "POST", "/api/v1/commands", `{"type":"update_agent","client_id":"client-1","arguments":{"url":"https://updates.example/agent.exe","sha256":"abc","version":"1.1.0"}}`},
+		{"update without version", "POST", "/api/v1/commands", `{"type":"update_agent","client_id":"client-1","arguments":{"url":"https://updates.example/agent.exe","sha256":"` + strings.Repeat("ab", 32) + `"}}`},
+		{"audit bad id", "GET", "/api/v1/commands/abc/audit", ``},
+		{"result bad id", "POST", "/api/v1/commands/abc/result", `{"client_id":"client-1","status":"completed"}`},
+		{"result without client", "POST", "/api/v1/commands/1/result", `{"status":"completed"}`},
+		{"result bad status", "POST", "/api/v1/commands/1/result", `{"client_id":"client-1","status":"delivered"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestCommandApprovalRequiresSession tests that staging and deciding on
+// commands that need two-person approval is refused without a signed-in admin
+func TestCommandApprovalRequiresSession(t *testing.T) {
+	handler := newTestServer().routeHandler()
+	digest := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"stage update", "/api/v1/commands", `{"type":"update_agent","client_id":"client-1","arguments":{"url":"https://updates.example/agent.exe","sha256":"` + digest + `","version":"1.1.0"}}`, http.StatusForbidden},
+		{"approve", "/api/v1/commands/1/approve", ``, http.StatusForbidden},
+		{"reject", "/api/v1/commands/1/reject", ``, http.StatusForbidden},
+		{"approve bad id", "/api/v1/commands/abc/approve", ``, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// A cookie naming an admin is not an authenticated admin
+	s := newTestServer()
+	s.config().Auth.Enabled, s.config().Auth.RequireKey = true, true
+	handler = s.routeHandler()
+	for _, tt := range tests[:3] {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		req.AddCookie(&http.Cookie{Name: "session_user", Value: "admin"})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s with a forged session cookie = %d, want 401", tt.name, rec.Code)
+		}
+	}
+}
+
+// TestRequestAdmin tests that the approving admin is the user authenticated
+// on the request
+func TestRequestAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *User
+		want    string
+		wantErr bool
+	}{
+		{"admin", &User{Username: "alice", Role: "admin"}, "alice", false},
+		{"viewer", &User{Username: "bob", Role: "viewer"}, "", true},
+		{"api key", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/commands/1/approve", nil)
+			// A cookie naming another user changes nothing
+			r.AddCookie(&http.Cookie{Name: "session_user", Value: "carol"})
+			if tt.user != nil {
+				r = withUser(r, tt.user)
+			}
+			got, err := requestAdmin(r)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("requestAdmin() = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleListMaintenanceWindows returns all maintenance windows
+func (s *ComplianceServer) handleListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := s.requestDB(r).ListMaintenanceWindows()
+	if err != nil {
+		s.logger.Error("Failed to list maintenance windows", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve maintenance windows")
+		return
+	}
+
+	s.respond(w, r, windows, maintenanceWindowsTable(windows))
+}
+
+// handleGetMaintenanceWindow returns a specific maintenance window
+func (s *ComplianceServer) handleGetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+
+	window, err := s.requestDB(r).GetMaintenanceWindow(id)
+	if err != nil {
+		s.sendMaintenanceWindowError(w, err, "Failed to retrieve maintenance window")
+		return
+	}
+
+	s.respond(w, r, window, nil)
+}
+
+// handleCreateMaintenanceWindow creates a new maintenance window
+func (s *ComplianceServer) handleCreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window api.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateMaintenanceWindow(&window); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	window.CreatedBy = "system"
+	if user := requestUser(r); user != nil {
+		window.CreatedBy = user.Username
+	}
+
+	if err := s.requestDB(r).CreateMaintenanceWindow(&window); err != nil {
+		s.logger.Error("Failed to create maintenance window", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create maintenance window")
+		return
+	}
+
+	noteAdminChange(r, strconv.Itoa(window.ID), nil, window)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.MaintenanceWindowCreatedResponse{
+		Status:  "success",
+		Message: "Maintenance window created successfully",
+		ID:      window.ID,
+	})
+}
+
+// handleUpdateMaintenanceWindow replaces an existing maintenance window
+func (s *ComplianceServer) handleUpdateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+
+	var window api.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateMaintenanceWindow(&window); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before, err := s.requestDB(r).GetMaintenanceWindow(id)
+	if err != nil {
+		s.sendMaintenanceWindowError(w, err, "Failed to update maintenance window")
+		return
+	}
+	if err := s.requestDB(r).UpdateMaintenanceWindow(id, &window); err != nil {
+		s.sendMaintenanceWindowError(w, err, "Failed to update maintenance window")
+		return
+	}
+	noteAdminChange(r, strconv.Itoa(id), before, window)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Maintenance window updated successfully",
+	})
+}
+
+// handleDeleteMaintenanceWindow deletes a maintenance window
+func (s *ComplianceServer) handleDeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.maintenanceWindowID(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := s.requestDB(r).GetMaintenanceWindow(id)
+	if err != nil {
+		s.sendMaintenanceWindowError(w, err, "Failed to delete maintenance window")
+		return
+	}
+	if err := s.requestDB(r).DeleteMaintenanceWindow(id); err != nil {
+		s.sendMaintenanceWindowError(w, err, "Failed to delete maintenance window")
+		return
+	}
+	noteAdminChange(r, strconv.Itoa(id), before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Maintenance window deleted successfully",
+	})
+}
+
+// maintenanceWindowID parses the {window_id} path parameter
+func (s *ComplianceServer) maintenanceWindowID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("window_id"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid maintenance window ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// sendMaintenanceWindowError maps database errors to 404 or 500
+func (s *ComplianceServer) sendMaintenanceWindowError(w http.ResponseWriter, err error, message string) {
+	if err.Error() == "maintenance window not found" {
+		s.sendError(w, http.StatusNotFound, "Maintenance window not found")
+		return
+	}
+	s.logger.Error(message, "error", err)
+	s.sendError(w, http.StatusInternalServerError, message)
+}
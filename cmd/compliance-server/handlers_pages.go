@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"path"
+)
+
+// handleLoginPage serves the login page
+func (s *ComplianceServer) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	html, err := assets.ReadFile(path.Join(templatesDir, "login.html"))
+	if err != nil {
+		s.logger.Error("Failed to read login.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Login page not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(html)
+}
+
+// handleAcceptInvitePage serves the page invited users choose their
+// password on
+func (s *ComplianceServer) handleAcceptInvitePage(w http.ResponseWriter, r *http.Request) {
+	html, err := assets.ReadFile(path.Join(templatesDir, "accept-invite.html"))
+	if err != nil {
+		s.logger.Error("Failed to read accept-invite.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Invitation page not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	// Keep the token in the address out of Referer headers
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(html)
+}
+
+// handleResetPasswordPage serves the page users request a password reset
+// link on and choose a new password with it
+func (s *ComplianceServer) handleResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	html, err := assets.ReadFile(path.Join(templatesDir, "reset-password.html"))
+	if err != nil {
+		s.logger.Error("Failed to read reset-password.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Password reset page not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	// Keep the token in the address out of Referer headers
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(html)
+}
+
+// handleDashboard serves the web dashboard
+func (s *ComplianceServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	// Read dashboard HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "dashboard.html"))
+	if err != nil {
+		s.logger.Error("Failed to read dashboard.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Dashboard not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// handleClientsPage serves the clients page
+func (s *ComplianceServer) handleClientsPage(w http.ResponseWriter, r *http.Request) {
+	// Read clients HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "clients.html"))
+	if err != nil {
+		s.logger.Error("Failed to read clients.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Clients page not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// handleSettings serves the settings page
+func (s *ComplianceServer) handleSettings(w http.ResponseWriter, r *http.Request) {
+	// Read settings HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "settings.html"))
+	if err != nil {
+		s.logger.Error("Failed to read settings.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Settings not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// handleAboutPage serves the about page
+func (s *ComplianceServer) handleAboutPage(w http.ResponseWriter, r *http.Request) {
+	// Read about HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "about.html"))
+	if err != nil {
+		s.logger.Error("Failed to read about.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "About page not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+func (s *ComplianceServer) handlePoliciesPage(w http.ResponseWriter, r *http.Request) {
+	// Read policies HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "policies.html"))
+	if err != nil {
+		s.logger.Error("Failed to read policies.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Policies page not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// handleClientDetailPage serves the client detail HTML page
+func (s *ComplianceServer) handleClientDetailPage(w http.ResponseWriter, r *http.Request) {
+	// Read client detail HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "client-detail.html"))
+	if err != nil {
+		s.logger.Error("Failed to read client-detail.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Client detail page not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// handleSubmissionDetailPage serves the submission detail HTML page
+func (s *ComplianceServer) handleSubmissionDetailPage(w http.ResponseWriter, r *http.Request) {
+	// Read submission detail HTML file
+	html, err := assets.ReadFile(path.Join(templatesDir, "submission-detail.html"))
+	if err != nil {
+		s.logger.Error("Failed to read submission-detail.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Submission detail page not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
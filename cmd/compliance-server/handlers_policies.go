@@ -0,0 +1,510 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleListPolicies returns all policies
+func (s *ComplianceServer) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := s.requestDB(r).ListPolicies()
+	if err != nil {
+		s.logger.Error("Failed to list policies", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policies")
+		return
+	}
+	if policies == nil {
+		policies = []Policy{}
+	}
+
+	// No Last-Modified: deleting a policy or changing its owner leaves
+	// updated_at as it was
+	s.respondCached(w, r, policies, policiesTable(policies), time.Time{})
+}
+
+// handleGetPolicy returns a specific policy
+func (s *ComplianceServer) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	policy, err := s.requestDB(r).GetPolicy(policyID)
+	if err != nil {
+		s.logger.Error("Failed to get policy", "error", err, "policy_id", policyID)
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+		}
+		return
+	}
+
+	// Owner changes leave updated_at as it was
+	s.respondCached(w, r, policy, nil, time.Time{})
+}
+
+// handleDownloadPolicy returns the report configuration of an active policy
+// as a JSON file for clients to run. The SHA-256 of the configuration is the
+// ETag, so a client sending the hash of its copy in If-None-Match gets 304
+// Not Modified while it is current. The configuration is signed by its
+// publisher or, failing that, the server (see policyDownloadSignature).
+// Clients naming themselves in X-Client-ID that are in the cohort of a
+// staged rollout get its candidate version instead.
+func (s *ComplianceServer) handleDownloadPolicy(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	policy, err := s.requestDB(r).GetPolicy(policyID)
+	if err != nil {
+		s.logger.Error("Failed to get policy", "error", err, "policy_id", policyID)
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+		}
+		return
+	}
+
+	// Inactive and draft policies are not distributed to clients
+	if policy.Status != "active" {
+		s.sendError(w, http.StatusNotFound, "Policy is not active")
+		return
+	}
+	policy = s.rolloutPolicy(policy, r.Header.Get(api.HeaderClientID))
+
+	data := []byte(policy.PolicyData)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	etag := `"` + hash + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", api.HeaderClientID)
+	w.Header().Set(api.HeaderPolicyVersion, policy.Version)
+	w.Header().Set(api.HeaderPolicySHA256, hash)
+	if signature := s.policyDownloadSignature(policy); signature != "" {
+		w.Header().Set(api.HeaderPolicySignature, signature)
+	}
+
+	if notModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", policy.PolicyID+".json"))
+	w.Write(data)
+}
+
+// handleCreatePolicy creates a new policy
+func (s *ComplianceServer) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate required fields
+	if policy.PolicyID == "" || policy.Name == "" || policy.PolicyData == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing required fields: policy_id, name, policy_data")
+		return
+	}
+	if err := checkPolicyData(policy.PolicyData); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkPolicyRetention(policy.RetentionDays); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkPolicySignature(policy.Signature); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Set default status if not provided
+	if policy.Status == "" {
+		policy.Status = "active"
+	}
+
+	// Provenance is only recorded by pack imports
+	policy.Provenance = nil
+
+	owner, ownerTeam, ok := s.checkPolicyOwners(w, policy.Owner, policy.OwnerTeam)
+	if !ok {
+		return
+	}
+	policy.Owner, policy.OwnerTeam = owner, ownerTeam
+
+	// Users own the policies they create unless they name other owners,
+	// which must still leave them able to edit it (admins excepted)
+	user := requestUser(r)
+	if user != nil && policy.Owner == "" && policy.OwnerTeam == "" {
+		policy.Owner = user.Username
+	}
+	if !canEditPolicy(user, &policy) {
+		s.sendError(w, http.StatusForbidden, "Policies must be owned by you or your team")
+		return
+	}
+
+	if err := s.requestDB(r).CreatePolicy(&policy); err != nil {
+		s.logger.Error("Failed to create policy", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create policy")
+		return
+	}
+
+	s.logger.Info("Policy created", "policy_id", policy.PolicyID, "owner", policy.Owner, "owner_team", policy.OwnerTeam)
+	noteAdminChange(r, policy.PolicyID, nil, policy)
+	s.publishPolicyUpdated(r, policy.PolicyID, "created")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.PolicyCreatedResponse{
+		Status:   "success",
+		Message:  "Policy created successfully",
+		PolicyID: policy.PolicyID,
+	})
+}
+
+// handleUpdatePolicy updates an existing policy. Only its owners and admins
+// may change it; the owners themselves are changed with handleSetPolicyOwner.
+// The request replaces the publisher signature too, so changed policy data
+// must be signed again. Changed policy data must pass validatePolicyData;
+// policies stored before it was checked can still be updated unchanged.
+func (s *ComplianceServer) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	var policy Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := checkPolicySignature(policy.Signature); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkPolicyRetention(policy.RetentionDays); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before := s.authorizePolicyEdit(w, r, policyID)
+	if before == nil {
+		return
+	}
+	if policy.PolicyData != before.PolicyData {
+		if err := checkPolicyData(policy.PolicyData); err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	policy.Provenance = nil
+
+	if err := s.requestDB(r).UpdatePolicy(policyID, &policy); err != nil {
+		s.logger.Error("Failed to update policy", "error", err, "policy_id", policyID)
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to update policy")
+		}
+		return
+	}
+
+	s.logger.Info("Policy updated", "policy_id", policyID)
+	if after, err := s.requestDB(r).GetPolicy(policyID); err == nil {
+		noteAdminChange(r, policyID, before, after)
+	} else {
+		noteAdminChange(r, policyID, before, nil)
+	}
+	s.publishPolicyUpdated(r, policyID, "updated")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Policy updated successfully",
+	})
+}
+
+// handleDeletePolicy deletes a policy. Only its owners and admins may delete it.
+func (s *ComplianceServer) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	before := s.authorizePolicyEdit(w, r, policyID)
+	if before == nil {
+		return
+	}
+
+	if err := s.requestDB(r).DeletePolicy(policyID); err != nil {
+		s.logger.Error("Failed to delete policy", "error", err, "policy_id", policyID)
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to delete policy")
+		}
+		return
+	}
+
+	s.logger.Info("Policy deleted", "policy_id", policyID)
+	noteAdminChange(r, policyID, before, nil)
+	s.publishPolicyUpdated(r, policyID, "deleted")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Policy deleted successfully",
+	})
+}
+
+// handleSetPolicyOwner replaces the user and team owning a policy
+// (PUT /api/v1/policies/{policy_id}/owner). Its current owners and admins
+// may hand it over.
+func (s *ComplianceServer) handleSetPolicyOwner(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	var request api.PolicyOwnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	owner, ownerTeam, ok := s.checkPolicyOwners(w, request.Owner, request.OwnerTeam)
+	if !ok {
+		return
+	}
+
+	before := s.authorizePolicyEdit(w, r, policyID)
+	if before == nil {
+		return
+	}
+
+	if err := s.requestDB(r).SetPolicyOwner(policyID, owner, ownerTeam); err != nil {
+		s.logger.Error("Failed to update policy owner", "error", err, "policy_id", policyID)
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to update policy owner")
+		}
+		return
+	}
+
+	s.logger.Info("Policy owner updated", "policy_id", policyID, "owner", owner, "owner_team", ownerTeam)
+	noteAdminChange(r, policyID,
+		api.PolicyOwnerRequest{Owner: before.Owner, OwnerTeam: before.OwnerTeam},
+		api.PolicyOwnerRequest{Owner: owner, OwnerTeam: ownerTeam})
+	s.publishPolicyUpdated(r, policyID, "owner")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Policy owner updated successfully",
+	})
+}
+
+// handleImportPolicies imports policies from configs/reports directory. The
+// importing user owns the imported policies.
+func (s *ComplianceServer) handleImportPolicies(w http.ResponseWriter, r *http.Request) {
+	// Look for report files in configs/reports directory
+	reportsDir := "configs/reports"
+	files, err := filepath.Glob(filepath.Join(reportsDir, "*.json"))
+	if err != nil {
+		s.logger.Error("Failed to list report files", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list report files")
+		return
+	}
+
+	imported := 0
+	skipped := 0
+	errors := []string{}
+
+	owner := ""
+	if user := requestUser(r); user != nil {
+		owner = user.Username
+	}
+
+	for _, file := range files {
+		// Read the report file
+		data, err := os.ReadFile(file)
+		if err != nil {
+			s.logger.Warn("Failed to read report file", "file", file, "error", err)
+			errors = append(errors, fmt.Sprintf("Failed to read %s: %v", filepath.Base(file), err))
+			continue
+		}
+
+		// Generate policy_id from filename
+		filename := filepath.Base(file)
+		policyID := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+		policy, err := reportPolicy(policyID, data)
+		if err != nil {
+			s.logger.Warn("Failed to parse report file", "file", file, "error", err)
+			errors = append(errors, fmt.Sprintf("Failed to parse %s: %v", filepath.Base(file), err))
+			continue
+		}
+
+		// Check if policy already exists
+		existing, _ := s.requestDB(r).GetPolicy(policyID)
+		if existing != nil {
+			s.logger.Info("Policy already exists, skipping", "policy_id", policyID)
+			skipped++
+			continue
+		}
+
+		// A publisher signature may sit beside the file (NIST.json.sig)
+		signature := ""
+		if sig, err := os.ReadFile(file + ".sig"); err == nil {
+			signature = strings.TrimSpace(string(sig))
+			if err := checkPolicySignature(signature); err != nil {
+				errors = append(errors, fmt.Sprintf("Invalid signature for %s: %v", filepath.Base(file), err))
+				continue
+			}
+		}
+
+		// Create policy
+		policy.Owner = owner
+		policy.Signature = signature
+
+		if err := s.requestDB(r).CreatePolicy(&policy); err != nil {
+			s.logger.Error("Failed to import policy", "policy_id", policyID, "error", err)
+			errors = append(errors, fmt.Sprintf("Failed to import %s: %v", policyID, err))
+			continue
+		}
+
+		s.logger.Info("Policy imported", "policy_id", policyID, "name", policy.Name)
+		imported++
+	}
+
+	resp := api.PolicyImportResponse{
+		Status:   "success",
+		Message:  fmt.Sprintf("Imported %d policies, skipped %d existing", imported, skipped),
+		Imported: imported,
+		Skipped:  skipped,
+		Errors:   errors,
+	}
+	noteAdminChange(r, reportsDir, nil, resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// reportPolicy builds an active policy from a report configuration, taking
+// its name, version and other details from the report's metadata
+func reportPolicy(policyID string, data []byte) (Policy, error) {
+	var reportConfig struct {
+		Version  string `json:"version"`
+		Metadata struct {
+			ReportTitle   string `json:"report_title"`
+			ReportVersion string `json:"report_version"`
+			Author        string `json:"author"`
+			Description   string `json:"description"`
+			Category      string `json:"category"`
+			Compliance    string `json:"compliance"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &reportConfig); err != nil {
+		return Policy{}, err
+	}
+
+	// Extract framework from compliance field (e.g., "NIST 800-171 Rev 2" -> "NIST")
+	framework := ""
+	if reportConfig.Metadata.Compliance != "" {
+		parts := strings.Split(reportConfig.Metadata.Compliance, " ")
+		if len(parts) > 0 {
+			framework = parts[0]
+		}
+	}
+
+	return Policy{
+		PolicyID:    policyID,
+		Name:        reportConfig.Metadata.ReportTitle,
+		Description: reportConfig.Metadata.Description,
+		Framework:   framework,
+		Version:     reportConfig.Metadata.ReportVersion,
+		Category:    reportConfig.Metadata.Category,
+		Author:      reportConfig.Metadata.Author,
+		Status:      "active",
+		PolicyData:  string(data),
+	}, nil
+}
+
+// handleSimulatePolicy projects the impact of a policy on the latest stored
+// evidence of clients without publishing it (POST /api/v1/policies/simulate)
+func (s *ComplianceServer) handleSimulatePolicy(w http.ResponseWriter, r *http.Request) {
+	var request api.PolicySimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if (request.PolicyID == "") == (request.PolicyData == "") {
+		s.sendError(w, http.StatusBadRequest, "Exactly one of policy_id or policy_data is required")
+		return
+	}
+
+	policyData := request.PolicyData
+	if request.PolicyID != "" {
+		policy, err := s.requestDB(r).GetPolicy(request.PolicyID)
+		if err != nil {
+			if err.Error() == "policy not found" {
+				s.sendError(w, http.StatusNotFound, "Policy not found")
+			} else {
+				s.logger.Error("Failed to get policy", "error", err, "policy_id", request.PolicyID)
+				s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+			}
+			return
+		}
+		policyData = policy.PolicyData
+	}
+
+	policy, err := parseSimulationPolicy(policyData)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	submissions, err := s.scopedDB(r).LatestSubmissions(policy.Metadata.ReportTitle)
+	if err != nil {
+		s.logger.Error("Failed to load evidence for simulation", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load evidence")
+		return
+	}
+
+	selected := make(map[string]bool, len(request.ClientIDs))
+	for _, clientID := range request.ClientIDs {
+		selected[clientID] = true
+	}
+
+	response := api.PolicySimulationResponse{
+		ReportType: policy.Metadata.ReportTitle,
+		Clients:    []api.ClientSimulation{},
+	}
+	for _, submission := range submissions {
+		if len(selected) > 0 && !selected[submission.ClientID] {
+			continue
+		}
+
+		sim := simulatePolicy(policy, submission)
+		response.ClientsEvaluated++
+		response.NewlyFailing += len(sim.NewlyFailing)
+		response.NewlyPassing += len(sim.NewlyPassing)
+		if len(sim.NewlyFailing) > 0 {
+			response.ClientsAffected++
+		}
+		response.Clients = append(response.Clients, sim)
+	}
+
+	s.logger.Info("Policy simulated",
+		"report_type", response.ReportType,
+		"clients", response.ClientsEvaluated,
+		"newly_failing", response.NewlyFailing,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleGetConfig returns current server configuration (sanitized)
+func (s *ComplianceServer) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	config := s.config()
+
+	// Create sanitized config (don't expose sensitive data like API keys)
+	configResponse := api.ConfigResponse{
+		Server: api.ServerConfigInfo{
+			Host: config.Server.Host,
+			Port: config.Server.Port,
+			TLS: api.TLSConfigInfo{
+				Enabled:  config.Server.TLS.Enabled,
+				CertFile: config.Server.TLS.CertFile,
+				KeyFile:  config.Server.TLS.KeyFile,
+			},
+		},
+		Database: api.DatabaseConfigInfo{
+			Type: config.Database.Type,
+			Host: config.Database.Host,
+			Port: config.Database.Port,
+			Name: config.Database.Name,
+		},
+		Auth: api.AuthConfigInfo{
+			Enabled:    config.Auth.Enabled,
+			RequireKey: config.Auth.RequireKey,
+			KeyCount:   len(config.Auth.APIKeys),
+		},
+		Dashboard: api.DashboardConfigInfo{
+			Enabled: config.Dashboard.Enabled,
+			Path:    config.Dashboard.Path,
+		},
+		Logging: api.LoggingConfigInfo{
+			Level:  config.Logging.Level,
+			Format: config.Logging.Format,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(configResponse)
+}
+
+// handleUpdateConfig updates server configuration
+func (s *ComplianceServer) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// Apply updates (limited to non-sensitive settings for safety)
+	// Note: This updates runtime config only, not the YAML file
+	// TODO: Add YAML file persistence if needed
+
+	if logging, ok := updates["logging"].(map[string]interface{}); ok {
+		if level, ok := logging["level"].(string); ok {
+			s.updateConfig(func(c *ServerConfig) error {
+				noteAdminChange(r, "logging.level", c.Logging.Level, level)
+				c.Logging.Level = level
+				return nil
+			})
+			s.logger.Info("Logging level updated", "new_level", level)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Configuration updated (runtime only)",
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRuntimeConfigUpdates tests that settings changed by concurrent
+// requests are neither lost nor torn for requests reading them. Run with
+// -race to check the snapshot handling.
+func TestRuntimeConfigUpdates(t *testing.T) {
+	s := newTestServer()
+	s.config().Auth.APIKeys = []string{"initial-key-0001"}
+	before := s.config()
+
+	post := func(h http.HandlerFunc, body string) int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return rec.Code
+	}
+	get := func(h http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if code := post(s.handleAddAPIKey, fmt.Sprintf(`{"key":"added-key-%04d"}`, i)); code != http.StatusOK {
+				t.Errorf("add key %d = %d, want 200", i, code)
+			}
+			post(s.handleUpdateConfig, fmt.Sprintf(`{"logging":{"level":"level-%d"}}`, i))
+			post(s.handleUpdateLoginMessage, fmt.Sprintf(`{"message":"message %d"}`, i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			for _, h := range []http.HandlerFunc{s.handleGetConfig, s.handleAPIKeys, s.handleGetSession, s.handleGetLoginMessage} {
+				if code := get(h); code != http.StatusOK {
+					t.Errorf("read during updates = %d, want 200", code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if keys := s.config().Auth.APIKeys; len(keys) != writers+1 {
+		t.Errorf("API keys after concurrent adds = %d, want %d", len(keys), writers+1)
+	}
+	if !strings.HasPrefix(s.config().Logging.Level, "level-") || !strings.HasPrefix(s.config().Dashboard.LoginMessage, "message ") {
+		t.Errorf("logging level %q, login message %q; want the last updates", s.config().Logging.Level, s.config().Dashboard.LoginMessage)
+	}
+	if len(before.Auth.APIKeys) != 1 || before.Logging.Level != "" || before.Dashboard.LoginMessage != "" {
+		t.Errorf("snapshot taken before the updates changed: %+v", before.Auth)
+	}
+
+	// A duplicate is refused without publishing a change
+	current := s.config()
+	if code := post(s.handleAddAPIKey, `{"key":"added-key-0000"}`); code != http.StatusConflict {
+		t.Errorf("duplicate key = %d, want 409", code)
+	}
+	if s.config() != current {
+		t.Error("refused update replaced the configuration")
+	}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if code := post(s.handleDeleteAPIKey, fmt.Sprintf(`{"key":"added-key-%04d"}`, i)); code != http.StatusOK {
+				t.Errorf("delete key %d = %d, want 200", i, code)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if keys := s.config().Auth.APIKeys; len(keys) != 1 || keys[0] != "initial-key-0001" {
+		t.Errorf("API keys after concurrent deletes = %v, want the initial key", keys)
+	}
+	if code := post(s.handleDeleteAPIKey, `{"key":"added-key-0000"}`); code != http.StatusNotFound {
+		t.Errorf("delete of a removed key = %d, want 404", code)
+	}
+}
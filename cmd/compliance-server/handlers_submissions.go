@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// handleSubmit handles compliance submission requests
+func (s *ComplianceServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// Parse submission
+	var submission api.ComplianceSubmission
+	if !s.decodeSubmission(w, r, &submission) {
+		return
+	}
+	if submission.Delta != nil {
+		s.sendError(w, http.StatusBadRequest, "Delta submissions go to /api/v1/compliance/submit-delta")
+		return
+	}
+
+	// Validate submission
+	if err := submission.Validate(); err != nil {
+		s.logger.Warn("Submission validation failed", "error", err)
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.storeSubmission(w, &submission, "full")
+}
+
+// decodeSubmission decodes a submission body of at most
+// api.MaxSubmissionBytes. When it cannot, the client has been answered and
+// it returns false.
+func (s *ComplianceServer) decodeSubmission(w http.ResponseWriter, r *http.Request, submission *api.ComplianceSubmission) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, api.MaxSubmissionBytes)).Decode(submission)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.logger.Warn("Submission body too large", "limit", api.MaxSubmissionBytes)
+		s.sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Submission is larger than %d MB", api.MaxSubmissionBytes>>20))
+		return false
+	}
+	s.logger.Warn("Invalid submission JSON", "error", err)
+	s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+	return false
+}
+
+// storeSubmission stores a validated submission, full or rebuilt from a
+// delta (kind names which for metrics), and answers the client
+func (s *ComplianceServer) storeSubmission(w http.ResponseWriter, submission *api.ComplianceSubmission, kind string) {
+	if s.refuseUnsupportedVersion(w, submission) {
+		return
+	}
+	if s.enforceSubmissionQuota(w, submission) {
+		return
+	}
+
+	metadata, err := normalizeMetadata(s.config().Metadata.Fields, submission.Metadata)
+	if err != nil {
+		s.logger.Warn("Submission metadata rejected", "error", err, "client_id", submission.ClientID)
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	submission.Metadata = metadata
+
+	s.logger.Info("Received compliance submission",
+		"submission_id", submission.SubmissionID,
+		"client_id", submission.ClientID,
+		"hostname", submission.Hostname,
+		"report_type", submission.ReportType,
+	)
+
+	// Update/create client first (required for foreign key constraint)
+	created, err := s.db.UpdateClientLastSeen(submission.ClientID, submission.Hostname, &submission.SystemInfo)
+	if err != nil {
+		s.logger.Error("Failed to register/update client", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to register client")
+		return
+	}
+	if created {
+		s.events.publish(topicClientRegistered, clientRegistered{
+			ClientID:  submission.ClientID,
+			Hostname:  submission.Hostname,
+			OSVersion: submission.SystemInfo.OSVersion,
+			Source:    "submission",
+		})
+	}
+	s.reconcileClientIdentity(submission.ClientID, submission.Hostname, &submission.SystemInfo)
+
+	// Flag submissions collected while the client is under maintenance
+	submission.DuringMaintenance = false
+	if window := s.activeMaintenanceWindow(submission.ClientID, submission.Hostname, time.Now()); window != nil {
+		submission.DuringMaintenance = true
+		s.logger.Info("Submission collected during maintenance",
+			"submission_id", submission.SubmissionID,
+			"window", window.Name,
+		)
+	}
+
+	// Keep the full check results for deltas before sampling drops details
+	state := newSubmissionState(submission)
+
+	// Past the day's evidence sample only the summary is kept
+	s.sampleSubmission(submission)
+
+	// Store submission in database (after client exists)
+	if err := s.db.SaveSubmission(submission); err != nil {
+		s.logger.Error("Failed to save submission", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save submission")
+		return
+	}
+	serverMetrics.submissions.Inc(kind)
+
+	// Without the state the client's next delta is refused and it sends
+	// the full submission
+	if err := s.db.SaveSubmissionState(submission.ClientID, submission.ReportType, state); err != nil {
+		s.logger.Warn("Failed to save submission state", "error", err, "client_id", submission.ClientID)
+	}
+
+	// Consumers forward it to the SIEM and resolve its missed run alerts
+	s.events.publish(topicSubmissionAccepted, newSubmissionAccepted(submission, kind))
+
+	// Send response
+	response := api.SubmissionResponse{
+		SubmissionID: submission.SubmissionID,
+		Status:       "accepted",
+		Message:      "Submission received and stored successfully",
+		ReceivedAt:   time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleStatus handles submission status requests
+func (s *ComplianceServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	submissionID := r.PathValue("submission_id")
+
+	// Get submission from database
+	submission, err := s.scopedDB(r).GetSubmission(submissionID)
+	if err != nil {
+		s.logger.Error("Failed to get submission", "error", err)
+		s.sendError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+
+	// Create summary
+	summary := api.SubmissionSummary{
+		SubmissionID:  submission.SubmissionID,
+		ClientID:      submission.ClientID,
+		Hostname:      submission.Hostname,
+		Timestamp:     submission.Timestamp,
+		ReportType:    submission.ReportType,
+		OverallStatus: submission.Compliance.OverallStatus,
+		PassedChecks:  submission.Compliance.PassedChecks,
+		FailedChecks:  submission.Compliance.FailedChecks,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleSubmissionDetail handles submission detail requests (API endpoint)
+func (s *ComplianceServer) handleSubmissionDetail(w http.ResponseWriter, r *http.Request) {
+	submissionID := r.PathValue("submission_id")
+
+	// Get submission from database
+	submission, err := s.scopedDB(r).GetSubmission(submissionID)
+	if err != nil {
+		if err.Error() == "submission not found" {
+			if archive, err := s.scopedDB(r).FindArchivedSubmission(submissionID); err == nil && archive != nil {
+				s.sendError(w, http.StatusGone, fmt.Sprintf("Submission was archived to %s on %s",
+					archive.Path, archive.CreatedAt.UTC().Format(time.RFC3339)))
+				return
+			}
+		}
+		s.logger.Error("Failed to get submission", "error", err, "submission_id", submissionID)
+		s.sendError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+
+	s.respond(w, r, submission, nil)
+}
+
+// handleClearAllSubmissions clears all submission history from all clients,
+// or from the clients in an operator's scope
+func (s *ComplianceServer) handleClearAllSubmissions(w http.ResponseWriter, r *http.Request) {
+	// Clear all submissions
+	deletedCount, err := s.scopedDB(r).ClearAllSubmissions()
+	if err != nil {
+		s.logger.Error("Failed to clear all submissions", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to clear all submissions")
+		return
+	}
+
+	s.logger.Info("All submissions cleared", "deleted_count", deletedCount)
+	if deletedCount > 0 {
+		go s.removeOrphanAttachments()
+	}
+	noteAdminChange(r, "", map[string]int64{"submissions": deletedCount}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.DeleteCountResponse{
+		Status:       "success",
+		Message:      fmt.Sprintf("Cleared %d submissions from all clients", deletedCount),
+		DeletedCount: deletedCount,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestHandleSubmitRejects tests submission bodies rejected before anything
+// is stored
+func TestHandleSubmitRejects(t *testing.T) {
+	s := newTestServer()
+	checks := strings.Repeat(`{"name":"q","status":"pass"},`, api.MaxQueriesPerSubmission)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid JSON", "/api/v1/compliance/submit", `{"client_id":`, http.StatusBadRequest},
+		{"too large", "/api/v1/compliance/submit", `{"hostname":"` + strings.Repeat("x", api.MaxSubmissionBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		{"too many checks", "/api/v1/compliance/submit", fmt.Sprintf(
+			`{"client_id":"c","hostname":"h","report_type":"r","timestamp":"2025-01-02T03:04:05Z","compliance":{"queries":[%s{"name":"last"}]}}`, checks),
+			http.StatusBadRequest},
+		{"hostname too long", "/api/v1/compliance/submit", fmt.Sprintf(
+			`{"client_id":"c","hostname":"%s","report_type":"r","timestamp":"2025-01-02T03:04:05Z","compliance":{"queries":[{"name":"q"}]}}`,
+			strings.Repeat("h", api.MaxIdentifierLength+1)), http.StatusBadRequest},
+		{"delta too large", "/api/v1/compliance/submit-delta", `{"hostname":"` + strings.Repeat("x", api.MaxSubmissionBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if strings.HasSuffix(tt.path, "-delta") {
+				s.handleSubmitDelta(rec, req)
+			} else {
+				s.handleSubmit(rec, req)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
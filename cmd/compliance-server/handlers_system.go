@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/buildinfo"
+)
+
+// handleRoot handles root path requests
+func (s *ComplianceServer) handleRoot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.ServerInfoResponse{
+		Service: "Compliance Toolkit Server",
+		Version: version,
+		Status:  "running",
+	})
+}
+
+// handleHealth handles health check requests
+func (s *ComplianceServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	// Check database connection
+	if err := s.requestDB(r).Ping(); err != nil {
+		s.logger.Error("Health check failed", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(api.HealthResponse{
+			Status: "unhealthy",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	build := buildinfo.Get(version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.HealthResponse{
+		Status:      "healthy",
+		Version:     version,
+		Maintenance: s.maintenanceMode().Enabled,
+		Build:       &build,
+	})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"compliancetoolkit/pkg/api"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// handleUsers lists all users
+func (s *ComplianceServer) handleUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.requestDB(r).ListUsers()
+	if err != nil {
+		s.logger.Error("Failed to list users", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+
+	userInfos := make([]api.UserInfo, 0, len(users))
+	for _, user := range users {
+		userInfos = append(userInfos, user.Info())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userInfos)
+}
+
+// handleCreateUser creates a new user
+func (s *ComplianceServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string           `json:"username"`
+		Password string           `json:"password"`
+		Role     string           `json:"role"`
+		Team     string           `json:"team"`  // Optional; members edit the team's policies
+		Scope    *api.ClientScope `json:"scope"` // Required for operators
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// Validate inputs
+	if request.Username == "" || request.Password == "" || request.Role == "" {
+		s.sendError(w, http.StatusBadRequest, "Username, password, and role are required")
+		return
+	}
+
+	// Validate role
+	validRoles := map[string]bool{"admin": true, roleOperator: true, "viewer": true, "auditor": true}
+	if !validRoles[request.Role] {
+		s.sendError(w, http.StatusBadRequest, "Invalid role. Must be: admin, operator, viewer, or auditor")
+		return
+	}
+
+	scope, err := validateUserScope(request.Role, request.Scope)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	team, err := normalizeTeam(request.Team)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check if username already exists
+	exists, err := s.requestDB(r).UserExists(request.Username)
+	if err != nil {
+		s.logger.Error("Failed to check user existence", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if exists {
+		s.sendError(w, http.StatusConflict, "Username already exists")
+		return
+	}
+
+	// Hash password
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	// Create user
+	if err := s.requestDB(r).CreateUser(request.Username, string(passwordHash), request.Role, team, scope); err != nil {
+		s.logger.Error("Failed to create user", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	s.logger.Info("User created", "username", request.Username, "role", request.Role)
+	created := User{Username: request.Username, Role: request.Role, Team: team}
+	if scope != nil {
+		created.Scope = *scope
+	}
+	noteAdminChange(r, request.Username, nil, created.Info())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "User created successfully",
+	})
+}
+
+// handleDeleteUser deletes a user
+func (s *ComplianceServer) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string `json:"username"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if request.Username == "" {
+		s.sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	var before interface{}
+	if user, err := s.requestDB(r).GetUser(request.Username); err == nil {
+		before = user.Info()
+	}
+
+	// Delete user
+	if err := s.requestDB(r).DeleteUser(request.Username); err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to delete user", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to delete user")
+		return
+	}
+
+	s.logger.Info("User deleted", "username", request.Username)
+	noteAdminChange(r, request.Username, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "User deleted successfully",
+	})
+}
+
+// handleChangePassword changes a user's password
+func (s *ComplianceServer) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username    string `json:"username"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if request.Username == "" || request.NewPassword == "" {
+		s.sendError(w, http.StatusBadRequest, "Username and new password are required")
+		return
+	}
+
+	// Hash new password
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	// Update password
+	if err := s.requestDB(r).UpdateUserPassword(request.Username, string(passwordHash)); err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to update password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	s.logger.Info("User password changed", "username", request.Username)
+	// Password hashes are never recorded
+	noteAdminChange(r, request.Username, nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Password changed successfully",
+	})
+}
+
+// handleSetUserScope replaces the clients an operator can see
+// (POST /api/v1/users/scope)
+func (s *ComplianceServer) handleSetUserScope(w http.ResponseWriter, r *http.Request) {
+	var request api.UserScopeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if request.Username == "" {
+		s.sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	scope, err := validateUserScope(roleOperator, &request.Scope)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before, _ := s.requestDB(r).GetUser(request.Username)
+
+	if err := s.requestDB(r).SetUserScope(request.Username, *scope); err != nil {
+		if err.Error() == "operator not found" {
+			s.sendError(w, http.StatusNotFound, "No operator with that username")
+			return
+		}
+		s.logger.Error("Failed to update user scope", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update scope")
+		return
+	}
+	s.noteUserChange(r, before, func(u *User) { u.Scope = *scope })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Scope updated successfully",
+	})
+}
+
+// handleSetUserTeam sets or clears the team of a user (POST /api/v1/users/team)
+func (s *ComplianceServer) handleSetUserTeam(w http.ResponseWriter, r *http.Request) {
+	var request api.UserTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if request.Username == "" {
+		s.sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	team, err := normalizeTeam(request.Team)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before, _ := s.requestDB(r).GetUser(request.Username)
+
+	if err := s.requestDB(r).SetUserTeam(request.Username, team); err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to update user team", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update team")
+		return
+	}
+	s.noteUserChange(r, before, func(u *User) { u.Team = team })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Team updated successfully",
+	})
+}
+
+// noteUserChange describes a change to a user for the admin audit trail:
+// before as read ahead of the change, and with change applied after. A user
+// that could not be read is recorded without values.
+func (s *ComplianceServer) noteUserChange(r *http.Request, before *User, change func(*User)) {
+	if before == nil {
+		return
+	}
+	after := *before
+	change(&after)
+	noteAdminChange(r, before.Username, before.Info(), after.Info())
+}
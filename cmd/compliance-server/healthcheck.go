@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// healthcheckTimeout bounds the whole health check request
+const healthcheckTimeout = 5 * time.Second
+
+// healthcheckURL returns the health endpoint of the server configured in
+// settings. A server listening on all interfaces is reached over loopback.
+func healthcheckURL(settings ServerSettings) string {
+	host := settings.Host
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if settings.TLS.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/health", scheme, net.JoinHostPort(host, strconv.Itoa(settings.Port)))
+}
+
+// checkHealth asks the running server for its health, returning an error
+// unless it answers healthy
+func checkHealth(settings ServerSettings) error {
+	client := &http.Client{
+		Timeout: healthcheckTimeout,
+		Transport: &http.Transport{
+			// The server is its own peer here; its certificate is often
+			// self-signed or issued for a name other than the loopback address
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := client.Get(healthcheckURL(settings))
+	if err != nil {
+		return fmt.Errorf("server unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var health api.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return fmt.Errorf("invalid health response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || health.Status != "healthy" {
+		return fmt.Errorf("server is %s: %s", health.Status, health.Error)
+	}
+	return nil
+}
+
+// runHealthcheck runs the healthcheck command for container and supervisor
+// health probes, which need no HTTP client in the image. It returns false
+// when the server is not healthy.
+func runHealthcheck(config *ServerConfig) bool {
+	if err := checkHealth(config.Server); err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return false
+	}
+	fmt.Println("healthy")
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestHealthcheckURL tests the address the healthcheck command probes
+func TestHealthcheckURL(t *testing.T) {
+	tests := []struct {
+		settings ServerSettings
+		want     string
+	}{
+		{ServerSettings{Host: "0.0.0.0", Port: 8080}, "http://127.0.0.1:8080/api/v1/health"},
+		{ServerSettings{Host: "::", Port: 8080}, "http://127.0.0.1:8080/api/v1/health"},
+		{ServerSettings{Host: "10.0.0.5", Port: 8443, TLS: TLSSettings{Enabled: true}}, "https://10.0.0.5:8443/api/v1/health"},
+		{ServerSettings{Host: "::1", Port: 8080}, "http://[::1]:8080/api/v1/health"},
+	}
+
+	for _, tt := range tests {
+		if got := healthcheckURL(tt.settings); got != tt.want {
+			t.Errorf("healthcheckURL(%+v) = %q, want %q", tt.settings, got, tt.want)
+		}
+	}
+}
+
+// TestCheckHealth tests the healthcheck command against healthy, unhealthy
+// and stopped servers
+func TestCheckHealth(t *testing.T) {
+	status := http.StatusOK
+	health := api.HealthResponse{Status: "healthy"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(health)
+	}))
+	defer ts.Close()
+
+	host, portStr, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	settings := ServerSettings{Host: host, Port: port}
+
+	if err := checkHealth(settings); err != nil {
+		t.Errorf("checkHealth() healthy server error = %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	health = api.HealthResponse{Status: "unhealthy", Error: "connection refused"}
+	if err := checkHealth(settings); err == nil {
+		t.Error("checkHealth() unhealthy server error = nil, want error")
+	}
+
+	ts.Close()
+	if err := checkHealth(settings); err == nil {
+		t.Error("checkHealth() stopped server error = nil, want error")
+	}
+}
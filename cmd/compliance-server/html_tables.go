@@ -0,0 +1,106 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// tablePage renders tabular responses as HTML for browsers without
+// JavaScript
+var tablePage = template.Must(template.ParseFS(assets, path.Join(templatesDir, "table.html")))
+
+// htmlTable is implemented by tables with a caption and links for their HTML
+// form. Other tables are captioned with the request path.
+type htmlTable interface {
+	htmlCaption() string
+	// htmlLink returns the page a cell links to, or ""
+	htmlLink(row []string, column int) string
+}
+
+// tableCell is a cell of an HTML table
+type tableCell struct {
+	Text string
+	Link string
+}
+
+// renderHTMLTable writes table as an accessible, script-free HTML page with
+// links to the request's CSV and JSON forms
+func renderHTMLTable(w io.Writer, r *http.Request, table csvTable) error {
+	header := table.csvHeader()
+	headings := make([]string, len(header))
+	for i, column := range header {
+		headings[i] = strings.ReplaceAll(column, "_", " ")
+	}
+
+	caption := r.URL.Path
+	linked, _ := table.(htmlTable)
+	if linked != nil {
+		caption = linked.htmlCaption()
+	}
+
+	rows := [][]tableCell{}
+	for _, row := range table.csvRows() {
+		cells := make([]tableCell, len(row))
+		for i, text := range row {
+			cells[i].Text = text
+			if linked != nil {
+				cells[i].Link = linked.htmlLink(row, i)
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	return tablePage.Execute(w, map[string]interface{}{
+		"Caption": caption,
+		"Header":  headings,
+		"Rows":    rows,
+		"CSVURL":  withFormat(r.URL, "csv"),
+		"JSONURL": withFormat(r.URL, "json"),
+	})
+}
+
+// withFormat returns the path and query of u with ?format= set to format
+func withFormat(u *url.URL, format string) string {
+	query := u.Query()
+	query.Set("format", format)
+	return u.Path + "?" + query.Encode()
+}
+
+// clientSubmissionsPage returns the HTML table of a client's submissions
+func clientSubmissionsPage(clientID string) string {
+	return "/api/v1/clients/" + url.PathEscape(clientID) + "/submissions?format=html"
+}
+
+func (t clientsTable) htmlCaption() string { return "Clients" }
+
+// htmlLink links client IDs to the client's submissions
+func (t clientsTable) htmlLink(row []string, column int) string {
+	if column == 0 && row[0] != "" {
+		return clientSubmissionsPage(row[0])
+	}
+	return ""
+}
+
+func (t submissionsTable) htmlCaption() string { return "Submissions" }
+
+// htmlLink links client IDs to the client's submissions
+func (t submissionsTable) htmlLink(row []string, column int) string {
+	if column == 1 && row[1] != "" {
+		return clientSubmissionsPage(row[1])
+	}
+	return ""
+}
+
+func (t checkFailuresTable) htmlCaption() string { return "Clients Failing the Check" }
+
+// htmlLink links client IDs to the client's submissions
+func (t checkFailuresTable) htmlLink(row []string, column int) string {
+	if column == 0 && row[0] != "" {
+		return clientSubmissionsPage(row[0])
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestRenderHTMLTable tests that tables render with headers, escaped cells,
+// links to client submissions and download links keeping the query
+func TestRenderHTMLTable(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/clients?tag=pos&format=html", nil)
+	table := clientsTable{{ClientID: "client 1", Hostname: "<script>alert(1)</script>", Status: "active"}}
+
+	var buf bytes.Buffer
+	if err := renderHTMLTable(&buf, r, table); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"<caption>Clients (1)</caption>",
+		`<th scope="col">client id</th>`,
+		`<a href="/api/v1/clients/client%201/submissions?format=html">client 1</a>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`href="/api/v1/clients?format=csv&amp;tag=pos"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %s:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("page contains a script")
+	}
+}
+
+// TestRenderHTMLTableEmpty tests that an empty table says so and is
+// captioned with the request path when it has no caption of its own
+func TestRenderHTMLTableEmpty(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/alerts?format=html", nil)
+
+	var buf bytes.Buffer
+	if err := renderHTMLTable(&buf, r, alertsTable([]api.Alert{})); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<caption>/api/v1/alerts (0)</caption>", "No entries"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page does not contain %s:\n%s", want, buf.String())
+		}
+	}
+}
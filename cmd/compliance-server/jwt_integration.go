@@ -11,54 +11,59 @@ import (
 
 // initializeJWT initializes JWT authentication components
 func (s *ComplianceServer) initializeJWT() error {
-	if !s.config.Auth.JWT.Enabled {
+	if !s.config().Auth.JWT.Enabled {
 		s.logger.Info("JWT authentication is disabled")
 		return nil
 	}
 
 	s.logger.Info("Initializing JWT authentication...")
 
-	// Generate or load JWT secret key
-	secretKey := s.config.Auth.JWT.SecretKey
+	// Without a configured secret, every server shares one kept in the
+	// database so tokens survive restarts and work on any replica
+	secretKey := s.config().Auth.JWT.SecretKey
 	if secretKey == "" {
-		// Auto-generate secret key
+		var created bool
 		var err error
-		secretKey, err = auth.GenerateSecretKey()
+		secretKey, created, err = s.db.SharedSecret(secretJWT, auth.GenerateSecretKey)
 		if err != nil {
-			return fmt.Errorf("failed to generate JWT secret key: %w", err)
+			return fmt.Errorf("failed to load JWT secret key: %w", err)
 		}
 
 		// Store in config for this session
-		s.config.Auth.JWT.SecretKey = secretKey
+		s.updateConfig(func(c *ServerConfig) error {
+			c.Auth.JWT.SecretKey = secretKey
+			return nil
+		})
 
-		s.logger.Warn("Auto-generated JWT secret key",
-			"warning", "Store this in server.yaml to persist across restarts",
-			"secret_prefix", secretKey[:16]+"...",
-		)
+		if created {
+			s.logger.Info("Generated JWT secret key and stored it in the database")
+		}
 	}
 
 	// Create JWT config
 	s.jwtConfig = auth.NewJWTConfig(secretKey)
 
 	// Apply custom lifetimes if configured
-	if s.config.Auth.JWT.AccessTokenLifetime > 0 {
-		s.jwtConfig.AccessTokenLifetime = time.Duration(s.config.Auth.JWT.AccessTokenLifetime) * time.Minute
+	jwt := s.config().Auth.JWT
+	if jwt.AccessTokenLifetime > 0 {
+		s.jwtConfig.AccessTokenLifetime = time.Duration(jwt.AccessTokenLifetime) * time.Minute
 	}
-	if s.config.Auth.JWT.RefreshTokenLifetime > 0 {
-		s.jwtConfig.RefreshTokenLifetime = time.Duration(s.config.Auth.JWT.RefreshTokenLifetime) * 24 * time.Hour
+	if jwt.RefreshTokenLifetime > 0 {
+		s.jwtConfig.RefreshTokenLifetime = time.Duration(jwt.RefreshTokenLifetime) * 24 * time.Hour
 	}
-	if s.config.Auth.JWT.Issuer != "" {
-		s.jwtConfig.Issuer = s.config.Auth.JWT.Issuer
+	if jwt.Issuer != "" {
+		s.jwtConfig.Issuer = jwt.Issuer
 	}
-	if s.config.Auth.JWT.Audience != "" {
-		s.jwtConfig.Audience = s.config.Auth.JWT.Audience
+	if jwt.Audience != "" {
+		s.jwtConfig.Audience = jwt.Audience
 	}
 
 	// Initialize JWT handlers
-	s.jwtHandlers = auth.NewAuthHandlers(s.db.db, s.jwtConfig)
+	s.jwtHandlers = auth.NewAuthHandlers(s.db.db.DB, s.jwtConfig)
+	s.jwtHandlers.SetLockoutPolicy(s.lockoutPolicy())
 
 	// Initialize JWT middleware
-	s.jwtMiddleware = auth.NewMiddleware(s.jwtConfig, s.db.db)
+	s.jwtMiddleware = auth.NewMiddleware(s.jwtConfig, s.db.db.DB)
 
 	s.logger.Info("JWT authentication initialized",
 		"access_token_lifetime", s.jwtConfig.AccessTokenLifetime,
@@ -72,17 +77,17 @@ func (s *ComplianceServer) initializeJWT() error {
 
 // registerJWTRoutes registers JWT authentication endpoints
 func (s *ComplianceServer) registerJWTRoutes() {
-	if !s.config.Auth.JWT.Enabled || s.jwtHandlers == nil {
+	if !s.config().Auth.JWT.Enabled || s.jwtHandlers == nil {
 		return
 	}
 
 	// JWT authentication endpoints (public)
-	s.mux.HandleFunc("/api/auth/login", s.jwtHandlers.Login)
-	s.mux.HandleFunc("/api/auth/refresh", s.jwtHandlers.Refresh)
+	s.mux.HandleFunc("POST /api/auth/login", s.rateLimit(s.loginLimiter)(s.jwtHandlers.Login))
+	s.mux.HandleFunc("POST /api/auth/refresh", s.jwtHandlers.Refresh)
 
 	// Protected endpoints (require JWT token)
-	s.mux.Handle("/api/auth/logout", s.jwtMiddleware.RequireAuth(http.HandlerFunc(s.jwtHandlers.Logout)))
-	s.mux.Handle("/api/auth/me", s.jwtMiddleware.RequireAuth(http.HandlerFunc(s.jwtHandlers.Me)))
+	s.mux.Handle("POST /api/auth/logout", s.jwtMiddleware.RequireAuth(http.HandlerFunc(s.jwtHandlers.Logout)))
+	s.mux.Handle("GET /api/auth/me", s.jwtMiddleware.RequireAuth(http.HandlerFunc(s.jwtHandlers.Me)))
 
 	s.logger.Info("JWT endpoints registered",
 		"endpoints", []string{
@@ -96,7 +101,7 @@ func (s *ComplianceServer) registerJWTRoutes() {
 
 // startCleanupTasks starts background cleanup tasks
 func (s *ComplianceServer) startCleanupTasks() {
-	if !s.config.Auth.JWT.Enabled {
+	if !s.config().Auth.JWT.Enabled {
 		return
 	}
 
@@ -120,7 +125,7 @@ func (s *ComplianceServer) cleanupExpiredTokens() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		refreshManager := auth.NewRefreshTokenManager(s.db.db, s.jwtConfig)
+		refreshManager := auth.NewRefreshTokenManager(s.db.db.DB, s.jwtConfig)
 		count, err := refreshManager.CleanupExpiredTokens(context.Background())
 		if err != nil {
 			s.logger.Error("Failed to cleanup expired tokens", "error", err)
@@ -136,7 +141,7 @@ func (s *ComplianceServer) cleanupJWTBlacklist() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		blacklistManager := auth.NewBlacklistManager(s.db.db)
+		blacklistManager := auth.NewBlacklistManager(s.db.db.DB)
 		count, err := blacklistManager.CleanupExpiredEntries(context.Background())
 		if err != nil {
 			s.logger.Error("Failed to cleanup JWT blacklist", "error", err)
@@ -155,7 +160,7 @@ func (s *ComplianceServer) cleanupOldAuditLogs() {
 	retentionPeriod := 90 * 24 * time.Hour
 
 	for range ticker.C {
-		auditLogger := auth.NewAuditLogger(s.db.db)
+		auditLogger := auth.NewAuditLogger(s.db.db.DB)
 		count, err := auditLogger.CleanupOldEntries(context.Background(), retentionPeriod)
 		if err != nil {
 			s.logger.Error("Failed to cleanup old audit logs", "error", err)
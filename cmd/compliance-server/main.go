@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"github.com/spf13/pflag"
 	"golang.org/x/crypto/bcrypt"
+
+	"compliancetoolkit/pkg/buildinfo"
 )
 
 const version = "1.0.0"
@@ -22,12 +22,28 @@ func main() {
 	generateConfig := flags.Bool("generate-config", false, "Generate default config file and exit")
 	hashAPIKey := flags.String("hash-api-key", "", "Generate bcrypt hash for an API key and exit")
 	port := flags.IntP("port", "p", 0, "Server port (overrides config)")
+	listen := flags.String("listen", "", "Listen address as host:port (overrides config)")
+	importEvidence := flags.StringSlice("import-evidence", nil, "Import toolkit evidence logs (files or directories) into the database and exit")
+	migrate := flags.String("migrate", "", "Manage schema migrations and exit: up, down or status")
+	migrateSteps := flags.Int("migrate-steps", 1, "Number of migrations --migrate down rolls back")
+	dryRun := flags.Bool("dry-run", false, "With --migrate, apply or import, list the changes that would be made without changing the database")
+	prune := flags.Bool("prune", false, "With apply, remove users, API keys, policies and client tags the state file does not list")
+	exportAll := flags.Bool("all", false, "With export, include submission history")
+	onConflict := flags.String("on-conflict", conflictFail, "With import, how rows already in the database are treated: fail, skip or overwrite")
+	retryAfter := flags.Duration("retry-after", 0, "With maintenance on, how long clients are told to wait before retrying (default 5m)")
+
+	// Service management flags
+	installSvc := flags.Bool("install-service", false, "Install as a Windows service or systemd unit")
+	uninstallSvc := flags.Bool("uninstall-service", false, "Uninstall the Windows service or systemd unit")
+	startSvc := flags.Bool("start-service", false, "Start the installed service")
+	stopSvc := flags.Bool("stop-service", false, "Stop the installed service")
+	statusSvc := flags.Bool("service-status", false, "Show the installed service's status")
 
 	flags.Parse(os.Args[1:])
 
 	// Handle version
 	if *showVersion {
-		fmt.Printf("Compliance Toolkit Server v%s\n", version)
+		fmt.Print(buildinfo.String("Compliance Toolkit Server", buildinfo.Get(version)))
 		return
 	}
 
@@ -50,6 +66,58 @@ func main() {
 		return
 	}
 
+	// Handle service management commands
+	if *installSvc {
+		if err := installService(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to install service: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *uninstallSvc {
+		if err := uninstallService(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to uninstall service: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *startSvc {
+		if err := startService(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to start service: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *stopSvc {
+		if err := stopService(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to stop service: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *statusSvc {
+		if err := serviceStatus(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to get service status: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check if running as Windows service
+	isService, err := isWindowsService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to determine service status: %v\n", err)
+		os.Exit(1)
+	}
+	if isService {
+		// Services start in the system directory; resolve templates and
+		// relative paths in the configuration next to the executable
+		if err := chdirToExecutable(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Load configuration
 	config, err := LoadServerConfig(*configFile)
 	if err != nil {
@@ -58,6 +126,13 @@ func main() {
 	}
 
 	// Apply CLI overrides
+	if *listen != "" {
+		config.Server.Host, config.Server.Port, err = parseListenAddress(*listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --listen address: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	if *port != 0 {
 		config.Server.Port = *port
 	}
@@ -72,10 +147,64 @@ func main() {
 	logger := setupLogging(config.Logging)
 	slog.SetDefault(logger)
 
+	// Handle commands: compliance-server apply state.yaml,
+	// compliance-server healthcheck for container health probes,
+	// compliance-server sign-policy publisher.key report.json,
+	// compliance-server sign-script publisher.key report.json,
+	// compliance-server maintenance on ["message"] | off | status and
+	// compliance-server export|import archive.zip
+	if args := flags.Args(); len(args) > 0 {
+		var ok bool
+		switch {
+		case args[0] == "apply" && len(args) == 2:
+			ok = runApplyCommand(config, args[1], *dryRun, *prune)
+		case args[0] == "healthcheck" && len(args) == 1:
+			ok = runHealthcheck(config)
+		case args[0] == "sign-policy" && len(args) == 3:
+			ok = runSignPolicyCommand(args[1], args[2])
+		case args[0] == "sign-script" && len(args) == 3:
+			ok = runSignScriptCommand(args[1], args[2])
+		case args[0] == "maintenance" && len(args) == 2:
+			ok = runMaintenanceCommand(config, args[1], "", *retryAfter)
+		case args[0] == "maintenance" && len(args) == 3 && args[1] == "on":
+			ok = runMaintenanceCommand(config, args[1], args[2], *retryAfter)
+		case args[0] == "export" && len(args) == 2:
+			ok = runExportCommand(config, args[1], *exportAll)
+		case args[0] == "import" && len(args) == 2:
+			ok = runImportCommand(config, args[1], *onConflict, *dryRun)
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unexpected arguments %q (usage: compliance-server apply state.yaml | compliance-server healthcheck | compliance-server sign-policy publisher.key report.json | compliance-server sign-script publisher.key report.json | compliance-server maintenance on|off|status | compliance-server export [--all] archive.zip | compliance-server import archive.zip)\n", args)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Handle schema migrations
+	if *migrate != "" {
+		if !runMigrateCommand(config, *migrate, *migrateSteps, *dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Handle evidence import
+	if len(*importEvidence) > 0 {
+		if !runEvidenceImport(config, *importEvidence) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Log startup
+	build := buildinfo.Get(version)
 	slog.Info("Compliance Server starting",
 		"version", version,
-		"port", config.Server.Port,
+		"commit", build.Commit,
+		"build_sha256", build.BinarySHA256,
+		"addr", config.Server.ListenAddress(),
 		"tls_enabled", config.Server.TLS.Enabled,
 	)
 
@@ -86,22 +215,15 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Start server in background
-	if err := server.Start(); err != nil {
-		slog.Error("Failed to start server", "error", err)
-		os.Exit(1)
+	// Run under the service control manager or in the foreground
+	if isService {
+		slog.Info("Running as Windows service")
+		err = runService(server, logger)
+	} else {
+		err = runConsole(server)
 	}
-
-	// Wait for shutdown signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	sig := <-sigChan
-	slog.Info("Received shutdown signal", "signal", sig.String())
-
-	// Graceful shutdown
-	if err := server.Shutdown(); err != nil {
-		slog.Error("Error during shutdown", "error", err)
+	if err != nil {
+		slog.Error("Server failed", "error", err)
 		os.Exit(1)
 	}
 
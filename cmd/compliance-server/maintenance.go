@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Maintenance window time zones must resolve on hosts without zoneinfo
+
+	"github.com/robfig/cron/v3"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// validateMaintenanceWindow checks that a window definition can be evaluated
+func validateMaintenanceWindow(w *api.MaintenanceWindow) error {
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if w.HostnamePattern != "" {
+		if _, err := path.Match(w.HostnamePattern, ""); err != nil {
+			return fmt.Errorf("invalid hostname_pattern: %w", err)
+		}
+	}
+
+	if w.Group != "" {
+		group, err := normalizeGroup(w.Group)
+		if err != nil {
+			return fmt.Errorf("invalid group: %w", err)
+		}
+		w.Group = group
+	}
+
+	if _, err := loadWindowLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	if w.StartsAt != nil && w.EndsAt != nil && !w.EndsAt.After(*w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	if w.Cron == "" {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return fmt.Errorf("one-off windows require starts_at and ends_at")
+		}
+		return nil
+	}
+
+	if _, err := cron.ParseStandard(w.Cron); err != nil {
+		return fmt.Errorf("invalid cron: %w", err)
+	}
+	if w.DurationMinutes <= 0 {
+		return fmt.Errorf("recurring windows require a positive duration_minutes")
+	}
+	return nil
+}
+
+// loadWindowLocation resolves a window's time zone, defaulting to UTC
+func loadWindowLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// maintenanceWindowCovers reports whether a window applies to a client with
+// the given tags
+func maintenanceWindowCovers(w api.MaintenanceWindow, clientID, hostname string, tags []string) bool {
+	if w.ClientID != "" && w.ClientID != clientID {
+		return false
+	}
+	if w.Group != "" && !slices.Contains(tags, w.Group) {
+		return false
+	}
+	return w.HostnamePattern == "" || hostnameMatches(w.HostnamePattern, hostname)
+}
+
+// maintenanceWindowsUseGroups reports whether any window covers a client
+// group, so client tags are only loaded when they matter
+func maintenanceWindowsUseGroups(windows []api.MaintenanceWindow) bool {
+	return slices.ContainsFunc(windows, func(w api.MaintenanceWindow) bool { return w.Group != "" })
+}
+
+// hostnameMatches reports whether a hostname matches a case-insensitive glob
+func hostnameMatches(pattern, hostname string) bool {
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(hostname))
+	return err == nil && matched
+}
+
+// maintenanceWindowActive reports whether a window is in effect at t. For a
+// recurring window the occurrence covering t, if any, is the first start
+// after t minus the duration: t is covered when that start is not after t.
+func maintenanceWindowActive(w api.MaintenanceWindow, t time.Time) bool {
+	if !w.Enabled {
+		return false
+	}
+	if w.StartsAt != nil && t.Before(*w.StartsAt) {
+		return false
+	}
+	if w.EndsAt != nil && !t.Before(*w.EndsAt) {
+		return false
+	}
+	if w.Cron == "" {
+		return w.StartsAt != nil && w.EndsAt != nil
+	}
+
+	schedule, err := cron.ParseStandard(w.Cron)
+	if err != nil {
+		return false
+	}
+	location, err := loadWindowLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	start := schedule.Next(t.Add(-duration).In(location))
+	return !start.IsZero() && !start.After(t)
+}
+
+// findMaintenanceWindow returns the first window covering the client at t
+func findMaintenanceWindow(windows []api.MaintenanceWindow, clientID, hostname string, tags []string, t time.Time) *api.MaintenanceWindow {
+	for i := range windows {
+		if maintenanceWindowCovers(windows[i], clientID, hostname, tags) && maintenanceWindowActive(windows[i], t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// activeMaintenanceWindow returns the window covering a client at t, or nil.
+// Lookup failures are logged and treated as no maintenance so that alerts
+// are never lost because the window table could not be read.
+func (s *ComplianceServer) activeMaintenanceWindow(clientID, hostname string, t time.Time) *api.MaintenanceWindow {
+	windows, err := s.db.ListMaintenanceWindows()
+	if err != nil {
+		s.logger.Warn("Failed to load maintenance windows", "error", err)
+		return nil
+	}
+	var tags []string
+	if maintenanceWindowsUseGroups(windows) {
+		if tags, err = s.db.GetClientTags(clientID); err != nil {
+			s.logger.Warn("Failed to load client tags", "client_id", clientID, "error", err)
+		}
+	}
+	return findMaintenanceWindow(windows, clientID, hostname, tags, t)
+}
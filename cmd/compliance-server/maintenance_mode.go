@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// defaultMaintenanceRetryAfter is the Retry-After sent when maintenance
+// mode was enabled without one
+const defaultMaintenanceRetryAfter = 5 * time.Minute
+
+// maintenanceRefreshInterval is how often the server reloads maintenance
+// mode, which another replica or the CLI may have changed
+const maintenanceRefreshInterval = 15 * time.Second
+
+// maintenanceExempt are the paths that still accept changes in maintenance
+// mode: signing in and out, and switching maintenance mode off
+var maintenanceExempt = map[string]bool{
+	"/api/v1/settings/maintenance-mode": true,
+	"/api/v1/auth/login":                true,
+	"/api/v1/auth/logout":               true,
+	"/api/auth/login":                   true,
+	"/api/auth/refresh":                 true,
+	"/api/auth/logout":                  true,
+}
+
+// maintenanceMode returns the maintenance mode in effect
+func (s *ComplianceServer) maintenanceMode() api.MaintenanceMode {
+	if mode := s.maintenance.Load(); mode != nil {
+		return *mode
+	}
+	return api.MaintenanceMode{}
+}
+
+// startMaintenanceMode loads maintenance mode and keeps reloading it
+func (s *ComplianceServer) startMaintenanceMode() {
+	s.refreshMaintenanceMode()
+	go func() {
+		ticker := time.NewTicker(maintenanceRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.refreshMaintenanceMode()
+		}
+	}()
+}
+
+// refreshMaintenanceMode reloads maintenance mode from the database. While
+// the database cannot be read, as during a migration, the last mode stays
+// in effect.
+func (s *ComplianceServer) refreshMaintenanceMode() {
+	mode, err := s.db.GetMaintenanceMode()
+	if err != nil {
+		s.logger.Warn("Failed to load maintenance mode", "error", err)
+		return
+	}
+	if previous := s.maintenanceMode(); previous.Enabled != mode.Enabled {
+		s.logger.Warn("Maintenance mode changed", "enabled", mode.Enabled, "message", mode.Message, "started_by", mode.StartedBy)
+	}
+	s.maintenance.Store(mode)
+}
+
+// maintenanceMiddleware marks every response while the server is in
+// maintenance mode, and answers requests that change data with 503 and
+// Retry-After. Reads are served, so the dashboard stays usable read-only.
+func (s *ComplianceServer) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := s.maintenanceMode()
+		if !mode.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set(api.HeaderMaintenanceMode, "on")
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if maintenanceExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := "The server is in maintenance mode; try again later"
+		if mode.Message != "" {
+			message = "The server is in maintenance mode: " + mode.Message
+		}
+		retryAfter := mode.RetryAfterSeconds
+		if retryAfter <= 0 {
+			retryAfter = int(defaultMaintenanceRetryAfter.Seconds())
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		s.sendError(w, http.StatusServiceUnavailable, message)
+	})
+}
+
+// withMaintenanceBanner adds a banner to the top of a dashboard page while
+// the server is in maintenance mode
+func (s *ComplianceServer) withMaintenanceBanner(page []byte) []byte {
+	mode := s.maintenanceMode()
+	if !mode.Enabled {
+		return page
+	}
+
+	body := bytes.Index(page, []byte("<body"))
+	if body < 0 {
+		return page
+	}
+	end := bytes.IndexByte(page[body:], '>')
+	if end < 0 {
+		return page
+	}
+	at := body + end + 1
+
+	text := "Maintenance in progress: the dashboard is read-only and changes are refused."
+	if mode.Message != "" {
+		text += " " + mode.Message
+	}
+	banner := fmt.Sprintf(`
+    <div class="maintenance-banner" role="status" style="background:#fef3c7;color:#78350f;border-bottom:1px solid #f59e0b;padding:10px 16px;text-align:center;font-weight:600">%s</div>`,
+		html.EscapeString(text))
+
+	result := make([]byte, 0, len(page)+len(banner))
+	result = append(result, page[:at]...)
+	result = append(result, banner...)
+	return append(result, page[at:]...)
+}
+
+// handleGetMaintenanceMode returns the maintenance mode in effect
+func (s *ComplianceServer) handleGetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.maintenanceMode())
+}
+
+// handleSetMaintenanceMode switches maintenance mode on or off for every
+// server replica
+func (s *ComplianceServer) handleSetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	var request api.MaintenanceMode
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if request.RetryAfterSeconds < 0 {
+		s.sendError(w, http.StatusBadRequest, "retry_after_seconds must not be negative")
+		return
+	}
+
+	before := s.maintenanceMode()
+	mode := newMaintenanceMode(before, request.Enabled, request.Message, time.Duration(request.RetryAfterSeconds)*time.Second, s.auditActor(r), time.Now())
+	if err := s.db.SetMaintenanceMode(mode); err != nil {
+		s.logger.Error("Failed to set maintenance mode", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set maintenance mode")
+		return
+	}
+	s.maintenance.Store(mode)
+	noteAdminChange(r, "maintenance_mode", before, *mode)
+	s.logger.Warn("Maintenance mode set", "enabled", mode.Enabled, "message", mode.Message, "by", mode.StartedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mode)
+}
+
+// newMaintenanceMode returns the mode after switching current on or off.
+// Switching it on again keeps the original start; switching it off clears
+// it.
+func newMaintenanceMode(current api.MaintenanceMode, enabled bool, message string, retryAfter time.Duration, actor string, now time.Time) *api.MaintenanceMode {
+	if !enabled {
+		return &api.MaintenanceMode{}
+	}
+	if retryAfter <= 0 {
+		retryAfter = defaultMaintenanceRetryAfter
+	}
+	mode := &api.MaintenanceMode{
+		Enabled:           true,
+		Message:           message,
+		RetryAfterSeconds: int(retryAfter.Seconds()),
+		StartedAt:         &now,
+		StartedBy:         actor,
+	}
+	if current.Enabled && current.StartedAt != nil {
+		mode.StartedAt, mode.StartedBy = current.StartedAt, current.StartedBy
+	}
+	return mode
+}
+
+// runMaintenanceCommand implements compliance-server maintenance on|off|status.
+// It does not run migrations, so it can be used before --migrate up.
+func runMaintenanceCommand(config *ServerConfig, action, message string, retryAfter time.Duration) bool {
+	db, err := connectDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	current, err := db.GetMaintenanceMode()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	mode := current
+	switch action {
+	case "on", "off":
+		actor := "cli"
+		if name, err := os.Hostname(); err == nil {
+			actor = "cli@" + name
+		}
+		mode = newMaintenanceMode(*current, action == "on", message, retryAfter, actor, time.Now())
+		if err := db.SetMaintenanceMode(mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+	case "status":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown maintenance command %q (use on, off or status)\n", action)
+		return false
+	}
+
+	if !mode.Enabled {
+		fmt.Println("Maintenance mode is off")
+		return true
+	}
+	fmt.Printf("Maintenance mode is on since %s (by %s); clients are told to retry after %ds\n",
+		mode.StartedAt.UTC().Format(time.RFC3339), mode.StartedBy, mode.RetryAfterSeconds)
+	if mode.Message != "" {
+		fmt.Printf("Message: %s\n", mode.Message)
+	}
+	if action == "on" {
+		fmt.Printf("Running servers pick this up within %s\n", maintenanceRefreshInterval)
+	}
+	return true
+}
+
+// GetMaintenanceMode returns the stored maintenance mode; off when none was
+// ever set
+func (d *Database) GetMaintenanceMode() (*api.MaintenanceMode, error) {
+	var mode api.MaintenanceMode
+	var startedAt sql.NullTime
+	err := d.db.QueryRow(safesql.New(`
+		SELECT enabled, message, retry_after_seconds, started_at, started_by
+		FROM maintenance_mode
+		WHERE id = 1
+	`)).Scan(&mode.Enabled, &mode.Message, &mode.RetryAfterSeconds, &startedAt, &mode.StartedBy)
+	if err == sql.ErrNoRows {
+		return &api.MaintenanceMode{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+	mode.StartedAt = timePtr(startedAt)
+	return &mode, nil
+}
+
+// SetMaintenanceMode stores the maintenance mode
+func (d *Database) SetMaintenanceMode(mode *api.MaintenanceMode) error {
+	_, err := d.db.Exec(safesql.New(`
+		INSERT INTO maintenance_mode (id, enabled, message, retry_after_seconds, started_at, started_by)
+		VALUES (1, $1, $2, $3, $4, $5)
+		ON CONFLICT(id) DO UPDATE SET
+			enabled = excluded.enabled,
+			message = excluded.message,
+			retry_after_seconds = excluded.retry_after_seconds,
+			started_at = excluded.started_at,
+			started_by = excluded.started_by
+	`, mode.Enabled, mode.Message, mode.RetryAfterSeconds, nullableTime(mode.StartedAt), mode.StartedBy))
+	if err != nil {
+		return fmt.Errorf("failed to set maintenance mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestMaintenanceMiddleware tests that maintenance mode refuses changes
+// with 503 and Retry-After and still serves reads
+func TestMaintenanceMiddleware(t *testing.T) {
+	s := newTestServer()
+	handler := s.maintenanceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := serve("POST", "/api/v1/compliance/submit"); rec.Code != http.StatusOK || rec.Header().Get(api.HeaderMaintenanceMode) != "" {
+		t.Fatalf("submit outside maintenance: status %d, header %q", rec.Code, rec.Header().Get(api.HeaderMaintenanceMode))
+	}
+
+	s.maintenance.Store(&api.MaintenanceMode{Enabled: true, Message: "Upgrading to 2.0", RetryAfterSeconds: 600})
+
+	rec := serve("POST", "/api/v1/compliance/submit")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "600" {
+		t.Errorf("submit in maintenance: status %d, Retry-After %q; want 503 and 600", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), "Upgrading to 2.0") {
+		t.Errorf("body = %s, want the maintenance message", rec.Body.String())
+	}
+
+	for _, tt := range []struct{ method, target string }{
+		{"GET", "/api/v1/clients"},
+		{"HEAD", "/api/v1/health"},
+		{"POST", "/api/v1/auth/login"},
+		{"PUT", "/api/v1/settings/maintenance-mode"},
+	} {
+		rec := serve(tt.method, tt.target)
+		if rec.Code != http.StatusOK || rec.Header().Get(api.HeaderMaintenanceMode) != "on" {
+			t.Errorf("%s %s in maintenance: status %d, header %q; want it served and marked", tt.method, tt.target, rec.Code, rec.Header().Get(api.HeaderMaintenanceMode))
+		}
+	}
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		if rec := serve(method, "/api/v1/policies/p1"); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s in maintenance: status %d, want 503", method, rec.Code)
+		}
+	}
+}
+
+// TestMaintenanceBanner tests the banner added to dashboard pages
+func TestMaintenanceBanner(t *testing.T) {
+	s := newTestServer()
+	page := []byte(`<html><head></head><body class="app"><main></main></body></html>`)
+
+	if got := s.withMaintenanceBanner(page); string(got) != string(page) {
+		t.Errorf("page outside maintenance changed: %s", got)
+	}
+
+	s.maintenance.Store(&api.MaintenanceMode{Enabled: true, Message: "Back at <b>06:00</b>"})
+	got := string(s.withMaintenanceBanner(page))
+	if !strings.HasPrefix(got, `<html><head></head><body class="app">`) || !strings.Contains(got, `class="maintenance-banner"`) {
+		t.Errorf("page = %s, want the banner right after <body>", got)
+	}
+	if !strings.Contains(got, "Back at &lt;b&gt;06:00&lt;/b&gt;") {
+		t.Errorf("page = %s, want the message escaped", got)
+	}
+}
+
+// TestNewMaintenanceMode tests switching maintenance mode on and off
+func TestNewMaintenanceMode(t *testing.T) {
+	first := time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	on := newMaintenanceMode(api.MaintenanceMode{}, true, "Database upgrade", 0, "admin", first)
+	if !on.Enabled || on.RetryAfterSeconds != 300 || !on.StartedAt.Equal(first) || on.StartedBy != "admin" {
+		t.Errorf("on = %+v, want enabled by admin with the default Retry-After", on)
+	}
+
+	again := newMaintenanceMode(*on, true, "Taking longer", 20*time.Minute, "cli@db01", later)
+	if again.Message != "Taking longer" || again.RetryAfterSeconds != 1200 || !again.StartedAt.Equal(first) || again.StartedBy != "admin" {
+		t.Errorf("updated = %+v, want the new message and Retry-After with the original start", again)
+	}
+
+	if off := newMaintenanceMode(*again, false, "ignored", time.Minute, "admin", later); off.Enabled || off.StartedAt != nil || off.Message != "" {
+		t.Errorf("off = %+v, want a cleared mode", off)
+	}
+}
+
+// TestSetMaintenanceModeValidation tests that a negative Retry-After is refused
+func TestSetMaintenanceModeValidation(t *testing.T) {
+	s := newTestServer()
+	for _, body := range []string{`{"enabled": true, "retry_after_seconds": -1}`, `not json`} {
+		rec := httptest.NewRecorder()
+		s.handleSetMaintenanceMode(rec, httptest.NewRequest("PUT", "/api/v1/settings/maintenance-mode", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestMaintenanceWindowActive tests one-off and recurring window evaluation
+func TestMaintenanceWindowActive(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2025, 3, d, h, m, 0, 0, time.UTC) }
+
+	oneOff := api.MaintenanceWindow{Enabled: true, StartsAt: ptrTime(at(10, 22, 0)), EndsAt: ptrTime(at(11, 2, 0))}
+	// Tuesdays 22:00 for 4 hours; 2025-03-11 is a Tuesday
+	patchNight := api.MaintenanceWindow{Enabled: true, Cron: "0 22 * * 2", DurationMinutes: 240}
+	bounded := patchNight
+	bounded.EndsAt = ptrTime(at(12, 0, 0))
+	eastern := patchNight
+	eastern.Timezone = "America/New_York"
+	disabled := oneOff
+	disabled.Enabled = false
+
+	tests := []struct {
+		name   string
+		window api.MaintenanceWindow
+		at     time.Time
+		want   bool
+	}{
+		{"one-off before", oneOff, at(10, 21, 59), false},
+		{"one-off start", oneOff, at(10, 22, 0), true},
+		{"one-off inside", oneOff, at(11, 1, 0), true},
+		{"one-off end is exclusive", oneOff, at(11, 2, 0), false},
+		{"disabled", disabled, at(11, 1, 0), false},
+		{"recurring before start", patchNight, at(11, 21, 59), false},
+		{"recurring start", patchNight, at(11, 22, 0), true},
+		{"recurring past midnight", patchNight, at(12, 1, 59), true},
+		{"recurring end is exclusive", patchNight, at(12, 2, 0), false},
+		{"recurring other day", patchNight, at(13, 23, 0), false},
+		{"recurring after ends_at", bounded, at(18, 23, 0), false},
+		{"recurring in time zone", eastern, at(12, 3, 0), true},
+		{"recurring in time zone before start", eastern, at(11, 23, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maintenanceWindowActive(tt.window, tt.at); got != tt.want {
+				t.Errorf("maintenanceWindowActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMaintenanceWindowCovers tests client, hostname and group scoping
+func TestMaintenanceWindowCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   api.MaintenanceWindow
+		clientID string
+		hostname string
+		tags     []string
+		want     bool
+	}{
+		{"fleet-wide", api.MaintenanceWindow{}, "client-1", "WS-01", nil, true},
+		{"matching client", api.MaintenanceWindow{ClientID: "client-1"}, "client-1", "WS-01", nil, true},
+		{"other client", api.MaintenanceWindow{ClientID: "client-2"}, "client-1", "WS-01", nil, false},
+		{"hostname glob", api.MaintenanceWindow{HostnamePattern: "sql-*"}, "client-1", "SQL-PROD-01", nil, true},
+		{"hostname glob miss", api.MaintenanceWindow{HostnamePattern: "sql-*"}, "client-1", "WS-01", nil, false},
+		{"client and glob", api.MaintenanceWindow{ClientID: "client-1", HostnamePattern: "WS-*"}, "client-1", "SQL-01", nil, false},
+		{"group member", api.MaintenanceWindow{Group: "pos"}, "client-1", "WS-01", []string{"emea", "pos"}, true},
+		{"not in group", api.MaintenanceWindow{Group: "pos"}, "client-1", "WS-01", []string{"emea"}, false},
+		{"untagged client", api.MaintenanceWindow{Group: "pos"}, "client-1", "WS-01", nil, false},
+		{"group and glob", api.MaintenanceWindow{Group: "pos", HostnamePattern: "SQL-*"}, "client-1", "WS-01", []string{"pos"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maintenanceWindowCovers(tt.window, tt.clientID, tt.hostname, tt.tags); got != tt.want {
+				t.Errorf("maintenanceWindowCovers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateMaintenanceWindow tests rejection of windows that cannot be evaluated
+func TestValidateMaintenanceWindow(t *testing.T) {
+	start := time.Date(2025, 3, 10, 22, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+
+	tests := []struct {
+		name    string
+		window  api.MaintenanceWindow
+		wantErr bool
+	}{
+		{"one-off", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &start, EndsAt: &end}, false},
+		{"recurring", api.MaintenanceWindow{Name: "Patch night", Cron: "0 22 * * 2", DurationMinutes: 240, Timezone: "Europe/London"}, false},
+		{"missing name", api.MaintenanceWindow{StartsAt: &start, EndsAt: &end}, true},
+		{"one-off without end", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &start}, true},
+		{"end before start", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &end, EndsAt: &start}, true},
+		{"bad cron", api.MaintenanceWindow{Name: "Patch night", Cron: "every tuesday", DurationMinutes: 240}, true},
+		{"recurring without duration", api.MaintenanceWindow{Name: "Patch night", Cron: "0 22 * * 2"}, true},
+		{"bad timezone", api.MaintenanceWindow{Name: "Patch night", Cron: "0 22 * * 2", DurationMinutes: 240, Timezone: "Mars/Olympus"}, true},
+		{"bad hostname pattern", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &start, EndsAt: &end, HostnamePattern: "SQL-["}, true},
+		{"group", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &start, EndsAt: &end, Group: "POS"}, false},
+		{"bad group", api.MaintenanceWindow{Name: "Upgrade", StartsAt: &start, EndsAt: &end, Group: "point of sale"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMaintenanceWindow(&tt.window)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMaintenanceWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestMissedRunSkipsMaintenance tests that runs scheduled inside a maintenance
+// window are not reported, while later missed runs still are
+func TestMissedRunSkipsMaintenance(t *testing.T) {
+	daily, err := cron.ParseStandard("0 2 * * *")
+	if err != nil {
+		t.Fatalf("ParseStandard() error = %v", err)
+	}
+
+	day := func(d, h int) time.Time { return time.Date(2025, 3, d, h, 0, 0, 0, time.UTC) }
+	windows := []api.MaintenanceWindow{{Enabled: true, StartsAt: ptrTime(day(10, 0)), EndsAt: ptrTime(day(10, 6))}}
+	suppressed := func(t time.Time) bool { return findMaintenanceWindow(windows, "client-1", "WS-01", nil, t) != nil }
+
+	if _, missed := missedRun(daily, day(9, 2), time.Time{}, day(10, 12), 2*time.Hour, time.UTC, suppressed); missed {
+		t.Error("run inside maintenance window reported as missed")
+	}
+
+	got, missed := missedRun(daily, day(9, 2), time.Time{}, day(11, 12), 2*time.Hour, time.UTC, suppressed)
+	if !missed || !got.Equal(day(11, 2)) {
+		t.Errorf("missedRun() = %v, %v; want %v, true", got, missed, day(11, 2))
+	}
+}
+
+// ptrTime returns a pointer to t for optional time fields
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/metrics"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// serverMetrics are the metrics served at /metrics. They are kept per
+// process, so each replica reports its own requests and submissions.
+var serverMetrics = newServerMetricSet(metrics.NewRegistry())
+
+// serverMetricSet is the server's metric families
+type serverMetricSet struct {
+	registry *metrics.Registry
+
+	requestDuration *metrics.Histogram
+	authFailures    *metrics.Counter
+	submissions     *metrics.Counter
+	dbDuration      *metrics.Histogram
+	clients         *metrics.Gauge
+	forwarded       *metrics.Counter
+	eventDeliveries *metrics.Counter
+	submissionFlags *metrics.Counter
+}
+
+// newServerMetricSet registers the server's metric families in r
+func newServerMetricSet(r *metrics.Registry) *serverMetricSet {
+	return &serverMetricSet{
+		registry: r,
+		requestDuration: r.Histogram("compliance_http_request_duration_seconds",
+			"HTTP request latency by route pattern and status code.", metrics.DefaultBuckets, "route", "code"),
+		authFailures: r.Counter("compliance_auth_failures_total",
+			"Requests refused with 401 Unauthorized, by route pattern.", "route"),
+		submissions: r.Counter("compliance_submissions_total",
+			"Compliance submissions stored, by kind (full or delta).", "kind"),
+		dbDuration: r.Histogram("compliance_db_query_duration_seconds",
+			"Database statement latency by operation (query or exec).", metrics.DefaultBuckets, "op"),
+		clients: r.Gauge("compliance_clients",
+			"Registered clients (total) and those seen in the last 24 hours (active).", "state"),
+		forwarded: r.Counter("compliance_forwarded_events_total",
+			"Events forwarded to the SIEM (sent) or dropped because the queue was full.", "result"),
+		eventDeliveries: r.Counter("compliance_event_deliveries_total",
+			"Event bus deliveries by consumer and result (delivered, retried, failed or dropped).", "consumer", "result"),
+		submissionFlags: r.Counter("compliance_submission_flags_total",
+			"Submissions refused over a client's quota or flagged as anomalous, by reason (hourly_quota, daily_quota or check_count).", "reason"),
+	}
+}
+
+// metricsMiddleware records the latency of every request, and counts
+// authentication failures, under the pattern of the route that served it.
+// Requests matching no route share the route label "unmatched", so probes of
+// arbitrary paths do not create series.
+func (s *ComplianceServer) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if _, pattern := s.mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+
+		serverMetrics.requestDuration.Observe(time.Since(start).Seconds(), route, strconv.Itoa(wrapped.statusCode))
+		if wrapped.statusCode == http.StatusUnauthorized {
+			serverMetrics.authFailures.Inc(route)
+		}
+	})
+}
+
+// handleMetrics serves the metrics in the Prometheus text format. With
+// metrics.token set the scraper must send it as a bearer token.
+func (s *ComplianceServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := s.config().Metrics.Token; token != "" {
+		sent := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			s.sendError(w, http.StatusUnauthorized, "Invalid metrics token")
+			return
+		}
+	}
+
+	if s.db != nil {
+		if total, active, err := s.requestDB(r).CountClients(); err != nil {
+			s.logger.Warn("Failed to count clients for metrics", "error", err)
+		} else {
+			serverMetrics.clients.Set(float64(total), "total")
+			serverMetrics.clients.Set(float64(active), "active")
+		}
+	}
+
+	serverMetrics.registry.Handler().ServeHTTP(w, r)
+}
+
+// CountClients returns the number of registered clients and of those seen
+// in the last 24 hours, the dashboard's definition of active
+func (d *Database) CountClients() (total, active int, err error) {
+	const query = `
+		SELECT COUNT(*), COUNT(CASE WHEN last_seen > CURRENT_TIMESTAMP - INTERVAL '24 hours' THEN 1 END)
+		FROM clients
+	`
+
+	if err := d.db.QueryRow(safesql.New(query)).Scan(&total, &active); err != nil {
+		return 0, 0, fmt.Errorf("failed to count clients: %w", err)
+	}
+	return total, active, nil
+}
+
+// timedDB is the database handle with the latency of each statement
+// recorded in compliance_db_query_duration_seconds. Statements run inside
+// transactions are not timed. Statements are canceled with ctx, when set
+// (see Database.WithContext).
+type timedDB struct {
+	*sql.DB
+	ctx context.Context
+}
+
+// context returns the context statements run under
+func (t *timedDB) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// Query runs a query returning rows
+func (t *timedDB) Query(q safesql.Query) (*sql.Rows, error) {
+	defer t.observe("query")()
+	return safesql.QueryContext(t.context(), t.DB, q)
+}
+
+// QueryRow runs a query returning at most one row
+func (t *timedDB) QueryRow(q safesql.Query) *sql.Row {
+	defer t.observe("query")()
+	return safesql.QueryRowContext(t.context(), t.DB, q)
+}
+
+// Exec runs a statement returning no rows
+func (t *timedDB) Exec(q safesql.Query) (sql.Result, error) {
+	defer t.observe("exec")()
+	return safesql.ExecContext(t.context(), t.DB, q)
+}
+
+// Begin starts a transaction, rolled back if the context is canceled
+// before it commits
+func (t *timedDB) Begin() (*safesql.Tx, error) {
+	return safesql.BeginTx(t.context(), t.DB, nil)
+}
+
+// Ping checks the connection
+func (t *timedDB) Ping() error {
+	return t.DB.PingContext(t.context())
+}
+
+// observe starts timing a statement and returns the function recording its
+// latency, also in the diagnostics of the request it runs for:
+// defer t.observe("query")()
+func (t *timedDB) observe(op string) func() {
+	start := time.Now()
+	diagnostics := requestDiagnosticsFrom(t.context())
+	diagnostics.start(op)
+	return func() {
+		serverMetrics.dbDuration.Observe(time.Since(start).Seconds(), op)
+		diagnostics.finish()
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandleMetrics tests the metrics token and that requests are recorded
+// under their route pattern
+func TestHandleMetrics(t *testing.T) {
+	s := newTestServer()
+	s.config().Metrics.Enabled = true
+	s.config().Metrics.Token = "scrape-secret"
+	s.mux = http.NewServeMux()
+	s.registerRoutes()
+	handler := s.metricsMiddleware(s.routeHandler())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"token", "Bearer scrape-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			body := rec.Body.String()
+			for _, want := range []string{
+				`compliance_http_request_duration_seconds_count{route="GET /{$}",code="200"}`,
+				`compliance_http_request_duration_seconds_count{route="unmatched",code="404"}`,
+				`compliance_auth_failures_total{route="GET /metrics"}`,
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("metrics do not contain %s", want)
+				}
+			}
+		})
+	}
+}
+
+// TestMetricsDisabled tests that /metrics is not served when disabled
+func TestMetricsDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer().routeHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code == http.StatusOK {
+		t.Errorf("status = %d with metrics disabled", rec.Code)
+	}
+}
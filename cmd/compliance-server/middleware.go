@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userContextKey keys the user authenticated by a session or JWT in a
+// request context
+type userContextKey struct{}
+
+// withUser returns r carrying the authenticated user and their client scope
+func withUser(r *http.Request, user *User) *http.Request {
+	r = r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+	return withClientScope(r, userScope(user))
+}
+
+// requestUser returns the user making r, or nil when r was authenticated
+// with an API key or authentication is disabled
+func requestUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey{}).(*User)
+	return user
+}
+
+// requireAuth middleware for web pages - redirects to login if not authenticated
+func (s *ComplianceServer) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Look up the session cookie's user
+		user := s.sessionUser(r)
+		if user == nil {
+			// Not authenticated, redirect to login
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		// User is authenticated, proceed
+		next(w, withUser(r, user))
+	}
+}
+
+// authMiddleware checks authentication (supports session cookies, JWT tokens, and API keys)
+func (s *ComplianceServer) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Skip auth if disabled
+		config := s.config()
+		if !config.Auth.Enabled || !config.Auth.RequireKey {
+			next(w, r)
+			return
+		}
+
+		// 1. Check for session authentication first (username/password login)
+		if user := s.sessionUser(r); user != nil {
+			// Valid session, allow access within the user's client scope
+			s.trackUsage(next, w, withUser(r, user), "", user.ID)
+			return
+		}
+
+		// 2. Check for JWT authentication (if enabled)
+		if config.Auth.JWT.Enabled && s.jwtMiddleware != nil {
+			authHeader := r.Header.Get("Authorization")
+			if strings.HasPrefix(authHeader, "Bearer ") {
+				// Attempt JWT authentication
+				token := strings.TrimPrefix(authHeader, "Bearer ")
+				if token != "" {
+					// Validate JWT token
+					claims, err := s.jwtConfig.ValidateAccessToken(token)
+					if err == nil {
+						// Check if token is blacklisted
+						blacklistMgr := auth.NewBlacklistManager(s.db.db.DB)
+						isBlacklisted, blErr := blacklistMgr.IsTokenBlacklisted(r.Context(), claims.ID)
+						if blErr == nil && !isBlacklisted {
+							// Valid JWT token, allow access within the user's client
+							// scope, read now so scope changes apply to issued tokens
+							if user, err := s.requestDB(r).GetUser(claims.Username); err == nil {
+								s.trackUsage(next, w, withUser(r, user), "", user.ID)
+								return
+							}
+						}
+					}
+				}
+			}
+		}
+
+		// 3. Fall back to API key authentication
+		var apiKey string
+
+		// Try to get API key from cookie (for dashboard/web UI)
+		if cookie, err := r.Cookie("api_token"); err == nil {
+			apiKey = cookie.Value
+		} else {
+			// Fall back to Authorization header (for API clients)
+			// Note: JWT Bearer tokens already handled above
+			apiKey = r.Header.Get("Authorization")
+			if apiKey == "" {
+				s.sendError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			// Remove "Bearer " prefix if present (for API keys with Bearer prefix)
+			apiKey = strings.TrimPrefix(apiKey, "Bearer ")
+		}
+
+		// Validate API key
+		keyHash, valid := s.validateAPIKey(apiKey)
+
+		if !valid {
+			s.logger.Warn("Invalid authentication", "remote_addr", r.RemoteAddr)
+			s.sendError(w, http.StatusUnauthorized, "Invalid authentication credentials")
+			return
+		}
+
+		// Config file keys (no hash) are not tracked
+		if keyHash == "" {
+			next(w, r)
+			return
+		}
+		s.trackUsage(next, w, r, keyHash, 0)
+	}
+}
+
+// validateAPIKey checks if an API key is valid (checks database first, then config fallback).
+// It returns the stored hash of a matching database key, empty for config keys.
+func (s *ComplianceServer) validateAPIKey(apiKey string) (string, bool) {
+	// First, check database for active API keys
+	hashes, err := s.db.ListActiveAPIKeyHashes()
+	if err != nil {
+		s.logger.Error("Failed to list active API key hashes", "error", err)
+		// Continue to config fallback if database fails
+	} else {
+		// Check against database hashes
+		for _, hash := range hashes {
+			if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(apiKey)); err == nil {
+				// Update last_used timestamp asynchronously
+				go func(keyHash string) {
+					if err := s.db.UpdateAPIKeyLastUsed(keyHash); err != nil {
+						s.logger.Warn("Failed to update API key last used", "error", err)
+					}
+				}(hash)
+				return hash, true
+			}
+		}
+	}
+
+	// DEPRECATED: Fall back to config-based keys for backwards compatibility
+	// WARNING: This functionality will be removed in v2.0
+	// Migrate to database-backed API keys: /api/v1/apikeys/generate
+	// Security issues with config-based keys:
+	//   - No audit trail (no last_used tracking)
+	//   - Cannot revoke without server restart
+	//   - Easily leaked in version control
+	//   - No expiration support
+	// If using hashed keys in config, check against config hashes
+	config := s.config()
+	if config.Auth.UseHashedKeys {
+		for _, hash := range config.Auth.APIKeyHashes {
+			if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(apiKey)); err == nil {
+				return "", true
+			}
+		}
+		return "", false
+	}
+
+	// DEPRECATED: Fall back to plain text comparison in config (legacy)
+	for _, key := range config.Auth.APIKeys {
+		if apiKey == key {
+			return "", true
+		}
+	}
+
+	return "", false
+}
+
+// loggingMiddleware logs all HTTP requests
+func (s *ComplianceServer) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Create response writer wrapper to capture status code
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(wrapped, r)
+
+		duration := time.Since(start)
+
+		s.logger.Info("HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+			"status", wrapped.statusCode,
+			"duration", duration.Milliseconds(),
+			"request_id", requestID(r),
+		)
+	})
+}
+
+// responseWriter wraps http.ResponseWriter to capture status code
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// migrationFiles holds the schema migrations. Each version has an up file
+// and, unless it cannot be undone, a down file:
+//
+//	migrations/0002_add_client_tags.up.sql
+//	migrations/0002_add_client_tags.down.sql
+//
+// Applied migrations must never be edited; add a new version instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the PostgreSQL advisory lock key held while a
+// migration runs, so replicas starting together apply each one once
+const migrationLockID = 0x636f6d706c69
+
+// migrationFileName matches "<version>_<name>.<up|down>.sql"
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// migration is one versioned schema change
+type migration struct {
+	Version int
+	Name    string
+	Up      safesql.Query
+	Down    safesql.Query // Empty if the migration cannot be rolled back
+}
+
+// Checksum identifies the up script, so an applied migration whose file was
+// changed afterwards can be detected
+func (m migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// loadMigrations reads the migrations in fsys, ordered by version
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version < 1 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		script, err := safesql.ReadFile(fsys, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = script
+		} else {
+			m.Down = script
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up.String() == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// pendingMigrations returns the migrations not yet applied, in the order
+// they are applied
+func pendingMigrations(migrations []migration, applied map[int]appliedMigration) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// rollbackMigrations returns the last steps applied migrations, newest
+// first. It fails if one of them cannot be rolled back or is no longer
+// known to this server version.
+func rollbackMigrations(migrations []migration, applied map[int]appliedMigration, steps int) ([]migration, error) {
+	known := make(map[int]migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	var rollback []migration
+	for _, version := range versions[:min(steps, len(versions))] {
+		m, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d was applied by a newer server version", version)
+		}
+		if m.Down.String() == "" {
+			return nil, fmt.Errorf("migration %04d_%s cannot be rolled back", m.Version, m.Name)
+		}
+		rollback = append(rollback, m)
+	}
+	return rollback, nil
+}
+
+// modifiedMigrations returns the applied migrations whose up script has
+// changed since it was applied
+func modifiedMigrations(migrations []migration, applied map[int]appliedMigration) []migration {
+	var modified []migration
+	for _, m := range migrations {
+		if a, ok := applied[m.Version]; ok && a.Checksum != m.Checksum() {
+			modified = append(modified, m)
+		}
+	}
+	return modified
+}
+
+// ensureMigrationsTable creates the table recording applied migrations
+func (d *Database) ensureMigrationsTable() error {
+	_, err := d.db.Exec(safesql.New(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the migrations recorded in schema_migrations
+func (d *Database) appliedMigrations() (map[int]appliedMigration, error) {
+	if err := d.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(safesql.New(`SELECT version, name, checksum, applied_at FROM schema_migrations`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp applies every pending migration, each in its own transaction.
+// With dryRun nothing is changed. It returns the migrations applied, or
+// that would be applied.
+func (d *Database) MigrateUp(dryRun bool) ([]migration, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range modifiedMigrations(migrations, applied) {
+		d.logger.Warn("Applied migration has been modified since; the schema may differ from its script",
+			"version", m.Version, "name", m.Name)
+	}
+
+	pending := pendingMigrations(migrations, applied)
+	if dryRun {
+		return pending, nil
+	}
+
+	var done []migration
+	for _, m := range pending {
+		ran, err := d.runMigration(m, m.Up, true)
+		if err != nil {
+			return done, err
+		}
+		if !ran {
+			d.logger.Info("Migration already applied by another server", "version", m.Version, "name", m.Name)
+			continue
+		}
+		done = append(done, m)
+		d.logger.Info("Applied migration", "version", m.Version, "name", m.Name)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the last steps applied migrations, newest first.
+// With dryRun nothing is changed. It returns the migrations rolled back, or
+// that would be rolled back.
+func (d *Database) MigrateDown(steps int, dryRun bool) ([]migration, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rollback, err := rollbackMigrations(migrations, applied, steps)
+	if err != nil || dryRun {
+		return rollback, err
+	}
+
+	var done []migration
+	for _, m := range rollback {
+		ran, err := d.runMigration(m, m.Down, false)
+		if err != nil {
+			return done, err
+		}
+		if !ran {
+			continue
+		}
+		done = append(done, m)
+		d.logger.Info("Rolled back migration", "version", m.Version, "name", m.Name)
+	}
+	return done, nil
+}
+
+// PendingMigrationCount returns how many migrations have not been applied
+func (d *Database) PendingMigrationCount() (int, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(pendingMigrations(migrations, applied)), nil
+}
+
+// runMigration executes one migration script and records (up) or removes
+// (down) it in schema_migrations in the same transaction. The transaction
+// holds an advisory lock, and the migration is skipped (false) when another
+// server applied or rolled it back while this one waited for the lock.
+func (d *Database) runMigration(m migration, script safesql.Query, up bool) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(safesql.New(`SELECT pg_advisory_xact_lock($1)`, migrationLockID)); err != nil {
+		return false, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	var recorded bool
+	err = tx.QueryRow(safesql.New(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version)).Scan(&recorded)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if recorded != up {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return false, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(safesql.New(`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, m.Version, m.Name, m.Checksum()))
+	} else {
+		_, err = tx.Exec(safesql.New(`DELETE FROM schema_migrations WHERE version = $1`, m.Version))
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	return true, tx.Commit()
+}
+
+// runMigrateCommand runs --migrate up, down or status and prints the
+// outcome. It returns false on failure.
+func runMigrateCommand(config *ServerConfig, command string, steps int, dryRun bool) bool {
+	if command == "down" && steps < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --migrate-steps must be at least 1")
+		return false
+	}
+
+	db, err := connectDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	var done []migration
+	verb := "Applied"
+	switch command {
+	case "up":
+		done, err = db.MigrateUp(dryRun)
+	case "down":
+		verb = "Rolled back"
+		done, err = db.MigrateDown(steps, dryRun)
+	case "status":
+		err = db.printMigrationStatus()
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --migrate command %q (use up, down or status)\n", command)
+		return false
+	}
+
+	if dryRun {
+		verb = "Would apply"
+		if command == "down" {
+			verb = "Would roll back"
+		}
+	}
+	for _, m := range done {
+		fmt.Printf("  %s %04d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if command != "status" && len(done) == 0 {
+		fmt.Println("Nothing to do; the schema is up to date")
+	}
+	return true
+}
+
+// printMigrationStatus lists every known migration and whether it has been
+// applied
+func (d *Database) printMigrationStatus() error {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	modified := make(map[int]bool)
+	for _, m := range modifiedMigrations(migrations, applied) {
+		modified[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		a, ok := applied[m.Version]
+		switch {
+		case !ok:
+			fmt.Printf("  pending   %04d_%s\n", m.Version, m.Name)
+		case modified[m.Version]:
+			fmt.Printf("  modified  %04d_%s (applied %s, script changed since)\n", m.Version, m.Name, a.AppliedAt.Format(time.RFC3339))
+		default:
+			fmt.Printf("  applied   %04d_%s (%s)\n", m.Version, m.Name, a.AppliedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+-- Drops every table of the baseline schema, and with it all data
+DROP TABLE IF EXISTS auth_audit_log;
+DROP TABLE IF EXISTS jwt_blacklist;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS command_audit;
+DROP TABLE IF EXISTS client_commands;
+DROP TABLE IF EXISTS maintenance_windows;
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS client_policies;
+DROP TABLE IF EXISTS policies;
+DROP TABLE IF EXISTS agent_telemetry;
+DROP TABLE IF EXISTS submissions;
+DROP TABLE IF EXISTS clients;
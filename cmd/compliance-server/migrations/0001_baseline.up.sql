@@ -0,0 +1,268 @@
+-- Baseline schema. Every statement is idempotent so that databases created
+-- before migrations were tracked are adopted without changes.
+
+-- Clients table
+CREATE TABLE IF NOT EXISTS clients (
+    id SERIAL PRIMARY KEY,
+    client_id TEXT UNIQUE NOT NULL,
+    hostname TEXT NOT NULL,
+    first_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    os_version TEXT,
+    build_number TEXT,
+    architecture TEXT,
+    domain TEXT,
+    ip_address TEXT,
+    mac_address TEXT,
+    status TEXT DEFAULT 'active',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Submissions table
+CREATE TABLE IF NOT EXISTS submissions (
+    id SERIAL PRIMARY KEY,
+    submission_id TEXT UNIQUE NOT NULL,
+    client_id TEXT NOT NULL,
+    hostname TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    report_type TEXT NOT NULL,
+    report_version TEXT,
+    overall_status TEXT,
+    total_checks INTEGER DEFAULT 0,
+    passed_checks INTEGER DEFAULT 0,
+    failed_checks INTEGER DEFAULT 0,
+    warning_checks INTEGER DEFAULT 0,
+    error_checks INTEGER DEFAULT 0,
+    compliance_data TEXT,  -- JSON
+    evidence TEXT,         -- JSON array
+    system_info TEXT,      -- JSON
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id)
+);
+
+-- Agent telemetry reported alongside submissions
+CREATE TABLE IF NOT EXISTS agent_telemetry (
+    submission_id TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    agent_version TEXT,
+    scan_duration_ms BIGINT DEFAULT 0,
+    check_count INTEGER DEFAULT 0,
+    check_p95_ms DOUBLE PRECISION DEFAULT 0,
+    check_max_ms DOUBLE PRECISION DEFAULT 0,
+    cache_backlog INTEGER DEFAULT 0,
+    retry_count INTEGER DEFAULT 0,
+    memory_alloc_bytes BIGINT DEFAULT 0,
+    memory_sys_bytes BIGINT DEFAULT 0,
+    FOREIGN KEY (submission_id) REFERENCES submissions(submission_id) ON DELETE CASCADE
+);
+
+-- Policies table
+CREATE TABLE IF NOT EXISTS policies (
+    id SERIAL PRIMARY KEY,
+    policy_id TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    framework TEXT,  -- NIST, FIPS, CIS, etc.
+    version TEXT,
+    category TEXT,
+    author TEXT,
+    status TEXT DEFAULT 'active',  -- active, inactive, draft
+    policy_data TEXT NOT NULL,  -- JSON policy configuration
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Client policy assignments (for future use)
+CREATE TABLE IF NOT EXISTS client_policies (
+    id SERIAL PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    policy_id TEXT NOT NULL,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    assigned_by TEXT,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id),
+    FOREIGN KEY (policy_id) REFERENCES policies(policy_id),
+    UNIQUE(client_id, policy_id)
+);
+
+-- Users table for authentication
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL CHECK(role IN ('admin', 'viewer', 'auditor')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login TIMESTAMP
+);
+
+-- API Keys table for secure key management
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    key_prefix TEXT NOT NULL,  -- First 8 chars for display
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used TIMESTAMP,
+    expires_at TIMESTAMP,
+    is_active BOOLEAN DEFAULT true
+);
+
+-- Alerts raised by background checks (missed scheduled runs, ...)
+CREATE TABLE IF NOT EXISTS alerts (
+    id SERIAL PRIMARY KEY,
+    alert_type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    client_id TEXT,
+    hostname TEXT,
+    report_type TEXT,
+    message TEXT NOT NULL,
+    dedupe_key TEXT UNIQUE NOT NULL,  -- Prevents the same condition being raised twice
+    expected_at TIMESTAMP,
+    last_success_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    acknowledged BOOLEAN DEFAULT false,
+    acknowledged_by TEXT,
+    resolved_at TIMESTAMP
+);
+
+-- Maintenance windows suppressing alerts for the clients they cover
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT,
+    client_id TEXT,         -- Empty for all clients
+    hostname_pattern TEXT,  -- Glob matched against hostnames
+    cron TEXT,              -- Recurring start; empty for one-off windows
+    duration_minutes INTEGER DEFAULT 0,
+    timezone TEXT,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    enabled BOOLEAN DEFAULT true,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Commands queued for clients, delivered in heartbeat responses
+CREATE TABLE IF NOT EXISTS client_commands (
+    id SERIAL PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    command_type TEXT NOT NULL,
+    payload TEXT,  -- JSON command arguments
+    status TEXT NOT NULL DEFAULT 'pending',  -- awaiting_approval, rejected, pending, delivered, completed, failed
+    message TEXT,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
+);
+
+-- Audit trail of every step in the life of a command
+CREATE TABLE IF NOT EXISTS command_audit (
+    id SERIAL PRIMARY KEY,
+    command_id INTEGER NOT NULL,
+    event TEXT NOT NULL,  -- staged, approved, rejected, queued, delivered, completed, failed
+    actor TEXT,
+    details TEXT,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes for performance
+CREATE INDEX IF NOT EXISTS idx_submissions_client_id ON submissions(client_id);
+CREATE INDEX IF NOT EXISTS idx_submissions_timestamp ON submissions(timestamp);
+CREATE INDEX IF NOT EXISTS idx_submissions_report_type ON submissions(report_type);
+CREATE INDEX IF NOT EXISTS idx_agent_telemetry_client_id ON agent_telemetry(client_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_clients_status ON clients(status);
+CREATE INDEX IF NOT EXISTS idx_policies_framework ON policies(framework);
+CREATE INDEX IF NOT EXISTS idx_policies_status ON policies(status);
+CREATE INDEX IF NOT EXISTS idx_client_policies_client_id ON client_policies(client_id);
+CREATE INDEX IF NOT EXISTS idx_client_policies_policy_id ON client_policies(policy_id);
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+CREATE INDEX IF NOT EXISTS idx_alerts_client_id ON alerts(client_id, alert_type);
+CREATE INDEX IF NOT EXISTS idx_alerts_resolved_at ON alerts(resolved_at);
+CREATE INDEX IF NOT EXISTS idx_client_commands_client_id ON client_commands(client_id, status);
+CREATE INDEX IF NOT EXISTS idx_command_audit_command_id ON command_audit(command_id);
+
+-- JWT Authentication Tables (Phase 1 Migration)
+
+-- Refresh tokens table for JWT authentication
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL,
+    token_family TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used TIMESTAMP,
+    revoked BOOLEAN DEFAULT false,
+    revoked_at TIMESTAMP,
+    revoked_reason TEXT,
+    user_agent TEXT,
+    ip_address TEXT,
+    device_fingerprint TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- JWT blacklist for immediate token revocation
+CREATE TABLE IF NOT EXISTS jwt_blacklist (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    blacklisted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reason TEXT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Auth audit log for security monitoring
+CREATE TABLE IF NOT EXISTS auth_audit_log (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER,
+    username TEXT,
+    event_type TEXT NOT NULL,
+    auth_method TEXT,
+    ip_address TEXT,
+    user_agent TEXT,
+    success BOOLEAN,
+    failure_reason TEXT,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    metadata TEXT
+);
+
+-- JWT-specific indexes
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_revoked ON refresh_tokens(revoked) WHERE revoked = false;
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token_family ON refresh_tokens(token_family);
+CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_expires_at ON jwt_blacklist(expires_at);
+CREATE INDEX IF NOT EXISTS idx_jwt_blacklist_user_id ON jwt_blacklist(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_log_user_id ON auth_audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_log_timestamp ON auth_audit_log(timestamp);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_log_event_type ON auth_audit_log(event_type);
+
+-- Columns added to users for JWT authentication
+ALTER TABLE users ADD COLUMN IF NOT EXISTS jwt_version INTEGER DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS account_locked_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_enabled BOOLEAN DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_secret TEXT;
+
+-- Columns added after the initial schema: client schedules reported by
+-- heartbeats, maintenance flags on submissions, command output and
+-- approvals, machine fingerprints
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS last_heartbeat TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS agent_version TEXT;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS schedule_enabled BOOLEAN DEFAULT false;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS schedule_cron TEXT;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS schedule_utc_offset INTEGER DEFAULT 0;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS schedule_reports TEXT;  -- JSON array of report types
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS schedule_updated_at TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS capabilities TEXT;  -- JSON array of command types
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS fingerprint TEXT;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS during_maintenance BOOLEAN DEFAULT false;
+ALTER TABLE client_commands ADD COLUMN IF NOT EXISTS output TEXT;
+ALTER TABLE client_commands ADD COLUMN IF NOT EXISTS approved_by TEXT;
+ALTER TABLE client_commands ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_clients_fingerprint ON clients(fingerprint);
@@ -0,0 +1,8 @@
+-- Operators cannot be represented without a scope, so they are removed
+DROP INDEX IF EXISTS idx_clients_domain;
+DROP TABLE IF EXISTS client_tags;
+DELETE FROM users WHERE role = 'operator';
+ALTER TABLE users DROP COLUMN IF EXISTS scope_tags;
+ALTER TABLE users DROP COLUMN IF EXISTS scope_orgs;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'viewer', 'auditor'));
@@ -0,0 +1,16 @@
+-- Operators: users limited to the clients in their scope. A scope lists
+-- organizations (client domains) and client tags, stored as JSON arrays.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'operator', 'viewer', 'auditor'));
+ALTER TABLE users ADD COLUMN scope_orgs TEXT;
+ALTER TABLE users ADD COLUMN scope_tags TEXT;
+
+-- Client tags, set by admins
+CREATE TABLE client_tags (
+    client_id TEXT NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (client_id, tag)
+);
+
+CREATE INDEX idx_client_tags_tag ON client_tags(tag);
+CREATE INDEX idx_clients_domain ON clients(LOWER(domain));
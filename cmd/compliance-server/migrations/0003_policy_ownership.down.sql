@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_policies_owner_team;
+DROP INDEX IF EXISTS idx_policies_owner;
+ALTER TABLE policies DROP COLUMN IF EXISTS owner_team;
+ALTER TABLE policies DROP COLUMN IF EXISTS owner;
+ALTER TABLE users DROP COLUMN IF EXISTS team;
@@ -0,0 +1,9 @@
+-- Policy ownership: a policy may be owned by a user, a team or both. Only
+-- its owners and admins may change it; policies without an owner (all
+-- existing ones) can only be changed by admins.
+ALTER TABLE users ADD COLUMN team TEXT;
+ALTER TABLE policies ADD COLUMN owner TEXT REFERENCES users(username) ON DELETE SET NULL;
+ALTER TABLE policies ADD COLUMN owner_team TEXT;
+
+CREATE INDEX idx_policies_owner ON policies(owner);
+CREATE INDEX idx_policies_owner_team ON policies(owner_team);
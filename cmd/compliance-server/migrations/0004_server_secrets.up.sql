@@ -0,0 +1,8 @@
+-- Secrets every server replica must agree on (JWT signing secret, command
+-- signing key). The first replica to start generates each one; the others
+-- read it back, so no replica depends on its own disk.
+CREATE TABLE server_secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
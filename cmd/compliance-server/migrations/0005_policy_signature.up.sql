@@ -0,0 +1,4 @@
+-- Publisher signature of a policy's report configuration. Clients verify
+-- it against a key they pin; unsigned policies are signed by the server
+-- with its command signing key when downloaded.
+ALTER TABLE policies ADD COLUMN signature TEXT;
@@ -0,0 +1,3 @@
+-- Where an imported policy came from: the signed policy pack, its source
+-- URL and the key that verified it, as JSON (api.PolicyProvenance)
+ALTER TABLE policies ADD COLUMN provenance TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_submissions_report_type_created;
+ALTER TABLE submissions DROP COLUMN IF EXISTS summary_only;
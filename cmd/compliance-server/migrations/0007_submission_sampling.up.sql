@@ -0,0 +1,6 @@
+-- Submissions whose evidence records and check details were dropped by
+-- evidence sampling (sampling.enabled)
+ALTER TABLE submissions ADD COLUMN summary_only BOOLEAN DEFAULT FALSE;
+
+-- Counts the full submissions of a report type stored today
+CREATE INDEX IF NOT EXISTS idx_submissions_report_type_created ON submissions(report_type, created_at);
@@ -0,0 +1,49 @@
+ALTER TABLE submissions RENAME TO submissions_partitioned;
+
+CREATE TABLE submissions (
+    id INTEGER NOT NULL DEFAULT nextval('submissions_id_seq'),
+    submission_id TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    hostname TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    report_type TEXT NOT NULL,
+    report_version TEXT,
+    overall_status TEXT,
+    total_checks INTEGER DEFAULT 0,
+    passed_checks INTEGER DEFAULT 0,
+    failed_checks INTEGER DEFAULT 0,
+    warning_checks INTEGER DEFAULT 0,
+    error_checks INTEGER DEFAULT 0,
+    compliance_data TEXT,
+    evidence TEXT,
+    system_info TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    during_maintenance BOOLEAN DEFAULT false,
+    summary_only BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id)
+);
+
+ALTER SEQUENCE submissions_id_seq OWNED BY submissions.id;
+
+-- Duplicate submission IDs in different months keep their oldest row
+INSERT INTO submissions
+SELECT DISTINCT ON (submission_id)
+       id, submission_id, client_id, hostname, timestamp, report_type, report_version,
+       overall_status, total_checks, passed_checks, failed_checks, warning_checks, error_checks,
+       compliance_data, evidence, system_info, created_at, during_maintenance, summary_only
+FROM submissions_partitioned
+ORDER BY submission_id, timestamp;
+
+-- Dropping the parent drops every partition
+DROP TABLE submissions_partitioned;
+
+ALTER TABLE submissions ADD PRIMARY KEY (id);
+ALTER TABLE submissions ADD CONSTRAINT submissions_submission_id_key UNIQUE (submission_id);
+CREATE INDEX idx_submissions_client_id ON submissions(client_id);
+CREATE INDEX idx_submissions_timestamp ON submissions(timestamp);
+CREATE INDEX idx_submissions_report_type ON submissions(report_type);
+CREATE INDEX idx_submissions_report_type_created ON submissions(report_type, created_at);
+
+DELETE FROM agent_telemetry WHERE submission_id NOT IN (SELECT submission_id FROM submissions);
+ALTER TABLE agent_telemetry ADD CONSTRAINT agent_telemetry_submission_id_fkey
+    FOREIGN KEY (submission_id) REFERENCES submissions(submission_id) ON DELETE CASCADE;
@@ -0,0 +1,81 @@
+-- Partition submissions by month of their timestamp, so queries over recent
+-- months read only those partitions however many years are retained.
+-- PostgreSQL cannot partition a table in place: the rows are copied into a
+-- new partitioned table. The server creates the partitions of coming months
+-- (see partitions.go); rows outside every monthly partition, such as those
+-- from clients with a wrong clock, land in submissions_default.
+
+-- A foreign key can only reference a unique constraint that includes the
+-- partition key; the server deletes telemetry with its submissions instead
+ALTER TABLE agent_telemetry DROP CONSTRAINT IF EXISTS agent_telemetry_submission_id_fkey;
+
+ALTER TABLE submissions RENAME TO submissions_unpartitioned;
+
+CREATE TABLE submissions (
+    id INTEGER NOT NULL DEFAULT nextval('submissions_id_seq'),
+    submission_id TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    hostname TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    report_type TEXT NOT NULL,
+    report_version TEXT,
+    overall_status TEXT,
+    total_checks INTEGER DEFAULT 0,
+    passed_checks INTEGER DEFAULT 0,
+    failed_checks INTEGER DEFAULT 0,
+    warning_checks INTEGER DEFAULT 0,
+    error_checks INTEGER DEFAULT 0,
+    compliance_data TEXT,  -- JSON
+    evidence TEXT,         -- JSON array
+    system_info TEXT,      -- JSON
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    during_maintenance BOOLEAN DEFAULT false,
+    summary_only BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (client_id) REFERENCES clients(client_id)
+) PARTITION BY RANGE (timestamp);
+
+ALTER SEQUENCE submissions_id_seq OWNED BY submissions.id;
+
+CREATE TABLE submissions_default PARTITION OF submissions DEFAULT;
+
+-- One partition per month from the oldest submission to next month
+DO $$
+DECLARE
+    partition_month DATE;
+BEGIN
+    FOR partition_month IN
+        SELECT generate_series(
+            date_trunc('month', LEAST(COALESCE(MIN(timestamp), CURRENT_DATE), CURRENT_DATE)),
+            date_trunc('month', CURRENT_DATE) + INTERVAL '1 month',
+            INTERVAL '1 month'
+        )::date
+        FROM submissions_unpartitioned
+    LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF submissions FOR VALUES FROM (%L) TO (%L)',
+            'submissions_' || to_char(partition_month, 'YYYY_MM'), partition_month, (partition_month + INTERVAL '1 month')::date);
+    END LOOP;
+END $$;
+
+INSERT INTO submissions (
+    id, submission_id, client_id, hostname, timestamp, report_type, report_version,
+    overall_status, total_checks, passed_checks, failed_checks, warning_checks, error_checks,
+    compliance_data, evidence, system_info, created_at, during_maintenance, summary_only
+)
+SELECT id, submission_id, client_id, hostname, timestamp, report_type, report_version,
+       overall_status, total_checks, passed_checks, failed_checks, warning_checks, error_checks,
+       compliance_data, evidence, system_info, created_at, during_maintenance, summary_only
+FROM submissions_unpartitioned;
+
+DROP TABLE submissions_unpartitioned;
+
+-- Added once the old table's submissions_pkey is gone
+ALTER TABLE submissions ADD PRIMARY KEY (id, timestamp);
+
+-- Submission IDs are unique per partition; the server checks for duplicates
+-- before storing
+CREATE UNIQUE INDEX idx_submissions_submission_id ON submissions(submission_id, timestamp);
+CREATE INDEX idx_submissions_client_id ON submissions(client_id);
+CREATE INDEX idx_submissions_client_timestamp ON submissions(client_id, timestamp);
+CREATE INDEX idx_submissions_timestamp ON submissions(timestamp);
+CREATE INDEX idx_submissions_report_type ON submissions(report_type);
+CREATE INDEX idx_submissions_report_type_created ON submissions(report_type, created_at);
@@ -0,0 +1,4 @@
+-- Time zone (IANA name) and locale the dashboard shows a user's timestamps
+-- in; empty uses the server default (dashboard.timezone) or the browser's
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
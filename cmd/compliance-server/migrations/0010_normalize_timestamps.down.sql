@@ -0,0 +1,3 @@
+-- Normalized timestamps stay TIMESTAMP; the text formats they were
+-- converted from are not restored
+DROP FUNCTION IF EXISTS compliance_parse_timestamp(TEXT);
@@ -0,0 +1,103 @@
+-- Timestamp columns hold UTC in TIMESTAMP (without time zone) columns.
+-- Databases adopted from older versions may have some as text, in whatever
+-- format was written (SQLite DATETIME, RFC 3339, Go's time.Time.String), or
+-- as TIMESTAMPTZ. Convert those in place; columns already TIMESTAMP are left
+-- alone. submissions.timestamp was rebuilt as TIMESTAMP by 0008.
+
+-- Parses a legacy text timestamp to UTC. Text without an offset is read as
+-- UTC (sessions run in UTC); unreadable text becomes NULL.
+CREATE OR REPLACE FUNCTION compliance_parse_timestamp(value TEXT) RETURNS TIMESTAMP AS $$
+DECLARE
+    cleaned TEXT := btrim(value);
+BEGIN
+    IF cleaned IS NULL OR cleaned = '' THEN
+        RETURN NULL;
+    END IF;
+    -- time.Time.String: monotonic clock reading and zone abbreviation
+    cleaned := regexp_replace(cleaned, ' m=[+-][0-9.]+$', '');
+    cleaned := regexp_replace(cleaned, '([+-][0-9]{4}) [A-Za-z]+$', '\1');
+    IF cleaned ~ '^[0-9]+$' THEN
+        RETURN to_timestamp(cleaned::BIGINT) AT TIME ZONE 'UTC';
+    END IF;
+    RETURN cleaned::TIMESTAMPTZ AT TIME ZONE 'UTC';
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DO $$
+DECLARE
+    col RECORD;
+    converted TEXT;
+BEGIN
+    FOR col IN
+        SELECT c.table_name, c.column_name, c.data_type,
+               c.column_default IS NOT NULL AS has_default,
+               c.is_nullable = 'NO' AS not_null
+        FROM information_schema.columns c
+        JOIN (VALUES
+            ('clients', 'first_seen'),
+            ('clients', 'last_seen'),
+            ('clients', 'created_at'),
+            ('clients', 'last_heartbeat'),
+            ('clients', 'schedule_updated_at'),
+            ('submissions', 'created_at'),
+            ('agent_telemetry', 'timestamp'),
+            ('policies', 'created_at'),
+            ('policies', 'updated_at'),
+            ('client_policies', 'assigned_at'),
+            ('users', 'created_at'),
+            ('users', 'last_login'),
+            ('users', 'password_changed_at'),
+            ('users', 'account_locked_until'),
+            ('api_keys', 'created_at'),
+            ('api_keys', 'last_used'),
+            ('api_keys', 'expires_at'),
+            ('alerts', 'expected_at'),
+            ('alerts', 'last_success_at'),
+            ('alerts', 'created_at'),
+            ('alerts', 'resolved_at'),
+            ('maintenance_windows', 'starts_at'),
+            ('maintenance_windows', 'ends_at'),
+            ('maintenance_windows', 'created_at'),
+            ('client_commands', 'created_at'),
+            ('client_commands', 'approved_at'),
+            ('client_commands', 'delivered_at'),
+            ('client_commands', 'completed_at'),
+            ('command_audit', 'timestamp'),
+            ('refresh_tokens', 'expires_at'),
+            ('refresh_tokens', 'created_at'),
+            ('refresh_tokens', 'last_used'),
+            ('refresh_tokens', 'revoked_at'),
+            ('jwt_blacklist', 'expires_at'),
+            ('jwt_blacklist', 'blacklisted_at'),
+            ('auth_audit_log', 'timestamp'),
+            ('server_secrets', 'created_at')
+        ) AS t(table_name, column_name)
+          ON c.table_name = t.table_name AND c.column_name = t.column_name
+        WHERE c.table_schema = current_schema()
+          AND c.data_type <> 'timestamp without time zone'
+    LOOP
+        IF col.data_type = 'timestamp with time zone' THEN
+            converted := format('%I AT TIME ZONE ''UTC''', col.column_name);
+        ELSIF col.not_null THEN
+            -- Required columns get the epoch for unreadable text
+            converted := format('COALESCE(compliance_parse_timestamp(%I::TEXT), ''epoch''::TIMESTAMP)', col.column_name);
+        ELSE
+            converted := format('compliance_parse_timestamp(%I::TEXT)', col.column_name);
+        END IF;
+
+        -- A text default such as CURRENT_TIMESTAMP::text cannot be cast
+        IF col.has_default THEN
+            EXECUTE format('ALTER TABLE %I ALTER COLUMN %I DROP DEFAULT', col.table_name, col.column_name);
+        END IF;
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMP USING %s', col.table_name, col.column_name, converted);
+        IF col.has_default THEN
+            EXECUTE format('ALTER TABLE %I ALTER COLUMN %I SET DEFAULT CURRENT_TIMESTAMP', col.table_name, col.column_name);
+        END IF;
+
+        RAISE NOTICE 'Converted %.% from % to timestamp', col.table_name, col.column_name, col.data_type;
+    END LOOP;
+END $$;
+
+DROP FUNCTION compliance_parse_timestamp(TEXT);
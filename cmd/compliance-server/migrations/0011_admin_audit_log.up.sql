@@ -0,0 +1,16 @@
+-- Administrative changes (users, API keys, policies, settings, cleared
+-- client history) with the object as JSON before and after the change
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id SERIAL PRIMARY KEY,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor TEXT NOT NULL,
+    remote_addr TEXT,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target TEXT,
+    before_value TEXT,
+    after_value TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_timestamp ON admin_audit_log(timestamp);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target ON admin_audit_log(target_type, target);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS must_change_password;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS archived_submissions;
+DROP TABLE IF EXISTS submission_archives;
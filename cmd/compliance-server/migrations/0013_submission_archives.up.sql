@@ -0,0 +1,21 @@
+-- Files the retention job archived expired submissions to, and which
+-- submissions each one holds, so a deleted submission can still be found
+CREATE TABLE IF NOT EXISTS submission_archives (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    path TEXT NOT NULL,
+    submission_count INTEGER NOT NULL,
+    oldest TIMESTAMP NOT NULL,
+    newest TIMESTAMP NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS archived_submissions (
+    submission_id TEXT PRIMARY KEY,
+    archive_id INTEGER NOT NULL REFERENCES submission_archives(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_submissions_archive ON archived_submissions(archive_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS report_type_rollups;
+DROP TABLE IF EXISTS client_rollups;
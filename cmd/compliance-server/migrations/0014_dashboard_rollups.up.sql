@@ -0,0 +1,41 @@
+-- Rollups the dashboard summary is served from instead of scanning
+-- submissions. The server maintains them as submissions are stored and
+-- deleted (rollups.go); they are filled here from the submissions stored so
+-- far.
+
+-- Each client's latest submission
+CREATE TABLE IF NOT EXISTS client_rollups (
+    client_id TEXT PRIMARY KEY REFERENCES clients(client_id) ON DELETE CASCADE,
+    submission_id TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    overall_status TEXT
+);
+
+-- Per client and report type totals; score_sum adds each scored
+-- submission's passed percentage
+CREATE TABLE IF NOT EXISTS report_type_rollups (
+    client_id TEXT NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    report_type TEXT NOT NULL,
+    submissions INTEGER NOT NULL DEFAULT 0,
+    compliant INTEGER NOT NULL DEFAULT 0,
+    noncompliant INTEGER NOT NULL DEFAULT 0,
+    score_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+    scored INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (client_id, report_type)
+);
+
+INSERT INTO client_rollups (client_id, submission_id, timestamp, overall_status)
+SELECT DISTINCT ON (client_id) client_id, submission_id, timestamp, overall_status
+FROM submissions
+ORDER BY client_id, timestamp DESC, id DESC
+ON CONFLICT (client_id) DO NOTHING;
+
+INSERT INTO report_type_rollups (client_id, report_type, submissions, compliant, noncompliant, score_sum, scored)
+SELECT client_id, report_type, COUNT(*),
+       SUM(CASE WHEN overall_status = 'compliant' THEN 1 ELSE 0 END),
+       SUM(CASE WHEN overall_status != 'compliant' THEN 1 ELSE 0 END),
+       COALESCE(SUM(passed_checks * 100.0 / NULLIF(total_checks, 0)), 0),
+       COUNT(NULLIF(total_checks, 0))
+FROM submissions
+GROUP BY client_id, report_type
+ON CONFLICT (client_id, report_type) DO NOTHING;
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS notify_digest;
+ALTER TABLE users DROP COLUMN IF EXISTS notify_key_expiry;
+ALTER TABLE users DROP COLUMN IF EXISTS notify_alerts;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,6 @@
+-- Profile fields users maintain themselves: a display name and which email
+-- notifications they receive (email itself was added by 0012)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_alerts BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_key_expiry BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_digest TEXT NOT NULL DEFAULT 'off';
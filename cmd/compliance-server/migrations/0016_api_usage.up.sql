@@ -0,0 +1,18 @@
+-- Requests made with each database API key or by each user, rolled up per
+-- endpoint (the route pattern) and UTC day. Exactly one of api_key_id and
+-- user_id is set.
+CREATE TABLE IF NOT EXISTS api_usage (
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id INTEGER REFERENCES api_keys(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,  -- Responses with status 400 or above
+    last_used TIMESTAMP NOT NULL,
+    CHECK ((api_key_id IS NULL) != (user_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_usage_key ON api_usage(api_key_id, day, endpoint) WHERE api_key_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_usage_user ON api_usage(user_id, day, endpoint) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_api_usage_day ON api_usage(day);
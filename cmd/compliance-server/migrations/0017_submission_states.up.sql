@@ -0,0 +1,12 @@
+-- The full check results of each client's latest submission per report
+-- type, which delta submissions are rebuilt from. Kept apart from the
+-- submissions so evidence sampling and retention do not touch them.
+CREATE TABLE IF NOT EXISTS submission_states (
+    client_id TEXT NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    report_type TEXT NOT NULL,
+    submission_id TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    state_hash TEXT NOT NULL,  -- api.QueryStateHash of queries
+    queries JSONB NOT NULL,    -- Check results without durations
+    PRIMARY KEY (client_id, report_type)
+);
@@ -0,0 +1,3 @@
+-- When the creator of an API key was warned that it expires soon, so each
+-- key is warned about once
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP;
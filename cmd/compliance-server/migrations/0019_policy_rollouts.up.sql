@@ -0,0 +1,18 @@
+-- Candidate versions of policies staged to a cohort of clients. The policy
+-- row keeps the current version, served to everyone else, until the
+-- candidate is promoted into it.
+CREATE TABLE IF NOT EXISTS policy_rollouts (
+    policy_id TEXT PRIMARY KEY REFERENCES policies(policy_id) ON DELETE CASCADE,
+    version TEXT NOT NULL,
+    policy_data TEXT NOT NULL,
+    signature TEXT,
+    percent INTEGER NOT NULL DEFAULT 0,  -- Share of clients, by a stable hash of policy and client ID
+    rings TEXT,                          -- JSON array of client tags always in the cohort
+    max_failure_increase DOUBLE PRECISION NOT NULL,
+    min_submissions INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',  -- active or halted
+    halt_reason TEXT,
+    started_by TEXT,
+    started_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS alert_rules;
+ALTER TABLE client_policies DROP CONSTRAINT IF EXISTS client_policies_policy_id_fkey;
+ALTER TABLE client_policies ADD CONSTRAINT client_policies_policy_id_fkey
+    FOREIGN KEY (policy_id) REFERENCES policies(policy_id);
+DROP TABLE IF EXISTS group_policies;
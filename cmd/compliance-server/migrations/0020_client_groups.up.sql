@@ -0,0 +1,30 @@
+-- Client groups are client tags. Policies and alert rules can be assigned
+-- to a group as well as to single clients (client_policies).
+CREATE TABLE IF NOT EXISTS group_policies (
+    tag TEXT NOT NULL,
+    policy_id TEXT NOT NULL REFERENCES policies(policy_id) ON DELETE CASCADE,
+    assigned_by TEXT,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tag, policy_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_policies_policy_id ON group_policies(policy_id);
+
+-- Deleting a policy removes its assignments to single clients too
+ALTER TABLE client_policies DROP CONSTRAINT IF EXISTS client_policies_policy_id_fkey;
+ALTER TABLE client_policies ADD CONSTRAINT client_policies_policy_id_fkey
+    FOREIGN KEY (policy_id) REFERENCES policies(policy_id) ON DELETE CASCADE;
+
+-- Alert rules override the alert settings of server.yaml for the clients of
+-- a group: they mute an alert type or change its grace and severity
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    alert_type TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT true,  -- false mutes the alert type for the group
+    severity TEXT,                          -- Empty keeps the alert type's severity
+    grace_minutes INTEGER,                  -- Empty keeps the configured grace
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (alert_type, tag)
+);
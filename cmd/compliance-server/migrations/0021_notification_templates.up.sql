@@ -0,0 +1,12 @@
+-- Notification templates replace the built-in wording of notification
+-- emails for the users of an organization (org), or for everyone when org
+-- is empty
+CREATE TABLE IF NOT EXISTS notification_templates (
+    name TEXT NOT NULL,           -- password_reset, invitation, api_key_expiry
+    org TEXT NOT NULL DEFAULT '', -- Lower-cased client domain; empty for the server default
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    updated_by TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (name, org)
+);
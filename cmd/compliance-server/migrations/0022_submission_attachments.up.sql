@@ -0,0 +1,17 @@
+-- Supplementary evidence files (screenshots, exported GPO reports) attached
+-- to submissions. The files are kept in the attachment store under
+-- storage_key; rows whose submission is deleted are removed with their files.
+CREATE TABLE IF NOT EXISTS submission_attachments (
+    id SERIAL PRIMARY KEY,
+    submission_id TEXT NOT NULL,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL, -- Sniffed from the content, not taken from the upload
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    uploaded_by TEXT,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_attachments_submission ON submission_attachments(submission_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_submissions_metadata;
+ALTER TABLE submissions DROP COLUMN IF EXISTS metadata;
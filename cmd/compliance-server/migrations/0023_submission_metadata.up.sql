@@ -0,0 +1,6 @@
+-- Site-specific metadata clients attach to submissions (metadata.fields),
+-- as a JSON object of field names and values
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- Answers metadata containment (@>) searches
+CREATE INDEX IF NOT EXISTS idx_submissions_metadata ON submissions USING GIN (metadata jsonb_path_ops);
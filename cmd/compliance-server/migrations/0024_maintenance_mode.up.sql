@@ -0,0 +1,11 @@
+-- Server-wide maintenance mode, a single row shared by every server replica.
+-- It can be switched with compliance-server maintenance on|off while the
+-- servers run, ahead of migrations and upgrades.
+CREATE TABLE IF NOT EXISTS maintenance_mode (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL DEFAULT '',
+    retry_after_seconds INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP,
+    started_by TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,4 @@
+ALTER TABLE agent_telemetry DROP COLUMN IF EXISTS agent_build_hash;
+ALTER TABLE agent_telemetry DROP COLUMN IF EXISTS agent_builder;
+ALTER TABLE agent_telemetry DROP COLUMN IF EXISTS agent_build_time;
+ALTER TABLE agent_telemetry DROP COLUMN IF EXISTS agent_commit;
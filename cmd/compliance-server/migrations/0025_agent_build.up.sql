@@ -0,0 +1,6 @@
+-- The agent build that produced each submission (telemetry.build), so
+-- evidence can be traced to the exact binary that collected it
+ALTER TABLE agent_telemetry ADD COLUMN IF NOT EXISTS agent_commit TEXT;
+ALTER TABLE agent_telemetry ADD COLUMN IF NOT EXISTS agent_build_time TEXT;
+ALTER TABLE agent_telemetry ADD COLUMN IF NOT EXISTS agent_builder TEXT;
+ALTER TABLE agent_telemetry ADD COLUMN IF NOT EXISTS agent_build_hash TEXT;
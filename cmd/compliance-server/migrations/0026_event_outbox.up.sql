@@ -0,0 +1,22 @@
+-- Outbox of the event bus: one row per event and consumer, kept until the
+-- consumer has handled the event (delivered_at) or it was given up after
+-- events.max_attempts (failed_at). Replicas claim due rows with
+-- FOR UPDATE SKIP LOCKED, and a claim holds a row for a lease by moving
+-- next_attempt_at ahead.
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    consumer TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_due ON event_outbox (next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_outbox_occurred ON event_outbox (occurred_at);
@@ -0,0 +1,14 @@
+-- Versions of policies replaced by updates, so the policy editor can show
+-- what changed and earlier policy data can be restored. The stored policy is
+-- the current version; revision numbers count up from 1 per policy.
+CREATE TABLE IF NOT EXISTS policy_revisions (
+    policy_id TEXT NOT NULL REFERENCES policies(policy_id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    version TEXT,
+    status TEXT,
+    policy_data TEXT NOT NULL,
+    saved_at TIMESTAMP NOT NULL,
+    superseded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (policy_id, revision)
+);
@@ -0,0 +1 @@
+ALTER TABLE policies DROP COLUMN IF EXISTS retention_days;
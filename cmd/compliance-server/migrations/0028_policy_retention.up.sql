@@ -0,0 +1,3 @@
+-- How long submissions of a policy's report type are kept, overriding the
+-- server's retention limits for them (NULL follows the limits)
+ALTER TABLE policies ADD COLUMN IF NOT EXISTS retention_days INTEGER CHECK (retention_days > 0);
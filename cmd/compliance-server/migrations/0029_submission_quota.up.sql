@@ -0,0 +1,3 @@
+-- Counts a client's submissions of the last hour and day for the
+-- submission quotas (submission_quota.per_hour and per_day)
+CREATE INDEX IF NOT EXISTS idx_submissions_client_created ON submissions(client_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE maintenance_windows DROP COLUMN IF EXISTS tag;
@@ -0,0 +1,2 @@
+-- Maintenance windows can cover a client group (client tag)
+ALTER TABLE maintenance_windows ADD COLUMN IF NOT EXISTS tag TEXT;
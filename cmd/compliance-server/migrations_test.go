@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// TestEmbeddedMigrations tests that the shipped migrations load and are
+// numbered without gaps
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want %d", i, m.Version, i+1)
+		}
+	}
+}
+
+// TestLoadMigrations tests parsing migration file names and pairing up and down scripts
+func TestLoadMigrations(t *testing.T) {
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []int
+		wantErr      bool
+	}{
+		{"ordered by version", fstest.MapFS{
+			"migrations/0010_add_tags.up.sql":    file("ALTER TABLE clients ADD COLUMN tags TEXT;"),
+			"migrations/0002_add_index.up.sql":   file("CREATE INDEX idx ON clients(hostname);"),
+			"migrations/0002_add_index.down.sql": file("DROP INDEX idx;"),
+			"migrations/0001_baseline.up.sql":    file("CREATE TABLE clients (id SERIAL);"),
+			"migrations/0001_baseline.down.sql":  file("DROP TABLE clients;"),
+		}, []int{1, 2, 10}, false},
+		{"invalid name", fstest.MapFS{"migrations/add_tags.sql": file("")}, nil, true},
+		{"down without up", fstest.MapFS{"migrations/0001_baseline.down.sql": file("DROP TABLE clients;")}, nil, true},
+		{"version used twice", fstest.MapFS{
+			"migrations/0001_baseline.up.sql": file("CREATE TABLE a (id INT);"),
+			"migrations/0001_other.up.sql":    file("CREATE TABLE b (id INT);"),
+		}, nil, true},
+		{"version zero", fstest.MapFS{"migrations/0000_baseline.up.sql": file("SELECT 1;")}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(migrations) != len(tt.wantVersions) {
+				t.Fatalf("loadMigrations() returned %d migrations, want %d", len(migrations), len(tt.wantVersions))
+			}
+			for i, m := range migrations {
+				if m.Version != tt.wantVersions[i] {
+					t.Errorf("migration %d version = %d, want %d", i, m.Version, tt.wantVersions[i])
+				}
+			}
+		})
+	}
+}
+
+// TestMigrationPlans tests choosing migrations to apply, roll back and flag as modified
+func TestMigrationPlans(t *testing.T) {
+	migrations := []migration{
+		{Version: 1, Name: "baseline", Up: safesql.New("CREATE TABLE a (id INT);"), Down: safesql.New("DROP TABLE a;")},
+		{Version: 2, Name: "irreversible", Up: safesql.New("UPDATE a SET id = 0;")},
+		{Version: 3, Name: "add_b", Up: safesql.New("CREATE TABLE b (id INT);"), Down: safesql.New("DROP TABLE b;")},
+	}
+	applied := func(versions ...int) map[int]appliedMigration {
+		m := make(map[int]appliedMigration)
+		for _, v := range versions {
+			a := appliedMigration{Version: v}
+			if v <= len(migrations) {
+				a.Checksum = migrations[v-1].Checksum()
+			}
+			m[v] = a
+		}
+		return m
+	}
+	versions := func(ms []migration) []int {
+		var v []int
+		for _, m := range ms {
+			v = append(v, m.Version)
+		}
+		return v
+	}
+	equal := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		if got := versions(pendingMigrations(migrations, applied())); !equal(got, []int{1, 2, 3}) {
+			t.Errorf("fresh database pending = %v, want [1 2 3]", got)
+		}
+		if got := versions(pendingMigrations(migrations, applied(1))); !equal(got, []int{2, 3}) {
+			t.Errorf("pending = %v, want [2 3]", got)
+		}
+		if got := pendingMigrations(migrations, applied(1, 2, 3)); len(got) != 0 {
+			t.Errorf("up to date pending = %v, want none", versions(got))
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		got, err := rollbackMigrations(migrations, applied(1, 2, 3), 1)
+		if err != nil || !equal(versions(got), []int{3}) {
+			t.Errorf("rollback 1 = %v, %v, want [3]", versions(got), err)
+		}
+		if _, err := rollbackMigrations(migrations, applied(1, 2, 3), 2); err == nil {
+			t.Error("rolling back an irreversible migration should fail")
+		}
+		if _, err := rollbackMigrations(migrations, applied(1, 2, 3, 4), 1); err == nil {
+			t.Error("rolling back a migration from a newer version should fail")
+		}
+		got, err = rollbackMigrations(migrations, applied(1), 5)
+		if err != nil || !equal(versions(got), []int{1}) {
+			t.Errorf("rollback past the first = %v, %v, want [1]", versions(got), err)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		changed := applied(1, 3)
+		changed[3] = appliedMigration{Version: 3, Checksum: "edited"}
+		if got := versions(modifiedMigrations(migrations, changed)); !equal(got, []int{3}) {
+			t.Errorf("modified = %v, want [3]", got)
+		}
+	})
+}
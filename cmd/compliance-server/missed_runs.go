@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// alertTypeMissedRun identifies alerts raised when a scheduled run does not
+// arrive. It is kept separate from client staleness: a client can keep sending
+// heartbeats and still fail to produce the reports it is scheduled to run.
+const alertTypeMissedRun = "missed_run"
+
+// startMissedRunMonitor periodically checks client schedules for missed runs
+func (s *ComplianceServer) startMissedRunMonitor() {
+	alerts := s.config().Alerts
+	if !alerts.MissedRunCheck {
+		return
+	}
+
+	s.logger.Info("Starting missed run monitor",
+		"interval", alerts.MissedRunInterval,
+		"grace", alerts.MissedRunGrace,
+	)
+
+	go func() {
+		ticker := time.NewTicker(alerts.MissedRunInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.checkMissedRuns(time.Now())
+		}
+	}()
+}
+
+// checkMissedRuns raises an alert for every scheduled report whose expected
+// run is more than the grace period overdue. The alert rules of a client's
+// groups may mute the alerts or change their grace and severity.
+func (s *ComplianceServer) checkMissedRuns(now time.Time) {
+	schedules, err := s.db.ListClientSchedules()
+	if err != nil {
+		s.logger.Error("Failed to list client schedules", "error", err)
+		return
+	}
+
+	windows, err := s.db.ListMaintenanceWindows()
+	if err != nil {
+		s.logger.Warn("Failed to load maintenance windows", "error", err)
+	}
+
+	// Alert rules of the clients' groups mute missed run alerts or change
+	// their grace and severity
+	rules, err := s.db.ListAlertRules()
+	if err != nil {
+		s.logger.Warn("Failed to load alert rules", "error", err)
+	}
+	var clientTags map[string][]string
+	if len(rules) > 0 || maintenanceWindowsUseGroups(windows) {
+		if clientTags, err = s.db.ListClientTags(); err != nil {
+			s.logger.Warn("Failed to load client tags", "error", err)
+		}
+	}
+
+	for _, clientSchedule := range schedules {
+		behavior := resolveAlertRules(rules, alertTypeMissedRun, clientTags[clientSchedule.ClientID])
+		if behavior.muted {
+			continue
+		}
+		grace := s.config().Alerts.MissedRunGrace
+		if behavior.grace > 0 {
+			grace = behavior.grace
+		}
+
+		schedule, err := cron.ParseStandard(clientSchedule.Cron)
+		if err != nil {
+			s.logger.Warn("Client reported an invalid schedule",
+				"client_id", clientSchedule.ClientID,
+				"cron", clientSchedule.Cron,
+				"error", err,
+			)
+			continue
+		}
+
+		lastRuns, err := s.db.GetLastSubmissionTimes(clientSchedule.ClientID)
+		if err != nil {
+			s.logger.Error("Failed to get last submission times", "client_id", clientSchedule.ClientID, "error", err)
+			continue
+		}
+
+		location := time.FixedZone("client", clientSchedule.UTCOffset)
+		inMaintenance := func(t time.Time) bool {
+			return findMaintenanceWindow(windows, clientSchedule.ClientID, clientSchedule.Hostname, clientTags[clientSchedule.ClientID], t) != nil
+		}
+
+		for _, reportType := range clientSchedule.Reports {
+			lastRun := lastRuns[reportType]
+
+			expected, missed := missedRun(schedule, lastRun, clientSchedule.Since, now, grace, location, inMaintenance)
+			if !missed {
+				continue
+			}
+
+			alert := missedRunAlert(clientSchedule, reportType, expected, lastRun, now)
+			if behavior.severity != "" {
+				alert.Severity = behavior.severity
+			}
+			dedupeKey := fmt.Sprintf("%s:%s:%s:%s", alertTypeMissedRun, clientSchedule.ClientID, reportType, expected.UTC().Format(time.RFC3339))
+
+			created, err := s.raiseAlert(alert, dedupeKey)
+			if err != nil {
+				s.logger.Error("Failed to create missed run alert", "client_id", clientSchedule.ClientID, "error", err)
+				continue
+			}
+			if created {
+				s.logger.Warn("Scheduled run missed",
+					"client_id", clientSchedule.ClientID,
+					"hostname", clientSchedule.Hostname,
+					"report_type", reportType,
+					"expected_at", expected,
+					"last_success", formatLastRun(lastRun),
+				)
+			}
+		}
+	}
+}
+
+// maxSuppressedRuns bounds how many consecutive runs inside maintenance
+// windows are skipped when looking for a missed run
+const maxSuppressedRuns = 1000
+
+// missedRun returns the first scheduled run after the last successful one
+// when that run is more than grace overdue. Runs scheduled before since (when
+// the schedule was reported or changed) are never expected, and runs for which
+// suppressed returns true (maintenance windows) are skipped. The schedule is
+// evaluated in the client's location because cron expressions are local time.
+func missedRun(schedule cron.Schedule, lastRun, since, now time.Time, grace time.Duration, location *time.Location, suppressed func(time.Time) bool) (time.Time, bool) {
+	reference := since
+	if lastRun.After(reference) {
+		reference = lastRun
+	}
+	if reference.IsZero() {
+		return time.Time{}, false
+	}
+
+	expected := schedule.Next(reference.In(location))
+	for i := 0; suppressed != nil && !expected.IsZero() && suppressed(expected); i++ {
+		if i == maxSuppressedRuns || expected.After(now) {
+			return time.Time{}, false
+		}
+		expected = schedule.Next(expected)
+	}
+	if expected.IsZero() || now.Before(expected.Add(grace)) {
+		return time.Time{}, false
+	}
+	return expected, true
+}
+
+// missedRunAlert builds the alert naming the report that was not delivered
+// and the client's last successful run of it
+func missedRunAlert(clientSchedule ClientSchedule, reportType string, expected, lastRun, now time.Time) *api.Alert {
+	alert := &api.Alert{
+		Timestamp:  now,
+		Severity:   "warning",
+		Type:       alertTypeMissedRun,
+		ClientID:   clientSchedule.ClientID,
+		Hostname:   clientSchedule.Hostname,
+		ReportType: reportType,
+		ExpectedAt: &expected,
+		Message: fmt.Sprintf("%s missed its scheduled %s run expected at %s (last successful run: %s)",
+			clientSchedule.Hostname, reportType, expected.Format(time.RFC3339), formatLastRun(lastRun)),
+	}
+	if !lastRun.IsZero() {
+		alert.LastSuccess = &lastRun
+	}
+	return alert
+}
+
+// formatLastRun describes the last successful run for alert messages
+func formatLastRun(lastRun time.Time) string {
+	if lastRun.IsZero() {
+		return "never"
+	}
+	return lastRun.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// TestMissedRun tests detection of scheduled runs that did not arrive in time
+func TestMissedRun(t *testing.T) {
+	daily, err := cron.ParseStandard("0 2 * * *")
+	if err != nil {
+		t.Fatalf("ParseStandard() error = %v", err)
+	}
+
+	// Client clock is UTC-5; 02:00 local is 07:00 UTC
+	eastern := time.FixedZone("client", -5*60*60)
+	day := func(d, h, m int) time.Time { return time.Date(2025, 3, d, h, m, 0, 0, time.UTC) }
+	grace := 2 * time.Hour
+
+	tests := []struct {
+		name     string
+		lastRun  time.Time
+		since    time.Time
+		now      time.Time
+		location *time.Location
+		want     time.Time
+		missed   bool
+	}{
+		{"run delivered", day(10, 2, 5), day(1, 0, 0), day(10, 12, 0), time.UTC, time.Time{}, false},
+		{"within grace", day(9, 2, 5), day(1, 0, 0), day(10, 3, 59), time.UTC, time.Time{}, false},
+		{"past grace", day(9, 2, 5), day(1, 0, 0), day(10, 4, 1), time.UTC, day(10, 2, 0), true},
+		{"reports first missed run", day(5, 2, 5), day(1, 0, 0), day(10, 12, 0), time.UTC, day(6, 2, 0), true},
+		{"never ran", time.Time{}, day(9, 12, 0), day(10, 4, 1), time.UTC, day(10, 2, 0), true},
+		{"schedule changed after last run", day(5, 2, 5), day(10, 1, 0), day(10, 3, 0), time.UTC, time.Time{}, false},
+		{"no reference", time.Time{}, time.Time{}, day(10, 12, 0), time.UTC, time.Time{}, false},
+		{"client local time", day(9, 7, 5), day(1, 0, 0), day(10, 8, 59), eastern, time.Time{}, false},
+		{"client local time missed", day(9, 7, 5), day(1, 0, 0), day(10, 9, 1), eastern, day(10, 7, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missed := missedRun(daily, tt.lastRun, tt.since, tt.now, grace, tt.location, nil)
+			if missed != tt.missed {
+				t.Fatalf("missedRun() missed = %v, want %v", missed, tt.missed)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("missedRun() expected = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMissedRunAlert tests that missed run alerts name the report and last successful run
+func TestMissedRunAlert(t *testing.T) {
+	schedule := ClientSchedule{ClientID: "client-1", Hostname: "WS-01"}
+	expected := time.Date(2025, 3, 10, 2, 0, 0, 0, time.UTC)
+	lastRun := time.Date(2025, 3, 9, 2, 4, 0, 0, time.UTC)
+
+	alert := missedRunAlert(schedule, "NIST 800-171", expected, lastRun, expected.Add(3*time.Hour))
+	if alert.Type != alertTypeMissedRun || alert.ReportType != "NIST 800-171" {
+		t.Errorf("alert type/report = %q/%q", alert.Type, alert.ReportType)
+	}
+	for _, want := range []string{"WS-01", "NIST 800-171", "2025-03-10T02:00:00Z", "2025-03-09T02:04:00Z"} {
+		if !strings.Contains(alert.Message, want) {
+			t.Errorf("Message = %q, missing %q", alert.Message, want)
+		}
+	}
+	if alert.LastSuccess == nil || !alert.LastSuccess.Equal(lastRun) {
+		t.Errorf("LastSuccess = %v, want %v", alert.LastSuccess, lastRun)
+	}
+
+	neverRan := missedRunAlert(schedule, "NIST 800-171", expected, time.Time{}, expected.Add(3*time.Hour))
+	if neverRan.LastSuccess != nil || !strings.Contains(neverRan.Message, "last successful run: never") {
+		t.Errorf("never-run alert = %+v", neverRan)
+	}
+}
@@ -0,0 +1,707 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// Media types the data endpoints can produce
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeCSV  = "text/csv"
+	mediaTypeHTML = "text/html"
+)
+
+// responseFormat identifies the representation chosen for a response
+type responseFormat int
+
+const (
+	formatJSON responseFormat = iota
+	formatCSV
+	formatHTML
+)
+
+// csvTable is implemented by response payloads that have a tabular CSV form,
+// which is also served as an HTML table. Column names match the JSON field
+// names so the representations stay in sync.
+type csvTable interface {
+	csvHeader() []string
+	csvRows() [][]string
+}
+
+// negotiateFormat selects the response format for a request.
+// An explicit ?format=json|csv|html query parameter wins over the Accept
+// header so that plain download links work from the browser. JSON is the
+// default and is preferred over CSV when both are equally acceptable; HTML is
+// chosen only when preferred over both, as browsers navigating to an endpoint
+// do. CSV and HTML are offered for tabular responses only. The second return
+// value is false when the client accepts none of the offered formats.
+func negotiateFormat(r *http.Request, tableAllowed bool) (responseFormat, bool) {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "json":
+		return formatJSON, true
+	case "csv":
+		return formatCSV, tableAllowed
+	case "html":
+		return formatHTML, tableAllowed
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, true
+	}
+
+	jsonQ := acceptQuality(accept, mediaTypeJSON)
+	csvQ, htmlQ := 0.0, 0.0
+	if tableAllowed {
+		csvQ = acceptQuality(accept, mediaTypeCSV)
+		htmlQ = acceptQuality(accept, mediaTypeHTML)
+	}
+
+	switch {
+	case jsonQ == 0 && csvQ == 0 && htmlQ == 0:
+		return formatJSON, false
+	case htmlQ > jsonQ && htmlQ > csvQ:
+		return formatHTML, true
+	case csvQ > jsonQ:
+		return formatCSV, true
+	default:
+		return formatJSON, true
+	}
+}
+
+// acceptQuality returns the q-value an Accept header assigns to mediaType,
+// honouring the most specific matching media range (type/subtype, type/*, */*).
+func acceptQuality(accept, mediaType string) float64 {
+	mainType := strings.SplitN(mediaType, "/", 2)[0]
+
+	bestSpecificity := -1
+	quality := 0.0
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+
+		specificity := -1
+		switch mediaRange {
+		case mediaType:
+			specificity = 2
+		case mainType + "/*":
+			specificity = 1
+		case "*/*":
+			specificity = 0
+		}
+		if specificity < bestSpecificity || specificity < 0 {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+
+		bestSpecificity = specificity
+		quality = q
+	}
+
+	return quality
+}
+
+// neutralizeFormula prefixes cells a spreadsheet would evaluate as a
+// formula. Hostnames, check names and messages come from clients and must
+// not run as formulas when a CSV response is opened.
+func neutralizeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// respond writes data in the representation negotiated with the client.
+// Pass a nil table for endpoints that have no CSV or HTML table
+// representation.
+func (s *ComplianceServer) respond(w http.ResponseWriter, r *http.Request, data interface{}, table csvTable) {
+	contentType, body, ok := s.render(w, r, data, table)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// render negotiates the response format and encodes data into it. When the
+// client accepts none of the offered formats a 406 is written and ok is false.
+func (s *ComplianceServer) render(w http.ResponseWriter, r *http.Request, data interface{}, table csvTable) (contentType string, body []byte, ok bool) {
+	w.Header().Add("Vary", "Accept")
+
+	format, ok := negotiateFormat(r, table != nil)
+	if !ok {
+		supported := mediaTypeJSON
+		if table != nil {
+			supported += ", " + mediaTypeCSV + ", " + mediaTypeHTML
+		}
+		s.sendError(w, http.StatusNotAcceptable, "Supported media types: "+supported)
+		return "", nil, false
+	}
+
+	var buf bytes.Buffer
+	if format == formatCSV {
+		writer := csv.NewWriter(&buf)
+		writer.Write(table.csvHeader())
+		rows := table.csvRows()
+		for _, row := range rows {
+			for i, cell := range row {
+				row[i] = neutralizeFormula(cell)
+			}
+		}
+		writer.WriteAll(rows)
+		if err := writer.Error(); err != nil {
+			s.logger.Error("Failed to write CSV response", "error", err)
+		}
+		return mediaTypeCSV + "; charset=utf-8", buf.Bytes(), true
+	}
+	if format == formatHTML {
+		if err := renderHTMLTable(&buf, r, table); err != nil {
+			s.logger.Error("Failed to write HTML response", "error", err)
+		}
+		// The page needs no scripts; refusing them keeps it usable under
+		// strict browser policies and safe from injected markup
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		return mediaTypeHTML + "; charset=utf-8", s.withMaintenanceBanner(buf.Bytes()), true
+	}
+
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		s.logger.Error("Failed to encode JSON response", "error", err)
+	}
+	return mediaTypeJSON, buf.Bytes(), true
+}
+
+// formatCSVTime renders timestamps for CSV output, leaving zero values empty
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatCSVTimePtr renders optional timestamps for CSV output
+func formatCSVTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatCSVTime(*t)
+}
+
+// formatCSVFloat renders scores and rates with fixed precision
+func formatCSVFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// clientsTable is the CSV form of a client list
+type clientsTable []api.ClientInfo
+
+func (t clientsTable) csvHeader() []string {
+	return []string{
+		"client_id", "hostname", "status", "first_seen", "last_seen",
+		"compliance_score", "last_submission_id", "os_version", "build_number",
+		"architecture", "domain", "ip_address", "maintenance_window",
+	}
+}
+
+func (t clientsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, c := range t {
+		rows = append(rows, []string{
+			c.ClientID,
+			c.Hostname,
+			c.Status,
+			formatCSVTime(c.FirstSeen),
+			formatCSVTime(c.LastSeen),
+			formatCSVFloat(c.ComplianceScore),
+			c.LastSubmission,
+			c.SystemInfo.OSVersion,
+			c.SystemInfo.BuildNumber,
+			c.SystemInfo.Architecture,
+			c.SystemInfo.Domain,
+			c.SystemInfo.IPAddress,
+			c.MaintenanceWindow,
+		})
+	}
+	return rows
+}
+
+// submissionsTable is the CSV form of a submission summary list
+type submissionsTable []api.SubmissionSummary
+
+func (t submissionsTable) csvHeader() []string {
+	return []string{
+		"submission_id", "client_id", "hostname", "timestamp", "report_type",
+		"overall_status", "total_checks", "passed_checks", "failed_checks",
+		"during_maintenance", "summary_only", "metadata",
+	}
+}
+
+func (t submissionsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, sub := range t {
+		rows = append(rows, []string{
+			sub.SubmissionID,
+			sub.ClientID,
+			sub.Hostname,
+			formatCSVTime(sub.Timestamp),
+			sub.ReportType,
+			sub.OverallStatus,
+			strconv.Itoa(sub.TotalChecks),
+			strconv.Itoa(sub.PassedChecks),
+			strconv.Itoa(sub.FailedChecks),
+			strconv.FormatBool(sub.DuringMaintenance),
+			strconv.FormatBool(sub.SummaryOnly),
+			formatMetadata(sub.Metadata),
+		})
+	}
+	return rows
+}
+
+// complianceByTypeTable is the CSV form of the dashboard summary, one row per
+// report type. Rows are sorted so repeated exports diff cleanly.
+type complianceByTypeTable map[string]api.ComplianceStats
+
+func (t complianceByTypeTable) csvHeader() []string {
+	return []string{"report_type", "total_submissions", "average_score", "pass_rate", "fail_rate"}
+}
+
+func (t complianceByTypeTable) csvRows() [][]string {
+	reportTypes := make([]string, 0, len(t))
+	for reportType := range t {
+		reportTypes = append(reportTypes, reportType)
+	}
+	sort.Strings(reportTypes)
+
+	rows := make([][]string, 0, len(t))
+	for _, reportType := range reportTypes {
+		stats := t[reportType]
+		rows = append(rows, []string{
+			reportType,
+			strconv.Itoa(stats.TotalSubmissions),
+			formatCSVFloat(stats.AverageScore),
+			formatCSVFloat(stats.PassRate),
+			formatCSVFloat(stats.FailRate),
+		})
+	}
+	return rows
+}
+
+// policiesTable is the CSV form of a policy list. The policy_data document is
+// omitted because it is a nested JSON payload rather than a tabular value.
+type policiesTable []Policy
+
+func (t policiesTable) csvHeader() []string {
+	return []string{
+		"policy_id", "name", "description", "framework", "version",
+		"category", "author", "status", "created_at", "updated_at",
+	}
+}
+
+func (t policiesTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, p := range t {
+		rows = append(rows, []string{
+			p.PolicyID,
+			p.Name,
+			p.Description,
+			p.Framework,
+			p.Version,
+			p.Category,
+			p.Author,
+			p.Status,
+			p.CreatedAt,
+			p.UpdatedAt,
+		})
+	}
+	return rows
+}
+
+// telemetryTable is the CSV form of a client's agent telemetry history
+type telemetryTable []api.TelemetryPoint
+
+func (t telemetryTable) csvHeader() []string {
+	return []string{
+		"submission_id", "timestamp", "report_type", "agent_version", "scan_duration_ms",
+		"check_count", "check_p95_ms", "check_max_ms", "cache_backlog", "retry_count",
+		"memory_alloc_bytes", "memory_sys_bytes",
+	}
+}
+
+func (t telemetryTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, p := range t {
+		rows = append(rows, []string{
+			p.SubmissionID,
+			formatCSVTime(p.Timestamp),
+			p.ReportType,
+			p.AgentVersion,
+			strconv.FormatInt(p.ScanDurationMs, 10),
+			strconv.Itoa(p.CheckCount),
+			formatCSVFloat(p.CheckP95Ms),
+			formatCSVFloat(p.CheckMaxMs),
+			strconv.Itoa(p.CacheBacklog),
+			strconv.Itoa(p.RetryCount),
+			strconv.FormatUint(p.MemoryAllocBytes, 10),
+			strconv.FormatUint(p.MemorySysBytes, 10),
+		})
+	}
+	return rows
+}
+
+// alertsTable is the CSV form of an alert list
+type alertsTable []api.Alert
+
+func (t alertsTable) csvHeader() []string {
+	return []string{
+		"id", "timestamp", "severity", "type", "client_id", "hostname", "report_type",
+		"expected_at", "last_success_at", "acknowledged", "resolved_at", "message",
+	}
+}
+
+func (t alertsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, a := range t {
+		rows = append(rows, []string{
+			a.ID,
+			formatCSVTime(a.Timestamp),
+			a.Severity,
+			a.Type,
+			a.ClientID,
+			a.Hostname,
+			a.ReportType,
+			formatCSVTimePtr(a.ExpectedAt),
+			formatCSVTimePtr(a.LastSuccess),
+			strconv.FormatBool(a.Acknowledged),
+			formatCSVTimePtr(a.ResolvedAt),
+			a.Message,
+		})
+	}
+	return rows
+}
+
+// maintenanceWindowsTable is the CSV form of a maintenance window list
+type maintenanceWindowsTable []api.MaintenanceWindow
+
+func (t maintenanceWindowsTable) csvHeader() []string {
+	return []string{
+		"id", "name", "client_id", "hostname_pattern", "group", "cron", "duration_minutes",
+		"timezone", "starts_at", "ends_at", "enabled", "created_by", "created_at",
+	}
+}
+
+func (t maintenanceWindowsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, w := range t {
+		rows = append(rows, []string{
+			strconv.Itoa(w.ID),
+			w.Name,
+			w.ClientID,
+			w.HostnamePattern,
+			w.Group,
+			w.Cron,
+			strconv.Itoa(w.DurationMinutes),
+			w.Timezone,
+			formatCSVTimePtr(w.StartsAt),
+			formatCSVTimePtr(w.EndsAt),
+			strconv.FormatBool(w.Enabled),
+			w.CreatedBy,
+			w.CreatedAt,
+		})
+	}
+	return rows
+}
+
+// commandsTable is the CSV form of a command list
+type commandsTable []api.Command
+
+func (t commandsTable) csvHeader() []string {
+	return []string{
+		"id", "client_id", "type", "reports", "status", "message",
+		"created_by", "created_at", "approved_by", "approved_at", "delivered_at", "completed_at",
+	}
+}
+
+func (t commandsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, c := range t {
+		rows = append(rows, []string{
+			strconv.Itoa(c.ID),
+			c.ClientID,
+			c.Type,
+			strings.Join(c.Reports, ";"),
+			c.Status,
+			c.Message,
+			c.CreatedBy,
+			formatCSVTime(c.CreatedAt),
+			c.ApprovedBy,
+			formatCSVTimePtr(c.ApprovedAt),
+			formatCSVTimePtr(c.DeliveredAt),
+			formatCSVTimePtr(c.CompletedAt),
+		})
+	}
+	return rows
+}
+
+// commandAuditTable is the CSV form of a command's audit trail
+type commandAuditTable []api.CommandAuditEntry
+
+func (t commandAuditTable) csvHeader() []string {
+	return []string{"id", "command_id", "event", "actor", "details", "timestamp"}
+}
+
+func (t commandAuditTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, e := range t {
+		rows = append(rows, []string{
+			strconv.Itoa(e.ID),
+			strconv.Itoa(e.CommandID),
+			e.Event,
+			e.Actor,
+			e.Details,
+			formatCSVTime(e.Timestamp),
+		})
+	}
+	return rows
+}
+
+// adminAuditTable is the CSV form of the admin audit trail, with the before
+// and after values as JSON text
+type adminAuditTable []api.AdminAuditEntry
+
+func (t adminAuditTable) csvHeader() []string {
+	return []string{"id", "timestamp", "actor", "remote_addr", "action", "target_type", "target", "before", "after"}
+}
+
+func (t adminAuditTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, e := range t {
+		rows = append(rows, []string{
+			strconv.Itoa(e.ID),
+			formatCSVTime(e.Timestamp),
+			e.Actor,
+			e.RemoteAddr,
+			e.Action,
+			e.TargetType,
+			e.Target,
+			string(e.Before),
+			string(e.After),
+		})
+	}
+	return rows
+}
+
+// flakyChecksTable is the CSV form of the flaky check report, one row per check
+type flakyChecksTable []api.FlakyPolicy
+
+func (t flakyChecksTable) csvHeader() []string {
+	return []string{
+		"report_type", "check", "flips", "observations", "flip_rate",
+		"clients_affected", "last_flip", "client_ids",
+	}
+}
+
+func (t flakyChecksTable) csvRows() [][]string {
+	var rows [][]string
+	for _, policy := range t {
+		for _, c := range policy.Checks {
+			rows = append(rows, []string{
+				policy.ReportType,
+				c.Name,
+				strconv.Itoa(c.Flips),
+				strconv.Itoa(c.Observations),
+				formatCSVFloat(c.FlipRate),
+				strconv.Itoa(c.ClientsAffected),
+				formatCSVTime(c.LastFlip),
+				strings.Join(c.ClientIDs, ";"),
+			})
+		}
+	}
+	return rows
+}
+
+// policyViolationsTable is the CSV form of the policy violations report
+type policyViolationsTable []api.PolicyViolation
+
+func (t policyViolationsTable) csvHeader() []string {
+	return []string{
+		"report_type", "check", "root_key", "path", "value_name", "reason",
+		"attempts", "clients_affected", "last_seen", "client_ids",
+	}
+}
+
+func (t policyViolationsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, v := range t {
+		rows = append(rows, []string{
+			v.ReportType,
+			v.Name,
+			v.RootKey,
+			v.Path,
+			v.ValueName,
+			v.Reason,
+			strconv.Itoa(v.Attempts),
+			strconv.Itoa(v.ClientsAffected),
+			formatCSVTime(v.LastSeen),
+			strings.Join(v.ClientIDs, ";"),
+		})
+	}
+	return rows
+}
+
+// checkFailuresTable is the CSV form of a check's failing clients
+type checkFailuresTable []api.CheckFailure
+
+func (t checkFailuresTable) csvHeader() []string {
+	return []string{
+		"client_id", "hostname", "submission_id", "timestamp", "expected",
+		"expected_operator", "actual", "message", "summary_only",
+	}
+}
+
+func (t checkFailuresTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, f := range t {
+		rows = append(rows, []string{
+			f.ClientID,
+			f.Hostname,
+			f.SubmissionID,
+			formatCSVTime(f.Timestamp),
+			f.Expected,
+			f.Operator,
+			f.Actual,
+			f.Message,
+			strconv.FormatBool(f.SummaryOnly),
+		})
+	}
+	return rows
+}
+
+// checkValuesTable is the CSV form of a check's value distribution
+type checkValuesTable []api.CheckValueCount
+
+func (t checkValuesTable) csvHeader() []string {
+	return []string{"value", "clients", "passing", "failing", "percent"}
+}
+
+func (t checkValuesTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, v := range t {
+		rows = append(rows, []string{
+			v.Value,
+			strconv.Itoa(v.Clients),
+			strconv.Itoa(v.Passing),
+			strconv.Itoa(v.Failing),
+			formatCSVFloat(v.Percent),
+		})
+	}
+	return rows
+}
+
+// controlFamiliesTable is the CSV form of the control family pivot
+type controlFamiliesTable []api.ControlFamily
+
+func (t controlFamiliesTable) csvHeader() []string {
+	return []string{
+		"framework", "family", "controls", "checks", "passed", "failed",
+		"pass_rate", "clients_failing",
+	}
+}
+
+func (t controlFamiliesTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, f := range t {
+		rows = append(rows, []string{
+			f.Framework,
+			f.Family,
+			strings.Join(f.Controls, ";"),
+			strconv.Itoa(f.Checks),
+			strconv.Itoa(f.Passed),
+			strconv.Itoa(f.Failed),
+			formatCSVFloat(f.PassRate),
+			strconv.Itoa(f.ClientsFailing),
+		})
+	}
+	return rows
+}
+
+// checkPerformanceTable is the CSV form of the check performance report
+type checkPerformanceTable []api.CheckPerformance
+
+func (t checkPerformanceTable) csvHeader() []string {
+	return []string{
+		"report_type", "check", "executions", "avg_ms", "p95_ms", "max_ms",
+		"errors", "error_rate", "error_classes",
+	}
+}
+
+func (t checkPerformanceTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, c := range t {
+		classes := make([]string, 0, len(c.ErrorClasses))
+		for class, count := range c.ErrorClasses {
+			classes = append(classes, class+"="+strconv.Itoa(count))
+		}
+		sort.Strings(classes)
+
+		rows = append(rows, []string{
+			c.ReportType,
+			c.Name,
+			strconv.Itoa(c.Executions),
+			formatCSVFloat(c.AvgMs),
+			formatCSVFloat(c.P95Ms),
+			formatCSVFloat(c.MaxMs),
+			strconv.Itoa(c.Errors),
+			formatCSVFloat(c.ErrorRate),
+			strings.Join(classes, ";"),
+		})
+	}
+	return rows
+}
+
+// attachmentsTable is the CSV form of a submission's attachment list
+type attachmentsTable []api.Attachment
+
+func (t attachmentsTable) csvHeader() []string {
+	return []string{
+		"id", "submission_id", "file_name", "content_type", "size_bytes", "sha256",
+		"description", "uploaded_by", "uploaded_at",
+	}
+}
+
+func (t attachmentsTable) csvRows() [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, a := range t {
+		rows = append(rows, []string{
+			strconv.FormatInt(a.ID, 10),
+			a.SubmissionID,
+			a.FileName,
+			a.ContentType,
+			strconv.FormatInt(a.SizeBytes, 10),
+			a.SHA256,
+			a.Description,
+			a.UploadedBy,
+			formatCSVTime(a.UploadedAt),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestNegotiateFormat tests Accept header and query parameter negotiation
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		accept     string
+		csvAllowed bool
+		wantFormat responseFormat
+		wantOK     bool
+	}{
+		{"no accept header", "/api/v1/clients", "", true, formatJSON, true},
+		{"wildcard", "/api/v1/clients", "*/*", true, formatJSON, true},
+		{"explicit json", "/api/v1/clients", "application/json", true, formatJSON, true},
+		{"explicit csv", "/api/v1/clients", "text/csv", true, formatCSV, true},
+		{"csv preferred by q", "/api/v1/clients", "application/json;q=0.5, text/csv", true, formatCSV, true},
+		{"json preferred by q", "/api/v1/clients", "text/csv;q=0.2, application/json", true, formatJSON, true},
+		{"equal q prefers json", "/api/v1/clients", "text/csv, application/json", true, formatJSON, true},
+		{"csv not offered", "/api/v1/clients/abc", "text/csv", false, formatJSON, false},
+		{"csv not offered with fallback", "/api/v1/clients/abc", "text/csv, */*;q=0.1", false, formatJSON, true},
+		{"text wildcard", "/api/v1/clients", "text/*", true, formatCSV, true},
+		{"unsupported type", "/api/v1/clients", "application/xml", true, formatJSON, false},
+		{"json excluded", "/api/v1/clients", "application/json;q=0, */*", true, formatCSV, true},
+		{"query overrides header", "/api/v1/clients?format=csv", "application/json", true, formatCSV, true},
+		{"query json", "/api/v1/clients?format=json", "text/csv", true, formatJSON, true},
+		{"query csv not offered", "/api/v1/clients/abc?format=csv", "", false, formatCSV, false},
+		{"browser navigation", "/api/v1/clients", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true, formatHTML, true},
+		{"browser navigation without table", "/api/v1/clients/abc", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false, formatJSON, true},
+		{"text wildcard prefers csv to html", "/api/v1/clients", "text/*", true, formatCSV, true},
+		{"query html", "/api/v1/clients?format=html", "application/json", true, formatHTML, true},
+		{"query html not offered", "/api/v1/clients/abc?format=html", "", false, formatHTML, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			format, ok := negotiateFormat(r, tt.csvAllowed)
+			if ok != tt.wantOK {
+				t.Fatalf("negotiateFormat() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && format != tt.wantFormat {
+				t.Errorf("negotiateFormat() = %v, want %v", format, tt.wantFormat)
+			}
+		})
+	}
+}
+
+// TestComplianceByTypeTableSorted tests that CSV rows are emitted in a stable order
+func TestComplianceByTypeTableSorted(t *testing.T) {
+	table := complianceByTypeTable{
+		"nist_800_171": {TotalSubmissions: 2},
+		"cis_windows":  {TotalSubmissions: 1},
+	}
+
+	rows := table.csvRows()
+	if len(rows) != 2 {
+		t.Fatalf("csvRows() returned %d rows, want 2", len(rows))
+	}
+	if rows[0][0] != "cis_windows" || rows[1][0] != "nist_800_171" {
+		t.Errorf("csvRows() not sorted by report type: %v", rows)
+	}
+	if len(rows[0]) != len(table.csvHeader()) {
+		t.Errorf("row width %d does not match header width %d", len(rows[0]), len(table.csvHeader()))
+	}
+}
+
+// TestTableWidths tests that every row of the tabular responses has a cell
+// per column, as the CSV and HTML forms need
+func TestTableWidths(t *testing.T) {
+	tables := map[string]csvTable{
+		"clients":             clientsTable{{ClientID: "client-1"}},
+		"submissions":         submissionsTable{{SubmissionID: "sub-1"}},
+		"compliance_by_type":  complianceByTypeTable{"cis": {}},
+		"policies":            policiesTable{{PolicyID: "policy-1"}},
+		"telemetry":           telemetryTable{{SubmissionID: "sub-1"}},
+		"alerts":              alertsTable{{ID: "alert-1"}},
+		"maintenance_windows": maintenanceWindowsTable{{ID: 1}},
+		"commands":            commandsTable{{ID: 1}},
+		"command_audit":       commandAuditTable{{ID: 1}},
+		"admin_audit":         adminAuditTable{{}},
+		"flaky_checks":        flakyChecksTable{{}},
+		"policy_violations":   policyViolationsTable{{}},
+		"check_failures":      checkFailuresTable{{}},
+		"check_values":        checkValuesTable{{}},
+		"control_families":    controlFamiliesTable{{}},
+		"check_performance":   checkPerformanceTable{{}},
+		"attachments":         attachmentsTable{{}},
+	}
+	for name, table := range tables {
+		for _, row := range table.csvRows() {
+			if len(row) != len(table.csvHeader()) {
+				t.Errorf("%s: row width %d does not match header width %d", name, len(row), len(table.csvHeader()))
+			}
+		}
+	}
+}
+
+// TestCSVNeutralizesFormulas tests that CSV cells a spreadsheet would run as
+// a formula are prefixed
+func TestCSVNeutralizesFormulas(t *testing.T) {
+	s := newTestServer()
+	table := clientsTable{{ClientID: "client-1", Hostname: `=HYPERLINK("http://evil.example")`}}
+	r := httptest.NewRequest("GET", "/api/v1/clients?format=csv", nil)
+
+	_, body, ok := s.render(httptest.NewRecorder(), r, table, table)
+	if !ok {
+		t.Fatal("render() refused CSV")
+	}
+	if !strings.Contains(string(body), `'=HYPERLINK`) {
+		t.Errorf("formula not neutralized: %s", body)
+	}
+
+	for cell, want := range map[string]string{"+1": "'+1", "-1": "'-1", "@SUM(A1)": "'@SUM(A1)", "WS-01": "WS-01", "": ""} {
+		if got := neutralizeFormula(cell); got != want {
+			t.Errorf("neutralizeFormula(%q) = %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Notification emails whose wording admins can replace
+const (
+	notificationPasswordReset = "password_reset"
+	notificationInvitation    = "invitation"
+	notificationAPIKeyExpiry  = "api_key_expiry"
+)
+
+// Limits on stored notification templates. Templates cannot loop, so they
+// render in time proportional to their size; the timeout is a backstop.
+const (
+	maxNotificationSubjectSize = 512
+	maxNotificationBodySize    = 16 << 10
+	maxNotificationOutputSize  = 64 << 10
+	notificationRenderTimeout  = time.Second
+)
+
+// builtinNotification is the wording a notification email is sent with when
+// no template replaces it, and example values of its fields for previews
+type builtinNotification struct {
+	subject, body string
+	sample        map[string]string
+}
+
+// builtinNotifications are the built-in notification emails by name
+var builtinNotifications = map[string]builtinNotification{
+	notificationPasswordReset: {
+		subject: "Reset your Compliance Toolkit password",
+		body: `A password reset was requested for your Compliance Toolkit account (username {{.Username}}).
+
+Choose a new password before {{.ExpiresAt}} by opening:
+
+{{.ResetURL}}
+
+The link works once. If you did not ask for it, ignore this email; your password stays as it is.
+`,
+		sample: map[string]string{
+			"Username":  "alice",
+			"ExpiresAt": "20 Oct 2026 09:30 UTC",
+			"ResetURL":  "https://compliance.example.com/reset-password?token=example",
+		},
+	},
+	notificationInvitation: {
+		subject: "Your Compliance Toolkit account",
+		body: `You have been invited to the Compliance Toolkit server as {{.Role}} (username {{.Username}}).
+
+Choose your password before {{.ExpiresAt}} by opening:
+
+{{.InviteURL}}
+
+The link works once. If you did not expect this invitation, ignore this email.
+`,
+		sample: map[string]string{
+			"Username":  "alice",
+			"Role":      "viewer",
+			"ExpiresAt": "20 Oct 2026 09:30 UTC",
+			"InviteURL": "https://compliance.example.com/accept-invite?token=example",
+		},
+	},
+	notificationAPIKeyExpiry: {
+		subject: `API key {{printf "%q" .KeyName}} expires on {{.ExpiresOn}}`,
+		body: `The Compliance Toolkit API key {{printf "%q" .KeyName}} (prefix {{.KeyPrefix}}) that you created expires at {{.ExpiresAt}}.
+
+Clients and scripts using it will be refused from then on, and the key will be
+deactivated. Generate a replacement and update them before then:
+
+{{.SettingsURL}}
+`,
+		sample: map[string]string{
+			"KeyName":     "build-agents",
+			"KeyPrefix":   "ctk_ab12",
+			"ExpiresOn":   "20 Oct 2026",
+			"ExpiresAt":   "20 Oct 2026 09:30 UTC",
+			"SettingsURL": "https://compliance.example.com/settings",
+		},
+	},
+}
+
+// notificationTemplateFuncs are the functions templates may call besides
+// the allowed built-in ones
+var notificationTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// allowedTemplateFuncs are the functions templates may call. Leaving out
+// call keeps templates from running code, and leaving out range and template
+// actions (checked separately) from looping.
+var allowedTemplateFuncs = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"len": true, "print": true, "printf": true, "println": true,
+	"upper": true, "lower": true, "trim": true,
+}
+
+// parseNotificationTemplate parses the text of a notification subject or
+// body, refusing actions and functions outside the restricted set
+func parseNotificationTemplate(text string) (*template.Template, error) {
+	t, err := template.New("notification").
+		Funcs(notificationTemplateFuncs).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, defined := range t.Templates() {
+		if defined != t {
+			return nil, fmt.Errorf("templates cannot define other templates (%q)", defined.Name())
+		}
+	}
+	if t.Tree == nil {
+		return t, nil
+	}
+	if err := checkTemplateNode(t.Tree.Root); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkTemplateNode returns an error for the first loop, template call or
+// disallowed function under node
+func checkTemplateNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkTemplateNode(n.Pipe)
+	case *parse.IfNode:
+		return checkBranchNode(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranchNode(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				if err := checkTemplateNode(arg); err != nil {
+					return err
+				}
+			}
+		}
+	case *parse.ChainNode:
+		return checkTemplateNode(n.Node)
+	case *parse.IdentifierNode:
+		if !allowedTemplateFuncs[n.Ident] {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+	case *parse.RangeNode:
+		return fmt.Errorf("range is not allowed")
+	case *parse.TemplateNode:
+		return fmt.Errorf("template and block are not allowed")
+	}
+	return nil
+}
+
+// checkBranchNode checks the condition and both branches of an if or with
+func checkBranchNode(n *parse.BranchNode) error {
+	for _, node := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkTemplateNode(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitedBuffer is a buffer refusing writes past limit bytes
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("output is larger than %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
+
+// executeNotificationTemplate renders t with data within the output and time
+// limits
+func executeNotificationTemplate(t *template.Template, data map[string]string) (string, error) {
+	type result struct {
+		text string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out := &limitedBuffer{limit: maxNotificationOutputSize}
+		err := t.Execute(out, data)
+		done <- result{out.String(), err}
+	}()
+
+	timer := time.NewTimer(notificationRenderTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.text, res.err
+	case <-timer.C:
+		return "", fmt.Errorf("template took longer than %s to render", notificationRenderTimeout)
+	}
+}
+
+// renderNotification renders the subject and body of tmpl with data
+func renderNotification(tmpl api.NotificationTemplate, data map[string]string) (api.RenderedNotification, error) {
+	var rendered api.RenderedNotification
+	subject, err := parseNotificationTemplate(tmpl.Subject)
+	if err != nil {
+		return rendered, fmt.Errorf("subject: %w", err)
+	}
+	body, err := parseNotificationTemplate(tmpl.Body)
+	if err != nil {
+		return rendered, fmt.Errorf("body: %w", err)
+	}
+	if rendered.Subject, err = executeNotificationTemplate(subject, data); err != nil {
+		return rendered, fmt.Errorf("subject: %w", err)
+	}
+	if rendered.Body, err = executeNotificationTemplate(body, data); err != nil {
+		return rendered, fmt.Errorf("body: %w", err)
+	}
+	// The mailer encodes the subject, but a line break in it is still a
+	// template mistake
+	rendered.Subject = strings.Join(strings.Fields(rendered.Subject), " ")
+	return rendered, nil
+}
+
+// validateNotificationTemplate normalizes tmpl and checks that it names a
+// notification email, is within the size limits and renders the
+// notification's example fields
+func validateNotificationTemplate(tmpl *api.NotificationTemplate) (api.RenderedNotification, error) {
+	builtin, ok := builtinNotifications[tmpl.Name]
+	if !ok {
+		return api.RenderedNotification{}, fmt.Errorf("unknown notification %q", tmpl.Name)
+	}
+	tmpl.Org = strings.ToLower(strings.TrimSpace(tmpl.Org))
+	if strings.TrimSpace(tmpl.Subject) == "" || strings.TrimSpace(tmpl.Body) == "" {
+		return api.RenderedNotification{}, fmt.Errorf("subject and body are required")
+	}
+	if len(tmpl.Subject) > maxNotificationSubjectSize {
+		return api.RenderedNotification{}, fmt.Errorf("subject is longer than %d bytes", maxNotificationSubjectSize)
+	}
+	if len(tmpl.Body) > maxNotificationBodySize {
+		return api.RenderedNotification{}, fmt.Errorf("body is longer than %d bytes", maxNotificationBodySize)
+	}
+	return renderNotification(*tmpl, builtin.sample)
+}
+
+// builtinNotificationTemplates returns the built-in notification emails as
+// templates, with the fields they may use, by name
+func builtinNotificationTemplates() []api.NotificationTemplate {
+	templates := make([]api.NotificationTemplate, 0, len(builtinNotifications))
+	for name, builtin := range builtinNotifications {
+		fields := make([]string, 0, len(builtin.sample))
+		for field := range builtin.sample {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		templates = append(templates, api.NotificationTemplate{Name: name, Subject: builtin.subject, Body: builtin.body, Fields: fields})
+	}
+	slices.SortFunc(templates, func(a, b api.NotificationTemplate) int { return strings.Compare(a.Name, b.Name) })
+	return templates
+}
+
+// notificationOrg returns the organization whose templates a user's
+// notifications use: the first organization in an operator's scope, or none
+// (the server default)
+func notificationOrg(user *User) string {
+	if user.Role != roleOperator || len(user.Scope.Orgs) == 0 {
+		return ""
+	}
+	return strings.ToLower(user.Scope.Orgs[0])
+}
+
+// notificationEmail returns the subject and body of the named notification
+// for a user of org. The org's template wins over the server default, which
+// wins over the built-in wording; a stored template that fails to render is
+// logged and the built-in wording is sent instead.
+func (s *ComplianceServer) notificationEmail(name, org string, data map[string]string) (string, string, error) {
+	orgs := []string{""}
+	if org != "" {
+		orgs = []string{org, ""}
+	}
+	for _, candidate := range orgs {
+		tmpl, err := s.db.NotificationTemplate(name, candidate)
+		if err != nil {
+			if err.Error() == "notification template not found" {
+				continue
+			}
+			s.logger.Error("Failed to get notification template", "name", name, "org", candidate, "error", err)
+			break
+		}
+		rendered, err := renderNotification(*tmpl, data)
+		if err != nil {
+			s.logger.Warn("Failed to render notification template, sending the built-in one", "name", name, "org", candidate, "error", err)
+			break
+		}
+		return rendered.Subject, rendered.Body, nil
+	}
+
+	builtin := builtinNotifications[name]
+	rendered, err := renderNotification(api.NotificationTemplate{Subject: builtin.subject, Body: builtin.body}, data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render %s notification: %w", name, err)
+	}
+	return rendered.Subject, rendered.Body, nil
+}
+
+// handleListNotificationTemplates lists the stored notification templates
+// and the built-in wording they replace (GET /api/v1/notification-templates)
+func (s *ComplianceServer) handleListNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.requestDB(r).ListNotificationTemplates()
+	if err != nil {
+		s.logger.Error("Failed to list notification templates", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list notification templates")
+		return
+	}
+	s.respond(w, r, api.NotificationTemplateListResponse{Templates: templates, Builtin: builtinNotificationTemplates()}, nil)
+}
+
+// decodeNotificationTemplate reads the template in the request body for the
+// notification named in the path and validates it, answering 400 when it
+// is invalid
+func (s *ComplianceServer) decodeNotificationTemplate(w http.ResponseWriter, r *http.Request) (*api.NotificationTemplate, *api.RenderedNotification, bool) {
+	var tmpl api.NotificationTemplate
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, nil, false
+	}
+	tmpl.Name = r.PathValue("name")
+	tmpl.Fields = nil
+	rendered, err := validateNotificationTemplate(&tmpl)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return nil, nil, false
+	}
+	return &tmpl, &rendered, true
+}
+
+// handlePreviewNotificationTemplate renders a template with example values
+// without storing it (POST /api/v1/notification-templates/{name}/preview)
+func (s *ComplianceServer) handlePreviewNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	_, rendered, ok := s.decodeNotificationTemplate(w, r)
+	if !ok {
+		return
+	}
+	s.respond(w, r, rendered, nil)
+}
+
+// handleSaveNotificationTemplate creates or replaces the template of a
+// notification for an organization, or the server default when org is
+// empty (PUT /api/v1/notification-templates/{name})
+func (s *ComplianceServer) handleSaveNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	tmpl, _, ok := s.decodeNotificationTemplate(w, r)
+	if !ok {
+		return
+	}
+	tmpl.UpdatedBy = s.auditActor(r)
+
+	db := s.requestDB(r)
+	before, err := db.NotificationTemplate(tmpl.Name, tmpl.Org)
+	if err != nil && err.Error() != "notification template not found" {
+		s.logger.Error("Failed to get notification template", "error", err, "name", tmpl.Name, "org", tmpl.Org)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save notification template")
+		return
+	}
+	if err := db.SaveNotificationTemplate(tmpl); err != nil {
+		s.logger.Error("Failed to save notification template", "error", err, "name", tmpl.Name, "org", tmpl.Org)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save notification template")
+		return
+	}
+
+	s.logger.Info("Notification template saved", "name", tmpl.Name, "org", tmpl.Org)
+	noteAdminChange(r, tmpl.Name, before, tmpl)
+	s.respond(w, r, tmpl, nil)
+}
+
+// handleDeleteNotificationTemplate deletes the template of a notification
+// for the organization in ?org= (the server default without it); the
+// notification falls back to the default or built-in wording
+// (DELETE /api/v1/notification-templates/{name})
+func (s *ComplianceServer) handleDeleteNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := builtinNotifications[name]; !ok {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Unknown notification %q", name))
+		return
+	}
+	org := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("org")))
+
+	tmpl, err := s.requestDB(r).DeleteNotificationTemplate(name, org)
+	if err != nil {
+		if err.Error() == "notification template not found" {
+			s.sendError(w, http.StatusNotFound, "Notification template not found")
+			return
+		}
+		s.logger.Error("Failed to delete notification template", "error", err, "name", name, "org", org)
+		s.sendError(w, http.StatusInternalServerError, "Failed to delete notification template")
+		return
+	}
+
+	s.logger.Info("Notification template deleted", "name", name, "org", org)
+	noteAdminChange(r, name, tmpl, nil)
+	s.respond(w, r, api.StatusResponse{Status: "success", Message: "Notification template deleted"}, nil)
+}
+
+// notificationTemplateColumns are the columns scanNotificationTemplate reads
+const notificationTemplateColumns = `name, org, subject, body, updated_by, updated_at`
+
+// scanNotificationTemplate reads a row of notificationTemplateColumns
+func scanNotificationTemplate(scan func(...any) error) (*api.NotificationTemplate, error) {
+	var tmpl api.NotificationTemplate
+	var updatedBy, updatedAt sql.NullString
+	if err := scan(&tmpl.Name, &tmpl.Org, &tmpl.Subject, &tmpl.Body, &updatedBy, &updatedAt); err != nil {
+		return nil, err
+	}
+	tmpl.UpdatedBy = updatedBy.String
+	tmpl.UpdatedAt = updatedAt.String
+	return &tmpl, nil
+}
+
+// ListNotificationTemplates returns the stored notification templates by
+// name, server defaults first
+func (d *Database) ListNotificationTemplates() ([]api.NotificationTemplate, error) {
+	rows, err := d.db.Query(safesql.New(`SELECT ` + notificationTemplateColumns + ` FROM notification_templates ORDER BY name, org`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []api.NotificationTemplate{}
+	for rows.Next() {
+		tmpl, err := scanNotificationTemplate(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification template: %w", err)
+		}
+		templates = append(templates, *tmpl)
+	}
+	return templates, rows.Err()
+}
+
+// NotificationTemplate returns the template of the named notification for
+// org, or the server default when org is empty
+func (d *Database) NotificationTemplate(name, org string) (*api.NotificationTemplate, error) {
+	tmpl, err := scanNotificationTemplate(d.db.QueryRow(safesql.New(
+		`SELECT `+notificationTemplateColumns+` FROM notification_templates WHERE name = $1 AND org = $2`, name, org)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("notification template not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SaveNotificationTemplate stores tmpl, replacing the template of the same
+// notification and organization, and sets its update time
+func (d *Database) SaveNotificationTemplate(tmpl *api.NotificationTemplate) error {
+	const query = `
+		INSERT INTO notification_templates (name, org, subject, body, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (name, org) DO UPDATE SET
+			subject = EXCLUDED.subject, body = EXCLUDED.body,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+
+	err := d.db.QueryRow(safesql.New(query, tmpl.Name, tmpl.Org, tmpl.Subject, tmpl.Body, nullIfEmpty(tmpl.UpdatedBy))).Scan(&tmpl.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save notification template: %w", err)
+	}
+	return nil
+}
+
+// DeleteNotificationTemplate deletes the template of the named notification
+// for org and returns it
+func (d *Database) DeleteNotificationTemplate(name, org string) (*api.NotificationTemplate, error) {
+	tmpl, err := scanNotificationTemplate(d.db.QueryRow(safesql.New(
+		`DELETE FROM notification_templates WHERE name = $1 AND org = $2 RETURNING `+notificationTemplateColumns, name, org)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("notification template not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete notification template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestBuiltinNotifications tests that every built-in notification renders
+// its example fields and uses no field the examples lack
+func TestBuiltinNotifications(t *testing.T) {
+	for name, builtin := range builtinNotifications {
+		rendered, err := renderNotification(api.NotificationTemplate{Subject: builtin.subject, Body: builtin.body}, builtin.sample)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if rendered.Subject == "" || strings.Contains(rendered.Body, "<no value>") {
+			t.Errorf("%s rendered as %+v", name, rendered)
+		}
+	}
+}
+
+// TestValidateNotificationTemplate tests which templates are accepted, and
+// that loops, code and oversized output are refused
+func TestValidateNotificationTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    api.NotificationTemplate
+		wantErr string
+	}{
+		{"branded", api.NotificationTemplate{Name: "invitation", Subject: "Welcome to ACME, {{.Username}}",
+			Body: "{{if eq .Role \"admin\"}}Admin{{else}}User{{end}} {{upper .Username}}: {{.InviteURL}}"}, ""},
+		{"unknown notification", api.NotificationTemplate{Name: "weekly_report", Subject: "s", Body: "b"}, "unknown notification"},
+		{"empty body", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: " "}, "required"},
+		{"unknown field", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: "{{.Password}}"}, "Password"},
+		{"syntax error", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: "{{.Username"}, "body"},
+		{"range", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: "{{range 1000000000}}x{{end}}"}, "range"},
+		{"define", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: `{{define "x"}}{{template "x"}}{{end}}`}, "define"},
+		{"block", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: `{{block "x" .}}y{{end}}`}, "define"},
+		{"recursion", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: `{{template "notification" .}}`}, "not allowed"},
+		{"call", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: "{{call .Username}}"}, `"call"`},
+		{"nested function", api.NotificationTemplate{Name: "invitation", Subject: "{{if (slice .Role 1)}}s{{end}}", Body: "b"}, `"slice"`},
+		{"output too large", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: `{{printf "%0900000d" 1}}`}, "larger than"},
+		{"body too large", api.NotificationTemplate{Name: "invitation", Subject: "s", Body: strings.Repeat("x", maxNotificationBodySize+1)}, "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateNotificationTemplate(&tt.tmpl)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRenderNotificationSubject tests that a rendered subject stays on one
+// line
+func TestRenderNotificationSubject(t *testing.T) {
+	rendered, err := renderNotification(api.NotificationTemplate{Subject: "Hello\n{{.Username}}\r\nBcc: x", Body: "b"}, map[string]string{"Username": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Subject != "Hello alice Bcc: x" {
+		t.Errorf("subject = %q", rendered.Subject)
+	}
+}
+
+// TestNotificationOrg tests which organization's templates a user gets
+func TestNotificationOrg(t *testing.T) {
+	operator := &User{Role: roleOperator, Scope: api.ClientScope{Orgs: []string{"EMEA.example.com", "apac.example.com"}}}
+	if org := notificationOrg(operator); org != "emea.example.com" {
+		t.Errorf("operator org = %q", org)
+	}
+	tagged := &User{Role: roleOperator, Scope: api.ClientScope{Tags: []string{"pos"}}}
+	admin := &User{Role: "admin"}
+	for _, user := range []*User{tagged, admin} {
+		if org := notificationOrg(user); org != "" {
+			t.Errorf("%+v org = %q, want the server default", user, org)
+		}
+	}
+}
+
+// TestNotificationTemplatePreview tests that previews render with example
+// values and refuse invalid templates
+func TestNotificationTemplatePreview(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	r := httptest.NewRequest("POST", "/api/v1/notification-templates/password_reset/preview",
+		strings.NewReader(`{"subject":"ACME password reset","body":"Hi {{.Username}}, open {{.ResetURL}}"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Hi alice, open https://") {
+		t.Errorf("preview: status = %d, body %s", w.Code, w.Body)
+	}
+
+	for _, tt := range []struct{ method, path, body string }{
+		{"POST", "/api/v1/notification-templates/password_reset/preview", `{"subject":"s","body":"{{range .}}{{end}}"}`},
+		{"PUT", "/api/v1/notification-templates/password_reset", `template`},
+		{"PUT", "/api/v1/notification-templates/digest", `{"subject":"s","body":"b"}`},
+		{"DELETE", "/api/v1/notification-templates/digest", ""},
+	} {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want 400: %s", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailer sends notification email through the configured SMTP server
+type mailer struct {
+	settings SMTPSettings
+	from     *mail.Address
+
+	// sendMail delivers a message; smtp.SendMail outside tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// newMailer returns the mailer for the notification settings, or nil when
+// notifications are disabled
+func newMailer(settings NotificationSettings) (*mailer, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+	from, err := mail.ParseAddress(settings.SMTP.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notifications.smtp.from: %w", err)
+	}
+	return &mailer{settings: settings.SMTP, from: from, sendMail: smtp.SendMail}, nil
+}
+
+// send emails a plain text message to a single recipient
+func (m *mailer) send(to, subject, body string) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", to, err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient.String())
+	// Encoding the subject also keeps line breaks out of the headers
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.settings.Username != "" {
+		auth = smtp.PlainAuth("", m.settings.Username, m.settings.Password, m.settings.Host)
+	}
+	addr := net.JoinHostPort(m.settings.Host, strconv.Itoa(m.settings.Port))
+	if err := m.sendMail(addr, auth, m.from.Address, []string{recipient.Address}, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", recipient.Address, err)
+	}
+	return nil
+}
+
+// dashboardURL returns an absolute link to a dashboard path when
+// notifications.base_url is set, else the path alone
+func (s *ComplianceServer) dashboardURL(path string) string {
+	return strings.TrimRight(s.config().Notifications.BaseURL, "/") + path
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Submissions are partitioned by month of their timestamp (migration 0008).
+// The server keeps partitions for the current month and the next
+// partitionMonthsAhead months, checking once a day.
+const (
+	partitionMonthsAhead    = 2
+	partitionCheckInterval  = 24 * time.Hour
+	submissionsDefaultTable = "submissions_default"
+)
+
+// partitionLockID is the PostgreSQL advisory lock key held while a server
+// creates partitions, so replicas do not race to create the same month
+const partitionLockID = 0x7061727469
+
+// submissionPartition returns the partition holding a month of submissions
+// and the range of timestamps it covers
+func submissionPartition(month time.Time) (name string, from, to time.Time) {
+	from = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("submissions_%04d_%02d", from.Year(), from.Month()), from, from.AddDate(0, 1, 0)
+}
+
+// startPartitionMaintenance creates the submission partitions of coming
+// months at startup and then daily
+func (s *ComplianceServer) startPartitionMaintenance() {
+	s.ensureSubmissionPartitions(time.Now())
+
+	go func() {
+		ticker := time.NewTicker(partitionCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.ensureSubmissionPartitions(time.Now())
+		}
+	}()
+}
+
+// ensureSubmissionPartitions creates missing partitions from the current
+// month through partitionMonthsAhead months ahead
+func (s *ComplianceServer) ensureSubmissionPartitions(now time.Time) {
+	for i := 0; i <= partitionMonthsAhead; i++ {
+		name, created, err := s.db.EnsureSubmissionPartition(now.UTC().AddDate(0, i, 0))
+		if err != nil {
+			s.logger.Error("Failed to create submission partition", "partition", name, "error", err)
+			continue
+		}
+		if created {
+			s.logger.Info("Created submission partition", "partition", name)
+		}
+	}
+}
+
+// EnsureSubmissionPartition creates the partition for a month of
+// submissions unless it exists, reporting whether it did. Submissions of
+// that month already in the default partition are moved into it. Databases
+// whose submissions table is not partitioned are left alone.
+func (d *Database) EnsureSubmissionPartition(month time.Time) (string, bool, error) {
+	name, from, to := submissionPartition(month)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return name, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(safesql.New(`SELECT pg_advisory_xact_lock($1)`, partitionLockID)); err != nil {
+		return name, false, fmt.Errorf("failed to lock partitions: %w", err)
+	}
+
+	var partitioned, exists bool
+	err = tx.QueryRow(safesql.New(`
+		SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('submissions')),
+		       to_regclass($1) IS NOT NULL
+	`, name)).Scan(&partitioned, &exists)
+	if err != nil {
+		return name, false, fmt.Errorf("failed to check partitions: %w", err)
+	}
+	if !partitioned || exists {
+		return name, false, nil
+	}
+
+	// DDL takes no parameters; the name and bounds, formatted from a time,
+	// are quoted instead
+	table := safesql.Identifier(name)
+	lower, upper := safesql.Literal(from.Format("2006-01-02")), safesql.Literal(to.Format("2006-01-02"))
+	statements := []safesql.Query{
+		safesql.New(`CREATE TABLE `).AppendQuery(table).Append(` (LIKE submissions INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`),
+		// Attaching fails while the default partition holds rows in range
+		safesql.New(`WITH moved AS (
+			DELETE FROM ` + submissionsDefaultTable + ` WHERE timestamp >= `).AppendQuery(lower).Append(` AND timestamp < `).AppendQuery(upper).Append(` RETURNING *
+		) INSERT INTO `).AppendQuery(table).Append(` SELECT * FROM moved`),
+		safesql.New(`ALTER TABLE submissions ATTACH PARTITION `).AppendQuery(table).
+			Append(` FOR VALUES FROM (`).AppendQuery(lower).Append(`) TO (`).AppendQuery(upper).Append(`)`),
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return name, false, fmt.Errorf("failed to create partition: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return name, false, fmt.Errorf("failed to commit partition: %w", err)
+	}
+	return name, true, nil
+}
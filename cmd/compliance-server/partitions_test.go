@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSubmissionPartition tests partition names and bounds, including the
+// turn of the year
+func TestSubmissionPartition(t *testing.T) {
+	tests := []struct {
+		month    time.Time
+		wantName string
+		wantFrom string
+		wantTo   string
+	}{
+		{time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC), "submissions_2026_10", "2026-10-01", "2026-11-01"},
+		{time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC), "submissions_2026_12", "2026-12-01", "2027-01-01"},
+		{time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), "submissions_2027_01", "2027-01-01", "2027-02-01"},
+	}
+
+	for _, tt := range tests {
+		name, from, to := submissionPartition(tt.month)
+		if name != tt.wantName || from.Format("2006-01-02") != tt.wantFrom || to.Format("2006-01-02") != tt.wantTo {
+			t.Errorf("submissionPartition(%v) = %s, %v, %v; want %s, %s, %s",
+				tt.month, name, from, to, tt.wantName, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
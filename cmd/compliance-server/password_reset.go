@@ -0,0 +1,294 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/auth"
+	"compliancetoolkit/pkg/safesql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// userTokenPasswordReset is the purpose of the token a user resets a
+	// forgotten password with
+	userTokenPasswordReset = "password_reset"
+
+	// passwordResetInterval is how often one user may be sent a reset link
+	passwordResetInterval = 5 * time.Minute
+)
+
+// passwordResetSent is the answer to every reset request, so the response
+// does not tell which usernames exist or have an email address
+const passwordResetSent = "If the account has an email address, a password reset link has been sent to it."
+
+// handleRequestPasswordReset emails a password reset link to the address on
+// a user's account (POST /api/v1/password-reset/request). Unknown users,
+// users without an email address and repeated requests get the same answer
+// as successful ones.
+func (s *ComplianceServer) handleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if s.mailer == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "Password reset by email is not enabled; ask an administrator to reset your password")
+		return
+	}
+
+	var req api.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Username == "" {
+		s.sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	if reason := s.sendPasswordReset(r, req.Username); reason != "" {
+		s.logger.Info("Password reset not sent", "username", req.Username, "reason", reason)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: passwordResetSent,
+	})
+}
+
+// sendPasswordReset issues a reset token for username and emails its link,
+// recording the request in the authentication audit log. It returns why no
+// link was sent, or "" when one was.
+func (s *ComplianceServer) sendPasswordReset(r *http.Request, username string) string {
+	event := auth.AuditEvent{
+		Username:  username,
+		EventType: auth.EventPasswordResetRequest,
+		IPAddress: s.remoteIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	defer func() { s.logAuthEvent(r, event) }()
+
+	user, err := s.requestDB(r).GetUser(username)
+	if err != nil {
+		if err.Error() != "user not found" {
+			s.logger.Error("Failed to get user", "username", username, "error", err)
+		}
+		event.FailureReason = "user not found"
+		return event.FailureReason
+	}
+	event.UserID = user.ID
+	if user.Email == "" {
+		event.FailureReason = "no email address"
+		return event.FailureReason
+	}
+
+	token, tokenHash, err := newUserToken()
+	if err != nil {
+		event.FailureReason = "internal error"
+		return event.FailureReason
+	}
+	expiresAt := time.Now().Add(s.config().Auth.PasswordResetTTL).UTC()
+	created, err := s.requestDB(r).CreatePasswordResetToken(user.ID, tokenHash, expiresAt, passwordResetInterval)
+	if err != nil {
+		s.logger.Error("Failed to create password reset token", "username", username, "error", err)
+		event.FailureReason = "internal error"
+		return event.FailureReason
+	}
+	if !created {
+		event.FailureReason = "requested too recently"
+		return event.FailureReason
+	}
+
+	resetURL := s.dashboardURL("/reset-password?token=" + url.QueryEscape(token))
+	subject, body, err := s.notificationEmail(notificationPasswordReset, notificationOrg(user), map[string]string{
+		"Username":  user.Username,
+		"ExpiresAt": expiresAt.Format("2 Jan 2006 15:04 MST"),
+		"ResetURL":  resetURL,
+	})
+	if err != nil {
+		s.logger.Error("Failed to compose password reset email", "username", username, "error", err)
+		event.FailureReason = "internal error"
+		return event.FailureReason
+	}
+	event.Success = true
+
+	// Sending in the background keeps the response time the same whether
+	// or not a link was sent
+	to := user.Email
+	go func() {
+		if err := s.mailer.send(to, subject, body); err != nil {
+			s.logger.Warn("Failed to email password reset", "username", username, "error", err)
+		}
+	}()
+	s.logger.Info("Password reset link sent", "username", username)
+	return ""
+}
+
+// handleValidatePasswordReset reports whose password a reset token sets and
+// until when, so the reset page can refuse a dead link before the user
+// types a password (POST /api/v1/password-reset/validate)
+func (s *ComplianceServer) handleValidatePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req api.TokenPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		s.sendError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	username, expiresAt, err := s.requestDB(r).CheckUserToken(userTokenPasswordReset, hashUserToken(req.Token))
+	if err != nil {
+		if err.Error() == "invalid token" {
+			s.sendError(w, http.StatusBadRequest, "This reset link is invalid, used or expired; request a new one")
+			return
+		}
+		s.logger.Error("Failed to check password reset token", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to check reset link")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.PasswordResetTokenResponse{
+		Username:  username,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// handleConfirmPasswordReset sets a user's password from the token in their
+// reset link (POST /api/v1/password-reset/confirm). The token works once and
+// until it expires; the user's sessions end.
+func (s *ComplianceServer) handleConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req api.TokenPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		s.sendError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+
+	event := auth.AuditEvent{
+		EventType: auth.EventPasswordReset,
+		IPAddress: s.remoteIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	username, err := s.requestDB(r).RedeemUserToken(userTokenPasswordReset, hashUserToken(req.Token), string(passwordHash))
+	if err != nil {
+		if err.Error() == "invalid token" {
+			s.logger.Warn("Invalid password reset token", "remote_addr", r.RemoteAddr)
+			event.FailureReason = "invalid token"
+			s.logAuthEvent(r, event)
+			s.sendError(w, http.StatusBadRequest, "This reset link is invalid, used or expired; request a new one")
+			return
+		}
+		s.logger.Error("Failed to reset password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+	event.Username = username
+	event.Success = true
+	s.logAuthEvent(r, event)
+
+	s.logger.Info("Password reset", "username", username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Your password is reset, %s. Log in with the new one.", username),
+	})
+}
+
+// logAuthEvent records event in the authentication audit log
+func (s *ComplianceServer) logAuthEvent(r *http.Request, event auth.AuditEvent) {
+	if err := auth.NewAuditLogger(s.db.db.DB).Log(r.Context(), event); err != nil {
+		s.logger.Warn("Failed to log authentication event", "event", event.EventType, "error", err)
+	}
+}
+
+// CreatePasswordResetToken stores a password reset token for a user, valid
+// until expiresAt, and expires the user's earlier unused reset tokens. It
+// stores nothing and returns false when the user was issued one less than
+// minInterval ago.
+func (d *Database) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time, minInterval time.Duration) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin password reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize requests for the same user so two cannot both pass the
+	// interval check
+	if _, err := tx.Exec(safesql.New(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID)); err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var recent bool
+	err = tx.QueryRow(safesql.New(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_tokens
+			WHERE user_id = $1 AND purpose = $2 AND created_at > CURRENT_TIMESTAMP - $3 * INTERVAL '1 second'
+		)
+	`, userID, userTokenPasswordReset, int(minInterval.Seconds()))).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("failed to check recent password resets: %w", err)
+	}
+	if recent {
+		return false, nil
+	}
+
+	// Only the newest link works
+	_, err = tx.Exec(safesql.New(`
+		UPDATE user_tokens SET expires_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	`, userID, userTokenPasswordReset))
+	if err != nil {
+		return false, fmt.Errorf("failed to expire earlier password resets: %w", err)
+	}
+
+	_, err = tx.Exec(safesql.New(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, tokenHash, userID, userTokenPasswordReset, expiresAt.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return false, fmt.Errorf("failed to store password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit password reset: %w", err)
+	}
+	return true, nil
+}
+
+// CheckUserToken returns the username and expiry of an unused, unexpired
+// token of the given purpose, or the error "invalid token"
+func (d *Database) CheckUserToken(purpose, tokenHash string) (string, time.Time, error) {
+	var username string
+	var expiresAt time.Time
+	err := d.db.QueryRow(safesql.New(`
+		SELECT u.username, t.expires_at
+		FROM user_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.purpose = $2 AND t.used_at IS NULL AND t.expires_at > CURRENT_TIMESTAMP
+	`, tokenHash, purpose)).Scan(&username, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, fmt.Errorf("invalid token")
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to check token: %w", err)
+	}
+	return username, expiresAt, nil
+}
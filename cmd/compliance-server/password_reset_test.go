@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRequestPasswordResetWithoutMail tests that resets are refused when
+// the server cannot send email
+func TestRequestPasswordResetWithoutMail(t *testing.T) {
+	s := newTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/password-reset/request", strings.NewReader(`{"username":"alice"}`))
+	rec := httptest.NewRecorder()
+	s.handleRequestPasswordReset(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+// TestRequestPasswordResetValidation tests requests refused before the
+// user is looked up
+func TestRequestPasswordResetValidation(t *testing.T) {
+	s := newTestServer()
+	s.mailer = &mailer{}
+	for _, body := range []string{`{}`, `not json`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/password-reset/request", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		s.handleRequestPasswordReset(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+// TestPasswordResetTokenValidation tests validate and confirm requests
+// refused before the token is looked up
+func TestPasswordResetTokenValidation(t *testing.T) {
+	s := newTestServer()
+	handlers := map[string]http.HandlerFunc{
+		"validate": s.handleValidatePasswordReset,
+		"confirm":  s.handleConfirmPasswordReset,
+	}
+	for name, handler := range handlers {
+		for _, body := range []string{`{"password":"long enough"}`, `not json`} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/password-reset/"+name, strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: status = %d, want 400", name, body, rec.Code)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/password-reset/confirm", strings.NewReader(`{"token":"abc","password":"short"}`))
+	rec := httptest.NewRecorder()
+	s.handleConfirmPasswordReset(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at least 8") {
+		t.Errorf("short password: status = %d, body %s; want 400 naming the minimum", rec.Code, rec.Body)
+	}
+}
+
+// TestResetPasswordPage tests that the page keeps its token out of
+// Referer headers
+func TestResetPasswordPage(t *testing.T) {
+	s := newTestServer()
+	req := httptest.NewRequest(http.MethodGet, "/reset-password?token=abc", nil)
+	rec := httptest.NewRecorder()
+	s.handleResetPasswordPage(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("status = %d, Referrer-Policy %q", rec.Code, rec.Header().Get("Referrer-Policy"))
+	}
+	if !strings.Contains(rec.Body.String(), "/api/v1/password-reset/confirm") {
+		t.Error("page does not post to the confirm endpoint")
+	}
+}
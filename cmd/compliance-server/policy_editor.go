@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+	"compliancetoolkit/pkg/schema"
+)
+
+// validatePolicyData checks report configuration JSON as clients will: its
+// shape against the report schema, then the expected value of every check
+// against its operator. The schema stops at the first problem it finds, the
+// operator checks report every bad check.
+func validatePolicyData(data string) []api.PolicyValidationError {
+	problems := []api.PolicyValidationError{}
+	if err := schema.ValidateReport([]byte(data)); err != nil {
+		var schemaErr *schema.Error
+		if errors.As(err, &schemaErr) {
+			return append(problems, api.PolicyValidationError{Path: schemaErr.Path, Message: schemaErr.Message})
+		}
+		return append(problems, api.PolicyValidationError{Message: err.Error()})
+	}
+
+	var report simulationPolicy
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return append(problems, api.PolicyValidationError{Message: err.Error()})
+	}
+	for i, query := range report.Queries {
+		if err := api.ValidateExpected(query.ExpectedValue, query.ExpectedOperator); err != nil {
+			problems = append(problems, api.PolicyValidationError{
+				Path:    fmt.Sprintf("queries[%d].expected_operator", i),
+				Message: err.Error(),
+			})
+		}
+	}
+	return problems
+}
+
+// checkPolicyData returns the first problem of policy data saved through the
+// API, phrased for an error response
+func checkPolicyData(data string) error {
+	problems := validatePolicyData(data)
+	if len(problems) == 0 {
+		return nil
+	}
+	if problems[0].Path == "" {
+		return fmt.Errorf("Invalid policy_data: %s", problems[0].Message)
+	}
+	return fmt.Errorf("Invalid policy_data: %s: %s", problems[0].Path, problems[0].Message)
+}
+
+// diffPolicyData compares two report configurations. Checks are matched by
+// name (by position when unnamed) and compared field by field; every other
+// top-level value and metadata field is listed in Settings. It fails when
+// either side is not a JSON object.
+func diffPolicyData(before, after string) (*api.PolicyDiff, error) {
+	var old, edited map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(before), &old); err != nil {
+		return nil, fmt.Errorf("previous policy data is not a JSON object: %w", err)
+	}
+	if err := json.Unmarshal([]byte(after), &edited); err != nil {
+		return nil, fmt.Errorf("policy data is not a JSON object: %w", err)
+	}
+
+	diff := &api.PolicyDiff{
+		Settings: []api.PolicyFieldChange{},
+		Added:    []string{},
+		Removed:  []string{},
+		Changed:  []api.PolicyCheckChange{},
+	}
+
+	// metadata is compared field by field, queries check by check
+	settingsBefore := flattenObject(old, "metadata", "queries")
+	settingsAfter := flattenObject(edited, "metadata", "queries")
+	for key, value := range objectFields(old["metadata"]) {
+		settingsBefore["metadata."+key] = value
+	}
+	for key, value := range objectFields(edited["metadata"]) {
+		settingsAfter["metadata."+key] = value
+	}
+	diff.Settings = diffFields(settingsBefore, settingsAfter)
+
+	oldChecks, oldOrder := policyChecks(old["queries"])
+	newChecks, newOrder := policyChecks(edited["queries"])
+	for _, name := range oldOrder {
+		if _, ok := newChecks[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	for _, name := range newOrder {
+		fields, ok := oldChecks[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		if changes := diffFields(fields, newChecks[name]); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, api.PolicyCheckChange{Name: name, Fields: changes})
+		} else {
+			diff.Unchanged++
+		}
+	}
+	return diff, nil
+}
+
+// flattenObject returns the compact JSON of the fields of object, leaving
+// out the skipped ones
+func flattenObject(object map[string]json.RawMessage, skip ...string) map[string]string {
+	fields := make(map[string]string, len(object))
+	for key, value := range object {
+		if slices.Contains(skip, key) {
+			continue
+		}
+		fields[key] = compactJSON(value)
+	}
+	return fields
+}
+
+// objectFields returns the fields of a JSON object as compact JSON, or none
+// when value is not an object
+func objectFields(value json.RawMessage) map[string]string {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(value, &object) != nil {
+		return nil
+	}
+	return flattenObject(object)
+}
+
+// policyChecks returns the fields of each check of a queries array by
+// check name, and the names in order
+func policyChecks(value json.RawMessage) (map[string]map[string]string, []string) {
+	var queries []json.RawMessage
+	json.Unmarshal(value, &queries)
+
+	checks := make(map[string]map[string]string, len(queries))
+	order := make([]string, 0, len(queries))
+	for i, query := range queries {
+		fields := objectFields(query)
+		var name string
+		if json.Unmarshal([]byte(fields["name"]), &name) != nil || name == "" {
+			name = fmt.Sprintf("queries[%d]", i)
+		}
+		if _, dup := checks[name]; dup {
+			name = fmt.Sprintf("%s (queries[%d])", name, i)
+		}
+		checks[name] = fields
+		order = append(order, name)
+	}
+	return checks, order
+}
+
+// diffFields lists the fields whose values differ, sorted by field
+func diffFields(before, after map[string]string) []api.PolicyFieldChange {
+	changes := []api.PolicyFieldChange{}
+	for field, value := range before {
+		if after[field] != value {
+			changes = append(changes, api.PolicyFieldChange{Field: field, Before: value, After: after[field]})
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes = append(changes, api.PolicyFieldChange{Field: field, After: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// compactJSON returns value without insignificant whitespace, so reformatted
+// policy data does not show as changed
+func compactJSON(value json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return string(value)
+	}
+	return compact.String()
+}
+
+// handleValidatePolicy checks edited policy data before it is saved and
+// diffs it against the stored policy or one of its revisions
+// (POST /api/v1/policies/{policy_id}/validate). Nothing is saved.
+func (s *ComplianceServer) handleValidatePolicy(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	var request api.PolicyValidationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if request.PolicyData == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing required field: policy_data")
+		return
+	}
+	if request.Against < 0 {
+		s.sendError(w, http.StatusBadRequest, "against must be a revision number")
+		return
+	}
+
+	policy, err := s.requestDB(r).GetPolicy(policyID)
+	if err != nil {
+		s.sendPolicyLookupError(w, policyID, err)
+		return
+	}
+	base, against := policy.PolicyData, "stored"
+	if request.Against > 0 {
+		revision, err := s.requestDB(r).GetPolicyRevision(policyID, request.Against)
+		if err != nil {
+			s.sendRevisionLookupError(w, policyID, err)
+			return
+		}
+		base, against = revision.PolicyData, fmt.Sprintf("revision %d", revision.Revision)
+	}
+
+	response := api.PolicyValidationResponse{Errors: validatePolicyData(request.PolicyData)}
+	response.Valid = len(response.Errors) == 0
+	var report simulationPolicy
+	if json.Unmarshal([]byte(request.PolicyData), &report) == nil {
+		response.ReportType = report.Metadata.ReportTitle
+		response.Checks = len(report.Queries)
+	}
+	if diff, err := diffPolicyData(base, request.PolicyData); err == nil {
+		diff.Against = against
+		response.Diff = diff
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListPolicyRevisions lists the revisions of a policy, newest first
+// (GET /api/v1/policies/{policy_id}/revisions)
+func (s *ComplianceServer) handleListPolicyRevisions(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	if _, err := s.requestDB(r).GetPolicy(policyID); err != nil {
+		s.sendPolicyLookupError(w, policyID, err)
+		return
+	}
+	revisions, err := s.requestDB(r).ListPolicyRevisions(policyID)
+	if err != nil {
+		s.logger.Error("Failed to list policy revisions", "error", err, "policy_id", policyID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list policy revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.PolicyRevisionsResponse{PolicyID: policyID, Revisions: revisions})
+}
+
+// handleGetPolicyRevision returns one revision of a policy with its data
+// (GET /api/v1/policies/{policy_id}/revisions/{revision})
+func (s *ComplianceServer) handleGetPolicyRevision(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+	number, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil || number < 1 {
+		s.sendError(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	revision, err := s.requestDB(r).GetPolicyRevision(policyID, number)
+	if err != nil {
+		s.sendRevisionLookupError(w, policyID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// sendPolicyLookupError answers a failed GetPolicy
+func (s *ComplianceServer) sendPolicyLookupError(w http.ResponseWriter, policyID string, err error) {
+	if err.Error() == "policy not found" {
+		s.sendError(w, http.StatusNotFound, "Policy not found")
+		return
+	}
+	s.logger.Error("Failed to get policy", "error", err, "policy_id", policyID)
+	s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+}
+
+// sendRevisionLookupError answers a failed GetPolicyRevision
+func (s *ComplianceServer) sendRevisionLookupError(w http.ResponseWriter, policyID string, err error) {
+	if err.Error() == "revision not found" {
+		s.sendError(w, http.StatusNotFound, "Revision not found")
+		return
+	}
+	s.logger.Error("Failed to get policy revision", "error", err, "policy_id", policyID)
+	s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy revision")
+}
+
+// handlePolicyEditorPage serves the policy editor
+// (GET /policies/{policy_id}/edit). The page loads the policy itself, so an
+// unknown policy is reported there.
+func (s *ComplianceServer) handlePolicyEditorPage(w http.ResponseWriter, r *http.Request) {
+	html, err := assets.ReadFile(path.Join(templatesDir, "policy-editor.html"))
+	if err != nil {
+		s.logger.Error("Failed to read policy-editor.html", "error", err)
+		s.sendErrorPage(w, r, http.StatusInternalServerError, "Policy editor not available")
+		return
+	}
+
+	s.writePage(w, r, html)
+}
+
+// savePolicyRevision copies the stored version of a policy into
+// policy_revisions before tx replaces it. The policy row is locked first so
+// concurrent updates number their revisions in turn.
+func savePolicyRevision(tx *safesql.Tx, policyID string) error {
+	var locked int
+	err := tx.QueryRow(safesql.New(`SELECT 1 FROM policies WHERE policy_id = $1 FOR UPDATE`, policyID)).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("policy not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock policy: %w", err)
+	}
+
+	const query = `
+		INSERT INTO policy_revisions (policy_id, revision, name, version, status, policy_data, saved_at)
+		SELECT policy_id,
+		       (SELECT COALESCE(MAX(revision), 0) + 1 FROM policy_revisions WHERE policy_id = $1),
+		       name, version, status, policy_data, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+		FROM policies
+		WHERE policy_id = $1
+	`
+	if _, err := tx.Exec(safesql.New(query, policyID)); err != nil {
+		return fmt.Errorf("failed to save policy revision: %w", err)
+	}
+	return nil
+}
+
+// ListPolicyRevisions returns the revisions of a policy without their data,
+// newest first
+func (d *Database) ListPolicyRevisions(policyID string) ([]api.PolicyRevision, error) {
+	const query = `
+		SELECT policy_id, revision, name, COALESCE(version, ''), COALESCE(status, ''), saved_at, superseded_at
+		FROM policy_revisions
+		WHERE policy_id = $1
+		ORDER BY revision DESC
+	`
+
+	rows, err := d.db.Query(safesql.New(query, policyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []api.PolicyRevision{}
+	for rows.Next() {
+		var rev api.PolicyRevision
+		if err := rows.Scan(&rev.PolicyID, &rev.Revision, &rev.Name, &rev.Version, &rev.Status, &rev.SavedAt, &rev.SupersededAt); err != nil {
+			return nil, fmt.Errorf("failed to scan policy revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetPolicyRevision returns one revision of a policy with its data
+func (d *Database) GetPolicyRevision(policyID string, revision int) (*api.PolicyRevision, error) {
+	const query = `
+		SELECT policy_id, revision, name, COALESCE(version, ''), COALESCE(status, ''), policy_data, saved_at, superseded_at
+		FROM policy_revisions
+		WHERE policy_id = $1 AND revision = $2
+	`
+
+	var rev api.PolicyRevision
+	err := d.db.QueryRow(safesql.New(query, policyID, revision)).Scan(
+		&rev.PolicyID, &rev.Revision, &rev.Name, &rev.Version, &rev.Status, &rev.PolicyData, &rev.SavedAt, &rev.SupersededAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy revision: %w", err)
+	}
+	return &rev, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// editorPolicy is a minimal report configuration that passes the schema
+const editorPolicy = `{
+  "version": "1.0",
+  "metadata": {"report_title": "Baseline", "report_version": "1.0.0"},
+  "queries": [
+    {"name": "UAC", "operation": "read", "root_key": "HKLM", "path": "SOFTWARE\\System", "value_name": "EnableLUA", "expected_value": "1"},
+    {"name": "Firewall", "operation": "read", "root_key": "HKLM", "path": "SYSTEM\\Firewall", "value_name": "Enabled", "expected_value": "1"}
+  ]
+}`
+
+// TestValidatePolicyData tests the schema and operator checks of edited
+// policy data
+func TestValidatePolicyData(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []api.PolicyValidationError
+	}{
+		{"valid", editorPolicy, []api.PolicyValidationError{}},
+		{"not JSON", `{"version":`, nil},
+		{"missing queries", `{"version": "1.0", "metadata": {"report_title": "Baseline", "report_version": "1.0.0"}}`, nil},
+		{"bad operators", `{
+			"version": "1.0",
+			"metadata": {"report_title": "Baseline", "report_version": "1.0.0"},
+			"queries": [
+				{"name": "A", "operation": "read", "root_key": "HKLM", "path": "X", "expected_value": "1", "expected_operator": "between"},
+				{"name": "B", "operation": "read", "root_key": "HKLM", "path": "X", "expected_value": "1"},
+				{"name": "C", "operation": "read", "root_key": "HKLM", "path": "X", "expected_value": "high", "expected_operator": "gte"}
+			]
+		}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validatePolicyData(tt.data)
+			if tt.want != nil {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("validatePolicyData() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if len(got) == 0 {
+				t.Fatal("validatePolicyData() found no problems")
+			}
+			if err := checkPolicyData(tt.data); err == nil || !strings.HasPrefix(err.Error(), "Invalid policy_data: ") {
+				t.Errorf("checkPolicyData() = %v, want an invalid policy_data error", err)
+			}
+		})
+	}
+
+	// Every bad check is reported with its location
+	got := validatePolicyData(tests[3].data)
+	var paths []string
+	for _, problem := range got {
+		paths = append(paths, problem.Path)
+	}
+	if want := []string{"queries[0].expected_operator", "queries[2].expected_operator"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("problem paths = %v, want %v", paths, want)
+	}
+}
+
+// TestDiffPolicyData tests that checks are matched by name and compared
+// field by field, ignoring formatting
+func TestDiffPolicyData(t *testing.T) {
+	edited := `{"version":"1.0","metadata":{"report_title":"Baseline","report_version":"1.1.0","author":"Security"},
+		"queries":[
+			{"name":"UAC","operation":"read","root_key":"HKLM","path":"SOFTWARE\\System","value_name":"EnableLUA","expected_value":"1"},
+			{"name":"Defender","operation":"read","root_key":"HKLM","path":"SOFTWARE\\Defender","value_name":"On","expected_value":"1"},
+			{"name":"Firewall","operation":"read","root_key":"HKLM","path":"SYSTEM\\Firewall","value_name":"Enabled","expected_value":"0","expected_operator":"eq"}
+		]}`
+
+	diff, err := diffPolicyData(editorPolicy, edited)
+	if err != nil {
+		t.Fatalf("diffPolicyData() error = %v", err)
+	}
+
+	wantSettings := []api.PolicyFieldChange{
+		{Field: "metadata.author", After: `"Security"`},
+		{Field: "metadata.report_version", Before: `"1.0.0"`, After: `"1.1.0"`},
+	}
+	if !reflect.DeepEqual(diff.Settings, wantSettings) {
+		t.Errorf("Settings = %+v, want %+v", diff.Settings, wantSettings)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"Defender"}) || len(diff.Removed) != 0 {
+		t.Errorf("Added = %v, Removed = %v, want [Defender] and none", diff.Added, diff.Removed)
+	}
+	wantChanged := []api.PolicyCheckChange{{Name: "Firewall", Fields: []api.PolicyFieldChange{
+		{Field: "expected_operator", After: `"eq"`},
+		{Field: "expected_value", Before: `"1"`, After: `"0"`},
+	}}}
+	if !reflect.DeepEqual(diff.Changed, wantChanged) {
+		t.Errorf("Changed = %+v, want %+v", diff.Changed, wantChanged)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1", diff.Unchanged)
+	}
+
+	// Removing a check, and reformatting only
+	diff, err = diffPolicyData(edited, editorPolicy)
+	if err != nil {
+		t.Fatalf("diffPolicyData() error = %v", err)
+	}
+	if !reflect.DeepEqual(diff.Removed, []string{"Defender"}) {
+		t.Errorf("Removed = %v, want [Defender]", diff.Removed)
+	}
+	compact := strings.Join(strings.Fields(editorPolicy), "")
+	if diff, err = diffPolicyData(editorPolicy, compact); err != nil {
+		t.Fatalf("diffPolicyData() error = %v", err)
+	}
+	if len(diff.Settings)+len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 || diff.Unchanged != 2 {
+		t.Errorf("reformatted policy diff = %+v, want no changes", diff)
+	}
+
+	if _, err := diffPolicyData(editorPolicy, `[1, 2]`); err == nil {
+		t.Error("diffPolicyData() of a non-object succeeded")
+	}
+}
+
+// TestPolicyEditorRequestValidation tests the 400 responses of the editor
+// endpoints and policy saves, which are returned before the database is used
+func TestPolicyEditorRequestValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"validate not JSON", "POST", "/api/v1/policies/baseline/validate", `policy`},
+		{"validate without data", "POST", "/api/v1/policies/baseline/validate", `{}`},
+		{"validate against negative revision", "POST", "/api/v1/policies/baseline/validate", `{"policy_data":"{}","against":-1}`},
+		{"revision not a number", "GET", "/api/v1/policies/baseline/revisions/latest", ``},
+		{"revision zero", "GET", "/api/v1/policies/baseline/revisions/0", ``},
+		{"create with invalid data", "POST", "/api/v1/policies",
+			`{"policy_id":"baseline","name":"Baseline","policy_data":"{\"version\":\"1.0\"}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestPolicyEditorPage tests that the editor page is embedded and, like the
+// other dashboard pages, needs a login
+func TestPolicyEditorPage(t *testing.T) {
+	html, err := assets.ReadFile(path.Join(templatesDir, "policy-editor.html"))
+	if err != nil || !strings.Contains(string(html), "/validate") {
+		t.Fatalf("policy-editor.html not embedded: %v", err)
+	}
+
+	handler := newTestServer().routeHandler()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/policies/baseline/edit", nil))
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(rec.Header().Get("Location"), "/login") {
+		t.Errorf("GET /policies/baseline/edit = %d to %q, want a redirect to the login page", rec.Code, rec.Header().Get("Location"))
+	}
+}
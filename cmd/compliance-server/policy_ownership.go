@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// roleAdmin is the role allowed to change every policy
+const roleAdmin = "admin"
+
+// normalizeTeam lower-cases a team name and checks it follows the same rules
+// as client tags. An empty name (no team) is valid.
+func normalizeTeam(team string) (string, error) {
+	team = strings.ToLower(strings.TrimSpace(team))
+	if team != "" && !validTag.MatchString(team) {
+		return "", fmt.Errorf("invalid team %q: use up to 64 letters, digits, '.', '-' or '_'", team)
+	}
+	return team, nil
+}
+
+// canEditPolicy reports whether user may change or delete policy. Admins
+// may change every policy, other users only those they own directly or
+// through their team. A nil user is a request authenticated with an API key
+// (or with authentication disabled), which keeps full access.
+func canEditPolicy(user *User, policy *Policy) bool {
+	switch {
+	case user == nil, user.Role == roleAdmin:
+		return true
+	case policy.Owner != "" && policy.Owner == user.Username:
+		return true
+	case policy.OwnerTeam != "" && policy.OwnerTeam == user.Team:
+		return true
+	}
+	return false
+}
+
+// authorizePolicyEdit loads a policy and checks that the user making r may
+// change it. On failure the error response has been sent and nil returned.
+func (s *ComplianceServer) authorizePolicyEdit(w http.ResponseWriter, r *http.Request, policyID string) *Policy {
+	policy, err := s.requestDB(r).GetPolicy(policyID)
+	if err != nil {
+		if err.Error() == "policy not found" {
+			s.sendError(w, http.StatusNotFound, "Policy not found")
+		} else {
+			s.logger.Error("Failed to get policy", "error", err, "policy_id", policyID)
+			s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+		}
+		return nil
+	}
+
+	if user := requestUser(r); !canEditPolicy(user, policy) {
+		s.logger.Warn("Policy change refused", "policy_id", policyID, "username", user.Username)
+		s.sendError(w, http.StatusForbidden, "Only the policy's owners and admins can change it")
+		return nil
+	}
+
+	return policy
+}
+
+// checkPolicyOwners normalizes the owners requested for a policy and checks
+// that the owning user exists. On failure the error response has been sent
+// and false returned.
+func (s *ComplianceServer) checkPolicyOwners(w http.ResponseWriter, owner, ownerTeam string) (string, string, bool) {
+	ownerTeam, err := normalizeTeam(ownerTeam)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+
+	owner = strings.TrimSpace(owner)
+	if owner != "" {
+		exists, err := s.db.UserExists(owner)
+		if err != nil {
+			s.logger.Error("Failed to check user existence", "error", err)
+			s.sendError(w, http.StatusInternalServerError, "Internal server error")
+			return "", "", false
+		}
+		if !exists {
+			s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Unknown owner %q", owner))
+			return "", "", false
+		}
+	}
+
+	return owner, ownerTeam, true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCanEditPolicy tests who may change a policy
+func TestCanEditPolicy(t *testing.T) {
+	owned := &Policy{PolicyID: "cis-l1", Owner: "alice", OwnerTeam: "cis-benchmarks"}
+	unowned := &Policy{PolicyID: "nist"}
+
+	tests := []struct {
+		name   string
+		user   *User
+		policy *Policy
+		want   bool
+	}{
+		{"API key", nil, owned, true},
+		{"admin", &User{Username: "root", Role: "admin"}, owned, true},
+		{"admin on unowned policy", &User{Username: "root", Role: "admin"}, unowned, true},
+		{"owner", &User{Username: "alice", Role: "viewer"}, owned, true},
+		{"team member", &User{Username: "bob", Role: "auditor", Team: "cis-benchmarks"}, owned, true},
+		{"other team", &User{Username: "carol", Role: "viewer", Team: "nist-800-171"}, owned, false},
+		{"no team", &User{Username: "dave", Role: "viewer"}, owned, false},
+		{"unowned policy", &User{Username: "alice", Role: "viewer"}, unowned, false},
+		{"no team on team-less policy", &User{Username: "dave", Role: "viewer"}, &Policy{Owner: "alice"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canEditPolicy(tt.user, tt.policy); got != tt.want {
+				t.Errorf("canEditPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNormalizeTeam tests team name clean-up and validation
+func TestNormalizeTeam(t *testing.T) {
+	tests := []struct {
+		team    string
+		want    string
+		wantErr bool
+	}{
+		{" CIS-Benchmarks ", "cis-benchmarks", false},
+		{"", "", false},
+		{"cis benchmarks", "", true},
+		{strings.Repeat("a", 65), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeTeam(tt.team)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeTeam(%q) error = %v, wantErr %v", tt.team, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeTeam(%q) = %q, want %q", tt.team, got, tt.want)
+		}
+	}
+}
+
+// TestPolicyOwnershipRequestValidation tests the 400 responses of the
+// ownership endpoints, which are returned before the database is used
+func TestPolicyOwnershipRequestValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"owner not JSON", "PUT", "/api/v1/policies/cis-l1/owner", `owner`},
+		{"invalid owner team", "PUT", "/api/v1/policies/cis-l1/owner", `{"owner_team":"cis benchmarks"}`},
+		{"policy with invalid owner team", "POST", "/api/v1/policies",
+			`{"policy_id":"cis-l1","name":"CIS L1","policy_data":"{}","owner_team":"cis benchmarks"}`},
+		{"team without username", "POST", "/api/v1/users/team", `{"team":"cis-benchmarks"}`},
+		{"invalid team", "POST", "/api/v1/users/team", `{"username":"alice","team":"cis benchmarks"}`},
+		{"user with invalid team", "POST", "/api/v1/users/create",
+			`{"username":"alice","password":"secret123","role":"viewer","team":"cis benchmarks"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/policypack"
+)
+
+// errPackFetch marks failures to download a pack or its signature, as
+// opposed to a pack that was downloaded but rejected
+var errPackFetch = errors.New("failed to download")
+
+// maxPackSignatureSize limits a downloaded detached signature
+const maxPackSignatureSize = 4 << 10
+
+// handleImportPolicyPack imports the report configurations of a signed
+// policy pack fetched from a URL under policies.pack_sources
+// (POST /api/v1/policies/import-url). The archive must be signed by one of
+// policies.trusted_keys; each imported policy records the pack, source and
+// key in its provenance. Existing policies are skipped unless the request
+// asks to update them, which keeps their owners and status.
+func (s *ComplianceServer) handleImportPolicyPack(w http.ResponseWriter, r *http.Request) {
+	var req api.PolicyPackImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.URL == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing required field: url")
+		return
+	}
+	if req.SignatureURL == "" {
+		req.SignatureURL = req.URL + ".sig"
+	}
+
+	if len(s.config().Policies.PackSources) == 0 {
+		s.sendError(w, http.StatusForbidden, "Policy pack import is disabled; configure policies.pack_sources")
+		return
+	}
+	for _, u := range []string{req.URL, req.SignatureURL} {
+		if !packSourceAllowed(s.config().Policies.PackSources, u) {
+			s.sendError(w, http.StatusForbidden, fmt.Sprintf("%s is not under policies.pack_sources", u))
+			return
+		}
+	}
+
+	pack, provenance, err := s.fetchPolicyPack(r.Context(), req)
+	if err != nil {
+		s.logger.Warn("Policy pack import rejected", "url", req.URL, "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, errPackFetch) {
+			status = http.StatusBadGateway
+		}
+		s.sendError(w, status, err.Error())
+		return
+	}
+
+	user := requestUser(r)
+	if user != nil {
+		provenance.ImportedBy = user.Username
+	}
+
+	resp := api.PolicyImportResponse{Status: "success", Errors: []string{}}
+	for _, report := range pack.Manifest.Reports {
+		policy, err := reportPolicy(report.PolicyID, pack.Data(report))
+		if err == nil {
+			err = checkPolicySignature(report.Signature)
+		}
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Invalid report %s: %v", report.File, err))
+			continue
+		}
+		policy.Signature = report.Signature
+		policy.Provenance = provenance
+
+		existing, _ := s.requestDB(r).GetPolicy(policy.PolicyID)
+		switch {
+		case existing == nil:
+			if user != nil {
+				policy.Owner = user.Username
+			}
+			err = s.requestDB(r).CreatePolicy(&policy)
+			if err == nil {
+				resp.Imported++
+			}
+		case !req.Update:
+			resp.Skipped++
+			continue
+		case !canEditPolicy(user, existing):
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Not allowed to update %s", policy.PolicyID))
+			continue
+		default:
+			policy.Status = existing.Status
+			policy.RetentionDays = existing.RetentionDays
+			err = s.requestDB(r).UpdatePolicy(policy.PolicyID, &policy)
+			if err == nil {
+				resp.Updated++
+			}
+		}
+		if err != nil {
+			s.logger.Error("Failed to import policy", "policy_id", policy.PolicyID, "error", err)
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Failed to import %s: %v", policy.PolicyID, err))
+		}
+	}
+
+	s.logger.Info("Policy pack imported",
+		"pack", provenance.Pack,
+		"pack_version", provenance.PackVersion,
+		"source", provenance.Source,
+		"signed_by", provenance.SignedBy,
+		"imported", resp.Imported,
+		"updated", resp.Updated,
+		"skipped", resp.Skipped,
+	)
+
+	resp.Message = fmt.Sprintf("Imported %d policies from %s %s, updated %d, skipped %d existing",
+		resp.Imported, provenance.Pack, provenance.PackVersion, resp.Updated, resp.Skipped)
+	noteAdminChange(r, req.URL, nil, resp)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleExportPolicyPack bundles policies into a policy pack signed with the
+// server's signing key (GET /api/v1/policies/export-pack), for import into
+// another environment that trusts this server's key. Repeated policy_id
+// parameters select the policies, any status; without them every active
+// policy is exported. name and version label the pack. The detached
+// signature is sent in X-Pack-Signature.
+func (s *ComplianceServer) handleExportPolicyPack(w http.ResponseWriter, r *http.Request) {
+	if s.commandKey == nil {
+		s.sendError(w, http.StatusServiceUnavailable, "Command signing is not initialized")
+		return
+	}
+
+	query := r.URL.Query()
+	var policies []Policy
+	if ids := query["policy_id"]; len(ids) > 0 {
+		for _, id := range ids {
+			policy, err := s.requestDB(r).GetPolicy(id)
+			if err != nil {
+				if err.Error() == "policy not found" {
+					s.sendError(w, http.StatusNotFound, fmt.Sprintf("Policy not found: %s", id))
+				} else {
+					s.logger.Error("Failed to get policy", "error", err, "policy_id", id)
+					s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policy")
+				}
+				return
+			}
+			policies = append(policies, *policy)
+		}
+	} else {
+		all, err := s.requestDB(r).ListPolicies()
+		if err != nil {
+			s.logger.Error("Failed to list policies", "error", err)
+			s.sendError(w, http.StatusInternalServerError, "Failed to retrieve policies")
+			return
+		}
+		for _, policy := range all {
+			if policy.Status == "active" {
+				policies = append(policies, policy)
+			}
+		}
+	}
+	if len(policies) == 0 {
+		s.sendError(w, http.StatusNotFound, "No active policies to export")
+		return
+	}
+
+	manifest := policypack.Manifest{
+		Name:      query.Get("name"),
+		Version:   query.Get("version"),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if manifest.Name == "" {
+		manifest.Name = "policies"
+	}
+	if manifest.Version == "" {
+		manifest.Version = manifest.CreatedAt.Format("20060102-150405")
+	}
+	if user := requestUser(r); user != nil {
+		manifest.Publisher = user.Username
+	}
+
+	archive, err := s.buildPolicyPack(manifest, policies)
+	if err != nil {
+		s.logger.Error("Failed to build policy pack", "error", err)
+		s.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.logger.Info("Policy pack exported", "pack", manifest.Name, "pack_version", manifest.Version, "policies", len(policies))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", manifest.Name+"-"+manifest.Version+".zip"))
+	w.Header().Set(api.HeaderPackSignature, policypack.Sign(s.commandKey, archive))
+	w.Write(archive)
+}
+
+// buildPolicyPack writes policies into a pack archive as
+// reports/<policy_id>.json, each with the signature clients verify it by
+func (s *ComplianceServer) buildPolicyPack(manifest policypack.Manifest, policies []Policy) ([]byte, error) {
+	files := make(map[string][]byte, len(policies))
+	for i := range policies {
+		policy := &policies[i]
+		file := "reports/" + policy.PolicyID + ".json"
+		files[file] = []byte(policy.PolicyData)
+		manifest.Reports = append(manifest.Reports, policypack.Report{
+			File:      file,
+			PolicyID:  policy.PolicyID,
+			Signature: s.policyDownloadSignature(policy),
+		})
+	}
+	return policypack.Build(manifest, files)
+}
+
+// fetchPolicyPack downloads a pack and its detached signature, verifies the
+// signature against the trusted keys and reads the archive
+func (s *ComplianceServer) fetchPolicyPack(ctx context.Context, req api.PolicyPackImportRequest) (*policypack.Pack, *api.PolicyProvenance, error) {
+	keys, err := s.config().Policies.trustedKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &http.Client{
+		Timeout: time.Minute,
+		// Redirects must stay within the allowed sources too
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if len(via) >= 5 || !packSourceAllowed(s.config().Policies.PackSources, r.URL.String()) {
+				return fmt.Errorf("redirect to %s is not under policies.pack_sources", r.URL.Redacted())
+			}
+			return nil
+		},
+	}
+
+	archive, err := fetchURL(ctx, client, req.URL, int64(s.config().Policies.MaxPackSizeMB)<<20)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := fetchURL(ctx, client, req.SignatureURL, maxPackSignatureSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := policypack.Verify(archive, string(signature), keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	pack, err := policypack.Read(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid policy pack: %w", err)
+	}
+
+	return pack, &api.PolicyProvenance{
+		Source:      req.URL,
+		Pack:        pack.Manifest.Name,
+		PackVersion: pack.Manifest.Version,
+		Publisher:   pack.Manifest.Publisher,
+		PackSHA256:  pack.SHA256,
+		SignedBy:    base64.StdEncoding.EncodeToString(key),
+		ImportedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// fetchURL downloads at most maxSize bytes from rawURL
+func fetchURL(ctx context.Context, client *http.Client, rawURL string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errPackFetch, rawURL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errPackFetch, rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w %s: status %d", errPackFetch, rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errPackFetch, rawURL, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, maxSize)
+	}
+	return data, nil
+}
+
+// packSourceAllowed reports whether rawURL is under one of the configured
+// source prefixes: same scheme and host, and a path within the prefix's
+func packSourceAllowed(sources []string, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User != nil || strings.Contains(u.Path, "..") {
+		return false
+	}
+	for _, source := range sources {
+		prefix, err := url.Parse(source)
+		if err != nil || prefix.Scheme != u.Scheme || !strings.EqualFold(prefix.Host, u.Host) {
+			continue
+		}
+		dir := prefix.Path
+		if u.Path == dir || strings.HasPrefix(u.Path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+const (
+	// rolloutCheckInterval is how often running rollouts are compared with
+	// the versions they replace
+	rolloutCheckInterval = 5 * time.Minute
+
+	// Halt threshold of rollouts that do not set one
+	defaultRolloutMaxFailureIncrease = 5.0 // Percentage points
+	defaultRolloutMinSubmissions     = 10
+
+	// alertTypeRolloutHalted identifies alerts raised when a rollout is
+	// halted automatically
+	alertTypeRolloutHalted = "rollout_halted"
+)
+
+// rolloutReport is the part of a report configuration a rollout needs
+type rolloutReport struct {
+	Metadata struct {
+		ReportTitle   string `json:"report_title"`
+		ReportVersion string `json:"report_version"`
+	} `json:"metadata"`
+}
+
+// parseRolloutReport decodes the title and version of report configuration
+// JSON; submissions carry them as their report type and version
+func parseRolloutReport(data string) (*rolloutReport, error) {
+	var report rolloutReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("invalid policy data: %w", err)
+	}
+	if report.Metadata.ReportTitle == "" || report.Metadata.ReportVersion == "" {
+		return nil, fmt.Errorf("policy data needs metadata.report_title and metadata.report_version")
+	}
+	return &report, nil
+}
+
+// rolloutBucket places a client in one of 100 buckets, fixed per policy, so
+// raising a rollout's percentage only adds clients to its cohort
+func rolloutBucket(policyID, clientID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(policyID + "\x00" + clientID))
+	return int(h.Sum32() % 100)
+}
+
+// rolloutCohort reports whether a client gets a rollout's candidate: it
+// carries one of the rollout's ring tags or falls within its percentage.
+// Clients that do not identify themselves get the current version.
+func rolloutCohort(rollout *api.PolicyRollout, clientID string, tags []string) bool {
+	if clientID == "" {
+		return false
+	}
+	for _, ring := range rollout.Rings {
+		for _, tag := range tags {
+			if strings.EqualFold(ring, tag) {
+				return true
+			}
+		}
+	}
+	return rolloutBucket(rollout.PolicyID, clientID) < rollout.Percent
+}
+
+// rolloutHaltReason returns why a rollout should be halted, or "" while its
+// candidate fails no more than MaxFailureIncrease percentage points more
+// checks than the current version. Both versions need MinSubmissions
+// submissions before they are compared.
+func rolloutHaltReason(rollout *api.PolicyRollout) string {
+	candidate, base := rollout.Candidate, rollout.Base
+	if candidate == nil || base == nil ||
+		candidate.Submissions < rollout.MinSubmissions || base.Submissions < rollout.MinSubmissions {
+		return ""
+	}
+	increase := candidate.FailureRate - base.FailureRate
+	if increase <= rollout.MaxFailureIncrease {
+		return ""
+	}
+	return fmt.Sprintf("Version %s fails %.1f%% of checks, %.1f points more than version %s (%.1f%%); the limit is %.1f",
+		rollout.Version, candidate.FailureRate, increase, rollout.BaseVersion, base.FailureRate, rollout.MaxFailureIncrease)
+}
+
+// applyRolloutRequest sets the cohort and threshold of a rollout from a
+// request. Zero threshold fields keep the rollout's values.
+func applyRolloutRequest(rollout *api.PolicyRollout, req api.PolicyRolloutRequest) error {
+	if req.Percent < 0 || req.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	if req.MaxFailureIncrease < 0 || req.MinSubmissions < 0 {
+		return fmt.Errorf("max_failure_increase and min_submissions must not be negative")
+	}
+
+	rings := []string{}
+	for _, ring := range req.Rings {
+		if ring = strings.TrimSpace(ring); ring != "" {
+			rings = append(rings, ring)
+		}
+	}
+
+	rollout.Percent = req.Percent
+	rollout.Rings = rings
+	if req.MaxFailureIncrease > 0 {
+		rollout.MaxFailureIncrease = req.MaxFailureIncrease
+	}
+	if req.MinSubmissions > 0 {
+		rollout.MinSubmissions = req.MinSubmissions
+	}
+	return nil
+}
+
+// rolloutPolicy returns the policy to serve a client: the candidate of the
+// policy's running rollout when the client is in its cohort, else policy.
+// Lookup failures serve the current version.
+func (s *ComplianceServer) rolloutPolicy(policy *Policy, clientID string) *Policy {
+	if clientID == "" {
+		return policy
+	}
+	rollout, err := s.db.GetPolicyRollout(policy.PolicyID)
+	if err != nil {
+		if err.Error() != "rollout not found" {
+			s.logger.Error("Failed to get policy rollout", "error", err, "policy_id", policy.PolicyID)
+		}
+		return policy
+	}
+	if rollout.Status != api.RolloutActive {
+		return policy
+	}
+
+	tags, err := s.db.GetClientTags(clientID)
+	if err != nil {
+		s.logger.Error("Failed to get client tags", "error", err, "client_id", clientID)
+		return policy
+	}
+	if !rolloutCohort(rollout, clientID, tags) {
+		return policy
+	}
+
+	candidate := *policy
+	candidate.Version = rollout.Version
+	candidate.PolicyData = rollout.PolicyData
+	candidate.Signature = rollout.Signature
+	return &candidate
+}
+
+// rolloutStats fills in the submissions of a rollout's candidate and of the
+// current version since the rollout started
+func (s *ComplianceServer) rolloutStats(rollout *api.PolicyRollout, policy *Policy) error {
+	candidate, err := parseRolloutReport(rollout.PolicyData)
+	if err != nil {
+		return err
+	}
+	// The current version may have been stored before the title was
+	// required; it is then compared under the candidate's title
+	baseTitle := candidate.Metadata.ReportTitle
+	if base, err := parseRolloutReport(policy.PolicyData); err == nil {
+		baseTitle = base.Metadata.ReportTitle
+	}
+
+	since := parseDBTime(rollout.StartedAt)
+	if rollout.Candidate, err = s.db.RolloutStats(candidate.Metadata.ReportTitle, rollout.Version, since); err != nil {
+		return err
+	}
+	rollout.Base, err = s.db.RolloutStats(baseTitle, policy.Version, since)
+	return err
+}
+
+// startRolloutMonitor checks running rollouts periodically and halts those
+// whose candidate fails too many checks
+func (s *ComplianceServer) startRolloutMonitor() {
+	go func() {
+		ticker := time.NewTicker(rolloutCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.checkPolicyRollouts()
+		}
+	}()
+}
+
+// checkPolicyRollouts halts every running rollout whose candidate's failure
+// rate exceeds the current version's by more than its threshold. The halt is
+// recorded in the admin audit trail and raised as an alert.
+func (s *ComplianceServer) checkPolicyRollouts() {
+	rollouts, err := s.db.ListPolicyRollouts()
+	if err != nil {
+		s.logger.Error("Failed to list policy rollouts", "error", err)
+		return
+	}
+
+	for _, rollout := range rollouts {
+		if rollout.Status != api.RolloutActive {
+			continue
+		}
+		policy, err := s.db.GetPolicy(rollout.PolicyID)
+		if err != nil {
+			s.logger.Error("Failed to get policy", "error", err, "policy_id", rollout.PolicyID)
+			continue
+		}
+		if err := s.rolloutStats(&rollout, policy); err != nil {
+			s.logger.Error("Failed to compare rollout versions", "error", err, "policy_id", rollout.PolicyID)
+			continue
+		}
+
+		reason := rolloutHaltReason(&rollout)
+		if reason == "" {
+			continue
+		}
+		// With several replicas only the one that halts it records the halt
+		halted, err := s.db.HaltPolicyRollout(rollout.PolicyID, reason)
+		if err != nil || !halted {
+			if err != nil {
+				s.logger.Error("Failed to halt policy rollout", "error", err, "policy_id", rollout.PolicyID)
+			}
+			continue
+		}
+		s.logger.Warn("Policy rollout halted", "policy_id", rollout.PolicyID, "version", rollout.Version, "reason", reason)
+		s.recordRolloutHalt(rollout, policy, reason)
+	}
+}
+
+// recordRolloutHalt writes the audit entry and alert of a halted rollout
+func (s *ComplianceServer) recordRolloutHalt(rollout api.PolicyRollout, policy *Policy, reason string) {
+	before := rollout
+	before.PolicyData = ""
+	after := before
+	after.Status = api.RolloutHalted
+	after.HaltReason = reason
+
+	entry := api.AdminAuditEntry{
+		Actor:      "system",
+		Action:     auditPolicyRolloutHalt,
+		TargetType: "policy",
+		Target:     rollout.PolicyID,
+	}
+	var err error
+	if entry.Before, err = auditValue(before); err == nil {
+		entry.After, err = auditValue(after)
+	}
+	if err == nil {
+		err = s.addAdminAudit(entry)
+	}
+	if err != nil {
+		s.logger.Error("Failed to record admin audit", "error", err, "action", auditPolicyRolloutHalt, "target", rollout.PolicyID)
+	}
+
+	reportType := policy.Name
+	if report, err := parseRolloutReport(rollout.PolicyData); err == nil {
+		reportType = report.Metadata.ReportTitle
+	}
+	alert := &api.Alert{
+		Timestamp:  time.Now(),
+		Severity:   "critical",
+		Type:       alertTypeRolloutHalted,
+		ReportType: reportType,
+		Message:    fmt.Sprintf("Rollout of policy %s halted: %s", rollout.PolicyID, reason),
+	}
+	dedupeKey := fmt.Sprintf("%s:%s:%s:%s", alertTypeRolloutHalted, rollout.PolicyID, rollout.Version, rollout.StartedAt)
+	if _, err := s.raiseAlert(alert, dedupeKey); err != nil {
+		s.logger.Error("Failed to create alert", "error", err, "type", alertTypeRolloutHalted)
+	}
+}
+
+// handleGetPolicyRollout returns the rollout of a policy with the
+// submissions of both versions so far
+func (s *ComplianceServer) handleGetPolicyRollout(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	policy, err := s.requestDB(r).GetPolicy(policyID)
+	if err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to retrieve policy")
+		return
+	}
+	rollout, err := s.requestDB(r).GetPolicyRollout(policyID)
+	if err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to retrieve rollout")
+		return
+	}
+	if err := s.rolloutStats(rollout, policy); err != nil {
+		s.logger.Warn("Failed to compare rollout versions", "error", err, "policy_id", policyID)
+	}
+
+	s.respond(w, r, rollout, nil)
+}
+
+// handlePutPolicyRollout stages a candidate version of a policy, or changes
+// the cohort and threshold of its rollout and resumes it if halted. Only the
+// policy's owners and admins may.
+func (s *ComplianceServer) handlePutPolicyRollout(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	var req api.PolicyRolloutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	policy := s.authorizePolicyEdit(w, r, policyID)
+	if policy == nil {
+		return
+	}
+	if policy.Status != "active" {
+		s.sendError(w, http.StatusConflict, "Only active policies are distributed to clients")
+		return
+	}
+
+	existing, err := s.requestDB(r).GetPolicyRollout(policyID)
+	if err != nil && err.Error() != "rollout not found" {
+		s.sendPolicyRolloutError(w, err, "Failed to retrieve rollout")
+		return
+	}
+
+	rollout := &api.PolicyRollout{
+		PolicyID:           policyID,
+		MaxFailureIncrease: defaultRolloutMaxFailureIncrease,
+		MinSubmissions:     defaultRolloutMinSubmissions,
+	}
+	if existing != nil {
+		current := *existing
+		rollout = &current
+	}
+
+	if req.PolicyData != "" {
+		report, err := parseRolloutReport(req.PolicyData)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if report.Metadata.ReportVersion == policy.Version {
+			s.sendError(w, http.StatusBadRequest, fmt.Sprintf("The candidate must have a version other than the current %s", policy.Version))
+			return
+		}
+		if err := checkPolicySignature(req.Signature); err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// A new candidate is compared from now on
+		rollout.Version = report.Metadata.ReportVersion
+		rollout.PolicyData = req.PolicyData
+		rollout.Signature = req.Signature
+		rollout.StartedBy = s.auditActor(r)
+		rollout.StartedAt = time.Now().UTC().Format(time.RFC3339)
+	} else if existing == nil {
+		s.sendError(w, http.StatusBadRequest, "policy_data is required to start a rollout")
+		return
+	}
+
+	if err := applyRolloutRequest(rollout, req); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rollout.Status = api.RolloutActive
+	rollout.HaltReason = ""
+
+	if err := s.requestDB(r).SavePolicyRollout(rollout); err != nil {
+		s.logger.Error("Failed to save policy rollout", "error", err, "policy_id", policyID)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save rollout")
+		return
+	}
+
+	s.logger.Info("Policy rollout updated", "policy_id", policyID, "version", rollout.Version,
+		"percent", rollout.Percent, "rings", rollout.Rings)
+	rollout.BaseVersion = policy.Version
+	noteAdminChange(r, policyID, rolloutAuditValue(existing), rolloutAuditValue(rollout))
+
+	s.respond(w, r, rollout, nil)
+}
+
+// handlePromotePolicyRollout makes a rollout's candidate the policy's
+// current version, served to every client, and ends the rollout
+func (s *ComplianceServer) handlePromotePolicyRollout(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	policy := s.authorizePolicyEdit(w, r, policyID)
+	if policy == nil {
+		return
+	}
+	rollout, err := s.requestDB(r).GetPolicyRollout(policyID)
+	if err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to retrieve rollout")
+		return
+	}
+
+	if err := s.requestDB(r).PromotePolicyRollout(policyID); err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to promote rollout")
+		return
+	}
+
+	s.logger.Info("Policy rollout promoted", "policy_id", policyID, "version", rollout.Version, "previous_version", policy.Version)
+	noteAdminChange(r, policyID,
+		map[string]string{"version": policy.Version},
+		map[string]string{"version": rollout.Version})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Version %s is now served to every client", rollout.Version),
+	})
+}
+
+// handleDeletePolicyRollout abandons a rollout; every client gets the
+// policy's current version again
+func (s *ComplianceServer) handleDeletePolicyRollout(w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("policy_id")
+
+	if s.authorizePolicyEdit(w, r, policyID) == nil {
+		return
+	}
+	rollout, err := s.requestDB(r).GetPolicyRollout(policyID)
+	if err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to retrieve rollout")
+		return
+	}
+
+	if err := s.requestDB(r).DeletePolicyRollout(policyID); err != nil {
+		s.sendPolicyRolloutError(w, err, "Failed to delete rollout")
+		return
+	}
+
+	s.logger.Info("Policy rollout cancelled", "policy_id", policyID, "version", rollout.Version)
+	noteAdminChange(r, policyID, rolloutAuditValue(rollout), nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: "Rollout cancelled",
+	})
+}
+
+// sendPolicyRolloutError answers a failed policy or rollout lookup
+func (s *ComplianceServer) sendPolicyRolloutError(w http.ResponseWriter, err error, message string) {
+	switch err.Error() {
+	case "policy not found":
+		s.sendError(w, http.StatusNotFound, "Policy not found")
+	case "rollout not found":
+		s.sendError(w, http.StatusNotFound, "The policy has no rollout")
+	default:
+		s.logger.Error(message, "error", err)
+		s.sendError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// rolloutAuditValue is a rollout as recorded in the admin audit trail,
+// without its policy data
+func rolloutAuditValue(rollout *api.PolicyRollout) interface{} {
+	if rollout == nil {
+		return nil
+	}
+	value := *rollout
+	value.PolicyData = ""
+	value.Candidate, value.Base = nil, nil
+	return value
+}
+
+// policyRolloutColumns are the columns scanned by scanPolicyRollout
+const policyRolloutColumns = `r.policy_id, r.version, r.policy_data, r.signature, r.percent, r.rings,
+	r.max_failure_increase, r.min_submissions, r.status, r.halt_reason, r.started_by,
+	r.started_at, r.updated_at, p.version`
+
+// scanPolicyRollout reads a row of policyRolloutColumns
+func scanPolicyRollout(scan func(dest ...interface{}) error) (*api.PolicyRollout, error) {
+	var rollout api.PolicyRollout
+	var signature, rings, haltReason, startedBy, baseVersion sql.NullString
+	if err := scan(&rollout.PolicyID, &rollout.Version, &rollout.PolicyData, &signature,
+		&rollout.Percent, &rings, &rollout.MaxFailureIncrease, &rollout.MinSubmissions,
+		&rollout.Status, &haltReason, &startedBy, &rollout.StartedAt, &rollout.UpdatedAt, &baseVersion); err != nil {
+		return nil, err
+	}
+	rollout.Signature = signature.String
+	rollout.HaltReason = haltReason.String
+	rollout.StartedBy = startedBy.String
+	rollout.BaseVersion = baseVersion.String
+	rollout.Rings = []string{}
+	if rings.Valid && rings.String != "" {
+		if err := json.Unmarshal([]byte(rings.String), &rollout.Rings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rollout rings: %w", err)
+		}
+	}
+	return &rollout, nil
+}
+
+// GetPolicyRollout returns the rollout of a policy
+func (d *Database) GetPolicyRollout(policyID string) (*api.PolicyRollout, error) {
+	const query = `
+		SELECT ` + policyRolloutColumns + `
+		FROM policy_rollouts r
+		JOIN policies p ON p.policy_id = r.policy_id
+		WHERE r.policy_id = $1
+	`
+
+	rollout, err := scanPolicyRollout(d.db.QueryRow(safesql.New(query, policyID)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("rollout not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy rollout: %w", err)
+	}
+	return rollout, nil
+}
+
+// ListPolicyRollouts returns every policy rollout
+func (d *Database) ListPolicyRollouts() ([]api.PolicyRollout, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT ` + policyRolloutColumns + `
+		FROM policy_rollouts r
+		JOIN policies p ON p.policy_id = r.policy_id
+		ORDER BY r.policy_id
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	var rollouts []api.PolicyRollout
+	for rows.Next() {
+		rollout, err := scanPolicyRollout(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan policy rollout: %w", err)
+		}
+		rollouts = append(rollouts, *rollout)
+	}
+	return rollouts, rows.Err()
+}
+
+// SavePolicyRollout creates or replaces the rollout of a policy
+func (d *Database) SavePolicyRollout(rollout *api.PolicyRollout) error {
+	rings, err := json.Marshal(rollout.Rings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollout rings: %w", err)
+	}
+
+	const query = `
+		INSERT INTO policy_rollouts (
+			policy_id, version, policy_data, signature, percent, rings, max_failure_increase,
+			min_submissions, status, halt_reason, started_by, started_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CURRENT_TIMESTAMP)
+		ON CONFLICT (policy_id) DO UPDATE SET
+			version = EXCLUDED.version, policy_data = EXCLUDED.policy_data,
+			signature = EXCLUDED.signature, percent = EXCLUDED.percent, rings = EXCLUDED.rings,
+			max_failure_increase = EXCLUDED.max_failure_increase,
+			min_submissions = EXCLUDED.min_submissions, status = EXCLUDED.status,
+			halt_reason = EXCLUDED.halt_reason, started_by = EXCLUDED.started_by,
+			started_at = EXCLUDED.started_at, updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = d.db.Exec(safesql.New(query, rollout.PolicyID, rollout.Version, rollout.PolicyData, nullIfEmpty(rollout.Signature), rollout.Percent, string(rings), rollout.MaxFailureIncrease, rollout.MinSubmissions, rollout.Status, nullIfEmpty(rollout.HaltReason), nullIfEmpty(rollout.StartedBy), parseDBTime(rollout.StartedAt).UTC().Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to save policy rollout: %w", err)
+	}
+	return nil
+}
+
+// HaltPolicyRollout halts a running rollout. It reports false when the
+// rollout was no longer running, e.g. another replica halted it first.
+func (d *Database) HaltPolicyRollout(policyID, reason string) (bool, error) {
+	const query = `
+		UPDATE policy_rollouts
+		SET status = $1, halt_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE policy_id = $3 AND status = $4
+	`
+
+	result, err := d.db.Exec(safesql.New(query, api.RolloutHalted, reason, policyID, api.RolloutActive))
+	if err != nil {
+		return false, fmt.Errorf("failed to halt policy rollout: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// PromotePolicyRollout replaces a policy's version, data and signature with
+// its rollout's candidate, keeping the replaced version as a revision, and
+// deletes the rollout
+func (d *Database) PromotePolicyRollout(policyID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := savePolicyRevision(tx, policyID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(safesql.New(`
+		UPDATE policies p
+		SET version = r.version, policy_data = r.policy_data, signature = r.signature,
+		    provenance = NULL, updated_at = CURRENT_TIMESTAMP
+		FROM policy_rollouts r
+		WHERE r.policy_id = p.policy_id AND p.policy_id = $1
+	`, policyID))
+	if err != nil {
+		return fmt.Errorf("failed to promote policy rollout: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rows == 0 {
+		return fmt.Errorf("rollout not found")
+	}
+
+	if _, err := tx.Exec(safesql.New(`DELETE FROM policy_rollouts WHERE policy_id = $1`, policyID)); err != nil {
+		return fmt.Errorf("failed to delete policy rollout: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeletePolicyRollout deletes the rollout of a policy
+func (d *Database) DeletePolicyRollout(policyID string) error {
+	result, err := d.db.Exec(safesql.New(`DELETE FROM policy_rollouts WHERE policy_id = $1`, policyID))
+	if err != nil {
+		return fmt.Errorf("failed to delete policy rollout: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("rollout not found")
+	}
+	return nil
+}
+
+// RolloutStats summarizes the submissions of a report type and version
+// received since a time
+func (d *Database) RolloutStats(reportType, version string, since time.Time) (*api.RolloutStats, error) {
+	const query = `
+		SELECT COUNT(*), COUNT(DISTINCT client_id),
+		       COALESCE(SUM(failed_checks), 0), COALESCE(SUM(total_checks), 0)
+		FROM submissions
+		WHERE report_type = $1 AND report_version = $2 AND created_at >= $3
+	`
+
+	var stats api.RolloutStats
+	var failed, total int64
+	err := d.db.QueryRow(safesql.New(query, reportType, version, since.UTC().Format(time.RFC3339))).
+		Scan(&stats.Submissions, &stats.Clients, &failed, &total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollout stats: %w", err)
+	}
+	if total > 0 {
+		stats.FailureRate = float64(failed) * 100 / float64(total)
+	}
+	return &stats, nil
+}
+
+// GetClientTags returns the tags of a client, empty for unknown clients
+func (d *Database) GetClientTags(clientID string) ([]string, error) {
+	rows, err := d.db.Query(safesql.New(`SELECT tag FROM client_tags WHERE client_id = $1 ORDER BY tag`, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan client tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
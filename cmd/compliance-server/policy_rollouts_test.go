@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestRolloutCohort tests that rings and the percentage select clients, and
+// that raising the percentage keeps the clients already selected
+func TestRolloutCohort(t *testing.T) {
+	rollout := &api.PolicyRollout{PolicyID: "cis-l1", Rings: []string{"Canary"}}
+
+	if rolloutCohort(rollout, "", []string{"canary"}) {
+		t.Error("a client without an ID is in the cohort")
+	}
+	if !rolloutCohort(rollout, "client-1", []string{"pos", "canary"}) {
+		t.Error("a client tagged with a ring is not in the cohort")
+	}
+	if rolloutCohort(rollout, "client-1", nil) {
+		t.Error("a client is in the cohort at 0 percent")
+	}
+
+	cohort := func(percent int) map[string]bool {
+		rollout := &api.PolicyRollout{PolicyID: "cis-l1", Percent: percent}
+		selected := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			clientID := fmt.Sprintf("client-%d", i)
+			if rolloutCohort(rollout, clientID, nil) {
+				selected[clientID] = true
+			}
+		}
+		return selected
+	}
+	five, twenty, all := cohort(5), cohort(20), cohort(100)
+	if len(five) < 20 || len(five) > 90 {
+		t.Errorf("5 percent selected %d of 1000 clients", len(five))
+	}
+	for clientID := range five {
+		if !twenty[clientID] {
+			t.Errorf("%s left the cohort when the percentage was raised", clientID)
+		}
+	}
+	if len(all) != 1000 {
+		t.Errorf("100 percent selected %d of 1000 clients", len(all))
+	}
+}
+
+// TestRolloutHaltReason tests when a candidate's failure rate halts its rollout
+func TestRolloutHaltReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate api.RolloutStats
+		base      api.RolloutStats
+		halt      bool
+	}{
+		{"within threshold", api.RolloutStats{Submissions: 10, FailureRate: 14}, api.RolloutStats{Submissions: 50, FailureRate: 10}, false},
+		{"spike", api.RolloutStats{Submissions: 10, FailureRate: 25}, api.RolloutStats{Submissions: 50, FailureRate: 10}, true},
+		{"too few candidate submissions", api.RolloutStats{Submissions: 9, FailureRate: 90}, api.RolloutStats{Submissions: 50, FailureRate: 10}, false},
+		{"too few base submissions", api.RolloutStats{Submissions: 10, FailureRate: 90}, api.RolloutStats{Submissions: 3, FailureRate: 10}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rollout := &api.PolicyRollout{
+				Version: "2.0", BaseVersion: "1.0",
+				MaxFailureIncrease: 5, MinSubmissions: 10,
+				Candidate: &tt.candidate, Base: &tt.base,
+			}
+			reason := rolloutHaltReason(rollout)
+			if (reason != "") != tt.halt {
+				t.Errorf("rolloutHaltReason() = %q, want halt %v", reason, tt.halt)
+			}
+			if tt.halt && !strings.Contains(reason, "15.0 points") {
+				t.Errorf("rolloutHaltReason() = %q, want the increase", reason)
+			}
+		})
+	}
+}
+
+// TestApplyRolloutRequest tests request validation and that unset
+// thresholds keep the rollout's values
+func TestApplyRolloutRequest(t *testing.T) {
+	rollout := &api.PolicyRollout{MaxFailureIncrease: 5, MinSubmissions: 10}
+	err := applyRolloutRequest(rollout, api.PolicyRolloutRequest{Percent: 20, Rings: []string{" ring0 ", ""}})
+	if err != nil {
+		t.Fatalf("applyRolloutRequest() error = %v", err)
+	}
+	if rollout.Percent != 20 || len(rollout.Rings) != 1 || rollout.Rings[0] != "ring0" ||
+		rollout.MaxFailureIncrease != 5 || rollout.MinSubmissions != 10 {
+		t.Errorf("rollout = %+v", rollout)
+	}
+
+	for _, req := range []api.PolicyRolloutRequest{{Percent: 101}, {Percent: -1}, {MaxFailureIncrease: -1}} {
+		if err := applyRolloutRequest(&api.PolicyRollout{}, req); err == nil {
+			t.Errorf("applyRolloutRequest(%+v) error = nil", req)
+		}
+	}
+}
+
+// TestParseRolloutReport tests that candidates need a title and version
+func TestParseRolloutReport(t *testing.T) {
+	report, err := parseRolloutReport(`{"metadata":{"report_title":"CIS L1","report_version":"2.0"}}`)
+	if err != nil || report.Metadata.ReportTitle != "CIS L1" || report.Metadata.ReportVersion != "2.0" {
+		t.Errorf("parseRolloutReport() = %+v, %v", report, err)
+	}
+	for _, data := range []string{`{"metadata":{"report_title":"CIS L1"}}`, `not json`} {
+		if _, err := parseRolloutReport(data); err == nil {
+			t.Errorf("parseRolloutReport(%s) error = nil", data)
+		}
+	}
+}
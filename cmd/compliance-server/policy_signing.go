@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// signPolicy signs a policy's report configuration for distribution to
+// clients
+func signPolicy(key ed25519.PrivateKey, policyID, version string, data []byte) string {
+	sum := sha256.Sum256(data)
+	payload := api.PolicySigningPayload(policyID, version, hex.EncodeToString(sum[:]))
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+}
+
+// signScript signs the script of a powershell query for clients, which
+// verify it before running the script
+func signScript(key ed25519.PrivateKey, queryName, script string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, api.ScriptSigningPayload(queryName, script)))
+}
+
+// checkPolicySignature checks that a publisher signature submitted with a
+// policy is a well-formed Ed25519 signature. The server cannot verify it:
+// only clients hold the publisher's public key.
+func checkPolicySignature(signature string) error {
+	if signature == "" {
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64: %w", err)
+	}
+	if len(raw) != ed25519.SignatureSize {
+		return fmt.Errorf("signature must be a %d byte Ed25519 signature", ed25519.SignatureSize)
+	}
+	return nil
+}
+
+// policyDownloadSignature returns the signature served with a policy: its
+// publisher's when it has one, otherwise one made with the server's command
+// signing key
+func (s *ComplianceServer) policyDownloadSignature(policy *Policy) string {
+	if policy.Signature != "" || s.commandKey == nil {
+		return policy.Signature
+	}
+	return signPolicy(s.commandKey, policy.PolicyID, policy.Version, []byte(policy.PolicyData))
+}
+
+// runSignPolicyCommand signs a report configuration with a publisher key
+// kept away from the server (compliance-server sign-policy publisher.key
+// report.json), creating the key on first use. The policy ID is the file
+// name without extension and the version its metadata.report_version, as
+// the server assigns when importing configs/reports. It prints the
+// signature and returns false on failure.
+func runSignPolicyCommand(keyFile, reportFile string) bool {
+	key, created, err := loadOrCreateSigningKey(keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Created publisher key %s. Configure clients with its public key:\n  reports.policy_public_key: %q\n",
+			keyFile, base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	var report struct {
+		Metadata struct {
+			ReportVersion string `json:"report_version"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s is not valid JSON: %v\n", reportFile, err)
+		return false
+	}
+
+	base := filepath.Base(reportFile)
+	policyID := strings.TrimSuffix(base, filepath.Ext(base))
+	fmt.Println(signPolicy(key, policyID, report.Metadata.ReportVersion, data))
+	return true
+}
+
+// runSignScriptCommand signs the scripts of a report configuration's
+// powershell queries with a publisher key (compliance-server sign-script
+// publisher.key report.json), creating the key on first use. It prints one
+// "name<TAB>signature" line per query, to copy into script_signature, and
+// returns false on failure.
+func runSignScriptCommand(keyFile, reportFile string) bool {
+	key, created, err := loadOrCreateSigningKey(keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Created publisher key %s. Configure clients with its public key:\n  security.powershell.public_key: %q\n",
+			keyFile, base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	var report struct {
+		Queries []struct {
+			Name      string `json:"name"`
+			Operation string `json:"operation"`
+			Script    string `json:"script"`
+		} `json:"queries"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s is not valid JSON: %v\n", reportFile, err)
+		return false
+	}
+
+	signed := 0
+	for _, query := range report.Queries {
+		if !strings.EqualFold(query.Operation, "powershell") || query.Script == "" {
+			continue
+		}
+		fmt.Printf("%s\t%s\n", query.Name, signScript(key, query.Name, query.Script))
+		signed++
+	}
+	if signed == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s has no powershell queries\n", reportFile)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSignPolicy tests that policy signatures verify for the signed policy,
+// version and data only
+func TestSignPolicy(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"metadata":{"report_version":"1.0"}}`)
+	signature, err := base64.StdEncoding.DecodeString(signPolicy(privateKey, "cis-l1", "1.0", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	tests := []struct {
+		name     string
+		policyID string
+		version  string
+		data     []byte
+		valid    bool
+	}{
+		{"unchanged", "cis-l1", "1.0", data, true},
+		{"other policy", "nist", "1.0", data, false},
+		{"other version", "cis-l1", "1.1", data, false},
+		{"changed data", "cis-l1", "1.0", []byte(`{}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := api.PolicySigningPayload(tt.policyID, tt.version, hash(tt.data))
+			if got := ed25519.Verify(publicKey, payload, signature); got != tt.valid {
+				t.Errorf("Verify() = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+
+	if err := checkPolicySignature(base64.StdEncoding.EncodeToString(signature)); err != nil {
+		t.Errorf("checkPolicySignature() error = %v", err)
+	}
+	for _, bad := range []string{"not base64!", "c2hvcnQ="} {
+		if err := checkPolicySignature(bad); err == nil {
+			t.Errorf("checkPolicySignature(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+// TestSignScript tests that script signatures verify for the signed query
+// name and script only
+func TestSignScript(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus"
+	signature, err := base64.StdEncoding.DecodeString(signScript(privateKey, "bitlocker_os_volume", script))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		script string
+		valid  bool
+	}{
+		{"unchanged", "bitlocker_os_volume", script, true},
+		{"other query", "defender_signature_age", script, false},
+		{"changed script", "bitlocker_os_volume", script + "; Remove-Item C:\\x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := api.ScriptSigningPayload(tt.query, tt.script)
+			if got := ed25519.Verify(publicKey, payload, signature); got != tt.valid {
+				t.Errorf("Verify() = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+}
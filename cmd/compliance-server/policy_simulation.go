@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// Check outcomes used by policy simulation in addition to the statuses
+// clients report
+const (
+	checkStatusPass = "pass"
+	checkStatusFail = "fail"
+	checkStatusNone = "no_evidence"
+)
+
+// simulationPolicy is the part of a report configuration a simulation needs
+type simulationPolicy struct {
+	Metadata struct {
+		ReportTitle string `json:"report_title"`
+	} `json:"metadata"`
+	Queries []simulationQuery `json:"queries"`
+}
+
+// simulationQuery is one check of a report configuration
+type simulationQuery struct {
+	Name             string `json:"name"`
+	RootKey          string `json:"root_key"`
+	Path             string `json:"path"`
+	ValueName        string `json:"value_name"`
+	ExpectedValue    string `json:"expected_value"`
+	ExpectedOperator string `json:"expected_operator"`
+}
+
+// parseSimulationPolicy decodes report configuration JSON for simulation
+func parseSimulationPolicy(data string) (*simulationPolicy, error) {
+	var policy simulationPolicy
+	if err := json.Unmarshal([]byte(data), &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy data: %w", err)
+	}
+	if policy.Metadata.ReportTitle == "" {
+		return nil, fmt.Errorf("policy data has no metadata.report_title")
+	}
+	if len(policy.Queries) == 0 {
+		return nil, fmt.Errorf("policy data has no queries")
+	}
+	for _, query := range policy.Queries {
+		if err := api.ValidateExpected(query.ExpectedValue, query.ExpectedOperator); err != nil {
+			return nil, fmt.Errorf("query %q: %w", query.Name, err)
+		}
+	}
+	return &policy, nil
+}
+
+// evidenceKey identifies the registry value a check reads
+func evidenceKey(rootKey, path, valueName string) string {
+	return strings.ToLower(rootKey + `\` + path + `\` + valueName)
+}
+
+// simulatePolicy re-evaluates a policy's checks against the results stored in
+// a submission. Each check is matched to stored evidence by the registry value
+// it reads, falling back to the check name for submissions recorded before
+// clients reported locations. Checks the evidence cannot answer are listed in
+// NoEvidence and left out of the projected score; a summary-only submission
+// (evidence sampling) answers none.
+func simulatePolicy(policy *simulationPolicy, submission *api.ComplianceSubmission) api.ClientSimulation {
+	byLocation := make(map[string]api.QueryResult)
+	byName := make(map[string]api.QueryResult)
+	results := submission.Compliance.Queries
+	if submission.SummaryOnly {
+		results = nil
+	}
+	for _, result := range results {
+		if result.Path != "" {
+			byLocation[evidenceKey(result.RootKey, result.Path, result.ValueName)] = result
+		}
+		byName[result.Name] = result
+	}
+
+	sim := api.ClientSimulation{
+		ClientID:     submission.ClientID,
+		Hostname:     submission.Hostname,
+		SubmissionID: submission.SubmissionID,
+		EvidenceTime: submission.Timestamp,
+		NewlyFailing: []api.SimulatedCheck{},
+		NewlyPassing: []api.SimulatedCheck{},
+	}
+	if total := submission.Compliance.TotalChecks; total > 0 {
+		sim.CurrentScore = float64(submission.Compliance.PassedChecks) * 100 / float64(total)
+	}
+
+	var evaluated, passed int
+	for _, query := range policy.Queries {
+		stored, ok := byLocation[evidenceKey(query.RootKey, query.Path, query.ValueName)]
+		if !ok {
+			stored, ok = byName[query.Name]
+		}
+
+		projected := projectCheck(query, stored, ok)
+		if projected == checkStatusNone {
+			sim.NoEvidence = append(sim.NoEvidence, query.Name)
+			continue
+		}
+
+		evaluated++
+		if projected == checkStatusPass {
+			passed++
+		}
+
+		check := api.SimulatedCheck{
+			Name:            query.Name,
+			Expected:        api.DescribeExpected(query.ExpectedValue, query.ExpectedOperator),
+			Actual:          stored.Actual,
+			CurrentStatus:   stored.Status,
+			ProjectedStatus: projected,
+		}
+		switch {
+		case projected == checkStatusFail && stored.Status == checkStatusPass:
+			sim.NewlyFailing = append(sim.NewlyFailing, check)
+		case projected == checkStatusPass && stored.Status != checkStatusPass:
+			sim.NewlyPassing = append(sim.NewlyPassing, check)
+		}
+	}
+
+	if evaluated > 0 {
+		sim.ProjectedScore = float64(passed) * 100 / float64(evaluated)
+	}
+	return sim
+}
+
+// projectCheck decides how a check would turn out given the stored result
+// for the value it reads. A value the client could not read stays an error;
+// a missing value fails just as it does on the client, and a value the
+// policy's operator cannot compare (such as text under gte) is an error.
+func projectCheck(query simulationQuery, stored api.QueryResult, found bool) string {
+	if !found {
+		return checkStatusNone
+	}
+
+	if stored.Status == "error" {
+		return "error"
+	}
+	if stored.Actual == "not found" {
+		return checkStatusFail
+	}
+
+	matches, err := api.CompareWithOperator(stored.Actual, query.ExpectedValue, query.ExpectedOperator)
+	if len(stored.ActualList) > 0 {
+		matches, err = api.CompareList(stored.ActualList, query.ExpectedValue, query.ExpectedOperator)
+	}
+	switch {
+	case err != nil:
+		return "error"
+	case matches:
+		return checkStatusPass
+	default:
+		return checkStatusFail
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSimulatePolicy tests re-evaluating stored evidence under a changed policy
+func TestSimulatePolicy(t *testing.T) {
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		ClientID:     "client-1",
+		Compliance: api.ComplianceData{
+			TotalChecks:  4,
+			PassedChecks: 2,
+			Queries: []api.QueryResult{
+				{Name: "UAC", Status: "pass", Actual: "1", RootKey: "HKLM", Path: `SOFTWARE\Policies\System`, ValueName: "EnableLUA"},
+				{Name: "Firewall", Status: "fail", Actual: "0", RootKey: "HKLM", Path: `SYSTEM\Firewall`, ValueName: "Enabled"},
+				{Name: "Legacy", Status: "pass", Actual: "5"}, // Recorded before clients reported locations
+				{Name: "Audit", Status: "fail", Actual: "not found", RootKey: "HKLM", Path: `SYSTEM\Audit`, ValueName: "Level"},
+			},
+		},
+	}
+
+	policy := &simulationPolicy{Queries: []simulationQuery{
+		{Name: "UAC (renamed)", RootKey: "hklm", Path: `software\policies\system`, ValueName: "EnableLUA", ExpectedValue: "2"},
+		{Name: "Firewall", RootKey: "HKLM", Path: `SYSTEM\Firewall`, ValueName: "Enabled", ExpectedValue: "0 (Disabled)"},
+		{Name: "Legacy", ExpectedValue: "5"},
+		{Name: "Audit", RootKey: "HKLM", Path: `SYSTEM\Audit`, ValueName: "Level", ExpectedValue: "3"},
+		{Name: "New check", RootKey: "HKLM", Path: `SYSTEM\New`, ValueName: "Value", ExpectedValue: "1"},
+	}}
+
+	sim := simulatePolicy(policy, submission)
+
+	if got := checkNames(sim.NewlyFailing); !reflect.DeepEqual(got, []string{"UAC (renamed)"}) {
+		t.Errorf("NewlyFailing = %v, want [UAC (renamed)]", got)
+	}
+	if got := checkNames(sim.NewlyPassing); !reflect.DeepEqual(got, []string{"Firewall"}) {
+		t.Errorf("NewlyPassing = %v, want [Firewall]", got)
+	}
+	if !reflect.DeepEqual(sim.NoEvidence, []string{"New check"}) {
+		t.Errorf("NoEvidence = %v, want [New check]", sim.NoEvidence)
+	}
+	if sim.CurrentScore != 50 {
+		t.Errorf("CurrentScore = %v, want 50", sim.CurrentScore)
+	}
+	// Firewall and Legacy pass out of the four checks with evidence
+	if sim.ProjectedScore != 50 {
+		t.Errorf("ProjectedScore = %v, want 50", sim.ProjectedScore)
+	}
+
+	// A summary-only submission has no values to re-evaluate
+	submission.SummaryOnly = true
+	sim = simulatePolicy(policy, submission)
+	if len(sim.NoEvidence) != len(policy.Queries) || sim.ProjectedScore != 0 {
+		t.Errorf("summary-only NoEvidence = %v, ProjectedScore = %v; want every check without evidence", sim.NoEvidence, sim.ProjectedScore)
+	}
+}
+
+// TestProjectCheckOperators tests projecting checks that use expected operators
+func TestProjectCheckOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		expected string
+		actual   string
+		list     []string
+		want     string
+	}{
+		{"gte passes", "gte", "14", "15", nil, checkStatusPass},
+		{"gte fails", "gte", "14", "8", nil, checkStatusFail},
+		{"one of passes", "one_of", "[TLS1.2, TLS1.3]", "TLS1.3", nil, checkStatusPass},
+		{"non-numeric actual under gte", "gte", "14", "Enabled", nil, "error"},
+		{"list equals in any order", "equals", "b,a", "a, b", []string{"a", "b"}, checkStatusPass},
+		{"list subset fails", "subset", "TLS1.2,TLS1.3", "TLS1.0, TLS1.2", []string{"TLS1.0", "TLS1.2"}, checkStatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := simulationQuery{Name: "check", ExpectedValue: tt.expected, ExpectedOperator: tt.operator}
+			stored := api.QueryResult{Name: "check", Status: checkStatusPass, Actual: tt.actual, ActualList: tt.list}
+			if got := projectCheck(query, stored, true); got != tt.want {
+				t.Errorf("projectCheck() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkNames returns the names of simulated checks in order
+func checkNames(checks []api.SimulatedCheck) []string {
+	names := []string{}
+	for _, check := range checks {
+		names = append(names, check.Name)
+	}
+	return names
+}
+
+// TestSimulatePolicyValidation tests that simulation requests without a
+// usable policy are rejected before any evidence is loaded
+func TestSimulatePolicyValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"no policy", `{}`},
+		{"both policies", `{"policy_id":"nist","policy_data":"{}"}`},
+		{"malformed policy data", `{"policy_data":"{"}`},
+		{"no report title", `{"policy_data":"{\"queries\":[{\"name\":\"a\"}]}"}`},
+		{"no queries", `{"policy_data":"{\"metadata\":{\"report_title\":\"NIST\"}}"}`},
+		{"unknown operator", `{"policy_data":"{\"metadata\":{\"report_title\":\"NIST\"},\"queries\":[{\"name\":\"a\",\"expected_value\":\"1\",\"expected_operator\":\"approx\"}]}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/policies/simulate", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+}
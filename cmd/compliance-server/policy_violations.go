@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sort"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// policyViolationStats accumulates the blocked executions of one check
+type policyViolationStats struct {
+	violation api.PolicyViolation
+	clients   map[string]bool
+}
+
+// summarizePolicyViolations aggregates the checks clients reported as
+// blocked by their security settings (error class "blocked"), those blocked
+// on the most clients first. Submissions reduced by evidence sampling keep
+// the error class but not the registry location, which is taken from a full
+// submission when there is one.
+func summarizePolicyViolations(history []*api.ComplianceSubmission) []api.PolicyViolation {
+	stats := make(map[checkKey]*policyViolationStats)
+	for _, submission := range history {
+		for _, result := range submission.Compliance.Queries {
+			if result.ErrorClass != api.ErrorClassBlocked {
+				continue
+			}
+
+			key := checkKey{reportType: submission.ReportType, name: result.Name}
+			st, ok := stats[key]
+			if !ok {
+				st = &policyViolationStats{
+					violation: api.PolicyViolation{ReportType: key.reportType, Name: key.name},
+					clients:   make(map[string]bool),
+				}
+				stats[key] = st
+			}
+
+			v := &st.violation
+			v.Attempts++
+			st.clients[submission.ClientID] = true
+			latest := !submission.Timestamp.Before(v.LastSeen)
+			if latest {
+				v.LastSeen = submission.Timestamp
+			}
+			if result.Path != "" && (latest || v.Path == "") {
+				v.RootKey, v.Path, v.ValueName = result.RootKey, result.Path, result.ValueName
+				v.Reason = result.Message
+			}
+		}
+	}
+
+	violations := make([]api.PolicyViolation, 0, len(stats))
+	for _, st := range stats {
+		v := st.violation
+		v.ClientIDs = make([]string, 0, len(st.clients))
+		for clientID := range st.clients {
+			v.ClientIDs = append(v.ClientIDs, clientID)
+		}
+		sort.Strings(v.ClientIDs)
+		v.ClientsAffected = len(v.ClientIDs)
+		violations = append(violations, v)
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.ClientsAffected != b.ClientsAffected {
+			return a.ClientsAffected > b.ClientsAffected
+		}
+		if a.Attempts != b.Attempts {
+			return a.Attempts > b.Attempts
+		}
+		if a.ReportType != b.ReportType {
+			return a.ReportType < b.ReportType
+		}
+		return a.Name < b.Name
+	})
+	return violations
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSummarizePolicyViolations tests aggregating blocked checks per policy
+// and ranking them by the clients they were blocked on
+func TestSummarizePolicyViolations(t *testing.T) {
+	day := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	secrets := api.QueryResult{
+		Name: "LSA secrets", Status: "error", ErrorClass: api.ErrorClassBlocked,
+		RootKey: "HKLM", Path: `SECURITY\Policy\Secrets`, Message: "Blocked by security.deny_registry_paths",
+	}
+	userRun := api.QueryResult{
+		Name: "User Run key", Status: "error", ErrorClass: api.ErrorClassBlocked,
+		RootKey: "HKCU", Path: `Software\Microsoft\Windows\CurrentVersion\Run`,
+	}
+	sampled := api.QueryResult{Name: secrets.Name, Status: "error", ErrorClass: api.ErrorClassBlocked}
+	submission := func(clientID, reportType string, at time.Time, results ...api.QueryResult) *api.ComplianceSubmission {
+		return &api.ComplianceSubmission{
+			ClientID: clientID, ReportType: reportType, Timestamp: at,
+			Compliance: api.ComplianceData{Queries: results},
+		}
+	}
+
+	history := []*api.ComplianceSubmission{
+		submission("c1", "CIS", day, secrets, userRun, api.QueryResult{Name: "Firewall", Status: "pass"}),
+		submission("c1", "CIS", day.Add(24*time.Hour), sampled, userRun),
+		submission("c2", "CIS", day, secrets),
+		submission("c3", "NIST", day, api.QueryResult{Name: "Missing", Status: "error", ErrorClass: api.ErrorClassNotFound}),
+	}
+
+	violations := summarizePolicyViolations(history)
+	if len(violations) != 2 {
+		t.Fatalf("violations = %+v, want LSA secrets and User Run key", violations)
+	}
+
+	lsa := violations[0]
+	if lsa.Name != "LSA secrets" || lsa.ClientsAffected != 2 || lsa.Attempts != 3 || !reflect.DeepEqual(lsa.ClientIDs, []string{"c1", "c2"}) {
+		t.Errorf("first = %+v, want LSA secrets blocked 3 times on c1 and c2", lsa)
+	}
+	if lsa.Path != secrets.Path || lsa.Reason != secrets.Message || !lsa.LastSeen.Equal(day.Add(24*time.Hour)) {
+		t.Errorf("LSA secrets = %+v, want the path of a full submission and the latest time", lsa)
+	}
+	if run := violations[1]; run.Name != "User Run key" || run.ClientsAffected != 1 || run.Attempts != 2 || run.RootKey != "HKCU" {
+		t.Errorf("second = %+v, want User Run key blocked twice on one client", run)
+	}
+}
+
+// TestPolicyViolationsValidation tests parameter validation of the policy violations report
+func TestPolicyViolationsValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	for _, query := range []string{"days=0", "days=366", "days=week"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/analytics/policy-violations?"+query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d (body %s)", query, rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	}
+}
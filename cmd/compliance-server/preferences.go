@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"time"
+
+	// Time zones resolve on hosts without a zoneinfo database (Windows)
+	_ "time/tzdata"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// localePattern accepts BCP 47 language tags such as "en", "de-DE" or
+// "zh-Hant-TW"; browsers validate them fully when formatting
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// loadDisplayLocation resolves an IANA time zone name. An empty name is
+// valid and means the viewer's browser time zone.
+func loadDisplayLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q (use an IANA name such as Europe/Berlin)", name)
+	}
+	return location, nil
+}
+
+// displayPreferences returns how timestamps are shown to user, who is nil
+// for API key requests: the user's time zone, else dashboard.timezone
+func (s *ComplianceServer) displayPreferences(user *User, now time.Time) api.DisplayPreferences {
+	prefs := api.DisplayPreferences{
+		EffectiveTimezone: s.config().Dashboard.Timezone,
+		ServerTime:        now.UTC(),
+	}
+	if user != nil {
+		prefs.Timezone, prefs.Locale = user.Timezone, user.Locale
+		if user.Timezone != "" {
+			prefs.EffectiveTimezone = user.Timezone
+		}
+	}
+	if location, err := loadDisplayLocation(prefs.EffectiveTimezone); err == nil && location != nil {
+		prefs.UTCOffset = now.In(location).Format("-07:00")
+	}
+	return prefs
+}
+
+// handleGetPreferences returns the caller's display preferences
+func (s *ComplianceServer) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.displayPreferences(requestUser(r), time.Now()))
+}
+
+// handleSetPreferences stores the time zone and locale the logged-in user's
+// dashboard shows timestamps in. Empty values restore the defaults.
+func (s *ComplianceServer) handleSetPreferences(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	if user == nil {
+		s.sendError(w, http.StatusBadRequest, "Display preferences belong to a user; log in to set them")
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+		Locale   string `json:"locale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, err := loadDisplayLocation(req.Timezone); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Locale != "" && !localePattern.MatchString(req.Locale) {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid locale %q (use a language tag such as en-GB)", req.Locale))
+		return
+	}
+
+	if err := s.requestDB(r).SetUserPreferences(user.Username, req.Timezone, req.Locale); err != nil {
+		s.logger.Error("Failed to update preferences", "username", user.Username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update preferences")
+		return
+	}
+	user.Timezone, user.Locale = req.Timezone, req.Locale
+
+	s.logger.Info("Display preferences updated", "username", user.Username, "timezone", req.Timezone, "locale", req.Locale)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.displayPreferences(user, time.Now()))
+}
+
+// writePage writes a dashboard page with the viewer's display preferences as
+// meta tags in its head, which /static/js/datetime.js formats timestamps by
+func (s *ComplianceServer) writePage(w http.ResponseWriter, r *http.Request, page []byte) {
+	prefs := s.displayPreferences(requestUser(r), time.Now())
+	hints := fmt.Sprintf(`<meta name="display-timezone" content="%s">
+    <meta name="display-locale" content="%s">
+</head>`, html.EscapeString(prefs.EffectiveTimezone), html.EscapeString(prefs.Locale))
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(s.withMaintenanceBanner(bytes.Replace(page, []byte("</head>"), []byte(hints), 1)))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDisplayPreferences tests that a user's time zone overrides the server
+// default and comes with its current offset
+func TestDisplayPreferences(t *testing.T) {
+	s := newTestServer()
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	prefs := s.displayPreferences(nil, now)
+	if prefs.EffectiveTimezone != "" || prefs.UTCOffset != "" {
+		t.Errorf("no default = %+v, want the browser's zone", prefs)
+	}
+
+	s.config().Dashboard.Timezone = "UTC"
+	if prefs := s.displayPreferences(nil, now); prefs.EffectiveTimezone != "UTC" || prefs.UTCOffset != "+00:00" {
+		t.Errorf("server default = %+v, want UTC +00:00", prefs)
+	}
+
+	user := &User{Username: "emea-ops", Timezone: "Europe/Berlin", Locale: "de-DE"}
+	prefs = s.displayPreferences(user, now.In(time.FixedZone("", -5*3600)))
+	if prefs.EffectiveTimezone != "Europe/Berlin" || prefs.UTCOffset != "+02:00" || prefs.Locale != "de-DE" {
+		t.Errorf("user preference = %+v, want Europe/Berlin +02:00 de-DE", prefs)
+	}
+	if !prefs.ServerTime.Equal(now) || prefs.ServerTime.Location() != time.UTC {
+		t.Errorf("server time = %v, want %v in UTC", prefs.ServerTime, now)
+	}
+}
+
+// TestLoadDisplayLocation tests time zone validation
+func TestLoadDisplayLocation(t *testing.T) {
+	for _, name := range []string{"", "UTC", "America/New_York", "Asia/Kolkata"} {
+		if _, err := loadDisplayLocation(name); err != nil {
+			t.Errorf("loadDisplayLocation(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"Local", "CEST", "Mars/Olympus_Mons"} {
+		if _, err := loadDisplayLocation(name); err == nil {
+			t.Errorf("loadDisplayLocation(%q) error = nil, want error", name)
+		}
+	}
+}
+
+// TestSetPreferencesValidation tests that preferences need a user and valid
+// values before anything is stored
+func TestSetPreferencesValidation(t *testing.T) {
+	s := newTestServer()
+	tests := []struct {
+		name string
+		user *User
+		body string
+	}{
+		{"api key", nil, `{"timezone":"UTC"}`},
+		{"unknown zone", &User{Username: "admin"}, `{"timezone":"Europe/Atlantis"}`},
+		{"invalid locale", &User{Username: "admin"}, `{"locale":"en_US.UTF-8"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/preferences", strings.NewReader(tt.body))
+			if tt.user != nil {
+				req = withUser(req, tt.user)
+			}
+			rec := httptest.NewRecorder()
+			s.handleSetPreferences(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+// TestWritePage tests that pages carry the viewer's display preferences
+func TestWritePage(t *testing.T) {
+	s := newTestServer()
+	req := withUser(httptest.NewRequest(http.MethodGet, "/dashboard", nil),
+		&User{Username: "admin", Timezone: "Asia/Tokyo", Locale: "ja"})
+	rec := httptest.NewRecorder()
+	s.writePage(rec, req, []byte("<html><head><title>x</title></head><body></body></html>"))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta name="display-timezone" content="Asia/Tokyo">`,
+		`<meta name="display-locale" content="ja">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page %q does not contain %q", body, want)
+		}
+	}
+	if strings.Index(body, "display-locale") > strings.Index(body, "</head>") {
+		t.Error("display hints are not in <head>")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Digest frequencies a user can choose
+const (
+	digestOff    = "off"
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// maxDisplayNameLength bounds display names, in characters
+const maxDisplayNameLength = 100
+
+// profile returns the user's own account information
+func (u User) profile() api.UserProfile {
+	return api.UserProfile{
+		Username:      u.Username,
+		Role:          u.Role,
+		DisplayName:   u.DisplayName,
+		Email:         u.Email,
+		Notifications: u.Notifications,
+	}
+}
+
+// applyProfileRequest validates a profile update and applies it to user
+func applyProfileRequest(user *User, req api.UserProfileRequest) error {
+	displayName := strings.TrimSpace(req.DisplayName)
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		return fmt.Errorf("display name must be at most %d characters", maxDisplayNameLength)
+	}
+	if strings.IndexFunc(displayName, unicode.IsControl) >= 0 {
+		return fmt.Errorf("display name must not contain control characters")
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if email != "" && !validEmail(email) {
+		return fmt.Errorf("invalid email address %q", req.Email)
+	}
+
+	// Without an email address the preferences are kept but nothing is sent
+	if req.Notifications != nil {
+		notifications := *req.Notifications
+		switch notifications.Digest {
+		case "":
+			notifications.Digest = digestOff
+		case digestOff, digestDaily, digestWeekly:
+		default:
+			return fmt.Errorf("digest must be %s, %s or %s", digestOff, digestDaily, digestWeekly)
+		}
+		user.Notifications = notifications
+	}
+
+	user.DisplayName = displayName
+	user.Email = email
+	return nil
+}
+
+// handleGetProfile returns the logged-in user's profile (GET /api/v1/profile)
+func (s *ComplianceServer) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	if user == nil {
+		s.sendError(w, http.StatusBadRequest, "Profiles belong to a user; log in to see yours")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.profile())
+}
+
+// handleUpdateProfile updates the logged-in user's display name, email and
+// notification preferences (PUT /api/v1/profile)
+func (s *ComplianceServer) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	if user == nil {
+		s.sendError(w, http.StatusBadRequest, "Profiles belong to a user; log in to update yours")
+		return
+	}
+
+	var req api.UserProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	s.updateProfile(w, r, user, req)
+}
+
+// handleSetUserProfile updates another user's profile
+// (POST /api/v1/users/profile)
+func (s *ComplianceServer) handleSetUserProfile(w http.ResponseWriter, r *http.Request) {
+	var req api.UserProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if req.Username == "" {
+		s.sendError(w, http.StatusBadRequest, "Username is required")
+		return
+	}
+
+	user, err := s.requestDB(r).GetUser(req.Username)
+	if err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to get user", "username", req.Username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	s.updateProfile(w, r, user, req)
+}
+
+// updateProfile applies a profile update to user, stores it and responds
+// with the updated profile
+func (s *ComplianceServer) updateProfile(w http.ResponseWriter, r *http.Request, user *User, req api.UserProfileRequest) {
+	updated := *user
+	if err := applyProfileRequest(&updated, req); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.requestDB(r).SetUserProfile(updated.Username, updated.DisplayName, updated.Email, updated.Notifications); err != nil {
+		if err.Error() == "user not found" {
+			s.sendError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		s.logger.Error("Failed to update profile", "username", updated.Username, "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+	noteAdminChange(r, user.Username, user.profile(), updated.profile())
+	*user = updated
+
+	s.logger.Info("User profile updated", "username", user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.profile())
+}
+
+// SetUserProfile stores a user's display name, email and notification
+// preferences
+func (d *Database) SetUserProfile(username, displayName, email string, notifications api.NotificationPreferences) error {
+	const query = `
+		UPDATE users SET display_name = $1, email = $2, notify_alerts = $3, notify_key_expiry = $4, notify_digest = $5
+		WHERE username = $6
+	`
+
+	result, err := d.db.Exec(safesql.New(query, displayName, email, notifications.Alerts, notifications.APIKeyExpiry, notifications.Digest, username))
+	if err != nil {
+		return fmt.Errorf("failed to update user profile: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestApplyProfileRequest tests validating and applying profile updates
+func TestApplyProfileRequest(t *testing.T) {
+	current := User{
+		Username:      "alice",
+		Email:         "alice@example.com",
+		Notifications: api.NotificationPreferences{APIKeyExpiry: true, Digest: digestWeekly},
+	}
+
+	user := current
+	err := applyProfileRequest(&user, api.UserProfileRequest{DisplayName: "  Alice Auditor ", Email: "alice@corp.example.com"})
+	if err != nil {
+		t.Fatalf("applyProfileRequest() error = %v", err)
+	}
+	if user.DisplayName != "Alice Auditor" || user.Email != "alice@corp.example.com" || user.Notifications != current.Notifications {
+		t.Errorf("user = %+v; want trimmed name, new email and unchanged notifications", user)
+	}
+
+	user = current
+	err = applyProfileRequest(&user, api.UserProfileRequest{Email: "alice@example.com", Notifications: &api.NotificationPreferences{Alerts: true}})
+	if err != nil {
+		t.Fatalf("applyProfileRequest() error = %v", err)
+	}
+	if want := (api.NotificationPreferences{Alerts: true, Digest: digestOff}); user.Notifications != want {
+		t.Errorf("notifications = %+v, want %+v", user.Notifications, want)
+	}
+
+	for name, req := range map[string]api.UserProfileRequest{
+		"invalid email":     {Email: "alice"},
+		"long display name": {DisplayName: strings.Repeat("a", maxDisplayNameLength+1)},
+		"control character": {DisplayName: "Alice\nBcc: everyone"},
+		"unknown digest":    {Notifications: &api.NotificationPreferences{Digest: "hourly"}},
+	} {
+		user := current
+		if err := applyProfileRequest(&user, req); err == nil {
+			t.Errorf("%s: error = nil, want error", name)
+		}
+	}
+}
+
+// TestProfileRequiresUser tests that API key requests have no profile
+func TestProfileRequiresUser(t *testing.T) {
+	s := newTestServer()
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		req := httptest.NewRequest(method, "/api/v1/profile", strings.NewReader(`{"display_name":"Agent"}`))
+		rec := httptest.NewRecorder()
+		if method == http.MethodGet {
+			s.handleGetProfile(rec, req)
+		} else {
+			s.handleUpdateProfile(rec, req)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want 400", method, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitSweepInterval is how often idle IP addresses are forgotten
+const rateLimitSweepInterval = time.Minute
+
+// rateLimitKeyPrefix starts the cache keys of token buckets, which are
+// followed by the limiter's name and the address
+const rateLimitKeyPrefix = "ratelimit:"
+
+// rateLimiter is a token bucket per IP address: each address may make up to
+// a minute's worth of requests at once, refilled evenly over the minute.
+// With a Redis cache the buckets are kept in Redis, so every replica counts
+// against the same limit; while Redis is unavailable, and without it, they
+// are kept in memory.
+type rateLimiter struct {
+	name   string
+	shared *fallbackCache // nil without a Redis cache
+
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket holds the requests an address has left
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests per address.
+// Its buckets are shared through cache when that is a Redis cache, under
+// name, which tells the limiters apart.
+func newRateLimiter(name string, perMinute int, cache sharedCache) *rateLimiter {
+	l := &rateLimiter{
+		name:      name,
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(perMinute),
+		buckets:   make(map[string]*tokenBucket),
+	}
+	if fallback, ok := cache.(*fallbackCache); ok {
+		l.shared = fallback
+	}
+	return l
+}
+
+// allow takes a request from key's bucket, reporting whether one was left
+// and otherwise how long until one is
+func (l *rateLimiter) allow(ctx context.Context, key string, now time.Time) (bool, time.Duration) {
+	if l.shared != nil {
+		bucketKey := rateLimitKeyPrefix + l.name + ":" + key
+		if allowed, wait, ok := l.shared.takeToken(ctx, bucketKey, l.perSecond, l.burst); ok {
+			return allowed, wait
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, refillWait(b.tokens, l.perSecond)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// refillWait returns how long a bucket with tokens left takes to refill to
+// one request
+func refillWait(tokens, perSecond float64) time.Duration {
+	return time.Duration((1 - tokens) / perSecond * float64(time.Second))
+}
+
+// tokenBucketScript takes a request from the token bucket in KEYS[1], a
+// hash of the tokens left and when it was last taken from, by Redis' clock
+// so replicas' clocks need not agree. ARGV are the burst and refill rate.
+// It returns 1 if a request was left, 0 otherwise, and the tokens left.
+// Full buckets expire, as they are indistinguishable from new ones.
+var tokenBucketScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local per_second = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * per_second)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / per_second * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// takeToken takes a request from the token bucket key in Redis
+func (c *redisCache) takeToken(ctx context.Context, key string, perSecond, burst float64) (bool, time.Duration, error) {
+	reply, err := tokenBucketScript.Run(ctx, c.client, []string{cacheKeyPrefix + key}, burst, perSecond).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(reply) != 2 {
+		return false, 0, fmt.Errorf("unexpected token bucket reply: %v", reply)
+	}
+	allowed, _ := reply[0].(int64)
+	left, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected token bucket reply: %w", err)
+	}
+	if allowed != 1 {
+		return false, refillWait(tokens, perSecond), nil
+	}
+	return true, 0, nil
+}
+
+// takeToken takes a request from the token bucket key in Redis while Redis
+// is available. It reports false for ok otherwise, and the caller counts
+// the request itself.
+func (c *fallbackCache) takeToken(ctx context.Context, key string, perSecond, burst float64) (allowed bool, wait time.Duration, ok bool) {
+	primary, isRedis := c.primary.(*redisCache)
+	if !isRedis || !c.usePrimary() {
+		return false, 0, false
+	}
+	allowed, wait, err := primary.takeToken(ctx, key, perSecond, burst)
+	if err != nil {
+		c.markDown(err)
+		return false, 0, false
+	}
+	c.markUp()
+	return allowed, wait, true
+}
+
+// sweep forgets addresses whose buckets have refilled, as they are
+// indistinguishable from new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimit returns middleware answering 429 Too Many Requests to addresses
+// over limiter's rate. A nil limiter (rate limiting disabled) lets every
+// request through.
+func (s *ComplianceServer) rateLimit(limiter *rateLimiter) middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if limiter == nil {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			addr := s.remoteIP(r)
+			if ok, wait := limiter.allow(r.Context(), addr, time.Now()); !ok {
+				s.logger.Warn("Rate limit exceeded", "remote_addr", addr, "path", r.URL.Path)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				s.sendError(w, http.StatusTooManyRequests, "Too many requests, try again later")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// remoteIP returns the IP address requests are limited by: the connection's
+// address, or with rate_limit.trust_forwarded_for the last address in
+// X-Forwarded-For, the one the proxy in front of the server saw
+func (s *ComplianceServer) remoteIP(r *http.Request) string {
+	if s.config().RateLimit.TrustForwardedFor {
+		if header := r.Header.Get("X-Forwarded-For"); header != "" {
+			hops := strings.Split(header, ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter tests that each address gets its own bucket that refills
+// over the minute
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := newRateLimiter("test", 3, nil)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.allow(ctx, "10.0.0.1", now); !ok {
+			t.Fatalf("request %d refused, want the burst of 3 allowed", i+1)
+		}
+	}
+	ok, wait := limiter.allow(ctx, "10.0.0.1", now)
+	if ok || wait != 20*time.Second {
+		t.Errorf("fourth request = %v, wait %v; want refused for 20s", ok, wait)
+	}
+	if ok, _ := limiter.allow(ctx, "10.0.0.2", now); !ok {
+		t.Error("another address was refused")
+	}
+	if ok, _ := limiter.allow(ctx, "10.0.0.1", now.Add(20*time.Second)); !ok {
+		t.Error("request after the wait refused")
+	}
+
+	// A minute later both buckets are full again and are forgotten
+	limiter.allow(ctx, "10.0.0.3", now.Add(2*time.Minute))
+	if len(limiter.buckets) != 1 {
+		t.Errorf("%d buckets after sweep, want only the new address", len(limiter.buckets))
+	}
+}
+
+// TestRateLimiterRedisUnavailable tests that a limiter sharing its buckets
+// through Redis keeps limiting in memory while Redis cannot be reached
+func TestRateLimiterRedisUnavailable(t *testing.T) {
+	ctx := context.Background()
+	cache, err := newSharedCache(CacheSettings{Backend: cacheBackendRedis, RedisURL: "redis://127.0.0.1:1/0"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newSharedCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	limiter := newRateLimiter("test", 1, cache)
+	if limiter.shared == nil {
+		t.Fatal("limiter does not share its buckets through the Redis cache")
+	}
+	now := time.Now()
+	if ok, _ := limiter.allow(ctx, "10.0.0.1", now); !ok {
+		t.Error("first request refused")
+	}
+	if ok, wait := limiter.allow(ctx, "10.0.0.1", now); ok || wait != time.Minute {
+		t.Errorf("second request = %v, wait %v; want refused for 1m", ok, wait)
+	}
+	if newRateLimiter("test", 1, newMemoryCache()).shared != nil {
+		t.Error("limiter shares its buckets through an in-memory cache")
+	}
+}
+
+// TestRateLimitMiddleware tests that login requests over the limit are
+// answered 429 with Retry-After before the handler runs
+func TestRateLimitMiddleware(t *testing.T) {
+	config := &ServerConfig{}
+	s := &ComplianceServer{
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		mux:          http.NewServeMux(),
+		loginLimiter: newRateLimiter("login", 2, nil),
+	}
+	s.cfg.Store(config)
+	s.registerRoutes()
+
+	login := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader("not json"))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		s.routeHandler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := login("192.0.2.10:50000"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("request %d status = %d, want 400 from the handler", i+1, rec.Code)
+		}
+	}
+	rec := login("192.0.2.10:50001")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("status = %d, Retry-After = %q; want 429 and 30", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := login("192.0.2.11:50000"); rec.Code != http.StatusBadRequest {
+		t.Errorf("other address status = %d, want 400", rec.Code)
+	}
+}
+
+// TestRemoteIP tests which address requests are limited by
+func TestRemoteIP(t *testing.T) {
+	s := newTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/compliance/submit", nil)
+	req.RemoteAddr = "10.1.2.3:41000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9")
+
+	if got := s.remoteIP(req); got != "10.1.2.3" {
+		t.Errorf("remoteIP() = %q, want the connection address 10.1.2.3", got)
+	}
+	s.config().RateLimit.TrustForwardedFor = true
+	if got := s.remoteIP(req); got != "203.0.113.9" {
+		t.Errorf("remoteIP() behind a proxy = %q, want the address the proxy saw 203.0.113.9", got)
+	}
+}
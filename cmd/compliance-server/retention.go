@@ -0,0 +1,527 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/objectstore"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// retentionBatchSize bounds how many submissions are deleted in one
+// transaction and written to one archive file
+const retentionBatchSize = 500
+
+// maxPolicyRetentionDays bounds the retention_days of a policy, at 100 years
+const maxPolicyRetentionDays = 36500
+
+// retentionLockID is the PostgreSQL advisory lock key held while a server
+// expires submissions, so replicas do not archive the same rows
+const retentionLockID = 0x726574656e
+
+// startRetention expires submissions past the retention limits at startup
+// and then every retention.interval
+func (s *ComplianceServer) startRetention() {
+	retention := s.config().Retention
+	if !retention.enabled() {
+		return
+	}
+
+	s.logger.Info("Starting submission retention",
+		"max_age", retention.MaxAge,
+		"max_per_client", retention.MaxPerClient,
+		"per_policy", retention.PerPolicy,
+		"action", retention.Action,
+		"archive_storage", retention.archiveStorage().Type,
+		"archive_max_age", retention.ArchiveMaxAge,
+		"interval", retention.Interval,
+	)
+
+	go func() {
+		s.applyRetention(time.Now())
+
+		ticker := time.NewTicker(retention.Interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.applyRetention(time.Now())
+		}
+	}()
+}
+
+// newArchiveStore returns the store expired submissions are archived to.
+// Archives in a directory can be read by their owner only.
+func newArchiveStore(retention RetentionSettings) (objectstore.Store, error) {
+	storage := retention.archiveStorage()
+	if storage.Type == objectstore.TypeDir {
+		return objectstore.NewPrivateDir(storage.Dir)
+	}
+	return objectstore.New(storage)
+}
+
+// applyRetention expires submissions in batches until none are left past
+// the limits, then deletes archives past retention.archive_max_age
+func (s *ComplianceServer) applyRetention(now time.Time) {
+	retention := s.config().Retention
+	var before time.Time
+	if retention.MaxAge > 0 {
+		before = now.Add(-retention.MaxAge)
+	}
+	ctx := context.Background()
+
+	policies, err := s.db.ListPolicies()
+	if err != nil {
+		s.logger.Error("Failed to load policy retention", "error", err)
+		return
+	}
+	cutoffs := policyRetentionCutoffs(policies, now)
+
+	total := 0
+	for batch := 1; ; batch++ {
+		var archive func([]*api.ComplianceSubmission) (*api.SubmissionArchive, error)
+		var written string
+		if retention.Action == retentionActionArchive {
+			archive = func(submissions []*api.ComplianceSubmission) (*api.SubmissionArchive, error) {
+				a, err := writeSubmissionArchive(ctx, s.archives, now, batch, submissions)
+				if a != nil {
+					written = a.Path
+				}
+				return a, err
+			}
+		}
+
+		count, err := s.db.ExpireSubmissions(before, retention.MaxPerClient, cutoffs, retentionBatchSize, archive)
+		if err != nil {
+			// The submissions are still in the database; an archive of
+			// them would be a duplicate on the next run
+			if written != "" {
+				s.archives.Delete(ctx, path.Base(written))
+			}
+			s.logger.Error("Failed to expire submissions", "error", err)
+			break
+		}
+		if written != "" {
+			s.logger.Info("Archived expired submissions", "path", written, "count", count)
+		}
+		total += count
+		if count < retentionBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Info("Expired submissions", "count", total, "action", retention.Action)
+		s.removeOrphanAttachments()
+	}
+
+	if retention.Action == retentionActionArchive && retention.ArchiveMaxAge > 0 {
+		s.expireSubmissionArchives(ctx, now.Add(-retention.ArchiveMaxAge))
+	}
+}
+
+// policyRetentionCutoffs returns the time before which submissions of each
+// report type expire, for the report types of policies that set
+// retention_days. Policies sharing a report type keep its submissions for
+// the longest of their retentions; policies whose data names no report type
+// are ignored.
+func policyRetentionCutoffs(policies []Policy, now time.Time) map[string]time.Time {
+	cutoffs := make(map[string]time.Time)
+	for _, policy := range policies {
+		if policy.RetentionDays <= 0 {
+			continue
+		}
+		var data struct {
+			Metadata struct {
+				ReportTitle string `json:"report_title"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal([]byte(policy.PolicyData), &data); err != nil || data.Metadata.ReportTitle == "" {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -policy.RetentionDays)
+		if current, ok := cutoffs[data.Metadata.ReportTitle]; !ok || cutoff.Before(current) {
+			cutoffs[data.Metadata.ReportTitle] = cutoff
+		}
+	}
+	return cutoffs
+}
+
+// checkPolicyRetention checks the retention_days of a policy
+func checkPolicyRetention(days int) error {
+	if days < 0 || days > maxPolicyRetentionDays {
+		return fmt.Errorf("retention_days must be between 0 and %d", maxPolicyRetentionDays)
+	}
+	return nil
+}
+
+// expireSubmissionArchives deletes the archives created before cutoff and
+// their records. An archive whose file cannot be deleted keeps its record,
+// so it is tried again on the next run.
+func (s *ComplianceServer) expireSubmissionArchives(ctx context.Context, cutoff time.Time) {
+	archives, err := s.db.ListSubmissionArchivesBefore(cutoff)
+	if err != nil {
+		s.logger.Error("Failed to list expired archives", "error", err)
+		return
+	}
+
+	var ids []int
+	for _, archive := range archives {
+		// Archives are named by their base name in the archive store,
+		// wherever it keeps them
+		if err := s.archives.Delete(ctx, path.Base(archive.Path)); err != nil {
+			s.logger.Error("Failed to delete expired archive", "path", archive.Path, "error", err)
+			continue
+		}
+		ids = append(ids, archive.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.db.DeleteSubmissionArchives(ids); err != nil {
+		s.logger.Error("Failed to delete expired archive records", "error", err)
+		return
+	}
+	s.logger.Info("Deleted expired archives", "count", len(ids), "before", cutoff.UTC().Format(time.RFC3339))
+}
+
+// writeSubmissionArchive writes submissions as a gzip-compressed JSON array
+// to a new object in store. Batch numbers the archives written in one run.
+func writeSubmissionArchive(ctx context.Context, store objectstore.Store, now time.Time, batch int, submissions []*api.ComplianceSubmission) (*api.SubmissionArchive, error) {
+	key := fmt.Sprintf("submissions-%s-%03d.json.gz", now.UTC().Format("20060102T150405Z"), batch)
+	existing, err := store.Get(ctx, key)
+	if err == nil {
+		existing.Close()
+		return nil, fmt.Errorf("archive %s already exists", store.Location(key))
+	}
+	if !errors.Is(err, objectstore.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check archive %s: %w", key, err)
+	}
+
+	// Archives are compressed in memory, as object stores need the size of
+	// an upload up front; a batch is at most retentionBatchSize submissions
+	var buf bytes.Buffer
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(&buf, hash))
+	if err := json.NewEncoder(gz).Encode(submissions); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	size := int64(buf.Len())
+	if err := store.Put(ctx, key, &buf, size, "application/gzip"); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	archive := &api.SubmissionArchive{
+		CreatedAt: now,
+		Path:      store.Location(key),
+		Count:     len(submissions),
+		SizeBytes: size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+	}
+	for _, submission := range submissions {
+		if archive.Oldest.IsZero() || submission.Timestamp.Before(archive.Oldest) {
+			archive.Oldest = submission.Timestamp
+		}
+		if submission.Timestamp.After(archive.Newest) {
+			archive.Newest = submission.Timestamp
+		}
+	}
+	return archive, nil
+}
+
+// handleListSubmissionArchives lists the files expired submissions were
+// archived to
+func (s *ComplianceServer) handleListSubmissionArchives(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			s.sendError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	archives, err := s.requestDB(r).ListSubmissionArchives(limit)
+	if err != nil {
+		s.logger.Error("Failed to list submission archives", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to list archives")
+		return
+	}
+
+	s.respond(w, r, api.SubmissionArchiveListResponse{Archives: archives, Count: len(archives)}, nil)
+}
+
+// ExpireSubmissions deletes up to limit of the oldest submissions that are
+// older than before (unless zero) or beyond the newest maxPerClient of their
+// client (unless 0), returning how many were deleted. Submissions of a
+// report type in cutoffs expire only once older than its cutoff, and are not
+// counted against maxPerClient. With archive set, the
+// deleted submissions are passed to it and the file it wrote is recorded
+// before the deletion commits. Nothing is deleted while another server holds
+// the retention lock.
+func (d *Database) ExpireSubmissions(before time.Time, maxPerClient int, cutoffs map[string]time.Time, limit int, archive func([]*api.ComplianceSubmission) (*api.SubmissionArchive, error)) (int, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(safesql.New(`SELECT pg_try_advisory_xact_lock($1)`, retentionLockID)).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("failed to lock retention: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	var cutoff interface{}
+	if !before.IsZero() {
+		cutoff = before.UTC().Format(time.RFC3339)
+	}
+	reportTypes := make([]string, 0, len(cutoffs))
+	reportCutoffs := make([]string, 0, len(cutoffs))
+	for reportType, reportCutoff := range cutoffs {
+		reportTypes = append(reportTypes, reportType)
+		reportCutoffs = append(reportCutoffs, reportCutoff.UTC().Format(time.RFC3339))
+	}
+	const query = `
+		DELETE FROM submissions
+		WHERE (submission_id, timestamp) IN (
+			SELECT submission_id, timestamp FROM (
+				SELECT s.submission_id, s.timestamp, o.cutoff,
+				       ROW_NUMBER() OVER (PARTITION BY s.client_id, o.cutoff IS NULL ORDER BY s.timestamp DESC, s.id DESC) AS position
+				FROM submissions s
+				LEFT JOIN unnest($4::text[], $5::timestamp[]) AS o(report_type, cutoff) ON o.report_type = s.report_type
+			) ranked
+			WHERE CASE WHEN cutoff IS NULL THEN timestamp < $1::timestamp OR ($2 > 0 AND position > $2)
+			           ELSE timestamp < cutoff END
+			ORDER BY timestamp
+			LIMIT $3
+		)
+		RETURNING submission_id, client_id, hostname, timestamp, report_type, report_version,
+		          compliance_data, evidence, system_info, during_maintenance, summary_only, metadata
+	`
+
+	rows, err := tx.Query(safesql.New(query, cutoff, maxPerClient, limit, pq.Array(reportTypes), pq.Array(reportCutoffs)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired submissions: %w", err)
+	}
+	defer rows.Close()
+
+	var submissions []*api.ComplianceSubmission
+	var ids []string
+	var clients []string
+	seenClients := make(map[string]bool)
+	for rows.Next() {
+		var submission api.ComplianceSubmission
+		var complianceData, evidence, systemInfo, timestampStr string
+		var metadata sql.NullString
+		err := rows.Scan(&submission.SubmissionID, &submission.ClientID, &submission.Hostname, &timestampStr,
+			&submission.ReportType, &submission.ReportVersion, &complianceData, &evidence, &systemInfo,
+			&submission.DuringMaintenance, &submission.SummaryOnly, &metadata)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan expired submission: %w", err)
+		}
+		if submission.Timestamp, err = parseStoredTime(timestampStr); err != nil {
+			return 0, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		if archive != nil {
+			if err := json.Unmarshal([]byte(complianceData), &submission.Compliance); err != nil {
+				return 0, fmt.Errorf("failed to unmarshal compliance data of %s: %w", submission.SubmissionID, err)
+			}
+			if err := json.Unmarshal([]byte(evidence), &submission.Evidence); err != nil {
+				return 0, fmt.Errorf("failed to unmarshal evidence of %s: %w", submission.SubmissionID, err)
+			}
+			if err := json.Unmarshal([]byte(systemInfo), &submission.SystemInfo); err != nil {
+				return 0, fmt.Errorf("failed to unmarshal system info of %s: %w", submission.SubmissionID, err)
+			}
+			if submission.Metadata, err = decodeMetadata(metadata); err != nil {
+				return 0, fmt.Errorf("submission %s: %w", submission.SubmissionID, err)
+			}
+		}
+		submissions = append(submissions, &submission)
+		ids = append(ids, submission.SubmissionID)
+		if !seenClients[submission.ClientID] {
+			seenClients[submission.ClientID] = true
+			clients = append(clients, submission.ClientID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to delete expired submissions: %w", err)
+	}
+	rows.Close()
+	if len(submissions) == 0 {
+		return 0, nil
+	}
+
+	// Telemetry has no foreign key to the partitioned submissions table
+	if _, err := tx.Exec(safesql.New(`DELETE FROM agent_telemetry WHERE submission_id = ANY($1)`, pq.Array(ids))); err != nil {
+		return 0, fmt.Errorf("failed to delete expired telemetry: %w", err)
+	}
+	if err := d.rebuildRollups(tx, clients); err != nil {
+		return 0, err
+	}
+
+	if archive != nil {
+		a, err := archive(submissions)
+		if err != nil {
+			return 0, err
+		}
+		if err := d.recordSubmissionArchive(tx, a, submissions); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit expired submissions: %w", err)
+	}
+	return len(submissions), nil
+}
+
+// recordSubmissionArchive records an archive file and the submissions it holds
+func (d *Database) recordSubmissionArchive(tx *safesql.Tx, archive *api.SubmissionArchive, submissions []*api.ComplianceSubmission) error {
+	err := tx.QueryRow(safesql.New(`
+		INSERT INTO submission_archives (path, submission_count, oldest, newest, size_bytes, sha256, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, archive.Path, archive.Count, archive.Oldest.UTC().Format(time.RFC3339), archive.Newest.UTC().Format(time.RFC3339), archive.SizeBytes, archive.SHA256)).Scan(&archive.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record archive: %w", err)
+	}
+
+	ids := make([]string, len(submissions))
+	clients := make([]string, len(submissions))
+	timestamps := make([]string, len(submissions))
+	for i, submission := range submissions {
+		ids[i] = submission.SubmissionID
+		clients[i] = submission.ClientID
+		timestamps[i] = submission.Timestamp.UTC().Format(time.RFC3339)
+	}
+
+	// A submission ID sent again after its first copy was archived points
+	// at its latest archive
+	_, err = tx.Exec(safesql.New(`
+		INSERT INTO archived_submissions (submission_id, archive_id, client_id, timestamp)
+		SELECT submission_id, $1, client_id, timestamp
+		FROM unnest($2::text[], $3::text[], $4::timestamp[]) AS archived(submission_id, client_id, timestamp)
+		ON CONFLICT (submission_id) DO UPDATE
+		SET archive_id = EXCLUDED.archive_id, client_id = EXCLUDED.client_id, timestamp = EXCLUDED.timestamp
+	`, archive.ID, pq.Array(ids), pq.Array(clients), pq.Array(timestamps)))
+	if err != nil {
+		return fmt.Errorf("failed to record archived submissions: %w", err)
+	}
+	return nil
+}
+
+// ListSubmissionArchives returns the most recent archive files, newest first
+func (d *Database) ListSubmissionArchives(limit int) ([]api.SubmissionArchive, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT id, created_at, path, submission_count, oldest, newest, size_bytes, sha256
+		FROM submission_archives
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query submission archives: %w", err)
+	}
+	defer rows.Close()
+
+	archives := []api.SubmissionArchive{}
+	for rows.Next() {
+		var archive api.SubmissionArchive
+		var createdAt, oldest, newest string
+		if err := rows.Scan(&archive.ID, &createdAt, &archive.Path, &archive.Count, &oldest, &newest,
+			&archive.SizeBytes, &archive.SHA256); err != nil {
+			return nil, fmt.Errorf("failed to scan submission archive: %w", err)
+		}
+		if archive.CreatedAt, err = parseStoredTime(createdAt); err != nil {
+			return nil, fmt.Errorf("failed to parse archive time: %w", err)
+		}
+		if archive.Oldest, err = parseStoredTime(oldest); err != nil {
+			return nil, fmt.Errorf("failed to parse archive time: %w", err)
+		}
+		if archive.Newest, err = parseStoredTime(newest); err != nil {
+			return nil, fmt.Errorf("failed to parse archive time: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	return archives, rows.Err()
+}
+
+// ListSubmissionArchivesBefore returns the archives created before cutoff,
+// oldest first
+func (d *Database) ListSubmissionArchivesBefore(cutoff time.Time) ([]api.SubmissionArchive, error) {
+	rows, err := d.db.Query(safesql.New(`
+		SELECT id, path FROM submission_archives
+		WHERE created_at < $1::timestamp
+		ORDER BY id
+	`, cutoff.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired archives: %w", err)
+	}
+	defer rows.Close()
+
+	var archives []api.SubmissionArchive
+	for rows.Next() {
+		var archive api.SubmissionArchive
+		if err := rows.Scan(&archive.ID, &archive.Path); err != nil {
+			return nil, fmt.Errorf("failed to scan submission archive: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	return archives, rows.Err()
+}
+
+// DeleteSubmissionArchives deletes the records of archives, and of the
+// submissions they hold
+func (d *Database) DeleteSubmissionArchives(ids []int) error {
+	if _, err := d.db.Exec(safesql.New(`DELETE FROM submission_archives WHERE id = ANY($1)`, pq.Array(ids))); err != nil {
+		return fmt.Errorf("failed to delete archive records: %w", err)
+	}
+	return nil
+}
+
+// FindArchivedSubmission returns the archive a deleted submission was
+// written to, or nil when it was never archived
+func (d *Database) FindArchivedSubmission(submissionID string) (*api.SubmissionArchive, error) {
+	scope := d.scopeCondition(safesql.New("s.client_id"))
+	query := safesql.New(`
+		SELECT a.id, a.created_at, a.path, a.submission_count, a.size_bytes, a.sha256
+		FROM archived_submissions s
+		JOIN submission_archives a ON a.id = s.archive_id
+		WHERE s.submission_id = $1 AND `, submissionID).
+		AppendQuery(scope)
+
+	var archive api.SubmissionArchive
+	var createdAt string
+	err := d.db.QueryRow(query).Scan(
+		&archive.ID, &createdAt, &archive.Path, &archive.Count, &archive.SizeBytes, &archive.SHA256)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archived submission: %w", err)
+	}
+	if archive.CreatedAt, err = parseStoredTime(createdAt); err != nil {
+		return nil, fmt.Errorf("failed to parse archive time: %w", err)
+	}
+	return &archive, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestWriteSubmissionArchive tests that archives hold the submissions as
+// gzip-compressed JSON and describe the file they were written to
+func TestWriteSubmissionArchive(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newArchiveStore(RetentionSettings{ArchiveDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	oldest := now.AddDate(-1, 0, -2)
+	submissions := []*api.ComplianceSubmission{
+		{SubmissionID: "b", ClientID: "client-1", Timestamp: oldest.Add(time.Hour), ReportType: "NIST_800_171"},
+		{SubmissionID: "a", ClientID: "client-2", Timestamp: oldest, ReportType: "NIST_800_171",
+			Compliance: api.ComplianceData{OverallStatus: "compliant", TotalChecks: 3, PassedChecks: 3}},
+	}
+
+	archive, err := writeSubmissionArchive(ctx, store, now, 2, submissions)
+	if err != nil {
+		t.Fatalf("writeSubmissionArchive() error = %v", err)
+	}
+
+	wantPath := filepath.Join(dir, "submissions-20261015T030000Z-002.json.gz")
+	if archive.Path != wantPath || archive.Count != 2 || !archive.Oldest.Equal(oldest) || !archive.Newest.Equal(oldest.Add(time.Hour)) {
+		t.Errorf("archive = %+v", archive)
+	}
+
+	data, err := os.ReadFile(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if archive.SizeBytes != int64(len(data)) || archive.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("size %d and sha256 %s do not describe the %d byte file", archive.SizeBytes, archive.SHA256, len(data))
+	}
+	if info, _ := os.Stat(wantPath); info.Mode().Perm()&0077 != 0 {
+		t.Errorf("archive mode = %v, want readable by the owner only", info.Mode())
+	}
+
+	f, err := os.Open(wantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzip: %v", err)
+	}
+	var got []api.ComplianceSubmission
+	if err := json.NewDecoder(gz).Decode(&got); err != nil {
+		t.Fatalf("archive is not a JSON array of submissions: %v", err)
+	}
+	if len(got) != 2 || got[1].SubmissionID != "a" || got[1].Compliance.PassedChecks != 3 {
+		t.Errorf("archived submissions = %+v", got)
+	}
+
+	if _, err := writeSubmissionArchive(ctx, store, now, 2, submissions); err == nil {
+		t.Error("second archive with the same name error = nil, want error")
+	}
+}
+
+// TestPolicyRetentionCutoffs tests that policies setting retention_days
+// give their report type its own cutoff, the longest winning
+func TestPolicyRetentionCutoffs(t *testing.T) {
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	policy := func(title string, days int) Policy {
+		return Policy{
+			PolicyData:    `{"version": "1.0", "metadata": {"report_title": "` + title + `"}, "queries": []}`,
+			RetentionDays: days,
+		}
+	}
+
+	cutoffs := policyRetentionCutoffs([]Policy{
+		policy("FIPS 140-2", 7*365),
+		policy("FIPS 140-2", 30),
+		policy("Drift Scan", 30),
+		policy("NIST 800-171", 0),
+		{PolicyData: `not json`, RetentionDays: 90},
+		{PolicyData: `{"version": "1.0"}`, RetentionDays: 90},
+	}, now)
+
+	want := map[string]time.Time{
+		"FIPS 140-2": now.AddDate(0, 0, -7*365),
+		"Drift Scan": now.AddDate(0, 0, -30),
+	}
+	if len(cutoffs) != len(want) {
+		t.Fatalf("policyRetentionCutoffs() = %v, want %v", cutoffs, want)
+	}
+	for reportType, cutoff := range want {
+		if !cutoffs[reportType].Equal(cutoff) {
+			t.Errorf("cutoff of %s = %v, want %v", reportType, cutoffs[reportType], cutoff)
+		}
+	}
+}
+
+// TestPolicyRetentionValidation tests that policies are saved only with a
+// retention_days in range
+func TestPolicyRetentionValidation(t *testing.T) {
+	handler := newTestServer().routeHandler()
+	data, _ := json.Marshal(editorPolicy)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		days   int
+	}{
+		{"create negative", "POST", "/api/v1/policies", -1},
+		{"create past 100 years", "POST", "/api/v1/policies", maxPolicyRetentionDays + 1},
+		{"update negative", "PUT", "/api/v1/policies/baseline", -30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"policy_id": "baseline", "name": "Baseline", "policy_data": %s, "retention_days": %d}`, data, tt.days)
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "retention_days") {
+				t.Errorf("status = %d, want 400 naming retention_days: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if err := checkPolicyRetention(0); err != nil {
+		t.Errorf("checkPolicyRetention(0) = %v, want nil", err)
+	}
+}
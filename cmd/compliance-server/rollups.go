@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// The dashboard summary is served from rollups (migration 0014) rather than
+// scanning submissions on every request: client_rollups holds each client's
+// latest submission and report_type_rollups the counts and score sums per
+// client and report type. Both are keyed by client so operator scopes still
+// apply. Storing a submission adds to them; deleting submissions rebuilds
+// the affected clients' rows from what remains. Every rollup is rebuilt
+// daily as well, which corrects them after partitions are dropped by hand.
+
+// rollupRebuildInterval is how often every rollup is rebuilt
+const rollupRebuildInterval = 24 * time.Hour
+
+// rollupLockID is the PostgreSQL advisory lock key held while rollups are
+// rebuilt, so rebuilds do not insert the same rows
+const rollupLockID = 0x726f6c6c7570
+
+// startRollupRebuild rebuilds every rollup daily
+func (s *ComplianceServer) startRollupRebuild() {
+	go func() {
+		ticker := time.NewTicker(rollupRebuildInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := s.db.RebuildRollups(); err != nil {
+				s.logger.Error("Failed to rebuild dashboard rollups", "error", err)
+			}
+		}
+	}()
+}
+
+// RebuildRollups recomputes every client's rollups from stored submissions
+func (d *Database) RebuildRollups() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := d.rebuildRollups(tx, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+	return nil
+}
+
+// addSubmissionRollup adds a stored submission to its client's rollups
+func (d *Database) addSubmissionRollup(tx *safesql.Tx, submission *api.ComplianceSubmission) error {
+	// A submission older than the client's latest (a delayed upload) only
+	// adds to the counts
+	_, err := tx.Exec(safesql.New(`
+		INSERT INTO client_rollups (client_id, submission_id, timestamp, overall_status)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client_id) DO UPDATE
+		SET submission_id = EXCLUDED.submission_id, timestamp = EXCLUDED.timestamp, overall_status = EXCLUDED.overall_status
+		WHERE client_rollups.timestamp <= EXCLUDED.timestamp
+	`, submission.ClientID, submission.SubmissionID, submission.Timestamp.UTC().Format(time.RFC3339), submission.Compliance.OverallStatus))
+	if err != nil {
+		return fmt.Errorf("failed to update client rollup: %w", err)
+	}
+
+	compliant, noncompliant, score, scored := submissionCounts(submission.Compliance)
+	_, err = tx.Exec(safesql.New(`
+		INSERT INTO report_type_rollups (client_id, report_type, submissions, compliant, noncompliant, score_sum, scored)
+		VALUES ($1, $2, 1, $3, $4, $5, $6)
+		ON CONFLICT (client_id, report_type) DO UPDATE
+		SET submissions = report_type_rollups.submissions + 1,
+		    compliant = report_type_rollups.compliant + EXCLUDED.compliant,
+		    noncompliant = report_type_rollups.noncompliant + EXCLUDED.noncompliant,
+		    score_sum = report_type_rollups.score_sum + EXCLUDED.score_sum,
+		    scored = report_type_rollups.scored + EXCLUDED.scored
+	`, submission.ClientID, submission.ReportType, compliant, noncompliant, score, scored))
+	if err != nil {
+		return fmt.Errorf("failed to update report type rollup: %w", err)
+	}
+	return nil
+}
+
+// rebuildRollups recomputes the rollups of clients from their stored
+// submissions, after submissions were deleted or moved. Nil clientIDs
+// rebuilds every client's.
+func (d *Database) rebuildRollups(tx *safesql.Tx, clientIDs []string) error {
+	condition := safesql.New("TRUE")
+	if clientIDs != nil {
+		condition = safesql.New("client_id = ANY($1)", pq.Array(clientIDs))
+	}
+	if _, err := tx.Exec(safesql.New(`SELECT pg_advisory_xact_lock($1)`, rollupLockID)); err != nil {
+		return fmt.Errorf("failed to lock rollups: %w", err)
+	}
+
+	// A submission committed during the rebuild may have added a row
+	// already; the rebuilt row, which counts it, replaces it
+	statements := []struct {
+		query safesql.Query
+		what  string
+	}{
+		{safesql.New(`DELETE FROM client_rollups WHERE `).AppendQuery(condition), "clear client rollups"},
+		{safesql.New(`INSERT INTO client_rollups (client_id, submission_id, timestamp, overall_status)
+			SELECT DISTINCT ON (client_id) client_id, submission_id, timestamp, overall_status
+			FROM submissions
+			WHERE `).AppendQuery(condition).Append(`
+			ORDER BY client_id, timestamp DESC, id DESC
+			ON CONFLICT (client_id) DO UPDATE
+			SET submission_id = EXCLUDED.submission_id, timestamp = EXCLUDED.timestamp, overall_status = EXCLUDED.overall_status`), "rebuild client rollups"},
+		{safesql.New(`DELETE FROM report_type_rollups WHERE `).AppendQuery(condition), "clear report type rollups"},
+		{safesql.New(`INSERT INTO report_type_rollups (client_id, report_type, submissions, compliant, noncompliant, score_sum, scored)
+			SELECT client_id, report_type, COUNT(*),
+			       SUM(CASE WHEN overall_status = 'compliant' THEN 1 ELSE 0 END),
+			       SUM(CASE WHEN overall_status != 'compliant' THEN 1 ELSE 0 END),
+			       COALESCE(SUM(passed_checks * 100.0 / NULLIF(total_checks, 0)), 0),
+			       COUNT(NULLIF(total_checks, 0))
+			FROM submissions
+			WHERE `).AppendQuery(condition).Append(`
+			GROUP BY client_id, report_type
+			ON CONFLICT (client_id, report_type) DO UPDATE
+			SET submissions = EXCLUDED.submissions, compliant = EXCLUDED.compliant, noncompliant = EXCLUDED.noncompliant,
+			    score_sum = EXCLUDED.score_sum, scored = EXCLUDED.scored`), "rebuild report type rollups"},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query); err != nil {
+			return fmt.Errorf("failed to %s: %w", statement.what, err)
+		}
+	}
+	return nil
+}
+
+// clearRollups removes the rollups of every client in the view's scope,
+// after all their submissions were deleted
+func (d *Database) clearRollups(tx *safesql.Tx) error {
+	scope := d.scopeCondition(safesql.New("client_id"))
+	for _, table := range []safesql.Query{safesql.New("client_rollups"), safesql.New("report_type_rollups")} {
+		if _, err := tx.Exec(safesql.New("DELETE FROM ").AppendQuery(table).Append(" WHERE ").AppendQuery(scope)); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// submissionCounts returns what a submission adds to its report type's
+// rollup: whether it is compliant or not, and its score (the percentage of
+// checks passed) unless it ran no checks
+func submissionCounts(c api.ComplianceData) (compliant, noncompliant int, score float64, scored int) {
+	if c.OverallStatus == "compliant" {
+		compliant = 1
+	} else {
+		noncompliant = 1
+	}
+	if c.TotalChecks > 0 {
+		scored = 1
+		score = float64(c.PassedChecks) * 100 / float64(c.TotalChecks)
+	}
+	return compliant, noncompliant, score, scored
+}
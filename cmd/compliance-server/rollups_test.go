@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSubmissionCounts tests what submissions add to report type rollups,
+// matching the aggregates they are rebuilt with
+func TestSubmissionCounts(t *testing.T) {
+	tests := []struct {
+		name                    string
+		data                    api.ComplianceData
+		compliant, noncompliant int
+		score                   float64
+		scored                  int
+	}{
+		{"compliant", api.ComplianceData{OverallStatus: "compliant", TotalChecks: 4, PassedChecks: 4}, 1, 0, 100, 1},
+		{"partial", api.ComplianceData{OverallStatus: "partial", TotalChecks: 8, PassedChecks: 6}, 0, 1, 75, 1},
+		{"no checks", api.ComplianceData{OverallStatus: "non-compliant"}, 0, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compliant, noncompliant, score, scored := submissionCounts(tt.data)
+			if compliant != tt.compliant || noncompliant != tt.noncompliant || score != tt.score || scored != tt.scored {
+				t.Errorf("submissionCounts() = %d, %d, %v, %d; want %d, %d, %v, %d",
+					compliant, noncompliant, score, scored, tt.compliant, tt.noncompliant, tt.score, tt.scored)
+			}
+		})
+	}
+}
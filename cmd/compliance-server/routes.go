@@ -0,0 +1,320 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routableMethods are the methods probed when building Allow headers
+var routableMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// middleware wraps a handler with cross-cutting behaviour (authentication, logging, ...)
+type middleware func(http.HandlerFunc) http.HandlerFunc
+
+// chain wraps h with the given middlewares. The first middleware listed is the
+// outermost one, so it runs first on every request.
+func chain(h http.HandlerFunc, middlewares ...middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// handle registers a handler for a method-qualified pattern such as
+// "GET /api/v1/clients/{client_id}". Path parameters are read in handlers
+// with r.PathValue. The mux answers requests whose path matches but whose
+// method does not with 405 Method Not Allowed. The handler and its
+// middlewares get server.request_timeout to answer.
+func (s *ComplianceServer) handle(pattern string, h http.HandlerFunc, middlewares ...middleware) {
+	s.mux.HandleFunc(pattern, chain(h, append([]middleware{s.timeoutMiddleware(pattern, false)}, middlewares...)...))
+}
+
+// handleLong registers a handler like handle, given
+// server.long_request_timeout to answer. It is for imports and exports.
+func (s *ComplianceServer) handleLong(pattern string, h http.HandlerFunc, middlewares ...middleware) {
+	s.mux.HandleFunc(pattern, chain(h, append([]middleware{s.timeoutMiddleware(pattern, true)}, middlewares...)...))
+}
+
+// registerRoutes sets up HTTP handlers
+func (s *ComplianceServer) registerRoutes() {
+	// Middleware chains
+	apiAuth := []middleware{s.authMiddleware}
+	pageAuth := []middleware{s.requireAuth}
+	// Agent and server-wide endpoints; operators get 403
+	unscopedAuth := []middleware{s.authMiddleware, s.requireUnscoped}
+	// Endpoints open to brute force and floods are rate limited per IP
+	// address before anything else runs
+	loginLimit := []middleware{s.rateLimit(s.loginLimiter)}
+	submitAuth := append([]middleware{s.rateLimit(s.submitLimiter)}, unscopedAuth...)
+	// Administrative changes are recorded in the admin audit trail
+	audited := func(auth []middleware, action string) []middleware {
+		return append(auth[:len(auth):len(auth)], s.auditAdmin(action))
+	}
+
+	// Server information
+	s.handle("GET /{$}", s.handleRoot)
+	s.handle("GET /api/v1/health", s.handleHealth)
+	if s.config().Metrics.Enabled {
+		// Scrapers authenticate with metrics.token, not a user or API key
+		s.handle("GET /metrics", s.handleMetrics)
+	}
+
+	// Compliance submissions
+	s.handle("POST /api/v1/compliance/submit", s.handleSubmit, submitAuth...)
+	s.handle("POST /api/v1/compliance/submit-delta", s.handleSubmitDelta, submitAuth...)
+	s.handle("GET /api/v1/compliance/status/{submission_id}", s.handleStatus, apiAuth...)
+	s.handle("GET /api/v1/submissions", s.handleSearchSubmissions, apiAuth...)
+	s.handle("GET /api/v1/submissions/{submission_id}", s.handleSubmissionDetail, apiAuth...)
+	s.handle("GET /api/v1/metadata/schema", s.handleMetadataSchema, apiAuth...)
+	s.handle("POST /api/v1/submissions/clear-all", s.handleClearAllSubmissions, audited(apiAuth, auditClientClearAll)...)
+	s.handle("GET /api/v1/submissions/{submission_id}/attachments", s.handleListAttachments, apiAuth...)
+	s.handleLong("POST /api/v1/submissions/{submission_id}/attachments", s.handleUploadAttachment, apiAuth...)
+	s.handle("GET /api/v1/submissions/{submission_id}/attachments/{attachment_id}", s.handleDownloadAttachment, apiAuth...)
+	s.handle("DELETE /api/v1/submissions/{submission_id}/attachments/{attachment_id}", s.handleDeleteAttachment, audited(apiAuth, auditAttachmentDelete)...)
+	s.handleLong("POST /api/v1/import/evidence", s.handleImportEvidence, unscopedAuth...)
+	s.handleLong("GET /api/v1/export/anonymized", s.handleAnonymizedExport, audited(apiAuth, auditExportAnonymized)...)
+
+	// Locating and erasing everything recorded about a machine or person
+	s.handleLong("POST /api/v1/data-subjects/locate", s.handleLocateDataSubject, unscopedAuth...)
+	s.handleLong("POST /api/v1/data-subjects/purge", s.handlePurgeDataSubject, audited(unscopedAuth, auditDataSubjectPurge)...)
+
+	// Clients
+	s.handle("GET /api/v1/clients", s.handleListClients, apiAuth...)
+	s.handle("POST /api/v1/clients/register", s.handleRegister, unscopedAuth...)
+	s.handle("POST /api/v1/clients/heartbeat", s.handleHeartbeat, unscopedAuth...)
+	s.handle("GET /api/v1/clients/duplicates", s.handleDuplicateClients, apiAuth...)
+	s.handle("GET /api/v1/clients/{client_id}", s.handleGetClient, apiAuth...)
+	s.handle("GET /api/v1/clients/{client_id}/submissions", s.handleClientSubmissions, apiAuth...)
+	s.handle("GET /api/v1/clients/{client_id}/telemetry", s.handleClientTelemetry, apiAuth...)
+	s.handle("POST /api/v1/clients/clear-history/{client_id}", s.handleClearClientHistory, audited(apiAuth, auditClientClearHistory)...)
+	s.handle("POST /api/v1/clients/merge/{client_id}", s.handleMergeClient, audited(apiAuth, auditClientMerge)...)
+	s.handle("POST /api/v1/clients/tags/{client_id}", s.handleSetClientTags, audited(unscopedAuth, auditClientTags)...)
+	s.handle("GET /api/v1/clients/{client_id}/policies", s.handleGetClientPolicies, apiAuth...)
+	s.handle("PUT /api/v1/clients/{client_id}/policies", s.handleSetClientPolicies, audited(unscopedAuth, auditClientPolicies)...)
+
+	// Client groups (client tags) and what is assigned to them
+	s.handle("GET /api/v1/groups", s.handleListClientGroups, unscopedAuth...)
+	s.handle("PUT /api/v1/groups/{group}/policies", s.handleSetGroupPolicies, audited(unscopedAuth, auditGroupPolicies)...)
+
+	// Alerts
+	s.handle("GET /api/v1/alerts", s.handleListAlerts, apiAuth...)
+	s.handle("POST /api/v1/alerts/{alert_id}/acknowledge", s.handleAcknowledgeAlert, apiAuth...)
+	s.handle("GET /api/v1/alert-rules", s.handleListAlertRules, unscopedAuth...)
+	s.handle("POST /api/v1/alert-rules", s.handleSaveAlertRule, audited(unscopedAuth, auditAlertRuleSave)...)
+	s.handle("DELETE /api/v1/alert-rules/{rule_id}", s.handleDeleteAlertRule, audited(unscopedAuth, auditAlertRuleDelete)...)
+
+	// Wording of notification emails, per organization
+	s.handle("GET /api/v1/notification-templates", s.handleListNotificationTemplates, unscopedAuth...)
+	s.handle("PUT /api/v1/notification-templates/{name}", s.handleSaveNotificationTemplate, audited(unscopedAuth, auditTemplateSave)...)
+	s.handle("DELETE /api/v1/notification-templates/{name}", s.handleDeleteNotificationTemplate, audited(unscopedAuth, auditTemplateDelete)...)
+	s.handle("POST /api/v1/notification-templates/{name}/preview", s.handlePreviewNotificationTemplate, unscopedAuth...)
+
+	// Client commands (delivered with heartbeat responses)
+	s.handle("GET /api/v1/commands", s.handleListCommands, apiAuth...)
+	s.handle("POST /api/v1/commands", s.handleCreateCommand, apiAuth...)
+	s.handle("GET /api/v1/commands/signing-key", s.handleCommandSigningKey, apiAuth...)
+	s.handle("POST /api/v1/commands/{command_id}/approve", s.handleApproveCommand, apiAuth...)
+	s.handle("POST /api/v1/commands/{command_id}/reject", s.handleRejectCommand, apiAuth...)
+	s.handle("POST /api/v1/commands/{command_id}/result", s.handleCommandResult, unscopedAuth...)
+	s.handle("GET /api/v1/commands/{command_id}/audit", s.handleCommandAudit, apiAuth...)
+
+	// Analytics
+	s.handle("GET /api/v1/analytics/flaky-checks", s.handleFlakyChecks, apiAuth...)
+	s.handle("GET /api/v1/analytics/check-performance", s.handleCheckPerformance, apiAuth...)
+	s.handle("GET /api/v1/analytics/policy-violations", s.handlePolicyViolations, apiAuth...)
+	s.handle("GET /api/v1/analytics/control-families", s.handleControlFamilies, apiAuth...)
+	s.handle("GET /api/v1/checks/{query_name}/failures", s.handleCheckFailures, apiAuth...)
+	s.handle("GET /api/v1/checks/{query_name}/values", s.handleCheckValues, apiAuth...)
+
+	// Maintenance windows
+	s.handle("GET /api/v1/maintenance-windows", s.handleListMaintenanceWindows, apiAuth...)
+	s.handle("POST /api/v1/maintenance-windows", s.handleCreateMaintenanceWindow, audited(unscopedAuth, auditWindowCreate)...)
+	s.handle("GET /api/v1/maintenance-windows/{window_id}", s.handleGetMaintenanceWindow, apiAuth...)
+	s.handle("PUT /api/v1/maintenance-windows/{window_id}", s.handleUpdateMaintenanceWindow, audited(unscopedAuth, auditWindowUpdate)...)
+	s.handle("DELETE /api/v1/maintenance-windows/{window_id}", s.handleDeleteMaintenanceWindow, audited(unscopedAuth, auditWindowDelete)...)
+
+	// Authentication endpoints
+	s.handle("GET /login", s.handleLoginPage)
+	s.handle("POST /api/v1/auth/login", s.handleLogin, loginLimit...)
+	s.handle("GET /accept-invite", s.handleAcceptInvitePage)
+	s.handle("POST /api/v1/users/accept-invite", s.handleAcceptInvite, loginLimit...)
+	s.handle("GET /reset-password", s.handleResetPasswordPage)
+	s.handle("POST /api/v1/password-reset/request", s.handleRequestPasswordReset, loginLimit...)
+	s.handle("POST /api/v1/password-reset/validate", s.handleValidatePasswordReset, loginLimit...)
+	s.handle("POST /api/v1/password-reset/confirm", s.handleConfirmPasswordReset, loginLimit...)
+	s.handle("POST /api/v1/auth/logout", s.handleLogout)
+	s.handle("GET /api/v1/auth/session", s.handleGetSession)
+
+	// Config endpoints (public for login message)
+	s.handle("GET /api/v1/config/login-message", s.handleGetLoginMessage)
+	s.handle("POST /api/v1/config/login-message/update", s.handleUpdateLoginMessage, audited(unscopedAuth, auditLoginMessage)...)
+
+	// Dashboard (if enabled)
+	if s.config().Dashboard.Enabled {
+		s.handle("GET "+s.config().Dashboard.Path, s.handleDashboard, pageAuth...)
+		s.handle("GET /clients", s.handleClientsPage, pageAuth...)
+		s.handle("GET /settings", s.handleSettings, pageAuth...)
+		s.handle("GET /policies", s.handlePoliciesPage, pageAuth...)
+		s.handle("GET /policies/{policy_id}/edit", s.handlePolicyEditorPage, pageAuth...)
+		s.handle("GET /about", s.handleAboutPage, pageAuth...)
+		s.handle("GET /client-detail", s.handleClientDetailPage, pageAuth...)
+		s.handle("GET /submission-detail", s.handleSubmissionDetailPage, pageAuth...)
+		s.handle("GET /api/v1/dashboard/summary", s.handleDashboardSummary, pageAuth...)
+	}
+
+	// Settings API endpoints
+	s.handle("GET /api/v1/settings/config", s.handleGetConfig, unscopedAuth...)
+	s.handle("POST /api/v1/settings/config/update", s.handleUpdateConfig, audited(unscopedAuth, auditSettingsUpdate)...)
+	s.handle("GET /api/v1/settings/maintenance-mode", s.handleGetMaintenanceMode, apiAuth...)
+	s.handle("PUT /api/v1/settings/maintenance-mode", s.handleSetMaintenanceMode, audited(unscopedAuth, auditMaintenanceMode)...)
+
+	// User management API endpoints
+	s.handle("GET /api/v1/users", s.handleUsers, unscopedAuth...)
+	s.handle("GET /api/v1/users/{username}/usage", s.handleUserUsage, unscopedAuth...)
+	s.handle("POST /api/v1/users/create", s.handleCreateUser, audited(unscopedAuth, auditUserCreate)...)
+	s.handleLong("POST /api/v1/users/import", s.handleImportUsers, audited(unscopedAuth, auditUserImport)...)
+	s.handle("POST /api/v1/users/delete", s.handleDeleteUser, audited(unscopedAuth, auditUserDelete)...)
+	s.handle("POST /api/v1/users/change-password", s.handleChangePassword, audited(unscopedAuth, auditUserPassword)...)
+	s.handle("POST /api/v1/users/scope", s.handleSetUserScope, audited(unscopedAuth, auditUserScope)...)
+	s.handle("POST /api/v1/users/team", s.handleSetUserTeam, audited(unscopedAuth, auditUserTeam)...)
+	s.handle("POST /api/v1/users/profile", s.handleSetUserProfile, audited(unscopedAuth, auditUserProfile)...)
+
+	// Admin audit trail
+	s.handle("GET /api/v1/audit", s.handleListAdminAudit, unscopedAuth...)
+
+	// Files expired submissions were archived to
+	s.handle("GET /api/v1/archives", s.handleListSubmissionArchives, unscopedAuth...)
+
+	// Display preferences of the logged-in user
+	s.handle("GET /api/v1/preferences", s.handleGetPreferences, apiAuth...)
+	s.handle("PUT /api/v1/preferences", s.handleSetPreferences, apiAuth...)
+
+	// Profile of the logged-in user
+	s.handle("GET /api/v1/profile", s.handleGetProfile, apiAuth...)
+	s.handle("PUT /api/v1/profile", s.handleUpdateProfile, audited(apiAuth, auditUserProfile)...)
+
+	// API Key management endpoints (database-backed)
+	s.handle("GET /api/v1/apikeys", s.handleListAPIKeys, unscopedAuth...)
+	s.handle("GET /api/v1/apikeys/{id}/usage", s.handleAPIKeyUsage, unscopedAuth...)
+	s.handle("POST /api/v1/apikeys/generate", s.handleGenerateAPIKey, audited(unscopedAuth, auditAPIKeyGenerate)...)
+	s.handle("POST /api/v1/apikeys/delete", s.handleDeleteAPIKeyDB, audited(unscopedAuth, auditAPIKeyRevoke)...)
+	s.handle("POST /api/v1/apikeys/toggle", s.handleToggleAPIKey, audited(unscopedAuth, auditAPIKeyToggle)...)
+
+	// Policy API endpoints
+	s.handle("GET /api/v1/policies", s.handleListPolicies, apiAuth...)
+	s.handle("POST /api/v1/policies", s.handleCreatePolicy, audited(unscopedAuth, auditPolicyCreate)...)
+	s.handleLong("POST /api/v1/policies/import", s.handleImportPolicies, audited(unscopedAuth, auditPolicyImport)...)
+	s.handleLong("POST /api/v1/policies/import-url", s.handleImportPolicyPack, audited(unscopedAuth, auditPolicyPackImport)...)
+	s.handleLong("GET /api/v1/policies/export-pack", s.handleExportPolicyPack, apiAuth...)
+	s.handle("POST /api/v1/policies/simulate", s.handleSimulatePolicy, apiAuth...)
+	s.handle("GET /api/v1/policies/{policy_id}", s.handleGetPolicy, apiAuth...)
+	s.handle("GET /api/v1/policies/{policy_id}/download", s.handleDownloadPolicy, apiAuth...)
+	s.handle("PUT /api/v1/policies/{policy_id}", s.handleUpdatePolicy, audited(unscopedAuth, auditPolicyUpdate)...)
+	s.handle("DELETE /api/v1/policies/{policy_id}", s.handleDeletePolicy, audited(unscopedAuth, auditPolicyDelete)...)
+	s.handle("PUT /api/v1/policies/{policy_id}/owner", s.handleSetPolicyOwner, audited(unscopedAuth, auditPolicyOwner)...)
+	s.handle("POST /api/v1/policies/{policy_id}/validate", s.handleValidatePolicy, apiAuth...)
+	s.handle("GET /api/v1/policies/{policy_id}/revisions", s.handleListPolicyRevisions, apiAuth...)
+	s.handle("GET /api/v1/policies/{policy_id}/revisions/{revision}", s.handleGetPolicyRevision, apiAuth...)
+	s.handle("GET /api/v1/policies/{policy_id}/rollout", s.handleGetPolicyRollout, apiAuth...)
+	s.handle("PUT /api/v1/policies/{policy_id}/rollout", s.handlePutPolicyRollout, audited(unscopedAuth, auditPolicyRollout)...)
+	s.handle("DELETE /api/v1/policies/{policy_id}/rollout", s.handleDeletePolicyRollout, audited(unscopedAuth, auditPolicyRolloutEnd)...)
+	s.handle("POST /api/v1/policies/{policy_id}/rollout/promote", s.handlePromotePolicyRollout, audited(unscopedAuth, auditPolicyPromote)...)
+
+	// JWT authentication endpoints (if enabled)
+	s.registerJWTRoutes()
+
+	// Static files (for JWT auth client and other assets)
+	s.mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(staticAssets)))
+}
+
+// routeHandler returns the mux wrapped with the behaviour every route shares:
+//   - a path with a trailing slash is redirected to its canonical form when
+//     only the slash-less path is registered
+//   - OPTIONS is answered for every route with the methods it supports
+//   - a known path requested with the wrong method gets a problem+json 405
+//     with an Allow header instead of the mux's plain-text response
+//   - an unknown path gets a problem+json 404 under /api/ and an HTML error
+//     page elsewhere
+//
+// HEAD is served by the GET handlers; net/http discards the body.
+func (s *ComplianceServer) routeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := s.mux.Handler(r); pattern != "" {
+			s.mux.ServeHTTP(w, r)
+			return
+		}
+
+		if canonical, ok := s.canonicalPath(r); ok {
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+
+		allowed := s.allowedMethods(r)
+		if len(allowed) == 0 {
+			s.sendNotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions {
+			s.handleOptions(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+}
+
+// handleOptions answers an OPTIONS request with the methods the path supports
+func (s *ComplianceServer) handleOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(s.allowedMethods(r), ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowedMethods returns the methods registered for the request path,
+// including OPTIONS when any method matches.
+func (s *ComplianceServer) allowedMethods(r *http.Request) []string {
+	var allowed []string
+	for _, method := range routableMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := s.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) > 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+// canonicalPath reports the slash-less form of a request path when the path
+// has a trailing slash and only the slash-less form is routable.
+func (s *ComplianceServer) canonicalPath(r *http.Request) (string, bool) {
+	path := r.URL.Path
+	if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+		return "", false
+	}
+
+	probe := r.Clone(r.Context())
+	probe.URL.Path = strings.TrimRight(path, "/")
+	if probe.URL.Path == "" {
+		return "", false
+	}
+
+	if len(s.allowedMethods(probe)) == 0 {
+		return "", false
+	}
+	return probe.URL.Path, true
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestServer creates a server with routes registered but no database.
+// Only requests that never reach a handler can be served by it.
+func newTestServer() *ComplianceServer {
+	config := &ServerConfig{}
+	config.Dashboard.Enabled = true
+	config.Dashboard.Path = "/dashboard"
+
+	s := &ComplianceServer{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		mux:    http.NewServeMux(),
+	}
+	s.cfg.Store(config)
+	s.registerRoutes()
+	return s
+}
+
+// TestChainOrder tests that middlewares run in the order they are listed
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next(w, r)
+			}
+		}
+	}
+
+	h := chain(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}, mark("first"), mark("second"))
+
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := []string{"first", "second", "handler"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+// TestRouteHandler tests method handling, Allow headers and trailing-slash redirects
+func TestRouteHandler(t *testing.T) {
+	handler := newTestServer().routeHandler()
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantStatus   int
+		wantAllow    string
+		wantLocation string
+	}{
+		{"health wrong method", "DELETE", "/api/v1/health", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"submit wrong method", "GET", "/api/v1/compliance/submit", http.StatusMethodNotAllowed, "POST, OPTIONS", ""},
+		{"client detail wrong method", "POST", "/api/v1/clients/client-1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"policy detail wrong method", "PATCH", "/api/v1/policies/policy-1", http.StatusMethodNotAllowed, "GET, HEAD, PUT, DELETE, OPTIONS", ""},
+		{"policy download wrong method", "POST", "/api/v1/policies/policy-1/download", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"evidence import wrong method", "GET", "/api/v1/import/evidence", http.StatusMethodNotAllowed, "POST, OPTIONS", ""},
+		{"attachment wrong method", "PUT", "/api/v1/submissions/sub-1/attachments/3", http.StatusMethodNotAllowed, "GET, HEAD, DELETE, OPTIONS", ""},
+		{"data subject purge wrong method", "GET", "/api/v1/data-subjects/purge", http.StatusMethodNotAllowed, "POST, OPTIONS", ""},
+		{"submission search wrong method", "POST", "/api/v1/submissions", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"policies options", "OPTIONS", "/api/v1/policies", http.StatusNoContent, "GET, HEAD, POST, OPTIONS", ""},
+		{"submit options", "OPTIONS", "/api/v1/compliance/submit", http.StatusNoContent, "POST, OPTIONS", ""},
+		{"trailing slash", "GET", "/api/v1/clients/", http.StatusPermanentRedirect, "", "/api/v1/clients"},
+		{"trailing slash with param", "GET", "/api/v1/clients/client-1/?format=csv", http.StatusPermanentRedirect, "", "/api/v1/clients/client-1?format=csv"},
+		{"trailing slash post", "POST", "/api/v1/compliance/submit/", http.StatusPermanentRedirect, "", "/api/v1/compliance/submit"},
+		{"unknown path", "GET", "/api/v1/unknown", http.StatusNotFound, "", ""},
+		{"unknown path options", "OPTIONS", "/api/v1/unknown", http.StatusNotFound, "", ""},
+		{"unknown client sub-resource", "GET", "/api/v1/clients/client-1/unknown", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// TestEmbeddedAssets tests that pages and scripts are served from the binary
+// rather than files beside it
+func TestEmbeddedAssets(t *testing.T) {
+	handler := newTestServer().routeHandler()
+	t.Chdir(t.TempDir())
+
+	for _, path := range []string{"/login", "/static/js/auth.js"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s = %d with %d bytes, want 200 with content", path, rec.Code, rec.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// alertTypeHungRun identifies alerts raised when a client's watchdog stopped
+// a report run that exceeded its maximum duration. The client carries on
+// with its schedule, so without the alert a hang would only show up as a
+// missed run, with no hint why.
+const alertTypeHungRun = "hung_run"
+
+// raiseRunFailureAlerts raises an alert for every run failure a heartbeat
+// reports. A heartbeat the client resends after a failed delivery does not
+// raise the alert twice.
+func (s *ComplianceServer) raiseRunFailureAlerts(heartbeat *api.Heartbeat) {
+	for _, failure := range heartbeat.RunFailures {
+		alert := hungRunAlert(heartbeat, failure, time.Now())
+		dedupeKey := fmt.Sprintf("%s:%s:%s:%s", alertTypeHungRun, heartbeat.ClientID, failure.ReportType, failure.StartedAt.UTC().Format(time.RFC3339))
+		if _, err := s.raiseAlert(alert, dedupeKey); err != nil {
+			s.logger.Error("Failed to create alert", "error", err, "type", alertTypeHungRun, "client_id", heartbeat.ClientID)
+		}
+	}
+}
+
+// hungRunAlert describes a run stopped by the client's watchdog
+func hungRunAlert(heartbeat *api.Heartbeat, failure api.RunFailure, now time.Time) *api.Alert {
+	limit := time.Duration(failure.LimitSeconds * float64(time.Second))
+	message := fmt.Sprintf("%s stopped its %s run started at %s after %s",
+		heartbeat.Hostname, failure.ReportType, failure.StartedAt.UTC().Format(time.RFC3339), limit)
+	severity := "warning"
+	if failure.Abandoned {
+		message += "; the run did not stop when cancelled, so the client may need a restart"
+		severity = "critical"
+	}
+
+	startedAt := failure.StartedAt
+	return &api.Alert{
+		Timestamp:  now,
+		Severity:   severity,
+		Type:       alertTypeHungRun,
+		ClientID:   heartbeat.ClientID,
+		Hostname:   heartbeat.Hostname,
+		ReportType: failure.ReportType,
+		ExpectedAt: &startedAt,
+		Message:    message,
+	}
+}
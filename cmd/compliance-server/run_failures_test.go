@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestHungRunAlert tests the alert raised for a run stopped by a client's watchdog
+func TestHungRunAlert(t *testing.T) {
+	now := time.Date(2026, 10, 15, 4, 5, 0, 0, time.UTC)
+	started := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+	heartbeat := &api.Heartbeat{ClientID: "client-123", Hostname: "WS-42", Timestamp: now.Add(-time.Hour)}
+	failure := api.RunFailure{ReportType: "NIST 800-171", StartedAt: started, LimitSeconds: 7200}
+
+	alert := hungRunAlert(heartbeat, failure, now)
+	if alert.Type != alertTypeHungRun || alert.Severity != "warning" || alert.ClientID != "client-123" || alert.ReportType != "NIST 800-171" {
+		t.Errorf("alert = %+v", alert)
+	}
+	if !alert.Timestamp.Equal(now) || alert.ExpectedAt == nil || !alert.ExpectedAt.Equal(started) {
+		t.Errorf("alert times = %v and %v, want the server time and the run's start", alert.Timestamp, alert.ExpectedAt)
+	}
+	if want := "WS-42 stopped its NIST 800-171 run started at 2026-10-15T02:00:00Z after 2h0m0s"; alert.Message != want {
+		t.Errorf("message = %q, want %q", alert.Message, want)
+	}
+
+	failure.Abandoned = true
+	alert = hungRunAlert(heartbeat, failure, now)
+	if alert.Severity != "critical" || !strings.Contains(alert.Message, "restart") {
+		t.Errorf("abandoned run alert = %+v, want a critical alert suggesting a restart", alert)
+	}
+}
+
+// TestHeartbeatRunFailuresValidation tests that run failures need a report
+// type and start time
+func TestHeartbeatRunFailuresValidation(t *testing.T) {
+	heartbeat := api.Heartbeat{ClientID: "client-123", Hostname: "WS-42"}
+	heartbeat.RunFailures = []api.RunFailure{{ReportType: "CIS"}}
+	if err := heartbeat.Validate(); err == nil {
+		t.Error("Validate() accepted a run failure without started_at")
+	}
+	heartbeat.RunFailures[0].StartedAt = time.Now()
+	if err := heartbeat.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
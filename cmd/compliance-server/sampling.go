@@ -0,0 +1,46 @@
+package main
+
+import (
+	"compliancetoolkit/pkg/api"
+)
+
+// sampleSubmission applies evidence sampling (sampling.enabled) to a
+// submission before it is stored: once today's sample of full submissions
+// of its report type has been stored, the rest are reduced to their summary
+func (s *ComplianceServer) sampleSubmission(submission *api.ComplianceSubmission) {
+	submission.SummaryOnly = false
+	if !s.config().Sampling.Enabled {
+		return
+	}
+
+	count, err := s.db.CountFullSubmissionsToday(submission.ReportType)
+	if err != nil {
+		// Storing too much evidence is better than losing an audit sample
+		s.logger.Warn("Failed to count sampled submissions, keeping full evidence",
+			"report_type", submission.ReportType, "error", err)
+		return
+	}
+	if count < s.config().Sampling.FullPerDay {
+		return
+	}
+	summarizeSubmission(submission)
+}
+
+// summarizeSubmission drops a submission's evidence records and check
+// details. The summary counts are kept, and for each check what dashboards
+// and check analytics read: its name, category, status, duration, error
+// class and controls.
+func summarizeSubmission(submission *api.ComplianceSubmission) {
+	submission.SummaryOnly = true
+	submission.Evidence = nil
+	for i, q := range submission.Compliance.Queries {
+		submission.Compliance.Queries[i] = api.QueryResult{
+			Name:       q.Name,
+			Category:   q.Category,
+			Status:     q.Status,
+			DurationMs: q.DurationMs,
+			ErrorClass: q.ErrorClass,
+			Controls:   q.Controls,
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestSummarizeSubmission tests that a sampled-out submission keeps its
+// summary and check statuses but not its evidence
+func TestSummarizeSubmission(t *testing.T) {
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		Compliance: api.ComplianceData{
+			OverallStatus: "partial",
+			TotalChecks:   2,
+			PassedChecks:  1,
+			FailedChecks:  1,
+			Queries: []api.QueryResult{
+				{Name: "UAC", Category: "Access", Status: "pass", Expected: "1", Actual: "1", Path: `SOFTWARE\Policies\System`, DurationMs: 2.5},
+				{Name: "Audit", Status: "error", Message: "access denied", ErrorClass: api.ErrorClassAccessDenied},
+			},
+		},
+		Evidence: []api.EvidenceRecord{{QueryName: "UAC", Action: "read", Result: "1"}},
+	}
+
+	summarizeSubmission(submission)
+
+	if !submission.SummaryOnly || submission.Evidence != nil {
+		t.Errorf("SummaryOnly = %v, evidence = %v; want a summary without evidence", submission.SummaryOnly, submission.Evidence)
+	}
+	if submission.Compliance.OverallStatus != "partial" || submission.Compliance.PassedChecks != 1 || submission.Compliance.FailedChecks != 1 {
+		t.Errorf("summary = %+v, want the counts kept", submission.Compliance)
+	}
+	want := []api.QueryResult{
+		{Name: "UAC", Category: "Access", Status: "pass", DurationMs: 2.5},
+		{Name: "Audit", Status: "error", ErrorClass: api.ErrorClassAccessDenied},
+	}
+	if !reflect.DeepEqual(submission.Compliance.Queries, want) {
+		t.Errorf("queries = %+v, want %+v", submission.Compliance.Queries, want)
+	}
+}
+
+// TestSampleSubmissionDisabled tests that submissions are stored in full
+// unless sampling is enabled
+func TestSampleSubmissionDisabled(t *testing.T) {
+	s := newTestServer()
+	submission := &api.ComplianceSubmission{
+		SummaryOnly: true, // Clients cannot mark their own submissions
+		Evidence:    []api.EvidenceRecord{{QueryName: "UAC"}},
+	}
+
+	s.sampleSubmission(submission)
+
+	if submission.SummaryOnly || len(submission.Evidence) != 1 {
+		t.Errorf("submission = %+v, want it stored in full", submission)
+	}
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// serverArchiveFormat is the layout version export writes. Import reads
+// archives of this version and older.
+const serverArchiveFormat = 1
+
+// serverArchiveManifest names the archive entry describing its contents; each
+// table is stored as <table>.jsonl, one JSON object per row
+const serverArchiveManifest = "manifest.json"
+
+// How import treats a row whose key already exists in the database
+const (
+	conflictFail      = "fail"      // Abort the import; nothing is changed
+	conflictSkip      = "skip"      // Keep the database's row
+	conflictOverwrite = "overwrite" // Replace the database's row with the archive's
+)
+
+// archiveTable is a table copied by export and import
+type archiveTable struct {
+	name string
+
+	// key identifies a row across servers; surrogate IDs differ between
+	// databases and are not copied
+	key []string
+
+	// omit lists the columns left out of the archive: surrogate IDs and
+	// secrets. On import they take their defaults, or fill, and are kept
+	// when a row is overwritten.
+	omit []string
+	fill map[string]interface{}
+
+	// history marks submission history, only copied with --all
+	history bool
+}
+
+// archiveTables are the tables an archive holds, in the order they are
+// imported so rows are stored after the rows they reference. API keys,
+// server secrets, sessions and logs are not copied.
+var archiveTables = []archiveTable{
+	// Users arrive without passwords or MFA secrets and cannot sign in until
+	// their password is reset
+	{name: "users", key: []string{"username"},
+		omit: []string{"id", "password_hash", "mfa_secret", "mfa_enabled"},
+		fill: map[string]interface{}{"password_hash": "!", "mfa_enabled": false}},
+	{name: "clients", key: []string{"client_id"}, omit: []string{"id"}},
+	{name: "client_tags", key: []string{"client_id", "tag"}},
+	{name: "policies", key: []string{"policy_id"}, omit: []string{"id"}},
+	{name: "policy_revisions", key: []string{"policy_id", "revision"}},
+	{name: "policy_rollouts", key: []string{"policy_id"}},
+	{name: "client_policies", key: []string{"client_id", "policy_id"}, omit: []string{"id"}},
+	{name: "group_policies", key: []string{"tag", "policy_id"}},
+	{name: "alert_rules", key: []string{"alert_type", "tag"}, omit: []string{"id"}},
+	{name: "maintenance_windows", key: []string{"name"}, omit: []string{"id"}},
+	{name: "notification_templates", key: []string{"name", "org"}},
+	{name: "submissions", key: []string{"submission_id"}, omit: []string{"id"}, history: true},
+	{name: "agent_telemetry", key: []string{"submission_id"}, history: true},
+	{name: "submission_states", key: []string{"client_id", "report_type"}, history: true},
+	{name: "submission_attachments", key: []string{"storage_key"}, omit: []string{"id"}, history: true},
+}
+
+// archiveManifest describes an archive
+type archiveManifest struct {
+	Format        int                    `json:"format"`
+	ServerVersion string                 `json:"server_version"`
+	SchemaVersion int                    `json:"schema_version"` // Latest migration of the exporting database
+	CreatedAt     time.Time              `json:"created_at"`
+	History       bool                   `json:"history"` // Exported with --all
+	Tables        []archiveTableManifest `json:"tables"`
+}
+
+// archiveTableManifest describes the rows of one table in an archive
+type archiveTableManifest struct {
+	Name    string   `json:"name"`
+	Rows    int      `json:"rows"`
+	Columns []string `json:"columns"`
+}
+
+// archiveTableResult counts what import did, or would do, with a table
+type archiveTableResult struct {
+	Name        string
+	Inserted    int
+	Overwritten int
+	Skipped     int
+}
+
+// validConflictStrategy reports whether strategy is one import knows
+func validConflictStrategy(strategy string) bool {
+	return strategy == conflictFail || strategy == conflictSkip || strategy == conflictOverwrite
+}
+
+// ExportArchive writes the tables of archiveTables, with submission history
+// when history is set, to w as a zip archive. The tables are read in one
+// snapshot, so the archive is consistent while the server keeps running.
+func (d *Database) ExportArchive(w io.Writer, history bool) (*archiveManifest, error) {
+	schemaVersion, err := d.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := safesql.BeginTx(context.Background(), d.db.DB, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	manifest := &archiveManifest{
+		Format:        serverArchiveFormat,
+		ServerVersion: version,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		History:       history,
+		Tables:        []archiveTableManifest{},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, table := range archiveTables {
+		if table.history && !history {
+			continue
+		}
+		columns, err := tableColumns(tx, table.name)
+		if err != nil {
+			return nil, err
+		}
+		section := archiveTableManifest{Name: table.name, Columns: slices.DeleteFunc(columns, func(column string) bool {
+			return slices.Contains(table.omit, column)
+		})}
+
+		entry, err := archive.Create(table.name + ".jsonl")
+		if err != nil {
+			return nil, err
+		}
+		if section.Rows, err = exportTable(tx, table, entry); err != nil {
+			return nil, err
+		}
+		manifest.Tables = append(manifest.Tables, section)
+	}
+
+	entry, err := archive.Create(serverArchiveManifest)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// exportTable writes the rows of a table as JSON lines, without its omitted
+// columns, and returns how many it wrote
+func exportTable(tx *safesql.Tx, table archiveTable, w io.Writer) (int, error) {
+	omit := append([]string{}, table.omit...) // An empty array rather than NULL
+	query := safesql.New(`SELECT (to_jsonb(t) - $1::text[])::text FROM `, pq.Array(omit)).
+		AppendQuery(safesql.Identifier(table.name)).Append(` t`)
+	rows, err := tx.Query(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", table.name, err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return count, fmt.Errorf("failed to read %s: %w", table.name, err)
+		}
+		if _, err := io.WriteString(w, row+"\n"); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// ImportArchive loads an archive written by ExportArchive in one
+// transaction, resolving rows whose key exists with strategy. With dryRun the
+// transaction is rolled back, so the counts show what an import would do.
+// The archive may come from an older schema; columns it lacks take their
+// defaults.
+func (d *Database) ImportArchive(path, strategy string, dryRun bool) ([]archiveTableResult, error) {
+	if !validConflictStrategy(strategy) {
+		return nil, fmt.Errorf("unknown conflict strategy %q (use fail, skip or overwrite)", strategy)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archive.Close()
+
+	manifest, err := readArchiveManifest(&archive.Reader)
+	if err != nil {
+		return nil, err
+	}
+	schemaVersion, err := d.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion > schemaVersion {
+		return nil, fmt.Errorf("archive is from schema version %d, newer than this server's %d; upgrade the server first", manifest.SchemaVersion, schemaVersion)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	sections := make(map[string]archiveTableManifest, len(manifest.Tables))
+	for _, section := range manifest.Tables {
+		sections[section.Name] = section
+	}
+
+	var results []archiveTableResult
+	for _, table := range archiveTables {
+		section, ok := sections[table.name]
+		if !ok {
+			continue
+		}
+		entry, err := archive.Open(table.name + ".jsonl")
+		if err != nil {
+			return nil, fmt.Errorf("archive has no rows for %s: %w", table.name, err)
+		}
+		result, err := importTable(tx, table, section.Columns, entry, strategy)
+		entry.Close()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if _, ok := sections["submissions"]; ok {
+		if err := d.rebuildRollups(tx, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return results, nil
+}
+
+// readArchiveManifest reads and checks the manifest of an archive
+func readArchiveManifest(archive *zip.Reader) (*archiveManifest, error) {
+	entry, err := archive.Open(serverArchiveManifest)
+	if err != nil {
+		return nil, fmt.Errorf("not a server archive: %w", err)
+	}
+	defer entry.Close()
+
+	var manifest archiveManifest
+	if err := json.NewDecoder(entry).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if manifest.Format < 1 || manifest.Format > serverArchiveFormat {
+		return nil, fmt.Errorf("archive format %d is not supported (this server reads up to %d)", manifest.Format, serverArchiveFormat)
+	}
+	return &manifest, nil
+}
+
+// importTable stores the rows of one table. Only the columns both the
+// archive and the database have are written.
+func importTable(tx *safesql.Tx, table archiveTable, archived []string, r io.Reader, strategy string) (archiveTableResult, error) {
+	result := archiveTableResult{Name: table.name}
+
+	existing, err := tableColumns(tx, table.name)
+	if err != nil {
+		return result, err
+	}
+	var columns []string
+	for _, column := range archived {
+		if slices.Contains(existing, column) && !slices.Contains(table.omit, column) {
+			columns = append(columns, column)
+		}
+	}
+	for _, column := range table.key {
+		if !slices.Contains(columns, column) {
+			return result, fmt.Errorf("archive rows of %s have no %s column", table.name, column)
+		}
+	}
+
+	fill := map[string]interface{}{}
+	inserted := slices.Clone(columns)
+	for column, value := range table.fill {
+		if slices.Contains(existing, column) {
+			fill[column] = value
+			inserted = append(inserted, column)
+		}
+	}
+	sort.Strings(inserted[len(columns):])
+	fillJSON, err := json.Marshal(fill)
+	if err != nil {
+		return result, err
+	}
+
+	statements := newArchiveStatements(table, columns, inserted)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if err := importRow(tx, table, statements, line, fillJSON, strategy, &result); err != nil {
+				return result, err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read %s rows: %w", table.name, err)
+		}
+	}
+}
+
+// archiveStatements builds the statements importing the rows of a table.
+// The fragments are built once; each statement takes the row as JSON.
+type archiveStatements struct {
+	table    safesql.Query // Quoted table name
+	keyMatch safesql.Query // The key columns of t equal those of r
+	columns  safesql.Query // Archived columns
+	inserted safesql.Query // Archived and fill columns
+	values   safesql.Query // Archived columns of r
+}
+
+// newArchiveStatements builds the statements importing rows of table, which
+// write columns and, when inserting, the fill columns too
+func newArchiveStatements(table archiveTable, columns, inserted []string) archiveStatements {
+	identifiers := func(columns []string, fromRecord bool) safesql.Query {
+		var list []safesql.Query
+		for _, column := range columns {
+			if fromRecord {
+				list = append(list, safesql.New(`r.`).AppendQuery(safesql.Identifier(column)))
+			} else {
+				list = append(list, safesql.Identifier(column))
+			}
+		}
+		return safesql.Join(list, `, `)
+	}
+
+	var match []safesql.Query
+	for _, column := range table.key {
+		match = append(match, safesql.New(`t.`).AppendQuery(safesql.Identifier(column)).
+			Append(` = r.`).AppendQuery(safesql.Identifier(column)))
+	}
+
+	return archiveStatements{
+		table:    safesql.Identifier(table.name),
+		keyMatch: safesql.Join(match, ` AND `),
+		columns:  identifiers(columns, false),
+		inserted: identifiers(inserted, false),
+		values:   identifiers(columns, true),
+	}
+}
+
+// record returns the row as a record of the table, aliased r
+func (st archiveStatements) record(row string) safesql.Query {
+	return safesql.New(`jsonb_populate_record(NULL::`).AppendQuery(st.table).Append(`, $1::jsonb) r`, row)
+}
+
+// exists returns whether a row with the same key is stored
+func (st archiveStatements) exists(row string) safesql.Query {
+	return safesql.New(`SELECT EXISTS (SELECT 1 FROM `).AppendQuery(st.table).Append(` t, `).AppendQuery(st.record(row)).
+		Append(` WHERE `).AppendQuery(st.keyMatch).Append(`)`)
+}
+
+// insert stores the row with the fill values
+func (st archiveStatements) insert(row, fill string) safesql.Query {
+	return safesql.New(`INSERT INTO `).AppendQuery(st.table).Append(` (`).AppendQuery(st.inserted).
+		Append(`) SELECT `).AppendQuery(st.inserted).
+		Append(` FROM jsonb_populate_record(NULL::`).AppendQuery(st.table).Append(`, $1::jsonb || $2::jsonb)`, row, fill)
+}
+
+// overwrite replaces the archived columns of the stored row with the same key
+func (st archiveStatements) overwrite(row string) safesql.Query {
+	return safesql.New(`UPDATE `).AppendQuery(st.table).Append(` t SET (`).AppendQuery(st.columns).
+		Append(`) = ROW(`).AppendQuery(st.values).Append(`) FROM `).AppendQuery(st.record(row)).
+		Append(` WHERE `).AppendQuery(st.keyMatch)
+}
+
+// importRow stores one archived row according to strategy
+func importRow(tx *safesql.Tx, table archiveTable, statements archiveStatements, row, fill []byte, strategy string, result *archiveTableResult) error {
+	var exists bool
+	if err := tx.QueryRow(statements.exists(string(row))).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check %s row: %w", table.name, err)
+	}
+
+	switch {
+	case !exists:
+		if _, err := tx.Exec(statements.insert(string(row), string(fill))); err != nil {
+			return fmt.Errorf("failed to import %s %s: %w", table.name, rowKey(table, row), err)
+		}
+		result.Inserted++
+	case strategy == conflictSkip:
+		result.Skipped++
+	case strategy == conflictOverwrite:
+		if _, err := tx.Exec(statements.overwrite(string(row))); err != nil {
+			return fmt.Errorf("failed to overwrite %s %s: %w", table.name, rowKey(table, row), err)
+		}
+		result.Overwritten++
+	default:
+		return fmt.Errorf("%s %s already exists (use --on-conflict skip or overwrite)", table.name, rowKey(table, row))
+	}
+	return nil
+}
+
+// rowKey describes the key of an archived row for messages, such as
+// "username=alice"
+func rowKey(table archiveTable, row []byte) string {
+	var values map[string]interface{}
+	json.Unmarshal(row, &values)
+	parts := make([]string, 0, len(table.key))
+	for _, column := range table.key {
+		parts = append(parts, fmt.Sprintf("%s=%v", column, values[column]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tableColumns returns the columns of a table in the current schema
+func tableColumns(tx *safesql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(safesql.New(`
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+	return columns, nil
+}
+
+// schemaVersion returns the latest migration applied to the database
+func (d *Database) schemaVersion() (int, error) {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	latest := 0
+	for version := range applied {
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// runExportCommand implements compliance-server export [--all] file. The
+// archive is written next to file and renamed into place when complete.
+func runExportCommand(config *ServerConfig, path string, history bool) bool {
+	db, err := NewDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open database: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	defer os.Remove(file.Name())
+
+	manifest, err := db.ExportArchive(file, history)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	for _, table := range manifest.Tables {
+		fmt.Printf("  %-24s %d rows\n", table.Name, table.Rows)
+	}
+	if history {
+		fmt.Printf("Exported to %s (schema version %d)\n", path, manifest.SchemaVersion)
+	} else {
+		fmt.Printf("Exported to %s without submission history (use --all to include it; schema version %d)\n", path, manifest.SchemaVersion)
+	}
+	return true
+}
+
+// runImportCommand implements compliance-server import file
+func runImportCommand(config *ServerConfig, path, strategy string, dryRun bool) bool {
+	if !validConflictStrategy(strategy) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --on-conflict %q (use fail, skip or overwrite)\n", strategy)
+		return false
+	}
+
+	db, err := NewDatabase(config.Database, slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open database: %v\n", err)
+		return false
+	}
+	defer db.Close()
+
+	results, err := db.ImportArchive(path, strategy, dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Println("Nothing was imported")
+		return false
+	}
+
+	users := 0
+	for _, result := range results {
+		fmt.Printf("  %-24s %d inserted, %d overwritten, %d skipped\n", result.Name, result.Inserted, result.Overwritten, result.Skipped)
+		if result.Name == "users" {
+			users = result.Inserted
+		}
+	}
+	if dryRun {
+		fmt.Println("Dry run; no changes were made")
+		return true
+	}
+	fmt.Printf("Imported %s\n", path)
+	if users > 0 {
+		fmt.Printf("%d new users have no password; set one with POST /api/v1/users/change-password\n", users)
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestArchiveTables tests that the archived tables are imported after the
+// tables they reference and that no secret is written out
+func TestArchiveTables(t *testing.T) {
+	position := map[string]int{}
+	for i, table := range archiveTables {
+		position[table.name] = i
+		if len(table.key) == 0 {
+			t.Errorf("%s has no key columns", table.name)
+		}
+		for _, column := range table.key {
+			for _, omitted := range table.omit {
+				if column == omitted {
+					t.Errorf("%s key column %s is not archived", table.name, column)
+				}
+			}
+		}
+	}
+
+	for _, order := range [][2]string{
+		{"users", "policies"},
+		{"clients", "client_tags"},
+		{"policies", "policy_revisions"},
+		{"policies", "group_policies"},
+		{"clients", "submissions"},
+		{"submissions", "submission_attachments"},
+	} {
+		if position[order[0]] >= position[order[1]] {
+			t.Errorf("%s is imported before %s", order[1], order[0])
+		}
+	}
+
+	for _, table := range archiveTables {
+		switch table.name {
+		case "api_keys", "sessions", "secrets", "audit_log":
+			t.Errorf("%s is archived", table.name)
+		case "users":
+			for _, column := range []string{"password_hash", "mfa_secret"} {
+				if !strings.Contains(strings.Join(table.omit, ","), column) {
+					t.Errorf("users.%s is archived", column)
+				}
+			}
+		}
+	}
+}
+
+// TestValidConflictStrategy tests the accepted --on-conflict values
+func TestValidConflictStrategy(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     bool
+	}{
+		{"fail", true},
+		{"skip", true},
+		{"overwrite", true},
+		{"", false},
+		{"merge", false},
+		{"Skip", false},
+	}
+
+	for _, tt := range tests {
+		if got := validConflictStrategy(tt.strategy); got != tt.want {
+			t.Errorf("validConflictStrategy(%q) = %v, want %v", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+// TestReadArchiveManifest tests that only archives with a manifest of a
+// known format are read
+func TestReadArchiveManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  bool
+	}{
+		{"current format", `{"format": 1, "schema_version": 27, "tables": [{"name": "users", "rows": 2}]}`, false},
+		{"newer format", `{"format": 2, "schema_version": 27}`, true},
+		{"no format", `{"schema_version": 27}`, true},
+		{"not JSON", `manifest`, true},
+		{"no manifest", ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			if tt.manifest != "" {
+				entry, _ := zw.Create(serverArchiveManifest)
+				entry.Write([]byte(tt.manifest))
+			} else {
+				entry, _ := zw.Create("users.jsonl")
+				entry.Write([]byte(`{"username": "admin"}` + "\n"))
+			}
+			zw.Close()
+
+			archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := readArchiveManifest(archive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readArchiveManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (manifest.SchemaVersion != 27 || len(manifest.Tables) != 1 || manifest.Tables[0].Rows != 2) {
+				t.Errorf("readArchiveManifest() = %+v", manifest)
+			}
+		})
+	}
+}
+
+// TestArchiveStatements tests the statements importing a row, which match
+// stored rows by the key columns and pass the row as a parameter
+func TestArchiveStatements(t *testing.T) {
+	table := archiveTable{name: "alert_rules", key: []string{"alert_type", "tag"}}
+	statements := newArchiveStatements(table, []string{"alert_type", "tag", "enabled"}, []string{"alert_type", "tag", "enabled"})
+	row := `{"alert_type": "stale", "tag": "", "enabled": true}`
+
+	tests := []struct {
+		name     string
+		got      string
+		args     []any
+		want     string
+		wantArgs []any
+	}{
+		{"exists", statements.exists(row).String(), statements.exists(row).Args(),
+			`SELECT EXISTS (SELECT 1 FROM "alert_rules" t, jsonb_populate_record(NULL::"alert_rules", $1::jsonb) r WHERE t."alert_type" = r."alert_type" AND t."tag" = r."tag")`,
+			[]any{row}},
+		{"insert", statements.insert(row, `{}`).String(), statements.insert(row, `{}`).Args(),
+			`INSERT INTO "alert_rules" ("alert_type", "tag", "enabled") SELECT "alert_type", "tag", "enabled" FROM jsonb_populate_record(NULL::"alert_rules", $1::jsonb || $2::jsonb)`,
+			[]any{row, `{}`}},
+		{"overwrite", statements.overwrite(row).String(), statements.overwrite(row).Args(),
+			`UPDATE "alert_rules" t SET ("alert_type", "tag", "enabled") = ROW(r."alert_type", r."tag", r."enabled") FROM jsonb_populate_record(NULL::"alert_rules", $1::jsonb) r WHERE t."alert_type" = r."alert_type" AND t."tag" = r."tag"`,
+			[]any{row}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("statement =\n%s\nwant\n%s", tt.got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", tt.args, tt.wantArgs)
+			}
+		})
+	}
+
+	if got, want := rowKey(table, []byte(row)), "alert_type=stale, tag="; got != want {
+		t.Errorf("rowKey() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	serviceName        = "ComplianceToolkitServer"
+	serviceDisplayName = "Compliance Toolkit Server"
+	serviceDescription = "Collects compliance reports from Compliance Toolkit clients and serves the dashboard"
+)
+
+// serviceCommand returns the absolute path of the running executable and the
+// arguments a service manager starts it with. The config path is made
+// absolute because services do not start in the directory they were
+// installed from.
+func serviceCommand(configPath string) (string, []string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	absPath, err := filepath.Abs(getConfigPath(configPath))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get absolute config path: %w", err)
+	}
+
+	return exePath, []string{"--config", absPath}, nil
+}
+
+// chdirToExecutable makes the executable's directory the working directory,
+// so that templates and relative paths in the configuration resolve as they
+// do when the server is started from its install directory
+func chdirToExecutable() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	return os.Chdir(filepath.Dir(exePath))
+}
+
+// runConsole serves until an interrupt or SIGTERM, or until the server fails.
+// Under systemd it reports readiness and shutdown to the service manager.
+func runConsole(server *ComplianceServer) error {
+	if err := server.Start(); err != nil {
+		return err
+	}
+	notifyServiceManager("READY=1\nSTATUS=Serving requests")
+
+	// Wait for shutdown signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	var serveErr error
+	select {
+	case sig := <-sigChan:
+		slog.Info("Received shutdown signal", "signal", sig.String())
+	case serveErr = <-server.serveErr:
+	}
+
+	// Graceful shutdown; a second signal stops waiting for requests in
+	// flight
+	go func() {
+		sig := <-sigChan
+		slog.Warn("Received second shutdown signal, exiting immediately", "signal", sig.String())
+		os.Exit(1)
+	}()
+	notifyServiceManager("STOPPING=1")
+	if err := server.Shutdown(); err != nil {
+		return err
+	}
+	if serveErr != nil {
+		return fmt.Errorf("server stopped: %w", serveErr)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// systemdUnitName is the unit the server is installed as
+	systemdUnitName = "compliance-server.service"
+
+	// systemdUnitDir is where installService writes the unit file
+	systemdUnitDir = "/etc/systemd/system"
+)
+
+// runService is unsupported; outside Windows the server runs in the
+// foreground under systemd or another supervisor
+func runService(server *ComplianceServer, logger *slog.Logger) error {
+	return fmt.Errorf("Windows services are not supported on this platform")
+}
+
+// isWindowsService always reports false outside Windows
+func isWindowsService() (bool, error) {
+	return false, nil
+}
+
+// notifyServiceManager sends a state change to systemd when it started the
+// server as a Type=notify unit (sd_notify). Without NOTIFY_SOCKET it does
+// nothing.
+func notifyServiceManager(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if err := sdNotify(socket, state); err != nil {
+		slog.Warn("Failed to notify systemd", "error", err)
+	}
+}
+
+// sdNotify writes state to the systemd notification socket. A leading '@'
+// names a socket in the abstract namespace.
+func sdNotify(socket, state string) error {
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to write to notify socket: %w", err)
+	}
+	return nil
+}
+
+// systemdUnit renders the unit file running exePath with args. The working
+// directory is the executable's, where the templates are installed.
+func systemdUnit(exePath string, args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{exePath}, args...) {
+		quoted = append(quoted, fmt.Sprintf("%q", arg))
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=%s
+Documentation=https://github.com/MrBrooks-code/compliance-toolkit
+Wants=network-online.target
+After=network-online.target postgresql.service
+
+[Service]
+Type=notify
+ExecStart=%s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=10s
+TimeoutStopSec=40s
+
+[Install]
+WantedBy=multi-user.target
+`, serviceDisplayName, strings.Join(quoted, " "), filepath.Dir(exePath))
+}
+
+// installService writes a systemd unit for the server
+func installService(configPath string) error {
+	exePath, args, err := serviceCommand(configPath)
+	if err != nil {
+		return err
+	}
+
+	unitPath := filepath.Join(systemdUnitDir, systemdUnitName)
+	if _, err := os.Stat(unitPath); err == nil {
+		return fmt.Errorf("service %s already exists", unitPath)
+	}
+
+	if err := os.WriteFile(unitPath, []byte(systemdUnit(exePath, args)), 0644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		fmt.Printf("Warning: Could not reload systemd: %v\n", err)
+	}
+	if err := systemctl("enable", systemdUnitName); err != nil {
+		fmt.Printf("Warning: Could not enable service: %v\n", err)
+	}
+
+	fmt.Printf("Service %s installed successfully\n", unitPath)
+	fmt.Printf("Start with: systemctl start %s\n", systemdUnitName)
+	return nil
+}
+
+// uninstallService stops, disables and removes the systemd unit
+func uninstallService() error {
+	unitPath := filepath.Join(systemdUnitDir, systemdUnitName)
+	if _, err := os.Stat(unitPath); err != nil {
+		return fmt.Errorf("service %s not found", unitPath)
+	}
+
+	if err := systemctl("disable", "--now", systemdUnitName); err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		fmt.Printf("Warning: Could not reload systemd: %v\n", err)
+	}
+
+	fmt.Printf("Service %s uninstalled successfully\n", systemdUnitName)
+	return nil
+}
+
+// startService starts the systemd unit
+func startService() error {
+	if err := systemctl("start", systemdUnitName); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	fmt.Printf("Service %s started successfully\n", systemdUnitName)
+	return nil
+}
+
+// stopService stops the systemd unit
+func stopService() error {
+	if err := systemctl("stop", systemdUnitName); err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	fmt.Printf("Service %s stopped successfully\n", systemdUnitName)
+	return nil
+}
+
+// serviceStatus displays the status of the systemd unit
+func serviceStatus() error {
+	cmd := exec.Command("systemctl", "status", "--no-pager", systemdUnitName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// systemctl status exits non-zero for stopped units; only a missing
+	// systemctl is an error
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return fmt.Errorf("failed to run systemctl: %w", err)
+		}
+	}
+	return nil
+}
+
+// systemctl runs systemctl with args, returning its output in the error
+func systemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
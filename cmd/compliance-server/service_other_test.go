@@ -0,0 +1,55 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSystemdUnit tests the rendered systemd unit file
+func TestSystemdUnit(t *testing.T) {
+	unit := systemdUnit("/opt/compliance server/compliance-server", []string{"--config", "/etc/compliance/server.yaml"})
+
+	for _, want := range []string{
+		"Type=notify\n",
+		`ExecStart="/opt/compliance server/compliance-server" "--config" "/etc/compliance/server.yaml"` + "\n",
+		"WorkingDirectory=/opt/compliance server\n",
+		"Restart=on-failure\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit is missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+// TestNotifyServiceManager tests that state changes reach the systemd
+// notification socket, and are skipped outside systemd
+func TestNotifyServiceManager(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	notifyServiceManager("READY=1")
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	notifyServiceManager("STOPPING=1")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := string(buf[:n]); got != "STOPPING=1" {
+		t.Errorf("notification = %q, want STOPPING=1", got)
+	}
+}
@@ -0,0 +1,330 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serverService implements the svc.Handler interface
+type serverService struct {
+	server *ComplianceServer
+	logger *slog.Logger
+	elog   *eventlog.Log
+}
+
+// Execute is called by the service control manager when the service starts
+func (s *serverService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
+	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
+
+	// Tell SCM we're starting
+	changes <- svc.Status{State: svc.StartPending}
+
+	if err := s.server.Start(); err != nil {
+		s.elog.Error(1, fmt.Sprintf("Failed to start server: %v", err))
+		s.logger.Error("Failed to start server", "error", err)
+		return false, 1
+	}
+
+	// Tell SCM we're running
+	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
+	s.elog.Info(1, "Compliance Toolkit Server service started successfully")
+
+	// Service control loop
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+
+			case svc.Stop, svc.Shutdown:
+				s.elog.Info(1, "Service stop requested")
+				s.logger.Info("Service stopping")
+
+				// Tell SCM we're stopping; Shutdown waits up to
+				// server.shutdown_timeout for requests in flight
+				waitHint := s.server.config().Server.ShutdownTimeout + 5*time.Second
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(waitHint.Milliseconds())}
+
+				if err := s.server.Shutdown(); err != nil {
+					s.elog.Error(1, fmt.Sprintf("Error during shutdown: %v", err))
+					s.logger.Error("Error during shutdown", "error", err)
+					return false, 1
+				}
+
+				s.elog.Info(1, "Service stopped")
+				s.logger.Info("Service stopped")
+				return false, 0
+
+			default:
+				s.elog.Warning(1, fmt.Sprintf("Unexpected control request: %v", c))
+			}
+
+		case err := <-s.server.serveErr:
+			// Server stopped unexpectedly; report failure so SCM recovery
+			// actions restart it
+			s.elog.Error(1, fmt.Sprintf("Server stopped with error: %v", err))
+			s.logger.Error("Server stopped with error", "error", err)
+			changes <- svc.Status{State: svc.StopPending}
+			s.server.Shutdown()
+			return false, 1
+		}
+	}
+}
+
+// runService runs the server under the Windows service control manager
+func runService(server *ComplianceServer, logger *slog.Logger) error {
+	// Open event log
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer elog.Close()
+
+	elog.Info(1, "Starting service")
+
+	// Run the service
+	err = svc.Run(serviceName, &serverService{
+		server: server,
+		logger: logger,
+		elog:   elog,
+	})
+	if err != nil {
+		elog.Error(1, fmt.Sprintf("Service failed: %v", err))
+		return fmt.Errorf("service execution failed: %w", err)
+	}
+
+	return nil
+}
+
+// isWindowsService checks if the process is running as a Windows service
+func isWindowsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// notifyServiceManager is a no-op on Windows, where the service control
+// manager is told about state changes by Execute
+func notifyServiceManager(state string) {}
+
+// installService installs the server as a Windows service
+func installService(configPath string) error {
+	exePath, args, err := serviceCommand(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Open service manager
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	// Check if service already exists
+	s, err := m.OpenService(serviceName)
+	if err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	// Create service
+	s, err = m.CreateService(serviceName, exePath, mgr.Config{
+		DisplayName:  serviceDisplayName,
+		Description:  serviceDescription,
+		StartType:    mgr.StartAutomatic,
+		Dependencies: []string{"Tcpip"}, // Require network
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	// Set service recovery options (restart on failure)
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 60 * time.Second},
+	}, 86400) // Reset failure count after 24 hours
+	if err == nil {
+		// Also restart when Execute reports a failure (the port is taken,
+		// the server stops with an error), not only when the process crashes
+		err = s.SetRecoveryActionsOnNonCrashFailures(true)
+	}
+	if err != nil {
+		// Non-fatal - service is still installed
+		fmt.Printf("Warning: Could not set recovery options: %v\n", err)
+	}
+
+	// Setup event log
+	err = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil {
+		// Remove service if event log setup fails
+		s.Delete()
+		return fmt.Errorf("failed to setup event log: %w", err)
+	}
+
+	fmt.Printf("Service %s installed successfully\n", serviceName)
+	fmt.Printf("Start with: sc start %s\n", serviceName)
+	return nil
+}
+
+// uninstallService stops and removes the server service
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service status: %w", err)
+	}
+
+	if status.State != svc.Stopped {
+		fmt.Printf("Stopping service...\n")
+		if err := stopAndWait(s); err != nil {
+			return err
+		}
+		fmt.Printf("Service stopped\n")
+	}
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+
+	if err := eventlog.Remove(serviceName); err != nil {
+		// Non-fatal
+		fmt.Printf("Warning: Could not remove event log: %v\n", err)
+	}
+
+	fmt.Printf("Service %s uninstalled successfully\n", serviceName)
+	return nil
+}
+
+// startService starts the server service
+func startService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	fmt.Printf("Service %s started successfully\n", serviceName)
+	return nil
+}
+
+// stopService stops the server service
+func stopService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+	defer s.Close()
+
+	if err := stopAndWait(s); err != nil {
+		return err
+	}
+
+	fmt.Printf("Service %s stopped successfully\n", serviceName)
+	return nil
+}
+
+// stopAndWait asks a service to stop and waits up to 40 seconds for it to
+// do so
+func stopAndWait(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+
+	timeout := time.Now().Add(40 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(timeout) {
+			return fmt.Errorf("timeout waiting for service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		status, err = s.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query service status: %w", err)
+		}
+	}
+	return nil
+}
+
+// serviceStatus displays the status of the server service
+func serviceStatus() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s not found", serviceName)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service status: %w", err)
+	}
+
+	config, err := s.Config()
+	if err != nil {
+		return fmt.Errorf("failed to query service config: %w", err)
+	}
+
+	fmt.Printf("Service: %s\n", serviceName)
+	fmt.Printf("Display Name: %s\n", config.DisplayName)
+	fmt.Printf("State: %s\n", serviceStateString(status.State))
+	fmt.Printf("Executable: %s\n", config.BinaryPathName)
+
+	return nil
+}
+
+// serviceStateString converts service state to string
+func serviceStateString(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "Stopped"
+	case svc.StartPending:
+		return "Start Pending"
+	case svc.StopPending:
+		return "Stop Pending"
+	case svc.Running:
+		return "Running"
+	default:
+		return fmt.Sprintf("Unknown (%d)", state)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Dashboard logins are kept in the sessions table (migration 0030). The
+// session_id cookie holds a random token and the table its SHA-256 hash,
+// so the user is only ever read from a session the server issued. Logging
+// out deletes the session, and setting a password deletes all of the
+// user's sessions.
+
+// sessionCookieName names the cookie holding a dashboard session token
+const sessionCookieName = "session_id"
+
+// sessionTTL is how long a dashboard session lasts
+const sessionTTL = 7 * 24 * time.Hour
+
+// sessionCleanupInterval is how often expired sessions are deleted
+const sessionCleanupInterval = 1 * time.Hour
+
+// sessionUser returns the user of r's session cookie, or nil when r has no
+// cookie or its session is unknown or expired
+func (s *ComplianceServer) sessionUser(r *http.Request) *User {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	user, err := s.requestDB(r).SessionUser(hashUserToken(cookie.Value))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			s.logger.Error("Failed to look up session", "error", err)
+		}
+		return nil
+	}
+	return user
+}
+
+// startSessionCleanup deletes expired sessions hourly
+func (s *ComplianceServer) startSessionCleanup() {
+	go func() {
+		ticker := time.NewTicker(sessionCleanupInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := s.db.DeleteExpiredSessions()
+			if err != nil {
+				s.logger.Error("Failed to clean up expired sessions", "error", err)
+			} else if count > 0 {
+				s.logger.Info("Cleaned up expired sessions", "count", count)
+			}
+		}
+	}()
+}
+
+// CreateSession stores a session for a user, keyed by its token hash
+func (d *Database) CreateSession(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := d.db.Exec(safesql.New(`
+		INSERT INTO sessions (token_hash, user_id, expires_at)
+		VALUES ($1, $2, $3)
+	`, tokenHash, userID, expiresAt.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// SessionUser returns the user of an unexpired session, or sql.ErrNoRows
+// when there is none
+func (d *Database) SessionUser(tokenHash string) (*User, error) {
+	var username string
+	err := d.db.QueryRow(safesql.New(`
+		SELECT u.username FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = $1 AND s.expires_at > CURRENT_TIMESTAMP
+	`, tokenHash)).Scan(&username)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetUser(username)
+}
+
+// DeleteSession ends a session
+func (d *Database) DeleteSession(tokenHash string) error {
+	if _, err := d.db.Exec(safesql.New(`DELETE FROM sessions WHERE token_hash = $1`, tokenHash)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes expired sessions and returns how many
+func (d *Database) DeleteExpiredSessions() (int64, error) {
+	result, err := d.db.Exec(safesql.New(`DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestForgedSessionCookie tests that a cookie naming a user is not a session
+func TestForgedSessionCookie(t *testing.T) {
+	s := newTestServer()
+	s.config().Auth.Enabled, s.config().Auth.RequireKey = true, true
+	handler := s.routeHandler()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"dashboard page", "/dashboard", http.StatusSeeOther},
+		{"api", "/api/v1/clients", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.AddCookie(&http.Cookie{Name: "session_user", Value: "admin"})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// TestSQLIsParameterized tests that every statement of the server is a
+// safesql.Query: nothing calls database/sql with text or formats SQL with fmt
+func TestSQLIsParameterized(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type-checks the package from source")
+	}
+	findings, err := safesql.Check(".")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	for _, f := range findings {
+		t.Error(f)
+	}
+}
@@ -12,7 +12,8 @@ let autoRefreshInterval = null;
 async function initDashboard() {
     // Check if user is authenticated (JWT or session)
     const isJWTAuth = window.authClient && window.authClient.isAuthenticated();
-    const hasSessionCookie = document.cookie.split('; ').some(row => row.startsWith('session_user='));
+    // The session cookie is HttpOnly; the role cookie is set alongside it
+    const hasSessionCookie = document.cookie.split('; ').some(row => row.startsWith('session_role='));
 
     if (!isJWTAuth && !hasSessionCookie) {
         // Not authenticated, redirect to login
@@ -112,12 +113,6 @@ function getUserDisplayName() {
         return user?.username || 'User';
     }
 
-    // Try session cookie
-    const sessionCookie = document.cookie.split('; ').find(row => row.startsWith('session_user='));
-    if (sessionCookie) {
-        return sessionCookie.split('=')[1] || 'User';
-    }
-
     return 'User';
 }
 
@@ -0,0 +1,79 @@
+/**
+ * Timestamp formatting for dashboard pages
+ *
+ * The API returns timestamps as RFC 3339 in UTC. Pages render them in the
+ * viewer's display time zone and locale, which the server puts in the
+ * display-timezone and display-locale meta tags (empty: the browser's own).
+ */
+(function () {
+    function meta(name) {
+        const el = document.querySelector(`meta[name="${name}"]`);
+        return el && el.content ? el.content : undefined;
+    }
+
+    // Read on first use: the server adds the meta tags at the end of <head>
+    let settings = null;
+    function display() {
+        if (!settings) {
+            settings = { timeZone: meta('display-timezone'), locale: meta('display-locale') };
+        }
+        return settings;
+    }
+
+    function parse(value) {
+        if (!value) return null;
+        const date = value instanceof Date ? value : new Date(value);
+        return isNaN(date.getTime()) ? null : date;
+    }
+
+    function format(value, options, fallback) {
+        const date = parse(value);
+        if (!date) return fallback === undefined ? '' : fallback;
+        const { timeZone, locale } = display();
+        try {
+            return new Intl.DateTimeFormat(locale, { timeZone, ...options }).format(date);
+        } catch (error) {
+            // Unknown zone or locale in this browser: fall back to its own
+            return new Intl.DateTimeFormat(undefined, options).format(date);
+        }
+    }
+
+    const DisplayTime = {
+        /** Date and time with the zone name, e.g. "15 Oct 2026, 02:00 CEST" */
+        dateTime(value, fallback) {
+            const text = format(value, { dateStyle: 'medium', timeStyle: 'short' }, fallback);
+            return parse(value) ? `${text} ${this.zoneName(value)}`.trim() : text;
+        },
+
+        /** Date only, e.g. "15 Oct 2026" */
+        date(value, fallback) {
+            return format(value, { dateStyle: 'medium' }, fallback);
+        },
+
+        /** Short month and day, e.g. "Oct 15" */
+        shortDate(value, fallback) {
+            return format(value, { month: 'short', day: 'numeric' }, fallback);
+        },
+
+        /** Abbreviated name of the display zone at a time, e.g. "CEST" or "UTC" */
+        zoneName(value) {
+            const date = parse(value) || new Date();
+            const { timeZone, locale } = display();
+            try {
+                const parts = new Intl.DateTimeFormat(locale, { timeZone, timeZoneName: 'short' }).formatToParts(date);
+                const zone = parts.find(part => part.type === 'timeZoneName');
+                return zone ? zone.value : '';
+            } catch (error) {
+                return '';
+            }
+        },
+
+        /** Full ISO timestamp in UTC, for tooltips */
+        iso(value) {
+            const date = parse(value);
+            return date ? date.toISOString() : '';
+        }
+    };
+
+    window.DisplayTime = DisplayTime;
+})();
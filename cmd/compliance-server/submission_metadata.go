@@ -0,0 +1,340 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Metadata field types
+const (
+	metadataTypeString  = "string"
+	metadataTypeInteger = "integer"
+	metadataTypeBoolean = "boolean"
+	metadataTypeEnum    = "enum"
+)
+
+// metadataDefaultMaxLength limits string values of fields without max_length
+const metadataDefaultMaxLength = 256
+
+// metadataMaxFields limits the fields metadata.fields may define
+const metadataMaxFields = 32
+
+// metadataNamePattern is the form of field names. Viper lowercases keys, so
+// names in client configurations are lowercase too.
+var metadataNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// validate checks metadata.fields
+func (m MetadataSettings) validate() error {
+	if len(m.Fields) > metadataMaxFields {
+		return fmt.Errorf("metadata.fields may define at most %d fields", metadataMaxFields)
+	}
+	seen := make(map[string]bool)
+	for i, field := range m.Fields {
+		if !metadataNamePattern.MatchString(field.Name) {
+			return fmt.Errorf("metadata.fields[%d].name must be lowercase letters, digits and underscores, starting with a letter", i)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("metadata.fields[%d]: %s is defined twice", i, field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case "", metadataTypeString:
+			if field.MaxLength < 0 || field.MaxLength > 4096 {
+				return fmt.Errorf("metadata.fields[%d].max_length must be between 0 and 4096", i)
+			}
+			if field.Pattern != "" {
+				if _, err := regexp.Compile(field.Pattern); err != nil {
+					return fmt.Errorf("metadata.fields[%d].pattern: %w", i, err)
+				}
+			}
+		case metadataTypeEnum:
+			if len(field.Values) == 0 {
+				return fmt.Errorf("metadata.fields[%d].values must list the allowed values of an enum", i)
+			}
+		case metadataTypeInteger, metadataTypeBoolean:
+		default:
+			return fmt.Errorf("metadata.fields[%d].type must be %s, %s, %s or %s", i,
+				metadataTypeString, metadataTypeInteger, metadataTypeBoolean, metadataTypeEnum)
+		}
+	}
+	return nil
+}
+
+// normalizeMetadata checks the metadata of a submission against the
+// defined fields and returns it with integers and booleans in canonical
+// form. Empty values count as absent; nil is returned for no metadata.
+func normalizeMetadata(fields []MetadataField, metadata map[string]string) (map[string]string, error) {
+	defined := make(map[string]MetadataField, len(fields))
+	for _, field := range fields {
+		defined[field.Name] = field
+	}
+
+	normalized := make(map[string]string)
+	for name, value := range metadata {
+		field, ok := defined[name]
+		if !ok {
+			return nil, fmt.Errorf("metadata field %q is not defined on this server", name)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		switch field.Type {
+		case metadataTypeInteger:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("metadata field %q must be an integer", name)
+			}
+			value = strconv.FormatInt(n, 10)
+		case metadataTypeBoolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("metadata field %q must be true or false", name)
+			}
+			value = strconv.FormatBool(b)
+		case metadataTypeEnum:
+			if !slices.Contains(field.Values, value) {
+				return nil, fmt.Errorf("metadata field %q must be one of %s", name, strings.Join(field.Values, ", "))
+			}
+		default:
+			maxLength := field.MaxLength
+			if maxLength == 0 {
+				maxLength = metadataDefaultMaxLength
+			}
+			if len(value) > maxLength {
+				return nil, fmt.Errorf("metadata field %q is longer than %d bytes", name, maxLength)
+			}
+			// The pattern was checked when the configuration was loaded
+			if field.Pattern != "" && !regexp.MustCompile(`^(?:`+field.Pattern+`)$`).MatchString(value) {
+				return nil, fmt.Errorf("metadata field %q does not match %s", name, field.Pattern)
+			}
+		}
+		normalized[name] = value
+	}
+
+	for _, field := range fields {
+		if _, ok := normalized[field.Name]; field.Required && !ok {
+			return nil, fmt.Errorf("metadata field %q is required", field.Name)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// encodeMetadata returns the metadata column of a submission
+func encodeMetadata(metadata map[string]string) (sql.NullString, error) {
+	if len(metadata) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// decodeMetadata reads the metadata column of a submission
+func decodeMetadata(column sql.NullString) (map[string]string, error) {
+	if !column.Valid || column.String == "" {
+		return nil, nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(column.String), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// formatMetadata writes metadata as name=value pairs sorted by name, for CSV
+func formatMetadata(metadata map[string]string) string {
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + metadata[name]
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// handleMetadataSchema lists the metadata fields submissions may carry
+// (GET /api/v1/metadata/schema)
+func (s *ComplianceServer) handleMetadataSchema(w http.ResponseWriter, r *http.Request) {
+	response := api.MetadataSchemaResponse{Fields: []api.MetadataField{}}
+	for _, field := range s.config().Metadata.Fields {
+		if field.Type == "" {
+			field.Type = metadataTypeString
+		}
+		if field.Type == metadataTypeString && field.MaxLength == 0 {
+			field.MaxLength = metadataDefaultMaxLength
+		}
+		response.Fields = append(response.Fields, api.MetadataField{
+			Name:        field.Name,
+			Description: field.Description,
+			Type:        field.Type,
+			Required:    field.Required,
+			Pattern:     field.Pattern,
+			Values:      field.Values,
+			MaxLength:   field.MaxLength,
+		})
+	}
+
+	s.respond(w, r, response, nil)
+}
+
+// submissionSearch is the filter of GET /api/v1/submissions
+type submissionSearch struct {
+	ClientID   string
+	ReportType string
+	Metadata   map[string]string // Values the submissions' metadata must hold
+	Since      time.Time
+	Limit      int
+}
+
+// parseSubmissionSearch reads the filter of GET /api/v1/submissions.
+// metadata.<name>=<value> parameters match metadata values exactly.
+func parseSubmissionSearch(query url.Values) (submissionSearch, error) {
+	search := submissionSearch{
+		ClientID:   query.Get("client_id"),
+		ReportType: query.Get("report_type"),
+		Metadata:   make(map[string]string),
+		Limit:      100,
+	}
+
+	for param, values := range query {
+		name, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if !metadataNamePattern.MatchString(name) {
+			return search, fmt.Errorf("invalid metadata field name %q", name)
+		}
+		if len(values) > 1 {
+			return search, fmt.Errorf("%s may be given once", param)
+		}
+		search.Metadata[name] = values[0]
+	}
+
+	if v := query.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return search, fmt.Errorf("since must be an RFC 3339 time such as 2026-10-01T00:00:00Z")
+		}
+		search.Since = since.UTC()
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			return search, fmt.Errorf("limit must be between 1 and 1000")
+		}
+		search.Limit = limit
+	}
+	return search, nil
+}
+
+// handleSearchSubmissions lists submissions newest first, filtered by
+// client, report type, time and metadata values (GET /api/v1/submissions)
+func (s *ComplianceServer) handleSearchSubmissions(w http.ResponseWriter, r *http.Request) {
+	search, err := parseSubmissionSearch(r.URL.Query())
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	submissions, err := s.scopedDB(r).SearchSubmissions(search)
+	if err != nil {
+		s.logger.Error("Failed to search submissions", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to search submissions")
+		return
+	}
+
+	s.respond(w, r, submissions, submissionsTable(submissions))
+}
+
+// SearchSubmissions returns the submissions matching search, newest first
+func (d *Database) SearchSubmissions(search submissionSearch) ([]api.SubmissionSummary, error) {
+	query := safesql.New(`
+		SELECT submission_id, client_id, hostname, timestamp, report_type,
+		       overall_status, total_checks, passed_checks, failed_checks, during_maintenance, summary_only, metadata
+		FROM submissions
+		WHERE `).
+		AppendQuery(d.scopeCondition(safesql.New("client_id")))
+	if search.ClientID != "" {
+		query = query.Append(" AND client_id = $1", search.ClientID)
+	}
+	if search.ReportType != "" {
+		query = query.Append(" AND report_type = $1", search.ReportType)
+	}
+	if !search.Since.IsZero() {
+		query = query.Append(" AND timestamp >= $1", search.Since.Format(time.RFC3339))
+	}
+	if len(search.Metadata) > 0 {
+		// Containment is answered from the GIN index on metadata
+		filter, err := json.Marshal(search.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		query = query.Append(" AND metadata @> $1::jsonb", string(filter))
+	}
+	query = query.Append(" ORDER BY timestamp DESC, submission_id LIMIT $1", search.Limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search submissions: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []api.SubmissionSummary{}
+	for rows.Next() {
+		sub, err := scanSubmissionSummary(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, *sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read submissions: %w", err)
+	}
+	return submissions, nil
+}
+
+// scanSubmissionSummary reads a submission summary row: submission_id,
+// client_id, hostname, timestamp, report_type, overall_status, total_checks,
+// passed_checks, failed_checks, during_maintenance, summary_only, metadata
+func scanSubmissionSummary(scan func(...any) error) (*api.SubmissionSummary, error) {
+	var sub api.SubmissionSummary
+	var timestampStr string
+	var metadata sql.NullString
+	err := scan(&sub.SubmissionID, &sub.ClientID, &sub.Hostname, &timestampStr, &sub.ReportType,
+		&sub.OverallStatus, &sub.TotalChecks, &sub.PassedChecks, &sub.FailedChecks,
+		&sub.DuringMaintenance, &sub.SummaryOnly, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan submission: %w", err)
+	}
+	if sub.Timestamp, err = parseStoredTime(timestampStr); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp of %s: %w", sub.SubmissionID, err)
+	}
+	if sub.Metadata, err = decodeMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("submission %s: %w", sub.SubmissionID, err)
+	}
+	return &sub, nil
+}
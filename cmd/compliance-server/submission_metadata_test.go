@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// TestNormalizeMetadata tests that metadata is checked against the defined
+// fields and put in canonical form
+func TestNormalizeMetadata(t *testing.T) {
+	fields := []MetadataField{
+		{Name: "ticket_id", Pattern: "(INC|CHG)[0-9]{6}"},
+		{Name: "image_build", Required: true, MaxLength: 8},
+		{Name: "ring", Type: metadataTypeEnum, Values: []string{"pilot", "broad"}},
+		{Name: "attempt", Type: metadataTypeInteger},
+		{Name: "gold_image", Type: metadataTypeBoolean},
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"valid", map[string]string{"ticket_id": "INC000123", "image_build": " 2026.10 ", "ring": "pilot", "attempt": "007", "gold_image": "TRUE"},
+			map[string]string{"ticket_id": "INC000123", "image_build": "2026.10", "ring": "pilot", "attempt": "7", "gold_image": "true"}, false},
+		{"empty values are dropped", map[string]string{"image_build": "b1", "ticket_id": " "}, map[string]string{"image_build": "b1"}, false},
+		{"required missing", map[string]string{"ticket_id": "INC000123"}, nil, true},
+		{"required empty", map[string]string{"image_build": ""}, nil, true},
+		{"undefined field", map[string]string{"image_build": "b1", "owner": "jdoe"}, nil, true},
+		{"pattern matches in full only", map[string]string{"image_build": "b1", "ticket_id": "xINC000123"}, nil, true},
+		{"too long", map[string]string{"image_build": "123456789"}, nil, true},
+		{"not in enum", map[string]string{"image_build": "b1", "ring": "canary"}, nil, true},
+		{"not an integer", map[string]string{"image_build": "b1", "attempt": "two"}, nil, true},
+		{"not a boolean", map[string]string{"image_build": "b1", "gold_image": "maybe"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeMetadata(fields, tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got, err := normalizeMetadata(nil, nil); got != nil || err != nil {
+		t.Errorf("normalizeMetadata(nil, nil) = %v, %v; want nil, nil", got, err)
+	}
+	if _, err := normalizeMetadata(nil, map[string]string{"ticket_id": "INC1"}); err == nil {
+		t.Error("metadata accepted by a server defining no fields")
+	}
+}
+
+// TestParseSubmissionSearch tests the metadata.<name> filter parameters
+func TestParseSubmissionSearch(t *testing.T) {
+	search, err := parseSubmissionSearch(url.Values{
+		"metadata.ticket_id": {"INC000123"},
+		"report_type":        {"NIST 800-171"},
+		"limit":              {"50"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if search.Metadata["ticket_id"] != "INC000123" || search.ReportType != "NIST 800-171" || search.Limit != 50 {
+		t.Errorf("parseSubmissionSearch() = %+v", search)
+	}
+
+	for _, query := range []url.Values{
+		{"metadata.Ticket": {"x"}},
+		{"metadata.ticket": {"a", "b"}},
+		{"since": {"yesterday"}},
+		{"limit": {"0"}},
+	} {
+		if _, err := parseSubmissionSearch(query); err == nil {
+			t.Errorf("parseSubmissionSearch(%v) accepted", query)
+		}
+	}
+}
+
+// TestFormatMetadata tests the CSV form of metadata
+func TestFormatMetadata(t *testing.T) {
+	got := formatMetadata(map[string]string{"ticket_id": "INC000123", "image_build": "2026.10"})
+	if want := "image_build=2026.10; ticket_id=INC000123"; got != want {
+		t.Errorf("formatMetadata() = %q, want %q", got, want)
+	}
+	if got := formatMetadata(nil); got != "" {
+		t.Errorf("formatMetadata(nil) = %q", got)
+	}
+}
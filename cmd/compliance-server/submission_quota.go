@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+)
+
+// Alerts raised for clients that submit too often or too much
+// (submission_quota). A flood of submissions is more often a broken
+// schedule or a restart loop than an attack, but either way it needs a look.
+const (
+	alertTypeSubmissionQuota   = "submission_quota"
+	alertTypeSubmissionAnomaly = "submission_anomaly"
+)
+
+// quotaCounts are what the submission quotas are checked against: a
+// client's submissions of the last hour and day, how long until the oldest
+// of each leaves its window, and the check count of its previous submission
+// of the report type (0 without one)
+type quotaCounts struct {
+	LastHour       int
+	LastDay        int
+	HourFreesIn    time.Duration
+	DayFreesIn     time.Duration
+	PreviousChecks int
+}
+
+// quotaBreach is a submission quota a client has used up
+type quotaBreach struct {
+	Reason     string // hourly_quota or daily_quota
+	Setting    string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+// QuotaCounts counts a client's stored submissions for the submission
+// quotas and reads the check count of its previous submission of reportType
+func (d *Database) QuotaCounts(clientID, reportType string) (quotaCounts, error) {
+	const query = `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'),
+			COUNT(*),
+			COALESCE(EXTRACT(EPOCH FROM MIN(created_at) FILTER (WHERE created_at > CURRENT_TIMESTAMP - INTERVAL '1 hour')
+				+ INTERVAL '1 hour' - CURRENT_TIMESTAMP), 0),
+			COALESCE(EXTRACT(EPOCH FROM MIN(created_at) + INTERVAL '24 hours' - CURRENT_TIMESTAMP), 0),
+			COALESCE((SELECT total_checks FROM submissions
+				WHERE client_id = $1 AND report_type = $2
+				ORDER BY created_at DESC LIMIT 1), 0)
+		FROM submissions
+		WHERE client_id = $1 AND created_at > CURRENT_TIMESTAMP - INTERVAL '24 hours'
+	`
+
+	var counts quotaCounts
+	var hourSeconds, daySeconds float64
+	err := d.db.QueryRow(safesql.New(query, clientID, reportType)).Scan(
+		&counts.LastHour, &counts.LastDay, &hourSeconds, &daySeconds, &counts.PreviousChecks)
+	if err != nil {
+		return quotaCounts{}, fmt.Errorf("failed to count client submissions: %w", err)
+	}
+	counts.HourFreesIn = time.Duration(hourSeconds * float64(time.Second))
+	counts.DayFreesIn = time.Duration(daySeconds * float64(time.Second))
+	return counts, nil
+}
+
+// checkQuota returns the quota a client with counts has used up, the daily
+// one first as it is the longer wait, or nil while it may submit
+func checkQuota(settings SubmissionQuotaSettings, counts quotaCounts) *quotaBreach {
+	if settings.PerDay > 0 && counts.LastDay >= settings.PerDay {
+		return &quotaBreach{Reason: "daily_quota", Setting: "per_day", Limit: settings.PerDay, RetryAfter: counts.DayFreesIn}
+	}
+	if settings.PerHour > 0 && counts.LastHour >= settings.PerHour {
+		return &quotaBreach{Reason: "hourly_quota", Setting: "per_hour", Limit: settings.PerHour, RetryAfter: counts.HourFreesIn}
+	}
+	return nil
+}
+
+// checkCountAnomaly reports whether a submission of checks checks has at
+// least factor times those of the client's previous one. Without a previous
+// submission, or with factor 0, nothing is anomalous.
+func checkCountAnomaly(factor float64, previous, checks int) bool {
+	return factor > 0 && previous > 0 && float64(checks) >= factor*float64(previous)
+}
+
+// enforceSubmissionQuota applies the submission quotas (submission_quota)
+// before a submission is stored. A client over a quota is answered 429 Too
+// Many Requests with Retry-After and flagged with an alert, and it returns
+// true. A submission with far more checks than the client's last is stored
+// but flagged.
+func (s *ComplianceServer) enforceSubmissionQuota(w http.ResponseWriter, submission *api.ComplianceSubmission) bool {
+	settings := s.config().SubmissionQuota
+	if !settings.Enabled {
+		return false
+	}
+
+	counts, err := s.db.QuotaCounts(submission.ClientID, submission.ReportType)
+	if err != nil {
+		// Losing compliance data is worse than an unchecked submission
+		s.logger.Warn("Failed to count client submissions, skipping the quota check",
+			"client_id", submission.ClientID, "error", err)
+		return false
+	}
+	now := time.Now()
+
+	if breach := checkQuota(settings, counts); breach != nil {
+		serverMetrics.submissionFlags.Inc(breach.Reason)
+		s.logger.Warn("Submission quota exceeded",
+			"client_id", submission.ClientID,
+			"hostname", submission.Hostname,
+			"quota", breach.Reason,
+			"limit", breach.Limit,
+		)
+		dedupeKey := fmt.Sprintf("%s:%s:%s", alertTypeSubmissionQuota, submission.ClientID, now.UTC().Format("2006-01-02"))
+		if _, err := s.raiseAlert(quotaAlert(submission, breach, now), dedupeKey); err != nil {
+			s.logger.Error("Failed to create alert", "error", err, "type", alertTypeSubmissionQuota, "client_id", submission.ClientID)
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(breach.RetryAfter.Seconds())))))
+		s.sendError(w, http.StatusTooManyRequests, fmt.Sprintf(
+			"Submission quota exceeded: at most %d submissions per client allowed (submission_quota.%s)", breach.Limit, breach.Setting))
+		return true
+	}
+
+	checks := submission.Compliance.TotalChecks
+	if checkCountAnomaly(settings.AnomalyFactor, counts.PreviousChecks, checks) {
+		serverMetrics.submissionFlags.Inc("check_count")
+		s.logger.Warn("Submission check count anomaly",
+			"submission_id", submission.SubmissionID,
+			"client_id", submission.ClientID,
+			"report_type", submission.ReportType,
+			"checks", checks,
+			"previous_checks", counts.PreviousChecks,
+		)
+		dedupeKey := fmt.Sprintf("%s:%s", alertTypeSubmissionAnomaly, submission.SubmissionID)
+		if _, err := s.raiseAlert(anomalyAlert(submission, counts.PreviousChecks, now), dedupeKey); err != nil {
+			s.logger.Error("Failed to create alert", "error", err, "type", alertTypeSubmissionAnomaly, "client_id", submission.ClientID)
+		}
+	}
+	return false
+}
+
+// quotaAlert describes a client refused for submitting over a quota
+func quotaAlert(submission *api.ComplianceSubmission, breach *quotaBreach, now time.Time) *api.Alert {
+	window := "hour"
+	if breach.Reason == "daily_quota" {
+		window = "24 hours"
+	}
+	return &api.Alert{
+		Timestamp:  now,
+		Severity:   "warning",
+		Type:       alertTypeSubmissionQuota,
+		ClientID:   submission.ClientID,
+		Hostname:   submission.Hostname,
+		ReportType: submission.ReportType,
+		Message: fmt.Sprintf("%s reached its quota of %d submissions in the last %s and further submissions are refused; check its schedule, or whether the agent is compromised",
+			submission.Hostname, breach.Limit, window),
+	}
+}
+
+// anomalyAlert describes a submission with far more checks than the
+// client's previous one of the report type
+func anomalyAlert(submission *api.ComplianceSubmission, previous int, now time.Time) *api.Alert {
+	return &api.Alert{
+		Timestamp:  now,
+		Severity:   "warning",
+		Type:       alertTypeSubmissionAnomaly,
+		ClientID:   submission.ClientID,
+		Hostname:   submission.Hostname,
+		ReportType: submission.ReportType,
+		Message: fmt.Sprintf("%s submitted %s with %d checks, up from %d in its previous submission (submission %s)",
+			submission.Hostname, submission.ReportType, submission.Compliance.TotalChecks, previous, submission.SubmissionID),
+	}
+}
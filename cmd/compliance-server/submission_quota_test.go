@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// TestCheckQuota tests that a client is refused once either quota is used
+// up, waiting for the longer window first
+func TestCheckQuota(t *testing.T) {
+	settings := SubmissionQuotaSettings{Enabled: true, PerHour: 10, PerDay: 50}
+	counts := quotaCounts{HourFreesIn: 20 * time.Minute, DayFreesIn: 5 * time.Hour}
+
+	tests := []struct {
+		name       string
+		hour, day  int
+		settings   SubmissionQuotaSettings
+		wantReason string
+		wantRetry  time.Duration
+	}{
+		{"under both", 9, 49, settings, "", 0},
+		{"hourly used up", 10, 12, settings, "hourly_quota", 20 * time.Minute},
+		{"daily used up", 3, 50, settings, "daily_quota", 5 * time.Hour},
+		{"both used up", 10, 50, settings, "daily_quota", 5 * time.Hour},
+		{"unlimited", 1000, 1000, SubmissionQuotaSettings{Enabled: true}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts.LastHour, counts.LastDay = tt.hour, tt.day
+			breach := checkQuota(tt.settings, counts)
+			if tt.wantReason == "" {
+				if breach != nil {
+					t.Errorf("checkQuota() = %+v, want no breach", breach)
+				}
+				return
+			}
+			if breach == nil || breach.Reason != tt.wantReason || breach.RetryAfter != tt.wantRetry {
+				t.Errorf("checkQuota() = %+v, want %s retrying after %v", breach, tt.wantReason, tt.wantRetry)
+			}
+		})
+	}
+}
+
+// TestCheckCountAnomaly tests that only a jump of at least the factor over a
+// previous submission is flagged
+func TestCheckCountAnomaly(t *testing.T) {
+	tests := []struct {
+		factor           float64
+		previous, checks int
+		want             bool
+	}{
+		{10, 50, 500, true},
+		{10, 50, 499, false},
+		{10, 0, 5000, false},
+		{0, 50, 5000, false},
+		{2.5, 4, 10, true},
+	}
+
+	for _, tt := range tests {
+		if got := checkCountAnomaly(tt.factor, tt.previous, tt.checks); got != tt.want {
+			t.Errorf("checkCountAnomaly(%v, %d, %d) = %v, want %v", tt.factor, tt.previous, tt.checks, got, tt.want)
+		}
+	}
+}
+
+// TestSubmissionQuotaAlerts tests the alerts flagging a client over its
+// quota and an anomalous submission
+func TestSubmissionQuotaAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 15, 4, 5, 0, 0, time.UTC)
+	submission := &api.ComplianceSubmission{
+		SubmissionID: "sub-1",
+		ClientID:     "client-123",
+		Hostname:     "WS-42",
+		ReportType:   "NIST 800-171",
+		Compliance:   api.ComplianceData{TotalChecks: 900},
+	}
+
+	alert := quotaAlert(submission, &quotaBreach{Reason: "daily_quota", Limit: 500}, now)
+	if alert.Type != alertTypeSubmissionQuota || alert.ClientID != "client-123" || !alert.Timestamp.Equal(now) {
+		t.Errorf("quota alert = %+v", alert)
+	}
+	if !strings.Contains(alert.Message, "WS-42 reached its quota of 500 submissions in the last 24 hours") {
+		t.Errorf("quota alert message = %q", alert.Message)
+	}
+
+	alert = anomalyAlert(submission, 60, now)
+	if alert.Type != alertTypeSubmissionAnomaly || alert.ReportType != "NIST 800-171" {
+		t.Errorf("anomaly alert = %+v", alert)
+	}
+	if want := "WS-42 submitted NIST 800-171 with 900 checks, up from 60 in its previous submission (submission sub-1)"; alert.Message != want {
+		t.Errorf("anomaly alert message = %q, want %q", alert.Message, want)
+	}
+}
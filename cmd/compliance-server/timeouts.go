@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// requestDiagnostics records the database work of a request, reported in
+// the 504 sent when the request times out
+type requestDiagnostics struct {
+	mu         sync.Mutex
+	statements int
+	dbTime     time.Duration // Time in finished statements
+	running    string        // Op of the statement running, if any
+	since      time.Time     // When the running statement started
+}
+
+type requestDiagnosticsKey struct{}
+
+// requestDiagnosticsFrom returns the diagnostics of the request ctx belongs
+// to, or nil outside a request
+func requestDiagnosticsFrom(ctx context.Context) *requestDiagnostics {
+	diagnostics, _ := ctx.Value(requestDiagnosticsKey{}).(*requestDiagnostics)
+	return diagnostics
+}
+
+// start records that a statement ("query" or "exec") started
+func (d *requestDiagnostics) start(op string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements++
+	d.running, d.since = op, time.Now()
+}
+
+// finish records that the running statement returned
+func (d *requestDiagnostics) finish() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dbTime += time.Since(d.since)
+	d.running = ""
+}
+
+// snapshot returns the statements run, the time spent in the database
+// (counting the running statement so far) and the op of the running
+// statement
+func (d *requestDiagnostics) snapshot() (int, time.Duration, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dbTime := d.dbTime
+	if d.running != "" {
+		dbTime += time.Since(d.since)
+	}
+	return d.statements, dbTime, d.running
+}
+
+// timeoutMiddleware gives the handler server.request_timeout to answer, or
+// server.long_request_timeout when long is set. The request's context is
+// canceled at the deadline, which stops its database statements (see
+// requestDB), and the client gets a 504 with diagnostics at once, whether
+// or not the handler has returned. The handler's response is buffered until
+// it returns; writes after the deadline fail with http.ErrHandlerTimeout.
+// A timeout of zero disables the middleware.
+func (s *ComplianceServer) timeoutMiddleware(route string, long bool) middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			settings := s.config().Server
+			timeout := settings.RequestTimeout
+			if long {
+				timeout = settings.LongRequestTimeout
+			}
+			if timeout <= 0 {
+				next(w, r)
+				return
+			}
+			// Leave time to send the response after the handler's deadline,
+			// which may be past the server's write timeout
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second))
+
+			diagnostics := &requestDiagnostics{}
+			ctx, cancel := context.WithTimeout(context.WithValue(r.Context(), requestDiagnosticsKey{}, diagnostics), timeout)
+			defer cancel()
+
+			// Start from the headers already set, such as the request ID
+			tw := &timeoutWriter{header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			start := time.Now()
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+						return
+					}
+					close(done)
+				}()
+				next(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				for key, values := range tw.header {
+					w.Header()[key] = values
+				}
+				if tw.code == 0 {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+				if r.Context().Err() != nil {
+					// The client went away; there is no one to answer
+					return
+				}
+
+				elapsed := time.Since(start)
+				statements, dbTime, running := diagnostics.snapshot()
+				s.logger.Warn("Request timed out",
+					"route", route,
+					"timeout", timeout,
+					"elapsed", elapsed,
+					"db_statements", statements,
+					"db_time", dbTime,
+					"canceled_during", running,
+					"request_id", requestID(r),
+				)
+				w.Header().Set("Content-Type", problemContentType)
+				w.WriteHeader(http.StatusGatewayTimeout)
+				json.NewEncoder(w).Encode(api.TimeoutResponse{
+					Problem:        newProblem(w, http.StatusGatewayTimeout, "The request took longer than "+timeout.String()+" and was canceled"),
+					Route:          route,
+					TimeoutSeconds: timeout.Seconds(),
+					ElapsedSeconds: elapsed.Seconds(),
+					DBStatements:   statements,
+					DBSeconds:      dbTime.Seconds(),
+					CanceledDuring: running,
+				})
+			}
+		}
+	}
+}
+
+// timeoutWriter buffers a handler's response until the handler returns, and
+// refuses writes once the request has timed out
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
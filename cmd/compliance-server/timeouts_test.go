@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// newTimeoutServer returns a test server giving handlers timeout
+func newTimeoutServer(timeout time.Duration) *ComplianceServer {
+	s := newTestServer()
+	s.updateConfig(func(c *ServerConfig) error {
+		c.Server.RequestTimeout = timeout
+		c.Server.LongRequestTimeout = 10 * timeout
+		return nil
+	})
+	return s
+}
+
+// TestTimeoutMiddlewarePassesResponse tests that a handler answering in time
+// is sent as written
+func TestTimeoutMiddlewarePassesResponse(t *testing.T) {
+	s := newTimeoutServer(time.Second)
+	h := chain(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("made"))
+	}, s.timeoutMiddleware("POST /things", false))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodPost, "/things", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "made" || rec.Header().Get("X-Test") != "yes" {
+		t.Errorf("response = %d %q (X-Test %q), want 201 \"made\" (yes)", rec.Code, rec.Body.String(), rec.Header().Get("X-Test"))
+	}
+}
+
+// TestTimeoutMiddlewareTimesOut tests that a handler still running at the
+// deadline is answered with a 504 describing the statement it was stopped
+// in, and that its later writes fail
+func TestTimeoutMiddlewareTimesOut(t *testing.T) {
+	s := newTimeoutServer(50 * time.Millisecond)
+	lateWrite := make(chan error, 1)
+	h := chain(func(w http.ResponseWriter, r *http.Request) {
+		diagnostics := requestDiagnosticsFrom(r.Context())
+		diagnostics.start("exec")
+		diagnostics.finish()
+		diagnostics.start("query")
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := w.Write([]byte("too late"))
+		lateWrite <- err
+	}, s.timeoutMiddleware("GET /slow", false))
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("answered after %v, want at the 50ms deadline", elapsed)
+	}
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", rec.Code)
+	}
+	var body api.TimeoutResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode 504 body: %v", err)
+	}
+	if body.Route != "GET /slow" || body.TimeoutSeconds != 0.05 || body.DBStatements != 2 || body.CanceledDuring != "query" {
+		t.Errorf("504 body = %+v, want route GET /slow, timeout 0.05, 2 statements, canceled during query", body)
+	}
+	if body.ElapsedSeconds < 0.05 || body.DBSeconds <= 0 {
+		t.Errorf("elapsed = %v, db = %v; want at least the timeout and some database time", body.ElapsedSeconds, body.DBSeconds)
+	}
+
+	if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("write after timeout error = %v, want http.ErrHandlerTimeout", err)
+	}
+}
+
+// TestTimeoutMiddlewareDisabled tests that a zero timeout leaves requests
+// without a deadline
+func TestTimeoutMiddlewareDisabled(t *testing.T) {
+	s := newTimeoutServer(0)
+	h := chain(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("request context has a deadline")
+		}
+	}, s.timeoutMiddleware("GET /x", false))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+}
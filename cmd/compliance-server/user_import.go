@@ -0,0 +1,400 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/safesql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// userTokenInvite is the purpose of the token an invited user sets
+	// their password with
+	userTokenInvite = "invite"
+
+	// maxImportUsers and maxImportSize bound a user import
+	maxImportUsers = 1000
+	maxImportSize  = 1 << 20
+
+	// minPasswordLength is the shortest password an invited user may choose
+	minPasswordLength = 8
+)
+
+// importUsernamePattern is the usernames an import may create
+var importUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// importRoles are the roles an import may assign. Operators need a client
+// scope, which a CSV row cannot carry.
+var importRoles = map[string]bool{"admin": true, "viewer": true, "auditor": true}
+
+// userImportRow is one account of an imported CSV file
+type userImportRow struct {
+	line     int
+	username string
+	role     string
+	email    string
+	err      error // Why the row cannot be imported
+}
+
+// parseUserImport reads a CSV file with a header row naming the username,
+// role and email columns, in any order; other columns are ignored. Rows with
+// invalid values are returned with err set, so the rest still import.
+func parseUserImport(r io.Reader) ([]userImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"username", "role", "email"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV header has no %s column (need username, role, email)", name)
+		}
+	}
+
+	var rows []userImportRow
+	seen := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == maxImportUsers {
+			return nil, fmt.Errorf("an import can create at most %d users", maxImportUsers)
+		}
+
+		field := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := userImportRow{
+			line:     line,
+			username: field("username"),
+			role:     strings.ToLower(field("role")),
+			email:    field("email"),
+		}
+		if row.username == "" && row.role == "" && row.email == "" {
+			continue
+		}
+
+		switch {
+		case !importUsernamePattern.MatchString(row.username):
+			row.err = fmt.Errorf("invalid username %q (letters, digits and . _ @ - only)", row.username)
+		case !importRoles[row.role]:
+			row.err = fmt.Errorf("invalid role %q (admin, viewer or auditor; create operators individually)", row.role)
+		case !validEmail(row.email):
+			row.err = fmt.Errorf("invalid email address %q", row.email)
+		case seen[strings.ToLower(row.username)] != 0:
+			row.err = fmt.Errorf("duplicate of line %d", seen[strings.ToLower(row.username)])
+		default:
+			seen[strings.ToLower(row.username)] = line
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the CSV file has no users")
+	}
+	return rows, nil
+}
+
+// validEmail reports whether s is a bare email address
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// newUserToken returns a random one-time token for a link and the hash it
+// is stored as
+func newUserToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashUserToken(token), nil
+}
+
+// hashUserToken returns the stored form of a token. Tokens are random, so
+// a fast hash is enough.
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleImportUsers creates accounts from a CSV file with username, role and
+// email columns (POST /api/v1/users/import). Each account gets an unusable
+// password and an invitation, emailed when notifications are enabled,
+// whose link lets the user choose a password. Existing usernames are
+// skipped.
+func (s *ComplianceServer) handleImportUsers(w http.ResponseWriter, r *http.Request) {
+	rows, err := parseUserImport(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("The CSV file is larger than %d bytes", maxImportSize))
+			return
+		}
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := api.UserImportResponse{Status: "success", Users: []api.UserImportResult{}}
+	created := []string{}
+	for _, row := range rows {
+		result := s.importUser(row)
+		switch result.Status {
+		case "created":
+			resp.Created++
+			created = append(created, row.username)
+		case "skipped":
+			resp.Skipped++
+		default:
+			resp.Failed++
+		}
+		resp.Users = append(resp.Users, result)
+	}
+
+	s.logger.Info("Users imported", "created", resp.Created, "skipped", resp.Skipped, "failed", resp.Failed)
+	// Invitation links stay out of the audit trail
+	noteAdminChange(r, "", nil, map[string]interface{}{"created": created, "skipped": resp.Skipped, "failed": resp.Failed})
+
+	resp.Message = fmt.Sprintf("Created %d users, skipped %d existing, %d failed", resp.Created, resp.Skipped, resp.Failed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// importUser creates the account of one import row and sends its
+// invitation
+func (s *ComplianceServer) importUser(row userImportRow) api.UserImportResult {
+	result := api.UserImportResult{Line: row.line, Username: row.username, Status: "failed"}
+	if row.err != nil {
+		result.Error = row.err.Error()
+		return result
+	}
+
+	exists, err := s.db.UserExists(row.username)
+	if err != nil {
+		s.logger.Error("Failed to check user existence", "username", row.username, "error", err)
+		result.Error = "internal error"
+		return result
+	}
+	if exists {
+		result.Status = "skipped"
+		result.Error = "username already exists"
+		return result
+	}
+
+	// Nobody knows this password; the invitation replaces it
+	unusable, _, err := newUserToken()
+	if err != nil {
+		result.Error = "internal error"
+		return result
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(unusable), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		result.Error = "internal error"
+		return result
+	}
+	token, tokenHash, err := newUserToken()
+	if err != nil {
+		result.Error = "internal error"
+		return result
+	}
+
+	expiresAt := time.Now().Add(s.config().Auth.InviteTTL).UTC()
+	if err := s.db.CreateInvitedUser(row.username, string(passwordHash), row.role, row.email, tokenHash, expiresAt); err != nil {
+		s.logger.Error("Failed to create invited user", "username", row.username, "error", err)
+		result.Error = "failed to create user"
+		return result
+	}
+	result.Status = "created"
+	s.logger.Info("User invited", "username", row.username, "role", row.role)
+
+	inviteURL := s.dashboardURL("/accept-invite?token=" + url.QueryEscape(token))
+	if s.mailer == nil {
+		result.InviteURL = inviteURL
+		return result
+	}
+	subject, body, err := s.notificationEmail(notificationInvitation, "", map[string]string{
+		"Username":  row.username,
+		"Role":      row.role,
+		"ExpiresAt": expiresAt.Format("2 Jan 2006 15:04 MST"),
+		"InviteURL": inviteURL,
+	})
+	if err == nil {
+		err = s.mailer.send(row.email, subject, body)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to email invitation", "username", row.username, "error", err)
+		result.Error = "invitation email failed; pass on invite_url"
+		result.InviteURL = inviteURL
+		return result
+	}
+	result.Emailed = true
+	return result
+}
+
+// handleAcceptInvite sets the password of an invited user from the token
+// in their invitation (POST /api/v1/users/accept-invite). The token works
+// once and until it expires.
+func (s *ComplianceServer) handleAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var req api.TokenPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		s.sendError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+
+	username, err := s.requestDB(r).RedeemUserToken(userTokenInvite, hashUserToken(req.Token), string(passwordHash))
+	if err != nil {
+		if err.Error() == "invalid token" {
+			s.logger.Warn("Invalid invitation token", "remote_addr", r.RemoteAddr)
+			s.sendError(w, http.StatusBadRequest, "This invitation is invalid, used or expired; ask an administrator for a new one")
+			return
+		}
+		s.logger.Error("Failed to accept invitation", "error", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+
+	s.logger.Info("Invitation accepted", "username", username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.StatusResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Your password is set, %s.", username),
+	})
+}
+
+// CreateInvitedUser creates a user who must set a password with the token
+// stored as tokenHash before expiresAt
+func (d *Database) CreateInvitedUser(username, passwordHash, role, email, tokenHash string, expiresAt time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin user creation: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(safesql.New(`
+		INSERT INTO users (username, password_hash, role, email, must_change_password)
+		VALUES ($1, $2, $3, $4, true)
+		RETURNING id
+	`, username, passwordHash, role, email)).Scan(&userID)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	_, err = tx.Exec(safesql.New(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, tokenHash, userID, userTokenInvite, expiresAt.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user creation: %w", err)
+	}
+	d.logger.Info("User created", "username", username, "role", role)
+	return nil
+}
+
+// RedeemUserToken uses up an unexpired token of the given purpose and sets
+// its user's password, clearing must_change_password and any lockout and
+// ending the user's sessions and refresh tokens. It returns the username,
+// or the error "invalid token".
+func (d *Database) RedeemUserToken(purpose, tokenHash, passwordHash string) (string, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin token redemption: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(safesql.New(`
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, tokenHash, purpose)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("invalid token")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to redeem token: %w", err)
+	}
+
+	var username string
+	err = tx.QueryRow(safesql.New(`
+		UPDATE users SET password_hash = $1, must_change_password = false,
+			password_changed_at = CURRENT_TIMESTAMP, failed_login_attempts = 0, account_locked_until = NULL
+		WHERE id = $2
+		RETURNING username
+	`, passwordHash, userID)).Scan(&username)
+	if err != nil {
+		return "", fmt.Errorf("failed to set password: %w", err)
+	}
+
+	// Sessions started with the old password end
+	_, err = tx.Exec(safesql.New(`
+		UPDATE refresh_tokens SET revoked = true, revoked_at = CURRENT_TIMESTAMP, revoked_reason = 'password set with token'
+		WHERE user_id = $1 AND revoked = false
+	`, userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if _, err := tx.Exec(safesql.New(`DELETE FROM sessions WHERE user_id = $1`, userID)); err != nil {
+		return "", fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit token redemption: %w", err)
+	}
+	return username, nil
+}
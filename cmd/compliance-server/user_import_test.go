@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+// TestParseUserImport tests reading accounts from a CSV file, keeping
+// invalid rows with their error
+func TestParseUserImport(t *testing.T) {
+	csv := "\ufeffEmail, Username, Role, Department\n" +
+		"alice@example.com, alice, Auditor, Finance\n" +
+		"\n" +
+		"bob@example.com, bob, operator, IT\n" +
+		"carol, carol, viewer, IT\n" +
+		"Alice2@example.com, ALICE, viewer, IT\n" +
+		"dave@example.com, dave smith, viewer, IT\n"
+
+	rows, err := parseUserImport(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("parseUserImport() error = %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5 (blank lines skipped)", len(rows))
+	}
+	first := rows[0]
+	if first.err != nil || first.line != 2 || first.username != "alice" || first.role != "auditor" || first.email != "alice@example.com" {
+		t.Errorf("first row = %+v", first)
+	}
+	for i, want := range []string{"invalid role", "invalid email", "duplicate of line 2", "invalid username"} {
+		if row := rows[i+1]; row.err == nil || !strings.Contains(row.err.Error(), want) {
+			t.Errorf("line %d error = %v, want %q", row.line, row.err, want)
+		}
+	}
+}
+
+// TestParseUserImportRejectsFile tests files that import nothing
+func TestParseUserImportRejectsFile(t *testing.T) {
+	for name, csv := range map[string]string{
+		"empty":          "",
+		"header only":    "username,role,email\n",
+		"missing column": "username,role\nalice,viewer\n",
+		"unclosed quote": "username,role,email\n\"alice,viewer,alice@example.com\n",
+	} {
+		if _, err := parseUserImport(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: error = nil, want error", name)
+		}
+	}
+}
+
+// TestImportUserInvalidRow tests that invalid rows fail without touching
+// the database
+func TestImportUserInvalidRow(t *testing.T) {
+	rows, err := parseUserImport(strings.NewReader("username,role,email\neve,root,eve@example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := newTestServer().importUser(rows[0])
+	if result.Status != "failed" || result.Line != 2 || !strings.Contains(result.Error, "invalid role") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+// TestImportUsersBadFile tests that an unreadable file is refused as a whole
+func TestImportUsersBadFile(t *testing.T) {
+	s := newTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import", strings.NewReader("name,email\nalice,alice@example.com\n"))
+	rec := httptest.NewRecorder()
+	s.handleImportUsers(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "no username column") {
+		t.Errorf("status = %d, body %s; want 400 naming the missing column", rec.Code, rec.Body)
+	}
+}
+
+// TestAcceptInviteValidation tests requests refused before the token is
+// looked up
+func TestAcceptInviteValidation(t *testing.T) {
+	s := newTestServer()
+	for _, body := range []string{`{"password":"long enough"}`, `{"token":"abc","password":"short"}`, `not json`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/accept-invite", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		s.handleAcceptInvite(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+// TestUserToken tests that tokens are random and stored hashed
+func TestUserToken(t *testing.T) {
+	token, hash, err := newUserToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := newUserToken()
+	if token == other || len(token) != 43 {
+		t.Errorf("tokens %q and %q, want distinct 43-character tokens", token, other)
+	}
+	if hash == token || hash != hashUserToken(token) || len(hash) != 64 {
+		t.Errorf("hash = %q, want the SHA-256 of the token", hash)
+	}
+}
+
+// TestMailerSend tests the message sent to the SMTP server
+func TestMailerSend(t *testing.T) {
+	m, err := newMailer(NotificationSettings{
+		Enabled: true,
+		SMTP:    SMTPSettings{Host: "smtp.example.com", Port: 587, Username: "relay", Password: "secret", From: "Compliance <compliance@example.com>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotAuth smtp.Auth
+	var gotMsg string
+	m.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, string(msg)
+		return nil
+	}
+
+	if err := m.send("alice@example.com", "Welcome\r\nBcc: everyone@example.com", "Line one\nLine two\n"); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "compliance@example.com" || len(gotTo) != 1 || gotTo[0] != "alice@example.com" || gotAuth == nil {
+		t.Errorf("sent to %s from %s for %v (auth %v)", gotAddr, gotFrom, gotTo, gotAuth)
+	}
+	for _, want := range []string{
+		"From: \"Compliance\" <compliance@example.com>\r\n",
+		"To: <alice@example.com>\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nLine one\r\nLine two\r\n",
+	} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("message does not contain %q:\n%s", want, gotMsg)
+		}
+	}
+	if strings.Contains(gotMsg, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", gotMsg)
+	}
+
+	if err := m.send("not an address", "Welcome", "Hi"); err == nil {
+		t.Error("send() to an invalid address error = nil, want error")
+	}
+}
+
+// TestNewMailerDisabled tests that disabled notifications have no mailer
+func TestNewMailerDisabled(t *testing.T) {
+	if m, err := newMailer(NotificationSettings{}); m != nil || err != nil {
+		t.Errorf("newMailer() = %v, %v; want nil, nil", m, err)
+	}
+}
+
+// TestDashboardURL tests links for email and import responses
+func TestDashboardURL(t *testing.T) {
+	s := newTestServer()
+	if got := s.dashboardURL("/accept-invite?token=x"); got != "/accept-invite?token=x" {
+		t.Errorf("without base_url = %q, want the path", got)
+	}
+	s.config().Notifications.BaseURL = "https://compliance.example.com/"
+	if got := s.dashboardURL("/accept-invite?token=x"); got != "https://compliance.example.com/accept-invite?token=x" {
+		t.Errorf("with base_url = %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds each webhook request
+const webhookTimeout = 10 * time.Second
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the body under the
+// webhook's secret, as "sha256=<hex>", so receivers can check the sender
+const webhookSignatureHeader = "X-Compliance-Signature"
+
+// newWebhookConsumer returns the consumer posting events to a webhook. Any
+// answer but 2xx fails the delivery, which the outbox retries.
+func newWebhookConsumer(settings WebhookSettings) *eventConsumer {
+	client := &http.Client{Timeout: webhookTimeout}
+	return &eventConsumer{
+		name:   "webhook:" + settings.Name,
+		topics: topicSet(settings.Topics...),
+		handle: func(event busEvent) error {
+			body, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			req, err := http.NewRequest(http.MethodPost, settings.URL, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "compliance-server/"+version)
+			req.Header.Set("X-Compliance-Event", event.Topic)
+			req.Header.Set("X-Compliance-Event-ID", event.ID)
+			if settings.Secret != "" {
+				req.Header.Set(webhookSignatureHeader, signWebhook(settings.Secret, body))
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("webhook answered %s", resp.Status)
+			}
+			return nil
+		},
+	}
+}
+
+// signWebhook returns the signature header value of a webhook body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/policypack"
+)
+
+// packOptions are the flags of the pack command
+type packOptions struct {
+	name      string
+	version   string
+	publisher string
+	keyFile   string // Publisher key to sign with; empty writes an unsigned pack
+}
+
+// runPackCLI bundles report configurations into a policy pack in the output
+// directory, for import by a compliance server (POST
+// /api/v1/policies/import-url). Reports are validated before they are
+// packed; without report names every report is included. With a key file
+// the archive gets a detached signature (<pack>.zip.sig) and each report a
+// policy signature for clients, and the key is created on first use.
+func (app *App) runPackCLI(reportNames []string, opts packOptions) bool {
+	if opts.name == "" || opts.version == "" {
+		fmt.Fprintf(os.Stderr, "Error: pack requires -pack-name and -pack-version\n")
+		return false
+	}
+	// Both end up in the archive's file name
+	if label := opts.name + opts.version; strings.ContainsAny(label, `/\:*?"<>|`) || strings.Contains(label, "..") {
+		fmt.Fprintf(os.Stderr, "Error: Invalid pack name or version (must be usable in a file name)\n")
+		return false
+	}
+
+	if len(reportNames) == 0 || (len(reportNames) == 1 && strings.EqualFold(reportNames[0], "all")) {
+		reports, err := app.loadAvailableReports()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to load reports: %v\n", err)
+			return false
+		}
+		reportNames = nil
+		for _, report := range reports {
+			reportNames = append(reportNames, report.ConfigFile)
+		}
+	}
+	if len(reportNames) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No reports found in configs/reports/\n")
+		return false
+	}
+
+	var key ed25519.PrivateKey
+	if opts.keyFile != "" {
+		var created bool
+		var err error
+		key, created, err = loadOrCreatePublisherKey(opts.keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+		if created {
+			fmt.Printf("Created publisher key %s. Trust it on the server and clients with its public key:\n  %s\n\n",
+				opts.keyFile, base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+		}
+	}
+
+	manifest := policypack.Manifest{
+		Name:      opts.name,
+		Version:   opts.version,
+		Publisher: opts.publisher,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	files := make(map[string][]byte, len(reportNames))
+	for _, name := range reportNames {
+		if err := pkg.ValidateFilePath(name, []string{".json"}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid report name: %v\n", err)
+			return false
+		}
+		path := filepath.Join(app.reportsDir, name)
+		config, err := pkg.LoadRegistryConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to load report %s: %v\n", name, err)
+			return false
+		}
+		if err := pkg.ValidateConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Report %s failed validation: %v\n", name, err)
+			return false
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return false
+		}
+
+		report := policypack.Report{
+			File:     "reports/" + filepath.Base(name),
+			PolicyID: strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)),
+		}
+		if key != nil {
+			report.Signature = signReport(key, report.PolicyID, config.Metadata.ReportVersion, data)
+		}
+		manifest.Reports = append(manifest.Reports, report)
+		files[report.File] = data
+		fmt.Printf("  ✅ %s (%s %s)\n", report.PolicyID, config.Metadata.ReportTitle, config.Metadata.ReportVersion)
+	}
+
+	archive, err := policypack.Build(manifest, files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to build pack: %v\n", err)
+		return false
+	}
+
+	if err := os.MkdirAll(app.outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create output directory: %v\n", err)
+		return false
+	}
+	archivePath := filepath.Join(app.outputDir, opts.name+"-"+opts.version+".zip")
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write pack: %v\n", err)
+		return false
+	}
+	sum := sha256.Sum256(archive)
+	fmt.Printf("\nPack: %s\nSHA-256: %s\n", archivePath, hex.EncodeToString(sum[:]))
+
+	if key == nil {
+		fmt.Println("⚠ The pack is unsigned; servers only import packs signed with a trusted key (-sign)")
+		return true
+	}
+	signaturePath := archivePath + ".sig"
+	if err := os.WriteFile(signaturePath, []byte(policypack.Sign(key, archive)+"\n"), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write signature: %v\n", err)
+		return false
+	}
+	fmt.Printf("Signature: %s\n", signaturePath)
+	return true
+}
+
+// signReport signs a report configuration the way clients verify a policy
+// downloaded from the server
+func signReport(key ed25519.PrivateKey, policyID, version string, data []byte) string {
+	sum := sha256.Sum256(data)
+	payload := api.PolicySigningPayload(policyID, version, hex.EncodeToString(sum[:]))
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+}
+
+// loadOrCreatePublisherKey reads a PEM encoded Ed25519 publisher key, the
+// same format compliance-server sign-policy uses, generating it if the file
+// does not exist
+func loadOrCreatePublisherKey(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := policypack.ParsePrivateKey(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid publisher key %s: %w", path, err)
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read publisher key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate publisher key: %w", err)
+	}
+	data, err = policypack.EncodePrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, false, fmt.Errorf("failed to create key directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, false, fmt.Errorf("failed to write publisher key: %w", err)
+	}
+	return key, true, nil
+}
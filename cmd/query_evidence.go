@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"compliancetoolkit/pkg/evidencequery"
+)
+
+// queryEvidenceOptions are the flags of the query-evidence command
+type queryEvidenceOptions struct {
+	where  string // Filter expression; empty keeps every result
+	fields string // Comma-separated fields to output; empty outputs all
+	format string // csv or json
+}
+
+// runQueryEvidenceCLI writes the check results of evidence logs that match
+// the where expression to stdout, as CSV or JSON. Arguments are evidence
+// logs, directories of them or glob patterns; without arguments the
+// evidence directory is queried.
+func (app *App) runQueryEvidenceCLI(args []string, opts queryEvidenceOptions) bool {
+	filter, err := evidencequery.Parse(opts.where)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid -where: %v\n", err)
+		return false
+	}
+	fields, err := evidencequery.ParseFields(opts.fields)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid -select: %v\n", err)
+		return false
+	}
+	if opts.format != "csv" && opts.format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: Invalid -format %q (use csv or json)\n", opts.format)
+		return false
+	}
+
+	if len(args) == 0 {
+		args = []string{app.evidenceDir}
+	}
+	files, err := evidencequery.Files(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	// Files that are not evidence logs (such as rollback snapshots in the
+	// same directory) are reported and skipped
+	var rows []evidencequery.Row
+	for _, file := range files {
+		loaded, err := evidencequery.Load(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %v\n", err)
+			continue
+		}
+		for _, row := range loaded {
+			if filter.Match(row) {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	if err := evidencequery.Write(os.Stdout, rows, fields, opts.format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"compliancetoolkit/pkg"
+)
+
+// queryRead is what reading one query's registry data returned
+type queryRead struct {
+	value *pkg.RegistryValue
+	data  map[string]interface{}
+	tree  *pkg.RegistryTree
+	err   error
+}
+
+// readQueries reads the registry data of a report's queries, up to
+// server.max_concurrent_reads at a time, so report loops only record the
+// results, in query order. Queries that are not reads, or that security
+// settings refuse, are left empty for the loop to report.
+func (app *App) readQueries(queries []pkg.RegistryQuery) []queryRead {
+	reads := make([]queryRead, len(queries))
+	pkg.RunConcurrently(context.Background(), len(queries), app.config.Server.MaxConcurrentReads, 0, func(ctx context.Context, i int) {
+		query := queries[i]
+		if query.Operation != "read" && query.Operation != "remediate" && !query.IsTree() {
+			return
+		}
+		if pkg.ValidateAgainstDenyList(query.Path, app.config.Security.DenyRegistryPaths) != nil ||
+			pkg.ValidateAgainstAllowList(query.RootKey, app.config.Security.AllowedRegistryRoots) != nil {
+			return
+		}
+		rootKey, err := pkg.ParseRootKey(query.RootKey)
+		if err != nil {
+			return
+		}
+
+		read := &reads[i]
+		switch {
+		case query.IsTree():
+			read.tree, read.err = app.reader.ReadTree(ctx, rootKey, query.Path, query.TreeOptions(app.config.Security.DenyRegistryPaths))
+		case query.ReadAll:
+			read.data, read.err = app.reader.BatchRead(ctx, rootKey, query.Path, []string{})
+		default:
+			read.value, read.err = app.reader.ReadQueryValue(ctx, rootKey, query)
+		}
+	})
+	return reads
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg"
+)
+
+// runRemediationCLI sets the non-compliant values of a report's remediate
+// queries to their expected values. Every change is recorded in a rollback
+// snapshot and in the evidence log. With dryRun the changes are only listed.
+func (app *App) runRemediationCLI(configFile string, dryRun bool) bool {
+	if !dryRun && app.config.Security.ReadOnly {
+		fmt.Fprintf(os.Stderr, "Error: Remediation is disabled while security.read_only is true\n")
+		fmt.Fprintf(os.Stderr, "Set security.read_only: false in the config file, or use -dry-run to preview changes\n")
+		return false
+	}
+	if app.remoteHost != "" {
+		fmt.Fprintf(os.Stderr, "Error: -remediate cannot be combined with -remote-host; remediation only changes the local registry\n")
+		return false
+	}
+
+	if err := pkg.ValidateFilePath(configFile, []string{".json"}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid report name: %v\n", err)
+		return false
+	}
+
+	config, err := pkg.LoadRegistryConfig(filepath.Join(app.reportsDir, configFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to load report: %v\n", err)
+		return false
+	}
+	if err := pkg.ValidateConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Report validation failed: %v\n", err)
+		return false
+	}
+
+	var queries []pkg.RegistryQuery
+	for _, query := range config.Queries {
+		if query.Operation == "remediate" {
+			queries = append(queries, query)
+		}
+	}
+	if len(queries) == 0 {
+		fmt.Printf("No remediate queries in %s; nothing to do\n", configFile)
+		return true
+	}
+
+	reportType := strings.TrimSuffix(filepath.Base(configFile), ".json")
+	evidenceLogger, err := pkg.NewEvidenceLogger(app.evidenceDir, reportType+"_remediation", slog.Default())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Could not create evidence log: %v\n", err)
+		return false
+	}
+	if err := evidenceLogger.GatherMachineInfo(app.reader); err != nil {
+		slog.Warn("Could not gather machine info", "error", err)
+	}
+
+	snapshotPath := filepath.Join(app.evidenceDir,
+		fmt.Sprintf("%s_rollback_%s.json", reportType, time.Now().Format("20060102_150405")))
+	snapshot := pkg.NewRollbackSnapshot(snapshotPath, reportType)
+
+	writer := pkg.NewRegistryWriter(
+		pkg.WithWriterLogger(slog.Default()),
+		pkg.WithWriterAuditLogger(app.auditLogger),
+		pkg.WithWritesEnabled(!app.config.Security.ReadOnly),
+		pkg.WithDryRun(dryRun),
+	)
+
+	if dryRun {
+		fmt.Printf("Remediation plan: %s (dry run, no changes will be made)\n", config.Metadata.ReportTitle)
+	} else {
+		fmt.Printf("Remediating: %s\n", config.Metadata.ReportTitle)
+	}
+	fmt.Println("======================")
+
+	ctx := context.Background()
+	changed, failed := 0, 0
+	for _, query := range queries {
+		if err := pkg.ValidateAgainstDenyList(query.Path, app.config.Security.DenyRegistryPaths); err != nil {
+			fmt.Printf("  ❌ %s: blocked by security policy\n", query.Name)
+			evidenceLogger.LogRemediation(query, nil, "", err)
+			failed++
+			continue
+		}
+		if err := pkg.ValidateAgainstAllowList(query.RootKey, app.config.Security.AllowedRegistryRoots); err != nil {
+			fmt.Printf("  ❌ %s: root key %s not allowed\n", query.Name, query.RootKey)
+			evidenceLogger.LogRemediation(query, nil, "", err)
+			failed++
+			continue
+		}
+
+		result, err := writer.Remediate(ctx, query, snapshot)
+		if err != nil {
+			fmt.Printf("  ❌ %s: %v\n", query.Name, err)
+			evidenceLogger.LogRemediation(query, result, "", err)
+			failed++
+			continue
+		}
+
+		switch {
+		case result.Compliant:
+			fmt.Printf("  ✅ %s: already compliant (%s)\n", query.Name, result.Previous.Display())
+			evidenceLogger.LogRemediation(query, result, "", nil)
+		case result.DryRun:
+			fmt.Printf("  ➜ %s: would change %s to %s\n", query.Name, result.Previous.Display(), result.Target.Display())
+			evidenceLogger.LogRemediation(query, result, "", nil)
+			changed++
+		default:
+			fmt.Printf("  🔧 %s: changed %s to %s\n", query.Name, result.Previous.Display(), result.Target.Display())
+			evidenceLogger.LogRemediation(query, result, snapshotPath, nil)
+			changed++
+		}
+	}
+
+	if err := evidenceLogger.Finalize(); err != nil {
+		slog.Warn("Could not write evidence log", "error", err)
+	}
+
+	fmt.Println("======================")
+	if dryRun {
+		fmt.Printf("%d value(s) would be changed, %d failed\n", changed, failed)
+	} else {
+		fmt.Printf("%d value(s) changed, %d failed\n", changed, failed)
+		if len(snapshot.Entries) > 0 {
+			fmt.Printf("Rollback snapshot: %s\n", snapshotPath)
+			fmt.Printf("To undo: ComplianceToolkit.exe -rollback=\"%s\"\n", snapshotPath)
+		}
+	}
+	fmt.Printf("Evidence saved to: %s\n", evidenceLogger.GetLogPath())
+
+	return failed == 0
+}
+
+// runRollbackCLI restores the values recorded in a rollback snapshot
+func (app *App) runRollbackCLI(snapshotPath string, dryRun bool) bool {
+	if !dryRun && app.config.Security.ReadOnly {
+		fmt.Fprintf(os.Stderr, "Error: Rollback is disabled while security.read_only is true\n")
+		return false
+	}
+	if err := pkg.ValidateFilePath(snapshotPath, []string{".json"}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid snapshot path: %v\n", err)
+		return false
+	}
+
+	snapshot, err := pkg.LoadRollbackSnapshot(snapshotPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+
+	// The snapshot is read from disk, so its entries are held to the same
+	// checks as the queries that produced them; nothing is restored unless
+	// every entry passes
+	blocked := false
+	for _, entry := range snapshot.Entries {
+		err := entry.Validate()
+		if err == nil {
+			err = pkg.ValidateAgainstDenyList(entry.Path, app.config.Security.DenyRegistryPaths)
+		}
+		if err == nil {
+			err = pkg.ValidateAgainstAllowList(entry.RootKey, app.config.Security.AllowedRegistryRoots)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", entry.CheckName, err)
+			blocked = true
+		}
+	}
+	if blocked {
+		fmt.Fprintf(os.Stderr, "Error: Rollback refused; the snapshot names registry values that cannot be written\n")
+		return false
+	}
+
+	writer := pkg.NewRegistryWriter(
+		pkg.WithWriterLogger(slog.Default()),
+		pkg.WithWriterAuditLogger(app.auditLogger),
+		pkg.WithWritesEnabled(!app.config.Security.ReadOnly),
+		pkg.WithDryRun(dryRun),
+	)
+
+	fmt.Printf("Rolling back %d change(s) from %s\n", len(snapshot.Entries), snapshot.CreatedAt.Format(time.RFC3339))
+	for i := len(snapshot.Entries) - 1; i >= 0; i-- {
+		entry := snapshot.Entries[i]
+		fmt.Printf("  %s: %s -> %s\n", entry.CheckName, entry.Applied.Display(), entry.Previous.Display())
+	}
+
+	if err := writer.Rollback(context.Background(), snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Rollback incomplete: %v\n", err)
+		return false
+	}
+
+	if dryRun {
+		fmt.Println("Dry run: no changes were made")
+	} else {
+		fmt.Println("Rollback complete")
+	}
+	return true
+}
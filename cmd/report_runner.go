@@ -80,8 +80,8 @@ func main() {
 
 		// Execute each query
 		for _, query := range config.Queries {
-			if query.Operation != "read" {
-				log.Printf("SKIP [%s]: Write operations not supported\n", query.Name)
+			if query.Operation != "read" && query.Operation != "remediate" && !query.IsTree() {
+				log.Printf("SKIP [%s]: Unsupported operation %q\n", query.Name, query.Operation)
 				continue
 			}
 
@@ -91,7 +91,32 @@ func main() {
 				continue
 			}
 
-			if query.ReadAll {
+			if query.IsTree() {
+				// Read the subtree's values
+				tree, err := reader.ReadTree(ctx, rootKey, query.Path, query.TreeOptions(nil))
+				if err != nil {
+					if !*outputJSON {
+						fmt.Printf("❌ [%s] Error: %v\n", query.Name, err)
+					}
+					reportResults[query.Name] = map[string]interface{}{
+						"error":       err.Error(),
+						"description": query.Description,
+					}
+					continue
+				}
+
+				if !*outputJSON {
+					fmt.Printf("✅ [%s] %s:\n", query.Name, query.Description)
+					for _, value := range tree.Values() {
+						fmt.Printf("   %s\n", value)
+					}
+				}
+				reportResults[query.Name] = map[string]interface{}{
+					"description": query.Description,
+					"tree":        tree,
+				}
+
+			} else if query.ReadAll {
 				// Batch read all values
 				data, err := reader.BatchRead(ctx, rootKey, query.Path, []string{})
 				if err != nil {
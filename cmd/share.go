@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"compliancetoolkit/pkg/netshare"
+)
+
+// outputTarget is an output directory that may live on a file share
+type outputTarget struct {
+	dir  *string // Directory in use; switched to the fallback when the share is down
+	name string  // Subdirectory of the fallback path, e.g. "reports"
+}
+
+// outputTargets returns the directories reports and evidence are written to
+func (app *App) outputTargets() []outputTarget {
+	return []outputTarget{
+		{&app.outputDir, "reports"},
+		{&app.evidenceDir, "evidence"},
+	}
+}
+
+// shareRetryPolicy returns the retry policy for writes to a share
+func (app *App) shareRetryPolicy() netshare.RetryPolicy {
+	return netshare.RetryPolicy{
+		Attempts: app.config.Reports.Share.RetryAttempts,
+		Delay:    app.config.Reports.Share.RetryDelay,
+	}
+}
+
+// fallbackDir returns the local directory used for name while its share is
+// unreachable
+func (app *App) fallbackDir(name string) string {
+	return filepath.Join(app.resolveDirectory(app.config.Reports.Share.FallbackPath), name)
+}
+
+// prepareShares connects to the shares holding the output directories. A
+// share that stays unreachable is replaced by its local fallback directory
+// for this run; a reachable one first receives the output an earlier run
+// left in the fallback.
+func (app *App) prepareShares() {
+	shareCfg := app.config.Reports.Share
+	creds := netshare.Credentials{Username: shareCfg.Username}
+	if shareCfg.PasswordEnv != "" {
+		creds.Password = os.Getenv(shareCfg.PasswordEnv)
+		if creds.Password == "" {
+			slog.Warn("Share password environment variable is empty", "variable", shareCfg.PasswordEnv)
+		}
+	}
+
+	policy := app.shareRetryPolicy()
+	connected := make(map[string]bool)
+
+	for _, target := range app.outputTargets() {
+		dir := *target.dir
+		if !netshare.IsUNC(dir) {
+			continue
+		}
+
+		err := policy.Do(func() error {
+			root, err := netshare.Root(dir)
+			if err != nil {
+				return err
+			}
+			if !connected[root] {
+				conn, err := netshare.Connect(dir, creds)
+				if err != nil {
+					return err
+				}
+				app.shares = append(app.shares, conn)
+				connected[root] = true
+			}
+			return os.MkdirAll(dir, 0755)
+		})
+		if err != nil {
+			fallback := app.fallbackDir(target.name)
+			slog.Warn("Share unavailable, writing output locally",
+				"share", dir, "fallback", fallback, "error", err)
+			os.MkdirAll(fallback, 0755)
+			*target.dir = fallback
+			continue
+		}
+
+		moved, err := netshare.Sync(app.fallbackDir(target.name), dir, policy)
+		if len(moved) > 0 {
+			slog.Info("Moved pending output to share", "share", dir, "files", len(moved))
+		}
+		if err != nil {
+			slog.Warn("Could not move pending output to share", "share", dir, "error", err)
+		}
+	}
+}
+
+// closeShares drops the share connections made with credentials
+func (app *App) closeShares() {
+	for _, conn := range app.shares {
+		if err := conn.Close(); err != nil {
+			slog.Warn("Could not disconnect share", "share", conn.Root(), "error", err)
+		}
+	}
+	app.shares = nil
+}
+
+// writeOutput runs write, retrying while *path is on a share that is
+// briefly unavailable. If the share stays unavailable, *path is moved to the
+// local fallback directory and written there, to be moved to the share by a
+// later run.
+func (app *App) writeOutput(path *string, name string, write func() error) error {
+	if !netshare.IsUNC(*path) {
+		return write()
+	}
+
+	err := app.shareRetryPolicy().Do(write)
+	if err == nil || !netshare.IsTransient(err) {
+		return err
+	}
+
+	fallback := filepath.Join(app.fallbackDir(name), filepath.Base(*path))
+	slog.Warn("Share unavailable, writing output locally", "path", *path, "fallback", fallback, "error", err)
+	*path = fallback
+	if err := write(); err != nil {
+		return fmt.Errorf("share unavailable and local fallback failed: %w", err)
+	}
+	return nil
+}
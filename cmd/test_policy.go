@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"compliancetoolkit/pkg"
+)
+
+// runTestPolicyCLI shows whether the security settings let queries read
+// path below rootKey (optional), and every deny list rule that matches it,
+// so a new rule can be tried before it is rolled out. It returns false when
+// the path is refused or the settings are invalid.
+func (app *App) runTestPolicyCLI(rootKey, path string) bool {
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "Error: test-policy requires --path\n")
+		return false
+	}
+	security := app.config.Security
+
+	fmt.Printf("Path: %s\n", path)
+	allowed := true
+
+	if err := pkg.ValidateRegistryPath(path); err != nil {
+		fmt.Printf("  ❌ not a valid registry path (character policy %s): %v\n", pkg.CurrentCharacterPolicy(), err)
+		allowed = false
+	} else if err := pkg.ValidateNoPathTraversal(path); err != nil {
+		fmt.Printf("  ❌ %v\n", err)
+		allowed = false
+	}
+
+	if rootKey != "" {
+		if err := pkg.ValidateAgainstAllowList(rootKey, security.AllowedRegistryRoots); err != nil {
+			fmt.Printf("  ❌ root key %s is not in security.allowed_registry_roots\n", rootKey)
+			allowed = false
+		} else {
+			fmt.Printf("  ✅ root key %s is allowed\n", rootKey)
+		}
+	}
+
+	matches := 0
+	for i, entry := range security.DenyRegistryPaths {
+		rule, err := pkg.CompileDenyRule(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: security.deny_registry_paths[%d]: %v\n", i, err)
+			return false
+		}
+		matched, parent := rule.Match(path)
+		if !matched {
+			continue
+		}
+		matches++
+		how := "matches"
+		if parent {
+			how = "denies a parent key"
+		}
+		fmt.Printf("  🔒 denied: security.deny_registry_paths[%d] %q %s\n", i, entry, how)
+	}
+	if matches > 0 {
+		allowed = false
+	} else {
+		fmt.Printf("  ✅ no rule of security.deny_registry_paths (%d) matches\n", len(security.DenyRegistryPaths))
+	}
+
+	if allowed {
+		fmt.Println("Result: queries may read this path")
+	} else {
+		fmt.Println("Result: queries may not read this path")
+	}
+	return allowed
+}
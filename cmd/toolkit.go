@@ -1,18 +1,21 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/netshare"
 	"github.com/spf13/pflag"
 )
 
@@ -26,6 +29,10 @@ type App struct {
 	evidenceDir string
 	reportsDir  string
 	exeDir      string
+	remoteHost  string
+	profile     string // Report profile run with -profile (empty: every query)
+	shares      []*netshare.Connection
+	uploader    *api.Client // Set with -upload
 }
 
 func main() {
@@ -36,6 +43,7 @@ func main() {
 	reportName := flags.StringP("report", "r", "", "Report to run (e.g., 'NIST_800_171_compliance.json' or 'all')")
 	listReports := flags.BoolP("list", "l", false, "List available reports and exit")
 	quiet := flags.BoolP("quiet", "q", false, "Suppress non-essential output (for scheduled runs)")
+	profile := flags.String("profile", "", "Run only the queries of this report profile (e.g., 'quick'); with -report all, reports without it are skipped")
 
 	// Configuration file flag
 	configFile := flags.StringP("config", "c", "", "Path to config file (default: ./config.yaml)")
@@ -46,6 +54,36 @@ func main() {
 	evidenceDir := flags.String("evidence", "", "Evidence logs directory (overrides config)")
 	timeout := flags.Duration("timeout", 0, "Registry operation timeout (overrides config)")
 	logLevel := flags.String("log-level", "", "Log level: debug, info, warn, error (overrides config)")
+	exportFormats := flags.String("export", "", "Also export results as csv, xlsx or csv,xlsx (overrides config)")
+
+	// Remote scanning flag
+	remoteHost := flags.String("remote-host", "", "Scan another machine's registry over the Remote Registry service (HKLM and HKU only)")
+
+	// Remediation flags
+	remediate := flags.Bool("remediate", false, "Set non-compliant values of the report's remediate queries to their expected values (requires security.read_only: false)")
+	dryRun := flags.Bool("dry-run", false, "With -remediate, -rollback or cleanup, show the changes without making them")
+	rollback := flags.String("rollback", "", "Restore the values recorded in a rollback snapshot written by -remediate")
+
+	// Server upload flags
+	upload := flags.Bool("upload", false, "Also submit results to a compliance server (requires -server and -api-key)")
+	serverURL := flags.String("server", "", "Compliance server URL for -upload (e.g., https://compliance.example.com)")
+	apiKey := flags.String("api-key", "", "API key for -upload")
+
+	// Policy pack flags
+	packName := flags.String("pack-name", "", "Name of the policy pack written by the pack command")
+	packVersion := flags.String("pack-version", "", "Version of the policy pack written by the pack command")
+	packPublisher := flags.String("publisher", "", "Publisher recorded in the policy pack manifest")
+	packKey := flags.String("sign", "", "Publisher key file to sign the policy pack with (created on first use)")
+
+	// Evidence query flags
+	where := flags.String("where", "", "With query-evidence, the results to keep (e.g., 'status=fail AND category~security')")
+	selectFields := flags.String("select", "", "With query-evidence, the comma-separated fields to output (default: all)")
+	queryFormat := flags.String("format", "csv", "With query-evidence, the output format: csv or json")
+	pseudonymKey := flags.String("pseudonym-key", "", "With anonymize, a file holding the key pseudonyms are derived from (the same pseudonyms in every run)")
+
+	// Security policy test flags
+	policyPath := flags.String("path", "", "With test-policy, the registry path to check against the security settings")
+	policyRoot := flags.String("root-key", "", "With test-policy, the root key to check against security.allowed_registry_roots (e.g., HKLM)")
 
 	// Generate default config flag
 	genConfig := flags.Bool("generate-config", false, "Generate default config.yaml file and exit")
@@ -121,6 +159,35 @@ func main() {
 		}
 		cfg.Logging.Level = *logLevel
 	}
+	if *exportFormats != "" {
+		formats := strings.Split(*exportFormats, ",")
+		for i := range formats {
+			formats[i] = strings.TrimSpace(formats[i])
+		}
+		if err := pkg.ValidateExportFormats(formats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid export formats: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Reports.ExportFormats = formats
+	}
+
+	if *remoteHost != "" {
+		if err := pkg.ValidateRemoteHost(*remoteHost); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid remote host: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *upload {
+		if *serverURL == "" || *apiKey == "" {
+			fmt.Fprintf(os.Stderr, "Error: -upload requires -server and -api-key\n")
+			os.Exit(1)
+		}
+		if u, err := url.Parse(*serverURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "Error: Invalid server URL '%s' (must be http:// or https://)\n", *serverURL)
+			os.Exit(1)
+		}
+	}
 
 	app := &App{
 		menu:        pkg.NewMenu(),
@@ -129,10 +196,17 @@ func main() {
 		evidenceDir: cfg.Reports.EvidencePath,
 		exeDir:      exeDir,
 		config:      cfg,
+		remoteHost:  *remoteHost,
+		profile:     *profile,
+	}
+	if *upload {
+		app.uploader = api.NewClient(strings.TrimSuffix(*serverURL, "/"), *apiKey)
 	}
 
 	// Initialize
 	app.init()
+	defer app.reader.Close()
+	defer app.closeShares()
 
 	// Handle CLI mode (non-interactive)
 	if *listReports {
@@ -140,6 +214,66 @@ func main() {
 		return
 	}
 
+	// "cleanup" command applies the retention policy and exits
+	if flags.Arg(0) == "cleanup" {
+		if !app.runCleanupCLI(*dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "pack" command bundles report configurations into a policy pack
+	if flags.Arg(0) == "pack" {
+		opts := packOptions{name: *packName, version: *packVersion, publisher: *packPublisher, keyFile: *packKey}
+		if !app.runPackCLI(flags.Args()[1:], opts) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "query-evidence" command filters evidence logs into CSV or JSON
+	if flags.Arg(0) == "query-evidence" {
+		opts := queryEvidenceOptions{where: *where, fields: *selectFields, format: *queryFormat}
+		if !app.runQueryEvidenceCLI(flags.Args()[1:], opts) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "anonymize" command writes evidence logs with identifying details replaced
+	if flags.Arg(0) == "anonymize" {
+		if !app.runAnonymizeCLI(flags.Args()[1:], *pseudonymKey) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "test-policy" command shows which security rules apply to a path
+	if flags.Arg(0) == "test-policy" {
+		if !app.runTestPolicyCLI(*policyRoot, *policyPath) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *rollback != "" {
+		if !app.runRollbackCLI(*rollback, *dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *remediate {
+		if *reportName == "" || strings.EqualFold(*reportName, "all") {
+			fmt.Fprintf(os.Stderr, "Error: -remediate requires -report with a single report\n")
+			os.Exit(1)
+		}
+		if !app.runRemediationCLI(*reportName, *dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *reportName != "" {
 		// Run specific report or all reports
 		success := app.runReportCLI(*reportName, *quiet)
@@ -184,9 +318,14 @@ func (app *App) init() {
 	app.logsDir = app.resolveDirectory(app.logsDir)
 	app.evidenceDir = app.resolveDirectory(app.evidenceDir)
 
-	os.MkdirAll(app.outputDir, 0755)
 	os.MkdirAll(app.logsDir, 0755)
-	os.MkdirAll(app.evidenceDir, 0755)
+
+	// Output directories on a share are created once the share is connected
+	for _, target := range app.outputTargets() {
+		if !netshare.IsUNC(*target.dir) {
+			os.MkdirAll(*target.dir, 0755)
+		}
+	}
 
 	// Create audit log directory if audit mode is enabled
 	if app.config.Security.AuditMode {
@@ -220,6 +359,16 @@ func (app *App) init() {
 
 	slog.SetDefault(logger)
 
+	// Connect output directories on file shares
+	app.prepareShares()
+
+	// Apply output retention before new reports are written
+	if retention := app.config.Reports.Retention; retention.CleanupOnStartup && retention.Enabled() {
+		if _, err := pkg.CleanupOutputDirs(app.retentionDirs(), retention, false, logger); err != nil {
+			slog.Warn("Output retention cleanup failed", "error", err)
+		}
+	}
+
 	// Initialize audit logger if enabled
 	var auditLogger *pkg.AuditLogger
 	if app.config.Security.AuditMode {
@@ -247,6 +396,10 @@ func (app *App) init() {
 	if auditLogger != nil {
 		readerOpts = append(readerOpts, pkg.WithAuditLogger(auditLogger))
 	}
+	if app.remoteHost != "" {
+		readerOpts = append(readerOpts, pkg.WithRemoteHost(app.remoteHost))
+		slog.Info("Scanning remote registry", "host", app.remoteHost)
+	}
 	app.reader = pkg.NewRegistryReader(readerOpts...)
 }
 
@@ -373,6 +526,7 @@ type ReportInfo struct {
 	ConfigFile string
 	Category   string
 	Version    string
+	Profiles   []string
 }
 
 func (app *App) loadAvailableReports() ([]ReportInfo, error) {
@@ -406,6 +560,7 @@ func (app *App) loadAvailableReports() ([]ReportInfo, error) {
 			ConfigFile: file.Name(),
 			Category:   config.Metadata.Category,
 			Version:    config.Metadata.ReportVersion,
+			Profiles:   config.ProfileNames(),
 		})
 	}
 
@@ -434,6 +589,11 @@ func (app *App) executeReport(configFile string) bool {
 		return false
 	}
 
+	if err := config.ApplyProfile(app.profile); err != nil {
+		fmt.Printf("  ❌  Cannot run profile: %v\n", err)
+		return false
+	}
+
 	// Create HTML report using metadata
 	reportName := config.Metadata.ReportTitle
 	if reportName == "" {
@@ -457,6 +617,8 @@ func (app *App) executeReport(configFile string) bool {
 	if err != nil {
 		fmt.Printf("  ⚠️  Warning: Could not create evidence log: %v\n", err)
 	} else {
+		evidenceLogger.SetReportMetadata(config.Metadata)
+
 		// Gather machine information for evidence
 		fmt.Println("  📋  Gathering machine information for audit trail...")
 		if err := evidenceLogger.GatherMachineInfo(app.reader); err != nil {
@@ -464,13 +626,16 @@ func (app *App) executeReport(configFile string) bool {
 		}
 	}
 
-	ctx := context.Background()
 	successCount := 0
 	errorCount := 0
 
-	// Execute queries
-	for _, query := range config.Queries {
-		if query.Operation != "read" {
+	// Execute queries, reading them concurrently and recording the results
+	// in order
+	reads := app.readQueries(config.Queries)
+	for i, query := range config.Queries {
+		// Remediate queries are read like any other check; values are only
+		// changed by an explicit -remediate run
+		if query.Operation != "read" && query.Operation != "remediate" && !query.IsTree() {
 			continue
 		}
 
@@ -495,8 +660,7 @@ func (app *App) executeReport(configFile string) bool {
 			continue
 		}
 
-		rootKey, err := pkg.ParseRootKey(query.RootKey)
-		if err != nil {
+		if _, err := pkg.ParseRootKey(query.RootKey); err != nil {
 			fmt.Printf("  ⚠️  [%s] Invalid root key: %s\n", query.Name, query.RootKey)
 			htmlReport.AddResult(query.Name, query.Description, nil, err)
 			if evidenceLogger != nil {
@@ -506,9 +670,31 @@ func (app *App) executeReport(configFile string) bool {
 			continue
 		}
 
-		if query.ReadAll {
+		if query.IsTree() {
+			// Subtree read
+			tree, err := reads[i].tree, reads[i].err
+			if err != nil {
+				if pkg.IsNotExist(err) {
+					fmt.Printf("  ⚠️  [%s] Not found\n", query.Name)
+				} else {
+					fmt.Printf("  ❌  [%s] Error: %v\n", query.Name, err)
+				}
+				htmlReport.AddTreeResult(query, nil, err)
+				if evidenceLogger != nil {
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, nil, err)
+				}
+				errorCount++
+			} else {
+				fmt.Printf("  ✅  [%s] Read %d keys\n", query.Name, len(tree.Keys))
+				htmlReport.AddTreeResult(query, tree, nil)
+				if evidenceLogger != nil {
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, tree.String(), nil)
+				}
+				successCount++
+			}
+		} else if query.ReadAll {
 			// Batch read
-			data, err := app.reader.BatchRead(ctx, rootKey, query.Path, []string{})
+			data, err := reads[i].data, reads[i].err
 			if err != nil {
 				if pkg.IsNotExist(err) {
 					fmt.Printf("  ⚠️  [%s] Not found\n", query.Name)
@@ -530,41 +716,23 @@ func (app *App) executeReport(configFile string) bool {
 			}
 		} else {
 			// Single value read (auto-detect type: string, integer, or binary)
-			value, err := app.reader.ReadValue(ctx, rootKey, query.Path, query.ValueName)
+			value, err := reads[i].value, reads[i].err
 			if err != nil {
 				if pkg.IsNotExist(err) {
 					fmt.Printf("  ⚠️  [%s] Not found\n", query.Name)
 				} else {
 					fmt.Printf("  ❌  [%s] Error: %v\n", query.Name, err)
 				}
-				htmlReport.AddResultWithDetails(
-					query.Name,
-					query.Description,
-					query.RootKey,
-					query.Path,
-					query.ValueName,
-					query.ExpectedValue,
-					nil,
-					err,
-				)
+				htmlReport.AddQueryResult(query, nil, err)
 				if evidenceLogger != nil {
 					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, nil, err)
 				}
 				errorCount++
 			} else {
 				fmt.Printf("  ✅  [%s] Success\n", query.Name)
-				htmlReport.AddResultWithDetails(
-					query.Name,
-					query.Description,
-					query.RootKey,
-					query.Path,
-					query.ValueName,
-					query.ExpectedValue,
-					value,
-					nil,
-				)
+				htmlReport.AddQueryResult(query, value, nil)
 				if evidenceLogger != nil {
-					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, value, nil)
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, value.ReportValue(), nil)
 				}
 				successCount++
 			}
@@ -572,15 +740,25 @@ func (app *App) executeReport(configFile string) bool {
 	}
 
 	// Generate HTML report
-	if err := htmlReport.Generate(); err != nil {
+	if err := app.writeOutput(&htmlReport.OutputPath, "reports", htmlReport.Generate); err != nil {
 		fmt.Printf("  ❌  Failed to generate HTML report: %v\n", err)
 		return false
 	}
 
+	// Export spreadsheets alongside the HTML report
+	var exported []string
+	err = app.writeOutput(&htmlReport.OutputPath, "reports", func() (err error) {
+		exported, err = htmlReport.Export(app.config.Reports.ExportFormats)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("  ⚠️  Warning: Could not export results: %v\n", err)
+	}
+
 	// Finalize evidence log
 	if evidenceLogger != nil {
 		fmt.Println("  📝  Finalizing compliance evidence log...")
-		if err := evidenceLogger.Finalize(); err != nil {
+		if err := app.writeOutput(&evidenceLogger.LogPath, "evidence", evidenceLogger.Finalize); err != nil {
 			fmt.Printf("  ⚠️  Warning: Could not finalize evidence log: %v\n", err)
 		} else {
 			fmt.Println()
@@ -588,14 +766,29 @@ func (app *App) executeReport(configFile string) bool {
 		}
 	}
 
+	// Submit to the compliance server
+	uploaded := true
+	if app.uploader != nil {
+		fmt.Println("  📤  Uploading results to server...")
+		if resp, err := app.uploadResults(htmlReport, evidenceLogger); err != nil {
+			fmt.Printf("  ❌  Upload failed: %v\n", err)
+			uploaded = false
+		} else {
+			fmt.Printf("  📤  Uploaded: submission %s (%s)\n", resp.SubmissionID, resp.Status)
+		}
+	}
+
 	fmt.Println()
 	fmt.Printf("  📊  Results: %d successful, %d errors\n", successCount, errorCount)
 	fmt.Printf("  📄  HTML Report: %s\n", htmlReport.OutputPath)
+	for _, path := range exported {
+		fmt.Printf("  📑  Export: %s\n", path)
+	}
 	if evidenceLogger != nil {
 		fmt.Printf("  📋  Evidence Log: %s\n", evidenceLogger.LogPath)
 	}
 
-	return true
+	return uploaded
 }
 
 func (app *App) viewHTMLReports() {
@@ -922,12 +1115,17 @@ func (app *App) listReportsCLI() {
 		fmt.Printf("    Title:    %s\n", report.Title)
 		fmt.Printf("    Category: %s\n", report.Category)
 		fmt.Printf("    Version:  %s\n", report.Version)
+		if len(report.Profiles) > 0 {
+			fmt.Printf("    Profiles: %s\n", strings.Join(report.Profiles, ", "))
+		}
 		fmt.Println()
 	}
 	fmt.Println("To run a specific report:")
 	fmt.Printf("  ComplianceToolkit.exe -report=<report-name.json>\n\n")
 	fmt.Println("To run all reports:")
 	fmt.Printf("  ComplianceToolkit.exe -report=all\n")
+	fmt.Println("To run only a profile's queries:")
+	fmt.Printf("  ComplianceToolkit.exe -report=all -profile=<profile>\n")
 }
 
 func (app *App) runReportCLI(reportName string, quiet bool) bool {
@@ -951,6 +1149,12 @@ func (app *App) runReportCLI(reportName string, quiet bool) bool {
 
 		allSuccess := true
 		for _, report := range reports {
+			if app.profile != "" && !slices.Contains(report.Profiles, app.profile) {
+				if !quiet {
+					fmt.Printf("\n⏭ Skipped: %s (no '%s' profile)\n", report.Title, app.profile)
+				}
+				continue
+			}
 			if !quiet {
 				fmt.Printf("\n▶ Running: %s\n", report.Title)
 			}
@@ -1048,6 +1252,17 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		return false
 	}
 
+	if err := config.ApplyProfile(app.profile); err != nil {
+		if !quiet {
+			fmt.Printf("Cannot run profile: %v\n", err)
+		}
+		slog.Error("Cannot run profile", "file", configFile, "profile", app.profile, "error", err)
+		return false
+	}
+	if app.profile != "" {
+		slog.Info("Running report profile", "file", configFile, "profile", app.profile, "queries", len(config.Queries))
+	}
+
 	// Create HTML report using metadata
 	reportName := config.Metadata.ReportTitle
 	if reportName == "" {
@@ -1071,6 +1286,7 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		}
 		slog.Warn("Could not create evidence log", "error", err)
 	} else {
+		evidenceLogger.SetReportMetadata(config.Metadata)
 		if err := evidenceLogger.GatherMachineInfo(app.reader); err != nil {
 			if !quiet {
 				fmt.Printf("Warning: Could not gather machine info: %v\n", err)
@@ -1079,13 +1295,16 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		}
 	}
 
-	ctx := context.Background()
 	successCount := 0
 	errorCount := 0
 
-	// Execute queries
-	for _, query := range config.Queries {
-		if query.Operation != "read" {
+	// Execute queries, reading them concurrently and recording the results
+	// in order
+	reads := app.readQueries(config.Queries)
+	for i, query := range config.Queries {
+		// Remediate queries are read like any other check; values are only
+		// changed by an explicit -remediate run
+		if query.Operation != "read" && query.Operation != "remediate" && !query.IsTree() {
 			continue
 		}
 
@@ -1116,8 +1335,7 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 			continue
 		}
 
-		rootKey, err := pkg.ParseRootKey(query.RootKey)
-		if err != nil {
+		if _, err := pkg.ParseRootKey(query.RootKey); err != nil {
 			if !quiet {
 				fmt.Printf("  Invalid root key [%s]: %s\n", query.Name, query.RootKey)
 			}
@@ -1129,9 +1347,28 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 			continue
 		}
 
-		if query.ReadAll {
+		if query.IsTree() {
+			// Subtree read
+			tree, err := reads[i].tree, reads[i].err
+			if err != nil {
+				if !quiet && !pkg.IsNotExist(err) {
+					fmt.Printf("  Error [%s]: %v\n", query.Name, err)
+				}
+				htmlReport.AddTreeResult(query, nil, err)
+				if evidenceLogger != nil {
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, nil, err)
+				}
+				errorCount++
+			} else {
+				htmlReport.AddTreeResult(query, tree, nil)
+				if evidenceLogger != nil {
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, tree.String(), nil)
+				}
+				successCount++
+			}
+		} else if query.ReadAll {
 			// Batch read
-			data, err := app.reader.BatchRead(ctx, rootKey, query.Path, []string{})
+			data, err := reads[i].data, reads[i].err
 			if err != nil {
 				if !quiet && !pkg.IsNotExist(err) {
 					fmt.Printf("  Error [%s]: %v\n", query.Name, err)
@@ -1150,38 +1387,20 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 			}
 		} else {
 			// Single value read
-			value, err := app.reader.ReadValue(ctx, rootKey, query.Path, query.ValueName)
+			value, err := reads[i].value, reads[i].err
 			if err != nil {
 				if !quiet && !pkg.IsNotExist(err) {
 					fmt.Printf("  Error [%s]: %v\n", query.Name, err)
 				}
-				htmlReport.AddResultWithDetails(
-					query.Name,
-					query.Description,
-					query.RootKey,
-					query.Path,
-					query.ValueName,
-					query.ExpectedValue,
-					nil,
-					err,
-				)
+				htmlReport.AddQueryResult(query, nil, err)
 				if evidenceLogger != nil {
 					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, nil, err)
 				}
 				errorCount++
 			} else {
-				htmlReport.AddResultWithDetails(
-					query.Name,
-					query.Description,
-					query.RootKey,
-					query.Path,
-					query.ValueName,
-					query.ExpectedValue,
-					value,
-					nil,
-				)
+				htmlReport.AddQueryResult(query, value, nil)
 				if evidenceLogger != nil {
-					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, value, nil)
+					evidenceLogger.LogResult(query.Name, query.Description, query.Path, query.ValueName, value.ReportValue(), nil)
 				}
 				successCount++
 			}
@@ -1189,7 +1408,7 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 	}
 
 	// Generate HTML report
-	if err := htmlReport.Generate(); err != nil {
+	if err := app.writeOutput(&htmlReport.OutputPath, "reports", htmlReport.Generate); err != nil {
 		if !quiet {
 			fmt.Printf("Failed to generate HTML report: %v\n", err)
 		}
@@ -1197,9 +1416,22 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		return false
 	}
 
+	// Export spreadsheets alongside the HTML report
+	var exported []string
+	err = app.writeOutput(&htmlReport.OutputPath, "reports", func() (err error) {
+		exported, err = htmlReport.Export(app.config.Reports.ExportFormats)
+		return err
+	})
+	if err != nil {
+		if !quiet {
+			fmt.Printf("Warning: Could not export results: %v\n", err)
+		}
+		slog.Warn("Could not export results", "error", err)
+	}
+
 	// Finalize evidence log
 	if evidenceLogger != nil {
-		if err := evidenceLogger.Finalize(); err != nil {
+		if err := app.writeOutput(&evidenceLogger.LogPath, "evidence", evidenceLogger.Finalize); err != nil {
 			if !quiet {
 				fmt.Printf("Warning: Could not finalize evidence log: %v\n", err)
 			}
@@ -1207,9 +1439,27 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		}
 	}
 
+	// Submit to the compliance server
+	uploaded := true
+	if app.uploader != nil {
+		resp, err := app.uploadResults(htmlReport, evidenceLogger)
+		if err != nil {
+			if !quiet {
+				fmt.Printf("Upload failed: %v\n", err)
+			}
+			slog.Error("Failed to upload results", "report", reportName, "error", err)
+			uploaded = false
+		} else if !quiet {
+			fmt.Printf("  Uploaded: submission %s (%s)\n", resp.SubmissionID, resp.Status)
+		}
+	}
+
 	if !quiet {
 		fmt.Printf("  Results: %d successful, %d errors\n", successCount, errorCount)
 		fmt.Printf("  HTML Report: %s\n", htmlReport.OutputPath)
+		for _, path := range exported {
+			fmt.Printf("  Export: %s\n", path)
+		}
 		if evidenceLogger != nil {
 			fmt.Printf("  Evidence Log: %s\n", evidenceLogger.LogPath)
 		}
@@ -1221,7 +1471,8 @@ func (app *App) executeReportQuiet(configFile string, quiet bool) bool {
 		"success_count", successCount,
 		"error_count", errorCount,
 		"html_report", htmlReport.OutputPath,
+		"exports", exported,
 	)
 
-	return true
+	return uploaded
 }
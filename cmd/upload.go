@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"compliancetoolkit/pkg"
+	"compliancetoolkit/pkg/api"
+)
+
+// uploadResults submits a completed report to the compliance server given
+// with -upload, so toolkit scans appear on the central dashboard
+func (app *App) uploadResults(report *pkg.HTMLReport, evidenceLogger *pkg.EvidenceLogger) (*api.SubmissionResponse, error) {
+	var machine *pkg.MachineInfo
+	if evidenceLogger != nil {
+		machine = &evidenceLogger.Evidence.MachineInfo
+	} else if app.remoteHost != "" {
+		machine = &pkg.MachineInfo{Hostname: app.remoteHost}
+	}
+
+	submission, err := pkg.NewSubmission(report, machine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build submission: %w", err)
+	}
+
+	resp, err := app.uploader.Submit(submission)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Uploaded results to server",
+		"submission_id", resp.SubmissionID,
+		"client_id", submission.ClientID,
+		"report", submission.ReportType,
+		"status", submission.Compliance.OverallStatus,
+	)
+	return resp, nil
+}
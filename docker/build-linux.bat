@@ -16,9 +16,16 @@ if not exist "docker\bin" mkdir "docker\bin"
 REM Clean previous Linux builds (keep .exe for debugging)
 if exist "docker\bin\compliance-server" del "docker\bin\compliance-server"
 
+REM Provenance reported by --version, /health and telemetry (see pkg/buildinfo)
+set BUILDINFO=compliancetoolkit/pkg/buildinfo
+set COMMIT=
+for /f %%i in ('git rev-parse HEAD 2^>nul') do set COMMIT=%%i
+for /f %%i in ('powershell -NoProfile -Command "(Get-Date).ToUniversalTime().ToString('yyyy-MM-ddTHH:mm:ssZ')"') do set BUILD_TIME=%%i
+set BUILDER=%USERNAME%@%COMPUTERNAME%
+
 REM Build for Linux AMD64
 echo Building Linux AMD64 binary...
-set CGO_ENABLED=1&& set GOOS=linux&& set GOARCH=amd64&& go build -ldflags="-s -w" -o docker\bin\compliance-server .\cmd\compliance-server
+set CGO_ENABLED=0&& set GOOS=linux&& set GOARCH=amd64&& go build -ldflags="-s -w -X %BUILDINFO%.Commit=%COMMIT% -X %BUILDINFO%.BuildTime=%BUILD_TIME% -X %BUILDINFO%.Builder=%BUILDER%" -o docker\bin\compliance-server .\cmd\compliance-server
 
 REM Reset environment variables
 set CGO_ENABLED=
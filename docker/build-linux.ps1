@@ -25,11 +25,18 @@ if (-not (Test-Path "docker\bin")) {
 
 # Build for Linux AMD64
 Write-Host "Building Linux AMD64 binary..." -ForegroundColor Yellow
-$env:CGO_ENABLED = "1"
+$env:CGO_ENABLED = "0"
 $env:GOOS = "linux"
 $env:GOARCH = "amd64"
 
-go build -ldflags="-s -w" -o docker\bin\compliance-server .\cmd\compliance-server
+# Provenance reported by --version, /health and telemetry (see pkg/buildinfo)
+$buildInfo = "compliancetoolkit/pkg/buildinfo"
+$commit = (git rev-parse HEAD 2>$null)
+$buildTime = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+$builder = "$env:USERNAME@$env:COMPUTERNAME"
+$ldflags = "-s -w -X $buildInfo.Commit=$commit -X $buildInfo.BuildTime=$buildTime -X $buildInfo.Builder=$builder"
+
+go build -ldflags="$ldflags" -o docker\bin\compliance-server .\cmd\compliance-server
 
 # Reset environment variables
 $env:GOOS = ""
@@ -55,7 +62,6 @@ Write-Host "Build complete!" -ForegroundColor Cyan
 Write-Host "=====================================" -ForegroundColor Cyan
 Write-Host ""
 Write-Host "Next steps:" -ForegroundColor Yellow
-Write-Host "1. cd docker" -ForegroundColor White
-Write-Host "2. docker-compose -f docker-compose.binary.yml build" -ForegroundColor White
-Write-Host "3. docker-compose -f docker-compose.binary.yml up -d" -ForegroundColor White
+Write-Host "1. docker build -f docker/Dockerfile -t compliance-server:latest ." -ForegroundColor White
+Write-Host "2. docker run -d -p 8080:8080 -e COMPLIANCE_DATABASE_HOST=<host> compliance-server:latest" -ForegroundColor White
 Write-Host ""
@@ -18,10 +18,16 @@ rm -f docker/bin/compliance-server
 # Create bin directory if it doesn't exist
 mkdir -p docker/bin
 
+# Provenance reported by --version, /health and telemetry (see pkg/buildinfo)
+BUILDINFO=compliancetoolkit/pkg/buildinfo
+COMMIT=$(git rev-parse HEAD 2>/dev/null || true)
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+BUILDER="$(whoami)@$(hostname)"
+
 # Build for Linux AMD64
 echo "Building Linux AMD64 binary..."
-CGO_ENABLED=1 GOOS=linux GOARCH=amd64 go build \
-    -ldflags="-s -w" \
+CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build \
+    -ldflags="-s -w -X ${BUILDINFO}.Commit=${COMMIT} -X ${BUILDINFO}.BuildTime=${BUILD_TIME} -X ${BUILDINFO}.Builder=${BUILDER}" \
     -o docker/bin/compliance-server \
     ./cmd/compliance-server
 
@@ -41,7 +47,6 @@ echo "Build complete!"
 echo "====================================="
 echo ""
 echo "Next steps:"
-echo "1. cd docker"
-echo "2. docker-compose -f docker-compose.binary.yml build"
-echo "3. docker-compose -f docker-compose.binary.yml up -d"
+echo "1. docker build -f docker/Dockerfile -t compliance-server:latest ."
+echo "2. docker run -d -p 8080:8080 -e COMPLIANCE_DATABASE_HOST=<host> compliance-server:latest"
 echo ""
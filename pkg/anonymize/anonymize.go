@@ -0,0 +1,399 @@
+// Package anonymize replaces identifying details in submissions and
+// evidence logs - host names, user names, IP and MAC addresses, domain and
+// organization names - with pseudonyms, so the data can be shared with
+// consultants or vendors outside the organization.
+//
+// Pseudonyms are consistent: a value always gets the same pseudonym from
+// the same key, so results can still be correlated by machine or user
+// ("host-3f9a1c07 failed both scans") without revealing who they are. The
+// pseudonym is derived from an HMAC of the value, so it cannot be reversed
+// without the key.
+//
+// Values are found in two ways. Fields that name an identifying value
+// (hostname, operator, ip_address, ...) are replaced whole, and the values
+// learned from them are also replaced wherever they appear in other text,
+// such as error messages and registry data. Other text is searched for IP
+// and MAC addresses, e-mail addresses, fully qualified domain names and the
+// user names in C:\Users\<name> paths.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind is the kind of identifying value a pseudonym stands for
+type Kind string
+
+// Kinds of identifying values
+const (
+	Host   Kind = "host"
+	User   Kind = "user"
+	Domain Kind = "domain"
+	Org    Kind = "org"
+	IP     Kind = "ip"
+	MAC    Kind = "mac"
+	ID     Kind = "id" // Client IDs and machine fingerprints
+)
+
+// fieldKinds maps JSON field names, lower case without separators, to the
+// kind of value they hold
+var fieldKinds = map[string]Kind{
+	"hostname": Host, "host": Host, "computername": Host, "machinename": Host, "dnshostname": Host, "servername": Host,
+
+	"username": User, "user": User, "operator": User, "owner": User, "registeredowner": User, "actor": User,
+	"createdby": User, "updatedby": User, "approvedby": User, "requestedby": User, "acknowledgedby": User,
+	"resolvedby": User, "assignedto": User, "email": User, "lastuser": User,
+
+	"domain": Domain, "domainname": Domain, "dnsdomain": Domain, "fqdn": Domain,
+
+	"org": Org, "organization": Org, "registeredorganization": Org, "company": Org,
+
+	"ip": IP, "ipaddress": IP, "ipv4": IP, "ipv6": IP, "remoteaddr": IP, "remoteip": IP, "clientip": IP,
+
+	"mac": MAC, "macaddress": MAC,
+
+	"clientid": ID, "fingerprint": ID, "machineid": ID,
+}
+
+// fieldKind returns the kind of value a JSON field holds, if it holds one
+func fieldKind(name string) (Kind, bool) {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	kind, ok := fieldKinds[name]
+	return kind, ok
+}
+
+// keptValues are placeholders that identify no one and are left alone
+var keptValues = map[string]bool{
+	"unknown": true, "system": true, "anonymous": true, "api-key": true, "localhost": true,
+	"n/a": true, "none": true, "public": true, "default": true, "all users": true, "default user": true,
+}
+
+// minLearnedLength is the shortest learned value replaced inside other
+// text; shorter ones would replace parts of unrelated words
+const minLearnedLength = 3
+
+// Text patterns of identifying values. Each is validated before it is
+// replaced.
+var (
+	ipv4Pattern  = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	ipv6Pattern  = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+	macPattern   = regexp.MustCompile(`\b[0-9A-Fa-f]{2}(?:[:-][0-9A-Fa-f]{2}){5}\b`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+`)
+	fqdnPattern  = regexp.MustCompile(`\b[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)+\b`)
+	usersPattern = regexp.MustCompile(`(?i)\\Users\\([^\\/:*?"<>|\s]+)`)
+)
+
+// domainTLDs are the top-level domains of names found in text. Other dotted
+// names, such as file and package names, are left alone unless they were
+// learned from an identifying field.
+var domainTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "edu": true, "gov": true, "mil": true, "int": true,
+	"io": true, "co": true, "biz": true, "info": true, "eu": true, "us": true, "uk": true,
+	"de": true, "fr": true, "nl": true, "ca": true, "au": true,
+	// Internal names
+	"local": true, "lan": true, "corp": true, "internal": true, "intranet": true, "home": true,
+}
+
+// pseudonymTLD is the reserved top-level domain of pseudonymous domain
+// names (RFC 2606)
+const pseudonymTLD = "example"
+
+// Scrubber replaces identifying values with pseudonyms. It remembers the
+// values it has learned, so one Scrubber should be used for every document
+// of an export. A Scrubber is not safe for concurrent use.
+type Scrubber struct {
+	key     []byte
+	learned map[string]Kind // Identifying values by lower case text
+	pattern *regexp.Regexp  // Matches the learned values; nil when stale
+}
+
+// New returns a Scrubber deriving pseudonyms from key. Without a key a
+// random one is used, so pseudonyms are consistent within the export but
+// not with other exports.
+func New(key []byte) *Scrubber {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(fmt.Sprintf("anonymize: no random key: %v", err))
+		}
+	}
+	return &Scrubber{key: key, learned: make(map[string]Kind)}
+}
+
+// hash returns n hex digits of the keyed hash of a value of a kind
+func (s *Scrubber) hash(kind Kind, value string, n int) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(string(kind) + "\x00" + strings.ToLower(value)))
+	return hex.EncodeToString(mac.Sum(nil))[:n]
+}
+
+// learn remembers an identifying value so it is replaced in other text
+func (s *Scrubber) learn(kind Kind, value string) {
+	if len(value) < minLearnedLength || keptValues[strings.ToLower(value)] {
+		return
+	}
+	if _, ok := s.learned[strings.ToLower(value)]; !ok {
+		s.learned[strings.ToLower(value)] = kind
+		s.pattern = nil
+	}
+}
+
+// Pseudonym returns the pseudonym of a value of a kind and learns the value.
+// Placeholders such as UNKNOWN and loopback addresses are returned as they
+// are.
+func (s *Scrubber) Pseudonym(kind Kind, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || keptValues[strings.ToLower(value)] {
+		return value
+	}
+
+	var pseudonym string
+	switch kind {
+	case IP:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return s.Pseudonym(Host, value)
+		}
+		if addr.IsLoopback() || addr.IsUnspecified() {
+			return value
+		}
+		sum, _ := hex.DecodeString(s.hash(IP, addr.String(), 8))
+		if addr.Is4() {
+			// The benchmarking range 198.18.0.0/15 (RFC 2544)
+			pseudonym = netip.AddrFrom4([4]byte{198, 18 + sum[0]&1, sum[1], sum[2]}).String()
+		} else {
+			// The documentation range 2001:db8::/32 (RFC 3849)
+			pseudonym = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 12: sum[0], sum[1], sum[2], sum[3]}).String()
+		}
+
+	case MAC:
+		// Locally administered, so it is no vendor's address
+		h := s.hash(MAC, strings.NewReplacer("-", "", ":", "").Replace(value), 8)
+		pseudonym = "02:00:" + h[0:2] + ":" + h[2:4] + ":" + h[4:6] + ":" + h[6:8]
+
+	case Host:
+		if isAddress(value) {
+			return s.Pseudonym(IP, value)
+		}
+		if name, domain, found := strings.Cut(value, "."); found {
+			pseudonym = s.Pseudonym(Host, name) + "." + s.Pseudonym(Domain, domain)
+		} else {
+			pseudonym = "host-" + s.hash(Host, value, 8)
+		}
+
+	case User:
+		if domain, name, found := strings.Cut(value, `\`); found {
+			pseudonym = s.Pseudonym(Domain, domain) + `\` + s.Pseudonym(User, name)
+		} else if name, domain, found := strings.Cut(value, "@"); found {
+			pseudonym = s.Pseudonym(User, name) + "@" + s.Pseudonym(Domain, domain)
+		} else {
+			pseudonym = "user-" + s.hash(User, value, 8)
+		}
+
+	case Domain:
+		if strings.Contains(value, ".") {
+			pseudonym = "domain-" + s.hash(Domain, value, 8) + "." + pseudonymTLD
+		} else {
+			// A NetBIOS domain name
+			pseudonym = "DOMAIN-" + s.hash(Domain, value, 8)
+		}
+
+	case Org:
+		pseudonym = "org-" + s.hash(Org, value, 8)
+
+	default:
+		pseudonym = string(kind) + "-" + s.hash(kind, value, 12)
+	}
+
+	s.learn(kind, value)
+	return pseudonym
+}
+
+// isAddress reports whether value is an IP address
+func isAddress(value string) bool {
+	_, err := netip.ParseAddr(value)
+	return err == nil
+}
+
+// span is an identifying value found in text
+type span struct {
+	start, end int
+	kind       Kind
+}
+
+// Text replaces the identifying values in free text: the values learned so
+// far and addresses, e-mail addresses, domain names and profile paths
+func (s *Scrubber) Text(text string) string {
+	var spans []span
+	add := func(pattern *regexp.Regexp, group int, kind func(match string) (Kind, bool)) {
+		for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*group], loc[2*group+1]
+			if start < 0 {
+				continue
+			}
+			if k, ok := kind(text[start:end]); ok {
+				spans = append(spans, span{start, end, k})
+			}
+		}
+	}
+
+	if learned := s.learnedPattern(); learned != nil {
+		add(learned, 0, func(match string) (Kind, bool) {
+			return s.learned[strings.ToLower(match)], true
+		})
+	}
+	add(emailPattern, 0, func(string) (Kind, bool) { return User, true })
+	add(macPattern, 0, func(string) (Kind, bool) { return MAC, true })
+	add(ipv4Pattern, 0, func(match string) (Kind, bool) { return IP, isAddress(match) })
+	add(ipv6Pattern, 0, func(match string) (Kind, bool) {
+		return IP, strings.Count(match, ":") >= 2 && isAddress(match) && !isMAC(match)
+	})
+	add(fqdnPattern, 0, func(match string) (Kind, bool) {
+		if !domainTLDs[strings.ToLower(match[strings.LastIndex(match, ".")+1:])] {
+			return "", false
+		}
+		// contoso.com is a domain, ws-0042.contoso.com a host in it
+		if strings.Count(match, ".") == 1 {
+			return Domain, true
+		}
+		return Host, true
+	})
+	add(usersPattern, 1, func(match string) (Kind, bool) {
+		return User, !keptValues[strings.ToLower(match)]
+	})
+	if len(spans) == 0 {
+		return text
+	}
+
+	// The earliest match wins and, of matches starting together, the
+	// longest
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		if sp.start < last || !atBoundary(text, sp.start, sp.end) {
+			continue
+		}
+		b.WriteString(text[last:sp.start])
+		b.WriteString(s.Pseudonym(sp.kind, text[sp.start:sp.end]))
+		last = sp.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// learnedPattern returns a pattern matching the learned values, longest
+// first, or nil when nothing has been learned
+func (s *Scrubber) learnedPattern() *regexp.Regexp {
+	if s.pattern == nil && len(s.learned) > 0 {
+		values := make([]string, 0, len(s.learned))
+		for value := range s.learned {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+		for i, value := range values {
+			values[i] = regexp.QuoteMeta(value)
+		}
+		s.pattern = regexp.MustCompile(`(?i)` + strings.Join(values, "|"))
+	}
+	return s.pattern
+}
+
+// atBoundary reports whether text[start:end] is not part of a longer word
+func atBoundary(text string, start, end int) bool {
+	isWord := func(c byte) bool {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	return (start == 0 || !isWord(text[start-1])) && (end == len(text) || !isWord(text[end]))
+}
+
+// isMAC reports whether value is a colon-separated MAC address
+func isMAC(value string) bool {
+	return macPattern.MatchString(value) && len(value) == 17
+}
+
+// Learn remembers the identifying values of a JSON document's fields, so
+// they are also replaced in documents scrubbed before the one they appear
+// in. Call it for every document of an export before scrubbing any.
+func (s *Scrubber) Learn(data []byte) error {
+	doc, err := decode(data)
+	if err != nil {
+		return err
+	}
+	s.walk(doc, "", false)
+	return nil
+}
+
+// JSON returns a JSON document with its identifying values replaced. Fields
+// are ordered by name in the result.
+func (s *Scrubber) JSON(data []byte) ([]byte, error) {
+	doc, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	s.walk(doc, "", false)
+	return json.MarshalIndent(s.walk(doc, "", true), "", "  ")
+}
+
+// decode parses a JSON document, keeping numbers as written
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return doc, nil
+}
+
+// walk visits the strings of a decoded JSON value held by field. It learns
+// the values of identifying fields and, with scrub, returns the value with
+// identifying values replaced.
+func (s *Scrubber) walk(v interface{}, field string, scrub bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = s.walk(value, key, scrub)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = s.walk(value, field, scrub)
+		}
+		return v
+	case string:
+		if kind, ok := fieldKind(field); ok {
+			pseudonym := s.Pseudonym(kind, v)
+			if scrub {
+				return pseudonym
+			}
+			return v
+		}
+		// Version numbers look like IPv4 addresses
+		if scrub && !strings.Contains(strings.ToLower(field), "version") {
+			return s.Text(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
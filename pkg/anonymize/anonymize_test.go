@@ -0,0 +1,115 @@
+package anonymize
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestPseudonym tests that pseudonyms are consistent, keyed and keep the
+// shape of the value they replace
+func TestPseudonym(t *testing.T) {
+	s := New([]byte("key"))
+
+	if a, b := s.Pseudonym(Host, "WS-0042"), s.Pseudonym(Host, "ws-0042"); a != b || !strings.HasPrefix(a, "host-") {
+		t.Errorf("host pseudonyms %q and %q", a, b)
+	}
+	if other := New([]byte("other key")).Pseudonym(Host, "WS-0042"); other == s.Pseudonym(Host, "WS-0042") {
+		t.Error("pseudonyms do not depend on the key")
+	}
+
+	tests := []struct {
+		kind          Kind
+		value, prefix string
+	}{
+		{Host, "ws-0042.corp.contoso.com", s.Pseudonym(Host, "ws-0042") + "." + s.Pseudonym(Domain, "corp.contoso.com")},
+		{Host, "10.1.2.3", "198.1"},
+		{User, `CONTOSO\alice`, s.Pseudonym(Domain, "CONTOSO") + `\` + s.Pseudonym(User, "alice")},
+		{User, "alice@contoso.com", s.Pseudonym(User, "alice") + "@domain-"},
+		{Domain, "contoso.com", "domain-"},
+		{Domain, "CONTOSO", "DOMAIN-"},
+		{IP, "fe80::1", "2001:db8::"},
+		{MAC, "00-1A-2B-3C-4D-5E", "02:00:"},
+		{ID, "client-abc", "id-"},
+		{User, "UNKNOWN", "UNKNOWN"},
+		{IP, "127.0.0.1", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		got := s.Pseudonym(tt.kind, tt.value)
+		if !strings.HasPrefix(got, tt.prefix) {
+			t.Errorf("Pseudonym(%s, %q) = %q, want prefix %q", tt.kind, tt.value, got, tt.prefix)
+		}
+		if tt.prefix != tt.value && strings.Contains(strings.ToLower(got), strings.ToLower(tt.value)) {
+			t.Errorf("Pseudonym(%s, %q) = %q reveals the value", tt.kind, tt.value, got)
+		}
+	}
+}
+
+// TestText tests finding identifying values in free text
+func TestText(t *testing.T) {
+	s := New([]byte("key"))
+	host := s.Pseudonym(Host, "ws-0042")
+	user := s.Pseudonym(User, "alice")
+
+	tests := []struct{ in, want string }{
+		{"Scanned WS-0042 at 10:30:45", "Scanned " + host + " at 10:30:45"},
+		{"ws-00421 is another machine", "ws-00421 is another machine"},
+		{`C:\Users\bob\AppData`, `C:\Users\` + s.Pseudonym(User, "bob") + `\AppData`},
+		{`C:\Users\Public\Desktop`, `C:\Users\Public\Desktop`},
+		{"Contact alice or alice@contoso.com", "Contact " + user + " or " + s.Pseudonym(User, "alice@contoso.com")},
+		{"Gateway 192.168.1.1, DNS fd00::53", "Gateway " + s.Pseudonym(IP, "192.168.1.1") + ", DNS " + s.Pseudonym(IP, "fd00::53")},
+		{"NIC 00:1a:2b:3c:4d:5e", "NIC " + s.Pseudonym(MAC, "00:1a:2b:3c:4d:5e")},
+		{"Joined dc01.contoso.local", "Joined " + s.Pseudonym(Host, "dc01.contoso.local")},
+		{"Build 10.0.19045.3693 of ntdll.dll and Microsoft.WindowsStore.App", "Build 10.0.19045.3693 of ntdll.dll and Microsoft.WindowsStore.App"},
+	}
+	for _, tt := range tests {
+		if got := s.Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) =\n%q, want\n%q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestJSON tests scrubbing a document, including values learned from
+// another document of the export
+func TestJSON(t *testing.T) {
+	s := New([]byte("key"))
+	if err := s.Learn([]byte(`{"machine_information": {"hostname": "FIN-LAPTOP-7"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := `{
+		"hostname": "WS-0042",
+		"client_id": "client-ws-0042",
+		"report_version": "1.0.0.1",
+		"system_info": {"domain": "contoso.com", "ip_address": "10.0.0.5", "mac_address": "00:1A:2B:3C:4D:5E"},
+		"scan_metadata": {"operator": "alice", "duration": 17.25},
+		"scan_results": {"Shares": {"actual_value": ["\\\\FIN-LAPTOP-7\\c$", "opened by alice"]}}
+	}`
+	out, err := s.JSON([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"WS-0042", "ws-0042", "contoso", "10.0.0.5", "1A:2B", "alice", "FIN-LAPTOP-7"} {
+		if strings.Contains(string(out), leaked) {
+			t.Errorf("%q left in %s", leaked, out)
+		}
+	}
+
+	var scrubbed struct {
+		Hostname      string `json:"hostname"`
+		ReportVersion string `json:"report_version"`
+		ScanMetadata  struct {
+			Duration json.Number `json:"duration"`
+		} `json:"scan_metadata"`
+	}
+	if err := json.Unmarshal(out, &scrubbed); err != nil {
+		t.Fatal(err)
+	}
+	if scrubbed.Hostname != s.Pseudonym(Host, "ws-0042") || scrubbed.ReportVersion != "1.0.0.1" || scrubbed.ScanMetadata.Duration != "17.25" {
+		t.Errorf("scrubbed = %+v", scrubbed)
+	}
+
+	if _, err := s.JSON([]byte("not json")); err == nil {
+		t.Error("JSON() accepted invalid JSON")
+	}
+}
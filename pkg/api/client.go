@@ -2,18 +2,39 @@ package api
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// ErrPolicyNotFound is returned by DownloadPolicy when the server has no
+// active policy with the requested ID
+var ErrPolicyNotFound = errors.New("policy not found on server")
+
+// ErrDeltaRejected is returned by SubmitDelta when the server cannot rebuild
+// the submission from the delta, or does not accept deltas; the submission
+// has to be sent in full
+var ErrDeltaRejected = errors.New("delta submission rejected")
+
+// ErrVersionUnsupported is returned by Submit and SubmitDelta when the
+// server refuses submissions from this agent version (426 Upgrade
+// Required); resending them will not help until the client is upgraded
+var ErrVersionUnsupported = errors.New("agent version not supported by the server")
+
 // Client is a client for the Compliance Toolkit API
 type Client struct {
 	baseURL    string
 	apiKey     string
+	clientID   string
 	httpClient *http.Client
 }
 
@@ -27,6 +48,14 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithClientID identifies the client in policy downloads, which decides
+// whether it gets a policy version under staged rollout
+func WithClientID(clientID string) ClientOption {
+	return func(c *Client) {
+		c.clientID = clientID
+	}
+}
+
 // WithInsecureSkipVerify disables TLS certificate verification (for testing only!)
 func WithInsecureSkipVerify() ClientOption {
 	return func(c *Client) {
@@ -73,17 +102,41 @@ func (c *Client) Submit(submission *ComplianceSubmission) (*SubmissionResponse,
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	resp, _, err := c.postSubmission("/api/v1/compliance/submit", submission)
+	return resp, err
+}
+
+// SubmitDelta submits a compliance report whose check results are replaced
+// by submission.Delta. It returns an error wrapping ErrDeltaRejected when
+// the server wants the full submission instead.
+func (c *Client) SubmitDelta(submission *ComplianceSubmission) (*SubmissionResponse, error) {
+	if submission.Delta == nil || submission.Delta.BaseSubmissionID == "" || submission.Delta.StateHash == "" {
+		return nil, fmt.Errorf("validation failed: delta with base_submission_id and state_hash is required")
+	}
+
+	resp, status, err := c.postSubmission("/api/v1/compliance/submit-delta", submission)
+	switch status {
+	case http.StatusConflict, http.StatusNotFound, http.StatusMethodNotAllowed:
+		// Servers without delta support answer 404 or 405
+		return nil, fmt.Errorf("%w: %v", ErrDeltaRejected, err)
+	}
+	return resp, err
+}
+
+// postSubmission sends a submission to path, returning the response status
+// code (0 when no response arrived)
+func (c *Client) postSubmission(path string, submission *ComplianceSubmission) (*SubmissionResponse, int, error) {
 	// Marshal to JSON
 	jsonData, err := json.Marshal(submission)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal submission: %w", err)
+		return nil, 0, fmt.Errorf("failed to marshal submission: %w", err)
 	}
 
 	// Create request
-	url := fmt.Sprintf("%s/api/v1/compliance/submit", c.baseURL)
+	url := c.baseURL + path
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -92,43 +145,128 @@ func (c *Client) Submit(submission *ComplianceSubmission) (*SubmissionResponse,
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, 0, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, errResp.Message)
+		if message, ok := errorMessage(body); ok {
+			if resp.StatusCode == http.StatusUpgradeRequired {
+				return nil, resp.StatusCode, fmt.Errorf("%w: server error (%d): %s", ErrVersionUnsupported, resp.StatusCode, message)
+			}
+			return nil, resp.StatusCode, fmt.Errorf("server error (%d): %s", resp.StatusCode, message)
+		}
+		if resp.StatusCode == http.StatusUpgradeRequired {
+			return nil, resp.StatusCode, fmt.Errorf("%w: server error (%d): %s", ErrVersionUnsupported, resp.StatusCode, string(body))
 		}
-		return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, string(body))
+		return nil, resp.StatusCode, fmt.Errorf("server error (%d): %s", resp.StatusCode, string(body))
 	}
 
 	// Parse response
 	var submissionResp SubmissionResponse
 	if err := json.Unmarshal(body, &submissionResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return &submissionResp, nil
+	return &submissionResp, resp.StatusCode, nil
 }
 
-// Register registers a new client with the server
-func (c *Client) Register(registration *ClientRegistration) error {
+// Register registers a new client with the server. The response carries
+// the server's verdict on the client's version when the server supports
+// the handshake.
+func (c *Client) Register(registration *ClientRegistration) (*RegistrationResponse, error) {
 	jsonData, err := json.Marshal(registration)
 	if err != nil {
-		return fmt.Errorf("failed to marshal registration: %w", err)
+		return nil, fmt.Errorf("failed to marshal registration: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/api/v1/clients/register", c.baseURL)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("registration failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var registrationResp RegistrationResponse
+	if err := json.Unmarshal(body, &registrationResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &registrationResp, nil
+}
+
+// Heartbeat reports that the client is alive along with its run schedule
+func (c *Client) Heartbeat(heartbeat *Heartbeat) (*HeartbeatResponse, error) {
+	jsonData, err := json.Marshal(heartbeat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/clients/heartbeat", c.baseURL)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("heartbeat failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var heartbeatResp HeartbeatResponse
+	if err := json.Unmarshal(body, &heartbeatResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &heartbeatResp, nil
+}
+
+// ReportCommandResult tells the server how a delivered command finished
+func (c *Client) ReportCommandResult(commandID int, result *CommandResult) error {
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command result: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/commands/%d/result", c.baseURL, commandID)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -142,14 +280,137 @@ func (c *Client) Register(registration *ClientRegistration) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("registration failed (%d): %s", resp.StatusCode, string(body))
+		return fmt.Errorf("command result rejected (%d): %s", resp.StatusCode, string(body))
 	}
 
 	return nil
 }
 
+// PolicyDownload is a report configuration fetched with DownloadPolicy
+type PolicyDownload struct {
+	Data        []byte // Report configuration JSON; nil when NotModified
+	Version     string // Policy version declared by the server
+	SHA256      string // Hex SHA-256 of Data, verified against the server's header
+	Signature   string // Base64 Ed25519 signature over PolicySigningPayload; not verified here
+	NotModified bool   // The local copy with the hash passed to DownloadPolicy is current
+}
+
+// DownloadPolicy fetches the report configuration of a policy. localSHA256
+// is the hex SHA-256 of the client's copy, or empty when it has none; if it
+// matches the server's copy the result is NotModified and carries no data.
+// The downloaded data is checked against the hash the server sends; callers
+// verify Signature before using it.
+func (c *Client) DownloadPolicy(policyID, localSHA256 string) (*PolicyDownload, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/policies/%s/download", c.baseURL, url.PathEscape(policyID))
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	if localSHA256 != "" {
+		req.Header.Set("If-None-Match", `"`+localSHA256+`"`)
+	}
+	if c.clientID != "" {
+		req.Header.Set(HeaderClientID, c.clientID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return &PolicyDownload{
+			Version:     resp.Header.Get(HeaderPolicyVersion),
+			SHA256:      localSHA256,
+			NotModified: true,
+		}, nil
+	case http.StatusNotFound:
+		return nil, ErrPolicyNotFound
+	default:
+		return nil, fmt.Errorf("policy download failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	sum := sha256.Sum256(body)
+	gotSum := hex.EncodeToString(sum[:])
+	if wantSum := strings.ToLower(resp.Header.Get(HeaderPolicySHA256)); gotSum != wantSum {
+		return nil, fmt.Errorf("policy %s checksum mismatch: got %s, server sent %q", policyID, gotSum, wantSum)
+	}
+
+	return &PolicyDownload{
+		Data:      body,
+		Version:   resp.Header.Get(HeaderPolicyVersion),
+		SHA256:    gotSum,
+		Signature: resp.Header.Get(HeaderPolicySignature),
+	}, nil
+}
+
+// UploadAttachment attaches a supplementary evidence file, read from r, to
+// a submission
+func (c *Client) UploadAttachment(submissionID, fileName string, r io.Reader, description string) (*Attachment, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form: %w", err)
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	if description != "" {
+		if err := form.WriteField("description", description); err != nil {
+			return nil, fmt.Errorf("failed to create form: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to create form: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/submissions/%s/attachments", c.baseURL, url.PathEscape(submissionID))
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		if message, ok := errorMessage(respBody); ok && message != "" {
+			return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, message)
+		}
+		return nil, fmt.Errorf("request failed (%d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var attachment Attachment
+	if err := json.Unmarshal(respBody, &attachment); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &attachment, nil
+}
+
 // GetStatus retrieves the status of a submission
 func (c *Client) GetStatus(submissionID string) (*SubmissionSummary, error) {
 	url := fmt.Sprintf("%s/api/v1/compliance/status/%s", c.baseURL, submissionID)
@@ -203,3 +464,21 @@ func (c *Client) Ping() error {
 
 	return nil
 }
+
+// errorMessage returns the message of an error response body, with the
+// request ID the server logged the error under when it sent one. ok is false
+// when the body is not JSON.
+func errorMessage(body []byte) (message string, ok bool) {
+	var problem Problem
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return "", false
+	}
+	message = problem.Message
+	if message == "" {
+		message = problem.Detail
+	}
+	if problem.RequestID != "" {
+		message += " (request ID " + problem.RequestID + ")"
+	}
+	return message, true
+}
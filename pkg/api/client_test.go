@@ -0,0 +1,153 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDownloadPolicy tests policy downloads, revalidation and hash checks
+func TestDownloadPolicy(t *testing.T) {
+	data := `{"version":"1.0","metadata":{"report_version":"2.1"}}`
+	sum := sha256.Sum256([]byte(data))
+	hash := hex.EncodeToString(sum[:])
+	sentHash := hash
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/policies/cis-l1/download" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer key" || r.Header.Get(HeaderClientID) != "client-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set(HeaderPolicyVersion, "2.1")
+		w.Header().Set(HeaderPolicySHA256, sentHash)
+		w.Header().Set(HeaderPolicySignature, "c2lnbmF0dXJl")
+		if r.Header.Get("If-None-Match") == `"`+hash+`"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "key", WithClientID("client-1"))
+
+	got, err := client.DownloadPolicy("cis-l1", "")
+	if err != nil {
+		t.Fatalf("DownloadPolicy() error = %v", err)
+	}
+	if string(got.Data) != data || got.Version != "2.1" || got.SHA256 != hash || got.Signature != "c2lnbmF0dXJl" || got.NotModified {
+		t.Errorf("DownloadPolicy() = %+v, want the policy data, version, hash and signature", got)
+	}
+
+	got, err = client.DownloadPolicy("cis-l1", hash)
+	if err != nil || !got.NotModified || got.Data != nil {
+		t.Errorf("DownloadPolicy() with current hash = %+v, %v; want not modified", got, err)
+	}
+
+	if _, err := client.DownloadPolicy("nist", ""); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("DownloadPolicy() unknown policy error = %v, want ErrPolicyNotFound", err)
+	}
+
+	sentHash = "0000"
+	if _, err := client.DownloadPolicy("cis-l1", ""); err == nil {
+		t.Error("DownloadPolicy() with a mismatched hash error = nil, want error")
+	}
+}
+
+// TestUploadAttachment tests that attachments are sent as multipart forms
+// and that server errors are reported
+func TestUploadAttachment(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/submissions/sub-1/attachments" || r.Header.Get("Authorization") != "Bearer key" {
+			http.NotFound(w, r)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Bad Request", Message: "file is required"})
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) == "too large" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Request Entity Too Large", Message: "Attachment is larger than 1 MB"})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Attachment{ID: 7, SubmissionID: "sub-1", FileName: header.Filename,
+			SizeBytes: int64(len(data)), Description: r.FormValue("description")})
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "key")
+	got, err := client.UploadAttachment("sub-1", "gpo.html", strings.NewReader("<html>"), "GPO report")
+	if err != nil {
+		t.Fatalf("UploadAttachment() error = %v", err)
+	}
+	if got.ID != 7 || got.FileName != "gpo.html" || got.SizeBytes != 6 || got.Description != "GPO report" {
+		t.Errorf("UploadAttachment() = %+v", got)
+	}
+
+	_, err = client.UploadAttachment("sub-1", "big.png", strings.NewReader("too large"), "")
+	if err == nil || !strings.Contains(err.Error(), "larger than 1 MB") {
+		t.Errorf("UploadAttachment() of a refused file error = %v, want the server's message", err)
+	}
+}
+
+// TestSubmitUpgradeRequired tests that a submission refused for the agent's
+// version is reported as ErrVersionUnsupported
+func TestSubmitUpgradeRequired(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUpgradeRequired)
+		json.NewEncoder(w).Encode(ErrorResponse{Message: "Agent version 1.0.0 is no longer supported"})
+	}))
+	defer ts.Close()
+
+	submission := &ComplianceSubmission{
+		ClientID:   "client-1",
+		Hostname:   "host-1",
+		ReportType: "cis",
+		Timestamp:  time.Now(),
+		Compliance: ComplianceData{Queries: []QueryResult{{Name: "q1", Status: "pass"}}},
+	}
+	_, err := NewClient(ts.URL, "key").Submit(submission)
+	if !errors.Is(err, ErrVersionUnsupported) || !strings.Contains(err.Error(), "no longer supported") {
+		t.Errorf("Submit() error = %v, want ErrVersionUnsupported with the server's message", err)
+	}
+}
+
+// TestErrorMessage tests reading the message of problem+json and earlier
+// error responses
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+		ok   bool
+	}{
+		{"problem", `{"type":"about:blank","title":"Not Found","status":404,"detail":"No client","request_id":"req-1","error":"Not Found","message":"No client","code":404}`, "No client (request ID req-1)", true},
+		{"detail only", `{"title":"Bad Request","status":400,"detail":"Bad hostname"}`, "Bad hostname", true},
+		{"legacy", `{"error":"Bad Request","message":"file is required"}`, "file is required", true},
+		{"not json", `Bad Gateway`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := errorMessage([]byte(tt.body))
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("errorMessage() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CompareValues performs smart comparison of registry values with expected values
+// Handles cases where expected value contains descriptions like "1 (Enabled)".
+// Clients use it to evaluate checks and the server to re-evaluate stored
+// evidence, so both always agree on what passes.
+func CompareValues(actual, expected string) bool {
+	// Trim whitespace and normalize
+	actual = strings.TrimSpace(actual)
+	expected = strings.TrimSpace(expected)
+
+	// Case 1: Exact match (case-insensitive)
+	if strings.EqualFold(actual, expected) {
+		return true
+	}
+
+	// Case 2: Expected format is "value (description)"
+	// Extract value before opening parenthesis and compare
+	if idx := strings.Index(expected, "("); idx > 0 {
+		expectedValue := strings.TrimSpace(expected[:idx])
+		if strings.EqualFold(actual, expectedValue) {
+			return true
+		}
+	}
+
+	// Case 3: Actual format is "value (description)", expected is just value
+	// Extract value before opening parenthesis and compare
+	if idx := strings.Index(actual, "("); idx > 0 {
+		actualValue := strings.TrimSpace(actual[:idx])
+		if strings.EqualFold(actualValue, expected) {
+			return true
+		}
+	}
+
+	// Case 4: Handle numeric comparisons (convert both to integers if possible)
+	// Registry often returns DWORD as integer, config might have "1" or "0"
+	actualInt, actualErr := strconv.ParseInt(actual, 10, 64)
+	expectedInt, expectedErr := strconv.ParseInt(expected, 10, 64)
+	if actualErr == nil && expectedErr == nil {
+		return actualInt == expectedInt
+	}
+
+	// Case 5: Expected has value before parenthesis, try numeric comparison
+	if idx := strings.Index(expected, "("); idx > 0 {
+		expectedValue := strings.TrimSpace(expected[:idx])
+		expectedInt, expectedErr := strconv.ParseInt(expectedValue, 10, 64)
+		if actualErr == nil && expectedErr == nil {
+			return actualInt == expectedInt
+		}
+	}
+
+	return false
+}
+
+// Comparison operators accepted in a check's expected_operator. An empty
+// operator means OperatorEquals.
+const (
+	OperatorEquals    = "equals"     // CompareValues semantics
+	OperatorNotEquals = "not_equals" // Anything CompareValues would not match
+	OperatorGT        = "gt"         // Numeric actual > expected
+	OperatorGTE       = "gte"        // Numeric actual >= expected
+	OperatorLT        = "lt"         // Numeric actual < expected
+	OperatorLTE       = "lte"        // Numeric actual <= expected
+	OperatorBetween   = "between"    // Numeric actual within "min,max", inclusive
+	OperatorRegex     = "regex"      // actual matches the expected regular expression
+	OperatorOneOf     = "one_of"     // actual equals one of "a,b,c" or "[a, b, c]"
+	OperatorBitmask   = "bitmask"    // Every bit set in expected is set in actual
+	OperatorContains  = "contains"   // Every item of "a,b" is an item of the actual list
+	OperatorSubset    = "subset"     // Every item of the actual list is one of "a,b"
+)
+
+// MaxExpectedLength is the longest expected value accepted, which bounds the
+// work of compiling a regex or splitting a list on every comparison
+const MaxExpectedLength = 4096
+
+// ValidateExpected checks that operator is known and that expected is a valid
+// operand for it, so malformed checks are rejected when a policy is loaded
+// rather than failing on every client.
+func ValidateExpected(expected, operator string) error {
+	if len(expected) > MaxExpectedLength {
+		return fmt.Errorf("expected value is longer than %d bytes", MaxExpectedLength)
+	}
+	switch strings.ToLower(operator) {
+	case "", OperatorEquals, OperatorNotEquals:
+		return nil
+	case OperatorGT, OperatorGTE, OperatorLT, OperatorLTE, OperatorBitmask:
+		if _, err := parseNumber(expected); err != nil {
+			return fmt.Errorf("%s requires a numeric expected value: %w", operator, err)
+		}
+	case OperatorBetween:
+		if _, _, err := parseRange(expected); err != nil {
+			return err
+		}
+	case OperatorRegex:
+		if _, err := regexp.Compile(expected); err != nil {
+			return fmt.Errorf("invalid regex expected value: %w", err)
+		}
+	case OperatorOneOf, OperatorContains, OperatorSubset:
+		if len(parseList(expected)) == 0 {
+			return fmt.Errorf("%s requires at least one expected value", operator)
+		}
+	default:
+		return fmt.Errorf("unknown expected_operator %q", operator)
+	}
+	return nil
+}
+
+// CompareWithOperator reports whether actual satisfies expected under
+// operator. An error means the check is malformed or the actual value is not
+// a number for a numeric operator; the check then neither passes nor fails.
+func CompareWithOperator(actual, expected, operator string) (bool, error) {
+	if err := ValidateExpected(expected, operator); err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(operator) {
+	case "", OperatorEquals:
+		return CompareValues(actual, expected), nil
+	case OperatorNotEquals:
+		return !CompareValues(actual, expected), nil
+	case OperatorRegex:
+		return regexp.MustCompile(expected).MatchString(strings.TrimSpace(actual)), nil
+	case OperatorOneOf:
+		for _, candidate := range parseList(expected) {
+			if CompareValues(actual, candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case OperatorContains, OperatorSubset:
+		return CompareList(parseList(actual), expected, operator)
+	}
+
+	value, err := parseNumber(actual)
+	if err != nil {
+		return false, fmt.Errorf("actual value %q is not numeric: %w", actual, err)
+	}
+
+	switch strings.ToLower(operator) {
+	case OperatorBetween:
+		low, high, _ := parseRange(expected)
+		return value.cmp(low) >= 0 && value.cmp(high) <= 0, nil
+	case OperatorBitmask:
+		mask, _ := parseNumber(expected)
+		return value.bits&mask.bits == mask.bits, nil
+	}
+
+	want, _ := parseNumber(expected)
+	switch strings.ToLower(operator) {
+	case OperatorGT:
+		return value.cmp(want) > 0, nil
+	case OperatorGTE:
+		return value.cmp(want) >= 0, nil
+	case OperatorLT:
+		return value.cmp(want) < 0, nil
+	default: // OperatorLTE
+		return value.cmp(want) <= 0, nil
+	}
+}
+
+// CompareList reports whether the items of a list value, such as a
+// REG_MULTI_SZ, satisfy expected under operator. Equality ignores the order
+// of items; other operators not specific to lists compare the items joined
+// as ReadValue renders them.
+func CompareList(actual []string, expected, operator string) (bool, error) {
+	if err := ValidateExpected(expected, operator); err != nil {
+		return false, err
+	}
+
+	want := parseList(expected)
+	switch strings.ToLower(operator) {
+	case "", OperatorEquals:
+		return containsAll(actual, want) && containsAll(want, actual), nil
+	case OperatorNotEquals:
+		return !(containsAll(actual, want) && containsAll(want, actual)), nil
+	case OperatorContains:
+		return containsAll(actual, want), nil
+	case OperatorSubset:
+		return containsAll(want, actual), nil
+	default:
+		return CompareWithOperator(strings.Join(actual, ", "), expected, operator)
+	}
+}
+
+// containsAll reports whether every item of items matches an item of list,
+// as CompareValues matches
+func containsAll(list, items []string) bool {
+	for _, item := range items {
+		found := false
+		for _, candidate := range list {
+			if CompareValues(candidate, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// DescribeExpected renders an expected value and operator for people, such
+// as ">= 14" or "one of [TLS1.2, TLS1.3]"
+func DescribeExpected(expected, operator string) string {
+	switch strings.ToLower(operator) {
+	case OperatorNotEquals:
+		return "not " + expected
+	case OperatorGT:
+		return "> " + expected
+	case OperatorGTE:
+		return ">= " + expected
+	case OperatorLT:
+		return "< " + expected
+	case OperatorLTE:
+		return "<= " + expected
+	case OperatorBetween:
+		return "between " + expected
+	case OperatorRegex:
+		return "matches /" + expected + "/"
+	case OperatorOneOf:
+		return "one of [" + strings.Join(parseList(expected), ", ") + "]"
+	case OperatorBitmask:
+		return "has bits " + expected
+	case OperatorContains:
+		return "contains [" + strings.Join(parseList(expected), ", ") + "]"
+	case OperatorSubset:
+		return "only [" + strings.Join(parseList(expected), ", ") + "]"
+	default:
+		return expected
+	}
+}
+
+// number is a parsed registry number. REG_QWORD values go up to 2^64-1,
+// beyond int64, while expected values may be negative, so the bits are
+// kept unsigned, negative numbers in two's complement.
+type number struct {
+	bits     uint64
+	negative bool
+}
+
+// cmp returns -1, 0 or +1 as n is less than, equal to or greater than o
+func (n number) cmp(o number) int {
+	switch {
+	case n.negative != o.negative:
+		if n.negative {
+			return -1
+		}
+		return 1
+	case n.bits < o.bits:
+		return -1
+	case n.bits > o.bits:
+		return 1
+	}
+	return 0
+}
+
+func (n number) String() string {
+	if n.negative {
+		return strconv.FormatInt(int64(n.bits), 10)
+	}
+	return strconv.FormatUint(n.bits, 10)
+}
+
+// parseNumber parses a registry number, ignoring any "(description)" suffix.
+// Numbers are decimal, leading zeros included, or hexadecimal with a 0x
+// prefix such as 0x1F.
+func parseNumber(s string) (number, error) {
+	s = strings.TrimSpace(s)
+	if idx := strings.Index(s, "("); idx > 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+
+	sign, digits := "", s
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, digits = s[:1], s[1:]
+	}
+	base := 10
+	if len(digits) > 2 && (strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X")) {
+		base, digits = 16, digits[2:]
+	}
+
+	if sign == "-" {
+		value, err := strconv.ParseInt("-"+digits, base, 64)
+		if err != nil {
+			return number{}, err
+		}
+		return number{bits: uint64(value), negative: value < 0}, nil
+	}
+	value, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return number{}, err
+	}
+	return number{bits: value}, nil
+}
+
+// parseRange parses a "min,max" range operand
+func parseRange(s string) (number, number, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return number{}, number{}, fmt.Errorf("between requires an expected value of the form \"min,max\"")
+	}
+	low, err := parseNumber(parts[0])
+	if err != nil {
+		return number{}, number{}, fmt.Errorf("invalid range minimum: %w", err)
+	}
+	high, err := parseNumber(parts[1])
+	if err != nil {
+		return number{}, number{}, fmt.Errorf("invalid range maximum: %w", err)
+	}
+	if low.cmp(high) > 0 {
+		return number{}, number{}, fmt.Errorf("range minimum %s is greater than maximum %s", low, high)
+	}
+	return low, high, nil
+}
+
+// parseList splits a list operand or value, with or without surrounding
+// brackets
+func parseList(s string) []string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
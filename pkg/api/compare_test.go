@@ -0,0 +1,257 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestCompareValues tests the default equality comparison
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		actual   string
+		expected string
+		want     bool
+	}{
+		{"Enabled", "enabled", true},
+		{"1", "1 (Enabled)", true},
+		{"1 (Enabled)", "1", true},
+		{"01", "1", true},
+		{"0", "1 (Enabled)", false},
+		{"TLS1.2", "TLS1.3", false},
+	}
+
+	for _, tt := range tests {
+		if got := CompareValues(tt.actual, tt.expected); got != tt.want {
+			t.Errorf("CompareValues(%q, %q) = %v, want %v", tt.actual, tt.expected, got, tt.want)
+		}
+	}
+}
+
+// TestCompareWithOperator tests each expected operator
+func TestCompareWithOperator(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+		operator string
+		want     bool
+		wantErr  bool
+	}{
+		{"empty operator is equals", "1", "1 (Enabled)", "", true, false},
+		{"not equals", "0", "1", "not_equals", true, false},
+		{"gte equal", "14", "14", "gte", true, false},
+		{"gte below", "8", "14", "gte", false, false},
+		{"gt", "15", "14", "gt", true, false},
+		{"lte with description", "30 (Minutes)", "60", "lte", true, false},
+		{"lt", "60", "60", "lt", false, false},
+		{"operator is case-insensitive", "20", "14", "GTE", true, false},
+		{"between inside", "5", "1,10", "between", true, false},
+		{"between outside", "11", "1,10", "between", false, false},
+		{"regex match", "TLS1.2", `^TLS1\.[23]$`, "regex", true, false},
+		{"regex no match", "TLS1.0", `^TLS1\.[23]$`, "regex", false, false},
+		{"one of brackets", "TLS1.3", "[TLS1.2, TLS1.3]", "one_of", true, false},
+		{"one of plain list", "tls1.2", "TLS1.2,TLS1.3", "one_of", true, false},
+		{"one of missing", "TLS1.0", "TLS1.2,TLS1.3", "one_of", false, false},
+		{"bitmask set", "7", "5", "bitmask", true, false},
+		{"bitmask hex", "255", "0x80", "bitmask", true, false},
+		{"bitmask missing bit", "3", "4", "bitmask", false, false},
+		{"leading zero is decimal", "010", "9", "gt", true, false},
+		{"leading zero with 8 and 9", "09", "08", "gt", true, false},
+		{"uppercase hex", "0X1F", "31", "gte", true, false},
+		{"qword above int64", "18446744073709551615", "9223372036854775807", "gt", true, false},
+		{"qword bitmask", "0xFFFFFFFFFFFFFFFF", "0x8000000000000000", "bitmask", true, false},
+		{"negative below qword", "-1", "18446744073709551615", "lt", true, false},
+		{"negative range", "-5", "-10,-1", "between", true, false},
+		{"underscores rejected", "1_000", "1", "gte", false, true},
+		{"octal prefix rejected", "0o17", "1", "gte", false, true},
+		{"non-numeric actual", "Enabled", "14", "gte", false, true},
+		{"non-numeric expected", "14", "many", "gte", false, true},
+		{"invalid regex", "x", "[", "regex", false, true},
+		{"inverted range", "5", "10,1", "between", false, true},
+		{"unknown operator", "1", "1", "approx", false, true},
+		{"contains in rendered list", "Netlogon, LanmanServer", "lanmanserver", "contains", true, false},
+		{"subset of rendered list", "TLS1.2, TLS1.3", "[TLS1.2, TLS1.3, TLS1.4]", "subset", true, false},
+		{"contains without items", "a", " , ", "contains", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareWithOperator(tt.actual, tt.expected, tt.operator)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareWithOperator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CompareWithOperator(%q, %q, %q) = %v, want %v", tt.actual, tt.expected, tt.operator, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDescribeExpected tests rendering expected values for people
+func TestDescribeExpected(t *testing.T) {
+	tests := []struct {
+		expected string
+		operator string
+		want     string
+	}{
+		{"1 (Enabled)", "", "1 (Enabled)"},
+		{"14", "gte", ">= 14"},
+		{"TLS1.2,TLS1.3", "one_of", "one of [TLS1.2, TLS1.3]"},
+		{`^TLS`, "regex", "matches /^TLS/"},
+		{"a, b", "contains", "contains [a, b]"},
+		{"[a,b]", "subset", "only [a, b]"},
+	}
+
+	for _, tt := range tests {
+		if got := DescribeExpected(tt.expected, tt.operator); got != tt.want {
+			t.Errorf("DescribeExpected(%q, %q) = %q, want %q", tt.expected, tt.operator, got, tt.want)
+		}
+	}
+}
+
+func TestCompareList(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   []string
+		expected string
+		operator string
+		want     bool
+		wantErr  bool
+	}{
+		{"equals in any order", []string{"b", "A"}, "[a, b]", "", true, false},
+		{"equals extra item", []string{"a", "b", "c"}, "a,b", "equals", false, false},
+		{"not equals", []string{"a"}, "a,b", "not_equals", true, false},
+		{"contains", []string{"Netlogon", "LanmanServer", "Spooler"}, "spooler,netlogon", "contains", true, false},
+		{"contains missing", []string{"Netlogon"}, "Spooler", "contains", false, false},
+		{"subset", []string{"TLS1.2"}, "TLS1.2,TLS1.3", "subset", true, false},
+		{"subset extra item", []string{"TLS1.0", "TLS1.2"}, "TLS1.2,TLS1.3", "subset", false, false},
+		{"empty list is a subset", nil, "a", "subset", true, false},
+		{"item with comma", []string{"a, b", "c"}, "c", "contains", true, false},
+		{"regex on joined items", []string{"a", "b"}, `^a, b$`, "regex", true, false},
+		{"numeric operator", []string{"1", "2"}, "1", "gte", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareList(tt.actual, tt.expected, tt.operator)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CompareList(%q, %q, %q) = %v, want %v", tt.actual, tt.expected, tt.operator, got, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzCompareWithOperator tests that parsing expected values and comparing
+// with them does not panic, that a comparison fails exactly when its
+// expected value is refused for a non-numeric operator, and that not_equals
+// is the opposite of equals
+func FuzzCompareWithOperator(f *testing.F) {
+	seeds := []struct{ actual, expected, operator string }{
+		{"1 (Enabled)", "1", ""},
+		{"14", "14", OperatorGTE},
+		{"0x1F", "0x3", OperatorBitmask},
+		{"5", "1,10", OperatorBetween},
+		{"TLS1.2", "TLS1.[23]", OperatorRegex},
+		{"TLS1.2", "[TLS1.2, TLS1.3]", OperatorOneOf},
+		{"a, b", "a,b", OperatorContains},
+		{"a", "a,b", OperatorSubset},
+		{"x", "(", OperatorRegex},
+		{"x", "10,1", OperatorBetween},
+	}
+	for _, seed := range seeds {
+		f.Add(seed.actual, seed.expected, seed.operator)
+	}
+
+	f.Fuzz(func(t *testing.T, actual, expected, operator string) {
+		validErr := ValidateExpected(expected, operator)
+		got, err := CompareWithOperator(actual, expected, operator)
+		DescribeExpected(expected, operator)
+
+		if validErr != nil {
+			if err == nil {
+				t.Fatalf("CompareWithOperator(%q, %q, %q) = %v, nil; ValidateExpected() = %v", actual, expected, operator, got, validErr)
+			}
+			return
+		}
+		switch strings.ToLower(operator) {
+		case "", OperatorEquals:
+			if err != nil {
+				t.Fatalf("CompareWithOperator(%q, %q, equals) error = %v", actual, expected, err)
+			}
+			notEqual, err := CompareWithOperator(actual, expected, OperatorNotEquals)
+			if err != nil || notEqual == got {
+				t.Fatalf("not_equals = %v, %v; equals = %v", notEqual, err, got)
+			}
+		case OperatorRegex, OperatorOneOf, OperatorContains, OperatorSubset:
+			if err != nil {
+				t.Fatalf("CompareWithOperator(%q, %q, %q) error = %v", actual, expected, operator, err)
+			}
+		}
+	})
+}
+
+// BenchmarkCompareValues measures the default equality comparison, made for
+// nearly every check a client or the server evaluates
+func BenchmarkCompareValues(b *testing.B) {
+	cases := []struct{ name, actual, expected string }{
+		{"exact", "Enabled", "enabled"},
+		{"described", "1", "1 (Enabled)"},
+		{"numeric", "01", "1"},
+		{"mismatch", "0", "1 (Enabled)"},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				CompareValues(c.actual, c.expected)
+			}
+		})
+	}
+}
+
+// BenchmarkCompareWithOperator measures each expected operator; regex and
+// list operators parse their expected value on every comparison
+func BenchmarkCompareWithOperator(b *testing.B) {
+	cases := []struct{ actual, expected, operator string }{
+		{"1", "1 (Enabled)", OperatorEquals},
+		{"3", "2", OperatorGTE},
+		{"900", "60,900", OperatorBetween},
+		{"0x5", "0x4", OperatorBitmask},
+		{"TLS 1.2", `^TLS 1\.[23]$`, OperatorRegex},
+		{"2", "1, 2, 3", OperatorOneOf},
+		{"Alice, Bob, Carol", "Bob, Carol", OperatorContains},
+	}
+	for _, c := range cases {
+		b.Run(c.operator, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := CompareWithOperator(c.actual, c.expected, c.operator); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompareList measures comparing a REG_MULTI_SZ value of 100 items
+func BenchmarkCompareList(b *testing.B) {
+	actual := make([]string, 100)
+	for i := range actual {
+		actual[i] = fmt.Sprintf("Service%d", i)
+	}
+	expected := strings.Join(actual[:10], ", ")
+	for _, operator := range []string{OperatorEquals, OperatorContains, OperatorSubset} {
+		b.Run(operator, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := CompareList(actual, expected, operator); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// SubmissionDelta replaces a submission's check results with the changes
+// since an earlier submission of the same report by the same client. The
+// submission's compliance counts are still sent in full; its queries are
+// rebuilt by the server from the base submission's and the delta.
+type SubmissionDelta struct {
+	BaseSubmissionID string        `json:"base_submission_id"`
+	Changed          []QueryResult `json:"changed,omitempty"` // New checks and checks whose result changed
+	Removed          []string      `json:"removed,omitempty"` // Names of checks no longer run
+	StateHash        string        `json:"state_hash"`        // QueryStateHash of the rebuilt queries
+}
+
+// comparableQuery is a check result without what changes on every run, so
+// that unchanged checks compare and hash equal
+func comparableQuery(q QueryResult) QueryResult {
+	q.DurationMs = 0
+	return q
+}
+
+// QueryStateHash returns the SHA-256 of a set of check results, ignoring
+// their order and durations. Client and server compare it to confirm a
+// delta rebuilt the state the client had.
+func QueryStateHash(queries []QueryResult) string {
+	sorted := make([]QueryResult, len(queries))
+	for i, q := range queries {
+		sorted[i] = comparableQuery(q)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	data, _ := json.Marshal(sorted) // QueryResult always marshals
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uniqueQueryNames reports whether every check in queries has its own name,
+// which deltas identify checks by
+func uniqueQueryNames(queries []QueryResult) bool {
+	seen := make(map[string]bool, len(queries))
+	for _, q := range queries {
+		if seen[q.Name] {
+			return false
+		}
+		seen[q.Name] = true
+	}
+	return true
+}
+
+// NewSubmissionDelta returns the delta that turns the base submission's
+// check results into current's, or false when current cannot be sent as a
+// delta because check names are not unique
+func NewSubmissionDelta(baseSubmissionID string, base, current []QueryResult) (*SubmissionDelta, bool) {
+	if !uniqueQueryNames(base) || !uniqueQueryNames(current) {
+		return nil, false
+	}
+
+	previous := make(map[string]QueryResult, len(base))
+	for _, q := range base {
+		previous[q.Name] = comparableQuery(q)
+	}
+
+	delta := &SubmissionDelta{BaseSubmissionID: baseSubmissionID, StateHash: QueryStateHash(current)}
+	for _, q := range current {
+		if old, ok := previous[q.Name]; !ok || !reflect.DeepEqual(old, comparableQuery(q)) {
+			delta.Changed = append(delta.Changed, q)
+		}
+		delete(previous, q.Name)
+	}
+	for _, q := range base {
+		if _, ok := previous[q.Name]; ok {
+			delta.Removed = append(delta.Removed, q.Name)
+		}
+	}
+	return delta, true
+}
+
+// Apply rebuilds the check results a delta describes from the base
+// submission's. Unchanged checks keep their base result without a duration;
+// changed and new checks follow in the order sent. It fails when the result
+// does not match the delta's state hash.
+func (d *SubmissionDelta) Apply(base []QueryResult) ([]QueryResult, error) {
+	if !uniqueQueryNames(base) || !uniqueQueryNames(d.Changed) {
+		return nil, fmt.Errorf("check names are not unique")
+	}
+
+	drop := make(map[string]bool, len(d.Removed)+len(d.Changed))
+	for _, name := range d.Removed {
+		drop[name] = true
+	}
+	for _, q := range d.Changed {
+		drop[q.Name] = true
+	}
+
+	queries := make([]QueryResult, 0, len(base)+len(d.Changed))
+	for _, q := range base {
+		if !drop[q.Name] {
+			queries = append(queries, comparableQuery(q))
+		}
+	}
+	queries = append(queries, d.Changed...)
+
+	if hash := QueryStateHash(queries); hash != d.StateHash {
+		return nil, fmt.Errorf("state hash %s does not match the rebuilt checks (%s)", d.StateHash, hash)
+	}
+	return queries, nil
+}
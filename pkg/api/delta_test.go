@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestSubmissionDelta tests that a delta carries only changed, new and
+// removed checks and rebuilds the current results from the base
+func TestSubmissionDelta(t *testing.T) {
+	base := []QueryResult{
+		{Name: "firewall", Status: "pass", Actual: "1", DurationMs: 3},
+		{Name: "smbv1", Status: "fail", Actual: "1", DurationMs: 4},
+		{Name: "autorun", Status: "pass", Actual: "255", DurationMs: 2},
+	}
+	current := []QueryResult{
+		{Name: "firewall", Status: "pass", Actual: "1", DurationMs: 9}, // Only the duration differs
+		{Name: "smbv1", Status: "pass", Actual: "0", DurationMs: 5},
+		{Name: "uac", Status: "pass", Actual: "1", DurationMs: 1},
+	}
+
+	delta, ok := NewSubmissionDelta("sub-1", base, current)
+	if !ok {
+		t.Fatal("NewSubmissionDelta() ok = false")
+	}
+	if delta.BaseSubmissionID != "sub-1" || len(delta.Changed) != 2 || delta.Changed[0].Name != "smbv1" || delta.Changed[1].Name != "uac" {
+		t.Errorf("changed = %+v, want smbv1 and uac", delta.Changed)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0] != "autorun" {
+		t.Errorf("removed = %v, want [autorun]", delta.Removed)
+	}
+	if delta.StateHash != QueryStateHash(current) {
+		t.Error("state hash is not the hash of the current results")
+	}
+
+	rebuilt, err := delta.Apply(base)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if QueryStateHash(rebuilt) != QueryStateHash(current) || len(rebuilt) != 3 {
+		t.Errorf("Apply() = %+v, want the current results", rebuilt)
+	}
+	if rebuilt[0].Name != "firewall" || rebuilt[0].DurationMs != 0 || rebuilt[1].DurationMs != 5 {
+		t.Errorf("Apply() = %+v, want unchanged checks without durations and changed ones as sent", rebuilt)
+	}
+
+	// A base the client did not have does not rebuild its state
+	stale := append([]QueryResult{}, base...)
+	stale[0].Actual = "0"
+	if _, err := delta.Apply(stale); err == nil {
+		t.Error("Apply() to a different base error = nil, want state hash mismatch")
+	}
+
+	duplicated := append(current, QueryResult{Name: "uac"})
+	if _, ok := NewSubmissionDelta("sub-1", base, duplicated); ok {
+		t.Error("NewSubmissionDelta() with duplicate check names ok = true")
+	}
+}
+
+// TestQueryStateHash tests that the hash ignores order and durations only
+func TestQueryStateHash(t *testing.T) {
+	a := []QueryResult{{Name: "a", Status: "pass", DurationMs: 1}, {Name: "b", Status: "fail"}}
+	b := []QueryResult{{Name: "b", Status: "fail", DurationMs: 7}, {Name: "a", Status: "pass"}}
+	if QueryStateHash(a) != QueryStateHash(b) {
+		t.Error("hash depends on order or durations")
+	}
+	b[0].Status = "pass"
+	if QueryStateHash(a) == QueryStateHash(b) {
+		t.Error("hash ignores a changed status")
+	}
+}
+
+// TestSubmitDelta tests that refused deltas are reported as ErrDeltaRejected
+func TestSubmitDelta(t *testing.T) {
+	status := http.StatusConflict
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/compliance/submit-delta" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"submission_id":"sub-2","status":"accepted"}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "key")
+	submission := &ComplianceSubmission{SubmissionID: "sub-2", Delta: &SubmissionDelta{BaseSubmissionID: "sub-1", StateHash: "abc"}}
+
+	for _, code := range []int{http.StatusConflict, http.StatusNotFound, http.StatusMethodNotAllowed} {
+		status = code
+		if _, err := client.SubmitDelta(submission); !errors.Is(err, ErrDeltaRejected) {
+			t.Errorf("status %d: error = %v, want ErrDeltaRejected", code, err)
+		}
+	}
+
+	status = http.StatusServiceUnavailable
+	if _, err := client.SubmitDelta(submission); err == nil || errors.Is(err, ErrDeltaRejected) {
+		t.Errorf("status 503: error = %v, want a retryable server error", err)
+	}
+
+	status = http.StatusOK
+	resp, err := client.SubmitDelta(submission)
+	if err != nil || resp.SubmissionID != "sub-2" {
+		t.Errorf("SubmitDelta() = %+v, %v", resp, err)
+	}
+
+	if _, err := client.SubmitDelta(&ComplianceSubmission{}); err == nil {
+		t.Error("SubmitDelta() without a delta error = nil, want error")
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"compliancetoolkit/pkg/decode"
+)
+
+// EvidenceLog is the JSON evidence log the standalone toolkit writes for each
+// scan. Only the fields needed to import it as a submission are decoded.
+type EvidenceLog struct {
+	ScanMetadata EvidenceLogMetadata          `json:"scan_metadata"`
+	MachineInfo  EvidenceLogMachine           `json:"machine_information"`
+	ScanResults  map[string]EvidenceLogResult `json:"scan_results"`
+}
+
+// EvidenceLogMetadata describes the scan that produced an evidence log
+type EvidenceLogMetadata struct {
+	ToolVersion string    `json:"tool_version"`
+	ScanID      string    `json:"scan_id"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Operator    string    `json:"operator"`
+	ReportType  string    `json:"report_type"` // Report file name without .json
+}
+
+// EvidenceLogMachine identifies the scanned machine
+type EvidenceLogMachine struct {
+	Hostname      string `json:"hostname"`
+	OSProductName string `json:"os_product_name"`
+	OSBuildNumber string `json:"os_build_number"`
+	Architecture  string `json:"processor_architecture"`
+}
+
+// EvidenceLogResult is one check in an evidence log
+type EvidenceLogResult struct {
+	CheckName     string      `json:"check_name"`
+	Description   string      `json:"description"`
+	RegistryPath  string      `json:"registry_path"`
+	ValueName     string      `json:"value_name"`
+	ExpectedValue string      `json:"expected_value,omitempty"`
+	ActualValue   interface{} `json:"actual_value"`
+	Status        string      `json:"status"` // PASS, FAIL, NOT_FOUND, ERROR
+	Timestamp     time.Time   `json:"timestamp"`
+	ErrorMessage  string      `json:"error_message,omitempty"`
+}
+
+// evidenceUnknown is written by the toolkit for machine details it could not read
+const evidenceUnknown = "UNKNOWN"
+
+// ParseEvidenceLog decodes a toolkit evidence log and checks that it can be
+// imported
+func ParseEvidenceLog(data []byte) (*EvidenceLog, error) {
+	var log EvidenceLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("invalid evidence log: %w", err)
+	}
+
+	hostname := log.MachineInfo.Hostname
+	if hostname == "" || hostname == evidenceUnknown {
+		return nil, fmt.Errorf("evidence log has no hostname")
+	}
+	if log.ScanMetadata.ReportType == "" {
+		return nil, fmt.Errorf("evidence log has no report type")
+	}
+	if log.ScanMetadata.StartTime.IsZero() {
+		return nil, fmt.Errorf("evidence log has no start time")
+	}
+	if len(log.ScanResults) == 0 {
+		return nil, fmt.Errorf("evidence log has no scan results")
+	}
+	return &log, nil
+}
+
+// SubmissionID returns a stable ID for the scan, so importing the same
+// evidence log twice produces the same submission
+func (l *EvidenceLog) SubmissionID() string {
+	key := strings.Join([]string{
+		strings.ToUpper(l.MachineInfo.Hostname),
+		l.ScanMetadata.ReportType,
+		l.ScanMetadata.ScanID,
+		l.ScanMetadata.StartTime.UTC().Format(time.RFC3339Nano),
+	}, "|")
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("evidence:"+key)).String()
+}
+
+// Submission converts the evidence log into a submission for clientID.
+// Evidence records mirror those the compliance client sends, so imported
+// scans keep their audit trail.
+func (l *EvidenceLog) Submission(clientID string) *ComplianceSubmission {
+	names := make([]string, 0, len(l.ScanResults))
+	for name := range l.ScanResults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queries := make([]QueryResult, 0, len(names))
+	evidence := make([]EvidenceRecord, 0, len(names))
+	for _, name := range names {
+		result := l.ScanResults[name]
+		if result.CheckName == "" {
+			result.CheckName = name
+		}
+
+		query := QueryResult{
+			Name:        result.CheckName,
+			Description: result.Description,
+			Status:      QueryStatusFromEvidence(result.Status),
+			Expected:    result.ExpectedValue,
+			Actual:      evidenceValue(result.ActualValue),
+			Message:     result.ErrorMessage,
+			Path:        result.RegistryPath,
+			ValueName:   result.ValueName,
+		}
+		if items, ok := evidenceList(result.ActualValue); ok {
+			query.Actual = strings.Join(items, ", ")
+			query.ActualList = items
+		} else if decoded, ok := evidenceDecoded(result.ActualValue); ok {
+			query.Actual = decoded.String()
+		}
+		record := EvidenceRecord{
+			QueryName: result.CheckName,
+			Timestamp: result.Timestamp,
+			Action:    "registry_read",
+			Result:    "success",
+			Details: map[string]interface{}{
+				"path":       result.RegistryPath,
+				"value_name": result.ValueName,
+				"source":     "toolkit_evidence_import",
+			},
+		}
+
+		switch strings.ToUpper(result.Status) {
+		case "NOT_FOUND":
+			query.Actual = "not found"
+			query.ErrorClass = ErrorClassNotFound
+			record.Result = "not_found"
+		case "ERROR":
+			query.Actual = "error"
+			query.ErrorClass = ErrorClassReadFailed
+			record.Result = "error"
+		default:
+			record.Details["actual_value"] = query.Actual
+		}
+		if result.ErrorMessage != "" {
+			record.Details["error"] = result.ErrorMessage
+		}
+
+		queries = append(queries, query)
+		evidence = append(evidence, record)
+	}
+
+	timestamp := l.ScanMetadata.EndTime
+	if timestamp.IsZero() {
+		timestamp = l.ScanMetadata.StartTime
+	}
+
+	return &ComplianceSubmission{
+		SubmissionID: l.SubmissionID(),
+		ClientID:     clientID,
+		Hostname:     l.MachineInfo.Hostname,
+		Timestamp:    timestamp,
+		ReportType:   l.ScanMetadata.ReportType,
+		Compliance:   NewComplianceData(queries),
+		Evidence:     evidence,
+		SystemInfo: SystemInfo{
+			OSVersion:    knownEvidenceValue(l.MachineInfo.OSProductName),
+			BuildNumber:  knownEvidenceValue(l.MachineInfo.OSBuildNumber),
+			Architecture: knownEvidenceValue(l.MachineInfo.Architecture),
+		},
+	}
+}
+
+// evidenceValue renders an actual value from an evidence log as text
+func evidenceValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}, map[string]interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// evidenceList returns the items of a multi-string value, which the toolkit
+// records as a JSON array of strings
+func evidenceList(v interface{}) ([]string, bool) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		item, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// evidenceDecoded returns a decoded binary value, which the toolkit records
+// as an object of its decoder and fields, so it is compared as the text the
+// toolkit compared
+func evidenceDecoded(v interface{}) (decode.Value, bool) {
+	object, ok := v.(map[string]interface{})
+	if !ok || object["decoder"] == nil || object["fields"] == nil {
+		return decode.Value{}, false
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return decode.Value{}, false
+	}
+	var decoded decode.Value
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Decoder == "" {
+		return decode.Value{}, false
+	}
+	return decoded, true
+}
+
+// knownEvidenceValue returns s, or "" when the toolkit could not read it
+func knownEvidenceValue(s string) string {
+	if s == evidenceUnknown {
+		return ""
+	}
+	return s
+}
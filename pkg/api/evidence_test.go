@@ -0,0 +1,98 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+const testEvidenceLog = `{
+  "scan_metadata": {
+    "tool_version": "Compliance Toolkit v1.0.0",
+    "scan_id": "SCAN_20250104_120000",
+    "start_time": "2025-01-04T12:00:00Z",
+    "end_time": "2025-01-04T12:00:05Z",
+    "operator": "admin",
+    "report_type": "NIST_800_171_compliance"
+  },
+  "machine_information": {
+    "hostname": "WS01",
+    "os_product_name": "Windows 11 Pro",
+    "os_build_number": "UNKNOWN",
+    "processor_architecture": "AMD64"
+  },
+  "scan_results": {
+    "uac": {"check_name": "uac", "registry_path": "SOFTWARE\\Policies", "value_name": "EnableLUA", "actual_value": 1, "status": "PASS"},
+    "smb1": {"check_name": "smb1", "actual_value": null, "status": "NOT_FOUND", "error_message": "Registry key or value does not exist"},
+    "build": {"check_name": "build", "actual_value": 4294967295, "status": "PASS"},
+    "protocols": {"check_name": "protocols", "actual_value": ["TLS1.2", "TLS1.3"], "status": "PASS"},
+    "shutdown": {"check_name": "shutdown", "actual_value": {"decoder": "filetime", "fields": [{"name": "Time", "value": "2025-01-04T11:00:00Z"}]}, "status": "PASS"},
+    "audit": {"check_name": "audit", "actual_value": null, "status": "ERROR", "error_message": "access denied"}
+  }
+}`
+
+// TestEvidenceLogSubmission tests converting a toolkit evidence log into a submission
+func TestEvidenceLogSubmission(t *testing.T) {
+	log, err := ParseEvidenceLog([]byte(testEvidenceLog))
+	if err != nil {
+		t.Fatalf("ParseEvidenceLog() error = %v", err)
+	}
+
+	submission := log.Submission("client-WS01")
+	if err := submission.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if submission.Hostname != "WS01" || submission.ReportType != "NIST_800_171_compliance" {
+		t.Errorf("submission = %s %s", submission.Hostname, submission.ReportType)
+	}
+	if !submission.Timestamp.Equal(log.ScanMetadata.EndTime) {
+		t.Errorf("Timestamp = %v, want end time", submission.Timestamp)
+	}
+	if submission.SystemInfo.OSVersion != "Windows 11 Pro" || submission.SystemInfo.BuildNumber != "" {
+		t.Errorf("SystemInfo = %+v", submission.SystemInfo)
+	}
+
+	data := submission.Compliance
+	if data.PassedChecks != 4 || data.FailedChecks != 1 || data.ErrorChecks != 1 || data.OverallStatus != "non-compliant" {
+		t.Errorf("compliance = %+v", data)
+	}
+	if len(submission.Evidence) != 6 {
+		t.Errorf("len(Evidence) = %d, want 6", len(submission.Evidence))
+	}
+
+	actual := make(map[string]string)
+	for _, q := range data.Queries {
+		actual[q.Name] = q.Actual
+		if q.Name == "protocols" && len(q.ActualList) != 2 {
+			t.Errorf("protocols ActualList = %q, want the multi-string's items", q.ActualList)
+		}
+	}
+	if actual["uac"] != "1" || actual["build"] != "4294967295" || actual["smb1"] != "not found" ||
+		actual["protocols"] != "TLS1.2, TLS1.3" || actual["shutdown"] != "Time: 2025-01-04T11:00:00Z" {
+		t.Errorf("actual values = %v", actual)
+	}
+
+	// Importing the same log again must produce the same submission
+	again, _ := ParseEvidenceLog([]byte(testEvidenceLog))
+	if again.SubmissionID() != submission.SubmissionID {
+		t.Errorf("SubmissionID() is not stable")
+	}
+}
+
+// TestParseEvidenceLogRejects tests evidence logs that cannot be imported
+func TestParseEvidenceLogRejects(t *testing.T) {
+	tests := map[string]string{
+		"not json":        "{",
+		"unknown host":    strings.Replace(testEvidenceLog, `"WS01"`, `"UNKNOWN"`, 1),
+		"no report type":  strings.Replace(testEvidenceLog, `"NIST_800_171_compliance"`, `""`, 1),
+		"no scan results": `{"scan_metadata": {"report_type": "x", "start_time": "2025-01-04T12:00:00Z"}, "machine_information": {"hostname": "WS01"}, "scan_results": {}}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseEvidenceLog([]byte(data)); err == nil {
+				t.Error("ParseEvidenceLog() accepted the log")
+			}
+		})
+	}
+}
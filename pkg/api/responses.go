@@ -0,0 +1,1004 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Response types for the server's management endpoints. Every handler encodes
+// one of these (or a type from types.go) so the wire format is defined in a
+// single place that clients and contract tests can share.
+
+// StatusResponse is the generic acknowledgement returned by mutating endpoints
+type StatusResponse struct {
+	Status  string `json:"status"` // "success", "registered"
+	Message string `json:"message,omitempty"`
+}
+
+// RegistrationResponse is returned by the client registration endpoint
+type RegistrationResponse struct {
+	Status        string         `json:"status"` // "registered"
+	Message       string         `json:"message,omitempty"`
+	Compatibility *Compatibility `json:"compatibility,omitempty"`
+}
+
+// ServerInfoResponse is returned by the server root endpoint
+type ServerInfoResponse struct {
+	Service string `json:"service"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// HealthResponse is returned by the health check endpoint
+type HealthResponse struct {
+	Status      string `json:"status"` // "healthy", "unhealthy"
+	Version     string `json:"version,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Maintenance bool   `json:"maintenance,omitempty"` // The server is in maintenance mode and refuses changes
+
+	Build *BuildInfo `json:"build,omitempty"`
+}
+
+// DeleteCountResponse is returned by bulk delete endpoints
+type DeleteCountResponse struct {
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+	DeletedCount int64  `json:"deleted_count"`
+}
+
+// ConfigResponse is the sanitized server configuration returned by the settings API
+type ConfigResponse struct {
+	Server    ServerConfigInfo    `json:"server"`
+	Database  DatabaseConfigInfo  `json:"database"`
+	Auth      AuthConfigInfo      `json:"auth"`
+	Dashboard DashboardConfigInfo `json:"dashboard"`
+	Logging   LoggingConfigInfo   `json:"logging"`
+}
+
+// ServerConfigInfo describes the listener configuration
+type ServerConfigInfo struct {
+	Host string        `json:"host"`
+	Port int           `json:"port"`
+	TLS  TLSConfigInfo `json:"tls"`
+}
+
+// TLSConfigInfo describes the TLS configuration
+type TLSConfigInfo struct {
+	Enabled  bool   `json:"enabled"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+// DatabaseConfigInfo describes the database connection (credentials omitted)
+type DatabaseConfigInfo struct {
+	Type string `json:"type"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	Name string `json:"name"`
+}
+
+// AuthConfigInfo describes the authentication configuration (keys omitted)
+type AuthConfigInfo struct {
+	Enabled    bool `json:"enabled"`
+	RequireKey bool `json:"require_key"`
+	KeyCount   int  `json:"key_count"`
+}
+
+// DashboardConfigInfo describes the dashboard configuration
+type DashboardConfigInfo struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"`
+}
+
+// LoggingConfigInfo describes the logging configuration
+type LoggingConfigInfo struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// LoginMessageResponse carries the banner shown on the login page
+type LoginMessageResponse struct {
+	Message string `json:"message"`
+}
+
+// LoginResponse is returned after a successful username/password login
+type LoginResponse struct {
+	Success  bool   `json:"success"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// SessionResponse describes the authentication state of the caller
+type SessionResponse struct {
+	Status         string `json:"status"`         // "authenticated"
+	Authentication string `json:"authentication"` // "cookie"
+}
+
+// DisplayPreferences says how the dashboard shows timestamps to a user. API
+// timestamps are always RFC 3339 in UTC; these are hints for rendering them.
+type DisplayPreferences struct {
+	Timezone          string    `json:"timezone"`             // IANA time zone the user chose; empty for the default
+	Locale            string    `json:"locale"`               // BCP 47 language tag the user chose; empty for the browser's
+	EffectiveTimezone string    `json:"effective_timezone"`   // Zone to render in: the user's, else the server default; empty for the browser's
+	UTCOffset         string    `json:"utc_offset,omitempty"` // Current offset of the effective zone, e.g. "+02:00"
+	ServerTime        time.Time `json:"server_time"`          // Server clock, in UTC
+}
+
+// UserInfo represents a dashboard user account (password hash never included)
+type UserInfo struct {
+	ID        int          `json:"id"`
+	Username  string       `json:"username"`
+	Role      string       `json:"role"`            // "admin", "operator", "viewer", "auditor"
+	Team      string       `json:"team,omitempty"`  // Team whose policies the user may edit
+	Scope     *ClientScope `json:"scope,omitempty"` // Clients an operator can see
+	CreatedAt string       `json:"created_at"`
+	LastLogin string       `json:"last_login,omitempty"`
+	Email     string       `json:"email,omitempty"`
+
+	DisplayName string `json:"display_name,omitempty"`
+
+	// MustChangePassword is set for invited users who have not yet accepted
+	// their invitation
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+}
+
+// UserImportResponse is returned by the bulk user import endpoint
+type UserImportResponse struct {
+	Status  string             `json:"status"`
+	Message string             `json:"message"`
+	Created int                `json:"created"`
+	Skipped int                `json:"skipped"` // Usernames that already exist
+	Failed  int                `json:"failed"`
+	Users   []UserImportResult `json:"users"`
+}
+
+// UserImportResult is the outcome of one row of an imported CSV file
+type UserImportResult struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Status   string `json:"status"` // "created", "skipped" or "failed"
+	Error    string `json:"error,omitempty"`
+	Emailed  bool   `json:"emailed"` // Whether the invitation was sent
+
+	// InviteURL is returned when the invitation could not be emailed, for
+	// the admin to pass on; it sets the user's password once
+	InviteURL string `json:"invite_url,omitempty"`
+}
+
+// TokenPasswordRequest sets a user's password with the token of an
+// invitation or password reset link
+type TokenPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// PasswordResetRequest asks for a password reset link to be emailed to the
+// address on the user's account
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+// PasswordResetTokenResponse describes a valid password reset token
+type PasswordResetTokenResponse struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ClientScope limits an operator to the clients in any of the listed
+// organizations (the domain a client reports) or carrying any of the tags
+type ClientScope struct {
+	Orgs []string `json:"orgs,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// UserScopeRequest replaces an operator's client scope
+type UserScopeRequest struct {
+	Username string      `json:"username"`
+	Scope    ClientScope `json:"scope"`
+}
+
+// UserTeamRequest sets the team of a user; an empty team removes the user
+// from their team
+type UserTeamRequest struct {
+	Username string `json:"username"`
+	Team     string `json:"team"`
+}
+
+// NotificationPreferences are the email notifications a user receives.
+// Invitations and password resets are always sent.
+type NotificationPreferences struct {
+	Alerts       bool   `json:"alerts"`         // Alerts on clients the user can see
+	APIKeyExpiry bool   `json:"api_key_expiry"` // Warnings before the user's API keys expire
+	Digest       string `json:"digest"`         // Compliance summary: "off", "daily" or "weekly"
+}
+
+// UserProfile is a user's own account information
+type UserProfile struct {
+	Username      string                  `json:"username"`
+	Role          string                  `json:"role"`
+	DisplayName   string                  `json:"display_name"`
+	Email         string                  `json:"email"`
+	Notifications NotificationPreferences `json:"notifications"`
+}
+
+// UserProfileRequest updates a user's profile. Username is required when an
+// admin updates another user (POST /api/v1/users/profile) and ignored on
+// PUT /api/v1/profile. Nil Notifications keeps the current preferences.
+type UserProfileRequest struct {
+	Username      string                   `json:"username,omitempty"`
+	DisplayName   string                   `json:"display_name"`
+	Email         string                   `json:"email"`
+	Notifications *NotificationPreferences `json:"notifications,omitempty"`
+}
+
+// ClientTagsRequest replaces the tags of a client
+type ClientTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// ClientGroup is a client tag, the group of clients carrying it, and what
+// is assigned to the group
+type ClientGroup struct {
+	Tag        string      `json:"tag"`
+	Clients    int         `json:"clients"`
+	Policies   []string    `json:"policies"` // IDs of the policies the group's clients run
+	AlertRules []AlertRule `json:"alert_rules"`
+}
+
+// ClientGroupListResponse is returned by the client groups endpoint
+type ClientGroupListResponse struct {
+	Groups []ClientGroup `json:"groups"`
+	Count  int           `json:"count"`
+}
+
+// PolicyAssignmentRequest replaces the policies assigned to a client group
+// or a single client
+type PolicyAssignmentRequest struct {
+	PolicyIDs []string `json:"policy_ids"`
+}
+
+// PolicyAssignment is a policy a client runs and how it was assigned
+type PolicyAssignment struct {
+	PolicyID   string `json:"policy_id"`
+	Group      string `json:"group,omitempty"` // Tag the policy is assigned through; empty when assigned to the client itself
+	AssignedBy string `json:"assigned_by,omitempty"`
+	AssignedAt string `json:"assigned_at,omitempty"`
+}
+
+// ClientPoliciesResponse lists the policies assigned to a client, directly
+// and through its groups. A policy assigned several ways is listed once per
+// assignment.
+type ClientPoliciesResponse struct {
+	ClientID string             `json:"client_id"`
+	Policies []PolicyAssignment `json:"policies"`
+}
+
+// AlertRule changes how an alert type is raised for the clients of a group.
+// A disabled rule mutes the alert type; an enabled one may change its
+// severity and grace period.
+type AlertRule struct {
+	ID           int    `json:"id"`
+	AlertType    string `json:"alert_type"` // e.g. "missed_run"
+	Group        string `json:"group"`      // Client tag
+	Enabled      bool   `json:"enabled"`
+	Severity     string `json:"severity,omitempty"`      // "info", "warning" or "critical"; empty keeps the default
+	GraceMinutes int    `json:"grace_minutes,omitempty"` // How late a run may be; 0 keeps alerts.missed_run_grace
+	CreatedBy    string `json:"created_by,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+}
+
+// NotificationTemplate replaces the wording of a notification email for the
+// users of an organization, or for every user when Org is empty. Subject and
+// Body are Go text templates over the fields listed in Fields.
+type NotificationTemplate struct {
+	Name      string   `json:"name"`          // "password_reset", "invitation" or "api_key_expiry"
+	Org       string   `json:"org,omitempty"` // Client domain; empty for the server default
+	Subject   string   `json:"subject"`
+	Body      string   `json:"body"`
+	Fields    []string `json:"fields,omitempty"` // Fields the templates may use, in built-in templates only
+	UpdatedBy string   `json:"updated_by,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+}
+
+// NotificationTemplateListResponse lists the stored notification templates
+// and the built-in ones they replace
+type NotificationTemplateListResponse struct {
+	Templates []NotificationTemplate `json:"templates"`
+	Builtin   []NotificationTemplate `json:"builtin"`
+}
+
+// RenderedNotification is a notification email rendered from a template
+type RenderedNotification struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// APIKeyInfo represents a database-backed API key (key material never included)
+type APIKeyInfo struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	KeyPrefix string `json:"key_prefix"` // First 8 chars for display
+	CreatedBy string `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+	LastUsed  string `json:"last_used,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	IsActive  bool   `json:"is_active"`
+	// ExpiresSoon is set on active keys expiring within the server's
+	// auth.api_key_expiry_warning
+	ExpiresSoon bool `json:"expires_soon,omitempty"`
+}
+
+// APIKeyCreatedResponse is returned once when a new API key is generated.
+// APIKey is the only time the full key is ever shown.
+type APIKeyCreatedResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	APIKey  string `json:"api_key"`
+	Prefix  string `json:"prefix"`
+	Name    string `json:"name"`
+}
+
+// APIUsageResponse describes the requests made with an API key or by a
+// user over the last Days days, in total, per endpoint and per day. An error
+// is a response with status 400 or above; ErrorRate is errors per request.
+type APIUsageResponse struct {
+	APIKeyID  int                `json:"api_key_id,omitempty"`
+	Username  string             `json:"username,omitempty"`
+	Days      int                `json:"days"`
+	Requests  int64              `json:"requests"`
+	Errors    int64              `json:"errors"`
+	ErrorRate float64            `json:"error_rate"`
+	LastUsed  *time.Time         `json:"last_used,omitempty"`
+	Endpoints []APIUsageEndpoint `json:"endpoints"` // Most recently used first
+	Daily     []APIUsageDay      `json:"daily"`     // Oldest day first; days without requests are left out
+}
+
+// APIUsageEndpoint is the usage of one endpoint, named by its route pattern
+// such as "GET /api/v1/clients/{client_id}"
+type APIUsageEndpoint struct {
+	Endpoint  string    `json:"endpoint"`
+	Requests  int64     `json:"requests"`
+	Errors    int64     `json:"errors"`
+	ErrorRate float64   `json:"error_rate"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// APIUsageDay is one UTC day's usage
+type APIUsageDay struct {
+	Date     string `json:"date"` // YYYY-MM-DD
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// Policy represents a compliance policy managed by the server
+type Policy struct {
+	ID          int    `json:"id"`
+	PolicyID    string `json:"policy_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Framework   string `json:"framework"`
+	Version     string `json:"version"`
+	Category    string `json:"category"`
+	Author      string `json:"author"`
+	Status      string `json:"status"`               // "active", "inactive", "draft"
+	PolicyData  string `json:"policy_data"`          // JSON report configuration
+	Owner       string `json:"owner,omitempty"`      // User who may edit the policy
+	OwnerTeam   string `json:"owner_team,omitempty"` // Team whose members may edit the policy
+	Signature   string `json:"signature,omitempty"`  // Publisher's base64 Ed25519 signature over PolicySigningPayload
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+
+	RetentionDays int               `json:"retention_days,omitempty"` // Days submissions of its report type are kept, overriding the retention limits (0 follows them)
+	Provenance    *PolicyProvenance `json:"provenance,omitempty"`     // Set for policies imported from a policy pack
+}
+
+// PolicyProvenance records the signed policy pack a policy was imported from
+type PolicyProvenance struct {
+	Source      string    `json:"source"`              // URL the pack was fetched from
+	Pack        string    `json:"pack"`                // Pack name from its manifest
+	PackVersion string    `json:"pack_version"`        // Pack version from its manifest
+	Publisher   string    `json:"publisher,omitempty"` // Publisher named in the manifest
+	PackSHA256  string    `json:"pack_sha256"`         // Hex SHA-256 of the archive
+	SignedBy    string    `json:"signed_by"`           // Base64 trusted key the pack signature verified against
+	ImportedBy  string    `json:"imported_by,omitempty"`
+	ImportedAt  time.Time `json:"imported_at"`
+}
+
+// PolicyOwnerRequest replaces the owners of a policy. Leaving both empty
+// makes the policy editable by admins only.
+type PolicyOwnerRequest struct {
+	Owner     string `json:"owner"`
+	OwnerTeam string `json:"owner_team"`
+}
+
+// PolicyCreatedResponse is returned after a policy is created
+type PolicyCreatedResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	PolicyID string `json:"policy_id"`
+}
+
+// PolicyPackImportRequest imports a signed policy pack from a URL
+// (POST /api/v1/policies/import-url)
+type PolicyPackImportRequest struct {
+	URL          string `json:"url"`
+	SignatureURL string `json:"signature_url,omitempty"` // Detached signature; defaults to URL + ".sig"
+	Update       bool   `json:"update,omitempty"`        // Replace policies that already exist instead of skipping them
+}
+
+// PolicyImportResponse summarizes a bulk policy import
+type PolicyImportResponse struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message,omitempty"`
+	Imported int      `json:"imported"`
+	Updated  int      `json:"updated,omitempty"` // Existing policies replaced by a pack import with update
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors"`
+}
+
+// Outcomes of importing one evidence log
+const (
+	EvidenceImported = "imported" // Stored as a new submission
+	EvidenceSkipped  = "skipped"  // Already imported
+	EvidenceFailed   = "failed"   // Invalid log or storage error
+)
+
+// EvidenceImportResult is the outcome of importing one evidence log
+type EvidenceImportResult struct {
+	Source       string `json:"source,omitempty"` // File name or position in the request
+	Status       string `json:"status"`           // EvidenceImported, EvidenceSkipped or EvidenceFailed
+	SubmissionID string `json:"submission_id,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+	ReportType   string `json:"report_type,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// EvidenceImportResponse summarizes an import of toolkit evidence logs
+type EvidenceImportResponse struct {
+	Status   string                 `json:"status"`
+	Message  string                 `json:"message,omitempty"`
+	Imported int                    `json:"imported"`
+	Skipped  int                    `json:"skipped"`
+	Failed   int                    `json:"failed"`
+	Results  []EvidenceImportResult `json:"results"`
+}
+
+// ClientMergeRequest names the client to merge into the client in the path
+type ClientMergeRequest struct {
+	SourceClientID string `json:"source_client_id"`
+}
+
+// ClientMergeResponse reports what a client merge moved from the source
+// client, which no longer exists afterwards, to the target client
+type ClientMergeResponse struct {
+	Status             string `json:"status"`
+	Message            string `json:"message,omitempty"`
+	TargetClientID     string `json:"target_client_id"`
+	SourceClientID     string `json:"source_client_id"`
+	Submissions        int64  `json:"submissions"`
+	Telemetry          int64  `json:"telemetry"`
+	PolicyAssignments  int64  `json:"policy_assignments"`
+	Alerts             int64  `json:"alerts"`
+	Commands           int64  `json:"commands"`
+	MaintenanceWindows int64  `json:"maintenance_windows"`
+}
+
+// DuplicateClientGroup lists the clients registered under one hostname,
+// most recently seen first
+type DuplicateClientGroup struct {
+	Hostname string       `json:"hostname"` // Lower case
+	Clients  []ClientInfo `json:"clients"`
+}
+
+// HeartbeatResponse acknowledges a client heartbeat and carries any
+// commands queued for the client since its last heartbeat
+type HeartbeatResponse struct {
+	Status     string    `json:"status"`
+	ServerTime time.Time `json:"server_time"`
+	Commands   []Command `json:"commands,omitempty"`
+	// Policies assigned to the client, directly or through its groups,
+	// that it runs in addition to its configured reports. Null when the
+	// server could not look them up; clients then keep the last list.
+	Policies []string `json:"policies"`
+
+	// Compatibility is the server's verdict on the client's agent version
+	// and schema versions. Servers predating the handshake leave it out.
+	Compatibility *Compatibility `json:"compatibility,omitempty"`
+}
+
+// Compatibility statuses
+const (
+	CompatibilityOK          = "ok"
+	CompatibilityDeprecated  = "deprecated"  // Still accepted, but due for an upgrade
+	CompatibilityUnsupported = "unsupported" // Submissions are refused until the client is upgraded
+)
+
+// Compatibility answers a client's version handshake on registration and
+// with every heartbeat
+type Compatibility struct {
+	Status         string   `json:"status"` // ok, deprecated or unsupported
+	ServerVersion  string   `json:"server_version"`
+	SchemaVersions []int    `json:"schema_versions"`       // Submission schema versions the server accepts
+	MinVersion     string   `json:"min_version,omitempty"` // Oldest agent version whose submissions are accepted
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// AlertListResponse is returned by the alerts endpoint
+type AlertListResponse struct {
+	Alerts []Alert `json:"alerts"`
+	Count  int     `json:"count"`
+}
+
+// AdminAuditListResponse is returned by the admin audit endpoint, newest
+// entry first
+type AdminAuditListResponse struct {
+	Entries []AdminAuditEntry `json:"entries"`
+	Count   int               `json:"count"`
+}
+
+// SubmissionArchiveListResponse is returned by the archive listing endpoint,
+// newest archive first
+type SubmissionArchiveListResponse struct {
+	Archives []SubmissionArchive `json:"archives"`
+	Count    int                 `json:"count"`
+}
+
+// MaintenanceWindow is a period during which alerts for the clients it covers
+// are suppressed and their submissions are flagged as collected during
+// maintenance. A window without Cron is a one-off window from StartsAt to
+// EndsAt; with Cron it recurs for DurationMinutes from each scheduled start,
+// optionally bounded by StartsAt and EndsAt. A window with none of ClientID,
+// HostnamePattern and Group covers every client.
+type MaintenanceWindow struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description,omitempty"`
+	ClientID        string     `json:"client_id,omitempty"`
+	HostnamePattern string     `json:"hostname_pattern,omitempty"` // Glob, e.g. "SQL-*"
+	Group           string     `json:"group,omitempty"`            // Client tag
+	Cron            string     `json:"cron,omitempty"`             // Start of each occurrence, e.g. "0 22 * * 2"
+	DurationMinutes int        `json:"duration_minutes,omitempty"`
+	Timezone        string     `json:"timezone,omitempty"` // IANA zone the cron expression uses (default UTC)
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Enabled         bool       `json:"enabled"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	CreatedAt       string     `json:"created_at,omitempty"`
+}
+
+// MaintenanceWindowCreatedResponse is returned after a maintenance window is created
+type MaintenanceWindowCreatedResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	ID      int    `json:"id"`
+}
+
+// MaintenanceMode is the server-wide maintenance mode, set for database
+// migrations and upgrades (GET and PUT /api/v1/settings/maintenance-mode).
+// While it is enabled the server answers requests that change data with 503
+// Service Unavailable and Retry-After, so clients cache their submissions
+// and retry, and the dashboard is read-only.
+type MaintenanceMode struct {
+	Enabled           bool       `json:"enabled"`
+	Message           string     `json:"message,omitempty"`             // Shown in the dashboard banner and 503 responses
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"` // Sent as Retry-After
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	StartedBy         string     `json:"started_by,omitempty"`
+}
+
+// HeaderMaintenanceMode is set to "on" on every response while the server is
+// in maintenance mode
+const HeaderMaintenanceMode = "X-Maintenance-Mode"
+
+// CommandRequest queues a command for one client, for every client whose
+// hostname matches HostnamePattern or for every client in Group
+type CommandRequest struct {
+	Type            string            `json:"type"`
+	ClientID        string            `json:"client_id,omitempty"`
+	HostnamePattern string            `json:"hostname_pattern,omitempty"`
+	Group           string            `json:"group,omitempty"` // Client tag
+	Reports         []string          `json:"reports,omitempty"`
+	Arguments       map[string]string `json:"arguments,omitempty"`
+}
+
+// CommandsCreatedResponse lists the commands queued by a command request
+type CommandsCreatedResponse struct {
+	Status   string    `json:"status"`
+	Message  string    `json:"message,omitempty"`
+	Commands []Command `json:"commands"`
+}
+
+// CommandSigningKeyResponse publishes the key clients use to verify commands
+type CommandSigningKeyResponse struct {
+	Algorithm string `json:"algorithm"`  // "ed25519"
+	PublicKey string `json:"public_key"` // Base64 raw public key
+}
+
+// Policy rollout statuses
+const (
+	RolloutActive = "active" // Clients in the cohort get the candidate
+	RolloutHalted = "halted" // Every client gets the current version until the rollout is resumed
+)
+
+// PolicyRolloutRequest starts or changes the staged rollout of a policy
+// (PUT /api/v1/policies/{policy_id}/rollout). PolicyData, with its
+// Signature, stages a new candidate; without it the running rollout's
+// cohort and threshold are changed, and a halted rollout resumes.
+type PolicyRolloutRequest struct {
+	PolicyData         string   `json:"policy_data,omitempty"`          // Candidate report configuration
+	Signature          string   `json:"signature,omitempty"`            // Publisher's signature over the candidate
+	Percent            int      `json:"percent"`                        // Share of clients, 0-100, that get the candidate
+	Rings              []string `json:"rings,omitempty"`                // Client tags whose clients get the candidate regardless of Percent
+	MaxFailureIncrease float64  `json:"max_failure_increase,omitempty"` // Percentage points the candidate's failure rate may exceed the current version's by
+	MinSubmissions     int      `json:"min_submissions,omitempty"`      // Submissions of each version needed before they are compared
+}
+
+// PolicyRollout is the staged rollout of a candidate version of a policy.
+// The policy keeps serving its current version to clients outside the
+// cohort until the candidate is promoted.
+type PolicyRollout struct {
+	PolicyID           string        `json:"policy_id"`
+	Version            string        `json:"version"`      // Candidate version, its metadata.report_version
+	BaseVersion        string        `json:"base_version"` // The policy's current version, the candidate is compared with
+	PolicyData         string        `json:"policy_data,omitempty"`
+	Signature          string        `json:"signature,omitempty"`
+	Percent            int           `json:"percent"`
+	Rings              []string      `json:"rings"`
+	MaxFailureIncrease float64       `json:"max_failure_increase"`
+	MinSubmissions     int           `json:"min_submissions"`
+	Status             string        `json:"status"` // RolloutActive or RolloutHalted
+	HaltReason         string        `json:"halt_reason,omitempty"`
+	StartedBy          string        `json:"started_by,omitempty"`
+	StartedAt          string        `json:"started_at"`
+	UpdatedAt          string        `json:"updated_at"`
+	Candidate          *RolloutStats `json:"candidate,omitempty"` // Submissions of the candidate since the rollout started
+	Base               *RolloutStats `json:"base,omitempty"`      // Submissions of the current version since the rollout started
+}
+
+// RolloutStats summarizes the submissions of one version during a rollout
+type RolloutStats struct {
+	Submissions int     `json:"submissions"`
+	Clients     int     `json:"clients"`
+	FailureRate float64 `json:"failure_rate"` // Failed checks per 100 checks run
+}
+
+// PolicySimulationRequest asks the server to evaluate a policy against the
+// latest stored evidence of clients without publishing it. Exactly one of
+// PolicyID and PolicyData is required.
+type PolicySimulationRequest struct {
+	PolicyID   string   `json:"policy_id,omitempty"`   // Stored (e.g. draft) policy to evaluate
+	PolicyData string   `json:"policy_data,omitempty"` // Unsaved report configuration JSON
+	ClientIDs  []string `json:"client_ids,omitempty"`  // Clients to evaluate; empty evaluates every client with evidence
+}
+
+// PolicySimulationResponse reports the projected impact of a policy on the
+// clients whose evidence was evaluated
+type PolicySimulationResponse struct {
+	ReportType       string             `json:"report_type"`
+	ClientsEvaluated int                `json:"clients_evaluated"`
+	ClientsAffected  int                `json:"clients_affected"` // Clients with at least one newly failing check
+	NewlyFailing     int                `json:"newly_failing"`    // Newly failing checks across all clients
+	NewlyPassing     int                `json:"newly_passing"`
+	Clients          []ClientSimulation `json:"clients"`
+}
+
+// ClientSimulation is the projected outcome of a policy for one client
+type ClientSimulation struct {
+	ClientID       string           `json:"client_id"`
+	Hostname       string           `json:"hostname"`
+	SubmissionID   string           `json:"submission_id"` // Submission whose evidence was re-evaluated
+	EvidenceTime   time.Time        `json:"evidence_time"`
+	CurrentScore   float64          `json:"current_score"`
+	ProjectedScore float64          `json:"projected_score"` // Share of checks with evidence that would pass
+	NewlyFailing   []SimulatedCheck `json:"newly_failing"`
+	NewlyPassing   []SimulatedCheck `json:"newly_passing"`
+	NoEvidence     []string         `json:"no_evidence,omitempty"` // Checks the stored evidence cannot answer
+}
+
+// SimulatedCheck is a check whose outcome would change under a simulated policy
+type SimulatedCheck struct {
+	Name            string `json:"name"`
+	Expected        string `json:"expected"`
+	Actual          string `json:"actual"`
+	CurrentStatus   string `json:"current_status"`
+	ProjectedStatus string `json:"projected_status"`
+}
+
+// PolicyValidationRequest asks the server to check edited policy data before
+// it is saved (POST /api/v1/policies/{policy_id}/validate): against the
+// report schema, and for its differences from the stored policy or one of
+// its revisions
+type PolicyValidationRequest struct {
+	PolicyData string `json:"policy_data"`       // Edited report configuration JSON
+	Against    int    `json:"against,omitempty"` // Revision to compare with; 0 compares with the stored policy
+}
+
+// PolicyValidationResponse reports the problems found in edited policy data
+// and how it differs from the version it was compared with
+type PolicyValidationResponse struct {
+	Valid      bool                    `json:"valid"`
+	Errors     []PolicyValidationError `json:"errors"`
+	ReportType string                  `json:"report_type,omitempty"` // metadata.report_title, whose evidence a dry run uses
+	Checks     int                     `json:"checks"`
+	Diff       *PolicyDiff             `json:"diff,omitempty"` // Omitted when either side is not a JSON object
+}
+
+// PolicyValidationError is one problem of edited policy data
+type PolicyValidationError struct {
+	Path    string `json:"path"` // Location such as "queries[2].root_key"; empty for the document
+	Message string `json:"message"`
+}
+
+// PolicyDiff lists the changes made to a policy's data. Checks are matched
+// by name, so a renamed check shows as removed and added.
+type PolicyDiff struct {
+	Against   string              `json:"against"`  // "stored" or "revision N"
+	Settings  []PolicyFieldChange `json:"settings"` // Changes outside the checks, such as metadata.report_version
+	Added     []string            `json:"added"`
+	Removed   []string            `json:"removed"`
+	Changed   []PolicyCheckChange `json:"changed"`
+	Unchanged int                 `json:"unchanged"` // Checks left as they were
+}
+
+// PolicyCheckChange lists the changed fields of one check
+type PolicyCheckChange struct {
+	Name   string              `json:"name"`
+	Fields []PolicyFieldChange `json:"fields"`
+}
+
+// PolicyFieldChange is one changed field, with its values as JSON; an
+// absent value is empty
+type PolicyFieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// PolicyRevision is a version of a policy kept when an update replaced it.
+// Revisions are numbered from 1 per policy; PolicyData is only returned for
+// a single revision.
+type PolicyRevision struct {
+	PolicyID     string    `json:"policy_id"`
+	Revision     int       `json:"revision"`
+	Name         string    `json:"name"`
+	Version      string    `json:"version"`
+	Status       string    `json:"status"`
+	PolicyData   string    `json:"policy_data,omitempty"`
+	SavedAt      time.Time `json:"saved_at"`      // When this version was saved
+	SupersededAt time.Time `json:"superseded_at"` // When an update replaced it
+}
+
+// PolicyRevisionsResponse lists the revisions of a policy, newest first
+type PolicyRevisionsResponse struct {
+	PolicyID  string           `json:"policy_id"`
+	Revisions []PolicyRevision `json:"revisions"`
+}
+
+// FlakyChecksResponse lists checks whose results flap between pass and fail
+// across consecutive submissions while the policy stays unchanged, grouped by
+// policy (report type)
+type FlakyChecksResponse struct {
+	Since    time.Time     `json:"since"`
+	MinFlips int           `json:"min_flips"`
+	Policies []FlakyPolicy `json:"policies"`
+}
+
+// FlakyPolicy lists the flaky checks of one report type, flakiest first
+type FlakyPolicy struct {
+	ReportType string       `json:"report_type"`
+	Checks     []FlakyCheck `json:"checks"`
+}
+
+// FlakyCheck summarizes how often one check flapped across the fleet
+type FlakyCheck struct {
+	Name            string    `json:"name"`
+	Flips           int       `json:"flips"`        // Pass/fail transitions between consecutive submissions
+	Observations    int       `json:"observations"` // Consecutive pass/fail result pairs compared
+	FlipRate        float64   `json:"flip_rate"`    // Flips / Observations
+	ClientsAffected int       `json:"clients_affected"`
+	LastFlip        time.Time `json:"last_flip"`
+	ClientIDs       []string  `json:"client_ids"` // Clients on which the check flapped
+}
+
+// CheckPerformanceResponse ranks checks by execution time or error rate
+// across the submissions made since Since
+type CheckPerformanceResponse struct {
+	Since  time.Time          `json:"since"`
+	SortBy string             `json:"sort_by"` // "duration" or "errors"
+	Checks []CheckPerformance `json:"checks"`
+}
+
+// CheckPerformance aggregates the execution statistics of one check
+type CheckPerformance struct {
+	ReportType   string         `json:"report_type"`
+	Name         string         `json:"name"`
+	Executions   int            `json:"executions"`
+	AvgMs        float64        `json:"avg_ms"` // Averages cover executions that reported a duration
+	P95Ms        float64        `json:"p95_ms"`
+	MaxMs        float64        `json:"max_ms"`
+	Errors       int            `json:"errors"` // Executions with status "error"
+	ErrorRate    float64        `json:"error_rate"`
+	ErrorClasses map[string]int `json:"error_classes,omitempty"` // Executions per error class, including not_found
+}
+
+// PolicyViolationsResponse lists the checks clients refused to run because
+// their security settings block the registry location read, across the
+// submissions made since Since
+type PolicyViolationsResponse struct {
+	Since      time.Time         `json:"since"`
+	Violations []PolicyViolation `json:"violations"`
+}
+
+// CheckFailuresResponse lists the clients whose latest submission of a
+// report type fails one check
+type CheckFailuresResponse struct {
+	ReportType       string         `json:"report_type"`
+	QueryName        string         `json:"query_name"`
+	ClientsEvaluated int            `json:"clients_evaluated"` // Clients whose latest submission has the check
+	ClientsFailing   int            `json:"clients_failing"`
+	Failures         []CheckFailure `json:"failures"`
+}
+
+// CheckFailure is a client failing a check, with the value it reported
+type CheckFailure struct {
+	ClientID     string    `json:"client_id"`
+	Hostname     string    `json:"hostname"`
+	SubmissionID string    `json:"submission_id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Expected     string    `json:"expected,omitempty"`
+	Operator     string    `json:"expected_operator,omitempty"`
+	Actual       string    `json:"actual,omitempty"`
+	ActualList   []string  `json:"actual_list,omitempty"`
+	Message      string    `json:"message,omitempty"`
+
+	// SummaryOnly is set when evidence sampling dropped the submission's
+	// check details, so the reported value is not known
+	SummaryOnly bool `json:"summary_only,omitempty"`
+}
+
+// CheckValuesResponse is the distribution of the values clients last
+// reported for one check of a report type. Min, Median and Max are set when
+// every reported value is a number.
+type CheckValuesResponse struct {
+	ReportType       string            `json:"report_type"`
+	QueryName        string            `json:"query_name"`
+	ClientsEvaluated int               `json:"clients_evaluated"` // Clients whose latest submission has the check
+	ClientsReporting int               `json:"clients_reporting"` // Clients that reported a value
+	NotFound         int               `json:"not_found"`         // Clients without the registry value
+	Unreported       int               `json:"unreported"`        // Clients whose value is unknown: read errors and sampled submissions
+	Numeric          bool              `json:"numeric"`
+	Min              *float64          `json:"min,omitempty"`
+	Median           *float64          `json:"median,omitempty"`
+	Max              *float64          `json:"max,omitempty"`
+	Values           []CheckValueCount `json:"values"`
+}
+
+// CheckValueCount counts the clients that reported one value of a check
+type CheckValueCount struct {
+	Value   string  `json:"value"`
+	Clients int     `json:"clients"`
+	Passing int     `json:"passing"`
+	Failing int     `json:"failing"`
+	Percent float64 `json:"percent"` // Share of the clients reporting a value
+}
+
+// ControlFamiliesResponse pivots the latest check results of clients, from
+// submissions made since Since, by framework control family
+type ControlFamiliesResponse struct {
+	Since    time.Time       `json:"since"`
+	Families []ControlFamily `json:"families"`
+}
+
+// ControlFamily summarizes the check results mapped to the controls of one
+// family of a framework, e.g. family "3.1" (Access Control) of NIST 800-171
+type ControlFamily struct {
+	Framework      string   `json:"framework"`
+	Family         string   `json:"family"`
+	Controls       []string `json:"controls"` // Control IDs of the family with results
+	Checks         int      `json:"checks"`   // Passed and failed results counted
+	Passed         int      `json:"passed"`
+	Failed         int      `json:"failed"`
+	PassRate       float64  `json:"pass_rate"`       // Percentage of checks passed
+	ClientsFailing int      `json:"clients_failing"` // Clients with a failed result in the family
+}
+
+// AnonymizedExport holds the submissions made since Since with host names,
+// user names, addresses and domain names replaced by pseudonyms, for sharing
+// outside the organization. Truncated is set when the period held more
+// submissions than one export returns.
+type AnonymizedExport struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Since       time.Time              `json:"since"`
+	Submissions []ComplianceSubmission `json:"submissions"`
+	Truncated   bool                   `json:"truncated"`
+}
+
+// Attachment is a supplementary evidence file, such as a screenshot or an
+// exported GPO report, attached to a submission
+type Attachment struct {
+	ID           int64     `json:"id"`
+	SubmissionID string    `json:"submission_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	SHA256       string    `json:"sha256"`
+	Description  string    `json:"description,omitempty"`
+	UploadedBy   string    `json:"uploaded_by,omitempty"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+// AttachmentListResponse lists the attachments of a submission
+type AttachmentListResponse struct {
+	SubmissionID string       `json:"submission_id"`
+	Attachments  []Attachment `json:"attachments"`
+}
+
+// MetadataField is a site-specific field submissions may carry in their
+// metadata, as the server defines it
+type MetadataField struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type"` // string, integer, boolean or enum
+	Required    bool     `json:"required"`
+	Pattern     string   `json:"pattern,omitempty"`    // string: regular expression the whole value must match
+	Values      []string `json:"values,omitempty"`     // enum: the allowed values
+	MaxLength   int      `json:"max_length,omitempty"` // string: the longest value
+}
+
+// MetadataSchemaResponse lists the metadata fields submissions may carry
+type MetadataSchemaResponse struct {
+	Fields []MetadataField `json:"fields"`
+}
+
+// DataSubjectRequest names the machine or person whose records are located
+// or purged. Hostname matches client and submission hostnames without regard
+// to case; a short name also matches its fully qualified names. User matches
+// usernames in the audit logs and whole words in submitted evidence.
+type DataSubjectRequest struct {
+	Hostname string `json:"hostname,omitempty"`
+	User     string `json:"user,omitempty"`
+}
+
+// DataSubjectReport lists the records referencing a data subject that a
+// purge deleted or redacted, or on a dry run would have
+type DataSubjectReport struct {
+	ReportID  string           `json:"report_id"`
+	Hostname  string           `json:"hostname,omitempty"`
+	User      string           `json:"user,omitempty"`
+	DryRun    bool             `json:"dry_run"`
+	ClientIDs []string         `json:"client_ids"`
+	Deleted   map[string]int64 `json:"deleted"`  // Rows deleted, by table
+	Redacted  map[string]int64 `json:"redacted"` // Rows whose references were replaced, by table
+	Files     int64            `json:"files"`    // Attachment files deleted from the attachment store
+	// ArchiveFiles are retention archives still holding submissions of the
+	// subject; they are not rewritten and must be deleted by hand
+	ArchiveFiles []string  `json:"archive_files,omitempty"`
+	Notes        []string  `json:"notes,omitempty"`
+	PerformedBy  string    `json:"performed_by"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+// SignedDataSubjectReport is a deletion report signed with the server's
+// signing key (GET /api/v1/commands/signing-key), as evidence that the
+// records were erased. Signature is the base64 Ed25519 signature of Report
+// exactly as sent.
+type SignedDataSubjectReport struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
+
+// PolicyViolation aggregates the blocked executions of one check
+type PolicyViolation struct {
+	ReportType      string    `json:"report_type"`
+	Name            string    `json:"name"`
+	RootKey         string    `json:"root_key,omitempty"`
+	Path            string    `json:"path,omitempty"`
+	ValueName       string    `json:"value_name,omitempty"`
+	Reason          string    `json:"reason,omitempty"` // Message of the latest blocked execution
+	Attempts        int       `json:"attempts"`
+	ClientsAffected int       `json:"clients_affected"`
+	LastSeen        time.Time `json:"last_seen"`
+	ClientIDs       []string  `json:"client_ids"`
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestResponseFieldNames pins the JSON field names of the management API
+// responses so that accidental renames are caught before they reach clients.
+func TestResponseFieldNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		fields []string
+	}{
+		{"StatusResponse", StatusResponse{Status: "success", Message: "ok"}, []string{"message", "status"}},
+		{"HealthResponse", HealthResponse{Status: "healthy", Version: "1.0.0"}, []string{"status", "version"}},
+		{"DeleteCountResponse", DeleteCountResponse{Status: "success"}, []string{"deleted_count", "status"}},
+		{"LoginResponse", LoginResponse{Success: true, Username: "admin", Role: "admin"}, []string{"role", "success", "username"}},
+		{"SessionResponse", SessionResponse{}, []string{"authentication", "status"}},
+		{"UserInfo", UserInfo{}, []string{"created_at", "id", "role", "username"}},
+		{"UserTeamRequest", UserTeamRequest{}, []string{"team", "username"}},
+		{"UserProfile", UserProfile{}, []string{"display_name", "email", "notifications", "role", "username"}},
+		{"ClientScope", ClientScope{Orgs: []string{"emea.example.com"}, Tags: []string{"pos"}}, []string{"orgs", "tags"}},
+		{"APIKeyInfo", APIKeyInfo{}, []string{"created_at", "created_by", "id", "is_active", "key_prefix", "name"}},
+		{"APIUsageResponse", APIUsageResponse{APIKeyID: 3}, []string{"api_key_id", "daily", "days", "endpoints", "error_rate", "errors", "requests"}},
+		{"APIKeyCreatedResponse", APIKeyCreatedResponse{Status: "success"}, []string{"api_key", "name", "prefix", "status"}},
+		{"PolicyCreatedResponse", PolicyCreatedResponse{Status: "success"}, []string{"policy_id", "status"}},
+		{"PolicyImportResponse", PolicyImportResponse{Status: "success"}, []string{"errors", "imported", "skipped", "status"}},
+		{"EvidenceImportResponse", EvidenceImportResponse{Status: "success"}, []string{"failed", "imported", "results", "skipped", "status"}},
+		{"EvidenceImportResult", EvidenceImportResult{Status: EvidenceImported}, []string{"status"}},
+		{"ClientMergeResponse", ClientMergeResponse{Status: "success"}, []string{"alerts", "commands", "maintenance_windows", "policy_assignments", "source_client_id", "status", "submissions", "target_client_id", "telemetry"}},
+		{"ClientGroup", ClientGroup{Tag: "pos"}, []string{"alert_rules", "clients", "policies", "tag"}},
+		{"PolicyAssignment", PolicyAssignment{PolicyID: "cis-l1"}, []string{"policy_id"}},
+		{"AlertRule", AlertRule{AlertType: "missed_run", Group: "pos"}, []string{"alert_type", "enabled", "group", "id"}},
+		{"NotificationTemplate", NotificationTemplate{Name: "invitation"}, []string{"body", "name", "subject"}},
+		{"DuplicateClientGroup", DuplicateClientGroup{}, []string{"clients", "hostname"}},
+		{"HeartbeatResponse", HeartbeatResponse{Status: "ok"}, []string{"policies", "server_time", "status"}},
+		{"AlertListResponse", AlertListResponse{}, []string{"alerts", "count"}},
+		{"MaintenanceWindowCreatedResponse", MaintenanceWindowCreatedResponse{Status: "success"}, []string{"id", "status"}},
+		{"MaintenanceWindow", MaintenanceWindow{Name: "Patch night"}, []string{"enabled", "id", "name"}},
+		{"CommandsCreatedResponse", CommandsCreatedResponse{Status: "success"}, []string{"commands", "status"}},
+		{"CommandSigningKeyResponse", CommandSigningKeyResponse{Algorithm: "ed25519"}, []string{"algorithm", "public_key"}},
+		{"PolicySimulationResponse", PolicySimulationResponse{}, []string{"clients", "clients_affected", "clients_evaluated", "newly_failing", "newly_passing", "report_type"}},
+		{"FlakyChecksResponse", FlakyChecksResponse{}, []string{"min_flips", "policies", "since"}},
+		{"FlakyCheck", FlakyCheck{}, []string{"client_ids", "clients_affected", "flip_rate", "flips", "last_flip", "name", "observations"}},
+		{"AnonymizedExport", AnonymizedExport{}, []string{"generated_at", "since", "submissions", "truncated"}},
+		{"Attachment", Attachment{}, []string{"content_type", "file_name", "id", "sha256", "size_bytes", "submission_id", "uploaded_at"}},
+		{"AttachmentListResponse", AttachmentListResponse{}, []string{"attachments", "submission_id"}},
+		{"MetadataField", MetadataField{}, []string{"name", "required", "type"}},
+		{"MetadataSchemaResponse", MetadataSchemaResponse{}, []string{"fields"}},
+		{"DataSubjectRequest", DataSubjectRequest{}, []string{}},
+		{"DataSubjectReport", DataSubjectReport{}, []string{"client_ids", "deleted", "dry_run", "files", "generated_at", "performed_by", "redacted", "report_id"}},
+		{"SignedDataSubjectReport", SignedDataSubjectReport{Report: []byte("{}")}, []string{"algorithm", "report", "signature"}},
+		{"CheckPerformanceResponse", CheckPerformanceResponse{}, []string{"checks", "since", "sort_by"}},
+		{"CheckPerformance", CheckPerformance{}, []string{"avg_ms", "error_rate", "errors", "executions", "max_ms", "name", "p95_ms", "report_type"}},
+		{"PolicyViolationsResponse", PolicyViolationsResponse{}, []string{"since", "violations"}},
+		{"PolicyViolation", PolicyViolation{}, []string{"attempts", "client_ids", "clients_affected", "last_seen", "name", "report_type"}},
+		{"ConfigResponse", ConfigResponse{}, []string{"auth", "dashboard", "database", "logging", "server"}},
+		{"PolicyOwnerRequest", PolicyOwnerRequest{}, []string{"owner", "owner_team"}},
+		{"PolicyRolloutRequest", PolicyRolloutRequest{}, []string{"percent"}},
+		{"PolicyRollout", PolicyRollout{}, []string{
+			"base_version", "max_failure_increase", "min_submissions", "percent", "policy_id",
+			"rings", "started_at", "status", "updated_at", "version",
+		}},
+		{"RolloutStats", RolloutStats{}, []string{"clients", "failure_rate", "submissions"}},
+		{"Policy", Policy{Owner: "alice", OwnerTeam: "cis-benchmarks"}, []string{
+			"author", "category", "created_at", "description", "framework", "id", "name",
+			"owner", "owner_team", "policy_data", "policy_id", "status", "updated_at", "version",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			got := make([]string, 0, len(decoded))
+			for key := range decoded {
+				got = append(got, key)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
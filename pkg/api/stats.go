@@ -0,0 +1,28 @@
+package api
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentile returns the p-th percentile (0-100) of values using the
+// nearest-rank method. It returns 0 for an empty slice. Clients use it for
+// scan telemetry and the server to aggregate check durations.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+// TestPercentile tests nearest-rank percentile calculation
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 95, 0},
+		{"single value", []float64{12.5}, 95, 12.5},
+		{"unsorted input", []float64{5, 1, 4, 2, 3}, 50, 3},
+		{"p95 of twenty", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, 95, 19},
+		{"p100 is max", []float64{3, 9, 1}, 100, 9},
+		{"p0 is min", []float64{3, 9, 1}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("Percentile(%v, %v) = %v, want %v", tt.values, tt.p, got, tt.want)
+			}
+		})
+	}
+}
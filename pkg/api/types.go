@@ -1,7 +1,11 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -16,6 +20,23 @@ type ComplianceSubmission struct {
 	Compliance    ComplianceData  `json:"compliance"`
 	Evidence      []EvidenceRecord `json:"evidence,omitempty"`
 	SystemInfo    SystemInfo      `json:"system_info"`
+	Telemetry     *AgentTelemetry `json:"telemetry,omitempty"`
+
+	// DuringMaintenance is set by the server when the submission arrived
+	// inside a maintenance window covering the client
+	DuringMaintenance bool `json:"during_maintenance,omitempty"`
+
+	// SummaryOnly is set by the server when evidence sampling dropped the
+	// submission's evidence records and check details
+	SummaryOnly bool `json:"summary_only,omitempty"`
+
+	// Delta is set on submissions sent to the delta endpoint, whose
+	// Compliance.Queries are rebuilt from it
+	Delta *SubmissionDelta `json:"delta,omitempty"`
+
+	// Metadata holds site-specific values such as a ticket or image build
+	// ID, checked against the fields the server defines (metadata.fields)
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // ComplianceData contains the actual compliance check results
@@ -31,18 +52,40 @@ type ComplianceData struct {
 
 // QueryResult represents the result of a single compliance check
 type QueryResult struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Category    string `json:"category,omitempty"`
-	Status      string `json:"status"` // "pass", "fail", "warning", "error"
-	Expected    string `json:"expected"`
-	Actual      string `json:"actual"`
-	Message     string `json:"message,omitempty"`
-	RootKey     string `json:"root_key,omitempty"`
-	Path        string `json:"path,omitempty"`
-	ValueName   string `json:"value_name,omitempty"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Category    string  `json:"category,omitempty"`
+	Status      string  `json:"status"` // "pass", "fail", "warning", "error"
+	Expected    string  `json:"expected"`
+	Operator    string  `json:"expected_operator,omitempty"` // How Actual is compared with Expected (Operator* constants)
+	Actual      string  `json:"actual"`
+	ActualList  []string `json:"actual_list,omitempty"` // Items of a multi-string value, which Actual joins with ", "
+	Message     string  `json:"message,omitempty"`
+	RootKey     string  `json:"root_key,omitempty"`
+	Path        string  `json:"path,omitempty"`
+	ValueName   string  `json:"value_name,omitempty"`
+	DurationMs  float64 `json:"duration_ms,omitempty"` // Time taken to execute the check
+	ErrorClass  string  `json:"error_class,omitempty"` // Why the value could not be read (ErrorClass* constants)
+
+	// Controls are the control IDs the check covers by framework, copied
+	// from the report configuration, e.g. {"NIST 800-171": ["3.1.1"]}
+	Controls map[string][]string `json:"controls,omitempty"`
 }
 
+// Error classes reported in QueryResult.ErrorClass
+const (
+	ErrorClassNotFound     = "not_found"     // Key or value does not exist
+	ErrorClassAccessDenied = "access_denied" // The agent lacks permission to read the key
+	ErrorClassTimeout      = "timeout"       // The read did not complete in time
+	ErrorClassInvalidQuery = "invalid_query" // The check itself is malformed (e.g. unknown root key)
+	ErrorClassReadFailed   = "read_failed"   // Any other read failure
+	ErrorClassBlocked      = "blocked"       // The client's security settings do not allow the read
+
+	// ErrorClassNeedsElevation marks a check skipped because it needs an
+	// elevated client and the client is not (security.without_elevation)
+	ErrorClassNeedsElevation = "needs_elevation"
+)
+
 // EvidenceRecord contains evidence/audit trail for a compliance check
 type EvidenceRecord struct {
 	QueryName string                 `json:"query_name"`
@@ -61,6 +104,44 @@ type SystemInfo struct {
 	IPAddress    string `json:"ip_address,omitempty"`
 	MacAddress   string `json:"mac_address,omitempty"`
 	LastBootTime string `json:"last_boot_time,omitempty"`
+	Fingerprint  string `json:"fingerprint,omitempty"` // Stable machine identifier (see pkg/fingerprint)
+}
+
+// AgentTelemetry describes how the agent itself performed while producing a
+// submission. It is optional so older agents remain compatible.
+type AgentTelemetry struct {
+	AgentVersion     string  `json:"agent_version,omitempty"`
+	ScanDurationMs   int64   `json:"scan_duration_ms"` // Wall time to execute all checks
+	CheckCount       int     `json:"check_count"`
+	CheckP95Ms       float64 `json:"check_p95_ms"` // 95th percentile of per-check durations
+	CheckMaxMs       float64 `json:"check_max_ms"`
+	CacheBacklog     int     `json:"cache_backlog"`      // Submissions waiting in the offline cache
+	RetryCount       int     `json:"retry_count"`        // Failed delivery attempts before this one
+	MemoryAllocBytes uint64  `json:"memory_alloc_bytes"` // Heap in use by the agent
+	MemorySysBytes   uint64  `json:"memory_sys_bytes"`   // Memory obtained from the OS by the agent
+
+	Build *BuildInfo `json:"build,omitempty"` // The agent build that produced the submission
+}
+
+// BuildInfo identifies the build of a binary (see pkg/buildinfo). Agents
+// send it with submission telemetry, so every submission records the build
+// that collected its evidence.
+type BuildInfo struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit,omitempty"`
+	BuildTime    string `json:"build_time,omitempty"`
+	Builder      string `json:"builder,omitempty"`
+	Modified     bool   `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+	GoVersion    string `json:"go_version,omitempty"`
+	BinarySHA256 string `json:"binary_sha256,omitempty"`
+}
+
+// TelemetryPoint is one agent telemetry sample in a client's history
+type TelemetryPoint struct {
+	SubmissionID string    `json:"submission_id"`
+	Timestamp    time.Time `json:"timestamp"`
+	ReportType   string    `json:"report_type"`
+	AgentTelemetry
 }
 
 // SubmissionResponse is returned after successfully submitting a compliance report
@@ -76,6 +157,206 @@ type ClientRegistration struct {
 	ClientID string     `json:"client_id"`
 	Hostname string     `json:"hostname"`
 	SystemInfo SystemInfo `json:"system_info"`
+
+	AgentVersion   string `json:"agent_version,omitempty"`
+	SchemaVersions []int  `json:"schema_versions,omitempty"` // Submission schema versions the client can send
+}
+
+// SchemaVersion is the version of the submission schema defined by this
+// package. It changes when a submission from an older client can no longer
+// be read correctly.
+const SchemaVersion = 1
+
+// ScheduleInfo describes the schedule a client runs its reports on
+type ScheduleInfo struct {
+	Enabled          bool     `json:"enabled"`
+	Cron             string   `json:"cron,omitempty"`     // Standard 5-field cron expression
+	UTCOffsetSeconds int      `json:"utc_offset_seconds"` // Offset of the client's local time, which the cron expression uses
+	Reports          []string `json:"reports,omitempty"`  // Report types produced by each scheduled run
+}
+
+// Heartbeat is sent periodically by scheduled clients so the server knows
+// they are alive and which schedule they are expected to follow
+type Heartbeat struct {
+	ClientID     string        `json:"client_id"`
+	Hostname     string        `json:"hostname"`
+	Timestamp    time.Time     `json:"timestamp"`
+	AgentVersion string        `json:"agent_version,omitempty"`
+	Schedule     *ScheduleInfo `json:"schedule,omitempty"`
+	Capabilities []string      `json:"capabilities,omitempty"` // Command types the client has opted in to executing
+
+	// SchemaVersions are the submission schema versions the client can
+	// send; clients predating the handshake leave it empty
+	SchemaVersions []int `json:"schema_versions,omitempty"`
+
+	// RunFailures are report runs the client's watchdog stopped since the
+	// last heartbeat the server accepted
+	RunFailures []RunFailure `json:"run_failures,omitempty"`
+}
+
+// RunFailure is a report run the client's watchdog stopped because it ran
+// longer than the client's schedule.max_run_duration
+type RunFailure struct {
+	ReportType   string    `json:"report_type"`
+	StartedAt    time.Time `json:"started_at"`
+	LimitSeconds float64   `json:"limit_seconds"`
+	Abandoned    bool      `json:"abandoned,omitempty"` // The run did not stop when cancelled and was left behind
+}
+
+// Headers sent with a policy download (GET /api/v1/policies/{id}/download).
+// The ETag is the quoted SHA-256 as well, so a client revalidates its copy by
+// sending the hash of its local file in If-None-Match.
+const (
+	HeaderPolicyVersion   = "X-Policy-Version"   // The policy's version
+	HeaderPolicySHA256    = "X-Policy-SHA256"    // Hex SHA-256 of the report configuration
+	HeaderPolicySignature = "X-Policy-Signature" // Base64 Ed25519 signature over PolicySigningPayload
+)
+
+// HeaderClientID names the client requesting a policy download, so the
+// server can serve it a version under staged rollout
+const HeaderClientID = "X-Client-ID"
+
+// HeaderPackSignature carries the base64 Ed25519 signature of a policy pack
+// exported by GET /api/v1/policies/export-pack, the pack's detached signature
+const HeaderPackSignature = "X-Pack-Signature"
+
+// PolicySigningPayload returns the bytes a policy signature covers: the
+// policy's ID, version and the SHA-256 of its report configuration, so a
+// signature cannot be replayed for another policy or version.
+func PolicySigningPayload(policyID, version, sha256 string) []byte {
+	payload, _ := json.Marshal(struct {
+		PolicyID string `json:"policy_id"`
+		Version  string `json:"version"`
+		SHA256   string `json:"sha256"`
+	}{policyID, version, strings.ToLower(sha256)})
+	return payload
+}
+
+// ScriptSigningPayload returns the bytes the signature of a powershell
+// query's script covers: the query's name and the SHA-256 of its script, so
+// a signed script cannot be moved to another check.
+func ScriptSigningPayload(queryName, script string) []byte {
+	sum := sha256.Sum256([]byte(script))
+	payload, _ := json.Marshal(struct {
+		Query  string `json:"query"`
+		SHA256 string `json:"sha256"`
+	}{queryName, hex.EncodeToString(sum[:])})
+	return payload
+}
+
+// Command types that can be queued for a client. A client only executes the
+// types listed in its capabilities.
+const (
+	CommandTypeScan              = "scan"               // Run reports immediately, outside the schedule
+	CommandTypeRefreshPolicies   = "refresh_policies"   // Reload report definitions, pulling updates from the server when enabled
+	CommandTypeUploadDiagnostics = "upload_diagnostics" // Return agent diagnostics in the command result
+	CommandTypeUpdateAgent       = "update_agent"       // Download, verify and install a new agent binary
+)
+
+// Arguments of the update_agent command
+const (
+	CommandArgURL     = "url"     // Where to download the new agent binary
+	CommandArgSHA256  = "sha256"  // Hex SHA-256 of the binary
+	CommandArgVersion = "version" // Version being installed
+)
+
+// Command lifecycle states
+const (
+	CommandStatusAwaitingApproval = "awaiting_approval" // Staged; a second admin must approve it before delivery
+	CommandStatusRejected         = "rejected"          // Staged command declined, never delivered
+	CommandStatusPending          = "pending"           // Queued, not yet collected by the client
+	CommandStatusDelivered        = "delivered"         // Returned to the client in a heartbeat response
+	CommandStatusCompleted        = "completed"         // Client reported success
+	CommandStatusFailed           = "failed"            // Client reported failure or refused the command
+)
+
+// Command is an instruction queued for a client and delivered in the
+// response to its next heartbeat. Delivered commands carry an Ed25519
+// signature over SigningPayload which clients verify before executing them.
+type Command struct {
+	ID          int               `json:"id"`
+	ClientID    string            `json:"client_id"`
+	Type        string            `json:"type"`
+	Reports     []string          `json:"reports,omitempty"`   // Report types to run; empty runs every configured report
+	Arguments   map[string]string `json:"arguments,omitempty"` // Type-specific arguments, e.g. CommandArgURL
+	Status      string            `json:"status"`
+	Message     string            `json:"message,omitempty"` // Result reported by the client
+	Output      string            `json:"output,omitempty"`  // Data returned by the client, e.g. diagnostics
+	CreatedBy   string            `json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ApprovedBy  string            `json:"approved_by,omitempty"` // Second admin who approved or rejected a staged command
+	ApprovedAt  *time.Time        `json:"approved_at,omitempty"`
+	DeliveredAt *time.Time        `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"` // Clients refuse the command after this time
+	Signature   string            `json:"signature,omitempty"`  // Base64 Ed25519 signature
+}
+
+// SigningPayload returns the bytes a command signature covers: everything a
+// client acts on, bound to the client the command was queued for.
+func (c *Command) SigningPayload() ([]byte, error) {
+	var expiresAt string
+	if c.ExpiresAt != nil {
+		expiresAt = c.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	// Map keys are marshaled in sorted order, so the encoding is stable
+	return json.Marshal(struct {
+		ID        int               `json:"id"`
+		ClientID  string            `json:"client_id"`
+		Type      string            `json:"type"`
+		Reports   []string          `json:"reports"`
+		Arguments map[string]string `json:"arguments"`
+		ExpiresAt string            `json:"expires_at"`
+	}{c.ID, c.ClientID, c.Type, c.Reports, c.Arguments, expiresAt})
+}
+
+// CommandResult is sent by a client when it has finished executing a command
+type CommandResult struct {
+	ClientID string `json:"client_id"`
+	Status   string `json:"status"` // CommandStatusCompleted or CommandStatusFailed
+	Message  string `json:"message,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// CommandAuditEntry records one step in the life of a command
+type CommandAuditEntry struct {
+	ID        int       `json:"id"`
+	CommandID int       `json:"command_id"`
+	Event     string    `json:"event"` // "staged", "approved", "rejected", "queued", "delivered", "completed", "failed"
+	Actor     string    `json:"actor"` // User who queued the command, or the client ID
+	Details   string    `json:"details,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AdminAuditEntry is one administrative change in the server's audit trail:
+// a user, API key, policy or setting changed, or client history cleared.
+// Before and After hold the object as JSON on either side of the change;
+// creations have no Before and deletions no After.
+type AdminAuditEntry struct {
+	ID         int             `json:"id"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Actor      string          `json:"actor"` // Username, or "api-key" for API key requests
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	Action     string          `json:"action"`      // e.g. "user.create", "policy.update"
+	TargetType string          `json:"target_type"` // "user", "api_key", "policy", "client", "settings"
+	Target     string          `json:"target,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+// SubmissionArchive is a gzip-compressed JSON file of submissions the
+// server's retention job removed from the database. The file holds a JSON
+// array of ComplianceSubmission, without agent telemetry.
+type SubmissionArchive struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Path      string    `json:"path"` // File on the server that archived it, or URL in its bucket or container
+	Count     int       `json:"submission_count"`
+	Oldest    time.Time `json:"oldest"`
+	Newest    time.Time `json:"newest"`
+	SizeBytes int64     `json:"size_bytes"`
+	SHA256    string    `json:"sha256"`
 }
 
 // ClientInfo represents information about a registered client
@@ -90,6 +371,10 @@ type ClientInfo struct {
 	ComplianceScore        float64            `json:"compliance_score,omitempty"`
 	ComplianceScoresByType map[string]float64 `json:"compliance_scores_by_type,omitempty"` // Average score per report type
 	SystemInfo             SystemInfo         `json:"system_info"`
+	MaintenanceWindow      string             `json:"maintenance_window,omitempty"` // Name of the maintenance window currently covering the client
+	AgentVersion           string             `json:"agent_version,omitempty"`      // Reported with the client's latest heartbeat
+	Compatibility          string             `json:"compatibility,omitempty"`      // deprecated or unsupported when the agent is older than the server accepts
+	Tags                   []string           `json:"tags,omitempty"`               // Set by admins; used to scope operators
 }
 
 // DashboardSummary provides a high-level overview for the dashboard
@@ -113,6 +398,11 @@ type SubmissionSummary struct {
 	TotalChecks   int       `json:"total_checks,omitempty"`
 	PassedChecks  int       `json:"passed_checks"`
 	FailedChecks  int       `json:"failed_checks"`
+
+	DuringMaintenance bool `json:"during_maintenance,omitempty"`
+	SummaryOnly       bool `json:"summary_only,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // ComplianceStats provides statistics for a specific compliance type
@@ -125,14 +415,18 @@ type ComplianceStats struct {
 
 // Alert represents a compliance alert/notification
 type Alert struct {
-	ID          string    `json:"id"`
-	Timestamp   time.Time `json:"timestamp"`
-	Severity    string    `json:"severity"` // "info", "warning", "critical"
-	Type        string    `json:"type"`
-	ClientID    string    `json:"client_id,omitempty"`
-	Hostname    string    `json:"hostname,omitempty"`
-	Message     string    `json:"message"`
-	Acknowledged bool     `json:"acknowledged"`
+	ID           string     `json:"id"`
+	Timestamp    time.Time  `json:"timestamp"`
+	Severity     string     `json:"severity"` // "info", "warning", "critical"
+	Type         string     `json:"type"`
+	ClientID     string     `json:"client_id,omitempty"`
+	Hostname     string     `json:"hostname,omitempty"`
+	Message      string     `json:"message"`
+	Acknowledged bool       `json:"acknowledged"`
+	ReportType   string     `json:"report_type,omitempty"`
+	ExpectedAt   *time.Time `json:"expected_at,omitempty"`     // Scheduled run time that was missed
+	LastSuccess  *time.Time `json:"last_success_at,omitempty"` // Most recent submission of the report type
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
 }
 
 // ErrorResponse represents an API error response
@@ -142,6 +436,65 @@ type ErrorResponse struct {
 	Code    int    `json:"code,omitempty"`
 }
 
+// ProblemTypeBlank is the type of problems described by their HTTP status
+// alone (RFC 7807 section 4.2)
+const ProblemTypeBlank = "about:blank"
+
+// Problem is an RFC 7807 problem details response, sent as
+// application/problem+json for every API error. It keeps the fields of
+// ErrorResponse, which older clients read.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"` // Also sent as the X-Request-ID header and logged by the server
+	ErrorResponse
+}
+
+// TimeoutResponse is the body of a 504 sent when a handler runs past its
+// timeout, with what the request was doing when it was stopped
+type TimeoutResponse struct {
+	Problem
+	Route          string  `json:"route"`
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	DBStatements   int     `json:"db_statements"`             // Statements run, including the canceled one
+	DBSeconds      float64 `json:"db_seconds"`                // Time spent in the database
+	CanceledDuring string  `json:"canceled_during,omitempty"` // "query" or "exec" when a statement was canceled
+}
+
+// Validate validates a Heartbeat
+func (h *Heartbeat) Validate() error {
+	if h.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	if h.Hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+	if h.Schedule != nil && h.Schedule.Enabled && h.Schedule.Cron == "" {
+		return fmt.Errorf("schedule.cron is required when the schedule is enabled")
+	}
+	for _, failure := range h.RunFailures {
+		if failure.ReportType == "" || failure.StartedAt.IsZero() {
+			return fmt.Errorf("run_failures require report_type and started_at")
+		}
+	}
+	return nil
+}
+
+// Limits on submissions, enforced where they enter the server so a faulty
+// or hostile client cannot have it decode, store or render unbounded data.
+// They are far above what the largest report produces.
+const (
+	MaxSubmissionBytes       = 32 << 20 // Request body of a submission
+	MaxQueriesPerSubmission  = 10000    // Check results
+	MaxEvidencePerSubmission = 50000    // Evidence records
+	MaxIdentifierLength      = 256      // IDs, hostname, report type and version, check names
+	MaxFieldLength           = 1 << 20  // Any other string of a check result, and each item of actual_list
+	MaxListItems             = 10000    // Items of a check's actual_list
+)
+
 // Validate validates a ComplianceSubmission
 func (s *ComplianceSubmission) Validate() error {
 	if s.ClientID == "" {
@@ -159,9 +512,104 @@ func (s *ComplianceSubmission) Validate() error {
 	if len(s.Compliance.Queries) == 0 {
 		return fmt.Errorf("compliance queries cannot be empty")
 	}
+
+	for _, field := range []struct{ name, value string }{
+		{"submission_id", s.SubmissionID},
+		{"client_id", s.ClientID},
+		{"hostname", s.Hostname},
+		{"report_type", s.ReportType},
+		{"report_version", s.ReportVersion},
+	} {
+		if len(field.value) > MaxIdentifierLength {
+			return fmt.Errorf("%s is longer than %d bytes", field.name, MaxIdentifierLength)
+		}
+	}
+	if len(s.Compliance.Queries) > MaxQueriesPerSubmission {
+		return fmt.Errorf("submission has %d checks; at most %d are accepted", len(s.Compliance.Queries), MaxQueriesPerSubmission)
+	}
+	if len(s.Evidence) > MaxEvidencePerSubmission {
+		return fmt.Errorf("submission has %d evidence records; at most %d are accepted", len(s.Evidence), MaxEvidencePerSubmission)
+	}
+	for i := range s.Compliance.Queries {
+		if err := s.Compliance.Queries[i].checkLimits(); err != nil {
+			return fmt.Errorf("compliance.queries[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// checkLimits checks the lengths of a check result's strings
+func (q *QueryResult) checkLimits() error {
+	if len(q.Name) > MaxIdentifierLength {
+		return fmt.Errorf("name is longer than %d bytes", MaxIdentifierLength)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"description", q.Description},
+		{"category", q.Category},
+		{"status", q.Status},
+		{"expected", q.Expected},
+		{"expected_operator", q.Operator},
+		{"actual", q.Actual},
+		{"message", q.Message},
+		{"root_key", q.RootKey},
+		{"path", q.Path},
+		{"value_name", q.ValueName},
+		{"error_class", q.ErrorClass},
+	} {
+		if len(field.value) > MaxFieldLength {
+			return fmt.Errorf("%s is longer than %d bytes", field.name, MaxFieldLength)
+		}
+	}
+	if len(q.ActualList) > MaxListItems {
+		return fmt.Errorf("actual_list has more than %d items", MaxListItems)
+	}
+	for _, item := range q.ActualList {
+		if len(item) > MaxFieldLength {
+			return fmt.Errorf("actual_list item is longer than %d bytes", MaxFieldLength)
+		}
+	}
+	return nil
+}
+
+// NewComplianceData counts the statuses of queries and derives the overall
+// status
+func NewComplianceData(queries []QueryResult) ComplianceData {
+	data := ComplianceData{
+		TotalChecks: len(queries),
+		Queries:     queries,
+	}
+
+	for _, query := range queries {
+		switch query.Status {
+		case "pass":
+			data.PassedChecks++
+		case "fail":
+			data.FailedChecks++
+		case "warning":
+			data.WarningChecks++
+		case "error":
+			data.ErrorChecks++
+		}
+	}
+
+	data.OverallStatus = data.CalculateOverallStatus()
+	return data
+}
+
+// QueryStatusFromEvidence maps a status from a toolkit evidence log (PASS,
+// FAIL, NOT_FOUND, ERROR) to a QueryResult status. A missing value fails its
+// check, as it does for the agent.
+func QueryStatusFromEvidence(status string) string {
+	switch strings.ToUpper(status) {
+	case "PASS":
+		return "pass"
+	case "FAIL", "NOT_FOUND":
+		return "fail"
+	default:
+		return "error"
+	}
+}
+
 // CalculateOverallStatus determines the overall compliance status
 func (c *ComplianceData) CalculateOverallStatus() string {
 	if c.TotalChecks == 0 {
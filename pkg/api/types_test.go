@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewComplianceData tests counting statuses and deriving the overall status
+func TestNewComplianceData(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{"empty", nil, "unknown"},
+		{"all pass", []string{"pass", "pass"}, "compliant"},
+		{"any fail", []string{"pass", "fail", "error"}, "non-compliant"},
+		{"errors only", []string{"pass", "error"}, "partial"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := make([]QueryResult, len(tt.statuses))
+			for i, status := range tt.statuses {
+				queries[i] = QueryResult{Name: status, Status: status}
+			}
+
+			data := NewComplianceData(queries)
+			if data.OverallStatus != tt.want {
+				t.Errorf("OverallStatus = %q, want %q", data.OverallStatus, tt.want)
+			}
+			if data.TotalChecks != len(tt.statuses) || data.PassedChecks+data.FailedChecks+data.ErrorChecks != len(tt.statuses) {
+				t.Errorf("counts = %+v", data)
+			}
+		})
+	}
+}
+
+// TestQueryStatusFromEvidence tests mapping evidence log statuses
+func TestQueryStatusFromEvidence(t *testing.T) {
+	tests := map[string]string{
+		"PASS":      "pass",
+		"FAIL":      "fail",
+		"NOT_FOUND": "fail",
+		"ERROR":     "error",
+		"pass":      "pass",
+		"":          "error",
+	}
+
+	for status, want := range tests {
+		if got := QueryStatusFromEvidence(status); got != want {
+			t.Errorf("QueryStatusFromEvidence(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+// TestSubmissionLimits tests that submissions beyond the size limits are
+// refused
+func TestSubmissionLimits(t *testing.T) {
+	valid := func() *ComplianceSubmission {
+		return &ComplianceSubmission{
+			SubmissionID: "sub-1",
+			ClientID:     "client-1",
+			Hostname:     "host-1",
+			ReportType:   "NIST 800-171",
+			Timestamp:    time.Now(),
+			Compliance:   ComplianceData{Queries: []QueryResult{{Name: "q", Status: "pass"}}},
+		}
+	}
+	long := strings.Repeat("x", MaxIdentifierLength+1)
+
+	tests := []struct {
+		name   string
+		modify func(s *ComplianceSubmission)
+		want   string // Empty for a submission that is accepted
+	}{
+		{"valid", func(s *ComplianceSubmission) {}, ""},
+		{"hostname too long", func(s *ComplianceSubmission) { s.Hostname = long }, "hostname"},
+		{"report version too long", func(s *ComplianceSubmission) { s.ReportVersion = long }, "report_version"},
+		{"too many checks", func(s *ComplianceSubmission) {
+			s.Compliance.Queries = make([]QueryResult, MaxQueriesPerSubmission+1)
+		}, "checks"},
+		{"too many evidence records", func(s *ComplianceSubmission) {
+			s.Evidence = make([]EvidenceRecord, MaxEvidencePerSubmission+1)
+		}, "evidence"},
+		{"check name too long", func(s *ComplianceSubmission) { s.Compliance.Queries[0].Name = long }, "queries[0]: name"},
+		{"actual too long", func(s *ComplianceSubmission) {
+			s.Compliance.Queries[0].Actual = strings.Repeat("x", MaxFieldLength+1)
+		}, "queries[0]: actual"},
+		{"too many list items", func(s *ComplianceSubmission) {
+			s.Compliance.Queries[0].ActualList = make([]string, MaxListItems+1)
+		}, "actual_list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submission := valid()
+			tt.modify(submission)
+			err := submission.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzSubmissionJSON tests that decoding and validating arbitrary
+// submission bodies does not panic, and that a submission accepted once is
+// accepted again after a round trip, as when the server stores and serves it
+func FuzzSubmissionJSON(f *testing.F) {
+	f.Add([]byte(`{"submission_id":"s","client_id":"c","hostname":"h","timestamp":"2025-01-02T03:04:05Z","report_type":"NIST",
+		"compliance":{"queries":[{"name":"q","status":"pass","expected":"1","actual":"1","actual_list":["a","b"]}]},
+		"evidence":[{"query_name":"q","action":"registry_read","details":{"path":"SOFTWARE\\X"}}],
+		"metadata":{"ticket":"CHG1"}}`))
+	f.Add([]byte(`{"client_id":"c","delta":{"base_submission_id":"b","state_hash":"h","changed":[{"name":"q"}],"removed":["r"]}}`))
+	f.Add([]byte(`{"compliance":{"queries":null}}`))
+	f.Add([]byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var submission ComplianceSubmission
+		if err := json.Unmarshal(data, &submission); err != nil {
+			return
+		}
+		if err := submission.Validate(); err != nil {
+			return
+		}
+
+		compliance := NewComplianceData(submission.Compliance.Queries)
+		if counted := compliance.PassedChecks + compliance.FailedChecks + compliance.WarningChecks + compliance.ErrorChecks; counted > compliance.TotalChecks {
+			t.Fatalf("NewComplianceData() counted %d of %d checks", counted, compliance.TotalChecks)
+		}
+		QueryStateHash(submission.Compliance.Queries)
+
+		encoded, err := json.Marshal(&submission)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var decoded ComplianceSubmission
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Unmarshal() of an encoded submission error = %v", err)
+		}
+		if err := decoded.Validate(); err != nil {
+			t.Fatalf("Validate() after a round trip = %v", err)
+		}
+	})
+}
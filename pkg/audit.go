@@ -18,6 +18,8 @@ const (
 	// Access events
 	AuditEventRegistryRead     AuditEventType = "registry.read"
 	AuditEventRegistryReadAll  AuditEventType = "registry.read_all"
+	AuditEventRegistryWrite    AuditEventType = "registry.write"
+	AuditEventRegistryRollback AuditEventType = "registry.rollback"
 	AuditEventConfigLoad       AuditEventType = "config.load"
 	AuditEventReportGenerate   AuditEventType = "report.generate"
 	AuditEventReportComplete   AuditEventType = "report.complete"
@@ -60,6 +62,14 @@ type AuditLogger struct {
 	sessionID string
 	stats     AuditStats
 	logFile   *os.File // Underlying file for proper cleanup
+	sinks     []AuditSink
+}
+
+// AuditSink receives every audit event besides the audit log, such as the
+// Windows event log (EventLogSink)
+type AuditSink interface {
+	WriteAuditEvent(event AuditEvent) error
+	Close() error
 }
 
 // AuditStats tracks audit logging statistics
@@ -132,21 +142,42 @@ func NewAuditLoggerWithFile(auditLogPath string, enabled bool) (*AuditLogger, er
 	}, nil
 }
 
-// Close closes the audit log file if one is open
+// AddSink sends every later audit event to sink as well
+func (a *AuditLogger) AddSink(sink AuditSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sinks = append(a.sinks, sink)
+}
+
+// Close closes the audit log file if one is open, and the sinks
 func (a *AuditLogger) Close() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	var firstErr error
+	for _, sink := range a.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	a.sinks = nil
+
 	if a.logFile != nil {
 		err := a.logFile.Close()
 		a.logFile = nil
-		return err
+		if firstErr == nil {
+			firstErr = err
+		}
 	}
-	return nil
+	return firstErr
 }
 
-// IsEnabled returns whether audit logging is enabled
+// IsEnabled returns whether audit logging is enabled. A nil logger is
+// disabled.
 func (a *AuditLogger) IsEnabled() bool {
+	if a == nil {
+		return false
+	}
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	return a.enabled
@@ -226,6 +257,71 @@ func (a *AuditLogger) LogRegistryRead(rootKey, path, valueName string, success b
 	a.updateStats(event)
 }
 
+// LogRegistryWrite logs a remediation that changed, or in a dry run would
+// have changed, a registry value
+func (a *AuditLogger) LogRegistryWrite(rootKey, path, valueName, oldValue, newValue string, dryRun bool, err error) {
+	a.logRegistryChange(AuditEventRegistryWrite, "write", rootKey, path, valueName, oldValue, newValue, dryRun, err)
+}
+
+// LogRegistryRollback logs a value being restored from a rollback snapshot
+func (a *AuditLogger) LogRegistryRollback(rootKey, path, valueName, restoredValue string, dryRun bool, err error) {
+	a.logRegistryChange(AuditEventRegistryRollback, "rollback", rootKey, path, valueName, "", restoredValue, dryRun, err)
+}
+
+// logRegistryChange logs a registry modification. Changes are always logged
+// at warning severity or above so they stand out from routine reads.
+func (a *AuditLogger) logRegistryChange(eventType AuditEventType, action, rootKey, path, valueName, oldValue, newValue string, dryRun bool, err error) {
+	if !a.IsEnabled() {
+		return
+	}
+
+	resource := fmt.Sprintf("%s\\%s", rootKey, path)
+	if valueName != "" {
+		resource = fmt.Sprintf("%s\\%s", resource, valueName)
+	}
+
+	result := "success"
+	severity := "warning"
+	errMsg := ""
+	if dryRun {
+		result = "planned"
+		severity = "info"
+	}
+	if err != nil {
+		result = "failed"
+		severity = "error"
+		errMsg = err.Error()
+	}
+
+	details := map[string]interface{}{
+		"root_key":   rootKey,
+		"path":       path,
+		"value_name": valueName,
+		"new_value":  newValue,
+		"dry_run":    dryRun,
+	}
+	if eventType == AuditEventRegistryWrite {
+		details["old_value"] = oldValue
+	}
+
+	event := AuditEvent{
+		Timestamp: time.Now(),
+		EventType: eventType,
+		User:      getCurrentUser(),
+		Resource:  resource,
+		Action:    action,
+		Result:    result,
+		Severity:  severity,
+		Source:    getCallerInfo(3),
+		SessionID: a.sessionID,
+		Error:     errMsg,
+		Details:   details,
+	}
+
+	a.logEvent(event)
+	a.updateStats(event)
+}
+
 // LogSecurityEvent logs a security-related event
 func (a *AuditLogger) LogSecurityEvent(eventType AuditEventType, resource, reason string, details map[string]interface{}) {
 	if !a.IsEnabled() {
@@ -404,6 +500,16 @@ func (a *AuditLogger) logEvent(event AuditEvent) {
 		"error", event.Error,
 		"details", event.Details,
 	)
+
+	a.mu.RLock()
+	sinks := a.sinks
+	a.mu.RUnlock()
+	for _, sink := range sinks {
+		// The event is in the audit log; a sink failing must not stop it
+		if err := sink.WriteAuditEvent(event); err != nil {
+			a.logger.Warn("Failed to write audit event to sink", "event_type", event.EventType, "error", err)
+		}
+	}
 }
 
 // updateStats updates internal statistics
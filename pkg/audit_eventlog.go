@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// auditEventIDs are the Windows event IDs audit events are written with.
+// SIEM and EDR rules match on them, so an ID is never changed or reused;
+// new event types get new IDs. IDs stay within 1-1000, the range the
+// EventCreate message file that sources are registered with can show.
+var auditEventIDs = map[AuditEventType]uint32{
+	AuditEventStartup:  100,
+	AuditEventShutdown: 101,
+	AuditEventError:    102,
+
+	AuditEventRegistryRead:     200,
+	AuditEventRegistryReadAll:  201,
+	AuditEventRegistryWrite:    202,
+	AuditEventRegistryRollback: 203,
+	AuditEventConfigLoad:       204,
+	AuditEventReportGenerate:   205,
+	AuditEventReportComplete:   206,
+
+	AuditEventAccessDenied:     300,
+	AuditEventValidationFailed: 301,
+	AuditEventPathTraversal:    302,
+	AuditEventInjectionAttempt: 303,
+	AuditEventPolicyViolation:  304,
+}
+
+// auditEventIDUnknown is the event ID of audit event types without one
+const auditEventIDUnknown = 999
+
+// AuditEventCategories are the categories of audit events, the part of the
+// event type before the dot, an EventLogSink can be limited to
+var AuditEventCategories = []string{"system", "registry", "config", "report", "security"}
+
+// AuditEventID returns the Windows event ID audit events of eventType are
+// written with
+func AuditEventID(eventType AuditEventType) uint32 {
+	if id, ok := auditEventIDs[eventType]; ok {
+		return id
+	}
+	return auditEventIDUnknown
+}
+
+// auditEventCategory returns the category of an audit event type
+func auditEventCategory(eventType AuditEventType) string {
+	category, _, _ := strings.Cut(string(eventType), ".")
+	return category
+}
+
+// eventLogWriter is the part of *eventlog.Log an EventLogSink writes with
+type eventLogWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// EventLogSink writes audit events to the Windows Application event log, so
+// endpoint EDR and SIEM agents collect them without tailing a file
+type EventLogSink struct {
+	log        eventLogWriter
+	categories map[string]bool
+}
+
+// NewEventLogSink opens the Application event log as source and returns a
+// sink writing the audit events of categories (AuditEventCategories) to
+// it. Events from a source that is not registered are written, but Event
+// Viewer shows them with a note that their description is missing.
+func NewEventLogSink(source string, categories []string) (*EventLogSink, error) {
+	log, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log source %s: %w", source, err)
+	}
+	return newEventLogSink(log, categories), nil
+}
+
+// newEventLogSink returns a sink writing the audit events of categories to log
+func newEventLogSink(log eventLogWriter, categories []string) *EventLogSink {
+	sink := &EventLogSink{log: log, categories: make(map[string]bool, len(categories))}
+	for _, category := range categories {
+		sink.categories[category] = true
+	}
+	return sink
+}
+
+// WriteAuditEvent writes event to the event log, at the level of its
+// severity, unless its category is not written
+func (s *EventLogSink) WriteAuditEvent(event AuditEvent) error {
+	if !s.categories[auditEventCategory(event.EventType)] {
+		return nil
+	}
+
+	message, err := formatEventLogMessage(event)
+	if err != nil {
+		return err
+	}
+	id := AuditEventID(event.EventType)
+	switch event.Severity {
+	case "error", "critical":
+		return s.log.Error(id, message)
+	case "warning":
+		return s.log.Warning(id, message)
+	default:
+		return s.log.Info(id, message)
+	}
+}
+
+// Close closes the event log
+func (s *EventLogSink) Close() error {
+	return s.log.Close()
+}
+
+// formatEventLogMessage describes an audit event in a line for Event
+// Viewer, followed by the event as JSON for agents that parse it
+func formatEventLogMessage(event AuditEvent) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	summary := fmt.Sprintf("%s: %s %s %s", event.EventType, event.Action, event.Resource, event.Result)
+	if event.Error != "" {
+		summary += ": " + event.Error
+	}
+	return summary + "\r\n\r\n" + string(data), nil
+}
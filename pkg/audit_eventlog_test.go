@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEventLog records the events written to it
+type fakeEventLog struct {
+	entries []fakeEventLogEntry
+	closed  bool
+}
+
+type fakeEventLogEntry struct {
+	level string
+	id    uint32
+	msg   string
+}
+
+func (f *fakeEventLog) Info(eid uint32, msg string) error {
+	f.entries = append(f.entries, fakeEventLogEntry{"info", eid, msg})
+	return nil
+}
+
+func (f *fakeEventLog) Warning(eid uint32, msg string) error {
+	f.entries = append(f.entries, fakeEventLogEntry{"warning", eid, msg})
+	return nil
+}
+
+func (f *fakeEventLog) Error(eid uint32, msg string) error {
+	f.entries = append(f.entries, fakeEventLogEntry{"error", eid, msg})
+	return nil
+}
+
+func (f *fakeEventLog) Close() error {
+	f.closed = true
+	return nil
+}
+
+// TestAuditEventIDs tests that every audit event type has its own event ID
+// in the range the registered message file shows
+func TestAuditEventIDs(t *testing.T) {
+	seen := map[uint32]AuditEventType{}
+	for eventType, id := range auditEventIDs {
+		if id < 1 || id > 1000 || id == auditEventIDUnknown {
+			t.Errorf("%s has event ID %d", eventType, id)
+		}
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s and %s share event ID %d", eventType, other, id)
+		}
+		seen[id] = eventType
+
+		category := auditEventCategory(eventType)
+		if !strings.Contains(strings.Join(AuditEventCategories, ","), category) {
+			t.Errorf("%s has unknown category %q", eventType, category)
+		}
+	}
+
+	if got := AuditEventID(AuditEventPolicyViolation); got != 304 {
+		t.Errorf("AuditEventID(policy_violation) = %d, want 304", got)
+	}
+	if got := AuditEventID("custom.event"); got != auditEventIDUnknown {
+		t.Errorf("AuditEventID(custom.event) = %d, want %d", got, auditEventIDUnknown)
+	}
+}
+
+// TestEventLogSink tests that audit events of the chosen categories reach
+// the event log at their severity, with a summary line and the event as JSON
+func TestEventLogSink(t *testing.T) {
+	log := &fakeEventLog{}
+	audit := NewAuditLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), true)
+	audit.AddSink(newEventLogSink(log, []string{"security", "report"}))
+
+	audit.LogPolicyViolation("HKLM\\SECURITY", "deny_registry_paths", "path is denied")
+	audit.LogRegistryRead("HKLM", "SOFTWARE\\Test", "Value", true, nil)
+	audit.LogReportGeneration("cis.json", time.Now(), false, nil)
+
+	if len(log.entries) != 2 {
+		t.Fatalf("event log entries = %+v, want the policy violation and the report", log.entries)
+	}
+	violation := log.entries[0]
+	if violation.level != "warning" || violation.id != 304 {
+		t.Errorf("policy violation written as %s %d, want warning 304", violation.level, violation.id)
+	}
+	summary, data, ok := strings.Cut(violation.msg, "\r\n\r\n")
+	if !ok || summary != "security.policy_violation: validate HKLM\\SECURITY denied: path is denied" {
+		t.Errorf("message summary = %q", summary)
+	}
+	var event AuditEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil || event.Details["policy"] != "deny_registry_paths" {
+		t.Errorf("message event = %+v, %v", event, err)
+	}
+	if report := log.entries[1]; report.level != "error" || report.id != 206 {
+		t.Errorf("failed report written as %s %d, want error 206", report.level, report.id)
+	}
+
+	if err := audit.Close(); err != nil || !log.closed {
+		t.Errorf("Close() = %v, event log closed %v", err, log.closed)
+	}
+}
+
+// TestAuditLogger_Nil tests that a nil audit logger logs nothing
+func TestAuditLogger_Nil(t *testing.T) {
+	var audit *AuditLogger
+	if audit.IsEnabled() {
+		t.Error("nil logger is enabled")
+	}
+	audit.LogPolicyViolation("resource", "policy", "reason")
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"compliancetoolkit/pkg/safesql"
 )
 
 // AuditLogger handles authentication audit logging for security monitoring
@@ -28,6 +30,8 @@ const (
 	EventFailedLogin  EventType = "failed_login"
 	EventTokenRevoked EventType = "token_revoked"
 	EventPasswordChange EventType = "password_change"
+	EventPasswordResetRequest EventType = "password_reset_request"
+	EventPasswordReset EventType = "password_reset"
 	EventAccountLocked EventType = "account_locked"
 	EventMFAEnabled   EventType = "mfa_enabled"
 	EventMFADisabled  EventType = "mfa_disabled"
@@ -67,7 +71,7 @@ func (a *AuditLogger) Log(ctx context.Context, event AuditEvent) error {
 		}
 	}
 
-	query := `
+	const query = `
 		INSERT INTO auth_audit_log (
 			user_id, username, event_type, auth_method,
 			ip_address, user_agent, success, failure_reason, metadata
@@ -81,17 +85,7 @@ func (a *AuditLogger) Log(ctx context.Context, event AuditEvent) error {
 		userIDVal = event.UserID
 	}
 
-	_, err = a.db.ExecContext(ctx, query,
-		userIDVal,
-		event.Username,
-		event.EventType,
-		event.AuthMethod,
-		event.IPAddress,
-		event.UserAgent,
-		event.Success,
-		event.FailureReason,
-		string(metadataJSON),
-	)
+	_, err = safesql.ExecContext(ctx, a.db, safesql.New(query, userIDVal, event.Username, event.EventType, event.AuthMethod, event.IPAddress, event.UserAgent, event.Success, event.FailureReason, string(metadataJSON)))
 
 	if err != nil {
 		return fmt.Errorf("failed to log audit event: %w", err)
@@ -183,7 +177,7 @@ type AuditLogEntry struct {
 
 // GetUserAuditLog retrieves audit log entries for a specific user
 func (a *AuditLogger) GetUserAuditLog(ctx context.Context, userID int, limit int) ([]AuditLogEntry, error) {
-	query := `
+	const query = `
 		SELECT id, user_id, username, event_type, auth_method,
 		       ip_address, user_agent, success, failure_reason, timestamp, metadata
 		FROM auth_audit_log
@@ -192,7 +186,7 @@ func (a *AuditLogger) GetUserAuditLog(ctx context.Context, userID int, limit int
 		LIMIT $2
 	`
 
-	rows, err := a.db.QueryContext(ctx, query, userID, limit)
+	rows, err := safesql.QueryContext(ctx, a.db, safesql.New(query, userID, limit))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit log: %w", err)
 	}
@@ -203,13 +197,13 @@ func (a *AuditLogger) GetUserAuditLog(ctx context.Context, userID int, limit int
 
 // GetRecentFailedLogins retrieves recent failed login attempts
 func (a *AuditLogger) GetRecentFailedLogins(ctx context.Context, username string, since time.Time) (int, error) {
-	query := `
+	const query = `
 		SELECT COUNT(*) FROM auth_audit_log
 		WHERE username = $1 AND event_type = $2 AND success = false AND timestamp > $3
 	`
 
 	var count int
-	err := a.db.QueryRowContext(ctx, query, username, EventFailedLogin, since).Scan(&count)
+	err := safesql.QueryRowContext(ctx, a.db, safesql.New(query, username, EventFailedLogin, since)).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count failed logins: %w", err)
 	}
@@ -219,7 +213,7 @@ func (a *AuditLogger) GetRecentFailedLogins(ctx context.Context, username string
 
 // GetAuditLogByTimeRange retrieves audit log entries within a time range
 func (a *AuditLogger) GetAuditLogByTimeRange(ctx context.Context, start, end time.Time, limit int) ([]AuditLogEntry, error) {
-	query := `
+	const query = `
 		SELECT id, user_id, username, event_type, auth_method,
 		       ip_address, user_agent, success, failure_reason, timestamp, metadata
 		FROM auth_audit_log
@@ -228,7 +222,7 @@ func (a *AuditLogger) GetAuditLogByTimeRange(ctx context.Context, start, end tim
 		LIMIT $3
 	`
 
-	rows, err := a.db.QueryContext(ctx, query, start, end, limit)
+	rows, err := safesql.QueryContext(ctx, a.db, safesql.New(query, start, end, limit))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit log: %w", err)
 	}
@@ -287,14 +281,14 @@ func (a *AuditLogger) scanAuditLogEntries(rows *sql.Rows) ([]AuditLogEntry, erro
 
 // CleanupOldEntries removes audit log entries older than the specified duration
 func (a *AuditLogger) CleanupOldEntries(ctx context.Context, olderThan time.Duration) (int64, error) {
-	query := `
+	const query = `
 		DELETE FROM auth_audit_log
 		WHERE timestamp < $1
 	`
 
 	cutoffTime := time.Now().Add(-olderThan)
 
-	result, err := a.db.ExecContext(ctx, query, cutoffTime)
+	result, err := safesql.ExecContext(ctx, a.db, safesql.New(query, cutoffTime.UTC()))
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup old audit entries: %w", err)
 	}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"compliancetoolkit/pkg/safesql"
 )
 
 // BlacklistManager handles JWT blacklist operations for immediate token revocation
@@ -19,12 +21,12 @@ func NewBlacklistManager(db *sql.DB) *BlacklistManager {
 
 // BlacklistToken adds a token's JTI to the blacklist
 func (m *BlacklistManager) BlacklistToken(ctx context.Context, jti string, userID int, expiresAt time.Time, reason string) error {
-	query := `
+	const query = `
 		INSERT INTO jwt_blacklist (jti, user_id, expires_at, reason)
 		VALUES ($1, $2, $3, $4)
 	`
 
-	_, err := m.db.ExecContext(ctx, query, jti, userID, expiresAt, reason)
+	_, err := safesql.ExecContext(ctx, m.db, safesql.New(query, jti, userID, expiresAt.UTC(), reason))
 	if err != nil {
 		return fmt.Errorf("failed to blacklist token: %w", err)
 	}
@@ -34,13 +36,13 @@ func (m *BlacklistManager) BlacklistToken(ctx context.Context, jti string, userI
 
 // IsTokenBlacklisted checks if a token's JTI is blacklisted
 func (m *BlacklistManager) IsTokenBlacklisted(ctx context.Context, jti string) (bool, error) {
-	query := `
+	const query = `
 		SELECT COUNT(*) FROM jwt_blacklist
 		WHERE jti = $1 AND expires_at > $2
 	`
 
 	var count int
-	err := m.db.QueryRowContext(ctx, query, jti, time.Now()).Scan(&count)
+	err := safesql.QueryRowContext(ctx, m.db, safesql.New(query, jti, time.Now().UTC())).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check blacklist: %w", err)
 	}
@@ -50,12 +52,12 @@ func (m *BlacklistManager) IsTokenBlacklisted(ctx context.Context, jti string) (
 
 // CleanupExpiredEntries removes expired entries from the blacklist
 func (m *BlacklistManager) CleanupExpiredEntries(ctx context.Context) (int64, error) {
-	query := `
+	const query = `
 		DELETE FROM jwt_blacklist
 		WHERE expires_at < $1
 	`
 
-	result, err := m.db.ExecContext(ctx, query, time.Now())
+	result, err := safesql.ExecContext(ctx, m.db, safesql.New(query, time.Now().UTC()))
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup blacklist: %w", err)
 	}
@@ -74,7 +76,7 @@ func (m *BlacklistManager) BlacklistAllUserTokens(ctx context.Context, userID in
 	// The actual blacklisting happens by checking jwt_version in token validation
 	// This function is here for completeness but may not be used in practice
 
-	query := `
+	const query = `
 		INSERT INTO jwt_blacklist (jti, user_id, expires_at, reason)
 		SELECT DISTINCT jti, user_id, expires_at, $1
 		FROM (
@@ -82,7 +84,7 @@ func (m *BlacklistManager) BlacklistAllUserTokens(ctx context.Context, userID in
 		)
 	`
 
-	_, err := m.db.ExecContext(ctx, query, reason, "all-tokens-"+time.Now().Format("20060102150405"), userID)
+	_, err := safesql.ExecContext(ctx, m.db, safesql.New(query, reason, "all-tokens-"+time.Now().Format("20060102150405"), userID))
 	if err != nil {
 		return fmt.Errorf("failed to blacklist all user tokens: %w", err)
 	}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+
+	"compliancetoolkit/pkg/safesql"
 )
 
 // AuthHandlers provides HTTP handlers for authentication endpoints
@@ -18,6 +20,7 @@ type AuthHandlers struct {
 	refreshTokenManager  *RefreshTokenManager
 	blacklistManager     *BlacklistManager
 	auditLogger          *AuditLogger
+	lockout              LockoutPolicy
 }
 
 // NewAuthHandlers creates new authentication handlers
@@ -28,9 +31,15 @@ func NewAuthHandlers(db *sql.DB, jwtConfig *JWTConfig) *AuthHandlers {
 		refreshTokenManager: NewRefreshTokenManager(db, jwtConfig),
 		blacklistManager:    NewBlacklistManager(db),
 		auditLogger:         NewAuditLogger(db),
+		lockout:             DefaultLockoutPolicy,
 	}
 }
 
+// SetLockoutPolicy changes when failed logins lock an account
+func (h *AuthHandlers) SetLockoutPolicy(policy LockoutPolicy) {
+	h.lockout = policy
+}
+
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Username string `json:"username"`
@@ -95,10 +104,11 @@ func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if account is locked
+	// Check if account is locked; answered as for unknown users so the lock
+	// does not reveal that the account exists
 	if user.AccountLockedUntil != nil && user.AccountLockedUntil.After(time.Now()) {
-		_ = h.auditLogger.LogFailedLogin(r.Context(), req.Username, "account locked", r.RemoteAddr, r.UserAgent())
-		respondError(w, http.StatusForbidden, fmt.Sprintf("account locked until %s", user.AccountLockedUntil.Format(time.RFC3339)))
+		_ = h.auditLogger.LogFailedLogin(r.Context(), req.Username, "account locked until "+user.AccountLockedUntil.Format(time.RFC3339), r.RemoteAddr, r.UserAgent())
+		respondUnauthorized(w, "invalid credentials")
 		return
 	}
 
@@ -337,7 +347,7 @@ type DBUser struct {
 }
 
 func (h *AuthHandlers) getUserByUsername(ctx context.Context, username string) (*DBUser, error) {
-	query := `
+	const query = `
 		SELECT id, username, password_hash, role, jwt_version,
 		       password_changed_at, failed_login_attempts, account_locked_until
 		FROM users
@@ -347,7 +357,7 @@ func (h *AuthHandlers) getUserByUsername(ctx context.Context, username string) (
 	var user DBUser
 	var passwordChangedAt, accountLockedUntil sql.NullTime
 
-	err := h.db.QueryRowContext(ctx, query, username).Scan(
+	err := safesql.QueryRowContext(ctx, h.db, safesql.New(query, username)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
@@ -377,7 +387,7 @@ func (h *AuthHandlers) getUserByUsername(ctx context.Context, username string) (
 }
 
 func (h *AuthHandlers) getUserByID(ctx context.Context, userID int) (*DBUser, error) {
-	query := `
+	const query = `
 		SELECT id, username, password_hash, role, jwt_version,
 		       password_changed_at, failed_login_attempts, account_locked_until
 		FROM users
@@ -387,7 +397,7 @@ func (h *AuthHandlers) getUserByID(ctx context.Context, userID int) (*DBUser, er
 	var user DBUser
 	var passwordChangedAt, accountLockedUntil sql.NullTime
 
-	err := h.db.QueryRowContext(ctx, query, userID).Scan(
+	err := safesql.QueryRowContext(ctx, h.db, safesql.New(query, userID)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
@@ -415,29 +425,17 @@ func (h *AuthHandlers) getUserByID(ctx context.Context, userID int) (*DBUser, er
 }
 
 func (h *AuthHandlers) incrementFailedLoginAttempts(ctx context.Context, userID int) error {
-	query := `
-		UPDATE users
-		SET failed_login_attempts = failed_login_attempts + 1,
-		    account_locked_until = CASE
-		        WHEN failed_login_attempts >= 4 THEN NOW() + INTERVAL '30 minutes'
-		        ELSE account_locked_until
-		    END
-		WHERE id = $1
-	`
-
-	_, err := h.db.ExecContext(ctx, query, userID)
+	_, err := RecordFailedLogin(ctx, h.db, userID, h.lockout)
 	return err
 }
 
 func (h *AuthHandlers) resetFailedLoginAttempts(ctx context.Context, userID int) error {
-	query := "UPDATE users SET failed_login_attempts = 0, account_locked_until = NULL WHERE id = $1"
-	_, err := h.db.ExecContext(ctx, query, userID)
-	return err
+	return ResetFailedLogins(ctx, h.db, userID)
 }
 
 func (h *AuthHandlers) updatePasswordChangedAt(ctx context.Context, userID int, changedAt time.Time) error {
-	query := "UPDATE users SET password_changed_at = $1 WHERE id = $2"
-	_, err := h.db.ExecContext(ctx, query, changedAt, userID)
+	const query = "UPDATE users SET password_changed_at = $1 WHERE id = $2"
+	_, err := safesql.ExecContext(ctx, h.db, safesql.New(query, changedAt.UTC(), userID))
 	return err
 }
 
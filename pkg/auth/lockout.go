@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"compliancetoolkit/pkg/safesql"
+)
+
+// LockoutPolicy decides when repeated failed logins lock an account
+type LockoutPolicy struct {
+	MaxAttempts int           // Consecutive failures that lock the account; 0 disables lockout
+	Duration    time.Duration // How long a locked account refuses logins
+}
+
+// DefaultLockoutPolicy locks an account for 30 minutes after 5 failed logins
+var DefaultLockoutPolicy = LockoutPolicy{MaxAttempts: 5, Duration: 30 * time.Minute}
+
+// RecordFailedLogin counts a failed login for a user, locking the account
+// when the policy's limit is reached. It returns the time the account is
+// locked until, or nil if it is not locked.
+func RecordFailedLogin(ctx context.Context, db *sql.DB, userID int, policy LockoutPolicy) (*time.Time, error) {
+	// A lock that has run out starts the count again
+	const query = `
+		UPDATE users u
+		SET failed_login_attempts = c.attempts,
+		    account_locked_until = CASE
+		        WHEN $2::integer > 0 AND c.attempts >= $2::integer THEN NOW() + $3::integer * INTERVAL '1 second'
+		        WHEN c.expired THEN NULL
+		        ELSE u.account_locked_until
+		    END
+		FROM (
+		    SELECT id,
+		           account_locked_until <= NOW() AS expired,
+		           CASE WHEN account_locked_until <= NOW() THEN 1
+		                ELSE COALESCE(failed_login_attempts, 0) + 1
+		           END AS attempts
+		    FROM users WHERE id = $1
+		) c
+		WHERE u.id = c.id
+		RETURNING CASE WHEN u.account_locked_until > NOW() THEN u.account_locked_until END
+	`
+
+	var lockedUntil sql.NullTime
+	err := safesql.QueryRowContext(ctx, db, safesql.New(query, userID, policy.MaxAttempts, int64(policy.Duration/time.Second))).Scan(&lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if !lockedUntil.Valid {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// ResetFailedLogins clears a user's failed login count and lock after a
+// successful login
+func ResetFailedLogins(ctx context.Context, db *sql.DB, userID int) error {
+	const query = "UPDATE users SET failed_login_attempts = 0, account_locked_until = NULL WHERE id = $1"
+	_, err := safesql.ExecContext(ctx, db, safesql.New(query, userID))
+	return err
+}
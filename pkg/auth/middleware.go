@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"compliancetoolkit/pkg/safesql"
 )
 
 // Middleware provides JWT authentication middleware for HTTP handlers
@@ -193,8 +195,8 @@ func GetUsername(ctx context.Context) (string, bool) {
 // getUserJWTVersion retrieves the current JWT version for a user from database
 func (m *Middleware) getUserJWTVersion(ctx context.Context, userID int) (int, error) {
 	var jwtVersion int
-	query := "SELECT jwt_version FROM users WHERE id = $1"
-	err := m.db.QueryRowContext(ctx, query, userID).Scan(&jwtVersion)
+	const query = "SELECT jwt_version FROM users WHERE id = $1"
+	err := safesql.QueryRowContext(ctx, m.db, safesql.New(query, userID)).Scan(&jwtVersion)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user JWT version: %w", err)
 	}
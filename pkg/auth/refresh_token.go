@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"compliancetoolkit/pkg/safesql"
 )
 
 // RefreshTokenManager handles refresh token storage and validation
@@ -60,23 +62,14 @@ func (m *RefreshTokenManager) StoreRefreshToken(ctx context.Context, userID int,
 	tokenID := uuid.New().String()
 
 	// Insert into database
-	query := `
+	const query = `
 		INSERT INTO refresh_tokens (
 			id, user_id, token_hash, token_family, expires_at,
 			user_agent, ip_address, device_fingerprint
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	_, err = m.db.ExecContext(ctx, query,
-		tokenID,
-		userID,
-		string(tokenHash),
-		claims.TokenFamily,
-		claims.ExpiresAt.Time,
-		metadata.UserAgent,
-		metadata.IPAddress,
-		metadata.DeviceFingerprint,
-	)
+	_, err = safesql.ExecContext(ctx, m.db, safesql.New(query, tokenID, userID, string(tokenHash), claims.TokenFamily, claims.ExpiresAt.Time.UTC(), metadata.UserAgent, metadata.IPAddress, metadata.DeviceFingerprint))
 
 	if err != nil {
 		return "", fmt.Errorf("failed to store refresh token: %w", err)
@@ -94,7 +87,7 @@ func (m *RefreshTokenManager) ValidateRefreshToken(ctx context.Context, token st
 	}
 
 	// Query database for all non-revoked tokens for this user in the same token family
-	query := `
+	const query = `
 		SELECT id, user_id, token_hash, token_family, expires_at, created_at,
 		       last_used, revoked, revoked_at, revoked_reason,
 		       user_agent, ip_address, device_fingerprint
@@ -103,7 +96,7 @@ func (m *RefreshTokenManager) ValidateRefreshToken(ctx context.Context, token st
 		ORDER BY created_at DESC
 	`
 
-	rows, err := m.db.QueryContext(ctx, query, claims.UserID, claims.TokenFamily)
+	rows, err := safesql.QueryContext(ctx, m.db, safesql.New(query, claims.UserID, claims.TokenFamily))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query refresh tokens: %w", err)
 	}
@@ -141,7 +134,7 @@ func (m *RefreshTokenManager) ValidateRefreshToken(ctx context.Context, token st
 		tokenHash := hex.EncodeToString(hasher.Sum(nil))
 		if rt.TokenHash == tokenHash {
 			// Token matches! Update last_used timestamp
-			_, err = m.db.ExecContext(ctx, "UPDATE refresh_tokens SET last_used = $1 WHERE id = $2", time.Now(), rt.ID)
+			_, err = safesql.ExecContext(ctx, m.db, safesql.New("UPDATE refresh_tokens SET last_used = $1 WHERE id = $2", time.Now().UTC(), rt.ID))
 			if err != nil {
 				// Non-fatal, just log
 				fmt.Printf("Warning: failed to update last_used timestamp: %v\n", err)
@@ -166,13 +159,13 @@ func (m *RefreshTokenManager) ValidateRefreshToken(ctx context.Context, token st
 
 // RevokeRefreshToken revokes a specific refresh token
 func (m *RefreshTokenManager) RevokeRefreshToken(ctx context.Context, tokenID string, reason string) error {
-	query := `
+	const query = `
 		UPDATE refresh_tokens
 		SET revoked = true, revoked_at = $1, revoked_reason = $2
 		WHERE id = $3
 	`
 
-	result, err := m.db.ExecContext(ctx, query, time.Now(), reason, tokenID)
+	result, err := safesql.ExecContext(ctx, m.db, safesql.New(query, time.Now().UTC(), reason, tokenID))
 	if err != nil {
 		return fmt.Errorf("failed to revoke refresh token: %w", err)
 	}
@@ -191,13 +184,13 @@ func (m *RefreshTokenManager) RevokeRefreshToken(ctx context.Context, tokenID st
 
 // RevokeTokenFamily revokes all tokens in a token family (security incident)
 func (m *RefreshTokenManager) RevokeTokenFamily(ctx context.Context, userID int, tokenFamily string, reason string) error {
-	query := `
+	const query = `
 		UPDATE refresh_tokens
 		SET revoked = true, revoked_at = $1, revoked_reason = $2
 		WHERE user_id = $3 AND token_family = $4 AND revoked = false
 	`
 
-	_, err := m.db.ExecContext(ctx, query, time.Now(), reason, userID, tokenFamily)
+	_, err := safesql.ExecContext(ctx, m.db, safesql.New(query, time.Now().UTC(), reason, userID, tokenFamily))
 	if err != nil {
 		return fmt.Errorf("failed to revoke token family: %w", err)
 	}
@@ -207,13 +200,13 @@ func (m *RefreshTokenManager) RevokeTokenFamily(ctx context.Context, userID int,
 
 // RevokeAllUserTokens revokes all refresh tokens for a user (logout all sessions)
 func (m *RefreshTokenManager) RevokeAllUserTokens(ctx context.Context, userID int, reason string) error {
-	query := `
+	const query = `
 		UPDATE refresh_tokens
 		SET revoked = true, revoked_at = $1, revoked_reason = $2
 		WHERE user_id = $3 AND revoked = false
 	`
 
-	_, err := m.db.ExecContext(ctx, query, time.Now(), reason, userID)
+	_, err := safesql.ExecContext(ctx, m.db, safesql.New(query, time.Now().UTC(), reason, userID))
 	if err != nil {
 		return fmt.Errorf("failed to revoke all user tokens: %w", err)
 	}
@@ -223,7 +216,7 @@ func (m *RefreshTokenManager) RevokeAllUserTokens(ctx context.Context, userID in
 
 // CleanupExpiredTokens removes expired refresh tokens from the database
 func (m *RefreshTokenManager) CleanupExpiredTokens(ctx context.Context) (int64, error) {
-	query := `
+	const query = `
 		DELETE FROM refresh_tokens
 		WHERE expires_at < $1 OR (revoked = true AND revoked_at < $2)
 	`
@@ -231,7 +224,7 @@ func (m *RefreshTokenManager) CleanupExpiredTokens(ctx context.Context) (int64,
 	// Delete tokens that expired more than 30 days ago or were revoked more than 30 days ago
 	cutoffTime := time.Now().Add(-30 * 24 * time.Hour)
 
-	result, err := m.db.ExecContext(ctx, query, time.Now(), cutoffTime)
+	result, err := safesql.ExecContext(ctx, m.db, safesql.New(query, time.Now().UTC(), cutoffTime.UTC()))
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup expired tokens: %w", err)
 	}
@@ -281,7 +274,7 @@ type TokenMetadata struct {
 
 // GetUserActiveTokens returns all active (non-revoked, non-expired) tokens for a user
 func (m *RefreshTokenManager) GetUserActiveTokens(ctx context.Context, userID int) ([]RefreshToken, error) {
-	query := `
+	const query = `
 		SELECT id, user_id, token_hash, token_family, expires_at, created_at,
 		       last_used, revoked, revoked_at, revoked_reason,
 		       user_agent, ip_address, device_fingerprint
@@ -290,7 +283,7 @@ func (m *RefreshTokenManager) GetUserActiveTokens(ctx context.Context, userID in
 		ORDER BY created_at DESC
 	`
 
-	rows, err := m.db.QueryContext(ctx, query, userID, time.Now())
+	rows, err := safesql.QueryContext(ctx, m.db, safesql.New(query, userID, time.Now().UTC()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query active tokens: %w", err)
 	}
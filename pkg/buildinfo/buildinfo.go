@@ -0,0 +1,111 @@
+// Package buildinfo describes how a binary was built, for --version, the
+// server's health endpoint and the telemetry of every submission, so
+// evidence can be traced to the exact agent build that collected it.
+//
+// Release builds set the provenance with -ldflags:
+//
+//	go build -ldflags "-X compliancetoolkit/pkg/buildinfo.Commit=$(git rev-parse HEAD)
+//	  -X compliancetoolkit/pkg/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+//	  -X compliancetoolkit/pkg/buildinfo.Builder=ci@build01" ./cmd/compliance-server
+//
+// Without them the commit and time Go records from the git checkout are
+// used, when there is one.
+package buildinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// Set with -ldflags -X at build time
+var (
+	Commit    string // Git commit the binary was built from
+	BuildTime string // RFC 3339 time of the build
+	Builder   string // Who or what built it, e.g. a CI runner
+)
+
+// Get returns the build information of the running binary, whose release
+// version is version
+func Get(version string) api.BuildInfo {
+	info := api.BuildInfo{
+		Version:      version,
+		Commit:       Commit,
+		BuildTime:    BuildTime,
+		Builder:      Builder,
+		GoVersion:    runtime.Version(),
+		BinarySHA256: binaryHash(),
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	vcs := map[string]string{}
+	for _, setting := range build.Settings {
+		vcs[setting.Key] = setting.Value
+	}
+	if info.Commit == "" {
+		info.Commit = vcs["vcs.revision"]
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = vcs["vcs.time"]
+	}
+	// Uncommitted changes are only known for the checkout Go built from
+	if info.Commit != "" && info.Commit == vcs["vcs.revision"] {
+		info.Modified = vcs["vcs.modified"] == "true"
+	}
+	return info
+}
+
+// String formats the build information for --version output
+func String(name string, info api.BuildInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s v%s\n", name, info.Version)
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if info.Modified {
+		commit += " (modified)"
+	}
+	fmt.Fprintf(&b, "  Commit:     %s\n", commit)
+	fmt.Fprintf(&b, "  Built:      %s\n", orUnknown(info.BuildTime))
+	fmt.Fprintf(&b, "  Builder:    %s\n", orUnknown(info.Builder))
+	fmt.Fprintf(&b, "  Go:         %s\n", info.GoVersion)
+	fmt.Fprintf(&b, "  SHA-256:    %s\n", orUnknown(info.BinarySHA256))
+	return b.String()
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// binaryHash returns the SHA-256 of the running executable, read once; empty
+// when it cannot be read
+var binaryHash = sync.OnceValue(func() string {
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+})
@@ -0,0 +1,56 @@
+package buildinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	Commit, BuildTime, Builder = "0123abcd", "2026-10-15T08:00:00Z", "ci@build01"
+	defer func() { Commit, BuildTime, Builder = "", "", "" }()
+
+	info := Get("2.1.0")
+	if info.Version != "2.1.0" || info.Commit != "0123abcd" || info.BuildTime != "2026-10-15T08:00:00Z" || info.Builder != "ci@build01" {
+		t.Errorf("Get() = %+v, want the version and the values set at build time", info)
+	}
+	if info.Modified {
+		t.Error("Get().Modified = true for a commit that is not the checkout's")
+	}
+	if info.GoVersion == "" {
+		t.Error("Get().GoVersion is empty")
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		t.Skipf("no executable path: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Skipf("cannot read the executable: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if want := hex.EncodeToString(sum[:]); info.BinarySHA256 != want {
+		t.Errorf("Get().BinarySHA256 = %q, want %q", info.BinarySHA256, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	info := Get("1.0.0")
+	info.Commit, info.Modified, info.Builder, info.BuildTime = "0123abcd", true, "", "2026-10-15T08:00:00Z"
+
+	got := String("Compliance Toolkit Client", info)
+	for _, want := range []string{
+		"Compliance Toolkit Client v1.0.0\n",
+		"Commit:     0123abcd (modified)\n",
+		"Built:      2026-10-15T08:00:00Z\n",
+		"Builder:    unknown\n",
+		"SHA-256:    " + info.BinarySHA256 + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("String() = %q, missing %q", got, want)
+		}
+	}
+}
@@ -2,17 +2,118 @@ package pkg
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/decode"
 )
 
 // RegistryConfig represents the JSON configuration structure
 type RegistryConfig struct {
 	Version  string          `json:"version"`
 	Metadata ReportMetadata  `json:"metadata"`
-	Queries  []RegistryQuery `json:"queries"`
+	Security *ReportSecurity `json:"security,omitempty"`
+
+	// Profiles name subsets of the queries, selected by tag, so one report
+	// serves e.g. a quick hourly drift check and a full nightly audit
+	Profiles map[string]ReportProfile `json:"profiles,omitempty"`
+
+	Queries []RegistryQuery `json:"queries"`
+}
+
+// ReportProfile selects the queries of a report tagged with any of Tags
+type ReportProfile struct {
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+}
+
+// ErrUnknownProfile is returned by ApplyProfile for a profile the report
+// does not define
+var ErrUnknownProfile = errors.New("report has no such profile")
+
+// ApplyProfile keeps only the queries of the named profile. An empty name
+// keeps every query, the full scan.
+func (c *RegistryConfig) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if names := c.ProfileNames(); len(names) > 0 {
+			return fmt.Errorf("%w %q (profiles: %s)", ErrUnknownProfile, name, strings.Join(names, ", "))
+		}
+		return fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+
+	queries := make([]RegistryQuery, 0, len(c.Queries))
+	for _, query := range c.Queries {
+		if query.HasAnyTag(profile.Tags) {
+			queries = append(queries, query)
+		}
+	}
+	c.Queries = queries
+	return nil
+}
+
+// ProfileNames returns the names of the report's profiles, sorted
+func (c *RegistryConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReportSecurity restricts the registry locations a report's queries may
+// read. A report distributed by the server carries it so endpoint security
+// settings are managed centrally; the compliance client combines it with
+// its own settings using Stricter.
+type ReportSecurity struct {
+	AllowedRegistryRoots []string `json:"allowed_registry_roots,omitempty"` // Root keys queries may read (empty allows all)
+	DenyRegistryPaths    []string `json:"deny_registry_paths,omitempty"`    // Paths, and their subkeys, queries may never read
+	AuditMode            bool     `json:"audit_mode,omitempty"`             // Log every registry location queries read or are refused
+}
+
+// Stricter combines two sets of security settings, keeping the stricter of
+// each: paths denied by either are denied, only root keys allowed by both
+// are allowed, and audit mode is on if either sets it. ok is false when both
+// allow root keys but none in common, so no query may run.
+func (s ReportSecurity) Stricter(other ReportSecurity) (merged ReportSecurity, ok bool) {
+	merged.AuditMode = s.AuditMode || other.AuditMode
+
+	seen := make(map[string]bool)
+	for _, path := range append(append([]string{}, s.DenyRegistryPaths...), other.DenyRegistryPaths...) {
+		key := strings.ToLower(strings.TrimSpace(path))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged.DenyRegistryPaths = append(merged.DenyRegistryPaths, path)
+	}
+
+	switch {
+	case len(s.AllowedRegistryRoots) == 0:
+		merged.AllowedRegistryRoots = other.AllowedRegistryRoots
+	case len(other.AllowedRegistryRoots) == 0:
+		merged.AllowedRegistryRoots = s.AllowedRegistryRoots
+	default:
+		for _, root := range s.AllowedRegistryRoots {
+			if ValidateAgainstAllowList(root, other.AllowedRegistryRoots) == nil {
+				merged.AllowedRegistryRoots = append(merged.AllowedRegistryRoots, root)
+			}
+		}
+		if len(merged.AllowedRegistryRoots) == 0 {
+			return merged, false
+		}
+	}
+	return merged, true
 }
 
 // ReportMetadata contains report identification and versioning
@@ -28,16 +129,228 @@ type ReportMetadata struct {
 
 // RegistryQuery represents a single registry operation
 type RegistryQuery struct {
-	Name          string      `json:"name"`
-	Description   string      `json:"description"`
-	RootKey       string      `json:"root_key"`
-	Path          string      `json:"path"`
-	ValueName     string      `json:"value_name,omitempty"`
-	Operation     string      `json:"operation"`
-	ReadAll       bool        `json:"read_all,omitempty"`
-	WriteType     string      `json:"write_type,omitempty"`
-	WriteValue    interface{} `json:"write_value,omitempty"`
-	ExpectedValue string      `json:"expected_value,omitempty"` // For compliance reporting
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	RootKey          string      `json:"root_key"`
+	Path             string      `json:"path"`
+	ValueName        string      `json:"value_name,omitempty"`
+	Operation        string      `json:"operation"`
+	ReadAll          bool        `json:"read_all,omitempty"`
+	WriteType        string      `json:"write_type,omitempty"`
+	WriteValue       interface{} `json:"write_value,omitempty"`
+	ExpectedValue    string      `json:"expected_value,omitempty"`    // For compliance reporting
+	ExpectedOperator string      `json:"expected_operator,omitempty"` // How the value is compared with ExpectedValue (api.Operator*)
+
+	// Tags group queries for report profiles, e.g. ["quick", "network"]
+	Tags []string `json:"tags,omitempty"`
+
+	// ExpandEnv expands the environment variables of a REG_EXPAND_SZ value,
+	// such as %SystemRoot%, before it is reported and compared
+	ExpandEnv bool `json:"expand_env,omitempty"`
+
+	// Decoder names the decoder (see package decode) of a REG_BINARY value,
+	// which is then reported as readable fields instead of hex. Well-known
+	// values, such as DigitalProductId, are decoded without it.
+	// DecodedField reports and compares a single field of the result.
+	Decoder      string `json:"decoder,omitempty"`
+	DecodedField string `json:"decoded_field,omitempty"`
+
+	// MaxDepth and MaxKeys limit read_tree queries, which read the key at
+	// Path and its subkeys: the levels of subkeys read and the keys read
+	// at most (0: DefaultTreeDepth and DefaultTreeKeys)
+	MaxDepth int `json:"max_depth,omitempty"`
+	MaxKeys  int `json:"max_keys,omitempty"`
+
+	// Script is the script block of a powershell query, whose output is
+	// compared with ExpectedValue. It only runs with a valid
+	// ScriptSignature, the base64 Ed25519 signature over
+	// api.ScriptSigningPayload. TimeoutSeconds stops a script that runs
+	// longer (0: the client's security.powershell.timeout).
+	Script          string `json:"script,omitempty"`
+	ScriptSignature string `json:"script_signature,omitempty"`
+	TimeoutSeconds  int    `json:"timeout_seconds,omitempty"`
+
+	// Controls maps compliance frameworks to the control IDs the query
+	// covers, e.g. {"NIST 800-171": ["3.1.1"], "CIS": ["2.3.1.1"]}
+	Controls map[string][]string `json:"controls,omitempty"`
+
+	// RequiresElevation marks a query only an administrator or LocalSystem
+	// can run, such as a script calling an administrative cmdlet. Reads of
+	// the locations in elevatedPaths and of other users' hives are known to
+	// need it without being marked.
+	RequiresElevation bool `json:"requires_elevation,omitempty"`
+}
+
+// elevatedPaths are the HKEY_LOCAL_MACHINE keys, and their subkeys, that
+// only LocalSystem can read
+var elevatedPaths = []string{`SECURITY`, `SAM`}
+
+// NeedsElevation reports whether the query can only run in an elevated
+// process, and why
+func (q RegistryQuery) NeedsElevation() (bool, string) {
+	if q.RequiresElevation {
+		return true, "marked requires_elevation"
+	}
+	if q.IsPowerShell() {
+		return false, ""
+	}
+	if q.IsPerUser() {
+		return true, "reads the hives of other users"
+	}
+	if root, err := ParseRootKey(strings.ToUpper(strings.TrimSpace(q.RootKey))); err != nil || root != registry.LOCAL_MACHINE {
+		return false, ""
+	}
+	path := strings.ToUpper(strings.Trim(q.Path, `\`))
+	for _, elevated := range elevatedPaths {
+		if path == elevated || strings.HasPrefix(path, elevated+`\`) {
+			return true, `HKLM\` + elevated + " is readable by LocalSystem only"
+		}
+	}
+	return false, ""
+}
+
+// HasAnyTag reports whether the query is tagged with any of tags, ignoring
+// case
+func (q RegistryQuery) HasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, own := range q.Tags {
+			if strings.EqualFold(tag, own) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches reports whether actual satisfies the query's expected value and
+// operator. An error means the comparison could not be made.
+func (q RegistryQuery) Matches(actual string) (bool, error) {
+	return api.CompareWithOperator(actual, q.ExpectedValue, q.ExpectedOperator)
+}
+
+// MatchesValue reports whether a typed value satisfies the query's expected
+// value and operator. The items of a multi-string are compared as a list
+// (see api.CompareList), and a REG_EXPAND_SZ is expanded first when the
+// query sets expand_env.
+func (q RegistryQuery) MatchesValue(value *RegistryValue) (bool, error) {
+	if q.ExpandEnv {
+		var err error
+		if value, err = value.Expand(); err != nil {
+			return false, err
+		}
+	}
+	if value.IsList() {
+		return api.CompareList(value.Strings, q.ExpectedValue, q.ExpectedOperator)
+	}
+	return q.Matches(value.Display())
+}
+
+// MatchesOutput reports whether the output lines of a powershell query's
+// script satisfy the query's expected value and operator. Several lines,
+// such as one per BitLocker volume, are compared as a list.
+func (q RegistryQuery) MatchesOutput(lines []string) (bool, error) {
+	if len(lines) > 1 {
+		return api.CompareList(lines, q.ExpectedValue, q.ExpectedOperator)
+	}
+	return q.Matches(strings.Join(lines, ""))
+}
+
+// DecodeValue decodes a REG_BINARY value with the query's decoder, or with
+// the decoder of a well-known value. Binary values without a decoder are
+// returned as they are; naming a decoder for another type is an error.
+func (q RegistryQuery) DecodeValue(value *RegistryValue) (*RegistryValue, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	rootKey := q.RootKey
+	if key, err := ParseRootKey(q.RootKey); err == nil {
+		rootKey = RootKeyToString(key)
+	}
+	decoder, ok, err := decode.For(q.Decoder, rootKey, q.Path, q.ValueName)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if q.DecodedField != "" {
+			return nil, fmt.Errorf("decoded_field %q needs a decoder", q.DecodedField)
+		}
+		return value, nil
+	}
+	if value.Type != RegTypeBinary {
+		if q.Decoder == "" {
+			return value, nil
+		}
+		return nil, fmt.Errorf("decoder %s applies to %s values, not %s", decoder.Name, RegTypeBinary, value.Type)
+	}
+
+	decoded, err := decoder.Apply(value.Binary)
+	if err != nil {
+		return nil, err
+	}
+	if q.DecodedField == "" {
+		return &RegistryValue{Type: RegTypeBinary, Binary: value.Binary, Decoded: &decoded}, nil
+	}
+	field, ok := decoded.Field(q.DecodedField)
+	if !ok {
+		return nil, fmt.Errorf("decoder %s has no field %q", decoder.Name, q.DecodedField)
+	}
+	return &RegistryValue{Type: RegTypeString, String: field}, nil
+}
+
+// IsTree reports whether the query's result is a RegistryTree: a subtree
+// (operation read_tree) or a key of every user (operation read_users)
+func (q RegistryQuery) IsTree() bool {
+	return strings.EqualFold(q.Operation, "read_tree") || q.IsPerUser()
+}
+
+// IsPerUser reports whether the query reads a key of every user whose hive
+// is loaded (operation read_users)
+func (q RegistryQuery) IsPerUser() bool {
+	return strings.EqualFold(q.Operation, "read_users")
+}
+
+// IsPowerShell reports whether the query runs a script block instead of
+// reading the registry (operation powershell)
+func (q RegistryQuery) IsPowerShell() bool {
+	return strings.EqualFold(q.Operation, "powershell")
+}
+
+// TreeOptions returns the limits of a read_tree or read_users query.
+// Subkeys under denyPaths are skipped.
+func (q RegistryQuery) TreeOptions(denyPaths []string) TreeOptions {
+	return TreeOptions{MaxDepth: q.MaxDepth, MaxKeys: q.MaxKeys, ValueName: q.ValueName, DenyPaths: denyPaths, PerUser: q.IsPerUser()}
+}
+
+// MatchesTree compares every value of a read_tree or read_users result
+// with the query's expected value and returns those that do not match.
+// Users without the key or value never match. A tree without values or
+// missing users returns ErrEmptyTree, which is a failed check; any other
+// error means a comparison could not be made.
+func (q RegistryQuery) MatchesTree(tree *RegistryTree) ([]TreeValue, error) {
+	values := tree.Values()
+	if len(values) == 0 && len(tree.Missing) == 0 {
+		return nil, ErrEmptyTree
+	}
+	var mismatches []TreeValue
+	for _, sid := range tree.Missing {
+		mismatches = append(mismatches, TreeValue{Path: sid, Name: q.ValueName, Value: "not found"})
+	}
+	for _, value := range values {
+		matches, err := q.Matches(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", value, err)
+		}
+		if !matches {
+			mismatches = append(mismatches, value)
+		}
+	}
+	return mismatches, nil
+}
+
+// ExpectedDescription renders the expected value with its operator for reports
+func (q RegistryQuery) ExpectedDescription() string {
+	return api.DescribeExpected(q.ExpectedValue, q.ExpectedOperator)
 }
 
 // LoadRegistryConfig loads registry operations from a JSON file (renamed to avoid conflict)
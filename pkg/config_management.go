@@ -10,6 +10,8 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"compliancetoolkit/pkg/netshare"
 )
 
 // Config represents the complete application configuration
@@ -70,6 +72,41 @@ type ReportsConfig struct {
 	Parallel bool `mapstructure:"parallel"`
 	// MaxParallelReports limits concurrent report generation (0 = CPU count)
 	MaxParallelReports int `mapstructure:"max_parallel_reports"`
+	// ExportFormats lists spreadsheet formats ("csv", "xlsx") written next to each HTML report
+	ExportFormats []string `mapstructure:"export_formats"`
+	// Retention limits how many old reports and evidence logs are kept
+	Retention RetentionConfig `mapstructure:"retention"`
+	// Share configures access when OutputPath or EvidencePath is a UNC path
+	Share ShareConfig `mapstructure:"share"`
+}
+
+// RetentionConfig limits how much output the toolkit keeps in each output
+// directory. Zero disables a limit.
+type RetentionConfig struct {
+	// CleanupOnStartup applies retention every time the toolkit starts
+	CleanupOnStartup bool `mapstructure:"cleanup_on_startup"`
+	// MaxAgeDays deletes files older than this many days
+	MaxAgeDays int `mapstructure:"max_age_days"`
+	// MaxFiles keeps only this many of the newest files per directory
+	MaxFiles int `mapstructure:"max_files"`
+	// MaxSizeMB deletes the oldest files once a directory exceeds this size
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+}
+
+// ShareConfig controls how reports and evidence are written to a UNC file
+// share. Output that cannot reach the share is written to FallbackPath and
+// moved to the share on a later run.
+type ShareConfig struct {
+	// Username for the share (user, DOMAIN\user or user@domain); empty uses the current user
+	Username string `mapstructure:"username"`
+	// PasswordEnv names the environment variable holding the password
+	PasswordEnv string `mapstructure:"password_env"`
+	// RetryAttempts is how many times a write is tried before falling back
+	RetryAttempts int `mapstructure:"retry_attempts"`
+	// RetryDelay is the wait before the first retry, doubled after each
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// FallbackPath is the local directory used while the share is unreachable
+	FallbackPath string `mapstructure:"fallback_path"`
 }
 
 // SecurityConfig contains security-related configuration
@@ -80,12 +117,17 @@ type SecurityConfig struct {
 	AllowedRegistryRoots []string `mapstructure:"allowed_registry_roots"`
 	// DenyRegistryPaths blocks specific registry paths (security-sensitive keys)
 	DenyRegistryPaths []string `mapstructure:"deny_registry_paths"`
-	// ReadOnly enforces read-only mode (always true for compliance scanner)
+	// ReadOnly blocks registry writes. It must be set to false before
+	// "remediate" queries may change values; dry runs work either way.
 	ReadOnly bool `mapstructure:"read_only"`
 	// AuditMode logs all registry access attempts
 	AuditMode bool `mapstructure:"audit_mode"`
 	// AuditLogPath is the directory where audit logs are stored
 	AuditLogPath string `mapstructure:"audit_log_path"`
+	// CharacterPolicy is the characters registry paths and value names may
+	// contain: "ascii", or "unicode" for localized Windows. LoadConfig
+	// applies it with SetCharacterPolicy.
+	CharacterPolicy string `mapstructure:"character_policy"`
 }
 
 // DefaultConfig returns a Config with sensible defaults
@@ -115,6 +157,20 @@ func DefaultConfig() *Config {
 			EnableDarkMode:     true,
 			Parallel:           false,
 			MaxParallelReports: 0, // 0 = use runtime.NumCPU()
+			ExportFormats:      []string{},
+			Retention: RetentionConfig{
+				CleanupOnStartup: false,
+				MaxAgeDays:       0, // 0 = keep everything
+				MaxFiles:         0,
+				MaxSizeMB:        0,
+			},
+			Share: ShareConfig{
+				Username:      "", // Empty = current user
+				PasswordEnv:   "",
+				RetryAttempts: 3,
+				RetryDelay:    2 * time.Second,
+				FallbackPath:  "output/pending",
+			},
 		},
 		Security: SecurityConfig{
 			RequireAdminPrivileges: false,
@@ -131,9 +187,10 @@ func DefaultConfig() *Config {
 				`SECURITY\Policy\Secrets`,
 				`SAM\SAM\Domains\Account\Users`,
 			},
-			ReadOnly:     true, // Always read-only for compliance scanner
-			AuditMode:    false,
-			AuditLogPath: "output/audit",
+			ReadOnly:        true, // Remediation must be enabled explicitly
+			AuditMode:       false,
+			AuditLogPath:    "output/audit",
+			CharacterPolicy: string(CharacterPolicyASCII),
 		},
 	}
 }
@@ -196,6 +253,7 @@ func LoadConfig(configPath string, flags *pflag.FlagSet) (*Config, error) {
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	SetCharacterPolicy(CharacterPolicy(config.Security.CharacterPolicy))
 
 	return &config, nil
 }
@@ -226,6 +284,16 @@ func setDefaults(v *viper.Viper, cfg *Config) {
 	v.SetDefault("reports.enable_dark_mode", cfg.Reports.EnableDarkMode)
 	v.SetDefault("reports.parallel", cfg.Reports.Parallel)
 	v.SetDefault("reports.max_parallel_reports", cfg.Reports.MaxParallelReports)
+	v.SetDefault("reports.export_formats", cfg.Reports.ExportFormats)
+	v.SetDefault("reports.retention.cleanup_on_startup", cfg.Reports.Retention.CleanupOnStartup)
+	v.SetDefault("reports.retention.max_age_days", cfg.Reports.Retention.MaxAgeDays)
+	v.SetDefault("reports.retention.max_files", cfg.Reports.Retention.MaxFiles)
+	v.SetDefault("reports.retention.max_size_mb", cfg.Reports.Retention.MaxSizeMB)
+	v.SetDefault("reports.share.username", cfg.Reports.Share.Username)
+	v.SetDefault("reports.share.password_env", cfg.Reports.Share.PasswordEnv)
+	v.SetDefault("reports.share.retry_attempts", cfg.Reports.Share.RetryAttempts)
+	v.SetDefault("reports.share.retry_delay", cfg.Reports.Share.RetryDelay)
+	v.SetDefault("reports.share.fallback_path", cfg.Reports.Share.FallbackPath)
 
 	// Security defaults
 	v.SetDefault("security.require_admin_privileges", cfg.Security.RequireAdminPrivileges)
@@ -234,6 +302,7 @@ func setDefaults(v *viper.Viper, cfg *Config) {
 	v.SetDefault("security.read_only", cfg.Security.ReadOnly)
 	v.SetDefault("security.audit_mode", cfg.Security.AuditMode)
 	v.SetDefault("security.audit_log_path", cfg.Security.AuditLogPath)
+	v.SetDefault("security.character_policy", cfg.Security.CharacterPolicy)
 }
 
 // validateConfig performs validation on the loaded configuration
@@ -277,21 +346,47 @@ func validateConfig(cfg *Config) error {
 		if p.path == "" {
 			return fmt.Errorf("%s cannot be empty", p.name)
 		}
+		// Shares may need credentials and are prepared when the toolkit starts
+		if netshare.IsUNC(p.path) {
+			continue
+		}
 		// Try to create directory if it doesn't exist
 		if err := os.MkdirAll(p.path, 0755); err != nil {
 			return fmt.Errorf("cannot create directory %s (%s): %w", p.name, p.path, err)
 		}
 	}
 
-	// Validate security: ReadOnly must always be true
-	if !cfg.Security.ReadOnly {
-		return fmt.Errorf("security.read_only must be true (compliance scanner is read-only)")
+	// Validate export formats
+	if err := ValidateExportFormats(cfg.Reports.ExportFormats); err != nil {
+		return fmt.Errorf("reports.export_formats: %w", err)
+	}
+
+	// Validate retention limits
+	if err := cfg.Reports.Retention.Validate(); err != nil {
+		return fmt.Errorf("reports.retention: %w", err)
+	}
+
+	// Validate share settings
+	if cfg.Reports.Share.RetryAttempts < 1 {
+		return fmt.Errorf("reports.share.retry_attempts must be at least 1 (got %d)", cfg.Reports.Share.RetryAttempts)
+	}
+	if cfg.Reports.Share.RetryDelay < 0 {
+		return fmt.Errorf("reports.share.retry_delay cannot be negative")
+	}
+	if cfg.Reports.Share.PasswordEnv != "" && cfg.Reports.Share.Username == "" {
+		return fmt.Errorf("reports.share.password_env requires reports.share.username")
 	}
 
 	// Validate allowed registry roots
 	if len(cfg.Security.AllowedRegistryRoots) == 0 {
 		return fmt.Errorf("security.allowed_registry_roots cannot be empty")
 	}
+	if err := ValidateDenyList(cfg.Security.DenyRegistryPaths); err != nil {
+		return fmt.Errorf("security.deny_registry_paths: %w", err)
+	}
+	if err := ValidateCharacterPolicy(CharacterPolicy(cfg.Security.CharacterPolicy)); err != nil {
+		return fmt.Errorf("security.character_policy: %w", err)
+	}
 
 	return nil
 }
@@ -1,8 +1,12 @@
 package pkg
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"golang.org/x/sys/windows/registry"
@@ -210,3 +214,248 @@ func TestParseRootKey_AllKeys(t *testing.T) {
 		})
 	}
 }
+
+// TestReportSecurityStricter tests that combining security settings keeps
+// the stricter of each setting
+func TestReportSecurityStricter(t *testing.T) {
+	client := ReportSecurity{
+		AllowedRegistryRoots: []string{"HKLM", "HKCU"},
+		DenyRegistryPaths:    []string{`SECURITY\Policy\Secrets`},
+	}
+
+	tests := []struct {
+		name      string
+		report    ReportSecurity
+		wantRoots []string
+		wantDeny  int
+		wantAudit bool
+		wantOK    bool
+	}{
+		{"no report settings", ReportSecurity{}, []string{"HKLM", "HKCU"}, 1, false, true},
+		{"narrower roots", ReportSecurity{AllowedRegistryRoots: []string{"HKEY_LOCAL_MACHINE", "HKU"}}, []string{"HKLM"}, 1, false, true},
+		{"disjoint roots", ReportSecurity{AllowedRegistryRoots: []string{"HKU"}}, nil, 1, false, false},
+		{"extra and duplicate deny", ReportSecurity{DenyRegistryPaths: []string{`security\policy\secrets`, `SAM\SAM`}}, []string{"HKLM", "HKCU"}, 2, false, true},
+		{"audit mode", ReportSecurity{AuditMode: true}, []string{"HKLM", "HKCU"}, 1, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, ok := client.Stricter(tt.report)
+			if ok != tt.wantOK {
+				t.Fatalf("Stricter() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(merged.AllowedRegistryRoots, tt.wantRoots) {
+				t.Errorf("allowed roots = %v, want %v", merged.AllowedRegistryRoots, tt.wantRoots)
+			}
+			if len(merged.DenyRegistryPaths) != tt.wantDeny || merged.AuditMode != tt.wantAudit {
+				t.Errorf("merged = %+v, want %d denied paths and audit mode %v", merged, tt.wantDeny, tt.wantAudit)
+			}
+		})
+	}
+
+	// A report cannot widen what the client allows
+	open, _ := ReportSecurity{}.Stricter(ReportSecurity{AllowedRegistryRoots: []string{"HKLM"}})
+	if !reflect.DeepEqual(open.AllowedRegistryRoots, []string{"HKLM"}) {
+		t.Errorf("client allowing all roots: allowed = %v, want the report's [HKLM]", open.AllowedRegistryRoots)
+	}
+}
+
+func TestRegistryQueryMatchesTree(t *testing.T) {
+	tree := &RegistryTree{Keys: []TreeKey{
+		{Path: "", Values: map[string]string{"": "root"}},
+		{Path: "Alpha", Values: map[string]string{"Start": "4"}},
+		{Path: `Alpha\Sub`, Values: map[string]string{"Start": "2", "Type": "1"}},
+	}, Truncated: true}
+
+	wantString := "(Default) = root\nAlpha\\Start = 4\nAlpha\\Sub\\Start = 2\nAlpha\\Sub\\Type = 1\n(truncated after 3 keys)"
+	if got := tree.String(); got != wantString {
+		t.Errorf("String() = %q, want %q", got, wantString)
+	}
+
+	query := RegistryQuery{Operation: "read_tree", ExpectedValue: "2", ExpectedOperator: "lte"}
+	mismatches, err := query.MatchesTree(&RegistryTree{Keys: tree.Keys[1:]})
+	if err != nil {
+		t.Fatalf("MatchesTree() error = %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].String() != `Alpha\Start = 4` {
+		t.Errorf("MatchesTree() mismatches = %v, want only Alpha\\Start", mismatches)
+	}
+
+	if _, err := query.MatchesTree(&RegistryTree{}); !errors.Is(err, ErrEmptyTree) {
+		t.Errorf("MatchesTree() empty tree error = %v, want ErrEmptyTree", err)
+	}
+	if !query.IsTree() || (RegistryQuery{Operation: "read"}).IsTree() {
+		t.Error("IsTree() reports the wrong operations")
+	}
+}
+
+func TestRegistryQueryMatchesTreePerUser(t *testing.T) {
+	tree := &RegistryTree{
+		Keys: []TreeKey{
+			{Path: "S-1-5-21-1-1001", Values: map[string]string{"ScreenSaveActive": "1"}},
+			{Path: "S-1-5-21-1-1002", Values: map[string]string{"ScreenSaveActive": "0"}},
+		},
+		Missing: []string{"S-1-12-1-7"},
+	}
+	query := RegistryQuery{Operation: "read_users", ValueName: "ScreenSaveActive", ExpectedValue: "1"}
+
+	mismatches, err := query.MatchesTree(tree)
+	if err != nil {
+		t.Fatalf("MatchesTree() error = %v", err)
+	}
+	if len(mismatches) != 2 || mismatches[0].String() != `S-1-12-1-7\ScreenSaveActive = not found` ||
+		mismatches[1].String() != `S-1-5-21-1-1002\ScreenSaveActive = 0` {
+		t.Errorf("MatchesTree() mismatches = %v, want the missing user and the disabled one", mismatches)
+	}
+	if tree.Compared() != 3 {
+		t.Errorf("Compared() = %d, want 3", tree.Compared())
+	}
+	if !strings.HasSuffix(tree.String(), "\nS-1-12-1-7 = not found") {
+		t.Errorf("String() = %q, want the missing user listed", tree.String())
+	}
+	if _, err := query.MatchesTree(&RegistryTree{Missing: []string{"S-1-5-21-1-1001"}}); err != nil {
+		t.Errorf("MatchesTree() with only missing users error = %v, want a failed comparison", err)
+	}
+	if !query.IsTree() || !query.IsPerUser() || (RegistryQuery{Operation: "read_tree"}).IsPerUser() {
+		t.Error("IsTree() or IsPerUser() reports the wrong operations")
+	}
+	if opts := query.TreeOptions(nil); !opts.PerUser {
+		t.Error("TreeOptions() of a read_users query is not per user")
+	}
+}
+
+func TestUserSIDs(t *testing.T) {
+	for name, want := range map[string]bool{
+		"S-1-5-21-3623811015-3361044348-30300820-1013":         true,
+		"S-1-12-1-2212432321-1144212340-3412313112-2131321211": true,
+		"S-1-5-21-3623811015-3361044348-30300820-1013_Classes": false,
+		"S-1-5-18": false,
+		"S-1-5-19": false,
+		".DEFAULT": false,
+	} {
+		if got := IsUserSID(name); got != want {
+			t.Errorf("IsUserSID(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if got := UserPath(`Software\Policies\Microsoft\Windows\Control Panel\Desktop`, "S-1-5-21-1-1001"); got != `S-1-5-21-1-1001\Software\Policies\Microsoft\Windows\Control Panel\Desktop` {
+		t.Errorf("UserPath() below the hive = %q", got)
+	}
+	if got := UserPath(`SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList\{sid}`, "S-1-5-21-1-1001"); got != `SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList\S-1-5-21-1-1001` {
+		t.Errorf("UserPath() with the placeholder = %q", got)
+	}
+}
+
+func TestRegistryConfigApplyProfile(t *testing.T) {
+	load := func() *RegistryConfig {
+		return &RegistryConfig{
+			Profiles: map[string]ReportProfile{
+				"quick":   {Tags: []string{"quick"}},
+				"network": {Tags: []string{"firewall", "smb"}},
+			},
+			Queries: []RegistryQuery{
+				{Name: "uac", Tags: []string{"quick"}},
+				{Name: "firewall", Tags: []string{"Quick", "firewall"}},
+				{Name: "smb1", Tags: []string{"smb"}},
+				{Name: "bitlocker"},
+			},
+		}
+	}
+	names := func(config *RegistryConfig) []string {
+		var names []string
+		for _, query := range config.Queries {
+			names = append(names, query.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		{"", []string{"uac", "firewall", "smb1", "bitlocker"}},
+		{"quick", []string{"uac", "firewall"}},
+		{"network", []string{"firewall", "smb1"}},
+	}
+	for _, tt := range tests {
+		config := load()
+		if err := config.ApplyProfile(tt.profile); err != nil {
+			t.Fatalf("ApplyProfile(%q) error = %v", tt.profile, err)
+		}
+		if got := names(config); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ApplyProfile(%q) queries = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+
+	config := load()
+	if err := config.ApplyProfile("nightly"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("ApplyProfile(unknown) error = %v, want ErrUnknownProfile", err)
+	}
+	if len(config.Queries) != 4 {
+		t.Errorf("ApplyProfile(unknown) left %d queries, want all 4", len(config.Queries))
+	}
+	if got := config.ProfileNames(); !reflect.DeepEqual(got, []string{"network", "quick"}) {
+		t.Errorf("ProfileNames() = %v", got)
+	}
+}
+
+// FuzzReportConfig tests that decoding and validating arbitrary report
+// configurations, as a policy download is loaded, does not panic, and that
+// the queries of a valid configuration can be compared and profiled
+func FuzzReportConfig(f *testing.F) {
+	f.Add([]byte(`{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+		"profiles":{"quick":{"tags":["quick"]}},
+		"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","value_name":"V","operation":"read","expected_value":"1,5","expected_operator":"between","tags":["quick"]}]}`))
+	f.Add([]byte(`{"version":"1.0","queries":[{"name":"u","root_key":"HKU","path":"Control Panel\\Desktop","operation":"read_users","value_name":"S"},
+		{"name":"t","root_key":"HKLM","path":"SYSTEM\\X","operation":"read_tree","max_depth":2,"max_keys":10}]}`))
+	f.Add([]byte(`{"version":"1.0","queries":[{"name":"p","operation":"powershell","script":"Get-Date","script_signature":"c2ln"}]}`))
+	f.Add([]byte(`{"version":"1.0","queries":[{"name":"d","root_key":"HKLM","path":"..\\SAM","operation":"read","decoder":"filetime"}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var config RegistryConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return
+		}
+		if err := ValidateConfig(&config); err != nil {
+			return
+		}
+		for _, query := range config.Queries {
+			query.Matches("1")
+			query.MatchesOutput([]string{"1", "2"})
+			query.ExpectedDescription()
+		}
+		for _, name := range config.ProfileNames() {
+			profiled := config
+			if err := profiled.ApplyProfile(name); err != nil {
+				t.Fatalf("ApplyProfile(%q) error = %v", name, err)
+			}
+		}
+	})
+}
+
+func TestRegistryQueryNeedsElevation(t *testing.T) {
+	tests := []struct {
+		name  string
+		query RegistryQuery
+		want  bool
+	}{
+		{"standard read", RegistryQuery{RootKey: "HKLM", Path: `SOFTWARE\Policies\Microsoft\Windows`, Operation: "read"}, false},
+		{"current user", RegistryQuery{RootKey: "HKCU", Path: `Software\Policies`, Operation: "read"}, false},
+		{"LSA policy", RegistryQuery{RootKey: "HKLM", Path: `SECURITY\Policy\PolAdtEv`, Operation: "read"}, true},
+		{"SAM root, long form", RegistryQuery{RootKey: "HKEY_LOCAL_MACHINE", Path: `sam`, Operation: "read_tree"}, true},
+		{"similar name", RegistryQuery{RootKey: "HKLM", Path: `SECURITYCENTER\Settings`, Operation: "read"}, false},
+		{"other root", RegistryQuery{RootKey: "HKU", Path: `SECURITY\Policy`, Operation: "read"}, false},
+		{"every user", RegistryQuery{RootKey: "HKU", Path: `Software\Policies`, Operation: "read_users"}, true},
+		{"script", RegistryQuery{Operation: "powershell", Script: "(Get-MpComputerStatus).AntivirusEnabled"}, false},
+		{"marked script", RegistryQuery{Operation: "powershell", Script: "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus", RequiresElevation: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.query.NeedsElevation()
+			if got != tt.want || (reason != "") != tt.want {
+				t.Errorf("NeedsElevation() = %v, %q; want %v with a reason", got, reason, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Names of the built-in decoders
+const (
+	FileTime         = "filetime"
+	SID              = "sid"
+	UTF16            = "utf16"
+	ShellState       = "shell_state"
+	DigitalProductID = "digital_product_id"
+)
+
+func init() {
+	MustRegister(Decoder{
+		Name:        FileTime,
+		Description: "FILETIME timestamp (UTC)",
+		Values:      []string{`HKLM\SYSTEM\CurrentControlSet\Control\Windows\ShutdownTime`},
+		Decode:      decodeFileTime,
+	})
+	MustRegister(Decoder{
+		Name:        SID,
+		Description: "Security identifier",
+		Decode:      decodeSID,
+	})
+	MustRegister(Decoder{
+		Name:        UTF16,
+		Description: "UTF-16LE text",
+		Decode:      decodeUTF16,
+	})
+	MustRegister(Decoder{
+		Name:        ShellState,
+		Description: "Explorer shell flags (REGSHELLSTATE)",
+		Values:      []string{`HKCU\Software\Microsoft\Windows\CurrentVersion\Explorer\ShellState`},
+		Decode:      decodeShellState,
+	})
+	MustRegister(Decoder{
+		Name:        DigitalProductID,
+		Description: "Windows product ID (the product key is not decoded)",
+		Values:      []string{`HKLM\SOFTWARE\Microsoft\Windows NT\CurrentVersion\DigitalProductId`},
+		Decode:      decodeDigitalProductID,
+	})
+}
+
+// fileTimeEpochDelta is the number of 100ns intervals between 1601-01-01,
+// the FILETIME epoch, and the Unix epoch
+const fileTimeEpochDelta = 116444736000000000
+
+// decodeFileTime decodes a little-endian FILETIME, as in ShutdownTime
+func decodeFileTime(data []byte) ([]Field, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: FILETIME needs 8 bytes, got %d", ErrTruncated, len(data))
+	}
+	ticks := int64(binary.LittleEndian.Uint64(data))
+	if ticks == 0 {
+		return []Field{{Name: "Time", Value: "never"}}, nil
+	}
+	t := time.Unix(0, 0).Add(time.Duration(ticks-fileTimeEpochDelta) * 100).UTC()
+	return []Field{{Name: "Time", Value: t.Format(time.RFC3339)}}, nil
+}
+
+// decodeSID decodes a binary security identifier into its S-1-... form
+func decodeSID(data []byte) ([]Field, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: SID needs 8 bytes, got %d", ErrTruncated, len(data))
+	}
+	count := int(data[1])
+	if len(data) < 8+4*count {
+		return nil, fmt.Errorf("%w: SID with %d sub-authorities needs %d bytes, got %d",
+			ErrTruncated, count, 8+4*count, len(data))
+	}
+
+	var authority uint64
+	for _, b := range data[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+
+	var sid strings.Builder
+	fmt.Fprintf(&sid, "S-%d-%d", data[0], authority)
+	for i := 0; i < count; i++ {
+		sid.WriteString("-" + strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data[8+4*i:])), 10))
+	}
+	return []Field{{Name: "SID", Value: sid.String()}}, nil
+}
+
+// decodeUTF16 decodes UTF-16LE text up to the first NUL
+func decodeUTF16(data []byte) ([]Field, error) {
+	return []Field{{Name: "Text", Value: utf16String(data)}}, nil
+}
+
+// shellStateFlags names the SHELLSTATE bit fields, lowest bit first
+var shellStateFlags = []string{
+	"ShowAllObjects",
+	"ShowExtensions",
+	"NoConfirmRecycle",
+	"ShowSysFiles",
+	"ShowCompColor",
+	"DoubleClickInWebView",
+	"DesktopHTML",
+	"Win95Classic",
+	"DontPrettyPath",
+	"ShowAttribCol",
+	"MapNetDrvBtn",
+	"ShowInfoTip",
+	"HideIcons",
+	"WebView",
+	"Filter",
+	"ShowSuperHidden",
+	"NoNetCrawling",
+}
+
+// decodeShellState decodes the flags of Explorer's ShellState value, a
+// REGSHELLSTATE: a DWORD size followed by the SHELLSTATE bit fields
+func decodeShellState(data []byte) ([]Field, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: ShellState needs 8 bytes, got %d", ErrTruncated, len(data))
+	}
+	flags := binary.LittleEndian.Uint32(data[4:])
+	fields := make([]Field, len(shellStateFlags))
+	for i, name := range shellStateFlags {
+		fields[i] = Field{Name: name, Value: strconv.FormatBool(flags&(1<<i) != 0)}
+	}
+	return fields, nil
+}
+
+// decodeDigitalProductID reads the product ID of a DigitalProductId value:
+// ASCII text at offset 8. The encoded product key further in is a secret
+// and is deliberately left alone.
+func decodeDigitalProductID(data []byte) ([]Field, error) {
+	const offset, size = 8, 24
+	if len(data) < offset+size {
+		return nil, fmt.Errorf("%w: DigitalProductId needs %d bytes, got %d", ErrTruncated, offset+size, len(data))
+	}
+	id := data[offset : offset+size]
+	if i := bytes.IndexByte(id, 0); i >= 0 {
+		id = id[:i]
+	}
+	return []Field{
+		{Name: "ProductID", Value: string(id)},
+		{Name: "Length", Value: strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data)), 10)},
+	}, nil
+}
+
+// utf16String decodes little-endian UTF-16 up to the first NUL
+func utf16String(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		u := binary.LittleEndian.Uint16(data[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,168 @@
+// Package decode turns REG_BINARY values into readable fields for reports.
+// Decoders are registered by name; a decoder may also claim well-known
+// values, which are then decoded without the query naming it. Decoders are
+// plain Go and do not touch the registry, so they can be tested anywhere.
+//
+// Only public formats are decoded. Values holding secrets, such as LSA
+// secrets or the product key inside DigitalProductId, are never decoded,
+// so reports and evidence cannot carry them.
+package decode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownDecoder is returned when no decoder has the requested name
+var ErrUnknownDecoder = errors.New("unknown decoder")
+
+// ErrTruncated is returned when data is too short for its format
+var ErrTruncated = errors.New("data too short")
+
+// Field is one named, rendered part of a decoded value
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Value is a binary value decoded into named fields, in the order the
+// format defines them
+type Value struct {
+	Decoder string  `json:"decoder"`
+	Fields  []Field `json:"fields"`
+}
+
+// String renders the fields as "Name: value; Name: value"
+func (v Value) String() string {
+	parts := make([]string, len(v.Fields))
+	for i, f := range v.Fields {
+		parts[i] = f.Name + ": " + f.Value
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Field returns the value of the named field, matched ignoring case
+func (v Value) Field(name string) (string, bool) {
+	for _, f := range v.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Decoder decodes one binary format
+type Decoder struct {
+	Name        string
+	Description string
+
+	// Values are well-known values decoded even when a query does not
+	// name the decoder, written "HKLM\path\value_name"
+	Values []string
+
+	Decode func(data []byte) ([]Field, error)
+}
+
+var (
+	mu        sync.RWMutex
+	decoders  = map[string]Decoder{}
+	wellKnown = map[string]string{} // normalised value location -> decoder name
+)
+
+// Register adds a decoder. Names and well-known values may only be
+// registered once.
+func Register(d Decoder) error {
+	if d.Name == "" || d.Decode == nil {
+		return errors.New("decoder needs a name and a decode function")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := strings.ToLower(d.Name)
+	if _, ok := decoders[name]; ok {
+		return fmt.Errorf("decoder %q is already registered", d.Name)
+	}
+	for _, loc := range d.Values {
+		if owner, ok := wellKnown[normalize(loc)]; ok {
+			return fmt.Errorf("value %s is already decoded by %q", loc, owner)
+		}
+	}
+
+	decoders[name] = d
+	for _, loc := range d.Values {
+		wellKnown[normalize(loc)] = name
+	}
+	return nil
+}
+
+// MustRegister is Register for built-in decoders, which cannot conflict
+func MustRegister(d Decoder) {
+	if err := Register(d); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the decoder with the given name, matched ignoring case
+func Lookup(name string) (Decoder, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	d, ok := decoders[strings.ToLower(name)]
+	return d, ok
+}
+
+// For returns the decoder of a value: the named decoder when name is set,
+// otherwise the decoder claiming the value's location, if any. rootKey is
+// the short form, e.g. "HKLM".
+func For(name, rootKey, path, valueName string) (Decoder, bool, error) {
+	if name != "" {
+		d, ok := Lookup(name)
+		if !ok {
+			return Decoder{}, false, fmt.Errorf("%w: %q", ErrUnknownDecoder, name)
+		}
+		return d, true, nil
+	}
+
+	mu.RLock()
+	owner, ok := wellKnown[normalize(rootKey+`\`+path+`\`+valueName)]
+	mu.RUnlock()
+	if !ok {
+		return Decoder{}, false, nil
+	}
+	d, ok := Lookup(owner)
+	return d, ok, nil
+}
+
+// Names returns the registered decoder names, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(decoders))
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply decodes data with d
+func (d Decoder) Apply(data []byte) (Value, error) {
+	fields, err := d.Decode(data)
+	if err != nil {
+		return Value{}, fmt.Errorf("decoder %s: %w", d.Name, err)
+	}
+	return Value{Decoder: d.Name, Fields: fields}, nil
+}
+
+// normalize makes value locations comparable: case-insensitive, with
+// single backslashes and no surrounding separators
+func normalize(loc string) string {
+	loc = strings.ToLower(strings.ReplaceAll(loc, "/", `\`))
+	for strings.Contains(loc, `\\`) {
+		loc = strings.ReplaceAll(loc, `\\`, `\`)
+	}
+	return strings.Trim(loc, `\`)
+}
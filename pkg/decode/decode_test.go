@@ -0,0 +1,129 @@
+package decode
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestBuiltinDecoders tests decoding each built-in format
+func TestBuiltinDecoders(t *testing.T) {
+	productID := make([]byte, 164)
+	productID[0] = 164
+	copy(productID[8:], "00330-80000-00000-AA123")
+	// Bytes where the product key lives must not surface
+	copy(productID[52:], []byte{0xde, 0xad, 0xbe, 0xef})
+
+	tests := []struct {
+		name    string
+		decoder string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"filetime", FileTime, []byte{0x00, 0x80, 0x3e, 0xd5, 0xde, 0xb1, 0x9d, 0x01}, "Time: 1970-01-01T00:00:00Z", nil},
+		{"filetime zero", FileTime, make([]byte, 8), "Time: never", nil},
+		{"filetime short", FileTime, []byte{1, 2}, "", ErrTruncated},
+		{"sid", SID, []byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0, 32, 2, 0, 0}, "SID: S-1-5-32-544", nil},
+		{"sid short", SID, []byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0}, "", ErrTruncated},
+		{"utf16", UTF16, []byte{'C', 0, ':', 0, '\\', 0, 0, 0, 'x', 0}, `Text: C:\`, nil},
+		{"digital product id", DigitalProductID, productID, "ProductID: 00330-80000-00000-AA123; Length: 164", nil},
+		{"digital product id short", DigitalProductID, productID[:20], "", ErrTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := Lookup(tt.decoder)
+			if !ok {
+				t.Fatalf("Lookup(%q) found nothing", tt.decoder)
+			}
+			got, err := d.Apply(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Apply() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestShellState tests decoding the shell flags bit fields
+func TestShellState(t *testing.T) {
+	d, _ := Lookup(ShellState)
+	// cbSize 0x24, flags: ShowAllObjects | ShowExtensions | ShowSuperHidden
+	got, err := d.Apply([]byte{0x24, 0, 0, 0, 0x03, 0x80, 0, 0})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"ShowAllObjects":   "true",
+		"showextensions":   "true",
+		"NoConfirmRecycle": "false",
+		"ShowSuperHidden":  "true",
+		"NoNetCrawling":    "false",
+	}
+	for name, value := range want {
+		if v, ok := got.Field(name); !ok || v != value {
+			t.Errorf("Field(%q) = %q, %v, want %q", name, v, ok, value)
+		}
+	}
+}
+
+// TestFor tests choosing a decoder by name and by well-known value
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name, decoder, root, path, value string
+		want                             string
+		wantErr                          error
+	}{
+		{"named", "SID", "HKLM", `SOFTWARE\Test`, "Owner", SID, nil},
+		{"well known", "", "HKLM", `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, "DigitalProductId", DigitalProductID, nil},
+		{"well known any case", "", "hklm", `system\currentcontrolset\control\windows\`, "shutdowntime", FileTime, nil},
+		{"not well known", "", "HKLM", `SOFTWARE\Test`, "Blob", "", nil},
+		{"unknown name", "nope", "HKLM", `SOFTWARE\Test`, "Blob", "", ErrUnknownDecoder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok, err := For(tt.decoder, tt.root, tt.path, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("For() error = %v, want %v", err, tt.wantErr)
+			}
+			if ok != (tt.want != "") || d.Name != tt.want {
+				t.Errorf("For() = %q, %v, want %q", d.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestRegister tests that plugins cannot replace decoders or claim taken values
+func TestRegister(t *testing.T) {
+	decode := func([]byte) ([]Field, error) { return nil, nil }
+
+	if err := Register(Decoder{Name: "SID", Decode: decode}); err == nil {
+		t.Error("Register() accepted a duplicate name")
+	}
+	if err := Register(Decoder{
+		Name:   "other_product_id",
+		Values: []string{`HKLM\SOFTWARE\Microsoft\Windows NT\CurrentVersion\DigitalProductId`},
+		Decode: decode,
+	}); err == nil {
+		t.Error("Register() accepted a value claimed by another decoder")
+	}
+	if err := Register(Decoder{Name: "no_func"}); err == nil {
+		t.Error("Register() accepted a decoder without a decode function")
+	}
+	if err := Register(Decoder{Name: "test_plugin", Decode: decode}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, ok := Lookup("TEST_PLUGIN"); !ok {
+		t.Error("Lookup() did not find the registered plugin")
+	}
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Prefixes of deny list rules that are patterns. A rule without one is a
+// registry path, denied with its subkeys.
+const (
+	// DenyRuleGlob prefixes a glob: * matches within one key name, ** across
+	// any number of keys and ? one character. Like a path, a glob also
+	// denies the subkeys of the keys it matches.
+	DenyRuleGlob = "glob:"
+
+	// DenyRuleRegex prefixes a regular expression (RE2 syntax) that must
+	// match the whole path. It denies only the paths it matches; end it with
+	// (\\.*)? to deny subkeys too.
+	DenyRuleRegex = "regex:"
+)
+
+// DenyRule is a compiled deny list rule. Matching ignores case, as registry
+// key names do.
+type DenyRule struct {
+	Rule    string         // The rule as written in the deny list
+	path    string         // Lowercase path of a plain rule
+	pattern *regexp.Regexp // Compiled glob or regex rule
+}
+
+// compiledDenyRules caches compiled rules by their text, as deny lists are
+// checked for every query and every key of a tree read
+var compiledDenyRules sync.Map
+
+// CompileDenyRule compiles a deny list rule: a registry path, or a pattern
+// prefixed with DenyRuleGlob or DenyRuleRegex
+func CompileDenyRule(rule string) (*DenyRule, error) {
+	if cached, ok := compiledDenyRules.Load(rule); ok {
+		return cached.(*DenyRule), nil
+	}
+
+	compiled := &DenyRule{Rule: rule}
+	trimmed := strings.TrimSpace(rule)
+	lower := strings.ToLower(trimmed)
+	var expr string
+	switch {
+	case strings.HasPrefix(lower, DenyRuleGlob):
+		glob := strings.TrimSpace(trimmed[len(DenyRuleGlob):])
+		if glob == "" {
+			return nil, fmt.Errorf("deny rule %q has an empty glob", rule)
+		}
+		expr = "^" + globToRegexp(glob) + `(?:\\.*)?$`
+	case strings.HasPrefix(lower, DenyRuleRegex):
+		re := strings.TrimSpace(trimmed[len(DenyRuleRegex):])
+		if re == "" {
+			return nil, fmt.Errorf("deny rule %q has an empty regex", rule)
+		}
+		expr = "^(?:" + re + ")$"
+	default:
+		if trimmed == "" {
+			return nil, fmt.Errorf("deny rule is empty")
+		}
+		compiled.path = lower
+	}
+
+	if expr != "" {
+		pattern, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("deny rule %q is not a valid pattern: %w", rule, err)
+		}
+		compiled.pattern = pattern
+	}
+
+	compiledDenyRules.Store(rule, compiled)
+	return compiled, nil
+}
+
+// globToRegexp translates a deny rule glob into a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(`.*`)
+			i++
+		case glob[i] == '*':
+			b.WriteString(`[^\\]*`)
+		case glob[i] == '?':
+			b.WriteString(`[^\\]`)
+		default:
+			// Copy the literal text up to the next wildcard
+			end := strings.IndexAny(glob[i:], "*?")
+			if end < 0 {
+				end = len(glob) - i
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+end]))
+			i += end - 1
+		}
+	}
+	return b.String()
+}
+
+// IsPattern reports whether the rule is a glob or regex rather than a path
+func (r *DenyRule) IsPattern() bool {
+	return r.pattern != nil
+}
+
+// Match reports whether the rule denies path. parent is set when a plain
+// rule denies path as a subkey of the rule's path.
+func (r *DenyRule) Match(path string) (matched, parent bool) {
+	path = strings.TrimSpace(path)
+	if r.pattern != nil {
+		return r.pattern.MatchString(path), false
+	}
+	path = strings.ToLower(path)
+	if path == r.path {
+		return true, false
+	}
+	if strings.HasPrefix(path, r.path+"\\") {
+		return true, true
+	}
+	return false, false
+}
+
+// ValidateDenyList compiles every rule of a deny list, so configuration
+// with a malformed pattern is refused when it is loaded
+func ValidateDenyList(denyList []string) error {
+	for i, rule := range denyList {
+		if _, err := CompileDenyRule(rule); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDenyRuleMatch tests matching paths against path, glob and regex rules
+func TestDenyRuleMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		path   string
+		want   bool
+		parent bool
+	}{
+		{"path", `SECURITY\Policy\Secrets`, `security\policy\secrets`, true, false},
+		{"path subkey", `SECURITY\Policy\Secrets`, `SECURITY\Policy\Secrets\DefaultPassword`, true, true},
+		{"path sibling", `SECURITY\Policy\Secrets`, `SECURITY\Policy\SecretsX`, false, false},
+		{"glob star", `glob:SOFTWARE\*\Secrets`, `SOFTWARE\Contoso\Secrets`, true, false},
+		{"glob subkey", `glob:SOFTWARE\*\Secrets`, `software\contoso\secrets\Api`, true, false},
+		{"glob star is one key", `glob:SOFTWARE\*\Secrets`, `SOFTWARE\Contoso\Apps\Secrets`, false, false},
+		{"glob double star", `glob:SOFTWARE\**\Secrets`, `SOFTWARE\Contoso\Apps\Secrets`, true, false},
+		{"glob question mark", `glob:SYSTEM\ControlSet00?\Services`, `SYSTEM\ControlSet002\Services\Tcpip`, true, false},
+		{"glob dot is literal", `glob:SOFTWARE\Test.Key`, `SOFTWARE\TestXKey`, false, false},
+		{"regex", `regex:SYSTEM\\ControlSet\d{3}\\Control\\Lsa`, `system\controlset001\control\lsa`, true, false},
+		{"regex is anchored", `regex:SYSTEM\\ControlSet\d{3}\\Control\\Lsa`, `SYSTEM\ControlSet001\Control\Lsa\Kerberos`, false, false},
+		{"regex with subkeys", `regex:SYSTEM\\ControlSet\d{3}\\Control\\Lsa(\\.*)?`, `SYSTEM\ControlSet001\Control\Lsa\Kerberos`, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := CompileDenyRule(tt.rule)
+			if err != nil {
+				t.Fatalf("CompileDenyRule(%q) error = %v", tt.rule, err)
+			}
+			matched, parent := rule.Match(tt.path)
+			if matched != tt.want || parent != tt.parent {
+				t.Errorf("Match(%q) = %v, %v; want %v, %v", tt.path, matched, parent, tt.want, tt.parent)
+			}
+		})
+	}
+}
+
+// TestValidateDenyList tests that malformed rules are refused when loaded,
+// and deny every path if they are checked anyway
+func TestValidateDenyList(t *testing.T) {
+	valid := []string{`SECURITY\Policy\Secrets`, `glob:SOFTWARE\*\Secrets`, `regex:SAM\\.*`}
+	if err := ValidateDenyList(valid); err != nil {
+		t.Errorf("ValidateDenyList() error = %v", err)
+	}
+
+	for _, rule := range []string{"", "glob:", "regex: ", "regex:(Secrets"} {
+		if err := ValidateDenyList(append(valid, rule)); err == nil {
+			t.Errorf("ValidateDenyList() accepted %q", rule)
+		}
+	}
+
+	err := ValidateAgainstDenyList(`SOFTWARE\Microsoft`, []string{"regex:(Secrets"})
+	if err == nil || !strings.Contains(err.Error(), "not a valid pattern") {
+		t.Errorf("ValidateAgainstDenyList() with a malformed rule = %v, want the path denied", err)
+	}
+	if err := ValidateAgainstDenyList(`SOFTWARE\Contoso\Secrets`, valid); err == nil || !strings.Contains(err.Error(), `glob:SOFTWARE\\*\\Secrets`) {
+		t.Errorf("ValidateAgainstDenyList() = %v, want the glob rule named", err)
+	}
+}
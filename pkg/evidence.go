@@ -6,17 +6,22 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg/fileio"
 )
 
-// EvidenceLogger creates comprehensive audit logs for compliance evidence
+// EvidenceLogger creates comprehensive audit logs for compliance evidence.
+// Its methods are safe to call from concurrent goroutines.
 type EvidenceLogger struct {
 	LogPath   string
 	StartTime time.Time
 	Evidence  *ComplianceEvidence
 	logger    *slog.Logger // Added for dependency injection
+	mu        sync.Mutex   // Guards Evidence
 }
 
 // ComplianceEvidence contains all audit trail information
@@ -24,6 +29,7 @@ type ComplianceEvidence struct {
 	ScanMetadata  ScanMetadata              `json:"scan_metadata"`
 	MachineInfo   MachineInfo               `json:"machine_information"`
 	ScanResults   map[string]ScanResult     `json:"scan_results"`
+	Remediations  []RemediationRecord       `json:"remediations,omitempty"`
 	Summary       ScanSummary               `json:"summary"`
 }
 
@@ -36,6 +42,8 @@ type ScanMetadata struct {
 	Duration      string    `json:"duration"`
 	Operator      string    `json:"operator"`
 	ReportType    string    `json:"report_type"`
+	Category      string    `json:"category,omitempty"`   // Report metadata category
+	Compliance    string    `json:"compliance,omitempty"` // Report metadata compliance framework
 }
 
 // MachineInfo contains system identification
@@ -67,6 +75,21 @@ type ScanResult struct {
 	ComplianceNote  string      `json:"compliance_note,omitempty"`
 }
 
+// RemediationRecord documents a registry change made, or planned in a dry
+// run, by a remediation
+type RemediationRecord struct {
+	CheckName     string    `json:"check_name"`
+	RegistryPath  string    `json:"registry_path"`
+	ValueName     string    `json:"value_name"`
+	PreviousValue string    `json:"previous_value"`
+	NewValue      string    `json:"new_value"`
+	ValueType     string    `json:"value_type"`
+	Status        string    `json:"status"` // APPLIED, PLANNED, COMPLIANT, FAILED
+	SnapshotPath  string    `json:"snapshot_path,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	ErrorMessage  string    `json:"error_message,omitempty"`
+}
+
 // ScanSummary provides scan statistics
 type ScanSummary struct {
 	TotalChecks    int       `json:"total_checks"`
@@ -111,6 +134,15 @@ func NewEvidenceLogger(logDir, reportType string, logger *slog.Logger) (*Evidenc
 	}, nil
 }
 
+// SetReportMetadata records the category and compliance framework of the
+// report in the scan metadata, so evidence can be queried by them
+func (e *EvidenceLogger) SetReportMetadata(metadata ReportMetadata) {
+	e.mu.Lock()
+	e.Evidence.ScanMetadata.Category = metadata.Category
+	e.Evidence.ScanMetadata.Compliance = metadata.Compliance
+	e.mu.Unlock()
+}
+
 // GatherMachineInfo collects system information for evidence
 func (e *EvidenceLogger) GatherMachineInfo(reader RegistryService) error {
 	ctx := context.Background()
@@ -164,7 +196,9 @@ func (e *EvidenceLogger) GatherMachineInfo(reader RegistryService) error {
 		`SYSTEM\CurrentControlSet\Control\Session Manager\Environment`,
 		"PROCESSOR_ARCHITECTURE")
 
+	e.mu.Lock()
 	e.Evidence.MachineInfo = info
+	e.mu.Unlock()
 	return nil
 }
 
@@ -193,11 +227,51 @@ func (e *EvidenceLogger) LogResult(checkName, description, regPath, valueName st
 		result.Status = "PASS"
 	}
 
+	e.mu.Lock()
 	e.Evidence.ScanResults[checkName] = result
+	e.mu.Unlock()
+}
+
+// LogRemediation records the outcome of remediating a check. result may be
+// nil when the remediation failed before the current value was captured.
+func (e *EvidenceLogger) LogRemediation(query RegistryQuery, result *RemediationResult, snapshotPath string, err error) {
+	record := RemediationRecord{
+		CheckName:    query.Name,
+		RegistryPath: fmt.Sprintf("%s\\%s", query.RootKey, query.Path),
+		ValueName:    query.ValueName,
+		SnapshotPath: snapshotPath,
+		Timestamp:    time.Now(),
+	}
+
+	if result != nil {
+		record.PreviousValue = result.Previous.Display()
+		record.NewValue = result.Target.Display()
+		record.ValueType = result.Target.Type
+	}
+
+	switch {
+	case err != nil:
+		record.Status = "FAILED"
+		record.ErrorMessage = err.Error()
+	case result.Compliant:
+		record.Status = "COMPLIANT"
+		record.NewValue = record.PreviousValue
+	case result.DryRun:
+		record.Status = "PLANNED"
+	default:
+		record.Status = "APPLIED"
+	}
+
+	e.mu.Lock()
+	e.Evidence.Remediations = append(e.Evidence.Remediations, record)
+	e.mu.Unlock()
 }
 
 // Finalize completes the evidence log and writes to file
 func (e *EvidenceLogger) Finalize() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	endTime := time.Now()
 	duration := endTime.Sub(e.StartTime)
 
@@ -232,8 +306,8 @@ func (e *EvidenceLogger) Finalize() error {
 
 	e.Evidence.Summary = summary
 
-	// Write to file
-	file, err := os.Create(e.LogPath)
+	// Write to a temporary file that replaces the log only when complete
+	file, err := fileio.Create(e.LogPath, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create evidence log: %w", err)
 	}
@@ -246,7 +320,7 @@ func (e *EvidenceLogger) Finalize() error {
 		return fmt.Errorf("failed to write evidence log: %w", err)
 	}
 
-	return nil
+	return file.Commit()
 }
 
 // GetLogPath returns the log file path
@@ -256,6 +330,9 @@ func (e *EvidenceLogger) GetLogPath() string {
 
 // GetSummaryText returns a human-readable summary
 func (e *EvidenceLogger) GetSummaryText() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	s := e.Evidence.Summary
 	return fmt.Sprintf(`
 COMPLIANCE SCAN SUMMARY
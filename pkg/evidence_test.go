@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestEvidenceLogger_ConcurrentLogResult(t *testing.T) {
+	logger, err := NewEvidenceLogger(t.TempDir(), "concurrent", slog.Default())
+	if err != nil {
+		t.Fatalf("NewEvidenceLogger() error = %v", err)
+	}
+
+	const producers = 8
+	const perProducer = 50
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				var checkErr error
+				if i%2 == 1 {
+					checkErr = errors.New("access denied")
+				}
+				logger.LogResult(fmt.Sprintf("check_%d_%d", p, i), "desc", "SOFTWARE\\Test", "Value", i, checkErr)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if err := logger.Finalize(); err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+
+	data, err := os.ReadFile(logger.GetLogPath())
+	if err != nil {
+		t.Fatalf("failed to read evidence log: %v", err)
+	}
+	var evidence ComplianceEvidence
+	if err := json.Unmarshal(data, &evidence); err != nil {
+		t.Fatalf("evidence log is not valid JSON: %v", err)
+	}
+
+	want := producers * perProducer
+	if evidence.Summary.TotalChecks != want || len(evidence.ScanResults) != want {
+		t.Errorf("TotalChecks = %d, results = %d, want %d", evidence.Summary.TotalChecks, len(evidence.ScanResults), want)
+	}
+	if evidence.Summary.Passed != want/2 || evidence.Summary.Errors != want/2 {
+		t.Errorf("Passed = %d, Errors = %d, want %d each", evidence.Summary.Passed, evidence.Summary.Errors, want/2)
+	}
+}
@@ -0,0 +1,443 @@
+// Package evidencequery filters and projects the check results of evidence
+// logs, so results can be pulled out of many scans at once without
+// scripting:
+//
+//	ComplianceToolkit.exe query-evidence -where "status=fail AND category~security" -select hostname,check,actual evidence
+//
+// Every check result of a log becomes a row of the fields listed in Fields,
+// taken from the result and the scan it belongs to.
+package evidencequery
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields are the fields of a row, in their default output order
+var Fields = []string{
+	"file", "scan_id", "report_type", "category", "compliance", "hostname", "operator", "start_time",
+	"check", "description", "registry_path", "value_name", "expected", "actual", "status",
+	"timestamp", "error", "note",
+}
+
+// Row is one check result of an evidence log, by field
+type Row map[string]string
+
+// evidenceLog is the part of an evidence log (pkg.ComplianceEvidence) rows
+// are built from
+type evidenceLog struct {
+	ScanMetadata struct {
+		ScanID     string `json:"scan_id"`
+		StartTime  string `json:"start_time"`
+		Operator   string `json:"operator"`
+		ReportType string `json:"report_type"`
+		Category   string `json:"category"`
+		Compliance string `json:"compliance"`
+	} `json:"scan_metadata"`
+	MachineInfo struct {
+		Hostname string `json:"hostname"`
+	} `json:"machine_information"`
+	ScanResults map[string]struct {
+		CheckName      string          `json:"check_name"`
+		Description    string          `json:"description"`
+		RegistryPath   string          `json:"registry_path"`
+		ValueName      string          `json:"value_name"`
+		ExpectedValue  string          `json:"expected_value"`
+		ActualValue    json.RawMessage `json:"actual_value"`
+		Status         string          `json:"status"`
+		Timestamp      string          `json:"timestamp"`
+		ErrorMessage   string          `json:"error_message"`
+		ComplianceNote string          `json:"compliance_note"`
+	} `json:"scan_results"`
+}
+
+// Load reads the check results of an evidence log, ordered by check name
+func Load(path string) ([]Row, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var log evidenceLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("%s is not an evidence log: %w", path, err)
+	}
+	if log.ScanResults == nil {
+		return nil, fmt.Errorf("%s is not an evidence log: no scan_results", path)
+	}
+
+	meta := log.ScanMetadata
+	rows := make([]Row, 0, len(log.ScanResults))
+	for name, result := range log.ScanResults {
+		check := result.CheckName
+		if check == "" {
+			check = name
+		}
+		rows = append(rows, Row{
+			"file":          filepath.Base(path),
+			"scan_id":       meta.ScanID,
+			"report_type":   meta.ReportType,
+			"category":      meta.Category,
+			"compliance":    meta.Compliance,
+			"hostname":      log.MachineInfo.Hostname,
+			"operator":      meta.Operator,
+			"start_time":    meta.StartTime,
+			"check":         check,
+			"description":   result.Description,
+			"registry_path": result.RegistryPath,
+			"value_name":    result.ValueName,
+			"expected":      result.ExpectedValue,
+			"actual":        formatValue(result.ActualValue),
+			"status":        result.Status,
+			"timestamp":     result.Timestamp,
+			"error":         result.ErrorMessage,
+			"note":          result.ComplianceNote,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["check"] < rows[j]["check"] })
+	return rows, nil
+}
+
+// formatValue renders an actual value as text: strings and numbers as they
+// are, other values as JSON, and null as the empty string
+func formatValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// Files expands the arguments of a query into evidence log paths: a
+// directory stands for the .json files in it and other arguments may be
+// glob patterns, as the Windows shell does not expand them. The paths are
+// sorted and duplicates removed.
+func Files(args []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				add(match)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no evidence logs match %s", arg)
+		}
+		for _, match := range matches {
+			add(match)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// ParseFields parses a comma-separated list of fields. An empty list
+// selects every field.
+func ParseFields(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Fields, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q (fields: %s)", field, strings.Join(Fields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// isField reports whether name is one of Fields
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the fields of rows as csv (with a header row) or json (an
+// array of objects with the fields in the given order)
+func Write(w io.Writer, rows []Row, fields []string, format string) error {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+		record := make([]string, len(fields))
+		for _, row := range rows {
+			for i, field := range fields {
+				record[i] = row[field]
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "json":
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, row := range rows {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n  {")
+			for j, field := range fields {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				key, _ := json.Marshal(field)
+				value, _ := json.Marshal(row[field])
+				buf.Write(key)
+				buf.WriteString(": ")
+				buf.Write(value)
+			}
+			buf.WriteString("}")
+		}
+		if len(rows) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("]\n")
+		_, err := w.Write(buf.Bytes())
+		return err
+
+	default:
+		return fmt.Errorf("unsupported format %q (use csv or json)", format)
+	}
+}
+
+// condition compares one field of a row with a value
+type condition struct {
+	field, op, value string
+}
+
+// operators are the comparison operators, longest first so that != is not
+// read as !
+var operators = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+// Filter selects rows by a where expression. The zero Filter matches every
+// row.
+type Filter struct {
+	// any holds the OR-ed groups of AND-ed conditions
+	any [][]condition
+}
+
+// Parse parses a where expression: conditions joined by AND and OR, where
+// AND binds tighter. A condition is field, operator and value:
+//
+//	=, !=   equal, not equal (case-insensitive)
+//	~, !~   contains, does not contain (case-insensitive)
+//	<, <=, >, >=   numbers by value, other text (such as timestamps) in order
+//
+// Values containing spaces or keywords are quoted with ' or ".
+func Parse(expr string) (Filter, error) {
+	var filter Filter
+	terms, joins, err := splitTerms(expr)
+	if err != nil {
+		return filter, err
+	}
+	if len(terms) == 0 {
+		return filter, nil
+	}
+
+	group := []condition{}
+	for i, term := range terms {
+		cond, err := parseCondition(term)
+		if err != nil {
+			return Filter{}, err
+		}
+		group = append(group, cond)
+		if i == len(joins) || joins[i] == "OR" {
+			filter.any = append(filter.any, group)
+			group = []condition{}
+		}
+	}
+	return filter, nil
+}
+
+// splitTerms splits an expression at the AND and OR keywords outside
+// quotes, returning the conditions and the keywords between them
+func splitTerms(expr string) (terms, joins []string, err error) {
+	var term strings.Builder
+	var quote rune
+	words := []rune(expr)
+	for i := 0; i < len(words); i++ {
+		r := words[i]
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+			term.WriteRune(r)
+			continue
+		}
+		if r == '\'' || r == '"' {
+			quote = r
+			term.WriteRune(r)
+			continue
+		}
+		// A keyword is a whole word: preceded and followed by a space
+		if r == ' ' || r == '\t' {
+			if keyword := keywordAt(words[i+1:]); keyword != "" {
+				terms = append(terms, strings.TrimSpace(term.String()))
+				joins = append(joins, keyword)
+				term.Reset()
+				i += len(keyword)
+				continue
+			}
+		}
+		term.WriteRune(r)
+	}
+	if quote != 0 {
+		return nil, nil, errors.New("unterminated quote in where expression")
+	}
+
+	last := strings.TrimSpace(term.String())
+	if last == "" && len(terms) == 0 {
+		return nil, nil, nil
+	}
+	terms = append(terms, last)
+	for _, t := range terms {
+		if t == "" {
+			return nil, nil, errors.New("AND and OR must join two conditions")
+		}
+	}
+	return terms, joins, nil
+}
+
+// keywordAt returns AND or OR when rest starts with that word followed by
+// a space
+func keywordAt(rest []rune) string {
+	for _, keyword := range []string{"AND", "OR"} {
+		n := len(keyword)
+		if len(rest) > n && strings.EqualFold(string(rest[:n]), keyword) && (rest[n] == ' ' || rest[n] == '\t') {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// parseCondition parses field, operator and value
+func parseCondition(term string) (condition, error) {
+	end := strings.IndexFunc(term, func(r rune) bool {
+		return !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'))
+	})
+	if end < 0 {
+		end = len(term)
+	}
+	if end == 0 {
+		return condition{}, fmt.Errorf("invalid condition %q: expected field, operator and value", term)
+	}
+	field := strings.ToLower(term[:end])
+	if !isField(field) {
+		return condition{}, fmt.Errorf("unknown field %q (fields: %s)", field, strings.Join(Fields, ", "))
+	}
+
+	rest := strings.TrimSpace(term[end:])
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			value := strings.TrimSpace(rest[len(op):])
+			if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			}
+			return condition{field: field, op: op, value: value}, nil
+		}
+	}
+	return condition{}, fmt.Errorf("invalid condition %q: expected one of %s after %s", term, strings.Join(operators, " "), field)
+}
+
+// Match reports whether row satisfies the filter
+func (f Filter) Match(row Row) bool {
+	if len(f.any) == 0 {
+		return true
+	}
+	for _, group := range f.any {
+		matched := true
+		for _, cond := range group {
+			if !cond.match(row[cond.field]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// match compares a field's value with the condition's
+func (c condition) match(value string) bool {
+	switch c.op {
+	case "=":
+		return strings.EqualFold(value, c.value)
+	case "!=":
+		return !strings.EqualFold(value, c.value)
+	case "~":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(c.value))
+	case "!~":
+		return !strings.Contains(strings.ToLower(value), strings.ToLower(c.value))
+	}
+
+	var cmp int
+	a, aErr := strconv.ParseFloat(value, 64)
+	b, bErr := strconv.ParseFloat(c.value, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+	case value == "":
+		// Missing values are never ordered
+		return false
+	default:
+		cmp = strings.Compare(value, c.value)
+	}
+
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
@@ -0,0 +1,178 @@
+package evidencequery
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLog = `{
+  "scan_metadata": {"scan_id": "SCAN_20261014_020000", "start_time": "2026-10-14T02:00:00Z",
+    "operator": "svc-scan", "report_type": "NIST_800_171_compliance", "category": "Security & Compliance"},
+  "machine_information": {"hostname": "WS-0042"},
+  "scan_results": {
+    "UAC": {"check_name": "UAC", "registry_path": "SOFTWARE\\Policies\\System", "value_name": "EnableLUA",
+      "expected_value": "1", "actual_value": 0, "status": "FAIL", "timestamp": "2026-10-14T02:00:01Z"},
+    "Firewall": {"check_name": "Firewall", "actual_value": "1", "status": "PASS", "timestamp": "2026-10-14T02:00:02Z"},
+    "Audit Policy": {"check_name": "Audit Policy", "actual_value": null, "status": "NOT_FOUND",
+      "error_message": "Registry key or value does not exist"},
+    "Installed": {"check_name": "Installed", "actual_value": {"7-Zip": "23.01"}, "status": "PASS"}
+  }
+}`
+
+// writeLog writes an evidence log to dir
+func writeLog(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoad tests reading rows from an evidence log
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	rows, err := Load(writeLog(t, dir, "nist_evidence.json", testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checks []string
+	for _, row := range rows {
+		checks = append(checks, row["check"])
+	}
+	if want := []string{"Audit Policy", "Firewall", "Installed", "UAC"}; !reflect.DeepEqual(checks, want) {
+		t.Errorf("checks = %v, want %v", checks, want)
+	}
+
+	uac := rows[3]
+	want := map[string]string{
+		"file": "nist_evidence.json", "hostname": "WS-0042", "category": "Security & Compliance",
+		"actual": "0", "expected": "1", "status": "FAIL", "value_name": "EnableLUA",
+	}
+	for field, value := range want {
+		if uac[field] != value {
+			t.Errorf("%s = %q, want %q", field, uac[field], value)
+		}
+	}
+	if rows[0]["actual"] != "" || rows[2]["actual"] != `{"7-Zip": "23.01"}` {
+		t.Errorf("actual values = %q, %q", rows[0]["actual"], rows[2]["actual"])
+	}
+
+	if _, err := Load(writeLog(t, dir, "config.json", `{"queries": []}`)); err == nil {
+		t.Error("Load() accepted a file without scan results")
+	}
+}
+
+// TestFiles tests expanding directories and patterns
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeLog(t, dir, "a_evidence.json", testLog)
+	b := writeLog(t, dir, "b_evidence.json", testLog)
+	writeLog(t, dir, "notes.txt", "")
+
+	files, err := Files([]string{dir, filepath.Join(dir, "b_*.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Files() = %v, want %v", files, want)
+	}
+
+	if _, err := Files([]string{filepath.Join(dir, "missing_*.json")}); err == nil {
+		t.Error("Files() accepted a pattern matching nothing")
+	}
+}
+
+// TestParse tests where expressions against a row
+func TestParse(t *testing.T) {
+	row := Row{"status": "FAIL", "category": "Security & Compliance", "check": "Password Age",
+		"actual": "90", "timestamp": "2026-10-14T02:00:01Z", "error": ""}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"status=fail", true},
+		{"status = 'FAIL'", true},
+		{"status!=fail", false},
+		{"status=fail AND category~security", true},
+		{"status=pass AND category~security", false},
+		{"status=pass OR check='password age'", true},
+		{"status=pass and check~age or status=error", false},
+		{`check="Password Age" and actual>=90`, true},
+		{"actual > 100", false},
+		{"actual < 100", true},
+		{"timestamp >= 2026-10-01", true},
+		{"error > a", false},
+		{"check !~ 'and'", true},
+		{`check ~ "word AND word"`, false},
+	}
+	for _, tt := range tests {
+		filter, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := filter.Match(row); got != tt.want {
+			t.Errorf("Parse(%q).Match() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestParseErrors tests that malformed expressions are refused
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"severity=high":              "unknown field",
+		"status":                     "expected one of",
+		"=fail":                      "invalid condition",
+		"status=fail OR  OR check=x": "join two conditions",
+		"check='open":                "unterminated quote",
+	}
+	for expr, wantErr := range tests {
+		if _, err := Parse(expr); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Parse(%q) error = %v, want one containing %q", expr, err, wantErr)
+		}
+	}
+}
+
+// TestWrite tests the csv and json output of selected fields
+func TestWrite(t *testing.T) {
+	rows := []Row{{"hostname": "WS-0042", "check": "UAC", "actual": `say "hi", ok`}}
+	fields, err := ParseFields("hostname, CHECK,actual")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, rows, fields, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "hostname,check,actual\nWS-0042,UAC,\"say \"\"hi\"\", ok\"\n"; buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, rows, fields, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[\n  {\"hostname\": \"WS-0042\", \"check\": \"UAC\", \"actual\": \"say \\\"hi\\\", ok\"}\n]\n"; buf.String() != want {
+		t.Errorf("json =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, nil, fields, "json"); err != nil || buf.String() != "[]\n" {
+		t.Errorf("empty json = %q, %v", buf.String(), err)
+	}
+	if err := Write(&buf, rows, fields, "xml"); err == nil {
+		t.Error("Write() accepted xml")
+	}
+	if _, err := ParseFields("hostname,severity"); err == nil {
+		t.Error("ParseFields() accepted an unknown field")
+	}
+}
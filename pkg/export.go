@@ -0,0 +1,284 @@
+package pkg
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"compliancetoolkit/pkg/decode"
+	"compliancetoolkit/pkg/fileio"
+)
+
+// Spreadsheet formats query results can be exported to alongside HTML
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// Result statuses used in exports, matching the evidence log
+const (
+	StatusPass     = "PASS"
+	StatusFail     = "FAIL"
+	StatusNotFound = "NOT_FOUND"
+	StatusError    = "ERROR"
+)
+
+// exportHeader is the column order of every export
+var exportHeader = []string{"Name", "Description", "Root Key", "Path", "Value Name", "Expected", "Actual", "Status", "Message"}
+
+// ExportRow is one query result in a spreadsheet export
+type ExportRow struct {
+	Name        string
+	Description string
+	RootKey     string
+	Path        string
+	ValueName   string
+	Expected    string
+	Actual      string
+	Status      string
+	Message     string
+}
+
+// fields returns the row's cells in exportHeader order
+func (r ExportRow) fields() []string {
+	return []string{r.Name, r.Description, r.RootKey, r.Path, r.ValueName, r.Expected, r.Actual, r.Status, r.Message}
+}
+
+// ValidateExportFormats checks that every format is csv or xlsx
+func ValidateExportFormats(formats []string) error {
+	for _, format := range formats {
+		switch strings.ToLower(format) {
+		case ExportFormatCSV, ExportFormatXLSX:
+		default:
+			return fmt.Errorf("unsupported export format %q (must be csv or xlsx)", format)
+		}
+	}
+	return nil
+}
+
+// ExportResults writes rows in each format to basePath plus the format's
+// extension and returns the files written
+func ExportResults(basePath, sheetName string, formats []string, rows []ExportRow) ([]string, error) {
+	if err := ValidateExportFormats(formats); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, format := range formats {
+		format = strings.ToLower(format)
+		path := basePath + "." + format
+
+		file, err := fileio.Create(path, 0644)
+		if err != nil {
+			return paths, fmt.Errorf("failed to create %s export: %w", format, err)
+		}
+
+		if format == ExportFormatXLSX {
+			err = WriteXLSX(file, sheetName, rows)
+		} else {
+			err = WriteCSV(file, rows)
+		}
+		if err == nil {
+			err = file.Commit()
+		}
+		file.Close()
+		if err != nil {
+			return paths, fmt.Errorf("failed to write %s export: %w", format, err)
+		}
+
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// WriteCSV writes rows as CSV with a header row
+func WriteCSV(w io.Writer, rows []ExportRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		fields := row.fields()
+		for i, field := range fields {
+			fields[i] = neutralizeFormula(field)
+		}
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// neutralizeFormula prefixes values a spreadsheet would evaluate as a formula.
+// Registry values come from the scanned machine and must not run as formulas
+// when the export is opened.
+func neutralizeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteXLSX writes rows as a single-sheet Office Open XML workbook with a
+// bold, filterable header row. Every cell is an inline string, so values are
+// never interpreted as numbers or formulas.
+func WriteXLSX(w io.Writer, sheetName string, rows []ExportRow) error {
+	zw := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(xlsxSheetName(sheetName)))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(rows)},
+	}
+
+	for _, part := range parts {
+		pw, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// xlsxSheet renders the worksheet XML for rows
+func xlsxSheet(rows []ExportRow) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<cols><col min="1" max="2" width="40" customWidth="1"/><col min="3" max="3" width="10" customWidth="1"/><col min="4" max="4" width="60" customWidth="1"/><col min="5" max="7" width="25" customWidth="1"/><col min="8" max="8" width="12" customWidth="1"/><col min="9" max="9" width="50" customWidth="1"/></cols>`)
+	b.WriteString(`<sheetData>`)
+
+	writeRow := func(index int, cells []string, style int) {
+		fmt.Fprintf(&b, `<row r="%d">`, index)
+		for col, cell := range cells {
+			fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`,
+				xlsxColumn(col), index, style, xmlEscape(cell))
+		}
+		b.WriteString(`</row>`)
+	}
+
+	writeRow(1, exportHeader, 1)
+	for i, row := range rows {
+		writeRow(i+2, row.fields(), 0)
+	}
+
+	b.WriteString(`</sheetData>`)
+	fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, xlsxColumn(len(exportHeader)-1), len(rows)+1)
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn returns the spreadsheet letter of a zero-based column index
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xlsxSheetName makes s a valid worksheet name: at most 31 characters and
+// none of []:*?/\
+func xlsxSheetName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	if runes := []rune(s); len(runes) > 31 {
+		s = string(runes[:31])
+	}
+	if s == "" {
+		s = "Results"
+	}
+	return s
+}
+
+// xmlEscape escapes s for XML text, replacing characters XML cannot carry
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// exportValue renders a registry value for a spreadsheet cell. Values read
+// with read_all are listed one "name = value" per line, sorted by name, and
+// the items of a multi-string are joined as ReadValue joins them.
+func exportValue(v interface{}) string {
+	if items, ok := v.([]string); ok {
+		return strings.Join(items, ", ")
+	}
+	if decoded, ok := v.(decode.Value); ok {
+		return decoded.String()
+	}
+	values, ok := v.(map[string]interface{})
+	if !ok {
+		if v == nil {
+			return ""
+		}
+		return formatValue(v)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s = %v", name, values[name]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines style 0 (default) and style 1 (bold header), both
+// wrapping text so multi-line values stay readable
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment vertical="top" wrapText="1"/></xf>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportTestRows() []ExportRow {
+	return []ExportRow{
+		{Name: "uac", Description: "UAC enabled", RootKey: "HKLM", Path: `SOFTWARE\Policies\System`, ValueName: "EnableLUA", Expected: "1", Actual: "1", Status: StatusPass},
+		{Name: "banner", Description: "Logon banner", RootKey: "HKLM", Path: `SOFTWARE\Winlogon`, ValueName: "Caption", Expected: "Authorized use only", Actual: "=HYPERLINK(\"http://x\")", Status: StatusFail, Message: "Expected 'Authorized use only' & more"},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, exportTestRows()); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header and 2 rows", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(exportHeader, ",") {
+		t.Errorf("header = %v, want %v", records[0], exportHeader)
+	}
+	if records[1][3] != `SOFTWARE\Policies\System` || records[1][7] != StatusPass {
+		t.Errorf("row 1 = %v", records[1])
+	}
+	// Values that start a formula must not be evaluated by spreadsheets
+	if got := records[2][6]; got != `'=HYPERLINK("http://x")` {
+		t.Errorf("formula cell = %q, want it prefixed with a quote", got)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, "NIST 800-171: Results/2025", exportTestRows()); err != nil {
+		t.Fatalf("WriteXLSX() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `name="NIST 800-171_ Results_2025"`) {
+		t.Errorf("sheet name not sanitized: %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet, `<autoFilter ref="A1:I3"/>`) {
+		t.Error("sheet has no autofilter over the header and both rows")
+	}
+	if !strings.Contains(sheet, "&#39; &amp; more") {
+		t.Error("message was not XML-escaped")
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[int]string{0: "A", 8: "I", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, want := range tests {
+		if got := xlsxColumn(index); got != want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
+
+func TestExportResults(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "report_20250101_120000")
+
+	paths, err := ExportResults(base, "Report", []string{"CSV", "xlsx"}, exportTestRows())
+	if err != nil {
+		t.Fatalf("ExportResults() error = %v", err)
+	}
+	if len(paths) != 2 || paths[0] != base+".csv" || paths[1] != base+".xlsx" {
+		t.Fatalf("paths = %v", paths)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("export %s missing: %v", path, err)
+		}
+	}
+
+	if _, err := ExportResults(base, "Report", []string{"pdf"}, nil); err == nil {
+		t.Error("ExportResults() accepted an unsupported format")
+	}
+}
@@ -0,0 +1,133 @@
+// Package fileio writes files atomically: data goes to a temporary file in
+// the destination directory, is flushed to disk, and is renamed over the
+// destination only once complete. A crash or a full disk leaves either the
+// previous file or no file, never a truncated one.
+package fileio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MinFreeSpace is the space, in bytes, that must remain free on a volume
+// after a write. Writers refuse to start rather than fill the disk.
+const MinFreeSpace = 10 << 20
+
+// ErrInsufficientSpace is returned when a volume does not have room for a write
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// errUnsupported is returned by freeSpace on platforms that cannot report it
+var errUnsupported = errors.New("free space not available on this platform")
+
+// File is a file being written atomically. Nothing is visible at the
+// destination until Commit succeeds; Close without Commit discards the
+// temporary file, so `defer f.Close()` is always safe.
+type File struct {
+	*os.File
+	path      string
+	perm      os.FileMode
+	committed bool
+	closed    bool
+}
+
+// Create starts an atomic write to path, creating its directory if needed.
+// The temporary file lives beside path so the final rename never crosses
+// volumes.
+func Create(path string, perm os.FileMode) (*File, error) {
+	return create(path, perm, 0)
+}
+
+// create starts an atomic write to path once the volume has room for size
+// bytes
+func create(path string, perm os.FileMode, size uint64) (*File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := EnsureFreeSpace(dir, size); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	return &File{File: tmp, path: path, perm: perm}, nil
+}
+
+// Commit flushes the temporary file to disk and renames it over the
+// destination
+func (f *File) Commit() error {
+	if f.closed {
+		return fmt.Errorf("commit of %s after close", f.path)
+	}
+
+	tmpName := f.File.Name()
+	err := f.File.Sync()
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	f.closed = true
+	if err == nil {
+		err = os.Chmod(tmpName, f.perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, f.path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+
+	f.committed = true
+	syncDir(filepath.Dir(f.path))
+	return nil
+}
+
+// Close discards the temporary file unless Commit succeeded
+func (f *File) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
+
+// WriteFile writes data to path atomically, checking first that the volume
+// has room for it
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := create(path, perm, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Commit()
+}
+
+// EnsureFreeSpace returns ErrInsufficientSpace when writing size bytes to dir
+// would leave less than MinFreeSpace free. Platforms that cannot report free
+// space are not checked.
+func EnsureFreeSpace(dir string, size uint64) error {
+	free, err := freeSpace(dir)
+	if errors.Is(err, errUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check free space on %s: %w", dir, err)
+	}
+
+	if free < size+MinFreeSpace {
+		return fmt.Errorf("%w on %s: %d bytes free, %d needed", ErrInsufficientSpace, dir, free, size+MinFreeSpace)
+	}
+	return nil
+}
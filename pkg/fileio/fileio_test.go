@@ -0,0 +1,107 @@
+package fileio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteFile tests writing and replacing a file atomically
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "report.json")
+
+	if err := WriteFile(path, []byte("first"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatalf("WriteFile() replace error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+// TestCreateWithoutCommit tests that an abandoned write leaves the previous file
+func TestCreateWithoutCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.json")
+	if err := WriteFile(path, []byte("complete"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := f.WriteString("trunc"); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+	f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "complete" {
+		t.Errorf("content = %q, want the previous file", data)
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+
+	if err := f.Commit(); err == nil {
+		t.Error("Commit() after Close succeeded")
+	}
+}
+
+// TestCommitThenClose tests that the usual defer Close after Commit is harmless
+func TestCommitThenClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	f.WriteString("<html></html>")
+	if err := f.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() after Commit error = %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("committed file missing: %v", err)
+	}
+}
+
+// TestEnsureFreeSpace tests refusing writes larger than the volume
+func TestEnsureFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := EnsureFreeSpace(dir, 0); err != nil {
+		t.Skipf("temp volume is nearly full or unsupported: %v", err)
+	}
+
+	if _, err := freeSpace(dir); errors.Is(err, errUnsupported) {
+		t.Skip("free space not available on this platform")
+	}
+	if err := EnsureFreeSpace(dir, 1<<62); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("EnsureFreeSpace() error = %v, want ErrInsufficientSpace", err)
+	}
+}
+
+// assertNoTempFiles fails if any temporary files were left in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
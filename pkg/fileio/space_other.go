@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package fileio
+
+// freeSpace is not implemented on this platform
+func freeSpace(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
+
+// syncDir is a no-op on this platform
+func syncDir(dir string) {}
@@ -0,0 +1,27 @@
+//go:build linux || darwin
+
+package fileio
+
+import (
+	"os"
+	"syscall"
+)
+
+// freeSpace returns the bytes available to unprivileged users on the volume
+// holding dir
+func freeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// syncDir flushes a directory entry so a completed rename survives a crash.
+// Failures are ignored; the file itself is already on disk.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package fileio
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the bytes available to the current user on the volume
+// holding dir
+func freeSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
+
+// syncDir is a no-op on Windows, which cannot open directories for flushing
+func syncDir(dir string) {}
@@ -0,0 +1,150 @@
+// Package fingerprint derives a stable machine identifier that survives
+// hostname changes and reimaging. Clients send it with their system
+// information and the server uses it to recognize a machine that registers
+// under a new client ID.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix marks the fingerprint scheme so a later change of inputs yields
+// fingerprints that can never collide with earlier ones
+const Prefix = "v1:"
+
+// placeholderSerials are serial numbers firmware vendors ship unfilled,
+// shared by every board of a model
+var placeholderSerials = map[string]bool{
+	"":                       true,
+	"0":                      true,
+	"00000000":               true,
+	"0123456789":             true,
+	"default string":         true,
+	"none":                   true,
+	"not applicable":         true,
+	"not specified":          true,
+	"system serial number":   true,
+	"to be filled by o.e.m.": true,
+}
+
+// Components are the machine properties a fingerprint is derived from
+type Components struct {
+	MachineGUID  string // HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid; changes on reinstall
+	Manufacturer string // SMBIOS system manufacturer
+	BIOSSerial   string // SMBIOS system serial number; survives reinstalls
+	MacAddress   string // Primary network adapter
+}
+
+// UsableSerial reports whether serial identifies one machine rather than
+// being an unfilled vendor placeholder
+func UsableSerial(serial string) bool {
+	s := strings.ToLower(strings.TrimSpace(serial))
+	return !placeholderSerials[s] && strings.Trim(s, "0") != ""
+}
+
+// Compute returns the fingerprint of a machine, or "" if the components do
+// not identify it. A usable BIOS serial (with the manufacturer, since serials
+// are only unique per vendor) is preferred because the firmware keeps it
+// across reinstalls. Without one the machine GUID and MAC address are used
+// together: the GUID alone repeats on machines cloned from an image that was
+// not generalized, and the MAC alone changes with docking stations and
+// adapter order.
+func Compute(c Components) string {
+	var input string
+	switch {
+	case UsableSerial(c.BIOSSerial):
+		input = "serial=" + normalize(c.Manufacturer) + "/" + normalize(c.BIOSSerial)
+	case c.MachineGUID != "" && c.MacAddress != "":
+		input = "guid=" + normalize(c.MachineGUID) + "|mac=" + normalizeMAC(c.MacAddress)
+	default:
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(Prefix + input))
+	return Prefix + hex.EncodeToString(sum[:])
+}
+
+// normalize trims and lower-cases a component so formatting differences
+// between readers do not change the fingerprint
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// normalizeMAC strips separators from a MAC address
+func normalizeMAC(mac string) string {
+	return strings.NewReplacer(":", "", "-", "", ".", "").Replace(normalize(mac))
+}
+
+// SMBIOS structure types and offsets of the System Information (type 1)
+// fields used here. See the DMTF SMBIOS specification, section 7.2.
+const (
+	smbiosTypeSystem      = 1
+	smbiosTypeEnd         = 127
+	smbiosManufacturerIdx = 0x04
+	smbiosSerialIdx       = 0x07
+	rawSMBIOSHeaderLen    = 8 // RawSMBIOSData header in the mssmbios registry value
+)
+
+// ParseSMBIOS returns the system manufacturer and serial number from the
+// raw SMBIOS table Windows stores in the SMBiosData value of
+// HKLM\SYSTEM\CurrentControlSet\Services\mssmbios\Data. It returns empty
+// strings if the table has no System Information structure.
+func ParseSMBIOS(data []byte) (manufacturer, serial string) {
+	if len(data) < rawSMBIOSHeaderLen {
+		return "", ""
+	}
+	table := data[rawSMBIOSHeaderLen:]
+
+	for offset := 0; offset+4 <= len(table); {
+		typ, length := table[offset], int(table[offset+1])
+		if length < 4 || offset+length > len(table) {
+			return "", ""
+		}
+		formatted := table[offset : offset+length]
+		strs, next := smbiosStrings(table, offset+length)
+
+		if typ == smbiosTypeSystem && length > smbiosSerialIdx {
+			return smbiosString(strs, formatted[smbiosManufacturerIdx]), smbiosString(strs, formatted[smbiosSerialIdx])
+		}
+		if typ == smbiosTypeEnd {
+			break
+		}
+		offset = next
+	}
+	return "", ""
+}
+
+// smbiosStrings returns the strings following a structure's formatted area
+// at start and the offset of the next structure. The string set ends with
+// two NUL bytes.
+func smbiosStrings(table []byte, start int) ([]string, int) {
+	var strs []string
+	i := start
+	for i < len(table) {
+		end := i
+		for end < len(table) && table[end] != 0 {
+			end++
+		}
+		if end == i {
+			// Empty string: end of the set. A structure without strings
+			// is followed by two NULs, the first of which is consumed here.
+			if len(strs) == 0 && end+1 < len(table) && table[end+1] == 0 {
+				return strs, end + 2
+			}
+			return strs, end + 1
+		}
+		strs = append(strs, string(table[i:end]))
+		i = end + 1
+	}
+	return strs, len(table)
+}
+
+// smbiosString resolves a 1-based string index; 0 means no string
+func smbiosString(strs []string, index byte) string {
+	if index == 0 || int(index) > len(strs) {
+		return ""
+	}
+	return strings.TrimSpace(strs[index-1])
+}
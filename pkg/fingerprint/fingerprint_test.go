@@ -0,0 +1,118 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+)
+
+// smbiosStructure builds a raw SMBIOS structure with a formatted area of
+// the given bytes (type, length and handle are filled in) and strings
+func smbiosStructure(typ byte, formatted []byte, strs ...string) []byte {
+	b := []byte{typ, byte(4 + len(formatted)), 0x00, 0x01}
+	b = append(b, formatted...)
+	if len(strs) == 0 {
+		return append(b, 0, 0)
+	}
+	for _, s := range strs {
+		b = append(append(b, s...), 0)
+	}
+	return append(b, 0)
+}
+
+// rawSMBIOS prefixes structures with the RawSMBIOSData header
+func rawSMBIOS(structures ...[]byte) []byte {
+	data := []byte{0, 3, 4, 0, 0, 0, 0, 0}
+	for _, s := range structures {
+		data = append(data, s...)
+	}
+	return data
+}
+
+// TestParseSMBIOS tests reading the system manufacturer and serial number
+func TestParseSMBIOS(t *testing.T) {
+	bios := smbiosStructure(0, []byte{1, 2, 0, 0}, "American Megatrends", "1.2.3")
+	noStrings := smbiosStructure(32, []byte{0, 0, 0, 0, 0, 0, 0})
+	// Type 1 formatted area: manufacturer=1, product=2, version=0, serial=3
+	system := smbiosStructure(1, []byte{1, 2, 0, 3}, "Dell Inc.", "Latitude 7440", " 5CG1234XYZ ")
+	end := smbiosStructure(127, nil)
+
+	tests := []struct {
+		name             string
+		data             []byte
+		wantManufacturer string
+		wantSerial       string
+	}{
+		{"system after other structures", rawSMBIOS(bios, noStrings, system, end), "Dell Inc.", "5CG1234XYZ"},
+		{"system first", rawSMBIOS(system, end), "Dell Inc.", "5CG1234XYZ"},
+		{"no serial string", rawSMBIOS(smbiosStructure(1, []byte{1, 0, 0, 0}, "Dell Inc."), end), "Dell Inc.", ""},
+		{"no system structure", rawSMBIOS(bios, end), "", ""},
+		{"truncated", rawSMBIOS(system)[:14], "", ""},
+		{"header only", rawSMBIOS(), "", ""},
+		{"empty", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manufacturer, serial := ParseSMBIOS(tt.data)
+			if manufacturer != tt.wantManufacturer || serial != tt.wantSerial {
+				t.Errorf("ParseSMBIOS() = %q, %q, want %q, %q", manufacturer, serial, tt.wantManufacturer, tt.wantSerial)
+			}
+		})
+	}
+}
+
+// TestCompute tests which components identify a machine
+func TestCompute(t *testing.T) {
+	laptop := Components{
+		MachineGUID:  "6f1c2b9e-1111-2222-3333-444455556666",
+		Manufacturer: "Dell Inc.",
+		BIOSSerial:   "5CG1234XYZ",
+		MacAddress:   "00:1a:2b:3c:4d:5e",
+	}
+	reimaged := laptop
+	reimaged.MachineGUID = "0a0a0a0a-7777-8888-9999-000011112222"
+	reimaged.MacAddress = "aa:bb:cc:dd:ee:ff" // Docked
+
+	noSerial := laptop
+	noSerial.BIOSSerial = "To Be Filled By O.E.M."
+	macFormatted := noSerial
+	macFormatted.MacAddress = "00-1A-2B-3C-4D-5E"
+	cloned := noSerial
+	cloned.MacAddress = "00:1a:2b:3c:4d:ff"
+
+	if got := Compute(laptop); !strings.HasPrefix(got, Prefix) || len(got) != len(Prefix)+64 {
+		t.Fatalf("Compute() = %q, want %s followed by a SHA-256 digest", got, Prefix)
+	}
+
+	tests := []struct {
+		name string
+		a, b Components
+		same bool
+	}{
+		{"reimaged keeps serial", laptop, reimaged, true},
+		{"serial case and spacing", laptop, Components{Manufacturer: "DELL INC.", BIOSSerial: " 5cg1234xyz"}, true},
+		{"other vendor same serial", laptop, Components{Manufacturer: "HP", BIOSSerial: "5CG1234XYZ"}, false},
+		{"placeholder serial uses guid and mac", noSerial, macFormatted, true},
+		{"clone with another mac", noSerial, cloned, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Compute(tt.a), Compute(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("Compute() = %q and %q, want same = %v", a, b, tt.same)
+			}
+		})
+	}
+
+	for _, c := range []Components{
+		{},
+		{BIOSSerial: "0000000000"},
+		{MachineGUID: laptop.MachineGUID},
+		{MacAddress: laptop.MacAddress, BIOSSerial: "Default string"},
+	} {
+		if got := Compute(c); got != "" {
+			t.Errorf("Compute(%+v) = %q, want no fingerprint", c, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
@@ -11,15 +12,21 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg/decode"
+	"compliancetoolkit/pkg/fileio"
 )
 
 //go:embed templates/html templates/css
 var templateFS embed.FS
 
-// HTMLReport generates HTML reports from registry scan results using templates
+// HTMLReport generates HTML reports from registry scan results using templates.
+// AddResult, AddResultWithDetails, SetMetadata and Generate are safe to call
+// from concurrent goroutines.
 type HTMLReport struct {
 	Title          string
 	Timestamp      time.Time
@@ -29,6 +36,7 @@ type HTMLReport struct {
 	tmpl           *template.Template
 	registryReader RegistryService // Changed from *RegistryReader to interface
 	logger         *slog.Logger    // Added for dependency injection
+	mu             sync.Mutex      // Guards Results, Metadata and tmpl
 }
 
 // ReportResult represents a single query result
@@ -41,6 +49,7 @@ type ReportResult struct {
 	Path          string
 	ValueName     string
 	ExpectedValue string
+	Status        string // StatusPass, StatusFail, StatusNotFound or StatusError
 }
 
 // NewHTMLReport creates a new HTML report with dependency injection
@@ -66,13 +75,16 @@ func (r *HTMLReport) AddResult(name, description string, value interface{}, err
 		Description: description,
 		Value:       value,
 		Success:     err == nil,
+		Status:      resultStatus(err),
 	}
 
 	if err != nil {
 		result.Error = err.Error()
 	}
 
+	r.mu.Lock()
 	r.Results[name] = result
+	r.mu.Unlock()
 }
 
 // AddResultWithDetails adds a result with full query details for compliance reporting
@@ -85,17 +97,146 @@ func (r *HTMLReport) AddResultWithDetails(name, description, rootKey, path, valu
 		Path:          path,
 		ValueName:     valueName,
 		ExpectedValue: expectedValue,
+		Status:        resultStatus(err),
 	}
 
 	if err != nil {
 		result.Error = err.Error()
 	}
 
+	r.mu.Lock()
 	r.Results[name] = result
+	r.mu.Unlock()
+}
+
+// AddQueryResult adds the result of reading a query's value, recording
+// whether the value meets the query's expected value. value is the string
+// ReadValue returns or the *RegistryValue ReadTypedValue returns, whose
+// multi-string items are listed and compared as a list.
+func (r *HTMLReport) AddQueryResult(query RegistryQuery, value interface{}, err error) {
+	typed, isTyped := value.(*RegistryValue)
+	if isTyped && err == nil {
+		value = typed.ReportValue()
+	}
+	r.AddResultWithDetails(query.Name, query.Description, query.RootKey, query.Path, query.ValueName,
+		query.ExpectedDescription(), value, err)
+
+	if err != nil || query.ExpectedValue == "" {
+		return
+	}
+
+	var matches bool
+	var cmpErr error
+	if isTyped {
+		matches, cmpErr = query.MatchesValue(typed)
+	} else if s, ok := value.(string); ok {
+		matches, cmpErr = query.Matches(s)
+	} else {
+		return
+	}
+
+	status := StatusPass
+	if cmpErr != nil {
+		status = StatusError
+	} else if !matches {
+		status = StatusFail
+	}
+
+	r.mu.Lock()
+	result := r.Results[query.Name]
+	result.Status = status
+	r.Results[query.Name] = result
+	r.mu.Unlock()
+}
+
+// AddTreeResult adds the result of a read_tree or read_users query. With an expected
+// value, every value of the tree must meet it; a tree without values fails,
+// and a truncated tree is an error as it was not fully checked.
+func (r *HTMLReport) AddTreeResult(query RegistryQuery, tree *RegistryTree, err error) {
+	var value interface{}
+	if tree != nil {
+		value = tree.String()
+	}
+	r.AddResultWithDetails(query.Name, query.Description, query.RootKey, query.Path, query.ValueName,
+		query.ExpectedDescription(), value, err)
+
+	if err != nil || query.ExpectedValue == "" {
+		return
+	}
+
+	status := StatusPass
+	message := ""
+	if mismatches, cmpErr := query.MatchesTree(tree); errors.Is(cmpErr, ErrEmptyTree) {
+		status = StatusFail
+		message = cmpErr.Error()
+	} else if cmpErr != nil {
+		status = StatusError
+		message = cmpErr.Error()
+	} else if len(mismatches) > 0 {
+		status = StatusFail
+		message = fmt.Sprintf("%d of %d values do not match", len(mismatches), tree.Compared())
+	} else if tree.Truncated {
+		status = StatusError
+		message = fmt.Sprintf("tree truncated after %d keys; raise max_keys", len(tree.Keys))
+	}
+
+	r.mu.Lock()
+	result := r.Results[query.Name]
+	result.Status = status
+	result.Error = message
+	r.Results[query.Name] = result
+	r.mu.Unlock()
+}
+
+// resultStatus classifies a read error the way the evidence log does
+func resultStatus(err error) string {
+	switch {
+	case err == nil:
+		return StatusPass
+	case IsNotExist(err):
+		return StatusNotFound
+	default:
+		return StatusError
+	}
+}
+
+// ExportRows returns the report's results as spreadsheet rows, sorted by name
+func (r *HTMLReport) ExportRows() []ExportRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := make([]ExportRow, 0, len(r.Results))
+	for name, result := range r.Results {
+		rows = append(rows, ExportRow{
+			Name:        name,
+			Description: result.Description,
+			RootKey:     result.RootKey,
+			Path:        result.Path,
+			ValueName:   result.ValueName,
+			Expected:    result.ExpectedValue,
+			Actual:      exportValue(result.Value),
+			Status:      result.Status,
+			Message:     result.Error,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// Export writes the results as CSV and/or XLSX next to the HTML report and
+// returns the files written
+func (r *HTMLReport) Export(formats []string) ([]string, error) {
+	basePath := strings.TrimSuffix(r.OutputPath, filepath.Ext(r.OutputPath))
+	return ExportResults(basePath, r.Title, formats, r.ExportRows())
 }
 
 // Generate creates the HTML file using the template system
 func (r *HTMLReport) Generate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// Parse templates
 	if err := r.loadTemplates(); err != nil {
 		return fmt.Errorf("failed to load templates: %w", err)
@@ -104,14 +245,8 @@ func (r *HTMLReport) Generate() error {
 	// Build report data
 	data := r.buildReportData()
 
-	// Ensure directory exists
-	dir := filepath.Dir(r.OutputPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	// Create output file
-	file, err := os.Create(r.OutputPath)
+	// Write to a temporary file that replaces the report only when complete
+	file, err := fileio.Create(r.OutputPath, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create HTML file: %w", err)
 	}
@@ -122,7 +257,7 @@ func (r *HTMLReport) Generate() error {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return nil
+	return file.Commit()
 }
 
 // loadTemplates loads and parses all HTML and CSS templates
@@ -169,12 +304,15 @@ func (r *HTMLReport) loadTemplates() error {
 
 // buildReportData constructs the data structure for template rendering
 func (r *HTMLReport) buildReportData() *ReportData {
-	// Get machine name
-	machineName, _ := os.Hostname()
-
 	// Gather system information for evidence panel
 	systemInfo := r.gatherSystemInfo()
 
+	// Name the scanned machine, which is not this host for remote scans
+	machineName := systemInfo.Hostname
+	if machineName == "" || machineName == "N/A" {
+		machineName, _ = os.Hostname()
+	}
+
 	// Convert Results map to QueryResult slice
 	queryResults := make([]QueryResult, 0, len(r.Results))
 	for name, result := range r.Results {
@@ -228,6 +366,8 @@ func (r *HTMLReport) buildReportData() *ReportData {
 
 // SetMetadata sets the report metadata
 func (r *HTMLReport) SetMetadata(metadata ReportMetadata) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.Metadata = metadata
 }
 
@@ -412,6 +552,12 @@ func formatValue(v interface{}) string {
 		return strings.TrimSpace(result)
 	case []string:
 		return strings.Join(val, "\n")
+	case decode.Value:
+		lines := make([]string, len(val.Fields))
+		for i, f := range val.Fields {
+			lines[i] = f.Name + ": " + f.Value
+		}
+		return strings.Join(lines, "\n")
 	case string:
 		return val
 	default:
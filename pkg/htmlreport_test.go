@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestHTMLReport_ConcurrentAddResult(t *testing.T) {
+	report := NewHTMLReport("Concurrent", t.TempDir(), slog.Default(), nil)
+
+	const producers = 8
+	const perProducer = 50
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				name := fmt.Sprintf("check_%d_%d", p, i)
+				if i%2 == 0 {
+					report.AddResult(name, "desc", i, nil)
+				} else {
+					report.AddResultWithDetails(name, "desc", "HKLM", "SOFTWARE\\Test", "Value", "1", i, nil)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if got, want := len(report.Results), producers*perProducer; got != want {
+		t.Errorf("len(Results) = %d, want %d", got, want)
+	}
+}
+
+func TestHTMLReport_AddQueryResultList(t *testing.T) {
+	report := NewHTMLReport("Lists", t.TempDir(), slog.Default(), nil)
+
+	protocols := &RegistryValue{Type: RegTypeMultiString, Strings: []string{"TLS1.2", "TLS1.3"}}
+	report.AddQueryResult(RegistryQuery{Name: "contains", ExpectedValue: "tls1.3", ExpectedOperator: "contains"}, protocols, nil)
+	report.AddQueryResult(RegistryQuery{Name: "subset", ExpectedValue: "TLS1.3", ExpectedOperator: "subset"}, protocols, nil)
+	report.AddQueryResult(RegistryQuery{Name: "equals", ExpectedValue: "[TLS1.3, TLS1.2]"}, protocols, nil)
+
+	want := map[string]string{"contains": StatusPass, "subset": StatusFail, "equals": StatusPass}
+	for _, row := range report.ExportRows() {
+		if row.Status != want[row.Name] {
+			t.Errorf("%s status = %q, want %q", row.Name, row.Status, want[row.Name])
+		}
+		if row.Actual != "TLS1.2, TLS1.3" {
+			t.Errorf("%s actual = %q, want the items joined", row.Name, row.Actual)
+		}
+	}
+	for _, result := range report.QueryResults() {
+		if len(result.ActualList) != 2 {
+			t.Errorf("%s ActualList = %q, want the multi-string's items", result.Name, result.ActualList)
+		}
+	}
+}
+
+func TestRegistryQueryMatchesValueExpand(t *testing.T) {
+	t.Setenv("CT_TEST_ROOT", `C:\Windows`)
+	value := &RegistryValue{Type: RegTypeExpandString, String: `%CT_TEST_ROOT%\System32`}
+	query := RegistryQuery{ExpectedValue: `C:\Windows\System32`}
+
+	if matches, err := query.MatchesValue(value); err != nil || matches {
+		t.Errorf("MatchesValue() without expand_env = %v, %v; want the raw value compared", matches, err)
+	}
+	query.ExpandEnv = true
+	if matches, err := query.MatchesValue(value); err != nil || !matches {
+		t.Errorf("MatchesValue() with expand_env = %v, %v; want a match", matches, err)
+	}
+	if expanded, _ := value.Expand(); expanded.Display() != `C:\Windows\System32` || value.String != `%CT_TEST_ROOT%\System32` {
+		t.Errorf("Expand() = %q, original %q", expanded.Display(), value.String)
+	}
+}
+
+func TestRegistryQueryDecodeValue(t *testing.T) {
+	// 1970-01-01T00:00:00Z as a FILETIME
+	shutdown := &RegistryValue{Type: RegTypeBinary, Binary: []byte{0x00, 0x80, 0x3e, 0xd5, 0xde, 0xb1, 0x9d, 0x01}}
+	wellKnown := RegistryQuery{Name: "shutdown", RootKey: "HKEY_LOCAL_MACHINE",
+		Path: `SYSTEM\CurrentControlSet\Control\Windows`, ValueName: "ShutdownTime"}
+
+	decoded, err := wellKnown.DecodeValue(shutdown)
+	if err != nil {
+		t.Fatalf("DecodeValue() error = %v", err)
+	}
+	if decoded.Display() != "Time: 1970-01-01T00:00:00Z" {
+		t.Errorf("Display() = %q, want the well-known value decoded", decoded.Display())
+	}
+
+	field := RegistryQuery{Name: "field", RootKey: "HKLM", Path: `SOFTWARE\Test`, ValueName: "Stamp",
+		Decoder: "filetime", DecodedField: "time", ExpectedValue: "1970-01-01T00:00:00Z"}
+	if decoded, err := field.DecodeValue(shutdown); err != nil || decoded.Display() != "1970-01-01T00:00:00Z" {
+		t.Errorf("DecodeValue() with decoded_field = %v, %v", decoded, err)
+	}
+
+	plain := RegistryQuery{Name: "plain", RootKey: "HKLM", Path: `SOFTWARE\Test`, ValueName: "Blob"}
+	if decoded, err := plain.DecodeValue(shutdown); err != nil || decoded.Display() != "00803ed5deb19d01" {
+		t.Errorf("DecodeValue() without a decoder = %v, %v; want hex", decoded, err)
+	}
+
+	wrongType := RegistryQuery{Name: "sz", RootKey: "HKLM", Path: `SOFTWARE\Test`, ValueName: "Name", Decoder: "sid"}
+	if _, err := wrongType.DecodeValue(&RegistryValue{Type: RegTypeString, String: "x"}); err == nil {
+		t.Error("DecodeValue() accepted a decoder for a REG_SZ value")
+	}
+
+	report := NewHTMLReport("Decoded", t.TempDir(), slog.Default(), nil)
+	report.AddQueryResult(wellKnown, decoded, nil)
+	report.AddQueryResult(field, mustDecode(t, field, shutdown), nil)
+	for _, row := range report.ExportRows() {
+		if row.Name == "shutdown" && row.Actual != "Time: 1970-01-01T00:00:00Z" {
+			t.Errorf("shutdown actual = %q, want the decoded fields", row.Actual)
+		}
+		if row.Name == "field" && row.Status != StatusPass {
+			t.Errorf("field status = %q, want the decoded field compared", row.Status)
+		}
+	}
+}
+
+func mustDecode(t *testing.T, query RegistryQuery, value *RegistryValue) *RegistryValue {
+	t.Helper()
+	decoded, err := query.DecodeValue(value)
+	if err != nil {
+		t.Fatalf("DecodeValue() error = %v", err)
+	}
+	return decoded
+}
@@ -23,8 +23,14 @@ type RegistryService interface {
 	// ReadValue reads any registry value and returns it as a string (auto-detects type)
 	ReadValue(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error)
 
+	// ReadTypedValue reads any registry value with its type
+	ReadTypedValue(ctx context.Context, rootKey registry.Key, path, valueName string) (*RegistryValue, error)
+
 	// BatchRead reads multiple values from the same registry key efficiently
 	BatchRead(ctx context.Context, rootKey registry.Key, path string, values []string) (map[string]interface{}, error)
+
+	// ReadTree reads the values of a key and its subkeys within opts' limits
+	ReadTree(ctx context.Context, rootKey registry.Key, path string, opts TreeOptions) (*RegistryTree, error)
 }
 
 // ReportService defines operations for generating compliance reports
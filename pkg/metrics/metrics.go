@@ -0,0 +1,284 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// exposes them in the Prometheus text format (version 0.0.4), so the server
+// and client can be scraped without a Prometheus client dependency.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are histogram upper bounds in seconds suited to request
+// and query latencies, as Prometheus client libraries use
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metric families and writes them sorted by name
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	onScrape []func()
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is a metric and its series, one per combination of label values
+type family struct {
+	name       string
+	help       string
+	kind       string // "counter", "gauge" or "histogram"
+	labelNames []string
+	buckets    []float64 // Histograms only
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the state of one combination of label values
+type series struct {
+	labelValues []string
+	value       float64  // Counters and gauges
+	counts      []uint64 // Histograms: observations per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// register adds a family; names must be unique
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	f.series = make(map[string]*series)
+	r.families[f.name] = f
+	return f
+}
+
+// OnScrape registers f to run before every write, to set gauges that are
+// read from elsewhere (a database count, a directory size)
+func (r *Registry) OnScrape(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onScrape = append(r.onScrape, f)
+}
+
+// with returns the series of the label values, creating it. It panics when
+// the number of values does not match the family's labels.
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up
+type Counter struct{ f *family }
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, kind: "counter", labelNames: labelNames})}
+}
+
+// Inc adds 1 to the series of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series of the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot decrease", c.f.name))
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.with(labelValues).value += v
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct{ f *family }
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, kind: "gauge", labelNames: labelNames})}
+}
+
+// Set sets the series of the label values to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.with(labelValues).value = v
+}
+
+// Add adds v, which may be negative, to the series of the label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.with(labelValues).value += v
+}
+
+// Histogram counts observations in buckets
+type Histogram struct{ f *family }
+
+// Histogram registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s buckets are not sorted", name))
+	}
+	return &Histogram{r.register(&family{name: name, help: help, kind: "histogram", labelNames: labelNames, buckets: buckets})}
+}
+
+// Observe records v in the series of the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.with(labelValues)
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// WriteTo writes every family in the text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	hooks := append([]func(){}, r.onScrape...)
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// write writes a family's help, type and samples
+func (f *family) write(w *countingWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, labels(f.labelNames, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labels(f.labelNames, s.labelValues, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labels(f.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labels(f.labelNames, s.labelValues, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, labels(f.labelNames, s.labelValues, "", ""), s.count)
+	}
+}
+
+// labels renders a label set, with an extra label (a histogram's le) when
+// extraName is set; empty when there are no labels
+func labels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel escapes a label value: backslash, double quote and newline
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// escapeHelp escapes help text: backslash and newline
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
+
+// formatValue renders a sample value as Prometheus parses it
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts bytes written and keeps the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
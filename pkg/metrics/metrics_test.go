@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestWriteTo tests the text exposition of each metric type
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("app_requests_total", "Requests served.", "route", "code")
+	inFlight := r.Gauge("app_in_flight", "Requests in flight.")
+	latency := r.Histogram("app_latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+
+	requests.Inc("GET /a", "2xx")
+	requests.Add(2, "GET /a", "2xx")
+	requests.Inc(`GET /b "quoted"`, "5xx")
+	inFlight.Add(3)
+	inFlight.Add(-1)
+	latency.Observe(0.05, "GET /a")
+	latency.Observe(0.1, "GET /a")
+	latency.Observe(3, "GET /a")
+
+	scrapes := 0
+	r.OnScrape(func() { scrapes++ })
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := `# HELP app_in_flight Requests in flight.
+# TYPE app_in_flight gauge
+app_in_flight 2
+# HELP app_latency_seconds Request latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{route="GET /a",le="0.1"} 2
+app_latency_seconds_bucket{route="GET /a",le="1"} 2
+app_latency_seconds_bucket{route="GET /a",le="+Inf"} 3
+app_latency_seconds_sum{route="GET /a"} 3.15
+app_latency_seconds_count{route="GET /a"} 3
+# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{route="GET /a",code="2xx"} 3
+app_requests_total{route="GET /b \"quoted\"",code="5xx"} 1
+`
+	if b.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", b.String(), want)
+	}
+	if scrapes != 1 {
+		t.Errorf("OnScrape hook ran %d times, want 1", scrapes)
+	}
+}
+
+// TestHandler tests that the handler serves the text format
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("app_starts_total", "Starts.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentType)
+	}
+	if !strings.Contains(rec.Body.String(), "app_starts_total 1\n") {
+		t.Errorf("body = %q, want app_starts_total 1", rec.Body.String())
+	}
+}
+
+// TestMisuse tests that programming errors panic rather than produce
+// output Prometheus rejects
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) { r.Counter("x_total", ""); r.Gauge("x_total", "") }},
+		{"wrong label count", func(r *Registry) { r.Counter("x_total", "", "a").Inc() }},
+		{"negative counter", func(r *Registry) { r.Counter("x_total", "").Add(-1) }},
+		{"unsorted buckets", func(r *Registry) { r.Histogram("x_seconds", "", []float64{1, 0.5}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			tt.f(NewRegistry())
+		})
+	}
+}
@@ -4,16 +4,20 @@ import (
 	"context"
 
 	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg"
 )
 
 // MockRegistryService is a mock implementation of RegistryService for testing
 type MockRegistryService struct {
-	ReadStringFunc  func(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error)
-	ReadIntegerFunc func(ctx context.Context, rootKey registry.Key, path, valueName string) (uint64, error)
-	ReadBinaryFunc  func(ctx context.Context, rootKey registry.Key, path, valueName string) ([]byte, error)
-	ReadStringsFunc func(ctx context.Context, rootKey registry.Key, path, valueName string) ([]string, error)
-	ReadValueFunc   func(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error)
-	BatchReadFunc   func(ctx context.Context, rootKey registry.Key, path string, values []string) (map[string]interface{}, error)
+	ReadStringFunc     func(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error)
+	ReadIntegerFunc    func(ctx context.Context, rootKey registry.Key, path, valueName string) (uint64, error)
+	ReadBinaryFunc     func(ctx context.Context, rootKey registry.Key, path, valueName string) ([]byte, error)
+	ReadStringsFunc    func(ctx context.Context, rootKey registry.Key, path, valueName string) ([]string, error)
+	ReadValueFunc      func(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error)
+	ReadTypedValueFunc func(ctx context.Context, rootKey registry.Key, path, valueName string) (*pkg.RegistryValue, error)
+	BatchReadFunc      func(ctx context.Context, rootKey registry.Key, path string, values []string) (map[string]interface{}, error)
+	ReadTreeFunc       func(ctx context.Context, rootKey registry.Key, path string, opts pkg.TreeOptions) (*pkg.RegistryTree, error)
 }
 
 // ReadString mocks the ReadString method
@@ -56,6 +60,14 @@ func (m *MockRegistryService) ReadValue(ctx context.Context, rootKey registry.Ke
 	return "", nil
 }
 
+// ReadTypedValue mocks the ReadTypedValue method
+func (m *MockRegistryService) ReadTypedValue(ctx context.Context, rootKey registry.Key, path, valueName string) (*pkg.RegistryValue, error) {
+	if m.ReadTypedValueFunc != nil {
+		return m.ReadTypedValueFunc(ctx, rootKey, path, valueName)
+	}
+	return &pkg.RegistryValue{Type: pkg.RegTypeString}, nil
+}
+
 // BatchRead mocks the BatchRead method
 func (m *MockRegistryService) BatchRead(ctx context.Context, rootKey registry.Key, path string, values []string) (map[string]interface{}, error) {
 	if m.BatchReadFunc != nil {
@@ -63,3 +75,11 @@ func (m *MockRegistryService) BatchRead(ctx context.Context, rootKey registry.Ke
 	}
 	return make(map[string]interface{}), nil
 }
+
+// ReadTree mocks the ReadTree method
+func (m *MockRegistryService) ReadTree(ctx context.Context, rootKey registry.Key, path string, opts pkg.TreeOptions) (*pkg.RegistryTree, error) {
+	if m.ReadTreeFunc != nil {
+		return m.ReadTreeFunc(ctx, rootKey, path, opts)
+	}
+	return &pkg.RegistryTree{Keys: []pkg.TreeKey{}}, nil
+}
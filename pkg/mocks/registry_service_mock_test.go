@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg"
+)
+
+// BenchmarkBatchRead measures a read_all query's path after the registry
+// read: BatchRead through the RegistryService interface, then rendering and
+// comparing each value as a check. The mock stands in for the registry so
+// results do not depend on the machine running the benchmark.
+func BenchmarkBatchRead(b *testing.B) {
+	for _, count := range []int{10, 100, 1000} {
+		data := make(map[string]interface{}, count)
+		names := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			name := fmt.Sprintf("Value%d", i)
+			names = append(names, name)
+			switch i % 4 {
+			case 0:
+				data[name] = fmt.Sprintf("String %d", i)
+			case 1:
+				data[name] = uint64(i)
+			case 2:
+				data[name] = []byte{byte(i), 0x00, 0xFF}
+			default:
+				data[name] = []string{"First", "Second"}
+			}
+		}
+
+		var service pkg.RegistryService = &MockRegistryService{
+			BatchReadFunc: func(ctx context.Context, rootKey registry.Key, path string, values []string) (map[string]interface{}, error) {
+				return data, nil
+			},
+		}
+		query := pkg.RegistryQuery{Name: "bench", Operation: "read", ExpectedValue: "1 (Enabled)"}
+
+		b.Run(fmt.Sprintf("%d_values", count), func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				values, err := service.BatchRead(ctx, registry.LOCAL_MACHINE, `SOFTWARE\Bench`, names)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, name := range names {
+					if _, err := query.Matches(fmt.Sprint(values[name])); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package netshare lets report output live on a UNC file share. It connects
+// to the share with optional credentials, retries operations that fail
+// while the share is briefly unreachable, and moves output written to a
+// local fallback directory back to the share once it is available again.
+package netshare
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/fileio"
+)
+
+// errUnsupported is returned when credentials are given on a platform that
+// cannot connect to shares itself
+var errUnsupported = errors.New("share credentials are only supported on Windows")
+
+// IsUNC reports whether path is a UNC path such as \\server\share\dir
+func IsUNC(path string) bool {
+	if len(path) < 3 || !isSeparator(path[0]) || !isSeparator(path[1]) {
+		return false
+	}
+	// \\?\ and \\.\ are device paths, not shares
+	return path[2] != '?' && path[2] != '.' && !isSeparator(path[2])
+}
+
+// Root returns the \\server\share part of a UNC path
+func Root(path string) (string, error) {
+	if !IsUNC(path) {
+		return "", fmt.Errorf("%s is not a UNC path", path)
+	}
+
+	parts := strings.FieldsFunc(path[2:], func(r rune) bool {
+		return r < 0x80 && isSeparator(byte(r))
+	})
+	if len(parts) < 2 {
+		return "", fmt.Errorf("UNC path %s must name a server and a share", path)
+	}
+	return `\\` + parts[0] + `\` + parts[1], nil
+}
+
+func isSeparator(c byte) bool {
+	return c == '\\' || c == '/'
+}
+
+// Credentials authenticate to a share. Without a username the share is
+// accessed as the current user.
+type Credentials struct {
+	Username string // user, DOMAIN\user or user@domain
+	Password string
+}
+
+// Connection is an authenticated connection to a share
+type Connection struct {
+	root      string
+	connected bool
+}
+
+// Connect authenticates to the share holding path. With empty credentials
+// no connection is made and Windows uses the current user's logon session.
+func Connect(path string, creds Credentials) (*Connection, error) {
+	root, err := Root(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Connection{root: root}
+	if creds.Username == "" {
+		return conn, nil
+	}
+
+	if err := addConnection(root, creds); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s as %s: %w", root, creds.Username, err)
+	}
+	conn.connected = true
+	return conn, nil
+}
+
+// Root returns the share the connection is for
+func (c *Connection) Root() string {
+	return c.root
+}
+
+// Close drops a connection made with credentials
+func (c *Connection) Close() error {
+	if !c.connected {
+		return nil
+	}
+	c.connected = false
+	return cancelConnection(c.root)
+}
+
+// RetryPolicy retries operations that fail with transient share errors
+type RetryPolicy struct {
+	Attempts int           // Total attempts, including the first
+	Delay    time.Duration // Wait before the first retry, doubled after each
+}
+
+// Do runs fn until it succeeds, fails with a non-transient error, or the
+// attempts are used up. It returns the last error.
+func (p RetryPolicy) Do(fn func() error) error {
+	delay := p.Delay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !IsTransient(err) || attempt >= p.Attempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// IsTransient reports whether err is a network error that may clear up if
+// the operation is retried
+func IsTransient(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync moves the files in the fallback directory src to dst, retrying
+// transient errors. Each file is written atomically to dst before it is
+// removed from src, so an interrupted sync never loses output. It returns
+// the destination paths of the files moved.
+func Sync(src, dst string, policy RetryPolicy) ([]string, error) {
+	entries, err := os.ReadDir(src)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	var moved []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		data, err := os.ReadFile(srcPath)
+		if err != nil {
+			return moved, fmt.Errorf("failed to read %s: %w", srcPath, err)
+		}
+		if err := policy.Do(func() error { return fileio.WriteFile(dstPath, data, 0644) }); err != nil {
+			return moved, fmt.Errorf("failed to copy %s to %s: %w", srcPath, dst, err)
+		}
+		if err := os.Remove(srcPath); err != nil {
+			return moved, fmt.Errorf("copied %s but could not remove it: %w", srcPath, err)
+		}
+		moved = append(moved, dstPath)
+	}
+	return moved, nil
+}
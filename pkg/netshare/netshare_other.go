@@ -0,0 +1,24 @@
+//go:build !windows
+
+package netshare
+
+import "syscall"
+
+// transientErrors are the errors a flaky mount or network produces
+var transientErrors = []error{
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.EHOSTUNREACH,
+	syscall.ENETUNREACH,
+}
+
+// addConnection is unsupported; mount the share before running instead
+func addConnection(root string, creds Credentials) error {
+	return errUnsupported
+}
+
+// cancelConnection is a no-op where addConnection is unsupported
+func cancelConnection(root string) error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package netshare
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRoot tests recognizing UNC paths and extracting the share
+func TestRoot(t *testing.T) {
+	tests := []struct {
+		path    string
+		isUNC   bool
+		want    string
+		wantErr bool
+	}{
+		{`\\fs01\compliance\reports`, true, `\\fs01\compliance`, false},
+		{`//fs01/compliance`, true, `\\fs01\compliance`, false},
+		{`\\fs01\compliance\`, true, `\\fs01\compliance`, false},
+		{`\\fs01`, true, "", true},
+		{`\\?\C:\output`, false, "", true},
+		{`\\.\pipe\x`, false, "", true},
+		{`C:\output\reports`, false, "", true},
+		{`output/reports`, false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := IsUNC(tt.path); got != tt.isUNC {
+				t.Errorf("IsUNC() = %v, want %v", got, tt.isUNC)
+			}
+			got, err := Root(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Root() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Root() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRetryPolicy tests that only transient errors are retried
+func TestRetryPolicy(t *testing.T) {
+	transient := fmt.Errorf("write failed: %w", os.ErrDeadlineExceeded)
+	permanent := errors.New("access denied")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"recovers", []error{transient, transient}, 3, nil},
+		{"gives up", []error{transient, transient, transient, transient}, 3, transient},
+		{"permanent", []error{permanent}, 1, permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := RetryPolicy{Attempts: 3}.Do(func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestSync tests moving fallback output to its destination
+func TestSync(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "pending")
+	dst := filepath.Join(t.TempDir(), "share", "reports")
+
+	if moved, err := Sync(src, dst, RetryPolicy{Attempts: 1}); err != nil || len(moved) != 0 {
+		t.Fatalf("Sync() of missing directory = %v, %v", moved, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "report.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := Sync(src, dst, RetryPolicy{Attempts: 1})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(moved) != 1 || moved[0] != filepath.Join(dst, "report.html") {
+		t.Fatalf("moved = %v", moved)
+	}
+
+	data, err := os.ReadFile(moved[0])
+	if err != nil || string(data) != "<html>" {
+		t.Errorf("destination = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "report.html")); !os.IsNotExist(err) {
+		t.Errorf("source file not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "nested")); err != nil {
+		t.Errorf("subdirectory was touched: %v", err)
+	}
+}
+
+// TestConnect tests connecting as the current user
+func TestConnect(t *testing.T) {
+	conn, err := Connect(`\\fs01\compliance\reports`, Credentials{})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if conn.Root() != `\\fs01\compliance` {
+		t.Errorf("Root() = %q", conn.Root())
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := Connect(`C:\output`, Credentials{}); err == nil {
+		t.Error("Connect() accepted a local path")
+	}
+}
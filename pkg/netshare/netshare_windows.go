@@ -0,0 +1,95 @@
+//go:build windows
+
+package netshare
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modmpr                     = windows.NewLazySystemDLL("mpr.dll")
+	procWNetAddConnection2W    = modmpr.NewProc("WNetAddConnection2W")
+	procWNetCancelConnection2W = modmpr.NewProc("WNetCancelConnection2W")
+)
+
+// netResource mirrors NETRESOURCEW
+type netResource struct {
+	Scope       uint32
+	Type        uint32
+	DisplayType uint32
+	Usage       uint32
+	LocalName   *uint16
+	RemoteName  *uint16
+	Comment     *uint16
+	Provider    *uint16
+}
+
+const (
+	resourceTypeDisk  = 0x1
+	connectTemporary  = 0x4
+	errorSessionCreds = windows.Errno(1219) // ERROR_SESSION_CREDENTIAL_CONFLICT
+)
+
+// transientErrors are the Windows errors a flaky share or network produces
+var transientErrors = []error{
+	windows.Errno(53),   // ERROR_BAD_NETPATH
+	windows.Errno(54),   // ERROR_NETWORK_BUSY
+	windows.Errno(59),   // ERROR_UNEXP_NET_ERR
+	windows.Errno(64),   // ERROR_NETNAME_DELETED
+	windows.Errno(121),  // ERROR_SEM_TIMEOUT
+	windows.Errno(1231), // ERROR_NETWORK_UNREACHABLE
+	windows.Errno(1232), // ERROR_HOST_UNREACHABLE
+	windows.Errno(1236), // ERROR_CONNECTION_ABORTED
+	windows.WSAETIMEDOUT,
+	windows.WSAECONNRESET,
+}
+
+// addConnection authenticates to root for the lifetime of the process
+func addConnection(root string, creds Credentials) error {
+	remote, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(creds.Username)
+	if err != nil {
+		return err
+	}
+	password, err := windows.UTF16PtrFromString(creds.Password)
+	if err != nil {
+		return err
+	}
+
+	resource := netResource{Type: resourceTypeDisk, RemoteName: remote}
+	ret, _, _ := procWNetAddConnection2W.Call(
+		uintptr(unsafe.Pointer(&resource)),
+		uintptr(unsafe.Pointer(password)),
+		uintptr(unsafe.Pointer(user)),
+		connectTemporary,
+	)
+	switch errno := windows.Errno(ret); errno {
+	case 0:
+		return nil
+	case errorSessionCreds:
+		// The current session is already connected to this server as a
+		// different user; Windows allows only one set of credentials
+		return fmt.Errorf("already connected to the server with other credentials: %w", errno)
+	default:
+		return errno
+	}
+}
+
+// cancelConnection drops the connection made by addConnection
+func cancelConnection(root string) error {
+	remote, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return err
+	}
+	ret, _, _ := procWNetCancelConnection2W.Call(uintptr(unsafe.Pointer(remote)), 0, 1)
+	if ret != 0 {
+		return windows.Errno(ret)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AzureConfig locates an Azure Blob Storage container
+type AzureConfig struct {
+	// Endpoint is the blob service URL; empty uses
+	// "https://<account>.blob.core.windows.net". The Azurite emulator is
+	// "http://127.0.0.1:10000/devstoreaccount1".
+	Endpoint  string `mapstructure:"endpoint"`
+	Account   string `mapstructure:"account"`
+	Container string `mapstructure:"container"`
+	Prefix    string `mapstructure:"prefix"` // Prepended to every key, e.g. "compliance/"
+
+	// AccountKey is the base64 storage account key requests are signed with
+	AccountKey string `mapstructure:"account_key"`
+}
+
+// azureVersion is the Blob service REST API version requests ask for
+const azureVersion = "2021-08-06"
+
+// Azure keeps objects as block blobs in an Azure Blob Storage container.
+// Requests are signed with a Shared Key.
+type Azure struct {
+	cfg      AzureConfig
+	key      []byte
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewAzure returns a store keeping objects in the container cfg names
+func NewAzure(cfg AzureConfig) (*Azure, error) {
+	if cfg.Account == "" || cfg.Container == "" {
+		return nil, errors.New("azure storage requires account and container")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.AccountKey)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("azure storage requires account_key, the base64 storage account key")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid azure endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+
+	return &Azure{
+		cfg:      cfg,
+		key:      key,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// blobURL returns the URL of key; an empty key gives the container's URL
+func (a *Azure) blobURL(key string) *url.URL {
+	u := *a.endpoint
+	u.Path = u.Path + "/" + a.cfg.Container
+	if key != "" {
+		u.Path += "/" + a.cfg.Prefix + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+// Location returns the blob's URL
+func (a *Azure) Location(key string) string {
+	return a.blobURL(key).String()
+}
+
+// do sends a signed request
+func (a *Azure) do(req *http.Request, what string) (*http.Response, error) {
+	req.Header.Set("X-Ms-Version", azureVersion)
+	a.sign(req, a.now().UTC())
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("azure %s %s: %w", req.Method, what, err)
+	}
+	return resp, nil
+}
+
+// azureError describes an unexpected Blob service response, whose body is
+// an XML error document
+func azureError(method, what string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("azure %s %s: %s: %s", method, what, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// newBlobRequest returns a request for the blob under key
+func (a *Azure) newBlobRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, a.blobURL(key).String(), body)
+}
+
+// Put uploads the object as a block blob in one request
+func (a *Azure) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := a.newBlobRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := a.do(req, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return azureError("PUT", key, resp)
+	}
+	return nil
+}
+
+// Get downloads the object
+func (a *Azure) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := a.newBlobRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.do(req, key)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, azureError("GET", key, resp)
+	}
+}
+
+// Delete removes the object
+func (a *Azure) Delete(ctx context.Context, key string) error {
+	req, err := a.newBlobRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.do(req, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNotFound:
+		return nil
+	default:
+		return azureError("DELETE", key, resp)
+	}
+}
+
+// enumerationResults is the part of a List Blobs response List reads
+type enumerationResults struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List lists the container with List Blobs, a page of up to 5000 blobs at
+// a time
+func (a *Azure) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {a.cfg.Prefix + prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		u := a.blobURL("")
+		u.RawQuery = canonicalQuery(query)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := a.do(req, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := azureError("LIST", prefix, resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result enumerationResults
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("azure list %s: invalid response: %w", prefix, err)
+		}
+
+		for _, blob := range result.Blobs {
+			modified, err := http.ParseTime(blob.Properties.LastModified)
+			if err != nil {
+				return nil, fmt.Errorf("azure list %s: invalid Last-Modified of %s", prefix, blob.Name)
+			}
+			objects = append(objects, Object{
+				Key:      strings.TrimPrefix(blob.Name, a.cfg.Prefix),
+				Size:     blob.Properties.ContentLength,
+				Modified: modified,
+			})
+		}
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// sign adds the x-ms-date and Shared Key Authorization headers to req
+func (a *Azure) sign(req *http.Request, now time.Time) {
+	req.Header.Set("X-Ms-Date", now.Format(http.TimeFormat))
+	signature := hmac.New(sha256.New, a.key)
+	signature.Write([]byte(a.stringToSign(req)))
+	req.Header.Set("Authorization", "SharedKey "+a.cfg.Account+":"+
+		base64.StdEncoding.EncodeToString(signature.Sum(nil)))
+}
+
+// stringToSign returns the string a Shared Key signature of req covers
+func (a *Azure) stringToSign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var headers []string
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			headers = append(headers, lower+":"+strings.TrimSpace(strings.Join(values, ",")))
+		}
+	}
+	sort.Strings(headers)
+
+	resource := "/" + a.cfg.Account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-Md5"),
+		req.Header.Get("Content-Type"),
+		"", // Date: x-ms-date is signed instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + strings.Join(headers, "\n") + "\n" + resource
+}
@@ -0,0 +1,247 @@
+// Package objectstore stores files by key, in a local directory, an
+// S3-compatible bucket (AWS S3, MinIO, Ceph) or an Azure Blob Storage
+// container, so a server can keep large blobs out of its database and off
+// its own disk when it runs stateless.
+//
+// Keys are slash-separated relative paths such as
+// "attachments/2026/10/3f9a1c07.png".
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"compliancetoolkit/pkg/fileio"
+)
+
+// Storage types
+const (
+	TypeDir   = "dir"
+	TypeS3    = "s3"
+	TypeAzure = "azure"
+)
+
+// ErrNotFound is returned by Get for a key that holds no object
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps objects by key
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any
+	// object there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get opens the object under key; the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object under key. A missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns the objects whose keys start with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// Location describes where the object under key is kept, such as a
+	// file path or URL, for logs and records
+	Location(key string) string
+}
+
+// Object describes a stored object
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Config selects and configures a store
+type Config struct {
+	Type  string      `mapstructure:"type"` // dir, s3 or azure
+	Dir   string      `mapstructure:"dir"`  // dir: directory objects are kept in
+	S3    S3Config    `mapstructure:"s3"`
+	Azure AzureConfig `mapstructure:"azure"`
+}
+
+// New returns the store cfg describes
+func New(cfg Config) (Store, error) {
+	switch cfg.Type {
+	case TypeDir, "":
+		return NewDir(cfg.Dir)
+	case TypeS3:
+		return NewS3(cfg.S3)
+	case TypeAzure:
+		return NewAzure(cfg.Azure)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q (use %s, %s or %s)", cfg.Type, TypeDir, TypeS3, TypeAzure)
+	}
+}
+
+// Expire deletes the objects under prefix last modified before cutoff and
+// returns how many were deleted
+func Expire(ctx context.Context, store Store, prefix string, cutoff time.Time) (int, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, object := range objects {
+		if !object.Modified.Before(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// validateKey refuses keys that are not clean relative paths, so a key can
+// never address a file outside a Dir store
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return nil
+}
+
+// Dir keeps objects as files in a directory
+type Dir struct {
+	dir  string
+	perm os.FileMode // Mode of the files written
+}
+
+// NewDir returns a store keeping objects in dir, which is created if needed
+func NewDir(dir string) (*Dir, error) {
+	return newDir(dir, 0750, 0640)
+}
+
+// NewPrivateDir returns a store keeping objects in dir, which is created if
+// needed, as files only their owner can read
+func NewPrivateDir(dir string) (*Dir, error) {
+	return newDir(dir, 0700, 0600)
+}
+
+func newDir(dir string, dirPerm, perm os.FileMode) (*Dir, error) {
+	if dir == "" {
+		return nil, errors.New("storage directory is required")
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Dir{dir: dir, perm: perm}, nil
+}
+
+// path returns the file of key
+func (d *Dir) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object atomically
+func (d *Dir) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := fileio.EnsureFreeSpace(d.dir, uint64(size)); err != nil {
+		return err
+	}
+
+	f, err := fileio.Create(path, d.perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	written, err := io.Copy(f, body)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if written != size {
+		return fmt.Errorf("failed to write %s: got %d bytes, want %d", key, written, size)
+	}
+	return f.Commit()
+}
+
+// Get opens the object's file
+func (d *Dir) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the object's file
+func (d *Dir) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory. Files being written, whose names start with a
+// dot, are left out.
+func (d *Dir) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), Modified: info.ModTime()})
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage directory: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Location returns the object's absolute file path
+func (d *Dir) Location(key string) string {
+	path := filepath.Join(d.dir, filepath.FromSlash(key))
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,267 @@
+// Package policypack builds and reads policy packs: zip archives of report
+// configurations with a manifest, distributed with a detached Ed25519
+// signature so a pack can be moved between environments and checked
+// against the publisher's key before it is imported.
+//
+// An archive holds manifest.json and the report files it lists:
+//
+//	manifest.json
+//	reports/NIST_800_171_compliance.json
+//
+// The detached signature (pack.zip.sig) is the base64 Ed25519 signature of
+// the whole archive.
+package policypack
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ManifestName is the manifest's path in the archive
+const ManifestName = "manifest.json"
+
+// MaxFileSize limits each file read from an archive, so a small archive
+// cannot expand into an unbounded amount of memory
+const MaxFileSize = 10 << 20
+
+// ErrUntrusted is returned when a pack's signature does not verify against
+// any trusted key
+var ErrUntrusted = errors.New("pack signature does not match a trusted key")
+
+// Manifest describes a pack and the report configurations in it
+type Manifest struct {
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Publisher string    `json:"publisher,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Reports   []Report  `json:"reports"`
+}
+
+// Report is one report configuration listed in a manifest
+type Report struct {
+	File      string `json:"file"`                // Path in the archive
+	PolicyID  string `json:"policy_id"`           // Defaults to the file name without extension
+	SHA256    string `json:"sha256"`              // Hex SHA-256 of the file
+	Signature string `json:"signature,omitempty"` // Optional policy signature for client verification
+}
+
+// Pack is a read and checked policy pack
+type Pack struct {
+	Manifest Manifest
+	SHA256   string            // Hex SHA-256 of the archive
+	files    map[string][]byte // Report files by path
+}
+
+// Data returns the contents of a report listed in the manifest
+func (p *Pack) Data(r Report) []byte {
+	return p.files[r.File]
+}
+
+// Read parses an archive, checking that every report the manifest lists is
+// present, valid JSON and matches its hash. It does not check the
+// signature; see Verify.
+func Read(archive []byte) (*Pack, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+
+	manifestData, ok := files[ManifestName]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", ManifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestName, err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("%s has no name", ManifestName)
+	}
+	if len(manifest.Reports) == 0 {
+		return nil, fmt.Errorf("%s lists no reports", ManifestName)
+	}
+
+	seen := make(map[string]bool, len(manifest.Reports))
+	for i := range manifest.Reports {
+		r := &manifest.Reports[i]
+		data, ok := files[r.File]
+		if !ok || r.File == ManifestName {
+			return nil, fmt.Errorf("report %q is not in the archive", r.File)
+		}
+		if r.PolicyID == "" {
+			r.PolicyID = defaultPolicyID(r.File)
+		}
+		if seen[r.PolicyID] {
+			return nil, fmt.Errorf("policy %s is listed twice", r.PolicyID)
+		}
+		seen[r.PolicyID] = true
+
+		if got := sha256Hex(data); !strings.EqualFold(got, r.SHA256) {
+			return nil, fmt.Errorf("report %s checksum mismatch: got %s, manifest lists %q", r.File, got, r.SHA256)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("report %s is not valid JSON", r.File)
+		}
+	}
+
+	return &Pack{Manifest: manifest, SHA256: sha256Hex(archive), files: files}, nil
+}
+
+// Build writes an archive holding the manifest and the report files it
+// lists, filling in each report's SHA-256 and default policy ID. files holds
+// the report data by archive path; files the manifest does not list are left
+// out.
+func Build(manifest Manifest, files map[string][]byte) ([]byte, error) {
+	manifest.Reports = append([]Report(nil), manifest.Reports...)
+	for i := range manifest.Reports {
+		r := &manifest.Reports[i]
+		if r.File == ManifestName || !fs.ValidPath(r.File) {
+			return nil, fmt.Errorf("invalid report path %q", r.File)
+		}
+		data, ok := files[r.File]
+		if !ok {
+			return nil, fmt.Errorf("no data for report %s", r.File)
+		}
+		if r.PolicyID == "" {
+			r.PolicyID = defaultPolicyID(r.File)
+		}
+		r.SHA256 = sha256Hex(data)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", ManifestName, err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	if err := write(ManifestName, manifestData); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ManifestName, err)
+	}
+	for _, r := range manifest.Reports {
+		if err := write(r.File, files[r.File]); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", r.File, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	// Read applies the same checks an importer will, so a pack that builds
+	// also imports
+	archive := buf.Bytes()
+	if _, err := Read(archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// Verify checks a detached base64 signature of an archive against trusted
+// keys and returns the key that made it
+func Verify(archive []byte, signature string, trusted []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pack signature encoding: %w", err)
+	}
+	for _, key := range trusted {
+		if ed25519.Verify(key, archive, sig) {
+			return key, nil
+		}
+	}
+	return nil, ErrUntrusted
+}
+
+// Sign returns the detached base64 signature of an archive
+func Sign(key ed25519.PrivateKey, archive []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, archive))
+}
+
+// EncodePrivateKey encodes an Ed25519 private key as PEM PKCS#8, the format
+// ParsePrivateKey reads
+func EncodePrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey decodes a PEM encoded PKCS#8 Ed25519 private key, the
+// format of the publisher key compliance-server sign-policy creates
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is %T, not Ed25519", parsed)
+	}
+	return key, nil
+}
+
+// readFile reads one archive entry, refusing entries over MaxFileSize
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", f.Name, MaxFileSize)
+	}
+	return data, nil
+}
+
+// defaultPolicyID is the policy ID of a report the manifest gives none for:
+// its file name without extension, as the server assigns on import
+func defaultPolicyID(file string) string {
+	return strings.TrimSuffix(path.Base(file), path.Ext(file))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+package policypack
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const testReport = `{"metadata":{"report_title":"NIST","report_version":"1.0"},"queries":[]}`
+
+// buildArchive zips files, adding manifest.json unless it is nil
+func buildArchive(t *testing.T, manifest *Manifest, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if manifest != nil {
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[ManifestName] = string(data)
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestRead tests reading packs and rejecting ones whose manifest does not
+// match their contents
+func TestRead(t *testing.T) {
+	report := Report{File: "reports/NIST_800_171.json", SHA256: sha256Hex([]byte(testReport))}
+
+	tests := []struct {
+		name     string
+		manifest *Manifest
+		files    map[string]string
+		wantErr  bool
+	}{
+		{"valid", &Manifest{Name: "nist", Reports: []Report{report}}, map[string]string{report.File: testReport}, false},
+		{"no manifest", nil, map[string]string{report.File: testReport}, true},
+		{"no reports", &Manifest{Name: "nist"}, map[string]string{}, true},
+		{"missing report", &Manifest{Name: "nist", Reports: []Report{report}}, map[string]string{}, true},
+		{"modified report", &Manifest{Name: "nist", Reports: []Report{report}}, map[string]string{report.File: testReport + " "}, true},
+		{"duplicate policy", &Manifest{Name: "nist", Reports: []Report{report, report}}, map[string]string{report.File: testReport}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack, err := Read(buildArchive(t, tt.manifest, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			r := pack.Manifest.Reports[0]
+			if r.PolicyID != "NIST_800_171" {
+				t.Errorf("policy ID = %q, want the file name", r.PolicyID)
+			}
+			if string(pack.Data(r)) != testReport {
+				t.Errorf("Data() = %q, want the report", pack.Data(r))
+			}
+		})
+	}
+}
+
+// TestVerify tests detached signatures against trusted keys
+func TestVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := []byte("archive")
+	signature := Sign(privateKey, archive)
+
+	key, err := Verify(archive, signature+"\n", []ed25519.PublicKey{otherKey, publicKey})
+	if err != nil || !key.Equal(publicKey) {
+		t.Errorf("Verify() = %v, %v; want the signing key", key, err)
+	}
+	if _, err := Verify(archive, signature, []ed25519.PublicKey{otherKey}); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("Verify() untrusted key error = %v, want ErrUntrusted", err)
+	}
+	if _, err := Verify([]byte("tampered"), signature, []ed25519.PublicKey{publicKey}); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("Verify() tampered archive error = %v, want ErrUntrusted", err)
+	}
+}
+
+// TestBuild tests that a built pack reads back with its hashes and policy
+// IDs filled in
+func TestBuild(t *testing.T) {
+	manifest := Manifest{
+		Name:    "nist",
+		Version: "1.0",
+		Reports: []Report{{File: "reports/NIST_800_171.json"}, {File: "reports/other.json", PolicyID: "custom"}},
+	}
+	files := map[string][]byte{
+		"reports/NIST_800_171.json": []byte(testReport),
+		"reports/other.json":        []byte(testReport),
+		"reports/unlisted.json":     []byte(testReport),
+	}
+
+	archive, err := Build(manifest, files)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if manifest.Reports[0].SHA256 != "" {
+		t.Error("Build() modified the caller's manifest")
+	}
+	pack, err := Read(archive)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := pack.Manifest.Reports; len(got) != 2 || got[0].PolicyID != "NIST_800_171" || got[1].PolicyID != "custom" {
+		t.Errorf("reports = %+v, want NIST_800_171 and custom", got)
+	}
+	if pack.Data(Report{File: "reports/unlisted.json"}) != nil {
+		t.Error("Build() included a file the manifest does not list")
+	}
+
+	for _, bad := range []Manifest{
+		{Name: "nist", Reports: []Report{{File: "reports/missing.json"}}},
+		{Name: "nist", Reports: []Report{{File: "../escape.json"}}},
+		{Name: "nist", Reports: []Report{{File: ManifestName}}},
+		{Name: "", Reports: []Report{{File: "reports/other.json"}}},
+		{Name: "nist", Reports: []Report{{File: "reports/other.json"}, {File: "reports/NIST_800_171.json", PolicyID: "other"}}},
+	} {
+		if _, err := Build(bad, files); err == nil {
+			t.Errorf("Build(%+v) succeeded, want an error", bad.Reports)
+		}
+	}
+}
+
+// TestPrivateKeyEncoding tests that an encoded key parses back
+func TestPrivateKeyEncoding(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodePrivateKey(key)
+	if err != nil {
+		t.Fatalf("EncodePrivateKey() error = %v", err)
+	}
+	parsed, err := ParsePrivateKey(data)
+	if err != nil || !parsed.Equal(key) {
+		t.Errorf("ParsePrivateKey() = %v, want the encoded key", err)
+	}
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("ParsePrivateKey() accepted data without a PEM block")
+	}
+}
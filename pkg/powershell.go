@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Limits of powershell queries. A script is passed as -EncodedCommand,
+// base64 of UTF-16LE after the session setup, which makes it about 2.7
+// times longer; MaxScriptLength keeps the command line of the longest
+// script within maxCommandLineLength, even with a long executable path.
+const (
+	MaxScriptLength           = 10 * 1024 // Longest script, in bytes
+	MaxPowerShellTimeout      = 300       // Largest timeout_seconds
+	DefaultPowerShellTimeout  = 30 * time.Second
+	MaxPowerShellOutputLength = 64 * 1024 // Output kept of each stream; the rest is dropped
+)
+
+// PowerShell language modes a script runs in. Constrained language mode
+// allows cmdlets such as Get-BitLockerVolume and Get-MpComputerStatus but
+// not arbitrary .NET types, COM objects or Add-Type.
+const (
+	LanguageModeConstrained = "ConstrainedLanguage"
+	LanguageModeFull        = "FullLanguage"
+)
+
+// PowerShellExecutionPolicies are the execution policies scripts may run
+// under. The policy applies to the modules and script files a script
+// loads; AllSigned only loads signed ones, such as Windows' own modules.
+var PowerShellExecutionPolicies = []string{"Restricted", "AllSigned", "RemoteSigned", "Bypass"}
+
+// maxCommandLineLength is the longest command line CreateProcess accepts,
+// in UTF-16 code units including the terminating null
+const maxCommandLineLength = 32767
+
+// ErrScriptTimeout is returned by RunPowerShell for a script stopped after
+// its timeout
+var ErrScriptTimeout = errors.New("script timed out")
+
+// PowerShellOptions control how RunPowerShell runs a script
+type PowerShellOptions struct {
+	Executable      string        // PowerShell binary (empty: powershell.exe)
+	ExecutionPolicy string        // One of PowerShellExecutionPolicies (empty: AllSigned)
+	LanguageMode    string        // LanguageModeConstrained or LanguageModeFull (empty: constrained)
+	Timeout         time.Duration // Stop the script after this long (0: DefaultPowerShellTimeout)
+}
+
+// withDefaults fills in the options left unset
+func (o PowerShellOptions) withDefaults() PowerShellOptions {
+	if o.Executable == "" {
+		o.Executable = "powershell.exe"
+	}
+	if o.ExecutionPolicy == "" {
+		o.ExecutionPolicy = "AllSigned"
+	}
+	if o.LanguageMode == "" {
+		o.LanguageMode = LanguageModeConstrained
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultPowerShellTimeout
+	}
+	return o
+}
+
+// PowerShellResult is a script run by RunPowerShell and what it wrote
+type PowerShellResult struct {
+	Command   []string      // The command line, with the script passed as -EncodedCommand
+	Script    string        // The script as run: the query's script after the session setup
+	Stdout    string        // Standard output, at most MaxPowerShellOutputLength bytes
+	Stderr    string        // Standard error, at most MaxPowerShellOutputLength bytes
+	ExitCode  int           // -1 when the script was stopped
+	Truncated bool          // Output was dropped past MaxPowerShellOutputLength
+	Duration  time.Duration // Time from start to exit
+}
+
+// Output returns the script's standard output without surrounding
+// whitespace, the value compared with a query's expected value
+func (r *PowerShellResult) Output() string {
+	return strings.TrimSpace(r.Stdout)
+}
+
+// Lines returns the non-blank lines of the script's standard output, each
+// trimmed. A script writing several objects produces one line each.
+func (r *PowerShellResult) Lines() []string {
+	var lines []string
+	for _, line := range strings.Split(r.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// PowerShellSession returns the script RunPowerShell runs for script: the
+// session is set up to write UTF-8 without progress records, then the
+// language mode is set before any of script runs. Once constrained, a
+// script cannot return to full language mode.
+func PowerShellSession(script, languageMode string) string {
+	var b strings.Builder
+	b.WriteString("$ProgressPreference = 'SilentlyContinue'\n")
+	b.WriteString("[Console]::OutputEncoding = [System.Text.Encoding]::UTF8\n")
+	if languageMode != LanguageModeFull {
+		b.WriteString("$ExecutionContext.SessionState.LanguageMode = '" + LanguageModeConstrained + "'\n")
+	}
+	b.WriteString(script)
+	return b.String()
+}
+
+// RunPowerShell runs script in a new PowerShell process without profiles or
+// interaction, and captures its output. A non-zero exit code is reported in
+// the result, not as an error; errors are failures to start the process,
+// ErrScriptTimeout, and ctx ending first.
+func RunPowerShell(parent context.Context, script string, opts PowerShellOptions) (*PowerShellResult, error) {
+	opts = opts.withDefaults()
+	session := PowerShellSession(script, opts.LanguageMode)
+
+	result := &PowerShellResult{
+		Command:  powerShellCommand(session, opts),
+		Script:   session,
+		ExitCode: -1,
+	}
+	if length := commandLineLength(result.Command); length >= maxCommandLineLength {
+		return result, fmt.Errorf("script is too long to run: its command line is %d characters, the limit is %d", length, maxCommandLineLength-1)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, opts.Timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: MaxPowerShellOutputLength}
+	stderr := &cappedBuffer{max: MaxPowerShellOutputLength}
+	cmd := exec.CommandContext(ctx, result.Command[0], result.Command[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second // Children holding the pipes open do not hold up the check
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+
+	if ctx.Err() != nil {
+		if err := parent.Err(); err != nil {
+			return result, err
+		}
+		return result, fmt.Errorf("%w after %s: %w", ErrScriptTimeout, opts.Timeout, context.DeadlineExceeded)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return result, fmt.Errorf("failed to run %s: %w", opts.Executable, err)
+	default:
+		result.ExitCode = 0
+	}
+	return result, nil
+}
+
+// powerShellCommand returns the command line running session with opts,
+// which have their defaults filled in
+func powerShellCommand(session string, opts PowerShellOptions) []string {
+	return []string{opts.Executable, "-NoLogo", "-NoProfile", "-NonInteractive",
+		"-ExecutionPolicy", opts.ExecutionPolicy, "-EncodedCommand", encodePowerShellCommand(session)}
+}
+
+// commandLineLength returns the length of the command line Windows builds
+// from args, in UTF-16 code units: the arguments separated by spaces, those
+// with spaces quoted. None of ours contain quotes to escape.
+func commandLineLength(args []string) int {
+	length := len(args) - 1
+	for _, arg := range args {
+		length += len(utf16.Encode([]rune(arg)))
+		if strings.ContainsAny(arg, " \t") {
+			length += 2
+		}
+	}
+	return length
+}
+
+// encodePowerShellCommand encodes a script for -EncodedCommand, base64 of
+// its UTF-16LE bytes, so it reaches PowerShell without quoting
+func encodePowerShellCommand(script string) string {
+	units := utf16.Encode([]rune(script))
+	raw := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(raw[2*i:], unit)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest,
+// so a script cannot exhaust the client's memory with output
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// TestPowerShellSession tests that scripts run constrained unless full
+// language mode is asked for
+func TestPowerShellSession(t *testing.T) {
+	script := "Get-MpComputerStatus"
+	constrained := PowerShellSession(script, LanguageModeConstrained)
+	if !strings.Contains(constrained, "LanguageMode = 'ConstrainedLanguage'") {
+		t.Errorf("PowerShellSession() does not constrain the session:\n%s", constrained)
+	}
+	if !strings.HasSuffix(constrained, "\n"+script) {
+		t.Errorf("PowerShellSession() does not end with the script:\n%s", constrained)
+	}
+	if full := PowerShellSession(script, LanguageModeFull); strings.Contains(full, "LanguageMode") {
+		t.Errorf("PowerShellSession(full) sets the language mode:\n%s", full)
+	}
+}
+
+// TestEncodePowerShellCommand tests the -EncodedCommand encoding, base64
+// of UTF-16LE
+func TestEncodePowerShellCommand(t *testing.T) {
+	raw, err := base64.StdEncoding.DecodeString(encodePowerShellCommand("dir é"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{'d', 0, 'i', 0, 'r', 0, ' ', 0, 0xE9, 0}
+	if string(raw) != string(want) {
+		t.Errorf("encodePowerShellCommand() = % x, want % x", raw, want)
+	}
+}
+
+// TestPowerShellCommandLineLimit tests that the longest script allowed
+// fits the command line CreateProcess accepts, with a long executable path
+// and the longest session setup. ASCII is the worst case: no character takes
+// fewer bytes than UTF-16 code units.
+func TestPowerShellCommandLineLimit(t *testing.T) {
+	opts := PowerShellOptions{
+		Executable: `C:\Program Files\` + strings.Repeat(`PowerShell Tools\`, 14) + "powershell.exe",
+	}.withDefaults()
+	session := PowerShellSession(strings.Repeat("#", MaxScriptLength), LanguageModeConstrained)
+
+	if length := commandLineLength(powerShellCommand(session, opts)); length >= maxCommandLineLength {
+		t.Errorf("command line of a %d byte script is %d characters, want under %d", MaxScriptLength, length, maxCommandLineLength)
+	}
+
+	// A 16 KiB script does not fit, so the check is not vacuous
+	long := PowerShellSession(strings.Repeat("#", 16*1024), LanguageModeConstrained)
+	if length := commandLineLength(powerShellCommand(long, opts)); length < maxCommandLineLength {
+		t.Errorf("command line of a 16 KiB script is %d characters, want it over the limit", length)
+	}
+	if got := commandLineLength([]string{`C:\Program Files\pwsh.exe`, "-NoLogo"}); got != 35 {
+		t.Errorf("commandLineLength() = %d, want 35", got)
+	}
+}
+
+// TestPowerShellResultLines tests that output is split into trimmed,
+// non-blank lines
+func TestPowerShellResultLines(t *testing.T) {
+	result := &PowerShellResult{Stdout: "On\r\n\r\n  Off \r\n"}
+	if got := strings.Join(result.Lines(), "|"); got != "On|Off" {
+		t.Errorf("Lines() = %q, want %q", got, "On|Off")
+	}
+	if got := result.Output(); got != "On\r\n\r\n  Off" {
+		t.Errorf("Output() = %q", got)
+	}
+}
+
+// TestCappedBuffer tests that output past the limit is dropped and
+// flagged
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{max: 5}
+	for _, chunk := range []string{"abc", "defg", "h"} {
+		if n, err := b.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if b.String() != "abcde" || !b.truncated {
+		t.Errorf("cappedBuffer = %q, truncated %v; want \"abcde\", true", b.String(), b.truncated)
+	}
+}
+
+// TestMatchesOutput tests comparing script output, several lines as a list
+func TestMatchesOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		operator string
+		lines    []string
+		want     bool
+	}{
+		{"single line", "On", "", []string{"On"}, true},
+		{"single line mismatch", "On", "", []string{"Off"}, false},
+		{"no output", "On", "", nil, false},
+		{"numeric", "7", "lte", []string{"3"}, true},
+		{"lines contain", "On", "contains", []string{"On", "Off"}, true},
+		{"every line one of", "On", "subset", []string{"On", "Off"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := RegistryQuery{Operation: "powershell", ExpectedValue: tt.expected, ExpectedOperator: tt.operator}
+			got, err := query.MatchesOutput(tt.lines)
+			if err != nil {
+				t.Fatalf("MatchesOutput() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MatchesOutput(%q) = %v, want %v", tt.lines, got, tt.want)
+			}
+		})
+	}
+}
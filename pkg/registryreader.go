@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/windows/registry"
@@ -41,6 +42,10 @@ type RegistryReader struct {
 	logger      *slog.Logger
 	timeout     time.Duration
 	auditLogger *AuditLogger
+	remoteHost  string
+
+	remoteMu    sync.Mutex
+	remoteRoots map[registry.Key]registry.Key
 }
 
 // RegistryReaderOption configures a RegistryReader
@@ -67,6 +72,16 @@ func WithAuditLogger(auditLogger *AuditLogger) RegistryReaderOption {
 	}
 }
 
+// WithRemoteHost reads the registry of another Windows machine through its
+// Remote Registry service instead of the local registry. Only HKLM and HKU
+// can be opened remotely, and the caller needs administrative rights on the
+// remote host.
+func WithRemoteHost(host string) RegistryReaderOption {
+	return func(r *RegistryReader) {
+		r.remoteHost = strings.TrimPrefix(host, `\\`)
+	}
+}
+
 // NewRegistryReader creates a new RegistryReader instance with options
 func NewRegistryReader(opts ...RegistryReaderOption) *RegistryReader {
 	r := &RegistryReader{
@@ -80,6 +95,66 @@ func NewRegistryReader(opts ...RegistryReaderOption) *RegistryReader {
 	return r
 }
 
+// RemoteHost returns the remote machine being read, or "" for the local registry
+func (r *RegistryReader) RemoteHost() string {
+	return r.remoteHost
+}
+
+// Close releases the connections to a remote host's registry. It is a no-op
+// for readers of the local registry.
+func (r *RegistryReader) Close() error {
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+
+	var errs []error
+	for _, root := range r.remoteRoots {
+		if err := root.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.remoteRoots = nil
+	return errors.Join(errs...)
+}
+
+// openKey opens path below rootKey on the local machine, or on the remote
+// host when one is configured
+func (r *RegistryReader) openKey(rootKey registry.Key, path string, access uint32) (registry.Key, error) {
+	if r.remoteHost == "" {
+		return registry.OpenKey(rootKey, path, access)
+	}
+
+	root, err := r.remoteRoot(rootKey)
+	if err != nil {
+		return 0, err
+	}
+	return registry.OpenKey(root, path, access)
+}
+
+// remoteRoot returns the remote host's handle for rootKey, connecting on
+// first use. Handles are shared by every read until Close.
+func (r *RegistryReader) remoteRoot(rootKey registry.Key) (registry.Key, error) {
+	if rootKey != registry.LOCAL_MACHINE && rootKey != registry.USERS {
+		return 0, fmt.Errorf("%s cannot be read remotely (only HKLM and HKU)", RootKeyToString(rootKey))
+	}
+
+	r.remoteMu.Lock()
+	defer r.remoteMu.Unlock()
+
+	if root, ok := r.remoteRoots[rootKey]; ok {
+		return root, nil
+	}
+
+	root, err := registry.OpenRemoteKey(r.remoteHost, rootKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to remote registry on %s: %w", r.remoteHost, err)
+	}
+	if r.remoteRoots == nil {
+		r.remoteRoots = make(map[registry.Key]registry.Key)
+	}
+	r.remoteRoots[rootKey] = root
+	return root, nil
+}
+
 // ReadString reads a string value from the registry with context support
 func (r *RegistryReader) ReadString(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error) {
 	return r.ReadStringWithTimeout(ctx, rootKey, path, valueName, r.timeout)
@@ -111,7 +186,7 @@ func (r *RegistryReader) ReadStringWithTimeout(ctx context.Context, rootKey regi
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
 			resultCh <- result{"", &RegistryError{
 				Op:    "OpenKey",
@@ -152,8 +227,21 @@ func (r *RegistryReader) ReadStringWithTimeout(ctx context.Context, rootKey regi
 
 // ReadValue reads any registry value and returns it as a string (auto-detects type)
 func (r *RegistryReader) ReadValue(ctx context.Context, rootKey registry.Key, path, valueName string) (string, error) {
+	value, err := r.ReadTypedValue(ctx, rootKey, path, valueName)
+	if err != nil {
+		return "", err
+	}
+	return value.Display(), nil
+}
+
+// ReadTypedValue reads any registry value with its type. A REG_MULTI_SZ
+// keeps its items and a REG_EXPAND_SZ is returned unexpanded.
+func (r *RegistryReader) ReadTypedValue(ctx context.Context, rootKey registry.Key, path, valueName string) (*RegistryValue, error) {
 	start := time.Now()
 	rootKeyStr := RootKeyToString(rootKey)
+	if r.remoteHost != "" {
+		rootKeyStr = `\\` + r.remoteHost + `\` + rootKeyStr
+	}
 
 	defer func() {
 		r.logger.Debug("registry read completed",
@@ -177,15 +265,15 @@ func (r *RegistryReader) ReadValue(ctx context.Context, rootKey registry.Key, pa
 	}
 
 	type result struct {
-		value string
+		value *RegistryValue
 		err   error
 	}
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
-			resultCh <- result{"", &RegistryError{
+			resultCh <- result{nil, &RegistryError{
 				Op:    "OpenKey",
 				Key:   path,
 				Value: valueName,
@@ -195,37 +283,17 @@ func (r *RegistryReader) ReadValue(ctx context.Context, rootKey registry.Key, pa
 		}
 		defer key.Close()
 
-		// Try string first (REG_SZ - most common)
-		if value, _, err := key.GetStringValue(valueName); err == nil {
-			resultCh <- result{value, nil}
-			return
-		}
-
-		// Try multi-string (REG_MULTI_SZ)
-		if values, _, err := key.GetStringsValue(valueName); err == nil {
-			resultCh <- result{strings.Join(values, ", "), nil}
-			return
-		}
-
-		// Try integer (DWORD/QWORD)
-		if value, _, err := key.GetIntegerValue(valueName); err == nil {
-			resultCh <- result{fmt.Sprintf("%d", value), nil}
-			return
-		}
-
-		// Try binary (REG_BINARY)
-		if value, _, err := key.GetBinaryValue(valueName); err == nil {
-			resultCh <- result{fmt.Sprintf("%x", value), nil}
+		value, err := readTypedValue(key, valueName)
+		if err != nil {
+			resultCh <- result{nil, &RegistryError{
+				Op:    "GetValue",
+				Key:   path,
+				Value: valueName,
+				Err:   err,
+			}}
 			return
 		}
-
-		// If all fail, return error
-		resultCh <- result{"", &RegistryError{
-			Op:    "GetValue",
-			Key:   path,
-			Value: valueName,
-			Err:   fmt.Errorf("unable to read value (tried string, multi-string, integer, and binary types)"),
-		}}
+		resultCh <- result{value, nil}
 	}()
 
 	select {
@@ -239,7 +307,7 @@ func (r *RegistryReader) ReadValue(ctx context.Context, rootKey registry.Key, pa
 		if r.auditLogger != nil && r.auditLogger.IsEnabled() {
 			r.auditLogger.LogRegistryRead(rootKeyStr, path, valueName, false, ctx.Err())
 		}
-		return "", fmt.Errorf("registry read cancelled: %w", ctx.Err())
+		return nil, fmt.Errorf("registry read cancelled: %w", ctx.Err())
 	case res := <-resultCh:
 		// Audit: completed read
 		if r.auditLogger != nil && r.auditLogger.IsEnabled() {
@@ -249,6 +317,53 @@ func (r *RegistryReader) ReadValue(ctx context.Context, rootKey registry.Key, pa
 	}
 }
 
+// ReadQueryValue reads the value a query names, expanding a REG_EXPAND_SZ
+// when the query sets expand_env and decoding a REG_BINARY that has a
+// decoder
+func (r *RegistryReader) ReadQueryValue(ctx context.Context, rootKey registry.Key, query RegistryQuery) (*RegistryValue, error) {
+	value, err := r.ReadTypedValue(ctx, rootKey, query.Path, query.ValueName)
+	if err != nil {
+		return nil, err
+	}
+	if query.ExpandEnv {
+		if value, err = value.Expand(); err != nil {
+			return nil, err
+		}
+	}
+	return query.DecodeValue(value)
+}
+
+// readTypedValue reads a value of an open key whatever its type
+func readTypedValue(key registry.Key, valueName string) (*RegistryValue, error) {
+	// Try string first (REG_SZ - most common - or REG_EXPAND_SZ)
+	if value, valType, err := key.GetStringValue(valueName); err == nil {
+		if valType == registry.EXPAND_SZ {
+			return &RegistryValue{Type: RegTypeExpandString, String: value}, nil
+		}
+		return &RegistryValue{Type: RegTypeString, String: value}, nil
+	}
+
+	// Try multi-string (REG_MULTI_SZ)
+	if values, _, err := key.GetStringsValue(valueName); err == nil {
+		return &RegistryValue{Type: RegTypeMultiString, Strings: values}, nil
+	}
+
+	// Try integer (DWORD/QWORD)
+	if value, valType, err := key.GetIntegerValue(valueName); err == nil {
+		if valType == registry.QWORD {
+			return &RegistryValue{Type: RegTypeQWord, Integer: value}, nil
+		}
+		return &RegistryValue{Type: RegTypeDWord, Integer: value}, nil
+	}
+
+	// Try binary (REG_BINARY)
+	if value, _, err := key.GetBinaryValue(valueName); err == nil {
+		return &RegistryValue{Type: RegTypeBinary, Binary: value}, nil
+	}
+
+	return nil, fmt.Errorf("unable to read value (tried string, multi-string, integer, and binary types)")
+}
+
 // ReadInteger reads a DWORD/QWORD value from the registry with context support
 func (r *RegistryReader) ReadInteger(ctx context.Context, rootKey registry.Key, path, valueName string) (uint64, error) {
 	start := time.Now()
@@ -268,7 +383,7 @@ func (r *RegistryReader) ReadInteger(ctx context.Context, rootKey registry.Key,
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
 			resultCh <- result{0, &RegistryError{Op: "OpenKey", Key: path, Value: valueName, Err: err}}
 			return
@@ -311,7 +426,7 @@ func (r *RegistryReader) ReadBinary(ctx context.Context, rootKey registry.Key, p
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
 			resultCh <- result{nil, &RegistryError{Op: "OpenKey", Key: path, Value: valueName, Err: err}}
 			return
@@ -354,7 +469,7 @@ func (r *RegistryReader) ReadStrings(ctx context.Context, rootKey registry.Key,
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
 			resultCh <- result{nil, &RegistryError{Op: "OpenKey", Key: path, Value: valueName, Err: err}}
 			return
@@ -396,7 +511,7 @@ func (r *RegistryReader) BatchRead(ctx context.Context, rootKey registry.Key, pa
 	resultCh := make(chan result, 1)
 
 	go func() {
-		key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
 		if err != nil {
 			resultCh <- result{nil, &RegistryError{Op: "OpenKey", Key: path, Err: err}}
 			return
@@ -156,6 +156,40 @@ func TestNewRegistryReader_Defaults(t *testing.T) {
 	}
 }
 
+func TestNewRegistryReader_RemoteHost(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "plain hostname", host: "WS01", want: "WS01"},
+		{name: "UNC style hostname", host: `\\WS01`, want: "WS01"},
+		{name: "empty host reads locally", host: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewRegistryReader(WithRemoteHost(tt.host))
+			if got := reader.RemoteHost(); got != tt.want {
+				t.Errorf("RemoteHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryReader_RemoteRootKeys(t *testing.T) {
+	reader := NewRegistryReader(WithRemoteHost("WS01"))
+	defer reader.Close()
+
+	// Only HKLM and HKU are exposed by the Remote Registry service, so other
+	// roots must be refused before any connection is attempted
+	for _, rootKey := range []registry.Key{registry.CURRENT_USER, registry.CLASSES_ROOT, registry.CURRENT_CONFIG} {
+		if _, err := reader.ReadValue(context.Background(), rootKey, `SOFTWARE\Test`, "Value"); err == nil {
+			t.Errorf("ReadValue(%s) on remote host should fail", RootKeyToString(rootKey))
+		}
+	}
+}
+
 func TestRegistryReader_ContextCancellation(t *testing.T) {
 	reader := NewRegistryReader(WithTimeout(10 * time.Second))
 
@@ -237,6 +271,51 @@ func TestRegistryReader_BatchRead_Integration(t *testing.T) {
 	}
 }
 
+// Integration test - subtree read
+func TestRegistryReader_ReadTree_Integration(t *testing.T) {
+	reader := NewRegistryReader()
+	ctx := context.Background()
+
+	tree, err := reader.ReadTree(ctx, registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services`,
+		TreeOptions{MaxDepth: 1, MaxKeys: 50, ValueName: "Start"})
+	if err != nil {
+		t.Fatalf("ReadTree() error = %v", err)
+	}
+	if len(tree.Keys) == 0 || len(tree.Keys) > 50 {
+		t.Errorf("ReadTree() read %d keys, want 1 to 50", len(tree.Keys))
+	}
+	for _, key := range tree.Keys {
+		if _, ok := key.Values["Start"]; !ok || len(key.Values) != 1 {
+			t.Errorf("ReadTree() key %q values = %v, want only Start", key.Path, key.Values)
+		}
+	}
+
+	_, err = reader.ReadTree(ctx, registry.LOCAL_MACHINE, `SOFTWARE\NonExistentKey12345`, TreeOptions{})
+	if !IsNotExist(err) {
+		t.Errorf("ReadTree() missing key error = %v, want not exist", err)
+	}
+}
+
+func TestRegistryReader_ReadUsers_Integration(t *testing.T) {
+	reader := NewRegistryReader()
+
+	tree, err := reader.ReadTree(context.Background(), registry.USERS, `Control Panel\Desktop`,
+		TreeOptions{ValueName: "WallPaper", PerUser: true})
+	if err != nil {
+		t.Fatalf("ReadTree() per user error = %v", err)
+	}
+	for _, key := range tree.Keys {
+		if !IsUserSID(key.Path) {
+			t.Errorf("ReadTree() per user read %q, want only user SIDs", key.Path)
+		}
+	}
+	for _, sid := range tree.Missing {
+		if !IsUserSID(sid) {
+			t.Errorf("ReadTree() per user missing %q, want only user SIDs", sid)
+		}
+	}
+}
+
 // Benchmark tests
 func BenchmarkReadString(b *testing.B) {
 	reader := NewRegistryReader()
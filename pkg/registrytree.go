@@ -0,0 +1,275 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Limits of read_tree queries
+const (
+	DefaultTreeDepth = 1     // Levels of subkeys read when max_depth is not set
+	MaxTreeDepth     = 16    // Largest max_depth
+	DefaultTreeKeys  = 1000  // Keys read when max_keys is not set
+	MaxTreeKeys      = 10000 // Largest max_keys
+)
+
+// ErrEmptyTree is returned by RegistryQuery.MatchesTree for a tree without
+// values to compare
+var ErrEmptyTree = errors.New("no values found")
+
+// treeTimeoutFactor is how many times the reader's timeout a whole tree
+// may take to read, when the caller's context has no deadline
+const treeTimeoutFactor = 10
+
+// TreeOptions limits the keys and values ReadTree reads
+type TreeOptions struct {
+	MaxDepth  int      // Levels of subkeys below the path (0: DefaultTreeDepth)
+	MaxKeys   int      // Keys read at most, the path's own included (0: DefaultTreeKeys)
+	ValueName string   // Read only this value of each key (empty: every value)
+	DenyPaths []string // Subkeys skipped, as security.deny_registry_paths
+
+	// PerUser reads the path below every loaded user hive instead of a
+	// subtree (see ReadUsers); MaxDepth and MaxKeys do not apply
+	PerUser bool
+}
+
+// TreeKey is a key read by ReadTree and its values, as ReadValue renders them
+type TreeKey struct {
+	Path   string            `json:"path"` // Relative to the tree's path; "" is the path itself
+	Values map[string]string `json:"values"`
+}
+
+// TreeValue is one value of a tree
+type TreeValue struct {
+	Path  string // Key, relative to the tree's path
+	Name  string
+	Value string
+}
+
+// RegistryTree is the result of ReadTree. With a value name, only the keys
+// holding that value are listed. For a per-user read, keys are user SIDs.
+type RegistryTree struct {
+	Keys      []TreeKey `json:"keys"`
+	Truncated bool      `json:"truncated,omitempty"` // max_keys was reached before every key was read
+	Skipped   int       `json:"skipped,omitempty"`   // Subkeys denied by security settings or that could not be opened
+	Missing   []string  `json:"missing,omitempty"`   // Per-user reads: users without the key or value
+}
+
+// Compared returns how many results MatchesTree compares: every value,
+// and every user missing the key or value
+func (t *RegistryTree) Compared() int {
+	return len(t.Values()) + len(t.Missing)
+}
+
+// Values returns every value of the tree, in key order and by name within
+// a key
+func (t *RegistryTree) Values() []TreeValue {
+	var values []TreeValue
+	for _, key := range t.Keys {
+		names := make([]string, 0, len(key.Values))
+		for name := range key.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			values = append(values, TreeValue{Path: key.Path, Name: name, Value: key.Values[name]})
+		}
+	}
+	return values
+}
+
+// String renders the tree one value per line, as path\name = value
+func (t *RegistryTree) String() string {
+	var lines []string
+	for _, value := range t.Values() {
+		lines = append(lines, value.String())
+	}
+	for _, sid := range t.Missing {
+		lines = append(lines, sid+" = not found")
+	}
+	if t.Truncated {
+		lines = append(lines, fmt.Sprintf("(truncated after %d keys)", len(t.Keys)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String renders the value as path\name = value
+func (v TreeValue) String() string {
+	name := v.Name
+	if name == "" {
+		name = "(Default)"
+	}
+	if v.Path != "" {
+		name = v.Path + `\` + name
+	}
+	return name + " = " + v.Value
+}
+
+// withDefaults fills in the limits left at zero
+func (o TreeOptions) withDefaults() TreeOptions {
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = DefaultTreeDepth
+	}
+	if o.MaxKeys <= 0 {
+		o.MaxKeys = DefaultTreeKeys
+	}
+	return o
+}
+
+// ReadTree reads the values of the key at path and of its subkeys, down to
+// opts.MaxDepth levels and at most opts.MaxKeys keys, depth first in name
+// order. Subkeys that are denied or cannot be opened are skipped and
+// counted; only a failure to open path itself is an error. With
+// opts.PerUser, the key at path is read for every user instead (see
+// walkUsers).
+func (r *RegistryReader) ReadTree(ctx context.Context, rootKey registry.Key, path string, opts TreeOptions) (*RegistryTree, error) {
+	start := time.Now()
+	opts = opts.withDefaults()
+	rootKeyStr := RootKeyToString(rootKey)
+	if r.remoteHost != "" {
+		rootKeyStr = `\\` + r.remoteHost + `\` + rootKeyStr
+	}
+
+	var keys int
+	defer func() {
+		r.logger.Debug("registry tree read completed",
+			slog.String("path", path),
+			slog.Int("keys", keys),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}()
+
+	// Create timeout context if parent doesn't have deadline
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, treeTimeoutFactor*r.timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		tree *RegistryTree
+		err  error
+	}
+	resultCh := make(chan result, 1)
+
+	go func() {
+		walk := r.walkTree
+		if opts.PerUser {
+			walk = r.walkUsers
+		}
+		tree, err := walk(ctx, rootKey, path, opts)
+		resultCh <- result{tree, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		if r.auditLogger != nil && r.auditLogger.IsEnabled() {
+			r.auditLogger.LogRegistryRead(rootKeyStr, path, opts.ValueName, false, ctx.Err())
+		}
+		return nil, fmt.Errorf("registry tree read cancelled: %w", ctx.Err())
+	case res := <-resultCh:
+		if r.auditLogger != nil && r.auditLogger.IsEnabled() {
+			r.auditLogger.LogRegistryRead(rootKeyStr, path, opts.ValueName, res.err == nil, res.err)
+		}
+		if res.tree != nil {
+			keys = len(res.tree.Keys)
+		}
+		return res.tree, res.err
+	}
+}
+
+// walkTree reads the tree below path. It stops early when ctx is done.
+func (r *RegistryReader) walkTree(ctx context.Context, rootKey registry.Key, path string, opts TreeOptions) (*RegistryTree, error) {
+	type pending struct {
+		rel   string
+		depth int
+	}
+	tree := &RegistryTree{Keys: []TreeKey{}}
+	stack := []pending{{}}
+	opened := 0
+
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if opened >= opts.MaxKeys {
+			tree.Truncated = true
+			break
+		}
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		full := joinRegistryPath(path, next.rel)
+		key, err := r.openKey(rootKey, full, registry.QUERY_VALUE|registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			if next.rel == "" {
+				return nil, &RegistryError{Op: "OpenKey", Key: path, Value: opts.ValueName, Err: err}
+			}
+			tree.Skipped++
+			continue
+		}
+		opened++
+
+		values := readKeyValues(key, opts.ValueName)
+		if opts.ValueName == "" || len(values) > 0 {
+			tree.Keys = append(tree.Keys, TreeKey{Path: next.rel, Values: values})
+		}
+
+		if next.depth < opts.MaxDepth {
+			names, err := key.ReadSubKeyNames(-1)
+			if err != nil {
+				tree.Skipped++
+			}
+			// Pushed in reverse so subkeys are read in name order
+			sort.Sort(sort.Reverse(sort.StringSlice(names)))
+			for _, name := range names {
+				child := joinRegistryPath(next.rel, name)
+				if ValidateAgainstDenyList(joinRegistryPath(path, child), opts.DenyPaths) != nil {
+					tree.Skipped++
+					continue
+				}
+				stack = append(stack, pending{rel: child, depth: next.depth + 1})
+			}
+		}
+		key.Close()
+	}
+	return tree, nil
+}
+
+// readKeyValues reads the values of an open key as ReadValue renders them:
+// only valueName when it is set, otherwise every value
+func readKeyValues(key registry.Key, valueName string) map[string]string {
+	values := make(map[string]string)
+	names := []string{valueName}
+	if valueName == "" {
+		var err error
+		if names, err = key.ReadValueNames(-1); err != nil {
+			return values
+		}
+	}
+	for _, name := range names {
+		if value, err := readTypedValue(key, name); err == nil {
+			values[name] = value.Display()
+		}
+	}
+	return values
+}
+
+// joinRegistryPath joins registry path elements, ignoring empty ones
+func joinRegistryPath(parent, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	default:
+		return parent + `\` + child
+	}
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// UserSIDPlaceholder stands for each user's SID in the path of a read_users
+// query, e.g. SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList\{sid}
+// under HKLM. Paths under HKU without it are read below each user's hive.
+const UserSIDPlaceholder = "{sid}"
+
+// userSIDPrefixes are the SIDs of accounts people log on with: local and
+// domain accounts (S-1-5-21) and Microsoft Entra ID accounts (S-1-12-1).
+// Service accounts such as LocalSystem (S-1-5-18) and .DEFAULT are skipped.
+var userSIDPrefixes = []string{"S-1-5-21-", "S-1-12-1-"}
+
+// IsUserSID reports whether name, a subkey of HKU, is the hive of a user
+// account rather than of a service account or a user's _Classes hive
+func IsUserSID(name string) bool {
+	upper := strings.ToUpper(name)
+	if strings.HasSuffix(upper, "_CLASSES") {
+		return false
+	}
+	for _, prefix := range userSIDPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserPath returns the path a read_users query reads for one user: the
+// template with UserSIDPlaceholder replaced, or the template below the
+// user's hive when it has no placeholder
+func UserPath(template, sid string) string {
+	if strings.Contains(template, UserSIDPlaceholder) {
+		return strings.ReplaceAll(template, UserSIDPlaceholder, sid)
+	}
+	return joinRegistryPath(sid, template)
+}
+
+// walkUsers reads the key at the path template for every user whose hive
+// is loaded under HKU, in SID order. Each user is a key of the tree named
+// by SID. Users without the key or value are listed as missing; paths
+// that are denied or cannot be opened are skipped and counted.
+func (r *RegistryReader) walkUsers(ctx context.Context, rootKey registry.Key, template string, opts TreeOptions) (*RegistryTree, error) {
+	sids, err := r.loadedUserSIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &RegistryTree{Keys: []TreeKey{}}
+	for _, sid := range sids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path := UserPath(template, sid)
+		if ValidateAgainstDenyList(path, opts.DenyPaths) != nil {
+			tree.Skipped++
+			continue
+		}
+		key, err := r.openKey(rootKey, path, registry.QUERY_VALUE)
+		if err != nil {
+			if errors.Is(err, registry.ErrNotExist) {
+				tree.Missing = append(tree.Missing, sid)
+			} else {
+				tree.Skipped++
+			}
+			continue
+		}
+		values := readKeyValues(key, opts.ValueName)
+		key.Close()
+
+		if len(values) == 0 {
+			tree.Missing = append(tree.Missing, sid)
+			continue
+		}
+		tree.Keys = append(tree.Keys, TreeKey{Path: sid, Values: values})
+	}
+	return tree, nil
+}
+
+// loadedUserSIDs returns the SIDs of the user hives loaded under HKU,
+// sorted. A user's hive is loaded while they are logged on.
+func (r *RegistryReader) loadedUserSIDs() ([]string, error) {
+	users, err := r.openKey(registry.USERS, "", registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil, &RegistryError{Op: "OpenKey", Key: "HKU", Err: err}
+	}
+	defer users.Close()
+
+	names, err := users.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, &RegistryError{Op: "ReadSubKeyNames", Key: "HKU", Err: err}
+	}
+
+	var sids []string
+	for _, name := range names {
+		if IsUserSID(name) {
+			sids = append(sids, name)
+		}
+	}
+	sort.Strings(sids)
+	return sids, nil
+}
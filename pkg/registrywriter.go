@@ -0,0 +1,656 @@
+package pkg
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/decode"
+	"compliancetoolkit/pkg/fileio"
+)
+
+// Registry value types accepted in RegistryQuery.WriteType
+const (
+	RegTypeString       = "string"
+	RegTypeExpandString = "expand_string"
+	RegTypeMultiString  = "multi_string"
+	RegTypeDWord        = "dword"
+	RegTypeQWord        = "qword"
+	RegTypeBinary       = "binary"
+)
+
+// regTypeRaw is the type of captured values of the registry types without
+// one of their own, such as REG_NONE and REG_LINK. Their bytes are restored
+// with their registry type, RegistryValue.RawType.
+const regTypeRaw = "raw"
+
+// ErrReadOnly is returned when a write is attempted by a writer created for a
+// read-only configuration
+var ErrReadOnly = errors.New("registry writes are disabled (security.read_only is true)")
+
+// RegistryValue is a typed registry value, as ReadTypedValue reads it and
+// rollback snapshots capture it
+type RegistryValue struct {
+	Type    string   `json:"type"`
+	String  string   `json:"string,omitempty"`
+	Strings []string `json:"strings,omitempty"`
+	Integer uint64   `json:"integer,omitempty"`
+	Binary  []byte   `json:"binary,omitempty"`
+	RawType uint32   `json:"raw_type,omitempty"` // Registry type of a raw value
+
+	// Decoded is a REG_BINARY value decoded into fields, which Display and
+	// ReportValue use in place of the bytes (see RegistryQuery.DecodeValue)
+	Decoded *decode.Value `json:"decoded,omitempty"`
+}
+
+// Display renders the value the way ReadValue reports it
+func (v *RegistryValue) Display() string {
+	if v == nil {
+		return "not found"
+	}
+	switch v.Type {
+	case RegTypeMultiString:
+		return strings.Join(v.Strings, ", ")
+	case RegTypeDWord, RegTypeQWord:
+		return strconv.FormatUint(v.Integer, 10)
+	case RegTypeBinary:
+		if v.Decoded != nil {
+			return v.Decoded.String()
+		}
+		return fmt.Sprintf("%x", v.Binary)
+	case regTypeRaw:
+		return fmt.Sprintf("%x", v.Binary)
+	default:
+		return v.String
+	}
+}
+
+// IsList reports whether the value is a multi-string (REG_MULTI_SZ)
+func (v *RegistryValue) IsList() bool {
+	return v != nil && v.Type == RegTypeMultiString
+}
+
+// ReportValue returns the value as reports and evidence record it: the
+// items of a multi-string, the fields of a decoded binary value, otherwise
+// the rendering of Display
+func (v *RegistryValue) ReportValue() interface{} {
+	if v.IsList() {
+		return v.Strings
+	}
+	if v != nil && v.Decoded != nil {
+		return *v.Decoded
+	}
+	return v.Display()
+}
+
+// Expand returns a copy of a REG_EXPAND_SZ value with its environment
+// variables, such as %SystemRoot%, expanded. Variables are expanded with
+// this process's environment, also for values read from a remote host.
+// Values of other types are returned as they are.
+func (v *RegistryValue) Expand() (*RegistryValue, error) {
+	if v == nil || v.Type != RegTypeExpandString {
+		return v, nil
+	}
+	expanded, err := registry.ExpandString(v.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand %q: %w", v.String, err)
+	}
+	return &RegistryValue{Type: RegTypeString, String: expanded}, nil
+}
+
+// SnapshotEntry records the state of one value before it was remediated.
+// Previous is nil when the value did not exist.
+type SnapshotEntry struct {
+	CheckName  string         `json:"check_name"`
+	RootKey    string         `json:"root_key"`
+	Path       string         `json:"path"`
+	ValueName  string         `json:"value_name"`
+	KeyExisted bool           `json:"key_existed"`
+	Previous   *RegistryValue `json:"previous,omitempty"`
+	Applied    *RegistryValue `json:"applied"`
+	Timestamp  time.Time      `json:"timestamp"`
+}
+
+// Validate checks an entry read back from a snapshot file the way a query is
+// checked before it is remediated, so an edited snapshot cannot name an
+// invalid key or value
+func (e SnapshotEntry) Validate() error {
+	if err := ValidateRootKey(e.RootKey); err != nil {
+		return err
+	}
+	if err := ValidateRegistryPath(e.Path); err != nil {
+		return err
+	}
+	return ValidateValueName(e.ValueName)
+}
+
+// RollbackSnapshot collects the previous state of every value changed by a
+// remediation run. Each entry is written to disk before the change it
+// describes is made, so a run interrupted part-way can still be undone.
+type RollbackSnapshot struct {
+	ReportType string          `json:"report_type"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Entries    []SnapshotEntry `json:"entries"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// NewRollbackSnapshot creates an empty snapshot persisted at path
+func NewRollbackSnapshot(path, reportType string) *RollbackSnapshot {
+	return &RollbackSnapshot{
+		ReportType: reportType,
+		CreatedAt:  time.Now(),
+		Entries:    []SnapshotEntry{},
+		path:       path,
+	}
+}
+
+// LoadRollbackSnapshot reads a snapshot written by a previous remediation run
+func LoadRollbackSnapshot(path string) (*RollbackSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollback snapshot: %w", err)
+	}
+
+	var snapshot RollbackSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse rollback snapshot: %w", err)
+	}
+	snapshot.path = path
+	return &snapshot, nil
+}
+
+// Path returns the file the snapshot is persisted to
+func (s *RollbackSnapshot) Path() string {
+	return s.path
+}
+
+// record appends an entry and persists the snapshot
+func (s *RollbackSnapshot) record(entry SnapshotEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Entries = append(s.Entries, entry)
+	if err := s.save(); err != nil {
+		s.Entries = s.Entries[:len(s.Entries)-1]
+		return err
+	}
+	return nil
+}
+
+// save writes the snapshot atomically so a crash never leaves a truncated
+// snapshot behind
+func (s *RollbackSnapshot) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode rollback snapshot: %w", err)
+	}
+
+	if err := fileio.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write rollback snapshot: %w", err)
+	}
+	return nil
+}
+
+// RemediationResult describes the outcome of remediating one check
+type RemediationResult struct {
+	CheckName string
+	RootKey   string
+	Path      string
+	ValueName string
+	Previous  *RegistryValue // nil when the value did not exist
+	Target    *RegistryValue
+	Compliant bool // The value already matched; nothing was changed
+	DryRun    bool // The change was planned but not made
+	Applied   bool
+}
+
+// RegistryWriter sets non-compliant registry values to their expected values.
+// Writes are refused unless the writer is explicitly enabled, and every
+// change is preceded by a rollback snapshot entry and audited.
+type RegistryWriter struct {
+	logger      *slog.Logger
+	auditLogger *AuditLogger
+	enabled     bool
+	dryRun      bool
+}
+
+// RegistryWriterOption configures a RegistryWriter
+type RegistryWriterOption func(*RegistryWriter)
+
+// WithWriterLogger sets a custom logger
+func WithWriterLogger(logger *slog.Logger) RegistryWriterOption {
+	return func(w *RegistryWriter) {
+		w.logger = logger
+	}
+}
+
+// WithWriterAuditLogger sets an audit logger for registry writes
+func WithWriterAuditLogger(auditLogger *AuditLogger) RegistryWriterOption {
+	return func(w *RegistryWriter) {
+		w.auditLogger = auditLogger
+	}
+}
+
+// WithWritesEnabled allows the writer to modify the registry. Callers should
+// only pass true when security.read_only is false.
+func WithWritesEnabled(enabled bool) RegistryWriterOption {
+	return func(w *RegistryWriter) {
+		w.enabled = enabled
+	}
+}
+
+// WithDryRun reports the changes a remediation would make without making them
+func WithDryRun(dryRun bool) RegistryWriterOption {
+	return func(w *RegistryWriter) {
+		w.dryRun = dryRun
+	}
+}
+
+// NewRegistryWriter creates a new RegistryWriter. Without WithWritesEnabled
+// it only supports dry runs.
+func NewRegistryWriter(opts ...RegistryWriterOption) *RegistryWriter {
+	w := &RegistryWriter{
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Remediate sets the value read by a "remediate" query to its expected value
+// when the current value does not comply. The previous state is recorded in
+// snapshot before the value is written.
+func (w *RegistryWriter) Remediate(ctx context.Context, query RegistryQuery, snapshot *RollbackSnapshot) (*RemediationResult, error) {
+	if !strings.EqualFold(query.Operation, "remediate") {
+		return nil, fmt.Errorf("query %q is not a remediate operation", query.Name)
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rootKey, err := ParseRootKey(query.RootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := RemediationTarget(query)
+	if err != nil {
+		return nil, err
+	}
+
+	keyExisted, previous, err := captureValue(rootKey, query.Path, query.ValueName)
+	if err != nil {
+		return nil, &RegistryError{Op: "Snapshot", Key: query.Path, Value: query.ValueName, Err: err}
+	}
+
+	result := &RemediationResult{
+		CheckName: query.Name,
+		RootKey:   query.RootKey,
+		Path:      query.Path,
+		ValueName: query.ValueName,
+		Previous:  previous,
+		Target:    target,
+		DryRun:    w.dryRun,
+	}
+
+	if previous != nil {
+		if matches, _ := query.MatchesValue(previous); matches {
+			result.Compliant = true
+			return result, nil
+		}
+	}
+
+	if w.dryRun {
+		w.auditWrite(query, previous, target, true, nil)
+		return result, nil
+	}
+	if !w.enabled {
+		return nil, ErrReadOnly
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("a rollback snapshot is required to remediate %q", query.Name)
+	}
+
+	if err := snapshot.record(SnapshotEntry{
+		CheckName:  query.Name,
+		RootKey:    query.RootKey,
+		Path:       query.Path,
+		ValueName:  query.ValueName,
+		KeyExisted: keyExisted,
+		Previous:   previous,
+		Applied:    target,
+		Timestamp:  time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	err = writeValue(rootKey, query.Path, query.ValueName, target)
+	w.auditWrite(query, previous, target, false, err)
+	if err != nil {
+		return nil, &RegistryError{Op: "SetValue", Key: query.Path, Value: query.ValueName, Err: err}
+	}
+
+	w.logger.Info("registry value remediated",
+		slog.String("check", query.Name),
+		slog.String("path", query.Path),
+		slog.String("value", query.ValueName),
+		slog.String("previous", previous.Display()),
+		slog.String("new", target.Display()),
+	)
+	result.Applied = true
+	return result, nil
+}
+
+// Rollback restores every value recorded in snapshot to its previous state,
+// newest change first. Values that did not exist are deleted again, along
+// with keys the remediation created when they are empty. Entries that fail
+// validation are skipped and reported. Callers check the security deny
+// list and allowed root keys, as they do before Remediate.
+func (w *RegistryWriter) Rollback(ctx context.Context, snapshot *RollbackSnapshot) error {
+	if !w.enabled && !w.dryRun {
+		return ErrReadOnly
+	}
+
+	var errs []error
+	for i := len(snapshot.Entries) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		entry := snapshot.Entries[i]
+		if err := entry.Validate(); err != nil {
+			w.auditRollback(entry, w.dryRun, err)
+			errs = append(errs, fmt.Errorf("%s: %w", entry.CheckName, err))
+			continue
+		}
+		if w.dryRun {
+			w.auditRollback(entry, true, nil)
+			continue
+		}
+
+		err := restoreEntry(entry)
+		w.auditRollback(entry, false, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.CheckName, err))
+			continue
+		}
+
+		w.logger.Info("registry value rolled back",
+			slog.String("check", entry.CheckName),
+			slog.String("path", entry.Path),
+			slog.String("value", entry.ValueName),
+			slog.String("restored", entry.Previous.Display()),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// auditWrite records a planned or attempted remediation in the audit log
+func (w *RegistryWriter) auditWrite(query RegistryQuery, previous, target *RegistryValue, dryRun bool, err error) {
+	if w.auditLogger != nil && w.auditLogger.IsEnabled() {
+		w.auditLogger.LogRegistryWrite(query.RootKey, query.Path, query.ValueName, previous.Display(), target.Display(), dryRun, err)
+	}
+}
+
+// auditRollback records a planned or attempted rollback in the audit log
+func (w *RegistryWriter) auditRollback(entry SnapshotEntry, dryRun bool, err error) {
+	if w.auditLogger != nil && w.auditLogger.IsEnabled() {
+		w.auditLogger.LogRegistryRollback(entry.RootKey, entry.Path, entry.ValueName, entry.Previous.Display(), dryRun, err)
+	}
+}
+
+// restoreEntry undoes a single remediation recorded in a validated entry
+func restoreEntry(entry SnapshotEntry) error {
+	rootKey, err := ParseRootKey(entry.RootKey)
+	if err != nil {
+		return err
+	}
+
+	if entry.Previous != nil {
+		return writeValue(rootKey, entry.Path, entry.ValueName, entry.Previous)
+	}
+
+	key, err := registry.OpenKey(rootKey, entry.Path, registry.SET_VALUE)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	err = key.DeleteValue(entry.ValueName)
+	key.Close()
+	if err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return err
+	}
+
+	if !entry.KeyExisted {
+		// Fails harmlessly when something else has since been stored in the key
+		registry.DeleteKey(rootKey, entry.Path)
+	}
+	return nil
+}
+
+// RemediationTarget returns the value a remediate query writes: WriteValue
+// when set, otherwise the expected value without any "(description)" suffix.
+// Queries with an expected_operator other than equals must set WriteValue.
+// The type comes from WriteType, defaulting to dword for numbers and string
+// for anything else.
+func RemediationTarget(query RegistryQuery) (*RegistryValue, error) {
+	raw := query.WriteValue
+	if raw == nil {
+		switch strings.ToLower(query.ExpectedOperator) {
+		case "", api.OperatorEquals:
+		default:
+			return nil, fmt.Errorf("query %q compares with %s; set write_value to the value to remediate to", query.Name, query.ExpectedOperator)
+		}
+
+		expected := strings.TrimSpace(query.ExpectedValue)
+		if idx := strings.Index(expected, " ("); idx > 0 {
+			expected = expected[:idx]
+		}
+		if expected == "" {
+			return nil, fmt.Errorf("query %q has no write_value or expected_value to remediate to", query.Name)
+		}
+		raw = expected
+	}
+
+	valueType := strings.ToLower(query.WriteType)
+	if valueType == "" {
+		valueType = RegTypeString
+		switch v := raw.(type) {
+		case float64:
+			valueType = RegTypeDWord
+		case string:
+			if _, err := strconv.ParseUint(v, 10, 32); err == nil {
+				valueType = RegTypeDWord
+			}
+		}
+	}
+
+	value := &RegistryValue{Type: valueType}
+	switch valueType {
+	case RegTypeString, RegTypeExpandString:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("query %q: %s requires a string value", query.Name, valueType)
+		}
+		value.String = s
+	case RegTypeMultiString:
+		switch v := raw.(type) {
+		case string:
+			value.Strings = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("query %q: %s requires a list of strings", query.Name, valueType)
+				}
+				value.Strings = append(value.Strings, s)
+			}
+		default:
+			return nil, fmt.Errorf("query %q: %s requires a list of strings", query.Name, valueType)
+		}
+	case RegTypeDWord, RegTypeQWord:
+		bits := 32
+		if valueType == RegTypeQWord {
+			bits = 64
+		}
+		var n uint64
+		var err error
+		switch v := raw.(type) {
+		case float64:
+			if v < 0 || v != float64(uint64(v)) {
+				err = fmt.Errorf("%v is not a non-negative integer", v)
+			}
+			n = uint64(v)
+		case string:
+			n, err = strconv.ParseUint(strings.TrimSpace(v), 0, bits)
+		default:
+			err = fmt.Errorf("unsupported value %v", v)
+		}
+		if err == nil && bits == 32 && n > 0xFFFFFFFF {
+			err = fmt.Errorf("%d does not fit in a DWORD", n)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query %q: invalid %s: %w", query.Name, valueType, err)
+		}
+		value.Integer = n
+	case RegTypeBinary:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("query %q: %s requires a hex string", query.Name, valueType)
+		}
+		data, err := hex.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("query %q: invalid %s: %w", query.Name, valueType, err)
+		}
+		value.Binary = data
+	default:
+		return nil, fmt.Errorf("query %q: unsupported write_type %q", query.Name, query.WriteType)
+	}
+
+	return value, nil
+}
+
+// captureValue reads the current type and data of a value. It reports
+// whether the key exists and returns a nil value when the value does not.
+func captureValue(rootKey registry.Key, path, valueName string) (bool, *RegistryValue, error) {
+	key, err := registry.OpenKey(rootKey, path, registry.QUERY_VALUE)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	defer key.Close()
+
+	size, valType, err := key.GetValue(valueName, nil)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return true, nil, nil
+		}
+		return true, nil, err
+	}
+
+	value := &RegistryValue{}
+	switch valType {
+	case registry.SZ, registry.EXPAND_SZ:
+		value.Type = RegTypeString
+		if valType == registry.EXPAND_SZ {
+			value.Type = RegTypeExpandString
+		}
+		value.String, _, err = key.GetStringValue(valueName)
+	case registry.MULTI_SZ:
+		value.Type = RegTypeMultiString
+		value.Strings, _, err = key.GetStringsValue(valueName)
+	case registry.DWORD:
+		value.Type = RegTypeDWord
+		value.Integer, _, err = key.GetIntegerValue(valueName)
+	case registry.QWORD:
+		value.Type = RegTypeQWord
+		value.Integer, _, err = key.GetIntegerValue(valueName)
+	case registry.BINARY:
+		value.Type = RegTypeBinary
+		value.Binary, _, err = key.GetBinaryValue(valueName)
+	default:
+		// Anything else is restored byte for byte, with its own type
+		value.Type = regTypeRaw
+		value.RawType = valType
+		value.Binary = make([]byte, size)
+		var n int
+		n, _, err = key.GetValue(valueName, value.Binary)
+		value.Binary = value.Binary[:min(n, size)]
+	}
+	if err != nil {
+		return true, nil, err
+	}
+	return true, value, nil
+}
+
+// writeValue sets a value, creating the key when it does not exist
+func writeValue(rootKey registry.Key, path, valueName string, value *RegistryValue) error {
+	key, _, err := registry.CreateKey(rootKey, path, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	switch value.Type {
+	case RegTypeString:
+		return key.SetStringValue(valueName, value.String)
+	case RegTypeExpandString:
+		return key.SetExpandStringValue(valueName, value.String)
+	case RegTypeMultiString:
+		return key.SetStringsValue(valueName, value.Strings)
+	case RegTypeDWord:
+		return key.SetDWordValue(valueName, uint32(value.Integer))
+	case RegTypeQWord:
+		return key.SetQWordValue(valueName, value.Integer)
+	case RegTypeBinary:
+		return key.SetBinaryValue(valueName, value.Binary)
+	case regTypeRaw:
+		return setRawValue(key, valueName, value.RawType, value.Binary)
+	default:
+		return fmt.Errorf("unsupported value type %q", value.Type)
+	}
+}
+
+var procRegSetValueExW = windows.NewLazySystemDLL("advapi32.dll").NewProc("RegSetValueExW")
+
+// setRawValue sets a value of any registry type from its bytes; the
+// registry package only sets the types it knows
+func setRawValue(key registry.Key, valueName string, valType uint32, data []byte) error {
+	name, err := windows.UTF16PtrFromString(valueName)
+	if err != nil {
+		return err
+	}
+	var buf *byte
+	if len(data) > 0 {
+		buf = &data[0]
+	}
+	ret, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(name)), 0,
+		uintptr(valType), uintptr(unsafe.Pointer(buf)), uintptr(len(data)))
+	if ret != 0 {
+		return windows.Errno(ret)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func TestRemediationTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   RegistryQuery
+		want    *RegistryValue
+		wantErr bool
+	}{
+		{
+			name:  "numeric expected value defaults to dword",
+			query: RegistryQuery{Name: "q", ExpectedValue: "1"},
+			want:  &RegistryValue{Type: RegTypeDWord, Integer: 1},
+		},
+		{
+			name:  "description suffix is stripped",
+			query: RegistryQuery{Name: "q", ExpectedValue: "2 (Require signing)"},
+			want:  &RegistryValue{Type: RegTypeDWord, Integer: 2},
+		},
+		{
+			name:  "text expected value defaults to string",
+			query: RegistryQuery{Name: "q", ExpectedValue: "Enabled"},
+			want:  &RegistryValue{Type: RegTypeString, String: "Enabled"},
+		},
+		{
+			name:  "write value overrides expected value",
+			query: RegistryQuery{Name: "q", ExpectedValue: "1", WriteValue: float64(5)},
+			want:  &RegistryValue{Type: RegTypeDWord, Integer: 5},
+		},
+		{
+			name:  "explicit string type keeps digits as text",
+			query: RegistryQuery{Name: "q", ExpectedValue: "10", WriteType: "string"},
+			want:  &RegistryValue{Type: RegTypeString, String: "10"},
+		},
+		{
+			name:  "multi string from list",
+			query: RegistryQuery{Name: "q", WriteType: "multi_string", WriteValue: []interface{}{"a", "b"}},
+			want:  &RegistryValue{Type: RegTypeMultiString, Strings: []string{"a", "b"}},
+		},
+		{
+			name:  "qword",
+			query: RegistryQuery{Name: "q", WriteType: "qword", WriteValue: "4294967296"},
+			want:  &RegistryValue{Type: RegTypeQWord, Integer: 4294967296},
+		},
+		{
+			name:  "binary from hex",
+			query: RegistryQuery{Name: "q", WriteType: "binary", WriteValue: "0a0b"},
+			want:  &RegistryValue{Type: RegTypeBinary, Binary: []byte{0x0a, 0x0b}},
+		},
+		{
+			name:    "dword out of range",
+			query:   RegistryQuery{Name: "q", WriteType: "dword", WriteValue: "4294967296"},
+			wantErr: true,
+		},
+		{
+			name:    "negative dword",
+			query:   RegistryQuery{Name: "q", WriteType: "dword", WriteValue: float64(-1)},
+			wantErr: true,
+		},
+		{
+			name:    "invalid hex",
+			query:   RegistryQuery{Name: "q", WriteType: "binary", WriteValue: "zz"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			query:   RegistryQuery{Name: "q", WriteType: "link", WriteValue: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "range comparison needs a write value",
+			query:   RegistryQuery{Name: "q", ExpectedValue: "14", ExpectedOperator: "gte"},
+			wantErr: true,
+		},
+		{
+			name:  "range comparison with write value",
+			query: RegistryQuery{Name: "q", ExpectedValue: "14", ExpectedOperator: "gte", WriteValue: float64(14)},
+			want:  &RegistryValue{Type: RegTypeDWord, Integer: 14},
+		},
+		{
+			name:    "nothing to write",
+			query:   RegistryQuery{Name: "q"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemediationTarget(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemediationTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RemediationTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryValue_Display(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *RegistryValue
+		want  string
+	}{
+		{"missing", nil, "not found"},
+		{"string", &RegistryValue{Type: RegTypeString, String: "x"}, "x"},
+		{"multi string", &RegistryValue{Type: RegTypeMultiString, Strings: []string{"a", "b"}}, "a, b"},
+		{"dword", &RegistryValue{Type: RegTypeDWord, Integer: 7}, "7"},
+		{"binary", &RegistryValue{Type: RegTypeBinary, Binary: []byte{0xff}}, "ff"},
+		{"raw", &RegistryValue{Type: regTypeRaw, RawType: registry.LINK, Binary: []byte{0x5c, 0x00}}, "5c00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.value.Display(); got != tt.want {
+				t.Errorf("Display() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rollback.json")
+	snapshot := NewRollbackSnapshot(path, "NIST")
+
+	entry := SnapshotEntry{
+		CheckName:  "UAC",
+		RootKey:    "HKLM",
+		Path:       "SOFTWARE\\Test",
+		ValueName:  "EnableLUA",
+		KeyExisted: true,
+		Applied:    &RegistryValue{Type: RegTypeDWord, Integer: 1},
+	}
+	if err := snapshot.record(entry); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+
+	loaded, err := LoadRollbackSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadRollbackSnapshot() error = %v", err)
+	}
+	if loaded.Path() != path || loaded.ReportType != "NIST" || len(loaded.Entries) != 1 {
+		t.Fatalf("loaded snapshot = %+v", loaded)
+	}
+	if got := loaded.Entries[0]; got.Previous != nil || got.Applied.Integer != 1 || !got.KeyExisted {
+		t.Errorf("loaded entry = %+v, want missing previous value and applied 1", got)
+	}
+
+	// Raw values keep their registry type, REG_NONE included
+	for _, valType := range []uint32{registry.NONE, registry.LINK} {
+		entry.Previous = &RegistryValue{Type: regTypeRaw, RawType: valType, Binary: []byte{1, 2}}
+		if err := snapshot.record(entry); err != nil {
+			t.Fatalf("record() error = %v", err)
+		}
+		loaded, err := LoadRollbackSnapshot(path)
+		if err != nil {
+			t.Fatalf("LoadRollbackSnapshot() error = %v", err)
+		}
+		got := loaded.Entries[len(loaded.Entries)-1].Previous
+		if got == nil || got.Type != regTypeRaw || got.RawType != valType || string(got.Binary) != "\x01\x02" {
+			t.Errorf("loaded previous value = %+v, want raw type %d", got, valType)
+		}
+	}
+}
+
+func TestRegistryWriter_Guards(t *testing.T) {
+	query := RegistryQuery{
+		Name:          "UAC",
+		RootKey:       "HKLM",
+		Path:          "SOFTWARE\\Test",
+		ValueName:     "EnableLUA",
+		Operation:     "read",
+		ExpectedValue: "1",
+	}
+
+	writer := NewRegistryWriter()
+	if _, err := writer.Remediate(context.Background(), query, nil); err == nil {
+		t.Error("Remediate() accepted a read query")
+	}
+
+	if err := writer.Rollback(context.Background(), &RollbackSnapshot{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Rollback() error = %v, want ErrReadOnly", err)
+	}
+
+	// Entries of an edited snapshot are validated, in dry runs too
+	snapshot := &RollbackSnapshot{Entries: []SnapshotEntry{
+		{CheckName: "UAC", RootKey: "HKLM", Path: "\\SOFTWARE\\Test", ValueName: "EnableLUA"},
+	}}
+	if err := NewRegistryWriter(WithDryRun(true)).Rollback(context.Background(), snapshot); err == nil {
+		t.Error("Rollback() accepted an entry with an invalid path")
+	}
+}
+
+func TestSnapshotEntry_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   SnapshotEntry
+		wantErr bool
+	}{
+		{"valid", SnapshotEntry{RootKey: "HKLM", Path: "SOFTWARE\\Test", ValueName: "EnableLUA"}, false},
+		{"default value", SnapshotEntry{RootKey: "HKLM", Path: "SOFTWARE\\Test"}, false},
+		{"bad root key", SnapshotEntry{RootKey: "HKEY_NOWHERE", Path: "SOFTWARE\\Test", ValueName: "EnableLUA"}, true},
+		{"leading backslash", SnapshotEntry{RootKey: "HKLM", Path: "\\SOFTWARE\\Test", ValueName: "EnableLUA"}, true},
+		{"control character in path", SnapshotEntry{RootKey: "HKLM", Path: "SOFTWARE\\Te\x00st", ValueName: "EnableLUA"}, true},
+		{"control character in value name", SnapshotEntry{RootKey: "HKLM", Path: "SOFTWARE\\Test", ValueName: "Enable\nLUA"}, true},
+		{"empty path", SnapshotEntry{RootKey: "HKLM", ValueName: "EnableLUA"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.entry.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
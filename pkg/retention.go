@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Legal hold markers. A LegalHoldMarker file in a directory holds every file
+// in it; a file named "<name>.hold" holds the file <name>. Held files and the
+// markers themselves are never deleted by retention cleanup.
+const (
+	LegalHoldMarker    = ".legal_hold"
+	LegalHoldExtension = ".hold"
+)
+
+// Enabled reports whether any retention limit is set
+func (rc RetentionConfig) Enabled() bool {
+	return rc.MaxAgeDays > 0 || rc.MaxFiles > 0 || rc.MaxSizeMB > 0
+}
+
+// Validate checks that no limit is negative
+func (rc RetentionConfig) Validate() error {
+	if rc.MaxAgeDays < 0 {
+		return fmt.Errorf("max_age_days must be >= 0 (got %d)", rc.MaxAgeDays)
+	}
+	if rc.MaxFiles < 0 {
+		return fmt.Errorf("max_files must be >= 0 (got %d)", rc.MaxFiles)
+	}
+	if rc.MaxSizeMB < 0 {
+		return fmt.Errorf("max_size_mb must be >= 0 (got %d)", rc.MaxSizeMB)
+	}
+	return nil
+}
+
+// CleanupResult describes what a retention pass removed from one directory
+type CleanupResult struct {
+	Dir        string
+	Deleted    []string // Files deleted, or that would be deleted in a dry run
+	Held       []string // Files kept only because of a legal hold
+	FreedBytes int64
+	Errors     []error
+}
+
+// retainedFile is a candidate for retention cleanup
+type retainedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	held    bool
+}
+
+// ApplyRetention deletes files in dir that exceed the retention limits,
+// oldest first. Subdirectories are left alone. Held files never count
+// against the limits. With dryRun nothing is deleted.
+func ApplyRetention(dir string, rc RetentionConfig, now time.Time, dryRun bool) (*CleanupResult, error) {
+	result := &CleanupResult{Dir: dir}
+	if !rc.Enabled() {
+		return result, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	dirHeld := names[LegalHoldMarker]
+
+	var files []retainedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == LegalHoldMarker || strings.HasSuffix(name, LegalHoldExtension) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since ReadDir
+		}
+		files = append(files, retainedFile{
+			path:    filepath.Join(dir, name),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			held:    dirHeld || names[name+LegalHoldExtension],
+		})
+	}
+
+	// Newest first, so the files kept are the most recent
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	cutoff := now.AddDate(0, 0, -rc.MaxAgeDays)
+	maxBytes := int64(rc.MaxSizeMB) << 20
+
+	var kept int
+	var keptBytes int64
+	for _, f := range files {
+		expired := (rc.MaxAgeDays > 0 && f.modTime.Before(cutoff)) ||
+			(rc.MaxFiles > 0 && kept >= rc.MaxFiles) ||
+			(rc.MaxSizeMB > 0 && keptBytes+f.size > maxBytes)
+
+		if !expired {
+			kept++
+			keptBytes += f.size
+			continue
+		}
+		if f.held {
+			result.Held = append(result.Held, f.path)
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(f.path); err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+		}
+		result.Deleted = append(result.Deleted, f.path)
+		result.FreedBytes += f.size
+	}
+
+	return result, nil
+}
+
+// CleanupOutputDirs applies retention to each directory and logs what was
+// removed
+func CleanupOutputDirs(dirs []string, rc RetentionConfig, dryRun bool, logger *slog.Logger) ([]*CleanupResult, error) {
+	now := time.Now()
+	results := make([]*CleanupResult, 0, len(dirs))
+	for _, dir := range dirs {
+		result, err := ApplyRetention(dir, rc, now, dryRun)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+
+		for _, path := range result.Deleted {
+			logger.Info("Retention removed output file", "path", path, "dry_run", dryRun)
+		}
+		for _, err := range result.Errors {
+			logger.Warn("Retention could not remove output file", "error", err)
+		}
+		if len(result.Held) > 0 {
+			logger.Info("Retention kept files under legal hold", "dir", dir, "count", len(result.Held))
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// Files are a day apart: report0 is newest, report4 oldest
+	setup := func(t *testing.T, markers ...string) string {
+		dir := t.TempDir()
+		for i := 0; i < 5; i++ {
+			path := filepath.Join(dir, "report"+string(rune('0'+i))+".html")
+			if err := os.WriteFile(path, make([]byte, 1<<20), 0644); err != nil {
+				t.Fatal(err)
+			}
+			modTime := now.AddDate(0, 0, -i)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, marker := range markers {
+			if err := os.WriteFile(filepath.Join(dir, marker), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Mkdir(filepath.Join(dir, "archive"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name        string
+		retention   RetentionConfig
+		markers     []string
+		wantDeleted []string
+		wantHeld    []string
+	}{
+		{
+			name:      "no limits",
+			retention: RetentionConfig{},
+		},
+		{
+			name:        "max age",
+			retention:   RetentionConfig{MaxAgeDays: 2},
+			wantDeleted: []string{"report3.html", "report4.html"},
+		},
+		{
+			name:        "max files",
+			retention:   RetentionConfig{MaxFiles: 2},
+			wantDeleted: []string{"report2.html", "report3.html", "report4.html"},
+		},
+		{
+			name:        "max size",
+			retention:   RetentionConfig{MaxSizeMB: 4},
+			wantDeleted: []string{"report4.html"},
+		},
+		{
+			name:        "file hold",
+			retention:   RetentionConfig{MaxFiles: 2},
+			markers:     []string{"report3.html" + LegalHoldExtension},
+			wantDeleted: []string{"report2.html", "report4.html"},
+			wantHeld:    []string{"report3.html"},
+		},
+		{
+			name:      "directory hold",
+			retention: RetentionConfig{MaxAgeDays: 1},
+			markers:   []string{LegalHoldMarker},
+			wantHeld:  []string{"report2.html", "report3.html", "report4.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setup(t, tt.markers...)
+
+			result, err := ApplyRetention(dir, tt.retention, now, false)
+			if err != nil {
+				t.Fatalf("ApplyRetention() error = %v", err)
+			}
+
+			if got := baseNames(result.Deleted); !equalStrings(got, tt.wantDeleted) {
+				t.Errorf("Deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := baseNames(result.Held); !equalStrings(got, tt.wantHeld) {
+				t.Errorf("Held = %v, want %v", got, tt.wantHeld)
+			}
+			if want := int64(len(tt.wantDeleted)) << 20; result.FreedBytes != want {
+				t.Errorf("FreedBytes = %d, want %d", result.FreedBytes, want)
+			}
+
+			for _, name := range tt.wantDeleted {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s still exists", name)
+				}
+			}
+			for _, marker := range append(tt.markers, "archive") {
+				if _, err := os.Stat(filepath.Join(dir, marker)); err != nil {
+					t.Errorf("%s was removed: %v", marker, err)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRetention_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().AddDate(0, 0, -30)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	results, err := CleanupOutputDirs([]string{dir, filepath.Join(dir, "missing")}, RetentionConfig{MaxAgeDays: 7}, true, logger)
+	if err != nil {
+		t.Fatalf("CleanupOutputDirs() error = %v", err)
+	}
+	if len(results) != 2 || len(results[0].Deleted) != 1 || len(results[1].Deleted) != 0 {
+		t.Fatalf("results = %+v, want one file listed in the first directory", results)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("dry run removed %s: %v", path, err)
+	}
+}
+
+func TestRetentionConfig_Validate(t *testing.T) {
+	if err := (RetentionConfig{MaxAgeDays: 30, MaxFiles: 10}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, rc := range []RetentionConfig{{MaxAgeDays: -1}, {MaxFiles: -1}, {MaxSizeMB: -1}} {
+		if err := rc.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted a negative limit", rc)
+		}
+	}
+}
+
+func baseNames(paths []string) []string {
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
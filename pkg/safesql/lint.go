@@ -0,0 +1,148 @@
+package safesql
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sqlMethods are the database/sql methods that take SQL text
+var sqlMethods = map[string]bool{
+	"Query": true, "QueryContext": true,
+	"QueryRow": true, "QueryRowContext": true,
+	"Exec": true, "ExecContext": true,
+	"Prepare": true, "PrepareContext": true,
+}
+
+// sqlText matches format strings that look like SQL, written in capitals as
+// this repository does: they start with a statement keyword, or hold one of
+// the clauses every statement has
+var sqlText = regexp.MustCompile(`(?s)^\s*(SELECT|INSERT|UPDATE|DELETE|WITH|CREATE|ALTER|DROP|TRUNCATE)\b|\b(SELECT\s.+\sFROM|INSERT\s+INTO|UPDATE\s.+\sSET|DELETE\s+FROM)\b`)
+
+// Finding is a place where SQL is not built with this package
+type Finding struct {
+	Pos     token.Position
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Pos, f.Message)
+}
+
+// Check type-checks the package in dir, without its tests, and reports
+//   - calls of the database/sql methods that take SQL text (Query, Exec,
+//     Prepare and their Context forms) on *sql.DB, *sql.Tx, *sql.Conn or
+//     *sql.Stmt, which bypass Query values
+//   - SQL formatted with fmt (Sprintf, Sprint, Fprintf, ...), which is how
+//     input ends up in statements
+//
+// It is a lint rule run from tests; see this package's documentation.
+func Check(dir string) ([]Finding, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := config.Check(pkg.ImportPath, fset, files, info); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if message := checkCall(info, selector, call); message != "" {
+				findings = append(findings, Finding{Pos: fset.Position(call.Pos()), Message: message})
+			}
+			return true
+		})
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i].Pos, findings[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Line < b.Line
+	})
+	return findings, nil
+}
+
+// checkCall returns what is wrong with a call, or "" when nothing is
+func checkCall(info *types.Info, selector *ast.SelectorExpr, call *ast.CallExpr) string {
+	// A method of database/sql called on a value (including through an
+	// embedded field)
+	if selection, ok := info.Selections[selector]; ok && sqlMethods[selector.Sel.Name] {
+		if owner := sqlOwner(selection.Obj()); owner != "" {
+			return fmt.Sprintf("(*sql.%s).%s runs SQL text; use a safesql.Query", owner, selector.Sel.Name)
+		}
+		return ""
+	}
+
+	// fmt formatting SQL
+	function, ok := info.Uses[selector.Sel].(*types.Func)
+	if !ok || function.Pkg() == nil || function.Pkg().Path() != "fmt" || len(call.Args) == 0 {
+		return ""
+	}
+	for _, arg := range call.Args {
+		value := info.Types[arg].Value
+		if value == nil {
+			continue
+		}
+		text, err := strconv.Unquote(value.ExactString())
+		if err == nil && sqlText.MatchString(text) {
+			return fmt.Sprintf("fmt.%s builds SQL; use safesql.New and Append", function.Name())
+		}
+	}
+	return ""
+}
+
+// sqlOwner returns the database/sql type declaring method, or ""
+func sqlOwner(method types.Object) string {
+	if method.Pkg() == nil || method.Pkg().Path() != "database/sql" {
+		return ""
+	}
+	signature, ok := method.Type().(*types.Signature)
+	if !ok || signature.Recv() == nil {
+		return ""
+	}
+	recv := signature.Recv().Type()
+	if pointer, ok := recv.(*types.Pointer); ok {
+		recv = pointer.Elem()
+	}
+	if named, ok := recv.(*types.Named); ok {
+		return strings.TrimPrefix(named.Obj().Name(), "sql.")
+	}
+	return ""
+}
@@ -0,0 +1,260 @@
+// Package safesql runs SQL statements whose text can only come from
+// constants in the source, with every value passed as a parameter, so
+// input can never become part of a statement.
+//
+// Statements are Query values. New and Append take the text as a constant
+// (a literal, or a concatenation of constants); a string variable does not
+// compile. Fragments are combined with Append and AppendQuery, which
+// renumber the PostgreSQL placeholders ($1, $2, ...) of each fragment to
+// follow those before it, so every fragment numbers its own parameters from
+// $1. The rare text that cannot be a parameter goes through Identifier,
+// Literal and ReadFile, which quote it or read it from a file.
+//
+// Check (lint.go) reports code that reaches database/sql directly or builds
+// SQL with fmt; packages that use a database run it in a test.
+package safesql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// constant is the type of SQL text. Untyped string constants convert to it
+// implicitly; string variables do not, and code outside this package cannot
+// convert them, so the text of a Query comes from the source.
+type constant string
+
+// Query is SQL text and the values of its placeholders
+type Query struct {
+	text string
+	args []any
+}
+
+// New returns a statement, or a fragment of one, with args as the values
+// of $1, $2, ...
+func New(text constant, args ...any) Query {
+	checkPlaceholders(string(text), len(args))
+	return Query{text: string(text), args: args}
+}
+
+// Append returns q followed by text, whose placeholders $1, $2, ... stand
+// for args and are renumbered to follow q's
+func (q Query) Append(text constant, args ...any) Query {
+	return q.AppendQuery(New(text, args...))
+}
+
+// AppendQuery returns q followed by p, p's placeholders renumbered to
+// follow q's
+func (q Query) AppendQuery(p Query) Query {
+	args := make([]any, 0, len(q.args)+len(p.args))
+	args = append(append(args, q.args...), p.args...)
+	return Query{text: q.text + renumber(p.text, len(q.args)), args: args}
+}
+
+// Join returns the queries separated by sep, their placeholders renumbered
+// in turn
+func Join(queries []Query, sep constant) Query {
+	var joined Query
+	for i, q := range queries {
+		if i > 0 {
+			joined = joined.Append(sep)
+		}
+		joined = joined.AppendQuery(q)
+	}
+	return joined
+}
+
+// Identifier returns name, such as a table name computed from a date,
+// quoted as a PostgreSQL identifier
+func Identifier(name string) Query {
+	name = strings.ReplaceAll(name, "\x00", "")
+	return Query{text: `"` + strings.ReplaceAll(name, `"`, `""`) + `"`}
+}
+
+// Literal returns value quoted as a PostgreSQL string literal, for the
+// statements (DDL) that take no parameters
+func Literal(value string) Query {
+	value = strings.ReplaceAll(value, "\x00", "")
+	quoted := "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	if strings.Contains(value, `\`) {
+		quoted = "E" + strings.ReplaceAll(quoted, `\`, `\\`)
+	}
+	return Query{text: quoted}
+}
+
+// ReadFile returns the script in the file name of fsys, such as an embedded
+// migration. Scripts take no parameters.
+func ReadFile(fsys fs.FS, name string) (Query, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Query{}, err
+	}
+	return Query{text: string(data)}, nil
+}
+
+// String returns the text of the statement
+func (q Query) String() string {
+	return q.text
+}
+
+// Args returns the values of the placeholders
+func (q Query) Args() []any {
+	return q.args
+}
+
+// Querier runs statements: *sql.DB, *sql.Tx and *sql.Conn
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// QueryContext runs a query returning rows
+func QueryContext(ctx context.Context, db Querier, q Query) (*sql.Rows, error) {
+	return db.QueryContext(ctx, q.text, q.args...)
+}
+
+// QueryRowContext runs a query returning at most one row
+func QueryRowContext(ctx context.Context, db Querier, q Query) *sql.Row {
+	return db.QueryRowContext(ctx, q.text, q.args...)
+}
+
+// ExecContext runs a statement returning no rows
+func ExecContext(ctx context.Context, db Querier, q Query) (sql.Result, error) {
+	return db.ExecContext(ctx, q.text, q.args...)
+}
+
+// Tx is a transaction that runs Query values. Its statements run under the
+// context it was started with.
+type Tx struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+// BeginTx starts a transaction, rolled back if ctx is done before it
+// commits
+func BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{ctx: ctx, tx: tx}, nil
+}
+
+// Query runs a query returning rows
+func (t *Tx) Query(q Query) (*sql.Rows, error) {
+	return t.tx.QueryContext(t.ctx, q.text, q.args...)
+}
+
+// QueryRow runs a query returning at most one row
+func (t *Tx) QueryRow(q Query) *sql.Row {
+	return t.tx.QueryRowContext(t.ctx, q.text, q.args...)
+}
+
+// Exec runs a statement returning no rows
+func (t *Tx) Exec(q Query) (sql.Result, error) {
+	return t.tx.ExecContext(t.ctx, q.text, q.args...)
+}
+
+// Commit commits the transaction
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction; after Commit it does nothing and
+// returns sql.ErrTxDone
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// renumber adds offset to the number of every placeholder in text
+func renumber(text string, offset int) string {
+	if offset == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, p := range placeholders(text) {
+		n, _ := strconv.Atoi(text[p.start+1 : p.end])
+		b.WriteString(text[last:p.start])
+		b.WriteString("$" + strconv.Itoa(n+offset))
+		last = p.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// checkPlaceholders panics when text uses a placeholder it has no value
+// for; the numbering of appended fragments would silently go wrong
+func checkPlaceholders(text string, args int) {
+	for _, p := range placeholders(text) {
+		if n, _ := strconv.Atoi(text[p.start+1 : p.end]); n < 1 || n > args {
+			panic(fmt.Sprintf("safesql: %s in %q has no value (%d arguments)", text[p.start:p.end], text, args))
+		}
+	}
+}
+
+// span is the position of a placeholder in text
+type span struct{ start, end int }
+
+// placeholders returns the positions of the placeholders in text, skipping
+// string literals, quoted identifiers, dollar-quoted strings and comments
+func placeholders(text string) []span {
+	var spans []span
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\'' || text[i] == '"':
+			// Doubled quotes inside are an empty pair followed by more text,
+			// which this loop skips the same way
+			end := strings.IndexByte(text[i+1:], text[i])
+			if end < 0 {
+				return spans
+			}
+			i += end + 1
+		case strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return spans
+			}
+			i += end
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return spans
+			}
+			i += end + 3
+		case text[i] == '$':
+			j := i + 1
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				spans = append(spans, span{i, j})
+				i = j - 1
+				continue
+			}
+			// A dollar-quoted string: $tag$ ... $tag$
+			for j < len(text) && (text[j] == '_' || isAlphanumeric(text[j])) {
+				j++
+			}
+			if j < len(text) && text[j] == '$' {
+				tag := text[i : j+1]
+				end := strings.Index(text[j+1:], tag)
+				if end < 0 {
+					return spans
+				}
+				i = j + end + len(tag)
+			}
+		}
+	}
+	return spans
+}
+
+// isAlphanumeric reports whether c is an ASCII letter or digit
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
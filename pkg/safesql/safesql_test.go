@@ -0,0 +1,124 @@
+package safesql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestAppend tests that appended fragments have their placeholders
+// renumbered, outside literals, quoted identifiers and comments
+func TestAppend(t *testing.T) {
+	scope := New(`client_id IN (SELECT client_id FROM client_tags WHERE tag = ANY($1)) AND org = $2`, "tags", "org")
+	q := New(`SELECT id FROM submissions WHERE timestamp >= $1 AND `, "since").
+		AppendQuery(scope).
+		Append(` AND note != '$1' AND "$2" = $1 -- $1
+		AND status = $2 /* $1 */ AND body = $tag$ $1 $tag$`, "id", "compliant")
+
+	wantText := `SELECT id FROM submissions WHERE timestamp >= $1 AND client_id IN (SELECT client_id FROM client_tags WHERE tag = ANY($2)) AND org = $3 AND note != '$1' AND "$2" = $4 -- $1
+		AND status = $5 /* $1 */ AND body = $tag$ $1 $tag$`
+	if q.String() != wantText {
+		t.Errorf("text =\n%s\nwant\n%s", q, wantText)
+	}
+	wantArgs := []any{"since", "tags", "org", "id", "compliant"}
+	if !reflect.DeepEqual(q.Args(), wantArgs) {
+		t.Errorf("args = %v, want %v", q.Args(), wantArgs)
+	}
+}
+
+// TestJoin tests joining fragments that each number from $1
+func TestJoin(t *testing.T) {
+	q := Join([]Query{New(`a = $1`, 1), New(`b = $1 OR c = $1`, 2), New(`d`)}, " AND ")
+	if q.String() != `a = $1 AND b = $2 OR c = $2 AND d` || !reflect.DeepEqual(q.Args(), []any{1, 2}) {
+		t.Errorf("Join() = %q %v", q, q.Args())
+	}
+	if q := Join(nil, ","); q.String() != "" || len(q.Args()) != 0 {
+		t.Errorf("Join(nil) = %q %v, want empty", q, q.Args())
+	}
+}
+
+// TestNewMissingArgument tests that a placeholder without a value panics
+func TestNewMissingArgument(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	New(`SELECT 1 WHERE a = $1 AND b = $2`, "a")
+}
+
+// TestQuoting tests the quoting of the text that cannot be a parameter
+func TestQuoting(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{Identifier("submissions_2026_10").String(), `"submissions_2026_10"`},
+		{Identifier(`x"; DROP TABLE users; --`).String(), `"x""; DROP TABLE users; --"`},
+		{Literal("2026-10-01").String(), `'2026-10-01'`},
+		{Literal(`it's`).String(), `'it''s'`},
+		{Literal(`a\'b`).String(), `E'a\\''b'`},
+		{Literal("nul\x00").String(), `'nul'`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %s, want %s", tt.got, tt.want)
+		}
+	}
+}
+
+// TestReadFile tests reading a script
+func TestReadFile(t *testing.T) {
+	fsys := fstest.MapFS{"migrations/0001_init.up.sql": {Data: []byte("CREATE TABLE t (id INT);")}}
+	q, err := ReadFile(fsys, "migrations/0001_init.up.sql")
+	if err != nil || q.String() != "CREATE TABLE t (id INT);" {
+		t.Errorf("ReadFile() = %q, %v", q, err)
+	}
+	if _, err := ReadFile(fsys, "missing.sql"); err == nil {
+		t.Error("ReadFile() of a missing file returned no error")
+	}
+}
+
+// TestCheck tests the lint rule on a package that builds SQL both ways
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	source := `package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type store struct{ *sql.DB }
+
+func (s store) find(db *sql.DB, name string) {
+	db.Query("SELECT id FROM users WHERE name = '" + name + "'")
+	s.Exec("DELETE FROM users")
+	query := fmt.Sprintf("SELECT id FROM %s WHERE name = $1", name)
+	_ = query
+	_ = fmt.Sprintf("user %s not found", name)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "store.go"), []byte(source), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := Check(dir)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, strings.TrimPrefix(f.String(), dir+string(filepath.Separator)))
+	}
+	want := []string{
+		"store.go:11:2: (*sql.DB).Query runs SQL text; use a safesql.Query",
+		"store.go:12:2: (*sql.DB).Exec runs SQL text; use a safesql.Query",
+		"store.go:13:11: fmt.Sprintf builds SQL; use safesql.New and Append",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,221 @@
+// Package schema checks report configurations against the JSON schema
+// embedded in the toolkit (report.schema.json), so a configuration that
+// arrives from elsewhere, such as a policy downloaded from the server, is
+// refused for its shape before any of it is used.
+//
+// Only the keywords report.schema.json uses are supported: type, required,
+// properties, additionalProperties (true or false), items, enum, minLength,
+// minItems, and if, then and else.
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReportSchema is the JSON schema of a report configuration
+//
+//go:embed report.schema.json
+var ReportSchema []byte
+
+// node is a schema, or a subschema, decoded from JSON
+type node struct {
+	Type                 string           `json:"type"`
+	Required             []string         `json:"required"`
+	Properties           map[string]*node `json:"properties"`
+	AdditionalProperties *bool            `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	Enum                 []interface{}    `json:"enum"`
+	MinLength            int              `json:"minLength"`
+	MinItems             int              `json:"minItems"`
+
+	// If selects Then for a value matching it and Else otherwise, e.g.
+	// the properties required by a query's operation
+	If   *node `json:"if"`
+	Then *node `json:"then"`
+	Else *node `json:"else"`
+}
+
+var reportSchema = mustParse(ReportSchema)
+
+// mustParse decodes an embedded schema; a broken one is a build mistake
+func mustParse(data []byte) *node {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Enum numbers compare with the document's
+	var n node
+	if err := decoder.Decode(&n); err != nil {
+		panic(fmt.Sprintf("schema: invalid embedded schema: %v", err))
+	}
+	return &n
+}
+
+// Error is a document's first departure from the schema
+type Error struct {
+	Path    string // Location in the document, such as "queries[2].root_key"; empty for the document itself
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return "schema: " + e.Message
+	}
+	return fmt.Sprintf("schema: %s: %s", e.Path, e.Message)
+}
+
+// ValidateReport checks a report configuration against ReportSchema. It
+// returns an *Error for a document that does not match it, and the decoding
+// error for one that is not JSON.
+func ValidateReport(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("schema: document is not valid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("schema: document is not valid JSON: data after the end of the document")
+	}
+	return reportSchema.validate("", doc)
+}
+
+// validate checks v, found at path, against n
+func (n *node) validate(path string, v interface{}) error {
+	if n.Type != "" && !hasType(v, n.Type) {
+		return &Error{path, fmt.Sprintf("must be %s, got %s", article(n.Type), typeName(v))}
+	}
+	if len(n.Enum) > 0 && !inEnum(v, n.Enum) {
+		return &Error{path, fmt.Sprintf("must be one of %s", enumList(n.Enum))}
+	}
+
+	switch value := v.(type) {
+	case string:
+		if utf8.RuneCountInString(value) < n.MinLength {
+			if n.MinLength == 1 {
+				return &Error{path, "must not be empty"}
+			}
+			return &Error{path, fmt.Sprintf("must be at least %d characters", n.MinLength)}
+		}
+	case []interface{}:
+		if len(value) < n.MinItems {
+			return &Error{path, fmt.Sprintf("must have at least %d item(s)", n.MinItems)}
+		}
+		if n.Items != nil {
+			for i, item := range value {
+				if err := n.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range n.Required {
+			if _, ok := value[name]; !ok {
+				return &Error{path, fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, known := n.Properties[name]
+			if !known {
+				if n.AdditionalProperties != nil && !*n.AdditionalProperties {
+					return &Error{path, fmt.Sprintf("unknown property %q", name)}
+				}
+				continue
+			}
+			if err := property.validate(join(path, name), value[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if n.If != nil {
+		branch := n.Else
+		if n.If.validate(path, v) == nil {
+			branch = n.Then
+		}
+		if branch != nil {
+			return branch.validate(path, v)
+		}
+	}
+	return nil
+}
+
+// hasType reports whether v is of the JSON schema type t
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	}
+	return typeName(v) == t
+}
+
+// typeName returns the JSON schema type of a decoded value
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// article prefixes a type name for messages: "an object", "a string"
+func article(t string) string {
+	if strings.IndexAny(t[:1], "aeiou") == 0 {
+		return "an " + t
+	}
+	return "a " + t
+}
+
+// inEnum reports whether v equals one of the allowed values
+func inEnum(v interface{}, allowed []interface{}) bool {
+	for _, a := range allowed {
+		if fmt.Sprint(a) == fmt.Sprint(v) && typeName(a) == typeName(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumList renders allowed values for messages
+func enumList(allowed []interface{}) string {
+	values := make([]string, len(allowed))
+	for i, a := range allowed {
+		values[i] = fmt.Sprintf("%q", fmt.Sprint(a))
+	}
+	return strings.Join(values, ", ")
+}
+
+// join appends a property name to a path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
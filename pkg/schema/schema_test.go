@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestValidateReport tests documents that match and depart from the schema
+func TestValidateReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		wantPath string // Empty for a document that matches
+	}{
+		{"minimal", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read"}]}`, ""},
+		{"security and extra metadata", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1","owner":"sec"},
+			"security":{"allowed_registry_roots":["HKLM"],"audit_mode":true},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","expected_value":"1"}]}`, ""},
+		{"control mappings", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","controls":{"NIST 800-171":["3.1.1"],"CIS":["2.3.1"]}}]}`, ""},
+		{"read_users", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKU","path":"Control Panel\\Desktop","operation":"read_users","value_name":"ScreenSaveActive"}]}`, ""},
+		{"read_tree limits", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SYSTEM\\X","operation":"read_tree","value_name":"Start","max_depth":2,"max_keys":500}]}`, ""},
+		{"powershell", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","operation":"powershell","script":"(Get-BitLockerVolume -MountPoint C:).ProtectionStatus","script_signature":"c2ln","timeout_seconds":60,"expected_value":"On"}]}`, ""},
+		{"powershell without signature", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","operation":"powershell","script":"Get-MpComputerStatus"}]}`, "queries[0]"},
+		{"profiles and tags", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"profiles":{"quick":{"tags":["quick"]}},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","tags":["quick"]}]}`, ""},
+		{"controls not an object", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","controls":["3.1.1"]}]}`, "queries[0].controls"},
+		{"not an object", `[]`, "-"},
+		{"missing queries", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"}}`, "-"},
+		{"no queries", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},"queries":[]}`, "queries"},
+		{"empty title", `{"version":"1.0","metadata":{"report_title":"","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read"}]}`, "metadata.report_title"},
+		{"missing path", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read"},{"name":"r","root_key":"HKLM","operation":"read"}]}`, "queries[1]"},
+		{"unknown query property", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","expected_valu":"1"}]}`, "queries[0]"},
+		{"unknown operation", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"delete"}]}`, "queries[0].operation"},
+		{"wrong type", `{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+			"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","read_all":"yes"}]}`, "queries[0].read_all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReport([]byte(tt.doc))
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Errorf("ValidateReport() error = %v, want nil", err)
+				}
+				return
+			}
+			var schemaErr *Error
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("ValidateReport() error = %v, want *Error", err)
+			}
+			if want := tt.wantPath; want != "-" && schemaErr.Path != want {
+				t.Errorf("ValidateReport() error path = %q, want %q (%v)", schemaErr.Path, want, err)
+			}
+		})
+	}
+
+	if err := ValidateReport([]byte(`{"version":`)); err == nil {
+		t.Error("ValidateReport() truncated JSON error = nil, want error")
+	}
+	if err := ValidateReport([]byte(`{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+		"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read"}]} {}`)); err == nil {
+		t.Error("ValidateReport() trailing data error = nil, want error")
+	}
+}
+
+// TestShippedReports tests that the report configurations in the
+// repository match the schema
+func TestShippedReports(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "configs", "reports", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no shipped reports found: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateReport(data); err != nil {
+			t.Errorf("%s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+// FuzzValidateReport tests that checking arbitrary documents against the
+// schema does not panic, and that errors for documents that are JSON are
+// *Error
+func FuzzValidateReport(f *testing.F) {
+	f.Add([]byte(`{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+		"queries":[{"name":"q","root_key":"HKLM","path":"SOFTWARE\\X","operation":"read","expected_value":"1"}]}`))
+	f.Add([]byte(`{"version":"1.0","metadata":{"report_title":"CIS","report_version":"1.1"},
+		"queries":[{"name":"q","operation":"powershell","script":"Get-Date","script_signature":"c2ln"}]}`))
+	f.Add([]byte(`{"queries":[{"operation":7}],"profiles":{"quick":{"tags":["a"]}}}`))
+	f.Add([]byte(`[[[[[]]]]]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := ValidateReport(data)
+		var doc interface{}
+		if err == nil || json.Unmarshal(data, &doc) != nil {
+			return
+		}
+		var schemaErr *Error
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("ValidateReport() error = %v, want *Error for a JSON document", err)
+		}
+	})
+}
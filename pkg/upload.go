@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"compliancetoolkit/pkg/api"
+)
+
+// QueryResults returns the report's results in the form submitted to the
+// compliance server, sorted by name
+func (r *HTMLReport) QueryResults() []api.QueryResult {
+	rows := r.ExportRows()
+	results := make([]api.QueryResult, 0, len(rows))
+	for _, row := range rows {
+		result := api.QueryResult{
+			Name:        row.Name,
+			Description: row.Description,
+			Status:      api.QueryStatusFromEvidence(row.Status),
+			Expected:    row.Expected,
+			Actual:      row.Actual,
+			Message:     row.Message,
+			RootKey:     row.RootKey,
+			Path:        row.Path,
+			ValueName:   row.ValueName,
+		}
+		r.mu.Lock()
+		if items, ok := r.Results[row.Name].Value.([]string); ok {
+			result.ActualList = items
+		}
+		r.mu.Unlock()
+
+		switch row.Status {
+		case StatusNotFound:
+			result.Actual = "not found"
+			result.ErrorClass = api.ErrorClassNotFound
+		case StatusError:
+			result.ErrorClass = api.ErrorClassReadFailed
+		case StatusFail:
+			if result.Message == "" {
+				result.Message = fmt.Sprintf("Expected '%s', got '%s'", row.Expected, row.Actual)
+			}
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// NewSubmission converts a completed report into a submission for the
+// compliance server. machine supplies the hostname and system details when
+// the evidence log gathered them; otherwise the local hostname is used. The
+// client ID matches the one the compliance client derives from the hostname,
+// so toolkit and agent results for a machine share one history.
+func NewSubmission(report *HTMLReport, machine *MachineInfo) (*api.ComplianceSubmission, error) {
+	hostname := ""
+	var sysInfo api.SystemInfo
+	if machine != nil {
+		hostname = knownValue(machine.Hostname)
+		sysInfo = api.SystemInfo{
+			OSVersion:    knownValue(machine.OSProductName),
+			BuildNumber:  knownValue(machine.OSBuildNumber),
+			Architecture: knownValue(machine.Architecture),
+		}
+	}
+	if hostname == "" {
+		var err error
+		if hostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to determine hostname: %w", err)
+		}
+	}
+
+	report.mu.Lock()
+	reportType := report.Title
+	metadata := report.Metadata
+	report.mu.Unlock()
+
+	submission := &api.ComplianceSubmission{
+		SubmissionID:  uuid.New().String(),
+		ClientID:      fmt.Sprintf("client-%s", hostname),
+		Hostname:      hostname,
+		Timestamp:     time.Now(),
+		ReportType:    reportType,
+		ReportVersion: metadata.ReportVersion,
+		Compliance:    api.NewComplianceData(report.QueryResults()),
+		SystemInfo:    sysInfo,
+	}
+	if err := submission.Validate(); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// knownValue returns s, or "" when the evidence log could not read it
+func knownValue(s string) string {
+	if s == "UNKNOWN" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func TestNewSubmission(t *testing.T) {
+	report := NewHTMLReport("NIST 800-171", t.TempDir(), slog.Default(), nil)
+	report.SetMetadata(ReportMetadata{ReportTitle: "NIST 800-171", ReportVersion: "2.0"})
+
+	query := func(name, expected string) RegistryQuery {
+		return RegistryQuery{Name: name, RootKey: "HKLM", Path: "SOFTWARE\\Test", ValueName: name, ExpectedValue: expected}
+	}
+	report.AddQueryResult(query("uac", "1"), "1", nil)
+	report.AddQueryResult(query("firewall", "1"), "0", nil)
+	report.AddQueryResult(query("smb1", "0"), nil, registry.ErrNotExist)
+	report.AddQueryResult(query("audit", "1"), nil, errors.New("access denied"))
+
+	submission, err := NewSubmission(report, &MachineInfo{Hostname: "WS01", OSProductName: "Windows 11 Pro", OSBuildNumber: "UNKNOWN"})
+	if err != nil {
+		t.Fatalf("NewSubmission() error = %v", err)
+	}
+
+	if submission.ClientID != "client-WS01" || submission.Hostname != "WS01" {
+		t.Errorf("identity = %q/%q, want client-WS01/WS01", submission.ClientID, submission.Hostname)
+	}
+	if submission.ReportType != "NIST 800-171" || submission.ReportVersion != "2.0" {
+		t.Errorf("report = %q %q", submission.ReportType, submission.ReportVersion)
+	}
+	if submission.SystemInfo.OSVersion != "Windows 11 Pro" || submission.SystemInfo.BuildNumber != "" {
+		t.Errorf("system info = %+v", submission.SystemInfo)
+	}
+
+	data := submission.Compliance
+	if data.TotalChecks != 4 || data.PassedChecks != 1 || data.FailedChecks != 2 || data.ErrorChecks != 1 {
+		t.Errorf("counts = %+v", data)
+	}
+	if data.OverallStatus != "non-compliant" {
+		t.Errorf("OverallStatus = %q, want non-compliant", data.OverallStatus)
+	}
+
+	byName := make(map[string]string)
+	for _, q := range data.Queries {
+		byName[q.Name] = q.Status + "/" + q.Actual + "/" + q.ErrorClass
+	}
+	if got := byName["smb1"]; got != "fail/not found/not_found" {
+		t.Errorf("smb1 = %q", got)
+	}
+	if got := byName["firewall"]; got != "fail/0/" {
+		t.Errorf("firewall = %q", got)
+	}
+}
@@ -1,12 +1,19 @@
 package pkg
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"golang.org/x/sys/windows/registry"
+
+	"compliancetoolkit/pkg/api"
+	"compliancetoolkit/pkg/decode"
 )
 
 // Validator interface for types that can validate themselves
@@ -35,6 +42,7 @@ const (
 	ErrCodeTooLong
 	ErrCodeInvalidCharacters
 	ErrCodeDisallowedPath
+	ErrCodeInvalidExpectedValue
 )
 
 func (e *ValidationError) Error() string {
@@ -62,6 +70,9 @@ var (
 	// Value names can include more characters but still need sanitization
 	validValueNameRegex = regexp.MustCompile(`^[a-zA-Z0-9\s\-_.()\[\]{}@#$%&+=]+$`)
 
+	// Remote hosts are NetBIOS names, DNS names or IP addresses
+	validRemoteHostRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-_.:]*$`)
+
 	// Detect potential injection attempts (null bytes, control chars, etc.)
 	injectionPatternRegex = regexp.MustCompile(`[\x00-\x1F\x7F]`)
 
@@ -69,6 +80,69 @@ var (
 	pathTraversalRegex = regexp.MustCompile(`\.\.[\\/]`)
 )
 
+// CharacterPolicy is the set of characters registry paths and value names
+// may contain
+type CharacterPolicy string
+
+const (
+	// CharacterPolicyASCII allows the ASCII letters, digits and punctuation
+	// of validRegistryPathRegex and validValueNameRegex (the default)
+	CharacterPolicyASCII CharacterPolicy = "ascii"
+
+	// CharacterPolicyUnicode also allows letters, combining marks and digits
+	// of any script, for key and value names on localized Windows. Control
+	// and format characters, such as bidirectional overrides and zero-width
+	// spaces, and Unicode punctuation and symbols, such as fullwidth dots and
+	// backslashes, are still refused, so names cannot hide or spoof path
+	// separators and traversal.
+	CharacterPolicyUnicode CharacterPolicy = "unicode"
+)
+
+// CharacterPolicies are the valid character policies
+var CharacterPolicies = []CharacterPolicy{CharacterPolicyASCII, CharacterPolicyUnicode}
+
+// Unicode forms of validRegistryPathRegex and validValueNameRegex. \s is
+// ASCII whitespace only.
+var (
+	unicodeRegistryPathRegex = regexp.MustCompile(`^[\p{L}\p{M}\p{N}\\\s\-_.()/]+$`)
+	unicodeValueNameRegex    = regexp.MustCompile(`^[\p{L}\p{M}\p{N}\s\-_.()\[\]{}@#$%&+=]+$`)
+)
+
+// characterPolicy is the policy in force; set once at startup
+var characterPolicy atomic.Value
+
+// SetCharacterPolicy sets the characters every later validation of registry
+// paths and value names allows. Programs set it once at startup from their
+// configuration; an empty policy restores CharacterPolicyASCII.
+func SetCharacterPolicy(policy CharacterPolicy) error {
+	if policy == "" {
+		policy = CharacterPolicyASCII
+	}
+	if err := ValidateCharacterPolicy(policy); err != nil {
+		return err
+	}
+	characterPolicy.Store(policy)
+	return nil
+}
+
+// CurrentCharacterPolicy returns the character policy in force
+func CurrentCharacterPolicy() CharacterPolicy {
+	if policy, ok := characterPolicy.Load().(CharacterPolicy); ok {
+		return policy
+	}
+	return CharacterPolicyASCII
+}
+
+// ValidateCharacterPolicy checks that policy is one of CharacterPolicies
+func ValidateCharacterPolicy(policy CharacterPolicy) error {
+	for _, valid := range CharacterPolicies {
+		if policy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid character policy %q, must be one of: %v", policy, CharacterPolicies)
+}
+
 // ValidRootKeys maps valid root key strings to registry.Key values
 var ValidRootKeys = map[string]registry.Key{
 	"HKLM":                 registry.LOCAL_MACHINE,
@@ -85,13 +159,23 @@ var ValidRootKeys = map[string]registry.Key{
 
 // Validate implements the Validator interface for RegistryQuery
 func (r *RegistryQuery) Validate() error {
+	// A powershell query runs a script instead of reading the registry
+	if r.IsPowerShell() {
+		return r.validatePowerShell()
+	}
+
 	// Validate root key
 	if err := ValidateRootKey(r.RootKey); err != nil {
 		return err
 	}
 
-	// Validate registry path
-	if err := ValidateRegistryPath(r.Path); err != nil {
+	// Validate registry path; the SID placeholder of a read_users path
+	// stands for a valid key name
+	path := r.Path
+	if r.IsPerUser() {
+		path = strings.ReplaceAll(path, UserSIDPlaceholder, "S-1-5-21-0")
+	}
+	if err := ValidateRegistryPath(path); err != nil {
 		return err
 	}
 
@@ -107,6 +191,115 @@ func (r *RegistryQuery) Validate() error {
 		return err
 	}
 
+	// Validate expected value against its comparison operator
+	if err := api.ValidateExpected(r.ExpectedValue, r.ExpectedOperator); err != nil {
+		return &ValidationError{
+			Field:   "ExpectedValue",
+			Value:   r.ExpectedValue,
+			Message: err.Error(),
+			Code:    ErrCodeInvalidExpectedValue,
+		}
+	}
+
+	// A remediation sets a single named value
+	if strings.EqualFold(r.Operation, "remediate") {
+		if r.ValueName == "" {
+			return &ValidationError{
+				Field:   "ValueName",
+				Value:   r.ValueName,
+				Message: "remediate operations require a value name",
+				Code:    ErrCodeEmptyField,
+			}
+		}
+		if r.ReadAll {
+			return &ValidationError{
+				Field:   "ReadAll",
+				Value:   "true",
+				Message: "remediate operations cannot read all values",
+				Code:    ErrCodeInvalidCharacters,
+			}
+		}
+	}
+
+	// Tree limits apply to read_tree queries, within bounds
+	if r.IsTree() && r.ReadAll {
+		return &ValidationError{
+			Field:   "ReadAll",
+			Value:   "true",
+			Message: r.Operation + " operations read every value unless value_name is set; read_all does not apply",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+	if r.IsTree() && !r.IsPerUser() {
+		if r.MaxDepth < 0 || r.MaxDepth > MaxTreeDepth {
+			return &ValidationError{
+				Field:   "MaxDepth",
+				Value:   fmt.Sprintf("%d", r.MaxDepth),
+				Message: fmt.Sprintf("max_depth must be between 0 and %d", MaxTreeDepth),
+				Code:    ErrCodeInvalidCharacters,
+			}
+		}
+		if r.MaxKeys < 0 || r.MaxKeys > MaxTreeKeys {
+			return &ValidationError{
+				Field:   "MaxKeys",
+				Value:   fmt.Sprintf("%d", r.MaxKeys),
+				Message: fmt.Sprintf("max_keys must be between 0 and %d", MaxTreeKeys),
+				Code:    ErrCodeInvalidCharacters,
+			}
+		}
+	} else if r.MaxDepth != 0 || r.MaxKeys != 0 {
+		return &ValidationError{
+			Field:   "Operation",
+			Value:   r.Operation,
+			Message: "max_depth and max_keys apply to read_tree operations only",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	// A read_users path is read below each user's hive under HKU, or names
+	// the user with the SID placeholder
+	if r.IsPerUser() && !strings.Contains(r.Path, UserSIDPlaceholder) &&
+		r.RootKey != "HKU" && r.RootKey != "HKEY_USERS" {
+		return &ValidationError{
+			Field:   "Path",
+			Value:   r.Path,
+			Message: "read_users operations read below each user's hive under HKU, or need " + UserSIDPlaceholder + " in the path",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	// Expansion applies to a single REG_EXPAND_SZ value
+	if r.ExpandEnv && (r.ReadAll || r.IsTree() || r.ValueName == "") {
+		return &ValidationError{
+			Field:   "ExpandEnv",
+			Value:   "true",
+			Message: "expand_env applies to queries reading a single named value",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	// Decoding applies to a single REG_BINARY value, with a known decoder
+	if r.Decoder != "" || r.DecodedField != "" {
+		if r.ReadAll || r.IsTree() || r.ValueName == "" {
+			return &ValidationError{
+				Field:   "Decoder",
+				Value:   r.Decoder,
+				Message: "decoder and decoded_field apply to queries reading a single named value",
+				Code:    ErrCodeInvalidCharacters,
+			}
+		}
+		if r.Decoder != "" {
+			if _, ok := decode.Lookup(r.Decoder); !ok {
+				return &ValidationError{
+					Field:   "Decoder",
+					Value:   r.Decoder,
+					Message: fmt.Sprintf("unknown decoder (available: %s)", strings.Join(decode.Names(), ", ")),
+					Code:    ErrCodeInvalidCharacters,
+				}
+			}
+		}
+	}
+
 	// Additional security checks
 	if err := ValidateNoPathTraversal(r.Path); err != nil {
 		return err
@@ -161,7 +354,7 @@ func ValidateRegistryPath(path string) error {
 	}
 
 	// Check length
-	if len(path) > MaxRegistryPathLength {
+	if utf8.RuneCountInString(path) > MaxRegistryPathLength {
 		return &ValidationError{
 			Field:   "Path",
 			Value:   path,
@@ -181,7 +374,16 @@ func ValidateRegistryPath(path string) error {
 	}
 
 	// Validate against allowed character set
-	if !validRegistryPathRegex.MatchString(path) {
+	if CurrentCharacterPolicy() == CharacterPolicyUnicode {
+		if !unicodeRegistryPathRegex.MatchString(path) {
+			return &ValidationError{
+				Field:   "Path",
+				Value:   path,
+				Message: "registry path contains disallowed characters (only letters, digits, backslash, forward slash, space, hyphen, underscore, dot, parentheses allowed)",
+				Code:    ErrCodeInvalidCharacters,
+			}
+		}
+	} else if !validRegistryPathRegex.MatchString(path) {
 		return &ValidationError{
 			Field:   "Path",
 			Value:   path,
@@ -222,7 +424,7 @@ func ValidateValueName(valueName string) error {
 	}
 
 	// Check length
-	if len(valueName) > MaxRegistryValueNameLength {
+	if utf8.RuneCountInString(valueName) > MaxRegistryValueNameLength {
 		return &ValidationError{
 			Field:   "ValueName",
 			Value:   valueName,
@@ -242,7 +444,11 @@ func ValidateValueName(valueName string) error {
 	}
 
 	// Validate against allowed character set (more permissive than paths)
-	if !validValueNameRegex.MatchString(valueName) {
+	valueNameRegex := validValueNameRegex
+	if CurrentCharacterPolicy() == CharacterPolicyUnicode {
+		valueNameRegex = unicodeValueNameRegex
+	}
+	if !valueNameRegex.MatchString(valueName) {
 		return &ValidationError{
 			Field:   "ValueName",
 			Value:   valueName,
@@ -254,6 +460,81 @@ func ValidateValueName(valueName string) error {
 	return nil
 }
 
+// validatePowerShell validates a powershell query: a signed script of
+// bounded length and timeout, without any of the registry fields
+func (r *RegistryQuery) validatePowerShell() error {
+	if strings.TrimSpace(r.Script) == "" {
+		return &ValidationError{
+			Field:   "Script",
+			Value:   r.Script,
+			Message: "powershell operations require a script",
+			Code:    ErrCodeEmptyField,
+		}
+	}
+	if len(r.Script) > MaxScriptLength {
+		return &ValidationError{
+			Field:   "Script",
+			Value:   r.Script[:50] + "...",
+			Message: fmt.Sprintf("script exceeds maximum length of %d bytes", MaxScriptLength),
+			Code:    ErrCodeTooLong,
+		}
+	}
+	if strings.ContainsRune(r.Script, 0) || !utf8.ValidString(r.Script) {
+		return &ValidationError{
+			Field:   "Script",
+			Value:   "",
+			Message: "script contains null bytes or invalid UTF-8",
+			Code:    ErrCodeInjectionAttempt,
+		}
+	}
+
+	if r.ScriptSignature == "" {
+		return &ValidationError{
+			Field:   "ScriptSignature",
+			Value:   r.ScriptSignature,
+			Message: "powershell operations require a script_signature",
+			Code:    ErrCodeEmptyField,
+		}
+	}
+	if signature, err := base64.StdEncoding.DecodeString(r.ScriptSignature); err != nil || len(signature) != ed25519.SignatureSize {
+		return &ValidationError{
+			Field:   "ScriptSignature",
+			Value:   r.ScriptSignature,
+			Message: fmt.Sprintf("script_signature must be a base64 %d byte Ed25519 signature", ed25519.SignatureSize),
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	if r.TimeoutSeconds < 0 || r.TimeoutSeconds > MaxPowerShellTimeout {
+		return &ValidationError{
+			Field:   "TimeoutSeconds",
+			Value:   fmt.Sprintf("%d", r.TimeoutSeconds),
+			Message: fmt.Sprintf("timeout_seconds must be between 0 and %d", MaxPowerShellTimeout),
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	if r.RootKey != "" || r.Path != "" || r.ValueName != "" || r.ReadAll || r.ExpandEnv ||
+		r.Decoder != "" || r.DecodedField != "" || r.MaxDepth != 0 || r.MaxKeys != 0 {
+		return &ValidationError{
+			Field:   "Operation",
+			Value:   r.Operation,
+			Message: "powershell operations run a script; registry fields such as root_key, path and value_name do not apply",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	if err := api.ValidateExpected(r.ExpectedValue, r.ExpectedOperator); err != nil {
+		return &ValidationError{
+			Field:   "ExpectedValue",
+			Value:   r.ExpectedValue,
+			Message: err.Error(),
+			Code:    ErrCodeInvalidExpectedValue,
+		}
+	}
+	return nil
+}
+
 // ValidateOperation validates a registry operation type
 func ValidateOperation(operation string) error {
 	if operation == "" {
@@ -267,14 +548,22 @@ func ValidateOperation(operation string) error {
 
 	validOps := map[string]bool{
 		"read": true,
-		// Future: "write", "delete", etc. (currently read-only by design)
+		// Reads like "read", and sets the expected value when a remediation
+		// run is explicitly requested (see RegistryWriter)
+		"remediate": true,
+		// Reads the values of a key and its subkeys (see RegistryReader.ReadTree)
+		"read_tree": true,
+		// Reads a key for every user whose hive is loaded (see walkUsers)
+		"read_users": true,
+		// Runs a signed script block and compares its output (see RunPowerShell)
+		"powershell": true,
 	}
 
 	if !validOps[strings.ToLower(operation)] {
 		return &ValidationError{
 			Field:   "Operation",
 			Value:   operation,
-			Message: "invalid operation, must be 'read' (tool is read-only)",
+			Message: "invalid operation, must be 'read', 'read_tree', 'read_users', 'powershell' or 'remediate'",
 			Code:    ErrCodeInvalidCharacters,
 		}
 	}
@@ -336,31 +625,53 @@ func ValidateNoInjection(input string) error {
 	return nil
 }
 
-// ValidateAgainstDenyList checks if a path is in the security deny list
+// ValidateAgainstDenyList checks if a path is in the security deny list. Entries
+// are paths, denied with their subkeys, or glob and regex patterns (see
+// DenyRuleGlob and DenyRuleRegex).
 func ValidateAgainstDenyList(path string, denyList []string) error {
-	normalizedPath := strings.ToLower(strings.TrimSpace(path))
-
-	for _, deniedPath := range denyList {
-		normalizedDenied := strings.ToLower(strings.TrimSpace(deniedPath))
+	for _, entry := range denyList {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
 
-		// Exact match
-		if normalizedPath == normalizedDenied {
+		// A rule that does not compile denies everything, so a broken
+		// pattern never opens up the registry
+		rule, err := CompileDenyRule(entry)
+		if err != nil {
 			return &ValidationError{
 				Field:   "Path",
 				Value:   path,
-				Message: "access to this registry path is blocked by security policy",
+				Message: fmt.Sprintf("access to this registry path is blocked by security policy (%v)", err),
 				Code:    ErrCodeDisallowedPath,
 			}
 		}
 
-		// Prefix match (block subkeys of denied paths)
-		if strings.HasPrefix(normalizedPath, normalizedDenied+"\\") {
+		matched, parent := rule.Match(path)
+		switch {
+		case !matched:
+			continue
+		case rule.IsPattern():
+			return &ValidationError{
+				Field:   "Path",
+				Value:   path,
+				Message: fmt.Sprintf("access to this registry path is blocked by security policy (rule %q)", entry),
+				Code:    ErrCodeDisallowedPath,
+			}
+		case parent:
+			// Prefix match (block subkeys of denied paths)
 			return &ValidationError{
 				Field:   "Path",
 				Value:   path,
 				Message: "access to this registry path is blocked by security policy (parent path denied)",
 				Code:    ErrCodeDisallowedPath,
 			}
+		default:
+			return &ValidationError{
+				Field:   "Path",
+				Value:   path,
+				Message: "access to this registry path is blocked by security policy",
+				Code:    ErrCodeDisallowedPath,
+			}
 		}
 	}
 
@@ -466,6 +777,40 @@ func ValidateFilePath(path string, allowedExtensions []string) error {
 	return nil
 }
 
+// ValidateRemoteHost validates a machine name for remote registry scanning.
+// A leading \\ as in UNC paths is accepted.
+func ValidateRemoteHost(host string) error {
+	name := strings.TrimPrefix(host, `\\`)
+	if name == "" {
+		return &ValidationError{
+			Field:   "RemoteHost",
+			Value:   host,
+			Message: "remote host cannot be empty",
+			Code:    ErrCodeEmptyField,
+		}
+	}
+
+	if len(name) > 253 {
+		return &ValidationError{
+			Field:   "RemoteHost",
+			Value:   host,
+			Message: "remote host exceeds 253 characters",
+			Code:    ErrCodeTooLong,
+		}
+	}
+
+	if !validRemoteHostRegex.MatchString(name) {
+		return &ValidationError{
+			Field:   "RemoteHost",
+			Value:   host,
+			Message: "remote host must be a machine name, DNS name or IP address",
+			Code:    ErrCodeInvalidCharacters,
+		}
+	}
+
+	return nil
+}
+
 // SanitizeRegistryPath sanitizes a registry path by removing dangerous characters
 func SanitizeRegistryPath(path string) string {
 	// Remove null bytes and control characters
@@ -540,6 +885,13 @@ func ValidateConfig(config *RegistryConfig) error {
 		}
 	}
 
+	// Deny list patterns of the report's own security settings must compile
+	if config.Security != nil {
+		if err := ValidateDenyList(config.Security.DenyRegistryPaths); err != nil {
+			return fmt.Errorf("security.deny_registry_paths: %w", err)
+		}
+	}
+
 	// Validate each query
 	for i, query := range config.Queries {
 		if err := query.Validate(); err != nil {
@@ -547,5 +899,34 @@ func ValidateConfig(config *RegistryConfig) error {
 		}
 	}
 
+	// Every profile must select at least one query, so a mistyped tag is
+	// not a scan that silently checks nothing
+	for _, name := range config.ProfileNames() {
+		profile := config.Profiles[name]
+		if strings.TrimSpace(name) == "" || len(profile.Tags) == 0 {
+			return &ValidationError{
+				Field:   "Profiles",
+				Value:   name,
+				Message: "profiles need a name and at least one tag",
+				Code:    ErrCodeEmptyField,
+			}
+		}
+		selected := false
+		for _, query := range config.Queries {
+			if query.HasAnyTag(profile.Tags) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			return &ValidationError{
+				Field:   "Profiles",
+				Value:   name,
+				Message: fmt.Sprintf("profile %q selects no query (tags: %s)", name, strings.Join(profile.Tags, ", ")),
+				Code:    ErrCodeEmptyField,
+			}
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"compliancetoolkit/pkg/fileio"
+)
+
+// MaxValidationCacheEntries is how many validated config hashes a
+// ValidationCache keeps; the oldest are dropped past it
+const MaxValidationCacheEntries = 1000
+
+// ValidationCache remembers the report config files that passed
+// ValidateConfig, by the SHA-256 of their contents, so unchanged files are
+// not validated again on every scheduled run. Results only hold for the
+// rules that produced them: a cache written under other rules, such as by
+// another build, is discarded.
+type ValidationCache struct {
+	mu      sync.Mutex
+	path    string // File the cache is kept in; empty keeps it in memory only
+	rules   string
+	entries map[string]time.Time // Content hash to when it was validated
+}
+
+// validationCacheFile is the JSON a ValidationCache is saved as
+type validationCacheFile struct {
+	Rules   string               `json:"rules"`
+	Configs map[string]time.Time `json:"configs"`
+}
+
+// NewValidationCache returns a cache kept in path for validation under
+// rules, e.g. the validating binary's hash. A missing, unreadable or stale
+// file starts an empty cache; an empty path keeps it in memory only.
+func NewValidationCache(path, rules string) *ValidationCache {
+	c := &ValidationCache{path: path, rules: rules, entries: map[string]time.Time{}}
+	if path == "" {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var file validationCacheFile
+	if json.Unmarshal(data, &file) == nil && file.Rules == rules && file.Configs != nil {
+		c.entries = file.Configs
+	}
+	return c
+}
+
+// LoadConfig loads the report config file at path and validates it with
+// ValidateConfig, unless a file with the same contents already passed.
+// cached reports that validation was skipped. A nil cache always validates.
+func (c *ValidationCache) LoadConfig(path string) (config *RegistryConfig, cached bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	config = &RegistryConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, false, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if c.validated(hash) {
+		return config, true, nil
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, false, err
+	}
+	if err := c.record(hash, time.Now()); err != nil {
+		return config, false, fmt.Errorf("config is valid, but the validation cache cannot be saved: %w", err)
+	}
+	return config, false, nil
+}
+
+// validated reports whether contents with hash passed validation
+func (c *ValidationCache) validated(hash string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[hash]
+	return ok
+}
+
+// record remembers that contents with hash passed validation at now, and
+// saves the cache
+func (c *ValidationCache) record(hash string, now time.Time) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[hash] = now
+	if excess := len(c.entries) - MaxValidationCacheEntries; excess > 0 {
+		hashes := make([]string, 0, len(c.entries))
+		for h := range c.entries {
+			hashes = append(hashes, h)
+		}
+		sort.Slice(hashes, func(i, j int) bool { return c.entries[hashes[i]].Before(c.entries[hashes[j]]) })
+		for _, h := range hashes[:excess] {
+			delete(c.entries, h)
+		}
+	}
+
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(validationCacheFile{Rules: c.rules, Configs: c.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return fileio.WriteFile(c.path, data, 0644)
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	validCacheConfig   = `{"version": "1.0", "queries": [{"name": "uac", "root_key": "HKLM", "path": "SOFTWARE\\Policies", "operation": "read"}]}`
+	invalidCacheConfig = `{"version": "1.0", "queries": [{"name": "uac", "root_key": "HKXX", "path": "SOFTWARE\\Policies", "operation": "read"}]}`
+)
+
+// writeCacheConfig writes a report config into dir and returns its path
+func writeCacheConfig(t testing.TB, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestValidationCache tests that unchanged configs are validated once, and
+// that changed and invalid ones are always validated
+func TestValidationCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", "validated_configs.json")
+	cache := NewValidationCache(cachePath, "build-1")
+
+	report := writeCacheConfig(t, dir, "report.json", validCacheConfig)
+	for i, wantCached := range []bool{false, true} {
+		config, cached, err := cache.LoadConfig(report)
+		if err != nil {
+			t.Fatalf("load %d: LoadConfig() error = %v", i, err)
+		}
+		if cached != wantCached || len(config.Queries) != 1 {
+			t.Errorf("load %d: LoadConfig() cached = %v with %d queries, want %v with 1", i, cached, len(config.Queries), wantCached)
+		}
+	}
+
+	// The same contents under another name were already validated
+	if _, cached, _ := cache.LoadConfig(writeCacheConfig(t, dir, "copy.json", validCacheConfig)); !cached {
+		t.Error("LoadConfig() validated a copy of a validated config again")
+	}
+
+	// Invalid configs are refused every time
+	invalid := writeCacheConfig(t, dir, "invalid.json", invalidCacheConfig)
+	for i := 0; i < 2; i++ {
+		if _, _, err := cache.LoadConfig(invalid); err == nil {
+			t.Fatalf("load %d: LoadConfig() accepted an invalid config", i)
+		}
+	}
+
+	// An edited file is validated again
+	writeCacheConfig(t, dir, "report.json", invalidCacheConfig)
+	if _, _, err := cache.LoadConfig(report); err == nil {
+		t.Error("LoadConfig() accepted an edited, invalid config")
+	}
+
+	// The results outlive the process, for the rules that produced them
+	if _, cached, _ := NewValidationCache(cachePath, "build-1").LoadConfig(writeCacheConfig(t, dir, "again.json", validCacheConfig)); !cached {
+		t.Error("reopened cache does not remember a validated config")
+	}
+	if _, cached, _ := NewValidationCache(cachePath, "build-2").LoadConfig(writeCacheConfig(t, dir, "again.json", validCacheConfig)); cached {
+		t.Error("cache under other rules remembers a validated config")
+	}
+
+	// A nil cache validates every time
+	var none *ValidationCache
+	if _, cached, err := none.LoadConfig(writeCacheConfig(t, dir, "nil.json", validCacheConfig)); err != nil || cached {
+		t.Errorf("nil cache LoadConfig() = cached %v, error %v; want validated", cached, err)
+	}
+}
+
+// TestValidationCacheLimit tests that the oldest entries are dropped past
+// MaxValidationCacheEntries
+func TestValidationCacheLimit(t *testing.T) {
+	cache := NewValidationCache("", "")
+	start := time.Now()
+	for i := 0; i <= MaxValidationCacheEntries; i++ {
+		if err := cache.record(fmt.Sprintf("hash%d", i), start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cache.entries) != MaxValidationCacheEntries {
+		t.Errorf("cache has %d entries, want %d", len(cache.entries), MaxValidationCacheEntries)
+	}
+	if cache.validated("hash0") || !cache.validated(fmt.Sprintf("hash%d", MaxValidationCacheEntries)) {
+		t.Error("cache did not drop the oldest entry")
+	}
+}
+
+// BenchmarkLoadConfig measures loading a large config with and without
+// its validation cached
+func BenchmarkLoadConfig(b *testing.B) {
+	query := `{"name": "q%d", "root_key": "HKLM", "path": "SOFTWARE\\Policies\\Microsoft\\Windows\\System", "value_name": "EnableSmartScreen", "operation": "read", "expected_value": "1 (Enabled)"}`
+	content := `{"version": "1.0", "queries": [`
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			content += ","
+		}
+		content += fmt.Sprintf(query, i)
+	}
+	content += "]}"
+	path := writeCacheConfig(b, b.TempDir(), "large.json", content)
+
+	for _, name := range []string{"uncached", "cached"} {
+		b.Run(name, func(b *testing.B) {
+			var cache *ValidationCache
+			if name == "cached" {
+				cache = NewValidationCache("", "")
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := cache.LoadConfig(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package pkg
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -110,6 +114,64 @@ func TestValidateRegistryPath(t *testing.T) {
 	}
 }
 
+// TestCharacterPolicy tests that the unicode character policy allows names
+// from localized Windows but not control, format or lookalike characters
+func TestCharacterPolicy(t *testing.T) {
+	t.Cleanup(func() { SetCharacterPolicy(CharacterPolicyASCII) })
+
+	tests := []struct {
+		name    string
+		path    string
+		ascii   bool // Valid under CharacterPolicyASCII
+		unicode bool // Valid under CharacterPolicyUnicode
+	}{
+		{"ascii", `SOFTWARE\Microsoft\Windows NT`, true, true},
+		{"latin letters", `SOFTWARE\Contoso\Einstellungen für Benutzer`, false, true},
+		{"japanese", `SOFTWARE\Contoso\ソフトウェア`, false, true},
+		{"combining mark", "SOFTWARE\\Contoso\\Cafe\u0301", false, true},
+		{"arabic-indic digits", "SOFTWARE\\Contoso\\\u0663\u0664", false, true},
+		{"bidi override", "SOFTWARE\\Contoso\\a\u202Eb", false, false},
+		{"zero-width space", "SOFTWARE\\Contoso\\a\u200Bb", false, false},
+		{"no-break space", "SOFTWARE\\Contoso\\a\u00A0b", false, false},
+		{"fullwidth backslash", "SOFTWARE\\Contoso\uFF3CSecrets", false, false},
+		{"fullwidth dots", "SOFTWARE\\Contoso\\\uFF0E\uFF0E", false, false},
+		{"invalid utf-8", "SOFTWARE\\Contoso\\\xff", false, false},
+		{"symbol", `SOFTWARE\Contoso\€`, false, false},
+	}
+
+	for _, policy := range CharacterPolicies {
+		if err := SetCharacterPolicy(policy); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			want := tt.ascii
+			if policy == CharacterPolicyUnicode {
+				want = tt.unicode
+			}
+			if err := ValidateRegistryPath(tt.path); (err == nil) != want {
+				t.Errorf("%s: ValidateRegistryPath(%s) error = %v, want valid %v", policy, tt.name, err, want)
+			}
+			valueName := tt.path[strings.LastIndex(tt.path, `\`)+1:]
+			if err := ValidateValueName(valueName); (err == nil) != want {
+				t.Errorf("%s: ValidateValueName(%s) error = %v, want valid %v", policy, tt.name, err, want)
+			}
+		}
+	}
+
+	// Traversal is refused whatever the characters around it
+	query := RegistryQuery{Name: "q", RootKey: "HKLM", Path: `SOFTWARE\Contoso\..\Geräte`, Operation: "read"}
+	if err := query.Validate(); err == nil {
+		t.Error("Validate() accepted a traversal path under the unicode policy")
+	}
+
+	if err := SetCharacterPolicy("latin1"); err == nil {
+		t.Error("SetCharacterPolicy() accepted an unknown policy")
+	}
+	if got := CurrentCharacterPolicy(); got != CharacterPolicyUnicode {
+		t.Errorf("CurrentCharacterPolicy() = %q after a refused change, want %q", got, CharacterPolicyUnicode)
+	}
+}
+
 // TestValidateValueName tests value name validation
 func TestValidateValueName(t *testing.T) {
 	tests := []struct {
@@ -330,6 +392,52 @@ func TestValidateFilePath(t *testing.T) {
 	}
 }
 
+// TestValidateRemoteHost tests remote host validation
+func TestValidateRemoteHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		wantErr bool
+		errCode ValidationErrorCode
+	}{
+		// Valid cases
+		{"netbios name", "WS01", false, 0},
+		{"dns name", "ws01.corp.example.com", false, 0},
+		{"ipv4 address", "10.0.0.15", false, 0},
+		{"unc prefix", `\\WS01`, false, 0},
+
+		// Invalid cases
+		{"empty host", "", true, ErrCodeEmptyField},
+		{"unc prefix only", `\\`, true, ErrCodeEmptyField},
+		{"too long", strings.Repeat("a", 254), true, ErrCodeTooLong},
+		{"share path", `\\WS01\C$`, true, ErrCodeInvalidCharacters},
+		{"spaces", "WS 01", true, ErrCodeInvalidCharacters},
+		{"leading hyphen", "-WS01", true, ErrCodeInvalidCharacters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRemoteHost(tt.host)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ValidateRemoteHost() expected error, got nil")
+					return
+				}
+				if verr, ok := err.(*ValidationError); ok {
+					if tt.errCode != 0 && verr.Code != tt.errCode {
+						t.Errorf("ValidateRemoteHost() error code = %v, want %v", verr.Code, tt.errCode)
+					}
+				}
+			} else {
+				if err != nil {
+					t.Errorf("ValidateRemoteHost() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // TestSanitizeRegistryPath tests path sanitization
 func TestSanitizeRegistryPath(t *testing.T) {
 	tests := []struct {
@@ -356,6 +464,10 @@ func TestSanitizeRegistryPath(t *testing.T) {
 	}
 }
 
+// testScriptSignature is a well-formed script signature; Validate does not
+// verify it
+var testScriptSignature = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+
 // TestRegistryQueryValidate tests the RegistryQuery.Validate method
 func TestRegistryQueryValidate(t *testing.T) {
 	tests := []struct {
@@ -405,6 +517,308 @@ func TestRegistryQueryValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid remediate query",
+			query: RegistryQuery{
+				Name:          "test_query",
+				RootKey:       "HKLM",
+				Path:          "SOFTWARE\\Microsoft\\Windows",
+				ValueName:     "TestValue",
+				Operation:     "remediate",
+				ExpectedValue: "1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "remediate without value name",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				Operation: "remediate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid range comparison",
+			query: RegistryQuery{
+				Name:             "test_query",
+				RootKey:          "HKLM",
+				Path:             "SOFTWARE\\Microsoft\\Windows",
+				ValueName:        "MinimumPasswordLength",
+				Operation:        "read",
+				ExpectedValue:    "14",
+				ExpectedOperator: "gte",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown expected operator",
+			query: RegistryQuery{
+				Name:             "test_query",
+				RootKey:          "HKLM",
+				Path:             "SOFTWARE\\Microsoft\\Windows",
+				Operation:        "read",
+				ExpectedValue:    "14",
+				ExpectedOperator: "approx",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid expected regex",
+			query: RegistryQuery{
+				Name:             "test_query",
+				RootKey:          "HKLM",
+				Path:             "SOFTWARE\\Microsoft\\Windows",
+				Operation:        "read",
+				ExpectedValue:    "TLS1.[23",
+				ExpectedOperator: "regex",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid read_tree query",
+			query: RegistryQuery{
+				Name:          "test_query",
+				RootKey:       "HKLM",
+				Path:          "SYSTEM\\CurrentControlSet\\Services",
+				ValueName:     "Start",
+				Operation:     "read_tree",
+				ExpectedValue: "4",
+				MaxDepth:      1,
+				MaxKeys:       500,
+			},
+			wantErr: false,
+		},
+		{
+			name: "read_tree depth out of bounds",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SYSTEM\\CurrentControlSet\\Services",
+				Operation: "read_tree",
+				MaxDepth:  MaxTreeDepth + 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "read_tree with read_all",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SYSTEM\\CurrentControlSet\\Services",
+				Operation: "read_tree",
+				ReadAll:   true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "tree limits on a read query",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				ValueName: "TestValue",
+				Operation: "read",
+				MaxKeys:   10,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid powershell query",
+			query: RegistryQuery{
+				Name:            "bitlocker_os_volume",
+				Operation:       "powershell",
+				Script:          "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus",
+				ScriptSignature: testScriptSignature,
+				TimeoutSeconds:  60,
+				ExpectedValue:   "On",
+			},
+			wantErr: false,
+		},
+		{
+			name: "powershell script at the length limit",
+			query: RegistryQuery{
+				Name:            "long_script",
+				Operation:       "powershell",
+				Script:          strings.Repeat("#", MaxScriptLength),
+				ScriptSignature: testScriptSignature,
+			},
+			wantErr: false,
+		},
+		{
+			name: "powershell script over the length limit",
+			query: RegistryQuery{
+				Name:            "long_script",
+				Operation:       "powershell",
+				Script:          strings.Repeat("#", MaxScriptLength+1),
+				ScriptSignature: testScriptSignature,
+			},
+			wantErr: true,
+		},
+		{
+			name: "powershell without signature",
+			query: RegistryQuery{
+				Name:      "bitlocker_os_volume",
+				Operation: "powershell",
+				Script:    "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus",
+			},
+			wantErr: true,
+		},
+		{
+			name: "powershell timeout out of bounds",
+			query: RegistryQuery{
+				Name:            "bitlocker_os_volume",
+				Operation:       "powershell",
+				Script:          "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus",
+				ScriptSignature: testScriptSignature,
+				TimeoutSeconds:  MaxPowerShellTimeout + 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "powershell with a registry path",
+			query: RegistryQuery{
+				Name:            "bitlocker_os_volume",
+				Operation:       "powershell",
+				RootKey:         "HKLM",
+				Path:            "SOFTWARE\\Microsoft\\Windows",
+				Script:          "(Get-BitLockerVolume -MountPoint C:).ProtectionStatus",
+				ScriptSignature: testScriptSignature,
+			},
+			wantErr: true,
+		},
+		{
+			name: "read_users below each hive",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKU",
+				Path:      "Software\\Policies\\Microsoft\\Windows\\Control Panel\\Desktop",
+				ValueName: "ScreenSaveActive",
+				Operation: "read_users",
+			},
+			wantErr: false,
+		},
+		{
+			name: "read_users with the SID placeholder",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows NT\\CurrentVersion\\ProfileList\\{sid}",
+				ValueName: "State",
+				Operation: "read_users",
+			},
+			wantErr: false,
+		},
+		{
+			name: "read_users outside HKU without the placeholder",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				ValueName: "TestValue",
+				Operation: "read_users",
+			},
+			wantErr: true,
+		},
+		{
+			name: "read_users with tree limits",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKU",
+				Path:      "Control Panel\\Desktop",
+				ValueName: "ScreenSaveActive",
+				Operation: "read_users",
+				MaxDepth:  2,
+			},
+			wantErr: true,
+		},
+		{
+			name: "placeholder outside read_users",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows NT\\CurrentVersion\\ProfileList\\{sid}",
+				ValueName: "State",
+				Operation: "read",
+			},
+			wantErr: true,
+		},
+		{
+			name: "expand_env on a named value",
+			query: RegistryQuery{
+				Name:          "test_query",
+				RootKey:       "HKLM",
+				Path:          "SYSTEM\\CurrentControlSet\\Services\\EventLog\\Application",
+				ValueName:     "File",
+				Operation:     "read",
+				ExpandEnv:     true,
+				ExpectedValue: "C:\\Windows\\System32\\winevt\\Logs\\Application.evtx",
+			},
+			wantErr: false,
+		},
+		{
+			name: "expand_env with read_all",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				Operation: "read",
+				ReadAll:   true,
+				ExpandEnv: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "decoder on a named value",
+			query: RegistryQuery{
+				Name:         "test_query",
+				RootKey:      "HKLM",
+				Path:         "SYSTEM\\CurrentControlSet\\Control\\Windows",
+				ValueName:    "ShutdownTime",
+				Operation:    "read",
+				Decoder:      "filetime",
+				DecodedField: "Time",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown decoder",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				ValueName: "TestValue",
+				Operation: "read",
+				Decoder:   "lsa_secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "decoder with read_all",
+			query: RegistryQuery{
+				Name:      "test_query",
+				RootKey:   "HKLM",
+				Path:      "SOFTWARE\\Microsoft\\Windows",
+				Operation: "read",
+				ReadAll:   true,
+				Decoder:   "sid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "contains operator",
+			query: RegistryQuery{
+				Name:             "test_query",
+				RootKey:          "HKLM",
+				Path:             "SOFTWARE\\Microsoft\\Windows",
+				ValueName:        "Pipes",
+				Operation:        "read",
+				ExpectedValue:    "[netlogon, samr]",
+				ExpectedOperator: "contains",
+			},
+			wantErr: false,
+		},
 		{
 			name: "path with injection",
 			query: RegistryQuery{
@@ -466,6 +880,40 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "profile selecting queries",
+			config: &RegistryConfig{
+				Version:  "1.0",
+				Profiles: map[string]ReportProfile{"quick": {Tags: []string{"Quick"}}},
+				Queries: []RegistryQuery{
+					{
+						Name:      "test_query",
+						RootKey:   "HKLM",
+						Path:      "SOFTWARE\\Microsoft\\Windows",
+						Operation: "read",
+						Tags:      []string{"quick"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "profile selecting no query",
+			config: &RegistryConfig{
+				Version:  "1.0",
+				Profiles: map[string]ReportProfile{"quick": {Tags: []string{"qiuck"}}},
+				Queries: []RegistryQuery{
+					{
+						Name:      "test_query",
+						RootKey:   "HKLM",
+						Path:      "SOFTWARE\\Microsoft\\Windows",
+						Operation: "read",
+						Tags:      []string{"quick"},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid query",
 			config: &RegistryConfig{
@@ -496,3 +944,69 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+// benchmarkPaths are registry paths of the shapes reports read, shortest to
+// longest
+var benchmarkPaths = map[string]string{
+	"short":  `SOFTWARE\Microsoft`,
+	"policy": `SOFTWARE\Policies\Microsoft\Windows NT\Terminal Services`,
+	"deep":   `SYSTEM\CurrentControlSet\Control\SecurityProviders\SCHANNEL\Protocols\TLS 1.2\Server`,
+	"long":   strings.Repeat(`Sub Key-1.2_(x)\`, 30) + "Leaf",
+}
+
+// BenchmarkValidateRegistryPath measures path validation, which runs for
+// every query of every scan
+func BenchmarkValidateRegistryPath(b *testing.B) {
+	for name, path := range benchmarkPaths {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateRegistryPath(path); err != nil {
+					b.Fatalf("ValidateRegistryPath(%q) error = %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkValidateConfig measures validating the shipped reports, and a
+// large config built by repeating their queries
+func BenchmarkValidateConfig(b *testing.B) {
+	files, err := filepath.Glob(filepath.Join("..", "configs", "reports", "*.json"))
+	if err != nil || len(files) == 0 {
+		b.Skip("shipped reports not found")
+	}
+
+	large := &RegistryConfig{Version: "1.0"}
+	for _, file := range files {
+		config, err := LoadRegistryConfig(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateConfig(config); err != nil {
+					b.Fatalf("ValidateConfig(%s) error = %v", name, err)
+				}
+			}
+		})
+		large.Queries = append(large.Queries, config.Queries...)
+	}
+
+	for len(large.Queries) < 1000 {
+		large.Queries = append(large.Queries, large.Queries...)
+	}
+	for i := range large.Queries {
+		large.Queries[i].Name = fmt.Sprintf("query_%d", i)
+	}
+	b.Run(fmt.Sprintf("%d_queries", len(large.Queries)), func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := ValidateConfig(large); err != nil {
+				b.Fatalf("ValidateConfig() error = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RunConcurrently calls run for each index from 0 to n-1, with at most
+// workers calls in flight (fewer than 1 runs them one at a time, in
+// order). Callers store each outcome at its index, so results keep the
+// order of the inputs however the calls interleave.
+//
+// Each call gets a context that ends after timeout; 0 leaves deadlines to
+// the callee, e.g. the reader's own timeout. Once ctx is done no further
+// calls start, and its error is returned after the calls in flight finish.
+func RunConcurrently(ctx context.Context, n, workers int, timeout time.Duration, run func(ctx context.Context, i int)) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	call := func(i int) {
+		callCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		run(callCtx, i)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				call(i)
+			}
+		}()
+	}
+
+	var err error
+dispatch:
+	for i := 0; i < n; i++ {
+		// Checked first so a cancelled run never starts another call,
+		// even when a worker is free
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	results := make([]int, 50)
+
+	err := RunConcurrently(context.Background(), len(results), 4, 0, func(ctx context.Context, i int) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+	})
+	if err != nil {
+		t.Fatalf("RunConcurrently() = %v", err)
+	}
+
+	for i, got := range results {
+		if got != i*i {
+			t.Fatalf("results[%d] = %d, want %d", i, got, i*i)
+		}
+	}
+	if p := peak.Load(); p > 4 {
+		t.Errorf("%d calls ran at once, want at most 4", p)
+	}
+}
+
+func TestRunConcurrently_Sequential(t *testing.T) {
+	var order []int
+	err := RunConcurrently(context.Background(), 5, 0, 0, func(ctx context.Context, i int) {
+		order = append(order, i)
+	})
+	if err != nil {
+		t.Fatalf("RunConcurrently() = %v", err)
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("call %d ran index %d; workers below 1 should run in order", i, got)
+		}
+	}
+}
+
+func TestRunConcurrently_Timeout(t *testing.T) {
+	errs := make([]error, 3)
+	err := RunConcurrently(context.Background(), len(errs), 3, 10*time.Millisecond, func(ctx context.Context, i int) {
+		if i == 1 {
+			<-ctx.Done()
+		}
+		errs[i] = ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("RunConcurrently() = %v", err)
+	}
+	if !errors.Is(errs[1], context.DeadlineExceeded) {
+		t.Errorf("slow call ended with %v, want a deadline", errs[1])
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("fast calls ended with %v and %v, want no error", errs[0], errs[2])
+	}
+}
+
+func TestRunConcurrently_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	ran := 0
+
+	err := RunConcurrently(ctx, 100, 2, 0, func(ctx context.Context, i int) {
+		mu.Lock()
+		ran++
+		if ran == 3 {
+			cancel()
+		}
+		mu.Unlock()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunConcurrently() = %v, want context.Canceled", err)
+	}
+	if ran > 5 {
+		t.Errorf("%d calls ran after cancelling at the third, want no more than the calls in flight", ran)
+	}
+}